@@ -30,7 +30,9 @@ async function main() {
 		entryPoints: {
 			'server': 'server/src/server.ts',
 			'indexer/worker': 'server/src/indexer/worker.ts',
-			'SqlWorker': 'server/src/storage/SqlWorker.ts'
+			'SqlWorker': 'server/src/storage/SqlWorker.ts',
+			'api/index': 'server/src/api/index.ts',
+			'cli/main': 'server/src/cli/main.ts'
 		},
 		bundle: true,
 		format: 'cjs',
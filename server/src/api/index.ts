@@ -0,0 +1,186 @@
+/**
+ * Smart Indexer library API.
+ *
+ * The stable entry point for embedding the indexer (`smart-indexer-server/api`).
+ * Everything else under src/ is internal to the language server and may
+ * change between releases.
+ */
+
+export { Indexer, createIndexer } from './indexer.js';
+export type {
+  IndexerOptions,
+  BenchOptions,
+  IndexArchiveOptions,
+  IndexDirOptions,
+  IndexDirResult,
+  IndexRootsResult,
+  IndexUpdateResult,
+  IndexToFileOptions,
+  IndexToFileResult,
+  IndexerStats,
+  Daemon,
+  DaemonOptions,
+  OverlayMap,
+  OverlayResult,
+  CommentMarkerOptions,
+  CoverageOptions,
+  PullOptions,
+  PullResult,
+  RemoteOptions,
+  VerifyIndexOptions,
+  VerifyIndexResult
+} from './indexer.js';
+export type {
+  StructuredQueryOptions,
+  StructuredQueryPage,
+  StructuredQueryPageOptions,
+  StructuredQueryResult
+} from '../features/structuredQuery.js';
+export { ScopeError } from '../features/searchScopes.js';
+export type { SavedQuery, ScopeOptions, SearchScope } from '../features/searchScopes.js';
+export { CursorError } from '../utils/pagination.js';
+export type { Page, PageOptions } from '../utils/pagination.js';
+export type { ArchiveFormat, ArchiveSource } from '../utils/archiveReader.js';
+export type { OutlineNode, OutlineRange, OutlinePosition } from '../features/outline.js';
+export type { PositionLookupResult } from '../features/positionLookup.js';
+export type { CommentMarker, CommentMarkerQuery, CommentMarkerReport } from '../features/commentMarkers.js';
+export type { HttpFramework, HttpRoute, HttpRouteQuery, HttpRouteReport } from '../features/httpRoutes.js';
+export type { SqlFunctionUsage, SqlQuery, SqlQueryFilter, SqlQueryReport } from '../features/sqlQueries.js';
+export type { SqlColumnRef, SqlOperation, SqlTableRef } from '../utils/sqlParser.js';
+export type { ConfigKey, ConfigKeyQuery, ConfigKeyReport, ConfigKeyUse, ConfigSource } from '../features/configKeys.js';
+export type {
+  ConcurrencyEvent,
+  ConcurrencyFunctionUsage,
+  ConcurrencyOp,
+  ConcurrencyQuery,
+  ConcurrencyReport
+} from '../features/concurrency.js';
+export type {
+  ErrorFunctionSummary,
+  ErrorPathEvent,
+  ErrorPathKind,
+  ErrorPathQuery,
+  ErrorPathReport
+} from '../features/errorPaths.js';
+export type { CoverageEntry, CoverageProfileStatus, CoverageQuery, CoverageReport, SymbolCoverage } from '../features/coverage.js';
+export type { GoTestFunction, GoTestPackage, TestsForQuery, TestsForReport } from '../features/goTests.js';
+export type { GoTestKind } from '../indexer/goIndexer.js';
+export type { AffectedTestsQuery, AffectedTestsReport, ChangedSymbol } from '../features/affectedTests.js';
+export type { GraphQLErrorJson, GraphQLResult } from '../utils/graphql.js';
+export type {
+  DocCodeBlock, DocDefinition, DocFormat, DocLink, DocLinkReport, DocMention, DocMentionQuery, DocMentionReport,
+  DocSearchHit, DocSearchQuery, DocSearchReport, DocSection
+} from '../features/docsIndex.js';
+export { QueryServer } from '../features/queryServer.js';
+export type { QueryResponse } from '../features/queryServer.js';
+export { QueryServerHost } from '../features/queryServerHost.js';
+export { AccessRuleError, QueryServerAuth } from '../features/queryServerAuth.js';
+export type { QueryPrincipal, QueryServerAccessRule } from '../features/queryServerAuth.js';
+export type { QueryListenOptions, QueryServerTls } from '../features/queryListener.js';
+export type { TypeHierarchyDirection, TypeHierarchyNode, TypeHierarchyQueryOptions } from '../features/typeHierarchy.js';
+export type {
+  RenameImpact,
+  RenameLocation,
+  RenameCollision,
+  RenameCollisionKind,
+  RenameBlastRadius,
+  RenameRisk
+} from '../features/renameImpact.js';
+export type {
+  CloneDetectionOptions,
+  CloneReport,
+  CloneCluster,
+  CloneMember
+} from '../features/cloneDetection.js';
+export type {
+  CodeMetric,
+  CodeMetricsOptions,
+  CodeMetricsReport,
+  FunctionMetricsEntry
+} from '../features/codeMetrics.js';
+export type { OwnershipOptions, OwnershipReport, OwnerSummary } from '../features/ownership.js';
+export type {
+  DeprecationOptions,
+  DeprecationReport,
+  DeprecatedCallSite,
+  DeprecatedSymbolUsage,
+  TeamDeprecationUsage
+} from '../features/deprecations.js';
+export type { ParseErrorOptions, ParseErrorReport, FileParseErrors } from '../features/parseErrors.js';
+export type { ApiSurfaceOptions, ApiSurfaceReport, IndexedGoModule } from '../features/apiSurface.js';
+export { formatApiSurface } from '../features/apiSurface.js';
+export type { SemverChange, SemverCheckOptions, SemverLevel, SemverReport } from '../features/semverCheck.js';
+export type { PolicyCheckOptions, PolicyReport, PolicyReportFormat, PolicyRule, PolicyViolation } from '../features/policyCheck.js';
+export { formatPolicyReport } from '../features/policyCheck.js';
+export type {
+  SarifAnalysis,
+  SarifAnalysisName,
+  SarifFinding,
+  SarifLevel,
+  SarifLog,
+  SarifOptions,
+  SarifResult,
+  SarifRule,
+  SarifRun
+} from '../features/sarif.js';
+export {
+  DEFAULT_METRIC_THRESHOLDS,
+  SARIF_ANALYSES,
+  deprecationFindings,
+  metricFindings,
+  policyFindings,
+  toSarif
+} from '../features/sarif.js';
+export type { ApiSymbol } from '../features/indexDiff.js';
+export type { IndexProblem, IndexProblemKind } from '../features/indexVerifier.js';
+export type { RankedSymbol } from '../utils/fuzzySearch.js';
+export type { PopularityScore } from '../utils/popularity.js';
+export type { WorkspaceRoot, WorkspaceRootSummary } from '../utils/workspaceRoots.js';
+export type { PushIndexResult, RemoteIndexManifest } from '../features/remoteIndex.js';
+export { connectDaemon, DaemonClient, daemonSocketPath, DAEMON_SOCKET, findDaemon, repositoryRoot } from '../features/daemon.js';
+export type { DaemonResponse } from '../features/daemon.js';
+export { completionScript, COMPLETION_KINDS, COMPLETION_SHELLS } from '../features/completions.js';
+export type { CompletionKind, CompletionQuery, CompletionScriptOptions, CompletionShell } from '../features/completions.js';
+export { RemoteStorageError, REMOTE_TOKEN_ENV_VAR } from '../utils/remoteStorage.js';
+export type { RemoteFetch } from '../utils/remoteStorage.js';
+export { parseQuery, formatQuery, QuerySyntaxError, QUERY_FIELDS } from '../utils/queryLanguage.js';
+export type { QueryNode, QueryTerm, QueryField } from '../utils/queryLanguage.js';
+export { parseTemplate, renderTemplate, TemplateError } from '../utils/outputTemplate.js';
+export { ConfigFileError, CONFIG_FILE_NAMES, PROFILE_ENV_VAR, TRUSTED_SETTING_KEYS } from '../config/configFile.js';
+export { buildTimestamp, SOURCE_DATE_EPOCH_ENV_VAR } from '../utils/buildTimestamp.js';
+export type { PolicyConfig, ThrottleConfig, ThrottleMode } from '../config/configurationManager.js';
+export { IndexThrottle } from '../index/indexThrottle.js';
+export type { IndexingKind, IndexThrottleOptions, ThrottleRunOptions } from '../index/indexThrottle.js';
+export { isOnBattery } from '../utils/powerSource.js';
+export type { EncryptionConfig } from '../config/configurationManager.js';
+export {
+  CommandKeyProvider,
+  EnvKeyProvider,
+  IndexEncryptionError,
+  INDEX_KEY_ENV_VAR,
+  isEncryptedIndex
+} from '../index/indexEncryption.js';
+export type { IndexKeyProvider, KeyCommandRunner, WrappedKey } from '../index/indexEncryption.js';
+export type { OutputTemplate } from '../utils/outputTemplate.js';
+export { CancellationError, CancellationTokenSource } from '../utils/asyncUtils.js';
+export type { CancellationToken, ProgressCallback } from '../utils/asyncUtils.js';
+export { formatProgress } from '../utils/indexingProgress.js';
+export { formatBenchmark } from '../profiler/benchmark.js';
+export type { BenchmarkResult, BenchmarkRun } from '../profiler/benchmark.js';
+export { ProfilerBusyError, SamplingProfiler, toPprof, writeProfile } from '../profiler/sampling.js';
+export type { CpuProfile, HeapProfile, Profile, ProfileKind, SamplingProfilerOptions } from '../profiler/sampling.js';
+export type { IndexingProgress, IndexingPhase, IndexingProgressListener } from '../utils/indexingProgress.js';
+export { LoggerService, NullLogger, LogLevel, LOG_SCOPES, parseLogLevel, parseLogLevels } from '../utils/Logger.js';
+export type { ILogger, LogFormat, LogLevels, LogWriter } from '../utils/Logger.js';
+export type {
+  IndexedSymbol,
+  IndexedReference,
+  IndexedFileResult,
+  ImportInfo,
+  SymbolLocation,
+  SymbolRange,
+  TypeParameter,
+  FunctionMetrics,
+  CodeTag,
+  ParseDiagnostic
+} from '../types.js';
@@ -0,0 +1,885 @@
+/**
+ * Indexer (library API) Tests
+ *
+ * Verifies directory and multi-root indexing, queries, search ranking,
+ * parse error listing, save/load and push/pull round trips, policy checks,
+ * updates from git changes, index verification, reproducible and encrypted
+ * index files, benchmarks and profiles.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { connectDaemon, createIndexer, CancellationError, EnvKeyProvider, formatBenchmark, INDEX_KEY_ENV_VAR, isEncryptedIndex, formatPolicyReport, policyFindings, toSarif, Indexer, IndexingProgress, LoggerService, RemoteFetch } from './index.js';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+import { Readable } from 'stream';
+import { decode } from '@msgpack/msgpack';
+
+describe('Indexer', () => {
+  let testDir: string;
+  let indexer: Indexer;
+
+  function write(relativePath: string, content: string): string {
+    const filePath = path.join(testDir, relativePath);
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, content);
+    return filePath;
+  }
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-api-'));
+    write('pkg/user/user.go', [
+      'package user',
+      '',
+      'type Person struct{ Name string }',
+      '',
+      'func (p *Person) Greet() string { return "hi " + p.Name }',
+      ''
+    ].join('\n'));
+    write('pkg/user/user_test.go', 'package user\n\nfunc TestGreet(t *testing.T) { (&Person{}).Greet() }\n');
+    write('tools/report.py', 'MAX_ROWS = 100\n\ndef render(rows):\n    return rows\n');
+    write('vendor/ignored.go', 'package vendor\n\nfunc Ignored() {}\n');
+    write('.gitignore', 'vendor/\n');
+    indexer = createIndexer();
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should index a directory and answer queries', async () => {
+    const progress: number[] = [];
+    const events: IndexingProgress[] = [];
+    const result = await indexer.indexDir(testDir, {
+      onProgress: done => progress.push(done),
+      onIndexingProgress: event => events.push(event)
+    });
+    expect(result.files).toBe(3);
+    expect(result.skipped).toBe(0);
+    expect(progress[progress.length - 1]).toBe(3);
+    expect(events[0]).toMatchObject({ phase: 'indexing', filesScanned: 3, filesIndexed: 0 });
+    expect(events[events.length - 1]).toMatchObject({ phase: 'idle', filesParsed: 3, filesIndexed: 3 });
+    expect(events[events.length - 1].bytesProcessed).toBe(
+      ['pkg/user/user.go', 'pkg/user/user_test.go', 'tools/report.py'].reduce((sum, f) => sum + fs.statSync(path.join(testDir, f)).size, 0)
+    );
+
+    expect((await indexer.findDefinitions('Greet')).map(s => s.containerName)).toEqual(['Person']);
+    expect((await indexer.findReferences('Greet')).map(r => path.basename(r.location.uri))).toContain('user_test.go');
+    expect((await indexer.search('MAX_ROWS')).map(s => s.value)).toEqual(['100']);
+    expect(await indexer.findDefinitions('Ignored')).toHaveLength(0);
+
+    const methods = await indexer.query('kind:method receiver:*Person');
+    expect(methods.symbols.map(s => s.name)).toEqual(['Greet']);
+    const tests = await indexer.query('tag:test lang:go');
+    expect(tests.symbols.map(s => s.name)).toEqual(['TestGreet']);
+  });
+
+  it('should page searches, references and queries', async () => {
+    write('pkg/user/more.go', 'package user\n\nfunc use(p *Person) {\n\tp.Greet()\n\tp.Greet()\n\tp.Greet()\n}\n');
+    await indexer.indexDir(testDir);
+
+    const references = await indexer.findReferences('Greet');
+    const first = await indexer.findReferencesPage('Greet', { limit: 2 });
+    const rest = await indexer.findReferencesPage('Greet', { cursor: first.nextCursor });
+    expect([...first.items, ...rest.items]).toEqual(references);
+    expect(rest.nextCursor).toBeUndefined();
+
+    const ranked = (await indexer.searchRanked('Greet')).map(r => r.symbol.id);
+    expect(ranked.length).toBeGreaterThan(1);
+    const search = await indexer.searchPage('Greet', { limit: 1 });
+    const next = await indexer.searchPage('Greet', { limit: 1, cursor: search.nextCursor });
+    expect([search.items[0].symbol.id, next.items[0].symbol.id, next.offset]).toEqual([ranked[0], ranked[1], 1]);
+
+    const methods = await indexer.queryPage('lang:go', { limit: 1, offset: 1 });
+    expect([methods.offset, methods.symbols.length, methods.truncated]).toEqual([1, 1, true]);
+  });
+
+  it('should search the docs and find the docs mentioning a symbol', async () => {
+    const readme = write('pkg/user/README.md', '# Users\n\n## Greeting\n\nCall `Person.Greet` or `Greet()`.\n');
+    write('docs/guide.adoc', '= Guide\n\n== Setup\n\n[source,go]\n----\nuser.Person{}\n----\n');
+    await indexer.indexDir(testDir);
+
+    expect((await indexer.docs({ text: 'greeting' })).sections.map(s => [s.heading, s.parents])).toEqual([['Greeting', ['Users']]]);
+    expect((await indexer.docs({ language: 'go' })).sections.map(s => s.anchor)).toEqual(['_setup']);
+    expect((await indexer.docMentions('Person.Greet')).mentions.map(m => m.text)).toEqual(['Person.Greet', 'Greet()']);
+    expect((await indexer.docLinks(readme)).resolved).toBe(2);
+  });
+
+  it('should log to an injected logger under subsystem scopes', async () => {
+    const lines: string[] = [];
+    const logger = new LoggerService(line => lines.push(line));
+    logger.setFormat('json');
+    const logged = createIndexer({ logger });
+
+    await logged.indexDir(testDir);
+    await logged.save(path.join(testDir, 'out', 'index.bin'));
+    const entries = lines.map(line => JSON.parse(line));
+
+    expect(entries.some(e => e.scope === 'walker' && e.message.includes('Workspace scan complete. Found 3'))).toBe(true);
+    expect(entries.find(e => e.scope === 'index')).toMatchObject({ level: 'INFO' });
+    expect(entries.find(e => e.scope === 'index').message).toContain('Indexed 3 files');
+    expect(entries.find(e => e.scope === 'store').message).toContain('Saved 3 files');
+  });
+
+  it('should rank searches by how often symbols are referenced', async () => {
+    write('pkg/store/store.go', [
+      'package store',
+      '',
+      'func NewServer() {}',
+      'func NewStore() {}',
+      '',
+      'func main() {',
+      '\tNewStore()',
+      '\tNewStore()',
+      '\tNewServer()',
+      '}',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const ranked = await indexer.searchRanked('NewS');
+    expect(ranked.map(r => r.symbol.name)).toEqual(['NewStore', 'NewServer']);
+    expect(ranked[0].popularity).toMatchObject({ references: 2, exported: 5 });
+    expect((await indexer.search('NewS', 1)).map(s => s.name)).toEqual(['NewStore']);
+  });
+
+  it('should outline a file with methods under their receiver type', async () => {
+    await indexer.indexDir(testDir);
+    const outline = await indexer.outline(path.join(testDir, 'pkg/user/user.go'));
+    expect(outline.map(node => [node.name, node.kind])).toEqual([['Person', 'struct']]);
+    expect(outline[0].children!.map(node => node.name)).toEqual(['Name', 'Greet']);
+    expect(outline[0].children![1].range.start).toEqual({ line: 4, character: 0 });
+  });
+
+  it('should lay unsaved buffers over the index without saving them', async () => {
+    await indexer.indexDir(testDir);
+    const buffer = 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Wave() string { return "bye" }\n';
+
+    const overlay = await indexer.overlayFile('pkg/user/user.go', buffer);
+    expect(overlay.uri).toBe(path.join(testDir, 'pkg/user/user.go'));
+    expect(overlay.shadowsIndexed).toBe(true);
+    expect(overlay.outline[0].children!.map(node => node.name)).toEqual(['Name', 'Wave']);
+    expect(await indexer.findDefinitions('Greet')).toHaveLength(0);
+    expect((await indexer.overlayFile('pkg/user/draft.go', 'package user\n\nfunc Draft() {}\n')).shadowsIndexed).toBe(false);
+
+    // Indexing from disk updates what the buffer shadows, not the buffer
+    write('pkg/user/user.go', 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Hello() string { return "" }\n');
+    await indexer.indexDir(testDir);
+    expect((await indexer.findDefinitions('Wave')).map(s => s.name)).toEqual(['Wave']);
+    expect(await indexer.findDefinitions('Draft')).toHaveLength(1);
+
+    const savePath = path.join(testDir, 'out', 'index.bin');
+    await indexer.save(savePath);
+    const loaded = createIndexer();
+    await loaded.load(savePath);
+    expect((await loaded.findDefinitions('Hello')).map(s => s.name)).toEqual(['Hello']);
+    expect(await loaded.findDefinitions('Wave')).toHaveLength(0);
+    expect(await loaded.findDefinitions('Draft')).toHaveLength(0);
+
+    expect(indexer.overlaidFiles()).toEqual([path.join(testDir, 'pkg/user/draft.go'), path.join(testDir, 'pkg/user/user.go')]);
+    expect(indexer.clearOverlay()).toBe(2);
+    expect((await indexer.findDefinitions('Hello')).map(s => s.name)).toEqual(['Hello']);
+    expect(await indexer.findDefinitions('Draft')).toHaveLength(0);
+  });
+
+  it('should query the index as if files had overlay contents', async () => {
+    await indexer.indexDir(testDir);
+    await indexer.overlayFile('tools/report.py', 'def render_all(rows):\n    return rows\n');
+    const edited = 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Wave() string { return "" }\n';
+
+    const names = await indexer.withOverlay({ 'pkg/user/user.go': edited, 'pkg/user/new.go': 'package user\n\nfunc NewPerson() {}\n' }, async () =>
+      (await indexer.query('kind:func,method')).symbols.map(s => s.name).sort());
+    expect(names).toEqual(['NewPerson', 'TestGreet', 'Wave', 'render_all']);
+    // Back to the overlays set before
+    expect(indexer.overlaidFiles()).toEqual([path.join(testDir, 'tools/report.py')]);
+    expect(await indexer.findDefinitions('Greet')).toHaveLength(1);
+    await expect(indexer.withOverlay({ 'pkg/user/user.go': edited }, async () => { throw new Error('boom'); })).rejects.toThrow('boom');
+    expect(await indexer.findDefinitions('Wave')).toHaveLength(0);
+
+    await indexer.setOverlay(new Map([[path.join(testDir, 'pkg/user/user.go'), edited]]));
+    expect(indexer.overlaidFiles()).toEqual([path.join(testDir, 'pkg/user/user.go')]);
+    expect(await indexer.findDefinitions('render')).toHaveLength(1);
+  });
+
+  it('should serve the index as a daemon on a socket under the root', async () => {
+    // Clients search for the socket up to the repository root
+    fs.mkdirSync(path.join(testDir, '.git'));
+    const daemon = await indexer.startDaemon(testDir, { watch: false });
+    try {
+      expect(daemon.indexed.files).toBe(3);
+      expect(daemon.socketPath).toBe(path.join(testDir, '.smart-index', 'daemon.sock'));
+      await expect(createIndexer().startDaemon(testDir, { watch: false })).rejects.toThrow('A daemon is already running');
+
+      const client = await connectDaemon(path.join(testDir, 'pkg', 'user'));
+      const { body } = await client!.get('/definition?name=Greet');
+      expect((body as { definitions: { name: string }[] }).definitions.map(s => s.name)).toEqual(['Greet']);
+    } finally {
+      await daemon.stop();
+    }
+    expect(fs.existsSync(daemon.socketPath)).toBe(false);
+    expect(await connectDaemon(testDir)).toBeUndefined();
+  });
+
+  it('should resolve the definition at a file position', async () => {
+    await indexer.indexDir(testDir);
+    const testFile = path.join(testDir, 'pkg/user/user_test.go');
+
+    const call = await indexer.definitionAt(testFile, 2, 45);
+    expect(call).toMatchObject({ name: 'Greet', role: 'reference' });
+    expect(call!.definitions.map(s => [path.basename(s.location.uri), s.location.line, s.containerName])).toEqual([['user.go', 4, 'Person']]);
+
+    const declaration = await indexer.definitionAt(path.join(testDir, 'pkg/user/user.go'), 2, 6);
+    expect(declaration).toMatchObject({ name: 'Person', role: 'definition' });
+    expect(await indexer.definitionAt(testFile, 1, 0)).toBeNull();
+  });
+
+  it('should return embedding hierarchies as trees', async () => {
+    write('pkg/user/admin.go', 'package user\n\ntype AdminUser struct {\n\t*Person\n\tsync.Mutex\n}\n');
+    await indexer.indexDir(testDir);
+
+    const [supertypes] = await indexer.typeHierarchy('AdminUser');
+    expect(supertypes.children.map(node => [node.name, node.type, !!node.location])).toEqual([
+      ['Person', '*Person', true],
+      ['Mutex', 'sync.Mutex', false]
+    ]);
+    const [subtypes] = await indexer.typeHierarchy('Person', 'subtypes');
+    expect(subtypes.children.map(node => node.name)).toEqual(['AdminUser']);
+  });
+
+  it('should rank functions by metrics computed while indexing', async () => {
+    write('tools/route.py', [
+      'def route(request):',
+      '    if request.method == "GET" and request.path:',
+      '        for handler in HANDLERS:',
+      '            if handler.matches(request):',
+      '                return handler',
+      '    return None',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.functionMetrics({ limit: 2 });
+    expect(report.functions.map(f => [f.symbol.name, f.complexity, f.nesting, f.parameters])).toEqual([
+      ['route', 5, 3, 1],
+      ['Greet', 1, 0, 0]
+    ]);
+    expect(report.functionsAnalyzed).toBe(4);
+  });
+
+  it('should index broken files and list their parse errors', async () => {
+    write('pkg/user/broken.go', 'package user\n\nfunc Broken() {\n\treturn\n\nfunc Recovered() {}\n');
+    await indexer.indexDir(testDir);
+
+    expect((await indexer.findDefinitions('Recovered')).map(s => path.basename(s.location.uri))).toEqual(['broken.go']);
+    const report = await indexer.parseErrors();
+    expect(report.files).toEqual([
+      { uri: path.join(testDir, 'pkg/user/broken.go'), diagnostics: [{ message: "Unclosed '{'", line: 2, character: 14 }] }
+    ]);
+    expect(report.filesScanned).toBe(4);
+  });
+
+  it('should assign owners from CODEOWNERS', async () => {
+    write('.github/CODEOWNERS', '* @org/maintainers\n/pkg/user/ @org/identity\n');
+    await indexer.indexDir(testDir);
+
+    expect(indexer.owners(path.join(testDir, 'pkg/user/user.go'))).toEqual(['@org/identity']);
+    const api = await indexer.query('exported:true owner:@org/identity -tag:test');
+    expect(api.symbols.map(s => s.name)).toEqual(['Person', 'Name', 'Greet']);
+
+    const report = await indexer.ownership({ tags: { exclude: ['test'] } });
+    expect(report.owners.map(summary => [summary.owner, summary.files, summary.exported])).toEqual([
+      ['@org/identity', 1, 3],
+      ['@org/maintainers', 1, 2]
+    ]);
+  });
+
+  it('should report uses of deprecated symbols by owner', async () => {
+    write('.github/CODEOWNERS', '/pkg/ @org/identity\n/web/ @org/web\n');
+    write('pkg/user/user.go', [
+      'package user',
+      '',
+      '// Deprecated: use Find.',
+      'func Lookup(name string) string { return Find(name) }',
+      '',
+      'func Find(name string) string { return name }',
+      ''
+    ].join('\n'));
+    write('pkg/user/user_test.go', 'package user\n\nfunc TestLookup(t *testing.T) { Lookup("x") }\n');
+    write('web/handler.go', [
+      'package web',
+      '',
+      'import "example.com/app/pkg/user"',
+      '',
+      'func Render() { user.Lookup("page") }',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.deprecations({ tags: { exclude: ['test'] } });
+    expect(report.deprecated.map(d => [d.symbol.name, d.notice])).toEqual([['Lookup', 'use Find.']]);
+    expect(report.teams.map(team => [team.owner, team.callSites])).toEqual([['@org/web', 1]]);
+    expect(report.teams[0].symbols[0].callSites[0]).toMatchObject({ line: 4, enclosing: 'Render' });
+    expect((await indexer.deprecations()).teams.map(team => [team.owner, team.callSites])).toEqual([['@org/identity', 1], ['@org/web', 1]]);
+  });
+
+  it('should list HTTP routes with their handlers', async () => {
+    write('api/routes.go', [
+      'package api',
+      '',
+      'import "github.com/gin-gonic/gin"',
+      '',
+      'func Register(r *gin.Engine) {',
+      '\tv1 := r.Group("/v1")',
+      '\tv1.GET("/users", ListUsers)',
+      '}',
+      '',
+      'func ListUsers(c *gin.Context) {}',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.httpRoutes({ method: 'GET' });
+    expect(report.routes.map(route => [route.method, route.path, route.framework])).toEqual([['GET', '/v1/users', 'gin']]);
+    expect(report.routes[0].definition).toMatchObject({ name: 'ListUsers', kind: 'function', location: { line: 9 } });
+  });
+
+  it('should find the functions using a table', async () => {
+    write('store/users.go', [
+      'package store',
+      '',
+      'import "database/sql"',
+      '',
+      'type Users struct{ db *sql.DB }',
+      '',
+      'func (u *Users) Delete(id int) error {',
+      '\t_, err := u.db.Exec("DELETE FROM users WHERE id = ?", id)',
+      '\treturn err',
+      '}',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.sqlQueries({ table: 'users' });
+    expect(report.functions.map(usage => [usage.name, usage.operations])).toEqual([['Users.Delete', ['delete']]]);
+    expect(report.queries[0]).toMatchObject({ line: 7, tables: [{ name: 'users', access: 'write' }] });
+    expect((await indexer.sqlQueries({ table: 'orders' })).total).toBe(0);
+  });
+
+  it('should list the configuration keys the code reads', async () => {
+    write('cmd/main.go', [
+      'package main',
+      '',
+      'import (',
+      '\t"flag"',
+      '\t"os"',
+      ')',
+      '',
+      'func main() {',
+      '\tverbose := flag.Bool("verbose", false, "log more")',
+      '\tdsn := os.Getenv("DATABASE_URL")',
+      '}',
+      ''
+    ].join('\n'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.configKeys();
+    expect(report.keys.map(key => [key.source, key.key, key.defaults])).toEqual([
+      ['env', 'DATABASE_URL', []],
+      ['flag', 'verbose', ['false']]
+    ]);
+    expect(report.keys[0].uses[0]).toMatchObject({ line: 9, call: 'Getenv' });
+    expect((await indexer.configKeys({ source: 'viper' })).total).toBe(0);
+  });
+
+  it('should index several roots into one workspace', async () => {
+    const svcA = path.join(testDir, 'repos', 'svc-a');
+    const svcB = path.join(testDir, 'repos', 'svc-b');
+    write('repos/svc-a/client/client.go', 'package client\n\nfunc Fetch() { store.NewStore() }\n');
+    write('repos/svc-a/CODEOWNERS', '* @team-a\n');
+    write('repos/svc-b/store/store.go', 'package store\n\nfunc NewStore() {}\n');
+    write('repos/svc-b/gen/gen.go', 'package gen\n\nfunc Generated() {}\n');
+    write('repos/svc-b/.gitignore', 'gen/\n');
+    write('repos/svc-b/CODEOWNERS', '* @team-b\n');
+
+    const result = await indexer.indexRoots([svcA, svcB]);
+    expect(result.roots.map(r => [r.name, r.files])).toEqual([['svc-a', 1], ['svc-b', 1]]);
+    expect(result.files).toBe(2);
+
+    const [definition] = await indexer.findDefinitions('NewStore');
+    expect(indexer.rootOf(definition.location.uri)?.name).toBe('svc-b');
+    const references = await indexer.findReferences('NewStore');
+    expect(references.map(r => indexer.rootOf(r.location.uri)?.name)).toEqual(['svc-a']);
+    expect(await indexer.findDefinitions('Generated')).toHaveLength(0);
+    expect(indexer.owners(path.join(svcA, 'client/client.go'))).toEqual(['@team-a']);
+    expect(indexer.owners(path.join(svcB, 'store/store.go'))).toEqual(['@team-b']);
+
+    const roots = indexer.roots();
+    expect(roots.map(r => [r.path, r.name, r.files])).toEqual([[svcA, 'svc-a', 1], [svcB, 'svc-b', 1]]);
+    expect(roots[0].indexedAt).toBeGreaterThan(0);
+
+    const savePath = path.join(testDir, 'out', 'workspace.idx');
+    await indexer.save(savePath);
+    const loaded = createIndexer();
+    await loaded.load(savePath);
+    expect(loaded.roots()).toEqual(roots);
+
+    expect(indexer.removeRoot(svcA)).toBe(1);
+    expect(indexer.roots().map(r => r.name)).toEqual(['svc-b']);
+    expect(await indexer.findReferences('NewStore')).toHaveLength(0);
+  });
+
+  it('should apply the config file at the root, its profile and overriding options', async () => {
+    write('smart-indexer.yaml', [
+      'languages: [go]',
+      'profiles:',
+      '  tools:',
+      '    includePatterns: ["tools/**"]',
+      '    languages: [go, python]'
+    ].join('\n'));
+
+    // Not read unless asked for; smart-indexer.yaml is indexed itself then
+    expect((await indexer.indexDir(testDir)).files).toBe(4);
+    expect((await createIndexer({ configFile: true }).indexDir(testDir)).files).toBe(2);
+    expect((await createIndexer({ configFile: true, profile: 'tools' }).indexDir(testDir)).files).toBe(1);
+    expect((await createIndexer({ configFile: true, profile: 'tools', includePatterns: ['pkg/**'] }).indexDir(testDir)).files).toBe(2);
+    expect((await createIndexer({ configFile: false, languages: ['py'] }).indexDir(testDir)).files).toBe(1);
+
+    await expect(createIndexer({ configFile: true, profile: 'nightly' }).indexDir(testDir)).rejects.toThrow('Unknown profile "nightly" (defined: tools)');
+    expect(() => createIndexer({ configFile: path.join(testDir, 'missing.toml') })).toThrow('File not found');
+
+    write('smart-indexer.yaml', 'languages: [go]\nencryption: { enabled: true, provider: command, command: ./key.sh }\n');
+    const lines: string[] = [];
+    await createIndexer({ configFile: true, logger: new LoggerService(line => lines.push(line)) }).indexDir(testDir);
+    expect(lines.filter(line => line.includes('Ignoring encryption in'))).toHaveLength(1);
+  });
+
+  it('should search and query in the scopes of the config file and run its saved queries', async () => {
+    write('smart-indexer.yaml', [
+      'search:',
+      '  scopes:',
+      '    go-code: "lang:go -tag:test"',
+      '    tools: "file:tools/**"',
+      '  queries:',
+      '    exported-go: { query: "exported:true", scope: go-code, description: "Exported Go API" }',
+      '    functions: "kind:func"'
+    ].join('\n'));
+    const scoped = createIndexer({ configFile: true, search: { scopes: { python: 'lang:py' } } });
+    await scoped.indexDir(testDir);
+
+    expect((await scoped.search('Greet')).map(s => s.name).sort()).toEqual(['Greet', 'TestGreet']);
+    expect((await scoped.search('Greet', 50, { scope: 'go-code' })).map(s => s.name)).toEqual(['Greet']);
+    expect((await scoped.searchPage('Greet', { scope: 'go-code' })).items.map(r => r.symbol.name)).toEqual(['Greet']);
+    expect((await scoped.query('kind:func', { scope: 'python' })).symbols.map(s => s.name)).toEqual(['render']);
+    expect((await scoped.savedQuery('exported-go')).symbols.map(s => s.name).sort()).toEqual(['Greet', 'Name', 'Person']);
+    expect((await scoped.savedQuery('functions', { scope: 'tools' })).symbols.map(s => s.name)).toEqual(['render']);
+
+    expect(scoped.scopes()).toEqual({
+      scopes: [
+        { name: 'go-code', filter: 'lang:go -tag:test' },
+        { name: 'python', filter: 'lang:py' },
+        { name: 'tools', filter: 'file:tools/**' }
+      ],
+      queries: [
+        { name: 'exported-go', query: 'exported:true', scope: 'go-code', description: 'Exported Go API' },
+        { name: 'functions', query: 'kind:func' }
+      ]
+    });
+    await expect(scoped.search('Greet', 50, { scope: 'web' })).rejects.toThrow('Unknown scope "web" (defined: go-code, python, tools)');
+    await expect(scoped.savedQuery('todo')).rejects.toThrow('Unknown saved query "todo"');
+  });
+
+  it('should find duplicated functions', async () => {
+    const body = (name: string, arg: string) => [
+      `def ${name}(${arg}):`,
+      '    kept = []',
+      `    for item in ${arg}:`,
+      '        if item is None or item == "":',
+      '            continue',
+      '        kept.append(str(item).strip().lower())',
+      '    return kept',
+      ''
+    ].join('\n');
+    write('tools/clean.py', body('clean', 'values'));
+    write('tools/legacy/normalize.py', body('normalize_all', 'entries'));
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.findClones({ minTokens: 20 });
+    expect(report.clusters).toHaveLength(1);
+    expect(report.clusters[0].exact).toBe(true);
+    expect(report.clusters[0].members.map(m => m.symbol.name)).toEqual(['clean', 'normalize_all']);
+  });
+
+  it('should drop files that are gone when indexing again', async () => {
+    await indexer.indexDir(testDir);
+    fs.rmSync(path.join(testDir, 'tools'), { recursive: true });
+
+    const result = await indexer.indexDir(testDir);
+    expect(result.removed).toBe(1);
+    expect(await indexer.findDefinitions('render')).toHaveLength(0);
+  });
+
+  it('should scope Go symbols to Bazel targets and index their generated sources', async () => {
+    write('MODULE.bazel', 'module(name = "app")\n');
+    write('pkg/user/BUILD.bazel', [
+      'go_library(name = "user", srcs = ["user.go", ":names"])',
+      'genrule(name = "names", outs = ["names.go"], cmd = "gen > $@")',
+      'go_test(name = "user_test", srcs = ["user_test.go"], embed = [":user"])'
+    ].join('\n'));
+    write('pkg/user/scratch.go', 'package user\n\nfunc Scratch() {}\n');
+    write('bazel-bin/pkg/user/names.go', '// Code generated by gen. DO NOT EDIT.\n\npackage user\n\nconst DefaultName = "anna"\n');
+    const bazel = createIndexer({ bazel: true, languages: ['go'] });
+    await bazel.indexDir(testDir);
+
+    expect(await bazel.findDefinitions('Scratch')).toHaveLength(0);
+    expect((await bazel.query('target://pkg/user:user_test kind:function')).symbols.map(s => s.name)).toEqual(['TestGreet']);
+    expect((await bazel.query('target://pkg/user:user name:DefaultName')).symbols.map(s => path.relative(testDir, s.location.uri)))
+      .toEqual([path.join('bazel-bin', 'pkg', 'user', 'names.go')]);
+  });
+
+  it('should index an archive and a git archive stream without extracting them', async () => {
+    const archivePath = path.join(os.tmpdir(), `${path.basename(testDir)}-app.tar.gz`);
+    execFileSync('tar', ['-czf', archivePath, '-C', testDir, 'pkg', 'tools'], { stdio: 'ignore' });
+    try {
+      const result = await indexer.indexArchive(archivePath);
+      expect(result).toMatchObject({ root: archivePath.replace(/\.tar\.gz$/, ''), files: 3, removed: 0 });
+      const [greet] = await indexer.findDefinitions('Greet');
+      expect(greet.location.uri).toBe(path.join(result.root, 'pkg', 'user', 'user.go'));
+      expect(indexer.roots().map(root => root.path)).toEqual([result.root]);
+    } finally {
+      fs.rmSync(archivePath, { force: true });
+    }
+
+    const git = (...args: string[]) => execFileSync('git', args, {
+      cwd: testDir,
+      stdio: 'pipe',
+      env: { ...process.env, GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com', GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com' }
+    });
+    git('init', '-q');
+    git('add', '.');
+    git('commit', '-q', '-m', 'base');
+    const worker = createIndexer();
+    await expect(worker.indexArchive(Readable.from([git('archive', 'HEAD')]))).rejects.toThrow('needs a root');
+
+    git('rm', '-q', 'tools/report.py');
+    git('commit', '-q', '-m', 'drop report');
+    const stream = Readable.from([git('archive', '--format=zip', '--prefix=app/', 'HEAD')]);
+    const result = await worker.indexArchive(stream, { root: '/ci/app', stripComponents: 1 });
+    expect(result).toMatchObject({ files: 2, removed: 0 });
+    expect(await worker.findDefinitions('render')).toHaveLength(0);
+    expect((await worker.findDefinitions('Person'))[0].location.uri).toBe(path.resolve('/ci/app/pkg/user/user.go'));
+  });
+
+  it('should check API changes since a release against the release version', async () => {
+    const git = (...args: string[]) => execFileSync('git', args, {
+      cwd: testDir,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: { ...process.env, GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com', GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com' }
+    });
+    git('init', '-q');
+    git('add', '.');
+    git('commit', '-q', '-m', 'base');
+    git('tag', 'v1.4.0');
+    write('pkg/user/user.go', 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Rename(name string) { p.Name = name }\n');
+    git('commit', '-q', '-am', 'rename Greet');
+
+    const report = await indexer.semverCheck(testDir, 'v1.4.0..HEAD', { release: 'v1.5.0' });
+    expect([report.level, report.compatible, report.suggestedVersion]).toEqual(['major', false, 'v2.0.0']);
+    expect(report.changes.map(c => `${c.level} ${c.symbol.qualifiedName}`)).toEqual(['major Person.Greet', 'minor Person.Rename']);
+  });
+
+  it('should check the policies of the config file and write them as SARIF', async () => {
+    write('smart-indexer.yaml', [
+      'policies:',
+      '  - name: exported-docs',
+      '    rule: exported-doc',
+      '    paths: ["pkg/**"]'
+    ].join('\n'));
+    indexer = createIndexer({ configFile: true });
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.checkPolicies(testDir);
+    expect([report.passed, report.violations.map(v => v.symbol)]).toEqual([false, ['Person', 'Person.Greet']]);
+    expect(formatPolicyReport(report).split('\n')[0]).toBe(
+      'pkg/user/user.go:3:6: error: Exported struct Person has no doc comment; add a comment starting with "Person" [exported-docs]'
+    );
+    expect((await indexer.checkPolicies(testDir, [{ name: 'short', rule: 'max-metric', metric: 'loc', max: 5 }])).passed).toBe(true);
+
+    const [run] = toSarif([policyFindings(report)], testDir).runs;
+    expect(run.results.map(r => [r.ruleId, r.level, r.locations[0].physicalLocation.artifactLocation.uri])).toEqual([
+      ['exported-docs', 'error', 'pkg/user/user.go'],
+      ['exported-docs', 'error', 'pkg/user/user.go']
+    ]);
+  });
+
+  it('should update the index from the changes since a commit', async () => {
+    const git = (...args: string[]) => execFileSync('git', args, {
+      cwd: testDir,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: { ...process.env, GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com', GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com' }
+    });
+    git('init', '-q');
+    git('add', '.');
+    git('commit', '-q', '-m', 'base');
+    await indexer.indexDir(testDir);
+    const savePath = path.join(testDir, 'out', 'index.bin');
+    await indexer.save(savePath);
+
+    write('pkg/user/user.go', 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Rename(name string) { p.Name = name }\n');
+    write('pkg/user/admin.go', 'package user\n\nfunc NewAdmin() *Person { return &Person{} }\n');
+    write('vendor/extra.go', 'package vendor\n\nfunc Extra() {}\n');
+    git('rm', '-q', 'tools/report.py');
+
+    const result = await createIndexer().updateIndexFile(savePath, testDir, 'HEAD');
+    expect(result).toMatchObject({ added: 1, modified: 1, removed: 1, skipped: 0 });
+    expect(result.since).toBe(git('rev-parse', 'HEAD').trim());
+
+    const updated = createIndexer();
+    await updated.load(savePath);
+    expect((await updated.findDefinitions('Rename')).map(s => s.name)).toEqual(['Rename']);
+    expect(await updated.findDefinitions('Greet')).toHaveLength(0);
+    expect(await updated.findDefinitions('NewAdmin')).toHaveLength(1);
+    expect(await updated.findDefinitions('render')).toHaveLength(0);
+    expect(await updated.findDefinitions('Extra')).toHaveLength(0);
+    await expect(updated.update(testDir, 'no-such-commit')).rejects.toThrow('Unknown commit');
+  });
+
+  it('should list comment markers with their git authors', async () => {
+    const git = (...args: string[]) => execFileSync('git', args, {
+      cwd: testDir,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: { ...process.env, GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com', GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com' }
+    });
+    write('pkg/user/user.go', 'package user\n\n// TODO(bob): validate names\ntype Person struct{ Name string }\n');
+    write('tools/report.py', '# PERF: render in batches\ndef render(rows):\n    return rows\n');
+    git('init', '-q');
+    git('add', '.');
+    git('commit', '-q', '-m', 'base');
+    write('pkg/user/user_test.go', 'package user\n\n// FIXME: flaky\nfunc TestGreet(t *testing.T) {}\n');
+    indexer = createIndexer({ commentMarkers: { tags: ['PERF'] } });
+    await indexer.indexDir(testDir);
+
+    const report = await indexer.commentMarkers({ blame: true, sort: 'age' });
+    expect(report.byTag).toEqual({ TODO: 1, PERF: 1, FIXME: 1 });
+    expect(report.markers.map(m => [m.tag, m.owner, m.author, m.ageDays])).toEqual([
+      ['TODO', 'bob', 'Ada', 0],
+      ['FIXME', undefined, 'Not Committed Yet', 0],
+      ['PERF', undefined, 'Ada', 0]
+    ]);
+    expect((await indexer.commentMarkers({ author: 'ada@example.com', blame: true })).total).toBe(2);
+    expect((await indexer.commentMarkers({ path: path.join(testDir, 'tools') })).markers.map(m => m.tag)).toEqual(['PERF']);
+    await expect(indexer.commentMarkers({ minAgeDays: 1 })).rejects.toThrow('needs git blame');
+  });
+
+  it('should save and load the index', async () => {
+    await indexer.indexDir(testDir);
+    const savePath = path.join(testDir, 'out', 'index.bin');
+    await indexer.save(savePath);
+    expect(fs.readdirSync(path.dirname(savePath))).toEqual(['index.bin']);
+
+    const loaded = createIndexer();
+    expect(await loaded.load(savePath)).toEqual(indexer.getStats());
+    const [greet] = await loaded.findDefinitions('Greet');
+    expect(greet.signature).toBe('func() string');
+    expect(greet.range.startOffset).toBeGreaterThan(0);
+    expect((await loaded.query('file:*_test.go')).symbols.map(s => s.name)).toEqual(['TestGreet']);
+
+    fs.writeFileSync(savePath, JSON.stringify({ hello: 'world' }));
+    await expect(loaded.load(savePath)).rejects.toThrow('Not a saved index');
+  });
+
+  it('should push the index and pull it into another checkout', async () => {
+    const objects = new Map<string, Uint8Array>();
+    const fetch: RemoteFetch = async (url, init) => {
+      if (init.method === 'PUT') {
+        objects.set(url, init.body!);
+      }
+      const body = objects.get(url);
+      return {
+        ok: !!body,
+        status: body ? 200 : 404,
+        statusText: body ? 'OK' : 'Not Found',
+        arrayBuffer: async () => new Uint8Array(body ?? []).buffer
+      };
+    };
+    await indexer.indexDir(testDir);
+    const pushed = await indexer.push('https://cache.example.com/main.idx', { fetch, env: {} });
+    expect(pushed.files).toBe(3);
+    expect([...objects.keys()]).toEqual(['https://cache.example.com/main.idx', 'https://cache.example.com/main.idx.manifest.json']);
+
+    const checkout = path.join(testDir, 'checkout', path.basename(testDir));
+    const pulled = createIndexer();
+    const result = await pulled.pull('https://cache.example.com/main.idx', { fetch, env: {}, roots: [checkout] });
+    expect(result).toMatchObject({ files: 3, outside: 0 });
+    expect(pulled.roots().map(r => r.path)).toEqual([checkout]);
+    const [greet] = await pulled.findDefinitions('Greet');
+    expect(greet.location.uri).toBe(path.join(checkout, 'pkg/user/user.go'));
+    const [original] = await indexer.findDefinitions('Greet');
+    expect(greet.id).not.toBe(original.id);
+    expect(greet.id.split(':').slice(1)).toEqual(original.id.split(':').slice(1));
+
+    objects.set('https://cache.example.com/main.idx', new Uint8Array([1, 2, 3]));
+    await expect(pulled.pull('https://cache.example.com/main.idx', { fetch, env: {} })).rejects.toThrow('Checksum mismatch');
+    await expect(pulled.pull('https://cache.example.com/other.idx', { fetch, env: {} })).rejects.toThrow('No index at');
+  });
+
+  it('should verify a saved index and repair it', async () => {
+    await indexer.indexDir(testDir);
+    const savePath = path.join(testDir, 'out', 'index.bin');
+    await indexer.save(savePath);
+    expect(await indexer.verify(savePath, { root: testDir })).toMatchObject({ ok: true, files: 3, reindex: [], remove: [] });
+
+    write('pkg/user/user.go', 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Rename(name string) { p.Name = name }\n');
+    write('pkg/user/admin.go', 'package user\n\nfunc NewAdmin() *Person { return &Person{} }\n');
+    fs.rmSync(path.join(testDir, 'tools'), { recursive: true });
+
+    const report = await indexer.verify(savePath, { root: testDir });
+    expect(report.ok).toBe(false);
+    expect(report.problems.map(p => [p.kind, path.relative(testDir, p.file!)])).toEqual([
+      ['missing', 'pkg/user/admin.go'],
+      ['modified', 'pkg/user/user.go'],
+      ['deleted', 'tools/report.py']
+    ]);
+    expect(report.problems.find(p => p.kind === 'dangling')).toBeUndefined();
+    expect(report.reindex).toEqual(['pkg/user/admin.go', 'pkg/user/user.go'].map(f => path.join(testDir, f)));
+    expect(report.remove).toEqual([path.join(testDir, 'tools/report.py')]);
+
+    const repaired = await indexer.verify(savePath, { root: testDir, repair: true });
+    expect(repaired.repaired).toEqual({ removed: 1, reindexed: 2, skipped: 0 });
+    expect((await indexer.verify(savePath, { root: testDir })).ok).toBe(true);
+
+    const loaded = createIndexer();
+    await loaded.load(savePath);
+    expect((await loaded.findDefinitions('Rename')).length).toBe(1);
+    expect(await loaded.findDefinitions('NewAdmin')).toHaveLength(1);
+    expect(await loaded.findDefinitions('render')).toHaveLength(0);
+
+    fs.writeFileSync(savePath, JSON.stringify({ hello: 'world' }));
+    await expect(indexer.verify(savePath)).rejects.toThrow('Not a saved index');
+  });
+
+  it('should index straight to a file within a memory budget', async () => {
+    const spillDir = path.join(testDir, 'spill');
+    fs.mkdirSync(spillDir);
+    const outputPath = path.join(testDir, 'out', 'index.bin');
+    const result = await indexer.indexDirToFile(testDir, outputPath, { memoryBudgetMB: 0.0001, tempDir: spillDir });
+    expect(result).toMatchObject({ files: 3, skipped: 0, outputPath });
+    expect(result.segments).toBeGreaterThan(1);
+    expect(result.bytes).toBe(fs.statSync(outputPath).size);
+    expect(fs.readdirSync(spillDir)).toEqual([]);
+    expect(indexer.getStats().files).toBe(0);
+
+    const loaded = createIndexer();
+    expect(await loaded.load(outputPath)).toEqual({ files: 3, symbols: result.symbols });
+    expect(await loaded.getAllFiles()).toEqual(
+      ['pkg/user/user.go', 'pkg/user/user_test.go', 'tools/report.py'].map(f => path.join(testDir, f))
+    );
+    expect((await loaded.query('kind:method receiver:*Person')).symbols.map(s => s.name)).toEqual(['Greet']);
+
+    await indexer.indexDir(testDir);
+    expect(loaded.getStats()).toEqual(indexer.getStats());
+
+    await expect(indexer.indexDirToFile(testDir, outputPath, { timeoutMs: 0 })).rejects.toThrow(CancellationError);
+    expect(fs.readdirSync(path.dirname(outputPath))).toEqual(['index.bin']);
+  });
+
+  it('should write byte-identical index files in reproducible mode', async () => {
+    const out = (name: string) => path.join(testDir, 'out', name);
+    const first = createIndexer({ reproducible: true, concurrency: 1 });
+    await first.indexDir(testDir);
+    await first.save(out('first.idx'));
+    await new Promise(resolve => setTimeout(resolve, 5));
+
+    const second = createIndexer({ reproducible: true });
+    await second.indexDir(testDir);
+    await second.save(out('second.idx'));
+    await second.indexDirToFile(testDir, out('direct.idx'), { memoryBudgetMB: 0.0001 });
+    const loaded = createIndexer({ reproducible: true });
+    await loaded.load(out('first.idx'));
+    await loaded.save(out('loaded.idx'));
+
+    const bytes = fs.readFileSync(out('first.idx'));
+    for (const name of ['second.idx', 'direct.idx', 'loaded.idx']) {
+      expect(fs.readFileSync(out(name)).equals(bytes)).toBe(true);
+    }
+    const saved = decode(bytes) as { roots: Array<{ indexedAt: number }>; files: Array<{ t: number }> };
+    expect(saved.roots.map(root => root.indexedAt)).toEqual([0]);
+    expect(new Set(saved.files.map(file => file.t))).toEqual(new Set([0]));
+
+    // Without it, each file records when it was indexed
+    await indexer.indexDir(testDir);
+    await indexer.save(out('stamped.idx'));
+    expect(fs.readFileSync(out('stamped.idx')).equals(bytes)).toBe(false);
+  });
+
+  it('should encrypt index files and decrypt them on load', async () => {
+    const out = (name: string) => path.join(testDir, 'out', name);
+    const key = new EnvKeyProvider(INDEX_KEY_ENV_VAR, { [INDEX_KEY_ENV_VAR]: 'ab'.repeat(32) });
+    const encrypting = createIndexer({ encryption: key });
+    await encrypting.indexDir(testDir);
+    await encrypting.save(out('index.bin'));
+    await encrypting.indexDirToFile(testDir, out('direct.bin'), { memoryBudgetMB: 0.0001 });
+
+    for (const name of ['index.bin', 'direct.bin']) {
+      const bytes = fs.readFileSync(out(name));
+      expect(isEncryptedIndex(bytes)).toBe(true);
+      expect(bytes.includes('Greet')).toBe(false);
+
+      // Read with the key whether or not the reader encrypts
+      const loaded = createIndexer({ encryption: false });
+      await expect(loaded.load(out(name))).rejects.toThrow('No index key');
+      const withKey = createIndexer({ encryption: key });
+      expect(await withKey.load(out(name))).toEqual(encrypting.getStats());
+    }
+
+    // A repair keeps the file encrypted
+    write('pkg/user/admin.go', 'package user\n\nfunc NewAdmin() *Person { return &Person{} }\n');
+    const repaired = await encrypting.verify(out('index.bin'), { root: testDir, repair: true });
+    expect(repaired.repaired).toMatchObject({ reindexed: 1 });
+    expect(isEncryptedIndex(fs.readFileSync(out('index.bin')))).toBe(true);
+  });
+
+  it('should benchmark indexing throughput and profile runs', async () => {
+    const bytes = ['pkg/user/user.go', 'pkg/user/user_test.go', 'tools/report.py']
+      .reduce((sum, f) => sum + fs.statSync(path.join(testDir, f)).size, 0);
+    const cpuProfile = path.join(testDir, 'profiles', 'bench.cpuprofile');
+    const runs: string[] = [];
+    const result = await indexer.bench(testDir, {
+      iterations: 3,
+      cpuProfile,
+      onIndexingProgress: event => event.phase === 'idle' && runs.push(event.phase)
+    });
+
+    expect(result).toMatchObject({ root: testDir, warmup: 1, files: 3, bytes, symbols: result.runs[0].symbols });
+    expect(result.runs.map(run => run.files)).toEqual([3, 3, 3]);
+    expect(runs).toHaveLength(4);
+    expect(result.bestMs).toBeLessThanOrEqual(result.medianMs);
+    expect(result.filesPerSecond).toBe(3 / (result.medianMs / 1000));
+    expect(formatBenchmark(result).split('\n')[1]).toMatch(/^3 runs \(1 warm-up\): median [\d.]+ms, best [\d.]+ms, mean [\d.]+ms ± [\d.]+ms$/);
+    expect(JSON.parse(fs.readFileSync(cpuProfile, 'utf-8')).nodes.length).toBeGreaterThan(0);
+
+    // Runs index on their own; this index stays empty
+    expect(indexer.getStats().files).toBe(0);
+    await expect(indexer.bench(testDir, { iterations: 0 })).rejects.toThrow('Invalid benchmark: 0 iterations after 1 warm-up runs');
+  });
+
+  it('should write CPU and heap profiles of an indexing run', async () => {
+    const cpuProfile = path.join(testDir, 'cpu.pb.gz');
+    const heapProfile = path.join(testDir, 'heap.heapprofile');
+    await indexer.indexDir(testDir, { cpuProfile, heapProfile });
+
+    expect(fs.readFileSync(cpuProfile).subarray(0, 2).toString('hex')).toBe('1f8b');
+    expect(JSON.parse(fs.readFileSync(heapProfile, 'utf-8')).head).toBeDefined();
+  });
+
+  it('should stop when cancelled', async () => {
+    const cancelled = { isCancellationRequested: true };
+    await expect(indexer.indexDir(testDir, { cancellationToken: cancelled })).rejects.toThrow();
+    await expect(indexer.indexDir(testDir, { timeoutMs: 0 })).rejects.toThrow(CancellationError);
+    expect(indexer.getStats().files).toBe(0);
+    await expect(indexer.indexDir(path.join(testDir, '.gitignore'))).rejects.toThrow('Not a directory');
+  });
+});
@@ -0,0 +1,2079 @@
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import * as chokidar from 'chokidar';
+import { encode } from '@msgpack/msgpack';
+import { DynamicIndex } from '../index/dynamicIndex.js';
+import { fromCompactShard, isCompactShard, toCompactShard } from '../index/ShardPersistenceManager.js';
+import { IndexThrottle } from '../index/indexThrottle.js';
+import { IndexKeyProvider, IndexKeys, indexKeysFor, isEncryptedIndex } from '../index/indexEncryption.js';
+import {
+  decodeSavedIndex,
+  DecodedSavedIndex,
+  mapSavedRoots,
+  relocateShard,
+  RootMapping,
+  SAVED_INDEX_FORMAT,
+  SavedIndex,
+  savedRoots
+} from '../index/savedIndex.js';
+import { SymbolIndexer } from '../indexer/symbolIndexer.js';
+import { LanguageRouter } from '../indexer/languageRouter.js';
+import { resolveBuildContext } from '../indexer/goBuildConstraints.js';
+import { collectBazelGeneratedFiles } from '../indexer/bazelTargets.js';
+import { FileScanner } from '../indexer/fileScanner.js';
+import { archiveEntryFile, ArchiveFormat, ArchiveSource, readArchive, stripArchiveExtension } from '../utils/archiveReader.js';
+import { gitChangesSince } from '../git/gitDelta.js';
+import { buildTimestamp } from '../utils/buildTimestamp.js';
+import {
+  CommentMarkerConfig, ConfigurationManager, HttpRoutesConfig, PolicyConfig, SearchConfig, ThrottleConfig
+} from '../config/configurationManager.js';
+import { ConfigFile, findConfigFile, readConfigFile } from '../config/configFile.js';
+import {
+  StructuredQuery, StructuredQueryOptions, StructuredQueryPage, StructuredQueryPageOptions, StructuredQueryResult
+} from '../features/structuredQuery.js';
+import { SavedQuery, ScopeOptions, SearchScope, SearchScopes } from '../features/searchScopes.js';
+import { buildOutline, OutlineNode } from '../features/outline.js';
+import { RenameImpact, RenameImpactAnalyzer } from '../features/renameImpact.js';
+import { CloneDetectionOptions, CloneDetector, CloneReport } from '../features/cloneDetection.js';
+import { CodeMetrics, CodeMetricsOptions, CodeMetricsReport } from '../features/codeMetrics.js';
+import { ParseErrors, ParseErrorOptions, ParseErrorReport } from '../features/parseErrors.js';
+import { Ownership, OwnershipOptions, OwnershipReport } from '../features/ownership.js';
+import { DeprecationOptions, DeprecationReport, Deprecations } from '../features/deprecations.js';
+import { ApiSurface, ApiSurfaceOptions, ApiSurfaceReport, IndexedGoModule } from '../features/apiSurface.js';
+import { IndexDiff } from '../features/indexDiff.js';
+import { SemverCheck, SemverCheckOptions, SemverReport } from '../features/semverCheck.js';
+import { PolicyCheck, PolicyCheckOptions, PolicyReport } from '../features/policyCheck.js';
+import { IndexVerifier, IndexVerifyReport } from '../features/indexVerifier.js';
+import { PositionLookup, PositionLookupResult } from '../features/positionLookup.js';
+import { TypeHierarchy, TypeHierarchyDirection, TypeHierarchyNode, TypeHierarchyQueryOptions } from '../features/typeHierarchy.js';
+import { CommentMarkerIndex, CommentMarkerQuery, CommentMarkerReport, MarkerBlame } from '../features/commentMarkers.js';
+import { HttpRouteIndex, HttpRouteQuery, HttpRouteReport } from '../features/httpRoutes.js';
+import { SqlQueryFilter, SqlQueryIndex, SqlQueryReport } from '../features/sqlQueries.js';
+import { ConfigKeyIndex, ConfigKeyQuery, ConfigKeyReport } from '../features/configKeys.js';
+import { ConcurrencyIndex, ConcurrencyQuery, ConcurrencyReport } from '../features/concurrency.js';
+import { ErrorPathIndex, ErrorPathQuery, ErrorPathReport } from '../features/errorPaths.js';
+import { CoverageIndex, CoverageQuery, CoverageReport } from '../features/coverage.js';
+import { GoTestIndex, TestsForQuery, TestsForReport } from '../features/goTests.js';
+import { DocLinkReport, DocMentionQuery, DocMentionReport, DocsIndex, DocSearchQuery, DocSearchReport } from '../features/docsIndex.js';
+import { AffectedTests, AffectedTestsQuery, AffectedTestsReport } from '../features/affectedTests.js';
+import { IndexGraphQL } from '../features/graphqlSchema.js';
+import { QueryServer } from '../features/queryServer.js';
+import { daemonSocketPath, prepareDaemonSocket } from '../features/daemon.js';
+import { CompletionQuery, IndexCompletions } from '../features/completions.js';
+import { GraphQLResult } from '../utils/graphql.js';
+import { SymbolHistoryIndex } from '../features/symbolHistory.js';
+import { pullIndex, pushIndex, PushIndexResult, RemoteIndexOptions } from '../features/remoteIndex.js';
+import { CodeOwners } from '../utils/codeOwners.js';
+import { CompactShard, IndexedFileResult, IndexedReference, IndexedSymbol, SHARD_VERSION } from '../types.js';
+import {
+  CancellationToken,
+  CancellationTokenSource,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+import { writeChunksAtomic, writeFileAtomic } from '../utils/atomicWrite.js';
+import { IndexingProgressListener, ProgressTracker } from '../utils/indexingProgress.js';
+import { tagFileResult } from '../utils/codeTags.js';
+import { SpillBuffer, SpillRecord } from '../utils/spillBuffer.js';
+import { rankSymbols, RankedSymbol } from '../utils/fuzzySearch.js';
+import { locationKey, Page, PageOptions, pageWindow, queryFingerprint, takePage } from '../utils/pagination.js';
+import { isWithinRoot, WorkspaceRoot, WorkspaceRootSummary, WorkspaceRoots } from '../utils/workspaceRoots.js';
+import { ILogger, NullLogger } from '../utils/Logger.js';
+import { SamplingProfiler } from '../profiler/sampling.js';
+import { BenchmarkResult, BenchmarkRun, formatBenchmark, summarizeBenchmark } from '../profiler/benchmark.js';
+
+export interface IndexerOptions {
+  /**
+   * Workspace config file (smart-indexer.yaml, .yml or .toml) whose
+   * settings apply under these options: a path, or true for the one at the
+   * root of the indexed directory, if any (default: none)
+   */
+  configFile?: string | boolean;
+  /**
+   * Also apply the config file settings that run programs, open the
+   * network or write outside the workspace (TRUSTED_SETTING_KEYS, see
+   * config/configFile.ts); only for files you trust (default: false)
+   */
+  trustConfigFile?: boolean;
+  /** Profile of the config file to use (default: SMART_INDEXER_PROFILE, else the file's own) */
+  profile?: string;
+  /**
+   * Glob patterns of paths to skip, on top of .gitignore and .indexerignore
+   * (default: the extension's `smartIndexer.excludePatterns` defaults)
+   */
+  excludePatterns?: string[];
+  /** Only index files matching these globs, relative to the indexed directory */
+  includePatterns?: string[];
+  /** Only index these languages (`go`, `ts`, `js`, `py`, `asm`) */
+  languages?: string[];
+  /** Files larger than this are skipped (default: 50) */
+  maxFileSizeMB?: number;
+  /** Index other languages (Java, Rust, C, ...) with the text indexer */
+  textIndexing?: boolean;
+  /** Files read and indexed at the same time (default: 8) */
+  concurrency?: number;
+  /**
+   * Index Go code for one build target, skipping files its build
+   * constraints exclude; GOOS/GOARCH default to the host's. Without it,
+   * files for every platform are indexed.
+   */
+  goBuild?: { tags?: string[]; goos?: string; goarch?: string };
+  /**
+   * Bazel workspace: tag Go symbols with the targets of their BUILD files,
+   * skip Go files no target compiles and index the targets' generated
+   * sources under bazel-bin (default: the config file's `bazel.enabled`)
+   */
+  bazel?: boolean;
+  /**
+   * Root of the repository whose CODEOWNERS assigns owners (default: the
+   * CODEOWNERS of the indexed root each file is under)
+   */
+  repositoryRoot?: string;
+  /** Comment markers to collect on top of TODO, FIXME, HACK, XXX, BUG and DEPRECATED */
+  commentMarkers?: Partial<CommentMarkerConfig>;
+  /** Routers httpRoutes() recognizes (default: net/http, chi, gin and echo) */
+  httpRoutes?: Pick<Partial<HttpRoutesConfig>, 'frameworks'>;
+  /**
+   * Named scopes and saved queries, added to those of the config file's
+   * `search` (see features/searchScopes.ts)
+   */
+  search?: Pick<Partial<SearchConfig>, 'scopes' | 'queries'>;
+  /**
+   * Receives the indexer's log, under the scopes `walker` (file scanning),
+   * `index` (indexing runs) and `store` (saving and loading); default: none.
+   * `new LoggerService(line => process.stderr.write(line + '\n'))` logs
+   * to stderr, with setFormat('json') as JSON Lines.
+   */
+  logger?: ILogger;
+  /**
+   * Write index files that are byte-identical for the same input: no
+   * build time in them (0, or SOURCE_DATE_EPOCH; see
+   * utils/buildTimestamp.ts), so CI can cache and verify them by hash
+   */
+  reproducible?: boolean;
+  /**
+   * Encrypt index files (save(), indexDirToFile(), push()) with AES-256-GCM
+   * under the key in SMART_INDEXER_INDEX_KEY, or the key provider given,
+   * e.g. a KMS client (see index/indexEncryption.ts); default: the config
+   * file's `encryption`. Encrypted files are decrypted on load either way.
+   */
+  encryption?: boolean | IndexKeyProvider;
+}
+
+export interface CommentMarkerOptions extends CommentMarkerQuery {
+  /**
+   * Add the author and age of each marker's line from git blame of the
+   * repository (IndexerOptions.repositoryRoot, else the file's root);
+   * needed by age filters and `sort: 'age'`
+   */
+  blame?: boolean;
+}
+
+export interface CoverageOptions extends CoverageQuery {
+  /**
+   * `go test -coverprofile` profiles to read, replacing those of earlier
+   * calls (default: the ones loaded before); relative to the working directory
+   */
+  profiles?: string[];
+}
+
+export interface IndexDirOptions {
+  /** Cancellation token for aborting the run */
+  cancellationToken?: CancellationToken;
+  /** Abort the run with a CancellationError after this many milliseconds */
+  timeoutMs?: number;
+  /** Progress callback for reporting indexing progress */
+  onProgress?: ProgressCallback;
+  /** Receives file and byte counts, rate and ETA after each chunk of files */
+  onIndexingProgress?: IndexingProgressListener;
+  /**
+   * Write a CPU profile of the run to this file when it ends: gzipped
+   * pprof (`cpu.pb.gz`), or DevTools JSON for `.cpuprofile` (see profiler/sampling.ts)
+   */
+  cpuProfile?: string;
+  /** Write the allocations of the run still live at its end to this file, pprof or `.heapprofile` */
+  heapProfile?: string;
+}
+
+export interface BenchOptions extends IndexDirOptions {
+  /** Timed runs (default: 5) */
+  iterations?: number;
+  /** Runs before the timed ones, to warm up the JIT and the disk cache (default: 1) */
+  warmup?: number;
+}
+
+export interface IndexToFileOptions extends IndexDirOptions {
+  /**
+   * Memory for buffered results, in MB (default: 256). Past it, results are
+   * sorted and written to temporary segments, which are merged into the
+   * output at the end.
+   */
+  memoryBudgetMB?: number;
+  /** Parent directory for the temporary segments (default: the OS temp directory) */
+  tempDir?: string;
+}
+
+export interface IndexArchiveOptions extends IndexDirOptions {
+  /**
+   * Directory the archive stands in for: entries are indexed as files
+   * under it (default: the archive path without its extension; required
+   * for streams). Nothing is read from or written to it.
+   */
+  root?: string;
+  /** Default: from the file extension, or detected from the first bytes */
+  format?: ArchiveFormat;
+  /** Leading path components to drop, e.g. 1 for `git archive --prefix=app/` */
+  stripComponents?: number;
+}
+
+export interface IndexDirResult {
+  /** Absolute path of the indexed directory */
+  root: string;
+  /** Its name among the workspace roots */
+  name: string;
+  files: number;
+  symbols: number;
+  /** Files that could not be read */
+  skipped: number;
+  /** Files previously indexed under root that no longer exist */
+  removed: number;
+  duration: number;
+}
+
+export interface IndexRootsResult {
+  /** One result per root, in the order given */
+  roots: IndexDirResult[];
+  files: number;
+  symbols: number;
+  skipped: number;
+  removed: number;
+  duration: number;
+}
+
+export interface IndexUpdateResult {
+  /** Absolute path of the updated directory */
+  root: string;
+  name: string;
+  /** The commit compared against, and HEAD, as hashes */
+  since: string;
+  head: string;
+  /** Changed files new to the index */
+  added: number;
+  /** Changed files indexed again */
+  modified: number;
+  /** Files dropped: deleted, or no longer indexable (e.g. now ignored) */
+  removed: number;
+  /** Changed files that could not be read */
+  skipped: number;
+  /** Symbols in the files indexed */
+  symbols: number;
+  duration: number;
+}
+
+export interface IndexToFileResult extends IndexDirResult {
+  outputPath: string;
+  /** Size of the written index file */
+  bytes: number;
+  /** Temporary segments written because the memory budget was reached */
+  segments: number;
+}
+
+export interface VerifyIndexOptions {
+  /** Also report files under this directory that would be indexed but have no entry */
+  root?: string;
+  /** Compare entries with the files on disk (default: true) */
+  checkFiles?: boolean;
+  /**
+   * Rewrite the index file: drop deleted files and index again the files
+   * the report lists. The in-memory index is left untouched.
+   */
+  repair?: boolean;
+  /** Cancellation token for aborting the check */
+  cancellationToken?: CancellationToken;
+  /** Abort the check with a CancellationError after this many milliseconds */
+  timeoutMs?: number;
+  /** Progress callback for reporting check progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface VerifyIndexResult extends IndexVerifyReport {
+  /** Set when the index file was rewritten */
+  repaired?: {
+    removed: number;
+    reindexed: number;
+    /** Files to index again that could not be read, left out */
+    skipped: number;
+  };
+}
+
+export interface OverlayResult {
+  uri: string;
+  /** Symbols of the buffer */
+  symbols: IndexedSymbol[];
+  /** The buffer's declarations as outline() returns them */
+  outline: OutlineNode[];
+  /** Whether the buffer shadows an indexed file; false for files not indexed (yet) */
+  shadowsIndexed: boolean;
+}
+
+export interface DaemonOptions extends IndexDirOptions {
+  /** Socket to listen on (default: `.smart-index/daemon.sock` under the indexed root) */
+  socketPath?: string;
+  /** Index files again as they change on disk (default: true) */
+  watch?: boolean;
+  /** Serve CPU and heap profiles of the process at /debug/pprof (see features/queryServer.ts) */
+  pprof?: boolean;
+  /**
+   * Index changed files in nice mode (see index/indexThrottle.ts); mode
+   * defaults to 'watch' here. Without it, the config file's throttle.
+   */
+  throttle?: Partial<ThrottleConfig>;
+}
+
+export interface Daemon {
+  root: string;
+  socketPath: string;
+  /** The run that indexed the root on start */
+  indexed: IndexDirResult;
+  /** Stop listening and watching; removes the socket */
+  stop(): Promise<void>;
+}
+
+/** File contents by path, standing in for what is on disk (see setOverlay) */
+export type OverlayMap = Record<string, string> | Map<string, string>;
+
+export interface IndexerStats {
+  files: number;
+  symbols: number;
+}
+
+export interface RemoteOptions extends RemoteIndexOptions {
+  /** Abort the transfer with a CancellationError after this many milliseconds */
+  timeoutMs?: number;
+}
+
+export interface PullOptions extends RemoteOptions {
+  /**
+   * Local directories the pulled roots are checked out at, matched to the
+   * saved roots by name, then by position (default: the saved paths)
+   */
+  roots?: string[];
+}
+
+export interface PullResult extends IndexerStats {
+  url: string;
+  /** Files under none of the given roots, left out */
+  outside: number;
+  /** Size of the download and of the index after decompression */
+  compressedBytes: number;
+  bytes: number;
+  /** When the index was pushed */
+  createdAt: number;
+}
+
+const DEFAULT_CONCURRENCY = 8;
+/** Quiet time before a changed file is indexed again by a daemon */
+const WATCH_DEBOUNCE_MS = 200;
+const DEFAULT_MEMORY_BUDGET_MB = 256;
+const DEFAULT_BENCH_ITERATIONS = 5;
+const DEFAULT_BENCH_WARMUP = 1;
+const WRITE_CHUNK_SIZE = 256 * 1024;
+/** Symbols a search ranks at most */
+const MAX_SEARCH_CANDIDATES = 1000;
+
+/**
+ * Indexer - the indexer as a library, for tools that embed it instead of
+ * running the language server.
+ *
+ * Indexes directories in memory with the same per-language indexers,
+ * ignore rules and code tags as the extension, answers the same queries,
+ * and saves to / loads from a single file:
+ *
+ *   const idx = createIndexer({ textIndexing: true });
+ *   await idx.indexDir('/src/service');
+ *   const hits = await idx.search('NewServer');
+ *   await idx.save('/tmp/service.idx');
+ *
+ * Several directories (one per repository of a multi-repo setup) can be
+ * indexed into the same index with indexRoots or repeated indexDir calls;
+ * searches and reference lookups then span all of them, and roots() lists
+ * each root with its file and symbol counts.
+ *
+ * Paths are absolute file paths; lines and characters are 0-based. An
+ * Indexer is not synchronized: run one indexDir or load at a time.
+ */
+export class Indexer {
+  private index: DynamicIndex;
+  private router: LanguageRouter;
+  private configManager = new ConfigurationManager();
+  private workspaceRoots = new WorkspaceRoots();
+  private repositoryOwners: CodeOwners | undefined;
+  /** CODEOWNERS per root, read when first asked */
+  private codeOwners = new Map<string, CodeOwners>();
+  /** Kept across calls so only changed files are rescanned */
+  private markerIndex: CommentMarkerIndex | undefined;
+  private routeIndex: HttpRouteIndex | undefined;
+  private sqlIndex: SqlQueryIndex | undefined;
+  private configKeyIndex: ConfigKeyIndex | undefined;
+  private concurrencyIndex: ConcurrencyIndex | undefined;
+  private errorPathIndex: ErrorPathIndex | undefined;
+  private coverageIndex: CoverageIndex | undefined;
+  private goTestIndex: GoTestIndex | undefined;
+  private docsIndex: DocsIndex | undefined;
+  /** Unsaved buffers laid over the index, with the results they shadow */
+  private overlays = new Map<string, { content: string; persisted: IndexedFileResult | undefined }>();
+  /** Blame per repository, created when first asked */
+  private histories = new Map<string, SymbolHistoryIndex>();
+  private logger: ILogger;
+
+  constructor(private options: IndexerOptions = {}) {
+    this.logger = options.logger ?? new NullLogger();
+    const symbolIndexer = new SymbolIndexer();
+    this.router = new LanguageRouter(symbolIndexer, options.textIndexing ?? false);
+    if (options.goBuild) {
+      this.router.setGoBuildContext(resolveBuildContext(options.goBuild));
+    }
+    this.router.setBazelEnabled(options.bazel ?? false);
+    this.index = new DynamicIndex(symbolIndexer);
+    this.index.setLanguageRouter(this.router);
+    if (options.repositoryRoot) {
+      this.repositoryOwners = new CodeOwners(path.resolve(options.repositoryRoot));
+    }
+    const { includePatterns, languages, commentMarkers, httpRoutes, search } = options;
+    this.configManager.updateFromSettings({ includePatterns, languages, commentMarkers, httpRoutes, search });
+    if (typeof options.configFile === 'string') {
+      this.useConfigFile(readConfigFile(options.configFile));
+    }
+  }
+
+  /**
+   * Index every indexable file under dir, replacing earlier results for
+   * files under it and dropping files that are gone. dir becomes one of
+   * the workspace roots; files indexed from other roots are kept.
+   *
+   * Cancellation (or timeoutMs passing) throws CancellationError between
+   * chunks of files: files indexed so far keep their new results and the
+   * rest keep their old ones. Index files are only written by save() and
+   * indexDirToFile().
+   */
+  async indexDir(dir: string, options: IndexDirOptions = {}): Promise<IndexDirResult> {
+    return this.withCancellation(options, token => this.indexDirWithToken(dir, token, options));
+  }
+
+  private async indexDirWithToken(
+    dir: string,
+    cancellationToken: CancellationToken,
+    { onProgress, onIndexingProgress }: IndexDirOptions
+  ): Promise<IndexDirResult> {
+    const start = Date.now();
+    const tracker = new ProgressTracker();
+    const { root, files } = await this.scan(dir, cancellationToken);
+    const { name } = this.workspaceRoots.add(root);
+    tracker.fileScanned(files.length);
+    tracker.startIndexing(files.length);
+    onIndexingProgress?.(tracker.snapshot());
+    this.codeOwners.delete(root);
+
+    const present = new Set(files);
+    let removed = 0;
+    for (const uri of this.index.getIndexedFiles()) {
+      if (isWithinRoot(root, uri) && !present.has(uri)) {
+        this.dropFile(uri);
+        removed++;
+      }
+    }
+
+    const concurrency = Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY);
+    let skipped = 0;
+    let symbols = 0;
+    for (let i = 0; i < files.length; i += concurrency) {
+      throwIfCancelled(cancellationToken);
+      await yieldToEventLoop();
+      onProgress?.(i, files.length, `Indexing (${i}/${files.length})`);
+
+      await Promise.all(files.slice(i, i + concurrency).map(async file => {
+        let content: string;
+        try {
+          content = await fsPromises.readFile(file, 'utf-8');
+        } catch (error) {
+          this.logger.scope('walker').warn(`[Indexer] Skipping unreadable file ${file}: ${error}`);
+          skipped++;
+          return;
+        }
+        symbols += (await this.storeFile(file, content))?.symbols.length ?? 0;
+        tracker.fileParsed(file);
+        tracker.fileIndexed(file, Buffer.byteLength(content));
+      }));
+      onIndexingProgress?.(tracker.snapshot());
+    }
+    onProgress?.(files.length, files.length, `Indexed ${files.length} files`);
+    tracker.setPhase('idle');
+    onIndexingProgress?.(tracker.snapshot());
+    this.workspaceRoots.markIndexed(root);
+
+    const duration = Date.now() - start;
+    this.logger.scope('index').info(
+      `[Indexer] Indexed ${files.length - skipped} files (${symbols} symbols) under ${root} in ${duration}ms; ` +
+      `${skipped} skipped, ${removed} removed`
+    );
+    return { root, name, files: files.length - skipped, symbols, skipped, removed, duration };
+  }
+
+  /**
+   * Index dir `warmup` times, then `iterations` times more timing each run,
+   * for indexing throughput in files/s and MB/s (see profiler/benchmark.ts).
+   * Every run starts from an empty index of its own, with this indexer's
+   * options; this index is left as it is. cpuProfile and heapProfile cover
+   * the timed runs only.
+   */
+  async bench(dir: string, options: BenchOptions = {}): Promise<BenchmarkResult> {
+    const { iterations = DEFAULT_BENCH_ITERATIONS, warmup = DEFAULT_BENCH_WARMUP, cpuProfile, heapProfile, ...runOptions } = options;
+    if (!Number.isInteger(iterations) || iterations < 1 || !Number.isInteger(warmup) || warmup < 0) {
+      throw new Error(`Invalid benchmark: ${iterations} iterations after ${warmup} warm-up runs`);
+    }
+    const runOnce = async (): Promise<BenchmarkRun> => {
+      let bytes = 0;
+      const start = performance.now();
+      const result = await new Indexer(this.options).indexDir(dir, {
+        ...runOptions,
+        onIndexingProgress: progress => {
+          bytes = progress.bytesProcessed;
+          runOptions.onIndexingProgress?.(progress);
+        }
+      });
+      return { durationMs: performance.now() - start, files: result.files, bytes, symbols: result.symbols };
+    };
+
+    for (let i = 0; i < warmup; i++) {
+      await runOnce();
+    }
+    const runs: BenchmarkRun[] = [];
+    await this.withCancellation({ cpuProfile, heapProfile }, async () => {
+      for (let i = 0; i < iterations; i++) {
+        runs.push(await runOnce());
+      }
+    });
+    const result = summarizeBenchmark(path.resolve(dir), runs, warmup);
+    this.logger.scope('index').info(`[Indexer] Benchmark of ${result.root}: ${formatBenchmark(result).split('\n').slice(1).join('; ')}`);
+    return result;
+  }
+
+  /**
+   * Index several directories into one logical workspace, e.g. the
+   * repositories of a multi-repo setup, as indexDir does for each in turn.
+   * Progress is reported per root; cancellation stops at the root being
+   * indexed, leaving the roots before it indexed.
+   */
+  async indexRoots(dirs: string[], options: IndexDirOptions = {}): Promise<IndexRootsResult> {
+    return this.withCancellation(options, async token => {
+      const start = Date.now();
+      const roots: IndexDirResult[] = [];
+      for (const dir of dirs) {
+        roots.push(await this.indexDirWithToken(dir, token, options));
+      }
+      const sum = (field: 'files' | 'symbols' | 'skipped' | 'removed') => roots.reduce((n, r) => n + r[field], 0);
+      return {
+        roots,
+        files: sum('files'),
+        symbols: sum('symbols'),
+        skipped: sum('skipped'),
+        removed: sum('removed'),
+        duration: Date.now() - start
+      };
+    });
+  }
+
+  /**
+   * Index the files of a .zip, .tar or .tar.gz archive, or of a stream of
+   * one such as `git archive` output, without extracting it: entries are
+   * parsed as they are read (see utils/archiveReader.ts). The archive
+   * stands in for options.root, which becomes a workspace root as with
+   * indexDir: its entries replace earlier results for files under it, and
+   * files under it the archive lacks are dropped. Ignore rules and the
+   * config file come from the options, not from the archive.
+   */
+  async indexArchive(source: ArchiveSource, options: IndexArchiveOptions = {}): Promise<IndexDirResult> {
+    return this.withCancellation(options, token => this.indexArchiveWithToken(source, token, options));
+  }
+
+  private async indexArchiveWithToken(
+    source: ArchiveSource,
+    cancellationToken: CancellationToken,
+    { root: rootOption, format, stripComponents, onProgress, onIndexingProgress }: IndexArchiveOptions
+  ): Promise<IndexDirResult> {
+    const start = Date.now();
+    if (rootOption === undefined && typeof source !== 'string') {
+      throw new Error('indexArchive needs a root to index a stream under');
+    }
+    const root = path.resolve(rootOption ?? stripArchiveExtension(source as string));
+    this.configManager.setWorkspaceRoot(root);
+    const scanner = this.configuredScanner();
+    const { name } = this.workspaceRoots.add(root);
+    const tracker = new ProgressTracker();
+    tracker.startIndexing(0);
+    onIndexingProgress?.(tracker.snapshot());
+    this.codeOwners.delete(root);
+
+    const concurrency = Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY);
+    const present = new Set<string>();
+    let symbols = 0;
+    let batch: { file: string; content: string }[] = [];
+    const flush = async () => {
+      throwIfCancelled(cancellationToken);
+      await Promise.all(batch.map(async ({ file, content }) => {
+        symbols += (await this.storeFile(file, content))?.symbols.length ?? 0;
+        tracker.fileParsed(file);
+        tracker.fileIndexed(file, Buffer.byteLength(content));
+      }));
+      batch = [];
+      onProgress?.(present.size, present.size, `Indexing (${present.size} files)`);
+      onIndexingProgress?.(tracker.snapshot());
+      await yieldToEventLoop();
+    };
+
+    const entries = readArchive(source, {
+      format,
+      stripComponents,
+      accept: (entry, size) => scanner.isIndexableEntry(archiveEntryFile(root, entry), size, root)
+    });
+    for await (const entry of entries) {
+      const file = archiveEntryFile(root, entry.path);
+      present.add(file);
+      tracker.fileScanned();
+      tracker.addToTotal();
+      batch.push({ file, content: entry.content.toString('utf-8') });
+      if (batch.length >= concurrency) {
+        await flush();
+      }
+    }
+    await flush();
+
+    // Only the whole archive tells which files are gone
+    let removed = 0;
+    for (const uri of this.index.getIndexedFiles()) {
+      if (isWithinRoot(root, uri) && !present.has(uri)) {
+        this.dropFile(uri);
+        removed++;
+      }
+    }
+    onProgress?.(present.size, present.size, `Indexed ${present.size} files`);
+    tracker.setPhase('idle');
+    onIndexingProgress?.(tracker.snapshot());
+    this.workspaceRoots.markIndexed(root);
+
+    const duration = Date.now() - start;
+    this.logger.scope('index').info(
+      `[Indexer] Indexed ${present.size} archive files (${symbols} symbols) as ${root} in ${duration}ms; ${removed} removed`
+    );
+    return { root, name, files: present.size, symbols, skipped: 0, removed, duration };
+  }
+
+  /**
+   * Apply the changes to dir since a commit, as git reports them (see
+   * git/gitDelta.ts), instead of walking dir: deleted files are dropped
+   * and added or modified ones indexed, with the ignore rules indexDir
+   * would apply. Uncommitted and untracked changes count. Much faster than
+   * indexDir on large trees, as long as the index was up to date at that
+   * commit. Throws when dir is not in a git repository or the commit is
+   * unknown.
+   */
+  async update(dir: string, since: string, options: IndexDirOptions = {}): Promise<IndexUpdateResult> {
+    return this.withCancellation(options, token => this.updateWithToken(dir, since, token, options));
+  }
+
+  /**
+   * Patch an index file written by save() or indexDirToFile() in place
+   * with the changes since a commit: load(), update() and save(). The file
+   * is left as it was when the update fails or is cancelled.
+   */
+  async updateIndexFile(indexPath: string, dir: string, since: string, options: IndexDirOptions = {}): Promise<IndexUpdateResult> {
+    await this.load(indexPath);
+    const result = await this.update(dir, since, options);
+    await this.save(indexPath);
+    return result;
+  }
+
+  private async updateWithToken(
+    dir: string,
+    since: string,
+    cancellationToken: CancellationToken,
+    { onProgress }: IndexDirOptions
+  ): Promise<IndexUpdateResult> {
+    const start = Date.now();
+    const { root, scanner } = await this.scanner(dir);
+    const delta = await gitChangesSince(root, since);
+    const { name } = this.workspaceRoots.add(root);
+    this.codeOwners.delete(root);
+
+    const indexed = new Set(this.index.getIndexedFiles());
+    const result: IndexUpdateResult = {
+      root, name, since: delta.since, head: delta.head,
+      added: 0, modified: 0, removed: 0, skipped: 0, symbols: 0, duration: 0
+    };
+    const drop = (file: string) => {
+      if (indexed.has(file)) {
+        this.dropFile(file);
+        result.removed++;
+      }
+    };
+    delta.deleted.forEach(drop);
+
+    const changed = [...delta.added, ...delta.modified];
+    const concurrency = Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY);
+    for (let i = 0; i < changed.length; i += concurrency) {
+      throwIfCancelled(cancellationToken);
+      await yieldToEventLoop();
+      onProgress?.(i, changed.length, `Indexing changes (${i}/${changed.length})`);
+
+      await Promise.all(changed.slice(i, i + concurrency).map(async file => {
+        if (!await scanner.isIndexable(file, root)) {
+          drop(file);
+          return;
+        }
+        let content: string;
+        try {
+          content = await fsPromises.readFile(file, 'utf-8');
+        } catch {
+          result.skipped++;
+          return;
+        }
+        result.symbols += (await this.storeFile(file, content))?.symbols.length ?? 0;
+        if (indexed.has(file)) {
+          result.modified++;
+        } else {
+          result.added++;
+        }
+      }));
+    }
+    onProgress?.(changed.length, changed.length, `Indexed ${changed.length} changed files`);
+    this.workspaceRoots.markIndexed(root);
+
+    result.duration = Date.now() - start;
+    return result;
+  }
+
+  /**
+   * The indexed roots with their file and symbol counts, in the order
+   * they were first indexed.
+   */
+  roots(): WorkspaceRootSummary[] {
+    return this.workspaceRoots.summarize(this.index.getIndexedFiles().map(uri => ({
+      uri,
+      symbols: this.index.getFileInfo(uri)?.symbols.length ?? 0
+    })));
+  }
+
+  /**
+   * The (deepest) indexed root a file is under, if any.
+   */
+  rootOf(filePath: string): WorkspaceRoot | undefined {
+    return this.workspaceRoots.rootOf(path.resolve(filePath));
+  }
+
+  /**
+   * Stop treating dir as a root and drop its files, except those under
+   * another root. Returns the number of files dropped.
+   */
+  removeRoot(dir: string): number {
+    const root = path.resolve(dir);
+    if (!this.workspaceRoots.remove(root)) {
+      return 0;
+    }
+    this.codeOwners.delete(root);
+    let removed = 0;
+    for (const uri of this.index.getIndexedFiles()) {
+      if (isWithinRoot(root, uri) && !this.workspaceRoots.rootOf(uri)) {
+        this.dropFile(uri);
+        removed++;
+      }
+    }
+    return removed;
+  }
+
+  /**
+   * Index dir and serve it as a daemon: the query server (see
+   * queryServer()) on a unix socket at `.smart-index/daemon.sock` under
+   * the root, a named pipe on Windows. Short-lived processes find it with
+   * connectDaemon (see features/daemon.ts) and get instant answers from
+   * the loaded index instead of indexing on every run. Files are indexed
+   * again as they change on disk unless `watch` is false. Throws if a
+   * daemon is already running on the socket; one left by a daemon that
+   * died is replaced.
+   */
+  async startDaemon(dir: string, options: DaemonOptions = {}): Promise<Daemon> {
+    const indexed = await this.indexDir(dir, options);
+    const socketPath = options.socketPath ?? daemonSocketPath(indexed.root);
+    await prepareDaemonSocket(socketPath);
+    const server = this.queryServer();
+    if (options.pprof) {
+      server.setProfiler(new SamplingProfiler());
+    }
+    await server.startOnSocket(socketPath);
+    const throttle = new IndexThrottle(
+      options.throttle ? { mode: 'watch', ...options.throttle } : this.configManager.getThrottleConfig(),
+      { logger: this.logger.scope('index') }
+    );
+    const watcher = options.watch === false ? undefined : this.watchRoot(indexed.root, throttle);
+    this.logger.scope('index').info(`[Indexer] Serving ${indexed.root} on ${socketPath}`);
+    return {
+      root: indexed.root,
+      socketPath,
+      indexed,
+      stop: async () => {
+        await watcher?.close();
+        await server.stop();
+        if (process.platform !== 'win32') {
+          await fsPromises.rm(socketPath, { force: true });
+        }
+      }
+    };
+  }
+
+  /**
+   * Index files under root again as they are added, changed or deleted,
+   * once each has been quiet for WATCH_DEBOUNCE_MS.
+   */
+  private watchRoot(root: string, throttle: IndexThrottle): { close(): Promise<void> } {
+    const pending = new Map<string, NodeJS.Timeout>();
+    const apply = async (file: string, deleted: boolean) => {
+      try {
+        if (path.basename(file) === 'CODEOWNERS') {
+          this.codeOwners.delete(root);
+        }
+        if (deleted || !await this.configuredScanner().isIndexable(file, root)) {
+          if (this.index.hasFile(file)) {
+            this.dropFile(file);
+          }
+          return;
+        }
+        const { size } = await fsPromises.stat(file);
+        await throttle.run('watch', async () => this.storeFile(file, await fsPromises.readFile(file, 'utf-8')), { bytes: size });
+      } catch (error) {
+        this.logger.scope('index').warn(`[Indexer] Could not index changed file ${file}: ${error}`);
+      }
+    };
+    const schedule = (deleted: boolean) => (filePath: string) => {
+      const file = path.resolve(filePath);
+      clearTimeout(pending.get(file));
+      pending.set(file, setTimeout(() => {
+        pending.delete(file);
+        void apply(file, deleted);
+      }, WATCH_DEBOUNCE_MS));
+    };
+
+    const watcher = chokidar.watch(root, {
+      ignored: ['**/.git/**', '**/.smart-index/**', '**/node_modules/**'],
+      persistent: true,
+      ignoreInitial: true
+    });
+    watcher.on('add', schedule(false));
+    watcher.on('change', schedule(false));
+    watcher.on('unlink', schedule(true));
+    return {
+      close: async () => {
+        pending.forEach(timer => clearTimeout(timer));
+        pending.clear();
+        await watcher.close();
+      }
+    };
+  }
+
+  /**
+   * Index every indexable file under dir straight into an index file, for
+   * trees too large to hold in memory. Writes the file save() writes and
+   * load() reads; the in-memory index is left untouched.
+   *
+   * Results are buffered up to memoryBudgetMB, then sorted and spilled to
+   * temporary segments that are merged into outputPath at the end, so
+   * memory stays around the budget plus the files being parsed. On
+   * cancellation the segments are deleted and outputPath is left as it was.
+   */
+  async indexDirToFile(dir: string, outputPath: string, options: IndexToFileOptions = {}): Promise<IndexToFileResult> {
+    return this.withCancellation(options, token => this.indexDirToFileWithToken(dir, path.resolve(outputPath), token, options));
+  }
+
+  private async indexDirToFileWithToken(
+    dir: string,
+    outputPath: string,
+    cancellationToken: CancellationToken,
+    { onProgress, onIndexingProgress, memoryBudgetMB, tempDir }: IndexToFileOptions
+  ): Promise<IndexToFileResult> {
+    const start = Date.now();
+    const tracker = new ProgressTracker();
+    const { root, files } = await this.scan(dir, cancellationToken);
+    tracker.fileScanned(files.length);
+    tracker.startIndexing(files.length);
+    onIndexingProgress?.(tracker.snapshot());
+
+    const buffer = new SpillBuffer({
+      budgetBytes: (memoryBudgetMB ?? DEFAULT_MEMORY_BUDGET_MB) * 1024 * 1024,
+      tempDir
+    });
+    try {
+      const concurrency = Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY);
+      const indexedAt = this.buildTimestamp();
+      let skipped = 0;
+      let symbols = 0;
+      for (let i = 0; i < files.length; i += concurrency) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Indexing (${i}/${files.length})`);
+
+        const shards = await Promise.all(files.slice(i, i + concurrency).map(async file => {
+          let result: IndexedFileResult;
+          let content: string;
+          try {
+            content = await fsPromises.readFile(file, 'utf-8');
+            result = tagFileResult(await this.router.indexFile(file, content), content);
+          } catch {
+            skipped++;
+            return undefined;
+          }
+          symbols += result.symbols.length;
+          tracker.fileParsed(file);
+          tracker.fileIndexed(file, Buffer.byteLength(content));
+          return { file, data: encode(toCompactShard({ ...result, lastIndexedAt: indexedAt })) };
+        }));
+        // Added in path order, so files that fit the budget need no merging
+        for (const shard of shards) {
+          if (shard) {
+            await buffer.add(shard.file, shard.data);
+          }
+        }
+        onIndexingProgress?.(tracker.snapshot());
+      }
+
+      throwIfCancelled(cancellationToken);
+      onProgress?.(files.length, files.length, `Writing ${outputPath}`);
+      tracker.setPhase('finalizing');
+      onIndexingProgress?.(tracker.snapshot());
+
+      const segments = buffer.segmentCount;
+      const savedRoot: WorkspaceRoot = { ...new WorkspaceRoots().add(root), indexedAt: this.buildTimestamp() };
+      await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+      const bytes = await writeChunksAtomic(
+        outputPath,
+        this.indexKeys().encryptChunks(encodeSavedIndex(buffer.size, buffer.drain(), [savedRoot], cancellationToken))
+      );
+      tracker.setPhase('idle');
+      onIndexingProgress?.(tracker.snapshot());
+
+      return {
+        root,
+        name: savedRoot.name,
+        files: files.length - skipped,
+        symbols,
+        skipped,
+        removed: 0,
+        duration: Date.now() - start,
+        outputPath,
+        bytes,
+        segments
+      };
+    } finally {
+      await buffer.dispose();
+    }
+  }
+
+  private async withCancellation<T>(
+    { cancellationToken, timeoutMs, cpuProfile, heapProfile }: IndexDirOptions,
+    run: (token: CancellationToken) => Promise<T>
+  ): Promise<T> {
+    const source = new CancellationTokenSource(cancellationToken);
+    if (timeoutMs !== undefined) {
+      source.cancelAfter(timeoutMs);
+    }
+    try {
+      if (cpuProfile || heapProfile) {
+        return await new SamplingProfiler().profile({ cpuProfile, heapProfile }, () => run(source.token));
+      }
+      return await run(source.token);
+    } finally {
+      source.dispose();
+    }
+  }
+
+  /**
+   * Indexable files under dir, with generated Bazel sources if enabled, in path order.
+   */
+  private async scan(dir: string, cancellationToken: CancellationToken): Promise<{ root: string; files: string[] }> {
+    const { root, scanner } = await this.scanner(dir);
+    const files = await scanner.scanWorkspace(root, true, cancellationToken);
+    const bazel = this.configManager.getBazelConfig();
+    if ((this.options.bazel ?? bazel.enabled) && bazel.generatedSources) {
+      files.push(...await collectBazelGeneratedFiles(files));
+    }
+    return { root, files: files.sort() };
+  }
+
+  /**
+   * A file scanner for dir, with the ignore rules of dir and, with
+   * `configFile: true`, its config file.
+   */
+  private async scanner(dir: string): Promise<{ root: string; scanner: FileScanner }> {
+    const root = path.resolve(dir);
+    const stat = await fsPromises.stat(root);
+    if (!stat.isDirectory()) {
+      throw new Error(`Not a directory: ${root}`);
+    }
+
+    this.configManager.setWorkspaceRoot(root);
+    if (this.options.configFile === true) {
+      this.useConfigFile(findConfigFile(root));
+    }
+    return { root, scanner: this.configuredScanner() };
+  }
+
+  /**
+   * A file scanner with the options and the config file in use.
+   */
+  private configuredScanner(): FileScanner {
+    const config = this.configManager.getConfig();
+    const scanner = new FileScanner(this.logger.scope('walker'));
+    scanner.configure({
+      excludePatterns: this.options.excludePatterns ?? config.excludePatterns,
+      maxFileSize: (this.options.maxFileSizeMB ?? config.maxFileSizeMB) * 1024 * 1024,
+      configManager: this.configManager,
+      useFolderHashing: false
+    });
+    return scanner;
+  }
+
+  /**
+   * Lay a config file under the options; the options win. Untrusted, the
+   * settings it may not set are ignored with a warning.
+   */
+  private useConfigFile(file: ConfigFile | null): void {
+    const loaded = this.configManager.setConfigFile(file, this.options.profile, this.options.trustConfigFile === true);
+    if (loaded && loaded.ignoredSettings.length > 0) {
+      this.logger.scope('walker').warn(
+        `[Indexer] Ignoring ${loaded.ignoredSettings.join(', ')} in ${loaded.path}; pass them as options, or trustConfigFile: true`
+      );
+    }
+    this.router.setTextIndexingEnabled(this.options.textIndexing ?? this.configManager.getConfig().textIndexingEnabled);
+    this.router.setBazelEnabled(this.options.bazel ?? this.configManager.getBazelConfig().enabled);
+  }
+
+  /**
+   * Index (or re-index) one file; content is read from disk if not given.
+   * A file with an overlay keeps showing its buffer.
+   */
+  async indexFile(filePath: string, content?: string): Promise<IndexedFileResult | undefined> {
+    const uri = path.resolve(filePath);
+    return this.storeFile(uri, content ?? await fsPromises.readFile(uri, 'utf-8'));
+  }
+
+  /**
+   * Drop a file from the index, and its overlay if it has one.
+   */
+  removeFile(filePath: string): void {
+    const uri = path.resolve(filePath);
+    this.overlays.delete(uri);
+    this.index.removeFile(uri);
+  }
+
+  /**
+   * Index the unsaved buffer of an editor in place of the file, e.g.
+   * content piped in on stdin: until clearOverlay, searches, definitions,
+   * references and outlines see the buffer, while save() and push()
+   * still write what was indexed from disk. Re-indexing the file (indexDir,
+   * update, indexFile) updates what the overlay shadows and keeps the
+   * buffer. The file need not exist; relative paths are taken under the
+   * first indexed root, e.g. `overlayFile('pkg/user/person.go', buffer)`.
+   */
+  async overlayFile(filePath: string, content: string): Promise<OverlayResult> {
+    const uri = this.overlayUri(filePath);
+    const existing = this.overlays.get(uri);
+    const persisted = existing ? existing.persisted : this.index.getFileResult(uri);
+    this.overlays.set(uri, { content, persisted });
+    await this.index.updateFile(uri, content);
+    const symbols = await this.index.getFileSymbols(uri);
+    return {
+      uri,
+      symbols,
+      outline: buildOutline(symbols, { groupByContainer: true }),
+      shadowsIndexed: persisted !== undefined
+    };
+  }
+
+  /**
+   * Drop the overlay of a file (default: of every file), bringing back
+   * what was indexed before it; returns the number of overlays dropped.
+   */
+  clearOverlay(filePath?: string): number {
+    const uris = filePath !== undefined
+      ? [this.overlayUri(filePath)].filter(uri => this.overlays.has(uri))
+      : [...this.overlays.keys()];
+    for (const uri of uris) {
+      const { persisted } = this.overlays.get(uri)!;
+      this.overlays.delete(uri);
+      if (persisted) {
+        this.index.setFileResult(uri, persisted);
+      } else {
+        this.index.removeFile(uri);
+      }
+    }
+    return uris.length;
+  }
+
+  /**
+   * Files with an overlay, in path order.
+   */
+  overlaidFiles(): string[] {
+    return [...this.overlays.keys()].sort();
+  }
+
+  /**
+   * Make overlay the set of overlays, like the Overlay of go/packages'
+   * Config: each path is queried as if it had the given content, whether
+   * or not it exists, until the next setOverlay. Overlays not in it are
+   * dropped, unchanged ones are not indexed again. Paths are taken as by
+   * overlayFile.
+   */
+  async setOverlay(overlay: OverlayMap): Promise<void> {
+    const next = this.overlayEntries(overlay);
+    for (const uri of [...this.overlays.keys()]) {
+      if (!next.has(uri)) {
+        this.clearOverlay(uri);
+      }
+    }
+    for (const [uri, content] of next) {
+      if (this.overlays.get(uri)?.content !== content) {
+        await this.overlayFile(uri, content);
+      }
+    }
+  }
+
+  /**
+   * Run fn with overlay laid over the index and the overlays already set,
+   * then go back to those, also when fn throws:
+   *
+   *   const defs = await idx.withOverlay({ 'pkg/user/person.go': edited }, () => idx.findDefinitions('Person'));
+   */
+  async withOverlay<T>(overlay: OverlayMap, fn: () => Promise<T>): Promise<T> {
+    const previous = new Map([...this.overlays].map(([uri, { content }]) => [uri, content]));
+    await this.setOverlay(new Map([...previous, ...this.overlayEntries(overlay)]));
+    try {
+      return await fn();
+    } finally {
+      await this.setOverlay(previous);
+    }
+  }
+
+  private overlayUri(filePath: string): string {
+    return path.resolve(this.workspaceRoots.paths()[0] ?? process.cwd(), filePath);
+  }
+
+  private overlayEntries(overlay: OverlayMap): Map<string, string> {
+    const entries = overlay instanceof Map ? [...overlay] : Object.entries(overlay);
+    return new Map(entries.map(([filePath, content]) => [this.overlayUri(filePath), content]));
+  }
+
+  /**
+   * Index a file read from disk; under an overlay, as what it shadows.
+   */
+  private async storeFile(uri: string, content: string): Promise<IndexedFileResult | undefined> {
+    await this.index.updateFile(uri, content);
+    const result = this.index.getFileResult(uri);
+    const overlay = this.overlays.get(uri);
+    if (overlay) {
+      overlay.persisted = result;
+      await this.index.updateFile(uri, overlay.content);
+    }
+    return result;
+  }
+
+  /**
+   * Drop a file gone from disk; an overlay keeps its buffer indexed.
+   */
+  private dropFile(uri: string): void {
+    const overlay = this.overlays.get(uri);
+    if (overlay) {
+      overlay.persisted = undefined;
+    } else {
+      this.index.removeFile(uri);
+    }
+  }
+
+  /**
+   * Fuzzy symbol search by name, ranked by match quality and popularity.
+   * `scope` limits it to a named scope: `search('Greet', 50, { scope: 'backend' })`.
+   */
+  async search(query: string, limit: number = 50, options: ScopeOptions = {}): Promise<IndexedSymbol[]> {
+    return (await this.searchRanked(query, limit, options)).map(r => r.symbol);
+  }
+
+  /**
+   * Fuzzy symbol search with the ranking scores: how well the name
+   * matches, plus popularity from reference counts and exported-ness (see
+   * utils/popularity.ts), e.g. `New` -> the constructors callers use most.
+   */
+  async searchRanked(query: string, limit: number = 50, options: ScopeOptions = {}): Promise<RankedSymbol<IndexedSymbol>[]> {
+    return this.rank(query, limit, Math.min(limit * 2, MAX_SEARCH_CANDIDATES), options.scope);
+  }
+
+  /**
+   * One page of ranked search results, from `offset` or after the
+   * `cursor` of the previous page (default: 50 per page). Deep pages
+   * rank all candidates up to their end, so they get slower.
+   */
+  async searchPage(query: string, options: PageOptions & ScopeOptions = {}): Promise<Page<RankedSymbol<IndexedSymbol>>> {
+    const window = pageWindow(options, 50, MAX_SEARCH_CANDIDATES, queryFingerprint('symbols', query, options.scope ?? null));
+    const candidateLimit = Math.max(Math.min(window.fetch * 2, MAX_SEARCH_CANDIDATES), window.fetch);
+    const ranked = await this.rank(query, window.fetch, candidateLimit, options.scope);
+    return takePage(ranked, window, r => r.symbol.id);
+  }
+
+  /**
+   * Ranked candidates; in a scope, as many candidates as searches take,
+   * so the filter does not starve the result. Throws ScopeError.
+   */
+  private async rank(query: string, limit: number, candidateLimit: number, scope?: string): Promise<RankedSymbol<IndexedSymbol>[]> {
+    const filter = scope ? this.searchScopes().filter(scope) : undefined;
+    const candidates = await this.index.searchSymbols(query, filter ? MAX_SEARCH_CANDIDATES : candidateLimit);
+    const inScope = filter ? await this.structuredQuery().filter(candidates, filter) : candidates;
+    const referenceCounts = await this.index.getReferenceCounts(inScope.map(s => s.name));
+    return rankSymbols(inScope, query, { popularity: { referenceCounts } }).slice(0, limit);
+  }
+
+  /**
+   * Structured query, e.g. `kind:func receiver:Person exported:true`
+   * (see utils/queryLanguage.ts), in a named scope if `scope` is given.
+   * Throws QuerySyntaxError on bad queries and ScopeError on unknown scopes.
+   */
+  async query(query: string, options: StructuredQueryOptions & ScopeOptions = {}): Promise<StructuredQueryResult> {
+    return this.structuredQuery().run(this.searchScopes().restrict(query, options.scope), options);
+  }
+
+  /**
+   * One page of a structured query (default: 100 per page), e.g.
+   * `queryPage(q, { limit: 500, cursor: previous.nextCursor })`.
+   */
+  async queryPage(query: string, options: StructuredQueryPageOptions & ScopeOptions = {}): Promise<StructuredQueryPage> {
+    return this.structuredQuery().page(this.searchScopes().restrict(query, options.scope), options);
+  }
+
+  /**
+   * One page of a saved query of the config (`search.queries`), in its
+   * scope or in `scope` instead. Throws ScopeError on unknown queries.
+   */
+  async savedQuery(name: string, options: StructuredQueryPageOptions & ScopeOptions = {}): Promise<StructuredQueryPage> {
+    return this.structuredQuery().page(this.searchScopes().resolve(name, options.scope), options);
+  }
+
+  /** The named scopes and saved queries of the config */
+  scopes(): { scopes: SearchScope[]; queries: SavedQuery[] } {
+    const scopes = this.searchScopes();
+    return { scopes: scopes.scopes(), queries: scopes.savedQueries() };
+  }
+
+  private searchScopes(): SearchScopes {
+    return new SearchScopes(this.configManager.getSearchConfig());
+  }
+
+  private structuredQuery(): StructuredQuery {
+    return new StructuredQuery(this, this.ownerLookup(), this.coverageIndex);
+  }
+
+  /**
+   * Run a GraphQL query against the index schema (see
+   * features/graphqlSchema.ts; `schema` for the SDL), e.g.
+   * `{ definitions(name: "Get") { references { enclosing { name owners } } } }`.
+   * Invalid documents come back as `errors` without `data`.
+   */
+  async graphql(query: string, variables?: Record<string, unknown>, operationName?: string): Promise<GraphQLResult> {
+    return this.indexGraphQL().execute({ query, variables, operationName });
+  }
+
+  /** The GraphQL schema graphql() answers, in SDL */
+  graphqlSchema(): string {
+    return this.indexGraphQL().schema();
+  }
+
+  private indexGraphQL(): IndexGraphQL {
+    return new IndexGraphQL({
+      findDefinitions: name => this.findDefinitions(name),
+      findDefinitionById: id => this.index.findDefinitionById(id),
+      searchSymbols: (query, limit) => this.search(query, limit),
+      getFileSymbols: uri => this.getFileSymbols(uri),
+      findReferencesByName: name => this.findReferences(name)
+    }, filePath => this.owners(filePath));
+  }
+
+  async findDefinitions(name: string): Promise<IndexedSymbol[]> {
+    return this.index.findDefinitions(name);
+  }
+
+  /**
+   * References to each name across the index, as search ranking counts them.
+   */
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    return this.index.getReferenceCounts(names);
+  }
+
+  async findReferences(name: string): Promise<IndexedReference[]> {
+    return this.index.findReferencesByName(name);
+  }
+
+  /**
+   * One page of the references to a name, in index order, e.g.
+   * `findReferencesPage('Load', { limit: 1000 })`. Without a limit the
+   * page holds all remaining references.
+   */
+  async findReferencesPage(name: string, options: PageOptions = {}): Promise<Page<IndexedReference>> {
+    const window = pageWindow(options, Infinity, Infinity, queryFingerprint('references', name));
+    return takePage(await this.findReferences(name), window, locationKey);
+  }
+
+  async getFileSymbols(filePath: string): Promise<IndexedSymbol[]> {
+    return this.index.getFileSymbols(path.resolve(filePath));
+  }
+
+  /**
+   * A query server over this index (see features/queryServer.ts), to
+   * start() on its own or add to a QueryServerHost with the indexes of
+   * other repositories: `host.add('payments', indexer.queryServer())`.
+   * It answers from the index as it is when queried, so updates show up
+   * without restarting it.
+   */
+  queryServer(): QueryServer {
+    return new QueryServer(
+      this.index, this.logger, undefined, undefined,
+      new StructuredQuery(this, this.ownerLookup(), {
+        refresh: async () => this.coverageIndex?.refresh(),
+        coverageOf: symbol => this.coverageIndex?.coverageOf(symbol)
+      }),
+      undefined, undefined, new CodeMetrics(this), new Ownership(this, this.ownerLookup()),
+      new PositionLookup({
+        getFileResult: async uri => this.index.getFileResult(uri) ?? null,
+        findDefinitions: name => this.findDefinitions(name)
+      }),
+      query => this.commentMarkers({ ...query, blame: true }),
+      query => this.httpRoutes(query),
+      filter => this.sqlQueries(filter),
+      query => this.configKeys(query),
+      query => this.concurrency(query),
+      query => this.errorPaths(query),
+      query => this.coverage(query),
+      query => this.testsFor(query.symbol, query),
+      undefined,
+      undefined,
+      {
+        search: query => this.docs(query),
+        mentions: query => this.docMentions(query.symbol, query),
+        links: uri => this.docLinks(uri)
+      },
+      {
+        overlay: async (uri, content) => {
+          const { uri: file, shadowsIndexed } = await this.overlayFile(uri, content);
+          return { uri: file, shadowsIndexed };
+        },
+        clear: uri => this.clearOverlay(uri)
+      },
+      query => this.complete(query)
+    );
+  }
+
+  /**
+   * The symbol declared or used at a 0-based line and character of an
+   * indexed file, with its definitions, best first; null when there is
+   * none (see features/positionLookup.ts).
+   */
+  async definitionAt(filePath: string, line: number, character: number): Promise<PositionLookupResult | null> {
+    return new PositionLookup({
+      getFileResult: async uri => this.index.getFileResult(uri) ?? null,
+      findDefinitions: name => this.findDefinitions(name)
+    }).lookup(path.resolve(filePath), line, character);
+  }
+
+  /**
+   * What renaming oldName (`Name` or `Type.Name`) to newName would change:
+   * every location, collisions with existing declarations, and the
+   * packages it reaches.
+   */
+  async renameCheck(oldName: string, newName: string): Promise<RenameImpact> {
+    return new RenameImpactAnalyzer({
+      findDefinitions: name => this.findDefinitions(name),
+      findReferencesByName: name => this.findReferences(name)
+    }).check(oldName, newName);
+  }
+
+  /**
+   * What each type named name (`AdminUser` or `pkg.AdminUser`) embeds or
+   * extends (`supertypes`), or what embeds or extends it (`subtypes`),
+   * transitively, as one tree per type (see features/typeHierarchy.ts).
+   */
+  async typeHierarchy(
+    name: string,
+    direction: TypeHierarchyDirection = 'supertypes',
+    options: TypeHierarchyQueryOptions = {}
+  ): Promise<TypeHierarchyNode[]> {
+    const hierarchy = new TypeHierarchy(this);
+    await hierarchy.build();
+    const queryOptions = { ...options, uri: options.uri && path.resolve(options.uri) };
+    return direction === 'subtypes' ? hierarchy.subtypes(name, queryOptions) : hierarchy.supertypes(name, queryOptions);
+  }
+
+  /**
+   * Near-duplicate functions, grouped into clusters (see
+   * features/cloneDetection.ts). Reads the indexed files from disk.
+   */
+  async findClones(options: CloneDetectionOptions = {}): Promise<CloneReport> {
+    return new CloneDetector(this).findClones(options);
+  }
+
+  /**
+   * Functions ranked by complexity, size, parameter count or nesting, e.g.
+   * `{ sort: 'complexity', limit: 50 }` (see features/codeMetrics.ts).
+   */
+  async functionMetrics(options: CodeMetricsOptions = {}): Promise<CodeMetricsReport> {
+    return new CodeMetrics(this).report(options);
+  }
+
+  /**
+   * Files with syntax errors and their diagnostics; the rest of each file
+   * is indexed (see features/parseErrors.ts).
+   */
+  async parseErrors(options: ParseErrorOptions = {}): Promise<ParseErrorReport> {
+    return new ParseErrors({
+      getAllFiles: () => this.getAllFiles(),
+      getFileResult: async uri => this.index.getFileResult(uri) ?? null
+    }).list(options);
+  }
+
+  /**
+   * Owners of a file per CODEOWNERS (see IndexerOptions.repositoryRoot).
+   */
+  owners(filePath: string): string[] {
+    const file = path.resolve(filePath);
+    if (this.repositoryOwners) {
+      return this.repositoryOwners.ownersOf(file);
+    }
+    const root = this.workspaceRoots.rootOf(file);
+    if (!root) {
+      return [];
+    }
+    let codeOwners = this.codeOwners.get(root.path);
+    if (!codeOwners) {
+      codeOwners = new CodeOwners(root.path);
+      this.codeOwners.set(root.path, codeOwners);
+    }
+    return codeOwners.ownersOf(file);
+  }
+
+  /**
+   * Files, definitions and exported definitions per CODEOWNERS owner, e.g.
+   * `{ owner: '@platform-team' }`; list an owner's API with
+   * `query('exported:true owner:@platform-team')`.
+   */
+  async ownership(options: OwnershipOptions = {}): Promise<OwnershipReport> {
+    return new Ownership(this, this.ownerLookup()).summarize(options);
+  }
+
+  /**
+   * Every use of a deprecated symbol (`Deprecated:` / `@deprecated` in its
+   * doc), grouped by the CODEOWNERS owner of the code using it, e.g.
+   * `{ tags: { exclude: ['test'] } }` (see features/deprecations.ts).
+   */
+  async deprecations(options: DeprecationOptions = {}): Promise<DeprecationReport> {
+    return new Deprecations({
+      getAllFiles: () => this.getAllFiles(),
+      getFileResult: async uri => this.index.getFileResult(uri) ?? null,
+      findDefinitions: name => this.findDefinitions(name)
+    }, this.ownerLookup()).report(options);
+  }
+
+  /**
+   * Exported API of a Go module (`github.com/acme/api`, or
+   * `path@version` for a dependency indexed in several versions), one
+   * line per feature; `formatApiSurface` writes it for diffing (see
+   * features/apiSurface.ts). `goModules` lists the modules to ask for.
+   */
+  async apiSurface(module: string, options: ApiSurfaceOptions = {}): Promise<ApiSurfaceReport> {
+    return new ApiSurface(this).build(module, options);
+  }
+
+  async goModules(): Promise<IndexedGoModule[]> {
+    return new ApiSurface(this).modules();
+  }
+
+  /**
+   * Classify the exported API changes of the git repository at dir over a
+   * range such as `v1.2.0..HEAD` as major, minor or patch, and check them
+   * against the release version (see features/semverCheck.ts). CI jobs
+   * fail on `!report.compatible`. Revisions are read from git, not from
+   * the index.
+   */
+  async semverCheck(dir: string, range: string, options: SemverCheckOptions = {}): Promise<SemverReport> {
+    return new SemverCheck(new IndexDiff(this.router, path.resolve(dir))).check(range, options);
+  }
+
+  /**
+   * Check the indexed files under dir against policies, by default the
+   * `policies` of the config file in use (see features/policyCheck.ts).
+   * With `since`, only code new since that commit counts; CI jobs index
+   * dir first and fail on `!report.passed`, printing
+   * `formatPolicyReport(report)`.
+   */
+  async checkPolicies(dir: string, policies?: PolicyConfig[], options: PolicyCheckOptions = {}): Promise<PolicyReport> {
+    const index = {
+      getAllFiles: () => this.getAllFiles(),
+      getFileResult: async (uri: string) => this.index.getFileResult(uri) ?? null
+    };
+    return new PolicyCheck(index, path.resolve(dir), (uri, content) => this.router.indexFile(uri, content))
+      .check(policies ?? this.configManager.getConfig().policies ?? [], options);
+  }
+
+  /**
+   * TODO, FIXME, HACK, DEPRECATED and configured comment markers of the
+   * indexed files, by tag, path, author and age, e.g.
+   * `{ tags: ['FIXME'], minAgeDays: 365, sort: 'age', blame: true }`
+   * (see features/commentMarkers.ts). Reads the indexed files from disk.
+   */
+  async commentMarkers(options: CommentMarkerOptions = {}): Promise<CommentMarkerReport> {
+    if (!this.markerIndex) {
+      this.markerIndex = new CommentMarkerIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri)
+      });
+    }
+    this.markerIndex.setConfig(this.configManager.getCommentMarkersConfig());
+    await this.markerIndex.build({ cancellationToken: options.cancellationToken });
+    const { blame, ...query } = options;
+    return this.markerIndex.query(query, blame ? this.fileBlame() : undefined);
+  }
+
+  /**
+   * HTTP routes the indexed Go files register with net/http, chi, gin or
+   * echo, each with its handler's definition, e.g. `{ method: 'GET',
+   * route: '/api/v1' }` (see features/httpRoutes.ts). Reads the indexed
+   * files from disk.
+   */
+  async httpRoutes(query: HttpRouteQuery = {}): Promise<HttpRouteReport> {
+    if (!this.routeIndex) {
+      this.routeIndex = new HttpRouteIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        getFileResult: async uri => this.index.getFileResult(uri) ?? null,
+        findDefinitions: name => this.findDefinitions(name)
+      });
+    }
+    this.routeIndex.setFrameworks(this.configManager.getHttpRoutesConfig().frameworks);
+    await this.routeIndex.build({ cancellationToken: query.cancellationToken });
+    return this.routeIndex.query(query);
+  }
+
+  /**
+   * SQL statements the indexed Go files pass to database/sql, sqlx or pgx,
+   * with the tables and columns they touch and the functions running them,
+   * e.g. `{ table: 'users', access: 'write' }` (see features/sqlQueries.ts).
+   * Reads the indexed files from disk.
+   */
+  async sqlQueries(filter: SqlQueryFilter = {}): Promise<SqlQueryReport> {
+    if (!this.sqlIndex) {
+      this.sqlIndex = new SqlQueryIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        getFileSymbols: uri => this.index.getFileSymbols(uri)
+      });
+    }
+    await this.sqlIndex.build({ cancellationToken: filter.cancellationToken });
+    return this.sqlIndex.query(filter);
+  }
+
+  /**
+   * Environment variables, viper keys and flags the indexed Go files read,
+   * where, and with what defaults, e.g. `{ source: 'env' }` (see
+   * features/configKeys.ts). Reads the indexed files from disk.
+   */
+  async configKeys(query: ConfigKeyQuery = {}): Promise<ConfigKeyReport> {
+    if (!this.configKeyIndex) {
+      this.configKeyIndex = new ConfigKeyIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri)
+      });
+    }
+    await this.configKeyIndex.build({ cancellationToken: query.cancellationToken });
+    return this.configKeyIndex.query(query);
+  }
+
+  /**
+   * Where the indexed Go files start goroutines, make, send on, receive
+   * from and close channels, and lock mutexes, with the functions doing
+   * it, e.g. `{ op: 'go', package: 'worker' }` (see features/concurrency.ts).
+   * Reads the indexed files from disk.
+   */
+  async concurrency(query: ConcurrencyQuery = {}): Promise<ConcurrencyReport> {
+    if (!this.concurrencyIndex) {
+      this.concurrencyIndex = new ConcurrencyIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        getFileSymbols: uri => this.index.getFileSymbols(uri)
+      });
+    }
+    await this.concurrencyIndex.build({ cancellationToken: query.cancellationToken });
+    return this.concurrencyIndex.query(query);
+  }
+
+  /**
+   * Where the indexed Go files panic, recover, pass errors on and assign
+   * them to `_`, with which functions can panic, e.g. `{ exported: true,
+   * canPanic: true }` (see features/errorPaths.ts). Reads the indexed
+   * files from disk.
+   */
+  async errorPaths(query: ErrorPathQuery = {}): Promise<ErrorPathReport> {
+    if (!this.errorPathIndex) {
+      this.errorPathIndex = new ErrorPathIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        getFileSymbols: uri => this.index.getFileSymbols(uri)
+      });
+    }
+    await this.errorPathIndex.build({ cancellationToken: query.cancellationToken });
+    return this.errorPathIndex.query(query);
+  }
+
+  /**
+   * Statement coverage of the indexed Go functions from `go test
+   * -coverprofile` profiles, with how often each is referenced, e.g. the
+   * exported functions no test runs that more than 10 places use:
+   * `{ profiles: ['coverage.out'], exported: true, maxCoverage: 0,
+   * minReferences: 11 }` (see features/coverage.ts). The profiles stay
+   * loaded for later calls and for `coverage:` terms of query().
+   */
+  async coverage(options: CoverageOptions = {}): Promise<CoverageReport> {
+    if (!this.coverageIndex) {
+      this.coverageIndex = new CoverageIndex(this);
+    }
+    if (options.profiles) {
+      this.coverageIndex.setProfiles(options.profiles.map(profile => path.resolve(profile)));
+    }
+    return this.coverageIndex.query(options);
+  }
+
+  /**
+   * The Go tests, benchmarks, fuzz targets and examples exercising a
+   * symbol, directly or through the functions calling it, with `go test`
+   * commands per package, e.g. `testsFor('user.Person', { maxDepth: 2 })`
+   * (see features/goTests.ts).
+   */
+  async testsFor(symbol: string, options: Omit<TestsForQuery, 'symbol'> = {}): Promise<TestsForReport> {
+    const tests = await this.goTests(options.cancellationToken);
+    return tests.testsFor({ ...options, symbol });
+  }
+
+  /**
+   * The Go tests the changes to dir since a commit can break, with the
+   * `go test` commands running only those, e.g. in CI:
+   * `affectedTests('.', 'origin/main')` (see features/affectedTests.ts).
+   * Uncommitted changes count. The index should include them: update()
+   * with the same commit first when it was saved before the changes.
+   */
+  async affectedTests(dir: string, since: string, options: Omit<AffectedTestsQuery, 'since'> = {}): Promise<AffectedTestsReport> {
+    const tests = await this.goTests(options.cancellationToken);
+    return new AffectedTests(tests, this, path.resolve(dir)).find({ ...options, since });
+  }
+
+  private async goTests(cancellationToken?: CancellationToken): Promise<GoTestIndex> {
+    if (!this.goTestIndex) {
+      this.goTestIndex = new GoTestIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        getFileResult: async uri => this.index.getFileResult(uri),
+        findDefinitions: name => this.findDefinitions(name)
+      });
+    }
+    await this.goTestIndex.build({ cancellationToken });
+    return this.goTestIndex;
+  }
+
+  /**
+   * Sections of the indexed Markdown and AsciiDoc files containing every
+   * word of `text`, e.g. `docs({ text: 'retry', language: 'go' })` for
+   * sections with a Go example (see features/docsIndex.ts).
+   */
+  async docs(query: DocSearchQuery = {}): Promise<DocSearchReport> {
+    const docs = await this.docsOf(query.cancellationToken);
+    return docs.search(query);
+  }
+
+  /**
+   * Inline code in the docs resolving to a symbol, e.g.
+   * `docMentions('Person.Greet')` finds `` `Person.Greet` `` and, next to
+   * its package, `` `Greet()` ``.
+   */
+  async docMentions(symbol: string, options: Omit<DocMentionQuery, 'symbol'> = {}): Promise<DocMentionReport> {
+    const docs = await this.docsOf(options.cancellationToken);
+    return docs.mentions({ ...options, symbol });
+  }
+
+  /**
+   * The symbol mentions of one document with the definitions they resolve to.
+   */
+  async docLinks(filePath: string): Promise<DocLinkReport> {
+    const docs = await this.docsOf();
+    return docs.links(path.resolve(filePath));
+  }
+
+  private async docsOf(cancellationToken?: CancellationToken): Promise<DocsIndex> {
+    if (!this.docsIndex) {
+      this.docsIndex = new DocsIndex({
+        getAllFiles: () => this.getAllFiles(),
+        getFileInfo: uri => this.index.getFileInfo(uri),
+        findDefinitions: name => this.findDefinitions(name)
+      });
+    }
+    await this.docsIndex.build({ cancellationToken });
+    return this.docsIndex;
+  }
+
+  /**
+   * Shell completions for a partly typed symbol name or path (see
+   * features/completions.ts), e.g. `complete({ kind: 'symbol', prefix:
+   * 'Person.Gr' })` -> `['Person.Greet']`. Paths are relative to `cwd`,
+   * by default the first indexed root.
+   */
+  async complete(query: CompletionQuery): Promise<string[]> {
+    const completions = new IndexCompletions({
+      searchSymbols: (text, limit) => this.search(text, limit),
+      getAllFiles: () => this.getAllFiles()
+    }, () => this.workspaceRoots.paths());
+    return completions.complete(query);
+  }
+
+  /**
+   * Declarations of a file as a tree: members under their types (Go
+   * methods too), nested functions under their functions.
+   */
+  async outline(filePath: string): Promise<OutlineNode[]> {
+    return buildOutline(await this.getFileSymbols(filePath), { groupByContainer: true });
+  }
+
+  /**
+   * Indexed files, in path order.
+   */
+  async getAllFiles(): Promise<string[]> {
+    return this.index.getIndexedFiles().sort();
+  }
+
+  getStats(): IndexerStats {
+    return this.index.getStats();
+  }
+
+  /**
+   * Blame of indexed files, from the repository each one is in; HEAD is
+   * read once per repository and call.
+   */
+  private fileBlame(): MarkerBlame {
+    const heads = new Map<string, Promise<string>>();
+    return async filePath => {
+      const root = this.options.repositoryRoot ? path.resolve(this.options.repositoryRoot) : this.workspaceRoots.rootOf(filePath)?.path;
+      if (!root) {
+        return undefined;
+      }
+      let history = this.histories.get(root);
+      if (!history) {
+        history = new SymbolHistoryIndex(this, root);
+        this.histories.set(root, history);
+      }
+      if (!heads.has(root)) {
+        heads.set(root, history.getHead());
+      }
+      return history.getFileBlame(filePath, await heads.get(root)!);
+    };
+  }
+
+  private ownerLookup() {
+    return { ownersOf: (filePath: string) => this.owners(filePath) };
+  }
+
+  /**
+   * Write the whole index, with its roots, to filePath. The file is written
+   * next to its destination and renamed into place, so readers never see a
+   * partial file.
+   */
+  async save(filePath: string): Promise<void> {
+    await fsPromises.mkdir(path.dirname(path.resolve(filePath)), { recursive: true });
+    const saved = await this.toSavedIndex();
+    await writeFileAtomic(filePath, await this.indexKeys().encrypt(encode(saved)));
+    this.logger.scope('store').info(`[Indexer] Saved ${saved.files.length} files to ${filePath}`);
+  }
+
+  /**
+   * Replace the index with one written by save(). Throws on files that are
+   * not saved indexes or were saved by an incompatible version.
+   */
+  async load(filePath: string): Promise<IndexerStats> {
+    const saved = await readSavedIndex(filePath, this.indexKeys());
+    this.restore(saved, filePath, 'index again');
+    const stats = this.getStats();
+    this.logger.scope('store').info(`[Indexer] Loaded ${stats.files} files from ${filePath}`);
+    return stats;
+  }
+
+  /**
+   * Upload the whole index, compressed and with a checksummed manifest, to
+   * an `s3://`, `gs://` or `http(s)://` URL (see utils/remoteStorage.ts for
+   * credentials), e.g. from CI so that developers can pull it instead of
+   * indexing locally.
+   */
+  async push(url: string, options: RemoteOptions = {}): Promise<PushIndexResult> {
+    return this.withCancellation(options, async cancellationToken => {
+      const saved = await this.toSavedIndex();
+      return pushIndex(url, encode(saved), { files: saved.files.length, roots: saved.roots ?? [] }, {
+        ...options,
+        cancellationToken,
+        keys: this.indexKeys()
+      });
+    });
+  }
+
+  /**
+   * Replace the index with one uploaded by push(), after checking it
+   * against its manifest. An index built on another machine is moved to the
+   * checkouts in `roots`; files under none of them are left out. Throws when
+   * there is no index at url or the download does not match its checksums.
+   */
+  async pull(url: string, options: PullOptions = {}): Promise<PullResult> {
+    return this.withCancellation(options, async cancellationToken => {
+      const { manifest, saved } = await pullIndex(url, { ...options, cancellationToken, keys: this.indexKeys() });
+      const outside = this.restore(saved, url, 'push it again', options.roots?.map(dir => path.resolve(dir)));
+      return {
+        url,
+        ...this.getStats(),
+        outside,
+        compressedBytes: manifest.compressedBytes,
+        bytes: manifest.bytes,
+        createdAt: manifest.createdAt
+      };
+    });
+  }
+
+  /** Keys in the order encodeSavedIndex() writes them, so save() and indexDirToFile() agree byte for byte */
+  private async toSavedIndex(): Promise<SavedIndex> {
+    const now = this.buildTimestamp();
+    return {
+      format: SAVED_INDEX_FORMAT,
+      shardVersion: SHARD_VERSION,
+      roots: this.workspaceRoots.list().map(root =>
+        this.options.reproducible && root.indexedAt !== undefined ? { ...root, indexedAt: now } : root
+      ),
+      files: (await this.getAllFiles())
+        .map(uri => this.overlays.has(uri) ? this.overlays.get(uri)!.persisted : this.index.getFileResult(uri))
+        .filter((result): result is IndexedFileResult => result !== undefined)
+        .map(result => toCompactShard({ ...result, lastIndexedAt: now }))
+    };
+  }
+
+  private buildTimestamp(): number {
+    return buildTimestamp(this.options.reproducible);
+  }
+
+  /**
+   * Keys of index files: the config's encryption, unless options.encryption
+   * turns it on or off or replaces its key provider; enabled forces it on.
+   */
+  private indexKeys(enabled?: boolean): IndexKeys {
+    const { encryption } = this.options;
+    const config = this.configManager.getEncryptionConfig();
+    return indexKeysFor(
+      { ...config, enabled: enabled ?? (encryption === undefined ? config.enabled : encryption !== false) },
+      { provider: typeof encryption === 'object' ? encryption : undefined, cwd: this.workspaceRoots.list()[0]?.path }
+    );
+  }
+
+  /**
+   * Replace the index and roots with a decoded saved index, moved to
+   * localRoots when given; returns the number of files left out as under
+   * none of them.
+   */
+  private restore(saved: DecodedSavedIndex, source: string, remedy: string, localRoots?: string[]): number {
+    if (saved.shardVersion !== SHARD_VERSION) {
+      throw new Error(`Index ${source} has format version ${saved.shardVersion}, expected ${SHARD_VERSION}; ${remedy}`);
+    }
+    const roots = savedRoots(saved);
+    let mappings: RootMapping[] | undefined;
+    if (localRoots) {
+      const local = new WorkspaceRoots();
+      localRoots.forEach(dir => local.add(dir));
+      mappings = mapSavedRoots(roots, local.list());
+    }
+
+    const shards: CompactShard[] = [];
+    let outside = 0;
+    for (const compact of saved.files) {
+      if (!isCompactShard(compact)) {
+        throw new Error(`Corrupt saved index: ${source}`);
+      }
+      const shard = mappings ? relocateShard(compact, mappings) : compact;
+      if (shard) {
+        shards.push(shard);
+      } else {
+        outside++;
+      }
+    }
+
+    this.overlays.clear();
+    for (const uri of this.index.getIndexedFiles()) {
+      this.index.removeFile(uri);
+    }
+    for (const shard of shards) {
+      const { lastIndexedAt: _lastIndexedAt, mtime: _mtime, ...result } = fromCompactShard(shard);
+      this.index.setFileResult(result.uri, result);
+    }
+
+    this.workspaceRoots.clear();
+    this.codeOwners.clear();
+    for (const root of roots) {
+      const mapped = mappings ? mappings.find(m => m.from === root.path)?.to : root.path;
+      if (mapped) {
+        this.workspaceRoots.add(mapped, root.name);
+        if (typeof root.indexedAt === 'number') {
+          this.workspaceRoots.markIndexed(mapped, root.indexedAt);
+        }
+      }
+    }
+    return outside;
+  }
+
+  /**
+   * Check an index file written by save() or indexDirToFile(): format
+   * version, integrity of its entries, and files deleted or changed since
+   * (see features/indexVerifier.ts). `reindex` lists exactly the files to
+   * index again; with `repair` the file is rewritten with them re-indexed
+   * and deleted files dropped. Throws on files that are not saved indexes.
+   */
+  async verify(filePath: string, options: VerifyIndexOptions = {}): Promise<VerifyIndexResult> {
+    return this.withCancellation(options, token => this.verifyWithToken(path.resolve(filePath), token, options));
+  }
+
+  private async verifyWithToken(
+    filePath: string,
+    cancellationToken: CancellationToken,
+    { root, checkFiles, repair, onProgress }: VerifyIndexOptions
+  ): Promise<VerifyIndexResult> {
+    const keys = this.indexKeys();
+    const data = await fsPromises.readFile(filePath);
+    const saved = decodeSavedIndex(await keys.decrypt(data, filePath), filePath);
+    const expectedFiles = root ? (await this.scan(root, cancellationToken)).files : undefined;
+    const report = await new IndexVerifier().verify(
+      { shardVersion: saved.shardVersion, files: saved.files },
+      { checkFiles, expectedFiles, cancellationToken, onProgress }
+    );
+    if (!repair || (report.reindex.length === 0 && report.remove.length === 0)) {
+      return report;
+    }
+
+    const dropped = new Set([...report.reindex, ...report.remove]);
+    const shards = new Map<string, CompactShard>();
+    for (const entry of saved.files) {
+      if (isCompactShard(entry) && !dropped.has(entry.u)) {
+        shards.set(entry.u, entry);
+      }
+    }
+
+    const concurrency = Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY);
+    const indexedAt = this.buildTimestamp();
+    let skipped = 0;
+    for (let i = 0; i < report.reindex.length; i += concurrency) {
+      throwIfCancelled(cancellationToken);
+      await yieldToEventLoop();
+      onProgress?.(i, report.reindex.length, `Indexing (${i}/${report.reindex.length})`);
+
+      await Promise.all(report.reindex.slice(i, i + concurrency).map(async file => {
+        try {
+          const content = await fsPromises.readFile(file, 'utf-8');
+          const result = tagFileResult(await this.router.indexFile(file, content), content);
+          shards.set(file, toCompactShard({ ...result, lastIndexedAt: indexedAt }));
+        } catch {
+          skipped++;
+        }
+      }));
+    }
+
+    throwIfCancelled(cancellationToken);
+    onProgress?.(report.reindex.length, report.reindex.length, `Writing ${filePath}`);
+    const repaired: SavedIndex = {
+      format: SAVED_INDEX_FORMAT,
+      shardVersion: SHARD_VERSION,
+      roots: savedRoots(saved),
+      files: [...shards.keys()].sort().map(uri => shards.get(uri)!)
+    };
+    // A repaired file stays encrypted
+    const writeKeys = isEncryptedIndex(data) && !keys.encrypts ? this.indexKeys(true) : keys;
+    await writeFileAtomic(filePath, await writeKeys.encrypt(encode(repaired)));
+    return {
+      ...report,
+      repaired: { removed: report.remove.length, reindexed: report.reindex.length - skipped, skipped }
+    };
+  }
+}
+
+/**
+ * Create an in-memory indexer; see Indexer.
+ */
+export function createIndexer(options: IndexerOptions = {}): Indexer {
+  return new Indexer(options);
+}
+
+/**
+ * Decoded index file, decrypted with keys, with its entries not yet
+ * checked. Throws on files that are not saved indexes.
+ */
+async function readSavedIndex(filePath: string, keys: IndexKeys): Promise<DecodedSavedIndex> {
+  return decodeSavedIndex(await keys.decrypt(await fsPromises.readFile(filePath), filePath), filePath);
+}
+
+/**
+ * A SavedIndex in MessagePack, produced shard by shard: the map and array
+ * headers are encoded by hand so the file list is never in memory at once.
+ * The bytes are those encode() writes for the whole of it, as save() does.
+ */
+async function* encodeSavedIndex(
+  count: number,
+  shards: AsyncIterable<SpillRecord>,
+  roots: WorkspaceRoot[],
+  cancellationToken: CancellationToken
+): AsyncGenerator<Uint8Array> {
+  yield Buffer.concat([
+    Uint8Array.of(0x84), // map of 4
+    encode('format'), encode(SAVED_INDEX_FORMAT),
+    encode('shardVersion'), encode(SHARD_VERSION),
+    encode('roots'), encode(roots),
+    encode('files'), arrayHeader(count)
+  ]);
+
+  let chunk: Uint8Array[] = [];
+  let chunkBytes = 0;
+  for await (const { data } of shards) {
+    chunk.push(data);
+    chunkBytes += data.length;
+    if (chunkBytes >= WRITE_CHUNK_SIZE) {
+      throwIfCancelled(cancellationToken);
+      yield Buffer.concat(chunk);
+      chunk = [];
+      chunkBytes = 0;
+    }
+  }
+  if (chunk.length > 0) {
+    yield Buffer.concat(chunk);
+  }
+}
+
+/** The shortest MessagePack array header for count items, as encode() writes it */
+function arrayHeader(count: number): Buffer {
+  if (count < 16) {
+    return Buffer.of(0x90 | count); // fixarray
+  }
+  if (count <= 0xffff) {
+    const header = Buffer.alloc(3);
+    header[0] = 0xdc; // array 16
+    header.writeUInt16BE(count, 1);
+    return header;
+  }
+  const header = Buffer.alloc(5);
+  header[0] = 0xdd; // array 32
+  header.writeUInt32BE(count, 1);
+  return header;
+}
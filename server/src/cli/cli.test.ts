@@ -0,0 +1,184 @@
+/**
+ * CLI Tests
+ *
+ * Runs smart-indexer commands against a repository indexed for the one
+ * query, and against a daemon serving it.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { CliIo, runCli } from './cli.js';
+
+interface Run {
+  status: number;
+  stdout: string;
+  stderr: string;
+}
+
+describe('CLI', () => {
+  let root: string;
+
+  function write(relativePath: string, content: string): void {
+    const filePath = path.join(root, relativePath);
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, content);
+  }
+
+  async function run(argv: string[], cwd: string = root, untilStopped?: Promise<void>, stdin = ''): Promise<Run> {
+    let stdout = '';
+    let stderr = '';
+    const io: CliIo = {
+      cwd,
+      stdout: text => { stdout += text; },
+      stderr: text => { stderr += text; },
+      readStdin: async () => stdin,
+      untilStopped: () => untilStopped ?? Promise.resolve()
+    };
+    const status = await runCli(argv, io);
+    return { status, stdout, stderr };
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-cli-'));
+    fs.mkdirSync(path.join(root, '.git'));
+    write('pkg/user/person.go', [
+      'package user',
+      '',
+      'type Person struct{ Name string }',
+      '',
+      'func (p *Person) Greet() string { return "hi " + p.Name }',
+      '',
+      'func NewPerson() *Person { return &Person{} }',
+      ''
+    ].join('\n'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should search, find references and outline files of the repository', async () => {
+    const cwd = path.join(root, 'pkg');
+    const search = await run(['search', 'Greet'], cwd);
+    expect(search).toEqual({ status: 0, stdout: 'user/person.go:5:18: method Person.Greet\n', stderr: '' });
+
+    const refs = await run(['refs', 'Person', '--json'], cwd);
+    expect(refs.status).toBe(0);
+    expect(JSON.parse(refs.stdout).name).toBe('Person');
+
+    const outline = await run(['outline', 'user/person.go'], cwd);
+    expect(outline.stdout.split('\n').filter(Boolean).map(line => line.split(': ')[1]))
+      .toEqual(['struct Person', 'field Person.Name', 'method Person.Greet', 'function NewPerson']);
+  });
+
+  it('should route queries to the daemon serving the repository', async () => {
+    let stop!: () => void;
+    const stopped = new Promise<void>(resolve => { stop = resolve; });
+    const daemon = run(['daemon', '--no-watch'], path.join(root, 'pkg'), stopped);
+    const socketPath = path.join(root, '.smart-index', 'daemon.sock');
+    for (let i = 0; i < 200 && !fs.existsSync(socketPath); i++) {
+      await new Promise(resolve => setTimeout(resolve, 25));
+    }
+
+    try {
+      // Not indexed by the daemon, which does not watch: only a fresh index has it
+      write('pkg/user/wave.go', 'package user\n\nfunc Wave() {}\n');
+      expect((await run(['search', 'Wave', '--kind', 'function'], path.join(root, 'pkg', 'user'))).stdout).toBe('');
+      expect((await run(['search', 'Wave', '--no-daemon'])).stdout).toBe('pkg/user/wave.go:3:6: function Wave\n');
+
+      // The daemon keeps a buffer indexed from stdin until it is cleared
+      await run(['index', '--stdin', '--path', 'pkg/user/wave.go'], root, undefined, 'package user\n\nfunc Wave() {}\n');
+      expect((await run(['search', 'Wave', '--kind', 'function'])).stdout).toBe('pkg/user/wave.go:3:6: function Wave\n');
+      expect((await run(['index', '--clear', '--path', 'pkg/user/wave.go'])).status).toBe(0);
+      expect((await run(['search', 'Wave', '--kind', 'function'])).stdout).toBe('');
+    } finally {
+      stop();
+    }
+    const served = await daemon;
+    expect(served.status).toBe(0);
+    expect(served.stderr).toContain(`Serving ${root} (1 files) on ${socketPath}`);
+    expect(fs.existsSync(socketPath)).toBe(false);
+  });
+
+  it('should run batches read from stdin and write one reply line per query', async () => {
+    const ndjson = await run(['batch'], root, undefined, '/definition?name=NewPerson\n{"id": "refs", "endpoint": "/references", "params": {"name": "Person"}}\n');
+    expect(ndjson.status).toBe(0);
+    const [definition, references] = ndjson.stdout.trim().split('\n').map(line => JSON.parse(line));
+    expect(definition).toMatchObject({ index: 0, status: 200 });
+    expect(definition.body.definitions[0].name).toBe('NewPerson');
+    expect(references).toMatchObject({ index: 1, id: 'refs', status: 200 });
+
+    // A failing query fails the run, after every reply is written
+    const array = await run(['batch', '--no-daemon'], root, undefined, '["/definition", "/definition?name=Person"]');
+    expect(array.status).toBe(1);
+    expect(array.stdout.trim().split('\n').map(line => JSON.parse(line).status)).toEqual([400, 200]);
+
+    // With a saved index, batches read it instead of indexing the repository again
+    expect((await run(['index'])).status).toBe(0);
+    write('pkg/user/wave.go', 'package user\n\nfunc Wave() {}\n');
+    const fromSaved = await run(['batch'], root, undefined, '/definition?name=NewPerson\n/definition?name=Wave\n');
+    const [found, unsaved] = fromSaved.stdout.trim().split('\n').map(line => JSON.parse(line));
+    expect(found.body.definitions).toHaveLength(1);
+    expect(unsaved.body.definitions).toEqual([]);
+    await run(['index']);
+    expect(JSON.parse((await run(['batch'], root, undefined, '/definition?name=Wave')).stdout).body.definitions).toHaveLength(1);
+
+    const unparsable = await run(['batch'], root, undefined, 'not a query');
+    expect(unparsable.status).toBe(1);
+    expect(unparsable.stderr).toContain('neither a path nor JSON');
+  });
+
+  it('should index a buffer from stdin in place of the file and outline it', async () => {
+    const buffer = 'package user\n\ntype Person struct{ Name string }\n\nfunc (p *Person) Wave() {}\n';
+    const cwd = path.join(root, 'pkg');
+    const indexed = await run(['index', '--stdin', '--path', 'user/person.go'], cwd, undefined, buffer);
+    expect(indexed.stdout).toBe([
+      'user/person.go:3:6: struct Person',
+      'user/person.go:3:21: field Person.Name',
+      'user/person.go:5:18: method Person.Wave',
+      ''
+    ].join('\n'));
+
+    // A new file need not exist, --json prints the nested outline
+    const created = await run(['index', '--stdin', '--path', 'pkg/user/wave.go', '--json'], root, undefined, 'package user\n\nfunc Wave() {}\n');
+    expect(JSON.parse(created.stdout)).toMatchObject({ shadowsIndexed: false, outline: [{ name: 'Wave' }] });
+
+    expect((await run(['index', '--path', 'pkg/user/person.go'])).status).toBe(2);
+    expect((await run(['index', '--stdin'])).status).toBe(2);
+    // Without a daemon there is no overlay to drop, and nothing is indexed to find out
+    expect(await run(['index', '--clear', '--path', 'pkg/user/person.go'])).toEqual({ status: 0, stdout: '', stderr: '' });
+  });
+
+  it('should lay a buffer over the saved index, or over nothing without one', async () => {
+    const buffer = 'package user\n\nfunc (p *Person) Wave() {}\n';
+    const bare = await run(['index', '--stdin', '--path', 'pkg/user/person.go', '--json'], root, undefined, buffer);
+    expect(JSON.parse(bare.stdout).shadowsIndexed).toBe(false);
+
+    expect((await run(['index'])).stderr).toBe(`Indexed 1 files into ${path.join(root, '.smart-index', 'index.idx')}\n`);
+    const saved = await run(['index', '--stdin', '--path', 'pkg/user/person.go', '--json'], root, undefined, buffer);
+    expect(JSON.parse(saved.stdout).shadowsIndexed).toBe(true);
+  });
+
+  it('should print the completion script of the commands', async () => {
+    const bash = await run(['completion', 'bash', '--command', 'si']);
+    expect(bash.status).toBe(0);
+    expect(bash.stdout).toContain('complete -F _si si');
+    expect(bash.stdout).toContain('search refs outline batch index daemon completion');
+    expect((await run(['completion', 'csh'])).status).toBe(2);
+  });
+
+  it('should report bad arguments with the usage and failed queries with the error', async () => {
+    expect((await run([])).stdout).toContain('Usage: smart-indexer <command>');
+    const unknown = await run(['frobnicate']);
+    expect(unknown.status).toBe(2);
+    expect(unknown.stderr).toContain('Unknown command: frobnicate');
+    expect((await run(['search'])).status).toBe(2);
+    expect((await run(['search', 'x', '--bogus'])).status).toBe(2);
+
+    const failed = await run(['search', 'Person', '--scope', 'nowhere']);
+    expect(failed.status).toBe(1);
+    expect(failed.stderr).toMatch(/^smart-indexer: .*nowhere/);
+  });
+});
@@ -0,0 +1,279 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseArgs } from 'util';
+import { createIndexer } from '../api/indexer.js';
+import { COMPLETION_SHELLS, CompletionShell, completionScript } from '../features/completions.js';
+import { connectDaemon, DaemonResponse, repositoryRoot } from '../features/daemon.js';
+import type { QueryResponse } from '../features/queryServer.js';
+
+/** What the CLI reads and writes: the process's own, or fakes in tests */
+export interface CliIo {
+  cwd: string;
+  stdout(text: string): void;
+  stderr(text: string): void;
+  /** All of standard input, for commands that take their input from it */
+  readStdin(): Promise<string>;
+  /** Resolves once the process is asked to stop (SIGINT, SIGTERM) */
+  untilStopped(): Promise<void>;
+}
+
+/** Where queries go: the daemon of the repository, or an index built for this run */
+interface QueryTarget {
+  request(method: string, url: string, body?: string): Promise<DaemonResponse>;
+}
+
+/** Where `smart-indexer index` saves the index of a repository, under its root */
+const SAVED_INDEX = path.join('.smart-index', 'index.idx');
+
+type Flags = ReturnType<typeof parseFlags>['values'];
+
+type Command = (args: string[], flags: Flags, io: CliIo) => Promise<number>;
+
+/** Bad arguments: reported with the usage, exit status 2 */
+class UsageError extends Error {}
+
+interface LocationJson {
+  uri: string;
+  line: number;
+  character: number;
+}
+
+interface SymbolJson {
+  name: string;
+  kind: string;
+  containerName?: string;
+  location: LocationJson;
+}
+
+const USAGE = [
+  'Usage: smart-indexer <command> [options]',
+  '',
+  '  search <query> [--kind k] [--scope s] [--limit n]  symbols matching a fuzzy query',
+  '  refs <name> [--limit n]                            references to a symbol',
+  '  outline <file>                                     symbols of a file',
+  '  batch                                              queries from stdin (NDJSON or a JSON array), one reply line each',
+  '  index [dir]                                        index dir (default: the repository) and save it for queries',
+  '  index --stdin --path <file> [--clear]              index stdin as the unsaved buffer of file, print its symbols',
+  '  daemon [dir] [--no-watch]                          index dir (default: the repository) and serve it',
+  '  completion <shell> [--command c] [--url u]         bash, zsh or fish completion script',
+  '',
+  'Queries go to the daemon serving the repository when one is running, else to the',
+  'index saved by index (run it again to refresh it), else the repository is indexed',
+  'for that one query. --no-daemon skips the daemon, --json prints the JSON of the',
+  'query server. A buffer indexed by a daemon shadows the file for later queries',
+  'until index --clear drops it.',
+  ''
+].join('\n');
+
+const COMMANDS: Record<string, Command> = {
+  search: async (args, flags, io) => {
+    const params = new URLSearchParams({ q: requireArg(args, 'query') });
+    for (const name of ['limit', 'kind', 'scope'] as const) {
+      if (flags[name] !== undefined) {
+        params.set(name, flags[name]!);
+      }
+    }
+    const body = await query(await openTarget(io, flags), 'GET', `/symbols?${params}`) as { symbols: SymbolJson[] };
+    return print(io, flags, body, body.symbols.map(symbol => `${where(io, symbol.location)}: ${symbol.kind} ${qualifiedName(symbol)}`));
+  },
+
+  refs: async (args, flags, io) => {
+    const params = new URLSearchParams({ name: requireArg(args, 'name') });
+    if (flags.limit !== undefined) {
+      params.set('limit', flags.limit);
+    }
+    const body = await query(await openTarget(io, flags), 'GET', `/references?${params}`) as { references: SymbolJson[] };
+    return print(io, flags, body, body.references.map(reference => `${where(io, reference.location)}: ${qualifiedName(reference)}`));
+  },
+
+  outline: async (args, flags, io) => {
+    const uri = path.resolve(io.cwd, requireArg(args, 'file'));
+    const body = await query(await openTarget(io, flags), 'GET', `/outline?${new URLSearchParams({ uri })}`) as { symbols: SymbolJson[] };
+    return print(io, flags, body, body.symbols.map(symbol => `${where(io, symbol.location)}: ${symbol.kind} ${qualifiedName(symbol)}`));
+  },
+
+  batch: async (_args, flags, io) => {
+    const replies = await query(await openTarget(io, flags), 'POST', '/batch', await io.readStdin()) as string;
+    io.stdout(replies);
+    // Failed queries have their own reply line; the exit status tells scripts one failed
+    const failed = replies.split('\n').some(line => line !== '' && (JSON.parse(line) as { status: number }).status !== 200);
+    return failed ? 1 : 0;
+  },
+
+  index: async (args, flags, io) => {
+    if (!flags.stdin && !flags.clear && flags.path === undefined) {
+      const root = path.resolve(io.cwd, args[0] ?? repositoryRoot(io.cwd) ?? '.');
+      const indexer = createIndexer({ configFile: true });
+      const indexed = await indexer.indexDir(root);
+      await indexer.save(path.join(root, SAVED_INDEX));
+      io.stderr(`Indexed ${indexed.files} files into ${path.join(root, SAVED_INDEX)}\n`);
+      return 0;
+    }
+    if (flags.path === undefined) {
+      throw new UsageError('Missing --path <file>');
+    }
+    const uri = path.resolve(io.cwd, flags.path);
+    if (!flags.stdin && !flags.clear) {
+      throw new UsageError('Missing --stdin: the buffer is read from stdin');
+    }
+    if (flags.clear) {
+      // Only a daemon keeps overlays from one run to the next
+      const daemon = flags['no-daemon'] ? undefined : await connectDaemon(io.cwd);
+      if (daemon) {
+        await query(daemon, 'DELETE', `/overlay?${new URLSearchParams({ uri })}`);
+      }
+      return 0;
+    }
+    // The saved index, if any, is all the buffer is laid over: nothing is indexed but the buffer
+    const target = await openTarget(io, flags, false);
+    const body = await query(target, 'POST', `/overlay?${new URLSearchParams({ uri })}`, await io.readStdin()) as { symbols: SymbolJson[] };
+    return print(io, flags, body, body.symbols.map(symbol => `${where(io, symbol.location)}: ${symbol.kind} ${qualifiedName(symbol)}`));
+  },
+
+  daemon: async (args, flags, io) => {
+    const root = path.resolve(io.cwd, args[0] ?? repositoryRoot(io.cwd) ?? '.');
+    const daemon = await createIndexer({ configFile: true }).startDaemon(root, { watch: !flags['no-watch'] });
+    io.stderr(`Serving ${daemon.root} (${daemon.indexed.files} files) on ${daemon.socketPath}\n`);
+    await io.untilStopped();
+    await daemon.stop();
+    return 0;
+  },
+
+  completion: async (args, flags, io) => {
+    const shell = requireArg(args, 'shell');
+    if (!(COMPLETION_SHELLS as readonly string[]).includes(shell)) {
+      throw new UsageError(`Unsupported shell: ${shell}`);
+    }
+    io.stdout(completionScript(shell as CompletionShell, { command: flags.command, url: flags.url }));
+    return 0;
+  }
+};
+
+function parseFlags(argv: string[]) {
+  return parseArgs({
+    args: argv,
+    allowPositionals: true,
+    options: {
+      json: { type: 'boolean' },
+      'no-daemon': { type: 'boolean' },
+      'no-watch': { type: 'boolean' },
+      stdin: { type: 'boolean' },
+      clear: { type: 'boolean' },
+      path: { type: 'string' },
+      limit: { type: 'string' },
+      kind: { type: 'string' },
+      scope: { type: 'string' },
+      command: { type: 'string' },
+      url: { type: 'string' },
+      help: { type: 'boolean', short: 'h' }
+    }
+  });
+}
+
+/**
+ * Run the `smart-indexer` command line: argv without the node and script
+ * paths. Returns the exit status: 0, 1 when the query failed, 2 on bad
+ * arguments.
+ */
+export async function runCli(argv: string[], io: CliIo): Promise<number> {
+  let parsed: ReturnType<typeof parseFlags>;
+  try {
+    parsed = parseFlags(argv);
+  } catch (error) {
+    io.stderr(`${errorMessage(error)}\n\n${USAGE}`);
+    return 2;
+  }
+  const [name, ...args] = parsed.positionals;
+  if (parsed.values.help || name === undefined || name === 'help') {
+    io.stdout(USAGE);
+    return 0;
+  }
+  const command = COMMANDS[name];
+  if (!command) {
+    io.stderr(`Unknown command: ${name}\n\n${USAGE}`);
+    return 2;
+  }
+  try {
+    return await command(args, parsed.values, io);
+  } catch (error) {
+    if (error instanceof UsageError) {
+      io.stderr(`${error.message}\n\n${USAGE}`);
+      return 2;
+    }
+    io.stderr(`smart-indexer: ${errorMessage(error)}\n`);
+    return 1;
+  }
+}
+
+/**
+ * Send a query server request (see features/queryServer.ts) and return
+ * the reply body; throws with the server's error unless it succeeded.
+ */
+async function query(target: QueryTarget, method: string, url: string, body?: string): Promise<unknown> {
+  const response = await target.request(method, url, body);
+  if (response.status !== 200) {
+    throw new Error((response.body as { error?: string } | undefined)?.error ?? `Query failed with status ${response.status}`);
+  }
+  return response.body ?? response.text;
+}
+
+/**
+ * The daemon of the repository, else the index `smart-indexer index`
+ * saved, else (when build is set) the repository indexed for this run.
+ */
+async function openTarget(io: CliIo, flags: Flags, build = true): Promise<QueryTarget> {
+  const daemon = flags['no-daemon'] ? undefined : await connectDaemon(io.cwd);
+  if (daemon) {
+    return daemon;
+  }
+  const root = repositoryRoot(io.cwd) ?? io.cwd;
+  const indexer = createIndexer({ configFile: true });
+  if (fs.existsSync(path.join(root, SAVED_INDEX))) {
+    await indexer.load(path.join(root, SAVED_INDEX));
+  } else if (build) {
+    await indexer.indexDir(root);
+  }
+  const server = indexer.queryServer();
+  return { request: async (method, url, body) => readResponse(await server.handle(method, url, body)) };
+}
+
+/** A reply of the query server as DaemonClient returns it */
+async function readResponse(response: QueryResponse): Promise<DaemonResponse> {
+  if (response.stream) {
+    let text = '';
+    for await (const item of response.stream) {
+      text += typeof item === 'string' ? item : JSON.stringify(item) + '\n';
+    }
+    return { status: response.status, text };
+  }
+  if (response.text !== undefined) {
+    return { status: response.status, text: response.text };
+  }
+  return { status: response.status, body: response.body };
+}
+
+function print(io: CliIo, flags: Flags, body: unknown, lines: string[]): number {
+  io.stdout(flags.json ? JSON.stringify(body, null, 2) + '\n' : lines.map(line => line + '\n').join(''));
+  return 0;
+}
+
+function requireArg(args: string[], name: string): string {
+  if (args[0] === undefined) {
+    throw new UsageError(`Missing <${name}>`);
+  }
+  return args[0];
+}
+
+/** `path:line:column`, 1-based like compilers print them, relative to the working directory */
+function where(io: CliIo, location: LocationJson): string {
+  const file = path.isAbsolute(location.uri) ? path.relative(io.cwd, location.uri) || location.uri : location.uri;
+  return `${file}:${location.line + 1}:${location.character + 1}`;
+}
+
+function qualifiedName(symbol: SymbolJson): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+function errorMessage(error: unknown): string {
+  return error instanceof Error ? error.message : String(error);
+}
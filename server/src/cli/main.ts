@@ -0,0 +1,31 @@
+#!/usr/bin/env node
+import { runCli } from './cli.js';
+
+/**
+ * Entry point of the `smart-indexer` command (see cli.ts). Exits once the
+ * output is flushed, as an index built for one query may leave workers
+ * running.
+ */
+runCli(process.argv.slice(2), {
+  cwd: process.cwd(),
+  stdout: text => process.stdout.write(text),
+  stderr: text => process.stderr.write(text),
+  readStdin: async () => {
+    let text = '';
+    process.stdin.setEncoding('utf-8');
+    for await (const chunk of process.stdin) {
+      text += chunk;
+    }
+    return text;
+  },
+  untilStopped: () => new Promise(resolve => {
+    process.once('SIGINT', () => resolve());
+    process.once('SIGTERM', () => resolve());
+  })
+}).then(
+  status => process.stdout.write('', () => process.exit(status)),
+  error => {
+    process.stderr.write(`smart-indexer: ${error instanceof Error ? error.stack : error}\n`);
+    process.exit(1);
+  }
+);
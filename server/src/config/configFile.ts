@@ -0,0 +1,266 @@
+import * as path from 'path';
+import type { ISmartIndexerSettings } from './configurationManager.js';
+import { ConfigObject, ConfigSyntaxError, ConfigValue, parseToml, parseYaml } from './configFormats.js';
+import { readFileIfExists, ReadFileSyncFn } from '../utils/ignoreRules.js';
+
+/*
+ * Workspace config file, checked into the repository next to the code:
+ *
+ *   # smart-indexer.yaml
+ *   includePatterns: ["cmd/**", "pkg/**"]
+ *   excludePatterns: ["**\/testdata/**"]
+ *   languages: [go]
+ *   profile: local
+ *   profiles:
+ *     ci:
+ *       indexingTimeoutMinutes: 30
+ *     local:
+ *       textIndexingEnabled: true
+ *
+ * Keys are the server settings (ISmartIndexerSettings). A profile is laid
+ * over the top-level settings, and settings given explicitly (editor
+ * settings, library options) over both. The profile comes from the
+ * `smartIndexer.profile` setting or library option, else from
+ * SMART_INDEXER_PROFILE, else from the file's `profile`.
+ *
+ * The file comes with the repository, so it is data: settings that run
+ * programs, open the network or write outside the workspace
+ * (TRUSTED_SETTING_KEYS) are ignored in it unless the user trusts it
+ * (`smartIndexer.trustConfigFile`, the `trustConfigFile` library option).
+ */
+
+/** Looked up at the workspace root, in this order */
+export const CONFIG_FILE_NAMES = ['smart-indexer.yaml', 'smart-indexer.yml', 'smart-indexer.toml'];
+
+/** Environment variable selecting the profile when no setting does, e.g. in CI */
+export const PROFILE_ENV_VAR = 'SMART_INDEXER_PROFILE';
+
+/**
+ * An unreadable or invalid config file; `line` is one-based when known.
+ */
+export class ConfigFileError extends Error {
+  constructor(message: string, readonly filePath: string, readonly line?: number) {
+    super(`${filePath}${line !== undefined ? `:${line}` : ''}: ${message}`);
+    this.name = 'ConfigFileError';
+  }
+}
+
+export interface ConfigFile {
+  /** Absolute path of the file */
+  path: string;
+  /** Settings outside any profile */
+  settings: ISmartIndexerSettings;
+  profiles: Record<string, ISmartIndexerSettings>;
+  /** The file's own `profile`, used when none is selected */
+  defaultProfile?: string;
+}
+
+/** Settings a config file or profile may set */
+const SETTING_KEYS: ReadonlyArray<keyof ISmartIndexerSettings> = [
+  'cacheDirectory',
+  'enableGitIntegration',
+  'excludePatterns',
+  'includePatterns',
+  'languages',
+  'maxIndexedFileSize',
+  'maxFileSizeMB',
+  'maxCacheSizeMB',
+  'maxConcurrentIndexJobs',
+  'enableBackgroundIndex',
+  'textIndexingEnabled',
+  'staticIndexEnabled',
+  'staticIndexPath',
+  'staticIndexCompression',
+  'logLevel',
+  'logFormat',
+  'maxConcurrentWorkers',
+  'batchSize',
+  'useFolderHashing',
+  'indexingTimeoutMinutes',
+  'autoSaveDelay',
+  'deadCode',
+  'queryServer',
+  'extractors',
+  'goIncludeDependencies',
+  'goBuild',
+  'bazel',
+  'dependencyRules',
+  'policies',
+  'embeddings',
+  'summaries',
+  'ignore',
+  'search',
+  'remoteIndex',
+  'commentMarkers',
+  'httpRoutes',
+  'coverage',
+  'throttle',
+  'encryption'
+];
+
+/**
+ * Settings a config file sets only when trusted: extractors and key or
+ * summary commands run programs, embeddings, summaries and remote indexes
+ * send code or tokens to a server, the query server listens on the
+ * network, and the cache directory is written to.
+ */
+export const TRUSTED_SETTING_KEYS: ReadonlyArray<keyof ISmartIndexerSettings> = [
+  'cacheDirectory',
+  'queryServer',
+  'extractors',
+  'embeddings',
+  'summaries',
+  'remoteIndex',
+  'encryption'
+];
+
+/**
+ * Parse config file content; the format follows the extension (`.toml`,
+ * else YAML).
+ */
+export function parseConfigFile(content: string, filePath: string): ConfigFile {
+  let document: ConfigValue;
+  try {
+    document = path.extname(filePath).toLowerCase() === '.toml' ? parseToml(content) : parseYaml(content);
+  } catch (error) {
+    if (error instanceof ConfigSyntaxError) {
+      throw new ConfigFileError(error.reason, filePath, error.line);
+    }
+    throw error;
+  }
+  if (!isObject(document)) {
+    throw new ConfigFileError('Expected a mapping of settings', filePath);
+  }
+
+  const { profile, profiles = {}, ...settings } = document;
+  if (profile !== undefined && typeof profile !== 'string') {
+    throw new ConfigFileError('"profile" must be a string', filePath);
+  }
+  if (!isObject(profiles)) {
+    throw new ConfigFileError('"profiles" must map profile names to settings', filePath);
+  }
+
+  const parsedProfiles: Record<string, ISmartIndexerSettings> = {};
+  for (const [name, value] of Object.entries(profiles)) {
+    if (!isObject(value)) {
+      throw new ConfigFileError(`Profile "${name}" must be a mapping of settings`, filePath);
+    }
+    parsedProfiles[name] = checkSettings(value, filePath, `profile "${name}"`);
+  }
+  if (profile !== undefined && !parsedProfiles[profile]) {
+    throw new ConfigFileError(`Default profile "${profile}" is not defined`, filePath);
+  }
+
+  return {
+    path: filePath,
+    settings: checkSettings(settings, filePath),
+    profiles: parsedProfiles,
+    defaultProfile: profile
+  };
+}
+
+/**
+ * The config file at the workspace root, the first of CONFIG_FILE_NAMES
+ * that exists; null without one.
+ */
+export function findConfigFile(workspaceRoot: string, readFile: ReadFileSyncFn = readFileIfExists): ConfigFile | null {
+  for (const name of CONFIG_FILE_NAMES) {
+    const filePath = path.join(workspaceRoot, name);
+    const content = readFile(filePath);
+    if (content !== null) {
+      return parseConfigFile(content, filePath);
+    }
+  }
+  return null;
+}
+
+/**
+ * A config file at a given path, e.g. from a library option.
+ */
+export function readConfigFile(filePath: string, readFile: ReadFileSyncFn = readFileIfExists): ConfigFile {
+  const resolved = path.resolve(filePath);
+  const content = readFile(resolved);
+  if (content === null) {
+    throw new ConfigFileError('File not found', resolved);
+  }
+  return parseConfigFile(content, resolved);
+}
+
+/**
+ * The file's settings with a profile laid over them; without a profile,
+ * the top-level settings.
+ */
+export function resolveProfile(file: ConfigFile, profile: string | undefined): ISmartIndexerSettings {
+  if (!profile) {
+    return file.settings;
+  }
+  const overrides = file.profiles[profile];
+  if (!overrides) {
+    const known = Object.keys(file.profiles);
+    throw new ConfigFileError(
+      `Unknown profile "${profile}" (${known.length > 0 ? `defined: ${known.join(', ')}` : 'none defined'})`,
+      file.path
+    );
+  }
+  return mergeSettings(file.settings, overrides);
+}
+
+/**
+ * The file without the settings of TRUSTED_SETTING_KEYS, at the top level
+ * and in every profile, and the settings removed (`profile "ci":
+ * extractors` for one of a profile).
+ */
+export function untrustedConfigFile(file: ConfigFile): { file: ConfigFile; ignored: string[] } {
+  const ignored: string[] = [];
+  const strip = (settings: ISmartIndexerSettings, where?: string): ISmartIndexerSettings => {
+    const kept: Record<string, unknown> = {};
+    for (const [key, value] of Object.entries(settings)) {
+      if ((TRUSTED_SETTING_KEYS as readonly string[]).includes(key)) {
+        ignored.push(where ? `${where}: ${key}` : key);
+      } else {
+        kept[key] = value;
+      }
+    }
+    return kept as ISmartIndexerSettings;
+  };
+
+  const settings = strip(file.settings);
+  const profiles: Record<string, ISmartIndexerSettings> = {};
+  for (const [name, overrides] of Object.entries(file.profiles)) {
+    profiles[name] = strip(overrides, `profile "${name}"`);
+  }
+  return { file: { ...file, settings, profiles }, ignored };
+}
+
+/**
+ * Settings with overrides laid over them: nested objects merge, lists and
+ * values replace, undefined values are skipped.
+ */
+export function mergeSettings<T extends object>(base: T, overrides: T): T {
+  const merged: Record<string, unknown> = { ...base };
+  for (const [key, value] of Object.entries(overrides)) {
+    if (value === undefined) {
+      continue;
+    }
+    const current = merged[key];
+    merged[key] = isPlainObject(value) && isPlainObject(current) ? mergeSettings(current, value) : value;
+  }
+  return merged as T;
+}
+
+function checkSettings(settings: ConfigObject, filePath: string, where: string = 'settings'): ISmartIndexerSettings {
+  for (const key of Object.keys(settings)) {
+    if (!(SETTING_KEYS as readonly string[]).includes(key)) {
+      throw new ConfigFileError(`Unknown setting "${key}" in ${where}`, filePath);
+    }
+  }
+  return settings as ISmartIndexerSettings;
+}
+
+function isObject(value: ConfigValue | undefined): value is ConfigObject {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
+
+function isPlainObject(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
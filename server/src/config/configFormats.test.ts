@@ -0,0 +1,130 @@
+/**
+ * Config Formats Tests
+ *
+ * Verifies the YAML and TOML subsets read from workspace config files.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ConfigSyntaxError, parseToml, parseYaml } from './configFormats.js';
+
+describe('parseYaml', () => {
+  it('should parse block and flow collections with typed scalars', () => {
+    const value = parseYaml([
+      '---',
+      '# Settings',
+      'cacheDirectory: .smart-index   # relative to the root',
+      'excludePatterns:',
+      '- "**/testdata/**"',
+      "- '**/*.pb.go'",
+      'queryServer: { enabled: true, port: 7717, host: "127.0.0.1" }',
+      'languages: [go, ts]',
+      'extractors:',
+      '  - name: proto',
+      '    command: protoc-gen-index',
+      '    extensions: [.proto]',
+      '  - name: sql',
+      '    command: "sql # indexer"',
+      'deadCode:',
+      '  allowlist: []',
+      '  enabled: false',
+      'staticIndexPath: ~',
+      "note: 'it''s fine: really'",
+      'ratio: 0.5'
+    ].join('\n'));
+
+    expect(value).toEqual({
+      cacheDirectory: '.smart-index',
+      excludePatterns: ['**/testdata/**', '**/*.pb.go'],
+      queryServer: { enabled: true, port: 7717, host: '127.0.0.1' },
+      languages: ['go', 'ts'],
+      extractors: [
+        { name: 'proto', command: 'protoc-gen-index', extensions: ['.proto'] },
+        { name: 'sql', command: 'sql # indexer' }
+      ],
+      deadCode: { allowlist: [], enabled: false },
+      staticIndexPath: null,
+      note: 'it\'s fine: really',
+      ratio: 0.5
+    });
+    expect(parseYaml('# nothing\n')).toEqual({});
+  });
+
+  it('should report errors with their line', () => {
+    const errorOf = (content: string) => {
+      try {
+        parseYaml(content);
+      } catch (error) {
+        expect(error instanceof ConfigSyntaxError).toBe(true);
+        return error as ConfigSyntaxError;
+      }
+      throw new Error('no error');
+    };
+
+    expect(errorOf('a: 1\na: 2').message).toBe('Duplicate key "a" (line 2)');
+    expect(errorOf('a: 1\n   b: 2').line).toBe(2);
+    expect(errorOf('a:\n  just text').reason).toContain('Expected "key: value"');
+    expect(errorOf('a: [1, 2').reason).toContain('Expected "," or "]"');
+    expect(errorOf('a: |\n  text').reason).toContain('Unsupported YAML syntax');
+  });
+});
+
+describe('parseToml', () => {
+  it('should parse tables, arrays of tables and inline values', () => {
+    const value = parseToml([
+      '# Settings',
+      'cacheDirectory = ".smart-index"',
+      "staticIndexPath = 'C:\\index\\static.db'",
+      'maxCacheSizeMB = 1_000',
+      'languages = [',
+      '  "go",  # Go first',
+      '  "ts",',
+      ']',
+      'queryServer = { enabled = true, port = 7717 }',
+      '',
+      '[goBuild]',
+      'tags = ["integration"]',
+      'goos = "linux"',
+      '',
+      '[[extractors]]',
+      'name = "proto"',
+      'extensions = [".proto"]',
+      '',
+      '[[extractors]]',
+      'name = "sql"',
+      '',
+      '[profiles.ci]',
+      'embeddings.enabled = false',
+      '"indexingTimeoutMinutes" = 30'
+    ].join('\n'));
+
+    expect(value).toEqual({
+      cacheDirectory: '.smart-index',
+      staticIndexPath: 'C:\\index\\static.db',
+      maxCacheSizeMB: 1000,
+      languages: ['go', 'ts'],
+      queryServer: { enabled: true, port: 7717 },
+      goBuild: { tags: ['integration'], goos: 'linux' },
+      extractors: [{ name: 'proto', extensions: ['.proto'] }, { name: 'sql' }],
+      profiles: { ci: { embeddings: { enabled: false }, indexingTimeoutMinutes: 30 } }
+    });
+  });
+
+  it('should report errors with their line', () => {
+    const errorOf = (content: string) => {
+      try {
+        parseToml(content);
+      } catch (error) {
+        expect(error instanceof ConfigSyntaxError).toBe(true);
+        return error as ConfigSyntaxError;
+      }
+      throw new Error('no error');
+    };
+
+    expect(errorOf('a = 1\na = 2').message).toBe('Duplicate key "a" (line 2)');
+    expect(errorOf('[a]\nx = 1\n[a]').reason).toBe('Table "a" is defined twice');
+    expect(errorOf('a = "open').reason).toBe('Unterminated string');
+    expect(errorOf('a = 1979-05-27').reason).toBe('Dates are not supported');
+    expect(errorOf('a = 1 b = 2').line).toBe(1);
+    expect(errorOf('\n\nname "x"').line).toBe(3);
+  });
+});
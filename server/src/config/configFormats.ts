@@ -0,0 +1,539 @@
+/*
+ * Parsers for the formats of the workspace config file (see configFile.ts):
+ * the parts of YAML and TOML a settings file needs, without dependencies.
+ *
+ * YAML: block mappings and sequences by indentation, flow `[a, b]` and
+ * `{a: 1}` collections, plain, 'single' and "double" quoted scalars,
+ * booleans, null, numbers and `#` comments. Anchors, tags and block
+ * scalars (`|`, `>`) are rejected.
+ *
+ * TOML: `key = value` with dotted and quoted keys, `[tables]`, `[[arrays
+ * of tables]]`, basic and literal strings, integers, floats, booleans,
+ * arrays (across lines) and inline tables. Dates and multi-line strings
+ * are rejected.
+ */
+
+export type ConfigValue = string | number | boolean | null | ConfigValue[] | ConfigObject;
+
+export interface ConfigObject {
+  [key: string]: ConfigValue;
+}
+
+/**
+ * A syntax error, on a one-based line.
+ */
+export class ConfigSyntaxError extends Error {
+  constructor(readonly reason: string, readonly line: number) {
+    super(`${reason} (line ${line})`);
+    this.name = 'ConfigSyntaxError';
+  }
+}
+
+// ============================================================================
+// YAML
+// ============================================================================
+
+interface YamlLine {
+  indent: number;
+  text: string;
+  line: number;
+}
+
+/**
+ * Parse a YAML document; an empty document is an empty mapping.
+ */
+export function parseYaml(content: string): ConfigValue {
+  const lines: YamlLine[] = [];
+  content.split(/\r?\n/).forEach((raw, i) => {
+    const text = stripYamlComment(raw).trimEnd();
+    if (!text.trim() || (lines.length === 0 && text === '---')) {
+      return;
+    }
+    if (/^\s*\t/.test(text)) {
+      throw new ConfigSyntaxError('Tabs are not allowed for indentation', i + 1);
+    }
+    const indent = text.length - text.trimStart().length;
+    lines.push({ indent, text: text.trimStart(), line: i + 1 });
+  });
+  if (lines.length === 0) {
+    return {};
+  }
+
+  let next = 0;
+
+  const parseBlock = (indent: number): ConfigValue => {
+    return isSequenceItem(lines[next].text) ? parseSequence(indent) : parseMapping(indent);
+  };
+
+  const parseMapping = (indent: number): ConfigObject => {
+    const mapping: ConfigObject = {};
+    while (next < lines.length && lines[next].indent === indent && !isSequenceItem(lines[next].text)) {
+      const { text, line } = lines[next];
+      const split = splitYamlKey(text, line);
+      if (!split) {
+        throw new ConfigSyntaxError(`Expected "key: value", got "${text}"`, line);
+      }
+      const [key, rest] = split;
+      if (Object.prototype.hasOwnProperty.call(mapping, key)) {
+        throw new ConfigSyntaxError(`Duplicate key "${key}"`, line);
+      }
+      next++;
+      mapping[key] = rest ? parseYamlInline(rest, line) : parseNested(indent, true);
+    }
+    if (next < lines.length && lines[next].indent > indent) {
+      throw new ConfigSyntaxError('Unexpected indentation', lines[next].line);
+    }
+    return mapping;
+  };
+
+  const parseSequence = (indent: number): ConfigValue[] => {
+    const items: ConfigValue[] = [];
+    while (next < lines.length && lines[next].indent === indent && isSequenceItem(lines[next].text)) {
+      const { text, line } = lines[next];
+      const rest = text.slice(1).trimStart();
+      if (!rest) {
+        next++;
+        items.push(parseNested(indent, false));
+      } else if (splitYamlKey(rest, line) && !/^[[{"']/.test(rest)) {
+        // `- key: value` starts a mapping indented to the key
+        lines[next] = { indent: indent + text.length - rest.length, text: rest, line };
+        items.push(parseMapping(lines[next].indent));
+      } else {
+        next++;
+        items.push(parseYamlInline(rest, line));
+      }
+    }
+    if (next < lines.length && lines[next].indent > indent) {
+      throw new ConfigSyntaxError('Unexpected indentation', lines[next].line);
+    }
+    return items;
+  };
+
+  /** Value of a `key:` or `-` with nothing after it: the block below, or null */
+  const parseNested = (indent: number, allowSameIndentSequence: boolean): ConfigValue => {
+    const line = lines[next];
+    if (line && line.indent > indent) {
+      return parseBlock(line.indent);
+    }
+    // `key:` followed by `- item` at the key's own indentation
+    if (line && allowSameIndentSequence && line.indent === indent && isSequenceItem(line.text)) {
+      return parseSequence(indent);
+    }
+    return null;
+  };
+
+  const value = parseBlock(lines[0].indent);
+  if (next < lines.length) {
+    throw new ConfigSyntaxError('Unexpected content', lines[next].line);
+  }
+  return value;
+}
+
+function isSequenceItem(text: string): boolean {
+  return text === '-' || text.startsWith('- ');
+}
+
+/** Line without its comment; `#` starts one at the line start or after whitespace, outside quotes */
+function stripYamlComment(raw: string): string {
+  let quote: string | null = null;
+  for (let i = 0; i < raw.length; i++) {
+    const char = raw[i];
+    if (quote) {
+      if (quote === '"' && char === '\\') {
+        i++;
+      } else if (char === quote) {
+        quote = null;
+      }
+    } else if (char === '"' || char === '\'') {
+      quote = char;
+    } else if (char === '#' && (i === 0 || /\s/.test(raw[i - 1]))) {
+      return raw.slice(0, i);
+    }
+  }
+  return raw;
+}
+
+/** `key: rest` split at the first `: ` (or final `:`) outside quotes and brackets */
+function splitYamlKey(text: string, line: number): [string, string] | null {
+  let quote: string | null = null;
+  let depth = 0;
+  for (let i = 0; i < text.length; i++) {
+    const char = text[i];
+    if (quote) {
+      if (quote === '"' && char === '\\') {
+        i++;
+      } else if (char === quote) {
+        quote = null;
+      }
+    } else if (char === '"' || char === '\'') {
+      quote = char;
+    } else if (char === '[' || char === '{') {
+      depth++;
+    } else if (char === ']' || char === '}') {
+      depth--;
+    } else if (char === ':' && depth === 0 && (i + 1 === text.length || /\s/.test(text[i + 1]))) {
+      const rawKey = text.slice(0, i).trim();
+      const key = /^["']/.test(rawKey) ? parseYamlScalar(rawKey, line) : rawKey;
+      return [String(key), text.slice(i + 1).trim()];
+    }
+  }
+  return null;
+}
+
+/** A value on one line: a flow collection or a scalar */
+function parseYamlInline(text: string, line: number): ConfigValue {
+  if (/^[&*!|>]/.test(text)) {
+    throw new ConfigSyntaxError(`Unsupported YAML syntax "${text[0]}"`, line);
+  }
+  if (text[0] !== '[' && text[0] !== '{') {
+    return parseYamlScalar(text, line);
+  }
+
+  let i = 0;
+  const skipSpace = () => {
+    while (i < text.length && /\s/.test(text[i])) {
+      i++;
+    }
+  };
+  const parseScalarUntil = (stops: string): ConfigValue => {
+    skipSpace();
+    const start = i;
+    if (text[i] === '"' || text[i] === '\'') {
+      const quote = text[i++];
+      for (;;) {
+        while (i < text.length && text[i] !== quote) {
+          i += quote === '"' && text[i] === '\\' ? 2 : 1;
+        }
+        // '' is an escaped quote in single-quoted scalars
+        if (quote === '\'' && text[i + 1] === '\'') {
+          i += 2;
+          continue;
+        }
+        break;
+      }
+      i++;
+    } else {
+      while (i < text.length && !stops.includes(text[i]) && !(text[i] === ':' && /[\s,\]}]/.test(text[i + 1] ?? ' '))) {
+        i++;
+      }
+    }
+    return parseYamlScalar(text.slice(start, i).trim(), line);
+  };
+  const parseFlow = (): ConfigValue => {
+    skipSpace();
+    const open = text[i];
+    if (open !== '[' && open !== '{') {
+      return parseScalarUntil(',]}');
+    }
+    i++;
+    const close = open === '[' ? ']' : '}';
+    const list: ConfigValue[] = [];
+    const mapping: ConfigObject = {};
+    for (;;) {
+      skipSpace();
+      if (text[i] === close) {
+        i++;
+        return open === '[' ? list : mapping;
+      }
+      if (open === '[') {
+        list.push(parseFlow());
+      } else {
+        const key = parseScalarUntil(',}');
+        skipSpace();
+        if (text[i] !== ':') {
+          throw new ConfigSyntaxError(`Expected ":" after "${key}"`, line);
+        }
+        i++;
+        mapping[String(key)] = parseFlow();
+      }
+      skipSpace();
+      if (text[i] === ',') {
+        i++;
+      } else if (text[i] !== close) {
+        throw new ConfigSyntaxError(`Expected "," or "${close}"`, line);
+      }
+    }
+  };
+
+  const value = parseFlow();
+  skipSpace();
+  if (i < text.length) {
+    throw new ConfigSyntaxError(`Unexpected "${text.slice(i)}"`, line);
+  }
+  return value;
+}
+
+function parseYamlScalar(text: string, line: number): ConfigValue {
+  if (text.startsWith('"')) {
+    if (text.length < 2 || !text.endsWith('"')) {
+      throw new ConfigSyntaxError('Unterminated string', line);
+    }
+    try {
+      return JSON.parse(text);
+    } catch {
+      throw new ConfigSyntaxError(`Invalid string ${text}`, line);
+    }
+  }
+  if (text.startsWith('\'')) {
+    if (text.length < 2 || !text.endsWith('\'')) {
+      throw new ConfigSyntaxError('Unterminated string', line);
+    }
+    return text.slice(1, -1).replace(/''/g, '\'');
+  }
+  if (text === '' || text === '~' || text === 'null' || text === 'Null' || text === 'NULL') {
+    return null;
+  }
+  if (/^(true|True|TRUE)$/.test(text)) {
+    return true;
+  }
+  if (/^(false|False|FALSE)$/.test(text)) {
+    return false;
+  }
+  if (/^[-+]?(\d+(\.\d*)?|\.\d+)([eE][-+]?\d+)?$/.test(text)) {
+    return Number(text);
+  }
+  return text;
+}
+
+// ============================================================================
+// TOML
+// ============================================================================
+
+/**
+ * Parse a TOML document into nested objects.
+ */
+export function parseToml(content: string): ConfigObject {
+  const root: ConfigObject = {};
+  /** Tables defined by a header or a key, which may not be redefined */
+  const defined = new Set<ConfigObject>();
+  let table = root;
+  let i = 0;
+
+  const lineAt = (offset: number) => content.slice(0, offset).split('\n').length;
+  const fail = (message: string): never => {
+    throw new ConfigSyntaxError(message, lineAt(i));
+  };
+  const skipSpace = () => {
+    while (content[i] === ' ' || content[i] === '\t') {
+      i++;
+    }
+  };
+  const skipComment = () => {
+    if (content[i] === '#') {
+      while (i < content.length && content[i] !== '\n') {
+        i++;
+      }
+    }
+  };
+  /** Whitespace, newlines and comments, as allowed inside arrays */
+  const skipBlank = () => {
+    for (;;) {
+      skipSpace();
+      skipComment();
+      if (content[i] === '\n' || content[i] === '\r') {
+        i++;
+      } else {
+        return;
+      }
+    }
+  };
+  const expectLineEnd = () => {
+    skipSpace();
+    skipComment();
+    if (content[i] === '\r') {
+      i++;
+    }
+    if (i < content.length && content[i] !== '\n') {
+      fail(`Unexpected "${content.slice(i).split('\n')[0]}"`);
+    }
+  };
+
+  const parseKey = (): string[] => {
+    const path: string[] = [];
+    for (;;) {
+      skipSpace();
+      if (content[i] === '"' || content[i] === '\'') {
+        path.push(parseString());
+      } else {
+        const match = /^[A-Za-z0-9_-]+/.exec(content.slice(i));
+        if (!match) {
+          fail('Expected a key');
+        }
+        path.push(match![0]);
+        i += match![0].length;
+      }
+      skipSpace();
+      if (content[i] !== '.') {
+        return path;
+      }
+      i++;
+    }
+  };
+
+  const parseString = (): string => {
+    const quote = content[i];
+    if (content.startsWith(quote.repeat(3), i)) {
+      fail('Multi-line strings are not supported');
+    }
+    const start = i++;
+    while (i < content.length && content[i] !== quote && content[i] !== '\n') {
+      i += quote === '"' && content[i] === '\\' ? 2 : 1;
+    }
+    if (content[i] !== quote) {
+      fail('Unterminated string');
+    }
+    i++;
+    if (quote === '\'') {
+      return content.slice(start + 1, i - 1);
+    }
+    try {
+      return JSON.parse(content.slice(start, i));
+    } catch {
+      return fail('Invalid string escape');
+    }
+  };
+
+  const parseValue = (): ConfigValue => {
+    skipSpace();
+    const char = content[i];
+    if (char === '"' || char === '\'') {
+      return parseString();
+    }
+    if (char === '[') {
+      i++;
+      const items: ConfigValue[] = [];
+      for (;;) {
+        skipBlank();
+        if (content[i] === ']') {
+          i++;
+          return items;
+        }
+        items.push(parseValue());
+        skipBlank();
+        if (content[i] === ',') {
+          i++;
+        } else if (content[i] !== ']') {
+          fail('Expected "," or "]"');
+        }
+      }
+    }
+    if (char === '{') {
+      i++;
+      const inline: ConfigObject = {};
+      skipSpace();
+      if (content[i] === '}') {
+        i++;
+        return inline;
+      }
+      for (;;) {
+        const key = parseKey();
+        if (content[i] !== '=') {
+          fail('Expected "="');
+        }
+        i++;
+        setKey(inline, key, parseValue());
+        skipSpace();
+        if (content[i] === '}') {
+          i++;
+          return inline;
+        }
+        if (content[i] !== ',') {
+          fail('Expected "," or "}"');
+        }
+        i++;
+      }
+    }
+    const match = /^[^\s,\]}#]+/.exec(content.slice(i));
+    if (!match) {
+      return fail('Expected a value');
+    }
+    const word = match[0];
+    i += word.length;
+    if (word === 'true' || word === 'false') {
+      return word === 'true';
+    }
+    if (/^[-+]?(0|[1-9](_?\d)*)(\.\d(_?\d)*)?([eE][-+]?\d(_?\d)*)?$/.test(word)) {
+      return Number(word.replace(/_/g, ''));
+    }
+    if (/^0x[0-9A-Fa-f_]+$/.test(word)) {
+      return parseInt(word.slice(2).replace(/_/g, ''), 16);
+    }
+    if (/^\d{4}-\d{2}-\d{2}/.test(word)) {
+      return fail('Dates are not supported');
+    }
+    return fail(`Invalid value "${word}"`);
+  };
+
+  const setKey = (target: ConfigObject, key: string[], value: ConfigValue) => {
+    const parent = descend(target, key.slice(0, -1));
+    const last = key[key.length - 1];
+    if (Object.prototype.hasOwnProperty.call(parent, last)) {
+      fail(`Duplicate key "${key.join('.')}"`);
+    }
+    parent[last] = value;
+  };
+
+  /** The table at path below target, created as needed; the last array of tables for `[[...]]` paths */
+  const descend = (target: ConfigObject, path: string[]): ConfigObject => {
+    let current = target;
+    for (const part of path) {
+      let child = current[part];
+      if (child === undefined) {
+        child = {};
+        current[part] = child;
+      }
+      if (Array.isArray(child)) {
+        child = child[child.length - 1];
+      }
+      if (child === null || typeof child !== 'object' || Array.isArray(child)) {
+        fail(`Key "${part}" is not a table`);
+      }
+      current = child as ConfigObject;
+    }
+    return current;
+  };
+
+  for (;;) {
+    skipBlank();
+    if (i >= content.length) {
+      return root;
+    }
+    if (content.startsWith('[[', i)) {
+      i += 2;
+      const key = parseKey();
+      if (!content.startsWith(']]', i)) {
+        fail('Expected "]]"');
+      }
+      i += 2;
+      const parent = descend(root, key.slice(0, -1));
+      const last = key[key.length - 1];
+      const existing = parent[last] ?? [];
+      if (!Array.isArray(existing)) {
+        fail(`Key "${key.join('.')}" is not an array of tables`);
+      }
+      table = {};
+      (existing as ConfigValue[]).push(table);
+      parent[last] = existing;
+    } else if (content[i] === '[') {
+      i++;
+      const key = parseKey();
+      if (content[i] !== ']') {
+        fail('Expected "]"');
+      }
+      i++;
+      table = descend(root, key);
+      if (defined.has(table)) {
+        fail(`Table "${key.join('.')}" is defined twice`);
+      }
+    } else {
+      const key = parseKey();
+      if (content[i] !== '=') {
+        fail('Expected "="');
+      }
+      i++;
+      setKey(table, key, parseValue());
+    }
+    defined.add(table);
+    expectLineEnd();
+  }
+}
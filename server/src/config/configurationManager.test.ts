@@ -0,0 +1,183 @@
+/**
+ * ConfigurationManager Tests
+ *
+ * Verifies the layering of defaults, the workspace config file with its
+ * profiles and explicit settings, the include / language filters (per
+ * root in multi-root workspaces) and the validation of throttle settings.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as path from 'path';
+import { ConfigurationManager } from './configurationManager.js';
+import { ConfigFileError, findConfigFile, parseConfigFile, PROFILE_ENV_VAR } from './configFile.js';
+
+const root = path.join(path.sep, 'ws');
+
+const yaml = [
+  'includePatterns: ["pkg/**", "cmd/**"]',
+  'maxConcurrentWorkers: 8',
+  'queryServer:',
+  '  enabled: true',
+  '  port: 8000',
+  'profile: local',
+  'profiles:',
+  '  ci:',
+  '    maxConcurrentWorkers: 2',
+  '    queryServer: { enabled: false }',
+  '  local:',
+  '    textIndexingEnabled: true'
+].join('\n');
+
+describe('ConfigurationManager', () => {
+  let manager: ConfigurationManager;
+  let savedProfile: string | undefined;
+
+  beforeEach(() => {
+    manager = new ConfigurationManager();
+    savedProfile = process.env[PROFILE_ENV_VAR];
+    delete process.env[PROFILE_ENV_VAR];
+  });
+
+  afterEach(() => {
+    if (savedProfile === undefined) {
+      delete process.env[PROFILE_ENV_VAR];
+    } else {
+      process.env[PROFILE_ENV_VAR] = savedProfile;
+    }
+  });
+
+  it('should lay the file and its default profile under explicit settings', () => {
+    manager.updateFromInitializationOptions({ queryServer: { port: 9000 } });
+    const loaded = manager.setConfigFile(parseConfigFile(yaml, path.join(root, 'smart-indexer.yaml')), undefined, true);
+
+    expect(loaded).toEqual({ path: path.join(root, 'smart-indexer.yaml'), profile: 'local', ignoredSettings: [] });
+    const config = manager.getConfig();
+    expect(config.maxConcurrentWorkers).toBe(8);
+    expect(config.textIndexingEnabled).toBe(true);
+    expect(manager.getQueryServerConfig()).toEqual({ enabled: true, port: 9000, host: '127.0.0.1', accessRules: [] });
+    expect(config.batchSize).toBe(50);
+
+    manager.updateFromSettings({ maxConcurrentWorkers: 3 });
+    expect(manager.getConfig().maxConcurrentWorkers).toBe(3);
+    expect(manager.getQueryServerConfig().port).toBe(9000);
+  });
+
+  it('should select the profile by argument, setting or environment', () => {
+    const file = parseConfigFile(yaml, path.join(root, 'smart-indexer.yaml'));
+
+    process.env[PROFILE_ENV_VAR] = 'ci';
+    manager.setConfigFile(file, undefined, true);
+    expect(manager.getConfig().maxConcurrentWorkers).toBe(2);
+    expect(manager.getQueryServerConfig()).toEqual({ enabled: false, port: 8000, host: '127.0.0.1', accessRules: [] });
+    expect(manager.getConfig().textIndexingEnabled).toBe(false);
+
+    manager.updateFromSettings({ profile: 'local' });
+    expect(manager.setConfigFile(file)?.profile).toBe('local');
+    expect(manager.setConfigFile(file, 'ci')?.profile).toBe('ci');
+    expect(() => manager.setConfigFile(file, 'nightly')).toThrow('Unknown profile "nightly" (defined: ci, local)');
+
+    manager.setConfigFile(null);
+    expect(manager.getConfig().maxConcurrentWorkers).toBe(4);
+    expect(manager.getConfigFile()).toBeNull();
+  });
+
+  it('should ignore the settings of an untrusted file that run programs or open the network', () => {
+    const file = parseConfigFile([
+      'languages: [go]',
+      'extractors: [{ name: proto, command: ./extract.sh, extensions: [.proto] }]',
+      'queryServer: { enabled: true, host: 0.0.0.0 }',
+      'encryption: { enabled: true, command: "curl https://keys.example" }',
+      'search: { scopes: { backend: "file:services/**" } }',
+      'profiles:',
+      '  ci:',
+      '    remoteIndex: { url: "http://cache.example/index" }',
+      '    batchSize: 10'
+    ].join('\n'), path.join(root, 'smart-indexer.yaml'));
+
+    expect(manager.setConfigFile(file, 'ci')?.ignoredSettings).toEqual([
+      'extractors', 'queryServer', 'encryption', 'profile "ci": remoteIndex'
+    ]);
+    expect(manager.getConfig().languages).toEqual(['go']);
+    expect(manager.getConfig().batchSize).toBe(10);
+    expect(manager.getSearchConfig().scopes).toEqual({ backend: 'file:services/**' });
+    expect(manager.getConfig().extractors).toEqual([]);
+    expect(manager.getQueryServerConfig().enabled).toBe(false);
+    expect(manager.getEncryptionConfig().enabled).toBe(false);
+    expect(manager.getRemoteIndexConfig().url).toBe('');
+
+    // Settings of the user still apply, and a trusted file sets them all
+    manager.updateFromSettings({ queryServer: { enabled: true } });
+    expect(manager.getQueryServerConfig()).toMatchObject({ enabled: true, host: '127.0.0.1' });
+    expect(manager.setConfigFile(file, 'ci', true)?.ignoredSettings).toEqual([]);
+    expect(manager.getConfig().extractors.map(e => e.name)).toEqual(['proto']);
+    expect(manager.getQueryServerConfig().host).toBe('0.0.0.0');
+  });
+
+  it('should only include files matching the include patterns and languages', () => {
+    manager.setWorkspaceRoot(root);
+    manager.setConfigFile(parseConfigFile(yaml, path.join(root, 'smart-indexer.yaml')));
+    manager.updateFromSettings({ languages: ['golang'] });
+
+    expect(manager.shouldExcludePath(path.join(root, 'pkg', 'user', 'user.go'))).toBe(false);
+    expect(manager.shouldExcludePath(path.join(root, 'pkg', 'web', 'app.ts'))).toBe(true);
+    expect(manager.shouldExcludePath(path.join(root, 'tools', 'gen.go'))).toBe(true);
+    expect(manager.shouldExcludePath(path.join(root, 'tools'), true)).toBe(false);
+    // Outside the workspace, e.g. the Go module cache
+    expect(manager.shouldExcludePath(path.join(path.sep, 'gopath', 'pkg', 'mod', 'dep.go'))).toBe(false);
+  });
+
+  it('should apply include patterns relative to each workspace root', () => {
+    const svcA = path.join(root, 'svc-a');
+    const svcB = path.join(path.sep, 'repos', 'svc-b');
+    manager.setWorkspaceRoots([svcA, svcB, path.join(svcB, 'third_party')]);
+    manager.updateFromSettings({ includePatterns: ['pkg/**'] });
+
+    expect(manager.shouldExcludePath(path.join(svcA, 'pkg', 'a.go'))).toBe(false);
+    expect(manager.shouldExcludePath(path.join(svcB, 'pkg', 'b.go'))).toBe(false);
+    expect(manager.shouldExcludePath(path.join(svcB, 'cmd', 'main.go'))).toBe(true);
+    // Nested root: relative to svc-b/third_party, not svc-b
+    expect(manager.shouldExcludePath(path.join(svcB, 'third_party', 'pkg', 'dep.go'))).toBe(false);
+    expect(manager.shouldExcludePath(path.join(root, 'other', 'pkg', 'x.go'))).toBe(false);
+  });
+
+  it('should keep the default of each throttle setting that is out of range', () => {
+    expect(manager.getThrottleConfig().mode).toBe('off');
+    manager.updateFromSettings({
+      throttle: { mode: 'nice' as never, maxWorkers: 2.5, fileDelayMs: -5, maxMBPerSecond: 4, pauseOnBattery: 'no' as never }
+    });
+
+    expect(manager.getThrottleConfig()).toEqual({
+      mode: 'off', maxWorkers: 2, fileDelayMs: 20, maxMBPerSecond: 4, pauseOnBattery: true
+    });
+    manager.updateFromSettings({ throttle: { mode: 'watch', maxWorkers: 64 } });
+    expect(manager.getThrottleConfig()).toMatchObject({ mode: 'watch', maxWorkers: 16 });
+  });
+});
+
+describe('configFile', () => {
+  it('should find the first config file at the root and reject unknown settings', () => {
+    const files = new Map([
+      [path.join(root, 'smart-indexer.toml'), 'languages = ["go"]\n[profiles.ci]\nbatchSize = 10\n'],
+      [path.join(root, 'elsewhere', 'smart-indexer.yaml'), 'languages: [ts]']
+    ]);
+    const file = findConfigFile(root, filePath => files.get(filePath) ?? null)!;
+    expect(file.path).toBe(path.join(root, 'smart-indexer.toml'));
+    expect(file.settings).toEqual({ languages: ['go'] });
+    expect(file.profiles).toEqual({ ci: { batchSize: 10 } });
+    expect(findConfigFile(path.join(root, 'empty'), () => null)).toBeNull();
+
+    const errorOf = (content: string) => {
+      try {
+        parseConfigFile(content, 'smart-indexer.yaml');
+      } catch (error) {
+        expect(error instanceof ConfigFileError).toBe(true);
+        return (error as Error).message;
+      }
+      throw new Error('no error');
+    };
+    expect(errorOf('excludePattern: []')).toBe('smart-indexer.yaml: Unknown setting "excludePattern" in settings');
+    expect(errorOf('profiles:\n  ci:\n    colour: red')).toContain('Unknown setting "colour" in profile "ci"');
+    expect(errorOf('profile: ci')).toContain('Default profile "ci" is not defined');
+    expect(errorOf('a: 1\na: 2')).toBe('smart-indexer.yaml:2: Duplicate key "a"');
+  });
+});
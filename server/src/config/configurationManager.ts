@@ -1,7 +1,21 @@
+import * as path from 'path';
+import { globToRegex, IgnoreRules, IgnoreRulesOptions } from '../utils/ignoreRules.js';
+import { CodeOwners } from '../utils/codeOwners.js';
+import { findRoot } from '../utils/workspaceRoots.js';
+import { extensionsOfLanguage } from '../utils/languages.js';
+import { CODE_TAGS, CodeTag } from '../types.js';
+import { LOG_FORMATS, LogFormat, parseLogLevels } from '../utils/Logger.js';
+import { ConfigFile, mergeSettings, PROFILE_ENV_VAR, resolveProfile, untrustedConfigFile } from './configFile.js';
+import type { QueryServerAccessRule } from '../features/queryServerAuth.js';
+
 export interface SmartIndexerConfig {
   cacheDirectory: string;
   enableGitIntegration: boolean;
   excludePatterns: string[];
+  /** Workspace-relative globs; when set, only matching files are indexed */
+  includePatterns: string[];
+  /** Languages to index (`go`, `ts`, ...); empty for all */
+  languages: string[];
   maxIndexedFileSize: number;
   maxFileSizeMB: number;
   maxCacheSizeMB: number;
@@ -10,11 +24,80 @@ export interface SmartIndexerConfig {
   textIndexingEnabled: boolean;
   staticIndexEnabled: boolean;
   staticIndexPath: string;
+  /** Block compression of exported binary indexes and shards */
+  staticIndexCompression: 'none' | 'deflate' | 'zstd';
+  /** Minimum log level, with per-scope levels: `info,parser=debug` */
+  logLevel: string;
+  /** Output channel log format */
+  logFormat: LogFormat;
   maxConcurrentWorkers: number;
   batchSize: number;
   useFolderHashing: boolean;
+  /** Full indexing runs longer than this are cancelled (0 = no limit) */
+  indexingTimeoutMinutes: number;
   autoSaveDelay: number;
   deadCode?: DeadCodeConfig;
+  queryServer?: QueryServerConfig;
+  extractors?: ExternalExtractorConfig[];
+  goIncludeDependencies: boolean;
+  goBuild?: GoBuildConfig;
+  bazel?: BazelConfig;
+  dependencyRules?: DependencyRule[];
+  policies?: PolicyConfig[];
+  embeddings?: EmbeddingsConfig;
+  summaries?: SummariesConfig;
+  ignore?: IgnoreConfig;
+  search?: SearchConfig;
+  remoteIndex?: RemoteIndexConfig;
+  commentMarkers?: CommentMarkerConfig;
+  httpRoutes?: HttpRoutesConfig;
+  coverage?: CoverageConfig;
+  throttle?: ThrottleConfig;
+  encryption?: EncryptionConfig;
+}
+
+export interface QueryServerConfig {
+  enabled: boolean;
+  port: number;
+  host: string;
+  /** Port to also serve the API over gRPC on, with the same host and credentials (see features/grpcListener.ts); unset or 0: no gRPC */
+  grpcPort?: number;
+  /** Who may query the server; none: no authentication (see features/queryServerAuth.ts) */
+  accessRules: QueryServerAccessRule[];
+  /** PEM files, relative to the workspace root, to serve HTTPS; `caFile` requires client certificates it signed */
+  tls?: QueryServerTlsConfig;
+  /** Serve CPU and heap profiles of the server at /debug/pprof (see profiler/sampling.ts) */
+  pprof?: boolean;
+}
+
+export interface QueryServerTlsConfig {
+  certFile: string;
+  keyFile: string;
+  caFile?: string;
+}
+
+/**
+ * Target of Go indexing, as with `go build -tags` and GOOS/GOARCH: files
+ * whose build constraints exclude them are skipped.
+ */
+export interface GoBuildConfig {
+  tags: string[];
+  /** Target operating system (empty: the host's) */
+  goos: string;
+  /** Target architecture (empty: the host's) */
+  goarch: string;
+  /** Index files for every platform and tag set instead of one target */
+  allConfigs: boolean;
+}
+
+/**
+ * Bazel workspaces: Go targets from the BUILD files Gazelle writes.
+ */
+export interface BazelConfig {
+  /** Tag Go symbols with their target labels and skip files no target compiles */
+  enabled: boolean;
+  /** Also index generated Go sources of the targets under bazel-bin */
+  generatedSources: boolean;
 }
 
 export interface DeadCodeConfig {
@@ -23,8 +106,243 @@ export interface DeadCodeConfig {
   excludePatterns: string[];
   checkBarrierFiles: boolean;
   debounceMs: number;
+  /** Name patterns the dead code report never lists (e.g. reflection-invoked handlers) */
+  allowlist: string[];
+}
+
+/**
+ * An external extractor process (JSON lines over stdio), see indexer/externalExtractor.ts.
+ */
+export interface ExternalExtractorConfig {
+  /** Name recorded as `metadata.extractor` on every symbol it produces */
+  name: string;
+  command: string;
+  args?: string[];
+  /** File extensions handed to the extractor, e.g. `[".proto", ".sql"]` */
+  extensions: string[];
+  /** Per-file timeout in milliseconds (default 10000) */
+  timeoutMs?: number;
+}
+
+/**
+ * Layering rule for the package dependency graph: packages matching `from`
+ * must not import packages matching any `disallow` glob.
+ */
+export interface DependencyRule {
+  from: string;
+  disallow: string[];
+  message?: string;
+}
+
+/**
+ * A policy the check command enforces, see features/policyCheck.ts:
+ * - `exported-doc`: exported symbols need a doc comment
+ * - `forbidden-import`: no imports matching any `imports` glob
+ * - `max-metric`: no function whose `metric` (default complexity) exceeds `max`
+ * `paths` and `exclude` are workspace-relative globs of the files it covers.
+ */
+export interface PolicyConfig {
+  name: string;
+  rule: 'exported-doc' | 'forbidden-import' | 'max-metric';
+  paths?: string[];
+  exclude?: string[];
+  /** Warnings are reported without failing the check (default: error) */
+  severity?: 'error' | 'warning';
+  imports?: string[];
+  metric?: 'complexity' | 'loc' | 'parameters' | 'nesting';
+  max?: number;
+  /** Explanation shown with violations */
+  message?: string;
+}
+
+/**
+ * Optional semantic search over functions and types, see features/embeddingIndex.ts.
+ * The API key is read from the environment variable named by `apiKeyEnv`,
+ * never from settings.
+ */
+export interface EmbeddingsConfig {
+  enabled: boolean;
+  /** `openai`: any OpenAI-compatible HTTP endpoint; `command`: a local JSON-lines process */
+  provider: 'openai' | 'command';
+  endpoint: string;
+  model: string;
+  apiKeyEnv: string;
+  /** Local model process for the `command` provider */
+  command?: string;
+  args?: string[];
+  /** Texts sent per embedding request */
+  batchSize: number;
+  /** Chunks longer than this are truncated before embedding */
+  maxChunkChars: number;
+  hnsw: HnswConfig;
 }
 
+/**
+ * Approximate nearest neighbor search over embeddings, see features/hnswIndex.ts.
+ * Changing `m` or `efConstruction` rebuilds the graph; `efSearch` applies immediately.
+ */
+export interface HnswConfig {
+  /** Exact brute-force search when false */
+  enabled: boolean;
+  m: number;
+  efConstruction: number;
+  efSearch: number;
+}
+
+/**
+ * One-line summaries of complex exported functions and types, see
+ * features/symbolSummaries.ts. Like embeddings, the source of each
+ * summarized symbol is sent to the provider; the API key is read from the
+ * environment variable named by `apiKeyEnv`.
+ */
+export interface SummariesConfig {
+  enabled: boolean;
+  /** `openai`: any OpenAI-compatible chat completions endpoint; `command`: a local JSON-lines process */
+  provider: 'openai' | 'command';
+  endpoint: string;
+  model: string;
+  apiKeyEnv: string;
+  /** Local model process for the `command` provider */
+  command?: string;
+  args?: string[];
+  /** Functions and methods at least this complex are summarized */
+  minComplexity: number;
+  /** Functions, methods and types at least this long are summarized */
+  minLines: number;
+  /** Sources longer than this are truncated before summarizing */
+  maxSourceChars: number;
+  /** Summary requests in flight at once */
+  concurrency: number;
+}
+
+/**
+ * File filtering on top of `excludePatterns`, see utils/ignoreRules.ts.
+ * `.indexerignore` files always apply.
+ */
+export interface IgnoreConfig extends IgnoreRulesOptions {
+  /** Skip files with a generated-code marker (`// Code generated ... DO NOT EDIT`) */
+  generated: boolean;
+}
+
+/**
+ * Workspace symbol search, see utils/codeTags.ts.
+ */
+export interface SearchConfig {
+  /** Code tags hidden from workspace symbol search, e.g. `["generated", "mock"]` */
+  excludeTags: CodeTag[];
+  /** Results kept per query cache until the index changes (0: no caching), see utils/queryCache.ts */
+  cacheSize: number;
+  /**
+   * Named scopes searches and queries can be limited to, as query language
+   * filters: `backend: "file:services/** -tag:generated"` (see features/searchScopes.ts)
+   */
+  scopes: Record<string, string>;
+  /** Structured queries saved by name; a string is the query alone */
+  queries: Record<string, SavedQueryConfig | string>;
+}
+
+/**
+ * A structured query saved in the config, see features/searchScopes.ts.
+ */
+export interface SavedQueryConfig {
+  /** Query language expression, e.g. `kind:func exported:true coverage:0` */
+  query: string;
+  /** Scope the query runs in (default: everything) */
+  scope?: string;
+  description?: string;
+}
+
+/**
+ * Prebuilt index shared through object storage, see features/remoteIndex.ts.
+ */
+export interface RemoteIndexConfig {
+  /** `s3://bucket/key`, `gs://bucket/key` or `http(s)://` URL of the index (empty: none) */
+  url: string;
+  /** Pull the index before the first full indexing of a workspace */
+  pullOnStartup: boolean;
+}
+
+/**
+ * Comment markers on top of TODO, FIXME, HACK, XXX, BUG and DEPRECATED,
+ * see features/commentMarkers.ts.
+ */
+export interface CommentMarkerConfig {
+  /** More tags, e.g. `["PERF", "SECURITY"]` */
+  tags: string[];
+  /** Regular expressions matched at the start of a comment line; a named group `owner` sets the owner */
+  patterns: Array<{ tag: string; pattern: string }>;
+}
+
+/**
+ * Routes registered with net/http, chi, gin or echo, see features/httpRoutes.ts.
+ */
+export interface HttpRoutesConfig {
+  enabled: boolean;
+  /** Routers to recognize; files that import none of them are not scanned */
+  frameworks: Array<'net/http' | 'chi' | 'gin' | 'echo'>;
+}
+
+/**
+ * Go coverage profiles per-function coverage is read from, see features/coverage.ts.
+ */
+export interface CoverageConfig {
+  /** `go test -coverprofile` output files, relative to the workspace root */
+  profiles: string[];
+}
+
+/**
+ * Nice mode: indexing that keeps out of the way of the user, see
+ * index/indexThrottle.ts.
+ */
+export interface ThrottleConfig {
+  /** off; watch: re-indexing of changed files; always: full indexing too */
+  mode: ThrottleMode;
+  /** Files indexed at once while throttled */
+  maxWorkers: number;
+  /** Pause before each file, in milliseconds */
+  fileDelayMs: number;
+  /** File bytes read per second, in MB (0 = no limit) */
+  maxMBPerSecond: number;
+  /** Hold throttled indexing while the machine runs on battery */
+  pauseOnBattery: boolean;
+}
+
+export const THROTTLE_MODES = ['off', 'watch', 'always'] as const;
+export type ThrottleMode = typeof THROTTLE_MODES[number];
+
+export const DEFAULT_THROTTLE_CONFIG: ThrottleConfig = {
+  mode: 'off',
+  maxWorkers: 1,
+  fileDelayMs: 20,
+  maxMBPerSecond: 0,
+  pauseOnBattery: true
+};
+
+/**
+ * Encryption of saved and pushed indexes at rest, see
+ * index/indexEncryption.ts. Encrypted indexes are decrypted on load
+ * whether or not it is enabled.
+ */
+export interface EncryptionConfig {
+  /** Encrypt indexes as they are written */
+  enabled: boolean;
+  /** `env`: a key in `keyEnv`; `command`: a helper that wraps keys, e.g. with a KMS */
+  provider: 'env' | 'command';
+  /** Environment variable holding the key (keys are never read from settings) */
+  keyEnv: string;
+  command?: string;
+  args?: string[];
+  /** While encrypting, also read indexes that are not encrypted, e.g. ones written before */
+  allowPlaintext: boolean;
+}
+
+export const DEFAULT_ENCRYPTION_CONFIG: EncryptionConfig = {
+  enabled: false,
+  provider: 'env',
+  keyEnv: 'SMART_INDEXER_INDEX_KEY',
+  allowPlaintext: false
+};
+
 const DEFAULT_DEAD_CODE_CONFIG: DeadCodeConfig = {
   enabled: false,
   entryPoints: [
@@ -39,7 +357,89 @@ const DEFAULT_DEAD_CODE_CONFIG: DeadCodeConfig = {
   ],
   excludePatterns: [],
   checkBarrierFiles: false, // Expensive, opt-in
-  debounceMs: 1500
+  debounceMs: 1500,
+  allowlist: []
+};
+
+const DEFAULT_QUERY_SERVER_CONFIG: QueryServerConfig = {
+  enabled: false,
+  port: 7717,
+  host: '127.0.0.1', // Keep it local unless access rules (and TLS) are configured
+  accessRules: []
+};
+
+const DEFAULT_GO_BUILD_CONFIG: GoBuildConfig = {
+  tags: [],
+  goos: '',
+  goarch: '',
+  allConfigs: false
+};
+
+const DEFAULT_BAZEL_CONFIG: BazelConfig = {
+  enabled: false,
+  generatedSources: true
+};
+
+const DEFAULT_EMBEDDINGS_CONFIG: EmbeddingsConfig = {
+  enabled: false, // Sends source code to the provider, opt-in
+  provider: 'openai',
+  endpoint: 'https://api.openai.com/v1/embeddings',
+  model: 'text-embedding-3-small',
+  apiKeyEnv: 'OPENAI_API_KEY',
+  batchSize: 64,
+  maxChunkChars: 2000,
+  hnsw: {
+    enabled: true,
+    m: 16,
+    efConstruction: 200,
+    efSearch: 64
+  }
+};
+
+const DEFAULT_SUMMARIES_CONFIG: SummariesConfig = {
+  enabled: false, // Sends source code to the provider, opt-in
+  provider: 'openai',
+  endpoint: 'https://api.openai.com/v1/chat/completions',
+  model: 'gpt-4o-mini',
+  apiKeyEnv: 'OPENAI_API_KEY',
+  minComplexity: 8,
+  minLines: 40,
+  maxSourceChars: 4000,
+  concurrency: 4
+};
+
+const DEFAULT_IGNORE_CONFIG: IgnoreConfig = {
+  gitignore: true,
+  vendor: true, // Vendored Go code is still indexed with goIncludeDependencies
+  testdata: true,
+  generated: true,
+  patterns: []
+};
+
+const DEFAULT_SEARCH_CONFIG: SearchConfig = {
+  excludeTags: [],
+  cacheSize: 500,
+  scopes: {},
+  queries: {}
+};
+
+const DEFAULT_REMOTE_INDEX_CONFIG: RemoteIndexConfig = {
+  url: '',
+  pullOnStartup: false
+};
+
+const DEFAULT_COMMENT_MARKERS_CONFIG: CommentMarkerConfig = {
+  tags: [],
+  patterns: []
+};
+
+const DEFAULT_HTTP_ROUTES_CONFIG: HttpRoutesConfig = {
+  enabled: false,
+  frameworks: ['net/http', 'chi', 'gin', 'echo']
+};
+
+const DEFAULT_COVERAGE_CONFIG: CoverageConfig = {
+  profiles: ['coverage.out']
 };
 
 const DEFAULT_CONFIG: SmartIndexerConfig = {
@@ -57,6 +457,8 @@ const DEFAULT_CONFIG: SmartIndexerConfig = {
     '**/coverage/**',
     '**/.vscode-test/**'
   ],
+  includePatterns: [],
+  languages: [],
   maxIndexedFileSize: 1048576,
   maxFileSizeMB: 50,
   maxCacheSizeMB: 500,
@@ -65,11 +467,32 @@ const DEFAULT_CONFIG: SmartIndexerConfig = {
   textIndexingEnabled: false,
   staticIndexEnabled: false,
   staticIndexPath: '',
+  staticIndexCompression: 'none',
+  logLevel: 'info',
+  logFormat: 'text',
   maxConcurrentWorkers: 4,
   batchSize: 50,
   useFolderHashing: true,
+  indexingTimeoutMinutes: 0,
   autoSaveDelay: 2000,
-  deadCode: DEFAULT_DEAD_CODE_CONFIG
+  deadCode: DEFAULT_DEAD_CODE_CONFIG,
+  queryServer: DEFAULT_QUERY_SERVER_CONFIG,
+  extractors: [],
+  goIncludeDependencies: false,
+  goBuild: DEFAULT_GO_BUILD_CONFIG,
+  bazel: DEFAULT_BAZEL_CONFIG,
+  dependencyRules: [],
+  policies: [],
+  embeddings: DEFAULT_EMBEDDINGS_CONFIG,
+  summaries: DEFAULT_SUMMARIES_CONFIG,
+  ignore: DEFAULT_IGNORE_CONFIG,
+  search: DEFAULT_SEARCH_CONFIG,
+  remoteIndex: DEFAULT_REMOTE_INDEX_CONFIG,
+  commentMarkers: DEFAULT_COMMENT_MARKERS_CONFIG,
+  httpRoutes: DEFAULT_HTTP_ROUTES_CONFIG,
+  coverage: DEFAULT_COVERAGE_CONFIG,
+  throttle: DEFAULT_THROTTLE_CONFIG,
+  encryption: DEFAULT_ENCRYPTION_CONFIG
 };
 
 /**
@@ -80,6 +503,8 @@ export interface ISmartIndexerSettings {
   cacheDirectory?: string;
   enableGitIntegration?: boolean;
   excludePatterns?: string[];
+  includePatterns?: string[];
+  languages?: string[];
   maxIndexedFileSize?: number;
   maxFileSizeMB?: number;
   maxCacheSizeMB?: number;
@@ -88,80 +513,138 @@ export interface ISmartIndexerSettings {
   textIndexingEnabled?: boolean;
   staticIndexEnabled?: boolean;
   staticIndexPath?: string;
+  staticIndexCompression?: 'none' | 'deflate' | 'zstd';
+  logLevel?: string;
+  logFormat?: LogFormat;
   maxConcurrentWorkers?: number;
   batchSize?: number;
   useFolderHashing?: boolean;
+  indexingTimeoutMinutes?: number;
   autoSaveDelay?: number;
   deadCode?: Partial<DeadCodeConfig>;
+  queryServer?: Partial<QueryServerConfig>;
+  extractors?: ExternalExtractorConfig[];
+  goIncludeDependencies?: boolean;
+  goBuild?: Partial<GoBuildConfig>;
+  bazel?: Partial<BazelConfig>;
+  dependencyRules?: DependencyRule[];
+  policies?: PolicyConfig[];
+  embeddings?: Partial<Omit<EmbeddingsConfig, 'hnsw'>> & { hnsw?: Partial<HnswConfig> };
+  summaries?: Partial<SummariesConfig>;
+  ignore?: Partial<IgnoreConfig>;
+  search?: Partial<SearchConfig>;
+  remoteIndex?: Partial<RemoteIndexConfig>;
+  commentMarkers?: Partial<CommentMarkerConfig>;
+  httpRoutes?: Partial<HttpRoutesConfig>;
+  coverage?: Partial<CoverageConfig>;
+  throttle?: Partial<ThrottleConfig>;
+  encryption?: Partial<EncryptionConfig>;
+  /** Profile of the workspace config file to use (see configFile.ts) */
+  profile?: string;
+}
+
+/** The workspace config file in use and the profile selected from it */
+export interface LoadedConfigFile {
+  path: string;
+  profile?: string;
+  /** Settings of the file ignored because it is not trusted (see configFile.ts) */
+  ignoredSettings: string[];
 }
 
+/**
+ * Configuration Manager - the effective settings: defaults, then the
+ * workspace config file (smart-indexer.yaml, with its profile), then the
+ * settings given explicitly by the editor or library, each layer merged
+ * over the one before.
+ */
 export class ConfigurationManager {
   private config: SmartIndexerConfig;
+  private workspaceRoot: string | null = null;
+  private workspaceRoots: Array<{ path: string }> = [];
+  private ignoreRules = new Map<string, IgnoreRules>();
+  private codeOwners: CodeOwners | null = null;
+  private fileSettings: ISmartIndexerSettings = {};
+  private explicitSettings: ISmartIndexerSettings = {};
+  private configFile: LoadedConfigFile | null = null;
+  private includeRegexes: RegExp[] | null = null;
 
   constructor() {
     this.config = { ...DEFAULT_CONFIG };
   }
 
+  /**
+   * Workspace root for .gitignore / .indexerignore lookups; until set, only
+   * the hardcoded exclusions apply.
+   */
+  setWorkspaceRoot(workspaceRoot: string): void {
+    this.setWorkspaceRoots([workspaceRoot]);
+  }
+
+  /**
+   * Roots of a multi-root workspace. Ignore files and include patterns
+   * apply per root, relative to the deepest root containing the file; the
+   * first root is the workspace root (CODEOWNERS).
+   */
+  setWorkspaceRoots(workspaceRoots: string[]): void {
+    this.workspaceRoot = workspaceRoots[0] ?? null;
+    this.workspaceRoots = workspaceRoots.map(root => ({ path: root }));
+    this.ignoreRules.clear();
+    this.codeOwners = null;
+  }
+
   getConfig(): SmartIndexerConfig {
     return { ...this.config };
   }
 
   updateFromInitializationOptions(opts: Partial<ISmartIndexerSettings> | null | undefined): void {
-    if (!opts) {return;}
-
-    if (typeof opts.cacheDirectory === 'string') {
-      this.config.cacheDirectory = opts.cacheDirectory;
-    }
-    if (typeof opts.enableGitIntegration === 'boolean') {
-      this.config.enableGitIntegration = opts.enableGitIntegration;
-    }
-    if (Array.isArray(opts.excludePatterns)) {
-      this.config.excludePatterns = opts.excludePatterns;
-    }
-    if (typeof opts.maxIndexedFileSize === 'number') {
-      this.config.maxIndexedFileSize = opts.maxIndexedFileSize;
-    }
-    if (typeof opts.maxFileSizeMB === 'number') {
-      this.config.maxFileSizeMB = Math.max(1, opts.maxFileSizeMB);
-    }
-    if (typeof opts.maxCacheSizeMB === 'number') {
-      this.config.maxCacheSizeMB = Math.max(10, opts.maxCacheSizeMB);
-    }
-    if (typeof opts.maxConcurrentIndexJobs === 'number') {
-      this.config.maxConcurrentIndexJobs = Math.max(1, Math.min(16, opts.maxConcurrentIndexJobs));
-    }
-    if (typeof opts.enableBackgroundIndex === 'boolean') {
-      this.config.enableBackgroundIndex = opts.enableBackgroundIndex;
-    }
-    if (typeof opts.textIndexingEnabled === 'boolean') {
-      this.config.textIndexingEnabled = opts.textIndexingEnabled;
-    }
-    if (typeof opts.staticIndexEnabled === 'boolean') {
-      this.config.staticIndexEnabled = opts.staticIndexEnabled;
-    }
-    if (typeof opts.staticIndexPath === 'string') {
-      this.config.staticIndexPath = opts.staticIndexPath;
-    }
-    if (typeof opts.maxConcurrentWorkers === 'number') {
-      this.config.maxConcurrentWorkers = Math.max(1, Math.min(16, opts.maxConcurrentWorkers));
-    }
-    if (typeof opts.batchSize === 'number') {
-      this.config.batchSize = Math.max(1, opts.batchSize);
-    }
-    if (typeof opts.useFolderHashing === 'boolean') {
-      this.config.useFolderHashing = opts.useFolderHashing;
-    }
-    if (typeof opts.autoSaveDelay === 'number') {
-      this.config.autoSaveDelay = Math.max(500, opts.autoSaveDelay);
-    }
-    if (opts.deadCode) {
-      this.config.deadCode = { ...DEFAULT_DEAD_CODE_CONFIG, ...opts.deadCode };
-    }
+    this.updateFromSettings(opts);
   }
 
+  /**
+   * Merge settings given explicitly (by the editor or library) over the
+   * current ones; they take precedence over the config file.
+   */
   updateFromSettings(settings: Partial<ISmartIndexerSettings> | null | undefined): void {
     if (!settings) {return;}
 
+    this.explicitSettings = mergeSettings(this.explicitSettings, settings);
+    this.rebuild();
+  }
+
+  /**
+   * Use a workspace config file (see configFile.ts) under the explicit
+   * settings, with the given profile, else the `profile` setting, else
+   * SMART_INDEXER_PROFILE, else the file's own. Unless trusted, its
+   * TRUSTED_SETTING_KEYS are ignored. Null removes it. Throws
+   * ConfigFileError for an unknown profile.
+   */
+  setConfigFile(file: ConfigFile | null, profile?: string, trusted: boolean = false): LoadedConfigFile | null {
+    if (!file) {
+      this.fileSettings = {};
+      this.configFile = null;
+    } else {
+      const { file: used, ignored } = trusted ? { file, ignored: [] } : untrustedConfigFile(file);
+      const selected = profile || this.explicitSettings.profile || process.env[PROFILE_ENV_VAR] || file.defaultProfile;
+      this.fileSettings = resolveProfile(used, selected);
+      this.configFile = { path: file.path, profile: selected, ignoredSettings: ignored };
+    }
+    this.rebuild();
+    return this.configFile;
+  }
+
+  /** The workspace config file in use, if any */
+  getConfigFile(): LoadedConfigFile | null {
+    return this.configFile;
+  }
+
+  private rebuild(): void {
+    this.config = { ...DEFAULT_CONFIG };
+    this.applySettings(mergeSettings(this.fileSettings, this.explicitSettings));
+    this.ignoreRules.clear();
+    this.includeRegexes = null;
+  }
+
+  private applySettings(settings: ISmartIndexerSettings): void {
     if (typeof settings.cacheDirectory === 'string') {
       this.config.cacheDirectory = settings.cacheDirectory;
     }
@@ -171,6 +654,12 @@ export class ConfigurationManager {
     if (Array.isArray(settings.excludePatterns)) {
       this.config.excludePatterns = settings.excludePatterns;
     }
+    if (Array.isArray(settings.includePatterns)) {
+      this.config.includePatterns = settings.includePatterns.filter(pattern => typeof pattern === 'string');
+    }
+    if (Array.isArray(settings.languages)) {
+      this.config.languages = settings.languages.filter(language => typeof language === 'string');
+    }
     if (typeof settings.maxIndexedFileSize === 'number') {
       this.config.maxIndexedFileSize = settings.maxIndexedFileSize;
     }
@@ -195,9 +684,21 @@ export class ConfigurationManager {
     if (typeof settings.staticIndexPath === 'string') {
       this.config.staticIndexPath = settings.staticIndexPath;
     }
+    if (settings.staticIndexCompression && ['none', 'deflate', 'zstd'].includes(settings.staticIndexCompression)) {
+      this.config.staticIndexCompression = settings.staticIndexCompression;
+    }
+    if (typeof settings.logLevel === 'string' && isLogLevelSpec(settings.logLevel)) {
+      this.config.logLevel = settings.logLevel;
+    }
+    if (settings.logFormat && LOG_FORMATS.includes(settings.logFormat)) {
+      this.config.logFormat = settings.logFormat;
+    }
     if (typeof settings.maxConcurrentWorkers === 'number') {
       this.config.maxConcurrentWorkers = Math.max(1, Math.min(16, settings.maxConcurrentWorkers));
     }
+    if (typeof settings.indexingTimeoutMinutes === 'number') {
+      this.config.indexingTimeoutMinutes = Math.max(0, settings.indexingTimeoutMinutes);
+    }
     if (typeof settings.batchSize === 'number') {
       this.config.batchSize = Math.max(1, settings.batchSize);
     }
@@ -210,6 +711,74 @@ export class ConfigurationManager {
     if (settings.deadCode) {
       this.config.deadCode = { ...DEFAULT_DEAD_CODE_CONFIG, ...settings.deadCode };
     }
+    if (settings.queryServer) {
+      this.config.queryServer = { ...DEFAULT_QUERY_SERVER_CONFIG, ...settings.queryServer };
+      if (!Array.isArray(this.config.queryServer.accessRules)) {
+        this.config.queryServer.accessRules = [];
+      }
+      const tls = this.config.queryServer.tls;
+      if (tls && (typeof tls.certFile !== 'string' || typeof tls.keyFile !== 'string' || !tls.certFile || !tls.keyFile)) {
+        this.config.queryServer.tls = undefined;
+      }
+    }
+    if (Array.isArray(settings.extractors)) {
+      this.config.extractors = settings.extractors.filter(isValidExtractorConfig);
+    }
+    if (typeof settings.goIncludeDependencies === 'boolean') {
+      this.config.goIncludeDependencies = settings.goIncludeDependencies;
+    }
+    if (settings.goBuild) {
+      this.config.goBuild = { ...DEFAULT_GO_BUILD_CONFIG, ...settings.goBuild };
+    }
+    if (settings.bazel) {
+      this.config.bazel = { ...DEFAULT_BAZEL_CONFIG, ...settings.bazel };
+    }
+    if (Array.isArray(settings.dependencyRules)) {
+      this.config.dependencyRules = settings.dependencyRules.filter(isValidDependencyRule);
+    }
+    if (Array.isArray(settings.policies)) {
+      this.config.policies = settings.policies.filter(isValidPolicy);
+    }
+    if (settings.embeddings) {
+      this.config.embeddings = {
+        ...DEFAULT_EMBEDDINGS_CONFIG,
+        ...settings.embeddings,
+        hnsw: { ...DEFAULT_EMBEDDINGS_CONFIG.hnsw, ...settings.embeddings.hnsw }
+      };
+    }
+    if (settings.summaries) {
+      this.config.summaries = { ...DEFAULT_SUMMARIES_CONFIG, ...settings.summaries };
+    }
+    if (settings.ignore) {
+      this.config.ignore = { ...DEFAULT_IGNORE_CONFIG, ...settings.ignore };
+    }
+    if (settings.search) {
+      const { cacheSize } = settings.search;
+      this.config.search = {
+        excludeTags: validCodeTags(settings.search.excludeTags),
+        cacheSize: Number.isInteger(cacheSize) && cacheSize! >= 0 ? cacheSize! : DEFAULT_SEARCH_CONFIG.cacheSize,
+        scopes: { ...settings.search.scopes },
+        queries: { ...settings.search.queries }
+      };
+    }
+    if (settings.remoteIndex) {
+      this.config.remoteIndex = { ...DEFAULT_REMOTE_INDEX_CONFIG, ...settings.remoteIndex };
+    }
+    if (settings.commentMarkers) {
+      this.config.commentMarkers = { ...DEFAULT_COMMENT_MARKERS_CONFIG, ...settings.commentMarkers };
+    }
+    if (settings.httpRoutes) {
+      this.config.httpRoutes = { ...DEFAULT_HTTP_ROUTES_CONFIG, ...settings.httpRoutes };
+    }
+    if (settings.coverage) {
+      this.config.coverage = { ...DEFAULT_COVERAGE_CONFIG, ...settings.coverage };
+    }
+    if (settings.throttle) {
+      this.config.throttle = validThrottleConfig(settings.throttle);
+    }
+    if (settings.encryption) {
+      this.config.encryption = { ...DEFAULT_ENCRYPTION_CONFIG, ...settings.encryption };
+    }
   }
 
   getMaxFileSizeBytes(): number {
@@ -224,11 +793,91 @@ export class ConfigurationManager {
     return this.config.deadCode || DEFAULT_DEAD_CODE_CONFIG;
   }
 
+  getQueryServerConfig(): QueryServerConfig {
+    return this.config.queryServer || DEFAULT_QUERY_SERVER_CONFIG;
+  }
+
+  getGoBuildConfig(): GoBuildConfig {
+    return this.config.goBuild || DEFAULT_GO_BUILD_CONFIG;
+  }
+
+  getBazelConfig(): BazelConfig {
+    return this.config.bazel || DEFAULT_BAZEL_CONFIG;
+  }
+
+  getEmbeddingsConfig(): EmbeddingsConfig {
+    return this.config.embeddings || DEFAULT_EMBEDDINGS_CONFIG;
+  }
+
+  getSummariesConfig(): SummariesConfig {
+    return this.config.summaries ?? DEFAULT_SUMMARIES_CONFIG;
+  }
+
+  getIgnoreConfig(): IgnoreConfig {
+    return this.config.ignore ?? DEFAULT_IGNORE_CONFIG;
+  }
+
+  getSearchConfig(): SearchConfig {
+    return this.config.search ?? DEFAULT_SEARCH_CONFIG;
+  }
+
+  getRemoteIndexConfig(): RemoteIndexConfig {
+    return this.config.remoteIndex ?? DEFAULT_REMOTE_INDEX_CONFIG;
+  }
+
+  getCommentMarkersConfig(): CommentMarkerConfig {
+    return this.config.commentMarkers ?? DEFAULT_COMMENT_MARKERS_CONFIG;
+  }
+
+  getHttpRoutesConfig(): HttpRoutesConfig {
+    return this.config.httpRoutes ?? DEFAULT_HTTP_ROUTES_CONFIG;
+  }
+
+  getCoverageConfig(): CoverageConfig {
+    return this.config.coverage ?? DEFAULT_COVERAGE_CONFIG;
+  }
+
+  getThrottleConfig(): ThrottleConfig {
+    return this.config.throttle ?? DEFAULT_THROTTLE_CONFIG;
+  }
+
+  getEncryptionConfig(): EncryptionConfig {
+    return this.config.encryption ?? DEFAULT_ENCRYPTION_CONFIG;
+  }
+
+  /**
+   * Re-read ignore files on the next check (after a .gitignore or .indexerignore change).
+   */
+  clearIgnoreCache(): void {
+    for (const rules of this.ignoreRules.values()) {
+      rules.clear();
+    }
+  }
+
+  /**
+   * Owners of workspace files per the workspace's CODEOWNERS; null until
+   * the workspace root is set.
+   */
+  getCodeOwners(): CodeOwners | null {
+    if (!this.codeOwners && this.workspaceRoot) {
+      this.codeOwners = new CodeOwners(this.workspaceRoot);
+    }
+    return this.codeOwners;
+  }
+
+  /**
+   * Lowercase extensions claimed by configured extractors, so the scanner
+   * and watcher pick up files no built-in indexer handles.
+   */
+  getExtractorExtensions(): string[] {
+    return (this.config.extractors || []).flatMap(e => e.extensions.map(ext => ext.toLowerCase()));
+  }
+
   isDeadCodeEnabled(): boolean {
     return this.config.deadCode?.enabled ?? DEFAULT_DEAD_CODE_CONFIG.enabled;
   }
 
-  shouldExcludePath(filePath: string): boolean {
+  shouldExcludePath(filePath: string, isDirectory: boolean = false): boolean {
     // Hardcoded exclusions for VS Code internal, Copilot caches, and build artifacts
     const hardcodedExclusions = [
       'vscode-userdata:',
@@ -261,6 +910,96 @@ export class ConfigurationManager {
       }
     }
 
+    if (!isDirectory && !this.isIncluded(filePath)) {
+      return true;
+    }
+
+    return this.getIgnoreRules(filePath)?.isIgnored(filePath, isDirectory) ?? false;
+  }
+
+  /**
+   * Whether a file passes `languages` and `includePatterns` (relative to
+   * the root containing it; files outside every root pass); directories
+   * are always walked.
+   */
+  private isIncluded(filePath: string): boolean {
+    const { includePatterns, languages } = this.config;
+    if (languages.length > 0) {
+      const extension = path.extname(filePath).toLowerCase();
+      if (!languages.some(language => extensionsOfLanguage(language)?.includes(extension))) {
+        return false;
+      }
+    }
+    const root = findRoot(this.workspaceRoots, filePath);
+    if (includePatterns.length === 0 || !root) {
+      return true; // e.g. Go dependencies in the module cache
+    }
+    if (!this.includeRegexes) {
+      this.includeRegexes = includePatterns.map(glob => new RegExp(`^${globToRegex(glob.replace(/^\.?\//, ''))}$`));
+    }
+    const relative = path.relative(root.path, filePath);
+    const normalized = relative.split(path.sep).join('/');
+    return this.includeRegexes.some(regex => regex.test(normalized));
+  }
+
+  private getIgnoreRules(filePath: string): IgnoreRules | null {
+    const root = findRoot(this.workspaceRoots, filePath);
+    if (!root) {
+      return null;
+    }
+    let rules = this.ignoreRules.get(root.path);
+    if (!rules) {
+      const ignore = this.getIgnoreConfig();
+      rules = new IgnoreRules(root.path, {
+        ...ignore,
+        vendor: ignore.vendor && !this.config.goIncludeDependencies
+      });
+      this.ignoreRules.set(root.path, rules);
+    }
+    return rules;
+  }
+}
+
+function isValidExtractorConfig(config: ExternalExtractorConfig): boolean {
+  return typeof config?.name === 'string' &&
+    typeof config.command === 'string' &&
+    Array.isArray(config.extensions);
+}
+
+function isLogLevelSpec(spec: string): boolean {
+  try {
+    parseLogLevels(spec);
+    return true;
+  } catch {
     return false;
   }
 }
+
+function isValidDependencyRule(rule: DependencyRule): boolean {
+  return typeof rule?.from === 'string' && Array.isArray(rule.disallow);
+}
+
+function isValidPolicy(policy: PolicyConfig): boolean {
+  return typeof policy?.name === 'string' && typeof policy.rule === 'string';
+}
+
+function validCodeTags(tags: unknown): CodeTag[] {
+  return Array.isArray(tags) ? CODE_TAGS.filter(tag => tags.includes(tag)) : [];
+}
+
+/**
+ * Throttle settings over the defaults; values of the wrong type or out of
+ * range keep the default.
+ */
+function validThrottleConfig(throttle: Partial<ThrottleConfig>): ThrottleConfig {
+  const number = (value: unknown, min: number, fallback: number) =>
+    typeof value === 'number' && Number.isFinite(value) && value >= min ? value : fallback;
+  const { mode, maxWorkers, fileDelayMs, maxMBPerSecond, pauseOnBattery } = throttle;
+  return {
+    mode: THROTTLE_MODES.includes(mode as ThrottleMode) ? mode! : DEFAULT_THROTTLE_CONFIG.mode,
+    maxWorkers: Math.min(16, Math.floor(number(maxWorkers, 1, DEFAULT_THROTTLE_CONFIG.maxWorkers))),
+    fileDelayMs: number(fileDelayMs, 0, DEFAULT_THROTTLE_CONFIG.fileDelayMs),
+    maxMBPerSecond: number(maxMBPerSecond, 0, DEFAULT_THROTTLE_CONFIG.maxMBPerSecond),
+    pauseOnBattery: typeof pauseOnBattery === 'boolean' ? pauseOnBattery : DEFAULT_THROTTLE_CONFIG.pauseOnBattery
+  };
+}
@@ -3,13 +3,15 @@ import {
   InitializeParams,
   InitializeResult,
   TextDocumentSyncKind,
-  DidChangeConfigurationNotification
+  DidChangeConfigurationNotification,
+  WorkspaceFoldersChangeEvent
 } from 'vscode-languageserver/node';
 import { URI } from 'vscode-uri';
 import * as path from 'path';
 import * as crypto from 'crypto';
 
 import { ConfigurationManager } from '../config/configurationManager.js';
+import { findConfigFile } from '../config/configFile.js';
 import { DynamicIndex } from '../index/dynamicIndex.js';
 import { BackgroundIndex } from '../index/backgroundIndex.js';
 import { MergedIndex } from '../index/mergedIndex.js';
@@ -17,14 +19,22 @@ import { StaticIndex } from '../index/staticIndex.js';
 import { StatsManager } from '../index/statsManager.js';
 import { FileWatcher } from '../index/fileWatcher.js';
 import { DeadCodeDetector } from '../features/deadCode.js';
+import { RemoteIndexSync } from '../features/remoteIndex.js';
+import { indexKeysFor } from '../index/indexEncryption.js';
 import { ImportResolver } from '../indexer/importResolver.js';
 import { LanguageRouter } from '../indexer/languageRouter.js';
 import { FileScanner } from '../indexer/fileScanner.js';
+import { collectGoDependencyFiles } from '../indexer/goModules.js';
+import { collectBazelGeneratedFiles } from '../indexer/bazelTargets.js';
 import { GitWatcher } from '../git/gitWatcher.js';
+import { gitChangesSince } from '../git/gitDelta.js';
 import { FolderHasher } from '../cache/folderHasher.js';
 import { Profiler } from '../profiler/profiler.js';
 import { FileSystemService } from '../utils/FileSystemService.js';
 import { LoggerService } from '../utils/Logger.js';
+import { CancellationError, CancellationTokenSource } from '../utils/asyncUtils.js';
+import { formatProgress } from '../utils/indexingProgress.js';
+import { isWithinRoot, WorkspaceRootSummary, WorkspaceRoots } from '../utils/workspaceRoots.js';
 import { TextDocuments } from 'vscode-languageserver/node';
 import { TextDocument } from 'vscode-languageserver-textdocument';
 
@@ -32,7 +42,10 @@ import { TextDocument } from 'vscode-languageserver-textdocument';
  * Result of server initialization.
  */
 export interface InitializationResult {
+  /** First workspace folder: holds the cache and config file */
   workspaceRoot: string;
+  /** Every workspace folder, all indexed into one index */
+  workspaceRoots: string[];
   hasConfigurationCapability: boolean;
   hasWorkspaceFolderCapability: boolean;
   importResolver: ImportResolver | null;
@@ -41,6 +54,21 @@ export interface InitializationResult {
   staticIndex: StaticIndex | undefined;
 }
 
+/**
+ * Result of updating the index from git changes (updateSince).
+ */
+export interface GitUpdateResult {
+  root: string;
+  /** The commit compared against, and HEAD, as hashes */
+  since: string;
+  head: string;
+  /** Changed files indexed */
+  indexed: number;
+  /** Files dropped: deleted, or no longer indexable */
+  removed: number;
+  duration: number;
+}
+
 /**
  * Dependencies required for server initialization.
  */
@@ -74,18 +102,36 @@ interface IndexMetadata {
 /**
  * Handles server initialization and indexing setup.
  * Extracted from server.ts for single-responsibility.
+ *
+ * Every folder of a multi-root workspace is indexed into the one index, so
+ * searches and references span repositories; the cache and config file
+ * live in the first folder. Git-aware incremental indexing needs a single
+ * repository, so multi-root workspaces are indexed in full, with unchanged
+ * files skipped by hash.
  */
 export class ServerInitializer {
   private workspaceRoot: string = '';
+  private workspaceRoots = new WorkspaceRoots();
   private hasConfigurationCapability: boolean = false;
   private hasWorkspaceFolderCapability: boolean = false;
   private importResolver: ImportResolver | null = null;
   private deadCodeDetector: DeadCodeDetector | null = null;
   private fileWatcher: FileWatcher | null = null;
   private staticIndex: StaticIndex | undefined;
+  private activeIndexing = new Set<CancellationTokenSource>();
 
   constructor(private readonly deps: ServerDependencies) {}
 
+  /**
+   * Cancel running background indexing (e.g. on shutdown). Files already
+   * being written are finished first, so the persisted index stays consistent.
+   */
+  cancelIndexing(): void {
+    for (const source of this.activeIndexing) {
+      source.cancel();
+    }
+  }
+
   /**
    * Handle LSP initialize request.
    */
@@ -118,6 +164,9 @@ export class ServerInitializer {
 
       // Determine workspace root
       this.workspaceRoot = this.determineWorkspaceRoot(params);
+      if (this.workspaceRoot) {
+        this.loadConfigFile(params.initializationOptions?.trustConfigFile === true);
+      }
 
       // Initialize import resolver and TypeScript service
       if (this.workspaceRoot) {
@@ -137,14 +186,16 @@ export class ServerInitializer {
           },
           definitionProvider: true,
           referencesProvider: true,
-          workspaceSymbolProvider: true
+          workspaceSymbolProvider: true,
+          documentSymbolProvider: true
         }
       };
 
       if (this.hasWorkspaceFolderCapability) {
         result.capabilities.workspace = {
           workspaceFolders: {
-            supported: true
+            supported: true,
+            changeNotifications: true
           }
         };
       }
@@ -172,9 +223,7 @@ export class ServerInitializer {
       }
 
       if (this.hasWorkspaceFolderCapability) {
-        connection.workspace.onDidChangeWorkspaceFolders(_event => {
-          connection.console.info('[ServerInitializer] Workspace folder change event received');
-        });
+        connection.workspace.onDidChangeWorkspaceFolders(event => this.handleWorkspaceFoldersChange(event));
       }
 
       // Load static index if enabled
@@ -198,6 +247,7 @@ export class ServerInitializer {
   getResult(): InitializationResult {
     return {
       workspaceRoot: this.workspaceRoot,
+      workspaceRoots: this.workspaceRoots.paths(),
       hasConfigurationCapability: this.hasConfigurationCapability,
       hasWorkspaceFolderCapability: this.hasWorkspaceFolderCapability,
       importResolver: this.importResolver,
@@ -208,7 +258,67 @@ export class ServerInitializer {
   }
 
   /**
-   * Determine workspace root from initialization params.
+   * Workspace roots with their indexed file and symbol counts.
+   */
+  getWorkspaceRoots(): WorkspaceRootSummary[] {
+    const { backgroundIndex } = this.deps;
+    return this.workspaceRoots.summarize(backgroundIndex.getAllFileUris().map(uri => ({
+      uri,
+      symbols: backgroundIndex.getFileInfo(uri)?.symbolCount ?? 0
+    })));
+  }
+
+  /**
+   * Apply the changes to a workspace folder since a commit, as git reports
+   * them (see git/gitDelta.ts), without scanning the folder: deleted files
+   * and files no longer indexable are dropped, changed ones indexed.
+   * Throws when the folder is not in a git repository or the commit is
+   * unknown.
+   */
+  async updateSince(since: string, root: string | null = this.workspaceRoot): Promise<GitUpdateResult> {
+    const { connection, backgroundIndex, fileScanner, statsManager } = this.deps;
+    if (!root) {
+      throw new Error('No workspace folder to update');
+    }
+
+    const start = Date.now();
+    const delta = await gitChangesSince(root, since);
+    connection.console.info(
+      `[ServerInitializer] Changes in ${root} since ${delta.since}: ${delta.added.length} added, ` +
+      `${delta.modified.length} modified, ${delta.deleted.length} deleted`
+    );
+
+    const indexed = new Set(backgroundIndex.getAllFileUris());
+    const toIndex: string[] = [];
+    const toRemove = delta.deleted.filter(file => indexed.has(file));
+    for (const file of [...delta.added, ...delta.modified]) {
+      if (await fileScanner.isIndexable(file, root)) {
+        toIndex.push(file);
+      } else if (indexed.has(file)) {
+        toRemove.push(file);
+      }
+    }
+
+    for (const file of toRemove) {
+      await backgroundIndex.removeFile(file);
+    }
+    await this.indexFilesInBackground(toIndex, true);
+    statsManager.recordIncrementalIndex();
+    this.updateStats();
+
+    return {
+      root,
+      since: delta.since,
+      head: delta.head,
+      indexed: toIndex.length,
+      removed: toRemove.length,
+      duration: Date.now() - start
+    };
+  }
+
+  /**
+   * Determine workspace root from initialization params, recording every
+   * workspace folder as a root.
    */
   private determineWorkspaceRoot(params: InitializeParams): string {
     const { connection, logger } = this.deps;
@@ -217,6 +327,7 @@ export class ServerInitializer {
       connection.console.info(`[ServerInitializer] Workspace folders (${params.workspaceFolders.length}):`);
       params.workspaceFolders.forEach((folder, idx) => {
         connection.console.info(`  [${idx}] ${folder.uri} -> ${URI.parse(folder.uri).fsPath}`);
+        this.workspaceRoots.add(URI.parse(folder.uri).fsPath, folder.name);
       });
       const root = URI.parse(params.workspaceFolders[0].uri).fsPath;
       connection.console.info(`[ServerInitializer] Selected workspace root: ${root}`);
@@ -224,9 +335,11 @@ export class ServerInitializer {
     } else if (params.rootUri) {
       const root = URI.parse(params.rootUri).fsPath;
       connection.console.info(`[ServerInitializer] Using rootUri: ${root}`);
+      this.workspaceRoots.add(root);
       return root;
     } else if (params.rootPath) {
       connection.console.info(`[ServerInitializer] Using rootPath: ${params.rootPath}`);
+      this.workspaceRoots.add(params.rootPath);
       return params.rootPath;
     } else {
       logger.warn('[ServerInitializer] No workspace root found - indexing will be disabled');
@@ -234,6 +347,33 @@ export class ServerInitializer {
     }
   }
 
+  /**
+   * Lay the workspace config file (smart-indexer.yaml) under the editor
+   * settings. An invalid file is reported and ignored, and so are the
+   * settings it may only set when the user trusts it.
+   */
+  private loadConfigFile(trusted: boolean): void {
+    const { connection, configManager, logger } = this.deps;
+    try {
+      const loaded = configManager.setConfigFile(findConfigFile(this.workspaceRoot), undefined, trusted);
+      if (loaded) {
+        connection.console.info(
+          `[ServerInitializer] Using config file ${loaded.path}${loaded.profile ? ` (profile "${loaded.profile}")` : ''}`
+        );
+        if (loaded.ignoredSettings.length > 0) {
+          const message = `ignoring ${loaded.ignoredSettings.join(', ')} in ${path.basename(loaded.path)}; ` +
+            'set them in your settings, or enable smartIndexer.trustConfigFile';
+          logger.warn(`[ServerInitializer] Untrusted config file: ${message}`);
+          connection.window.showWarningMessage(`Smart Indexer: ${message}.`);
+        }
+      }
+    } catch (error) {
+      const message = error instanceof Error ? error.message : String(error);
+      logger.error(`[ServerInitializer] Ignoring config file: ${message}`);
+      connection.window.showWarningMessage(`Smart Indexer: ignoring config file. ${message}`);
+    }
+  }
+
   /**
    * Load static index if enabled in configuration.
    */
@@ -332,45 +472,57 @@ export class ServerInitializer {
         return;
       }
 
-      if (config.enableGitIntegration) {
-        await gitWatcher.init(this.workspaceRoot);
-        const isRepo = await gitWatcher.isRepository();
-
-        if (isRepo) {
-          connection.console.info('[ServerInitializer] Git repository detected, performing incremental indexing...');
-          await this.performGitAwareIndexing();
-
-          // Watch for git changes
-          gitWatcher.watchForChanges(async (gitChanges) => {
-            connection.console.info('[ServerInitializer] Git HEAD changed, reindexing affected files...');
-            try {
-              for (const file of gitChanges.deleted) {
-                await backgroundIndex.removeFile(file);
-              }
-              const filesToIndex = [...gitChanges.added, ...gitChanges.modified];
-              if (filesToIndex.length > 0) {
-                await this.indexFilesInBackground(filesToIndex);
-                statsManager.recordIncrementalIndex();
+      try {
+        if (this.workspaceRoots.size > 1) {
+          connection.console.info(
+            `[ServerInitializer] Multi-root workspace (${this.workspaceRoots.size} folders), performing full background indexing`
+          );
+          await this.performFullBackgroundIndexing();
+        } else if (config.enableGitIntegration) {
+          await gitWatcher.init(this.workspaceRoot);
+          const isRepo = await gitWatcher.isRepository();
+
+          if (isRepo) {
+            connection.console.info('[ServerInitializer] Git repository detected, performing incremental indexing...');
+            await this.performGitAwareIndexing();
+
+            // Watch for git changes
+            gitWatcher.watchForChanges(async (gitChanges) => {
+              connection.console.info('[ServerInitializer] Git HEAD changed, reindexing affected files...');
+              try {
+                for (const file of gitChanges.deleted) {
+                  await backgroundIndex.removeFile(file);
+                }
+                const filesToIndex = [...gitChanges.added, ...gitChanges.modified];
+                if (filesToIndex.length > 0) {
+                  await this.indexFilesInBackground(filesToIndex, true);
+                  statsManager.recordIncrementalIndex();
+                }
+                
+                await this.saveMetadata({
+                  version: 1,
+                  lastGitHash: gitChanges.currentHash,
+                  lastUpdatedAt: Date.now()
+                });
+                
+                this.updateStats();
+              } catch (error) {
+                logger.error(`[ServerInitializer] Error handling git changes: ${error}`);
               }
-              
-              await this.saveMetadata({
-                version: 1,
-                lastGitHash: gitChanges.currentHash,
-                lastUpdatedAt: Date.now()
-              });
-              
-              this.updateStats();
-            } catch (error) {
-              logger.error(`[ServerInitializer] Error handling git changes: ${error}`);
-            }
-          });
+            });
+          } else {
+            connection.console.info('[ServerInitializer] Not a Git repository, performing full background indexing');
+            await this.performFullBackgroundIndexing();
+          }
         } else {
-          connection.console.info('[ServerInitializer] Not a Git repository, performing full background indexing');
+          connection.console.info('[ServerInitializer] Git integration disabled, performing full background indexing');
           await this.performFullBackgroundIndexing();
         }
-      } else {
-        connection.console.info('[ServerInitializer] Git integration disabled, performing full background indexing');
-        await this.performFullBackgroundIndexing();
+      } catch (error) {
+        if (!(error instanceof CancellationError)) {
+          throw error;
+        }
+        connection.console.warn('[ServerInitializer] Indexing cancelled; remaining files will be indexed on the next run');
       }
 
       this.updateStats();
@@ -385,7 +537,7 @@ export class ServerInitializer {
         documents,
         backgroundIndex,
         configManager,
-        this.workspaceRoot,
+        this.workspaceRoots.paths(),
         logger,
         600 // 600ms debounce delay
       );
@@ -441,7 +593,7 @@ export class ServerInitializer {
           const filesToIndex = [...changes.added, ...changes.modified];
           if (filesToIndex.length > 0) {
             connection.console.info(`[ServerInitializer] Indexing ${filesToIndex.length} changed files...`);
-            await this.indexFilesInBackground(filesToIndex);
+            await this.indexFilesInBackground(filesToIndex, true);
             
             const incrementalDuration = Date.now() - incrementalStart;
             profiler.record('incrementalIndex', incrementalDuration);
@@ -470,7 +622,7 @@ export class ServerInitializer {
    * Perform full workspace indexing.
    */
   private async performFullBackgroundIndexing(): Promise<void> {
-    const { connection, fileScanner, statsManager, logger } = this.deps;
+    const { connection, fileScanner, configManager, statsManager, logger } = this.deps;
 
     if (!this.workspaceRoot) {
       logger.warn('[ServerInitializer] No workspace root available - cannot perform full indexing');
@@ -478,17 +630,39 @@ export class ServerInitializer {
     }
 
     try {
+      await this.pullRemoteIndexIfEmpty();
       connection.console.info('[ServerInitializer] Starting full workspace background indexing...');
-      const allFiles = await fileScanner.scanWorkspace(this.workspaceRoot, true); // Skip folder hash optimization for full scan
+      const allFiles: string[] = [];
+      for (const root of this.workspaceRoots.paths()) {
+        allFiles.push(...await fileScanner.scanWorkspace(root, true)); // Skip folder hash optimization for full scan
+      }
       connection.console.info(`[ServerInitializer] File scanner discovered ${allFiles.length} indexable files`);
+
+      if (configManager.getConfig().goIncludeDependencies) {
+        const dependencyFiles = await collectGoDependencyFiles(allFiles);
+        connection.console.info(`[ServerInitializer] Including ${dependencyFiles.length} Go dependency files from the module cache`);
+        allFiles.push(...dependencyFiles);
+      }
+
+      const bazel = configManager.getBazelConfig();
+      if (bazel.enabled && bazel.generatedSources) {
+        const generatedFiles = await collectBazelGeneratedFiles(allFiles);
+        connection.console.info(`[ServerInitializer] Including ${generatedFiles.length} generated Go files of Bazel targets`);
+        allFiles.push(...generatedFiles);
+      }
       
       if (allFiles.length === 0) {
         logger.warn('[ServerInitializer] No files found to index. Check excludePatterns and file extensions.');
         return;
       }
 
+      // One call for all roots: ensureUpToDate drops indexed files it is not given
       await this.indexFilesInBackground(allFiles);
       statsManager.recordFullIndex();
+      const indexedAt = Date.now();
+      for (const root of this.workspaceRoots.paths()) {
+        this.workspaceRoots.markIndexed(root, indexedAt);
+      }
     } catch (error) {
       logger.error(`[ServerInitializer] Error performing full background indexing: ${error}`);
       if (error instanceof Error) {
@@ -499,10 +673,78 @@ export class ServerInitializer {
   }
 
   /**
-   * Index files in background with worker pool.
+   * Seed an empty index from the configured remote index, so the full
+   * indexing that follows only parses files changed since it was built.
+   * A failed pull is logged and indexing goes on without it.
    */
-  private async indexFilesInBackground(files: string[]): Promise<void> {
-    const { connection, backgroundIndex, profiler, statsManager, fileSystem, logger } = this.deps;
+  private async pullRemoteIndexIfEmpty(): Promise<void> {
+    const { connection, configManager, backgroundIndex, logger } = this.deps;
+    const { url, pullOnStartup } = configManager.getRemoteIndexConfig();
+    if (!url || !pullOnStartup || backgroundIndex.getAllFileUris().length > 0) {
+      return;
+    }
+
+    try {
+      connection.console.info(`[ServerInitializer] Pulling prebuilt index from ${url}...`);
+      const roots = this.workspaceRoots.list();
+      const result = await new RemoteIndexSync(backgroundIndex).pull(url, roots, {
+        keys: indexKeysFor(configManager.getEncryptionConfig(), { cwd: roots[0]?.path })
+      });
+      connection.console.info(
+        `[ServerInitializer] Pulled ${result.imported} of ${result.files} files from ${url} ` +
+        `(${result.changed} changed locally, ${result.missing} missing, ${result.outside} outside the workspace)`
+      );
+    } catch (error) {
+      logger.warn(`[ServerInitializer] Could not pull the index from ${url}, indexing locally: ${error}`);
+    }
+  }
+
+  /**
+   * Drop the files of folders removed from the workspace (unless another
+   * root holds them) and index folders added to it, by indexing all roots
+   * again with unchanged files skipped.
+   */
+  private async handleWorkspaceFoldersChange(event: WorkspaceFoldersChangeEvent): Promise<void> {
+    const { connection, configManager, backgroundIndex, logger } = this.deps;
+
+    try {
+      for (const folder of event.removed) {
+        const root = URI.parse(folder.uri).fsPath;
+        this.workspaceRoots.remove(root);
+        this.fileWatcher?.removeRoot(root);
+        const dropped = backgroundIndex.getAllFileUris().filter(uri => isWithinRoot(root, uri) && !this.workspaceRoots.rootOf(uri));
+        for (const uri of dropped) {
+          await backgroundIndex.removeFile(uri);
+        }
+        connection.console.info(`[ServerInitializer] Workspace folder removed: ${root} (${dropped.length} files dropped)`);
+      }
+      for (const folder of event.added) {
+        const root = URI.parse(folder.uri).fsPath;
+        this.workspaceRoots.add(root, folder.name);
+        this.fileWatcher?.addRoot(root);
+        connection.console.info(`[ServerInitializer] Workspace folder added: ${root}`);
+      }
+      configManager.setWorkspaceRoots(this.workspaceRoots.paths());
+
+      if (event.added.length > 0 && this.workspaceRoot && configManager.getConfig().enableBackgroundIndex) {
+        await this.performFullBackgroundIndexing();
+      }
+      this.updateStats();
+    } catch (error) {
+      if (error instanceof CancellationError) {
+        connection.console.warn('[ServerInitializer] Indexing cancelled; remaining files will be indexed on the next run');
+        return;
+      }
+      logger.error(`[ServerInitializer] Error updating workspace folders: ${error}`);
+    }
+  }
+
+  /**
+   * Index files in background with worker pool. Without incremental,
+   * files is the whole workspace and indexed files not in it are dropped.
+   */
+  private async indexFilesInBackground(files: string[], incremental: boolean = false): Promise<void> {
+    const { connection, backgroundIndex, configManager, profiler, statsManager, fileSystem, logger } = this.deps;
 
     if (files.length === 0) {
       return;
@@ -517,17 +759,34 @@ export class ServerInitializer {
       const progress = await connection.window.createWorkDoneProgress();
       progress.begin('Indexing files', 0, `0/${files.length}`, true);
 
+      // Cancelled from the progress notification, on shutdown, or after the configured timeout
+      const cancellation = new CancellationTokenSource(progress.token);
+      const timeoutMinutes = configManager.getConfig().indexingTimeoutMinutes;
+      if (timeoutMinutes > 0) {
+        cancellation.cancelAfter(timeoutMinutes * 60 * 1000);
+      }
+      this.activeIndexing.add(cancellation);
+
       const computeHash = async (uri: string): Promise<string> => {
         const content = await fileSystem.readFile(uri);
         return crypto.createHash('sha256').update(content).digest('hex');
       };
 
       // Use BackgroundIndex's built-in worker pool
-      await backgroundIndex.ensureUpToDate(files, computeHash, (current, total) => {
-        progress.report((current / total) * 100, `${current}/${total}`);
-      });
-
-      progress.done();
+      try {
+        const onProgress = (current: number, total: number) => {
+          progress.report((current / total) * 100, formatProgress(backgroundIndex.getIndexingProgress()));
+        };
+        if (incremental) {
+          await backgroundIndex.updateFiles(files, computeHash, onProgress, cancellation.token);
+        } else {
+          await backgroundIndex.ensureUpToDate(files, computeHash, onProgress, cancellation.token);
+        }
+      } finally {
+        this.activeIndexing.delete(cancellation);
+        cancellation.dispose();
+        progress.done();
+      }
 
       // Record profiling metrics
       const fullIndexDuration = Date.now() - fullIndexStart;
@@ -547,6 +806,10 @@ export class ServerInitializer {
       // Simple auto-tuning based on performance
       this.autoTuneIndexing(fullIndexDuration, files.length);
     } catch (error) {
+      if (error instanceof CancellationError) {
+        connection.console.warn(`[ServerInitializer] Background indexing cancelled after ${Date.now() - fullIndexStart}ms`);
+        throw error;
+      }
       logger.error(`[ServerInitializer] Error in background indexing: ${error}`);
       if (error instanceof Error) {
         logger.error(`[ServerInitializer] Stack trace: ${error.stack}`);
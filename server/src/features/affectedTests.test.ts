@@ -0,0 +1,173 @@
+/**
+ * Affected Tests Tests
+ *
+ * Selects the Go tests a changeset affects from a throwaway git
+ * repository: tests reaching changed symbols through callers, changed
+ * tests, whole packages for init and testdata changes, everything for
+ * go.mod changes.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { AffectedTests } from './affectedTests.js';
+import { GoTestIndex } from './goTests.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { GitRunner } from '../git/gitDelta.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const storeGo = `package store
+
+type Store struct{ items map[string]string }
+
+func (s *Store) Get(key string) string {
+	return s.items[key]
+}
+
+func (s *Store) Put(key, value string) {
+	s.items[key] = value
+}
+`;
+
+const storeTestGo = `package store
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	s := &Store{items: map[string]string{"a": "b"}}
+	if s.Get("a") != "b" {
+		t.Fatal("get")
+	}
+}
+
+func TestPut(t *testing.T) {
+	s := &Store{items: map[string]string{}}
+	s.Put("a", "b")
+}
+`;
+
+const apiGo = `package api
+
+import "example.com/app/store"
+
+func Lookup(s *store.Store, key string) string {
+	return s.Get(key)
+}
+`;
+
+const apiTestGo = `package api
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	_ = Lookup(nil, "a")
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = Lookup(nil, "a")
+	}
+}
+`;
+
+describe('AffectedTests', () => {
+  let root: string;
+  let index: MockBackgroundIndex;
+  let affected: AffectedTests;
+  const goIndexer = new GoIndexer();
+
+  function git(args: string[]): string {
+    return execFileSync('git', args, {
+      cwd: root,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com',
+        GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com'
+      }
+    });
+  }
+
+  const runGit: GitRunner = async args => git(args);
+
+  /** Write a file and index it when it is Go */
+  function write(file: string, content: string): void {
+    const filePath = path.join(root, file);
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, content);
+    if (file.endsWith('.go')) {
+      const result = goIndexer.indexFile(filePath, content);
+      index.addFile(filePath, result.symbols, result.references, { imports: result.imports, hash: String(Date.now() + Math.random()) });
+    }
+  }
+
+  async function find(options: { maxDepth?: number } = {}) {
+    const tests = new GoTestIndex(index.asBackgroundIndex());
+    await tests.build();
+    affected = new AffectedTests(tests, index.asBackgroundIndex(), root, runGit);
+    return affected.find({ since: 'base', ...options });
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'affected-tests-'));
+    index = new MockBackgroundIndex();
+    git(['init', '-q']);
+    write('go.mod', 'module example.com/app\n');
+    write('store/store.go', storeGo);
+    write('store/store_test.go', storeTestGo);
+    write('api/api.go', apiGo);
+    write('api/api_test.go', apiTestGo);
+    git(['add', '.']);
+    git(['commit', '-q', '-m', 'base']);
+    git(['tag', 'base']);
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should select the tests reaching a changed function, across packages', async () => {
+    write('store/store.go', storeGo.replace('return s.items[key]', 'v := s.items[key]\n\treturn v'));
+
+    const report = await find();
+
+    expect(report.changed.map(symbol => symbol.name)).toEqual(['Store.Get']);
+    expect(report.tests.map(test => [test.name, test.via])).toEqual([
+      ['TestGet', []],
+      ['TestLookup', ['Lookup']],
+      ['BenchmarkLookup', ['Lookup']]
+    ]);
+    expect(report.commands).toEqual([
+      "go test ./api -run '^(TestLookup)$'",
+      "go test ./api -run '^$' -bench '^(BenchmarkLookup)$'",
+      "go test ./store -run '^(TestGet)$'"
+    ]);
+    expect(report).toMatchObject({ files: 1, all: false, head: git(['rev-parse', 'HEAD']).trim() });
+    expect((await find({ maxDepth: 0 })).tests.map(test => test.name)).toEqual(['TestGet']);
+  });
+
+  it('should select changed tests and whole packages for init and testdata changes', async () => {
+    write('store/store_test.go', storeTestGo.replace('s.Put("a", "b")', 's.Put("a", "c")'));
+    write('api/init.go', 'package api\n\nfunc init() {}\n');
+    write('store/testdata/items.json', '{}\n');
+    git(['add', '.']);
+    git(['commit', '-q', '-m', 'change']);
+
+    const report = await find();
+
+    expect(report.tests.map(test => test.name)).toEqual(['TestPut']);
+    expect(report.packages.map(pkg => [pkg.dir, pkg.all])).toEqual([['./api', true], ['./store', true]]);
+    expect(report.commands).toEqual(['go test ./api', 'go test ./store']);
+  });
+
+  it('should run everything when go.mod changes and nothing for comment-only changes', async () => {
+    write('store/store.go', storeGo.replace('package store\n', 'package store\n\n// Package store keeps items.\n'));
+    expect(await find()).toMatchObject({ changed: [], tests: [], commands: [] });
+
+    write('go.mod', 'module example.com/app\n\ngo 1.22\n');
+    expect(await find()).toMatchObject({ all: true, commands: ['go test ./...'] });
+  });
+});
@@ -0,0 +1,193 @@
+import * as path from 'path';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { GoSymbolMetadata, GoTestKind } from '../indexer/goIndexer.js';
+import { ChangedLineRange, GitRunner, gitChangedLinesSince } from '../git/gitDelta.js';
+import { GoTestFunction, GoTestIndex, GoTestPackage, goTestPackages, goTestTarget, sortTests } from './goTests.js';
+import {
+  CancellationToken,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface AffectedTestsQuery {
+  /** The commit to compare the working tree with, e.g. `HEAD~1` or `origin/main` */
+  since: string;
+  /** Functions followed between a test and a changed symbol (default: unlimited) */
+  maxDepth?: number;
+  /** Only these kinds of test functions (default: all) */
+  kinds?: GoTestKind[];
+  /** Cancellation token for aborting the lookup */
+  cancellationToken?: CancellationToken;
+}
+
+export interface ChangedSymbol {
+  /** Qualified name, e.g. `Store.Get` */
+  name: string;
+  kind: string;
+  location: SymbolLocation;
+}
+
+export interface AffectedTestsReport {
+  /** The commit compared with, resolved to its hash */
+  since: string;
+  head: string;
+  /** Changed files, Go or not */
+  files: number;
+  /** Definitions on changed lines, added files included */
+  changed: ChangedSymbol[];
+  /** Fewest functions away from a change first; changed tests have an empty `via` */
+  tests: GoTestFunction[];
+  /** The tests per package folder; packages to run whole have `all` set */
+  packages: Array<GoTestPackage & { all?: boolean }>;
+  /** go.mod, go.sum or go.work changed, so every package may be affected */
+  all: boolean;
+  /** `go test` commands running the affected tests, `go test ./...` when all */
+  commands: string[];
+}
+
+/** Tests affected by a changeset; how servers expose it */
+export type AffectedTestsSource = (query: AffectedTestsQuery) => Promise<AffectedTestsReport>;
+
+/** The part of an index affected-test selection reads: the background index or the library Indexer */
+export interface AffectedTestsSourceIndex {
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+}
+
+const YIELD_INTERVAL = 50;
+
+/** Files whose change can affect every package of the module */
+const MODULE_FILES = new Set(['go.mod', 'go.sum', 'go.work', 'go.work.sum']);
+
+/** Functions that run before every test of their package */
+const PACKAGE_SETUP = new Set(['init', 'TestMain']);
+
+/**
+ * Affected Tests - the Go tests a changeset can break, for running only
+ * those in CI: `go test` commands from `git diff <since>`.
+ *
+ * The definitions on changed lines (every definition of an added file)
+ * are followed back through the reference graph of the GoTestIndex to
+ * the tests that reach them, with no depth limit by default. Changed
+ * tests are affected themselves. A changed `init` or `TestMain`, or a
+ * changed file under a `testdata` folder, affects every test of its
+ * package; a changed go.mod, go.sum or go.work affects all of them.
+ * Deleted files add nothing: code that used them changed too, or it no
+ * longer builds. Expects an up-to-date GoTestIndex.
+ */
+export class AffectedTests {
+  constructor(
+    private tests: GoTestIndex,
+    private index: AffectedTestsSourceIndex,
+    private root: string,
+    private runGit?: GitRunner
+  ) {}
+
+  async find(query: AffectedTestsQuery): Promise<AffectedTestsReport> {
+    const delta = await gitChangedLinesSince(this.root, query.since, this.runGit);
+    const kinds = query.kinds && query.kinds.length > 0 ? new Set(query.kinds) : undefined;
+
+    const changed: IndexedSymbol[] = [];
+    const wholePackages = new Set<string>();
+    let all = false;
+    for (let i = 0; i < delta.files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(query.cancellationToken);
+        await yieldToEventLoop();
+      }
+      const { file, status, ranges } = delta.files[i];
+      if (MODULE_FILES.has(path.basename(file))) {
+        all = true;
+        continue;
+      }
+      const testdata = testdataPackage(file);
+      if (testdata) {
+        wholePackages.add(testdata);
+        continue;
+      }
+      if (status === 'deleted' || path.extname(file) !== '.go') {
+        continue;
+      }
+      for (const symbol of await this.index.getFileSymbols(file)) {
+        if (symbol.isDefinition === false || symbol.kind === 'closure') {
+          continue;
+        }
+        if (status === 'added' || ranges.some(range => overlaps(symbol, range))) {
+          changed.push(symbol);
+          if (PACKAGE_SETUP.has(symbol.name) && !symbol.containerName) {
+            wholePackages.add(path.dirname(file));
+          }
+        }
+      }
+    }
+
+    // Keyed by file and name: the graph has no symbol ids for tests
+    const found = new Map<string, GoTestFunction>();
+    for (const symbol of changed) {
+      const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+      if (go?.testKind && (!kinds || kinds.has(go.testKind))) {
+        found.set(`${symbol.location.uri}:${symbol.name}`, {
+          name: symbol.name,
+          kind: go.testKind,
+          uri: symbol.location.uri,
+          package: go.package ?? '',
+          location: symbol.location,
+          via: []
+        });
+      }
+    }
+    const exercising = this.tests.testsExercising(changed, {
+      maxDepth: query.maxDepth ?? Infinity,
+      kinds: query.kinds,
+      cancellationToken: query.cancellationToken
+    });
+    for (const test of exercising) {
+      const key = `${test.uri}:${test.name}`;
+      if (!found.has(key)) {
+        found.set(key, test);
+      }
+    }
+    const tests = sortTests([...found.values()]);
+
+    const packages: Array<GoTestPackage & { all?: boolean }> = goTestPackages(tests, this.root);
+    for (const dir of wholePackages) {
+      const target = goTestTarget(dir, this.root);
+      const existing = packages.find(pkg => pkg.dir === target);
+      if (existing) {
+        existing.all = true;
+        existing.commands = [`go test ${target}`];
+      } else {
+        packages.push({ dir: target, tests: [], benchmarks: [], commands: [`go test ${target}`], all: true });
+      }
+    }
+    packages.sort((a, b) => (a.dir < b.dir ? -1 : a.dir > b.dir ? 1 : 0));
+
+    return {
+      since: delta.since,
+      head: delta.head,
+      files: delta.files.length,
+      changed: changed.map(symbol => ({
+        name: symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name,
+        kind: symbol.kind,
+        location: symbol.location
+      })),
+      tests,
+      packages,
+      all,
+      commands: all ? ['go test ./...'] : packages.flatMap(pkg => pkg.commands)
+    };
+  }
+}
+
+/**
+ * The package folder whose tests read a file under its `testdata`
+ * folder, e.g. `/ws/parser` for `/ws/parser/testdata/cases/a.json`.
+ */
+function testdataPackage(file: string): string | undefined {
+  const segments = file.split(path.sep);
+  const index = segments.lastIndexOf('testdata');
+  return index > 0 ? segments.slice(0, index).join(path.sep) : undefined;
+}
+
+function overlaps(symbol: IndexedSymbol, range: ChangedLineRange): boolean {
+  return symbol.range.startLine <= range.endLine && symbol.range.endLine >= range.startLine;
+}
@@ -0,0 +1,129 @@
+/**
+ * ApiSurface Tests
+ *
+ * Verifies the exported API lines of a Go module, what is left out of it,
+ * parameter names dropped from signatures, and module version selection.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ApiSurface, formatApiSurface, signatureTypes } from './apiSurface.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { annotateGoModule, GoModuleInfo } from '../indexer/goModules.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const userGo = `package user
+
+// Person is a registered user.
+type Person struct {
+	Name string \`json:"name"\`
+	age  int
+	io.Reader
+}
+
+type ID int64
+
+type Store interface {
+	Get(id ID) (*Person, error)
+}
+
+const MaxUsers = 10
+
+var ErrMissing error
+
+func NewPerson(name string, opts ...Option) *Person { return nil }
+
+func (p *Person) Rename(first, last string) {}
+
+func Keys[K comparable, V any](m map[K]V) []K { return nil }
+
+type person struct{}
+
+func (p person) Hidden() {}
+
+func helper() {}
+`;
+
+const windowsOnlyGo = `//go:build windows
+
+package fsutil
+
+func Open(name string) error { return nil }
+`;
+
+describe('ApiSurface', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+  const workspace: GoModuleInfo = { module: 'example.com/acme', dir: '/ws', thirdParty: false };
+
+  function add(uri: string, content: string, module: GoModuleInfo = workspace): void {
+    const result = goIndexer.indexFile(uri, content);
+    annotateGoModule(result.symbols, uri, module);
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    add('/ws/user/user.go', userGo);
+    add('/ws/user/user_test.go', 'package user\n\nfunc TestRename(t *testing.T) {}\n\nfunc Fixture() Person { return Person{} }\n');
+    add('/ws/internal/db/db.go', 'package db\n\nfunc Open() {}\n');
+    add('/ws/cmd/server/main.go', 'package main\n\nfunc Run() {}\n');
+    add('/ws/fsutil/open.go', windowsOnlyGo);
+  });
+
+  it('should list exported declarations and members one per line, sorted', async () => {
+    const report = await new ApiSurface(index.asBackgroundIndex()).build('example.com/acme');
+
+    expect(report.packages).toEqual(['example.com/acme/fsutil', 'example.com/acme/user']);
+    expect(formatApiSurface(report)).toBe([
+      'pkg example.com/acme/fsutil (windows), func Open(string) error',
+      'pkg example.com/acme/user, const MaxUsers = 10',
+      'pkg example.com/acme/user, func Keys[K comparable, V any](map[K]V) []K',
+      'pkg example.com/acme/user, func NewPerson(string, ...Option) *Person',
+      'pkg example.com/acme/user, method (*Person) Rename(string, string)',
+      'pkg example.com/acme/user, type ID int64',
+      'pkg example.com/acme/user, type Person struct',
+      'pkg example.com/acme/user, type Person struct, Name string',
+      'pkg example.com/acme/user, type Person struct, embedded io.Reader',
+      'pkg example.com/acme/user, type Store interface',
+      'pkg example.com/acme/user, type Store interface, Get(ID) (*Person, error)',
+      'pkg example.com/acme/user, var ErrMissing error',
+      ''
+    ].join('\n'));
+  });
+
+  it('should include internal packages when asked', async () => {
+    const report = await new ApiSurface(index.asBackgroundIndex()).build('example.com/acme', { includeInternal: true });
+
+    expect(report.features).toContain('pkg example.com/acme/internal/db, func Open()');
+    expect(report.features.some(feature => feature.includes('cmd/server'))).toBe(false);
+  });
+
+  it('should pick a module version and reject unknown or ambiguous modules', async () => {
+    const uuid = (version: string): GoModuleInfo => ({
+      module: 'github.com/google/uuid', version, dir: `/gopath/pkg/mod/github.com/google/uuid@${version}`, thirdParty: true
+    });
+    add('/gopath/pkg/mod/github.com/google/uuid@v1.5.0/uuid.go', 'package uuid\n\nfunc New() UUID { return UUID{} }\n', uuid('v1.5.0'));
+    add('/gopath/pkg/mod/github.com/google/uuid@v1.6.0/uuid.go', 'package uuid\n\nfunc New() UUID { return UUID{} }\n\nfunc NewV7() (UUID, error) { return UUID{}, nil }\n', uuid('v1.6.0'));
+    const surface = new ApiSurface(index.asBackgroundIndex());
+
+    expect(await surface.modules()).toEqual([
+      { module: 'example.com/acme', thirdParty: false },
+      { module: 'github.com/google/uuid', version: 'v1.5.0', thirdParty: true },
+      { module: 'github.com/google/uuid', version: 'v1.6.0', thirdParty: true }
+    ]);
+    const report = await surface.build('github.com/google/uuid@v1.6.0');
+    expect([report.version, report.features]).toEqual(['v1.6.0', [
+      'pkg github.com/google/uuid, func New() UUID',
+      'pkg github.com/google/uuid, func NewV7() (UUID, error)'
+    ]]);
+    await expect(surface.build('github.com/google/uuid')).rejects.toThrow('indexed in several versions (v1.5.0, v1.6.0)');
+    await expect(surface.build('example.com/other')).rejects.toThrow('Unknown module: example.com/other');
+  });
+
+  it('should drop parameter names from signatures', () => {
+    expect(signatureTypes('func(a, b int, c string) (n int, err error)')).toBe('(int, int, string) (int, error)');
+    expect(signatureTypes('func(fn func(x int) error, ch <-chan int)')).toBe('(func(int) error, <-chan int)');
+    expect(signatureTypes('func(int, chan string) (result bool)')).toBe('(int, chan string) bool');
+    expect(signatureTypes('func()')).toBe('()');
+  });
+});
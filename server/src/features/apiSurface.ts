@@ -0,0 +1,346 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { IndexedSymbol } from '../types.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** Index reads the API surface report needs */
+export type ApiSurfaceSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols'>;
+
+export interface ApiSurfaceOptions {
+  /** List internal packages too, which other modules cannot import (default: false) */
+  includeInternal?: boolean;
+  /** Cancellation token for aborting the report */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting file scanning progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ApiSurfaceReport {
+  module: string;
+  /** Version of a dependency module; unset for workspace modules */
+  version?: string;
+  /** Import paths of the packages with exported declarations, sorted */
+  packages: string[];
+  /**
+   * One line per exported declaration, method, field and interface
+   * method, sorted (see formatApiSurface)
+   */
+  features: string[];
+  filesScanned: number;
+}
+
+/** A Go module of the index */
+export interface IndexedGoModule {
+  module: string;
+  version?: string;
+  thirdParty: boolean;
+}
+
+const YIELD_INTERVAL = 50;
+
+const TYPE_KINDS = new Set(['struct', 'interface', 'type']);
+
+/** Words that start an unnamed parameter type with a space in it (`chan int`) */
+const TYPE_KEYWORDS = new Set(['chan', 'func', 'interface', 'map', 'struct']);
+
+interface PackageSymbols {
+  importPath: string;
+  symbols: Array<{ symbol: IndexedSymbol; go: GoSymbolMetadata }>;
+}
+
+/**
+ * API Surface - the exported API of a Go module as read from the index, in
+ * the line format of Go's api/*.txt files: one feature per line, prefixed
+ * with its package, sorted.
+ *
+ *   pkg example.com/acme/user, func NewPerson(string) *Person
+ *   pkg example.com/acme/user, method (*Person) Rename(string)
+ *   pkg example.com/acme/user, type Person struct, Name string
+ *
+ * Parameter names are left out of signatures, so renaming one is not an
+ * API change. Diffing two reports (`diff old.txt new.txt`) lists what was
+ * added and removed; a changed signature shows as one of each. Test
+ * files, main packages and, unless asked for, internal packages are not
+ * part of the API. Features of files with a build constraint name it
+ * after the package: `pkg example.com/acme/fsutil (windows), func Open(string) error`.
+ */
+export class ApiSurface {
+  constructor(private index: ApiSurfaceSourceIndex) {}
+
+  /**
+   * Go modules with indexed files, workspace modules first.
+   */
+  async modules(): Promise<IndexedGoModule[]> {
+    const modules = new Map<string, IndexedGoModule>();
+    for (const uri of await this.index.getAllFiles()) {
+      if (!uri.endsWith('.go')) {
+        continue;
+      }
+      const go = (await this.index.getFileSymbols(uri)).find(symbol => symbol.metadata?.go)?.metadata?.go as GoSymbolMetadata | undefined;
+      if (go?.module) {
+        const key = `${go.module}@${go.moduleVersion ?? ''}`;
+        modules.set(key, { module: go.module, ...(go.moduleVersion && { version: go.moduleVersion }), thirdParty: go.thirdParty === true });
+      }
+    }
+    return [...modules.values()].sort((a, b) =>
+      Number(a.thirdParty) - Number(b.thirdParty) || a.module.localeCompare(b.module) || (a.version ?? '').localeCompare(b.version ?? ''));
+  }
+
+  /**
+   * The exported API of a module, given as its path or `path@version`.
+   * Throws if no indexed file belongs to the module, or if it is indexed
+   * in several versions and none was given.
+   */
+  async build(moduleSpec: string, options: ApiSurfaceOptions = {}): Promise<ApiSurfaceReport> {
+    const { cancellationToken, onProgress } = options;
+    const at = moduleSpec.lastIndexOf('@');
+    const module = at > 0 ? moduleSpec.substring(0, at) : moduleSpec;
+    const requestedVersion = at > 0 ? moduleSpec.substring(at + 1) : undefined;
+
+    const files = (await this.index.getAllFiles()).filter(uri => uri.endsWith('.go')).sort();
+    const packages = new Map<string, PackageSymbols>();
+    const versions = new Set<string>();
+    let filesScanned = 0;
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Reading exported API (${i}/${files.length})`);
+      }
+      const symbols = await this.index.getFileSymbols(files[i]);
+      const first = symbols.find(symbol => symbol.metadata?.go)?.metadata?.go as GoSymbolMetadata | undefined;
+      if (first?.module !== module || !first.importPath) {
+        continue;
+      }
+      if (requestedVersion !== undefined && (first.moduleVersion ?? '') !== requestedVersion) {
+        continue;
+      }
+      versions.add(first.moduleVersion ?? '');
+      filesScanned++;
+      if (!isApiFile(files[i], symbols, first, options.includeInternal === true)) {
+        continue;
+      }
+
+      const key = `${first.importPath}\0${first.moduleVersion ?? ''}`;
+      let entry = packages.get(key);
+      if (!entry) {
+        entry = { importPath: first.importPath, symbols: [] };
+        packages.set(key, entry);
+      }
+      for (const symbol of symbols) {
+        const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+        if (go && symbol.isDefinition !== false && !go.assembly) {
+          entry.symbols.push({ symbol, go });
+        }
+      }
+    }
+
+    if (versions.size === 0) {
+      throw new Error(`Unknown module: ${moduleSpec}`);
+    }
+    if (versions.size > 1) {
+      const listed = [...versions].map(version => version || '(workspace)').sort().join(', ');
+      throw new Error(`Module ${module} is indexed in several versions (${listed}); pass ${module}@<version>`);
+    }
+
+    const features = new Set<string>();
+    const importPaths = new Set<string>();
+    for (const pkg of packages.values()) {
+      const before = features.size;
+      for (const feature of packageFeatures(pkg)) {
+        features.add(feature);
+      }
+      if (features.size > before) {
+        importPaths.add(pkg.importPath);
+      }
+    }
+
+    const version = [...versions][0];
+    return {
+      module,
+      ...(version && { version }),
+      packages: [...importPaths].sort(),
+      features: [...features].sort(),
+      filesScanned
+    };
+  }
+}
+
+/**
+ * The report as text, one feature per line, for committing and diffing.
+ */
+export function formatApiSurface(report: ApiSurfaceReport): string {
+  return report.features.map(feature => feature + '\n').join('');
+}
+
+function isApiFile(uri: string, symbols: IndexedSymbol[], go: GoSymbolMetadata, includeInternal: boolean): boolean {
+  if (uri.endsWith('_test.go') || go.package === 'main' || symbols.some(symbol => symbol.tags?.includes('test'))) {
+    return false;
+  }
+  return includeInternal || !/(^|\/)internal(\/|$)/.test(go.importPath ?? '');
+}
+
+/**
+ * Features of one package. Members count only when their type is an
+ * exported type of the package, wherever the type is declared.
+ */
+function packageFeatures(pkg: PackageSymbols): string[] {
+  const types = new Map<string, IndexedSymbol>();
+  for (const { symbol } of pkg.symbols) {
+    if (!symbol.containerName && TYPE_KINDS.has(symbol.kind) && symbol.isExported === true) {
+      types.set(symbol.name, symbol);
+    }
+  }
+
+  const features: string[] = [];
+  for (const { symbol, go } of pkg.symbols) {
+    const prefix = `pkg ${pkg.importPath}${go.buildConstraint ? ` (${go.buildConstraint})` : ''}, `;
+    const feature = featureOf(symbol, go, types);
+    if (feature) {
+      features.push(prefix + feature);
+    }
+  }
+  return features;
+}
+
+function featureOf(symbol: IndexedSymbol, go: GoSymbolMetadata, types: Map<string, IndexedSymbol>): string | undefined {
+  if (symbol.isExported !== true) {
+    return undefined;
+  }
+  if (symbol.containerName) {
+    const owner = types.get(go.receiverType ?? symbol.containerName);
+    if (!owner) {
+      return undefined;
+    }
+    const ownerHeader = `type ${owner.name}${typeParameterNames(owner)} ${owner.kind}`;
+    if (symbol.kind === 'field') {
+      return go.embedded ? `${ownerHeader}, embedded ${go.type ?? symbol.name}` : `${ownerHeader}, ${symbol.name} ${go.type ?? ''}`.trimEnd();
+    }
+    if (symbol.kind === 'method' && go.receiverType) {
+      const receiver = `${go.pointerReceiver ? '*' : ''}${owner.name}${typeParameterNames(owner)}`;
+      return `method (${receiver}) ${symbol.name}${signatureTypes(symbol.signature)}`;
+    }
+    if (symbol.kind === 'method') {
+      return `${ownerHeader}, ${symbol.name}${signatureTypes(symbol.signature)}`;
+    }
+    return undefined;
+  }
+
+  switch (symbol.kind) {
+    case 'function':
+      return `func ${symbol.name}${typeParameterList(symbol)}${signatureTypes(symbol.signature)}`;
+    case 'constant':
+      return ['const', symbol.name, go.type, symbol.value !== undefined ? `= ${symbol.value}` : undefined].filter(Boolean).join(' ');
+    case 'variable':
+      return ['var', symbol.name, go.type].filter(Boolean).join(' ');
+    case 'struct':
+    case 'interface':
+      return `type ${symbol.name}${typeParameterList(symbol)} ${symbol.kind}`;
+    case 'type':
+      return `type ${symbol.name}${typeParameterList(symbol)} ${go.alias ? '= ' : ''}${go.underlying ?? ''}`.trimEnd();
+    default:
+      return undefined;
+  }
+}
+
+/** `[K comparable, V any]` of a generic declaration */
+function typeParameterList(symbol: IndexedSymbol): string {
+  const params = symbol.typeParameters ?? [];
+  return params.length > 0 ? `[${params.map(param => `${param.name} ${param.constraint}`).join(', ')}]` : '';
+}
+
+/** `[K, V]` of a generic type, as it appears in receivers */
+function typeParameterNames(symbol: IndexedSymbol): string {
+  const params = symbol.typeParameters ?? [];
+  return params.length > 0 ? `[${params.map(param => param.name).join(', ')}]` : '';
+}
+
+/**
+ * Parameters and results of a `func(...)` signature without their names:
+ * `func(name string, opts ...Option) (p *Person, err error)` becomes
+ * `(string, ...Option) (*Person, error)`.
+ */
+export function signatureTypes(signature: string | undefined): string {
+  if (!signature) {
+    return '()';
+  }
+  const text = signature.trim().replace(/^func\s*/, '');
+  const close = matchingParen(text, 0);
+  if (!text.startsWith('(') || close < 0) {
+    return text;
+  }
+  const params = `(${parameterTypes(text.substring(1, close)).join(', ')})`;
+  const results = text.substring(close + 1).trim();
+  if (!results) {
+    return params;
+  }
+  if (results.startsWith('(') && matchingParen(results, 0) === results.length - 1) {
+    const types = parameterTypes(results.substring(1, results.length - 1));
+    return `${params} ${types.length === 1 ? types[0] : `(${types.join(', ')})`}`;
+  }
+  return `${params} ${unnamedFuncType(results)}`;
+}
+
+function parameterTypes(list: string): string[] {
+  const items = splitTopLevel(list);
+  const named = items.some(item => {
+    const match = /^([A-Za-z_]\w*)\s+\S/.exec(item);
+    return match !== null && !TYPE_KEYWORDS.has(match[1]);
+  });
+  if (!named) {
+    return items.map(unnamedFuncType);
+  }
+  // `a, b int`: names without a type take the type of the next parameter
+  const types: string[] = new Array(items.length);
+  let pending = '';
+  for (let i = items.length - 1; i >= 0; i--) {
+    const match = /^[A-Za-z_]\w*\s+(.+)$/s.exec(items[i]);
+    if (match) {
+      pending = unnamedFuncType(match[1].trim());
+    }
+    types[i] = pending;
+  }
+  return types;
+}
+
+/** Parameter names of a func type inside a signature go too */
+function unnamedFuncType(type: string): string {
+  return /^func\s*\(/.test(type) ? `func${signatureTypes(type)}` : type;
+}
+
+function splitTopLevel(list: string): string[] {
+  const items: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < list.length; i++) {
+    const c = list[i];
+    if (c === '(' || c === '[' || c === '{') {
+      depth++;
+    } else if (c === ')' || c === ']' || c === '}') {
+      depth--;
+    } else if (c === ',' && depth === 0) {
+      items.push(list.substring(start, i).trim());
+      start = i + 1;
+    }
+  }
+  items.push(list.substring(start).trim());
+  return items.filter(item => item.length > 0).map(item => item.replace(/\s+/g, ' '));
+}
+
+function matchingParen(text: string, open: number): number {
+  let depth = 0;
+  for (let i = open; i < text.length; i++) {
+    if (text[i] === '(') {
+      depth++;
+    } else if (text[i] === ')' && --depth === 0) {
+      return i;
+    }
+  }
+  return -1;
+}
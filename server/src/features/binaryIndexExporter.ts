@@ -0,0 +1,65 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { BinaryIndexCompression, BinaryIndexWriter } from '../index/binaryIndex.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+import { buildTimestamp } from '../utils/buildTimestamp.js';
+
+export interface BinaryIndexExportOptions {
+  /** Block compression of the written file (default none) */
+  compression?: BinaryIndexCompression;
+  /** Record build time 0 (see utils/buildTimestamp.ts) */
+  reproducible?: boolean;
+  /** Cancellation token for aborting the export */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting export progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface BinaryIndexExportResult {
+  outputPath: string;
+  files: number;
+  symbols: number;
+  bytes: number;
+}
+
+const YIELD_INTERVAL = 50;
+
+/**
+ * Exports the background index's symbols as a binary static index (.sidx),
+ * e.g. built once in CI and loaded by every checkout through
+ * `smartIndexer.staticIndex.path` without parsing hundreds of MB of JSON.
+ */
+export class BinaryIndexExporter {
+  constructor(private backgroundIndex: BackgroundIndex) {}
+
+  /**
+   * Write the binary index to outputPath.
+   */
+  async export(outputPath: string, options: BinaryIndexExportOptions = {}): Promise<BinaryIndexExportResult> {
+    const { compression, reproducible, cancellationToken, onProgress } = options;
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const writer = new BinaryIndexWriter();
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Encoding symbols (${i}/${files.length})`);
+      }
+      const symbols = await this.backgroundIndex.getFileSymbols(files[i]);
+      if (symbols.length > 0) {
+        writer.addFile(files[i], symbols);
+      }
+    }
+
+    throwIfCancelled(cancellationToken);
+    const stats = await writer.finish(outputPath, { compression, createdAt: buildTimestamp(reproducible) });
+
+    onProgress?.(files.length, files.length, 'Binary index export complete');
+    return { outputPath, ...stats };
+  }
+}
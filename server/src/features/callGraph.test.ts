@@ -0,0 +1,179 @@
+/**
+ * CallGraph Tests
+ *
+ * Verifies caller/callee edges built from indexed call sites.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { CallGraph } from './callGraph.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+
+const serviceGo = `package service
+
+func (s *Service) Save(name string) error {
+	if err := validate(name); err != nil {
+		return err
+	}
+	return s.repo.Insert(name)
+}
+
+func validate(name string) error {
+	return checkLength(name)
+}
+`;
+
+const helpersGo = `package service
+
+func checkLength(name string) error {
+	return nil
+}
+`;
+
+const repoGo = `package repo
+
+func (r *Repo) Insert(name string) error {
+	return nil
+}
+`;
+
+describe('CallGraph', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  function nodeId(graph: CallGraph, qualifiedName: string): string {
+    const [node] = graph.findNodesByName(qualifiedName);
+    expect(node).toBeDefined();
+    return node.id;
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+  });
+
+  it('should link Go callers and callees across files', async () => {
+    addGoFile('/ws/service/service.go', serviceGo);
+    addGoFile('/ws/service/helpers.go', helpersGo);
+    addGoFile('/ws/repo/repo.go', repoGo);
+
+    const graph = new CallGraph(index.asBackgroundIndex());
+    const stats = await graph.build();
+
+    expect(stats.functions).toBe(4);
+    expect(stats.edges).toBe(3);
+
+    const save = nodeId(graph, 'service.Service.Save');
+    const calleeNames = graph.callees(save).map(e => graph.getNode(e.callee)!.qualifiedName).sort();
+    expect(calleeNames).toEqual(['repo.Repo.Insert', 'service.validate']);
+
+    const checkLength = nodeId(graph, 'checkLength');
+    const callers = graph.callers(checkLength);
+    expect(callers).toHaveLength(1);
+    expect(graph.getNode(callers[0].caller)!.name).toBe('validate');
+    expect(callers[0].callSites[0]).toMatchObject({ uri: '/ws/service/service.go', line: 10 });
+  });
+
+  it('should prefer same-file definitions and flag ambiguous callees', async () => {
+    const a = '/ws/src/a.ts';
+    const b = '/ws/src/b.ts';
+    const c = '/ws/src/c.ts';
+    const fn = (id: string, name: string, uri: string, startLine: number, endLine: number) => createTestSymbol({
+      id,
+      name,
+      kind: 'function',
+      filePath: uri,
+      location: { uri, line: startLine, character: 9 },
+      range: { startLine, startCharacter: 0, endLine, endCharacter: 1 }
+    });
+    const call = (name: string, uri: string, line: number) => createTestReference({
+      symbolName: name,
+      location: { uri, line, character: 2 },
+      range: { startLine: line, startCharacter: 2, endLine: line, endCharacter: 2 + name.length },
+      isCall: true
+    });
+
+    index.addFile(a, [fn('a-main', 'main', a, 0, 3), fn('a-format', 'format', a, 4, 6)], [call('format', a, 1), call('render', a, 2)]);
+    index.addFile(b, [fn('b-format', 'format', b, 0, 2), fn('b-render', 'render', b, 3, 5)]);
+    index.addFile(c, [fn('c-render', 'render', c, 0, 2)]);
+
+    const graph = new CallGraph(index.asBackgroundIndex());
+    await graph.build();
+
+    const edges = graph.callees('a-main');
+    const format = edges.find(e => e.callee.endsWith('format'));
+    expect(format?.callee).toBe('a-format');
+    expect(format?.ambiguous).toBeUndefined();
+
+    const render = edges.filter(e => e.callee.endsWith('render'));
+    expect(render.map(e => e.callee).sort()).toEqual(['b-render', 'c-render']);
+    expect(render.every(e => e.ambiguous)).toBe(true);
+  });
+
+  it('should attribute calls in closures to them and link closures to their function', async () => {
+    addGoFile('/ws/service/worker.go', `package service
+
+func (s *Service) Start(names []string) {
+	for _, name := range names {
+		go func(n string) {
+			validate(n)
+		}(name)
+	}
+}
+`);
+    addGoFile('/ws/service/service.go', serviceGo);
+    addGoFile('/ws/service/helpers.go', helpersGo);
+
+    const graph = new CallGraph(index.asBackgroundIndex());
+    await graph.build();
+
+    const start = nodeId(graph, 'service.Service.Start');
+    const closure = nodeId(graph, 'service.Service.Start$1');
+    expect(graph.callees(start).map(e => [e.callee, e.callSites[0].line])).toEqual([[closure, 4]]);
+    expect(graph.callees(closure).map(e => graph.getNode(e.callee)!.name)).toEqual(['validate']);
+    expect(graph.getNode(closure)!.kind).toBe('closure');
+  });
+
+  it('should answer queries by name or position with a bounded slice', async () => {
+    addGoFile('/ws/service/service.go', serviceGo);
+    addGoFile('/ws/service/helpers.go', helpersGo);
+    addGoFile('/ws/repo/repo.go', repoGo);
+
+    const graph = new CallGraph(index.asBackgroundIndex());
+    await graph.build();
+
+    const byName = graph.query({ name: 'validate', depth: 1 });
+    expect(byName.roots).toEqual([nodeId(graph, 'validate')]);
+    expect(byName.nodes.map(node => node.name).sort()).toEqual(['Save', 'checkLength', 'validate']);
+
+    const byPosition = graph.query({ uri: '/ws/service/service.go', line: 3, character: 1, direction: 'callees' });
+    expect(byPosition.nodes.map(node => node.name).sort()).toEqual(['Insert', 'Save', 'checkLength', 'validate']);
+
+    expect(graph.query({ name: 'missing' })).toEqual({ roots: [], nodes: [], edges: [] });
+  });
+
+  it('should serialize a bounded view as DOT and JSON', async () => {
+    addGoFile('/ws/service/service.go', serviceGo);
+    addGoFile('/ws/service/helpers.go', helpersGo);
+
+    const graph = new CallGraph(index.asBackgroundIndex());
+    await graph.build();
+
+    const view = graph.view([nodeId(graph, 'checkLength')], 'callers', 1);
+    expect(view.nodes.map(n => n.name).sort()).toEqual(['checkLength', 'validate']);
+
+    const dot = graph.toDot(view);
+    expect(dot).toContain('digraph callgraph {');
+    expect(dot).toMatch(/n\d -> n\d \[label="1"\];/);
+    expect(dot).toContain('label="service.validate"');
+
+    const json = JSON.parse(graph.toJson(view));
+    expect(json.edges).toHaveLength(1);
+    expect(json.nodes).toHaveLength(2);
+  });
+});
@@ -0,0 +1,441 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol, IndexedReference, SymbolLocation } from '../types.js';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface CallGraphOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface CallGraphNode {
+  /** Symbol id of the function/method */
+  id: string;
+  name: string;
+  /** Container-qualified name (e.g. `UserService.save`, `store.Store.Get`) */
+  qualifiedName: string;
+  kind: string;
+  location: SymbolLocation;
+}
+
+export interface CallGraphEdge {
+  caller: string;
+  callee: string;
+  /** Every call site of callee inside caller */
+  callSites: SymbolLocation[];
+  /** True when the callee name matched several definitions and all of them were linked */
+  ambiguous?: boolean;
+}
+
+export interface CallGraphStats {
+  functions: number;
+  edges: number;
+  /** Call sites whose callee has no function/method definition in the index */
+  unresolvedCalls: number;
+}
+
+export type CallGraphDirection = 'callers' | 'callees' | 'both';
+
+/**
+ * A connected slice of the call graph, ready for serialization.
+ */
+export interface CallGraphView {
+  nodes: CallGraphNode[];
+  edges: CallGraphEdge[];
+}
+
+export interface CallGraphQuery {
+  /** Function or method name, or qualified name (`Store.Get`) */
+  name?: string;
+  /** Or the innermost function containing a position */
+  uri?: string;
+  line?: number;
+  character?: number;
+  direction?: CallGraphDirection;
+  /** Calls followed from the roots (default: 2) */
+  depth?: number;
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+}
+
+/** The slice of the graph around the functions a query names */
+export interface CallGraphReport extends CallGraphView {
+  /** Ids of the functions the query resolved to; empty when none matched */
+  roots: string[];
+}
+
+/** Call graph slices; how servers expose the graph */
+export type CallGraphSource = (query: CallGraphQuery) => Promise<CallGraphReport>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_QUERY_DEPTH = 2;
+
+const CALLABLE_KINDS = new Set(['function', 'method', 'constructor', 'closure']);
+
+/**
+ * Call Graph - caller -> callee edges for every function and method.
+ *
+ * Built from the background index: the indexers flag call sites on
+ * references (`isCall`), the caller is the innermost function/method whose
+ * range contains the call site, and the callee is resolved by name.
+ * Calls in a closure (`go func() {...}()`, a handler lambda) are the
+ * closure's; the function it is written in has an edge to it, with the
+ * closure's definition as the call site.
+ *
+ * Name resolution mirrors the LSIF exporter: a definition in the same file
+ * wins, then (Go only) one in the same package directory, then a
+ * workspace-unique definition. Remaining ambiguous names are linked to every
+ * candidate and flagged, which over-approximates - the safe side for impact
+ * analysis before a refactor.
+ */
+export class CallGraph {
+  private nodes: Map<string, CallGraphNode> = new Map();
+  private outgoing: Map<string, Map<string, CallGraphEdge>> = new Map();
+  private incoming: Map<string, Map<string, CallGraphEdge>> = new Map();
+  private callablesByFile: Map<string, IndexedSymbol[]> = new Map();
+
+  constructor(private backgroundIndex: BackgroundIndex) {}
+
+  /**
+   * (Re)build the graph from the current background index.
+   */
+  async build(options: CallGraphOptions = {}): Promise<CallGraphStats> {
+    const { cancellationToken, onProgress } = options;
+    this.nodes.clear();
+    this.outgoing.clear();
+    this.incoming.clear();
+    this.callablesByFile.clear();
+
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const totalSteps = files.length * 2;
+    const definitionsByName = new Map<string, IndexedSymbol[]>();
+    const callsByFile = new Map<string, IndexedReference[]>();
+
+    // Pass 1: callable definitions and call sites
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, totalSteps, `Collecting functions (${i}/${files.length})`);
+      }
+
+      const fileResult = await this.backgroundIndex.getFileResult(files[i]);
+      if (!fileResult) {
+        continue;
+      }
+
+      const callables = fileResult.symbols.filter(s => s.isDefinition !== false && CALLABLE_KINDS.has(s.kind));
+      if (callables.length > 0) {
+        this.callablesByFile.set(files[i], callables);
+      }
+      for (const symbol of callables) {
+        this.nodes.set(symbol.id, toNode(symbol));
+        if (symbol.kind === 'closure') {
+          // Synthesized names (`Greet$1`) are never called by name
+          continue;
+        }
+        let byName = definitionsByName.get(symbol.name);
+        if (!byName) {
+          byName = [];
+          definitionsByName.set(symbol.name, byName);
+        }
+        byName.push(symbol);
+      }
+
+      const calls = fileResult.references.filter(r => r.isCall && !r.isLocal);
+      if (calls.length > 0) {
+        callsByFile.set(files[i], calls);
+      }
+    }
+
+    // Pass 2: link call sites to callers and callees
+    let unresolvedCalls = 0;
+    let step = 0;
+    for (const [uri, calls] of callsByFile) {
+      if (step++ % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(files.length + step, totalSteps, `Linking calls (${step}/${callsByFile.size})`);
+      }
+
+      for (const call of calls) {
+        const caller = this.findEnclosingCallable(uri, call.location.line, call.location.character);
+        if (!caller) {
+          // Top-level call (module initialization) - no function to attribute it to
+          continue;
+        }
+
+        const candidates = definitionsByName.get(call.symbolName);
+        if (!candidates || candidates.length === 0) {
+          unresolvedCalls++;
+          continue;
+        }
+
+        const callees = this.resolveCallees(candidates, uri);
+        for (const callee of callees) {
+          this.addEdge(caller.id, callee.id, call.location, callees.length > 1);
+        }
+      }
+    }
+
+    // Closures: an edge from the function they are written in
+    for (const [uri, callables] of this.callablesByFile) {
+      for (const closure of callables) {
+        if (closure.kind !== 'closure') {
+          continue;
+        }
+        const parent = this.findEnclosingCallable(uri, closure.location.line, closure.location.character, closure);
+        if (parent) {
+          this.addEdge(parent.id, closure.id, closure.location, false);
+        }
+      }
+    }
+
+    onProgress?.(totalSteps, totalSteps, 'Call graph complete');
+
+    let edges = 0;
+    for (const targets of this.outgoing.values()) {
+      edges += targets.size;
+    }
+    return { functions: this.nodes.size, edges, unresolvedCalls };
+  }
+
+  /**
+   * Functions and methods that call the given symbol.
+   */
+  callers(symbolId: string): CallGraphEdge[] {
+    return Array.from(this.incoming.get(symbolId)?.values() || []);
+  }
+
+  /**
+   * Functions and methods called by the given symbol.
+   */
+  callees(symbolId: string): CallGraphEdge[] {
+    return Array.from(this.outgoing.get(symbolId)?.values() || []);
+  }
+
+  getNode(symbolId: string): CallGraphNode | undefined {
+    return this.nodes.get(symbolId);
+  }
+
+  /**
+   * Find the innermost function/method containing a position.
+   */
+  findNodeAt(uri: string, line: number, character: number): CallGraphNode | undefined {
+    const symbol = this.findEnclosingCallable(uri, line, character);
+    return symbol ? this.nodes.get(symbol.id) : undefined;
+  }
+
+  /**
+   * Find functions/methods by name or qualified name.
+   */
+  findNodesByName(name: string): CallGraphNode[] {
+    return Array.from(this.nodes.values()).filter(n => n.name === name || n.qualifiedName === name);
+  }
+
+  /**
+   * Collect the nodes reachable from roots in the given direction.
+   * Without roots the whole graph is returned.
+   */
+  view(roots?: string[], direction: CallGraphDirection = 'both', maxDepth: number = Infinity): CallGraphView {
+    if (!roots) {
+      const edges: CallGraphEdge[] = [];
+      for (const targets of this.outgoing.values()) {
+        edges.push(...targets.values());
+      }
+      return { nodes: Array.from(this.nodes.values()), edges };
+    }
+
+    const visited = new Set<string>();
+    const edges = new Set<CallGraphEdge>();
+
+    const walk = (next: (id: string) => CallGraphEdge[], pick: (e: CallGraphEdge) => string) => {
+      const seen = new Set<string>(roots);
+      let frontier = [...roots];
+      for (let depth = 0; depth < maxDepth && frontier.length > 0; depth++) {
+        const nextFrontier: string[] = [];
+        for (const id of frontier) {
+          for (const edge of next(id)) {
+            edges.add(edge);
+            const target = pick(edge);
+            if (!seen.has(target)) {
+              seen.add(target);
+              nextFrontier.push(target);
+            }
+          }
+        }
+        frontier = nextFrontier;
+      }
+      seen.forEach(id => visited.add(id));
+    };
+
+    if (direction !== 'callers') {
+      walk(id => this.callees(id), e => e.callee);
+    }
+    if (direction !== 'callees') {
+      walk(id => this.callers(id), e => e.caller);
+    }
+
+    return {
+      nodes: Array.from(visited).map(id => this.nodes.get(id)).filter((n): n is CallGraphNode => !!n),
+      edges: Array.from(edges)
+    };
+  }
+
+  /**
+   * The callers and callees around the functions a query names, by name
+   * or position, up to depth calls away.
+   */
+  query(query: CallGraphQuery): CallGraphReport {
+    let roots: string[];
+    if (query.uri !== undefined && query.line !== undefined) {
+      const node = this.findNodeAt(query.uri, query.line, query.character ?? 0);
+      roots = node ? [node.id] : [];
+    } else {
+      roots = query.name ? this.findNodesByName(query.name).map(node => node.id) : [];
+    }
+    if (roots.length === 0) {
+      return { roots, nodes: [], edges: [] };
+    }
+    return { roots, ...this.view(roots, query.direction ?? 'both', query.depth ?? DEFAULT_QUERY_DEPTH) };
+  }
+
+  /**
+   * Serialize a view as JSON with node ids and call sites.
+   */
+  toJson(view: CallGraphView): string {
+    return JSON.stringify(view, null, 2);
+  }
+
+  /**
+   * Serialize a view as a Graphviz DOT digraph. Ambiguous edges are dashed.
+   */
+  toDot(view: CallGraphView): string {
+    const ids = new Map<string, string>();
+    view.nodes.forEach((node, i) => ids.set(node.id, `n${i}`));
+
+    const lines = [
+      'digraph callgraph {',
+      '  rankdir=LR;',
+      '  node [shape=box, fontname="Helvetica"];'
+    ];
+    for (const node of view.nodes) {
+      lines.push(`  ${ids.get(node.id)} [label=${dotString(node.qualifiedName)}, tooltip=${dotString(`${node.location.uri}:${node.location.line + 1}`)}];`);
+    }
+    for (const edge of view.edges) {
+      const from = ids.get(edge.caller);
+      const to = ids.get(edge.callee);
+      if (!from || !to) {
+        continue;
+      }
+      const attrs = [`label="${edge.callSites.length}"`];
+      if (edge.ambiguous) {
+        attrs.push('style=dashed');
+      }
+      lines.push(`  ${from} -> ${to} [${attrs.join(', ')}];`);
+    }
+    lines.push('}');
+    return lines.join('\n') + '\n';
+  }
+
+  private findEnclosingCallable(uri: string, line: number, character: number, exclude?: IndexedSymbol): IndexedSymbol | undefined {
+    let best: IndexedSymbol | undefined;
+    for (const symbol of this.callablesByFile.get(uri) || []) {
+      if (symbol === exclude || !rangeContains(symbol, line, character)) {
+        continue;
+      }
+      // Innermost wins: later start means a nested declaration
+      if (!best || compareStart(symbol, best) > 0) {
+        best = symbol;
+      }
+    }
+    return best;
+  }
+
+  private resolveCallees(candidates: IndexedSymbol[], uri: string): IndexedSymbol[] {
+    if (candidates.length === 1) {
+      return candidates;
+    }
+
+    const sameFile = candidates.filter(c => c.location.uri === uri);
+    if (sameFile.length > 0) {
+      return sameFile;
+    }
+
+    // Go: unqualified calls resolve within the package, i.e. the directory
+    if (path.extname(uri) === '.go') {
+      const dir = path.dirname(uri);
+      const samePackage = candidates.filter(c => path.dirname(c.location.uri) === dir);
+      if (samePackage.length > 0) {
+        return samePackage;
+      }
+    }
+
+    return candidates;
+  }
+
+  private addEdge(callerId: string, calleeId: string, site: SymbolLocation, ambiguous: boolean): void {
+    let targets = this.outgoing.get(callerId);
+    if (!targets) {
+      targets = new Map();
+      this.outgoing.set(callerId, targets);
+    }
+
+    let edge = targets.get(calleeId);
+    if (!edge) {
+      edge = { caller: callerId, callee: calleeId, callSites: [] };
+      if (ambiguous) {
+        edge.ambiguous = true;
+      }
+      targets.set(calleeId, edge);
+
+      let sources = this.incoming.get(calleeId);
+      if (!sources) {
+        sources = new Map();
+        this.incoming.set(calleeId, sources);
+      }
+      sources.set(callerId, edge);
+    }
+
+    edge.callSites.push(site);
+  }
+}
+
+function toNode(symbol: IndexedSymbol): CallGraphNode {
+  return {
+    id: symbol.id,
+    name: symbol.name,
+    qualifiedName: symbol.fullContainerPath ? `${symbol.fullContainerPath}.${symbol.name}` : symbol.name,
+    kind: symbol.kind,
+    location: symbol.location
+  };
+}
+
+function rangeContains(symbol: IndexedSymbol, line: number, character: number): boolean {
+  const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+  if (line < startLine || line > endLine) {
+    return false;
+  }
+  if (line === startLine && character < startCharacter) {
+    return false;
+  }
+  if (line === endLine && character > endCharacter) {
+    return false;
+  }
+  return true;
+}
+
+function compareStart(a: IndexedSymbol, b: IndexedSymbol): number {
+  return a.range.startLine - b.range.startLine || a.range.startCharacter - b.range.startCharacter;
+}
+
+function dotString(value: string): string {
+  return `"${value.replace(/\\/g, '\\\\').replace(/"/g, '\\"')}"`;
+}
@@ -0,0 +1,210 @@
+/**
+ * CloneDetector Tests
+ *
+ * Verifies token normalization, similarity and clone clustering.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { CloneDetector, normalizeTokens, shingleHashes, shingleSimilarity } from './cloneDetection.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { PythonIndexer } from '../indexer/pythonIndexer.js';
+import { tagFileResult } from '../utils/codeTags.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const ordersGo = `package orders
+
+// TotalPrice sums the order lines.
+func TotalPrice(lines []Line, discount float64) float64 {
+	total := 0.0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		total += line.Price * float64(line.Quantity)
+	}
+	if discount > 0 {
+		total = total * (1 - discount)
+	}
+	return total
+}
+
+func Describe(o Order) string {
+	return fmt.Sprintf("order %d", o.ID)
+}
+`;
+
+const invoicesGo = `package invoices
+
+func InvoiceAmount(items []Item, rebate float64) float64 {
+	sum := 0.0
+	for _, item := range items {
+		if item.Count <= 0 {
+			continue
+		}
+		sum += item.Cost * float64(item.Count) // per item
+	}
+	if rebate > 0 {
+		sum = sum * (1 - rebate)
+	}
+	return sum
+}
+`;
+
+const cartGo = `package cart
+
+func CartTotal(entries []Entry, off float64) float64 {
+	acc := 0.0
+	for _, e := range entries {
+		if e.N <= 0 {
+			continue
+		}
+		acc += e.P * float64(e.N)
+	}
+	if off > 0 {
+		acc = acc * (1 - off)
+	}
+	if acc < 0 {
+		acc = 0
+	}
+	return acc
+}
+`;
+
+const ordersTestGo = `package orders
+
+func expectedTotal(lines []Line, discount float64) float64 {
+	total := 0.0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		total += line.Price * float64(line.Quantity)
+	}
+	if discount > 0 {
+		total = total * (1 - 0.5)
+	}
+	return total
+}
+`;
+
+const reportPy = `def summarize(rows):
+    # Skip empty rows
+    result = []
+    for row in rows:
+        if not row:
+            continue
+        result.append(row.strip())
+    return result
+
+
+def render(rows):
+    return "\\n".join(rows)
+`;
+
+const exportPy = `def collect(lines):
+    out = []
+    for line in lines:
+        if not line:
+            continue
+        out.append(line.strip())
+    return out
+`;
+
+describe('CloneDetector', () => {
+  let index: MockBackgroundIndex;
+  let contents: Map<string, string>;
+  let detector: CloneDetector;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    contents = new Map();
+    const goIndexer = new GoIndexer();
+    const pythonIndexer = new PythonIndexer();
+    for (const [uri, content] of [
+      ['/ws/orders/orders.go', ordersGo],
+      ['/ws/orders/orders_test.go', ordersTestGo],
+      ['/ws/invoices/invoices.go', invoicesGo],
+      ['/ws/cart/cart.go', cartGo],
+      ['/ws/py/report.py', reportPy],
+      ['/ws/py/export.py', exportPy]
+    ]) {
+      const parsed = uri.endsWith('.go') ? goIndexer.indexFile(uri, content) : pythonIndexer.parse(uri, content);
+      const result = tagFileResult({ uri, hash: uri, ...parsed }, content);
+      index.addFile(uri, result.symbols, result.references);
+      contents.set(uri, content);
+    }
+    detector = new CloneDetector(index.asBackgroundIndex(), async uri => {
+      const content = contents.get(uri);
+      if (content === undefined) {
+        throw new Error('ENOENT');
+      }
+      return content;
+    });
+  });
+
+  it('should cluster renamed copies and near-duplicates', async () => {
+    const report = await detector.findClones({ minTokens: 30, tags: { exclude: ['test'] } });
+
+    const names = report.clusters.map(cluster => cluster.members.map(m => m.symbol.name));
+    expect(names).toEqual([
+      ['CartTotal', 'InvoiceAmount', 'TotalPrice'],
+      ['collect', 'summarize']
+    ]);
+
+    const [go, py] = report.clusters;
+    expect(go.exact).toBe(false);
+    expect(go.similarity).toBeGreaterThanOrEqual(0.8);
+    expect(go.similarity).toBeLessThan(1);
+    expect(go.members[2]).toMatchObject({ startLine: 3, endLine: 15 });
+
+    // Comments and names differ, the token streams do not
+    expect(py.exact).toBe(true);
+    expect(py.similarity).toBe(1);
+    expect(report.filesScanned).toBe(6);
+    expect(report.functionsAnalyzed).toBe(5);
+    expect(report.truncated).toBe(false);
+  });
+
+  it('should apply the similarity threshold, scope, tags and limit', async () => {
+    const strict = await detector.findClones({ minTokens: 30, minSimilarity: 1, tags: { exclude: ['test'] } });
+    expect(strict.clusters.map(c => c.members.map(m => m.symbol.name))).toEqual([
+      ['InvoiceAmount', 'TotalPrice'],
+      ['collect', 'summarize']
+    ]);
+
+    const withTests = await detector.findClones({ minTokens: 30, scopePath: '/ws/orders', minSimilarity: 0.8 });
+    expect(withTests.clusters.map(c => c.members.map(m => m.symbol.name))).toEqual([['TotalPrice', 'expectedTotal']]);
+    expect(withTests.filesScanned).toBe(2);
+
+    const limited = await detector.findClones({ minTokens: 30, limit: 1 });
+    expect(limited.clusters).toHaveLength(1);
+    expect(limited.truncated).toBe(true);
+
+    // The Python functions are below the default of 50 tokens
+    const defaults = await detector.findClones();
+    expect(defaults.clusters.map(c => c.members.map(m => m.symbol.name))).toEqual([
+      ['CartTotal', 'InvoiceAmount', 'TotalPrice', 'expectedTotal']
+    ]);
+    await expect(detector.findClones({ minSimilarity: 0 })).rejects.toThrow('minSimilarity must be in (0, 1]');
+  });
+});
+
+describe('normalizeTokens', () => {
+  it('should replace names and literals and drop comments', () => {
+    expect(normalizeTokens('if x := f("a", 42); x != nil { /* c */ return x } // done')).toEqual([
+      'if', '$', ':=', '$', '(', '"', ',', '0', ')', ';', '$', '!=', 'nil', '{', 'return', '$', '}'
+    ]);
+    expect(normalizeTokens('x = """doc\n# not a comment"""  # comment\ny = 1.5e3', true)).toEqual([
+      '$', '=', '"', '$', '=', '0'
+    ]);
+    expect(normalizeTokens('const s = `a\\`; return s')).toEqual(['const', '$', '=', '"', ';', 'return', '$']);
+  });
+
+  it('should measure shingle similarity', () => {
+    const a = shingleHashes(normalizeTokens('a = b + c; return a * 2'), 3);
+    const b = shingleHashes(normalizeTokens('x = y + z; return x * 7'), 3);
+    const c = shingleHashes(normalizeTokens('for (;;) { break }'), 3);
+    expect(shingleSimilarity(a, b)).toBe(1);
+    expect(shingleSimilarity(a, c)).toBe(0);
+  });
+});
@@ -0,0 +1,370 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { CodeTagFilter, isTagFilterEmpty, matchesTagFilter } from '../utils/codeTags.js';
+import { rangeSource, tokenizeSource, usesHashComments } from '../utils/sourceTokens.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type CloneReader = (filePath: string) => Promise<string>;
+
+/** The part of an index clone detection reads: the background index or the library Indexer */
+export type CloneSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols'>;
+
+export interface CloneDetectionOptions {
+  /** Jaccard similarity of token shingles a pair needs to count as clones, 0..1 (default 0.8) */
+  minSimilarity?: number;
+  /** Functions with fewer normalized tokens are skipped (default 50) */
+  minTokens?: number;
+  /** Tokens per shingle (default 5) */
+  shingleSize?: number;
+  /** Only functions under this folder */
+  scopePath?: string;
+  /** Code tags to leave out or keep, e.g. `{ exclude: ['test', 'generated'] }` */
+  tags?: CodeTagFilter;
+  /** Maximum number of clusters (default: 100) */
+  limit?: number;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface CloneMember {
+  symbol: IndexedSymbol;
+  /** Zero-based first and last line of the function */
+  startLine: number;
+  endLine: number;
+  /** Normalized tokens of the function */
+  tokens: number;
+}
+
+export interface CloneCluster {
+  /** Lowest similarity among the pairs that put the members together */
+  similarity: number;
+  /** Every member has the same normalized token stream */
+  exact: boolean;
+  /** Members in path order */
+  members: CloneMember[];
+}
+
+export interface CloneReport {
+  /** Largest duplication (members × tokens) first */
+  clusters: CloneCluster[];
+  functionsAnalyzed: number;
+  filesScanned: number;
+  /** True if the limit was reached (there are more clusters) */
+  truncated: boolean;
+}
+
+interface Fingerprint {
+  member: CloneMember;
+  /** Hash of the whole normalized token stream */
+  exactHash: number;
+  shingles: Set<number>;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_MIN_SIMILARITY = 0.8;
+const DEFAULT_MIN_TOKENS = 50;
+const DEFAULT_SHINGLE_SIZE = 5;
+const DEFAULT_LIMIT = 100;
+
+/**
+ * MinHash signature length and its split into LSH bands. 16 bands of 4 rows
+ * make pairs above ~0.5 similarity likely candidates; candidates are then
+ * checked against the exact similarity.
+ */
+const MINHASH_SIZE = 64;
+const BAND_ROWS = 4;
+
+const CLONE_KINDS = new Set(['function', 'method', 'constructor']);
+
+/**
+ * Clone Detection - finds functions that are copies of each other, renamed
+ * or slightly edited.
+ *
+ * Each function's source is reduced to a normalized token stream: comments
+ * dropped, identifiers replaced by one placeholder and literals by another,
+ * keywords and operators kept. This stands in for its syntax tree across
+ * languages, so renaming variables or changing constants does not hide a
+ * copy. Overlapping windows of `shingleSize` tokens are hashed; two
+ * functions are clones when the Jaccard similarity of their shingle sets
+ * reaches `minSimilarity`. MinHash with banding picks the candidate pairs,
+ * so the scan is not quadratic in the number of functions.
+ *
+ * Clone pairs are joined into clusters (A~B and B~C put A, B and C
+ * together), reported largest duplication first.
+ */
+export class CloneDetector {
+  constructor(
+    private index: CloneSourceIndex,
+    private readFile: CloneReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  async findClones(options: CloneDetectionOptions = {}): Promise<CloneReport> {
+    const { cancellationToken, onProgress } = options;
+    const minSimilarity = options.minSimilarity ?? DEFAULT_MIN_SIMILARITY;
+    if (!(minSimilarity > 0 && minSimilarity <= 1)) {
+      throw new Error(`minSimilarity must be in (0, 1], got ${options.minSimilarity}`);
+    }
+    const minTokens = options.minTokens ?? DEFAULT_MIN_TOKENS;
+    const shingleSize = Math.max(1, options.shingleSize ?? DEFAULT_SHINGLE_SIZE);
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+
+    const files = (await this.index.getAllFiles())
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const fingerprints: Fingerprint[] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Fingerprinting functions (${i}/${files.length})`);
+      }
+
+      const symbols = (await this.index.getFileSymbols(files[i])).filter(symbol =>
+        symbol.isDefinition !== false &&
+        CLONE_KINDS.has(symbol.kind) &&
+        symbol.range.endLine > symbol.range.startLine &&
+        (!options.tags || isTagFilterEmpty(options.tags) || matchesTagFilter(symbol.tags, options.tags))
+      );
+      if (symbols.length === 0) {
+        continue;
+      }
+
+      let content: string;
+      try {
+        content = await this.readFile(files[i]);
+      } catch {
+        continue; // Deleted since indexing
+      }
+      const lines = content.split('\n');
+      const hashComments = usesHashComments(files[i]);
+
+      for (const symbol of symbols) {
+        const tokens = normalizeTokens(rangeSource(lines, symbol.range), hashComments);
+        if (tokens.length < Math.max(minTokens, shingleSize)) {
+          continue;
+        }
+        fingerprints.push({
+          member: { symbol, startLine: symbol.range.startLine, endLine: symbol.range.endLine, tokens: tokens.length },
+          exactHash: hashString(tokens.join(' ')),
+          shingles: shingleHashes(tokens, shingleSize)
+        });
+      }
+    }
+
+    throwIfCancelled(cancellationToken);
+    onProgress?.(files.length, files.length, `Comparing ${fingerprints.length} functions`);
+    const clusters = clusterClones(fingerprints, minSimilarity);
+
+    return {
+      clusters: clusters.slice(0, limit),
+      functionsAnalyzed: fingerprints.length,
+      filesScanned: files.length,
+      truncated: clusters.length > limit
+    };
+  }
+}
+
+/**
+ * Normalized token stream of source code: identifiers become `$`, string
+ * literals `"`, numbers `0`; keywords and punctuation are kept; comments are
+ * dropped (see utils/sourceTokens.ts).
+ */
+export function normalizeTokens(source: string, hashComments: boolean = false): string[] {
+  return tokenizeSource(source, hashComments).map(token => {
+    switch (token.type) {
+      case 'identifier':
+        return '$';
+      case 'string':
+        return '"';
+      case 'number':
+        return '0';
+      default:
+        return token.text;
+    }
+  });
+}
+
+/**
+ * Jaccard similarity of two shingle sets.
+ */
+export function shingleSimilarity(a: Set<number>, b: Set<number>): number {
+  if (a.size === 0 && b.size === 0) {
+    return 1;
+  }
+  const [small, large] = a.size <= b.size ? [a, b] : [b, a];
+  let shared = 0;
+  for (const shingle of small) {
+    if (large.has(shingle)) {
+      shared++;
+    }
+  }
+  return shared / (a.size + b.size - shared);
+}
+
+/**
+ * Hashes of every window of `size` consecutive tokens.
+ */
+export function shingleHashes(tokens: string[], size: number): Set<number> {
+  const shingles = new Set<number>();
+  for (let i = 0; i + size <= tokens.length; i++) {
+    shingles.add(hashString(tokens.slice(i, i + size).join(' ')));
+  }
+  return shingles;
+}
+
+function clusterClones(fingerprints: Fingerprint[], minSimilarity: number): CloneCluster[] {
+  const parent = fingerprints.map((_, i) => i);
+  const find = (i: number): number => {
+    while (parent[i] !== i) {
+      parent[i] = parent[parent[i]];
+      i = parent[i];
+    }
+    return i;
+  };
+  const lowest = new Map<number, number>();
+  const union = (a: number, b: number, similarity: number) => {
+    const rootA = find(a);
+    const rootB = find(b);
+    if (rootA !== rootB) {
+      lowest.set(rootA, Math.min(similarity, lowest.get(rootA) ?? 1, lowest.get(rootB) ?? 1));
+      lowest.delete(rootB);
+      parent[rootB] = rootA;
+    }
+  };
+
+  // Exact copies share one representative for the similarity pass
+  const representatives: number[] = [];
+  const byExactHash = new Map<number, number>();
+  for (let i = 0; i < fingerprints.length; i++) {
+    const first = byExactHash.get(fingerprints[i].exactHash);
+    if (first === undefined) {
+      byExactHash.set(fingerprints[i].exactHash, i);
+      representatives.push(i);
+    } else if (!overlaps(fingerprints[first].member, fingerprints[i].member)) {
+      union(first, i, 1);
+    } else {
+      representatives.push(i);
+    }
+  }
+
+  const buckets = new Map<string, number[]>();
+  for (const i of representatives) {
+    const signature = minHash(fingerprints[i].shingles);
+    for (let band = 0; band < MINHASH_SIZE / BAND_ROWS; band++) {
+      const key = `${band}:${signature.slice(band * BAND_ROWS, (band + 1) * BAND_ROWS).join(',')}`;
+      const bucket = buckets.get(key);
+      if (bucket) {
+        bucket.push(i);
+      } else {
+        buckets.set(key, [i]);
+      }
+    }
+  }
+
+  const compared = new Set<string>();
+  for (const bucket of buckets.values()) {
+    for (let x = 0; x < bucket.length; x++) {
+      for (let y = x + 1; y < bucket.length; y++) {
+        const a = bucket[x];
+        const b = bucket[y];
+        const key = `${a}:${b}`;
+        if (compared.has(key)) {
+          continue;
+        }
+        compared.add(key);
+        if (overlaps(fingerprints[a].member, fingerprints[b].member)) {
+          continue; // A function and one nested in it
+        }
+        const similarity = shingleSimilarity(fingerprints[a].shingles, fingerprints[b].shingles);
+        if (similarity >= minSimilarity) {
+          union(a, b, similarity);
+        }
+      }
+    }
+  }
+
+  const groups = new Map<number, number[]>();
+  for (let i = 0; i < fingerprints.length; i++) {
+    const root = find(i);
+    const group = groups.get(root);
+    if (group) {
+      group.push(i);
+    } else {
+      groups.set(root, [i]);
+    }
+  }
+
+  const clusters: CloneCluster[] = [];
+  for (const [root, group] of groups) {
+    if (group.length < 2) {
+      continue;
+    }
+    const members = group.map(i => fingerprints[i].member).sort(bySourceOrder);
+    clusters.push({
+      similarity: Math.round((lowest.get(root) ?? 1) * 1000) / 1000,
+      exact: group.every(i => fingerprints[i].exactHash === fingerprints[group[0]].exactHash),
+      members
+    });
+  }
+
+  const weight = (cluster: CloneCluster) => cluster.members.reduce((sum, member) => sum + member.tokens, 0);
+  return clusters.sort((a, b) =>
+    weight(b) - weight(a) || bySourceOrder(a.members[0], b.members[0])
+  );
+}
+
+function minHash(shingles: Set<number>): number[] {
+  const signature = new Array<number>(MINHASH_SIZE).fill(0xffffffff);
+  for (const shingle of shingles) {
+    for (let k = 0; k < MINHASH_SIZE; k++) {
+      const value = mix32(shingle ^ Math.imul(k + 1, 0x9e3779b9));
+      if (value < signature[k]) {
+        signature[k] = value;
+      }
+    }
+  }
+  return signature;
+}
+
+/** FNV-1a, 32 bit */
+function hashString(text: string): number {
+  let hash = 0x811c9dc5;
+  for (let i = 0; i < text.length; i++) {
+    hash ^= text.charCodeAt(i);
+    hash = Math.imul(hash, 0x01000193);
+  }
+  return hash >>> 0;
+}
+
+/** MurmurHash3 finalizer; spreads one hash into an independent one per seed */
+function mix32(value: number): number {
+  let h = value;
+  h ^= h >>> 16;
+  h = Math.imul(h, 0x85ebca6b);
+  h ^= h >>> 13;
+  h = Math.imul(h, 0xc2b2ae35);
+  h ^= h >>> 16;
+  return h >>> 0;
+}
+
+function overlaps(a: CloneMember, b: CloneMember): boolean {
+  return a.symbol.location.uri === b.symbol.location.uri && a.startLine <= b.endLine && b.startLine <= a.endLine;
+}
+
+function bySourceOrder(a: CloneMember, b: CloneMember): number {
+  if (a.symbol.location.uri !== b.symbol.location.uri) {
+    return a.symbol.location.uri < b.symbol.location.uri ? -1 : 1;
+  }
+  return a.startLine - b.startLine;
+}
@@ -0,0 +1,73 @@
+/**
+ * CodeMetrics Tests
+ *
+ * Verifies ranking, thresholds and filters of the function metrics report.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { CodeMetrics } from './codeMetrics.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { FunctionMetrics, IndexedSymbol } from '../types.js';
+
+function fn(uri: string, name: string, metrics: FunctionMetrics | undefined, extra: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    id: `${uri}:${name}`,
+    name,
+    kind: 'function',
+    filePath: uri,
+    location: { uri, line: 0, character: 0 },
+    metrics,
+    ...extra
+  });
+}
+
+describe('CodeMetrics', () => {
+  let index: MockBackgroundIndex;
+  let codeMetrics: CodeMetrics;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    index.addFile('/ws/api/handler.go', [
+      fn('/ws/api/handler.go', 'Handle', { complexity: 14, loc: 80, nesting: 4 }, { parametersCount: 2 }),
+      fn('/ws/api/handler.go', 'parse', { complexity: 3, loc: 12, nesting: 1 }, { parametersCount: 1 }),
+      createTestSymbol({ id: 'req', name: 'Request', kind: 'struct', filePath: '/ws/api/handler.go' })
+    ]);
+    index.addFile('/ws/api/handler_test.go', [
+      fn('/ws/api/handler_test.go', 'TestHandle', { complexity: 20, loc: 150, nesting: 2 }, { tags: ['test'] })
+    ]);
+    index.addFile('/ws/store/store.go', [
+      fn('/ws/store/store.go', 'Save', { complexity: 14, loc: 30, nesting: 2 }, { parametersCount: 5 }),
+      fn('/ws/store/store.go', 'legacy', undefined)
+    ]);
+    codeMetrics = new CodeMetrics(index.asBackgroundIndex());
+  });
+
+  it('should rank functions by the sort metric', async () => {
+    const report = await codeMetrics.report({ tags: { exclude: ['test'] } });
+    expect(report.functions.map(f => [f.symbol.name, f.complexity])).toEqual([
+      ['Handle', 14],
+      ['Save', 14],
+      ['parse', 3]
+    ]);
+    expect(report.functionsAnalyzed).toBe(3);
+    expect(report.filesScanned).toBe(3);
+
+    const byParameters = await codeMetrics.report({ sort: 'parameters', limit: 1 });
+    expect(byParameters.functions.map(f => [f.symbol.name, f.parameters])).toEqual([['Save', 5]]);
+    expect(byParameters.matched).toBe(4);
+    expect(byParameters.truncated).toBe(true);
+  });
+
+  it('should apply thresholds and scope', async () => {
+    const report = await codeMetrics.report({ sort: 'loc', min: { complexity: 10, nesting: 2 } });
+    expect(report.functions.map(f => f.symbol.name)).toEqual(['TestHandle', 'Handle', 'Save']);
+
+    const scoped = await codeMetrics.report({ scopePath: '/ws/store', min: { loc: 1 } });
+    expect(scoped.functions.map(f => f.symbol.name)).toEqual(['Save']);
+    expect(scoped.filesScanned).toBe(1);
+
+    await expect(codeMetrics.report({ sort: 'size' as any })).rejects.toThrow('Unknown metric "size"');
+    await expect(codeMetrics.report({ min: { loc: -1 } })).rejects.toThrow('Invalid minimum for loc');
+  });
+});
@@ -0,0 +1,127 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { CodeTagFilter, isTagFilterEmpty, matchesTagFilter } from '../utils/codeTags.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export const CODE_METRICS = ['complexity', 'loc', 'parameters', 'nesting'] as const;
+
+export type CodeMetric = typeof CODE_METRICS[number];
+
+/** The part of an index the report reads: the background index or the library Indexer */
+export type MetricsSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols'>;
+
+export interface CodeMetricsOptions {
+  /** Metric to rank by, highest first (default: complexity) */
+  sort?: CodeMetric;
+  /** Keep only functions reaching every given minimum, e.g. `{ complexity: 10 }` */
+  min?: Partial<Record<CodeMetric, number>>;
+  /** Number of functions to return (default: 50) */
+  limit?: number;
+  /** Only functions under this folder */
+  scopePath?: string;
+  /** Code tags to leave out or keep, e.g. `{ exclude: ['test', 'generated'] }` */
+  tags?: CodeTagFilter;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface FunctionMetricsEntry extends Record<CodeMetric, number> {
+  symbol: IndexedSymbol;
+}
+
+export interface CodeMetricsReport {
+  /** Ranked by the sort metric, then by path */
+  functions: FunctionMetricsEntry[];
+  /** Functions with metrics in scope */
+  functionsAnalyzed: number;
+  /** Functions reaching the minimums, before the limit */
+  matched: number;
+  filesScanned: number;
+  truncated: boolean;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 50;
+
+/**
+ * Code Metrics - ranks functions and methods by the complexity and size
+ * metrics stored at indexing time (see utils/functionMetrics.ts) for
+ * tech-debt triage: the 50 most complex functions, everything nested
+ * deeper than 4, long functions in one package.
+ *
+ * Nothing is re-parsed: the scan reads the symbols of the background index
+ * file by file in path order. Files indexed before metrics existed have
+ * none and are skipped until re-indexed.
+ */
+export class CodeMetrics {
+  constructor(private index: MetricsSourceIndex) {}
+
+  /**
+   * Throws on an unknown sort metric or a negative minimum.
+   */
+  async report(options: CodeMetricsOptions = {}): Promise<CodeMetricsReport> {
+    const { cancellationToken, onProgress } = options;
+    const sort = options.sort ?? 'complexity';
+    if (!CODE_METRICS.includes(sort)) {
+      throw new Error(`Unknown metric "${sort}", expected one of ${CODE_METRICS.join(', ')}`);
+    }
+    const minimums = Object.entries(options.min ?? {}).filter(([, value]) => value !== undefined) as Array<[CodeMetric, number]>;
+    for (const [metric, value] of minimums) {
+      if (!CODE_METRICS.includes(metric) || !(value >= 0)) {
+        throw new Error(`Invalid minimum for ${metric}: ${value}`);
+      }
+    }
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+    const tags = options.tags && !isTagFilterEmpty(options.tags) ? options.tags : undefined;
+
+    const files = (await this.index.getAllFiles())
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const matches: FunctionMetricsEntry[] = [];
+    let functionsAnalyzed = 0;
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting function metrics (${i}/${files.length})`);
+      }
+
+      for (const symbol of await this.index.getFileSymbols(files[i])) {
+        if (!symbol.metrics || symbol.isDefinition === false || (tags && !matchesTagFilter(symbol.tags, tags))) {
+          continue;
+        }
+        functionsAnalyzed++;
+        const entry: FunctionMetricsEntry = {
+          symbol,
+          complexity: symbol.metrics.complexity,
+          loc: symbol.metrics.loc,
+          parameters: symbol.parametersCount ?? 0,
+          nesting: symbol.metrics.nesting
+        };
+        if (minimums.every(([metric, value]) => entry[metric] >= value)) {
+          matches.push(entry);
+        }
+      }
+    }
+
+    // Stable: equal values keep path order
+    matches.sort((a, b) => b[sort] - a[sort]);
+    return {
+      functions: matches.slice(0, limit),
+      functionsAnalyzed,
+      matched: matches.length,
+      filesScanned: files.length,
+      truncated: matches.length > limit
+    };
+  }
+}
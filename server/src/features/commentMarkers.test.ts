@@ -0,0 +1,177 @@
+/**
+ * Comment Marker Tests
+ *
+ * Verifies marker parsing in TS, Go and Python comments (owners, JSDoc and
+ * Go deprecations, custom tags and patterns), and queries by tag, path,
+ * author and age over blame from a fake git.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { CommentMarkerIndex, findCommentMarkers, MarkerBlame } from './commentMarkers.js';
+import { BlameLine, UNCOMMITTED } from './symbolHistory.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const SERVICE_TS = '/ws/src/service.ts';
+const STORE_GO = '/ws/pkg/store/store.go';
+const SCRIPT_PY = '/ws/scripts/deploy.py';
+
+const serviceTs = `// TODO(alice): split this class
+export class Service {
+  /**
+   * Loads everything.
+   * FIXME: no pagination
+   * @deprecated use loadPage
+   */
+  load() {
+    const label = 'TODO: not a comment';
+    return label; // HACK - cache bypass
+  }
+}
+// todo list for the next release
+`;
+
+const storeGo = `package store
+
+// Get reads a key.
+//
+// Deprecated: use Lookup.
+func Get(key string) string {
+	return "" // XXX
+}
+
+// PERF: allocates per call
+// NOTE[bob] keep in sync with the schema
+func Lookup(key string) string {
+	return ""
+}
+`;
+
+const deployPy = `# TODO: retry on timeout
+print("# FIXME in a string")
+`;
+
+const files: Record<string, string> = { [SERVICE_TS]: serviceTs, [STORE_GO]: storeGo, [SCRIPT_PY]: deployPy };
+
+const DAY_S = 24 * 60 * 60;
+const NOW_S = 1_800_000_000;
+
+function blameLine(author: string, ageDays: number): BlameLine {
+  return { commit: 'c'.repeat(40), author, authorEmail: `${author}@example.com`, time: NOW_S - ageDays * DAY_S, summary: 'change' };
+}
+
+describe('findCommentMarkers', () => {
+  it('should find markers at the start of comment lines', () => {
+    const markers = findCommentMarkers(SERVICE_TS, serviceTs);
+
+    expect(markers.map(({ tag, text, line, character, owner }) => ({ tag, text, line, character, owner }))).toEqual([
+      { tag: 'TODO', text: 'split this class', line: 0, character: 3, owner: 'alice' },
+      { tag: 'FIXME', text: 'no pagination', line: 4, character: 5, owner: undefined },
+      { tag: 'DEPRECATED', text: 'use loadPage', line: 5, character: 5, owner: undefined },
+      { tag: 'HACK', text: 'cache bypass', line: 9, character: 21, owner: undefined }
+    ]);
+  });
+
+  it('should read Go deprecations, custom tags and patterns', () => {
+    const markers = findCommentMarkers(STORE_GO, storeGo, {
+      tags: ['perf'],
+      patterns: [{ tag: 'note', pattern: 'NOTE\\[(?<owner>\\w+)\\]' }]
+    });
+
+    expect(markers.map(({ tag, text, owner }) => [tag, text, owner])).toEqual([
+      ['DEPRECATED', 'use Lookup.', undefined],
+      ['XXX', '', undefined],
+      ['PERF', 'allocates per call', undefined],
+      ['NOTE', 'keep in sync with the schema', 'bob']
+    ]);
+    expect(findCommentMarkers(STORE_GO, storeGo).map(m => m.tag)).toEqual(['DEPRECATED', 'XXX']);
+  });
+
+  it('should read full-line comments of languages without a scanner', () => {
+    expect(findCommentMarkers(SCRIPT_PY, deployPy)).toEqual([
+      { tag: 'TODO', text: 'retry on timeout', uri: SCRIPT_PY, line: 0, character: 2 }
+    ]);
+  });
+});
+
+describe('CommentMarkerIndex', () => {
+  let index: MockBackgroundIndex;
+  let markers: CommentMarkerIndex;
+  let contents: Record<string, string>;
+  let reads: string[];
+
+  // The service is alice's from 400 days ago except an uncommitted HACK line; the Go file is bob's from last week
+  const blame: MarkerBlame = async filePath => {
+    if (filePath === SCRIPT_PY) {
+      return undefined;
+    }
+    return contents[filePath].split('\n').map((_, line) =>
+      filePath === SERVICE_TS && line === 9
+        ? { ...blameLine('', 0), commit: UNCOMMITTED }
+        : blameLine(filePath === SERVICE_TS ? 'alice' : 'bob', filePath === SERVICE_TS ? 400 : 7)
+    );
+  };
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = { ...files };
+    for (const uri of Object.keys(contents)) {
+      index.addFile(uri, []);
+    }
+    reads = [];
+    markers = new CommentMarkerIndex(index.asBackgroundIndex(), async filePath => {
+      reads.push(filePath);
+      return contents[filePath];
+    }, () => NOW_S * 1000);
+    await markers.build();
+  });
+
+  it('should filter by tag and path and count markers per tag', async () => {
+    const all = await markers.query();
+    expect(all).toMatchObject({ total: 7, blamed: false, truncated: false });
+    expect(all.byTag).toEqual({ DEPRECATED: 2, FIXME: 1, HACK: 1, TODO: 2, XXX: 1 });
+
+    const todos = await markers.query({ tags: ['todo', 'fixme'], path: '/ws/src' });
+    expect(todos.markers.map(m => m.tag)).toEqual(['TODO', 'FIXME']);
+
+    const limited = await markers.query({ limit: 2 });
+    expect(limited).toMatchObject({ total: 7, truncated: true });
+    expect(limited.markers).toHaveLength(2);
+  });
+
+  it('should add blame and filter and sort by age and author', async () => {
+    const old = await markers.query({ minAgeDays: 365, sort: 'age' }, blame);
+    expect(old.markers.map(m => [m.tag, m.author, m.ageDays])).toEqual([
+      ['TODO', 'alice', 400],
+      ['FIXME', 'alice', 400],
+      ['DEPRECATED', 'alice', 400]
+    ]);
+    expect(old.markers[0].date).toBe(new Date((NOW_S - 400 * DAY_S) * 1000).toISOString());
+
+    const hack = (await markers.query({ tags: ['HACK'] }, blame)).markers[0];
+    expect(hack).toMatchObject({ author: 'Not Committed Yet', ageDays: 0, uncommitted: true });
+
+    const bob = await markers.query({ author: 'BOB@example.com' }, blame);
+    expect(bob.markers.map(m => m.uri)).toEqual([STORE_GO, STORE_GO]);
+    // The owner in the marker counts as its author, also without blame
+    expect((await markers.query({ author: 'alice' })).markers.map(m => m.text)).toEqual(['split this class']);
+  });
+
+  it('should reject age queries without blame', async () => {
+    await expect(markers.query({ maxAgeDays: 30 })).rejects.toThrow('Filtering or sorting markers by age needs git blame');
+    await expect(markers.query({ sort: 'age' })).rejects.toThrow('needs git blame');
+  });
+
+  it('should rescan only changed files and custom tags on config changes', async () => {
+    reads = [];
+    contents[SCRIPT_PY] = '# BUG: wrong region\n';
+    index.addFile(SCRIPT_PY, [], [], { hash: 'changed' });
+
+    expect(await markers.build()).toMatchObject({ files: 3, updated: 1 });
+    expect(reads).toEqual([SCRIPT_PY]);
+    expect((await markers.query({ path: SCRIPT_PY })).markers.map(m => m.tag)).toEqual(['BUG']);
+
+    markers.setConfig({ tags: ['PERF'], patterns: [] });
+    expect(await markers.build()).toMatchObject({ updated: 3 });
+    expect((await markers.query({ tags: ['PERF'] })).total).toBe(1);
+  });
+});
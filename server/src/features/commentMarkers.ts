@@ -0,0 +1,355 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { isContentScannable, scanContent } from '../indexer/components/ContentScanner.js';
+import { BlameLine, UNCOMMITTED } from './symbolHistory.js';
+import { CommentMarkerConfig } from '../config/configurationManager.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** Tags found without configuration; `DEPRECATED` also matches Go's `Deprecated:` and JSDoc's `@deprecated` */
+export const DEFAULT_MARKER_TAGS = ['TODO', 'FIXME', 'HACK', 'XXX', 'BUG', 'DEPRECATED'];
+
+export interface CommentMarker {
+  /** Upper-case tag: TODO, FIXME, ... */
+  tag: string;
+  /** Rest of the comment line */
+  text: string;
+  uri: string;
+  /** 0-based position of the tag */
+  line: number;
+  character: number;
+  /** Name in the marker itself: `TODO(alice): ...` */
+  owner?: string;
+  /** From git blame of the marker's line */
+  author?: string;
+  authorEmail?: string;
+  commit?: string;
+  /** ISO 8601 author date of the line */
+  date?: string;
+  ageDays?: number;
+  /** The line has uncommitted changes */
+  uncommitted?: boolean;
+}
+
+export interface CommentMarkerIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface CommentMarkerQuery {
+  /** Only these tags (case-insensitive; default: all) */
+  tags?: string[];
+  /** Only markers in this file or under this folder */
+  path?: string;
+  /** Owner in the marker, or blame author name or email (case-insensitive) */
+  author?: string;
+  /** At least this many days old (needs blame) */
+  minAgeDays?: number;
+  /** At most this many days old (needs blame) */
+  maxAgeDays?: number;
+  /** `path` (default) or `age`, oldest first (needs blame) */
+  sort?: 'path' | 'age';
+  /** Number of markers to return (default: 500) */
+  limit?: number;
+  /** Cancellation token for aborting the blame */
+  cancellationToken?: CancellationToken;
+}
+
+export interface CommentMarkerReport {
+  /** Matching markers, before the limit */
+  total: number;
+  /** Matching markers per tag, before the limit */
+  byTag: Record<string, number>;
+  markers: CommentMarker[];
+  /** Whether markers carry blame */
+  blamed: boolean;
+  truncated: boolean;
+}
+
+/** Raised for queries that cannot be answered, e.g. an age filter without git blame */
+export class MarkerQueryError extends Error {}
+
+/** Markers matching a query, blamed when git is available; how servers expose the index */
+export type CommentMarkerSource = (query: CommentMarkerQuery) => Promise<CommentMarkerReport>;
+
+/** The part of an index the marker index reads: the background index or the library Indexer */
+export interface CommentMarkerSourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type MarkerReader = (filePath: string) => Promise<string>;
+
+/** Blame of a file (see SymbolHistoryIndex.getFileBlame), undefined when it is not tracked */
+export type MarkerBlame = (filePath: string) => Promise<BlameLine[] | undefined>;
+
+interface MarkerRule {
+  tag: string;
+  /** Matched at the start of the comment line */
+  re: RegExp;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 500;
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+/** Languages without a content scanner: full-line comments only */
+const HASH_COMMENT_EXTENSIONS = new Set(['.py', '.rb', '.sh', '.bash', '.pl', '.r', '.yaml', '.yml', '.toml']);
+const SLASH_COMMENT_EXTENSIONS = new Set(['.java', '.kt', '.cs', '.rs', '.c', '.h', '.cc', '.cpp', '.cxx', '.hpp', '.swift', '.scala', '.dart', '.php']);
+
+/** Comment delimiters and decoration before the text of a comment line */
+const COMMENT_PREFIX_RE = /^\s*(?:\/\/+|\/\*+|\*+(?!\/)|#+)?\s*/;
+
+/** `TODO`, `TODO:`, `TODO(alice):` - the word must be followed by a separator */
+const TAG_RE = /^@?([A-Za-z][A-Za-z0-9_]*)(?:\(([^)]*)\))?(?=[\s:,.-]|$)[\s:,.-]*/;
+
+export function isMarkerScannable(uri: string): boolean {
+  const ext = path.extname(uri).toLowerCase();
+  return isContentScannable(uri) || HASH_COMMENT_EXTENSIONS.has(ext) || SLASH_COMMENT_EXTENSIONS.has(ext);
+}
+
+/**
+ * Markers in the comments of a file. A marker starts a comment line:
+ * `// TODO: ...`, ` * FIXME(bob) ...`, `# HACK ...`. Tags are matched in
+ * upper case only, so prose ("todo list") is not a marker; deprecation
+ * also matches Go's `Deprecated:` and JSDoc's `@deprecated`.
+ */
+export function findCommentMarkers(uri: string, content: string, config?: Partial<CommentMarkerConfig>): CommentMarker[] {
+  const tags = new Set([...DEFAULT_MARKER_TAGS, ...(config?.tags ?? []).map(tag => tag.toUpperCase())]);
+  const rules = compilePatterns(config?.patterns ?? []);
+  const markers: CommentMarker[] = [];
+
+  for (const comment of commentsOf(uri, content)) {
+    comment.text.split('\n').forEach((raw, i) => {
+      const prefix = COMMENT_PREFIX_RE.exec(raw)![0].length;
+      const rest = raw.slice(prefix).replace(/\s*\*+\/\s*$/, '');
+      const marker = matchMarker(rest, tags, rules);
+      if (marker) {
+        markers.push({
+          ...marker,
+          uri,
+          line: comment.line + i,
+          character: (i === 0 ? comment.character : 0) + prefix
+        });
+      }
+    });
+  }
+  return markers;
+}
+
+/**
+ * Comment Marker Index - TODO, FIXME, HACK, DEPRECATED and custom comment
+ * markers across the workspace, for tech-debt reports.
+ *
+ * Like the comment search index (features/contentIndex.ts) it is built
+ * from the files of the background index and rescans only files whose
+ * hash changed. Queries filter by tag, path and author; with git blame,
+ * each marker also gets the author and age of its line, so "FIXMEs older
+ * than a year under services/billing" is one query.
+ */
+export class CommentMarkerIndex {
+  private files: Map<string, { hash: string; markers: CommentMarker[] }> = new Map();
+  private config: CommentMarkerConfig = { tags: [], patterns: [] };
+
+  constructor(
+    private index: CommentMarkerSourceIndex,
+    private readFile: MarkerReader = filePath => fs.promises.readFile(filePath, 'utf-8'),
+    private now: () => number = Date.now
+  ) {}
+
+  /**
+   * Use other custom tags and patterns; every file is rescanned on the next build.
+   */
+  setConfig(config: CommentMarkerConfig): void {
+    if (JSON.stringify(config) !== JSON.stringify(this.config)) {
+      this.config = config;
+      this.files.clear();
+    }
+  }
+
+  /**
+   * Bring the index in line with the source index.
+   */
+  async build(options: CommentMarkerIndexOptions = {}): Promise<{ files: number; markers: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles()).filter(isMarkerScannable).sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting comment markers (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        this.files.set(uri, { hash, markers: findCommentMarkers(uri, await this.readFile(uri), this.config) });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'Comment markers complete');
+    let markers = 0;
+    for (const file of this.files.values()) {
+      markers += file.markers.length;
+    }
+    return { files: this.files.size, markers, updated };
+  }
+
+  /**
+   * Markers matching the query. Without blame, age filters and age
+   * sorting are rejected and markers carry no author or date.
+   */
+  async query(query: CommentMarkerQuery = {}, blame?: MarkerBlame): Promise<CommentMarkerReport> {
+    const usesAge = query.minAgeDays !== undefined || query.maxAgeDays !== undefined || query.sort === 'age';
+    if (usesAge && !blame) {
+      throw new MarkerQueryError('Filtering or sorting markers by age needs git blame');
+    }
+    const tags = query.tags && query.tags.length > 0 ? new Set(query.tags.map(tag => tag.toUpperCase())) : undefined;
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const author = query.author?.toLowerCase();
+    const limit = query.limit ?? DEFAULT_LIMIT;
+
+    const uris = [...this.files.keys()]
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const matches: CommentMarker[] = [];
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(query.cancellationToken);
+        await yieldToEventLoop();
+      }
+      let markers = this.files.get(uris[i])!.markers.filter(marker => !tags || tags.has(marker.tag));
+      if (markers.length === 0) {
+        continue;
+      }
+      if (blame) {
+        const lines = await blame(uris[i]);
+        markers = markers.map(marker => this.withBlame(marker, lines?.[marker.line]));
+      }
+      matches.push(...markers.filter(marker =>
+        (!author || [marker.owner, marker.author, marker.authorEmail].some(name => name?.toLowerCase() === author)) &&
+        (query.minAgeDays === undefined || (marker.ageDays ?? -1) >= query.minAgeDays) &&
+        (query.maxAgeDays === undefined || (marker.ageDays !== undefined && marker.ageDays <= query.maxAgeDays))
+      ));
+    }
+
+    if (query.sort === 'age') {
+      // Oldest first; markers without blame (untracked files) last
+      matches.sort((a, b) => (b.ageDays ?? -1) - (a.ageDays ?? -1));
+    }
+    const byTag: Record<string, number> = {};
+    for (const marker of matches) {
+      byTag[marker.tag] = (byTag[marker.tag] ?? 0) + 1;
+    }
+    return {
+      total: matches.length,
+      byTag,
+      markers: matches.slice(0, limit),
+      blamed: !!blame,
+      truncated: matches.length > limit
+    };
+  }
+
+  private withBlame(marker: CommentMarker, line: BlameLine | undefined): CommentMarker {
+    if (!line) {
+      return marker;
+    }
+    if (line.commit === UNCOMMITTED) {
+      return { ...marker, author: 'Not Committed Yet', date: new Date(this.now()).toISOString(), ageDays: 0, uncommitted: true };
+    }
+    return {
+      ...marker,
+      author: line.author,
+      authorEmail: line.authorEmail,
+      commit: line.commit,
+      date: new Date(line.time * 1000).toISOString(),
+      ageDays: Math.floor((this.now() - line.time * 1000) / DAY_MS)
+    };
+  }
+}
+
+function matchMarker(
+  rest: string,
+  tags: Set<string>,
+  rules: MarkerRule[]
+): Pick<CommentMarker, 'tag' | 'text' | 'owner'> | null {
+  for (const rule of rules) {
+    const match = rule.re.exec(rest);
+    if (match) {
+      const owner = match.groups?.owner;
+      return { tag: rule.tag, text: rest.slice(match[0].length).replace(/^[\s:,.-]+/, '').trim(), ...(owner ? { owner } : {}) };
+    }
+  }
+
+  const match = TAG_RE.exec(rest);
+  if (!match) {
+    return null;
+  }
+  const [matched, word, owner] = match;
+  const deprecation = word.toLowerCase() === 'deprecated' && (rest.startsWith('@') || /^\w+:/.test(rest));
+  if (!deprecation && (rest.startsWith('@') || !tags.has(word))) {
+    return null;
+  }
+  return {
+    tag: deprecation ? 'DEPRECATED' : word,
+    text: rest.slice(matched.length).trim(),
+    ...(owner?.trim() ? { owner: owner.trim() } : {})
+  };
+}
+
+function compilePatterns(patterns: CommentMarkerConfig['patterns']): MarkerRule[] {
+  const rules: MarkerRule[] = [];
+  for (const { tag, pattern } of patterns) {
+    try {
+      rules.push({ tag: tag.toUpperCase(), re: new RegExp(`^(?:${pattern})`) });
+    } catch {
+      // Invalid patterns are skipped; the configuration layer reports them
+    }
+  }
+  return rules;
+}
+
+/**
+ * Comment text with the position of its first character.
+ */
+function commentsOf(uri: string, content: string): Array<{ text: string; line: number; character: number }> {
+  if (isContentScannable(uri)) {
+    return scanContent(uri, content).filter(span => span.kind !== 'string');
+  }
+  const ext = path.extname(uri).toLowerCase();
+  const prefix = HASH_COMMENT_EXTENSIONS.has(ext) ? '#' : SLASH_COMMENT_EXTENSIONS.has(ext) ? '//' : undefined;
+  if (!prefix) {
+    return [];
+  }
+  const comments: Array<{ text: string; line: number; character: number }> = [];
+  content.split('\n').forEach((text, line) => {
+    if (text.trimStart().startsWith(prefix)) {
+      comments.push({ text, line, character: 0 });
+    }
+  });
+  return comments;
+}
@@ -0,0 +1,86 @@
+/**
+ * Completion Tests
+ *
+ * Symbol and path completions from the index, and the generated bash,
+ * zsh and fish scripts.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { completionScript, IndexCompletions } from './completions.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+describe('IndexCompletions', () => {
+  let completions: IndexCompletions;
+
+  beforeEach(() => {
+    const symbols = [
+      createTestSymbol({ name: 'Person', kind: 'struct', filePath: '/ws/pkg/user/person.go' }),
+      createTestSymbol({ name: 'Greet', kind: 'method', containerName: 'Person', filePath: '/ws/pkg/user/person.go' }),
+      createTestSymbol({ name: 'Grow', kind: 'method', containerName: 'Plant', filePath: '/ws/pkg/user/person.go' }),
+      createTestSymbol({ name: 'PersonStore', kind: 'interface', filePath: '/ws/pkg/user/store.go' }),
+      createTestSymbol({ name: 'Peek', kind: 'function', filePath: '/ws/pkg/util.go' })
+    ];
+    const files = ['/ws/pkg/user/person.go', '/ws/pkg/user/store.go', '/ws/pkg/util.go', '/ws/README.md'];
+    completions = new IndexCompletions({
+      // Fuzzy, like the real search: the prefix is matched afterwards
+      searchSymbols: async (query, limit) => symbols.filter(s => s.name.toLowerCase().includes(query.toLowerCase()[0])).slice(0, limit),
+      getAllFiles: async () => files
+    }, () => ['/ws']);
+  });
+
+  it('should complete symbol names and members', async () => {
+    expect(await completions.complete({ kind: 'symbol', prefix: 'Pers' })).toEqual(['Person', 'PersonStore']);
+    expect(await completions.complete({ kind: 'symbol', prefix: 'Person.Gr' })).toEqual(['Person.Greet']);
+    expect(await completions.complete({ kind: 'symbol', prefix: 'Pe', limit: 2, describe: true })).toEqual(['Peek\tfunction', 'Person\tstruct']);
+    expect(await completions.complete({ kind: 'symbol', prefix: '' })).toEqual([]);
+  });
+
+  it('should complete indexed paths one segment at a time', async () => {
+    expect(await completions.complete({ kind: 'file', prefix: '' })).toEqual(['README.md', 'pkg/']);
+    expect(await completions.complete({ kind: 'file', prefix: 'pkg/' })).toEqual(['pkg/user/', 'pkg/util.go']);
+    expect(await completions.complete({ kind: 'file', prefix: 'pkg/user/p' })).toEqual(['pkg/user/person.go']);
+    expect(await completions.complete({ kind: 'file', prefix: 'user/s', cwd: '/ws/pkg' })).toEqual(['user/store.go']);
+    expect(await completions.complete({ kind: 'file', prefix: '../', cwd: '/ws/pkg' })).toEqual(['../README.md', '../pkg/']);
+  });
+});
+
+describe('completionScript', () => {
+  it('should ask the daemon for the arguments of search, refs and outline', () => {
+    const bash = completionScript('bash');
+    expect(bash).toContain('complete -F _smart_indexer smart-indexer');
+    expect(bash).toContain('.smart-index/daemon.sock');
+    expect(bash).toContain('search|refs) kind=symbol');
+    // Fails on syntax errors
+    execFileSync('bash', ['-n'], { input: bash });
+
+    const zsh = completionScript('zsh', { command: 'si' });
+    expect(zsh.startsWith('#compdef si\n')).toBe(true);
+    expect(zsh).toContain('compdef _si si');
+
+    const fish = completionScript('fish', { url: 'http://127.0.0.1:7717/' });
+    expect(fish).toContain("curl -sf --max-time 1 'http://127.0.0.1:7717/complete'");
+    expect(fish).toContain("complete -c smart-indexer -n '__fish_seen_subcommand_from outline' -a '(_smart_indexer_query file (commandline -ct))'");
+  });
+
+  it('should reject names and URLs that would break the script', () => {
+    expect(() => completionScript('bash', { command: 'rm -rf' })).toThrow('Invalid command name');
+    expect(() => completionScript('bash', { url: 'http://x/ $(id)' })).toThrow('Invalid query server URL');
+    expect(() => completionScript('bash', { url: 'file:///etc/passwd' })).toThrow('Invalid query server URL');
+  });
+
+  it('should quote the URL so it is passed to curl as one word', () => {
+    const marker = path.join(fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-completion-')), 'injected');
+    const url = `http://127.0.0.1:1/'$(id>${marker})'\\`;
+    const bash = completionScript('bash', { url });
+    expect(bash).toContain(`curl -sf --max-time 1 'http://127.0.0.1:1/'\\''$(id>${marker})'\\''\\/complete'`);
+    execFileSync('bash', ['-c', `${bash}\n_smart_indexer_query symbol P || true`]);
+    expect(fs.existsSync(marker)).toBe(false);
+
+    const fish = completionScript('fish', { url });
+    expect(fish).toContain(`curl -sf --max-time 1 'http://127.0.0.1:1/\\'$(id>${marker})\\'\\\\/complete'`);
+  });
+});
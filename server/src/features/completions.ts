@@ -0,0 +1,273 @@
+import * as path from 'path';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { CONFIG_FILE_NAMES } from '../config/configFile.js';
+import { DAEMON_SOCKET } from './daemon.js';
+
+export const COMPLETION_KINDS = ['symbol', 'file'] as const;
+export type CompletionKind = typeof COMPLETION_KINDS[number];
+
+export const COMPLETION_SHELLS = ['bash', 'zsh', 'fish'] as const;
+export type CompletionShell = typeof COMPLETION_SHELLS[number];
+
+/** What completions are looked up in */
+export type CompletionSourceIndex = Pick<ISymbolIndex, 'searchSymbols'> & Pick<BackgroundIndex, 'getAllFiles'>;
+
+export interface CompletionQuery {
+  kind: CompletionKind;
+  /** The word being completed: `Per`, `Person.Gr`, `pkg/us` */
+  prefix: string;
+  /** Folder file prefixes are relative to, the shell's working directory (default: the first root) */
+  cwd?: string;
+  limit?: number;
+  /** Add the kind of symbols, tab-separated, as fish shows it */
+  describe?: boolean;
+}
+
+/** Answers completion queries, e.g. IndexCompletions.complete */
+export type CompletionSource = (query: CompletionQuery) => Promise<string[]>;
+
+export interface CompletionScriptOptions {
+  /** Command the script completes (default: `smart-indexer`) */
+  command?: string;
+  /**
+   * Query server to ask (`http://127.0.0.1:7717`); by default the daemon
+   * whose socket is in the working directory or a folder above it
+   */
+  url?: string;
+}
+
+const DEFAULT_COMPLETION_LIMIT = 100;
+/** Symbols searched per completion, before matching the prefix */
+const SEARCH_CANDIDATES = 500;
+const DEFAULT_COMMAND = 'smart-indexer';
+
+/** Subcommands of the CLI (see cli/cli.ts) and the kind of argument they complete */
+const SUBCOMMANDS: Array<[string, CompletionKind | undefined]> = [
+  ['search', 'symbol'], ['refs', 'symbol'], ['outline', 'file'], ['batch', undefined], ['index', undefined], ['daemon', undefined], ['completion', undefined]
+];
+
+/**
+ * Index Completions - the symbol names and file paths a shell offers for
+ * a partly typed word, taken from the index rather than from a fixed
+ * list of flags.
+ *
+ * Symbols complete by name (`Per` -> `Person`, `PersonStore`) or, after
+ * a dot, by member (`Person.Gr` -> `Person.Greet`). Files complete one
+ * path segment at a time like the shell's own completion, folders with a
+ * trailing slash, but only to indexed files.
+ */
+export class IndexCompletions {
+  constructor(private index: CompletionSourceIndex, private roots: () => string[]) {}
+
+  async complete(query: CompletionQuery): Promise<string[]> {
+    const limit = query.limit ?? DEFAULT_COMPLETION_LIMIT;
+    return query.kind === 'file'
+      ? this.completeFile(query.prefix, query.cwd ?? this.roots()[0] ?? process.cwd(), limit)
+      : this.completeSymbol(query.prefix, limit, query.describe ?? false);
+  }
+
+  private async completeSymbol(prefix: string, limit: number, describe: boolean): Promise<string[]> {
+    const dot = prefix.lastIndexOf('.');
+    const container = dot > 0 ? prefix.substring(0, dot) : undefined;
+    const member = prefix.substring(dot + 1);
+    if (!member && !container) {
+      return [];
+    }
+
+    const kinds = new Map<string, string>();
+    for (const symbol of await this.index.searchSymbols(member || container!, SEARCH_CANDIDATES)) {
+      if (symbol.isDefinition === false) {
+        continue;
+      }
+      const matches = container === undefined
+        ? symbol.name.startsWith(member)
+        : symbol.containerName === container && symbol.name.startsWith(member);
+      const candidate = container === undefined ? symbol.name : `${container}.${symbol.name}`;
+      if (matches && !kinds.has(candidate)) {
+        kinds.set(candidate, symbol.kind);
+      }
+    }
+    return [...kinds.keys()]
+      .sort()
+      .slice(0, limit)
+      .map(candidate => describe ? `${candidate}\t${kinds.get(candidate)}` : candidate);
+  }
+
+  private async completeFile(prefix: string, cwd: string, limit: number): Promise<string[]> {
+    const slash = prefix.lastIndexOf('/');
+    const typedDir = prefix.substring(0, slash + 1);
+    const partial = prefix.substring(slash + 1);
+    const dir = path.resolve(cwd, typedDir || '.');
+
+    const candidates = new Set<string>();
+    for (const file of await this.index.getAllFiles()) {
+      const relative = path.relative(dir, file);
+      if (relative.startsWith('..') || path.isAbsolute(relative)) {
+        continue;
+      }
+      const [segment, ...rest] = relative.split(path.sep);
+      if (segment.startsWith(partial)) {
+        candidates.add(typedDir + segment + (rest.length > 0 ? '/' : ''));
+      }
+    }
+    return [...candidates].sort().slice(0, limit);
+  }
+}
+
+/**
+ * A completion script for bash, zsh or fish. Subcommands complete
+ * statically; the arguments of `search` and `refs` (symbols) and
+ * `outline` (files) are asked from the query server's /complete endpoint
+ * with curl, by default the socket of a daemon the user owns in the
+ * working directory or above it, up to the repository root (see
+ * features/daemon.ts). Without a reachable server only subcommands
+ * complete.
+ *
+ *   source <(smart-indexer completion bash)
+ */
+export function completionScript(shell: CompletionShell, options: CompletionScriptOptions = {}): string {
+  const command = options.command ?? DEFAULT_COMMAND;
+  if (!/^[A-Za-z0-9._-]+$/.test(command)) {
+    throw new Error(`Invalid command name for completion: ${command}`);
+  }
+  if (options.url !== undefined && !/^https?:\/\/\S+$/.test(options.url)) {
+    throw new Error(`Invalid query server URL for completion: ${options.url}`);
+  }
+  const name = `_${command.replace(/[^A-Za-z0-9]/g, '_')}`;
+  switch (shell) {
+    case 'bash':
+      return bashScript(command, name, options.url);
+    case 'zsh':
+      return zshScript(command, name, options.url);
+    case 'fish':
+      return fishScript(command, name, options.url);
+    default:
+      throw new Error(`Unsupported shell: ${shell}; use ${COMPLETION_SHELLS.join(', ')}`);
+  }
+}
+
+/** A word in single quotes: a quote ends the string, so it is written as '\'' */
+const posixQuote = (word: string) => `'${word.replace(/'/g, `'\\''`)}'`;
+
+/** A word in fish's single quotes, where backslashes and quotes are escaped */
+const fishQuote = (word: string) => `'${word.replace(/[\\']/g, '\\$&')}'`;
+
+/** Files that end the search for a daemon socket, as in repositoryRoot */
+const ROOT_MARKERS = ['.git', ...CONFIG_FILE_NAMES];
+
+const subcommandsOf = (kind: CompletionKind) => SUBCOMMANDS.filter(([, k]) => k === kind).map(([subcommand]) => subcommand);
+
+/** A POSIX function printing the completions of kind ($1) for prefix ($2) */
+function posixQuery(name: string, url: string | undefined): string[] {
+  const request = (target: string) =>
+    `  curl -sf --max-time 1 ${target} -G --data-urlencode "kind=$1" --data-urlencode "prefix=$2" --data-urlencode "cwd=$PWD" 2>/dev/null`;
+  if (url !== undefined) {
+    return [`${name}_query() {`, request(posixQuote(`${url.replace(/\/+$/, '')}/complete`)), '}'];
+  }
+  return [
+    `${name}_query() {`,
+    '  local dir="$PWD" sock="" marker',
+    '  while :; do',
+    `    if [ -S "$dir/${DAEMON_SOCKET}" ] && [ -O "$dir/${DAEMON_SOCKET}" ]; then sock="$dir/${DAEMON_SOCKET}"; break; fi`,
+    `    for marker in ${ROOT_MARKERS.join(' ')}; do [ -e "$dir/$marker" ] && return 0; done`,
+    '    [ "$dir" = / ] && return 0',
+    '    dir="$(dirname "$dir")"',
+    '  done',
+    request('--unix-socket "$sock" http://localhost/complete'),
+    '}'
+  ];
+}
+
+function bashScript(command: string, name: string, url: string | undefined): string {
+  return [
+    `# bash completion for ${command}; source it from ~/.bashrc`,
+    ...posixQuery(name, url),
+    '',
+    `${name}() {`,
+    '  local cur="${COMP_WORDS[COMP_CWORD]}" kind',
+    '  if [ "$COMP_CWORD" -eq 1 ]; then',
+    `    COMPREPLY=($(compgen -W "${SUBCOMMANDS.map(([subcommand]) => subcommand).join(' ')}" -- "$cur"))`,
+    '    return',
+    '  fi',
+    '  case "${COMP_WORDS[1]}" in',
+    `    ${subcommandsOf('symbol').join('|')}) kind=symbol ;;`,
+    `    ${subcommandsOf('file').join('|')}) kind=file ;;`,
+    '    *) return ;;',
+    '  esac',
+    '  local IFS=$\'\\n\'',
+    `  COMPREPLY=($(${name}_query "$kind" "$cur"))`,
+    '  # Folders complete further instead of ending the word',
+    '  if [ "${#COMPREPLY[@]}" -eq 1 ] && [ "${COMPREPLY[0]%/}" != "${COMPREPLY[0]}" ]; then',
+    '    compopt -o nospace 2>/dev/null',
+    '  fi',
+    '}',
+    `complete -F ${name} ${command}`,
+    ''
+  ].join('\n');
+}
+
+function zshScript(command: string, name: string, url: string | undefined): string {
+  return [
+    `#compdef ${command}`,
+    `# zsh completion for ${command}; put it on $fpath as ${name}, or source it after compinit`,
+    ...posixQuery(name, url),
+    '',
+    `${name}() {`,
+    '  local -a suggestions folders',
+    '  if (( CURRENT == 2 )); then',
+    `    compadd -- ${SUBCOMMANDS.map(([subcommand]) => subcommand).join(' ')}`,
+    '    return',
+    '  fi',
+    '  case ${words[2]} in',
+    `    ${subcommandsOf('symbol').join('|')})`,
+    `      suggestions=(\${(f)"$(${name}_query symbol "\${words[CURRENT]}")"})`,
+    '      compadd -- $suggestions',
+    '      ;;',
+    `    ${subcommandsOf('file').join('|')})`,
+    `      suggestions=(\${(f)"$(${name}_query file "\${words[CURRENT]}")"})`,
+    '      folders=(${(M)suggestions:#*/})',
+    '      compadd -S \'\' -- $folders',
+    '      compadd -- ${suggestions:#*/}',
+    '      ;;',
+    '  esac',
+    '}',
+    '',
+    `if [ "$funcstack[1]" = "${name}" ]; then`,
+    `  ${name} "$@"`,
+    'else',
+    `  compdef ${name} ${command}`,
+    'fi',
+    ''
+  ].join('\n');
+}
+
+function fishScript(command: string, name: string, url: string | undefined): string {
+  const request = (target: string) =>
+    `    curl -sf --max-time 1 ${target} -G --data-urlencode "kind=$argv[1]" --data-urlencode "prefix=$argv[2]" --data-urlencode "cwd=$PWD" --data-urlencode describe=true 2>/dev/null`;
+  const query = url !== undefined
+    ? [`function ${name}_query`, request(fishQuote(`${url.replace(/\/+$/, '')}/complete`)), 'end']
+    : [
+      `function ${name}_query`,
+      '    set -l dir $PWD',
+      `    while not test -S "$dir/${DAEMON_SOCKET}" -a -O "$dir/${DAEMON_SOCKET}"`,
+      `        for marker in ${ROOT_MARKERS.join(' ')}`,
+      '            test -e "$dir/$marker"; and return 0',
+      '        end',
+      '        test "$dir" = /; and return 0',
+      '        set dir (dirname "$dir")',
+      '    end',
+      request(`--unix-socket "$dir/${DAEMON_SOCKET}" http://localhost/complete`),
+      'end'
+    ];
+  return [
+    `# fish completion for ${command}; put it in ~/.config/fish/completions/${command}.fish`,
+    ...query,
+    '',
+    `complete -c ${command} -f`,
+    `complete -c ${command} -n __fish_use_subcommand -a '${SUBCOMMANDS.map(([subcommand]) => subcommand).join(' ')}'`,
+    `complete -c ${command} -n '__fish_seen_subcommand_from ${subcommandsOf('symbol').join(' ')}' -a '(${name}_query symbol (commandline -ct))'`,
+    `complete -c ${command} -n '__fish_seen_subcommand_from ${subcommandsOf('file').join(' ')}' -a '(${name}_query file (commandline -ct))'`,
+    ''
+  ].join('\n');
+}
@@ -0,0 +1,185 @@
+/**
+ * Concurrency Tests
+ *
+ * Verifies goroutine, channel and mutex extraction (select cases, ranges
+ * over channels, deferred unlocks) and the concurrency index: enclosing
+ * functions and closures, op, package and target filters, rescans.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ConcurrencyIndex, findConcurrency } from './concurrency.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const POOL_GO = '/ws/internal/worker/pool.go';
+const CACHE_GO = '/ws/internal/cache/cache.go';
+
+const poolGo = `package worker
+
+import (
+	"context"
+	"sync"
+)
+
+type Pool struct {
+	mu      sync.RWMutex
+	jobs    chan Job
+	results map[string]int
+}
+
+func NewPool(size int) *Pool {
+	p := &Pool{jobs: make(chan Job, size)}
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	for job := range p.jobs {
+		p.mu.Lock()
+		p.results[job.ID]++
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) Result(id string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.results[id]
+}
+
+func Run(ctx context.Context, p *Pool, batch []Job) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, job := range batch {
+			select {
+			case p.jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	<-done
+	return ctx.Err()
+}
+`;
+
+const cacheGo = `package cache
+
+import "sync"
+
+var mu sync.Mutex
+
+func Start(refresh func(), out chan<- string) {
+	go refresh()
+	mu.Lock()
+	out <- "started"
+	mu.Unlock()
+}
+`;
+
+describe('findConcurrency', () => {
+  it('should find goroutines, channel operations and mutex calls', () => {
+    const events = findConcurrency(POOL_GO, poolGo);
+
+    expect(events.map(event => [event.op, event.target, event.line])).toEqual([
+      ['make', 'jobs', 14],
+      ['go', 'p.work', 16],
+      ['receive', 'p.jobs', 22],
+      ['lock', 'p.mu', 23],
+      ['unlock', 'p.mu', 25],
+      ['lock', 'p.mu', 30],
+      ['unlock', 'p.mu', 31],
+      ['make', 'done', 36],
+      ['go', 'func literal', 37],
+      ['close', 'done', 38],
+      ['send', 'p.jobs', 41],
+      ['receive', 'ctx.Done()', 42],
+      ['receive', 'done', 47]
+    ]);
+    expect(events[0]).toMatchObject({ package: 'worker', character: 18, type: 'chan Job', buffered: true });
+    expect(events[2].range).toBe(true);
+    expect(events[5].read).toBe(true);
+    expect(events[6]).toMatchObject({ read: true, deferred: true });
+    expect(events[7].buffered).toBeUndefined();
+    expect(events[9].deferred).toBe(true);
+    expect([events[10].select, events[11].select, events[12].select]).toEqual([true, true, undefined]);
+  });
+
+  it('should ignore channel types and ranges over other values', () => {
+    const events = findConcurrency('/ws/pipe.go', `package pipe
+
+func Merge(in <-chan int, ids []int) <-chan int {
+	var out chan<- int
+	for _, id := range ids {
+		use(id, out)
+	}
+	return nil
+}
+`);
+    expect(events).toEqual([]);
+  });
+});
+
+describe('ConcurrencyIndex', () => {
+  let index: MockBackgroundIndex;
+  let concurrency: ConcurrencyIndex;
+  let contents: Record<string, string>;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, hash?: string): void {
+    contents[uri] = content;
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(hash ? { hash } : {}) });
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = {};
+    addGoFile(POOL_GO, poolGo);
+    addGoFile(CACHE_GO, cacheGo);
+    addGoFile('/ws/internal/worker/pool_test.go', 'package worker\n\nfunc TestRun(t *testing.T) { go Run(nil, nil, nil) }\n');
+    concurrency = new ConcurrencyIndex(index.asBackgroundIndex(), async filePath => contents[filePath]);
+    await concurrency.build();
+  });
+
+  it('should list the goroutine call sites of a package', () => {
+    const report = concurrency.query({ op: 'go', package: 'worker' });
+
+    expect(report).toMatchObject({ total: 2, byOp: { go: 2 }, truncated: false });
+    expect(report.events.map(event => [event.function, event.target])).toEqual([
+      ['NewPool', 'p.work'],
+      ['Run', 'func literal']
+    ]);
+    expect(report.events[0].functionLocation).toMatchObject({ uri: POOL_GO, line: 13 });
+    expect(concurrency.query({ op: 'go', package: 'internal/cache' }).events.map(event => event.target)).toEqual(['refresh']);
+  });
+
+  it('should attribute events in goroutine bodies to their closure', () => {
+    const report = concurrency.query({ path: POOL_GO, target: 'done' });
+
+    expect(report.functions.map(usage => [usage.name, usage.ops, usage.channels])).toEqual([
+      ['Run', { make: 1, receive: 1 }, ['done']],
+      ['Run$1', { close: 1 }, ['done']]
+    ]);
+  });
+
+  it('should summarize mutex use per function', () => {
+    const report = concurrency.query({ target: 'mu' });
+
+    expect(report.byOp).toEqual({ lock: 3, unlock: 3 });
+    expect(report.functions.map(usage => [usage.name, usage.package, usage.mutexes])).toEqual([
+      ['Start', 'cache', ['mu']],
+      ['Pool.work', 'worker', ['p.mu']],
+      ['Pool.Result', 'worker', ['p.mu']]
+    ]);
+    expect(concurrency.query({ limit: 1 }).truncated).toBe(true);
+  });
+
+  it('should rescan changed files only', async () => {
+    addGoFile(CACHE_GO, cacheGo.replace('go refresh()', 'go refresh()\n\tgo refresh()'), 'changed');
+    expect(await concurrency.build()).toMatchObject({ files: 2, events: 18, updated: 1 });
+  });
+});
@@ -0,0 +1,498 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { GO_KEYWORDS, GoToken, GoTokenizer } from '../indexer/components/GoTokenizer.js';
+import { assignedName, attachFunctions, matchBrackets, splitArgs } from '../indexer/components/GoTokenUtils.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/**
+ * `go` statements, channel `make`, send, receive and `close`, and
+ * Lock/Unlock calls on mutexes.
+ */
+export type ConcurrencyOp = 'go' | 'make' | 'send' | 'receive' | 'close' | 'lock' | 'unlock';
+
+export const CONCURRENCY_OPS: ConcurrencyOp[] = ['go', 'make', 'send', 'receive', 'close', 'lock', 'unlock'];
+
+export interface ConcurrencyEvent {
+  uri: string;
+  /** Name in the file's package clause */
+  package: string;
+  /** 0-based position of `go`, `make`, `<-`, `range`, `close` or the Lock/Unlock method */
+  line: number;
+  character: number;
+  op: ConcurrencyOp;
+  /**
+   * What it acts on, as written: the function a goroutine runs (`s.worker`,
+   * `func literal`), the channel (`s.jobs`, `ctx.Done()`) or the mutex
+   * (`s.mu`). Absent for channels made in place, e.g. `return make(chan int)`.
+   */
+  target?: string;
+  /** make: the channel type, e.g. `chan Job` */
+  type?: string;
+  /** make: with a buffer size */
+  buffered?: boolean;
+  /** receive: a `for range` over the channel */
+  range?: boolean;
+  /** send, receive: a case of a select statement */
+  select?: boolean;
+  /** lock, unlock: RLock, RUnlock or TryRLock */
+  read?: boolean;
+  /** close, lock, unlock: run by a defer statement */
+  deferred?: boolean;
+  /** Function, method or closure the event is in, e.g. `Pool.Run` or `Pool.Run$1` */
+  function?: string;
+  functionLocation?: SymbolLocation;
+}
+
+export interface ConcurrencyIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ConcurrencyQuery {
+  op?: ConcurrencyOp;
+  /** Only events in this package: a package name (`worker`) or a folder path suffix (`internal/worker`) */
+  package?: string;
+  /** Only events on this channel, mutex or function; `jobs` also matches `s.jobs` */
+  target?: string;
+  /** Only events in this file or under this folder */
+  path?: string;
+  /** Number of events to return (default: 1000); functions are counted over all of them */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface ConcurrencyFunctionUsage {
+  /** Qualified function name; undefined for events outside functions */
+  name?: string;
+  uri: string;
+  package: string;
+  location?: SymbolLocation;
+  /** Matching events in the function */
+  events: number;
+  ops: Partial<Record<ConcurrencyOp, number>>;
+  /** Channels it makes, sends on, receives from or closes */
+  channels: string[];
+  /** Mutexes it locks or unlocks */
+  mutexes: string[];
+}
+
+export interface ConcurrencyReport {
+  /** Matching events, before the limit */
+  total: number;
+  /** Matching events per operation, before the limit */
+  byOp: Partial<Record<ConcurrencyOp, number>>;
+  /** In path and position order */
+  events: ConcurrencyEvent[];
+  /** Functions with matching events, most events first */
+  functions: ConcurrencyFunctionUsage[];
+  truncated: boolean;
+}
+
+/** Events matching a query; how servers expose the index */
+export type ConcurrencySource = (query: ConcurrencyQuery) => Promise<ConcurrencyReport>;
+
+/** The part of an index the concurrency index reads: the background index or the library Indexer */
+export interface ConcurrencySourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+  /** Declarations, for the function each event is in */
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type ConcurrencyReader = (filePath: string) => Promise<string>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+/** sync.Mutex, sync.RWMutex and sync.Locker methods, and whether they take the read lock */
+const LOCK_METHODS: Record<string, { op: 'lock' | 'unlock'; read: boolean }> = {
+  Lock: { op: 'lock', read: false },
+  TryLock: { op: 'lock', read: false },
+  RLock: { op: 'lock', read: true },
+  TryRLock: { op: 'lock', read: true },
+  Unlock: { op: 'unlock', read: false },
+  RUnlock: { op: 'unlock', read: true }
+};
+
+const FUNCTION_KINDS = new Set(['function', 'method', 'closure']);
+
+/**
+ * Goroutine launches, channel operations and mutex calls in a Go file.
+ *
+ * Channels are told apart by syntax: `make(chan T)`, `<-` and `close`.
+ * A `for range` counts as a receive when the ranged name is declared
+ * with a channel type or made with `make(chan ...)` in the file. Lock
+ * and Unlock calls without arguments are taken as mutex calls, whatever
+ * the receiver's type, so embedded mutexes (`s.Lock()`) are seen too.
+ */
+export function findConcurrency(uri: string, content: string): ConcurrencyEvent[] {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens } = tokenizer.tokenize();
+  const closing = matchBrackets(tokens);
+  const packageName = tokens[0]?.text === 'package' && tokens[1]?.type === 'ident' ? tokens[1].text : '';
+  const channels = channelNames(tokens, closing);
+  const selects = new Set<number>();
+  const braces: number[] = [];
+  const events: ConcurrencyEvent[] = [];
+
+  const text = (start: number, end: number) => content.slice(tokens[start].offset, tokens[end].end).replace(/\s+/g, ' ');
+  const push = (token: GoToken, op: ConcurrencyOp, details: Partial<ConcurrencyEvent>) => {
+    const position = tokenizer.positionAt(token.offset);
+    events.push({ uri, package: packageName, line: position.line, character: position.character, op, ...details });
+  };
+
+  for (let i = 0; i < tokens.length; i++) {
+    const token = tokens[i];
+    const previous = tokens[i - 1];
+    const next = tokens[i + 1];
+
+    if (token.type === 'punct') {
+      if (token.text === '{') {
+        braces.push(i);
+      } else if (token.text === '}') {
+        braces.pop();
+      } else if (token.text === '<-' && next?.text !== 'chan' && previous?.text !== 'chan') {
+        const inSelect = selects.has(braces[braces.length - 1]) && inCaseHeader(tokens, i);
+        // A line ending in an operand ends a statement: `<-done` on the next line is a receive
+        if (previous && previous.line === token.line && endsOperand(previous)) {
+          // ch <- v
+          push(token, 'send', { target: text(operandBefore(tokens, i - 1, closing), i - 1), ...(inSelect ? { select: true } : {}) });
+        } else {
+          const end = operandAfter(tokens, i + 1, closing);
+          push(token, 'receive', { ...(end > i ? { target: text(i + 1, end) } : {}), ...(inSelect ? { select: true } : {}) });
+        }
+      }
+      continue;
+    }
+    if (token.type !== 'ident') {
+      continue;
+    }
+    const method = LOCK_METHODS[token.text];
+    if (previous?.text === '.') {
+      if (method && next?.text === '(' && tokens[i + 2]?.text === ')') {
+        const start = operandBefore(tokens, i - 2, closing);
+        push(token, method.op, {
+          target: text(start, i - 2),
+          ...(method.read ? { read: true } : {}),
+          ...(tokens[start - 1]?.text === 'defer' ? { deferred: true } : {})
+        });
+      }
+      continue;
+    }
+
+    switch (token.text) {
+      case 'select':
+        if (next?.text === '{') {
+          selects.add(i + 1);
+        }
+        break;
+      case 'go': {
+        if (next?.text === 'func') {
+          push(token, 'go', { target: 'func literal' });
+          break;
+        }
+        const end = selectorEnd(tokens, i + 1);
+        push(token, 'go', end > i ? { target: text(i + 1, end) } : {});
+        break;
+      }
+      case 'range': {
+        const end = operandAfter(tokens, i + 1, closing);
+        if (end > i && tokens[end].type === 'ident' && channels.has(tokens[end].text)) {
+          push(token, 'receive', { target: text(i + 1, end), range: true });
+        }
+        break;
+      }
+      case 'make': {
+        if (next?.text !== '(' || (tokens[i + 2]?.text !== 'chan' && tokens[i + 3]?.text !== 'chan')) {
+          break;
+        }
+        const args = splitArgs(tokens, i + 1, closing[i + 1]);
+        const name = madeChannelName(tokens, i);
+        push(token, 'make', {
+          ...(name ? { target: name } : {}),
+          type: text(args[0].start, args[0].end - 1),
+          ...(args.length > 1 && !(args[1].end - args[1].start === 1 && tokens[args[1].start].text === '0') ? { buffered: true } : {})
+        });
+        break;
+      }
+      case 'close': {
+        const args = next?.text === '(' ? splitArgs(tokens, i + 1, closing[i + 1]) : [];
+        if (args.length === 1) {
+          push(token, 'close', { target: text(args[0].start, args[0].end - 1), ...(tokens[i - 1]?.text === 'defer' ? { deferred: true } : {}) });
+        }
+        break;
+      }
+    }
+
+  }
+  return events;
+}
+
+/**
+ * Concurrency Index - where Go code starts goroutines, makes, sends on,
+ * receives from and closes channels, and locks mutexes, so "which
+ * functions spawn goroutines in package worker" is one query during a
+ * concurrency review.
+ *
+ * Like the SQL query index (features/sqlQueries.ts) it is built from the
+ * files of the background index, rescans only files whose hash changed
+ * and leaves test files out. Each event is attributed to the innermost
+ * function, method or closure it is in (see indexer/goIndexer.ts), so the
+ * body of `go func() { ... }()` is told apart from the function starting it.
+ */
+export class ConcurrencyIndex {
+  private files: Map<string, { hash: string; events: ConcurrencyEvent[] }> = new Map();
+
+  constructor(
+    private index: ConcurrencySourceIndex,
+    private readFile: ConcurrencyReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the index in line with the source index.
+   */
+  async build(options: ConcurrencyIndexOptions = {}): Promise<{ files: number; events: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles())
+      .filter(uri => path.extname(uri) === '.go' && !uri.endsWith('_test.go'))
+      .sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting goroutines and channels (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        const events = findConcurrency(uri, await this.readFile(uri));
+        if (events.length > 0) {
+          attachFunctions(events, await this.index.getFileSymbols(uri), FUNCTION_KINDS);
+        }
+        this.files.set(uri, { hash, events });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'Concurrency analysis complete');
+    let events = 0;
+    for (const file of this.files.values()) {
+      events += file.events.length;
+    }
+    return { files: this.files.size, events, updated };
+  }
+
+  /**
+   * Events matching the query, and the functions they are in.
+   */
+  query(query: ConcurrencyQuery = {}): ConcurrencyReport {
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const limit = query.limit ?? DEFAULT_LIMIT;
+    const folder = query.package?.includes('/') ? path.sep + query.package.replace(/^\/+|\/+$/g, '').split('/').join(path.sep) : undefined;
+
+    const matches: ConcurrencyEvent[] = [];
+    for (const uri of [...this.files.keys()].sort()) {
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      if (folder && !path.dirname(uri).endsWith(folder)) {
+        continue;
+      }
+      matches.push(...this.files.get(uri)!.events.filter(event =>
+        (!query.op || event.op === query.op) &&
+        (!query.package || folder !== undefined || event.package === query.package) &&
+        (!query.target || (event.target !== undefined &&
+          (event.target === query.target || event.target.endsWith(`.${query.target}`))))
+      ));
+    }
+
+    const byOp: Partial<Record<ConcurrencyOp, number>> = {};
+    const functions = new Map<string, ConcurrencyFunctionUsage>();
+    for (const event of matches) {
+      byOp[event.op] = (byOp[event.op] ?? 0) + 1;
+      const key = `${event.uri}#${event.function ?? ''}`;
+      let usage = functions.get(key);
+      if (!usage) {
+        usage = {
+          ...(event.function ? { name: event.function, location: event.functionLocation } : {}),
+          uri: event.uri,
+          package: event.package,
+          events: 0,
+          ops: {},
+          channels: [],
+          mutexes: []
+        };
+        functions.set(key, usage);
+      }
+      usage.events++;
+      usage.ops[event.op] = (usage.ops[event.op] ?? 0) + 1;
+      const names = event.op === 'lock' || event.op === 'unlock' ? usage.mutexes
+        : event.op === 'go' ? undefined
+        : usage.channels;
+      if (names && event.target && !names.includes(event.target)) {
+        names.push(event.target);
+      }
+    }
+
+    return {
+      total: matches.length,
+      byOp,
+      events: matches.slice(0, limit),
+      functions: [...functions.values()].sort((a, b) => b.events - a.events),
+      truncated: matches.length > limit
+    };
+  }
+}
+
+/**
+ * Names the file gives a channel type (`jobs chan Job`, `out <-chan int`,
+ * `done chan<- struct{}`) or assigns `make(chan ...)` to.
+ */
+function channelNames(tokens: GoToken[], closing: Array<number | undefined>): Set<string> {
+  const names = new Set<string>();
+  for (let i = 0; i < tokens.length; i++) {
+    const token = tokens[i];
+    if (token.type !== 'ident' || GO_KEYWORDS.has(token.text)) {
+      continue;
+    }
+    const next = tokens[i + 1];
+    if (next?.text === 'chan' || (next?.text === '<-' && tokens[i + 2]?.text === 'chan')) {
+      names.add(token.text);
+    } else if (token.text === 'make' && next?.text === '(' && closing[i + 1] !== undefined &&
+      (tokens[i + 2]?.text === 'chan' || tokens[i + 3]?.text === 'chan')) {
+      const name = madeChannelName(tokens, i);
+      if (name) {
+        names.add(name.slice(name.lastIndexOf('.') + 1));
+      }
+    }
+  }
+  return names;
+}
+
+/** Whether the token can end the operand of a send: `ch`, `chans[i]`, `out()` */
+function endsOperand(token: GoToken): boolean {
+  if (token.type === 'ident') {
+    return !GO_KEYWORDS.has(token.text);
+  }
+  return token.text === ')' || token.text === ']';
+}
+
+/**
+ * Whether the `<-` at index i is in the header of a select case
+ * (`case v := <-ch:`), not in the statements under it.
+ */
+function inCaseHeader(tokens: GoToken[], i: number): boolean {
+  let depth = 0;
+  for (let j = i - 1; j >= 0; j--) {
+    const text = tokens[j].text;
+    if (text === ')' || text === ']' || text === '}') {
+      depth++;
+    } else if (text === '(' || text === '[' || text === '{') {
+      if (depth === 0) {
+        return false;
+      }
+      depth--;
+    } else if (depth === 0 && (text === ':' || text === 'default')) {
+      return false;
+    } else if (depth === 0 && text === 'case') {
+      return true;
+    }
+  }
+  return false;
+}
+
+/**
+ * First token of the operand ending at token end: `s.jobs`, `chans[i]`, `s.out()`.
+ */
+function operandBefore(tokens: GoToken[], end: number, closing: Array<number | undefined>): number {
+  let j = end;
+  while (j >= 0) {
+    const text = tokens[j].text;
+    if (text === ')' || text === ']') {
+      const open = closing.lastIndexOf(j);
+      if (open < 1) {
+        return j;
+      }
+      j = open - 1;
+      continue;
+    }
+    if (tokens[j].type !== 'ident' || tokens[j - 1]?.text !== '.') {
+      return j;
+    }
+    j -= 2;
+  }
+  return 0;
+}
+
+/**
+ * Last token of the operand starting at token start: `ch`, `s.jobs`,
+ * `ctx.Done()`, `chans[i]`; start - 2 when there is none.
+ */
+function operandAfter(tokens: GoToken[], start: number, closing: Array<number | undefined>): number {
+  if (tokens[start]?.type !== 'ident' || GO_KEYWORDS.has(tokens[start].text)) {
+    return start - 2;
+  }
+  let j = start;
+  for (;;) {
+    const next = tokens[j + 1];
+    if (next?.text === '.' && tokens[j + 2]?.type === 'ident') {
+      j += 2;
+    } else if ((next?.text === '(' || next?.text === '[') && closing[j + 1] !== undefined) {
+      j = closing[j + 1]!;
+    } else {
+      return j;
+    }
+  }
+}
+
+/**
+ * Last token of the selector starting at token start (`worker`, `s.run`); start - 2 when there is none.
+ */
+function selectorEnd(tokens: GoToken[], start: number): number {
+  if (tokens[start]?.type !== 'ident') {
+    return start - 2;
+  }
+  let j = start;
+  while (tokens[j + 1]?.text === '.' && tokens[j + 2]?.type === 'ident') {
+    j += 2;
+  }
+  return j;
+}
+
+/**
+ * `ch` in `ch := make(...)`, `s.jobs = make(...)` or `jobs: make(...)`,
+ * where start is the index of `make`.
+ */
+function madeChannelName(tokens: GoToken[], start: number): string | undefined {
+  if (tokens[start - 1]?.text === ':' && tokens[start - 2]?.type === 'ident') {
+    return tokens[start - 2].text;
+  }
+  return assignedName(tokens, start);
+}
@@ -0,0 +1,133 @@
+/**
+ * Configuration Key Tests
+ *
+ * Verifies key extraction for environment variables, viper and
+ * flag/pflag/cobra (defaults, types, usage text, aliases, instances) and
+ * the key index: keys merged across files, filters and rescans.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ConfigKeyIndex, findConfigKeys } from './configKeys.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const MAIN_GO = '/ws/cmd/server/main.go';
+const CONFIG_GO = '/ws/internal/config/config.go';
+
+const mainGo = `package main
+
+import (
+	"cmp"
+	"flag"
+	"os"
+	"time"
+)
+
+const envPort = "PORT"
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	var timeout time.Duration
+	flag.DurationVar(&timeout, "timeout", 30 * time.Second, "request timeout")
+	port := cmp.Or(os.Getenv(envPort), "8080")
+	if token, ok := os.LookupEnv("API_TOKEN"); ok {
+		use(token)
+	}
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Bool("dry-run", false, "print statements only")
+	flag.Parse()
+	cache.Get("not-config")
+}
+`;
+
+const configGo = `package config
+
+import (
+	"github.com/spf13/cobra"
+	cfg "github.com/spf13/viper"
+)
+
+func Load(cmd *cobra.Command) {
+	cfg.SetDefault("db.url", "postgres://localhost/app")
+	cfg.BindEnv("db.url", "DATABASE_URL")
+	url := cfg.GetString("db.url")
+	cmd.Flags().StringP("config", "c", "", "config file")
+	cmd.PersistentFlags().CountP("verbose", "v", "more logs")
+	v := cfg.New()
+	v.GetDuration("http.timeout")
+}
+`;
+
+describe('findConfigKeys', () => {
+  it('should read environment variables and flags with their defaults', () => {
+    const uses = findConfigKeys(MAIN_GO, mainGo);
+
+    expect(uses.map(use => [use.source, use.key, use.type, use.default])).toEqual([
+      ['flag', 'addr', 'string', '":8080"'],
+      ['flag', 'timeout', 'time.Duration', '30 * time.Second'],
+      ['env', 'PORT', 'string', '"8080"'],
+      ['env', 'API_TOKEN', 'string', undefined],
+      ['flag', 'dry-run', 'bool', 'false']
+    ]);
+    expect(uses[0]).toMatchObject({ call: 'String', line: 12, character: 14, description: 'listen address' });
+  });
+
+  it('should read viper keys through aliases and instances, and cobra flags', () => {
+    const uses = findConfigKeys(CONFIG_GO, configGo);
+
+    expect(uses.map(use => [use.source, use.key, use.call, use.default ?? use.env ?? use.type])).toEqual([
+      ['viper', 'db.url', 'SetDefault', '"postgres://localhost/app"'],
+      ['viper', 'db.url', 'BindEnv', 'DATABASE_URL'],
+      ['viper', 'db.url', 'GetString', 'string'],
+      ['flag', 'config', 'StringP', '""'],
+      ['flag', 'verbose', 'CountP', 'int'],
+      ['viper', 'http.timeout', 'GetDuration', 'time.Duration']
+    ]);
+    expect(uses[4].description).toBe('more logs');
+    expect(findConfigKeys('/ws/x.go', 'package x\n\nfunc f() { os.Getenv("HOME") }\n')).toEqual([]);
+  });
+});
+
+describe('ConfigKeyIndex', () => {
+  let index: MockBackgroundIndex;
+  let keys: ConfigKeyIndex;
+  let contents: Record<string, string>;
+
+  function addFile(uri: string, content: string, hash?: string): void {
+    contents[uri] = content;
+    index.addFile(uri, [], [], hash ? { hash } : {});
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = {};
+    addFile(MAIN_GO, mainGo);
+    addFile(CONFIG_GO, configGo);
+    addFile('/ws/internal/config/config_test.go', 'package config\n\nimport "os"\n\nvar _ = os.Getenv("TEST_ONLY")\n');
+    keys = new ConfigKeyIndex(index.asBackgroundIndex(), async filePath => contents[filePath]);
+    await keys.build();
+  });
+
+  it('should merge the uses of a key', () => {
+    const report = keys.query();
+
+    expect(report.keys.map(key => `${key.source} ${key.key}`)).toEqual([
+      'env API_TOKEN', 'env PORT',
+      'viper db.url', 'viper http.timeout',
+      'flag addr', 'flag config', 'flag dry-run', 'flag timeout', 'flag verbose'
+    ]);
+    expect(report.keys[2]).toMatchObject({
+      types: ['string'], defaults: ['"postgres://localhost/app"'], env: ['DATABASE_URL']
+    });
+    expect(report.keys[2].uses.map(use => use.line)).toEqual([8, 9, 10]);
+  });
+
+  it('should filter by source, key and path', async () => {
+    expect(keys.query({ source: 'env' }).total).toBe(2);
+    expect(keys.query({ key: 'URL' }).keys.map(key => key.key)).toEqual(['db.url']);
+    expect(keys.query({ path: '/ws/cmd' }).keys.map(key => key.source)).toEqual(['env', 'env', 'flag', 'flag', 'flag']);
+    expect(keys.query({ limit: 3 })).toMatchObject({ total: 9, truncated: true });
+
+    addFile(MAIN_GO, 'package main\n\nimport "os"\n\nvar home = os.Getenv("HOME")\n', 'changed');
+    expect(await keys.build()).toMatchObject({ files: 2, keys: 5, updated: 1 });
+  });
+});
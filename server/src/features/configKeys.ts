@@ -0,0 +1,451 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { GoToken, GoTokenizer, unquoteGoString } from '../indexer/components/GoTokenizer.js';
+import { splitArgs, stringConstants, TokenSpan } from '../indexer/components/GoTokenUtils.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type ConfigSource = 'env' | 'viper' | 'flag';
+
+export const CONFIG_SOURCES: ConfigSource[] = ['env', 'viper', 'flag'];
+
+/** One place the code reads, defines or defaults a key */
+export interface ConfigKeyUse {
+  source: ConfigSource;
+  key: string;
+  uri: string;
+  /** 0-based position of the call (`Getenv`, `GetString`, `Duration`, ...) */
+  line: number;
+  character: number;
+  call: string;
+  /** Go expression of the default: `"8080"`, `30 * time.Second` */
+  default?: string;
+  /** Go type the call reads: `string`, `time.Duration`, `[]string` */
+  type?: string;
+  /** Usage text of a flag */
+  description?: string;
+  /** Environment variable a viper key is bound to */
+  env?: string;
+}
+
+export interface ConfigKey {
+  source: ConfigSource;
+  key: string;
+  types: string[];
+  /** Distinct defaults, in file order */
+  defaults: string[];
+  description?: string;
+  /** Environment variables bound to a viper key */
+  env: string[];
+  uses: ConfigKeyUse[];
+}
+
+export interface ConfigKeyIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ConfigKeyQuery {
+  source?: ConfigSource;
+  /** Keys containing this text (case-insensitive) */
+  key?: string;
+  /** Only keys used in this file or under this folder */
+  path?: string;
+  /** Number of keys to return (default: 1000) */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface ConfigKeyReport {
+  /** Matching keys, before the limit */
+  total: number;
+  /** By source, then key */
+  keys: ConfigKey[];
+  truncated: boolean;
+}
+
+/** Keys matching a query; how servers expose the index */
+export type ConfigKeySource = (query: ConfigKeyQuery) => Promise<ConfigKeyReport>;
+
+/** The part of the background index the key index reads */
+export interface ConfigKeySourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type ConfigReader = (filePath: string) => Promise<string>;
+
+type Library = 'os' | 'syscall' | 'viper' | 'flag' | 'pflag' | 'cobra' | 'cmp';
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+const IMPORT_PATHS: Record<string, Library> = {
+  'os': 'os',
+  'syscall': 'syscall',
+  'cmp': 'cmp',
+  'flag': 'flag',
+  'github.com/spf13/viper': 'viper',
+  'github.com/spf13/pflag': 'pflag',
+  'github.com/spf13/cobra': 'cobra'
+};
+
+/** Flag definitions by value type; `StringVar`, `StringP` and `StringVarP` follow from these */
+const FLAG_TYPES: Record<string, string> = {
+  String: 'string', Bool: 'bool', Int: 'int', Int8: 'int8', Int16: 'int16', Int32: 'int32', Int64: 'int64',
+  Uint: 'uint', Uint8: 'uint8', Uint16: 'uint16', Uint32: 'uint32', Uint64: 'uint64', Float32: 'float32',
+  Float64: 'float64', Duration: 'time.Duration', StringSlice: '[]string', StringArray: '[]string',
+  IntSlice: '[]int', BoolSlice: '[]bool', DurationSlice: '[]time.Duration', StringToString: 'map[string]string',
+  IP: 'net.IP', Count: 'int'
+};
+
+/** Exact names first, so `IP` is not read as `I` with a shorthand */
+const FLAG_SUFFIXES = ['', 'VarP', 'Var', 'P'];
+
+/** Flags without a default: `Func(name, usage, fn)`, `Var(value, name, usage)` */
+const FLAG_FUNCS = new Set(['Func', 'BoolFunc', 'Var', 'VarP', 'TextVar']);
+
+const VIPER_TYPES: Record<string, string> = {
+  GetString: 'string', GetBool: 'bool', GetInt: 'int', GetInt32: 'int32', GetInt64: 'int64', GetUint: 'uint',
+  GetUint16: 'uint16', GetUint32: 'uint32', GetUint64: 'uint64', GetFloat64: 'float64',
+  GetDuration: 'time.Duration', GetTime: 'time.Time', GetStringSlice: '[]string', GetIntSlice: '[]int',
+  GetStringMap: 'map[string]any', GetStringMapString: 'map[string]string',
+  GetStringMapStringSlice: 'map[string][]string', GetSizeInBytes: 'uint'
+};
+
+/** Chained flag sets: `cmd.Flags().String(...)` */
+const FLAG_SET_CALLS = new Set(['Flags', 'PersistentFlags', 'LocalFlags']);
+
+/**
+ * Configuration keys a Go file reads: environment variables (`os.Getenv`,
+ * `os.LookupEnv`), viper keys (`viper.GetString`, `SetDefault`, `BindEnv`)
+ * and command-line flags (flag, pflag and cobra `Flags()`), with their
+ * defaults. Keys are string literals or constants of the file.
+ */
+export function findConfigKeys(uri: string, content: string): ConfigKeyUse[] {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens } = tokenizer.tokenize();
+  const qualifiers = importQualifiers(tokens);
+  if (qualifiers.size === 0) {
+    return [];
+  }
+  const has = (library: Library) => [...qualifiers.values()].includes(library);
+  const constants = stringConstants(tokens);
+  const instances = constructedNames(tokens, qualifiers);
+
+  const literal = (arg: TokenSpan | undefined): string | undefined => {
+    if (!arg || arg.end - arg.start !== 1) {
+      return undefined;
+    }
+    const token = tokens[arg.start];
+    return token.type === 'string' ? unquoteGoString(token.text) : token.type === 'ident' ? constants.get(token.text) : undefined;
+  };
+  const source = (arg: TokenSpan | undefined): string | undefined =>
+    arg && content.slice(tokens[arg.start].offset, tokens[arg.end - 1].end).replace(/\s+/g, ' ');
+
+  const uses: ConfigKeyUse[] = [];
+  for (let i = 2; i < tokens.length; i++) {
+    const name = tokens[i].text;
+    if (tokens[i].type !== 'ident' || tokens[i - 1].text !== '.' || tokens[i + 1]?.text !== '(') {
+      continue;
+    }
+    const receiver = libraryOfReceiver(tokens, i - 2, qualifiers, instances, has);
+    if (!receiver) {
+      continue;
+    }
+    const args = splitArgs(tokens, i + 1);
+    const use = toUse(receiver, name, args, literal, source);
+    if (!use) {
+      continue;
+    }
+    if (use.source === 'env' && use.default === undefined) {
+      use.default = orDefault(tokens, i - 2, qualifiers, source);
+    }
+    const position = tokenizer.positionAt(tokens[i].offset);
+    uses.push({ ...use, uri, line: position.line, character: position.character, call: name });
+  }
+  return uses;
+}
+
+/**
+ * Configuration Key Index - every environment variable, viper key and
+ * flag the Go code reads, where, and with what defaults.
+ *
+ * Built from the files of the background index like the route index
+ * (features/httpRoutes.ts): only files whose hash changed are rescanned
+ * and test files are left out. Uses of a key across files are merged, so
+ * a `viper.SetDefault` in one package gives the default of a
+ * `viper.GetString` in another.
+ */
+export class ConfigKeyIndex {
+  private files: Map<string, { hash: string; uses: ConfigKeyUse[] }> = new Map();
+
+  constructor(
+    private index: ConfigKeySourceIndex,
+    private readFile: ConfigReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the index in line with the background index.
+   */
+  async build(options: ConfigKeyIndexOptions = {}): Promise<{ files: number; keys: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles())
+      .filter(uri => path.extname(uri) === '.go' && !uri.endsWith('_test.go'))
+      .sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting configuration keys (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        this.files.set(uri, { hash, uses: findConfigKeys(uri, await this.readFile(uri)) });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'Configuration keys complete');
+    return { files: this.files.size, keys: this.collect().size, updated };
+  }
+
+  /**
+   * Keys matching the query, with all their uses.
+   */
+  query(query: ConfigKeyQuery = {}): ConfigKeyReport {
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const text = query.key?.toLowerCase();
+    const limit = query.limit ?? DEFAULT_LIMIT;
+
+    const keys = [...this.collect().values()]
+      .filter(key =>
+        (!query.source || key.source === query.source) &&
+        (!text || key.key.toLowerCase().includes(text)) &&
+        (!scope || key.uses.some(use => use.uri === scope || use.uri.startsWith(scope + path.sep)))
+      )
+      .sort((a, b) =>
+        CONFIG_SOURCES.indexOf(a.source) - CONFIG_SOURCES.indexOf(b.source) || a.key.localeCompare(b.key)
+      );
+
+    return { total: keys.length, keys: keys.slice(0, limit), truncated: keys.length > limit };
+  }
+
+  /** Uses grouped per source and key, in path order */
+  private collect(): Map<string, ConfigKey> {
+    const keys = new Map<string, ConfigKey>();
+    for (const uri of [...this.files.keys()].sort()) {
+      for (const use of this.files.get(uri)!.uses) {
+        const id = `${use.source}:${use.key}`;
+        let key = keys.get(id);
+        if (!key) {
+          key = { source: use.source, key: use.key, types: [], defaults: [], env: [], uses: [] };
+          keys.set(id, key);
+        }
+        key.uses.push(use);
+        if (use.type && !key.types.includes(use.type)) {
+          key.types.push(use.type);
+        }
+        if (use.default !== undefined && !key.defaults.includes(use.default)) {
+          key.defaults.push(use.default);
+        }
+        if (use.env && !key.env.includes(use.env)) {
+          key.env.push(use.env);
+        }
+        if (use.description && !key.description) {
+          key.description = use.description;
+        }
+      }
+    }
+    return keys;
+  }
+}
+
+/**
+ * Import qualifiers of the libraries read here: `viper`, or the alias of
+ * `cfg "github.com/spf13/viper"`.
+ */
+function importQualifiers(tokens: GoToken[]): Map<string, Library> {
+  const qualifiers = new Map<string, Library>();
+  for (let i = 0; i < tokens.length; i++) {
+    if (tokens[i].text !== 'import') {
+      continue;
+    }
+    const grouped = tokens[i + 1]?.text === '(';
+    for (let j = i + 1; j < tokens.length && tokens[j].text !== ')'; j++) {
+      if (tokens[j].type !== 'string') {
+        continue;
+      }
+      const importPath = unquoteGoString(tokens[j].text);
+      const library = IMPORT_PATHS[importPath];
+      const alias = tokens[j - 1].type === 'ident' && tokens[j - 1].text !== 'import' ? tokens[j - 1].text : undefined;
+      if (library && alias !== '_') {
+        qualifiers.set(alias ?? importPath.slice(importPath.lastIndexOf('/') + 1), library);
+      }
+      if (!grouped) {
+        break;
+      }
+    }
+  }
+  return qualifiers;
+}
+
+/**
+ * Names assigned a new viper instance or flag set:
+ * `v := viper.New()`, `fs := flag.NewFlagSet("serve", flag.ExitOnError)`.
+ */
+function constructedNames(tokens: GoToken[], qualifiers: Map<string, Library>): Map<string, Library> {
+  const names = new Map<string, Library>();
+  for (let i = 1; i + 3 < tokens.length; i++) {
+    const operator = tokens[i].text;
+    if ((operator !== '=' && operator !== ':=') || tokens[i - 1].type !== 'ident' || tokens[i + 2]?.text !== '.') {
+      continue;
+    }
+    const library = qualifiers.get(tokens[i + 1].text);
+    const constructor = tokens[i + 3].text;
+    if ((library === 'viper' && constructor === 'New') ||
+      ((library === 'flag' || library === 'pflag') && constructor === 'NewFlagSet')) {
+      names.set(tokens[i - 1].text, library);
+    }
+  }
+  return names;
+}
+
+/**
+ * The library a call's receiver (ending at index `last`) belongs to:
+ * an imported package, a name from constructedNames, or a cobra
+ * `Flags()` chain.
+ */
+function libraryOfReceiver(
+  tokens: GoToken[],
+  last: number,
+  qualifiers: Map<string, Library>,
+  instances: Map<string, Library>,
+  has: (library: Library) => boolean
+): Library | undefined {
+  const token = tokens[last];
+  if (token.type === 'ident') {
+    // Not a field: `cfg.viper.GetString` is some other viper
+    if (tokens[last - 1]?.text === '.') {
+      return undefined;
+    }
+    return qualifiers.get(token.text) ?? instances.get(token.text);
+  }
+  if (token.text === ')' && tokens[last - 1]?.text === '(' && FLAG_SET_CALLS.has(tokens[last - 2]?.text ?? '') &&
+    tokens[last - 3]?.text === '.' && (has('cobra') || has('pflag'))) {
+    return 'pflag';
+  }
+  return undefined;
+}
+
+/**
+ * The key use a call makes, or null when it is not a configuration call.
+ */
+function toUse(
+  library: Library,
+  name: string,
+  args: TokenSpan[],
+  literal: (arg: TokenSpan | undefined) => string | undefined,
+  source: (arg: TokenSpan | undefined) => string | undefined
+): Omit<ConfigKeyUse, 'uri' | 'line' | 'character' | 'call'> | null {
+  if (library === 'os' || library === 'syscall') {
+    const key = (name === 'Getenv' || (library === 'os' && name === 'LookupEnv')) ? literal(args[0]) : undefined;
+    return key !== undefined ? { source: 'env', key, type: 'string' } : null;
+  }
+
+  if (library === 'viper') {
+    const key = literal(args[0]);
+    if (key === undefined) {
+      return null;
+    }
+    if (name === 'Get' || name === 'IsSet' || VIPER_TYPES[name]) {
+      return { source: 'viper', key, ...(VIPER_TYPES[name] ? { type: VIPER_TYPES[name] } : {}) };
+    }
+    if (name === 'SetDefault' && args[1]) {
+      return { source: 'viper', key, default: source(args[1]) };
+    }
+    if (name === 'BindEnv') {
+      const env = literal(args[1]);
+      return { source: 'viper', key, ...(env !== undefined ? { env } : {}) };
+    }
+    return null;
+  }
+
+  if (library !== 'flag' && library !== 'pflag') {
+    return null;
+  }
+  // Var: (p, name, ...), P: (name, shorthand, ...); then default and usage
+  if (FLAG_FUNCS.has(name)) {
+    const nameIndex = name === 'Var' || name === 'VarP' || name === 'TextVar' ? 1 : 0;
+    const key = literal(args[nameIndex]);
+    const usage = literal(args[name === 'TextVar' ? 3 : name === 'VarP' ? 3 : nameIndex + 1]);
+    return key !== undefined
+      ? { source: 'flag', key, ...(name === 'TextVar' ? { default: source(args[2]) } : {}), ...(usage ? { description: usage } : {}) }
+      : null;
+  }
+  const suffix = FLAG_SUFFIXES.find(candidate => name.endsWith(candidate) && FLAG_TYPES[name.slice(0, name.length - candidate.length)]);
+  if (suffix === undefined) {
+    return null;
+  }
+  const base = name.slice(0, name.length - suffix.length);
+  const nameIndex = suffix.startsWith('Var') ? 1 : 0;
+  const defaultIndex = nameIndex + (suffix.endsWith('P') ? 2 : 1);
+  const key = literal(args[nameIndex]);
+  if (key === undefined) {
+    return null;
+  }
+  // Count flags start at zero and take no default
+  const usage = literal(args[base === 'Count' ? defaultIndex : defaultIndex + 1]);
+  return {
+    source: 'flag',
+    key,
+    type: FLAG_TYPES[base],
+    ...(base !== 'Count' && args[defaultIndex] ? { default: source(args[defaultIndex]) } : {}),
+    ...(usage ? { description: usage } : {})
+  };
+}
+
+/**
+ * The fallback of `cmp.Or(os.Getenv("PORT"), "8080")`, when the receiver
+ * at `receiver` opens the first argument of cmp.Or.
+ */
+function orDefault(
+  tokens: GoToken[],
+  receiver: number,
+  qualifiers: Map<string, Library>,
+  source: (arg: TokenSpan | undefined) => string | undefined
+): string | undefined {
+  const open = receiver - 1;
+  if (tokens[open]?.text !== '(' || tokens[open - 1]?.text !== 'Or' || tokens[open - 2]?.text !== '.' ||
+    qualifiers.get(tokens[open - 3]?.text ?? '') !== 'cmp') {
+    return undefined;
+  }
+  const args = splitArgs(tokens, open);
+  return args.length > 1 ? source(args[args.length - 1]) : undefined;
+}
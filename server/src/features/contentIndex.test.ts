@@ -0,0 +1,156 @@
+/**
+ * ContentIndex Tests
+ *
+ * Verifies comment/string extraction, phrase search, scoping, incremental
+ * updates and skipping files through a persisted skip list.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { ContentIndex } from './contentIndex.js';
+import { FileSkipList } from './fileSkipList.js';
+import { scanContent } from '../indexer/components/ContentScanner.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const clientTs = `/**
+ * Retries when the connection is refused.
+ */
+export async function connect(url: string) {
+  const ratio = total / count / 2; // not a regex
+  const pattern = /"connection refused"/g;
+  // TODO: surface "connection refused" to the caller
+  throw new Error(\`dial \${url}: connection refused\`);
+}
+`;
+
+const dialerGo = `package net
+
+// Dial connects to the address.
+// It fails with connection refused when nothing listens.
+func Dial(addr string) error {
+	// retry once
+	return errors.New("connection refused")
+}
+`;
+
+describe('ContentScanner', () => {
+  it('should classify TS doc comments, comments and strings, skipping regex literals', () => {
+    const spans = scanContent('/ws/client.ts', clientTs);
+    expect(spans.map(s => s.kind)).toEqual(['doc', 'comment', 'comment', 'string', 'string']);
+    expect(spans.find(s => s.text.startsWith('`dial'))?.text).toBe('`dial ${');
+    expect(spans[spans.length - 1].text).toBe('}: connection refused`');
+  });
+
+  it('should treat Go comment blocks before declarations as doc comments', () => {
+    const spans = scanContent('/ws/net/dial.go', dialerGo);
+    expect(spans.map(s => [s.kind, s.line])).toEqual([
+      ['doc', 2],
+      ['doc', 3],
+      ['comment', 5],
+      ['string', 6]
+    ]);
+  });
+});
+
+describe('ContentIndex', () => {
+  let background: MockBackgroundIndex;
+  let files: Map<string, string>;
+  let reads: string[];
+  let testDir: string;
+
+  function create(skipList?: FileSkipList): ContentIndex {
+    return new ContentIndex(background.asBackgroundIndex(), async uri => {
+      reads.push(uri);
+      return files.get(uri)!;
+    }, skipList);
+  }
+
+  async function build(): Promise<ContentIndex> {
+    const index = create();
+    await index.build();
+    return index;
+  }
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-content-'));
+    background = new MockBackgroundIndex();
+    files = new Map([['/ws/client.ts', clientTs], ['/ws/net/dial.go', dialerGo]]);
+    reads = [];
+    for (const uri of files.keys()) {
+      background.addFile(uri, []);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should find a phrase and map it back to file positions', async () => {
+    const index = await build();
+    const matches = index.search('connection refused');
+
+    expect(matches.map(m => [m.uri, m.kind, m.line])).toEqual([
+      ['/ws/client.ts', 'comment', 6],
+      ['/ws/client.ts', 'string', 7],
+      ['/ws/net/dial.go', 'doc', 3],
+      ['/ws/net/dial.go', 'string', 6]
+    ]);
+    const templateMatch = matches[1];
+    expect(clientTs.split('\n')[7].slice(templateMatch.character, templateMatch.endCharacter)).toBe('connection refused');
+    expect(templateMatch.preview).toBe('}: connection refused`');
+  });
+
+  it('should map positions inside multi-line comments', async () => {
+    const index = await build();
+    const [match] = index.search('Retries when');
+
+    expect(match.kind).toBe('doc');
+    expect([match.line, match.character]).toEqual([1, 3]);
+  });
+
+  it('should restrict matches to the requested kinds', async () => {
+    const index = await build();
+
+    expect(index.search('connection refused', { kinds: ['string'] }).map(m => m.line)).toEqual([7, 6]);
+    expect(index.search('connection refused', { kinds: ['doc', 'comment'] })).toHaveLength(2);
+    expect(index.search('refused connection')).toEqual([]);
+    expect(index.search('Connection Refused', { caseSensitive: true })).toEqual([]);
+  });
+
+  it('should only rescan files whose hash changed', async () => {
+    const index = await build();
+    expect(reads).toHaveLength(2);
+
+    files.set('/ws/net/dial.go', 'package net\n\n// no longer refusing\n');
+    background.addFile('/ws/net/dial.go', [], [], { hash: 'changed' });
+    const stats = await index.build();
+
+    expect(stats.updated).toBe(1);
+    expect(reads).toEqual(['/ws/client.ts', '/ws/net/dial.go', '/ws/net/dial.go']);
+    expect(index.search('connection refused').every(m => m.uri === '/ws/client.ts')).toBe(true);
+    expect(index.search('refusing')).toHaveLength(1);
+  });
+
+  it('should leave files the skip list rules out unread, also after a restart', async () => {
+    const skipListPath = path.join(testDir, 'skip-list.bin');
+    // Nothing is known yet: every file is read and gets a filter
+    expect(await create(new FileSkipList(skipListPath)).build({ query: 'listens' })).toMatchObject({ updated: 2, skipped: 0 });
+    expect(fs.existsSync(skipListPath)).toBe(true);
+
+    reads = [];
+    const restarted = create(new FileSkipList(skipListPath));
+    expect(await restarted.build({ query: 'nothing listens' })).toMatchObject({ files: 1, updated: 1, skipped: 1 });
+    expect(reads).toEqual(['/ws/net/dial.go']);
+    expect(restarted.search('nothing listens')).toHaveLength(1);
+
+    // Later queries read the files they need; a changed file is read again
+    expect(await restarted.build({ query: 'caller' })).toMatchObject({ updated: 1, skipped: 0 });
+    files.set('/ws/net/dial.go', 'package net\n\n// the caller retries\n');
+    background.addFile('/ws/net/dial.go', [], [], { hash: 'changed' });
+    expect(await restarted.build({ query: 'caller' })).toMatchObject({ updated: 1, skipped: 0 });
+    expect(reads).toEqual(['/ws/net/dial.go', '/ws/client.ts', '/ws/net/dial.go']);
+    expect(restarted.search('caller').map(m => m.uri)).toEqual(['/ws/client.ts', '/ws/net/dial.go']);
+  });
+});
@@ -0,0 +1,336 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { ContentKind, isContentScannable, scanContent } from '../indexer/components/ContentScanner.js';
+import { FileSkipList } from './fileSkipList.js';
+import * as fs from 'fs';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface ContentIndexOptions {
+  /**
+   * Read only the files that may match this query: with a skip list,
+   * files whose filter rules a word out stay unread until a query needs them
+   */
+  query?: string;
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ContentSearchOptions {
+  /** Restrict to these span kinds (default: all) */
+  kinds?: ContentKind[];
+  /** Match token case exactly (default: case-insensitive) */
+  caseSensitive?: boolean;
+  /** Maximum number of matches (default: 500) */
+  limit?: number;
+}
+
+export interface ContentMatch {
+  uri: string;
+  kind: ContentKind;
+  line: number;
+  character: number;
+  endLine: number;
+  endCharacter: number;
+  /** Source line containing the start of the match, trimmed */
+  preview: string;
+}
+
+export interface ContentIndexStats {
+  files: number;
+  spans: number;
+  terms: number;
+  /** Files (re)scanned by the last build */
+  updated: number;
+  /** Files the last build left unread because the skip list ruled the query out */
+  skipped: number;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type ContentReader = (filePath: string) => Promise<string>;
+
+interface StoredSpan {
+  uri: string;
+  kind: ContentKind;
+  text: string;
+  offset: number;
+  line: number;
+  character: number;
+}
+
+interface Token {
+  text: string;
+  start: number;
+  end: number;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 500;
+
+/** Words; escape sequences (`\n`, `\t`) are consumed so they do not glue onto the next word */
+const TOKEN_RE = /\\.|[\p{L}\p{N}_]+/gu;
+
+/**
+ * Content Index - inverted index over comments and string literals.
+ *
+ * Every doc comment, comment and string literal of a TS/JS/Go file is a
+ * span; each lowercased word maps to the set of spans containing it
+ * (the posting list). A query is tokenized the same way, candidate spans
+ * are the intersection of its terms' postings, and each candidate is then
+ * checked for the terms as a consecutive phrase. Match offsets inside the
+ * span are mapped back to file positions.
+ *
+ * Files are rescanned only when their hash in the background index
+ * changed since the last build, so repeated searches are cheap.
+ *
+ * With a skip list (fileSkipList.ts), every file read also records a bloom
+ * filter of its words and identifiers, and a build for a query leaves the
+ * files that cannot match unread. A fresh process with a persisted list
+ * then reads only the handful of candidate files for its first search.
+ */
+export class ContentIndex {
+  private files: Map<string, { hash: string; spanIds: number[] }> = new Map();
+  private spans: Array<StoredSpan | undefined> = [];
+  private postings: Map<string, Set<number>> = new Map();
+  private liveSpans = 0;
+
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private readFile: ContentReader = filePath => fs.promises.readFile(filePath, 'utf-8'),
+    private skipList?: FileSkipList
+  ) {}
+
+  /**
+   * Bring the index in line with the background index.
+   */
+  async build(options: ContentIndexOptions = {}): Promise<ContentIndexStats> {
+    const { cancellationToken, onProgress } = options;
+    const uris = this.backgroundIndex.getAllFileUris().filter(isContentScannable).sort();
+    const current = new Set(uris);
+    const terms = options.query ? tokenize(options.query).map(token => token.text.toLowerCase()) : [];
+    let updated = 0;
+    let skipped = 0;
+
+    await this.skipList?.load();
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.removeFile(uri);
+        this.skipList?.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Indexing comments and strings (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.backgroundIndex.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      if (terms.length > 0 && this.skipList?.mightContain(uri, hash, terms) === false) {
+        // Read again when a query needs it; stale spans must not match meanwhile
+        this.removeFile(uri);
+        skipped++;
+        continue;
+      }
+
+      try {
+        const content = await this.readFile(uri);
+        this.updateFile(uri, content, hash);
+        if (hash !== '') {
+          this.skipList?.set(uri, hash, contentTerms(content));
+        }
+      } catch {
+        this.removeFile(uri);
+        this.skipList?.delete(uri);
+      }
+      updated++;
+    }
+
+    // Removed spans leave holes; compact once they dominate
+    if (this.spans.length > 1024 && this.liveSpans < this.spans.length / 2) {
+      this.compact();
+    }
+
+    try {
+      await this.skipList?.save();
+    } catch {
+      // A lost skip list only costs reads on the next cold start
+    }
+
+    onProgress?.(uris.length, uris.length, 'Content index complete');
+    return { files: this.files.size, spans: this.liveSpans, terms: this.postings.size, updated, skipped };
+  }
+
+  /**
+   * (Re)index one file from its content.
+   */
+  updateFile(uri: string, content: string, hash: string = ''): void {
+    this.removeFile(uri);
+
+    const spanIds = scanContent(uri, content).map(span => this.addSpan({ uri, ...span }));
+    this.liveSpans += spanIds.length;
+    this.files.set(uri, { hash, spanIds });
+  }
+
+  removeFile(uri: string): void {
+    const entry = this.files.get(uri);
+    if (!entry) {
+      return;
+    }
+    for (const id of entry.spanIds) {
+      const span = this.spans[id];
+      if (!span) {
+        continue;
+      }
+      for (const token of tokenize(span.text)) {
+        const term = token.text.toLowerCase();
+        const posting = this.postings.get(term);
+        posting?.delete(id);
+        if (posting && posting.size === 0) {
+          this.postings.delete(term);
+        }
+      }
+      this.spans[id] = undefined;
+    }
+    this.liveSpans -= entry.spanIds.length;
+    this.files.delete(uri);
+  }
+
+  /**
+   * Find a word or phrase ("connection refused") in comments and strings.
+   */
+  search(query: string, options: ContentSearchOptions = {}): ContentMatch[] {
+    const terms = tokenize(query).map(t => t.text);
+    if (terms.length === 0) {
+      return [];
+    }
+    const kinds = options.kinds && options.kinds.length > 0 ? new Set(options.kinds) : null;
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const lowerTerms = terms.map(t => t.toLowerCase());
+
+    // Intersect posting lists, starting from the rarest term
+    const postings = lowerTerms.map(term => this.postings.get(term));
+    if (postings.some(p => !p)) {
+      return [];
+    }
+    const ordered = (postings as Set<number>[]).slice().sort((a, b) => a.size - b.size);
+    const candidates = [...ordered[0]]
+      .filter(id => ordered.every(p => p.has(id)))
+      .map(id => this.spans[id]!)
+      .filter(span => !kinds || kinds.has(span.kind))
+      .sort((a, b) => a.uri.localeCompare(b.uri) || a.offset - b.offset);
+
+    const matches: ContentMatch[] = [];
+    for (const span of candidates) {
+      const tokens = tokenize(span.text);
+      for (let i = 0; i + terms.length <= tokens.length; i++) {
+        if (!terms.every((term, k) => sameTerm(tokens[i + k].text, term, options.caseSensitive))) {
+          continue;
+        }
+        matches.push(toMatch(span, tokens[i].start, tokens[i + terms.length - 1].end));
+        if (matches.length >= limit) {
+          return matches;
+        }
+      }
+    }
+    return matches;
+  }
+
+  getStats(): Omit<ContentIndexStats, 'updated' | 'skipped'> {
+    return { files: this.files.size, spans: this.liveSpans, terms: this.postings.size };
+  }
+
+  private compact(): void {
+    const files = [...this.files.entries()];
+    const spans = this.spans;
+    this.spans = [];
+    this.postings.clear();
+    this.liveSpans = 0;
+    this.files.clear();
+
+    for (const [uri, entry] of files) {
+      const spanIds = entry.spanIds.map(oldId => this.addSpan(spans[oldId]!));
+      this.liveSpans += spanIds.length;
+      this.files.set(uri, { hash: entry.hash, spanIds });
+    }
+  }
+
+  private addSpan(span: StoredSpan): number {
+    const id = this.spans.length;
+    this.spans.push(span);
+    for (const term of new Set(tokenize(span.text).map(t => t.text.toLowerCase()))) {
+      let posting = this.postings.get(term);
+      if (!posting) {
+        posting = new Set();
+        this.postings.set(term, posting);
+      }
+      posting.add(id);
+    }
+    return id;
+  }
+}
+
+function tokenize(text: string): Token[] {
+  const tokens: Token[] = [];
+  for (const match of text.matchAll(TOKEN_RE)) {
+    if (match[0][0] !== '\\') {
+      tokens.push({ text: match[0], start: match.index!, end: match.index! + match[0].length });
+    }
+  }
+  return tokens;
+}
+
+/**
+ * Lowercased words and identifiers anywhere in a file. Spans are parts of
+ * the file, so this covers every term the file's spans are posted under.
+ */
+function contentTerms(content: string): Set<string> {
+  return new Set(tokenize(content).map(token => token.text.toLowerCase()));
+}
+
+function sameTerm(token: string, term: string, caseSensitive?: boolean): boolean {
+  return caseSensitive ? token === term : token.toLowerCase() === term.toLowerCase();
+}
+
+/**
+ * Map offsets inside a span back to file positions.
+ */
+function toMatch(span: StoredSpan, start: number, end: number): ContentMatch {
+  const startPos = positionInSpan(span, start);
+  const endPos = positionInSpan(span, end);
+  const lineStart = span.text.lastIndexOf('\n', start - 1) + 1;
+  const lineEnd = span.text.indexOf('\n', start);
+  return {
+    uri: span.uri,
+    kind: span.kind,
+    line: startPos.line,
+    character: startPos.character,
+    endLine: endPos.line,
+    endCharacter: endPos.character,
+    preview: span.text.slice(lineStart, lineEnd === -1 ? undefined : lineEnd).trim()
+  };
+}
+
+function positionInSpan(span: StoredSpan, offset: number): { line: number; character: number } {
+  let line = span.line;
+  let lastNewline = -1;
+  for (let i = span.text.indexOf('\n'); i !== -1 && i < offset; i = span.text.indexOf('\n', i + 1)) {
+    line++;
+    lastNewline = i;
+  }
+  return {
+    line,
+    character: lastNewline === -1 ? span.character + offset : offset - lastNewline - 1
+  };
+}
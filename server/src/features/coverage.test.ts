@@ -0,0 +1,133 @@
+/**
+ * Coverage Tests
+ *
+ * Verifies per-function coverage from Go coverage profiles: closures
+ * counted in their function, profile files matched to workspace files by
+ * path suffix, coverage and reference filters, rereading changed profiles.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { CoverageIndex } from './coverage.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const STORE_GO = '/ws/internal/store/store.go';
+const MAIN_GO = '/ws/cmd/app/main.go';
+const PROFILE = '/ws/coverage.out';
+
+const storeGo = `package store
+
+type Store struct{ items map[string]string }
+
+func New() *Store {
+	return &Store{items: map[string]string{}}
+}
+
+func (s *Store) Get(key string) (string, bool) {
+	v, ok := s.items[key]
+	return v, ok
+}
+
+func (s *Store) Each(fn func(string)) {
+	for k := range s.items {
+		func() {
+			fn(k)
+		}()
+	}
+}
+`;
+
+const mainGo = `package main
+
+func main() {
+	s := store.New()
+	s.Get("a")
+	s.Get("b")
+}
+`;
+
+const profile = `mode: set
+example.com/app/internal/store/store.go:5.19,7.2 1 1
+example.com/app/internal/store/store.go:9.48,12.2 2 0
+example.com/app/internal/store/store.go:14.39,15.25 1 1
+example.com/app/internal/store/store.go:15.25,16.10 1 1
+example.com/app/internal/store/store.go:16.10,18.4 1 0
+example.com/app/cmd/app/main.go:3.13,7.2 3 0
+example.com/app/internal/gen/gen.go:1.1,2.2 1 1
+`;
+
+describe('CoverageIndex', () => {
+  let index: MockBackgroundIndex;
+  let coverage: CoverageIndex;
+  let profiles: Record<string, { text: string; mtimeMs: number }>;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports });
+  }
+
+  async function symbol(uri: string, name: string) {
+    return (await index.getFileSymbols(uri)).find(s => s.name === name)!;
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    addGoFile(STORE_GO, storeGo);
+    addGoFile(MAIN_GO, mainGo);
+    // Same file name, shorter common suffix with the profile's
+    addGoFile('/ws/legacy/store.go', 'package legacy\n\nfunc Get() {}\n');
+    profiles = { [PROFILE]: { text: profile, mtimeMs: 1 } };
+    coverage = new CoverageIndex(index.asBackgroundIndex(), {
+      readFile: async filePath => profiles[filePath].text,
+      stat: async filePath => {
+        const entry = profiles[filePath];
+        if (!entry) {
+          throw new Error('ENOENT');
+        }
+        return { mtimeMs: entry.mtimeMs, size: entry.text.length };
+      }
+    });
+    coverage.setProfiles([PROFILE]);
+    await coverage.refresh();
+  });
+
+  it('should compute coverage per function, closures included', async () => {
+    expect(coverage.coverageOf(await symbol(STORE_GO, 'New'))).toEqual({ statements: 1, covered: 1, percent: 100 });
+    expect(coverage.coverageOf(await symbol(STORE_GO, 'Get'))).toEqual({ statements: 2, covered: 0, percent: 0 });
+    expect(coverage.coverageOf(await symbol(STORE_GO, 'Each'))).toEqual({ statements: 3, covered: 2, percent: 66.7 });
+    expect(coverage.coverageOf(await symbol(STORE_GO, 'Each$1'))).toEqual({ statements: 1, covered: 0, percent: 0 });
+    expect(coverage.coverageOf(await symbol(STORE_GO, 'Store'))).toBeUndefined();
+    expect(coverage.coverageOf(await symbol('/ws/legacy/store.go', 'Get'))).toBeUndefined();
+  });
+
+  it('should list untested exported functions, most referenced first', async () => {
+    const report = await coverage.query({ exported: true, maxCoverage: 0 });
+
+    expect(report.functions.map(entry => [entry.name, entry.percent, entry.references])).toEqual([['Store.Get', 0, 2]]);
+    expect(report.functions[0].location).toMatchObject({ uri: STORE_GO, line: 8 });
+    expect(report).toMatchObject({ files: 2, unmatched: ['example.com/app/internal/gen/gen.go'], total: 1 });
+    expect(report.profiles).toEqual([{ path: PROFILE, loaded: true, mode: 'set' }]);
+
+    const all = await coverage.query();
+    expect(all.functions.map(entry => entry.name)).toEqual(['Store.Get', 'New', 'main', 'Store.Each']);
+    expect(all).toMatchObject({ statements: 9, covered: 3, percent: 33.3 });
+    expect((await coverage.query({ minReferences: 1, minCoverage: 50 })).functions.map(entry => entry.name)).toEqual(['New']);
+    expect((await coverage.query({ limit: 1 })).truncated).toBe(true);
+    expect((await coverage.query({ path: '/ws/cmd' })).functions.map(entry => entry.name)).toEqual(['main']);
+  });
+
+  it('should reread profiles when they change and report missing ones', async () => {
+    profiles[PROFILE] = { text: profile.replace('9.48,12.2 2 0', '9.48,12.2 2 1'), mtimeMs: 2 };
+    expect((await coverage.query({ maxCoverage: 0, exported: true })).functions).toEqual([]);
+
+    coverage.setProfiles([PROFILE, '/ws/other.out']);
+    const report = await coverage.query();
+    expect(report.profiles[1]).toEqual({ path: '/ws/other.out', loaded: false, error: 'not found' });
+
+    profiles['/ws/other.out'] = { text: 'not a profile', mtimeMs: 1 };
+    const malformed = (await coverage.query()).profiles[1];
+    expect(malformed.loaded).toBe(false);
+    expect(malformed.error).toContain('Not a Go coverage profile');
+  });
+});
@@ -0,0 +1,344 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { CoverageBlock, CoverageMode, parseCoverProfile } from '../utils/goCoverage.js';
+import {
+  CancellationToken,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface SymbolCoverage {
+  /** Statements in the function's blocks, closures included */
+  statements: number;
+  /** Of those, statements the tests ran */
+  covered: number;
+  /** covered / statements, 0-100 with one decimal */
+  percent: number;
+}
+
+export interface CoverageEntry extends SymbolCoverage {
+  /** Qualified name, e.g. `Store.Get` */
+  name: string;
+  kind: string;
+  exported: boolean;
+  location: SymbolLocation;
+  /** References to the name across the index (by name, like search ranking) */
+  references: number;
+}
+
+export interface CoverageQuery {
+  /** Only functions covered at most this much, in percent: 0 lists the untested ones */
+  maxCoverage?: number;
+  /** Only functions covered at least this much, in percent */
+  minCoverage?: number;
+  /** Only functions referenced at least this often */
+  minReferences?: number;
+  exported?: boolean;
+  /** Only functions in this file or under this folder */
+  path?: string;
+  /** Number of functions to return (default: 1000) */
+  limit?: number;
+  /** Cancellation token for aborting the lookup */
+  cancellationToken?: CancellationToken;
+}
+
+export interface CoverageProfileStatus {
+  path: string;
+  loaded: boolean;
+  mode?: CoverageMode;
+  /** Why the profile could not be read or parsed */
+  error?: string;
+}
+
+export interface CoverageReport {
+  profiles: CoverageProfileStatus[];
+  /** Workspace files the profiles cover */
+  files: number;
+  /** Profile file names no workspace file matches, e.g. from vendored or generated code */
+  unmatched: string[];
+  /** Statements and covered statements over the matching functions */
+  statements: number;
+  covered: number;
+  percent: number;
+  /** Matching functions, before the limit */
+  total: number;
+  /** Most referenced first, then least covered */
+  functions: CoverageEntry[];
+  truncated: boolean;
+}
+
+/** Functions matching a query; how servers expose the index */
+export type CoverageSource = (query: CoverageQuery) => Promise<CoverageReport>;
+
+/** The part of CoverageIndex queries need; injectable for tests */
+export interface CoverageLookup {
+  refresh(): Promise<void>;
+  coverageOf(symbol: IndexedSymbol): SymbolCoverage | undefined;
+}
+
+/** The part of an index the coverage index reads: the background index or the library Indexer */
+export interface CoverageSourceIndex {
+  getAllFiles(): Promise<string[]>;
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+  getReferenceCounts(names: string[]): Promise<Map<string, number>>;
+}
+
+/** Reads and stats a profile; injectable for tests */
+export interface CoverageFileSystem {
+  readFile(filePath: string): Promise<string>;
+  stat(filePath: string): Promise<{ mtimeMs: number; size: number }>;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+/** Closures are counted in the function they are in, like `go tool cover -func` */
+const FUNCTION_KINDS = new Set(['function', 'method', 'closure']);
+const REPORTED_KINDS = new Set(['function', 'method']);
+
+const nodeFileSystem: CoverageFileSystem = {
+  readFile: filePath => fs.promises.readFile(filePath, 'utf-8'),
+  stat: filePath => fs.promises.stat(filePath)
+};
+
+/**
+ * Coverage Index - per-function statement coverage of Go code from
+ * `go test -coverprofile` profiles (see utils/goCoverage.ts).
+ *
+ * A function's coverage is over the profile blocks inside its range, so
+ * its closures count towards it. Profiles name files by import path
+ * (`example.com/app/internal/user/user.go`); each is matched to the
+ * workspace .go file sharing the longest path suffix, the shortest path
+ * winning ties. Profiles are reread when their size or modification time
+ * changes, so rerunning the tests updates the numbers without
+ * re-indexing. Functions in files no profile lists have no coverage.
+ */
+export class CoverageIndex implements CoverageLookup {
+  private profilePaths: string[] = [];
+  private loaded = new Map<string, { mtimeMs: number; size: number; status: CoverageProfileStatus; files: Map<string, CoverageBlock[]> }>();
+  /** Merged blocks per workspace file */
+  private blocks = new Map<string, CoverageBlock[]>();
+  private unmatched: string[] = [];
+
+  constructor(private index: CoverageSourceIndex, private fileSystem: CoverageFileSystem = nodeFileSystem) {}
+
+  /** Absolute paths of the profiles to read; missing ones are reported, not errors */
+  setProfiles(profilePaths: string[]): void {
+    this.profilePaths = [...profilePaths];
+  }
+
+  getProfiles(): string[] {
+    return [...this.profilePaths];
+  }
+
+  /**
+   * Reread changed profiles and match their files to the workspace files.
+   */
+  async refresh(): Promise<void> {
+    for (const profilePath of [...this.loaded.keys()]) {
+      if (!this.profilePaths.includes(profilePath)) {
+        this.loaded.delete(profilePath);
+      }
+    }
+    for (const profilePath of this.profilePaths) {
+      let stats: { mtimeMs: number; size: number };
+      try {
+        stats = await this.fileSystem.stat(profilePath);
+      } catch {
+        this.loaded.set(profilePath, { mtimeMs: -1, size: -1, status: { path: profilePath, loaded: false, error: 'not found' }, files: new Map() });
+        continue;
+      }
+      const existing = this.loaded.get(profilePath);
+      if (existing && existing.mtimeMs === stats.mtimeMs && existing.size === stats.size) {
+        continue;
+      }
+      try {
+        const profile = parseCoverProfile(await this.fileSystem.readFile(profilePath));
+        this.loaded.set(profilePath, { ...stats, status: { path: profilePath, loaded: true, mode: profile.mode }, files: profile.files });
+      } catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        this.loaded.set(profilePath, { ...stats, status: { path: profilePath, loaded: false, error: message }, files: new Map() });
+      }
+    }
+    await this.matchFiles();
+  }
+
+  /**
+   * Coverage of a function, method or closure; undefined for other symbols
+   * and for functions without statements in the profiles.
+   */
+  coverageOf(symbol: IndexedSymbol): SymbolCoverage | undefined {
+    if (!FUNCTION_KINDS.has(symbol.kind)) {
+      return undefined;
+    }
+    const blocks = this.blocks.get(symbol.location.uri);
+    if (!blocks) {
+      return undefined;
+    }
+    const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+    let statements = 0;
+    let covered = 0;
+    for (const block of blocks) {
+      // Profile positions are one-based
+      const blockStartLine = block.startLine - 1;
+      const blockEndLine = block.endLine - 1;
+      const startsInside = blockStartLine > startLine || (blockStartLine === startLine && block.startColumn - 1 >= startCharacter);
+      const endsInside = blockEndLine < endLine || (blockEndLine === endLine && block.endColumn - 1 <= endCharacter);
+      if (startsInside && endsInside) {
+        statements += block.statements;
+        if (block.count > 0) {
+          covered += block.statements;
+        }
+      }
+    }
+    return statements === 0 ? undefined : { statements, covered, percent: toPercent(covered, statements) };
+  }
+
+  /**
+   * Functions and methods matching the query, with their coverage and
+   * reference counts. Rereads changed profiles first.
+   */
+  async query(query: CoverageQuery = {}): Promise<CoverageReport> {
+    await this.refresh();
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const limit = query.limit ?? DEFAULT_LIMIT;
+
+    const candidates: Array<{ symbol: IndexedSymbol; coverage: SymbolCoverage }> = [];
+    const uris = [...this.blocks.keys()].sort();
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(query.cancellationToken);
+        await yieldToEventLoop();
+      }
+      const uri = uris[i];
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      for (const symbol of await this.index.getFileSymbols(uri)) {
+        if (symbol.isDefinition === false || !REPORTED_KINDS.has(symbol.kind)) {
+          continue;
+        }
+        if (query.exported !== undefined && (symbol.isExported === true) !== query.exported) {
+          continue;
+        }
+        const coverage = this.coverageOf(symbol);
+        if (!coverage ||
+          (query.maxCoverage !== undefined && coverage.percent > query.maxCoverage) ||
+          (query.minCoverage !== undefined && coverage.percent < query.minCoverage)) {
+          continue;
+        }
+        candidates.push({ symbol, coverage });
+      }
+    }
+
+    const counts = candidates.length > 0
+      ? await this.index.getReferenceCounts(candidates.map(({ symbol }) => symbol.name))
+      : new Map<string, number>();
+    const functions: CoverageEntry[] = [];
+    for (const { symbol, coverage } of candidates) {
+      const references = counts.get(symbol.name) ?? 0;
+      if (query.minReferences !== undefined && references < query.minReferences) {
+        continue;
+      }
+      functions.push({
+        name: symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name,
+        kind: symbol.kind,
+        exported: symbol.isExported === true,
+        location: symbol.location,
+        ...coverage,
+        references
+      });
+    }
+    functions.sort((a, b) =>
+      b.references - a.references ||
+      a.percent - b.percent ||
+      (a.location.uri < b.location.uri ? -1 : a.location.uri > b.location.uri ? 1 : a.location.line - b.location.line));
+
+    let statements = 0;
+    let covered = 0;
+    for (const entry of functions) {
+      statements += entry.statements;
+      covered += entry.covered;
+    }
+    return {
+      profiles: this.profilePaths.map(profilePath => this.loaded.get(profilePath)!.status),
+      files: this.blocks.size,
+      unmatched: [...this.unmatched],
+      statements,
+      covered,
+      percent: statements === 0 ? 0 : toPercent(covered, statements),
+      total: functions.length,
+      functions: functions.slice(0, limit),
+      truncated: functions.length > limit
+    };
+  }
+
+  private async matchFiles(): Promise<void> {
+    const byBaseName = new Map<string, string[]>();
+    for (const uri of await this.index.getAllFiles()) {
+      if (path.extname(uri) !== '.go') {
+        continue;
+      }
+      const baseName = path.basename(uri);
+      byBaseName.set(baseName, [...(byBaseName.get(baseName) ?? []), uri]);
+    }
+
+    const merged = new Map<string, Map<string, CoverageBlock>>();
+    const unmatched = new Set<string>();
+    for (const profilePath of this.profilePaths) {
+      for (const [fileName, blocks] of this.loaded.get(profilePath)?.files ?? []) {
+        const uri = matchWorkspaceFile(fileName, byBaseName);
+        if (!uri) {
+          unmatched.add(fileName);
+          continue;
+        }
+        let fileBlocks = merged.get(uri);
+        if (!fileBlocks) {
+          fileBlocks = new Map();
+          merged.set(uri, fileBlocks);
+        }
+        for (const block of blocks) {
+          // The same package tested by several profiles: covered if any run covered it
+          const key = `${block.startLine}.${block.startColumn},${block.endLine}.${block.endColumn}`;
+          const existing = fileBlocks.get(key);
+          fileBlocks.set(key, existing ? { ...existing, count: existing.count + block.count } : block);
+        }
+      }
+    }
+    this.blocks = new Map([...merged].map(([uri, blocks]) => [uri, [...blocks.values()]]));
+    this.unmatched = [...unmatched].sort();
+  }
+}
+
+/**
+ * The workspace file sharing the most trailing path segments with a
+ * profile file name; the shortest path wins ties, so the module root's
+ * `main.go` is preferred over `cmd/tool/main.go` for `example.com/app/main.go`.
+ */
+function matchWorkspaceFile(fileName: string, byBaseName: Map<string, string[]>): string | undefined {
+  const segments = fileName.split('/');
+  const candidates = byBaseName.get(segments[segments.length - 1]);
+  if (!candidates) {
+    return undefined;
+  }
+  let best: string | undefined;
+  let bestLength = 0;
+  for (const uri of candidates) {
+    const uriSegments = uri.split(/[\\/]/);
+    let length = 0;
+    while (length < segments.length && length < uriSegments.length &&
+      segments[segments.length - 1 - length] === uriSegments[uriSegments.length - 1 - length]) {
+      length++;
+    }
+    if (length > bestLength || (length === bestLength && best !== undefined && uri.length < best.length)) {
+      best = uri;
+      bestLength = length;
+    }
+  }
+  return best;
+}
+
+function toPercent(covered: number, statements: number): number {
+  return Math.round((covered / statements) * 1000) / 10;
+}
@@ -0,0 +1,93 @@
+/**
+ * Daemon Tests
+ *
+ * Serves a query server on a per-repository unix socket, finds it from
+ * folders below the root but not from other repositories or users, and
+ * replaces sockets left by dead daemons.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { connectDaemon, DaemonClient, daemonSocketPath, findDaemon, prepareDaemonSocket, repositoryRoot } from './daemon.js';
+import { QueryServer } from './queryServer.js';
+import { MockIndex, createTestSymbol } from '../test/mocks/MockIndex.js';
+
+describe('Daemon', () => {
+  let root: string;
+  let server: QueryServer;
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'daemon-'));
+    fs.mkdirSync(path.join(root, 'pkg', 'user'), { recursive: true });
+    fs.mkdirSync(path.join(root, '.git'));
+    const index = new MockIndex();
+    index.addSymbol(createTestSymbol({ name: 'Load', kind: 'function', filePath: path.join(root, 'pkg/user/load.go') }));
+    server = new QueryServer(index);
+  });
+
+  afterEach(async () => {
+    await server.stop();
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should serve queries on the socket of the root and be found from below it', async () => {
+    const socketPath = daemonSocketPath(root);
+    expect(socketPath).toBe(path.join(root, '.smart-index', 'daemon.sock'));
+    expect(await findDaemon(root)).toBeUndefined();
+
+    await prepareDaemonSocket(socketPath);
+    await server.startOnSocket(socketPath);
+    expect(server.socketPath()).toBe(socketPath);
+    expect(server.address()).toBeNull();
+
+    expect(await findDaemon(path.join(root, 'pkg', 'user'))).toBe(socketPath);
+    const client = await connectDaemon(path.join(root, 'pkg'));
+    const response = await client!.get('/definition?name=Load');
+    expect(response.status).toBe(200);
+    expect((response.body as { definitions: { name: string }[] }).definitions.map(d => d.name)).toEqual(['Load']);
+    expect((await client!.request('POST', '/batch', '/symbols?q=Load\n')).text).toContain('"status":200');
+
+    await expect(prepareDaemonSocket(socketPath)).rejects.toThrow('A daemon is already running');
+  });
+
+  it('should only let the owner connect and stop searching at the repository root', async () => {
+    const socketPath = daemonSocketPath(root);
+    await prepareDaemonSocket(socketPath);
+    await server.startOnSocket(socketPath);
+    expect(fs.statSync(socketPath).mode & 0o777).toBe(0o600);
+
+    // A nested repository does not use the daemon of the one around it
+    const nested = path.join(root, 'third_party', 'lib');
+    fs.mkdirSync(path.join(nested, '.git'), { recursive: true });
+    expect(repositoryRoot(nested)).toBe(nested);
+    expect(await findDaemon(nested)).toBeUndefined();
+    fs.rmSync(path.join(root, '.git'), { recursive: true });
+    fs.writeFileSync(path.join(root, 'smart-indexer.yaml'), '');
+    expect(repositoryRoot(path.join(root, 'pkg'))).toBe(root);
+    expect(await findDaemon(path.join(root, 'pkg'))).toBe(socketPath);
+
+    // Outside a repository only the folder itself is searched
+    fs.rmSync(path.join(root, 'smart-indexer.yaml'));
+    expect(await findDaemon(path.join(root, 'pkg'))).toBeUndefined();
+    expect(await findDaemon(root)).toBe(socketPath);
+
+    // Root can hand the socket to another user, whose daemon it then is
+    if (process.getuid?.() === 0) {
+      fs.chownSync(socketPath, 4321, 4321);
+      expect(await findDaemon(root)).toBeUndefined();
+    }
+  });
+
+  it('should replace sockets left behind by dead daemons', async () => {
+    const socketPath = daemonSocketPath(root);
+    fs.mkdirSync(path.dirname(socketPath), { recursive: true });
+    fs.writeFileSync(socketPath, '');
+
+    expect(await findDaemon(root)).toBeUndefined();
+    await prepareDaemonSocket(socketPath);
+    await server.startOnSocket(socketPath);
+    expect((await new DaemonClient(socketPath).get('/health')).body).toEqual({ status: 'ok' });
+  });
+});
@@ -0,0 +1,185 @@
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as http from 'http';
+import * as os from 'os';
+import * as path from 'path';
+import { CONFIG_FILE_NAMES } from '../config/configFile.js';
+
+/** Where a daemon listens, relative to the root it serves */
+export const DAEMON_SOCKET = path.join('.smart-index', 'daemon.sock');
+
+/** How long a socket may take to answer before it counts as dead */
+const PROBE_TIMEOUT_MS = 1000;
+
+export interface DaemonResponse {
+  status: number;
+  /** Parsed JSON body */
+  body?: unknown;
+  /** Plain-text and NDJSON bodies, as sent */
+  text?: string;
+}
+
+/**
+ * Socket of the daemon serving root: `.smart-index/daemon.sock` under
+ * it, or on Windows a named pipe derived from the user and the root.
+ */
+export function daemonSocketPath(root: string): string {
+  if (process.platform === 'win32') {
+    const hash = crypto.createHash('sha256')
+      .update(`${os.userInfo().username}\0${path.resolve(root).toLowerCase()}`)
+      .digest('hex')
+      .slice(0, 16);
+    return `\\\\.\\pipe\\smart-indexer-${hash}`;
+  }
+  return path.join(path.resolve(root), DAEMON_SOCKET);
+}
+
+/**
+ * The repository or workspace dir is in: the nearest folder, dir included,
+ * holding `.git` or a config file (see config/configFile.ts).
+ */
+export function repositoryRoot(dir: string): string | undefined {
+  let current = path.resolve(dir);
+  for (;;) {
+    if (['.git', ...CONFIG_FILE_NAMES].some(name => fs.existsSync(path.join(current, name)))) {
+      return current;
+    }
+    const parent = path.dirname(current);
+    if (parent === current) {
+      return undefined;
+    }
+    current = parent;
+  }
+}
+
+/**
+ * The socket of a running daemon serving dir or a folder above it up to
+ * its repository root (only dir outside a repository), the deepest one
+ * first. Sockets other users own, and those left behind by daemons that
+ * died, are skipped.
+ */
+export async function findDaemon(dir: string): Promise<string | undefined> {
+  let current = path.resolve(dir);
+  const root = repositoryRoot(current) ?? current;
+  for (;;) {
+    const socketPath = daemonSocketPath(current);
+    if (isOwnSocket(socketPath) && await isDaemonRunning(socketPath)) {
+      return socketPath;
+    }
+    if (current === root) {
+      return undefined;
+    }
+    current = path.dirname(current);
+  }
+}
+
+/**
+ * Whether socketPath exists and belongs to this user, so queries and
+ * buffers never go to a daemon someone else started. Named pipes have no
+ * owner to check; they only let their creator write to them.
+ */
+function isOwnSocket(socketPath: string): boolean {
+  if (process.platform === 'win32') {
+    return true;
+  }
+  try {
+    return fs.statSync(socketPath).uid === process.getuid!();
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * A client of the daemon serving dir or a folder above it, if one is
+ * running; callers fall back to indexing themselves without one.
+ */
+export async function connectDaemon(dir: string): Promise<DaemonClient | undefined> {
+  const socketPath = await findDaemon(dir);
+  return socketPath ? new DaemonClient(socketPath) : undefined;
+}
+
+/**
+ * Whether a daemon answers its health check on socketPath.
+ */
+export async function isDaemonRunning(socketPath: string): Promise<boolean> {
+  try {
+    const response = await new DaemonClient(socketPath).request('GET', '/health', undefined, PROBE_TIMEOUT_MS);
+    return response.status === 200;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * Make socketPath ready to listen on: create its folder and remove a
+ * socket a dead daemon left behind. Throws if a daemon is running on it.
+ */
+export async function prepareDaemonSocket(socketPath: string): Promise<void> {
+  if (process.platform === 'win32') {
+    if (await isDaemonRunning(socketPath)) {
+      throw new Error(`A daemon is already running on ${socketPath}`);
+    }
+    return;
+  }
+  await fs.promises.mkdir(path.dirname(socketPath), { recursive: true });
+  if (!fs.existsSync(socketPath)) {
+    return;
+  }
+  if (await isDaemonRunning(socketPath)) {
+    throw new Error(`A daemon is already running on ${socketPath}`);
+  }
+  await fs.promises.rm(socketPath, { force: true });
+}
+
+/**
+ * Daemon Client - sends query server requests (see features/queryServer.ts)
+ * to a daemon over its socket, so a short-lived process gets answers from
+ * an index that is already loaded instead of indexing on every run:
+ *
+ *   const daemon = await connectDaemon(process.cwd());
+ *   const { body } = daemon ? await daemon.get('/definition?name=Load') : ...;
+ */
+export class DaemonClient {
+  constructor(private socketPath: string) {}
+
+  get(url: string): Promise<DaemonResponse> {
+    return this.request('GET', url);
+  }
+
+  /**
+   * Send a request; JSON replies are parsed, others come back as text.
+   * Throws when the daemon cannot be reached or takes longer than timeoutMs.
+   */
+  request(method: string, url: string, body?: string, timeoutMs?: number): Promise<DaemonResponse> {
+    return new Promise((resolve, reject) => {
+      const req = http.request({
+        socketPath: this.socketPath,
+        method,
+        path: url,
+        headers: body !== undefined ? { 'Content-Length': Buffer.byteLength(body) } : undefined
+      }, res => {
+        const chunks: Buffer[] = [];
+        res.on('data', (chunk: Buffer) => chunks.push(chunk));
+        res.on('error', reject);
+        res.on('end', () => {
+          const text = Buffer.concat(chunks).toString('utf-8');
+          const status = res.statusCode ?? 0;
+          if (!String(res.headers['content-type']).startsWith('application/json')) {
+            resolve({ status, text });
+            return;
+          }
+          try {
+            resolve({ status, body: JSON.parse(text) });
+          } catch (error) {
+            reject(error);
+          }
+        });
+      });
+      req.on('error', reject);
+      if (timeoutMs !== undefined) {
+        req.setTimeout(timeoutMs, () => req.destroy(new Error(`Daemon on ${this.socketPath} did not answer within ${timeoutMs}ms`)));
+      }
+      req.end(body);
+    });
+  }
+}
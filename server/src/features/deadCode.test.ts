@@ -0,0 +1,129 @@
+/**
+ * DeadCodeDetector Tests
+ *
+ * Verifies the unreferenced symbol report over indexed Go sources.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { DeadCodeDetector } from './deadCode.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const mainGo = `package main
+
+func main() {
+	run()
+}
+
+func run() {
+	helper()
+}
+
+func helper() {}
+
+func unused() {
+	orphan()
+}
+
+func orphan() {}
+
+func ping() {
+	pong()
+}
+
+func pong() {
+	ping()
+}
+
+var debugMode = false
+
+type Store interface {
+	Save(name string) error
+}
+
+type memStore struct{}
+
+func (m *memStore) Save(name string) error {
+	return nil
+}
+
+func (m *memStore) reset() {}
+
+func HandleWebhook() {}
+`;
+
+const mainTestGo = `package main
+
+import "testing"
+
+func TestRun(t *testing.T) {
+	run()
+}
+
+func testHelper() {}
+`;
+
+describe('DeadCodeDetector.findUnreferencedSymbols', () => {
+  let index: MockBackgroundIndex;
+  let detector: DeadCodeDetector;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports });
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    detector = new DeadCodeDetector(index as unknown as BackgroundIndex);
+    addGoFile('/repo/cmd/app/main.go', mainGo);
+    addGoFile('/repo/cmd/app/main_test.go', mainTestGo);
+  });
+
+  it('should report unreferenced and transitively dead symbols', async () => {
+    const report = await detector.findUnreferencedSymbols();
+
+    expect(report.symbols.map(s => [s.symbol.name, s.reason, s.exported, s.confidence])).toEqual([
+      ['unused', 'never-referenced', false, 'high'],
+      ['orphan', 'only-dead-callers', false, 'low'],
+      ['ping', 'only-dead-callers', false, 'low'],
+      ['pong', 'only-dead-callers', false, 'low'],
+      ['debugMode', 'never-referenced', false, 'high'],
+      ['Store', 'never-referenced', true, 'medium'],
+      ['reset', 'never-referenced', false, 'high'],
+      ['HandleWebhook', 'never-referenced', true, 'medium']
+    ]);
+    // memStore is kept by its Save method, which satisfies Store
+    expect(report.analyzedFiles).toBe(1);
+  });
+
+  it('should honour allowlists and the exported filter', async () => {
+    const report = await detector.findUnreferencedSymbols({
+      allowlist: ['Handle*', 'memStore.*'],
+      includeExported: false,
+      includeTests: true
+    });
+    const names = report.symbols.map(s => s.symbol.name);
+
+    expect(names).not.toContain('HandleWebhook');
+    expect(names).not.toContain('reset');
+    expect(names).toContain('testHelper');
+    expect(names).not.toContain('TestRun');
+    // Store is exported and filtered out; Save satisfies Store
+    expect(names).not.toContain('Store');
+    expect(names).not.toContain('Save');
+  });
+
+  it('should format a report grouped by file', async () => {
+    const report = await detector.findUnreferencedSymbols({ allowlist: ['*'] });
+    expect(detector.formatUnreferencedReport(report, '/repo')).toBe(
+      'Dead code: 0 unreferenced symbols (13 checked in 1 files)\n'
+    );
+
+    const full = detector.formatUnreferencedReport(await detector.findUnreferencedSymbols(), '/repo');
+    expect(full).toContain('\ncmd/app/main.go\n');
+    expect(full).toContain('  13:6\tfunction\tunused\tnever referenced (high)');
+    expect(full).toContain('\tmethod\tmemStore.reset\tnever referenced (high)');
+  });
+});
@@ -2,7 +2,10 @@ import { BackgroundIndex } from '../index/backgroundIndex.js';
 import { IndexedSymbol, IndexedReference, IndexedFileResult } from '../types.js';
 import { ConfigurationManager } from '../config/configurationManager.js';
 import { pluginRegistry } from '../plugins/FrameworkPlugin.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { PythonSymbolMetadata } from '../indexer/pythonIndexer.js';
 import * as fsPromises from 'fs/promises';
+import * as path from 'path';
 import { minimatch } from 'minimatch';
 import { 
   CancellationToken, 
@@ -36,6 +39,76 @@ export interface DeadCodeOptions {
   onProgress?: ProgressCallback;
 }
 
+export interface UnreferencedSymbolOptions {
+  /** Extra name patterns that are never reported (`Container.name` is matched too) */
+  allowlist?: string[];
+  excludePatterns?: string[];
+  /** Report symbols declared in test files as well */
+  includeTests?: boolean;
+  /** Report exported symbols (default true) */
+  includeExported?: boolean;
+  /** Scope path to limit reported symbols to a specific folder */
+  scopePath?: string;
+  /** Cancellation token for aborting the operation */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting analysis progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface UnreferencedSymbol {
+  symbol: IndexedSymbol;
+  exported: boolean;
+  /** `never-referenced`, or only referenced from other unreferenced code */
+  reason: 'never-referenced' | 'only-dead-callers';
+  confidence: 'high' | 'medium' | 'low';
+}
+
+export interface UnreferencedSymbolReport {
+  symbols: UnreferencedSymbol[];
+  checkedSymbols: number;
+  analyzedFiles: number;
+}
+
+/**
+ * Names that are invoked by a runtime or test framework rather than by code:
+ * Go entry points, tests and common stdlib interface methods, Python dunder
+ * methods and unittest/pytest hooks.
+ */
+const DEFAULT_ALLOWLIST = [
+  'main',
+  'init',
+  'Test*',
+  'Benchmark*',
+  'Example*',
+  'Fuzz*',
+  'String',
+  'Error',
+  'ServeHTTP',
+  'MarshalJSON',
+  'UnmarshalJSON',
+  '__*__',
+  'test_*',
+  'setUp',
+  'tearDown',
+  'setUpClass',
+  'tearDownClass'
+];
+
+/**
+ * Symbol kinds covered by the unreferenced symbol report.
+ */
+const REPORTED_KINDS = new Set([
+  'function',
+  'method',
+  'class',
+  'interface',
+  'type',
+  'enum',
+  'struct',
+  'constant',
+  'variable'
+]);
+
 /**
  * Angular lifecycle hooks that should never be flagged as unused
  * (called by the framework, not directly referenced in code)
@@ -300,6 +373,183 @@ export class DeadCodeDetector {
     };
   }
 
+  /**
+   * Functions, types and variables that nothing references outside their
+   * own declaration, exported or not.
+   *
+   * References are matched by name (as in find-references), so a symbol
+   * sharing its name with a used one is kept. Each reference is attributed
+   * to the innermost reported symbol enclosing it; symbols are reachable
+   * from references outside any such symbol (top-level code, test files,
+   * excluded files) and from allowlisted symbols. Whatever is not reachable
+   * is reported, so functions only called from dead code (including
+   * mutually recursive ones) are reported too.
+   *
+   * Never reported: allowlisted names, framework hooks, methods named like
+   * an interface method (they may be called through the interface), Python
+   * functions with decorators (registered by the decorator) and Go module
+   * dependencies.
+   */
+  async findUnreferencedSymbols(options: UnreferencedSymbolOptions = {}): Promise<UnreferencedSymbolReport> {
+    const { cancellationToken, onProgress, scopePath } = options;
+    const includeExported = options.includeExported ?? true;
+    const allowlist = [...DEFAULT_ALLOWLIST, ...(options.allowlist ?? [])];
+
+    throwIfCancelled(cancellationToken);
+    const allFiles = await this.backgroundIndex.getAllFiles();
+    const YIELD_INTERVAL = 50;
+
+    interface Node {
+      symbol: IndexedSymbol;
+      live: boolean;
+      referenced: boolean;
+      /** Names referenced from inside this symbol (and not a nested one) */
+      uses: string[];
+    }
+
+    const nodesByName = new Map<string, Node[]>();
+    const rootNames = new Set<string>();
+    const interfaceMethods = new Set<string>();
+    const nodes: Node[] = [];
+    let analyzedFiles = 0;
+
+    onProgress?.(0, allFiles.length, 'Collecting references...');
+
+    for (let i = 0; i < allFiles.length; i++) {
+      const fileUri = allFiles[i];
+      if (i % YIELD_INTERVAL === 0 && i > 0) {
+        await yieldToEventLoop();
+        throwIfCancelled(cancellationToken);
+        onProgress?.(i, allFiles.length, `Collecting references... (${i}/${allFiles.length} files)`);
+      }
+
+      const fileResult = await this.backgroundIndex.getFileResult(fileUri);
+      if (!fileResult) {
+        continue;
+      }
+
+      for (const symbol of fileResult.symbols) {
+        if (symbol.kind === 'method' && symbol.containerKind === 'interface') {
+          interfaceMethods.add(symbol.name);
+        }
+      }
+
+      const analyzed = !(scopePath && !this.isFileInScope(fileUri, scopePath)) &&
+        !this.shouldExcludeFile(fileUri, options.excludePatterns || [], options.includeTests || false);
+      const fileNodes: Node[] = [];
+      if (analyzed) {
+        analyzedFiles++;
+        for (const symbol of fileResult.symbols) {
+          if (this.isReportableSymbol(symbol)) {
+            const node: Node = { symbol, live: false, referenced: false, uses: [] };
+            fileNodes.push(node);
+            nodes.push(node);
+            const named = nodesByName.get(symbol.name);
+            if (named) {
+              named.push(node);
+            } else {
+              nodesByName.set(symbol.name, [node]);
+            }
+          }
+        }
+      }
+
+      for (const ref of fileResult.references) {
+        if (ref.isImport || ref.isLocal) {
+          continue;
+        }
+        const enclosing = this.innermostEnclosing(ref, fileNodes);
+        if (!enclosing) {
+          rootNames.add(ref.symbolName);
+        } else if (enclosing.symbol.name !== ref.symbolName) {
+          enclosing.uses.push(ref.symbolName);
+        }
+      }
+    }
+
+    // Mark everything reachable from roots
+    const queue: Node[] = [];
+    const markLive = (node: Node) => {
+      if (!node.live) {
+        node.live = true;
+        queue.push(node);
+      }
+    };
+
+    for (const node of nodes) {
+      for (const name of node.uses) {
+        for (const target of nodesByName.get(name) ?? []) {
+          if (target !== node) {
+            target.referenced = true;
+          }
+        }
+      }
+      if (rootNames.has(node.symbol.name)) {
+        node.referenced = true;
+        markLive(node);
+      } else if (this.isKeptSymbol(node.symbol, allowlist, interfaceMethods)) {
+        markLive(node);
+      }
+    }
+
+    while (queue.length > 0) {
+      const node = queue.shift()!;
+      for (const name of node.uses) {
+        for (const target of nodesByName.get(name) ?? []) {
+          markLive(target);
+        }
+      }
+    }
+
+    const symbols: UnreferencedSymbol[] = [];
+    for (const node of nodes) {
+      const exported = this.isExported(node.symbol);
+      if (node.live || (exported && !includeExported)) {
+        continue;
+      }
+      symbols.push({
+        symbol: node.symbol,
+        exported,
+        reason: node.referenced ? 'only-dead-callers' : 'never-referenced',
+        // Exported symbols may still be used by code outside the workspace
+        confidence: node.referenced ? 'low' : exported ? 'medium' : 'high'
+      });
+    }
+
+    symbols.sort((a, b) =>
+      a.symbol.filePath.localeCompare(b.symbol.filePath) || a.symbol.location.line - b.symbol.location.line
+    );
+    onProgress?.(allFiles.length, allFiles.length, 'Analysis complete');
+
+    return { symbols, checkedSymbols: nodes.length, analyzedFiles };
+  }
+
+  /**
+   * Plain-text report grouped by file, paths relative to workspaceRoot.
+   */
+  formatUnreferencedReport(report: UnreferencedSymbolReport, workspaceRoot?: string): string {
+    const lines = [
+      `Dead code: ${report.symbols.length} unreferenced symbols ` +
+      `(${report.checkedSymbols} checked in ${report.analyzedFiles} files)`
+    ];
+
+    let currentFile: string | undefined;
+    for (const entry of report.symbols) {
+      const { symbol } = entry;
+      if (symbol.filePath !== currentFile) {
+        currentFile = symbol.filePath;
+        lines.push('', workspaceRoot ? path.relative(workspaceRoot, currentFile).split(path.sep).join('/') : currentFile);
+      }
+      const name = symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+      const reason = entry.reason === 'never-referenced' ? 'never referenced' : 'only referenced from unused code';
+      lines.push(
+        `  ${symbol.location.line + 1}:${symbol.location.character + 1}\t${symbol.kind}\t${name}\t` +
+        `${reason}${entry.exported ? ', exported' : ''} (${entry.confidence})`
+      );
+    }
+    return lines.join('\n') + '\n';
+  }
+
   /**
    * Check if a file is an entry point (public API boundary)
    */
@@ -447,6 +697,10 @@ export class DeadCodeDetector {
           return true;
         }
       }
+      // Go and pytest naming conventions
+      if (/(_test\.go|\/test_[^/]*\.py|_test\.py)$/.test(normalizedUri)) {
+        return true;
+      }
     }
     
     return false;
@@ -476,6 +730,63 @@ export class DeadCodeDetector {
     return exportableKinds.includes(symbol.kind);
   }
 
+  /**
+   * Functions, methods, types and top-level variables; interface methods
+   * are contracts rather than code and Go module dependencies are not ours.
+   */
+  private isReportableSymbol(symbol: IndexedSymbol): boolean {
+    if (!REPORTED_KINDS.has(symbol.kind) || symbol.isDefinition === false) {
+      return false;
+    }
+    if ((symbol.kind === 'variable' || symbol.kind === 'constant') && symbol.containerName) {
+      return false;
+    }
+    if (symbol.kind === 'method' && symbol.containerKind === 'interface') {
+      return false;
+    }
+    return !(symbol.metadata?.go as GoSymbolMetadata | undefined)?.thirdParty;
+  }
+
+  /**
+   * Symbols that are alive regardless of references.
+   */
+  private isKeptSymbol(symbol: IndexedSymbol, allowlist: string[], interfaceMethods: Set<string>): boolean {
+    const qualifiedName = symbol.containerName ? `${symbol.containerName}.${symbol.name}` : undefined;
+    if (allowlist.some(pattern => minimatch(symbol.name, pattern) || (qualifiedName && minimatch(qualifiedName, pattern)))) {
+      return true;
+    }
+    if (this.isFrameworkMethod(symbol)) {
+      return true;
+    }
+    if (symbol.kind === 'method' && interfaceMethods.has(symbol.name)) {
+      return true;
+    }
+    return ((symbol.metadata?.python as PythonSymbolMetadata | undefined)?.decorators?.length ?? 0) > 0;
+  }
+
+  private isExported(symbol: IndexedSymbol): boolean {
+    return symbol.isExported ?? (!symbol.containerName && this.isExportedSymbol(symbol, []));
+  }
+
+  /**
+   * Innermost symbol whose range contains the reference.
+   */
+  private innermostEnclosing<T extends { symbol: IndexedSymbol }>(ref: IndexedReference, nodes: T[]): T | undefined {
+    let best: T | undefined;
+    for (const node of nodes) {
+      if (!this.isPositionWithinRange(ref, node.symbol)) {
+        continue;
+      }
+      const range = node.symbol.range;
+      if (!best ||
+          range.startLine > best.symbol.range.startLine ||
+          (range.startLine === best.symbol.range.startLine && range.startCharacter >= best.symbol.range.startCharacter)) {
+        best = node;
+      }
+    }
+    return best;
+  }
+
   /**
    * Check if symbol has @public, @api, or @export marker in JSDoc or comments.
    */
@@ -0,0 +1,125 @@
+/**
+ * DependencyGraph Tests
+ *
+ * Verifies package edges resolved from indexed imports, cycle detection
+ * and layering rule violations.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { DependencyGraph, findCycles } from './dependencyGraph.js';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const root = '/repo';
+
+describe('DependencyGraph', () => {
+  let index: MockBackgroundIndex;
+
+  function addGoFile(uri: string, importPath: string, imports: string[]): void {
+    const symbol = createTestSymbol({
+      name: 'X',
+      location: { uri, line: 0, character: 0 },
+      metadata: { go: { package: importPath.split('/').pop(), importPath } }
+    });
+    index.addFile(uri, [symbol], [], {
+      imports: imports.map(spec => ({ localName: spec.split('/').pop()!, moduleSpecifier: spec }))
+    });
+  }
+
+  function addFile(uri: string, imports: string[]): void {
+    index.addFile(uri, [], [], {
+      imports: imports.map(spec => ({ localName: 'x', moduleSpecifier: spec }))
+    });
+  }
+
+  function build(options: Parameters<DependencyGraph['build']>[0] = {}) {
+    return new DependencyGraph(index as unknown as BackgroundIndex, root).build(options);
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+  });
+
+  it('should resolve Go import paths to workspace packages', async () => {
+    addGoFile('/repo/cmd/server/main.go', 'github.com/acme/app/cmd/server', ['github.com/acme/app/pkg/api', 'fmt']);
+    addGoFile('/repo/pkg/api/handler.go', 'github.com/acme/app/pkg/api', ['github.com/acme/app/pkg/store', 'net/http']);
+    addGoFile('/repo/pkg/api/routes.go', 'github.com/acme/app/pkg/api', ['github.com/acme/app/pkg/store']);
+    addGoFile('/repo/pkg/store/store.go', 'github.com/acme/app/pkg/store', []);
+
+    const graph = await build();
+
+    expect(graph.packages.map(p => [p.id, p.files])).toEqual([
+      ['cmd/server', 1], ['pkg/api', 2], ['pkg/store', 1]
+    ]);
+    expect(graph.edges).toEqual([
+      { from: 'cmd/server', to: 'pkg/api', files: ['/repo/cmd/server/main.go'] },
+      { from: 'pkg/api', to: 'pkg/store', files: ['/repo/pkg/api/handler.go', '/repo/pkg/api/routes.go'] }
+    ]);
+    expect(graph.cycles).toEqual([]);
+
+    const withExternal = await build({ includeExternal: true });
+    expect(withExternal.packages.filter(p => p.external).map(p => p.id)).toEqual(['fmt', 'net/http']);
+  });
+
+  it('should resolve relative TypeScript and Python imports', async () => {
+    addFile('/repo/src/app/main.ts', ['../services/user.js', '../utils', 'lodash', '@angular/core/testing']);
+    addFile('/repo/src/services/user.ts', []);
+    addFile('/repo/src/utils/index.ts', []);
+    addFile('/repo/tools/report/cli.py', ['.render', '..common', 'requests']);
+    addFile('/repo/tools/report/render.py', []);
+    addFile('/repo/tools/common/__init__.py', []);
+
+    const graph = await build({ includeExternal: true });
+
+    expect(graph.edges.map(e => `${e.from} -> ${e.to}`)).toEqual([
+      'src/app -> @angular/core',
+      'src/app -> lodash',
+      'src/app -> src/services',
+      'src/app -> src/utils',
+      'tools/report -> requests',
+      'tools/report -> tools/common'
+    ]);
+  });
+
+  it('should report import cycles and layering violations', async () => {
+    addGoFile('/repo/pkg/api/api.go', 'example.com/app/pkg/api', ['example.com/app/pkg/internal/db', 'example.com/app/pkg/service']);
+    addGoFile('/repo/pkg/service/service.go', 'example.com/app/pkg/service', ['example.com/app/pkg/model']);
+    addGoFile('/repo/pkg/model/model.go', 'example.com/app/pkg/model', ['example.com/app/pkg/service']);
+    addGoFile('/repo/pkg/internal/db/db.go', 'example.com/app/pkg/internal/db', []);
+
+    const graph = await build({
+      rules: [{ from: 'pkg/api/**', disallow: ['pkg/internal/db/**'], message: 'use the service layer' }]
+    });
+
+    expect(graph.cycles).toEqual([
+      { packages: ['pkg/model', 'pkg/service'], path: ['pkg/model', 'pkg/service', 'pkg/model'] }
+    ]);
+    expect(graph.violations).toEqual([{
+      from: 'pkg/api',
+      to: 'pkg/internal/db',
+      disallowed: 'pkg/internal/db/**',
+      message: 'use the service layer',
+      files: ['/repo/pkg/api/api.go']
+    }]);
+
+    const dot = new DependencyGraph(index as unknown as BackgroundIndex, root).toDot(graph);
+    expect(dot).toContain('"pkg/api" -> "pkg/internal/db" [label="1", color=orange, penwidth=2, tooltip="use the service layer"];');
+    expect(dot).toContain('"pkg/service" -> "pkg/model" [label="1", color=red];');
+  });
+});
+
+describe('findCycles', () => {
+  it('should return the shortest cycle of each strongly connected component', () => {
+    const edge = (from: string, to: string) => ({ from, to, files: [] });
+    const cycles = findCycles([
+      edge('a', 'b'), edge('b', 'c'), edge('c', 'a'), edge('c', 'b'),
+      edge('c', 'd'), edge('d', 'e'), edge('e', 'd')
+    ]);
+
+    expect(cycles).toEqual([
+      { packages: ['a', 'b', 'c'], path: ['a', 'b', 'c', 'a'] },
+      { packages: ['d', 'e'], path: ['d', 'e', 'd'] }
+    ]);
+  });
+});
@@ -0,0 +1,483 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { ImportInfo, IndexedFileResult } from '../types.js';
+import { DependencyRule } from '../config/configurationManager.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { minimatch } from 'minimatch';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface DependencyGraphOptions {
+  /** Keep edges to packages outside the workspace (stdlib, modules, npm packages) */
+  includeExternal?: boolean;
+  /** Layering rules to check */
+  rules?: DependencyRule[];
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface PackageNode {
+  /** Workspace-relative directory (`pkg/api`), or the import path of an external package */
+  id: string;
+  /** Go import path of workspace packages */
+  importPath?: string;
+  files: number;
+  external?: boolean;
+}
+
+export interface PackageEdge {
+  from: string;
+  to: string;
+  /** Files of `from` importing `to` */
+  files: string[];
+}
+
+export interface ImportCycle {
+  /** Packages of one strongly connected component, sorted */
+  packages: string[];
+  /** One concrete cycle through them, first package repeated at the end */
+  path: string[];
+}
+
+export interface LayeringViolation {
+  from: string;
+  to: string;
+  /** Rule pattern that matched `to` */
+  disallowed: string;
+  message?: string;
+  files: string[];
+}
+
+export interface DependencyGraphResult {
+  packages: PackageNode[];
+  edges: PackageEdge[];
+  cycles: ImportCycle[];
+  violations: LayeringViolation[];
+}
+
+const YIELD_INTERVAL = 50;
+
+const TS_EXTENSIONS = ['.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs'];
+
+/**
+ * Dependency Graph - package -> package import edges.
+ *
+ * A package is a directory, named by its workspace-relative path. Imports
+ * are resolved per language from the indexed import statements:
+ * - Go: import paths map to directories through `metadata.go.importPath`
+ * - TS/JS: relative specifiers resolve to indexed files (or `index.*`)
+ * - Python: relative and workspace-rooted absolute modules resolve to
+ *   `.py` files or package `__init__.py`
+ * Everything else (stdlib, module dependencies, npm packages) becomes an
+ * external node, dropped unless `includeExternal` is set.
+ *
+ * Cycles are the strongly connected components of the graph. Layering
+ * rules (`{ from, disallow }` globs over package names) report every edge
+ * from a matching package to a disallowed one.
+ */
+export class DependencyGraph {
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private workspaceRoot: string
+  ) {}
+
+  async build(options: DependencyGraphOptions = {}): Promise<DependencyGraphResult> {
+    const { cancellationToken, onProgress } = options;
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const fileSet = new Set(files);
+    const totalSteps = files.length * 2;
+
+    // Pass 1: packages and Go import paths
+    const packages = new Map<string, PackageNode>();
+    const importsByFile = new Map<string, ImportInfo[]>();
+    const goPackages = new Map<string, string>();
+    const packageByFile = new Map<string, string>();
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, totalSteps, `Collecting packages (${i}/${files.length})`);
+      }
+
+      const fileResult = await this.backgroundIndex.getFileResult(files[i]);
+      if (!fileResult) {
+        continue;
+      }
+      const importPath = goImportPath(fileResult);
+      const external = isExternalPath(files[i], this.workspaceRoot);
+      const id = packageOfFile(files[i], fileResult, this.workspaceRoot);
+      packageByFile.set(files[i], id);
+
+      let node = packages.get(id);
+      if (!node) {
+        node = { id, files: 0, ...(external && { external: true }) };
+        packages.set(id, node);
+      }
+      node.files++;
+      if (importPath) {
+        node.importPath = importPath;
+        goPackages.set(importPath, id);
+      }
+      if (fileResult.imports.length > 0) {
+        importsByFile.set(files[i], fileResult.imports);
+      }
+    }
+
+    // Pass 2: resolve imports to package edges
+    const edges = new Map<string, PackageEdge>();
+    let step = 0;
+    for (const [uri, imports] of importsByFile) {
+      if (step++ % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(files.length + step, totalSteps, `Resolving imports (${step}/${importsByFile.size})`);
+      }
+
+      const from = packageByFile.get(uri)!;
+      for (const imp of imports) {
+        const target = this.resolveImport(uri, imp.moduleSpecifier, fileSet, goPackages, packageByFile, packages);
+        if (!target || target.id === from || (target.external && !options.includeExternal)) {
+          continue;
+        }
+        if (!packages.has(target.id)) {
+          packages.set(target.id, { id: target.id, files: 0, external: true });
+        }
+
+        const key = `${from}\0${target.id}`;
+        let edge = edges.get(key);
+        if (!edge) {
+          edge = { from, to: target.id, files: [] };
+          edges.set(key, edge);
+        }
+        if (!edge.files.includes(uri)) {
+          edge.files.push(uri);
+        }
+      }
+    }
+
+    const result: DependencyGraphResult = {
+      packages: Array.from(packages.values())
+        .filter(p => !p.external || options.includeExternal)
+        .sort((a, b) => a.id.localeCompare(b.id)),
+      edges: Array.from(edges.values()).sort((a, b) => a.from.localeCompare(b.from) || a.to.localeCompare(b.to)),
+      cycles: [],
+      violations: []
+    };
+    result.cycles = findCycles(result.edges);
+    result.violations = checkRules(result.edges, packages, options.rules ?? []);
+    onProgress?.(totalSteps, totalSteps, 'Done');
+    return result;
+  }
+
+  toJson(graph: DependencyGraphResult): string {
+    return JSON.stringify(graph, null, 2);
+  }
+
+  /**
+   * Graphviz DOT: external packages are dashed, cycle edges red and
+   * layering violations bold orange (with the rule message as tooltip).
+   */
+  toDot(graph: DependencyGraphResult): string {
+    const cycleEdges = new Set<string>();
+    for (const cycle of graph.cycles) {
+      const members = new Set(cycle.packages);
+      for (const edge of graph.edges) {
+        if (members.has(edge.from) && members.has(edge.to)) {
+          cycleEdges.add(`${edge.from}\0${edge.to}`);
+        }
+      }
+    }
+    const violations = new Map(graph.violations.map(v => [`${v.from}\0${v.to}`, v]));
+
+    const lines = [
+      'digraph deps {',
+      '  rankdir=LR;',
+      '  node [shape=box, fontname="Helvetica"];'
+    ];
+    for (const node of graph.packages) {
+      const attrs = [`tooltip=${dotString(node.importPath ?? node.id)}`];
+      if (node.external) {
+        attrs.push('style=dashed', 'color=gray50');
+      }
+      lines.push(`  ${dotString(node.id)} [${attrs.join(', ')}];`);
+    }
+    for (const edge of graph.edges) {
+      const key = `${edge.from}\0${edge.to}`;
+      const attrs = [`label="${edge.files.length}"`];
+      const violation = violations.get(key);
+      if (violation) {
+        attrs.push('color=orange', 'penwidth=2', `tooltip=${dotString(violation.message ?? `must not import ${violation.disallowed}`)}`);
+      } else if (cycleEdges.has(key)) {
+        attrs.push('color=red');
+      }
+      lines.push(`  ${dotString(edge.from)} -> ${dotString(edge.to)} [${attrs.join(', ')}];`);
+    }
+    lines.push('}');
+    return lines.join('\n') + '\n';
+  }
+
+  private resolveImport(
+    uri: string,
+    specifier: string,
+    fileSet: Set<string>,
+    goPackages: Map<string, string>,
+    packageByFile: Map<string, string>,
+    packages: Map<string, PackageNode>
+  ): { id: string; external?: boolean } | undefined {
+    const ext = path.extname(uri).toLowerCase();
+    const internal = (file: string) => {
+      const id = packageByFile.get(file) ?? directoryPackage(file, this.workspaceRoot);
+      return { id, external: packages.get(id)?.external };
+    };
+
+    if (ext === '.go') {
+      const id = goPackages.get(specifier);
+      return id ? { id, external: packages.get(id)?.external } : { id: specifier, external: true };
+    }
+
+    if (ext === '.py' || ext === '.pyi') {
+      const file = resolvePythonModule(uri, specifier, this.workspaceRoot, fileSet);
+      if (file) {
+        return internal(file);
+      }
+      return specifier.startsWith('.') ? undefined : { id: specifier.split('.')[0], external: true };
+    }
+
+    if (TS_EXTENSIONS.includes(ext)) {
+      if (specifier.startsWith('./') || specifier.startsWith('../')) {
+        const file = resolveScriptModule(path.resolve(path.dirname(uri), specifier), fileSet);
+        return file ? internal(file) : undefined;
+      }
+      const parts = specifier.split('/');
+      return { id: specifier.startsWith('@') ? parts.slice(0, 2).join('/') : parts[0], external: true };
+    }
+
+    return undefined;
+  }
+
+}
+
+function resolveScriptModule(base: string, fileSet: Set<string>): string | undefined {
+  if (fileSet.has(base)) {
+    return base;
+  }
+  // import './user.js' compiled from user.ts
+  const withoutJs = base.replace(/\.(m|c)?js$/, '');
+  for (const candidate of [withoutJs, base]) {
+    for (const ext of TS_EXTENSIONS) {
+      if (fileSet.has(candidate + ext)) {
+        return candidate + ext;
+      }
+    }
+  }
+  for (const ext of TS_EXTENSIONS) {
+    const index = path.join(base, 'index' + ext);
+    if (fileSet.has(index)) {
+      return index;
+    }
+  }
+  return undefined;
+}
+
+function resolvePythonModule(uri: string, specifier: string, workspaceRoot: string, fileSet: Set<string>): string | undefined {
+  let base: string;
+  let rest: string;
+  if (specifier.startsWith('.')) {
+    const dots = specifier.match(/^\.+/)![0].length;
+    base = path.dirname(uri);
+    for (let i = 1; i < dots; i++) {
+      base = path.dirname(base);
+    }
+    rest = specifier.slice(dots);
+  } else {
+    base = workspaceRoot;
+    rest = specifier;
+  }
+
+  const modulePath = rest ? path.join(base, ...rest.split('.')) : base;
+  for (const candidate of [modulePath + '.py', modulePath + '.pyi', path.join(modulePath, '__init__.py')]) {
+    if (fileSet.has(candidate)) {
+      return candidate;
+    }
+  }
+  return undefined;
+}
+
+/**
+ * The package a file belongs to, named as in the graph: its
+ * workspace-relative directory, or the import path of Go dependencies
+ * indexed from the module cache.
+ */
+export function packageOfFile(uri: string, fileResult: IndexedFileResult, workspaceRoot: string): string {
+  const importPath = goImportPath(fileResult);
+  return isExternalPath(uri, workspaceRoot) && importPath ? importPath : directoryPackage(uri, workspaceRoot);
+}
+
+function goImportPath(fileResult: IndexedFileResult): string | undefined {
+  return (fileResult.symbols.find(s => s.metadata?.go)?.metadata?.go as GoSymbolMetadata | undefined)?.importPath;
+}
+
+function directoryPackage(uri: string, workspaceRoot: string): string {
+  const dir = path.dirname(uri);
+  if (isExternalPath(uri, workspaceRoot)) {
+    return dir.split(path.sep).join('/');
+  }
+  return path.relative(workspaceRoot, dir).split(path.sep).join('/') || '.';
+}
+
+function isExternalPath(uri: string, workspaceRoot: string): boolean {
+  const relative = path.relative(workspaceRoot, uri);
+  return relative.startsWith('..') || path.isAbsolute(relative);
+}
+
+/**
+ * Strongly connected components with more than one package (Tarjan),
+ * each with one concrete cycle.
+ */
+export function findCycles(edges: PackageEdge[]): ImportCycle[] {
+  const adjacency = new Map<string, string[]>();
+  for (const edge of edges) {
+    if (!adjacency.has(edge.from)) {
+      adjacency.set(edge.from, []);
+    }
+    adjacency.get(edge.from)!.push(edge.to);
+  }
+
+  let counter = 0;
+  const index = new Map<string, number>();
+  const lowLink = new Map<string, number>();
+  const stack: string[] = [];
+  const onStack = new Set<string>();
+  const components: string[][] = [];
+
+  // Iterative to survive deep import chains
+  for (const root of Array.from(adjacency.keys()).sort()) {
+    if (index.has(root)) {
+      continue;
+    }
+    const work: Array<{ node: string; next: number }> = [{ node: root, next: 0 }];
+    index.set(root, counter);
+    lowLink.set(root, counter++);
+    stack.push(root);
+    onStack.add(root);
+
+    while (work.length > 0) {
+      const frame = work[work.length - 1];
+      const neighbors = adjacency.get(frame.node) ?? [];
+      if (frame.next < neighbors.length) {
+        const neighbor = neighbors[frame.next++];
+        if (!index.has(neighbor)) {
+          index.set(neighbor, counter);
+          lowLink.set(neighbor, counter++);
+          stack.push(neighbor);
+          onStack.add(neighbor);
+          work.push({ node: neighbor, next: 0 });
+        } else if (onStack.has(neighbor)) {
+          lowLink.set(frame.node, Math.min(lowLink.get(frame.node)!, index.get(neighbor)!));
+        }
+        continue;
+      }
+
+      work.pop();
+      if (work.length > 0) {
+        const parent = work[work.length - 1].node;
+        lowLink.set(parent, Math.min(lowLink.get(parent)!, lowLink.get(frame.node)!));
+      }
+      if (lowLink.get(frame.node) === index.get(frame.node)) {
+        const component: string[] = [];
+        let member: string;
+        do {
+          member = stack.pop()!;
+          onStack.delete(member);
+          component.push(member);
+        } while (member !== frame.node);
+        if (component.length > 1) {
+          components.push(component.sort());
+        }
+      }
+    }
+  }
+
+  return components
+    .map(packages => ({ packages, path: shortestCycle(packages[0], new Set(packages), adjacency) }))
+    .sort((a, b) => a.packages[0].localeCompare(b.packages[0]));
+}
+
+/**
+ * Shortest path start -> ... -> start inside one component (BFS).
+ */
+function shortestCycle(start: string, members: Set<string>, adjacency: Map<string, string[]>): string[] {
+  const previous = new Map<string, string>();
+  const queue = [start];
+  while (queue.length > 0) {
+    const node = queue.shift()!;
+    for (const neighbor of adjacency.get(node) ?? []) {
+      if (!members.has(neighbor)) {
+        continue;
+      }
+      if (neighbor === start) {
+        const cycle = [start];
+        for (let at: string | undefined = node; at !== start && at !== undefined; at = previous.get(at)) {
+          cycle.splice(1, 0, at);
+        }
+        if (node !== start) {
+          cycle.push(start);
+        }
+        return cycle;
+      }
+      if (!previous.has(neighbor)) {
+        previous.set(neighbor, node);
+        queue.push(neighbor);
+      }
+    }
+  }
+  return [start];
+}
+
+function checkRules(edges: PackageEdge[], packages: Map<string, PackageNode>, rules: DependencyRule[]): LayeringViolation[] {
+  const violations: LayeringViolation[] = [];
+  for (const edge of edges) {
+    for (const rule of rules) {
+      if (!matchesPackage(rule.from, packages.get(edge.from))) {
+        continue;
+      }
+      const disallowed = rule.disallow.find(pattern => matchesPackage(pattern, packages.get(edge.to)));
+      if (disallowed) {
+        violations.push({
+          from: edge.from,
+          to: edge.to,
+          disallowed,
+          ...(rule.message && { message: rule.message }),
+          files: edge.files
+        });
+        break;
+      }
+    }
+  }
+  return violations;
+}
+
+/**
+ * Patterns match the package name or its Go import path; `a/**` also
+ * matches `a` itself.
+ */
+function matchesPackage(pattern: string, node: PackageNode | undefined): boolean {
+  if (!node) {
+    return false;
+  }
+  return [node.id, node.importPath].some(name =>
+    name !== undefined &&
+    (minimatch(name, pattern) || (pattern.endsWith('/**') && name === pattern.slice(0, -3)))
+  );
+}
+
+function dotString(value: string): string {
+  return `"${value.replace(/\\/g, '\\\\').replace(/"/g, '\\"')}"`;
+}
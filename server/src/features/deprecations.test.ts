@@ -0,0 +1,134 @@
+/**
+ * Deprecations Tests
+ *
+ * Verifies the usage report over Go packages: uses resolved through the
+ * package qualifier, uses inside deprecated code left out, grouping by
+ * CODEOWNERS owner and the owner, name and tag filters.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { Deprecations } from './deprecations.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { CodeTag } from '../types.js';
+
+const legacyGo = `package legacy
+
+// Get reads a key.
+//
+// Deprecated: use store.Lookup, which
+// caches results.
+func Get(key string) string {
+	return Fetch(key)
+}
+
+// Fetch reads a key without caching.
+func Fetch(key string) string {
+	return ""
+}
+
+// Deprecated: use Client.
+func Connect() {
+	Get("config")
+}
+`;
+
+const storeGo = `package store
+
+func Get(key string) string {
+	return ""
+}
+`;
+
+const billingGo = `package billing
+
+import "example.com/app/legacy"
+
+func Charge() {
+	legacy.Get("rate")
+	legacy.Get("currency")
+	legacy.Connect()
+}
+`;
+
+const authGo = `package auth
+
+import (
+	"example.com/app/legacy"
+	"example.com/app/store"
+)
+
+func Login() {
+	legacy.Get("user")
+	store.Get("session")
+}
+`;
+
+const authTestGo = `package auth
+
+import "example.com/app/legacy"
+
+func TestLogin(t *testing.T) {
+	legacy.Get("fixture")
+}
+`;
+
+const OWNERS: Record<string, string[]> = { '/ws/billing/': ['@org/billing'], '/ws/auth/': ['@org/identity'] };
+
+describe('Deprecations', () => {
+  let index: MockBackgroundIndex;
+  let deprecations: Deprecations;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, tags?: CodeTag[]): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(tags && { tags }) });
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    addGoFile('/ws/legacy/legacy.go', legacyGo);
+    addGoFile('/ws/store/store.go', storeGo);
+    addGoFile('/ws/billing/billing.go', billingGo);
+    addGoFile('/ws/auth/auth.go', authGo);
+    addGoFile('/ws/auth/auth_test.go', authTestGo, ['test']);
+    deprecations = new Deprecations(index.asBackgroundIndex(), {
+      ownersOf: filePath => Object.entries(OWNERS).find(([dir]) => filePath.startsWith(dir))?.[1] ?? []
+    });
+  });
+
+  it('should record deprecation notices from doc comments', async () => {
+    const report = await deprecations.report();
+
+    expect(report.deprecated.map(d => [d.symbol.name, d.notice])).toEqual([
+      ['Get', 'use store.Lookup, which caches results.'],
+      ['Connect', 'use Client.']
+    ]);
+    expect((await index.getFileSymbols('/ws/legacy/legacy.go')).find(s => s.name === 'Fetch')!.deprecated).toBeUndefined();
+  });
+
+  it('should group live uses by owning team', async () => {
+    const report = await deprecations.report();
+
+    // Connect's call to Get is deprecated code itself; store.Get is another Get
+    expect(report).toMatchObject({ callSites: 5, inDeprecatedCode: 1, filesScanned: 5 });
+    expect(report.teams.map(team => [team.owner, team.callSites, team.symbols.map(s => [s.symbol.name, s.callSites.length])])).toEqual([
+      ['@org/billing', 3, [['Get', 2], ['Connect', 1]]],
+      ['@org/identity', 2, [['Get', 2]]]
+    ]);
+    expect(report.unowned.callSites).toBe(0);
+    expect(report.teams[1].symbols[0].callSites[0]).toMatchObject({
+      uri: '/ws/auth/auth.go', line: 8, character: 8, enclosing: 'Login', isCall: true
+    });
+  });
+
+  it('should filter by symbol name, owner and code tag', async () => {
+    const connect = await deprecations.report({ name: 'Connect' });
+    expect(connect.deprecated.map(d => d.symbol.name)).toEqual(['Connect']);
+    expect(connect.callSites).toBe(1);
+
+    const identity = await deprecations.report({ owner: 'org/identity', tags: { exclude: ['test'] } });
+    expect(identity.teams.map(team => [team.owner, team.callSites])).toEqual([['@org/identity', 1]]);
+    expect(identity.deprecated[0].callSites.map(site => site.enclosing)).toEqual(['Login']);
+  });
+});
@@ -0,0 +1,232 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedFileResult, IndexedReference, IndexedSymbol, SymbolRange } from '../types.js';
+import { CodeTagFilter, isTagFilterEmpty, matchesTagFilter } from '../utils/codeTags.js';
+import { normalizeOwner, OwnerLookup } from '../utils/codeOwners.js';
+import { PositionLookup } from './positionLookup.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** The part of an index the report reads: the background index or the library Indexer */
+export type DeprecationSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileResult' | 'findDefinitions'>;
+
+export interface DeprecationOptions {
+  /** Only deprecated symbols with this name (`Get` or `Client.Get`) */
+  name?: string;
+  /** Only call sites in files owned by this owner (`@platform-team`, `platform-team`) */
+  owner?: string;
+  /** Only call sites under this folder */
+  scopePath?: string;
+  /** Code tags of call-site files to leave out or keep, e.g. `{ exclude: ['test'] }` */
+  tags?: CodeTagFilter;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface DeprecatedCallSite {
+  uri: string;
+  /** 0-based position of the use */
+  line: number;
+  character: number;
+  range: SymbolRange;
+  /** The definition the use is in, e.g. `Server.Start`; absent at file level */
+  enclosing?: string;
+  /** Called (or constructed), not only referenced */
+  isCall: boolean;
+}
+
+export interface DeprecatedSymbolUsage {
+  symbol: IndexedSymbol;
+  /** From the doc comment; '' when it gives no reason */
+  notice: string;
+  callSites: DeprecatedCallSite[];
+}
+
+export interface TeamDeprecationUsage {
+  /** As written in CODEOWNERS; undefined for unowned code */
+  owner?: string;
+  callSites: number;
+  /** The deprecated symbols the team still uses, with its call sites only */
+  symbols: DeprecatedSymbolUsage[];
+}
+
+export interface DeprecationReport {
+  /** Every deprecated definition, most call sites first (unused ones too) */
+  deprecated: DeprecatedSymbolUsage[];
+  /** Most call sites first; a file with several owners counts for each */
+  teams: TeamDeprecationUsage[];
+  unowned: TeamDeprecationUsage;
+  /** Live call sites */
+  callSites: number;
+  /** Uses inside deprecated definitions, which go away with them; not counted */
+  inDeprecatedCode: number;
+  filesScanned: number;
+}
+
+const YIELD_INTERVAL = 50;
+
+/**
+ * Deprecations - every live use of a deprecated symbol, grouped by the
+ * CODEOWNERS owner of the code that still uses it, to drive migrations.
+ *
+ * Symbols are deprecated by their doc comment (`Deprecated:` paragraphs in
+ * Go, `@deprecated` in JSDoc, `.. deprecated::` in docstrings; see
+ * utils/docComments.ts), recorded as `deprecated` when indexing. Uses are
+ * matched to definitions like go-to-definition does (see
+ * features/positionLookup.ts), so `legacy.Get` is not confused with
+ * another package's `Get`. Uses inside deprecated definitions are left out:
+ * they go away with the code they are in.
+ */
+export class Deprecations {
+  private lookup: PositionLookup;
+
+  constructor(private index: DeprecationSourceIndex, private codeOwners: OwnerLookup) {
+    this.lookup = new PositionLookup(index);
+  }
+
+  async report(options: DeprecationOptions = {}): Promise<DeprecationReport> {
+    const { cancellationToken, onProgress } = options;
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+    const tags = options.tags && !isTagFilterEmpty(options.tags) ? options.tags : undefined;
+    const wanted = options.owner !== undefined ? normalizeOwner(options.owner) : undefined;
+    const files = (await this.index.getAllFiles()).sort();
+
+    // Pass 1: deprecated definitions
+    const deprecated = new Map<string, DeprecatedSymbolUsage>();
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length * 2, `Collecting deprecated symbols (${i}/${files.length})`);
+      }
+      for (const symbol of (await this.index.getFileResult(files[i]))?.symbols ?? []) {
+        if (symbol.isDefinition !== false && symbol.deprecated !== undefined && matchesName(symbol, options.name)) {
+          deprecated.set(symbolKey(symbol), { symbol, notice: symbol.deprecated, callSites: [] });
+        }
+      }
+    }
+    const names = new Set([...deprecated.values()].map(usage => usage.symbol.name));
+
+    // Pass 2: their uses
+    const teams = new Map<string, TeamDeprecationUsage>();
+    const unowned: TeamDeprecationUsage = { callSites: 0, symbols: [] };
+    let callSites = 0;
+    let inDeprecatedCode = 0;
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(files.length + i, files.length * 2, `Finding uses of deprecated symbols (${i}/${files.length})`);
+      }
+      const uri = files[i];
+      if (names.size === 0 || (scope && uri !== scope && !uri.startsWith(scope + path.sep))) {
+        continue;
+      }
+      const owners = this.codeOwners.ownersOf(uri);
+      if (wanted && !owners.some(owner => normalizeOwner(owner) === wanted)) {
+        continue;
+      }
+      const file = await this.index.getFileResult(uri);
+      if (!file || (tags && !matchesTagFilter(file.tags, tags))) {
+        continue;
+      }
+
+      for (const reference of file.references) {
+        if (reference.isImport || reference.isLocal || !names.has(reference.symbolName)) {
+          continue;
+        }
+        const [definition] = await this.lookup.resolveReference(file, reference);
+        const usage = definition && deprecated.get(symbolKey(definition));
+        if (!usage) {
+          continue;
+        }
+        const enclosing = enclosingDefinitions(file, reference);
+        if (enclosing.some(symbol => symbol.deprecated !== undefined)) {
+          inDeprecatedCode++;
+          continue;
+        }
+
+        const site: DeprecatedCallSite = {
+          uri,
+          line: reference.location.line,
+          character: reference.location.character,
+          range: reference.range,
+          ...(enclosing.length > 0 && { enclosing: qualifiedName(enclosing[0]) }),
+          isCall: reference.isCall === true
+        };
+        usage.callSites.push(site);
+        callSites++;
+        const targets = owners.length > 0 ? owners.map(owner => {
+          let team = teams.get(owner);
+          if (!team) {
+            team = { owner, callSites: 0, symbols: [] };
+            teams.set(owner, team);
+          }
+          return team;
+        }) : [unowned];
+        for (const team of targets) {
+          addCallSite(team, usage, site);
+        }
+      }
+    }
+
+    onProgress?.(files.length * 2, files.length * 2, 'Deprecation report complete');
+    const mostUsed = (a: DeprecatedSymbolUsage, b: DeprecatedSymbolUsage) => b.callSites.length - a.callSites.length;
+    for (const team of [...teams.values(), unowned]) {
+      team.symbols.sort(mostUsed);
+    }
+    return {
+      deprecated: [...deprecated.values()].sort(mostUsed),
+      teams: [...teams.values()].sort((a, b) => b.callSites - a.callSites || (a.owner! < b.owner! ? -1 : 1)),
+      unowned,
+      callSites,
+      inDeprecatedCode,
+      filesScanned: files.length
+    };
+  }
+}
+
+function addCallSite(team: TeamDeprecationUsage, usage: DeprecatedSymbolUsage, site: DeprecatedCallSite): void {
+  let entry = team.symbols.find(s => s.symbol === usage.symbol);
+  if (!entry) {
+    entry = { symbol: usage.symbol, notice: usage.notice, callSites: [] };
+    team.symbols.push(entry);
+  }
+  entry.callSites.push(site);
+  team.callSites++;
+}
+
+/** Definitions of file whose range contains the reference, innermost first */
+function enclosingDefinitions(file: IndexedFileResult, reference: IndexedReference): IndexedSymbol[] {
+  const { startLine, startCharacter } = reference.range;
+  return file.symbols
+    .filter(symbol => symbol.isDefinition !== false && contains(symbol.range, startLine, startCharacter))
+    .sort((a, b) => (a.range.endLine - a.range.startLine) - (b.range.endLine - b.range.startLine));
+}
+
+function contains(range: SymbolRange, line: number, character: number): boolean {
+  if (line < range.startLine || line > range.endLine) {
+    return false;
+  }
+  return (line !== range.startLine || character >= range.startCharacter) &&
+    (line !== range.endLine || character <= range.endCharacter);
+}
+
+function matchesName(symbol: IndexedSymbol, name: string | undefined): boolean {
+  return !name || symbol.name === name || qualifiedName(symbol) === name;
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+/** Definitions come back from name lookups as new objects; match them by position */
+function symbolKey(symbol: IndexedSymbol): string {
+  return `${symbol.location.uri}:${symbol.location.line}:${symbol.location.character}:${symbol.name}`;
+}
@@ -0,0 +1,176 @@
+/**
+ * DocsIndex Tests
+ *
+ * Verifies Markdown and AsciiDoc sections, code blocks and mentions, doc
+ * search, and resolving mentions to indexed Go definitions.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { DocsIndex, parseDocFile } from './docsIndex.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockIndex } from '../test/mocks/MockIndex.js';
+
+const readme = `---
+title: Greeter
+---
+# Greeter
+
+Say hello with \`Person.Greet\` or \`greet.New()\`.
+
+Installation
+------------
+
+\`\`\`bash
+go get example.com/greet  # \`NotAMention\`
+\`\`\`
+
+## Usage ##
+
+Create a \`*Person\` and call \`Greet\`; \`go test ./...\` checks it.
+
+~~~go
+p := greet.New("Ada")
+~~~
+
+## Usage
+`;
+
+const guide = `= Greeter Guide
+
+[[setup]]
+== Set Up
+
+.Example
+[source,go]
+----
+p := greet.New("Ada")
+----
+
+// A comment with \`Greeter\`
+
+== Greeting people
+
+Use \`+Person.Greet+\` to greet a \`Person\`.
+`;
+
+const greetGo = `package greet
+
+type Person struct {
+	Name string
+}
+
+func New(name string) *Person { return &Person{Name: name} }
+
+func (p *Person) Greet() string { return "Hello, " + p.Name }
+`;
+
+const otherGo = `package other
+
+type Robot struct{}
+
+func (r Robot) Greet() string { return "beep" }
+`;
+
+describe('DocsIndex', () => {
+  it('should read Markdown headings, code blocks and mentions', () => {
+    const doc = parseDocFile('/ws/README.md', readme);
+
+    expect(doc.sections.map(s => [s.heading, s.level, s.anchor, s.line, s.endLine])).toEqual([
+      ['Greeter', 1, 'greeter', 3, 22],
+      ['Installation', 2, 'installation', 7, 13],
+      ['Usage', 2, 'usage', 14, 21],
+      ['Usage', 2, 'usage-1', 22, 22]
+    ]);
+    expect(doc.sections[2].parents).toEqual(['Greeter']);
+    expect(doc.sections[1].codeBlocks).toEqual([{ language: 'bash', line: 10, endLine: 12 }]);
+    expect(doc.sections[2].codeBlocks).toEqual([{ language: 'go', line: 18, endLine: 20 }]);
+    expect(doc.sections.flatMap(s => s.mentions.map(m => [m.target, m.text, m.line, m.character, m.heading]))).toEqual([
+      ['Person.Greet', 'Person.Greet', 5, 16, 'Greeter'],
+      ['greet.New', 'greet.New()', 5, 34, 'Greeter'],
+      ['Person', '*Person', 16, 10, 'Usage'],
+      ['Greet', 'Greet', 16, 29, 'Usage']
+    ]);
+  });
+
+  it('should read AsciiDoc sections with anchors and source blocks', () => {
+    const doc = parseDocFile('/ws/docs/guide.adoc', guide);
+
+    expect(doc.format).toBe('asciidoc');
+    expect(doc.sections.map(s => [s.heading, s.level, s.anchor])).toEqual([
+      ['Greeter Guide', 1, '_greeter_guide'],
+      ['Set Up', 2, 'setup'],
+      ['Greeting people', 2, '_greeting_people']
+    ]);
+    expect(doc.sections[1].codeBlocks).toEqual([{ language: 'go', line: 7, endLine: 9 }]);
+    expect(doc.sections[1].mentions).toEqual([]);
+    expect(doc.sections[2].mentions.map(m => [m.target, m.character])).toEqual([['Person.Greet', 6], ['Person', 33]]);
+  });
+
+  describe('with an index', () => {
+    let index: MockIndex;
+    let files: Record<string, string>;
+    let docs: DocsIndex;
+
+    beforeEach(async () => {
+      index = new MockIndex();
+      const indexer = new GoIndexer();
+      for (const [uri, source] of [['/ws/greet/greet.go', greetGo], ['/ws/other/robot.go', otherGo]]) {
+        for (const symbol of indexer.indexFile(uri, source).symbols) {
+          index.addSymbol(symbol);
+        }
+      }
+      files = { '/ws/greet/README.md': readme, '/ws/docs/guide.adoc': guide, '/ws/greet/greet.go': greetGo };
+      docs = new DocsIndex({
+        getAllFiles: async () => Object.keys(files),
+        getFileInfo: uri => ({ hash: String(files[uri]?.length) }),
+        findDefinitions: name => index.findDefinitions(name)
+      }, async uri => files[uri]);
+      expect(await docs.build()).toEqual({ files: 2, sections: 7, updated: 2 });
+    });
+
+    it('should search sections by heading and text, headings first', () => {
+      const report = docs.search({ text: 'usage' });
+      expect(report.sections.map(s => [s.uri, s.heading, s.line])).toEqual([
+        ['/ws/greet/README.md', 'Usage', 14],
+        ['/ws/greet/README.md', 'Usage', 22]
+      ]);
+
+      const hello = docs.search({ text: 'Greet ada' });
+      expect(hello.sections.map(s => s.heading)).toEqual(['Set Up', 'Usage']);
+      expect(docs.search({ text: 'person', language: 'go' }).sections.map(s => s.heading)).toEqual(['Usage']);
+      expect(docs.search({ text: 'person', language: 'bash' }).sections).toEqual([]);
+      expect(docs.search({ text: 'hello' }).sections[0].excerpt).toBe('Say hello with `Person.Greet` or `greet.New()`.');
+      expect(docs.search({ path: '/ws/docs', limit: 1 })).toMatchObject({ total: 3, truncated: true });
+    });
+
+    it('should find the docs mentioning a symbol', async () => {
+      const report = await docs.mentions({ symbol: 'Person.Greet' });
+      expect(report.definitions.map(d => `${d.containerName}.${d.name}`)).toEqual(['Person.Greet']);
+      expect(report.mentions.map(m => [m.uri, m.line, m.text])).toEqual([
+        ['/ws/docs/guide.adoc', 15, 'Person.Greet'],
+        ['/ws/greet/README.md', 5, 'Person.Greet'],
+        // A bare name resolves to the definition next to the document
+        ['/ws/greet/README.md', 16, 'Greet']
+      ]);
+
+      expect((await docs.mentions({ symbol: 'Robot.Greet' })).mentions).toEqual([]);
+      expect((await docs.mentions({ symbol: 'New', path: '/ws/greet' })).mentions.map(m => m.text)).toEqual(['greet.New()']);
+    });
+
+    it('should resolve the links of a document and pick up changes', async () => {
+      const report = await docs.links('/ws/greet/README.md');
+      expect(report.resolved).toBe(4);
+      expect(report.links.map(l => [l.text, l.definition?.location.uri, l.definition?.location.line])).toEqual([
+        ['Person.Greet', '/ws/greet/greet.go', 8],
+        ['greet.New()', '/ws/greet/greet.go', 6],
+        ['*Person', '/ws/greet/greet.go', 2],
+        ['Greet', '/ws/greet/greet.go', 8]
+      ]);
+
+      files['/ws/greet/README.md'] = '# Greeter\n\nSee `Missing`.\n';
+      delete files['/ws/docs/guide.adoc'];
+      expect(await docs.build()).toEqual({ files: 1, sections: 1, updated: 1 });
+      expect((await docs.links('/ws/greet/README.md')).links).toMatchObject([{ target: 'Missing', definition: undefined }]);
+    });
+  });
+});
@@ -0,0 +1,560 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { SymbolDocs } from './symbolDocs.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type DocFormat = 'markdown' | 'asciidoc';
+
+/** Documentation files by extension */
+export const DOC_EXTENSIONS: Record<string, DocFormat> = {
+  '.md': 'markdown',
+  '.markdown': 'markdown',
+  '.adoc': 'asciidoc',
+  '.asciidoc': 'asciidoc'
+};
+
+export interface DocCodeBlock {
+  /** Language of the fence or `[source,go]` attribute; absent when it names none */
+  language?: string;
+  /** 0-based lines of the opening and closing delimiter */
+  line: number;
+  endLine: number;
+}
+
+/** Inline code that names a symbol: `Person.Greet`, `user.New()`, `*Config` */
+export interface DocMention {
+  /** Dotted name it resolves by: `Person.Greet` for `Person::Greet()` */
+  target: string;
+  /** The code span as written */
+  text: string;
+  uri: string;
+  /** 0-based position of the span content */
+  line: number;
+  character: number;
+  /** Heading of the section it is in; '' before the first heading */
+  heading: string;
+  anchor?: string;
+}
+
+export interface DocSection {
+  uri: string;
+  /** Heading text without markup; '' for the text before the first heading */
+  heading: string;
+  /** Number of `#` or `=`; 0 for the text before the first heading */
+  level: number;
+  /** Fragment of the heading: GitHub's slug for Markdown, the section id for AsciiDoc */
+  anchor?: string;
+  /** Headings of the enclosing sections, outermost first */
+  parents: string[];
+  /** 0-based lines of the heading and of the last line before the next heading at its level or above */
+  line: number;
+  endLine: number;
+  codeBlocks: DocCodeBlock[];
+  mentions: DocMention[];
+}
+
+export interface DocFile {
+  uri: string;
+  format: DocFormat;
+  sections: DocSection[];
+}
+
+export interface DocsIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface DocSearchQuery {
+  /** Words that must all be in the heading or text of a section (case-insensitive) */
+  text?: string;
+  /** Only sections with a code block in this language */
+  language?: string;
+  /** Only documents in this file or under this folder */
+  path?: string;
+  /** Number of sections to return (default: 100) */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface DocSearchHit extends DocSection {
+  /** First line of the section containing the first word, trimmed */
+  excerpt?: string;
+}
+
+export interface DocSearchReport {
+  /** Matching sections, before the limit */
+  total: number;
+  /** Sections whose heading has every word first, then by file and line */
+  sections: DocSearchHit[];
+  truncated: boolean;
+}
+
+export interface DocMentionQuery {
+  /** The symbol: `Person`, `Person.Greet`, `user.Person` or `user.Person.Greet` */
+  symbol: string;
+  /** Only documents in this file or under this folder */
+  path?: string;
+  /** Number of mentions to return (default: 1000) */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export type DocDefinition = Pick<IndexedSymbol, 'name' | 'kind' | 'containerName' | 'location'>;
+
+export interface DocMentionReport {
+  symbol: string;
+  /** Definitions the symbol resolved to */
+  definitions: DocDefinition[];
+  /** Mentions resolving to one of them, before the limit */
+  total: number;
+  /** By file and position */
+  mentions: DocMention[];
+  truncated: boolean;
+}
+
+export interface DocLink extends DocMention {
+  /** The definition the mention resolves to; absent when none is indexed */
+  definition?: DocDefinition;
+}
+
+export interface DocLinkReport {
+  uri: string;
+  links: DocLink[];
+  /** Links with a definition */
+  resolved: number;
+}
+
+/** Doc search, mentions and links; how servers expose the index */
+export interface DocsSource {
+  search(query: DocSearchQuery): Promise<DocSearchReport>;
+  mentions(query: DocMentionQuery): Promise<DocMentionReport>;
+  links(uri: string): Promise<DocLinkReport>;
+}
+
+/** The part of the background index the docs index reads */
+export interface DocsSourceIndex extends Pick<ISymbolIndex, 'findDefinitions'> {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type DocReader = (filePath: string) => Promise<string>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_SEARCH_LIMIT = 100;
+const DEFAULT_MENTION_LIMIT = 1000;
+const EXCERPT_LENGTH = 160;
+
+/** `Name`, `pkg.Name`, `Type::method`, `Class#method`, with `*`/`&` and call parentheses */
+const MENTION = /^[*&]?([A-Za-z_$][\w$]*(?:(?:\.|::|#)[A-Za-z_$][\w$]*)*)(?:\([^()]*\))?$/;
+
+/**
+ * Sections of a Markdown or AsciiDoc document, with their code blocks and
+ * the inline code that names symbols.
+ *
+ * Markdown: ATX (`## Usage`) and setext headings, fenced code blocks
+ * (``` and ~~~) and YAML front matter, which is skipped. AsciiDoc: `==`
+ * headings with `[[id]]`/`[#id]` anchors, `----` listing and `....`
+ * literal blocks with their `[source,go]` language; comments and
+ * passthrough blocks are skipped.
+ */
+export function parseDocFile(uri: string, content: string, format: DocFormat = docFormatOf(uri) ?? 'markdown'): DocFile {
+  const lines = content.split(/\r?\n/);
+  const sections: DocSection[] = [];
+  const stack: DocSection[] = [];
+  const anchors = new Map<string, number>();
+  let current: DocSection | undefined;
+
+  const open = (heading: string, level: number, line: number, explicitAnchor?: string): void => {
+    while (stack.length > 0 && stack[stack.length - 1].level >= level) {
+      stack.pop();
+    }
+    current = {
+      uri,
+      heading,
+      level,
+      anchor: explicitAnchor ?? uniqueAnchor(anchors, heading, format),
+      parents: stack.map(s => s.heading),
+      line,
+      endLine: line,
+      codeBlocks: [],
+      mentions: []
+    };
+    stack.push(current);
+    sections.push(current);
+  };
+  const section = (line: number): DocSection => {
+    if (!current) {
+      current = { uri, heading: '', level: 0, parents: [], line, endLine: line, codeBlocks: [], mentions: [] };
+      sections.push(current);
+    }
+    return current;
+  };
+  const mentions = (line: number): void => {
+    const own = section(line);
+    for (const span of codeSpans(lines[line], format)) {
+      own.mentions.push({ ...span, uri, line, heading: own.heading, anchor: own.anchor });
+    }
+  };
+
+  let i = 0;
+  if (format === 'markdown' && /^---\s*$/.test(lines[0] ?? '')) {
+    const end = lines.findIndex((line, n) => n > 0 && /^(---|\.\.\.)\s*$/.test(line));
+    i = end === -1 ? 0 : end + 1;
+  }
+
+  let attributeLanguage: string | undefined;
+  let explicitAnchor: string | undefined;
+  for (; i < lines.length; i++) {
+    const line = lines[i];
+
+    // Code blocks
+    const fence = /^ {0,3}(`{3,}|~{3,})\s*([^\s`{,]*)/.exec(line);
+    const block = format === 'asciidoc' ? /^(-{4,}|\.{4,}|\+{4,}|\/{4,})\s*$/.exec(line) : null;
+    if (fence || block) {
+      const delimiter = fence ? fence[1] : block![1];
+      const closing = fence
+        ? new RegExp(`^ {0,3}${delimiter[0]}{${delimiter.length},}\\s*$`)
+        : new RegExp(`^${delimiter.replace(/[.+]/g, '\\$&')}\\s*$`);
+      let end = i + 1;
+      while (end < lines.length && !closing.test(lines[end])) {
+        end++;
+      }
+      const endLine = Math.min(end, lines.length - 1);
+      if (fence || delimiter[0] === '-' || delimiter[0] === '.') {
+        const language = fence ? fence[2] || undefined : attributeLanguage;
+        section(i).codeBlocks.push({ language: language?.toLowerCase(), line: i, endLine });
+      }
+      attributeLanguage = undefined;
+      explicitAnchor = undefined;
+      i = endLine;
+      continue;
+    }
+
+    if (format === 'asciidoc') {
+      const attribute = /^\[(.*)\]\s*$/.exec(line);
+      if (attribute) {
+        const id = /^(?:\[([^\],]+)(?:,[^\]]*)?\]|#([\w:-]+).*)$/.exec(attribute[1]);
+        if (id) {
+          explicitAnchor = id[1] ?? id[2];
+        } else {
+          const source = /^(?:source)?,\s*([\w+#.-]+)/.exec(attribute[1]);
+          attributeLanguage = source ? source[1] : undefined;
+        }
+        continue;
+      }
+      // Comments and block titles (`.Example`) keep the attributes for the next block
+      if (/^\/\/|^\.[^.\s]/.test(line)) {
+        continue;
+      }
+      const title = /^(={1,6})\s+(.+?)(?:\s+=+)?\s*$/.exec(line);
+      if (title) {
+        open(plainText(title[2]), title[1].length, i, explicitAnchor);
+        mentions(i);
+        explicitAnchor = undefined;
+        attributeLanguage = undefined;
+        continue;
+      }
+    }
+
+    // Headings
+    const atx = /^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$/.exec(line);
+    if (atx && atx[2] !== undefined) {
+      open(plainText(atx[2]), atx[1].length, i);
+      mentions(i);
+      continue;
+    }
+    const underline = format === 'markdown' && line.trim() !== '' && i + 1 < lines.length
+      ? /^ {0,3}(=+|-+)[ \t]*$/.exec(lines[i + 1])
+      : null;
+    if (underline && (i === 0 || lines[i - 1].trim() === '') && !/^ {0,3}([-*+]|\d+[.)])\s|^ {4}|^\s*\|/.test(line)) {
+      open(plainText(line.trim()), underline[1][0] === '=' ? 1 : 2, i);
+      mentions(i);
+      i++;
+      continue;
+    }
+
+    if (line.trim() !== '') {
+      mentions(i);
+      attributeLanguage = undefined;
+    }
+  }
+
+  // A section runs until the next heading at its level or above
+  const lastLine = Math.max(0, lines[lines.length - 1] === '' ? lines.length - 2 : lines.length - 1);
+  for (let s = 0; s < sections.length; s++) {
+    const own = sections[s];
+    let next = s + 1;
+    while (next < sections.length && own.level > 0 && sections[next].level > own.level) {
+      next++;
+    }
+    own.endLine = next < sections.length ? sections[next].line - 1 : lastLine;
+  }
+  return { uri, format, sections };
+}
+
+/** Format of a documentation file by its extension; undefined for other files */
+export function docFormatOf(uri: string): DocFormat | undefined {
+  return DOC_EXTENSIONS[path.extname(uri).toLowerCase()];
+}
+
+/**
+ * Docs Index - the headings, code blocks and symbol mentions of the
+ * Markdown and AsciiDoc files in the workspace, for doc search and "docs
+ * mentioning this symbol".
+ *
+ * Built from the files of the background index like the configuration
+ * key index (features/configKeys.ts): only files whose hash changed are
+ * rescanned. Mentions are inline code such as `Person.Greet`; they are
+ * resolved like doc comment links (features/symbolDocs.ts), by their last
+ * name part and qualifier, preferring definitions next to the document.
+ */
+export class DocsIndex {
+  private files: Map<string, { hash: string; doc: DocFile; text: string[] }> = new Map();
+  private symbolDocs: SymbolDocs;
+
+  constructor(
+    private index: DocsSourceIndex,
+    private readFile: DocReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {
+    this.symbolDocs = new SymbolDocs(index);
+  }
+
+  /**
+   * Bring the index in line with the background index.
+   */
+  async build(options: DocsIndexOptions = {}): Promise<{ files: number; sections: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles()).filter(uri => docFormatOf(uri) !== undefined).sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Reading docs (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        const content = await this.readFile(uri);
+        const doc = parseDocFile(uri, content);
+        this.files.set(uri, { hash, doc, text: sectionTexts(doc, content) });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'Docs complete');
+    let sections = 0;
+    for (const file of this.files.values()) {
+      sections += file.doc.sections.length;
+    }
+    return { files: this.files.size, sections, updated };
+  }
+
+  /**
+   * Sections containing every word of the query, optionally with a code
+   * block in a language.
+   */
+  search(query: DocSearchQuery = {}): DocSearchReport {
+    const words = (query.text ?? '').toLowerCase().split(/\s+/).filter(Boolean);
+    const language = query.language?.toLowerCase();
+    const limit = query.limit ?? DEFAULT_SEARCH_LIMIT;
+
+    const hits: Array<{ hit: DocSearchHit; inHeading: boolean }> = [];
+    for (const [uri, file] of this.files) {
+      if (!inScope(uri, query.path)) {
+        continue;
+      }
+      file.doc.sections.forEach((section, s) => {
+        if (language && !section.codeBlocks.some(block => block.language === language)) {
+          return;
+        }
+        const text = file.text[s];
+        const lower = text.toLowerCase();
+        if (!words.every(word => lower.includes(word))) {
+          return;
+        }
+        const heading = section.heading.toLowerCase();
+        hits.push({
+          hit: { ...section, excerpt: words.length > 0 ? excerpt(text, words[0]) : undefined },
+          inHeading: words.length > 0 && words.every(word => heading.includes(word))
+        });
+      });
+    }
+
+    hits.sort((a, b) =>
+      Number(b.inHeading) - Number(a.inHeading) || a.hit.uri.localeCompare(b.hit.uri) || a.hit.line - b.hit.line
+    );
+    return {
+      total: hits.length,
+      sections: hits.slice(0, limit).map(({ hit }) => hit),
+      truncated: hits.length > limit
+    };
+  }
+
+  /**
+   * Mentions in the docs that resolve to a definition of the symbol.
+   */
+  async mentions(query: DocMentionQuery): Promise<DocMentionReport> {
+    const definitions = await this.symbolDocs.definitionsOf(query.symbol);
+    const ids = new Set(definitions.map(s => s.id));
+    const names = new Set(definitions.map(s => s.name));
+    const limit = query.limit ?? DEFAULT_MENTION_LIMIT;
+
+    const found: DocMention[] = [];
+    const resolved = new Map<string, string | undefined>();
+    for (const [uri, file] of this.files) {
+      if (!inScope(uri, query.path)) {
+        continue;
+      }
+      for (const section of file.doc.sections) {
+        for (const mention of section.mentions) {
+          if (!names.has(lastName(mention.target))) {
+            continue;
+          }
+          throwIfCancelled(query.cancellationToken);
+          // Each name is resolved once per document
+          const key = `${uri}\0${mention.target}`;
+          if (!resolved.has(key)) {
+            resolved.set(key, (await this.symbolDocs.resolveLinkFrom(mention.target, uri))?.id);
+          }
+          if (ids.has(resolved.get(key)!)) {
+            found.push(mention);
+          }
+        }
+      }
+    }
+
+    found.sort((a, b) => a.uri.localeCompare(b.uri) || a.line - b.line || a.character - b.character);
+    return {
+      symbol: query.symbol,
+      definitions: definitions.map(toDefinition),
+      total: found.length,
+      mentions: found.slice(0, limit),
+      truncated: found.length > limit
+    };
+  }
+
+  /**
+   * The mentions of one document with the definitions they resolve to.
+   */
+  async links(uri: string): Promise<DocLinkReport> {
+    const file = this.files.get(path.resolve(uri)) ?? this.files.get(uri);
+    const links: DocLink[] = [];
+    for (const section of file?.doc.sections ?? []) {
+      for (const mention of section.mentions) {
+        const definition = await this.symbolDocs.resolveLinkFrom(mention.target, mention.uri);
+        links.push(definition ? { ...mention, definition: toDefinition(definition) } : mention);
+      }
+    }
+    return { uri, links, resolved: links.filter(link => link.definition).length };
+  }
+}
+
+/** Inline code spans of a line that look like symbol names */
+function codeSpans(line: string, format: DocFormat): Array<{ target: string; text: string; character: number }> {
+  const spans: Array<{ target: string; text: string; character: number }> = [];
+  const pattern = /(`+)(?!`)([\s\S]*?[^`])\1(?!`)/g;
+  let match: RegExpExecArray | null;
+  while ((match = pattern.exec(line)) !== null) {
+    let text = match[2];
+    let character = match.index + match[1].length;
+    // `+Name+` is literal monospace in AsciiDoc
+    if (format === 'asciidoc' && /^\+.*\+$/.test(text)) {
+      text = text.slice(1, -1);
+      character++;
+    }
+    const leading = text.length - text.trimStart().length;
+    text = text.trim();
+    const name = MENTION.exec(text);
+    if (name) {
+      spans.push({ target: name[1].replace(/::|#/g, '.'), text, character: character + leading });
+    }
+  }
+  return spans;
+}
+
+/** Heading text without links, emphasis and code markers */
+function plainText(text: string): string {
+  return text
+    .replace(/!?\[([^\]]*)\]\([^)]*\)/g, '$1')
+    .replace(/<<[^,>]*,\s*([^>]*)>>/g, '$1')
+    .replace(/`+/g, '')
+    .replace(/(\*\*|__|\*)(.+?)\1/g, '$2')
+    .trim();
+}
+
+/**
+ * GitHub's heading slug for Markdown (`Getting Started` → `getting-started`),
+ * Asciidoctor's section id for AsciiDoc (`_getting_started`); repeated
+ * headings get a counter.
+ */
+function uniqueAnchor(seen: Map<string, number>, heading: string, format: DocFormat): string {
+  const base = format === 'markdown'
+    ? heading.toLowerCase().replace(/[^\p{L}\p{N}\s_-]/gu, '').replace(/\s/g, '-')
+    : '_' + heading.toLowerCase().replace(/[^\p{L}\p{N}\s_.-]/gu, '').trim().replace(/[\s.-]+/g, '_');
+  const count = seen.get(base) ?? 0;
+  seen.set(base, count + 1);
+  if (count === 0) {
+    return base;
+  }
+  return format === 'markdown' ? `${base}-${count}` : `${base}_${count + 1}`;
+}
+
+/** Heading, prose and code of each section; the text doc search matches */
+function sectionTexts(doc: DocFile, content: string): string[] {
+  const lines = content.split(/\r?\n/);
+  return doc.sections.map((section, s) => {
+    const next = doc.sections[s + 1];
+    const end = next ? next.line : lines.length;
+    return [section.heading, ...lines.slice(section.level > 0 ? section.line + 1 : section.line, end)].join('\n');
+  });
+}
+
+function excerpt(text: string, word: string): string | undefined {
+  const line = text.split('\n').find(l => l.toLowerCase().includes(word))?.trim();
+  return line && line.length > EXCERPT_LENGTH ? line.slice(0, EXCERPT_LENGTH - 1) + '…' : line;
+}
+
+function inScope(uri: string, scope: string | undefined): boolean {
+  if (!scope) {
+    return true;
+  }
+  const root = path.resolve(scope);
+  return uri === root || uri.startsWith(root + path.sep);
+}
+
+function lastName(target: string): string {
+  return target.slice(target.lastIndexOf('.') + 1);
+}
+
+function toDefinition(symbol: IndexedSymbol): DocDefinition {
+  return { name: symbol.name, kind: symbol.kind, containerName: symbol.containerName, location: symbol.location };
+}
@@ -0,0 +1,197 @@
+/**
+ * EmbeddingIndex Tests
+ *
+ * Uses a bag-of-words provider so similarity is predictable, and checks
+ * chunking, ranking, incremental updates and the on-disk store.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { EmbeddingIndex } from './embeddingIndex.js';
+import { EmbeddingProvider, FetchFn, OpenAIEmbeddingProvider } from './embeddingProvider.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const personGo = `package people
+
+// Person is a human with a name.
+type Person struct {
+	Name string
+}
+
+// NewPerson constructs a person from a name.
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+`;
+
+const storeGo = `package people
+
+// Save writes records to the database.
+func Save(db *DB, records []string) error {
+	return db.Write(records)
+}
+`;
+
+const DIMENSIONS = 64;
+
+/** Hashes lowercased words (camelCase split) into a fixed number of buckets */
+class BagOfWordsProvider implements EmbeddingProvider {
+  texts: string[] = [];
+
+  constructor(readonly model: string = 'bag-of-words') {}
+
+  async embed(texts: string[]): Promise<number[][]> {
+    this.texts.push(...texts);
+    return texts.map(text => {
+      const vector = new Array(DIMENSIONS).fill(0);
+      const words = text.replace(/([a-z])([A-Z])/g, '$1 $2').toLowerCase().match(/[a-z]+/g) ?? [];
+      for (const word of words) {
+        let h = 0;
+        for (const c of word) {
+          h = (h * 31 + c.charCodeAt(0)) % DIMENSIONS;
+        }
+        vector[h] += 1;
+      }
+      return vector;
+    });
+  }
+}
+
+describe('EmbeddingIndex', () => {
+  let root: string;
+  let cacheDir: string;
+  let background: MockBackgroundIndex;
+  let provider: BagOfWordsProvider;
+  const goIndexer = new GoIndexer();
+
+  function writeFile(file: string, content: string): void {
+    const uri = path.join(root, file);
+    fs.writeFileSync(uri, content);
+    const result = goIndexer.indexFile(uri, content);
+    background.addFile(uri, result.symbols, result.references, { hash: `${content.length}:${content}` });
+  }
+
+  function createIndex(): EmbeddingIndex {
+    return new EmbeddingIndex(background.asBackgroundIndex(), provider, root, cacheDir, { batchSize: 2 });
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'embedding-index-'));
+    cacheDir = path.join(root, '.smart-index');
+    background = new MockBackgroundIndex();
+    provider = new BagOfWordsProvider();
+    writeFile('person.go', personGo);
+    writeFile('store.go', storeGo);
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should chunk functions and types and rank them by similarity', async () => {
+    const index = createIndex();
+    const stats = await index.update();
+
+    expect(stats).toMatchObject({ files: 2, chunks: 3, dimensions: DIMENSIONS, embedded: 3 });
+    expect(provider.texts[0]).toBe([
+      'struct Person',
+      'file: person.go',
+      '// Person is a human with a name.',
+      'type Person struct {',
+      '\tName string',
+      '}'
+    ].join('\n'));
+
+    const results = await index.search('function that constructs a person');
+    expect(results.map(r => r.chunk.name)).toEqual(['NewPerson', 'Person', 'Save']);
+    expect(results[0].chunk).toMatchObject({ kind: 'function', line: 8, endLine: 10 });
+    expect(results[0].score).toBeGreaterThan(results[2].score);
+
+    expect((await index.search('write records to the database', 1)).map(r => r.chunk.name)).toEqual(['Save']);
+  });
+
+  it('should only embed chunks whose text changed', async () => {
+    const index = createIndex();
+    await index.update();
+    provider.texts = [];
+
+    expect((await index.update()).embedded).toBe(0);
+
+    writeFile('person.go', personGo.replace('{Name: name}', '{Name: strings.TrimSpace(name)}'));
+    const stats = await index.update();
+    expect(stats.embedded).toBe(1);
+    expect(provider.texts[0]).toContain('function NewPerson');
+
+    background.removeFile(path.join(root, 'store.go'));
+    expect(await index.update()).toMatchObject({ files: 1, chunks: 2, embedded: 0 });
+    expect((await index.search('database records')).map(r => r.chunk.name)).not.toContain('Save');
+  });
+
+  it('should reload vectors from disk and discard them when the model changes', async () => {
+    await createIndex().update();
+    expect(fs.readdirSync(path.join(cacheDir, 'embeddings')).sort()).toEqual(['index.json', 'vectors.bin']);
+
+    provider = new BagOfWordsProvider();
+    const reloaded = createIndex();
+    expect((await reloaded.update()).embedded).toBe(0);
+    expect((await reloaded.search('function that constructs a person', 1))[0].chunk.name).toBe('NewPerson');
+
+    provider = new BagOfWordsProvider('other-model');
+    expect((await createIndex().update()).embedded).toBe(3);
+  });
+
+  it('should keep an HNSW graph in step with the vectors', async () => {
+    const createAnnIndex = () => new EmbeddingIndex(background.asBackgroundIndex(), provider, root, cacheDir, {
+      hnsw: { m: 4, efConstruction: 16 }
+    });
+    const index = createAnnIndex();
+    await index.update();
+    expect(fs.existsSync(path.join(cacheDir, 'embeddings', 'hnsw.bin'))).toBe(true);
+    expect((await index.search('function that constructs a person')).map(r => r.chunk.name))
+      .toEqual(['NewPerson', 'Person', 'Save']);
+
+    background.removeFile(path.join(root, 'store.go'));
+    await index.update();
+    expect((await index.search('write records to the database')).map(r => r.chunk.name)).toEqual(['Person', 'NewPerson']);
+
+    // Reloaded graph answers without re-embedding
+    provider.texts = [];
+    const reloaded = createAnnIndex();
+    expect((await reloaded.update()).embedded).toBe(0);
+    expect((await reloaded.search('constructs a person', 1)).length).toBe(1);
+    expect(provider.texts).toEqual(['constructs a person']);
+  });
+});
+
+describe('OpenAIEmbeddingProvider', () => {
+  it('should post the batch and return vectors in input order', async () => {
+    const requests: Array<{ url: string; headers: Record<string, string>; body: string }> = [];
+    const fetchFn: FetchFn = async (url, init) => {
+      requests.push({ url, headers: init.headers, body: init.body });
+      return {
+        ok: true,
+        status: 200,
+        statusText: 'OK',
+        json: async () => ({ data: [{ index: 1, embedding: [0, 1] }, { index: 0, embedding: [1, 0] }] }),
+        text: async () => ''
+      };
+    };
+
+    const provider = new OpenAIEmbeddingProvider('http://localhost:11434/v1/embeddings', 'nomic', 'secret', fetchFn);
+    expect(await provider.embed(['a', 'b'])).toEqual([[1, 0], [0, 1]]);
+    expect(requests[0].headers.Authorization).toBe('Bearer secret');
+    expect(JSON.parse(requests[0].body)).toEqual({ model: 'nomic', input: ['a', 'b'] });
+
+    const failing = new OpenAIEmbeddingProvider('http://x', 'm', undefined, async () => ({
+      ok: false,
+      status: 401,
+      statusText: 'Unauthorized',
+      json: async () => ({}),
+      text: async () => 'invalid api key'
+    }));
+    await expect(failing.embed(['a'])).rejects.toThrow('Embedding request failed: 401 Unauthorized - invalid api key');
+  });
+});
@@ -0,0 +1,452 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { EmbeddingProvider } from './embeddingProvider.js';
+import { HnswIndex, HnswOptions } from './hnswIndex.js';
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface EmbeddingIndexOptions {
+  /** Texts sent per provider request (default 64) */
+  batchSize?: number;
+  /** Chunks longer than this are truncated (default 2000) */
+  maxChunkChars?: number;
+  /** Approximate search through an HNSW graph; exact (brute-force) search when omitted */
+  hnsw?: HnswOptions;
+}
+
+export interface EmbeddingUpdateOptions {
+  /** Cancellation token for aborting the update */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting update progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface EmbeddingChunk {
+  /** Symbol id the chunk was cut from */
+  id: string;
+  uri: string;
+  name: string;
+  kind: string;
+  containerName?: string;
+  line: number;
+  endLine: number;
+  /** Hash of the embedded text; identical chunks share one vector */
+  hash: string;
+}
+
+export interface EmbeddingSearchResult {
+  chunk: EmbeddingChunk;
+  /** Cosine similarity to the query, higher is closer */
+  score: number;
+}
+
+export interface EmbeddingIndexStats {
+  files: number;
+  chunks: number;
+  dimensions: number;
+  /** Chunks sent to the provider by the last update */
+  embedded: number;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type EmbeddingReader = (filePath: string) => Promise<string>;
+
+interface StoredFile {
+  hash: string;
+  chunks: EmbeddingChunk[];
+}
+
+type TextChunk = EmbeddingChunk & { text: string };
+
+interface StoredIndex {
+  version: number;
+  model: string;
+  dimensions: number;
+  files: Record<string, StoredFile>;
+  /** Chunk hashes, in the order of their vectors in vectors.bin */
+  vectors: string[];
+}
+
+const STORE_VERSION = 1;
+const YIELD_INTERVAL = 50;
+const DEFAULT_BATCH_SIZE = 64;
+const DEFAULT_MAX_CHUNK_CHARS = 2000;
+
+/** Symbols worth a chunk of their own; fields, variables and imports are too small to describe */
+const CHUNK_KINDS = new Set(['function', 'method', 'class', 'interface', 'struct', 'type', 'enum']);
+
+/** Comment-ish lines directly above a declaration, plus decorators/attributes */
+const LEADING_LINE_RE = /^\s*(\/\/|\/\*|\*|#|@)/;
+
+/**
+ * Embedding Index - vectors for functions and types, for natural-language search.
+ *
+ * Every function, method, class, interface, struct, type and enum becomes
+ * one chunk: its kind, qualified name, file, leading comment and source,
+ * truncated to `maxChunkChars`. Chunks are embedded by the configured
+ * provider, normalized, and searched by cosine similarity.
+ *
+ * Updates are incremental: a file is re-chunked only when its hash in the
+ * background index changed, and a chunk is re-embedded only when its text
+ * changed. Vectors live in `<cacheDir>/embeddings/` (index.json + raw
+ * Float32 vectors.bin) and are discarded when the model changes.
+ *
+ * With `hnsw` options, vectors are also kept in an HNSW graph (hnsw.bin,
+ * see hnswIndex.ts) that is updated in place as chunks come and go, so a
+ * query visits a few thousand vectors instead of all of them.
+ */
+export class EmbeddingIndex {
+  private files: Map<string, StoredFile> = new Map();
+  private vectors: Map<string, Float32Array> = new Map();
+  private dimensions = 0;
+  private loaded = false;
+  private ann: HnswIndex | null = null;
+  /** Chunks per vector hash for mapping graph results back; rebuilt after updates */
+  private chunksByHash: Map<string, EmbeddingChunk[]> | null = null;
+
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private provider: EmbeddingProvider,
+    private workspaceRoot: string,
+    private cacheDir: string | undefined = undefined,
+    private options: EmbeddingIndexOptions = {},
+    private readFile: EmbeddingReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the vectors in line with the background index.
+   */
+  async update(options: EmbeddingUpdateOptions = {}): Promise<EmbeddingIndexStats> {
+    const { cancellationToken, onProgress } = options;
+    this.load();
+
+    const uris = this.backgroundIndex.getAllFileUris().sort();
+    const current = new Set(uris);
+    let changed = [...this.files.keys()].some(uri => !current.has(uri)) || this.ensureAnn();
+
+    // Chunk changed files first; nothing is replaced until every vector is in
+    const rechunked: Map<string, { hash: string; chunks: TextChunk[] }> = new Map();
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Chunking symbols (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.backgroundIndex.getFileInfo(uri)?.hash ?? '';
+      if (hash !== '' && this.files.get(uri)?.hash === hash) {
+        continue;
+      }
+      const symbols = (await this.backgroundIndex.getFileSymbols(uri)).filter(isChunkable);
+      let content = '';
+      if (symbols.length > 0) {
+        try {
+          content = await this.readFile(uri);
+        } catch {
+          // Deleted since indexing - dropped below
+        }
+      }
+      rechunked.set(uri, { hash, chunks: content ? this.chunkFile(uri, content, symbols) : [] });
+    }
+
+    const missing = new Map<string, string>();
+    for (const file of rechunked.values()) {
+      for (const chunk of file.chunks) {
+        if (!this.vectors.has(chunk.hash)) {
+          missing.set(chunk.hash, chunk.text);
+        }
+      }
+    }
+
+    const batchSize = Math.max(1, this.options.batchSize ?? DEFAULT_BATCH_SIZE);
+    const pending = [...missing.entries()];
+    for (let start = 0; start < pending.length; start += batchSize) {
+      throwIfCancelled(cancellationToken);
+      onProgress?.(start, pending.length, `Embedding chunks (${start}/${pending.length})`);
+      const batch = pending.slice(start, start + batchSize);
+      const embeddings = await this.provider.embed(batch.map(([, text]) => text));
+      // Kept across failed updates so a retry does not pay for them again
+      batch.forEach(([hash], k) => {
+        const vector = this.toVector(embeddings[k]);
+        this.vectors.set(hash, vector);
+        this.ann?.insert(hash, vector);
+      });
+    }
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+    for (const [uri, file] of rechunked) {
+      this.files.set(uri, { hash: file.hash, chunks: file.chunks.map(({ text: _text, ...chunk }) => chunk) });
+      changed = true;
+    }
+
+    if (changed) {
+      this.chunksByHash = null;
+      this.pruneVectors();
+      this.save();
+    }
+
+    onProgress?.(uris.length, uris.length, 'Embedding index complete');
+    return { ...this.getStats(), embedded: pending.length };
+  }
+
+  /**
+   * Chunks closest in meaning to a natural-language query.
+   */
+  async search(query: string, limit: number = 10): Promise<EmbeddingSearchResult[]> {
+    this.load();
+    if (this.vectors.size === 0 || !query.trim()) {
+      return [];
+    }
+    const [embedding] = await this.provider.embed([query]);
+    const queryVector = this.toVector(embedding);
+
+    if (this.ann) {
+      return this.searchAnn(queryVector, limit);
+    }
+
+    const results: EmbeddingSearchResult[] = [];
+    for (const file of this.files.values()) {
+      for (const chunk of file.chunks) {
+        const vector = this.vectors.get(chunk.hash);
+        if (vector) {
+          results.push({ chunk, score: dot(queryVector, vector) });
+        }
+      }
+    }
+    return results
+      .sort((a, b) => b.score - a.score || a.chunk.uri.localeCompare(b.chunk.uri) || a.chunk.line - b.chunk.line)
+      .slice(0, limit);
+  }
+
+  /**
+   * Graph search; `limit` vectors cover at least `limit` chunks since
+   * identical chunks share a vector.
+   */
+  private searchAnn(queryVector: Float32Array, limit: number): EmbeddingSearchResult[] {
+    if (!this.chunksByHash) {
+      this.chunksByHash = new Map();
+      for (const file of this.files.values()) {
+        for (const chunk of file.chunks) {
+          const chunks = this.chunksByHash.get(chunk.hash);
+          if (chunks) {
+            chunks.push(chunk);
+          } else {
+            this.chunksByHash.set(chunk.hash, [chunk]);
+          }
+        }
+      }
+    }
+
+    const results: EmbeddingSearchResult[] = [];
+    for (const { key, score } of this.ann!.search(queryVector, limit)) {
+      for (const chunk of this.chunksByHash.get(key) ?? []) {
+        results.push({ chunk, score });
+      }
+    }
+    return results.slice(0, limit);
+  }
+
+  getStats(): Omit<EmbeddingIndexStats, 'embedded'> {
+    let chunks = 0;
+    for (const file of this.files.values()) {
+      chunks += file.chunks.length;
+    }
+    return { files: this.files.size, chunks, dimensions: this.dimensions };
+  }
+
+  /**
+   * Text embedded for a symbol: a header the query can match on, the
+   * leading comment and the source of the declaration.
+   */
+  private chunkFile(uri: string, content: string, symbols: IndexedSymbol[]): TextChunk[] {
+    const lines = content.split('\n');
+    const relativePath = path.relative(this.workspaceRoot, uri).split(path.sep).join('/');
+    const maxChars = this.options.maxChunkChars ?? DEFAULT_MAX_CHUNK_CHARS;
+
+    return symbols.map(symbol => {
+      const { startLine, endLine } = symbol.range;
+      let first = startLine;
+      while (first > 0 && LEADING_LINE_RE.test(lines[first - 1])) {
+        first--;
+      }
+      const qualifiedName = symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+      const text = [
+        `${symbol.kind} ${qualifiedName}`,
+        `file: ${relativePath}`,
+        ...lines.slice(first, endLine + 1)
+      ].join('\n').slice(0, maxChars);
+
+      return {
+        id: symbol.id,
+        uri,
+        name: symbol.name,
+        kind: symbol.kind,
+        ...(symbol.containerName && { containerName: symbol.containerName }),
+        line: startLine,
+        endLine,
+        hash: crypto.createHash('sha256').update(text).digest('hex').substring(0, 16),
+        text
+      };
+    });
+  }
+
+  private toVector(embedding: number[] | undefined): Float32Array {
+    if (!Array.isArray(embedding) || embedding.length === 0) {
+      throw new Error(`Embedding provider '${this.provider.model}' returned an empty vector`);
+    }
+    if (this.dimensions === 0) {
+      this.dimensions = embedding.length;
+    } else if (embedding.length !== this.dimensions) {
+      throw new Error(`Embedding provider '${this.provider.model}' returned ${embedding.length} dimensions, expected ${this.dimensions}`);
+    }
+    return normalize(embedding);
+  }
+
+  private pruneVectors(): void {
+    const used = new Set<string>();
+    for (const file of this.files.values()) {
+      for (const chunk of file.chunks) {
+        used.add(chunk.hash);
+      }
+    }
+    for (const hash of [...this.vectors.keys()]) {
+      if (!used.has(hash)) {
+        this.vectors.delete(hash);
+        this.ann?.delete(hash);
+      }
+    }
+    if (this.ann?.needsCompaction()) {
+      this.ann.compact();
+    }
+  }
+
+  /**
+   * Build the graph from the loaded vectors when approximate search is on
+   * and no usable hnsw.bin was found. Returns true if it had to be built.
+   */
+  private ensureAnn(): boolean {
+    if (!this.options.hnsw || this.ann) {
+      return false;
+    }
+    this.ann = new HnswIndex(this.options.hnsw);
+    for (const [hash, vector] of this.vectors) {
+      this.ann.insert(hash, vector);
+    }
+    return this.vectors.size > 0;
+  }
+
+  private storeDir(): string | undefined {
+    return this.cacheDir ? path.join(this.cacheDir, 'embeddings') : undefined;
+  }
+
+  private load(): void {
+    if (this.loaded) {
+      return;
+    }
+    this.loaded = true;
+    const dir = this.storeDir();
+    if (!dir) {
+      return;
+    }
+
+    try {
+      const stored = JSON.parse(fs.readFileSync(path.join(dir, 'index.json'), 'utf-8')) as StoredIndex;
+      if (stored.version !== STORE_VERSION || stored.model !== this.provider.model) {
+        return; // Different model: vectors are not comparable, start over
+      }
+      const buffer = fs.readFileSync(path.join(dir, 'vectors.bin'));
+      const all = new Float32Array(buffer.buffer, buffer.byteOffset, buffer.byteLength / 4);
+      if (all.length !== stored.vectors.length * stored.dimensions) {
+        return;
+      }
+      stored.vectors.forEach((hash, i) => {
+        this.vectors.set(hash, all.slice(i * stored.dimensions, (i + 1) * stored.dimensions));
+      });
+      this.files = new Map(Object.entries(stored.files));
+      this.dimensions = stored.dimensions;
+    } catch {
+      // Missing or corrupt store - rebuilt on update
+      return;
+    }
+
+    if (this.options.hnsw) {
+      try {
+        const graph = HnswIndex.deserialize(
+          fs.readFileSync(path.join(dir, 'hnsw.bin')),
+          hash => this.vectors.get(hash),
+          this.options.hnsw
+        );
+        // A graph missing vectors (or built with other parameters) is rebuilt by ensureAnn
+        if (graph && graph.size === this.vectors.size) {
+          this.ann = graph;
+        }
+      } catch {
+        // No graph yet
+      }
+    }
+  }
+
+  private save(): void {
+    const dir = this.storeDir();
+    if (!dir) {
+      return;
+    }
+
+    const hashes = [...this.vectors.keys()];
+    const all = new Float32Array(hashes.length * this.dimensions);
+    hashes.forEach((hash, i) => all.set(this.vectors.get(hash)!, i * this.dimensions));
+    const stored: StoredIndex = {
+      version: STORE_VERSION,
+      model: this.provider.model,
+      dimensions: this.dimensions,
+      files: Object.fromEntries(this.files),
+      vectors: hashes
+    };
+
+    fs.mkdirSync(dir, { recursive: true });
+    fs.writeFileSync(path.join(dir, 'vectors.bin'), Buffer.from(all.buffer, all.byteOffset, all.byteLength));
+    if (this.ann) {
+      fs.writeFileSync(path.join(dir, 'hnsw.bin'), this.ann.serialize());
+    }
+    fs.writeFileSync(path.join(dir, 'index.json'), JSON.stringify(stored));
+  }
+}
+
+function isChunkable(symbol: IndexedSymbol): boolean {
+  return CHUNK_KINDS.has(symbol.kind) && symbol.isDefinition !== false;
+}
+
+function normalize(embedding: number[]): Float32Array {
+  const vector = Float32Array.from(embedding);
+  let norm = 0;
+  for (let i = 0; i < vector.length; i++) {
+    norm += vector[i] * vector[i];
+  }
+  norm = Math.sqrt(norm);
+  if (norm > 0) {
+    for (let i = 0; i < vector.length; i++) {
+      vector[i] /= norm;
+    }
+  }
+  return vector;
+}
+
+function dot(a: Float32Array, b: Float32Array): number {
+  let sum = 0;
+  for (let i = 0; i < a.length; i++) {
+    sum += a[i] * b[i];
+  }
+  return sum;
+}
@@ -0,0 +1,109 @@
+import { EmbeddingsConfig } from '../config/configurationManager.js';
+import { JsonLinesProcess } from '../utils/jsonLinesProcess.js';
+import { ILogger } from '../utils/Logger.js';
+
+/**
+ * Turns texts into vectors. Implementations must return one vector per
+ * input text, in input order, all of the same dimension.
+ */
+export interface EmbeddingProvider {
+  /** Model identifier; vectors from different models are never mixed */
+  readonly model: string;
+  embed(texts: string[]): Promise<number[][]>;
+  dispose?(): void;
+}
+
+/** HTTP transport; injectable for tests */
+export type FetchFn = (url: string, init: { method: string; headers: Record<string, string>; body: string }) => Promise<{
+  ok: boolean;
+  status: number;
+  statusText: string;
+  json(): Promise<unknown>;
+  text(): Promise<string>;
+}>;
+
+interface OpenAIEmbeddingResponse {
+  data: Array<{ index: number; embedding: number[] }>;
+}
+
+/**
+ * Any endpoint implementing the OpenAI `/v1/embeddings` API
+ * (OpenAI, Azure OpenAI, Ollama, LM Studio, vLLM, ...).
+ */
+export class OpenAIEmbeddingProvider implements EmbeddingProvider {
+  constructor(
+    private readonly endpoint: string,
+    readonly model: string,
+    private readonly apiKey: string | undefined,
+    private readonly fetchFn: FetchFn = fetch as unknown as FetchFn
+  ) {}
+
+  async embed(texts: string[]): Promise<number[][]> {
+    const headers: Record<string, string> = { 'Content-Type': 'application/json' };
+    if (this.apiKey) {
+      headers.Authorization = `Bearer ${this.apiKey}`;
+    }
+
+    const response = await this.fetchFn(this.endpoint, {
+      method: 'POST',
+      headers,
+      body: JSON.stringify({ model: this.model, input: texts })
+    });
+    if (!response.ok) {
+      const detail = (await response.text().catch(() => '')).slice(0, 500);
+      throw new Error(`Embedding request failed: ${response.status} ${response.statusText}${detail ? ` - ${detail}` : ''}`);
+    }
+
+    const body = await response.json() as OpenAIEmbeddingResponse;
+    if (!Array.isArray(body?.data) || body.data.length !== texts.length) {
+      throw new Error(`Embedding response has ${body?.data?.length ?? 0} vectors for ${texts.length} inputs`);
+    }
+    return [...body.data].sort((a, b) => a.index - b.index).map(item => item.embedding);
+  }
+}
+
+/**
+ * A local model behind a JSON-lines process (e.g. an ONNX runtime script):
+ *
+ *   -> {"id":1,"method":"embed","params":{"texts":["..."]}}
+ *   <- {"id":1,"result":{"embeddings":[[0.1, ...]]}}
+ */
+export class CommandEmbeddingProvider implements EmbeddingProvider {
+  private readonly process: JsonLinesProcess;
+
+  constructor(
+    readonly model: string,
+    command: string,
+    args: string[],
+    logger: ILogger,
+    cwd?: string
+  ) {
+    // Local models on CPU are slow on big batches
+    this.process = new JsonLinesProcess({ name: model, command, args, timeoutMs: 120000 }, 'Embeddings', logger, cwd);
+  }
+
+  async embed(texts: string[]): Promise<number[][]> {
+    const result = await this.process.request<{ embeddings?: number[][] }>('embed', { texts });
+    if (!Array.isArray(result?.embeddings) || result.embeddings.length !== texts.length) {
+      throw new Error(`Embedding process '${this.model}' returned ${result?.embeddings?.length ?? 0} vectors for ${texts.length} inputs`);
+    }
+    return result.embeddings;
+  }
+
+  dispose(): void {
+    this.process.dispose();
+  }
+}
+
+/**
+ * Create the provider described by the configuration.
+ */
+export function createEmbeddingProvider(config: EmbeddingsConfig, logger: ILogger, cwd?: string): EmbeddingProvider {
+  if (config.provider === 'command') {
+    if (!config.command) {
+      throw new Error('smartIndexer.embeddings.command is required for the command provider');
+    }
+    return new CommandEmbeddingProvider(config.model, config.command, config.args ?? [], logger, cwd);
+  }
+  return new OpenAIEmbeddingProvider(config.endpoint, config.model, process.env[config.apiKeyEnv]);
+}
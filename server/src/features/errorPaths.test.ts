@@ -0,0 +1,180 @@
+/**
+ * Error Path Tests
+ *
+ * Verifies panic, recover, propagation and discard extraction and the
+ * error path index: panics followed through calls, recovers stopping
+ * them, exported, kind and package filters, rescans.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ErrorPathIndex, findErrorPaths } from './errorPaths.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const CONFIG_GO = '/ws/internal/config/config.go';
+const PARSE_GO = '/ws/internal/config/parse.go';
+
+const configGo = `package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Config struct{ Port int }
+
+func MustLoad(path string) *Config {
+	c, err := Load(path)
+	if err != nil {
+		panic(fmt.Sprintf("config: %v", err))
+	}
+	return c
+}
+
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	return &Config{Port: port}, parse(f)
+}
+
+func (c *Config) Addr() string {
+	return fmt.Sprintf(":%d", mustPort(c.Port))
+}
+
+func Safe(path string) (c *Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("config: %v", r)
+		}
+	}()
+	return MustLoad(path), nil
+}
+`;
+
+const parseGo = `package config
+
+import "io"
+
+func parse(r io.Reader) error {
+	_, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func mustPort(port int) int {
+	if port == 0 {
+		panic("no port")
+	}
+	_ = validate(port)
+	return port
+}
+
+func validate(port int) error {
+	return nil
+}
+`;
+
+describe('findErrorPaths', () => {
+  it('should find panics, recovers, propagated and dropped errors', () => {
+    const events = findErrorPaths(CONFIG_GO, configGo);
+
+    expect(events.map(event => [event.kind, event.expression, event.line])).toEqual([
+      ['panic', 'fmt.Sprintf("config: %v", err)', 13],
+      ['propagate', 'fmt.Errorf("open config: %w", err)', 21],
+      ['discard', 'strconv.Atoi(os.Getenv("PORT"))', 24],
+      ['recover', '', 34]
+    ]);
+    expect(events[1]).toMatchObject({ package: 'config', character: 2, wrapped: true });
+    expect(events[2].character).toBe(7);
+    expect(events[3].deferred).toBe(true);
+  });
+
+  it('should ignore blank names that are not the last result and errors that are not passed on', () => {
+    const events = findErrorPaths('/ws/main.go', `package main
+
+import "errors"
+
+var _ Runner = (*job)(nil)
+
+func run(items []string, m map[string]int) error {
+	for _, item := range items {
+		_, ok := m[item]
+		_, err := start(item)
+		if !ok || err != nil {
+			return errors.New("missing")
+		}
+	}
+	recover()
+	return nil
+}
+`);
+    expect(events.map(event => [event.kind, event.deferred])).toEqual([['recover', undefined]]);
+  });
+});
+
+describe('ErrorPathIndex', () => {
+  let index: MockBackgroundIndex;
+  let errorPaths: ErrorPathIndex;
+  let contents: Record<string, string>;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, hash?: string): void {
+    contents[uri] = content;
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(hash ? { hash } : {}) });
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = {};
+    addGoFile(CONFIG_GO, configGo);
+    addGoFile(PARSE_GO, parseGo);
+    addGoFile('/ws/internal/config/config_test.go', 'package config\n\nfunc TestLoad(t *testing.T) { panic("todo") }\n');
+    errorPaths = new ErrorPathIndex(index.asBackgroundIndex(), async filePath => contents[filePath]);
+    await errorPaths.build();
+  });
+
+  it('should list the exported functions that can panic, through the calls of their package', () => {
+    const report = errorPaths.query({ exported: true, canPanic: true });
+
+    expect(report.functions.map(summary => [summary.name, summary.panics, summary.panicsVia])).toEqual([
+      ['MustLoad', 1, undefined],
+      ['Config.Addr', 0, 'mustPort']
+    ]);
+    expect(report.events.map(event => event.function)).toEqual(['MustLoad']);
+    expect(report.functions[1].location).toMatchObject({ uri: CONFIG_GO, line: 28 });
+  });
+
+  it('should not report functions that recover as panicking', () => {
+    const report = errorPaths.query({ path: CONFIG_GO });
+    const safe = report.functions.find(summary => summary.name === 'Safe');
+
+    expect(safe).toMatchObject({ recovers: true, canPanic: false, exported: true });
+    expect(report.events.find(event => event.kind === 'recover')?.function).toBe('Safe$1');
+  });
+
+  it('should list the functions swallowing errors', () => {
+    const report = errorPaths.query({ kind: 'discard' });
+
+    expect(report).toMatchObject({ total: 2, byKind: { discard: 2 }, truncated: false });
+    expect(report.functions.map(summary => [summary.name, summary.discards, summary.exported])).toEqual([
+      ['Load', 1, true],
+      ['mustPort', 1, false]
+    ]);
+    expect(errorPaths.query({ kind: 'propagate', package: 'internal/config' }).byKind).toEqual({ propagate: 2 });
+    expect(errorPaths.query({ package: 'server' }).total).toBe(0);
+    expect(errorPaths.query({ limit: 1 }).truncated).toBe(true);
+  });
+
+  it('should rescan changed files only', async () => {
+    addGoFile(PARSE_GO, parseGo.replace('_ = validate(port)', '_ = validate(port)\n\t_ = validate(port + 1)'), 'changed');
+    expect(await errorPaths.build()).toMatchObject({ files: 2, events: 8, updated: 1 });
+  });
+});
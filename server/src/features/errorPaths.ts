@@ -0,0 +1,686 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { GO_KEYWORDS, GO_PREDECLARED, GoToken, GoTokenizer } from '../indexer/components/GoTokenizer.js';
+import { matchBrackets } from '../indexer/components/GoTokenUtils.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/**
+ * `panic` (and `log.Panic`) calls, `recover` calls, returns passing an
+ * error on, and call results assigned to `_`.
+ */
+export type ErrorPathKind = 'panic' | 'recover' | 'propagate' | 'discard';
+
+export const ERROR_PATH_KINDS: ErrorPathKind[] = ['panic', 'recover', 'propagate', 'discard'];
+
+export interface ErrorPathEvent {
+  uri: string;
+  /** Name in the file's package clause */
+  package: string;
+  /** 0-based position of `panic`, `recover`, `return` or the `_` */
+  line: number;
+  character: number;
+  kind: ErrorPathKind;
+  /**
+   * As written: the panic value, the returned error (`err`,
+   * `fmt.Errorf("load: %w", err)`) or the call whose result is dropped
+   * (`f.Close()`). Empty for recover.
+   */
+  expression: string;
+  /** propagate: wrapped with `%w` or errors.Wrap and friends */
+  wrapped?: boolean;
+  /** recover: called in a deferred function literal, where it stops panics */
+  deferred?: boolean;
+  /** Function, method or closure the event is in, e.g. `Config.Load` or `Config.Load$1` */
+  function?: string;
+  functionLocation?: SymbolLocation;
+}
+
+/**
+ * A function or method with the error-path events in it and in the
+ * closures written in it.
+ */
+export interface ErrorFunctionSummary {
+  /** Qualified name, e.g. `Config.Load` */
+  name: string;
+  uri: string;
+  package: string;
+  location: SymbolLocation;
+  exported: boolean;
+  panics: number;
+  /** Defers a function calling recover */
+  recovers: boolean;
+  propagates: number;
+  discards: number;
+  /** Panics, or calls a function of its package that can, and does not recover */
+  canPanic: boolean;
+  /** The function called on the way to a panic, when the panic is not its own */
+  panicsVia?: string;
+}
+
+export interface ErrorPathIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ErrorPathQuery {
+  kind?: ErrorPathKind;
+  /** Only exported functions and methods, and the events in them */
+  exported?: boolean;
+  /** Only functions that can panic, and the events in them */
+  canPanic?: boolean;
+  /** Only this package: a package name (`config`) or a folder path suffix (`internal/config`) */
+  package?: string;
+  /** Only files in this file or under this folder */
+  path?: string;
+  /** Number of events to return (default: 1000); functions are not limited */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface ErrorPathReport {
+  /** Matching events, before the limit */
+  total: number;
+  /** Matching events per kind, before the limit */
+  byKind: Partial<Record<ErrorPathKind, number>>;
+  /** In path and position order */
+  events: ErrorPathEvent[];
+  /**
+   * Functions matching the query with events of the kind asked for (any
+   * kind without one), or that can panic through the functions they call;
+   * in path and position order
+   */
+  functions: ErrorFunctionSummary[];
+  truncated: boolean;
+}
+
+/** Events matching a query; how servers expose the index */
+export type ErrorPathSource = (query: ErrorPathQuery) => Promise<ErrorPathReport>;
+
+/** The part of an index the error path index reads: the background index or the library Indexer */
+export interface ErrorPathSourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+  /** Declarations, for the function each event is in */
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type ErrorPathReader = (filePath: string) => Promise<string>;
+
+/** A call in a function body: `parse(...)` or `s.load(...)` */
+interface Call {
+  name: string;
+  method: boolean;
+  line: number;
+  character: number;
+}
+
+/** What a function does, before calls are followed */
+interface FunctionFacts {
+  summary: Omit<ErrorFunctionSummary, 'canPanic' | 'panicsVia'>;
+  kind: string;
+  calls: Call[];
+}
+
+interface FileEntry {
+  hash: string;
+  events: ErrorPathEvent[];
+  functions: FunctionFacts[];
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+const FUNCTION_KINDS = new Set(['function', 'method', 'closure']);
+
+/** github.com/pkg/errors (and cockroachdb/errors) functions wrapping an error */
+const WRAP_FUNCTIONS = new Set(['Wrap', 'Wrapf', 'WithStack', 'WithMessage', 'WithMessagef', 'Join']);
+
+const ERROR_NAME_RE = /^err(?:[A-Z0-9_]\w*)?$|Err$/;
+
+/**
+ * Panics, recovers, error returns and dropped call results in a Go file.
+ *
+ * Errors are told apart by name and convention, not by type: a return
+ * propagates when its last result is an `err`-like name (`err`, `errLoad`,
+ * `parseErr`) or wraps one, and a call result is dropped when the last
+ * name it is assigned to is `_` (`_ = f.Close()`, `n, _ := strconv.Atoi(s)`).
+ * `log.Panic`, `log.Panicf` and `log.Panicln` count as panics.
+ */
+export function findErrorPaths(uri: string, content: string): ErrorPathEvent[] {
+  return scanErrorPaths(uri, content).events;
+}
+
+function scanErrorPaths(uri: string, content: string): { packageName: string; events: ErrorPathEvent[]; calls: Call[] } {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens } = tokenizer.tokenize();
+  const closing = matchBrackets(tokens);
+  const packageName = tokens[0]?.text === 'package' && tokens[1]?.type === 'ident' ? tokens[1].text : '';
+  const deferredBodies: Array<{ open: number; close: number }> = [];
+  const events: ErrorPathEvent[] = [];
+  const calls: Call[] = [];
+
+  const text = (start: number, end: number) => start > end
+    ? ''
+    : content.slice(tokens[start].offset, tokens[end].end).replace(/\s+/g, ' ');
+  const push = (token: GoToken, kind: ErrorPathKind, expression: string, details: Partial<ErrorPathEvent> = {}) => {
+    const position = tokenizer.positionAt(token.offset);
+    events.push({ uri, package: packageName, line: position.line, character: position.character, kind, expression, ...details });
+  };
+
+  for (let i = 0; i < tokens.length; i++) {
+    const token = tokens[i];
+    const previous = tokens[i - 1];
+    const next = tokens[i + 1];
+
+    if (token.type === 'punct') {
+      if ((token.text === '=' || token.text === ':=') && previous?.text === '_') {
+        // The error is the last result: `_ = f()`, `n, _ := parse(s)`, but not `_, err := f()`
+        const end = callEnd(tokens, i + 1, closing);
+        if (end !== undefined && endsStatement(tokens, end)) {
+          push(previous, 'discard', text(i + 1, end));
+        }
+      }
+      continue;
+    }
+    if (token.type !== 'ident') {
+      continue;
+    }
+
+    if (previous?.text === '.') {
+      if (next?.text === '(' && !GO_KEYWORDS.has(token.text)) {
+        const position = tokenizer.positionAt(token.offset);
+        calls.push({ name: token.text, method: true, ...position });
+      }
+      if (token.text.startsWith('Panic') && tokens[i - 2]?.text === 'log' && tokens[i - 3]?.text !== '.' && next?.text === '(') {
+        push(tokens[i - 2], 'panic', text(i + 2, (closing[i + 1] ?? i + 2) - 1));
+      }
+      continue;
+    }
+
+    switch (token.text) {
+      case 'defer':
+        if (next?.text === 'func' && tokens[i + 2]?.text === '(') {
+          const open = bodyOpen(tokens, closing[i + 2], closing);
+          if (open !== undefined && closing[open] !== undefined) {
+            deferredBodies.push({ open, close: closing[open]! });
+          }
+        }
+        continue;
+      case 'panic':
+        if (next?.text === '(') {
+          push(token, 'panic', text(i + 2, (closing[i + 1] ?? i + 2) - 1));
+        }
+        continue;
+      case 'recover':
+        if (next?.text === '(' && tokens[i + 2]?.text === ')') {
+          // Only a function run by defer stops a panic with recover
+          const innermost = deferredBodies.filter(body => body.open < i && i < body.close).pop();
+          push(token, 'recover', '', innermost && !inNestedLiteral(tokens, innermost.open, i, closing) ? { deferred: true } : {});
+        }
+        continue;
+      case 'return': {
+        if (!next || next.line > token.line) {
+          continue;
+        }
+        const end = statementEnd(tokens, i + 1);
+        const last = lastResult(tokens, i + 1, end);
+        if (last) {
+          const propagated = propagatedError(tokens, last.start, last.end, closing);
+          if (propagated) {
+            push(token, 'propagate', text(last.start, last.end - 1), propagated.wrapped ? { wrapped: true } : {});
+          }
+        }
+        continue;
+      }
+    }
+
+    // Not the name of a declaration: func Load(, func (c *Config) Load(
+    const declared = previous?.text === 'func' || (previous?.text === ')' && tokens[closing.lastIndexOf(i - 1) - 1]?.text === 'func');
+    if (next?.text === '(' && !GO_KEYWORDS.has(token.text) && !GO_PREDECLARED.has(token.text) && !declared) {
+      const position = tokenizer.positionAt(token.offset);
+      calls.push({ name: token.text, method: false, ...position });
+    }
+  }
+  return { packageName, events, calls };
+}
+
+/**
+ * Error Path Index - where Go code panics, recovers, passes errors on and
+ * drops them, so "which exported functions can panic" and "which
+ * functions swallow errors" are one query during an error handling audit.
+ *
+ * Like the concurrency index (features/concurrency.ts) it is built from
+ * the files of the background index, rescans only files whose hash
+ * changed and leaves test files out; events are attributed to the
+ * innermost function, method or closure. A function can panic when it
+ * does, or a closure written in it does, or it calls a function of the
+ * same package that can, unless it defers a recover. Calls are matched by
+ * name within the package folder: plain calls to functions, `x.m()` to
+ * methods; calls into other packages are not followed.
+ */
+export class ErrorPathIndex {
+  private files: Map<string, FileEntry> = new Map();
+
+  constructor(
+    private index: ErrorPathSourceIndex,
+    private readFile: ErrorPathReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the index in line with the source index.
+   */
+  async build(options: ErrorPathIndexOptions = {}): Promise<{ files: number; events: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles())
+      .filter(uri => path.extname(uri) === '.go' && !uri.endsWith('_test.go'))
+      .sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting error paths (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        const { packageName, events, calls } = scanErrorPaths(uri, await this.readFile(uri));
+        const symbols = await this.index.getFileSymbols(uri);
+        this.files.set(uri, { hash, events, functions: summarize(uri, packageName, events, calls, symbols) });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'Error paths complete');
+    let events = 0;
+    for (const file of this.files.values()) {
+      events += file.events.length;
+    }
+    return { files: this.files.size, events, updated };
+  }
+
+  /**
+   * Events matching the query, and the functions they are in.
+   */
+  query(query: ErrorPathQuery = {}): ErrorPathReport {
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const limit = query.limit ?? DEFAULT_LIMIT;
+    const folder = query.package?.includes('/') ? path.sep + query.package.replace(/^\/+|\/+$/g, '').split('/').join(path.sep) : undefined;
+    const summaries = this.summaries();
+
+    const matches: ErrorPathEvent[] = [];
+    const functions: ErrorFunctionSummary[] = [];
+    for (const uri of [...this.files.keys()].sort()) {
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      if (folder && !path.dirname(uri).endsWith(folder)) {
+        continue;
+      }
+      const file = this.files.get(uri)!;
+      const ownerOf = (event: ErrorPathEvent) => event.function === undefined
+        ? undefined
+        : summaries.get(`${uri}#${ownerName(event.function)}`);
+      const matchesFunction = (summary: ErrorFunctionSummary | undefined) =>
+        (!query.exported || summary?.exported === true) && (!query.canPanic || summary?.canPanic === true);
+
+      const events = file.events.filter(event =>
+        (!query.kind || event.kind === query.kind) &&
+        (!query.package || folder !== undefined || event.package === query.package) &&
+        matchesFunction(ownerOf(event)));
+      matches.push(...events);
+
+      for (const facts of file.functions) {
+        const summary = summaries.get(`${uri}#${facts.summary.name}`)!;
+        if (!matchesFunction(summary) || (query.package && folder === undefined && summary.package !== query.package)) {
+          continue;
+        }
+        const own = events.some(event => ownerOf(event) === summary);
+        if (own || (!query.kind && summary.canPanic) || (query.kind === 'panic' && summary.canPanic)) {
+          functions.push(summary);
+        }
+      }
+    }
+
+    const byKind: Partial<Record<ErrorPathKind, number>> = {};
+    for (const event of matches) {
+      byKind[event.kind] = (byKind[event.kind] ?? 0) + 1;
+    }
+    return {
+      total: matches.length,
+      byKind,
+      events: matches.slice(0, limit),
+      functions,
+      truncated: matches.length > limit
+    };
+  }
+
+  /**
+   * Summaries by `uri#name`, with panics followed through the calls of
+   * each package folder.
+   */
+  private summaries(): Map<string, ErrorFunctionSummary> {
+    const byFolder = new Map<string, FunctionFacts[]>();
+    for (const [uri, file] of this.files) {
+      const folder = path.dirname(uri);
+      byFolder.set(folder, [...(byFolder.get(folder) ?? []), ...file.functions]);
+    }
+
+    const summaries = new Map<string, ErrorFunctionSummary>();
+    for (const functions of byFolder.values()) {
+      const byName = new Map<string, FunctionFacts[]>();
+      for (const facts of functions) {
+        const key = `${facts.kind}:${facts.summary.name.slice(facts.summary.name.lastIndexOf('.') + 1)}`;
+        byName.set(key, [...(byName.get(key) ?? []), facts]);
+      }
+
+      // undefined while being visited, so recursion ends on cycles
+      const visited = new Map<FunctionFacts, { canPanic: boolean; via?: string } | undefined>();
+      const visit = (facts: FunctionFacts): { canPanic: boolean; via?: string } => {
+        if (visited.has(facts)) {
+          return visited.get(facts) ?? { canPanic: false };
+        }
+        visited.set(facts, undefined);
+        let result: { canPanic: boolean; via?: string } = { canPanic: false };
+        if (!facts.summary.recovers) {
+          if (facts.summary.panics > 0) {
+            result = { canPanic: true };
+          } else {
+            for (const call of facts.calls) {
+              const callee = (byName.get(`${call.method ? 'method' : 'function'}:${call.name}`) ?? [])
+                .find(candidate => candidate !== facts && visit(candidate).canPanic);
+              if (callee) {
+                result = { canPanic: true, via: callee.summary.name };
+                break;
+              }
+            }
+          }
+        }
+        visited.set(facts, result);
+        return result;
+      };
+
+      for (const facts of functions) {
+        const { canPanic, via } = visit(facts);
+        summaries.set(`${facts.summary.uri}#${facts.summary.name}`, { ...facts.summary, canPanic, ...(via ? { panicsVia: via } : {}) });
+      }
+    }
+    return summaries;
+  }
+}
+
+/**
+ * Set each event's function, and sum events and calls up per function and
+ * method, closures included.
+ */
+function summarize(
+  uri: string,
+  packageName: string,
+  events: ErrorPathEvent[],
+  calls: Call[],
+  symbols: IndexedSymbol[]
+): FunctionFacts[] {
+  const callables = symbols.filter(symbol => symbol.isDefinition !== false && FUNCTION_KINDS.has(symbol.kind));
+  const innermost = (line: number, character: number): IndexedSymbol | undefined => {
+    let best: IndexedSymbol | undefined;
+    for (const symbol of callables) {
+      // Ranges nest, so of those containing the position the innermost starts last
+      if (contains(symbol, line, character) && (!best || startsAfter(symbol, best))) {
+        best = symbol;
+      }
+    }
+    return best;
+  };
+
+  const functions = new Map<string, FunctionFacts>();
+  for (const symbol of callables) {
+    if (symbol.kind === 'closure') {
+      continue;
+    }
+    const name = qualifiedName(symbol);
+    functions.set(name, {
+      summary: {
+        name,
+        uri,
+        package: packageName,
+        location: symbol.location,
+        exported: symbol.isExported === true,
+        panics: 0,
+        recovers: false,
+        propagates: 0,
+        discards: 0
+      },
+      kind: symbol.kind,
+      calls: []
+    });
+  }
+
+  for (const event of events) {
+    const symbol = innermost(event.line, event.character);
+    if (!symbol) {
+      continue;
+    }
+    event.function = qualifiedName(symbol);
+    event.functionLocation = symbol.location;
+    const facts = functions.get(ownerName(event.function));
+    if (!facts) {
+      continue;
+    }
+    switch (event.kind) {
+      case 'panic':
+        facts.summary.panics++;
+        break;
+      case 'recover':
+        facts.summary.recovers ||= event.deferred === true;
+        break;
+      case 'propagate':
+        facts.summary.propagates++;
+        break;
+      case 'discard':
+        facts.summary.discards++;
+        break;
+    }
+  }
+  for (const call of calls) {
+    const symbol = innermost(call.line, call.character);
+    const facts = symbol && functions.get(ownerName(qualifiedName(symbol)));
+    if (facts && !facts.calls.some(other => other.name === call.name && other.method === call.method)) {
+      facts.calls.push(call);
+    }
+  }
+  return [...functions.values()];
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+/** The function a closure is written in: `Config.Load` for `Config.Load$1$2` */
+function ownerName(name: string): string {
+  const dollar = name.indexOf('$');
+  return dollar === -1 ? name : name.slice(0, dollar);
+}
+
+function contains(symbol: IndexedSymbol, line: number, character: number): boolean {
+  const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+  return (line > startLine || (line === startLine && character >= startCharacter)) &&
+    (line < endLine || (line === endLine && character < endCharacter));
+}
+
+function startsAfter(a: IndexedSymbol, b: IndexedSymbol): boolean {
+  return a.range.startLine > b.range.startLine ||
+    (a.range.startLine === b.range.startLine && a.range.startCharacter > b.range.startCharacter);
+}
+
+/**
+ * What a returned expression does with an error: passes it on (`err`) or
+ * wraps it (`fmt.Errorf("...: %w", err)`, `errors.Wrap(err, "...")`).
+ * Undefined for anything else, e.g. `nil` or `errors.New("...")`.
+ */
+function propagatedError(
+  tokens: GoToken[],
+  start: number,
+  end: number,
+  closing: Array<number | undefined>
+): { wrapped: boolean } | undefined {
+  if (end - start === 1) {
+    return tokens[start].type === 'ident' && ERROR_NAME_RE.test(tokens[start].text) ? { wrapped: false } : undefined;
+  }
+  // pkg.Func( ... ) spanning the whole expression
+  if (tokens[start + 1]?.text !== '.' || tokens[start + 3]?.text !== '(' || closing[start + 3] !== end - 1) {
+    return undefined;
+  }
+  const pkg = tokens[start].text;
+  const name = tokens[start + 2].text;
+  const mentionsError = tokens.slice(start + 4, end - 1).some(token => token.type === 'ident' && ERROR_NAME_RE.test(token.text));
+  if (pkg === 'fmt' && name === 'Errorf') {
+    const format = tokens[start + 4];
+    return format?.type === 'string' && format.text.includes('%w') && mentionsError ? { wrapped: true } : undefined;
+  }
+  return pkg === 'errors' && WRAP_FUNCTIONS.has(name) && mentionsError ? { wrapped: true } : undefined;
+}
+
+/**
+ * Index just past the statement starting at token start: the statement
+ * ends at a line break after an operand, a `;` or the closing `}` of its
+ * block, as Go's semicolon rule has it.
+ */
+function statementEnd(tokens: GoToken[], start: number): number {
+  let depth = 0;
+  for (let j = start; j < tokens.length; j++) {
+    const text = tokens[j].text;
+    if (text === '(' || text === '[' || text === '{') {
+      depth++;
+    } else if (text === ')' || text === ']' || text === '}') {
+      if (depth === 0) {
+        return j;
+      }
+      depth--;
+    } else if (text === ';' && depth === 0) {
+      return j;
+    }
+    if (depth === 0 && tokens[j + 1] && tokens[j + 1].line > tokens[j].line && endsLine(tokens[j])) {
+      return j + 1;
+    }
+  }
+  return tokens.length;
+}
+
+/** Whether a line ending in this token ends the statement */
+function endsLine(token: GoToken): boolean {
+  if (token.type !== 'punct') {
+    return !GO_KEYWORDS.has(token.text) || token.text === 'return' || token.text === 'break' ||
+      token.text === 'continue' || token.text === 'fallthrough';
+  }
+  return token.text === ')' || token.text === ']' || token.text === '}' || token.text === '++' || token.text === '--';
+}
+
+/**
+ * The last of the comma-separated results between start and end.
+ */
+function lastResult(tokens: GoToken[], start: number, end: number): { start: number; end: number } | undefined {
+  let depth = 0;
+  let last = start;
+  for (let j = start; j < end; j++) {
+    const text = tokens[j].text;
+    if (text === '(' || text === '[' || text === '{') {
+      depth++;
+    } else if (text === ')' || text === ']' || text === '}') {
+      depth--;
+    } else if (text === ',' && depth === 0) {
+      last = j + 1;
+    }
+  }
+  return last < end ? { start: last, end } : undefined;
+}
+
+/**
+ * Last token of the call starting at token start (`f()`, `f.Close()`,
+ * `strconv.Atoi(s)`, `b.Build().Run()`); undefined for anything else.
+ */
+function callEnd(tokens: GoToken[], start: number, closing: Array<number | undefined>): number | undefined {
+  if (tokens[start]?.type !== 'ident' || GO_KEYWORDS.has(tokens[start].text)) {
+    return undefined;
+  }
+  let j = start;
+  let called = false;
+  for (;;) {
+    const next = tokens[j + 1];
+    if (next?.text === '.' && tokens[j + 2]?.type === 'ident') {
+      j += 2;
+      called = false;
+    } else if ((next?.text === '(' || next?.text === '[') && closing[j + 1] !== undefined) {
+      called = next.text === '(';
+      j = closing[j + 1]!;
+    } else {
+      return called ? j : undefined;
+    }
+  }
+}
+
+/** Whether nothing follows token end in its statement */
+function endsStatement(tokens: GoToken[], end: number): boolean {
+  const next = tokens[end + 1];
+  return !next || next.line > tokens[end].line || next.text === ';' || next.text === '}';
+}
+
+/**
+ * Index of the `{` opening the body of a func literal whose parameters
+ * close at token paramsClose, past any results.
+ */
+function bodyOpen(tokens: GoToken[], paramsClose: number | undefined, closing: Array<number | undefined>): number | undefined {
+  if (paramsClose === undefined) {
+    return undefined;
+  }
+  for (let j = paramsClose + 1; j < tokens.length; j++) {
+    const text = tokens[j].text;
+    if (text === '{') {
+      return j;
+    }
+    if (text === '(') {
+      j = closing[j] ?? j;
+    } else if (tokens[j].line > tokens[paramsClose].line) {
+      return undefined;
+    }
+  }
+  return undefined;
+}
+
+/** Whether the token at index at is in a func literal nested in the body opening at index open */
+function inNestedLiteral(tokens: GoToken[], open: number, at: number, closing: Array<number | undefined>): boolean {
+  for (let j = open + 1; j < at; j++) {
+    if (tokens[j].text === 'func' && tokens[j + 1]?.text === '(') {
+      const body = bodyOpen(tokens, closing[j + 1], closing);
+      if (body !== undefined && body < at && (closing[body] ?? tokens.length) > at) {
+        return true;
+      }
+    }
+  }
+  return false;
+}
@@ -0,0 +1,155 @@
+import { BloomFilter } from '../utils/bloomFilter.js';
+import { ProtoReader, ProtoWriter } from '../utils/protoWire.js';
+import { writeFileAtomic } from '../utils/atomicWrite.js';
+import * as fs from 'fs';
+import * as path from 'path';
+
+const SKIP_LIST_VERSION = 1;
+const FALSE_POSITIVE_RATE = 0.01;
+
+interface SkipEntry {
+  hash: string;
+  filter: BloomFilter;
+}
+
+/**
+ * File Skip List - one bloom filter of terms per file.
+ *
+ * A search asks `mightContain()` before reading a file and skips it when
+ * the filter rules a query term out; a filter never rules out a term the
+ * file has, so skipping loses no matches. Filters are kept for the content
+ * hash they were built from and are ignored once the file changes.
+ *
+ * At 1% false positives a filter costs ~10 bits per distinct term, so the
+ * list for a large workspace is a few MB: small enough to load whole and
+ * persist next to the index (`skip-list.bin`), which lets a fresh process
+ * search a cold cache without first reading every file.
+ */
+export class FileSkipList {
+  private entries: Map<string, SkipEntry> = new Map();
+  private loaded = false;
+  private dirty = false;
+
+  /**
+   * @param filePath - Where the list is persisted; in memory only when unset
+   */
+  constructor(private filePath?: string) {}
+
+  get size(): number {
+    return this.entries.size;
+  }
+
+  /**
+   * Whether the file, at this content hash, may contain every term
+   * (lowercased). Undefined when there is no filter for that content.
+   */
+  mightContain(uri: string, hash: string, terms: string[]): boolean | undefined {
+    const entry = this.entries.get(uri);
+    if (!entry || entry.hash !== hash || hash === '') {
+      return undefined;
+    }
+    return terms.every(term => entry.filter.has(term));
+  }
+
+  /**
+   * Record the terms of a file's content.
+   */
+  set(uri: string, hash: string, terms: Set<string>): void {
+    const filter = BloomFilter.create(terms.size, FALSE_POSITIVE_RATE);
+    for (const term of terms) {
+      filter.add(term);
+    }
+    this.entries.set(uri, { hash, filter });
+    this.dirty = true;
+  }
+
+  delete(uri: string): void {
+    if (this.entries.delete(uri)) {
+      this.dirty = true;
+    }
+  }
+
+  /**
+   * Read the persisted list once; a missing or unreadable file starts empty.
+   */
+  async load(): Promise<void> {
+    if (this.loaded) {
+      return;
+    }
+    this.loaded = true;
+    if (!this.filePath) {
+      return;
+    }
+
+    let bytes: Buffer;
+    try {
+      bytes = await fs.promises.readFile(this.filePath);
+    } catch {
+      return;
+    }
+    try {
+      const entries = new Map<string, SkipEntry>();
+      const reader = new ProtoReader(bytes);
+      let version = 0;
+      while (reader.hasMore()) {
+        const { field, wireType } = reader.tag();
+        if (field === 1) {
+          version = reader.varint();
+        } else if (field === 2) {
+          const [uri, entry] = decodeEntry(reader.message());
+          entries.set(uri, entry);
+        } else {
+          reader.skip(wireType);
+        }
+      }
+      if (version === SKIP_LIST_VERSION) {
+        // Filters built meanwhile are newer than the persisted ones
+        this.entries = new Map([...entries, ...this.entries]);
+      }
+    } catch {
+      // Corrupt list - rebuilt as files are read
+    }
+  }
+
+  /**
+   * Persist the list if it changed since the last load or save.
+   */
+  async save(): Promise<void> {
+    if (!this.filePath || !this.dirty) {
+      return;
+    }
+    const writer = new ProtoWriter(this.entries.size * 64).uint(1, SKIP_LIST_VERSION);
+    for (const [uri, entry] of this.entries) {
+      writer.message(2, new ProtoWriter()
+        .string(1, uri)
+        .string(2, entry.hash)
+        .uint(3, entry.filter.hashes)
+        .bytes(4, entry.filter.bits));
+    }
+    await fs.promises.mkdir(path.dirname(this.filePath), { recursive: true });
+    await writeFileAtomic(this.filePath, writer.finish());
+    this.dirty = false;
+  }
+}
+
+function decodeEntry(reader: ProtoReader): [string, SkipEntry] {
+  let uri = '';
+  let hash = '';
+  let hashes = 1;
+  let bits: Uint8Array = new Uint8Array(8);
+  while (reader.hasMore()) {
+    const { field, wireType } = reader.tag();
+    if (field === 1) {
+      uri = reader.string();
+    } else if (field === 2) {
+      hash = reader.string();
+    } else if (field === 3) {
+      hashes = reader.varint();
+    } else if (field === 4) {
+      bits = reader.bytes().slice();
+    } else {
+      reader.skip(wireType);
+    }
+  }
+  return [uri, { hash, filter: new BloomFilter(bits, hashes) }];
+}
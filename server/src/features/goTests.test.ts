@@ -0,0 +1,169 @@
+/**
+ * Go Test Index Tests
+ *
+ * Verifies finding the tests, benchmarks, fuzz targets and examples that
+ * exercise a symbol: through helpers and callers, across packages that
+ * import it, qualified symbol names, depth and kind limits, and the
+ * `go test` commands per package.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { GoTestIndex } from './goTests.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const USER_GO = '/ws/internal/user/user.go';
+const USER_TEST_GO = '/ws/internal/user/user_test.go';
+
+const userGo = `package user
+
+type Person struct{ Name string }
+
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+
+func (p *Person) Greet() string {
+	return "hi " + p.Name
+}
+
+func Version() string { return "1" }
+`;
+
+const userTestGo = `package user
+
+import "testing"
+
+func newFixture() *Person {
+	return NewPerson("fixture")
+}
+
+func TestGreet(t *testing.T) {
+	t.Run("named", func(t *testing.T) {
+		if got := newFixture().Greet(); got == "" {
+			t.Fatal("empty")
+		}
+	})
+}
+
+func BenchmarkNewPerson(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewPerson("x")
+	}
+}
+
+func ExampleNewPerson() {
+	NewPerson("ada")
+}
+
+func TestVersion(t *testing.T) {
+	_ = Version()
+}
+`;
+
+const shopGo = `package shop
+
+import "example.com/app/internal/user"
+
+func Checkout(p *user.Person) string {
+	return p.Greet()
+}
+`;
+
+const shopTestGo = `package shop_test
+
+import (
+	"testing"
+
+	"example.com/app/internal/shop"
+)
+
+func FuzzCheckout(f *testing.F) {
+	f.Fuzz(func(t *testing.T, name string) {
+		shop.Checkout(nil)
+	})
+}
+`;
+
+// Its own Person, not the user package's
+const otherTestGo = `package other
+
+import "testing"
+
+type Person struct{}
+
+func TestPerson(t *testing.T) {
+	_ = Person{}
+}
+`;
+
+describe('GoTestIndex', () => {
+  let index: MockBackgroundIndex;
+  let tests: GoTestIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, hash?: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(hash ? { hash } : {}) });
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    addGoFile(USER_GO, userGo);
+    addGoFile(USER_TEST_GO, userTestGo);
+    addGoFile('/ws/internal/shop/shop.go', shopGo);
+    addGoFile('/ws/internal/shop/shop_test.go', shopTestGo);
+    addGoFile('/ws/internal/other/other_test.go', otherTestGo);
+    tests = new GoTestIndex(index.asBackgroundIndex());
+    await tests.build();
+  });
+
+  it('should find the tests exercising a symbol through helpers and callers', async () => {
+    const report = await tests.testsFor({ symbol: 'user.Person', root: '/ws' });
+
+    expect(report.definitions.map(definition => [definition.name, definition.location.uri])).toEqual([['Person', USER_GO]]);
+    expect(report.tests.map(test => [test.name, test.kind, test.via])).toEqual([
+      ['FuzzCheckout', 'fuzz', ['Checkout']],
+      ['TestGreet', 'test', ['Person.Greet']],
+      ['BenchmarkNewPerson', 'benchmark', ['NewPerson']],
+      ['ExampleNewPerson', 'example', ['NewPerson']]
+    ]);
+    expect(report.tests[0]).toMatchObject({ package: 'shop_test', location: { uri: '/ws/internal/shop/shop_test.go', line: 8 } });
+    expect(report.packages).toEqual([
+      {
+        dir: './internal/shop',
+        tests: ['FuzzCheckout'],
+        benchmarks: [],
+        commands: ["go test ./internal/shop -run '^(FuzzCheckout)$'"]
+      },
+      {
+        dir: './internal/user',
+        tests: ['ExampleNewPerson', 'TestGreet'],
+        benchmarks: ['BenchmarkNewPerson'],
+        commands: [
+          "go test ./internal/user -run '^(ExampleNewPerson|TestGreet)$'",
+          "go test ./internal/user -run '^$' -bench '^(BenchmarkNewPerson)$'"
+        ]
+      }
+    ]);
+  });
+
+  it('should resolve qualified names and limit depth and kinds', async () => {
+    const greet = await tests.testsFor({ symbol: 'user.Person.Greet', maxDepth: 0 });
+    expect(greet.tests.map(test => [test.name, test.via])).toEqual([['TestGreet', []]]);
+    expect((await tests.testsFor({ symbol: 'Person.Greet' })).tests.map(test => test.name)).toEqual(['TestGreet', 'FuzzCheckout']);
+
+    const benchmarks = await tests.testsFor({ symbol: 'NewPerson', kinds: ['benchmark'] });
+    expect(benchmarks.tests.map(test => test.name)).toEqual(['BenchmarkNewPerson']);
+    expect(benchmarks.packages[0].dir).toBe('/ws/internal/user');
+
+    expect((await tests.testsFor({ symbol: 'internal/other.Person' })).tests.map(test => test.name)).toEqual(['TestPerson']);
+    expect(await tests.testsFor({ symbol: 'billing.Person' })).toMatchObject({ definitions: [], tests: [], packages: [] });
+  });
+
+  it('should reread changed files only', async () => {
+    addGoFile(USER_TEST_GO, userTestGo.replace('_ = Version()', '_ = Version()\n\t_ = NewPerson("v")'), 'changed');
+    expect(await tests.build()).toEqual({ files: 5, tests: 6, updated: 1 });
+    expect((await tests.testsFor({ symbol: 'NewPerson', maxDepth: 0 })).tests.map(test => test.name)).toContain('TestVersion');
+  });
+});
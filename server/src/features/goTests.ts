@@ -0,0 +1,399 @@
+import * as path from 'path';
+import { IndexedFileResult, IndexedSymbol, SymbolLocation } from '../types.js';
+import { GoSymbolMetadata, GoTestKind, goPackageNameFromPath } from '../indexer/goIndexer.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface GoTestIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface TestsForQuery {
+  /**
+   * The symbol: `Person`, `user.Person`, `Person.Greet`, `user.Person.Greet`,
+   * or with the package as a folder suffix or import path, `internal/user.Person`
+   */
+  symbol: string;
+  /** Functions followed between a test and the symbol (default: 3; 0: direct references only) */
+  maxDepth?: number;
+  /** Only these kinds of test functions (default: all) */
+  kinds?: GoTestKind[];
+  /** Workspace root, for `go test ./pkg` commands; without it packages are absolute folders */
+  root?: string;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface GoTestFunction {
+  name: string;
+  kind: GoTestKind;
+  uri: string;
+  /** Name in the test file's package clause, e.g. `user_test` */
+  package: string;
+  location: SymbolLocation;
+  /** Functions from the test down to the symbol, e.g. `['newFixture', 'Store.Load']`; empty when it refers to it itself */
+  via: string[];
+}
+
+export interface GoTestPackage {
+  /** Package folder, relative to the root as `./internal/user` when one is given */
+  dir: string;
+  /** Tests, fuzz targets and examples, which `go test -run` selects */
+  tests: string[];
+  benchmarks: string[];
+  /** `go test` commands running them */
+  commands: string[];
+}
+
+export interface TestsForReport {
+  symbol: string;
+  /** Definitions the symbol resolved to */
+  definitions: Array<{ name: string; kind: string; location: SymbolLocation }>;
+  /** Fewest functions in between first, then path and position order */
+  tests: GoTestFunction[];
+  /** The tests per package folder, with commands to run them */
+  packages: GoTestPackage[];
+}
+
+/** Tests exercising a symbol; how servers expose the index */
+export type TestsForSource = (query: TestsForQuery) => Promise<TestsForReport>;
+
+/** The part of an index the test index reads: the background index or the library Indexer */
+export interface GoTestSourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are read again when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+  getFileResult(uri: string): Promise<IndexedFileResult | null | undefined>;
+  findDefinitions(name: string): Promise<IndexedSymbol[]>;
+}
+
+/** A function or method and the names its body (closures included) refers to */
+interface GraphFunction {
+  id: string;
+  name: string;
+  qualifiedName: string;
+  testKind?: GoTestKind;
+  location: SymbolLocation;
+  uses: Set<string>;
+}
+
+interface GoFileGraph {
+  hash: string;
+  uri: string;
+  dir: string;
+  package: string;
+  importPath?: string;
+  /** Import paths of the file */
+  imports: string[];
+  functions: GraphFunction[];
+}
+
+/** Where a definition is visible from: its package folder, and files importing it */
+interface DefinitionScope {
+  name: string;
+  dir: string;
+  package?: string;
+  importPath?: string;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_MAX_DEPTH = 3;
+
+/**
+ * Go Test Index - which tests, benchmarks, fuzz targets and examples
+ * exercise a symbol, for picking the tests to run after a change.
+ *
+ * Test functions are classified by the Go indexer (GoSymbolMetadata.testKind).
+ * A test exercises a symbol when its body, closures included, refers to it,
+ * or refers to a function that does, up to maxDepth functions in between:
+ * TestCheckout -> newFixture -> Store.Load -> Person. References are
+ * matched by name from files that can see the definition, those of its
+ * package folder and those importing its package, so a `Get` elsewhere
+ * does not count unless its file imports the package. Calls through
+ * interfaces and function values are not followed.
+ */
+export class GoTestIndex {
+  private files: Map<string, GoFileGraph> = new Map();
+  /** Functions per name they refer to; rebuilt after file changes */
+  private users: Map<string, Array<{ fn: GraphFunction; file: GoFileGraph }>> | undefined;
+
+  constructor(private index: GoTestSourceIndex) {}
+
+  /**
+   * Read the Go files whose content changed since the last build.
+   */
+  async build(options: GoTestIndexOptions = {}): Promise<{ files: number; tests: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles()).filter(uri => path.extname(uri) === '.go').sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+        updated++;
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Linking tests (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      const result = await this.index.getFileResult(uri);
+      if (result) {
+        this.files.set(uri, toFileGraph(uri, hash, result));
+      } else {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    if (updated > 0) {
+      this.users = undefined;
+    }
+    onProgress?.(uris.length, uris.length, 'Test linking complete');
+    let tests = 0;
+    for (const file of this.files.values()) {
+      tests += file.functions.filter(fn => fn.testKind).length;
+    }
+    return { files: this.files.size, tests, updated };
+  }
+
+  /**
+   * Tests exercising the symbol, nearest first, grouped into `go test`
+   * commands per package.
+   */
+  async testsFor(query: TestsForQuery): Promise<TestsForReport> {
+    const definitions = await this.resolve(query.symbol);
+    const tests = this.testsExercising(definitions, query);
+    return {
+      symbol: query.symbol,
+      definitions: definitions.map(symbol => ({ name: qualifiedName(symbol), kind: symbol.kind, location: symbol.location })),
+      tests,
+      packages: goTestPackages(tests, query.root)
+    };
+  }
+
+  /**
+   * Tests referring to any of the definitions, or to functions that do,
+   * nearest first. The definitions themselves are not reported, tests or not.
+   */
+  testsExercising(definitions: IndexedSymbol[], options: Omit<TestsForQuery, 'symbol' | 'root'> = {}): GoTestFunction[] {
+    const maxDepth = options.maxDepth ?? DEFAULT_MAX_DEPTH;
+    const kinds = options.kinds && options.kinds.length > 0 ? new Set(options.kinds) : undefined;
+    const users = this.usersByName();
+
+    const visited = new Set(definitions.map(symbol => symbol.id));
+    const found = new Map<string, GoTestFunction>();
+    let frontier: Array<{ scope: DefinitionScope; via: string[] }> = definitions.map(symbol => ({
+      scope: definitionScope(symbol),
+      via: []
+    }));
+
+    while (frontier.length > 0) {
+      throwIfCancelled(options.cancellationToken);
+      const next: typeof frontier = [];
+      for (const { scope, via } of frontier) {
+        for (const { fn, file } of users.get(scope.name) ?? []) {
+          if (visited.has(fn.id) || !canSee(file, scope)) {
+            continue;
+          }
+          visited.add(fn.id);
+          if (fn.testKind) {
+            if (!kinds || kinds.has(fn.testKind)) {
+              found.set(fn.id, { name: fn.name, kind: fn.testKind, uri: file.uri, package: file.package, location: fn.location, via });
+            }
+          } else if (via.length < maxDepth) {
+            next.push({
+              scope: { name: fn.name, dir: file.dir, package: file.package, importPath: file.importPath },
+              via: [fn.qualifiedName, ...via]
+            });
+          }
+        }
+      }
+      frontier = next;
+    }
+
+    return sortTests([...found.values()]);
+  }
+
+  /**
+   * Definitions a symbol name resolves to: by its last segment, narrowed by
+   * the qualifier to a receiver or container, a package name, folder or
+   * import path.
+   */
+  private async resolve(symbol: string): Promise<IndexedSymbol[]> {
+    const separator = symbol.lastIndexOf('.');
+    const name = symbol.slice(separator + 1);
+    const qualifier = separator === -1 ? '' : symbol.slice(0, separator);
+    const definitions = (await this.index.findDefinitions(name))
+      .filter(s => s.isDefinition !== false && path.extname(s.location.uri) === '.go' && s.kind !== 'closure');
+    return definitions.filter(s => matchesQualifier(s, qualifier));
+  }
+
+  private usersByName(): Map<string, Array<{ fn: GraphFunction; file: GoFileGraph }>> {
+    if (!this.users) {
+      this.users = new Map();
+      for (const file of this.files.values()) {
+        for (const fn of file.functions) {
+          for (const name of fn.uses) {
+            let users = this.users.get(name);
+            if (!users) {
+              users = [];
+              this.users.set(name, users);
+            }
+            users.push({ fn, file });
+          }
+        }
+      }
+    }
+    return this.users;
+  }
+}
+
+function toFileGraph(uri: string, hash: string, result: IndexedFileResult): GoFileGraph {
+  const definitions = result.symbols.filter(s => s.isDefinition !== false);
+  const go = definitions.map(s => s.metadata?.go as GoSymbolMetadata | undefined).find(meta => meta?.package);
+  // Top-level functions do not nest, so the one containing a reference
+  // owns it, also when it is in a closure
+  const functions = definitions
+    .filter(s => s.kind === 'function' || s.kind === 'method')
+    .map(s => ({
+      symbol: s,
+      fn: {
+        id: s.id,
+        name: s.name,
+        qualifiedName: qualifiedName(s),
+        testKind: (s.metadata?.go as GoSymbolMetadata | undefined)?.testKind,
+        location: s.location,
+        uses: new Set<string>()
+      } as GraphFunction
+    }));
+
+  for (const reference of result.references) {
+    if (reference.isLocal || reference.isImport) {
+      continue;
+    }
+    const { line, character } = reference.location;
+    const owner = functions.find(({ symbol }) => {
+      const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+      return (line > startLine || (line === startLine && character >= startCharacter)) &&
+        (line < endLine || (line === endLine && character < endCharacter));
+    });
+    owner?.fn.uses.add(reference.symbolName);
+  }
+
+  return {
+    hash,
+    uri,
+    dir: path.dirname(uri),
+    package: go?.package ?? '',
+    importPath: go?.importPath,
+    imports: result.imports.map(imp => imp.moduleSpecifier),
+    functions: functions.map(({ fn }) => fn)
+  };
+}
+
+function definitionScope(symbol: IndexedSymbol): DefinitionScope {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  return { name: symbol.name, dir: path.dirname(symbol.location.uri), package: go?.package, importPath: go?.importPath };
+}
+
+/**
+ * Whether references in a file can be to the definition: the file is in
+ * its package folder (external `_test` packages included) or imports its
+ * package, by import path or, without one, by the package name.
+ */
+function canSee(file: GoFileGraph, scope: DefinitionScope): boolean {
+  if (file.dir === scope.dir) {
+    return true;
+  }
+  if (scope.importPath) {
+    return file.imports.includes(scope.importPath);
+  }
+  const folder = path.basename(scope.dir);
+  return file.imports.some(spec => spec.endsWith('/' + folder) || goPackageNameFromPath(spec) === scope.package);
+}
+
+function matchesQualifier(symbol: IndexedSymbol, qualifier: string): boolean {
+  if (!qualifier) {
+    return true;
+  }
+  const container = symbol.containerName;
+  if (container && qualifier === container) {
+    return true;
+  }
+  if (container && qualifier.endsWith('.' + container)) {
+    return matchesPackage(symbol, qualifier.slice(0, -container.length - 1));
+  }
+  return matchesPackage(symbol, qualifier);
+}
+
+function matchesPackage(symbol: IndexedSymbol, pkg: string): boolean {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  const dir = path.dirname(symbol.location.uri).split(path.sep).join('/');
+  return pkg === go?.package || pkg === go?.importPath || (pkg.includes('/') && (dir.endsWith('/' + pkg) || go?.importPath?.endsWith('/' + pkg) === true));
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+/** Nearest first, then by file and line */
+export function sortTests(tests: GoTestFunction[]): GoTestFunction[] {
+  return tests.sort((a, b) =>
+    a.via.length - b.via.length ||
+    (a.uri < b.uri ? -1 : a.uri > b.uri ? 1 : a.location.line - b.location.line));
+}
+
+/**
+ * Tests grouped by package folder, with the `go test` commands running
+ * exactly them; folders are `./`-relative to root when given.
+ */
+export function goTestPackages(tests: GoTestFunction[], root: string | undefined): GoTestPackage[] {
+  const byDir = new Map<string, GoTestFunction[]>();
+  for (const test of tests) {
+    const dir = path.dirname(test.uri);
+    byDir.set(dir, [...(byDir.get(dir) ?? []), test]);
+  }
+
+  return [...byDir.keys()].sort().map(dir => {
+    const members = byDir.get(dir)!;
+    const target = goTestTarget(dir, root);
+    const tests = members.filter(test => test.kind !== 'benchmark').map(test => test.name).sort();
+    const benchmarks = members.filter(test => test.kind === 'benchmark').map(test => test.name).sort();
+    const commands: string[] = [];
+    if (tests.length > 0) {
+      commands.push(`go test ${target} -run '^(${tests.join('|')})$'`);
+    }
+    if (benchmarks.length > 0) {
+      commands.push(`go test ${target} -run '^$' -bench '^(${benchmarks.join('|')})$'`);
+    }
+    return { dir: target, tests, benchmarks, commands };
+  });
+}
+
+/** A package folder as `go test` takes it: `./internal/user` under root, else as is */
+export function goTestTarget(dir: string, root: string | undefined): string {
+  if (!root) {
+    return dir;
+  }
+  const relative = path.relative(root, dir).split(path.sep).join('/');
+  return relative === '' ? '.' : `./${relative}`;
+}
@@ -0,0 +1,171 @@
+/**
+ * GraphExporter Tests
+ *
+ * Verifies the Neo4j export of packages, files and symbols with their
+ * call, reference, containment and import relationships, as a Cypher
+ * script and as neo4j-admin import CSVs.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { GraphExporter } from './graphExporter.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const handlerGo = `package api
+
+import "example.com/app/service"
+
+// Handler serves orders.
+func Handler() {
+	service.PlaceOrder(nil)
+}
+`;
+
+const orderGo = `package service
+
+import "example.com/app/db"
+
+type Order struct {
+	ID string
+}
+
+func (o *Order) Save() error {
+	return db.Insert(o.ID)
+}
+
+func PlaceOrder(o *Order) error {
+	return o.Save()
+}
+`;
+
+const dbGo = `package db
+
+import "fmt"
+
+func Insert(id string) error {
+	return fmt.Errorf("insert %s", id)
+}
+`;
+
+describe('GraphExporter', () => {
+  let index: MockBackgroundIndex;
+  let tempDir: string;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, importPath: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    const symbols = result.symbols.map(s => ({ ...s, metadata: { ...s.metadata, go: { ...s.metadata?.go, importPath } } }));
+    index.addFile(uri, symbols, result.references, { imports: result.imports });
+  }
+
+  function createExporter(): GraphExporter {
+    return new GraphExporter(index.asBackgroundIndex(), '/ws');
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'graph-export-'));
+    addGoFile('/ws/api/handler.go', 'example.com/app/api', handlerGo);
+    addGoFile('/ws/service/order.go', 'example.com/app/service', orderGo);
+    addGoFile('/ws/db/db.go', 'example.com/app/db', dbGo);
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should write a Cypher script of nodes before the relationships matching them', async () => {
+    const outputPath = path.join(tempDir, 'export', 'graph.cypher');
+    const result = await createExporter().export(outputPath);
+
+    expect(result).toMatchObject({
+      outputPath,
+      format: 'cypher',
+      nodes: { Package: 4, File: 3, Symbol: 6 },
+      relationships: { IN_PACKAGE: 3, DEFINED_IN: 6, MEMBER_OF: 2, CALLS: 3, REFERENCES: 3, IMPORTS: 3, DEPENDS_ON: 3 },
+      unresolvedReferences: 0
+    });
+
+    const script = fs.readFileSync(outputPath, 'utf-8');
+    expect(script).toContain('CREATE CONSTRAINT symbol_id IF NOT EXISTS FOR (n:Symbol) REQUIRE n.id IS UNIQUE;');
+    expect(script).toContain("{id: 'fmt', external: true}");
+    expect(script).toMatch(/\{id: '[^']+', name: 'Handler', qualifiedName: 'api\.Handler', kind: 'function', file: 'api\/handler\.go', line: 5, character: 5, exported: true, deprecated: false\}/);
+    expect(script).toContain('MATCH (a:Symbol {id: row.from}), (b:Symbol {id: row.to})\nMERGE (a)-[r:CALLS]->(b) SET r += row.properties;');
+    expect(script).toContain("{from: 'api', to: 'service', properties: {files: 1}}");
+    expect(script.indexOf('MERGE (n:Symbol')).toBeLessThan(script.indexOf('MERGE (a)-[:IN_PACKAGE]->(b)'));
+
+    // Handler -> PlaceOrder -> Order.Save -> db.Insert, the path into package db
+    const ids = new Map([...script.matchAll(/\{id: '([^']+)', name: '(\w+)'/g)].map(m => [m[1], m[2]]));
+    const calls = [...script.matchAll(/\{from: '([^']+)', to: '([^']+)', properties: \{count: (\d+), ambiguous: false\}\}/g)]
+      .map(m => `${ids.get(m[1])} -> ${ids.get(m[2])}`);
+    expect(calls).toEqual(['Handler -> PlaceOrder', 'Save -> Insert', 'PlaceOrder -> Save']);
+  });
+
+  it('should write neo4j-admin CSVs and an import script', async () => {
+    const outputPath = path.join(tempDir, 'graph-csv');
+    const result = await createExporter().export(outputPath, { format: 'csv', relationships: ['MEMBER_OF', 'REFERENCES'] });
+
+    expect(result.importScript).toBe(path.join(outputPath, 'import.sh'));
+    expect(result.relationships).toMatchObject({ MEMBER_OF: 2, REFERENCES: 3, CALLS: 0, IMPORTS: 0 });
+    expect(fs.readdirSync(outputPath).sort()).toEqual([
+      'File.csv', 'Package.csv', 'Symbol-MEMBER_OF-Symbol.csv', 'Symbol-REFERENCES-Symbol.csv', 'Symbol.csv', 'import.sh'
+    ]);
+
+    const read = (file: string) => fs.readFileSync(path.join(outputPath, file), 'utf-8').split('\n');
+    expect(read('Package.csv')).toContain('"fmt",,true');
+    expect(read('Symbol.csv')[0]).toBe('id:ID(Symbol),name,qualifiedName,kind,file,line:int,character:int,exported:boolean,deprecated:boolean');
+    const references = read('Symbol-REFERENCES-Symbol.csv');
+    expect(references[0]).toBe(':START_ID(Symbol),:END_ID(Symbol),count:int');
+    expect(references.slice(1).filter(Boolean).map(line => line.replace(/"[0-9a-f]+:service\./g, '"'))).toEqual([
+      '"Order.Save#7fac","Order",1',
+      '"Order.Save#7fac","Order.ID",1',
+      '"PlaceOrder#8ed2","Order",1'
+    ]);
+    expect(read('import.sh').slice(3)).toEqual([
+      'exec neo4j-admin database import full \\',
+      '  --nodes=Package=Package.csv \\',
+      '  --nodes=File=File.csv \\',
+      '  --nodes=Symbol=Symbol.csv \\',
+      '  --relationships=MEMBER_OF=Symbol-MEMBER_OF-Symbol.csv \\',
+      '  --relationships=REFERENCES=Symbol-REFERENCES-Symbol.csv \\',
+      '  "${1:-neo4j}"',
+      ''
+    ]);
+  });
+
+  it('should escape values, skip ambiguous references and reject unknown options', async () => {
+    index = new MockBackgroundIndex();
+    index.addFile('/ws/a.ts', [
+      createTestSymbol({ id: "it's", name: 'quote\\d "name"\n', kind: 'class', location: { uri: '/ws/a.ts', line: 0, character: 0 } })
+    ], [], { tags: ['generated', 'test'] });
+    for (const uri of ['/ws/b.ts', '/ws/c.ts']) {
+      index.addFile(uri, [createTestSymbol({ id: uri, name: 'Shared', location: { uri, line: 0, character: 0 } })]);
+    }
+    index.addFile('/ws/d.ts', [], [{
+      symbolName: 'Shared',
+      location: { uri: '/ws/d.ts', line: 2, character: 0 },
+      range: { startLine: 2, startCharacter: 0, endLine: 2, endCharacter: 6 }
+    }]);
+
+    const cypher = path.join(tempDir, 'graph.cypher');
+    const result = await createExporter().export(cypher);
+    expect([result.relationships.REFERENCES, result.unresolvedReferences]).toEqual([0, 1]);
+    const script = fs.readFileSync(cypher, 'utf-8');
+    expect(script).toContain("{id: 'a.ts', language: 'ts', tags: ['generated', 'test']}");
+    expect(script).toContain("{id: 'it\\'s', name: 'quote\\\\d \"name\"\\n'");
+
+    const csv = path.join(tempDir, 'csv');
+    await createExporter().export(csv, { format: 'csv' });
+    expect(fs.readFileSync(path.join(csv, 'File.csv'), 'utf-8')).toContain('"a.ts","ts","generated;test"');
+    expect(fs.readFileSync(path.join(csv, 'Symbol.csv'), 'utf-8')).toContain('"it\'s","quote\\d ""name""\n"');
+
+    await expect(createExporter().export(cypher, { format: 'graphml' as never }))
+      .rejects.toThrow('Unsupported graph format: graphml; use cypher, csv');
+    await expect(createExporter().export(cypher, { relationships: ['USES' as never] }))
+      .rejects.toThrow('Unknown relationship type: USES');
+  });
+});
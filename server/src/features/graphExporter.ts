@@ -0,0 +1,580 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedFileResult, IndexedSymbol } from '../types.js';
+import { CallGraph } from './callGraph.js';
+import { DependencyGraph, packageOfFile } from './dependencyGraph.js';
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export const GRAPH_EXPORT_FORMATS = ['cypher', 'csv'] as const;
+export type GraphExportFormat = typeof GRAPH_EXPORT_FORMATS[number];
+
+export const GRAPH_NODE_LABELS = ['Package', 'File', 'Symbol'] as const;
+export type GraphNodeLabel = typeof GRAPH_NODE_LABELS[number];
+
+export const GRAPH_RELATIONSHIP_TYPES = [
+  'IN_PACKAGE', 'DEFINED_IN', 'MEMBER_OF', 'CALLS', 'REFERENCES', 'IMPORTS', 'DEPENDS_ON'
+] as const;
+export type GraphRelationshipType = typeof GRAPH_RELATIONSHIP_TYPES[number];
+
+export interface GraphExportOptions {
+  /** Cypher script (default) or a folder of neo4j-admin import CSVs */
+  format?: GraphExportFormat;
+  /** Relationship types to write (default: all) */
+  relationships?: GraphRelationshipType[];
+  /** Cancellation token for aborting the export */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting export progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface GraphExportResult {
+  /** The Cypher script, or the CSV folder */
+  outputPath: string;
+  format: GraphExportFormat;
+  nodes: Record<GraphNodeLabel, number>;
+  relationships: Record<GraphRelationshipType, number>;
+  /** Non-call references whose definition could not be resolved unambiguously */
+  unresolvedReferences: number;
+  /** csv: shell script running `neo4j-admin database import` on the folder */
+  importScript?: string;
+}
+
+type GraphValue = string | number | boolean | string[] | undefined;
+type GraphRow = Record<string, GraphValue>;
+type NodeRef = [GraphNodeLabel, string];
+type CsvType = 'string' | 'int' | 'boolean' | 'string[]';
+
+/** Properties of each node label, `id` first; the CSV columns */
+const NODE_PROPERTIES: Record<GraphNodeLabel, Array<[string, CsvType]>> = {
+  Package: [['id', 'string'], ['importPath', 'string'], ['external', 'boolean']],
+  File: [['id', 'string'], ['language', 'string'], ['tags', 'string[]']],
+  Symbol: [
+    ['id', 'string'], ['name', 'string'], ['qualifiedName', 'string'], ['kind', 'string'], ['file', 'string'],
+    ['line', 'int'], ['character', 'int'], ['exported', 'boolean'], ['deprecated', 'boolean']
+  ]
+};
+
+const RELATIONSHIP_PROPERTIES: Record<GraphRelationshipType, Array<[string, CsvType]>> = {
+  IN_PACKAGE: [],
+  DEFINED_IN: [],
+  MEMBER_OF: [],
+  CALLS: [['count', 'int'], ['ambiguous', 'boolean']],
+  REFERENCES: [['count', 'int']],
+  IMPORTS: [],
+  DEPENDS_ON: [['files', 'int']]
+};
+
+const YIELD_INTERVAL = 50;
+/** Rows per UNWIND statement */
+const CYPHER_BATCH_SIZE = 500;
+
+/**
+ * Exports the symbol graph for Neo4j, for ad-hoc graph queries the
+ * editor has no view for (the shortest call path from a handler to a
+ * package, everything that transitively depends on a type):
+ *
+ *   (:File)-[:IN_PACKAGE]->(:Package)
+ *   (:Symbol)-[:DEFINED_IN]->(:File)
+ *   (:Symbol)-[:MEMBER_OF]->(:Symbol)
+ *   (:Symbol)-[:CALLS {count, ambiguous}]->(:Symbol)
+ *   (:Symbol|File)-[:REFERENCES {count}]->(:Symbol)
+ *   (:File)-[:IMPORTS]->(:Package)
+ *   (:Package)-[:DEPENDS_ON {files}]->(:Package)
+ *
+ * Calls come from the call graph and imports from the dependency graph,
+ * with their resolution rules. Other references are attributed to the
+ * innermost definition around them (the file at top level) and linked
+ * like containers are: same file, then (Go) same package, then a
+ * workspace-unique name; ambiguous names are counted, not guessed.
+ *
+ * Every node has an `id`: the package id, the workspace-relative file
+ * path or the symbol id. Lines and characters are 0-based.
+ *
+ * `cypher` writes a script of batched idempotent MERGE statements for
+ * `cypher-shell -f`; `csv` writes one file per label and relationship
+ * type plus an import.sh for `neo4j-admin database import full`, the
+ * fast path for an empty database.
+ */
+export class GraphExporter {
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private workspaceRoot: string
+  ) {}
+
+  /**
+   * Write the graph to outputPath: a file for cypher, a folder for csv.
+   */
+  async export(outputPath: string, options: GraphExportOptions = {}): Promise<GraphExportResult> {
+    const format = options.format ?? 'cypher';
+    if (!GRAPH_EXPORT_FORMATS.includes(format)) {
+      throw new Error(`Unsupported graph format: ${format}; use ${GRAPH_EXPORT_FORMATS.join(', ')}`);
+    }
+    const relationships = options.relationships && options.relationships.length > 0
+      ? options.relationships
+      : GRAPH_RELATIONSHIP_TYPES;
+    for (const type of relationships) {
+      if (!GRAPH_RELATIONSHIP_TYPES.includes(type)) {
+        throw new Error(`Unknown relationship type: ${type}; use ${GRAPH_RELATIONSHIP_TYPES.join(', ')}`);
+      }
+    }
+
+    const writer = format === 'csv'
+      ? await CsvGraphWriter.open(outputPath)
+      : await CypherGraphWriter.open(outputPath, this.workspaceRoot);
+    let result: GraphExportResult;
+    try {
+      result = { outputPath, format, ...await this.write(writer, new Set(relationships), options) };
+    } finally {
+      await writer.close();
+    }
+    if (writer instanceof CsvGraphWriter) {
+      result.importScript = writer.importScript;
+    }
+    return result;
+  }
+
+  private async write(
+    writer: GraphWriter,
+    types: Set<GraphRelationshipType>,
+    options: GraphExportOptions
+  ): Promise<Pick<GraphExportResult, 'nodes' | 'relationships' | 'unresolvedReferences'>> {
+    const { cancellationToken, onProgress } = options;
+    const nodes = Object.fromEntries(GRAPH_NODE_LABELS.map(label => [label, 0])) as Record<GraphNodeLabel, number>;
+    const relationships = Object.fromEntries(GRAPH_RELATIONSHIP_TYPES.map(type => [type, 0])) as Record<GraphRelationshipType, number>;
+    let unresolvedReferences = 0;
+
+    const node = (label: GraphNodeLabel, row: GraphRow): Promise<void> => {
+      nodes[label]++;
+      return writer.node(label, row);
+    };
+    const relationship = (type: GraphRelationshipType, from: NodeRef, to: NodeRef, row: GraphRow = {}): Promise<void> => {
+      if (!types.has(type)) {
+        return Promise.resolve();
+      }
+      relationships[type]++;
+      return writer.relationship(type, from, to, row);
+    };
+
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const totalSteps = files.length * 2;
+    const dependencies = await new DependencyGraph(this.backgroundIndex, this.workspaceRoot)
+      .build({ includeExternal: true, cancellationToken });
+    for (const pkg of dependencies.packages) {
+      await node('Package', { id: pkg.id, importPath: pkg.importPath, external: pkg.external ?? false });
+    }
+
+    // Pass 1: files and symbols. Keyed by id: shards may be reloaded between passes
+    const definitionsByName = new Map<string, Array<{ id: string; uri: string }>>();
+    const definedIn = new Map<string, string>();
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, totalSteps, `Writing symbols (${i}/${files.length})`);
+      }
+
+      const uri = files[i];
+      const fileResult = await this.backgroundIndex.getFileResult(uri);
+      if (!fileResult) {
+        continue;
+      }
+      await node('File', { id: this.fileId(uri), language: path.extname(uri).slice(1).toLowerCase(), tags: fileResult.tags });
+
+      for (const symbol of fileResult.symbols) {
+        if (symbol.isDefinition === false || definedIn.has(symbol.id)) {
+          continue;
+        }
+        definedIn.set(symbol.id, uri);
+        await node('Symbol', {
+          id: symbol.id,
+          name: symbol.name,
+          qualifiedName: qualifiedName(symbol),
+          kind: symbol.kind,
+          file: this.fileId(uri),
+          line: symbol.location.line,
+          character: symbol.location.character,
+          exported: symbol.isExported,
+          deprecated: symbol.deprecated !== undefined
+        });
+        if (symbol.kind === 'closure') {
+          // Synthesized names (`Greet$1`) are never referenced by name
+          continue;
+        }
+        let byName = definitionsByName.get(symbol.name);
+        if (!byName) {
+          byName = [];
+          definitionsByName.set(symbol.name, byName);
+        }
+        byName.push({ id: symbol.id, uri });
+      }
+    }
+
+    // Pass 2: containment and references, file by file
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(files.length + i, totalSteps, `Linking references (${i}/${files.length})`);
+      }
+
+      const uri = files[i];
+      const fileResult = await this.backgroundIndex.getFileResult(uri);
+      if (!fileResult) {
+        continue;
+      }
+      const file: NodeRef = ['File', this.fileId(uri)];
+      await relationship('IN_PACKAGE', file, ['Package', packageOfFile(uri, fileResult, this.workspaceRoot)]);
+
+      const definitions = fileResult.symbols.filter(s => s.isDefinition !== false && definedIn.get(s.id) === uri);
+      for (const symbol of definitions) {
+        await relationship('DEFINED_IN', ['Symbol', symbol.id], file);
+        const container = symbol.containerName ? resolveDefinition(definitionsByName.get(symbol.containerName), uri) : undefined;
+        if (container && container !== symbol.id) {
+          await relationship('MEMBER_OF', ['Symbol', symbol.id], ['Symbol', container]);
+        }
+      }
+
+      if (types.has('REFERENCES')) {
+        const counts = this.countReferences(uri, fileResult, definitions, definitionsByName);
+        unresolvedReferences += counts.unresolved;
+        for (const [from, targets] of counts.references) {
+          for (const [to, count] of targets) {
+            await relationship('REFERENCES', from === '' ? file : ['Symbol', from], ['Symbol', to], { count });
+          }
+        }
+      }
+    }
+
+    if (types.has('CALLS')) {
+      const callGraph = new CallGraph(this.backgroundIndex);
+      await callGraph.build({ cancellationToken });
+      for (const edge of callGraph.view().edges) {
+        await relationship('CALLS', ['Symbol', edge.caller], ['Symbol', edge.callee], {
+          count: edge.callSites.length,
+          ambiguous: edge.ambiguous ?? false
+        });
+      }
+    }
+
+    for (const edge of dependencies.edges) {
+      for (const uri of edge.files) {
+        await relationship('IMPORTS', ['File', this.fileId(uri)], ['Package', edge.to]);
+      }
+      await relationship('DEPENDS_ON', ['Package', edge.from], ['Package', edge.to], { files: edge.files.length });
+    }
+
+    onProgress?.(totalSteps, totalSteps, 'Graph export complete');
+    return { nodes, relationships, unresolvedReferences };
+  }
+
+  /**
+   * Non-call references of a file by the definition they are in ('' for
+   * the file itself) and the definition they resolve to.
+   */
+  private countReferences(
+    uri: string,
+    fileResult: IndexedFileResult,
+    definitions: IndexedSymbol[],
+    definitionsByName: Map<string, Array<{ id: string; uri: string }>>
+  ): { references: Map<string, Map<string, number>>; unresolved: number } {
+    const references = new Map<string, Map<string, number>>();
+    let unresolved = 0;
+    for (const reference of fileResult.references) {
+      if (reference.isCall || reference.isLocal || reference.isImport) {
+        continue;
+      }
+      const target = resolveDefinition(definitionsByName.get(reference.symbolName), uri);
+      if (!target) {
+        unresolved++;
+        continue;
+      }
+      const { line, character } = reference.location;
+      let innermost: IndexedSymbol | undefined;
+      for (const symbol of definitions) {
+        if (rangeContains(symbol, line, character) && (!innermost || compareStart(symbol, innermost) >= 0)) {
+          innermost = symbol;
+        }
+      }
+      const from = innermost?.id ?? '';
+      if (from === target) {
+        continue;
+      }
+
+      let targets = references.get(from);
+      if (!targets) {
+        targets = new Map();
+        references.set(from, targets);
+      }
+      targets.set(target, (targets.get(target) ?? 0) + 1);
+    }
+    return { references, unresolved };
+  }
+
+  private fileId(uri: string): string {
+    const relative = path.relative(this.workspaceRoot, uri);
+    const inside = !relative.startsWith('..') && !path.isAbsolute(relative);
+    return (inside ? relative : uri).split(path.sep).join('/');
+  }
+}
+
+interface GraphWriter {
+  node(label: GraphNodeLabel, row: GraphRow): Promise<void>;
+  relationship(type: GraphRelationshipType, from: NodeRef, to: NodeRef, row: GraphRow): Promise<void>;
+  close(): Promise<void>;
+}
+
+/**
+ * `UNWIND [...] AS row MERGE ...` statements of up to CYPHER_BATCH_SIZE
+ * rows, nodes before the relationships that match them.
+ */
+class CypherGraphWriter implements GraphWriter {
+  private nodes = new Map<GraphNodeLabel, GraphRow[]>();
+  private relationships = new Map<string, { type: GraphRelationshipType; from: GraphNodeLabel; to: GraphNodeLabel; rows: string[] }>();
+
+  private constructor(private stream: fs.WriteStream) {}
+
+  static async open(outputPath: string, workspaceRoot: string): Promise<CypherGraphWriter> {
+    await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+    const writer = new CypherGraphWriter(fs.createWriteStream(outputPath, { encoding: 'utf-8' }));
+    await writer.write([
+      `// Symbol graph of ${workspaceRoot.replace(/[\r\n]/g, ' ')}, written by smart-indexer`,
+      `// Load it with: cypher-shell -f ${path.basename(outputPath).replace(/[\r\n]/g, ' ')}`,
+      ...GRAPH_NODE_LABELS.map(label =>
+        `CREATE CONSTRAINT ${label.toLowerCase()}_id IF NOT EXISTS FOR (n:${label}) REQUIRE n.id IS UNIQUE;`),
+      ''
+    ].join('\n'));
+    return writer;
+  }
+
+  async node(label: GraphNodeLabel, row: GraphRow): Promise<void> {
+    let rows = this.nodes.get(label);
+    if (!rows) {
+      rows = [];
+      this.nodes.set(label, rows);
+    }
+    rows.push(row);
+    if (rows.length >= CYPHER_BATCH_SIZE) {
+      await this.flushNodes(label);
+    }
+  }
+
+  async relationship(type: GraphRelationshipType, from: NodeRef, to: NodeRef, row: GraphRow): Promise<void> {
+    // Relationships MATCH their nodes, which must be written first
+    for (const label of [...this.nodes.keys()]) {
+      await this.flushNodes(label);
+    }
+
+    const key = `${from[0]}\0${type}\0${to[0]}`;
+    let batch = this.relationships.get(key);
+    if (!batch) {
+      batch = { type, from: from[0], to: to[0], rows: [] };
+      this.relationships.set(key, batch);
+    }
+    const properties = RELATIONSHIP_PROPERTIES[type].length > 0 ? `, properties: ${cypherMap(row)}` : '';
+    batch.rows.push(`{from: ${cypherValue(from[1])}, to: ${cypherValue(to[1])}${properties}}`);
+    if (batch.rows.length >= CYPHER_BATCH_SIZE) {
+      await this.flushRelationships(key);
+    }
+  }
+
+  async close(): Promise<void> {
+    try {
+      for (const label of [...this.nodes.keys()]) {
+        await this.flushNodes(label);
+      }
+      for (const key of [...this.relationships.keys()]) {
+        await this.flushRelationships(key);
+      }
+    } finally {
+      await new Promise<void>((resolve, reject) => {
+        this.stream.once('error', reject);
+        this.stream.end(() => resolve());
+      });
+    }
+  }
+
+  private async flushNodes(label: GraphNodeLabel): Promise<void> {
+    const rows = this.nodes.get(label)!;
+    this.nodes.delete(label);
+    await this.write(
+      `UNWIND [\n  ${rows.map(cypherMap).join(',\n  ')}\n] AS row\n` +
+      `MERGE (n:${label} {id: row.id}) SET n += row;\n`
+    );
+  }
+
+  private async flushRelationships(key: string): Promise<void> {
+    const { type, from, to, rows } = this.relationships.get(key)!;
+    this.relationships.delete(key);
+    const merge = RELATIONSHIP_PROPERTIES[type].length > 0
+      ? `MERGE (a)-[r:${type}]->(b) SET r += row.properties;`
+      : `MERGE (a)-[:${type}]->(b);`;
+    await this.write(
+      `UNWIND [\n  ${rows.join(',\n  ')}\n] AS row\n` +
+      `MATCH (a:${from} {id: row.from}), (b:${to} {id: row.to})\n${merge}\n`
+    );
+  }
+
+  private async write(text: string): Promise<void> {
+    if (!this.stream.write(text)) {
+      await new Promise<void>(resolve => this.stream.once('drain', resolve));
+    }
+  }
+}
+
+/**
+ * One CSV per node label (`Symbol.csv`) and per relationship type and
+ * endpoint labels (`Symbol-CALLS-Symbol.csv`), with neo4j-admin headers
+ * (`id:ID(Symbol)`, `:START_ID(Symbol)`, `line:int`).
+ */
+class CsvGraphWriter implements GraphWriter {
+  private files = new Map<string, fs.WriteStream>();
+  private nodeFiles: Array<[GraphNodeLabel, string]> = [];
+  private relationshipFiles: Array<[GraphRelationshipType, string]> = [];
+  importScript?: string;
+
+  private constructor(private dir: string) {}
+
+  static async open(dir: string): Promise<CsvGraphWriter> {
+    await fsPromises.mkdir(dir, { recursive: true });
+    return new CsvGraphWriter(dir);
+  }
+
+  node(label: GraphNodeLabel, row: GraphRow): Promise<void> {
+    const columns = NODE_PROPERTIES[label];
+    return this.write(`${label}.csv`, () => {
+      this.nodeFiles.push([label, `${label}.csv`]);
+      return columns.map(([name, type], i) => i === 0 ? `${name}:ID(${label})` : csvHeader(name, type));
+    }, columns.map(([name, type]) => csvCell(row[name], type)));
+  }
+
+  relationship(type: GraphRelationshipType, from: NodeRef, to: NodeRef, row: GraphRow): Promise<void> {
+    const columns = RELATIONSHIP_PROPERTIES[type];
+    const file = `${from[0]}-${type}-${to[0]}.csv`;
+    return this.write(file, () => {
+      this.relationshipFiles.push([type, file]);
+      return [`:START_ID(${from[0]})`, `:END_ID(${to[0]})`, ...columns.map(([name, t]) => csvHeader(name, t))];
+    }, [csvCell(from[1], 'string'), csvCell(to[1], 'string'), ...columns.map(([name, t]) => csvCell(row[name], t))]);
+  }
+
+  async close(): Promise<void> {
+    await Promise.all([...this.files.values()].map(stream => new Promise<void>((resolve, reject) => {
+      stream.once('error', reject);
+      stream.end(() => resolve());
+    })));
+    this.importScript = path.join(this.dir, 'import.sh');
+    await fsPromises.writeFile(this.importScript, [
+      '#!/bin/sh',
+      '# Imports the symbol graph into a new, stopped database: sh import.sh [database]',
+      'cd "$(dirname "$0")" || exit 1',
+      'exec neo4j-admin database import full \\',
+      ...this.nodeFiles.map(([label, file]) => `  --nodes=${label}=${file} \\`),
+      ...this.relationshipFiles.map(([type, file]) => `  --relationships=${type}=${file} \\`),
+      '  "${1:-neo4j}"',
+      ''
+    ].join('\n'), { mode: 0o755 });
+  }
+
+  private async write(file: string, header: () => string[], cells: string[]): Promise<void> {
+    let stream = this.files.get(file);
+    if (!stream) {
+      stream = fs.createWriteStream(path.join(this.dir, file), { encoding: 'utf-8' });
+      this.files.set(file, stream);
+      stream.write(header().join(',') + '\n');
+    }
+    if (!stream.write(cells.join(',') + '\n')) {
+      await new Promise<void>(resolve => stream!.once('drain', resolve));
+    }
+  }
+}
+
+/**
+ * The definition a name resolves to from a file: one in the same file,
+ * then (Go) the only one in the same package, then the only one anywhere.
+ */
+function resolveDefinition(candidates: Array<{ id: string; uri: string }> | undefined, uri: string): string | undefined {
+  if (!candidates || candidates.length === 0) {
+    return undefined;
+  }
+  const local = candidates.find(c => c.uri === uri);
+  if (local) {
+    return local.id;
+  }
+  if (path.extname(uri) === '.go') {
+    const dir = path.dirname(uri);
+    const samePackage = candidates.filter(c => path.dirname(c.uri) === dir);
+    if (samePackage.length > 0) {
+      return samePackage.length === 1 ? samePackage[0].id : undefined;
+    }
+  }
+  return candidates.length === 1 ? candidates[0].id : undefined;
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.fullContainerPath
+    ? `${symbol.fullContainerPath}.${symbol.name}`
+    : symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+function rangeContains(symbol: IndexedSymbol, line: number, character: number): boolean {
+  const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+  if (line < startLine || line > endLine) {
+    return false;
+  }
+  if (line === startLine && character < startCharacter) {
+    return false;
+  }
+  if (line === endLine && character > endCharacter) {
+    return false;
+  }
+  return true;
+}
+
+function compareStart(a: IndexedSymbol, b: IndexedSymbol): number {
+  return a.range.startLine - b.range.startLine || a.range.startCharacter - b.range.startCharacter;
+}
+
+function cypherMap(row: GraphRow): string {
+  const entries = Object.entries(row).filter(([, value]) => value !== undefined);
+  return `{${entries.map(([key, value]) => `${key}: ${cypherValue(value!)}`).join(', ')}}`;
+}
+
+function cypherValue(value: string | number | boolean | string[]): string {
+  if (Array.isArray(value)) {
+    return `[${value.map(cypherValue).join(', ')}]`;
+  }
+  if (typeof value === 'string') {
+    return `'${value.replace(/[\\'\u0000-\u001f]/g, c => {
+      switch (c) {
+        case '\\': return '\\\\';
+        case '\'': return '\\\'';
+        case '\n': return '\\n';
+        case '\r': return '\\r';
+        case '\t': return '\\t';
+        default: return `\\u${c.charCodeAt(0).toString(16).padStart(4, '0')}`;
+      }
+    })}'`;
+  }
+  return String(value);
+}
+
+function csvHeader(name: string, type: CsvType): string {
+  return type === 'string' ? name : `${name}:${type}`;
+}
+
+function csvCell(value: GraphValue, type: CsvType): string {
+  if (value === undefined) {
+    return '';
+  }
+  const text = Array.isArray(value) ? value.join(';') : String(value);
+  return type === 'string' || type === 'string[]'
+    ? `"${text.replace(/"/g, '""')}"`
+    : text;
+}
@@ -0,0 +1,103 @@
+/**
+ * Index GraphQL Tests
+ *
+ * Verifies the index schema: symbol search and definitions, references
+ * with the functions enclosing them and the owners of their files, file
+ * outlines and the printed SDL.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { IndexGraphQL } from './graphqlSchema.js';
+import { MockIndex, createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+
+const storeUri = '/ws/store/store.go';
+const apiUri = '/ws/api/api.go';
+
+describe('IndexGraphQL', () => {
+  let index: MockIndex;
+  let graphql: IndexGraphQL;
+
+  beforeEach(() => {
+    index = new MockIndex();
+    index.addSymbol(createTestSymbol({
+      id: 'store', name: 'Store', kind: 'struct', filePath: storeUri, isExported: true,
+      location: { uri: storeUri, line: 2, character: 5 },
+      range: { startLine: 2, startCharacter: 0, endLine: 2, endCharacter: 40 }
+    }));
+    index.addSymbol(createTestSymbol({
+      id: 'get', name: 'Get', kind: 'method', containerName: 'Store', filePath: storeUri, signature: 'func (s *Store) Get(key string) string',
+      location: { uri: storeUri, line: 4, character: 16 },
+      range: { startLine: 4, startCharacter: 0, endLine: 6, endCharacter: 1 }
+    }));
+    index.addSymbol(createTestSymbol({
+      id: 'lookup', name: 'Lookup', kind: 'function', filePath: apiUri,
+      location: { uri: apiUri, line: 4, character: 5 },
+      range: { startLine: 4, startCharacter: 0, endLine: 7, endCharacter: 1 }
+    }));
+    index.addReference('Get', createTestReference({
+      symbolName: 'Get',
+      location: { uri: apiUri, line: 5, character: 11 },
+      range: { startLine: 5, startCharacter: 11, endLine: 5, endCharacter: 14 },
+      isCall: true
+    }));
+    index.addReference('Get', createTestReference({
+      symbolName: 'Get',
+      location: { uri: '/ws/main.go', line: 0, character: 8 },
+      range: { startLine: 0, startCharacter: 8, endLine: 0, endCharacter: 11 }
+    }));
+    graphql = new IndexGraphQL(index, filePath => filePath.startsWith('/ws/api/') ? ['@api-team'] : []);
+  });
+
+  it('should return a symbol, its callers, their enclosing functions and owners in one query', async () => {
+    const result = await graphql.execute({
+      query: `query Callers($name: String!) {
+        definitions(name: $name) {
+          qualifiedName signature owners
+          references(calls: true) { isCall location { uri line } enclosing { name kind owners } }
+        }
+      }`,
+      variables: { name: 'Get' }
+    });
+
+    expect(result).toEqual({
+      data: {
+        definitions: [{
+          qualifiedName: 'Store.Get',
+          signature: 'func (s *Store) Get(key string) string',
+          owners: [],
+          references: [{
+            isCall: true,
+            location: { uri: apiUri, line: 5 },
+            enclosing: { name: 'Lookup', kind: 'function', owners: ['@api-team'] }
+          }]
+        }]
+      }
+    });
+  });
+
+  it('should search symbols by kind and list the symbols of a file', async () => {
+    const result = await graphql.execute({
+      query: `{
+        symbols(query: "Sto", kind: "struct") { name exported members { name } file { uri } }
+        file(uri: "${apiUri}") { owners symbols { name } }
+        symbol(id: "get") { name references { enclosing { name } } }
+      }`
+    });
+
+    expect(result.data).toEqual({
+      symbols: [{ name: 'Store', exported: true, members: [{ name: 'Get' }], file: { uri: storeUri } }],
+      file: { owners: ['@api-team'], symbols: [{ name: 'Lookup' }] },
+      symbol: { name: 'Get', references: [{ enclosing: { name: 'Lookup' } }, { enclosing: null }] }
+    });
+  });
+
+  it('should report unknown fields and print the schema', async () => {
+    expect((await graphql.execute({ query: '{ symbols(query: "x") { nope } }' })).errors).toEqual([
+      { message: 'Cannot query field "nope" on type "Symbol"', locations: [{ line: 1, column: 25 }] }
+    ]);
+    const sdl = graphql.schema();
+    expect(sdl).toContain('symbols(query: String!, limit: Int = 50, kind: [String!]): [Symbol!]!');
+    expect(sdl).toContain('type Reference {');
+    expect(sdl).toContain('  enclosing: Symbol\n');
+  });
+});
@@ -0,0 +1,240 @@
+import { IndexedReference, IndexedSymbol, SymbolRange } from '../types.js';
+import { executeGraphQL, GraphQLRequest, GraphQLResult, GraphQLSchema, printSchema } from '../utils/graphql.js';
+import { normalizeKind } from '../utils/symbolKind.js';
+
+/** The part of an index GraphQL queries read: the background index, a merged index or the library Indexer */
+export interface GraphQLSourceIndex {
+  findDefinitions(name: string): Promise<IndexedSymbol[]>;
+  findDefinitionById(symbolId: string): Promise<IndexedSymbol | null>;
+  searchSymbols(query: string, limit: number): Promise<IndexedSymbol[]>;
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+  findReferencesByName?(name: string): Promise<IndexedReference[]>;
+}
+
+/** CODEOWNERS owners of a file */
+export type GraphQLOwnersOf = (filePath: string) => string[];
+
+const DEFAULT_LIMIT = 50;
+const MAX_LIMIT = 1000;
+const ENCLOSING_KINDS = new Set(['function', 'method', 'constructor', 'closure']);
+
+/** What resolvers share during one request: the index, and file symbols read so far */
+class RequestContext {
+  private files = new Map<string, Promise<IndexedSymbol[]>>();
+
+  constructor(readonly index: GraphQLSourceIndex, readonly ownersOf: GraphQLOwnersOf) {}
+
+  /** Symbols of a file, read once per request however many references land in it */
+  fileSymbols(uri: string): Promise<IndexedSymbol[]> {
+    let symbols = this.files.get(uri);
+    if (!symbols) {
+      symbols = this.index.getFileSymbols(uri);
+      this.files.set(uri, symbols);
+    }
+    return symbols;
+  }
+
+  async references(name: string, limit: number | undefined, calls: boolean | undefined): Promise<IndexedReference[]> {
+    if (!this.index.findReferencesByName) {
+      throw new Error('The index has no reference lookup');
+    }
+    const references = (await this.index.findReferencesByName(name)).filter(ref => !calls || ref.isCall);
+    return references.slice(0, clampLimit(limit));
+  }
+}
+
+const SCHEMA: GraphQLSchema<RequestContext> = {
+  query: 'Query',
+  types: {
+    Query: {
+      fields: {
+        symbols: {
+          type: '[Symbol!]!',
+          description: 'Fuzzy symbol search by name',
+          args: { query: { type: 'String!' }, limit: { type: 'Int', defaultValue: DEFAULT_LIMIT }, kind: { type: '[String!]' } },
+          resolve: async (_parent, args: { query: string; limit?: number; kind?: string[] }, context) => {
+            const limit = clampLimit(args.limit);
+            if (!args.kind || args.kind.length === 0) {
+              return context.index.searchSymbols(args.query, limit);
+            }
+            // Over-fetch so the kind filter does not starve the result
+            const kinds = new Set(args.kind.map(normalizeKind));
+            const symbols = await context.index.searchSymbols(args.query, MAX_LIMIT);
+            return symbols.filter(symbol => kinds.has(normalizeKind(symbol.kind))).slice(0, limit);
+          }
+        },
+        definitions: {
+          type: '[Symbol!]!',
+          description: 'Definitions of a name, e.g. Greet',
+          args: { name: { type: 'String!' } },
+          resolve: (_parent, args: { name: string }, context) => context.index.findDefinitions(args.name)
+        },
+        symbol: {
+          type: 'Symbol',
+          description: 'A symbol by its id',
+          args: { id: { type: 'ID!' } },
+          resolve: (_parent, args: { id: string }, context) => context.index.findDefinitionById(args.id)
+        },
+        references: {
+          type: '[Reference!]!',
+          description: 'References to a name',
+          args: { name: { type: 'String!' }, limit: { type: 'Int', defaultValue: DEFAULT_LIMIT }, calls: { type: 'Boolean', defaultValue: false } },
+          resolve: (_parent, args: { name: string; limit?: number; calls?: boolean }, context) =>
+            context.references(args.name, args.limit, args.calls)
+        },
+        file: {
+          type: 'File',
+          description: 'An indexed file by path',
+          args: { uri: { type: 'String!' } },
+          resolve: (_parent, args: { uri: string }) => ({ uri: args.uri })
+        }
+      }
+    },
+    Symbol: {
+      description: 'A definition in the index',
+      fields: {
+        id: { type: 'ID!' },
+        canonicalId: { type: 'String' },
+        name: { type: 'String!' },
+        kind: { type: 'String!' },
+        containerName: { type: 'String' },
+        qualifiedName: {
+          type: 'String!',
+          description: 'Container and name, e.g. Person.Greet',
+          resolve: (symbol: IndexedSymbol) => qualifiedName(symbol)
+        },
+        signature: { type: 'String' },
+        doc: { type: 'String', description: 'Raw doc comment' },
+        exported: { type: 'Boolean', resolve: (symbol: IndexedSymbol) => symbol.isExported ?? null },
+        deprecated: { type: 'String', description: 'Deprecation notice; empty when it gives no reason, null when not deprecated' },
+        tags: { type: '[String!]!', resolve: (symbol: IndexedSymbol) => symbol.tags ?? [] },
+        location: { type: 'Location!' },
+        range: { type: 'Range!' },
+        file: { type: 'File!', resolve: (symbol: IndexedSymbol) => ({ uri: symbol.location.uri }) },
+        owners: {
+          type: '[String!]!',
+          description: 'CODEOWNERS owners of the file',
+          resolve: (symbol: IndexedSymbol, _args, context) => context.ownersOf(symbol.location.uri)
+        },
+        references: {
+          type: '[Reference!]!',
+          description: 'References to the symbol name; calls: only call sites',
+          args: { limit: { type: 'Int', defaultValue: DEFAULT_LIMIT }, calls: { type: 'Boolean', defaultValue: false } },
+          resolve: (symbol: IndexedSymbol, args: { limit?: number; calls?: boolean }, context) =>
+            context.references(symbol.name, args.limit, args.calls)
+        },
+        members: {
+          type: '[Symbol!]!',
+          description: 'Definitions declared in the symbol, in the same file',
+          resolve: async (symbol: IndexedSymbol, _args, context) => (await context.fileSymbols(symbol.location.uri))
+            .filter(member => member.isDefinition !== false && member.containerName === symbol.name && member.id !== symbol.id)
+        }
+      }
+    },
+    Reference: {
+      description: 'A use of a name',
+      fields: {
+        name: { type: 'String!', resolve: (reference: IndexedReference) => reference.symbolName },
+        containerName: { type: 'String' },
+        isCall: { type: 'Boolean!', resolve: (reference: IndexedReference) => reference.isCall === true },
+        isImport: { type: 'Boolean!', resolve: (reference: IndexedReference) => reference.isImport === true },
+        location: { type: 'Location!' },
+        range: { type: 'Range!' },
+        file: { type: 'File!', resolve: (reference: IndexedReference) => ({ uri: reference.location.uri }) },
+        enclosing: {
+          type: 'Symbol',
+          description: 'The innermost function, method or closure containing the reference',
+          resolve: async (reference: IndexedReference, _args, context) => {
+            const { startLine, startCharacter } = reference.range;
+            const enclosing = (await context.fileSymbols(reference.location.uri))
+              .filter(symbol => symbol.isDefinition !== false && ENCLOSING_KINDS.has(symbol.kind) &&
+                contains(symbol.range, startLine, startCharacter))
+              .sort((a, b) => (a.range.endLine - a.range.startLine) - (b.range.endLine - b.range.startLine));
+            return enclosing[0] ?? null;
+          }
+        }
+      }
+    },
+    File: {
+      description: 'An indexed file',
+      fields: {
+        uri: { type: 'String!' },
+        symbols: {
+          type: '[Symbol!]!',
+          description: 'Definitions in the file',
+          args: { kind: { type: '[String!]' } },
+          resolve: async (file: { uri: string }, args: { kind?: string[] }, context) => {
+            const kinds = args.kind && args.kind.length > 0 ? new Set(args.kind.map(normalizeKind)) : undefined;
+            return (await context.fileSymbols(file.uri))
+              .filter(symbol => symbol.isDefinition !== false && (!kinds || kinds.has(normalizeKind(symbol.kind))));
+          }
+        },
+        owners: {
+          type: '[String!]!',
+          description: 'CODEOWNERS owners of the file',
+          resolve: (file: { uri: string }, _args, context) => context.ownersOf(file.uri)
+        }
+      }
+    },
+    Location: {
+      description: '0-based position',
+      fields: {
+        uri: { type: 'String!' },
+        line: { type: 'Int!' },
+        character: { type: 'Int!' }
+      }
+    },
+    Range: {
+      description: '0-based range; offsets are UTF-8 bytes when indexed',
+      fields: {
+        startLine: { type: 'Int!' },
+        startCharacter: { type: 'Int!' },
+        endLine: { type: 'Int!' },
+        endCharacter: { type: 'Int!' },
+        startOffset: { type: 'Int' },
+        endOffset: { type: 'Int' }
+      }
+    }
+  }
+};
+
+/**
+ * Index GraphQL - the index as a GraphQL schema, so clients fetch the
+ * nested data they need in one request instead of stitching REST calls:
+ * a symbol, its references, the functions they are in and who owns
+ * those (see utils/graphql.ts for what the executor supports).
+ *
+ * Symbols, references and files are read lazily, only for the fields a
+ * query selects; file symbols are read once per request however many
+ * references resolve their `enclosing` function in the same file.
+ * Searches and reference lists take a `limit` (50 by default, at most
+ * 1000).
+ */
+export class IndexGraphQL {
+  constructor(private index: GraphQLSourceIndex, private ownersOf: GraphQLOwnersOf = () => []) {}
+
+  execute(request: GraphQLRequest): Promise<GraphQLResult> {
+    return executeGraphQL(SCHEMA, request, new RequestContext(this.index, this.ownersOf));
+  }
+
+  /** The schema in SDL, for clients and code generators */
+  schema(): string {
+    return printSchema(SCHEMA);
+  }
+}
+
+function clampLimit(limit: number | undefined): number {
+  return Math.max(0, Math.min(limit ?? DEFAULT_LIMIT, MAX_LIMIT));
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+function contains(range: SymbolRange, line: number, character: number): boolean {
+  if (line < range.startLine || line > range.endLine) {
+    return false;
+  }
+  return (line !== range.startLine || character >= range.startCharacter) &&
+    (line !== range.endLine || character <= range.endCharacter);
+}
@@ -0,0 +1,194 @@
+/**
+ * GrpcListener Tests
+ *
+ * Verifies the gRPC side of the query server over a real HTTP/2
+ * connection: request decoding, message framing, status codes and the
+ * next-cursor trailer.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as http2 from 'http2';
+import { QueryServer } from './queryServer.js';
+import { QueryServerAuth } from './queryServerAuth.js';
+import { MockIndex, createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+import { IndexingProgress } from '../utils/indexingProgress.js';
+import { ProtoReader, ProtoWriter, WIRE_FIXED64, WIRE_LENGTH_DELIMITED, WIRE_VARINT } from '../utils/protoWire.js';
+
+const uri = '/ws/src/user.go';
+const source = 'UserService{}.Run()\n';
+
+interface Reply {
+  headers: http2.IncomingHttpHeaders;
+  messages: Uint8Array[];
+  trailers: http2.IncomingHttpHeaders;
+}
+
+/** Fields of a message by number; strings and messages stay bytes */
+function fields(bytes: Uint8Array): Map<number, Array<number | Uint8Array>> {
+  const reader = new ProtoReader(bytes);
+  const result = new Map<number, Array<number | Uint8Array>>();
+  while (reader.hasMore()) {
+    const { field, wireType } = reader.tag();
+    const value = wireType === WIRE_VARINT ? reader.varint()
+      : wireType === WIRE_FIXED64 ? reader.double()
+        : wireType === WIRE_LENGTH_DELIMITED ? reader.bytes() : (reader.skip(wireType), 0);
+    result.set(field, [...(result.get(field) ?? []), value]);
+  }
+  return result;
+}
+
+function text(value: number | Uint8Array | undefined): string | undefined {
+  return value instanceof Uint8Array ? Buffer.from(value).toString('utf-8') : undefined;
+}
+
+describe('GrpcListener', () => {
+  let index: MockIndex;
+  let server: QueryServer;
+  let progress: IndexingProgress;
+  let client: http2.ClientHttp2Session;
+
+  beforeEach(() => {
+    index = new MockIndex();
+    for (const [i, name] of ['UserService', 'UserStore', 'UserCache'].entries()) {
+      index.addSymbol(createTestSymbol({
+        id: name, name, kind: 'struct', filePath: uri,
+        location: { uri, line: 2 + i, character: 5 },
+        range: { startLine: 2 + i, startCharacter: 0, endLine: 2 + i, endCharacter: 20 },
+        tags: i === 0 ? ['generated'] : undefined
+      }));
+    }
+    index.addReference('UserService', createTestReference({
+      symbolName: 'UserService',
+      location: { uri, line: 4, character: 8 },
+      range: { startLine: 4, startCharacter: 8, endLine: 4, endCharacter: 19 }
+    }));
+    progress = {
+      phase: 'indexing', filesScanned: 10, filesChecked: 10, filesTotal: 4, filesParsed: 2, filesIndexed: 2,
+      bytesProcessed: 0, filesPerSecond: 2.5, bytesPerSecond: 0, elapsedMs: 800
+    };
+    server = new QueryServer(index, undefined, async () => source, undefined, undefined, () => progress);
+  });
+
+  afterEach(async () => {
+    client?.close();
+    await server.stop();
+  });
+
+  async function connect(options: Parameters<QueryServer['startGrpc']>[2] = {}): Promise<void> {
+    const address = await server.startGrpc(0, '127.0.0.1', options);
+    client = http2.connect(`http://127.0.0.1:${address.port}`);
+  }
+
+  function call(method: string, request: ProtoWriter, metadata: Record<string, string> = {}): Promise<Reply> {
+    return new Promise((resolve, reject) => {
+      const message = request.finish();
+      const frame = Buffer.alloc(5 + message.length);
+      frame.writeUInt32BE(message.length, 1);
+      frame.set(message, 5);
+
+      const stream = client.request({
+        ':method': 'POST',
+        ':path': `/smartindexer.v1.SmartIndexer/${method}`,
+        'content-type': 'application/grpc',
+        te: 'trailers',
+        ...metadata
+      });
+      const reply: Reply = { headers: {}, messages: [], trailers: {} };
+      const chunks: Buffer[] = [];
+      stream.on('response', headers => { reply.headers = headers; });
+      stream.on('trailers', trailers => { reply.trailers = trailers; });
+      stream.on('data', (chunk: Buffer) => chunks.push(chunk));
+      stream.on('end', () => {
+        const body = Buffer.concat(chunks);
+        for (let at = 0; at < body.length; at += 5 + body.readUInt32BE(at + 1)) {
+          reply.messages.push(body.subarray(at + 5, at + 5 + body.readUInt32BE(at + 1)));
+        }
+        resolve(reply);
+      });
+      stream.on('error', reject);
+      stream.end(frame);
+    });
+  }
+
+  it('should stream SearchSymbols results and send the next cursor as trailing metadata', async () => {
+    await connect();
+    const first = await call('SearchSymbols', new ProtoWriter().string(1, 'User').uint(2, 2));
+    expect(first.headers['content-type']).toBe('application/grpc+proto');
+    expect(first.trailers['grpc-status']).toBe('0');
+    expect(first.messages).toHaveLength(2);
+    const cursor = first.trailers['x-next-cursor'] as string;
+    expect(typeof cursor).toBe('string');
+
+    const symbol = fields(first.messages[0]);
+    expect(text(symbol.get(2)?.[0])).toBe('struct');
+    const location = fields(symbol.get(5)![0] as Uint8Array);
+    expect(text(location.get(1)?.[0])).toBe(uri);
+    expect(location.get(3)).toEqual([5]);
+
+    const next = await call('SearchSymbols', new ProtoWriter().string(1, 'User').uint(2, 2).string(11, cursor));
+    expect(next.trailers['grpc-status']).toBe('0');
+    expect(next.trailers['x-next-cursor']).toBeUndefined();
+    const names = [...first.messages, ...next.messages].map(message => text(fields(message).get(1)?.[0]));
+    expect(names.sort()).toEqual(['UserCache', 'UserService', 'UserStore']);
+
+    // Filters map to the query parameters of /stream/symbols
+    const filtered = await call('SearchSymbols', new ProtoWriter().string(1, 'User').string(4, 'generated'));
+    expect(filtered.messages.map(message => text(fields(message).get(1)?.[0])).sort()).toEqual(['UserCache', 'UserStore']);
+  });
+
+  it('should answer FindReferences at line 0, character 0 and the unary RPCs', async () => {
+    await connect();
+    // Line 0, character 0: only the uri is on the wire
+    const position = new ProtoWriter().string(1, uri);
+    const references = await call('FindReferences', new ProtoWriter().message(2, position));
+    expect(references.trailers['grpc-status']).toBe('0');
+    expect(references.messages).toHaveLength(1);
+    const reference = fields(references.messages[0]);
+    expect(text(reference.get(1)?.[0])).toBe('UserService');
+    expect(fields(reference.get(6)![0] as Uint8Array).get(2)).toEqual([8]);
+
+    const definitions = await call('FindDefinitions', new ProtoWriter().string(1, 'UserStore'));
+    expect(definitions.messages).toHaveLength(1);
+    const response = fields(definitions.messages[0]);
+    expect(text(response.get(1)?.[0])).toBe('UserStore');
+    expect(response.get(2)).toHaveLength(1);
+
+    const outline = await call('GetOutline', new ProtoWriter().string(1, uri));
+    expect(fields(outline.messages[0]).get(2)).toHaveLength(3);
+    expect(outline.trailers['grpc-status']).toBe('0');
+  });
+
+  it('should stream progress with doubles and optional fields', async () => {
+    await connect();
+    progress = { ...progress, phase: 'idle', bytesTotal: 0 };
+    const reply = await call('StreamProgress', new ProtoWriter());
+    const event = fields(reply.messages[0]);
+    expect(text(event.get(1)?.[0])).toBe('idle');
+    expect(event.get(9)).toEqual([2.5]);
+    // Set to 0 is not the same as unknown
+    expect(event.get(8)).toEqual([0]);
+    expect(event.get(11)).toBeUndefined();
+    expect(event.get(12)).toEqual([800]);
+  });
+
+  it('should map errors to gRPC status codes in Trailers-Only responses', async () => {
+    await connect();
+    const missing = await call('SearchSymbols', new ProtoWriter());
+    expect(missing.headers['grpc-status']).toBe('3');
+    expect(decodeURIComponent(missing.headers['grpc-message'] as string)).toBe('Missing query parameter "q"');
+    expect(missing.messages).toEqual([]);
+
+    const unknown = await call('Rename', new ProtoWriter());
+    expect(unknown.headers['grpc-status']).toBe('12');
+  });
+
+  it('should check bearer tokens in the authorization metadata', async () => {
+    await connect({ auth: new QueryServerAuth([{ name: 'ci', token: 's3cret' }]) });
+    const denied = await call('GetOutline', new ProtoWriter().string(1, uri));
+    expect(denied.headers['grpc-status']).toBe('16');
+
+    const allowed = await call('GetOutline', new ProtoWriter().string(1, uri), { authorization: 'Bearer s3cret' });
+    expect(allowed.trailers['grpc-status']).toBe('0');
+    expect(fields(allowed.messages[0]).get(2)).toHaveLength(3);
+  });
+});
@@ -0,0 +1,489 @@
+import * as http2 from 'http2';
+import { AddressInfo } from 'net';
+import { ILogger } from '../utils/Logger.js';
+import { IndexingProgress } from '../utils/indexingProgress.js';
+import { ProtoReader, ProtoWriter, WIRE_LENGTH_DELIMITED, WIRE_VARINT } from '../utils/protoWire.js';
+import type { QueryResponse } from './queryServer.js';
+import { authenticate, QueryHandler, QueryListenOptions } from './queryListener.js';
+
+/** Service of server/proto/smart_indexer.proto */
+const SERVICE = 'smartindexer.v1.SmartIndexer';
+const GRPC_CONTENT_TYPE = 'application/grpc+proto';
+/** Largest request message accepted, gRPC's default */
+const MAX_MESSAGE_BYTES = 4 * 1024 * 1024;
+
+/** gRPC status codes */
+const GrpcStatus = {
+  OK: 0,
+  UNKNOWN: 2,
+  INVALID_ARGUMENT: 3,
+  NOT_FOUND: 5,
+  PERMISSION_DENIED: 7,
+  RESOURCE_EXHAUSTED: 8,
+  UNIMPLEMENTED: 12,
+  INTERNAL: 13,
+  UNAVAILABLE: 14,
+  UNAUTHENTICATED: 16
+} as const;
+
+/** gRPC status of the HTTP statuses the query server answers with */
+const STATUS_OF_HTTP: Record<number, number> = {
+  400: GrpcStatus.INVALID_ARGUMENT,
+  401: GrpcStatus.UNAUTHENTICATED,
+  403: GrpcStatus.PERMISSION_DENIED,
+  404: GrpcStatus.NOT_FOUND,
+  413: GrpcStatus.RESOURCE_EXHAUSTED,
+  503: GrpcStatus.UNAVAILABLE
+};
+
+class GrpcError extends Error {
+  constructor(readonly status: number, message: string) {
+    super(message);
+  }
+}
+
+interface LocationJson {
+  uri: string;
+  line: number;
+  character: number;
+}
+
+interface RangeJson {
+  startLine: number;
+  startCharacter: number;
+  endLine: number;
+  endCharacter: number;
+  startOffset?: number;
+  endOffset?: number;
+}
+
+/** Symbol JSON of the query server */
+interface SymbolJson {
+  name: string;
+  kind: string;
+  containerName?: string;
+  fullContainerPath?: string;
+  location: LocationJson;
+  range: RangeJson;
+  module?: string;
+  moduleVersion?: string;
+  thirdParty?: boolean;
+  tags?: string[];
+  doc?: string;
+  documentation?: string;
+  signature?: string;
+  typeParameters?: Array<{ name: string; constraint?: string }>;
+  value?: string;
+  deprecated?: boolean;
+  deprecation?: string;
+  summary?: string;
+}
+
+/** Reference JSON; indexes without reference lookup answer symbols */
+interface ReferenceJson {
+  name: string;
+  containerName?: string;
+  isImport?: boolean;
+  isCall?: boolean;
+  location: LocationJson;
+  range: RangeJson;
+}
+
+/** Query parameter each request field is passed as, by field number; messages fill in several */
+type RequestFields = Record<number, string | ((message: ProtoReader, params: URLSearchParams) => void)>;
+
+interface GrpcMethod {
+  /** Endpoint of the query server answering the RPC, and how its request maps to parameters */
+  endpoint: string;
+  fields: RequestFields;
+  /** Server-streaming RPCs send a message per item of the stream, the others the body */
+  streaming: boolean;
+  encode(result: any): ProtoWriter;
+}
+
+const POSITION: RequestFields = { 1: 'uri', 2: 'line', 3: 'character' };
+
+const SYMBOL_QUERY: RequestFields = {
+  1: 'name',
+  2: (message, params) => {
+    // proto3 leaves 0 off the wire
+    params.set('line', '0');
+    params.set('character', '0');
+    readParams(message, POSITION, params);
+  },
+  3: 'exclude',
+  4: 'only',
+  5: 'limit',
+  6: 'offset',
+  7: 'cursor'
+};
+
+/** The RPCs by name, each answered by the query server endpoint with the same results */
+const METHODS: Record<string, GrpcMethod> = {
+  SearchSymbols: {
+    endpoint: '/stream/symbols',
+    fields: {
+      1: 'q', 2: 'limit', 3: 'scope', 4: 'exclude', 5: 'only', 6: 'signature',
+      7: 'constraint', 8: 'generic', 9: 'kind', 10: 'offset', 11: 'cursor'
+    },
+    streaming: true,
+    encode: encodeSymbol
+  },
+  FindReferences: { endpoint: '/stream/references', fields: SYMBOL_QUERY, streaming: true, encode: encodeReference },
+  FindDefinitions: {
+    endpoint: '/definition',
+    fields: SYMBOL_QUERY,
+    streaming: false,
+    encode: (body: { name: string; definitions: SymbolJson[] }) =>
+      repeated(new ProtoWriter().string(1, body.name), 2, body.definitions, encodeSymbol)
+  },
+  GetOutline: {
+    endpoint: '/outline',
+    fields: { 1: 'uri' },
+    streaming: false,
+    encode: (body: { uri: string; symbols: SymbolJson[] }) =>
+      repeated(new ProtoWriter().string(1, body.uri), 2, body.symbols, encodeSymbol)
+  },
+  Query: {
+    endpoint: '/stream/query',
+    fields: { 1: 'q', 2: 'limit', 3: 'offset', 4: 'cursor', 5: 'scope', 6: 'saved' },
+    streaming: true,
+    encode: encodeSymbol
+  },
+  StreamProgress: { endpoint: '/stream/progress', fields: { 1: 'interval' }, streaming: true, encode: encodeProgress }
+};
+
+/**
+ * gRPC Listener - the gRPC side of the query server, over HTTP/2 (h2c,
+ * or TLS with `tls`). Each RPC of server/proto/smart_indexer.proto is
+ * answered by the endpoint returning the same results: server-streaming
+ * RPCs by the /stream/* endpoints, flow-controlled message by message,
+ * with the next cursor of a page in the `x-next-cursor` trailer.
+ *
+ * Credentials are checked as by QueryListener: a bearer token in the
+ * `authorization` metadata or a client certificate. Errors of the query
+ * server come back as the matching gRPC status (400 as INVALID_ARGUMENT,
+ * 401 as UNAUTHENTICATED, ...). Compressed requests are refused.
+ */
+export class GrpcListener {
+  private server: http2.Http2Server | http2.Http2SecureServer | null = null;
+  private sessions = new Set<http2.ServerHttp2Session>();
+  private secure = false;
+
+  constructor(private handler: QueryHandler, private logger: ILogger, private label: string) {}
+
+  /**
+   * Start listening. Port 0 picks a free port; the bound address is returned.
+   */
+  async start(port: number, host: string = '127.0.0.1', options: QueryListenOptions = {}): Promise<AddressInfo> {
+    if (this.server) {
+      await this.stop();
+    }
+
+    const onStream = (stream: http2.ServerHttp2Stream, headers: http2.IncomingHttpHeaders) => {
+      void this.respond(stream, headers, options);
+    };
+    const onSession = (session: http2.ServerHttp2Session) => {
+      this.sessions.add(session);
+      session.once('close', () => this.sessions.delete(session));
+    };
+    const server = options.tls
+      ? http2.createSecureServer({
+        cert: options.tls.cert,
+        key: options.tls.key,
+        ca: options.tls.ca,
+        requestCert: options.tls.ca !== undefined,
+        rejectUnauthorized: options.tls.ca !== undefined
+      }).on('stream', onStream).on('session', onSession)
+      : http2.createServer().on('stream', onStream).on('session', onSession);
+
+    await new Promise<void>((resolve, reject) => {
+      server.once('error', reject);
+      server.listen(port, host, () => {
+        server.off('error', reject);
+        resolve();
+      });
+    });
+
+    this.server = server;
+    this.secure = options.tls !== undefined;
+    const address = server.address() as AddressInfo;
+    this.logger.info(`[${this.label}] Serving gRPC on ${address.address}:${address.port}` +
+      `${this.secure ? ' (TLS)' : ''}${options.auth ? ' (authenticated)' : ''}`);
+    return address;
+  }
+
+  /** Stop listening and cut off the open connections, streams included */
+  async stop(): Promise<void> {
+    const server = this.server;
+    if (!server) {
+      return;
+    }
+    this.server = null;
+    const closed = new Promise<void>(resolve => server.close(() => resolve()));
+    for (const session of this.sessions) {
+      session.destroy();
+    }
+    this.sessions.clear();
+    await closed;
+    this.logger.info(`[${this.label}] Stopped serving gRPC`);
+  }
+
+  isRunning(): boolean {
+    return this.server !== null;
+  }
+
+  /** The bound port and address; null when stopped */
+  address(): AddressInfo | null {
+    return this.server ? this.server.address() as AddressInfo : null;
+  }
+
+  /** Whether the listener speaks TLS */
+  isSecure(): boolean {
+    return this.server !== null && this.secure;
+  }
+
+  private async respond(
+    stream: http2.ServerHttp2Stream,
+    headers: http2.IncomingHttpHeaders,
+    options: QueryListenOptions
+  ): Promise<void> {
+    stream.on('error', error => this.logger.warn(`[${this.label}] gRPC stream error: ${error}`));
+    if (headers[':method'] !== 'POST' || !String(headers['content-type'] ?? '').startsWith('application/grpc')) {
+      stream.respond({ ':status': 415 }, { endStream: true });
+      return;
+    }
+
+    const path = headers[':path'] ?? '';
+    let method: GrpcMethod;
+    let response: QueryResponse;
+    try {
+      const name = path.startsWith(`/${SERVICE}/`) ? path.substring(SERVICE.length + 2) : '';
+      method = METHODS[name];
+      if (!method) {
+        throw new GrpcError(GrpcStatus.UNIMPLEMENTED, `Unknown method ${path}`);
+      }
+      const principal = authenticate(headers.authorization, stream.session!.socket, options);
+      if (principal === null) {
+        this.logger.warn(`[${this.label}] Rejected ${path} from ${stream.session?.socket.remoteAddress}: no valid credentials`);
+        throw new GrpcError(GrpcStatus.UNAUTHENTICATED, 'Missing or invalid access token');
+      }
+      const params = decodeRequest(await readMessage(stream), method.fields);
+      response = await this.handler.handle('GET', `${method.endpoint}?${params}`, '', principal);
+      if (response.status !== 200) {
+        const error = (response.body as { error?: string } | undefined)?.error ?? `HTTP status ${response.status}`;
+        throw new GrpcError(STATUS_OF_HTTP[response.status] ?? (response.status >= 500 ? GrpcStatus.INTERNAL : GrpcStatus.UNKNOWN), error);
+      }
+    } catch (error) {
+      // Trailers-Only: the status goes with the headers
+      const status = error instanceof GrpcError ? error.status : GrpcStatus.INTERNAL;
+      if (!stream.destroyed) {
+        stream.respond({ ':status': 200, 'content-type': GRPC_CONTENT_TYPE, ...statusTrailers(status, errorMessage(error)) }, { endStream: true });
+      }
+      return;
+    }
+    await this.writeMessages(stream, method, response);
+  }
+
+  /**
+   * Send the response as messages, pausing while the stream's flow
+   * control window is full and stopping early if the client cancels.
+   * The status and the response's trailers follow the last message.
+   */
+  private async writeMessages(stream: http2.ServerHttp2Stream, method: GrpcMethod, response: QueryResponse): Promise<void> {
+    let trailers: http2.OutgoingHttpHeaders = {};
+    stream.respond({ ':status': 200, 'content-type': GRPC_CONTENT_TYPE }, { waitForTrailers: true });
+    stream.once('wantTrailers', () => stream.sendTrailers(trailers));
+    let closed = false;
+    stream.once('close', () => {
+      closed = true;
+    });
+
+    try {
+      const results = method.streaming ? response.stream ?? [] : [response.body];
+      for await (const result of results) {
+        if (closed) {
+          return;
+        }
+        if (!stream.write(frame(method.encode(result).finish()))) {
+          await new Promise<void>(resolve => {
+            stream.once('drain', resolve);
+            stream.once('close', resolve);
+          });
+        }
+      }
+      trailers = {
+        ...statusTrailers(GrpcStatus.OK),
+        ...Object.fromEntries(Object.entries(response.trailers ?? {})
+          .map(([name, value]) => [name.toLowerCase(), value()])
+          .filter(entry => entry[1] !== undefined))
+      };
+    } catch (error) {
+      this.logger.error(`[${this.label}] Error while streaming: ${error}`);
+      trailers = statusTrailers(GrpcStatus.INTERNAL, errorMessage(error));
+    }
+    if (!closed) {
+      stream.end();
+    }
+  }
+}
+
+/**
+ * The request message, out of its length-prefixed frame; an empty body is
+ * an empty message.
+ */
+function readMessage(stream: http2.ServerHttp2Stream): Promise<Uint8Array> {
+  return new Promise((resolve, reject) => {
+    const chunks: Buffer[] = [];
+    let size = 0;
+    stream.on('data', (chunk: Buffer) => {
+      size += chunk.length;
+      if (size <= MAX_MESSAGE_BYTES + 5) {
+        chunks.push(chunk);
+      }
+    });
+    stream.on('end', () => {
+      if (size > MAX_MESSAGE_BYTES + 5) {
+        reject(new GrpcError(GrpcStatus.RESOURCE_EXHAUSTED, `Request message exceeds ${MAX_MESSAGE_BYTES} bytes`));
+        return;
+      }
+      const body = Buffer.concat(chunks);
+      if (body.length === 0) {
+        resolve(body);
+      } else if (body.length < 5 || body.readUInt32BE(1) !== body.length - 5) {
+        reject(new GrpcError(GrpcStatus.INVALID_ARGUMENT, 'Expected one length-prefixed request message'));
+      } else if (body[0] !== 0) {
+        reject(new GrpcError(GrpcStatus.UNIMPLEMENTED, 'Compressed messages are not supported'));
+      } else {
+        resolve(body.subarray(5));
+      }
+    });
+    stream.on('error', reject);
+  });
+}
+
+function decodeRequest(message: Uint8Array, fields: RequestFields): URLSearchParams {
+  try {
+    return readParams(new ProtoReader(message), fields);
+  } catch (error) {
+    throw new GrpcError(GrpcStatus.INVALID_ARGUMENT, `Malformed request message: ${errorMessage(error)}`);
+  }
+}
+
+/**
+ * Query parameters of a request message. Empty strings and zeros are
+ * unset, as proto3 leaves them off the wire; unknown fields are skipped.
+ */
+function readParams(reader: ProtoReader, fields: RequestFields, params = new URLSearchParams()): URLSearchParams {
+  while (reader.hasMore()) {
+    const { field, wireType } = reader.tag();
+    const param = fields[field];
+    if (typeof param === 'function' && wireType === WIRE_LENGTH_DELIMITED) {
+      param(reader.message(), params);
+    } else if (typeof param === 'string' && wireType === WIRE_LENGTH_DELIMITED) {
+      const value = reader.string();
+      if (value) {
+        params.set(param, value);
+      }
+    } else if (typeof param === 'string' && wireType === WIRE_VARINT) {
+      const value = reader.varint();
+      if (value) {
+        params.set(param, String(value));
+      }
+    } else {
+      reader.skip(wireType);
+    }
+  }
+  return params;
+}
+
+function frame(message: Uint8Array): Buffer {
+  const framed = Buffer.alloc(5 + message.length);
+  framed.writeUInt32BE(message.length, 1);
+  framed.set(message, 5);
+  return framed;
+}
+
+function statusTrailers(status: number, message?: string): http2.OutgoingHttpHeaders {
+  return {
+    'grpc-status': String(status),
+    ...(message && { 'grpc-message': encodeURIComponent(message) })
+  };
+}
+
+function errorMessage(error: unknown): string {
+  return error instanceof Error ? error.message : String(error);
+}
+
+function repeated<T>(writer: ProtoWriter, field: number, items: T[], encode: (item: T) => ProtoWriter): ProtoWriter {
+  for (const item of items) {
+    writer.message(field, encode(item));
+  }
+  return writer;
+}
+
+function encodePosition(location: LocationJson): ProtoWriter {
+  return new ProtoWriter(64).string(1, location.uri).uint(2, location.line).uint(3, location.character);
+}
+
+function encodeRange(range: RangeJson): ProtoWriter {
+  return new ProtoWriter(32)
+    .uint(1, range.startLine)
+    .uint(2, range.startCharacter)
+    .uint(3, range.endLine)
+    .uint(4, range.endCharacter)
+    .optional(5, range.startOffset)
+    .optional(6, range.endOffset);
+}
+
+function encodeSymbol(symbol: SymbolJson): ProtoWriter {
+  const writer = new ProtoWriter()
+    .string(1, symbol.name)
+    .string(2, symbol.kind)
+    .string(3, symbol.containerName)
+    .string(4, symbol.fullContainerPath)
+    .message(5, encodePosition(symbol.location))
+    .message(6, encodeRange(symbol.range))
+    .string(7, symbol.module)
+    .string(8, symbol.moduleVersion)
+    .bool(9, symbol.thirdParty);
+  for (const tag of symbol.tags ?? []) {
+    writer.bytes(10, Buffer.from(tag, 'utf-8'));
+  }
+  writer.string(11, symbol.doc).string(12, symbol.documentation).string(13, symbol.signature);
+  for (const parameter of symbol.typeParameters ?? []) {
+    writer.message(14, new ProtoWriter().string(1, parameter.name).string(2, parameter.constraint));
+  }
+  return writer
+    .string(15, symbol.value)
+    .bool(16, symbol.deprecated)
+    .string(17, symbol.deprecation)
+    .string(18, symbol.summary);
+}
+
+function encodeReference(reference: ReferenceJson): ProtoWriter {
+  return new ProtoWriter(128)
+    .string(1, reference.name)
+    .string(2, reference.containerName)
+    .bool(3, reference.isImport)
+    .bool(4, reference.isCall)
+    .message(5, encodePosition(reference.location))
+    .message(6, encodeRange(reference.range));
+}
+
+function encodeProgress(progress: IndexingProgress): ProtoWriter {
+  return new ProtoWriter(128)
+    .string(1, progress.phase)
+    .uint(2, progress.filesScanned)
+    .uint(3, progress.filesChecked)
+    .uint(4, progress.filesTotal)
+    .uint(5, progress.filesParsed)
+    .uint(6, progress.filesIndexed)
+    .uint(7, progress.bytesProcessed)
+    .optional(8, progress.bytesTotal)
+    .double(9, progress.filesPerSecond)
+    .double(10, progress.bytesPerSecond)
+    .optional(11, progress.etaMs === undefined ? undefined : Math.round(progress.etaMs))
+    .uint(12, Math.round(progress.elapsedMs))
+    .string(13, progress.currentFile);
+}
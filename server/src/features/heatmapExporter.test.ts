@@ -0,0 +1,161 @@
+/**
+ * HeatmapExporter Tests
+ *
+ * Verifies per-directory references, churn and complexity, roll-up by
+ * depth, and the CSV and nested JSON output.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { HeatmapExporter, formatHeatmapCsv, heatmapTree } from './heatmapExporter.js';
+import { GitRunner } from './symbolHistory.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { FunctionMetrics, IndexedReference, IndexedSymbol } from '../types.js';
+
+const ROOT = '/ws';
+const NOW = Date.parse('2024-06-30T12:00:00Z');
+
+function fn(uri: string, name: string, metrics?: FunctionMetrics, extra: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    id: `${uri}:${name}`,
+    name,
+    kind: 'function',
+    filePath: uri,
+    location: { uri, line: 0, character: 0 },
+    metrics,
+    ...extra
+  });
+}
+
+function ref(uri: string, name: string): IndexedReference {
+  return {
+    symbolName: name,
+    location: { uri, line: 1, character: 0 },
+    range: { startLine: 1, startCharacter: 0, endLine: 1, endCharacter: name.length }
+  };
+}
+
+const gitLog = `commit aaa
+
+3\t1\tpkg/api/handler.go
+10\t0\tpkg/store/store.go
+commit bbb
+
+5\t5\tpkg/api/handler.go
+-\t-\tpkg/api/logo.png
+2\t0\tREADME.md
+commit ccc
+
+1\t1\tpkg/api/handler_test.go
+`;
+
+describe('HeatmapExporter', () => {
+  let index: MockBackgroundIndex;
+  let gitArgs: string[][];
+  let runGit: GitRunner;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    index.addFile('/ws/pkg/api/handler.go', [
+      fn('/ws/pkg/api/handler.go', 'Handle', { complexity: 12, loc: 40, nesting: 3 }, { isExported: true }),
+      fn('/ws/pkg/api/handler.go', 'parse', { complexity: 3, loc: 10, nesting: 1 }),
+      createTestSymbol({ id: 'req', name: 'Request', kind: 'struct', filePath: '/ws/pkg/api/handler.go', isExported: true })
+    ], [ref('/ws/pkg/api/handler.go', 'Save')]);
+    index.addFile('/ws/pkg/api/handler_test.go', [
+      fn('/ws/pkg/api/handler_test.go', 'TestHandle', { complexity: 2, loc: 8, nesting: 1 }, { tags: ['test'] })
+    ], [ref('/ws/pkg/api/handler_test.go', 'Handle'), ref('/ws/pkg/api/handler_test.go', 'Request')]);
+    index.addFile('/ws/pkg/store/store.go', [
+      fn('/ws/pkg/store/store.go', 'Save', { complexity: 4, loc: 20, nesting: 2 }, { isExported: true })
+    ], [ref('/ws/pkg/store/store.go', 'Request')]);
+    index.addFile('/ws/main.go', [fn('/ws/main.go', 'main', { complexity: 1, loc: 5, nesting: 0 })], [ref('/ws/main.go', 'Handle')]);
+
+    gitArgs = [];
+    runGit = async args => {
+      gitArgs.push(args);
+      return gitLog;
+    };
+  });
+
+  it('should aggregate references, churn and complexity per directory', async () => {
+    const exporter = new HeatmapExporter(index.asBackgroundIndex(), ROOT, runGit, () => NOW);
+    const report = await exporter.build({ days: 30 });
+
+    expect(gitArgs[0]).toContain('--since=2024-05-31T12:00:00.000Z');
+    expect(report).toMatchObject({ filesScanned: 4, days: 30, git: true });
+    expect(report.entries.map(e => [e.path, e.parent, e.files, e.symbols, e.exported, e.functions, e.references])).toEqual([
+      ['.', undefined, 1, 1, 0, 1, 0],
+      ['pkg', '.', 0, 0, 0, 0, 0],
+      ['pkg/api', 'pkg', 2, 4, 2, 3, 4],
+      ['pkg/store', 'pkg', 1, 1, 1, 1, 1]
+    ]);
+    const api = report.entries[2];
+    expect([api.commits, api.linesChanged, api.complexity, api.maxComplexity, api.loc]).toEqual([3, 16, 17, 12, 58]);
+    // Binary files count as commits without lines; README.md is not indexed
+    expect(report.entries[0]).toMatchObject({ commits: 0, linesChanged: 0 });
+    expect(report.entries.map(e => e.hotspot)).toEqual([0, 0, 1, 0.078]);
+  });
+
+  it('should roll up by depth and filter by tags', async () => {
+    const exporter = new HeatmapExporter(index.asBackgroundIndex(), ROOT, runGit, () => NOW);
+    const report = await exporter.build({ depth: 1, tags: { exclude: ['test'] } });
+
+    expect(report.entries.map(e => [e.path, e.files, e.functions, e.commits, e.complexity])).toEqual([
+      ['.', 1, 1, 0, 1],
+      ['pkg', 2, 3, 2, 19]
+    ]);
+    await expect(exporter.build({ depth: 0 })).rejects.toThrow('Invalid depth: 0');
+    await expect(exporter.build({ days: -1 })).rejects.toThrow('Invalid number of days: -1');
+  });
+
+  it('should report zero churn outside a git repository', async () => {
+    const exporter = new HeatmapExporter(index.asBackgroundIndex(), ROOT, async () => {
+      throw new Error('not a git repository');
+    });
+    const report = await exporter.build();
+
+    expect(report.git).toBe(false);
+    expect(report.entries.every(e => e.commits === 0 && e.hotspot === 0)).toBe(true);
+  });
+
+  describe('output', () => {
+    let dir: string;
+
+    beforeEach(() => {
+      dir = fs.mkdtempSync(path.join(os.tmpdir(), 'heatmap-'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    it('should write CSV rows and a nested JSON tree', async () => {
+      const exporter = new HeatmapExporter(index.asBackgroundIndex(), ROOT, runGit, () => NOW);
+
+      const csvPath = path.join(dir, 'out', 'heatmap.csv');
+      expect(await exporter.export(csvPath, 'csv')).toEqual({ outputPath: csvPath, format: 'csv', entries: 4, filesScanned: 4 });
+      const rows = fs.readFileSync(csvPath, 'utf-8').trimEnd().split('\n');
+      expect(rows[0]).toBe('path,parent,files,symbols,exported,functions,references,commits,linesChanged,complexity,maxComplexity,loc,hotspot');
+      expect(rows[3]).toBe('pkg/api,pkg,2,4,2,3,4,3,16,17,12,58,1');
+
+      const jsonPath = path.join(dir, 'heatmap.json');
+      await exporter.export(jsonPath, 'json');
+      const { root } = JSON.parse(fs.readFileSync(jsonPath, 'utf-8'));
+      expect(root.name).toBe('.');
+      expect(root.children.map((c: any) => c.name)).toEqual(['pkg']);
+      expect(root.children[0].children.map((c: any) => [c.name, c.path, c.complexity])).toEqual([['api', 'pkg/api', 17], ['store', 'pkg/store', 4]]);
+      expect('parent' in root.children[0]).toBe(false);
+
+      await expect(exporter.export(jsonPath, 'xml' as any)).rejects.toThrow('Unknown heatmap format "xml"');
+    });
+
+    it('should quote CSV cells with commas and quotes', () => {
+      const entry = { path: 'a,"b"', parent: '.', files: 1, symbols: 0, exported: 0, functions: 0, references: 0,
+        commits: 0, linesChanged: 0, complexity: 0, maxComplexity: 0, loc: 0, hotspot: 0 };
+      expect(formatHeatmapCsv([entry]).split('\n')[1]).toBe('"a,""b""",.,1,0,0,0,0,0,0,0,0,0,0');
+      expect(heatmapTree([])).toBeUndefined();
+    });
+  });
+});
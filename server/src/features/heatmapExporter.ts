@@ -0,0 +1,357 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { simpleGit } from 'simple-git';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import { CodeTagFilter, isTagFilterEmpty, matchesTagFilter } from '../utils/codeTags.js';
+import { GitRunner } from './symbolHistory.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type HeatmapFormat = 'csv' | 'json';
+
+export const HEATMAP_FORMATS: readonly HeatmapFormat[] = ['csv', 'json'];
+
+/** The part of an index the heatmap reads: the background index or the library Indexer */
+export type HeatmapSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols' | 'getReferenceCounts'>;
+
+export interface HeatmapOptions {
+  /** Days of git history counted as churn (default: 90) */
+  days?: number;
+  /**
+   * Directory depth to roll files up to: 1 aggregates per top-level
+   * directory. Default: every directory is its own entry.
+   */
+  depth?: number;
+  /** Only files under this folder */
+  scopePath?: string;
+  /** Code tags to leave out or keep, e.g. `{ exclude: ['test', 'generated'] }` */
+  tags?: CodeTagFilter;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+/** Metrics of the files directly in one directory (or rolled up into it) */
+export interface HeatmapEntry {
+  /** Workspace-relative directory, `.` for the workspace root */
+  path: string;
+  /** Parent entry; undefined for the root */
+  parent?: string;
+  files: number;
+  /** Definitions */
+  symbols: number;
+  exported: number;
+  /** Functions and methods with metrics */
+  functions: number;
+  /** Non-local references to the entry's definitions from anywhere, counted by name */
+  references: number;
+  /** Commits touching the files in the period */
+  commits: number;
+  /** Lines added plus lines deleted in the period */
+  linesChanged: number;
+  /** Cyclomatic complexity summed over the functions */
+  complexity: number;
+  maxComplexity: number;
+  /** Lines of the functions */
+  loc: number;
+  /**
+   * Churn times complexity, relative to the hottest entry: 1 for the
+   * entry with the most commits × complexity, 0 for entries without
+   * either.
+   */
+  hotspot: number;
+}
+
+export interface HeatmapReport {
+  /** Parents before their children, in path order */
+  entries: HeatmapEntry[];
+  filesScanned: number;
+  days: number;
+  /** False outside a git repository: churn is all zero */
+  git: boolean;
+}
+
+export interface HeatmapExportResult {
+  outputPath: string;
+  format: HeatmapFormat;
+  entries: number;
+  filesScanned: number;
+}
+
+/** Nested entry of the JSON export */
+export interface HeatmapNode extends Omit<HeatmapEntry, 'parent'> {
+  name: string;
+  children: HeatmapNode[];
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_DAYS = 90;
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+const FUNCTION_KINDS = new Set(['function', 'method', 'constructor', 'closure']);
+
+/** CSV columns, in order */
+const CSV_COLUMNS: Array<keyof HeatmapEntry> = [
+  'path', 'parent', 'files', 'symbols', 'exported', 'functions', 'references',
+  'commits', 'linesChanged', 'complexity', 'maxComplexity', 'loc', 'hotspot'
+];
+
+interface Totals {
+  files: number;
+  symbols: number;
+  exported: number;
+  functions: number;
+  complexity: number;
+  maxComplexity: number;
+  loc: number;
+  names: string[];
+  commits: Set<string>;
+  linesChanged: number;
+}
+
+/**
+ * Heatmap - reference counts, git churn and complexity per directory
+ * (a Go package), for treemaps of where code is used, changed and
+ * complex at once. Churn comes from one `git log --numstat` over the
+ * period, complexity from the function metrics stored at indexing time
+ * (see utils/functionMetrics.ts), reference counts from the reference
+ * index by name, as in `refs:` queries.
+ *
+ * Each entry holds the files directly in its directory; ancestors are
+ * listed too, with zeros when they have no files of their own, so the
+ * entries form a tree. CSV is flat with a `parent` column (d3.stratify,
+ * spreadsheets); JSON nests the entries under `children` (d3.hierarchy).
+ */
+export class HeatmapExporter {
+  private runGit: GitRunner;
+
+  constructor(
+    private index: HeatmapSourceIndex,
+    private workspaceRoot: string,
+    runGit?: GitRunner,
+    private now: () => number = Date.now
+  ) {
+    const git = simpleGit(workspaceRoot);
+    this.runGit = runGit ?? (args => git.raw(args));
+  }
+
+  /**
+   * Throws on a negative period or a depth below 1.
+   */
+  async build(options: HeatmapOptions = {}): Promise<HeatmapReport> {
+    const { cancellationToken, onProgress } = options;
+    const days = options.days ?? DEFAULT_DAYS;
+    if (!(days >= 0)) {
+      throw new Error(`Invalid number of days: ${options.days}`);
+    }
+    if (options.depth !== undefined && !(Number.isInteger(options.depth) && options.depth >= 1)) {
+      throw new Error(`Invalid depth: ${options.depth}`);
+    }
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+    const tags = options.tags && !isTagFilterEmpty(options.tags) ? options.tags : undefined;
+
+    const files = (await this.index.getAllFiles())
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const totals = new Map<string, Totals>();
+    const entryOfFile = new Map<string, Totals>();
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting metrics (${i}/${files.length})`);
+      }
+
+      const definitions = (await this.index.getFileSymbols(files[i]))
+        .filter(symbol => symbol.isDefinition !== false && (!tags || matchesTagFilter(symbol.tags, tags)));
+      if (tags && definitions.length === 0) {
+        continue;
+      }
+      const relative = this.relative(files[i]);
+      const entry = this.entry(totals, this.entryPath(relative, options.depth));
+      entryOfFile.set(relative, entry);
+      entry.files++;
+      for (const symbol of definitions) {
+        entry.symbols++;
+        entry.names.push(symbol.name);
+        if (symbol.isExported === true) {
+          entry.exported++;
+        }
+        if (symbol.metrics && FUNCTION_KINDS.has(symbol.kind)) {
+          entry.functions++;
+          entry.complexity += symbol.metrics.complexity;
+          entry.maxComplexity = Math.max(entry.maxComplexity, symbol.metrics.complexity);
+          entry.loc += symbol.metrics.loc;
+        }
+      }
+    }
+
+    throwIfCancelled(cancellationToken);
+    onProgress?.(files.length, files.length, 'Reading git history...');
+    const git = await this.collectChurn(days, entryOfFile);
+
+    onProgress?.(files.length, files.length, 'Counting references...');
+    const counts = await this.index.getReferenceCounts([...totals.values()].flatMap(entry => entry.names));
+
+    // Ancestors complete the tree
+    for (const entryPath of [...totals.keys()]) {
+      for (let parent = parentOf(entryPath); parent !== undefined; parent = parentOf(parent)) {
+        this.entry(totals, parent);
+      }
+    }
+
+    const entries = [...totals.entries()].map(([entryPath, entry]): HeatmapEntry => ({
+      path: entryPath,
+      ...(entryPath !== '.' && { parent: parentOf(entryPath) }),
+      files: entry.files,
+      symbols: entry.symbols,
+      exported: entry.exported,
+      functions: entry.functions,
+      references: entry.names.reduce((sum, name) => sum + (counts.get(name) ?? 0), 0),
+      commits: entry.commits.size,
+      linesChanged: entry.linesChanged,
+      complexity: entry.complexity,
+      maxComplexity: entry.maxComplexity,
+      loc: entry.loc,
+      hotspot: 0
+    }));
+    const hottest = Math.max(0, ...entries.map(entry => entry.commits * entry.complexity));
+    for (const entry of entries) {
+      entry.hotspot = hottest > 0 ? Math.round(entry.commits * entry.complexity / hottest * 1000) / 1000 : 0;
+    }
+
+    entries.sort((a, b) => comparePaths(a.path, b.path));
+    return { entries, filesScanned: files.length, days, git };
+  }
+
+  /**
+   * Write the heatmap to outputPath as CSV or nested JSON.
+   */
+  async export(outputPath: string, format: HeatmapFormat, options: HeatmapOptions = {}): Promise<HeatmapExportResult> {
+    if (!HEATMAP_FORMATS.includes(format)) {
+      throw new Error(`Unknown heatmap format "${format}", expected one of ${HEATMAP_FORMATS.join(', ')}`);
+    }
+    const report = await this.build(options);
+    const text = format === 'csv'
+      ? formatHeatmapCsv(report.entries)
+      : JSON.stringify({ days: report.days, root: heatmapTree(report.entries) }, null, 2) + '\n';
+    await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+    await fsPromises.writeFile(outputPath, text, 'utf-8');
+    return { outputPath, format, entries: report.entries.length, filesScanned: report.filesScanned };
+  }
+
+  /**
+   * Add commits and changed lines of the period to the entries of the
+   * files they touch; false when git history can't be read.
+   */
+  private async collectChurn(days: number, entryOfFile: Map<string, Totals>): Promise<boolean> {
+    const since = new Date(this.now() - days * DAY_MS).toISOString();
+    let output: string;
+    try {
+      output = await this.runGit([
+        '-c', 'core.quotePath=false', 'log', '--relative', `--since=${since}`, '--no-renames', '--numstat', '--format=commit %H'
+      ]);
+    } catch {
+      // Not a repository, or no commits yet
+      return false;
+    }
+
+    let commit = '';
+    for (const line of output.split('\n')) {
+      if (line.startsWith('commit ')) {
+        commit = line.slice(7).trim();
+        continue;
+      }
+      // <added>\t<deleted>\t<path>; binary files show - for both
+      const match = /^(\d+|-)\t(\d+|-)\t(.+)$/.exec(line);
+      const entry = match && entryOfFile.get(match[3]);
+      if (!entry) {
+        continue;
+      }
+      entry.commits.add(commit);
+      entry.linesChanged += (match[1] === '-' ? 0 : Number(match[1])) + (match[2] === '-' ? 0 : Number(match[2]));
+    }
+    return true;
+  }
+
+  private entry(totals: Map<string, Totals>, entryPath: string): Totals {
+    let entry = totals.get(entryPath);
+    if (!entry) {
+      entry = {
+        files: 0, symbols: 0, exported: 0, functions: 0, complexity: 0, maxComplexity: 0, loc: 0,
+        names: [], commits: new Set(), linesChanged: 0
+      };
+      totals.set(entryPath, entry);
+    }
+    return entry;
+  }
+
+  /** Directory of a workspace-relative file, cut at depth */
+  private entryPath(relative: string, depth: number | undefined): string {
+    const dirs = path.posix.dirname(relative).split('/').filter(part => part && part !== '.');
+    const kept = depth === undefined ? dirs : dirs.slice(0, depth);
+    return kept.length > 0 ? kept.join('/') : '.';
+  }
+
+  private relative(filePath: string): string {
+    return path.relative(this.workspaceRoot, filePath).split(path.sep).join('/');
+  }
+}
+
+function parentOf(entryPath: string): string | undefined {
+  if (entryPath === '.') {
+    return undefined;
+  }
+  const slash = entryPath.lastIndexOf('/');
+  return slash === -1 ? '.' : entryPath.slice(0, slash);
+}
+
+/** The root first, then path order with parents before children */
+function comparePaths(a: string, b: string): number {
+  if (a === '.' || b === '.') {
+    return a === b ? 0 : a === '.' ? -1 : 1;
+  }
+  const partsA = a.split('/');
+  const partsB = b.split('/');
+  for (let i = 0; i < Math.min(partsA.length, partsB.length); i++) {
+    if (partsA[i] !== partsB[i]) {
+      return partsA[i] < partsB[i] ? -1 : 1;
+    }
+  }
+  return partsA.length - partsB.length;
+}
+
+/**
+ * The entries as CSV, RFC 4180 quoting, one header row.
+ */
+export function formatHeatmapCsv(entries: HeatmapEntry[]): string {
+  const cell = (value: unknown) => {
+    const text = value === undefined ? '' : String(value);
+    return /[",\r\n]/.test(text) ? `"${text.replace(/"/g, '""')}"` : text;
+  };
+  const rows = entries.map(entry => CSV_COLUMNS.map(column => cell(entry[column])).join(','));
+  return [CSV_COLUMNS.join(','), ...rows].join('\n') + '\n';
+}
+
+/**
+ * Nest the entries of a report under their parents; the root entry
+ * (`.`) is the top of the tree.
+ */
+export function heatmapTree(entries: HeatmapEntry[]): HeatmapNode | undefined {
+  const nodes = new Map<string, HeatmapNode>();
+  for (const { parent: _parent, ...entry } of entries) {
+    nodes.set(entry.path, { ...entry, name: entry.path === '.' ? '.' : path.posix.basename(entry.path), children: [] });
+  }
+  for (const entry of entries) {
+    if (entry.parent !== undefined) {
+      nodes.get(entry.parent)?.children.push(nodes.get(entry.path)!);
+    }
+  }
+  return nodes.get('.');
+}
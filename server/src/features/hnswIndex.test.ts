@@ -0,0 +1,111 @@
+/**
+ * HnswIndex Tests
+ *
+ * Compares graph search against brute force on seeded random vectors and
+ * checks deletes, compaction and the serialized form.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { HnswIndex } from './hnswIndex.js';
+
+const DIMENSIONS = 24;
+
+/** Deterministic PRNG (mulberry32) so graphs and vectors are reproducible */
+function seeded(seed: number): () => number {
+  return () => {
+    seed = (seed + 0x6d2b79f5) | 0;
+    let t = Math.imul(seed ^ (seed >>> 15), 1 | seed);
+    t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+}
+
+function randomVector(random: () => number): Float32Array {
+  const vector = Float32Array.from({ length: DIMENSIONS }, () => random() * 2 - 1);
+  const norm = Math.hypot(...vector);
+  return vector.map(x => x / norm);
+}
+
+function bruteForce(vectors: Map<string, Float32Array>, query: Float32Array, k: number): string[] {
+  return [...vectors.entries()]
+    .map(([key, v]) => ({ key, score: v.reduce((sum, x, i) => sum + x * query[i], 0) }))
+    .sort((a, b) => b.score - a.score)
+    .slice(0, k)
+    .map(r => r.key);
+}
+
+describe('HnswIndex', () => {
+  let random: () => number;
+  let vectors: Map<string, Float32Array>;
+  let index: HnswIndex;
+
+  function recall(queries: number, k: number): number {
+    let found = 0;
+    for (let q = 0; q < queries; q++) {
+      const query = randomVector(random);
+      const expected = new Set(bruteForce(vectors, query, k));
+      found += index.search(query, k).filter(r => expected.has(r.key)).length;
+    }
+    return found / (queries * k);
+  }
+
+  beforeEach(() => {
+    random = seeded(42);
+    vectors = new Map();
+    index = new HnswIndex({ m: 8, efConstruction: 64, efSearch: 32, random: seeded(7) });
+    for (let i = 0; i < 1500; i++) {
+      const vector = randomVector(random);
+      vectors.set(`v${i}`, vector);
+      index.insert(`v${i}`, vector);
+    }
+  });
+
+  it('should find nearly all true nearest neighbors', () => {
+    expect(index.size).toBe(1500);
+    expect(recall(50, 10)).toBeGreaterThan(0.9);
+
+    const [best] = index.search(vectors.get('v123')!, 1);
+    expect(best.key).toBe('v123');
+    expect(best.score).toBeGreaterThan(0.9999);
+  });
+
+  it('should never return deleted keys and compact when tombstones dominate', () => {
+    for (let i = 0; i < 800; i++) {
+      expect(index.delete(`v${i}`)).toBe(true);
+      vectors.delete(`v${i}`);
+    }
+    expect(index.delete('v0')).toBe(false);
+    expect(index.size).toBe(700);
+    expect(index.needsCompaction()).toBe(true);
+
+    const query = randomVector(random);
+    expect(index.search(query, 20).every(r => vectors.has(r.key))).toBe(true);
+    expect(recall(30, 10)).toBeGreaterThan(0.85);
+
+    index.compact();
+    expect(index.needsCompaction()).toBe(false);
+    expect(index.size).toBe(700);
+    expect(recall(30, 10)).toBeGreaterThan(0.9);
+
+    index.insert('v5', Float32Array.from(randomVector(random)));
+    expect(index.has('v5')).toBe(true);
+  });
+
+  it('should round-trip through serialize, keeping tombstones routable', () => {
+    index.delete('v1');
+    vectors.delete('v1');
+    const query = randomVector(random);
+    const before = index.search(query, 10);
+
+    const loaded = HnswIndex.deserialize(index.serialize(), key => vectors.get(key), { m: 8, efConstruction: 64, efSearch: 32 })!;
+    expect(loaded).not.toBeNull();
+    expect(loaded.size).toBe(1499);
+    expect(loaded.has('v1')).toBe(false);
+    expect(loaded.search(query, 10)).toEqual(before);
+
+    // Different build parameters or lost vectors: caller rebuilds
+    expect(HnswIndex.deserialize(index.serialize(), key => vectors.get(key), { m: 16 })).toBeNull();
+    vectors.delete('v2');
+    expect(HnswIndex.deserialize(index.serialize(), key => vectors.get(key), { m: 8, efConstruction: 64 })).toBeNull();
+  });
+});
@@ -0,0 +1,447 @@
+export interface HnswOptions {
+  /** Links per node and layer (layer 0 keeps 2 * m); higher improves recall at the cost of memory (default 16) */
+  m?: number;
+  /** Candidate list size while inserting; higher builds a better graph, slower (default 200) */
+  efConstruction?: number;
+  /** Default candidate list size while searching; higher improves recall, slower (default 64) */
+  efSearch?: number;
+  /** Random source for level assignment; injectable for reproducible graphs */
+  random?: () => number;
+}
+
+export interface HnswResult {
+  key: string;
+  /** Dot product with the query; cosine similarity for normalized vectors */
+  score: number;
+}
+
+/** Resolves a node key to its vector when loading a persisted graph */
+export type VectorResolver = (key: string) => Float32Array | undefined;
+
+interface HnswNode {
+  key: string;
+  vector: Float32Array;
+  /** neighbors[layer] = node ids */
+  neighbors: number[][];
+  deleted: boolean;
+}
+
+interface Candidate {
+  id: number;
+  distance: number;
+}
+
+interface SerializedHeader {
+  version: number;
+  m: number;
+  efConstruction: number;
+  entryPoint: number;
+  maxLevel: number;
+  dimensions: number;
+  keys: string[];
+  levels: number[];
+  deleted: number[];
+}
+
+const FORMAT_VERSION = 1;
+const DEFAULT_M = 16;
+const DEFAULT_EF_CONSTRUCTION = 200;
+const DEFAULT_EF_SEARCH = 64;
+/** Deleted nodes still route searches; rebuild once they outnumber live ones */
+const MIN_COMPACTION_SIZE = 64;
+
+/**
+ * HNSW - Hierarchical Navigable Small World graph for approximate nearest
+ * neighbor search (Malkov & Yashunin, 2016).
+ *
+ * Nodes live on a random number of layers (exponentially fewer per layer).
+ * A search descends greedily from the top layer's entry point and then
+ * explores layer 0 with a candidate list of `ef` nodes, so cost grows with
+ * log(n) instead of n. Neighbors are chosen with the paper's diversity
+ * heuristic, which keeps clustered data reachable.
+ *
+ * Vectors are expected to be normalized; distance is `1 - dot`. Deletes
+ * are tombstones: the node keeps routing but is never returned, and
+ * `needsCompaction()` tells the owner when to `compact()`.
+ */
+export class HnswIndex {
+  readonly m: number;
+  readonly efConstruction: number;
+  efSearch: number;
+
+  private nodes: HnswNode[] = [];
+  private ids: Map<string, number> = new Map();
+  private entryPoint = -1;
+  private maxLevel = -1;
+  private deletedCount = 0;
+  private readonly levelMultiplier: number;
+  private readonly random: () => number;
+
+  constructor(options: HnswOptions = {}) {
+    this.m = Math.max(2, options.m ?? DEFAULT_M);
+    this.efConstruction = Math.max(this.m, options.efConstruction ?? DEFAULT_EF_CONSTRUCTION);
+    this.efSearch = Math.max(1, options.efSearch ?? DEFAULT_EF_SEARCH);
+    this.levelMultiplier = 1 / Math.log(this.m);
+    this.random = options.random ?? Math.random;
+  }
+
+  /** Live (not deleted) nodes */
+  get size(): number {
+    return this.nodes.length - this.deletedCount;
+  }
+
+  has(key: string): boolean {
+    const id = this.ids.get(key);
+    return id !== undefined && !this.nodes[id].deleted;
+  }
+
+  /**
+   * Add a vector under a key. Re-inserting a deleted key revives it, so keys
+   * must identify the vector (e.g. a content hash).
+   */
+  insert(key: string, vector: Float32Array): void {
+    const existing = this.ids.get(key);
+    if (existing !== undefined) {
+      if (this.nodes[existing].deleted) {
+        this.nodes[existing].deleted = false;
+        this.deletedCount--;
+      }
+      return;
+    }
+
+    const level = Math.floor(-Math.log(1 - this.random()) * this.levelMultiplier);
+    const id = this.nodes.length;
+    this.nodes.push({ key, vector, neighbors: Array.from({ length: level + 1 }, () => []), deleted: false });
+    this.ids.set(key, id);
+    this.link(id, level);
+  }
+
+  delete(key: string): boolean {
+    const id = this.ids.get(key);
+    if (id === undefined || this.nodes[id].deleted) {
+      return false;
+    }
+    this.nodes[id].deleted = true;
+    this.deletedCount++;
+    return true;
+  }
+
+  /**
+   * The `k` live nodes closest to a query, best first.
+   *
+   * @param ef Candidate list size (default `efSearch`); raised to `k` if smaller
+   */
+  search(query: Float32Array, k: number, ef: number = this.efSearch): HnswResult[] {
+    if (this.entryPoint === -1 || k <= 0) {
+      return [];
+    }
+
+    let entry = this.entryPoint;
+    for (let layer = this.maxLevel; layer > 0; layer--) {
+      entry = this.searchLayer(query, [entry], 1, layer)[0].id;
+    }
+    // Tombstones take slots in the candidate list, so widen it by their share
+    const width = Math.max(ef, k) + Math.min(this.deletedCount, Math.max(ef, k));
+    return this.searchLayer(query, [entry], width, 0)
+      .filter(c => !this.nodes[c.id].deleted)
+      .slice(0, k)
+      .map(c => ({ key: this.nodes[c.id].key, score: 1 - c.distance }));
+  }
+
+  needsCompaction(): boolean {
+    return this.deletedCount >= MIN_COMPACTION_SIZE && this.deletedCount > this.size;
+  }
+
+  /**
+   * Rebuild the graph from live nodes, dropping tombstones.
+   */
+  compact(): void {
+    const live = this.nodes.filter(node => !node.deleted);
+    this.nodes = [];
+    this.ids.clear();
+    this.entryPoint = -1;
+    this.maxLevel = -1;
+    this.deletedCount = 0;
+    for (const node of live) {
+      this.insert(node.key, node.vector);
+    }
+  }
+
+  /**
+   * Graph only; vectors are stored by the owner and resolved on load,
+   * except those of tombstones, which the owner has already dropped.
+   *
+   * Layout: u32 header length, JSON header (keys, levels, tombstones),
+   * padding to 4 bytes, per node and layer an i32 count followed by that
+   * many i32 neighbor ids, then the f32 vectors of the tombstones.
+   */
+  serialize(): Buffer {
+    const header: SerializedHeader = {
+      version: FORMAT_VERSION,
+      m: this.m,
+      efConstruction: this.efConstruction,
+      entryPoint: this.entryPoint,
+      maxLevel: this.maxLevel,
+      dimensions: this.nodes[0]?.vector.length ?? 0,
+      keys: this.nodes.map(node => node.key),
+      levels: this.nodes.map(node => node.neighbors.length - 1),
+      deleted: this.nodes.flatMap((node, id) => node.deleted ? [id] : [])
+    };
+
+    const links: number[] = [];
+    for (const node of this.nodes) {
+      for (const neighbors of node.neighbors) {
+        links.push(neighbors.length, ...neighbors);
+      }
+    }
+
+    const tombstones = new Float32Array(header.deleted.length * header.dimensions);
+    header.deleted.forEach((id, i) => tombstones.set(this.nodes[id].vector, i * header.dimensions));
+
+    const headerBytes = Buffer.from(JSON.stringify(header), 'utf-8');
+    const offset = align4(4 + headerBytes.length);
+    const buffer = Buffer.alloc(offset + links.length * 4 + tombstones.byteLength);
+    buffer.writeUInt32LE(headerBytes.length, 0);
+    headerBytes.copy(buffer, 4);
+    Buffer.from(Int32Array.from(links).buffer).copy(buffer, offset);
+    Buffer.from(tombstones.buffer).copy(buffer, offset + links.length * 4);
+    return buffer;
+  }
+
+  /**
+   * Load a serialized graph. Returns null when it was built with different
+   * `m`/`efConstruction` or a vector can no longer be resolved; the owner
+   * should rebuild then.
+   */
+  static deserialize(buffer: Buffer, resolveVector: VectorResolver, options: HnswOptions = {}): HnswIndex | null {
+    const index = new HnswIndex(options);
+    let header: SerializedHeader;
+    try {
+      const headerLength = buffer.readUInt32LE(0);
+      header = JSON.parse(buffer.subarray(4, 4 + headerLength).toString('utf-8'));
+    } catch {
+      return null;
+    }
+    if (header.version !== FORMAT_VERSION || header.m !== index.m || header.efConstruction !== index.efConstruction) {
+      return null;
+    }
+
+    const offset = align4(4 + buffer.readUInt32LE(0));
+    const tombstoneBytes = header.deleted.length * header.dimensions * 4;
+    const linksEnd = buffer.byteOffset + buffer.byteLength - tombstoneBytes;
+    if (linksEnd < buffer.byteOffset + offset) {
+      return null;
+    }
+    const links = new Int32Array(buffer.buffer.slice(buffer.byteOffset + offset, linksEnd));
+    const tombstones = new Float32Array(buffer.buffer.slice(linksEnd, linksEnd + tombstoneBytes));
+    const deleted = new Map(header.deleted.map((id, i) => [id, i]));
+    let position = 0;
+
+    for (let id = 0; id < header.keys.length; id++) {
+      const tombstone = deleted.get(id);
+      const vector = tombstone === undefined
+        ? resolveVector(header.keys[id])
+        : tombstones.slice(tombstone * header.dimensions, (tombstone + 1) * header.dimensions);
+      if (!vector) {
+        return null;
+      }
+      const neighbors: number[][] = [];
+      for (let layer = 0; layer <= header.levels[id]; layer++) {
+        const count = links[position++];
+        neighbors.push(Array.from(links.subarray(position, position + count)));
+        position += count;
+      }
+      index.nodes.push({ key: header.keys[id], vector, neighbors, deleted: deleted.has(id) });
+      index.ids.set(header.keys[id], id);
+    }
+    if (position !== links.length) {
+      return null;
+    }
+
+    index.entryPoint = header.entryPoint;
+    index.maxLevel = header.maxLevel;
+    index.deletedCount = deleted.size;
+    return index;
+  }
+
+  private link(id: number, level: number): void {
+    const node = this.nodes[id];
+    if (this.entryPoint === -1) {
+      this.entryPoint = id;
+      this.maxLevel = level;
+      return;
+    }
+
+    let entries = [this.entryPoint];
+    for (let layer = this.maxLevel; layer > level; layer--) {
+      entries = [this.searchLayer(node.vector, entries, 1, layer)[0].id];
+    }
+
+    for (let layer = Math.min(level, this.maxLevel); layer >= 0; layer--) {
+      const candidates = this.searchLayer(node.vector, entries, this.efConstruction, layer);
+      node.neighbors[layer] = this.selectNeighbors(candidates, this.m);
+
+      for (const neighborId of node.neighbors[layer]) {
+        const neighbor = this.nodes[neighborId];
+        neighbor.neighbors[layer].push(id);
+        if (neighbor.neighbors[layer].length > this.maxNeighbors(layer)) {
+          const ranked = neighbor.neighbors[layer]
+            .map(other => ({ id: other, distance: distance(neighbor.vector, this.nodes[other].vector) }))
+            .sort((a, b) => a.distance - b.distance);
+          neighbor.neighbors[layer] = this.selectNeighbors(ranked, this.maxNeighbors(layer));
+        }
+      }
+      entries = candidates.map(c => c.id);
+    }
+
+    if (level > this.maxLevel) {
+      this.entryPoint = id;
+      this.maxLevel = level;
+    }
+  }
+
+  private maxNeighbors(layer: number): number {
+    return layer === 0 ? this.m * 2 : this.m;
+  }
+
+  /**
+   * Diversity heuristic: keep a candidate only if it is closer to the base
+   * than to every neighbor kept so far, then fill up with the closest of
+   * the rest. `candidates` must be sorted by distance to the base.
+   */
+  private selectNeighbors(candidates: Candidate[], limit: number): number[] {
+    const selected: Candidate[] = [];
+    const pruned: Candidate[] = [];
+    for (const candidate of candidates) {
+      if (selected.length >= limit) {
+        break;
+      }
+      const vector = this.nodes[candidate.id].vector;
+      if (selected.every(s => distance(vector, this.nodes[s.id].vector) > candidate.distance)) {
+        selected.push(candidate);
+      } else {
+        pruned.push(candidate);
+      }
+    }
+    for (let i = 0; selected.length < limit && i < pruned.length; i++) {
+      selected.push(pruned[i]);
+    }
+    return selected.map(c => c.id);
+  }
+
+  /**
+   * Best-first search of one layer, returning up to `ef` nodes sorted by distance.
+   */
+  private searchLayer(query: Float32Array, entries: number[], ef: number, layer: number): Candidate[] {
+    const visited = new Set<number>(entries);
+    const candidates = new Heap<Candidate>((a, b) => a.distance - b.distance);
+    const results = new Heap<Candidate>((a, b) => b.distance - a.distance);
+
+    for (const id of entries) {
+      const entry = { id, distance: distance(query, this.nodes[id].vector) };
+      candidates.push(entry);
+      results.push(entry);
+    }
+    while (results.size > ef) {
+      results.pop();
+    }
+
+    while (candidates.size > 0) {
+      const current = candidates.pop()!;
+      if (results.size >= ef && current.distance > results.peek()!.distance) {
+        break;
+      }
+      for (const neighborId of this.nodes[current.id].neighbors[layer] ?? []) {
+        if (visited.has(neighborId)) {
+          continue;
+        }
+        visited.add(neighborId);
+        const d = distance(query, this.nodes[neighborId].vector);
+        if (results.size < ef || d < results.peek()!.distance) {
+          const neighbor = { id: neighborId, distance: d };
+          candidates.push(neighbor);
+          results.push(neighbor);
+          if (results.size > ef) {
+            results.pop();
+          }
+        }
+      }
+    }
+
+    return results.toArray().sort((a, b) => a.distance - b.distance);
+  }
+}
+
+/**
+ * Binary heap; `compare(a, b) < 0` puts `a` closer to the top.
+ */
+class Heap<T> {
+  private items: T[] = [];
+
+  constructor(private compare: (a: T, b: T) => number) {}
+
+  get size(): number {
+    return this.items.length;
+  }
+
+  peek(): T | undefined {
+    return this.items[0];
+  }
+
+  push(item: T): void {
+    const items = this.items;
+    items.push(item);
+    let i = items.length - 1;
+    while (i > 0) {
+      const parent = (i - 1) >> 1;
+      if (this.compare(items[i], items[parent]) >= 0) {
+        break;
+      }
+      [items[i], items[parent]] = [items[parent], items[i]];
+      i = parent;
+    }
+  }
+
+  pop(): T | undefined {
+    const items = this.items;
+    const top = items[0];
+    const last = items.pop();
+    if (items.length > 0 && last !== undefined) {
+      items[0] = last;
+      let i = 0;
+      for (;;) {
+        const left = i * 2 + 1;
+        const right = left + 1;
+        let best = i;
+        if (left < items.length && this.compare(items[left], items[best]) < 0) {
+          best = left;
+        }
+        if (right < items.length && this.compare(items[right], items[best]) < 0) {
+          best = right;
+        }
+        if (best === i) {
+          break;
+        }
+        [items[i], items[best]] = [items[best], items[i]];
+        i = best;
+      }
+    }
+    return top;
+  }
+
+  toArray(): T[] {
+    return [...this.items];
+  }
+}
+
+function distance(a: Float32Array, b: Float32Array): number {
+  let sum = 0;
+  for (let i = 0; i < a.length; i++) {
+    sum += a[i] * b[i];
+  }
+  return 1 - sum;
+}
+
+function align4(n: number): number {
+  return (n + 3) & ~3;
+}
@@ -0,0 +1,187 @@
+/**
+ * HTTP Route Tests
+ *
+ * Verifies route extraction for net/http, chi, gin and echo (group and
+ * Route prefixes, method patterns, mounts, handler expressions) and the
+ * route index: handler definitions, filters and rescans.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { findHttpRoutes, HttpRouteIndex } from './httpRoutes.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const SERVER_GO = '/ws/cmd/api/server.go';
+const HANDLERS_GO = '/ws/users/handlers.go';
+
+const serverGo = `package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"example.com/app/users"
+)
+
+func routes(h *users.Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/health", health)
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/", h.List)
+		r.With(auth).Post("/", h.Create)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", users.Show)
+			r.Method("DELETE", "/", http.HandlerFunc(h.Delete))
+		})
+	})
+	r.Mount("/admin", adminRouter())
+	// r.Get("/commented", nope)
+	r.Get(healthPath, health)
+	return r
+}
+
+func health(w http.ResponseWriter, req *http.Request) {}
+`;
+
+const handlersGo = `package users
+
+import "net/http"
+
+type Handler struct{}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {}
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {}
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {}
+
+func Show(w http.ResponseWriter, r *http.Request) {}
+`;
+
+describe('findHttpRoutes', () => {
+  const summary = (uri: string, content: string) =>
+    findHttpRoutes(uri, content).map(route => `${route.method} ${route.path} ${route.handler}`);
+
+  it('should read chi routes with Route prefixes, Method and Mount', () => {
+    expect(summary(SERVER_GO, serverGo)).toEqual([
+      'GET /health health',
+      'GET /users/ h.List',
+      'POST /users/ h.Create',
+      'GET /users/{id}/ users.Show',
+      'DELETE /users/{id}/ h.Delete',
+      'ANY /admin/* adminRouter()'
+    ]);
+    expect(findHttpRoutes(SERVER_GO, serverGo)[0]).toMatchObject({
+      framework: 'chi', line: 11, character: 3, handlerLine: 11, handlerCharacter: 18
+    });
+  });
+
+  it('should read net/http patterns', () => {
+    const content = `package main
+
+import "net/http"
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", getItem)
+	mux.Handle("/static/", http.StripPrefix("/static/", files))
+	http.HandleFunc("example.com/ping", func(w http.ResponseWriter, r *http.Request) {})
+	cache.Get("/not-a-route", key)
+}
+`;
+    expect(summary('/ws/main.go', content)).toEqual([
+      'GET /items/{id} getItem',
+      'ANY /static/ http.StripPrefix("/static/", files)',
+      'ANY /ping func literal'
+    ]);
+    expect(findHttpRoutes('/ws/main.go', content, ['chi'])).toEqual([]);
+  });
+
+  it('should read gin and echo groups and handler positions', () => {
+    const gin = `package api
+
+import "github.com/gin-gonic/gin"
+
+func Register(r *gin.Engine) {
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/users", auth(), listUsers)
+		v1.Handle("PUT", "/users/:id", updateUser)
+	}
+	r.Group("/internal").Any("/debug", debug)
+}
+`;
+    expect(summary('/ws/api/gin.go', gin)).toEqual([
+      'GET /api/v1/users listUsers',
+      'PUT /api/v1/users/:id updateUser',
+      'ANY /internal/debug debug'
+    ]);
+
+    const echo = `package api
+
+import "github.com/labstack/echo/v4"
+
+func Register(e *echo.Echo) {
+	admin := e.Group("/admin", basicAuth)
+	admin.DELETE("/cache", clearCache, audit)
+	e.Add("PATCH", "/items/:id", patchItem)
+}
+`;
+    expect(summary('/ws/api/echo.go', echo)).toEqual([
+      'DELETE /admin/cache clearCache',
+      'PATCH /items/:id patchItem'
+    ]);
+  });
+});
+
+describe('HttpRouteIndex', () => {
+  let index: MockBackgroundIndex;
+  let routes: HttpRouteIndex;
+  let contents: Record<string, string>;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, hash?: string): void {
+    contents[uri] = content;
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(hash ? { hash } : {}) });
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = {};
+    addGoFile(SERVER_GO, serverGo);
+    addGoFile(HANDLERS_GO, handlersGo);
+    addGoFile('/ws/cmd/api/server_test.go', 'package main\n\nimport "net/http"\n\nfunc init() { http.HandleFunc("/test", nil) }\n');
+    routes = new HttpRouteIndex(index.asBackgroundIndex(), async filePath => contents[filePath]);
+    await routes.build();
+  });
+
+  it('should link routes to their handler definitions', async () => {
+    const report = await routes.query();
+    expect(report).toMatchObject({ total: 6, truncated: false });
+    expect(report.byMethod).toEqual({ GET: 3, POST: 1, DELETE: 1, ANY: 1 });
+    expect(report.routes.map(route => [route.method, route.path, route.definition?.containerName, route.definition?.name])).toEqual([
+      ['ANY', '/admin/*', undefined, undefined],
+      ['GET', '/health', undefined, 'health'],
+      ['GET', '/users/', 'Handler', 'List'],
+      ['POST', '/users/', 'Handler', 'Create'],
+      ['DELETE', '/users/{id}/', 'Handler', 'Delete'],
+      ['GET', '/users/{id}/', undefined, 'Show']
+    ]);
+    expect(report.routes[5].definition!.location).toMatchObject({ uri: HANDLERS_GO, line: 10 });
+  });
+
+  it('should filter by method, route prefix and file', async () => {
+    expect((await routes.query({ method: 'get', route: '/users' })).routes.map(route => route.handler)).toEqual(['h.List', 'users.Show']);
+    // ANY routes take every method
+    expect((await routes.query({ method: 'PUT' })).routes.map(route => route.path)).toEqual(['/admin/*']);
+    expect((await routes.query({ path: '/ws/users' })).total).toBe(0);
+    expect((await routes.query({ limit: 2 })).truncated).toBe(true);
+  });
+
+  it('should rescan changed files only', async () => {
+    addGoFile(HANDLERS_GO, handlersGo.replace('import "net/http"', 'import "net/http"\n\nfunc init() { http.HandleFunc("/ready", Show) }'), 'changed');
+    expect(await routes.build()).toMatchObject({ files: 2, routes: 7, updated: 1 });
+
+    routes.setFrameworks(['gin']);
+    expect(await routes.build()).toMatchObject({ routes: 0, updated: 2 });
+  });
+});
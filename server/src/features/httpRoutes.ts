@@ -0,0 +1,507 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { GoToken, GoTokenizer, unquoteGoString } from '../indexer/components/GoTokenizer.js';
+import { assignedName, matchBrackets, selectorEndingAt, splitArgs, TokenSpan } from '../indexer/components/GoTokenUtils.js';
+import { HttpRoutesConfig } from '../config/configurationManager.js';
+import { IndexedSymbol } from '../types.js';
+import { PositionLookup, PositionLookupIndex } from './positionLookup.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type HttpFramework = HttpRoutesConfig['frameworks'][number];
+
+export const HTTP_FRAMEWORKS: HttpFramework[] = ['net/http', 'chi', 'gin', 'echo'];
+
+export interface HttpRoute {
+  /** Upper case; `ANY` for routes that take every method */
+  method: string;
+  /** With the prefixes of the groups it is registered under, e.g. `/api/v1/users/{id}` */
+  path: string;
+  framework: HttpFramework;
+  uri: string;
+  /** 0-based position of the registering call (`Get`, `HandleFunc`, ...) */
+  line: number;
+  character: number;
+  /** Handler expression as written: `listUsers`, `h.ListUsers`, `func literal` */
+  handler: string;
+  /** 0-based position of the handler's name; absent for func literals */
+  handlerLine?: number;
+  handlerCharacter?: number;
+  /** Where the handler is defined; set by queries when it is indexed */
+  definition?: Pick<IndexedSymbol, 'name' | 'kind' | 'containerName' | 'location'>;
+}
+
+export interface HttpRouteIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface HttpRouteQuery {
+  /** Only routes taking this method (case-insensitive); `ANY` routes take every method */
+  method?: string;
+  /** Only routes whose path starts with this, e.g. `/api/v1` */
+  route?: string;
+  framework?: HttpFramework;
+  /** Only routes registered in this file or under this folder */
+  path?: string;
+  /** Number of routes to return (default: 1000) */
+  limit?: number;
+  /** Cancellation token for aborting the handler lookups */
+  cancellationToken?: CancellationToken;
+}
+
+export interface HttpRouteReport {
+  /** Matching routes, before the limit */
+  total: number;
+  /** Matching routes per method, before the limit */
+  byMethod: Record<string, number>;
+  /** In path order, then method */
+  routes: HttpRoute[];
+  truncated: boolean;
+}
+
+/** Raised for queries that cannot be answered, e.g. with route extraction turned off */
+export class RouteQueryError extends Error {}
+
+/** Routes matching a query; how servers expose the index */
+export type HttpRouteSource = (query: HttpRouteQuery) => Promise<HttpRouteReport>;
+
+/** The part of an index the route index reads: the background index or the library Indexer */
+export interface HttpRouteSourceIndex extends PositionLookupIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type RouteReader = (filePath: string) => Promise<string>;
+
+/** A Route or Group callback: registrations on `param` inside it get `prefix` */
+interface RouterScope {
+  param: string;
+  /** Token index of the callback body's closing brace */
+  close: number;
+  previous: string | undefined;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+const IMPORT_PATHS: Array<[RegExp, HttpFramework]> = [
+  [/^net\/http$/, 'net/http'],
+  [/^github\.com\/go-chi\/chi(?:\/v\d+)?$/, 'chi'],
+  [/^github\.com\/gin-gonic\/gin$/, 'gin'],
+  [/^github\.com\/labstack\/echo(?:\/v\d+)?$/, 'echo']
+];
+
+const CHI_METHODS: Record<string, string> = {
+  Get: 'GET', Post: 'POST', Put: 'PUT', Delete: 'DELETE', Patch: 'PATCH',
+  Head: 'HEAD', Options: 'OPTIONS', Connect: 'CONNECT', Trace: 'TRACE'
+};
+
+const GIN_METHODS: Record<string, string> = {
+  GET: 'GET', POST: 'POST', PUT: 'PUT', DELETE: 'DELETE', PATCH: 'PATCH',
+  HEAD: 'HEAD', OPTIONS: 'OPTIONS', Any: 'ANY'
+};
+
+const ECHO_METHODS: Record<string, string> = { ...GIN_METHODS, CONNECT: 'CONNECT', TRACE: 'TRACE' };
+
+/** Calls taking a method and a path (gin Handle, chi Method, echo Add) or a `[METHOD ]path` pattern */
+const PATTERN_CALLS = new Set(['Handle', 'HandleFunc', 'Method', 'MethodFunc', 'Add']);
+
+/** `GET /users/{id}` (Go 1.22 ServeMux) or `example.com/users` */
+const METHOD_PATTERN_RE = /^([A-Z]+)\s+(\S+)$/;
+
+/**
+ * Routes registered in a Go file, for the frameworks it imports: net/http
+ * (`Handle`, `HandleFunc`, Go 1.22 `"GET /path"` patterns), chi (`Get`,
+ * `Post`, ..., `Method`, `Mount`, `Route` and `Group` callbacks), gin and
+ * echo (`GET`, `POST`, ..., `Any`, `Handle`/`Add` and `Group` prefixes).
+ *
+ * Only literal paths count; prefixes follow `v1 := r.Group("/v1")` and
+ * `r.Route("/users", func(r chi.Router) { ... })` within the file.
+ */
+export function findHttpRoutes(uri: string, content: string, frameworks: HttpFramework[] = HTTP_FRAMEWORKS): HttpRoute[] {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens } = tokenizer.tokenize();
+  const imported = importedFrameworks(tokens).filter(framework => frameworks.includes(framework));
+  if (imported.length === 0) {
+    return [];
+  }
+  const has = (framework: HttpFramework) => imported.includes(framework);
+  const closing = matchBrackets(tokens);
+  const prefixes = new Map<string, string>();
+  const scopes: RouterScope[] = [];
+  const routes: HttpRoute[] = [];
+
+  const literal = (arg: TokenSpan | undefined): string | undefined =>
+    arg && arg.end - arg.start === 1 && tokens[arg.start].type === 'string' ? unquoteGoString(tokens[arg.start].text) : undefined;
+
+  for (let i = 0; i < tokens.length; i++) {
+    while (scopes.length > 0 && scopes[scopes.length - 1].close <= i) {
+      const scope = scopes.pop()!;
+      setPrefix(prefixes, scope.param, scope.previous);
+    }
+
+    // receiver . Name (
+    const name = tokens[i];
+    if (name.type !== 'ident' || tokens[i - 1]?.text !== '.' || tokens[i + 1]?.text !== '(') {
+      continue;
+    }
+    const open = i + 1;
+    const args = splitArgs(tokens, open, closing[open]);
+    const receiver = receiverOf(tokens, i - 2, closing, prefixes);
+    if (!receiver) {
+      continue;
+    }
+
+    if (name.text === 'Group' || name.text === 'Route') {
+      const prefix = literal(args[0]);
+      const callback = args[prefix !== undefined ? 1 : 0];
+      const joined = joinPath(receiver.prefix, prefix ?? '');
+      if (callback && tokens[callback.start].text === 'func') {
+        // chi: r.Route("/users", func(r chi.Router) { ... }), r.Group(func(r chi.Router) { ... })
+        const param = tokens[callback.start + 2];
+        const body = tokens.findIndex((token, j) => j > callback.start && token.text === '{');
+        if (param?.type === 'ident' && body !== -1 && body < callback.end) {
+          scopes.push({ param: param.text, close: closing[body] ?? tokens.length, previous: prefixes.get(param.text) });
+          prefixes.set(param.text, joined);
+        }
+      } else if (prefix !== undefined) {
+        // gin, echo: v1 := r.Group("/v1")
+        const target = assignedName(tokens, receiver.start);
+        if (target) {
+          prefixes.set(target, joined);
+        }
+      }
+      continue;
+    }
+
+    const route = toRoute(name.text, args, literal, has);
+    if (!route) {
+      continue;
+    }
+    const handlerArg = route.framework === 'gin' ? args[args.length - 1] : args[route.handlerIndex];
+    if (!handlerArg || handlerArg.start >= handlerArg.end) {
+      continue;
+    }
+    const position = tokenizer.positionAt(name.offset);
+    routes.push({
+      method: route.method,
+      path: joinPath(receiver.prefix, route.path),
+      framework: route.framework,
+      uri,
+      line: position.line,
+      character: position.character,
+      ...describeHandler(tokens, handlerArg, closing, content, tokenizer)
+    });
+  }
+  return routes;
+}
+
+/**
+ * HTTP Route Index - the API routes of Go services across the workspace,
+ * each linked to the definition of its handler.
+ *
+ * Like the comment marker index (features/commentMarkers.ts) it is built
+ * from the files of the background index and rescans only files whose
+ * hash changed; test files are left out. Handlers are resolved at query
+ * time like go-to-definition (features/positionLookup.ts), so `h.List`
+ * goes to the method and `users.List` to the users package.
+ */
+export class HttpRouteIndex {
+  private files: Map<string, { hash: string; routes: HttpRoute[] }> = new Map();
+  private frameworks: HttpFramework[] = HTTP_FRAMEWORKS;
+  private lookup: PositionLookup;
+
+  constructor(
+    private index: HttpRouteSourceIndex,
+    private readFile: RouteReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {
+    this.lookup = new PositionLookup(index);
+  }
+
+  /**
+   * Recognize other frameworks; every file is rescanned on the next build.
+   */
+  setFrameworks(frameworks: HttpFramework[]): void {
+    if (frameworks.join(',') !== this.frameworks.join(',')) {
+      this.frameworks = [...frameworks];
+      this.files.clear();
+    }
+  }
+
+  /**
+   * Bring the index in line with the source index.
+   */
+  async build(options: HttpRouteIndexOptions = {}): Promise<{ files: number; routes: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles())
+      .filter(uri => path.extname(uri) === '.go' && !uri.endsWith('_test.go'))
+      .sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting HTTP routes (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        this.files.set(uri, { hash, routes: findHttpRoutes(uri, await this.readFile(uri), this.frameworks) });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'HTTP routes complete');
+    let routes = 0;
+    for (const file of this.files.values()) {
+      routes += file.routes.length;
+    }
+    return { files: this.files.size, routes, updated };
+  }
+
+  /**
+   * Routes matching the query, with their handler definitions.
+   */
+  async query(query: HttpRouteQuery = {}): Promise<HttpRouteReport> {
+    const method = query.method?.toUpperCase();
+    const scope = query.path ? path.resolve(query.path) : undefined;
+    const limit = query.limit ?? DEFAULT_LIMIT;
+
+    const matches: HttpRoute[] = [];
+    for (const [uri, file] of this.files) {
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      matches.push(...file.routes.filter(route =>
+        (!method || route.method === method || route.method === 'ANY') &&
+        (!query.route || route.path.startsWith(query.route)) &&
+        (!query.framework || route.framework === query.framework)
+      ));
+    }
+    matches.sort((a, b) =>
+      (a.path < b.path ? -1 : a.path > b.path ? 1 : 0) || (a.method < b.method ? -1 : a.method > b.method ? 1 : 0));
+
+    const byMethod: Record<string, number> = {};
+    for (const route of matches) {
+      byMethod[route.method] = (byMethod[route.method] ?? 0) + 1;
+    }
+    const routes: HttpRoute[] = [];
+    for (const route of matches.slice(0, limit)) {
+      if (routes.length % YIELD_INTERVAL === 0) {
+        throwIfCancelled(query.cancellationToken);
+        await yieldToEventLoop();
+      }
+      routes.push(await this.withDefinition(route));
+    }
+    return { total: matches.length, byMethod, routes, truncated: matches.length > limit };
+  }
+
+  private async withDefinition(route: HttpRoute): Promise<HttpRoute> {
+    if (route.handlerLine === undefined || route.handlerCharacter === undefined) {
+      return route;
+    }
+    const found = await this.lookup.lookup(route.uri, route.handlerLine, route.handlerCharacter);
+    const definition = found?.definitions[0];
+    if (!definition) {
+      return route;
+    }
+    const { name, kind, containerName, location } = definition;
+    return { ...route, definition: { name, kind, ...(containerName ? { containerName } : {}), location } };
+  }
+}
+
+/**
+ * Frameworks among the file's imports.
+ */
+function importedFrameworks(tokens: GoToken[]): HttpFramework[] {
+  const frameworks: HttpFramework[] = [];
+  for (let i = 0; i < tokens.length; i++) {
+    if (tokens[i].text !== 'import') {
+      continue;
+    }
+    const grouped = tokens[i + 1]?.text === '(';
+    for (let j = i + 1; j < tokens.length && tokens[j].text !== ')'; j++) {
+      if (tokens[j].type === 'string') {
+        const importPath = unquoteGoString(tokens[j].text);
+        const framework = IMPORT_PATHS.find(([re]) => re.test(importPath))?.[1];
+        if (framework && !frameworks.includes(framework)) {
+          frameworks.push(framework);
+        }
+        if (!grouped) {
+          break;
+        }
+      }
+    }
+  }
+  return frameworks;
+}
+
+/**
+ * The route a call registers, or null when it is not a registration of
+ * an imported framework.
+ */
+function toRoute(
+  name: string,
+  args: TokenSpan[],
+  literal: (arg: TokenSpan | undefined) => string | undefined,
+  has: (framework: HttpFramework) => boolean
+): { method: string; path: string; framework: HttpFramework; handlerIndex: number } | null {
+  const first = literal(args[0]);
+  if (first === undefined) {
+    return null;
+  }
+
+  if (PATTERN_CALLS.has(name)) {
+    const second = literal(args[1]);
+    if (second !== undefined) {
+      // Handle("GET", "/users", h), Method("GET", ...), Add("GET", ...)
+      const framework: HttpFramework | undefined = name === 'Add' ? (has('echo') ? 'echo' : undefined)
+        : name === 'Handle' && has('gin') ? 'gin'
+        : name.startsWith('Method') && has('chi') ? 'chi'
+        : undefined;
+      return framework && second.startsWith('/') ? { method: first.toUpperCase(), path: second, framework, handlerIndex: 2 } : null;
+    }
+    if (name === 'Add' || name.startsWith('Method')) {
+      return null;
+    }
+    const framework: HttpFramework | undefined = has('chi') ? 'chi' : has('net/http') ? 'net/http' : undefined;
+    const pattern = METHOD_PATTERN_RE.exec(first);
+    const target = pattern ? pattern[2] : first;
+    const slash = target.indexOf('/');
+    if (!framework || slash === -1) {
+      return null;
+    }
+    return { method: pattern ? pattern[1] : 'ANY', path: target.slice(slash), framework, handlerIndex: 1 };
+  }
+
+  if (!first.startsWith('/')) {
+    return null;
+  }
+  if (name === 'Mount' && has('chi')) {
+    // Mounted routers take everything below the prefix
+    return { method: 'ANY', path: joinPath(first, '/*'), framework: 'chi', handlerIndex: 1 };
+  }
+  if (CHI_METHODS[name] && has('chi')) {
+    return { method: CHI_METHODS[name], path: first, framework: 'chi', handlerIndex: 1 };
+  }
+  if (GIN_METHODS[name] && has('gin')) {
+    return { method: GIN_METHODS[name], path: first, framework: 'gin', handlerIndex: 1 };
+  }
+  if (ECHO_METHODS[name] && has('echo')) {
+    return { method: ECHO_METHODS[name], path: first, framework: 'echo', handlerIndex: 1 };
+  }
+  return null;
+}
+
+/**
+ * The receiver ending at token `end` (`r`, `s.router`, `r.With(auth)`),
+ * with the prefix routes registered on it get. Null for anything else,
+ * e.g. a package-level call of a function named like a route method.
+ */
+function receiverOf(
+  tokens: GoToken[],
+  end: number,
+  closing: Array<number | undefined>,
+  prefixes: Map<string, string>
+): { start: number; prefix: string } | null {
+  let j = end;
+  const inline: string[] = [];
+  // Calls in the chain: r.With(auth).Get, r.Group("/v1").GET
+  while (tokens[j]?.text === ')') {
+    const open = closing.lastIndexOf(j);
+    if (open < 2 || tokens[open - 1].type !== 'ident' || tokens[open - 2].text !== '.') {
+      return null;
+    }
+    if (tokens[open - 1].text === 'Group' && tokens[open + 1]?.type === 'string') {
+      inline.unshift(unquoteGoString(tokens[open + 1].text));
+    }
+    j = open - 3;
+  }
+
+  const selector = selectorEndingAt(tokens, j);
+  if (!selector) {
+    return null;
+  }
+  const prefix = inline.reduce(joinPath, prefixes.get(selector.name) ?? '');
+  return { start: selector.start, prefix };
+}
+
+function describeHandler(
+  tokens: GoToken[],
+  arg: TokenSpan,
+  closing: Array<number | undefined>,
+  content: string,
+  tokenizer: GoTokenizer
+): Pick<HttpRoute, 'handler' | 'handlerLine' | 'handlerCharacter'> {
+  let { start, end } = arg;
+  // http.HandlerFunc(listUsers) is a conversion; the handler is its operand
+  if (tokens[start + 2]?.text === 'HandlerFunc' && tokens[start + 3]?.text === '(' && closing[start + 3] === end - 1 && end - start > 5) {
+    start += 4;
+    end -= 1;
+  }
+  if (tokens[start].text === 'func') {
+    return { handler: 'func literal' };
+  }
+  const text = content.slice(tokens[start].offset, tokens[end - 1].end).replace(/\s+/g, ' ');
+
+  // A call (NewUserHandler(db), auth(list)) names its function; otherwise the last name
+  let nameToken: GoToken | undefined;
+  for (let j = start; j < end; j++) {
+    if (tokens[j].type === 'ident') {
+      nameToken = tokens[j];
+    }
+    if (tokens[j].text === '(' || tokens[j].text === '{' || tokens[j].text === '[') {
+      break;
+    }
+  }
+  if (!nameToken) {
+    return { handler: text };
+  }
+  const position = tokenizer.positionAt(nameToken.offset);
+  return { handler: text, handlerLine: position.line, handlerCharacter: position.character };
+}
+
+function setPrefix(prefixes: Map<string, string>, name: string, prefix: string | undefined): void {
+  if (prefix === undefined) {
+    prefixes.delete(name);
+  } else {
+    prefixes.set(name, prefix);
+  }
+}
+
+/**
+ * `/api` + `/users` -> `/api/users`; an empty prefix leaves the path as it is.
+ */
+function joinPath(prefix: string, route: string): string {
+  if (!prefix) {
+    return route;
+  }
+  if (!route) {
+    return prefix;
+  }
+  return `${prefix.replace(/\/+$/, '')}/${route.replace(/^\/+/, '')}`;
+}
@@ -0,0 +1,178 @@
+/**
+ * IndexDiff Tests
+ *
+ * Indexes two commits of a throwaway git repository and checks the
+ * exported API changes between them.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { IndexDiff, GitRunner } from './indexDiff.js';
+import { LanguageRouter } from '../indexer/languageRouter.js';
+import { SymbolIndexer } from '../indexer/symbolIndexer.js';
+
+const personV1 = `package people
+
+type Person struct {
+	Name string
+	age  int
+}
+
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+
+func (p *Person) Greet() string {
+	return "hi " + p.Name
+}
+
+func Legacy() {}
+
+func helper() {}
+`;
+
+const personV2 = `package people
+
+type Person struct {
+	Name  string
+	Email string
+}
+
+func NewPerson(name string, email string) *Person {
+	return &Person{Name: name, Email: email}
+}
+
+func (p *Person) Greet() string {
+	return "hello " + p.Name
+}
+
+func helper(x int) {}
+`;
+
+// Methods of unexported types are not API
+const registryV2 = `package people
+
+type Registry struct{}
+
+func (r *registry) Add() {}
+
+type registry struct{}
+`;
+
+describe('IndexDiff', () => {
+  let root: string;
+  let cacheDir: string;
+  let gitCalls: string[][];
+
+  function git(args: string[]): string {
+    return execFileSync('git', args, {
+      cwd: root,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com',
+        GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com'
+      }
+    });
+  }
+
+  function commit(files: Record<string, string>, message: string): void {
+    for (const [file, content] of Object.entries(files)) {
+      fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+      fs.writeFileSync(path.join(root, file), content);
+      git(['add', file]);
+    }
+    git(['commit', '-q', '-m', message]);
+  }
+
+  function createDiff(): IndexDiff {
+    const runGit: GitRunner = async args => {
+      gitCalls.push(args);
+      return git(args);
+    };
+    return new IndexDiff(new LanguageRouter(new SymbolIndexer()), root, cacheDir, runGit);
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'index-diff-'));
+    cacheDir = path.join(root, '.smart-index');
+    gitCalls = [];
+    git(['init', '-q']);
+    commit({ 'pkg/people/person.go': personV1, 'pkg/people/person_test.go': 'package people\n\nfunc TestX() {}\n' }, 'v1');
+    git(['tag', 'v1']);
+    commit({ 'pkg/people/person.go': personV2, 'pkg/people/registry.go': registryV2 }, 'v2');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should report added, removed and signature-changed exported symbols', async () => {
+    const result = await createDiff().diff('v1', 'HEAD');
+
+    expect(result.added.map(s => `${s.package} ${s.qualifiedName} ${s.kind}`)).toEqual([
+      'pkg/people Person.Email field',
+      'pkg/people Registry struct'
+    ]);
+    expect(result.removed.map(s => s.qualifiedName)).toEqual(['Legacy']);
+    expect(result.changed.map(c => [c.after.qualifiedName, c.before.signature, c.after.signature])).toEqual([
+      ['NewPerson', 'func NewPerson(name string) *Person', 'func NewPerson(name string, email string) *Person']
+    ]);
+    expect(result.head.revision).toBe('HEAD');
+    expect(result.base.commit).toMatch(/^[0-9a-f]{40}$/);
+  });
+
+  it('should reuse cached revision snapshots', async () => {
+    await createDiff().diff('v1', 'HEAD');
+    expect(fs.readdirSync(path.join(cacheDir, 'revisions'))).toHaveLength(2);
+
+    gitCalls = [];
+    await createDiff().diff('v1', 'HEAD');
+    expect(gitCalls.every(args => args[0] === 'rev-parse')).toBe(true);
+  });
+
+  it('should render a markdown report and reject unknown revisions', async () => {
+    const diff = createDiff();
+    const markdown = diff.toMarkdown(await diff.diff('v1', 'HEAD'));
+
+    expect(markdown).toContain('2 added, 1 removed, 1 changed');
+    expect(markdown).toContain('## Removed\n\n- `pkg/people` **Legacy** (function) — `func Legacy()`');
+    expect(markdown).toContain('  - after: `func NewPerson(name string, email string) *Person`');
+
+    await expect(diff.diff('no-such-branch', 'HEAD')).rejects.toThrow('Unknown revision: no-such-branch');
+  });
+
+  it('should route changes to reviewers by the head revision\'s CODEOWNERS', async () => {
+    commit({ '.github/CODEOWNERS': '* @org/maintainers\n/pkg/people/registry.go @org/registry\n' }, 'owners');
+    const diff = createDiff();
+    const result = await diff.diff('v1', 'HEAD');
+
+    expect(result.reviewers).toEqual([
+      { owner: '@org/maintainers', added: 1, removed: 1, changed: 1 },
+      { owner: '@org/registry', added: 1, removed: 0, changed: 0 }
+    ]);
+    expect(result.added.map(s => [s.qualifiedName, s.owners])).toEqual([
+      ['Person.Email', ['@org/maintainers']],
+      ['Registry', ['@org/registry']]
+    ]);
+
+    const markdown = diff.toMarkdown(result);
+    expect(markdown).toContain('## Reviewers\n\n- @org/maintainers: 1 added, 1 removed, 1 changed\n- @org/registry: 1 added');
+    expect(markdown).toContain('**Registry** (struct) — `type Registry struct` (@org/registry)');
+
+    // The owners are cached with the snapshot
+    gitCalls = [];
+    expect((await createDiff().diff('v1', 'HEAD')).reviewers).toEqual(result.reviewers);
+    expect(gitCalls.every(args => args[0] === 'rev-parse')).toBe(true);
+  });
+
+  it('should have no reviewers without a CODEOWNERS file', async () => {
+    const result = await createDiff().diff('v1', 'HEAD');
+    expect(result.reviewers).toEqual([]);
+    expect(result.added.every(s => s.owners === undefined)).toBe(true);
+  });
+});
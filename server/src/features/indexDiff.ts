@@ -0,0 +1,508 @@
+import { LanguageRouter } from '../indexer/languageRouter.js';
+import { createDefaultParserRegistry } from '../indexer/parserRegistry.js';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { CODEOWNERS_LOCATIONS, CodeOwnersRule, parseCodeOwners, resolveOwners } from '../utils/codeOwners.js';
+import { simpleGit } from 'simple-git';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/**
+ * Runs git with the given arguments in the workspace root and returns stdout.
+ */
+export type GitRunner = (args: string[]) => Promise<string>;
+
+/**
+ * One exported symbol of a revision.
+ */
+export interface ApiSymbol {
+  /** Package directory (workspace-relative, '.' for the root) */
+  package: string;
+  /** `Container.name` for members, else the name */
+  qualifiedName: string;
+  kind: string;
+  /**
+   * Declaration header, whitespace collapsed (`func NewPerson(name string) *Person`);
+   * name and type for Go fields, variables and constants (`Email string`)
+   */
+  signature: string;
+  file: string;
+  /** Zero-based line */
+  line: number;
+  /** False for Go structs `==` cannot compare: a field, exported or not, is a slice, map or func */
+  comparable?: boolean;
+  /** Owners of the file per the head revision's CODEOWNERS (set in diff results only) */
+  owners?: string[];
+}
+
+/**
+ * Exported API of one commit, cached under `<cacheDirectory>/revisions/<commit>.json`.
+ */
+export interface ApiSnapshot {
+  commit: string;
+  symbols: ApiSymbol[];
+  /** The commit's CODEOWNERS file, if it has one */
+  codeOwners?: { file: string; content: string };
+}
+
+export interface ApiChange {
+  before: ApiSymbol;
+  after: ApiSymbol;
+}
+
+/**
+ * The API changes one owner should review.
+ */
+export interface ReviewerRoute {
+  /** As written in CODEOWNERS; undefined for changes nobody owns */
+  owner?: string;
+  added: number;
+  removed: number;
+  changed: number;
+}
+
+export interface IndexDiffResult {
+  base: { revision: string; commit: string };
+  head: { revision: string; commit: string };
+  added: ApiSymbol[];
+  removed: ApiSymbol[];
+  changed: ApiChange[];
+  /**
+   * Owners of the changed files per the head revision's CODEOWNERS, most
+   * changes first, unowned changes last; empty without a CODEOWNERS file.
+   */
+  reviewers: ReviewerRoute[];
+}
+
+export interface IndexDiffOptions {
+  /** Cancellation token for aborting the diff */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting indexing progress */
+  onProgress?: ProgressCallback;
+}
+
+const SNAPSHOT_VERSION = 3;
+
+const YIELD_INTERVAL = 50;
+
+const TS_EXTENSIONS = ['.ts', '.tsx', '.js', '.jsx', '.mts', '.cts', '.mjs', '.cjs'];
+
+const SKIPPED_DIRECTORIES = /(^|\/)(node_modules|vendor|dist|out|build|testdata)\//;
+
+const TEST_FILES = /(\.(test|spec)\.[jt]sx?|_test\.go|(^|\/)test_[^/]*\.py)$/;
+
+const TYPE_KINDS = new Set(['class', 'interface', 'struct', 'enum', 'type']);
+
+const VALUE_KINDS = new Set(['field', 'variable', 'constant']);
+
+const SIGNATURE_LIMIT = 300;
+
+/**
+ * Index Diff - API change report between two git revisions.
+ *
+ * Each revision is indexed from `git show <commit>:<file>` (without
+ * touching the working tree) with the same parsers as the background
+ * index, reduced to its exported symbols and cached per commit. The diff
+ * reports exported symbols that were added, removed, or whose declaration
+ * header changed. Symbols are matched by package directory, qualified
+ * name and kind, so moving a declaration between files of one package is
+ * not a change. Test files are ignored. Changes are routed to reviewers by
+ * the head revision's CODEOWNERS, as a pull request would be.
+ */
+export class IndexDiff {
+  private runGit: GitRunner;
+  private extensions: Set<string>;
+
+  constructor(
+    private languageRouter: LanguageRouter,
+    private workspaceRoot: string,
+    private cacheDir?: string,
+    runGit?: GitRunner
+  ) {
+    const git = simpleGit(workspaceRoot);
+    this.runGit = runGit ?? (args => git.raw(args));
+    this.extensions = new Set([...TS_EXTENSIONS, ...createDefaultParserRegistry().getExtensions()]);
+  }
+
+  async diff(baseRevision: string, headRevision: string, options: IndexDiffOptions = {}): Promise<IndexDiffResult> {
+    return (await this.compare(baseRevision, headRevision, options)).result;
+  }
+
+  /**
+   * The diff with the snapshots it was computed from, for reports that
+   * look at the rest of the API (see features/semverCheck.ts).
+   */
+  async compare(
+    baseRevision: string,
+    headRevision: string,
+    options: IndexDiffOptions = {}
+  ): Promise<{ result: IndexDiffResult; base: ApiSnapshot; head: ApiSnapshot }> {
+    const baseCommit = await this.resolveCommit(baseRevision);
+    const headCommit = await this.resolveCommit(headRevision);
+
+    const base = await this.getSnapshot(baseCommit, options, 0);
+    const head = await this.getSnapshot(headCommit, options, 1);
+
+    const keyOf = (s: ApiSymbol) => `${s.package}\0${s.qualifiedName}\0${s.kind}`;
+    const group = (symbols: ApiSymbol[]) => {
+      const groups = new Map<string, ApiSymbol[]>();
+      for (const symbol of symbols) {
+        const key = keyOf(symbol);
+        groups.set(key, [...(groups.get(key) ?? []), symbol]);
+      }
+      return groups;
+    };
+    const before = group(base.symbols);
+    const after = group(head.symbols);
+
+    const result: IndexDiffResult = {
+      base: { revision: baseRevision, commit: baseCommit },
+      head: { revision: headRevision, commit: headCommit },
+      added: [],
+      removed: [],
+      changed: [],
+      reviewers: []
+    };
+
+    for (const [key, symbols] of after) {
+      const previous = before.get(key);
+      if (!previous) {
+        result.added.push(...symbols);
+        continue;
+      }
+      // Overloads: compare the sets of signatures
+      const oldSignatures = previous.map(s => s.signature).sort().join('\n');
+      const newSignatures = symbols.map(s => s.signature).sort().join('\n');
+      if (oldSignatures !== newSignatures) {
+        result.changed.push({ before: previous[0], after: symbols[0] });
+      }
+    }
+    for (const [key, symbols] of before) {
+      if (!after.has(key)) {
+        result.removed.push(...symbols);
+      }
+    }
+
+    const compare = (a: ApiSymbol, b: ApiSymbol) =>
+      a.package.localeCompare(b.package) || a.qualifiedName.localeCompare(b.qualifiedName) || a.kind.localeCompare(b.kind);
+    result.added.sort(compare);
+    result.removed.sort(compare);
+    result.changed.sort((a, b) => compare(a.after, b.after));
+
+    if (head.codeOwners) {
+      routeToReviewers(result, parseCodeOwners(head.codeOwners.content));
+    }
+    return { result, base, head };
+  }
+
+  /**
+   * Exported API of a commit: from the snapshot cache, else indexed from git.
+   */
+  async getSnapshot(commit: string, options: IndexDiffOptions = {}, step = 0): Promise<ApiSnapshot> {
+    const cached = await this.readSnapshot(commit);
+    if (cached) {
+      return cached;
+    }
+
+    const { cancellationToken, onProgress } = options;
+    const listing = (await this.runGit(['-c', 'core.quotePath=false', 'ls-tree', '-r', '--name-only', commit]))
+      .split('\n').map(f => f.trim());
+    const files = listing.filter(f => this.isApiFile(f));
+
+    const symbols: ApiSymbol[] = [];
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(step * files.length + i, files.length * 2, `Indexing ${commit.slice(0, 8)} (${i}/${files.length})`);
+      }
+
+      let content: string;
+      try {
+        content = await this.runGit(['show', `${commit}:./${files[i]}`]);
+      } catch {
+        continue;
+      }
+      const uri = path.join(this.workspaceRoot, ...files[i].split('/'));
+      const result = await this.languageRouter.indexFile(uri, content);
+      symbols.push(...toApiSymbols(result.symbols, files[i], content));
+    }
+
+    const snapshot: ApiSnapshot = { commit, symbols: resolveMembers(symbols) };
+    const codeOwnersFile = CODEOWNERS_LOCATIONS.find(location => listing.includes(location));
+    if (codeOwnersFile) {
+      try {
+        snapshot.codeOwners = { file: codeOwnersFile, content: await this.runGit(['show', `${commit}:./${codeOwnersFile}`]) };
+      } catch {
+        // Unreadable: the diff has no reviewers
+      }
+    }
+    await this.writeSnapshot(snapshot);
+    return snapshot;
+  }
+
+  /**
+   * Markdown report for pull request review.
+   */
+  toMarkdown(result: IndexDiffResult): string {
+    const short = (ref: { revision: string; commit: string }) => `\`${ref.revision}\` (${ref.commit.slice(0, 8)})`;
+    const lines = [
+      `# API changes: ${short(result.base)} → ${short(result.head)}`,
+      '',
+      `${result.added.length} added, ${result.removed.length} removed, ${result.changed.length} changed`
+    ];
+
+    const section = (title: string, symbols: ApiSymbol[]) => {
+      if (symbols.length === 0) {
+        return;
+      }
+      lines.push('', `## ${title}`, '');
+      for (const symbol of symbols) {
+        lines.push(`- \`${symbol.package}\` **${symbol.qualifiedName}** (${symbol.kind}) — \`${symbol.signature}\`${ownedBy(symbol)}`);
+      }
+    };
+    if (result.reviewers.length > 0) {
+      lines.push('', '## Reviewers', '');
+      for (const route of result.reviewers) {
+        const counts = (['added', 'removed', 'changed'] as const)
+          .filter(kind => route[kind] > 0)
+          .map(kind => `${route[kind]} ${kind}`);
+        lines.push(`- ${route.owner ?? '_No owner_'}: ${counts.join(', ')}`);
+      }
+    }
+    section('Removed', result.removed);
+    section('Added', result.added);
+
+    if (result.changed.length > 0) {
+      lines.push('', '## Changed', '');
+      for (const { before, after } of result.changed) {
+        lines.push(
+          `- \`${after.package}\` **${after.qualifiedName}** (${after.kind}) — ${after.file}:${after.line + 1}${ownedBy(after)}`,
+          `  - before: \`${before.signature}\``,
+          `  - after: \`${after.signature}\``
+        );
+      }
+    }
+    return lines.join('\n') + '\n';
+  }
+
+  private async resolveCommit(revision: string): Promise<string> {
+    try {
+      return (await this.runGit(['rev-parse', '--verify', `${revision}^{commit}`])).trim();
+    } catch {
+      throw new Error(`Unknown revision: ${revision}`);
+    }
+  }
+
+  private isApiFile(file: string): boolean {
+    return file.length > 0 &&
+      this.extensions.has(path.extname(file).toLowerCase()) &&
+      !file.endsWith('.d.ts') &&
+      !SKIPPED_DIRECTORIES.test(file) &&
+      !TEST_FILES.test(file);
+  }
+
+  private snapshotPath(commit: string): string | undefined {
+    return this.cacheDir ? path.join(this.cacheDir, 'revisions', `${commit}.json`) : undefined;
+  }
+
+  private async readSnapshot(commit: string): Promise<ApiSnapshot | undefined> {
+    const file = this.snapshotPath(commit);
+    if (!file) {
+      return undefined;
+    }
+    try {
+      const data = JSON.parse(await fsPromises.readFile(file, 'utf-8'));
+      if (data.version !== SNAPSHOT_VERSION) {
+        return undefined;
+      }
+      return { commit, symbols: data.symbols, ...(data.codeOwners && { codeOwners: data.codeOwners }) };
+    } catch {
+      return undefined;
+    }
+  }
+
+  private async writeSnapshot(snapshot: ApiSnapshot): Promise<void> {
+    const file = this.snapshotPath(snapshot.commit);
+    if (!file) {
+      return;
+    }
+    try {
+      await fsPromises.mkdir(path.dirname(file), { recursive: true });
+      await fsPromises.writeFile(file, JSON.stringify({
+        version: SNAPSHOT_VERSION,
+        symbols: snapshot.symbols,
+        codeOwners: snapshot.codeOwners
+      }));
+    } catch {
+      // The snapshot is only a cache
+    }
+  }
+}
+
+/**
+ * Set the owners of every reported symbol (removed ones by their old file)
+ * and count the changes per owner.
+ */
+function routeToReviewers(result: IndexDiffResult, rules: CodeOwnersRule[]): void {
+  const routes = new Map<string | undefined, ReviewerRoute>();
+  const route = (symbol: ApiSymbol, kind: 'added' | 'removed' | 'changed'): ApiSymbol => {
+    const owners = resolveOwners(rules, symbol.file);
+    for (const owner of owners.length > 0 ? owners : [undefined]) {
+      let entry = routes.get(owner);
+      if (!entry) {
+        entry = { ...(owner !== undefined && { owner }), added: 0, removed: 0, changed: 0 };
+        routes.set(owner, entry);
+      }
+      entry[kind]++;
+    }
+    return owners.length > 0 ? { ...symbol, owners } : symbol;
+  };
+
+  result.added = result.added.map(symbol => route(symbol, 'added'));
+  result.removed = result.removed.map(symbol => route(symbol, 'removed'));
+  result.changed = result.changed.map(({ before, after }) => ({ before, after: route(after, 'changed') }));
+
+  const total = (entry: ReviewerRoute) => entry.added + entry.removed + entry.changed;
+  result.reviewers = [...routes.values()].sort((a, b) =>
+    (a.owner === undefined ? 1 : 0) - (b.owner === undefined ? 1 : 0) ||
+    total(b) - total(a) ||
+    (a.owner! < b.owner! ? -1 : 1));
+}
+
+function ownedBy(symbol: ApiSymbol): string {
+  return symbol.owners ? ` (${symbol.owners.join(' ')})` : '';
+}
+
+/** An exported symbol before resolveMembers */
+type ProvisionalApiSymbol = ApiSymbol & { container?: string; fieldTypes?: string[] };
+
+/**
+ * Exported symbols of one file. Members are kept provisionally (with their
+ * container) and filtered by resolveMembers once all files are known, as
+ * Go methods may be declared apart from their type.
+ */
+function toApiSymbols(symbols: IndexedSymbol[], file: string, content: string): ProvisionalApiSymbol[] {
+  const lineStarts = [0];
+  for (let i = 0; i < content.length; i++) {
+    if (content[i] === '\n') {
+      lineStarts.push(i + 1);
+    }
+  }
+  const dir = path.posix.dirname(file);
+  const pkg = dir === '' ? '.' : dir;
+
+  // Field types of Go structs, unexported fields included, for resolveMembers to tell comparable ones
+  const fieldTypes = new Map<string, string[]>();
+  for (const symbol of symbols) {
+    const type = (symbol.metadata?.go as GoSymbolMetadata | undefined)?.type;
+    if (symbol.kind === 'field' && symbol.containerName && type) {
+      fieldTypes.set(symbol.containerName, [...(fieldTypes.get(symbol.containerName) ?? []), type]);
+    }
+  }
+
+  const result: ProvisionalApiSymbol[] = [];
+  for (const symbol of symbols) {
+    if (symbol.isDefinition === false || symbol.isExported === false ||
+        symbol.visibility === 'private' || symbol.visibility === 'protected') {
+      continue;
+    }
+    if (!symbol.containerName && !symbol.isExported) {
+      continue;
+    }
+    const start = lineStarts[symbol.range.startLine] + symbol.range.startCharacter;
+    const end = (lineStarts[symbol.range.endLine] ?? content.length) + symbol.range.endCharacter;
+    // Go fields, variables and constants are indexed by their name only
+    const goType = (symbol.metadata?.go as GoSymbolMetadata | undefined)?.type;
+    result.push({
+      package: pkg,
+      qualifiedName: symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name,
+      kind: symbol.kind,
+      signature: goType && VALUE_KINDS.has(symbol.kind)
+        ? `${symbol.name} ${goType}`
+        : declarationHeader(content.slice(start, Math.max(start, end)), symbol.kind),
+      file,
+      line: symbol.location.line,
+      ...(symbol.containerName && { container: symbol.containerName }),
+      ...(symbol.kind === 'struct' && fieldTypes.has(symbol.name) && { fieldTypes: fieldTypes.get(symbol.name) })
+    });
+  }
+  return result;
+}
+
+/**
+ * Keep members only when their container is an exported type of the same
+ * package (drops locals of functions and members of unexported types).
+ */
+function resolveMembers(symbols: ProvisionalApiSymbol[]): ApiSymbol[] {
+  const exportedTypes = new Set(
+    symbols.filter(s => !s.container && TYPE_KINDS.has(s.kind)).map(s => `${s.package}\0${s.qualifiedName}`)
+  );
+  const structFields = new Map(
+    symbols.filter(s => s.fieldTypes).map(s => [`${s.package}\0${s.qualifiedName}`, s.fieldTypes!])
+  );
+  return symbols
+    .filter(s => !s.container || exportedTypes.has(`${s.package}\0${s.container}`))
+    .map(({ container: _container, fieldTypes: _fieldTypes, ...symbol }) =>
+      structFields.has(`${symbol.package}\0${symbol.qualifiedName}`) &&
+      !isComparableStruct(symbol.package, symbol.qualifiedName, structFields, new Set())
+        ? { ...symbol, comparable: false }
+        : symbol);
+}
+
+/**
+ * Whether `==` compares a Go struct: none of its fields is a slice, map
+ * or func, or a struct of the package that is not comparable. Types of
+ * other packages count as comparable.
+ */
+function isComparableStruct(pkg: string, name: string, structFields: Map<string, string[]>, visiting: Set<string>): boolean {
+  const key = `${pkg}\0${name}`;
+  if (visiting.has(key)) {
+    return true;
+  }
+  visiting.add(key);
+  return (structFields.get(key) ?? []).every(type => {
+    let text = type.trim();
+    // Arrays compare by their elements
+    while (/^\[[^\]]+\]/.test(text)) {
+      text = text.replace(/^\[[^\]]+\]/, '');
+    }
+    if (text.startsWith('[]') || text.startsWith('map[') || /^func\b/.test(text)) {
+      return false;
+    }
+    const named = text.replace(/\[.*\]$/, '');
+    return !structFields.has(`${pkg}\0${named}`) || isComparableStruct(pkg, named, structFields, visiting);
+  });
+}
+
+/**
+ * Declaration text up to its body: the first top-level `{`, an initializer
+ * `=` (except for type declarations) or the end of the first line outside
+ * brackets. Whitespace is collapsed.
+ */
+function declarationHeader(text: string, kind: string): string {
+  let depth = 0;
+  let end = text.length;
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i];
+    if (c === '(' || c === '[' || c === '<') {
+      depth++;
+    } else if ((c === ')' || c === ']' || (c === '>' && text[i - 1] !== '=')) && depth > 0) {
+      depth--;
+    } else if (depth === 0 && (c === '{' || c === '\n')) {
+      end = i;
+      break;
+    } else if (depth === 0 && c === '=' && kind !== 'type' && !'=>!<'.includes(text[i + 1] ?? '') && !'=!<>'.includes(text[i - 1] ?? '')) {
+      end = i;
+      break;
+    }
+  }
+  const header = text.slice(0, end).replace(/\s+/g, ' ').trim().replace(/^export\s+/, '');
+  return header.length > SIGNATURE_LIMIT ? header.slice(0, SIGNATURE_LIMIT) + '…' : header;
+}
@@ -0,0 +1,132 @@
+/**
+ * Index Shards Tests
+ *
+ * Verifies directory and module sharding, partial rebuilds, merging
+ * with last-shard-wins semantics and reproducible output.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { IndexShardBuilder, mergeShards, shardFileName } from './indexShards.js';
+import { BinaryIndex } from '../index/binaryIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+
+describe('Index Shards', () => {
+  let testDir: string;
+  let index: MockBackgroundIndex;
+  const ws = '/ws';
+  const files = {
+    main: '/ws/main.go',
+    api: '/ws/services/api/handler.go',
+    apiInternal: '/ws/services/api/internal/db.go',
+    web: '/ws/services/web/src/app.ts',
+    lib: '/ws/lib/strings.go',
+    external: '/gomodcache/golang.org/x/text/text.go'
+  };
+  const manifests = new Set(['/ws/go.mod', '/ws/services/api/go.mod', '/ws/services/web/package.json']);
+
+  function addFile(uri: string, names: string[]): void {
+    index.addFile(uri, names.map(name => createTestSymbol({ id: `${uri}#${name}`, name, filePath: uri })));
+  }
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-shards-'));
+    index = new MockBackgroundIndex();
+    addFile(files.main, ['main']);
+    addFile(files.api, ['Handle']);
+    addFile(files.apiInternal, ['Query']);
+    addFile(files.web, ['App']);
+    addFile(files.lib, ['Trim']);
+    addFile(files.external, ['Transform']);
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  function builder(): IndexShardBuilder {
+    return new IndexShardBuilder(index.asBackgroundIndex(), ws, filePath => manifests.has(filePath));
+  }
+
+  it('should shard by directory depth', async () => {
+    const result = await builder().build(testDir, { by: 'directory', depth: 2 });
+
+    expect(result.shards.map(shard => [shard.root, shard.files])).toEqual([
+      ['.', 1],
+      ['_external', 1],
+      ['lib', 1],
+      ['services/api', 2],
+      ['services/web', 1]
+    ]);
+    expect(path.basename(result.shards[3].outputPath)).toBe('services__api.sidx');
+
+    const api = await BinaryIndex.open(result.shards[3].outputPath);
+    expect(await api.getFileUris()).toEqual([files.api, files.apiInternal]);
+    await api.close();
+  });
+
+  it('should shard by nearest module manifest and rebuild only included shards', async () => {
+    const all = await builder().build(testDir, { by: 'module' });
+    expect(all.shards.map(shard => [shard.root, shard.files])).toEqual([
+      ['.', 2],
+      ['_external', 1],
+      ['services/api', 2],
+      ['services/web', 1]
+    ]);
+
+    // A directory inside a module rebuilds the whole module shard
+    const partialDir = path.join(testDir, 'partial');
+    const partial = await builder().build(partialDir, { by: 'module', include: ['services/api/internal'] });
+    expect(partial.shards.map(shard => [shard.root, shard.files])).toEqual([['services/api', 2]]);
+
+    // Modules below an included directory are rebuilt too; services/ itself belongs to the root module
+    const parent = await builder().build(path.join(testDir, 'parent'), { by: 'module', include: ['services'] });
+    expect(parent.shards.map(shard => shard.root)).toEqual(['.', 'services/api', 'services/web']);
+  });
+
+  it('should merge shards with the last shard winning per file', async () => {
+    const shardDir = path.join(testDir, 'shards');
+    await builder().build(shardDir, { by: 'module' });
+
+    // A fresh build of one module after Handle was renamed
+    addFile(files.api, ['HandleRequest']);
+    const freshDir = path.join(testDir, 'fresh');
+    await builder().build(freshDir, { by: 'module', include: ['services/api'] });
+
+    const outputPath = path.join(testDir, 'full.sidx');
+    const result = await mergeShards([shardDir, path.join(freshDir, shardFileName('services/api'))], outputPath);
+    expect(result).toMatchObject({ shards: 5, files: 6, symbols: 6, overriddenFiles: 2 });
+
+    const merged = await BinaryIndex.open(outputPath);
+    expect(await merged.findByName('Handle')).toEqual([]);
+    expect((await merged.findByName('HandleRequest')).map(s => s.filePath)).toEqual([files.api]);
+    expect((await merged.findByName('Transform')).map(s => s.filePath)).toEqual([files.external]);
+    await merged.close();
+
+    await expect(mergeShards([freshDir + '-missing'], outputPath)).rejects.toThrow();
+    fs.mkdirSync(path.join(testDir, 'empty'));
+    await expect(mergeShards([path.join(testDir, 'empty')], outputPath)).rejects.toThrow('No shards to merge');
+  });
+
+  it('should write byte-identical shards and merges when reproducible', async () => {
+    const build = async (name: string) => {
+      const dir = path.join(testDir, name);
+      await builder().build(dir, { by: 'module', reproducible: true });
+      await mergeShards([dir], path.join(testDir, `${name}.sidx`), { reproducible: true });
+      return dir;
+    };
+    const first = await build('first');
+    await new Promise(resolve => setTimeout(resolve, 5));
+    const second = await build('second');
+
+    const shardFiles = fs.readdirSync(first).sort();
+    expect(fs.readdirSync(second).sort()).toEqual(shardFiles);
+    for (const file of shardFiles) {
+      expect(fs.readFileSync(path.join(second, file)).equals(fs.readFileSync(path.join(first, file)))).toBe(true);
+    }
+    expect(fs.readFileSync(`${second}.sidx`).equals(fs.readFileSync(`${first}.sidx`))).toBe(true);
+  });
+});
@@ -0,0 +1,289 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { BinaryIndex, BinaryIndexCompression, BinaryIndexWriter, BINARY_INDEX_EXTENSION } from '../index/binaryIndex.js';
+import * as fs from 'fs';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+import { buildTimestamp } from '../utils/buildTimestamp.js';
+
+/**
+ * How files are grouped into shards:
+ * - `directory`: by the first `depth` segments of the workspace-relative path
+ * - `module`: by the nearest directory with a module manifest (go.mod,
+ *   package.json, Cargo.toml, ...)
+ */
+export type ShardStrategy = 'directory' | 'module';
+
+export const SHARD_STRATEGIES: ShardStrategy[] = ['directory', 'module'];
+
+export interface ShardBuildOptions {
+  by?: ShardStrategy;
+  /** Path segments per shard for the `directory` strategy (default 1) */
+  depth?: number;
+  /** Workspace-relative directories to build; unset builds every shard */
+  include?: string[];
+  /** Block compression of the shard files (default none) */
+  compression?: BinaryIndexCompression;
+  /** Record build time 0 (see utils/buildTimestamp.ts) */
+  reproducible?: boolean;
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ShardInfo {
+  /** Workspace-relative shard root ('.' for the workspace root) */
+  root: string;
+  outputPath: string;
+  files: number;
+  symbols: number;
+  bytes: number;
+}
+
+export interface ShardBuildResult {
+  outputDir: string;
+  shards: ShardInfo[];
+}
+
+export interface ShardMergeOptions {
+  /** Block compression of the merged file (default none) */
+  compression?: BinaryIndexCompression;
+  /** Record build time 0 (see utils/buildTimestamp.ts) */
+  reproducible?: boolean;
+  /** Cancellation token for aborting the merge */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting merge progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ShardMergeResult {
+  outputPath: string;
+  shards: number;
+  files: number;
+  symbols: number;
+  bytes: number;
+  /** Files present in more than one shard; the last shard listing them wins */
+  overriddenFiles: number;
+}
+
+/** Checks whether a path exists; injectable for tests */
+export type ExistsFn = (filePath: string) => boolean;
+
+const YIELD_INTERVAL = 50;
+
+/** Shard for indexed files outside the workspace (module caches, SDKs) */
+export const EXTERNAL_SHARD = '_external';
+
+const MODULE_MANIFESTS = [
+  'go.mod',
+  'package.json',
+  'Cargo.toml',
+  'pyproject.toml',
+  'setup.py',
+  'pom.xml',
+  'build.gradle',
+  'build.gradle.kts'
+];
+
+/**
+ * Index Shards - split the index into per-directory or per-module .sidx
+ * files and merge them back.
+ *
+ * Each shard is an ordinary binary static index, so CI can rebuild only the
+ * shards of affected modules (`include`) and either merge them into one
+ * file or load the shard directory as is through
+ * `smartIndexer.staticIndex.path`.
+ */
+export class IndexShardBuilder {
+  private manifestDirs: Map<string, string | null> = new Map();
+
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private workspaceRoot: string,
+    private exists: ExistsFn = fs.existsSync
+  ) {}
+
+  /**
+   * Write one `<shard>.sidx` per shard to outputDir.
+   */
+  async build(outputDir: string, options: ShardBuildOptions = {}): Promise<ShardBuildResult> {
+    const { by = 'directory', depth = 1, compression, reproducible, cancellationToken, onProgress } = options;
+    const include = options.include?.map(dir => toRelative(this.workspaceRoot, path.resolve(this.workspaceRoot, dir)));
+    // A shard owning an included directory is rebuilt whole, so it can replace its previous build
+    const owners = new Set(include?.map(dir => this.shardRoot(dir, by, depth)));
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+
+    const groups = new Map<string, string[]>();
+    for (const file of files) {
+      const root = this.shardRoot(toRelative(this.workspaceRoot, path.dirname(file)), by, depth);
+      if (include && !owners.has(root) && !include.some(dir => isWithin(root, dir))) {
+        continue;
+      }
+      const group = groups.get(root);
+      if (group) {
+        group.push(file);
+      } else {
+        groups.set(root, [file]);
+      }
+    }
+
+    const shards: ShardInfo[] = [];
+    const createdAt = buildTimestamp(reproducible);
+    const total = [...groups.values()].reduce((sum, group) => sum + group.length, 0);
+    let processed = 0;
+
+    for (const root of [...groups.keys()].sort()) {
+      const writer = new BinaryIndexWriter();
+      let fileCount = 0;
+      for (const file of groups.get(root)!) {
+        if (processed % YIELD_INTERVAL === 0) {
+          throwIfCancelled(cancellationToken);
+          await yieldToEventLoop();
+          onProgress?.(processed, total, `Building shard ${root} (${processed}/${total})`);
+        }
+        processed++;
+        const symbols = await this.backgroundIndex.getFileSymbols(file);
+        if (symbols.length > 0) {
+          writer.addFile(file, symbols);
+          fileCount++;
+        }
+      }
+      if (fileCount === 0) {
+        continue;
+      }
+
+      throwIfCancelled(cancellationToken);
+      const outputPath = path.join(outputDir, shardFileName(root));
+      const stats = await writer.finish(outputPath, { compression, createdAt });
+      shards.push({ root, outputPath, ...stats });
+    }
+
+    onProgress?.(total, total, 'Shard build complete');
+    return { outputDir, shards };
+  }
+
+  /**
+   * Shard root of a workspace-relative directory.
+   */
+  private shardRoot(dir: string, by: ShardStrategy, depth: number): string {
+    if (dir === EXTERNAL_SHARD || dir === '.') {
+      return dir;
+    }
+    if (by === 'module') {
+      const manifestDir = this.findManifestDir(path.join(this.workspaceRoot, dir));
+      return manifestDir === null ? '.' : toRelative(this.workspaceRoot, manifestDir);
+    }
+    return dir.split('/').slice(0, Math.max(1, depth)).join('/');
+  }
+
+  /**
+   * Nearest directory at or above dir (inside the workspace) with a module manifest.
+   */
+  private findManifestDir(dir: string): string | null {
+    const cached = this.manifestDirs.get(dir);
+    if (cached !== undefined) {
+      return cached;
+    }
+    let result: string | null = null;
+    if (MODULE_MANIFESTS.some(name => this.exists(path.join(dir, name)))) {
+      result = dir;
+    } else {
+      const parent = path.dirname(dir);
+      if (dir !== this.workspaceRoot && parent !== dir) {
+        result = this.findManifestDir(parent);
+      }
+    }
+    this.manifestDirs.set(dir, result);
+    return result;
+  }
+}
+
+/**
+ * Merge shards into one binary index. Inputs are applied in order: when a
+ * file appears in several shards (an older full build plus a fresh shard),
+ * the last one wins. Directories expand to the .sidx files they contain.
+ */
+export async function mergeShards(
+  inputs: string[],
+  outputPath: string,
+  options: ShardMergeOptions = {}
+): Promise<ShardMergeResult> {
+  const { compression, reproducible, cancellationToken, onProgress } = options;
+  const shardPaths = await expandShardPaths(inputs);
+  if (shardPaths.length === 0) {
+    throw new Error('No shards to merge');
+  }
+
+  const shards: BinaryIndex[] = [];
+  try {
+    const owners = new Map<string, number>();
+    let listed = 0;
+    for (let i = 0; i < shardPaths.length; i++) {
+      throwIfCancelled(cancellationToken);
+      const shard = await BinaryIndex.open(shardPaths[i]);
+      shards.push(shard);
+      for (const uri of await shard.getFileUris()) {
+        owners.set(uri, i);
+        listed++;
+      }
+    }
+
+    const writer = new BinaryIndexWriter();
+    const files = [...owners.keys()].sort();
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Merging shards (${i}/${files.length})`);
+      }
+      writer.addFile(files[i], await shards[owners.get(files[i])!].getFileSymbols(files[i]));
+    }
+
+    throwIfCancelled(cancellationToken);
+    const stats = await writer.finish(outputPath, { compression, createdAt: buildTimestamp(reproducible) });
+    onProgress?.(files.length, files.length, 'Shard merge complete');
+    return { outputPath, shards: shardPaths.length, ...stats, overriddenFiles: listed - files.length };
+  } finally {
+    await Promise.all(shards.map(shard => shard.close()));
+  }
+}
+
+async function expandShardPaths(inputs: string[]): Promise<string[]> {
+  const result: string[] = [];
+  for (const input of inputs) {
+    const stats = await fs.promises.stat(input);
+    if (stats.isDirectory()) {
+      const entries = await fs.promises.readdir(input);
+      result.push(...entries.filter(name => name.endsWith(BINARY_INDEX_EXTENSION)).sort().map(name => path.join(input, name)));
+    } else {
+      result.push(input);
+    }
+  }
+  return result;
+}
+
+/**
+ * `pkg/api` -> `pkg__api.sidx`, '.' -> `_root.sidx`.
+ */
+export function shardFileName(root: string): string {
+  const name = root === '.' ? '_root' : root.replace(/[\\/]/g, '__').replace(/[^\w.-]/g, '_');
+  return name + BINARY_INDEX_EXTENSION;
+}
+
+/** Workspace-relative path with '/' separators, or EXTERNAL_SHARD */
+function toRelative(workspaceRoot: string, filePath: string): string {
+  const relative = path.relative(workspaceRoot, filePath);
+  if (relative.startsWith('..') || path.isAbsolute(relative)) {
+    return EXTERNAL_SHARD;
+  }
+  return relative.split(path.sep).join('/') || '.';
+}
+
+function isWithin(relative: string, dir: string): boolean {
+  return dir === '.' || relative === dir || relative.startsWith(dir + '/');
+}
@@ -0,0 +1,126 @@
+/**
+ * Index Verifier Tests
+ *
+ * Verifies format, integrity, staleness and reference checks of saved
+ * index entries.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import * as path from 'path';
+import { hashContent, IndexVerifier } from './indexVerifier.js';
+import { CompactShard, SHARD_VERSION } from '../types.js';
+
+const root = path.join(path.sep, 'src');
+const file = (name: string) => path.join(root, name);
+
+function shard(name: string, content: string, parts: Partial<CompactShard> = {}): CompactShard {
+  return { u: file(name), h: hashContent(content), s: [], r: [], i: [], t: 0, v: SHARD_VERSION, ...parts };
+}
+
+function symbol(name: string) {
+  return { id: name, n: name, k: 'function', p: { l: 0, c: 0 }, r: { sl: 0, sc: 0, el: 0, ec: 1 } };
+}
+
+function reference(name: string, scope?: number) {
+  return { sn: name, p: { l: 1, c: 0 }, r: { sl: 1, sc: 0, el: 1, ec: 1 }, si: scope };
+}
+
+describe('IndexVerifier', () => {
+  let disk: Map<string, string>;
+  let verifier: IndexVerifier;
+
+  beforeEach(() => {
+    disk = new Map([
+      [file('app.ts'), 'helper();'],
+      [file('util.ts'), 'export function helper() {}'],
+      [file('new.ts'), 'export const fresh = 1;'],
+      [file('widgets/index.ts'), 'export {};']
+    ]);
+    verifier = new IndexVerifier(async filePath => disk.get(filePath) ?? null);
+  });
+
+  it('should report nothing for an index matching the files', async () => {
+    const report = await verifier.verify({
+      shardVersion: SHARD_VERSION,
+      files: [
+        shard('app.ts', disk.get(file('app.ts'))!, {
+          i: [{ localName: 'helper', moduleSpecifier: './util.js' }],
+          r: [reference('helper', 0)],
+          sc: ['app.ts:0']
+        }),
+        shard('util.ts', disk.get(file('util.ts'))!, { s: [symbol('helper')] })
+      ]
+    });
+
+    expect(report).toMatchObject({ ok: true, formatVersion: SHARD_VERSION, files: 2, symbols: 1, references: 1 });
+    expect(report.problems).toEqual([]);
+    expect(report.reindex).toEqual([]);
+    expect(report.remove).toEqual([]);
+  });
+
+  it('should list stale, corrupt and missing files to index again', async () => {
+    disk.set(file('broken.ts'), 'let local;');
+    const report = await verifier.verify({
+      shardVersion: SHARD_VERSION,
+      files: [
+        shard('app.ts', disk.get(file('app.ts'))!, {
+          i: [{ localName: 'w', moduleSpecifier: './widgets' }, { localName: 'x', moduleSpecifier: './gone' }],
+          r: [reference('removedHelper')]
+        }),
+        shard('broken.ts', 'let local;', { r: [reference('local', 3)] }),
+        shard('util.ts', disk.get(file('util.ts'))!, { r: [reference('removedHelper')] }),
+        shard('util.ts', disk.get(file('util.ts'))!),
+        shard('deleted.ts', 'x', { s: [symbol('removedHelper')] }),
+        { u: file('new.ts'), broken: true },
+        'garbage'
+      ]
+    }, { expectedFiles: [file('app.ts'), file('new.ts'), file('extra.ts')] });
+
+    expect(report.ok).toBe(false);
+    expect(report.problems.map(p => [p.kind, p.file && path.relative(root, p.file)])).toEqual([
+      ['corrupt', undefined],
+      ['dangling', 'app.ts'],
+      ['corrupt', 'broken.ts'],
+      ['deleted', 'deleted.ts'],
+      ['missing', 'extra.ts'],
+      ['corrupt', 'new.ts'],
+      ['duplicate', 'util.ts'],
+      ['missing', path.join('widgets', 'index.ts')]
+    ]);
+    expect(report.problems[0].message).toBe('Entry 6 is not a file shard and has no file');
+    expect(report.problems[1].message).toBe(`References "removedHelper", defined only in deleted file ${file('deleted.ts')}`);
+    expect(report.problems[2].message).toBe('Reference 0 points at scope 3, outside the scope table (0)');
+    expect(report.problems[7].message).toBe(`File is not indexed; imported by ${file('app.ts')}`);
+    expect(report.reindex).toEqual(['broken.ts', 'extra.ts', 'new.ts', 'util.ts', path.join('widgets', 'index.ts')].map(file));
+    expect(report.remove).toEqual([file('deleted.ts')]);
+    expect(report.files).toBe(5);
+  });
+
+  it('should report changed files and skip the disk when asked', async () => {
+    const files = [shard('app.ts', 'old content'), shard('gone.ts', '')];
+    const report = await verifier.verify({ shardVersion: SHARD_VERSION, files });
+    expect(report.problems.map(p => p.kind)).toEqual(['modified', 'deleted']);
+    expect(report.reindex).toEqual([file('app.ts')]);
+    expect(report.remove).toEqual([file('gone.ts')]);
+
+    expect((await verifier.verify({ shardVersion: SHARD_VERSION, files }, { checkFiles: false })).ok).toBe(true);
+  });
+
+  it('should mark every file stale on a format version mismatch', async () => {
+    const report = await verifier.verify({
+      shardVersion: SHARD_VERSION - 1,
+      files: [shard('app.ts', disk.get(file('app.ts'))!, { v: SHARD_VERSION - 1 }), shard('gone.ts', '')]
+    });
+
+    expect(report.formatVersion).toBe(SHARD_VERSION - 1);
+    expect(report.problems[0]).toEqual({
+      kind: 'format',
+      message: `Format version ${SHARD_VERSION - 1}, expected ${SHARD_VERSION}; every file needs indexing again`
+    });
+    expect(report.reindex).toEqual([file('app.ts')]);
+    expect(report.remove).toEqual([file('gone.ts')]);
+
+    const entry = await verifier.verify({ shardVersion: SHARD_VERSION, files: [shard('util.ts', disk.get(file('util.ts'))!, { v: 3 })] });
+    expect(entry.problems).toEqual([{ kind: 'format', file: file('util.ts'), message: `Entry has format version 3, expected ${SHARD_VERSION}` }]);
+  });
+});
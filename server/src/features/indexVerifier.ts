@@ -0,0 +1,372 @@
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import { isCompactShard } from '../index/ShardPersistenceManager.js';
+import { CompactShard, SHARD_VERSION } from '../types.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** Reads a file's content; null when it no longer exists */
+export type IndexFileReader = (filePath: string) => Promise<string | null>;
+
+export type IndexProblemKind =
+  /** The index (or one entry) was written in another format version */
+  | 'format'
+  /** An entry is not a file shard, or points outside its own tables */
+  | 'corrupt'
+  /** A file has more than one entry */
+  | 'duplicate'
+  /** The file was deleted after indexing */
+  | 'deleted'
+  /** The file's content changed after indexing */
+  | 'modified'
+  /** A file that should be indexed has no entry */
+  | 'missing'
+  /** A reference to a name whose only definitions are in deleted files */
+  | 'dangling';
+
+export interface IndexProblem {
+  kind: IndexProblemKind;
+  /** The file the problem is about; absent for the index as a whole */
+  file?: string;
+  message: string;
+}
+
+export interface IndexVerifyOptions {
+  /**
+   * Compare entries with the files on disk: deleted and modified files, and
+   * relative imports of files with no entry (default: true)
+   */
+  checkFiles?: boolean;
+  /** Files that should be indexed, e.g. a fresh scan; those without an entry are missing */
+  expectedFiles?: string[];
+  /** Cancellation token for aborting the check */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting check progress */
+  onProgress?: ProgressCallback;
+}
+
+/** Saved index contents as decoded, before any entry is trusted */
+export interface SavedIndexEntries {
+  shardVersion: unknown;
+  files: unknown[];
+}
+
+export interface IndexVerifyReport {
+  /** True if there are no problems */
+  ok: boolean;
+  /** Format version the index was written with, and the one this build reads */
+  formatVersion: number | null;
+  expectedFormatVersion: number;
+  /** Files with entries, and the symbols and references of those entries */
+  files: number;
+  symbols: number;
+  references: number;
+  /** In path order; problems of the index as a whole first */
+  problems: IndexProblem[];
+  /** Files to index again to bring the index up to date, in path order */
+  reindex: string[];
+  /** Entries to drop: files deleted since indexing, in path order */
+  remove: string[];
+}
+
+const YIELD_INTERVAL = 50;
+
+const JS_EXTENSIONS = ['.ts', '.tsx', '.js', '.jsx', '.mts', '.cts', '.mjs', '.cjs'];
+
+/** Compiled-output extensions TypeScript imports are written with, and their sources */
+const OUTPUT_EXTENSIONS: Record<string, string[]> = {
+  '.js': ['.ts', '.tsx'],
+  '.jsx': ['.tsx'],
+  '.mjs': ['.mts'],
+  '.cjs': ['.cts']
+};
+
+/**
+ * Index Verifier - checks a saved index before it is trusted, and says
+ * exactly which files to index again.
+ *
+ * - Format: the index and each entry must be written with SHARD_VERSION;
+ *   otherwise every file needs indexing again.
+ * - Integrity: every entry must be a file shard, each file must have one
+ *   entry, and references must point into their shard's scope table.
+ * - Staleness (checkFiles): files must still exist and hash to the content
+ *   they were indexed from (SHA-256, as the indexers hash).
+ * - References: relative imports must resolve to an indexed file, and names
+ *   referred to must not be defined only in deleted files.
+ *
+ * Verification only reads; see Indexer.verify for repairing.
+ */
+export class IndexVerifier {
+  constructor(private readFile: IndexFileReader = readIfExists) {}
+
+  async verify(saved: SavedIndexEntries, options: IndexVerifyOptions = {}): Promise<IndexVerifyReport> {
+    const { cancellationToken, onProgress } = options;
+    const checkFiles = options.checkFiles ?? true;
+    const formatVersion = typeof saved.shardVersion === 'number' ? saved.shardVersion : null;
+    const outdated = formatVersion !== SHARD_VERSION;
+
+    const indexProblems: IndexProblem[] = [];
+    const fileProblems: IndexProblem[] = [];
+    const reindex = new Set<string>();
+    const remove = new Set<string>();
+    if (outdated) {
+      indexProblems.push({
+        kind: 'format',
+        message: `Format version ${formatVersion ?? 'unknown'}, expected ${SHARD_VERSION}; every file needs indexing again`
+      });
+    }
+
+    // Entries by file; the last one wins, as when loading
+    const entries = new Map<string, CompactShard>();
+    const counts = new Map<string, number>();
+    let symbols = 0;
+    let references = 0;
+    saved.files.forEach((entry, position) => {
+      const uri = entryUri(entry);
+      if (uri === undefined) {
+        indexProblems.push({ kind: 'corrupt', message: `Entry ${position} is not a file shard and has no file` });
+        return;
+      }
+      counts.set(uri, (counts.get(uri) ?? 0) + 1);
+      if (!isCompactShard(entry)) {
+        fileProblems.push({ kind: 'corrupt', file: uri, message: 'Entry is not a file shard' });
+        reindex.add(uri);
+        return;
+      }
+      symbols += entry.s.length;
+      references += entry.r.length;
+      entries.set(uri, entry);
+      if (outdated) {
+        reindex.add(uri);
+        return;
+      }
+      if (entry.v !== SHARD_VERSION) {
+        fileProblems.push({ kind: 'format', file: uri, message: `Entry has format version ${entry.v}, expected ${SHARD_VERSION}` });
+        reindex.add(uri);
+      }
+      const broken = brokenPart(entry);
+      if (broken) {
+        fileProblems.push({ kind: 'corrupt', file: uri, message: broken });
+        reindex.add(uri);
+      }
+    });
+    for (const [uri, count] of counts) {
+      if (count > 1) {
+        fileProblems.push({ kind: 'duplicate', file: uri, message: `File has ${count} entries` });
+        reindex.add(uri);
+      }
+    }
+
+    const files = [...counts.keys()].sort();
+    if (checkFiles) {
+      for (let i = 0; i < files.length; i++) {
+        if (i % YIELD_INTERVAL === 0) {
+          throwIfCancelled(cancellationToken);
+          await yieldToEventLoop();
+          onProgress?.(i, files.length, `Checking files (${i}/${files.length})`);
+        }
+        const uri = files[i];
+        const content = await this.readFile(uri);
+        if (content === null) {
+          fileProblems.push({ kind: 'deleted', file: uri, message: 'File no longer exists' });
+          reindex.delete(uri);
+          remove.add(uri);
+          continue;
+        }
+        const entry = entries.get(uri);
+        if (entry && !reindex.has(uri) && entry.h !== hashContent(content)) {
+          fileProblems.push({ kind: 'modified', file: uri, message: 'File changed since it was indexed' });
+          reindex.add(uri);
+        }
+      }
+    }
+
+    throwIfCancelled(cancellationToken);
+    onProgress?.(files.length, files.length, 'Checking references');
+    for (const uri of options.expectedFiles ?? []) {
+      const file = path.resolve(uri);
+      if (!counts.has(file)) {
+        fileProblems.push({ kind: 'missing', file, message: 'File is not indexed' });
+        reindex.add(file);
+      }
+    }
+    if (!outdated) {
+      if (checkFiles) {
+        await this.checkImports(entries, counts, remove, fileProblems, reindex);
+      }
+      checkDanglingReferences(entries, remove, reindex, fileProblems);
+    }
+
+    fileProblems.sort((a, b) => a.file! < b.file! ? -1 : a.file! > b.file! ? 1 : 0);
+    const problems = [...indexProblems, ...fileProblems];
+    return {
+      ok: problems.length === 0,
+      formatVersion,
+      expectedFormatVersion: SHARD_VERSION,
+      files: counts.size,
+      symbols,
+      references,
+      problems,
+      reindex: [...reindex].sort(),
+      remove: [...remove].sort()
+    };
+  }
+
+  /**
+   * Relative imports and re-exports of JS/TS files that resolve to a file on
+   * disk with no entry; imports of files that do not exist are the source's
+   * problem, not the index's. Files indexed again are skipped: their
+   * imports may have changed.
+   */
+  private async checkImports(
+    entries: Map<string, CompactShard>,
+    indexed: Map<string, number>,
+    deleted: Set<string>,
+    problems: IndexProblem[],
+    reindex: Set<string>
+  ): Promise<void> {
+    const reported = new Set<string>();
+    for (const [uri, entry] of entries) {
+      if (deleted.has(uri) || reindex.has(uri) || !JS_EXTENSIONS.includes(path.extname(uri).toLowerCase())) {
+        continue;
+      }
+      const specifiers = [...entry.i.map(imp => imp.moduleSpecifier), ...(entry.re ?? []).map(re => re.moduleSpecifier)];
+      for (const specifier of new Set(specifiers)) {
+        if (!specifier.startsWith('./') && !specifier.startsWith('../')) {
+          continue;
+        }
+        const candidates = importCandidates(path.resolve(path.dirname(uri), specifier));
+        if (candidates.some(candidate => indexed.has(candidate) && !deleted.has(candidate))) {
+          continue;
+        }
+        for (const candidate of candidates) {
+          if (reported.has(candidate) || indexed.has(candidate) || await this.readFile(candidate) === null) {
+            continue;
+          }
+          reported.add(candidate);
+          problems.push({ kind: 'missing', file: candidate, message: `File is not indexed; imported by ${uri}` });
+          reindex.add(candidate);
+          break;
+        }
+      }
+    }
+  }
+}
+
+/**
+ * Files a relative import may mean: the path itself, with an extension, an
+ * index file in it, or the source of a compiled-output extension.
+ */
+function importCandidates(target: string): string[] {
+  const ext = path.extname(target).toLowerCase();
+  const candidates = [target];
+  for (const source of OUTPUT_EXTENSIONS[ext] ?? []) {
+    candidates.push(target.slice(0, -ext.length) + source);
+  }
+  if (!JS_EXTENSIONS.includes(ext)) {
+    candidates.push(...JS_EXTENSIONS.map(jsExt => target + jsExt));
+    candidates.push(...JS_EXTENSIONS.map(jsExt => path.join(target, 'index' + jsExt)));
+  }
+  return candidates;
+}
+
+/**
+ * References to names that deleted files define and no remaining file does:
+ * whatever they referred to went with the file. Files indexed again are
+ * skipped, as their references may have changed.
+ */
+function checkDanglingReferences(
+  entries: Map<string, CompactShard>,
+  deleted: Set<string>,
+  reindex: Set<string>,
+  problems: IndexProblem[]
+): void {
+  if (deleted.size === 0) {
+    return;
+  }
+  const definedBy = new Map<string, string>();
+  const definedElsewhere = new Set<string>();
+  for (const [uri, entry] of entries) {
+    for (const symbol of entry.s) {
+      if (deleted.has(uri)) {
+        if (!definedBy.has(symbol.n)) {
+          definedBy.set(symbol.n, uri);
+        }
+      } else {
+        definedElsewhere.add(symbol.n);
+      }
+    }
+  }
+
+  for (const [uri, entry] of entries) {
+    if (deleted.has(uri) || reindex.has(uri)) {
+      continue;
+    }
+    const names = new Set(entry.r.filter(ref => !ref.lo).map(ref => ref.sn));
+    for (const name of [...names].sort()) {
+      const source = definedBy.get(name);
+      if (source && !definedElsewhere.has(name)) {
+        problems.push({ kind: 'dangling', file: uri, message: `References "${name}", defined only in deleted file ${source}` });
+      }
+    }
+  }
+}
+
+/**
+ * What in a shard points outside it or is not the shape it should have;
+ * undefined if nothing.
+ */
+function brokenPart(entry: CompactShard): string | undefined {
+  const scopes = entry.sc?.length ?? 0;
+  for (let i = 0; i < entry.s.length; i++) {
+    const symbol = entry.s[i];
+    if (!symbol || typeof symbol.n !== 'string' || typeof symbol.k !== 'string' || !isPosition(symbol.p)) {
+      return `Symbol ${i} is malformed`;
+    }
+  }
+  for (let i = 0; i < entry.r.length; i++) {
+    const ref = entry.r[i];
+    if (!ref || typeof ref.sn !== 'string' || !isPosition(ref.p)) {
+      return `Reference ${i} is malformed`;
+    }
+    if (ref.si !== undefined && !(Number.isInteger(ref.si) && ref.si >= 0 && ref.si < scopes)) {
+      return `Reference ${i} points at scope ${ref.si}, outside the scope table (${scopes})`;
+    }
+  }
+  for (let i = 0; i < entry.i.length; i++) {
+    if (!entry.i[i] || typeof entry.i[i].moduleSpecifier !== 'string') {
+      return `Import ${i} is malformed`;
+    }
+  }
+  return undefined;
+}
+
+function isPosition(value: unknown): boolean {
+  const position = value as { l?: unknown; c?: unknown } | undefined;
+  return typeof position === 'object' && position !== null && typeof position.l === 'number' && typeof position.c === 'number';
+}
+
+function entryUri(entry: unknown): string | undefined {
+  const uri = (entry as { u?: unknown } | null)?.u;
+  return typeof entry === 'object' && typeof uri === 'string' ? uri : undefined;
+}
+
+/**
+ * Content hash as the indexers compute it.
+ */
+export function hashContent(content: string): string {
+  return crypto.createHash('sha256').update(content).digest('hex');
+}
+
+async function readIfExists(filePath: string): Promise<string | null> {
+  try {
+    return await fs.promises.readFile(filePath, 'utf-8');
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,112 @@
+/**
+ * ImplementationIndex Tests
+ *
+ * Verifies Go method-set satisfaction and declared TS implementations.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ImplementationIndex } from './interfaceImplementations.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const ioGo = `package io
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+type Closer interface {
+	Close() error
+}
+
+type ReadCloser interface {
+	Reader
+	Closer
+}
+`;
+
+const filesGo = `package files
+
+type File struct{}
+
+func (f *File) Read(p []byte) (int, error) { return 0, nil }
+func (f *File) Close() error { return nil }
+
+type Buffer struct{}
+
+func (b Buffer) Read(p []byte) (int, error) { return 0, nil }
+
+type LoggedFile struct {
+	*File
+}
+
+type Named struct{}
+
+func (n Named) Close(force bool) error { return nil }
+`;
+
+describe('ImplementationIndex', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  async function build(): Promise<ImplementationIndex> {
+    const implementations = new ImplementationIndex(index.asBackgroundIndex());
+    await implementations.build();
+    return implementations;
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    addGoFile('/ws/io/io.go', ioGo);
+    addGoFile('/ws/files/files.go', filesGo);
+  });
+
+  it('should find Go implementations from method sets', async () => {
+    const implementations = await build();
+
+    const readers = implementations.implementations('Reader');
+    expect(readers.map(m => m.name).sort()).toEqual(['Buffer', 'File', 'LoggedFile']);
+    expect(readers.find(m => m.name === 'File')?.pointerReceiver).toBe(true);
+    expect(readers.find(m => m.name === 'Buffer')?.pointerReceiver).toBeUndefined();
+    // Embedding *File promotes its pointer methods to the value type
+    expect(readers.find(m => m.name === 'LoggedFile')?.pointerReceiver).toBeUndefined();
+  });
+
+  it('should include methods of embedded interfaces', async () => {
+    const implementations = await build();
+
+    const readClosers = implementations.implementations('io.ReadCloser');
+    expect(readClosers.map(m => m.name).sort()).toEqual(['File', 'LoggedFile']);
+  });
+
+  it('should list interfaces satisfied by a type', async () => {
+    const implementations = await build();
+
+    expect(implementations.interfacesOf('File').map(m => m.name).sort()).toEqual(['Closer', 'ReadCloser', 'Reader']);
+    // Close(force bool) does not match Close() by parameter count
+    expect(implementations.interfacesOf('Named')).toEqual([]);
+  });
+
+  it('should use declared implements for TS classes, including base classes', async () => {
+    const uri = '/ws/src/repo.ts';
+    index.addFile(uri, [
+      createTestSymbol({ id: 'i', name: 'Repository', kind: 'interface', filePath: uri, location: { uri, line: 0, character: 17 } }),
+      createTestSymbol({ id: 'b', name: 'BaseRepo', kind: 'class', implements: ['Repository'], filePath: uri, location: { uri, line: 2, character: 13 } }),
+      createTestSymbol({ id: 'u', name: 'UserRepo', kind: 'class', extends: 'BaseRepo', filePath: uri, location: { uri, line: 4, character: 13 } })
+    ]);
+
+    const implementations = await build();
+
+    expect(implementations.implementations('Repository').map(m => [m.name, m.via])).toEqual([
+      ['BaseRepo', 'declared'],
+      ['UserRepo', 'declared']
+    ]);
+    expect(implementations.interfacesOf('UserRepo').map(m => m.name)).toEqual(['Repository']);
+  });
+});
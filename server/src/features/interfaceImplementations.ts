@@ -0,0 +1,366 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface ImplementationIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface ImplementationMatch {
+  /** The concrete type (for `implementations`) or the interface (for `interfacesOf`) */
+  name: string;
+  qualifiedName: string;
+  kind: string;
+  location: SymbolLocation;
+  /** Go: only *T satisfies the interface because some methods have pointer receivers */
+  pointerReceiver?: boolean;
+  /** How the relationship was established */
+  via: 'method-set' | 'declared';
+}
+
+interface MethodInfo {
+  name: string;
+  /** Requires a pointer receiver to be in the method set */
+  pointer: boolean;
+  parametersCount?: number;
+}
+
+interface EmbedInfo {
+  name: string;
+  qualifier?: string;
+  pointer: boolean;
+}
+
+interface TypeEntry {
+  key: string;
+  symbol: IndexedSymbol;
+  isInterface: boolean;
+  isGo: boolean;
+  methods: Map<string, MethodInfo>;
+  embeds: EmbedInfo[];
+}
+
+const YIELD_INTERVAL = 50;
+
+const GO_TYPE_KINDS = new Set(['struct', 'interface', 'type']);
+
+/**
+ * Implementation Index - which concrete types satisfy which interfaces.
+ *
+ * Go interfaces are satisfied implicitly, so satisfaction is computed from
+ * method sets: a type's own methods plus methods promoted through embedded
+ * fields, and an interface's methods plus those of embedded interfaces.
+ * Methods are matched by name and parameter count - the indexer does not
+ * type-check signatures, so a same-named method with different parameter
+ * types still matches. When only pointer-receiver methods complete the set,
+ * the match is reported with `pointerReceiver` (only *T implements it).
+ *
+ * TS/JS relationships are explicit and come from `implements`/`extends`,
+ * including implementations inherited from base classes.
+ */
+export class ImplementationIndex {
+  private types: Map<string, TypeEntry> = new Map();
+  private byName: Map<string, TypeEntry[]> = new Map();
+  private methodSetCache: Map<string, Map<string, MethodInfo>> = new Map();
+
+  constructor(private backgroundIndex: BackgroundIndex) {}
+
+  /**
+   * Collect types and methods from the background index.
+   */
+  async build(options: ImplementationIndexOptions = {}): Promise<{ types: number; interfaces: number }> {
+    const { cancellationToken, onProgress } = options;
+    this.types.clear();
+    this.byName.clear();
+    this.methodSetCache.clear();
+
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const pendingMembers: IndexedSymbol[] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting types (${i}/${files.length})`);
+      }
+
+      const uri = files[i];
+      const isGo = path.extname(uri) === '.go';
+      const symbols = await this.backgroundIndex.getFileSymbols(uri);
+
+      for (const symbol of symbols) {
+        if (symbol.isDefinition === false) {
+          continue;
+        }
+        if (isGo ? GO_TYPE_KINDS.has(symbol.kind) : symbol.kind === 'class' || symbol.kind === 'interface') {
+          this.addType(symbol, isGo);
+        } else if (isGo && (symbol.kind === 'method' || (symbol.kind === 'field' && goMetadata(symbol).embedded))) {
+          pendingMembers.push(symbol);
+        }
+      }
+    }
+
+    // Methods can be declared in another file of the package than their receiver type.
+    // Interface methods are indexed as members of the interface and land here too.
+    for (const member of pendingMembers) {
+      const owner = this.types.get(typeKey(member.location.uri, member.containerName || ''));
+      if (!owner) {
+        continue;
+      }
+      if (member.kind === 'method') {
+        owner.methods.set(member.name, {
+          name: member.name,
+          pointer: goMetadata(member).pointerReceiver === true,
+          parametersCount: member.parametersCount
+        });
+      } else {
+        owner.embeds.push(parseEmbed(member.name, goMetadata(member).type || member.name));
+      }
+    }
+
+    onProgress?.(files.length, files.length, 'Implementation index complete');
+
+    let interfaces = 0;
+    for (const entry of this.types.values()) {
+      if (entry.isInterface) {
+        interfaces++;
+      }
+    }
+    return { types: this.types.size - interfaces, interfaces };
+  }
+
+  /**
+   * Concrete types implementing an interface (`Reader` or `pkg.Reader`).
+   */
+  implementations(interfaceName: string, fromUri?: string): ImplementationMatch[] {
+    const matches: ImplementationMatch[] = [];
+    const seen = new Set<string>();
+
+    for (const iface of this.lookup(interfaceName, fromUri).filter(t => t.isInterface)) {
+      if (iface.isGo) {
+        const required = this.methodSet(iface);
+        if (required.size === 0) {
+          continue; // every type satisfies an empty interface
+        }
+        for (const candidate of this.types.values()) {
+          if (!candidate.isGo || candidate.isInterface || seen.has(candidate.key)) {
+            continue;
+          }
+          const pointerReceiver = this.satisfies(candidate, required);
+          if (pointerReceiver !== null) {
+            seen.add(candidate.key);
+            matches.push(toMatch(candidate, 'method-set', pointerReceiver));
+          }
+        }
+      } else {
+        for (const candidate of this.types.values()) {
+          if (!candidate.isGo && !candidate.isInterface && !seen.has(candidate.key) &&
+              this.declaredInterfaces(candidate).has(iface.symbol.name)) {
+            seen.add(candidate.key);
+            matches.push(toMatch(candidate, 'declared'));
+          }
+        }
+      }
+    }
+
+    return matches;
+  }
+
+  /**
+   * Interfaces satisfied by a concrete type (`Person` or `pkg.Person`).
+   */
+  interfacesOf(typeName: string, fromUri?: string): ImplementationMatch[] {
+    const matches: ImplementationMatch[] = [];
+    const seen = new Set<string>();
+
+    for (const type of this.lookup(typeName, fromUri).filter(t => !t.isInterface)) {
+      if (type.isGo) {
+        for (const iface of this.types.values()) {
+          if (!iface.isGo || !iface.isInterface || seen.has(iface.key)) {
+            continue;
+          }
+          const required = this.methodSet(iface);
+          if (required.size === 0) {
+            continue;
+          }
+          const pointerReceiver = this.satisfies(type, required);
+          if (pointerReceiver !== null) {
+            seen.add(iface.key);
+            matches.push(toMatch(iface, 'method-set', pointerReceiver));
+          }
+        }
+      } else {
+        for (const name of this.declaredInterfaces(type)) {
+          for (const iface of (this.byName.get(name) || []).filter(t => !t.isGo && t.isInterface)) {
+            if (!seen.has(iface.key)) {
+              seen.add(iface.key);
+              matches.push(toMatch(iface, 'declared'));
+            }
+          }
+        }
+      }
+    }
+
+    return matches;
+  }
+
+  /**
+   * Returns null if type does not satisfy required, otherwise whether a
+   * pointer receiver is needed.
+   */
+  private satisfies(type: TypeEntry, required: Map<string, MethodInfo>): boolean | null {
+    const available = this.methodSet(type);
+    let pointer = false;
+    for (const method of required.values()) {
+      const found = available.get(method.name);
+      if (!found) {
+        return null;
+      }
+      if (method.parametersCount !== undefined && found.parametersCount !== undefined &&
+          method.parametersCount !== found.parametersCount) {
+        return null;
+      }
+      pointer = pointer || found.pointer;
+    }
+    return pointer;
+  }
+
+  /**
+   * Own methods plus methods promoted through embedded types. Own methods
+   * shadow promoted ones; embedding *E makes E's pointer methods available on T.
+   */
+  private methodSet(entry: TypeEntry, visiting: Set<string> = new Set()): Map<string, MethodInfo> {
+    const cached = this.methodSetCache.get(entry.key);
+    if (cached) {
+      return cached;
+    }
+    if (visiting.has(entry.key)) {
+      return new Map();
+    }
+    visiting.add(entry.key);
+
+    const set = new Map(entry.methods);
+    for (const embed of entry.embeds) {
+      const target = this.resolveEmbed(embed, entry);
+      if (!target) {
+        continue;
+      }
+      for (const method of this.methodSet(target, visiting).values()) {
+        if (!set.has(method.name)) {
+          set.set(method.name, { ...method, pointer: method.pointer && !embed.pointer });
+        }
+      }
+    }
+
+    visiting.delete(entry.key);
+    this.methodSetCache.set(entry.key, set);
+    return set;
+  }
+
+  private resolveEmbed(embed: EmbedInfo, owner: TypeEntry): TypeEntry | undefined {
+    const candidates = this.byName.get(embed.name) || [];
+    if (embed.qualifier) {
+      const qualified = candidates.filter(c => goMetadata(c.symbol).package === embed.qualifier);
+      return qualified.length === 1 ? qualified[0] : undefined;
+    }
+    const samePackage = candidates.find(c => packageKey(c.symbol.location.uri) === packageKey(owner.symbol.location.uri));
+    return samePackage ?? (candidates.length === 1 ? candidates[0] : undefined);
+  }
+
+  /**
+   * TS/JS: interfaces named in `implements`, including those of base classes.
+   */
+  private declaredInterfaces(entry: TypeEntry, visiting: Set<string> = new Set()): Set<string> {
+    const names = new Set<string>(entry.symbol.implements || []);
+    if (entry.symbol.extends && !visiting.has(entry.key)) {
+      visiting.add(entry.key);
+      for (const base of (this.byName.get(entry.symbol.extends) || []).filter(t => !t.isGo && !t.isInterface)) {
+        this.declaredInterfaces(base, visiting).forEach(name => names.add(name));
+      }
+    }
+    return names;
+  }
+
+  private lookup(name: string, fromUri?: string): TypeEntry[] {
+    const dot = name.lastIndexOf('.');
+    const qualifier = dot > 0 ? name.slice(0, dot) : undefined;
+    const simpleName = dot > 0 ? name.slice(dot + 1) : name;
+
+    let entries = this.byName.get(simpleName) || [];
+    if (qualifier) {
+      entries = entries.filter(e => goMetadata(e.symbol).package === qualifier || e.symbol.fullContainerPath === qualifier);
+    }
+    if (fromUri && entries.length > 1) {
+      const local = entries.filter(e => packageKey(e.symbol.location.uri) === packageKey(fromUri));
+      if (local.length > 0) {
+        return local;
+      }
+    }
+    return entries;
+  }
+
+  private addType(symbol: IndexedSymbol, isGo: boolean): void {
+    const entry: TypeEntry = {
+      key: typeKey(symbol.location.uri, symbol.name),
+      symbol,
+      isInterface: symbol.kind === 'interface',
+      isGo,
+      methods: new Map(),
+      embeds: []
+    };
+    if (isGo && entry.isInterface) {
+      // Embedded interfaces carry no field symbol; names are recorded on the interface
+      for (const embed of goMetadata(symbol).embeds || []) {
+        entry.embeds.push({ name: embed, pointer: false });
+      }
+    }
+    this.types.set(entry.key, entry);
+    const list = this.byName.get(symbol.name) || [];
+    list.push(entry);
+    this.byName.set(symbol.name, list);
+  }
+}
+
+/**
+ * Go types are keyed by package directory, TS/JS types by file.
+ */
+function typeKey(uri: string, name: string): string {
+  return `${packageKey(uri)}#${name}`;
+}
+
+function packageKey(uri: string): string {
+  return path.extname(uri) === '.go' ? path.dirname(uri) : uri;
+}
+
+function parseEmbed(name: string, typeText: string): EmbedInfo {
+  const pointer = typeText.startsWith('*');
+  const bare = typeText.replace(/^\*/, '').replace(/\[.*$/, '');
+  const dot = bare.lastIndexOf('.');
+  return { name, pointer, ...(dot > 0 && { qualifier: bare.slice(0, dot) }) };
+}
+
+function goMetadata(symbol: IndexedSymbol): GoSymbolMetadata {
+  return (symbol.metadata?.go ?? {}) as GoSymbolMetadata;
+}
+
+function toMatch(entry: TypeEntry, via: ImplementationMatch['via'], pointerReceiver?: boolean): ImplementationMatch {
+  const symbol = entry.symbol;
+  return {
+    name: symbol.name,
+    qualifiedName: symbol.fullContainerPath ? `${symbol.fullContainerPath}.${symbol.name}` : symbol.name,
+    kind: symbol.kind,
+    location: symbol.location,
+    via,
+    ...(pointerReceiver && { pointerReceiver })
+  };
+}
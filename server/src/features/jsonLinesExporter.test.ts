@@ -0,0 +1,132 @@
+/**
+ * JsonLinesExporter Tests
+ *
+ * Verifies record layout, record type selection and streaming with
+ * backpressure.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { JsonLinesExporter } from './jsonLinesExporter.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+import { Writable } from 'stream';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+
+describe('JsonLinesExporter', () => {
+  let testDir: string;
+  let index: MockBackgroundIndex;
+  const person = '/ws/pkg/people/person.go';
+  const main = '/ws/cmd/main.go';
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-jsonl-'));
+    index = new MockBackgroundIndex();
+    index.addFile(person, [
+      createTestSymbol({
+        id: 'person',
+        name: 'Person',
+        kind: 'struct',
+        filePath: person,
+        range: { startLine: 2, startCharacter: 5, endLine: 4, endCharacter: 1 },
+        isExported: true
+      }),
+      createTestSymbol({
+        id: 'greet',
+        name: 'Greet',
+        kind: 'method',
+        containerName: 'Person',
+        filePath: person,
+        range: { startLine: 6, startCharacter: 17, endLine: 8, endCharacter: 1 }
+      })
+    ]);
+    index.addFile(main, [], [
+      createTestReference({
+        symbolName: 'Greet',
+        range: { startLine: 9, startCharacter: 4, endLine: 9, endCharacter: 9 },
+        isCall: true
+      })
+    ], {
+      imports: [{ localName: 'people', moduleSpecifier: 'example.com/app/pkg/people' }]
+    });
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should write one file record followed by its symbols per line', async () => {
+    const outputPath = path.join(testDir, 'out', 'index.jsonl');
+    const result = await new JsonLinesExporter(index.asBackgroundIndex(), '/ws').export(outputPath);
+
+    expect(result).toEqual({
+      outputPath,
+      files: 2,
+      counts: { file: 2, symbol: 2, reference: 0, import: 0 }
+    });
+
+    const records = fs.readFileSync(outputPath, 'utf-8').trim().split('\n').map(line => JSON.parse(line));
+    expect(records.map(r => `${r.type} ${r.path}`)).toEqual([
+      'file cmd/main.go',
+      'file pkg/people/person.go',
+      'symbol pkg/people/person.go',
+      'symbol pkg/people/person.go'
+    ]);
+    expect(records[1]).toMatchObject({ symbols: 2, references: 0, imports: 0, hash: `hash-${person}` });
+    expect(records[3]).toMatchObject({
+      name: 'Greet',
+      kind: 'method',
+      container: 'Person',
+      qualifiedName: 'Person.Greet',
+      line: 6,
+      character: 17,
+      endLine: 8,
+      endCharacter: 1,
+      definition: true
+    });
+  });
+
+  it('should stream the selected record types and wait for the consumer', async () => {
+    const chunks: string[] = [];
+    let pendingCallbacks = 0;
+    const slow = new Writable({
+      highWaterMark: 1,
+      write(chunk, _encoding, callback) {
+        chunks.push(chunk.toString());
+        pendingCallbacks++;
+        setTimeout(() => {
+          pendingCallbacks--;
+          callback();
+        }, 1);
+      }
+    });
+
+    const result = await new JsonLinesExporter(index.asBackgroundIndex(), '/ws').exportTo(slow, {
+      records: ['reference', 'import']
+    });
+
+    expect(result.counts).toEqual({ file: 0, symbol: 0, reference: 1, import: 1 });
+    expect(pendingCallbacks).toBeLessThanOrEqual(1);
+    expect(chunks.every(chunk => chunk.endsWith('\n'))).toBe(true);
+    expect(chunks.map(chunk => JSON.parse(chunk))).toEqual([
+      {
+        type: 'reference',
+        path: 'cmd/main.go',
+        name: 'Greet',
+        line: 9,
+        character: 4,
+        endLine: 9,
+        endCharacter: 9,
+        call: true,
+        local: false
+      },
+      {
+        type: 'import',
+        path: 'cmd/main.go',
+        module: 'example.com/app/pkg/people',
+        localName: 'people'
+      }
+    ]);
+  });
+});
@@ -0,0 +1,208 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedFileResult } from '../types.js';
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import { Writable } from 'stream';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type JsonLinesRecordType = 'file' | 'symbol' | 'reference' | 'import';
+
+export const JSON_LINES_RECORD_TYPES: readonly JsonLinesRecordType[] = ['file', 'symbol', 'reference', 'import'];
+
+export interface JsonLinesExportOptions {
+  /** Record types to write (default: file and symbol) */
+  records?: JsonLinesRecordType[];
+  /** Cancellation token for aborting the export */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting export progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface JsonLinesExportResult {
+  /** Output file, or undefined when written to a caller-supplied stream */
+  outputPath?: string;
+  files: number;
+  /** Lines written per record type */
+  counts: Record<JsonLinesRecordType, number>;
+}
+
+const YIELD_INTERVAL = 50;
+
+const DEFAULT_RECORDS: JsonLinesRecordType[] = ['file', 'symbol'];
+
+/**
+ * Exports the background index as JSON Lines: one self-contained record
+ * per line, written shard by shard with stream backpressure, so neither
+ * side ever holds the whole index (`jq -c 'select(.kind == "function")'`,
+ * bulk ingestion jobs).
+ *
+ * Every record has a `type` and a workspace-relative `path`:
+ *
+ *   {"type":"file","path":"pkg/a.go","hash":"...","symbols":12,"references":40,"imports":3,"tags":["test"]}
+ *   {"type":"symbol","path":"pkg/a.go","name":"Greet","kind":"method","container":"Person",
+ *    "qualifiedName":"Person.Greet","line":12,"character":17,"endLine":14,"endCharacter":1,
+ *    "startOffset":210,"endOffset":264,...}
+ *   {"type":"reference","path":"pkg/b.go","name":"Greet","line":3,"character":4,...}
+ *   {"type":"import","path":"pkg/b.go","module":"fmt","localName":"fmt"}
+ *
+ * Lines and characters are 0-based; `startOffset`/`endOffset` are UTF-8
+ * byte offsets into the file (end exclusive). A file's record precedes its symbols,
+ * references and imports; files are in path order.
+ */
+export class JsonLinesExporter {
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private workspaceRoot: string
+  ) {}
+
+  /**
+   * Write the export to outputPath.
+   */
+  async export(outputPath: string, options: JsonLinesExportOptions = {}): Promise<JsonLinesExportResult> {
+    await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+    const stream = fs.createWriteStream(outputPath, { encoding: 'utf-8' });
+
+    try {
+      const result = await this.exportTo(stream, options);
+      return { ...result, outputPath };
+    } finally {
+      await new Promise<void>((resolve, reject) => {
+        stream.once('error', reject);
+        stream.end(() => resolve());
+      });
+    }
+  }
+
+  /**
+   * Write the export to a stream (left open), e.g. an HTTP response or stdout.
+   */
+  async exportTo(stream: Writable, options: JsonLinesExportOptions = {}): Promise<JsonLinesExportResult> {
+    const { cancellationToken, onProgress } = options;
+    const records = new Set(options.records && options.records.length > 0 ? options.records : DEFAULT_RECORDS);
+    const counts: Record<JsonLinesRecordType, number> = { file: 0, symbol: 0, reference: 0, import: 0 };
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    let exported = 0;
+
+    const write = async (record: Record<string, unknown> & { type: JsonLinesRecordType }): Promise<void> => {
+      counts[record.type]++;
+      if (!stream.write(JSON.stringify(record) + '\n')) {
+        await new Promise<void>(resolve => stream.once('drain', resolve));
+      }
+    };
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Exporting records (${i}/${files.length})`);
+      }
+
+      const fileResult = await this.backgroundIndex.getFileResult(files[i]);
+      if (!fileResult || fileResult.isSkipped) {
+        continue;
+      }
+      exported++;
+      for (const record of this.toRecords(fileResult, records)) {
+        await write(record);
+      }
+    }
+
+    onProgress?.(files.length, files.length, 'JSON Lines export complete');
+    return { files: exported, counts };
+  }
+
+  private *toRecords(
+    fileResult: IndexedFileResult,
+    records: Set<JsonLinesRecordType>
+  ): Generator<Record<string, unknown> & { type: JsonLinesRecordType }> {
+    const filePath = path.relative(this.workspaceRoot, fileResult.uri).split(path.sep).join('/');
+
+    if (records.has('file')) {
+      yield {
+        type: 'file',
+        path: filePath,
+        hash: fileResult.hash,
+        symbols: fileResult.symbols.length,
+        references: fileResult.references.length,
+        imports: fileResult.imports.length,
+        tags: fileResult.tags
+      };
+    }
+
+    if (records.has('symbol')) {
+      for (const symbol of fileResult.symbols) {
+        yield {
+          type: 'symbol',
+          path: filePath,
+          id: symbol.id,
+          canonicalId: symbol.canonicalId,
+          name: symbol.name,
+          kind: symbol.kind,
+          container: symbol.containerName,
+          qualifiedName: symbol.fullContainerPath
+            ? `${symbol.fullContainerPath}.${symbol.name}`
+            : symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name,
+          line: symbol.range.startLine,
+          character: symbol.range.startCharacter,
+          endLine: symbol.range.endLine,
+          endCharacter: symbol.range.endCharacter,
+          startOffset: symbol.range.startOffset,
+          endOffset: symbol.range.endOffset,
+          definition: symbol.isDefinition !== false,
+          exported: symbol.isExported,
+          visibility: symbol.visibility,
+          tags: symbol.tags,
+          doc: symbol.doc,
+          deprecated: symbol.deprecated,
+          signature: symbol.signature,
+          typeParameters: symbol.typeParameters,
+          value: symbol.value,
+          metrics: symbol.metrics,
+          captures: symbol.captures,
+          metadata: symbol.metadata
+        };
+      }
+    }
+
+    if (records.has('reference')) {
+      for (const reference of fileResult.references) {
+        yield {
+          type: 'reference',
+          path: filePath,
+          name: reference.symbolName,
+          line: reference.range.startLine,
+          character: reference.range.startCharacter,
+          endLine: reference.range.endLine,
+          endCharacter: reference.range.endCharacter,
+          startOffset: reference.range.startOffset,
+          endOffset: reference.range.endOffset,
+          container: reference.containerName,
+          call: reference.isCall,
+          import: reference.isImport,
+          local: reference.isLocal
+        };
+      }
+    }
+
+    if (records.has('import')) {
+      for (const imp of fileResult.imports) {
+        yield {
+          type: 'import',
+          path: filePath,
+          module: imp.moduleSpecifier,
+          localName: imp.localName,
+          importedName: imp.exportedName,
+          default: imp.isDefault,
+          namespace: imp.isNamespace,
+          dynamic: imp.isDynamic
+        };
+      }
+    }
+  }
+}
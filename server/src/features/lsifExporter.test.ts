@@ -0,0 +1,95 @@
+/**
+ * LsifExporter Tests
+ * 
+ * Verifies the LSIF dump structure produced from the background index.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { LsifExporter, LSIF_VERSION } from './lsifExporter.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+
+function readDump(filePath: string): any[] {
+  return fs.readFileSync(filePath, 'utf-8').trim().split('\n').map(line => JSON.parse(line));
+}
+
+describe('LsifExporter', () => {
+  let testDir: string;
+  let index: MockBackgroundIndex;
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-lsif-'));
+    index = new MockBackgroundIndex();
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should emit metadata, documents, ranges and results', async () => {
+    const service = '/ws/src/service.ts';
+    const consumer = '/ws/src/consumer.ts';
+    index.addFile(service, [
+      createTestSymbol({ id: 'svc', name: 'UserService', kind: 'class', filePath: service, location: { uri: service, line: 2, character: 13 } })
+    ]);
+    index.addFile(consumer, [], [
+      createTestReference({
+        symbolName: 'UserService',
+        location: { uri: consumer, line: 5, character: 10 },
+        range: { startLine: 5, startCharacter: 10, endLine: 5, endCharacter: 21 }
+      })
+    ]);
+
+    const outputPath = path.join(testDir, 'dump.lsif');
+    const result = await new LsifExporter(index.asBackgroundIndex(), '/ws').export(outputPath);
+
+    expect(result.documents).toBe(2);
+    expect(result.definitions).toBe(1);
+    expect(result.references).toBe(1);
+    expect(result.unresolvedReferences).toBe(0);
+
+    const elements = readDump(outputPath);
+    expect(elements[0]).toMatchObject({ label: 'metaData', version: LSIF_VERSION });
+
+    const ids = elements.map(e => e.id);
+    expect(new Set(ids).size).toBe(ids.length);
+
+    const labels = elements.map(e => e.label);
+    expect(labels).toContain('definitionResult');
+    expect(labels).toContain('referenceResult');
+    expect(labels).toContain('hoverResult');
+
+    const definitionRange = elements.find(e => e.label === 'range' && e.start.line === 2);
+    expect(definitionRange.end.character).toBe(13 + 'UserService'.length);
+
+    // Every edge must point at previously emitted vertices
+    const seen = new Set<number>();
+    for (const element of elements) {
+      if (element.type === 'edge') {
+        const targets = element.inVs ?? [element.inV];
+        expect(seen.has(element.outV)).toBe(true);
+        for (const target of targets) {
+          expect(seen.has(target)).toBe(true);
+        }
+      }
+      seen.add(element.id);
+    }
+  });
+
+  it('should not guess between ambiguous cross-file definitions', async () => {
+    const a = '/ws/a.ts';
+    const b = '/ws/b.ts';
+    const c = '/ws/c.ts';
+    index.addFile(a, [createTestSymbol({ id: 'a', name: 'helper', filePath: a, location: { uri: a, line: 0, character: 0 } })]);
+    index.addFile(b, [createTestSymbol({ id: 'b', name: 'helper', filePath: b, location: { uri: b, line: 0, character: 0 } })]);
+    index.addFile(c, [], [createTestReference({ symbolName: 'helper', location: { uri: c, line: 1, character: 0 } })]);
+
+    const result = await new LsifExporter(index.asBackgroundIndex(), '/ws').export(path.join(testDir, 'dump.lsif'));
+
+    expect(result.references).toBe(0);
+    expect(result.unresolvedReferences).toBe(1);
+  });
+});
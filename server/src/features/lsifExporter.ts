@@ -0,0 +1,351 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol, IndexedReference } from '../types.js';
+import { URI } from 'vscode-uri';
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface LsifExportOptions {
+  /** Cancellation token for aborting the export */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting export progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface LsifExportResult {
+  outputPath: string;
+  documents: number;
+  definitions: number;
+  references: number;
+  /** References whose definition could not be resolved unambiguously */
+  unresolvedReferences: number;
+}
+
+/**
+ * LSIF protocol version emitted by the exporter.
+ */
+export const LSIF_VERSION = '0.5.0';
+
+const YIELD_INTERVAL = 50;
+
+const LANGUAGE_IDS: Record<string, string> = {
+  '.ts': 'typescript',
+  '.tsx': 'typescriptreact',
+  '.mts': 'typescript',
+  '.cts': 'typescript',
+  '.js': 'javascript',
+  '.jsx': 'javascriptreact',
+  '.mjs': 'javascript',
+  '.cjs': 'javascript',
+  '.go': 'go',
+  '.py': 'python',
+  '.java': 'java',
+  '.cs': 'csharp',
+  '.rs': 'rust',
+  '.c': 'c',
+  '.h': 'c',
+  '.cpp': 'cpp',
+  '.hpp': 'cpp'
+};
+
+/**
+ * Per-definition bookkeeping collected while emitting ranges.
+ */
+interface ResultSetEntry {
+  resultSetId: number;
+  definitionRanges: Map<number, number[]>; // documentId -> rangeIds
+  referenceRanges: Map<number, number[]>;  // documentId -> rangeIds
+}
+
+/**
+ * Exports the background index as an LSIF dump (JSON lines).
+ *
+ * The dump contains documents, definition/reference ranges, result sets
+ * with definition and reference results, and hover results, so it can be
+ * uploaded to code-intelligence pipelines (e.g. Sourcegraph `src lsif upload`).
+ *
+ * References are recorded by name in the index, so they are linked to a
+ * definition in the same file first, then to a workspace-unique definition.
+ * Ambiguous names are counted as unresolved instead of guessing.
+ */
+export class LsifExporter {
+  private nextId = 1;
+  private stream: fs.WriteStream | null = null;
+
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private workspaceRoot: string
+  ) {}
+
+  /**
+   * Write the LSIF dump to outputPath.
+   */
+  async export(outputPath: string, options: LsifExportOptions = {}): Promise<LsifExportResult> {
+    const { cancellationToken, onProgress } = options;
+    this.nextId = 1;
+
+    await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+    this.stream = fs.createWriteStream(outputPath, { encoding: 'utf-8' });
+
+    const result: LsifExportResult = {
+      outputPath,
+      documents: 0,
+      definitions: 0,
+      references: 0,
+      unresolvedReferences: 0
+    };
+
+    try {
+      const files = (await this.backgroundIndex.getAllFiles()).sort();
+      const totalSteps = files.length * 2;
+
+      await this.emit({
+        type: 'vertex',
+        label: 'metaData',
+        version: LSIF_VERSION,
+        projectRoot: URI.file(this.workspaceRoot).toString(),
+        positionEncoding: 'utf-16',
+        toolInfo: { name: 'smart-indexer' }
+      });
+      const projectId = await this.emit({ type: 'vertex', label: 'project', kind: 'typescript' });
+
+      // Pass 1: documents and one result set (+ hover) per definition
+      const documentIds = new Map<string, number>();
+      // Keyed by definitionKey() - shards may be reloaded between passes, so object identity is not stable
+      const resultSets = new Map<string, ResultSetEntry>();
+      const definitionsByName = new Map<string, { uri: string; key: string }[]>();
+
+      for (let i = 0; i < files.length; i++) {
+        if (i % YIELD_INTERVAL === 0) {
+          throwIfCancelled(cancellationToken);
+          await yieldToEventLoop();
+          onProgress?.(i, totalSteps, `Collecting definitions (${i}/${files.length})`);
+        }
+
+        const uri = files[i];
+        const fileResult = await this.backgroundIndex.getFileResult(uri);
+        if (!fileResult) {
+          continue;
+        }
+
+        const documentId = await this.emit({
+          type: 'vertex',
+          label: 'document',
+          uri: URI.file(uri).toString(),
+          languageId: documentLanguage(uri)
+        });
+        documentIds.set(uri, documentId);
+
+        for (const symbol of fileResult.symbols) {
+          const key = definitionKey(uri, symbol);
+          if (symbol.isDefinition === false || resultSets.has(key)) {
+            continue;
+          }
+
+          const resultSetId = await this.emit({ type: 'vertex', label: 'resultSet' });
+          const hoverId = await this.emit({
+            type: 'vertex',
+            label: 'hoverResult',
+            result: { contents: [{ language: documentLanguage(uri), value: this.formatHover(symbol) }] }
+          });
+          await this.emit({ type: 'edge', label: 'textDocument/hover', outV: resultSetId, inV: hoverId });
+
+          resultSets.set(key, { resultSetId, definitionRanges: new Map(), referenceRanges: new Map() });
+
+          let byName = definitionsByName.get(symbol.name);
+          if (!byName) {
+            byName = [];
+            definitionsByName.set(symbol.name, byName);
+          }
+          byName.push({ uri, key });
+        }
+      }
+
+      if (documentIds.size > 0) {
+        await this.emit({ type: 'edge', label: 'contains', outV: projectId, inVs: Array.from(documentIds.values()) });
+      }
+      result.documents = documentIds.size;
+
+      // Pass 2: ranges for definitions and references, linked to their result sets
+      for (let i = 0; i < files.length; i++) {
+        if (i % YIELD_INTERVAL === 0) {
+          throwIfCancelled(cancellationToken);
+          await yieldToEventLoop();
+          onProgress?.(files.length + i, totalSteps, `Emitting ranges (${i}/${files.length})`);
+        }
+
+        const uri = files[i];
+        const documentId = documentIds.get(uri);
+        const fileResult = documentId !== undefined ? await this.backgroundIndex.getFileResult(uri) : null;
+        if (documentId === undefined || !fileResult) {
+          continue;
+        }
+
+        const rangeIds: number[] = [];
+
+        for (const symbol of fileResult.symbols) {
+          const entry = resultSets.get(definitionKey(uri, symbol));
+          if (!entry) {
+            continue;
+          }
+
+          const rangeId = await this.emitRange(
+            symbol.location.line,
+            symbol.location.character,
+            symbol.location.line,
+            symbol.location.character + symbol.name.length
+          );
+          await this.emit({ type: 'edge', label: 'next', outV: rangeId, inV: entry.resultSetId });
+          pushRange(entry.definitionRanges, documentId, rangeId);
+          rangeIds.push(rangeId);
+          result.definitions++;
+        }
+
+        for (const reference of fileResult.references) {
+          if (reference.isLocal) {
+            continue;
+          }
+
+          const targetKey = this.resolveReference(reference, uri, definitionsByName);
+          if (!targetKey) {
+            result.unresolvedReferences++;
+            continue;
+          }
+
+          const entry = resultSets.get(targetKey)!;
+          const rangeId = await this.emitRange(
+            reference.range.startLine,
+            reference.range.startCharacter,
+            reference.range.endLine,
+            reference.range.endCharacter
+          );
+          await this.emit({ type: 'edge', label: 'next', outV: rangeId, inV: entry.resultSetId });
+          pushRange(entry.referenceRanges, documentId, rangeId);
+          rangeIds.push(rangeId);
+          result.references++;
+        }
+
+        if (rangeIds.length > 0) {
+          await this.emit({ type: 'edge', label: 'contains', outV: documentId, inVs: rangeIds });
+        }
+      }
+
+      // Pass 3: definition and reference results
+      for (const entry of resultSets.values()) {
+        if (entry.definitionRanges.size > 0) {
+          const definitionResultId = await this.emit({ type: 'vertex', label: 'definitionResult' });
+          await this.emit({ type: 'edge', label: 'textDocument/definition', outV: entry.resultSetId, inV: definitionResultId });
+          for (const [documentId, ranges] of entry.definitionRanges) {
+            await this.emit({ type: 'edge', label: 'item', outV: definitionResultId, inVs: ranges, document: documentId });
+          }
+        }
+
+        const referenceResultId = await this.emit({ type: 'vertex', label: 'referenceResult' });
+        await this.emit({ type: 'edge', label: 'textDocument/references', outV: entry.resultSetId, inV: referenceResultId });
+        for (const [documentId, ranges] of entry.definitionRanges) {
+          await this.emit({ type: 'edge', label: 'item', outV: referenceResultId, inVs: ranges, document: documentId, property: 'definitions' });
+        }
+        for (const [documentId, ranges] of entry.referenceRanges) {
+          await this.emit({ type: 'edge', label: 'item', outV: referenceResultId, inVs: ranges, document: documentId, property: 'references' });
+        }
+      }
+
+      onProgress?.(totalSteps, totalSteps, 'LSIF export complete');
+    } finally {
+      await this.closeStream();
+    }
+
+    return result;
+  }
+
+  /**
+   * Pick the definition a reference points to.
+   * Same-file definitions win; otherwise the name must be unique in the workspace.
+   */
+  private resolveReference(
+    reference: IndexedReference,
+    uri: string,
+    definitionsByName: Map<string, { uri: string; key: string }[]>
+  ): string | null {
+    const candidates = definitionsByName.get(reference.symbolName);
+    if (!candidates || candidates.length === 0) {
+      return null;
+    }
+
+    const local = candidates.find(c => c.uri === uri);
+    if (local) {
+      return local.key;
+    }
+
+    return candidates.length === 1 ? candidates[0].key : null;
+  }
+
+  /**
+   * Build the hover text for a definition.
+   */
+  private formatHover(symbol: IndexedSymbol): string {
+    const qualifiedName = symbol.fullContainerPath
+      ? `${symbol.fullContainerPath}.${symbol.name}`
+      : symbol.name;
+    return `(${symbol.kind}) ${qualifiedName}`;
+  }
+
+  private emitRange(startLine: number, startCharacter: number, endLine: number, endCharacter: number): Promise<number> {
+    return this.emit({
+      type: 'vertex',
+      label: 'range',
+      start: { line: startLine, character: startCharacter },
+      end: { line: endLine, character: endCharacter }
+    });
+  }
+
+  /**
+   * Write one LSIF element and return its id. Honors stream backpressure.
+   */
+  private async emit(element: Record<string, unknown>): Promise<number> {
+    const id = this.nextId++;
+    const line = JSON.stringify({ id, ...element }) + '\n';
+
+    if (!this.stream!.write(line)) {
+      await new Promise<void>(resolve => this.stream!.once('drain', resolve));
+    }
+
+    return id;
+  }
+
+  private closeStream(): Promise<void> {
+    const stream = this.stream;
+    this.stream = null;
+    if (!stream) {
+      return Promise.resolve();
+    }
+
+    return new Promise((resolve, reject) => {
+      stream.once('error', reject);
+      stream.end(() => resolve());
+    });
+  }
+}
+
+function pushRange(map: Map<number, number[]>, documentId: number, rangeId: number): void {
+  const ranges = map.get(documentId);
+  if (ranges) {
+    ranges.push(rangeId);
+  } else {
+    map.set(documentId, [rangeId]);
+  }
+}
+
+function definitionKey(uri: string, symbol: IndexedSymbol): string {
+  return `${uri}#${symbol.id}`;
+}
+
+function documentLanguage(uri: string): string {
+  return LANGUAGE_IDS[path.extname(uri).toLowerCase()] || 'plaintext';
+}
@@ -0,0 +1,83 @@
+/**
+ * Outline Tests
+ *
+ * Nesting by range, grouping of out-of-line members and range widening.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { buildOutline } from './outline.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const goUri = '/ws/model/person.go';
+
+const symbols = [
+  createTestSymbol({
+    name: 'Person', kind: 'struct', filePath: goUri,
+    location: { uri: goUri, line: 2, character: 5 },
+    range: { startLine: 2, startCharacter: 0, endLine: 4, endCharacter: 1 }
+  }),
+  createTestSymbol({
+    name: 'Name', kind: 'property', containerName: 'Person', filePath: goUri,
+    location: { uri: goUri, line: 3, character: 1 },
+    range: { startLine: 3, startCharacter: 1, endLine: 3, endCharacter: 12 }
+  }),
+  createTestSymbol({
+    name: 'Greet', kind: 'method', containerName: 'Person', filePath: goUri,
+    location: { uri: goUri, line: 6, character: 17 },
+    range: { startLine: 6, startCharacter: 0, endLine: 8, endCharacter: 1 }
+  }),
+  createTestSymbol({
+    name: 'Close', kind: 'method', containerName: 'Conn', filePath: goUri,
+    location: { uri: goUri, line: 10, character: 15 },
+    range: { startLine: 10, startCharacter: 0, endLine: 10, endCharacter: 20 }
+  }),
+  createTestSymbol({
+    name: 'run', kind: 'function', filePath: goUri,
+    location: { uri: goUri, line: 12, character: 5 },
+    range: { startLine: 12, startCharacter: 0, endLine: 16, endCharacter: 1 }
+  }),
+  createTestSymbol({
+    name: 'step', kind: 'function', filePath: goUri,
+    location: { uri: goUri, line: 13, character: 1 },
+    range: { startLine: 13, startCharacter: 1, endLine: 15, endCharacter: 2 }
+  }),
+  createTestSymbol({
+    name: 'Person', kind: 'text', filePath: goUri, isDefinition: false,
+    location: { uri: goUri, line: 14, character: 3 },
+    range: { startLine: 14, startCharacter: 3, endLine: 14, endCharacter: 9 }
+  })
+];
+
+describe('buildOutline', () => {
+  it('should nest members and nested functions by range', () => {
+    const outline = buildOutline(symbols);
+    expect(outline.map(node => [node.name, node.detail])).toEqual([
+      ['Person', undefined],
+      ['Greet', 'Person'],
+      ['Close', 'Conn'],
+      ['run', undefined]
+    ]);
+    expect(outline[0].children!.map(node => node.name)).toEqual(['Name']);
+    expect(outline[3].children!.map(node => node.name)).toEqual(['step']);
+  });
+
+  it('should group out-of-line members under their type', () => {
+    const outline = buildOutline(symbols, { groupByContainer: true });
+    expect(outline.map(node => [node.name, node.detail])).toEqual([
+      ['Person', undefined],
+      ['Close', 'Conn'],
+      ['run', undefined]
+    ]);
+    expect(outline[0].children!.map(node => [node.name, node.kind])).toEqual([['Name', 'property'], ['Greet', 'method']]);
+  });
+
+  it('should widen ranges recorded without the name', () => {
+    const [node] = buildOutline([createTestSymbol({
+      name: 'Greet', kind: 'method', filePath: goUri,
+      location: { uri: goUri, line: 6, character: 17 },
+      range: { startLine: 6, startCharacter: 0, endLine: 6, endCharacter: 10 }
+    })]);
+    expect(node.range).toEqual({ start: { line: 6, character: 0 }, end: { line: 6, character: 22 } });
+    expect(node.selectionRange).toEqual({ start: { line: 6, character: 17 }, end: { line: 6, character: 22 } });
+  });
+});
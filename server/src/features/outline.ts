@@ -0,0 +1,159 @@
+/**
+ * File outline - the declarations of one file as a tree.
+ *
+ * Built from the index without re-parsing: a symbol is nested under the
+ * innermost declaration whose range contains it (class → members,
+ * function → nested functions). Powers the LSP documentSymbol handler, the
+ * query server's /outline and the library's Indexer.outline().
+ */
+
+import { IndexedSymbol } from '../types.js';
+
+export interface OutlinePosition {
+  line: number;
+  character: number;
+}
+
+export interface OutlineRange {
+  start: OutlinePosition;
+  end: OutlinePosition;
+}
+
+export interface OutlineNode {
+  name: string;
+  /** Index kind: `class`, `struct`, `method`, ... */
+  kind: string;
+  /** Constant value (`= 5`), or the container of an out-of-line member */
+  detail?: string;
+  canonicalId?: string;
+  /** The whole declaration; always contains selectionRange */
+  range: OutlineRange;
+  /** The declared name */
+  selectionRange: OutlineRange;
+  children?: OutlineNode[];
+}
+
+export interface OutlineOptions {
+  /**
+   * Also nest members declared outside their type (Go methods) under the
+   * type when it is declared in the same file. Their ranges are then not
+   * inside the parent's, which LSP documentSymbol does not allow.
+   */
+  groupByContainer?: boolean;
+}
+
+/** Kinds that can own out-of-line members */
+const CONTAINER_KINDS = new Set(['class', 'struct', 'interface', 'type', 'enum']);
+
+/**
+ * Arrange the declarations of a file into a tree. Text occurrences and
+ * references are left out.
+ */
+export function buildOutline(symbols: IndexedSymbol[], options: OutlineOptions = {}): OutlineNode[] {
+  const items = symbols
+    .filter(symbol => symbol.isDefinition !== false && symbol.kind !== 'text')
+    .map(symbol => ({ symbol, node: toOutlineNode(symbol) }))
+    .sort((a, b) =>
+      comparePositions(a.node.range.start, b.node.range.start) ||
+      comparePositions(b.node.range.end, a.node.range.end)
+    );
+
+  const roots: Array<{ symbol: IndexedSymbol; node: OutlineNode }> = [];
+  const stack: Array<{ symbol: IndexedSymbol; node: OutlineNode }> = [];
+
+  for (const item of items) {
+    while (stack.length > 0 && !contains(stack[stack.length - 1].node.range, item.node.range)) {
+      stack.pop();
+    }
+
+    const parent = stack[stack.length - 1];
+    if (parent) {
+      addChild(parent.node, item.node);
+    } else {
+      roots.push(item);
+    }
+    stack.push(item);
+  }
+
+  return options.groupByContainer ? groupOutOfLineMembers(roots) : roots.map(root => withContainerDetail(root));
+}
+
+/**
+ * Move top-level members under the type they belong to, in source order.
+ */
+function groupOutOfLineMembers(roots: Array<{ symbol: IndexedSymbol; node: OutlineNode }>): OutlineNode[] {
+  const containers = new Map<string, OutlineNode>();
+  for (const { symbol, node } of roots) {
+    if (CONTAINER_KINDS.has(symbol.kind) && !containers.has(symbol.name)) {
+      containers.set(symbol.name, node);
+    }
+  }
+
+  const result: OutlineNode[] = [];
+  for (const root of roots) {
+    const container = root.symbol.containerName ? containers.get(root.symbol.containerName) : undefined;
+    if (container && container !== root.node) {
+      addChild(container, root.node);
+    } else {
+      result.push(withContainerDetail(root));
+    }
+  }
+  return result;
+}
+
+/**
+ * Members that could not be nested show their container instead.
+ */
+function withContainerDetail({ symbol, node }: { symbol: IndexedSymbol; node: OutlineNode }): OutlineNode {
+  if (symbol.containerName) {
+    node.detail = symbol.containerName;
+  }
+  return node;
+}
+
+function addChild(parent: OutlineNode, child: OutlineNode): void {
+  if (!parent.children) {
+    parent.children = [];
+  }
+  parent.children.push(child);
+}
+
+function toOutlineNode(symbol: IndexedSymbol): OutlineNode {
+  const selectionRange: OutlineRange = {
+    start: { line: symbol.location.line, character: symbol.location.character },
+    end: { line: symbol.location.line, character: symbol.location.character + symbol.name.length }
+  };
+
+  // Widen the declaration range if the index recorded it without the name
+  const declared: OutlineRange = {
+    start: { line: symbol.range.startLine, character: symbol.range.startCharacter },
+    end: { line: symbol.range.endLine, character: symbol.range.endCharacter }
+  };
+  const range: OutlineRange = {
+    start: comparePositions(declared.start, selectionRange.start) <= 0 ? declared.start : selectionRange.start,
+    end: comparePositions(declared.end, selectionRange.end) >= 0 ? declared.end : selectionRange.end
+  };
+
+  return {
+    name: symbol.name,
+    kind: symbol.kind,
+    // Constants show their value: MaxRetries = 5
+    ...(symbol.value !== undefined && { detail: `= ${symbol.value}` }),
+    ...(symbol.canonicalId && { canonicalId: symbol.canonicalId }),
+    range,
+    selectionRange
+  };
+}
+
+function comparePositions(a: OutlinePosition, b: OutlinePosition): number {
+  return a.line - b.line || a.character - b.character;
+}
+
+/**
+ * Strict containment - symbols sharing the exact same range stay siblings.
+ */
+function contains(outer: OutlineRange, inner: OutlineRange): boolean {
+  const startsBefore = comparePositions(outer.start, inner.start);
+  const endsAfter = comparePositions(inner.end, outer.end);
+  return startsBefore <= 0 && endsAfter <= 0 && (startsBefore < 0 || endsAfter < 0);
+}
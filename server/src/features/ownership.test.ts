@@ -0,0 +1,69 @@
+/**
+ * Ownership Tests
+ *
+ * Verifies the per-owner aggregation of files, definitions and exported API.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { Ownership } from './ownership.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { parseCodeOwners, resolveOwners } from '../utils/codeOwners.js';
+import { IndexedSymbol } from '../types.js';
+
+function def(uri: string, name: string, extra: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    id: `${uri}:${name}`,
+    name,
+    kind: 'function',
+    filePath: uri,
+    location: { uri, line: 0, character: 0 },
+    isExported: /^[A-Z]/.test(name),
+    ...extra
+  });
+}
+
+describe('Ownership', () => {
+  let index: MockBackgroundIndex;
+  let ownership: Ownership;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    index.addFile('/ws/pkg/api/handler.go', [def('/ws/pkg/api/handler.go', 'Handle'), def('/ws/pkg/api/handler.go', 'parse')]);
+    index.addFile('/ws/pkg/api/handler_test.go', [
+      def('/ws/pkg/api/handler_test.go', 'TestHandle', { tags: ['test'] })
+    ]);
+    index.addFile('/ws/pkg/store/store.go', [def('/ws/pkg/store/store.go', 'Save'), def('/ws/pkg/store/store.go', 'Load')]);
+    index.addFile('/ws/tools/gen.go', [def('/ws/tools/gen.go', 'main')]);
+
+    const rules = parseCodeOwners('/pkg/ @platform-team\n/pkg/api/ @api-owners @platform-team');
+    ownership = new Ownership(index.asBackgroundIndex(), {
+      ownersOf: filePath => resolveOwners(rules, filePath.replace(/^\/ws\//, ''))
+    });
+  });
+
+  it('should count files, definitions and exported API per owner', async () => {
+    const report = await ownership.summarize({ tags: { exclude: ['test'] } });
+
+    expect(report.owners).toEqual([
+      { owner: '@platform-team', files: 2, symbols: 4, exported: 3 },
+      { owner: '@api-owners', files: 1, symbols: 2, exported: 1 }
+    ]);
+    expect(report.unowned).toEqual({ files: 1, symbols: 1, exported: 0 });
+    expect(report.filesScanned).toBe(4);
+    expect(ownership.ownersOf('/ws/pkg/api/handler.go')).toEqual(['@api-owners', '@platform-team']);
+  });
+
+  it('should keep only the files of one owner and scope', async () => {
+    const report = await ownership.summarize({ owner: 'API-Owners' });
+    expect(report.owners.map(summary => [summary.owner, summary.files])).toEqual([
+      ['@api-owners', 2],
+      ['@platform-team', 2]
+    ]);
+    expect(report.unowned.files).toBe(0);
+
+    const scoped = await ownership.summarize({ scopePath: '/ws/pkg/store' });
+    expect(scoped.owners).toEqual([{ owner: '@platform-team', files: 1, symbols: 2, exported: 2 }]);
+    expect(scoped.filesScanned).toBe(1);
+  });
+});
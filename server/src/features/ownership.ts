@@ -0,0 +1,112 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { CodeTagFilter, isTagFilterEmpty, matchesTagFilter } from '../utils/codeTags.js';
+import { normalizeOwner, OwnerLookup } from '../utils/codeOwners.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** The part of an index the report reads: the background index or the library Indexer */
+export type OwnershipSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols'>;
+
+export interface OwnershipOptions {
+  /** Only count files owned by this owner (`@platform-team`, `platform-team`) */
+  owner?: string;
+  /** Only files under this folder */
+  scopePath?: string;
+  /** Code tags to leave out or keep, e.g. `{ exclude: ['test', 'generated'] }` */
+  tags?: CodeTagFilter;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface OwnerSummary {
+  /** As written in CODEOWNERS; undefined for unowned code */
+  owner?: string;
+  files: number;
+  /** Definitions in those files */
+  symbols: number;
+  /** Exported definitions: the owner's API */
+  exported: number;
+}
+
+export interface OwnershipReport {
+  /** Most files first; a file with several owners counts for each */
+  owners: OwnerSummary[];
+  unowned: OwnerSummary;
+  filesScanned: number;
+}
+
+const YIELD_INTERVAL = 50;
+
+/**
+ * Ownership - how much code and API each CODEOWNERS owner has: files,
+ * definitions and exported definitions per owner, and what nobody owns.
+ * Files are read from the index in path order and their owners looked up
+ * (see utils/codeOwners.ts); tag filters apply per symbol.
+ */
+export class Ownership {
+  constructor(private index: OwnershipSourceIndex, private codeOwners: OwnerLookup) {}
+
+  /** Owners of one file, in CODEOWNERS order */
+  ownersOf(filePath: string): string[] {
+    return this.codeOwners.ownersOf(filePath);
+  }
+
+  async summarize(options: OwnershipOptions = {}): Promise<OwnershipReport> {
+    const { cancellationToken, onProgress } = options;
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+    const tags = options.tags && !isTagFilterEmpty(options.tags) ? options.tags : undefined;
+    const wanted = options.owner !== undefined ? normalizeOwner(options.owner) : undefined;
+
+    const files = (await this.index.getAllFiles())
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const summaries = new Map<string, OwnerSummary>();
+    const unowned: OwnerSummary = { files: 0, symbols: 0, exported: 0 };
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Summarizing ownership (${i}/${files.length})`);
+      }
+
+      const owners = this.codeOwners.ownersOf(files[i]);
+      if (wanted && !owners.some(owner => normalizeOwner(owner) === wanted)) {
+        continue;
+      }
+      const definitions = (await this.index.getFileSymbols(files[i]))
+        .filter(symbol => symbol.isDefinition !== false && (!tags || matchesTagFilter(symbol.tags, tags)));
+      if (tags && definitions.length === 0) {
+        continue;
+      }
+      const exported = definitions.filter(symbol => symbol.isExported === true).length;
+
+      const targets = owners.length > 0 ? owners.map(owner => {
+        let summary = summaries.get(owner);
+        if (!summary) {
+          summary = { owner, files: 0, symbols: 0, exported: 0 };
+          summaries.set(owner, summary);
+        }
+        return summary;
+      }) : [unowned];
+      for (const summary of targets) {
+        summary.files++;
+        summary.symbols += definitions.length;
+        summary.exported += exported;
+      }
+    }
+
+    return {
+      owners: [...summaries.values()].sort((a, b) => b.files - a.files || (a.owner! < b.owner! ? -1 : 1)),
+      unowned,
+      filesScanned: files.length
+    };
+  }
+}
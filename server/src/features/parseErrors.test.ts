@@ -0,0 +1,49 @@
+/**
+ * ParseErrors Tests
+ *
+ * Verifies the listing of syntax errors stored with indexed files.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { ParseErrors } from './parseErrors.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+describe('ParseErrors', () => {
+  let index: MockBackgroundIndex;
+  let parseErrors: ParseErrors;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    index.addFile('/ws/web/broken.ts', [], [], {
+      diagnostics: [
+        { message: "'}' expected.", line: 3, character: 18 },
+        { message: 'Declaration or statement expected.', line: 9, character: 0 }
+      ]
+    });
+    index.addFile('/ws/web/fine.ts', []);
+    index.addFile('/ws/api/handler.go', [], [], {
+      diagnostics: [{ message: "Unclosed '{'", line: 2, character: 31 }]
+    });
+    index.addFile('/ws/api/empty.go', [], [], { diagnostics: [] });
+    parseErrors = new ParseErrors(index.asBackgroundIndex());
+  });
+
+  it('should list files with diagnostics in path order', async () => {
+    const report = await parseErrors.list();
+    expect(report.files.map(f => [f.uri, f.diagnostics.length])).toEqual([
+      ['/ws/api/handler.go', 1],
+      ['/ws/web/broken.ts', 2]
+    ]);
+    expect(report).toMatchObject({ errors: 3, filesScanned: 4, truncated: false });
+  });
+
+  it('should restrict the listing to a folder and a limit', async () => {
+    const scoped = await parseErrors.list({ scopePath: '/ws/web' });
+    expect(scoped.files.map(f => f.uri)).toEqual(['/ws/web/broken.ts']);
+    expect(scoped.filesScanned).toBe(2);
+
+    const limited = await parseErrors.list({ limit: 1 });
+    expect(limited.files.map(f => f.uri)).toEqual(['/ws/api/handler.go']);
+    expect(limited).toMatchObject({ errors: 3, truncated: true });
+  });
+});
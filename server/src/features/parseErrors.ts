@@ -0,0 +1,86 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { ParseDiagnostic } from '../types.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** The part of an index the listing reads: the background index or the library Indexer */
+export type ParseErrorSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileResult'>;
+
+export interface ParseErrorOptions {
+  /** Only files under this folder */
+  scopePath?: string;
+  /** Number of files to return (default: 200) */
+  limit?: number;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface FileParseErrors {
+  uri: string;
+  diagnostics: ParseDiagnostic[];
+}
+
+export interface ParseErrorReport {
+  /** Files with syntax errors, in path order */
+  files: FileParseErrors[];
+  /** Diagnostics in all matching files, before the limit */
+  errors: number;
+  filesScanned: number;
+  truncated: boolean;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 200;
+
+/**
+ * Parse Errors - lists the syntax errors recorded at indexing time.
+ *
+ * Broken files are still indexed: the TS/JS parser skips the top-level
+ * declarations it cannot read (see indexer/components/ParseRecovery.ts) and
+ * the Go and Python indexers resynchronize at the next declaration. What
+ * they skipped is stored with the file as diagnostics, so a file with
+ * missing symbols can be told apart from one that has none.
+ */
+export class ParseErrors {
+  constructor(private index: ParseErrorSourceIndex) {}
+
+  async list(options: ParseErrorOptions = {}): Promise<ParseErrorReport> {
+    const { cancellationToken, onProgress } = options;
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+
+    const files = (await this.index.getAllFiles())
+      .filter(uri => !scope || uri === scope || uri.startsWith(scope + path.sep))
+      .sort();
+    const matches: FileParseErrors[] = [];
+    let errors = 0;
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting parse errors (${i}/${files.length})`);
+      }
+
+      const diagnostics = (await this.index.getFileResult(files[i]))?.diagnostics;
+      if (diagnostics && diagnostics.length > 0) {
+        matches.push({ uri: files[i], diagnostics });
+        errors += diagnostics.length;
+      }
+    }
+
+    return {
+      files: matches.slice(0, limit),
+      errors,
+      filesScanned: files.length,
+      truncated: matches.length > limit
+    };
+  }
+}
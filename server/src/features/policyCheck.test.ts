@@ -0,0 +1,139 @@
+/**
+ * PolicyCheck Tests
+ *
+ * Checks doc comment, import and complexity policies over a throwaway
+ * git repository, across the whole index and for code new since a commit.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { PolicyCheck, formatPolicyReport } from './policyCheck.js';
+import { PolicyConfig } from '../config/configurationManager.js';
+import { LanguageRouter } from '../indexer/languageRouter.js';
+import { SymbolIndexer } from '../indexer/symbolIndexer.js';
+import { IndexedFileResult } from '../types.js';
+
+const storeGo = `package store
+
+import "example.com/acme/pkg/legacy"
+
+// Open opens a store.
+func Open() {}
+
+func Close() {}
+
+func route(kind int) int {
+	if kind == 1 {
+		return 1
+	} else if kind == 2 {
+		return 2
+	}
+	return legacy.Route(kind)
+}
+`;
+
+const policies: PolicyConfig[] = [
+  { name: 'exported-docs', rule: 'exported-doc' },
+  { name: 'no-legacy', rule: 'forbidden-import', imports: ['pkg/legacy/**'], exclude: ['pkg/legacy/**'], message: 'use pkg/routing instead' },
+  { name: 'complexity', rule: 'max-metric', max: 2, severity: 'warning' }
+];
+
+describe('PolicyCheck', () => {
+  let root: string;
+  let results: Map<string, IndexedFileResult>;
+  const router = new LanguageRouter(new SymbolIndexer());
+
+  function git(args: string[]): string {
+    return execFileSync('git', args, {
+      cwd: root,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com',
+        GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com'
+      }
+    });
+  }
+
+  async function write(relative: string, content: string): Promise<void> {
+    const uri = path.join(root, relative);
+    fs.mkdirSync(path.dirname(uri), { recursive: true });
+    fs.writeFileSync(uri, content);
+    results.set(uri, await router.indexFile(uri, content));
+  }
+
+  function createCheck(): PolicyCheck {
+    return new PolicyCheck(
+      { getAllFiles: async () => [...results.keys()], getFileResult: async uri => results.get(uri) ?? null },
+      root,
+      (uri, content) => router.indexFile(uri, content),
+      async args => git(args)
+    );
+  }
+
+  beforeEach(async () => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'policy-check-'));
+    results = new Map();
+    fs.writeFileSync(path.join(root, 'go.mod'), 'module example.com/acme\n\ngo 1.22\n');
+    await write('pkg/legacy/legacy.go', 'package legacy\n\nfunc Route(kind int) int { return kind }\n');
+    await write('pkg/store/store.go', storeGo);
+    await write('pkg/store/store_test.go', 'package store\n\nfunc TestOpen(t *testing.T) {}\n');
+    git(['init', '-q']);
+    git(['add', '.']);
+    git(['commit', '-q', '-m', 'initial']);
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should report every violation in the index', async () => {
+    const report = await createCheck().check(policies);
+
+    expect([report.filesChecked, report.errors, report.warnings, report.passed]).toEqual([3, 3, 1, false]);
+    expect(formatPolicyReport(report)).toBe([
+      'pkg/legacy/legacy.go:3:6: error: Exported function Route has no doc comment; add a comment starting with "Route" [exported-docs]',
+      'pkg/store/store.go:3:8: error: Import of example.com/acme/pkg/legacy is forbidden (matches pkg/legacy/**): use pkg/routing instead [no-legacy]',
+      'pkg/store/store.go:8:6: error: Exported function Close has no doc comment; add a comment starting with "Close" [exported-docs]',
+      'pkg/store/store.go:10:6: warning: route has complexity 3, above the maximum of 2 [complexity]',
+      '3 errors, 1 warning in 3 files (3 policies)',
+      ''
+    ].join('\n'));
+    expect(formatPolicyReport(report, 'github').split('\n')[1]).toBe(
+      '::error file=pkg/store/store.go,line=3,col=8,title=no-legacy::Import of example.com/acme/pkg/legacy is forbidden (matches pkg/legacy/**): use pkg/routing instead'
+    );
+  });
+
+  it('should only check code new since a commit', async () => {
+    await write('pkg/store/store.go', storeGo.replace('func Close() {}', 'func Close() {}\n\nfunc Flush() {}'));
+    await write('pkg/api/api.go', 'package api\n\nimport "example.com/acme/pkg/legacy"\n\n// Serve serves.\nfunc Serve() { legacy.Route(0) }\n');
+    const report = await createCheck().check(policies, { since: 'HEAD' });
+
+    expect(report.since).toBe(git(['rev-parse', 'HEAD']).trim());
+    expect(report.filesChecked).toBe(2);
+    // Close and the legacy import of store.go, and the unchanged route, predate the commit
+    expect(report.violations.map(v => `${path.relative(root, v.file)} ${v.policy} ${v.symbol ?? ''}`.trim())).toEqual([
+      `${path.join('pkg', 'api', 'api.go')} no-legacy`,
+      `${path.join('pkg', 'store', 'store.go')} exported-docs Flush`
+    ]);
+  });
+
+  it('should select policies by name and reject invalid ones', async () => {
+    const check = createCheck();
+
+    const report = await check.check(policies, { policies: ['complexity'] });
+    expect([report.policies, report.passed, report.warnings]).toEqual([['complexity'], true, 1]);
+    expect(formatPolicyReport(await check.check(policies, { policies: ['no-legacy'], since: 'HEAD' })))
+      .toMatch(/^No policy violations in 0 files changed since [0-9a-f]{8} \(1 policy\)\n$/);
+
+    await expect(check.check(policies, { policies: ['docs'] })).rejects.toThrow('Unknown policy "docs"');
+    await expect(check.check([{ name: 'deep', rule: 'max-metric', metric: 'depth' as never, max: 3 }]))
+      .rejects.toThrow('Invalid policy "deep": unknown metric "depth"');
+    await expect(check.check([{ name: 'imports', rule: 'forbidden-import' }]))
+      .rejects.toThrow('Invalid policy "imports": forbidden-import needs "imports" globs');
+  });
+});
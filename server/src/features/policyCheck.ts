@@ -0,0 +1,450 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { minimatch } from 'minimatch';
+import { simpleGit } from 'simple-git';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { PolicyConfig } from '../config/configurationManager.js';
+import { CodeTag, IndexedFileResult, IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { classifyPath } from '../utils/codeTags.js';
+import { ChangedLineRange, GitRunner, gitChangedLinesSince } from '../git/gitDelta.js';
+import { CODE_METRICS, CodeMetric } from './codeMetrics.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export const POLICY_RULES = ['exported-doc', 'forbidden-import', 'max-metric'] as const;
+
+export type PolicyRule = typeof POLICY_RULES[number];
+
+/** The part of an index the check reads: the background index or the library Indexer */
+export type PolicySourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileResult'>;
+
+/** Indexes a file's content at the base commit, e.g. LanguageRouter.indexFile */
+export type PolicyContentIndexer = (uri: string, content: string) => Promise<IndexedFileResult>;
+
+export interface PolicyCheckOptions {
+  /**
+   * Check only code new since this commit (`origin/main`, `HEAD`):
+   * exported symbols and imports the file did not have, and functions on
+   * changed lines. Without it every indexed file is checked.
+   */
+  since?: string;
+  /** Only the policies with these names (default: all) */
+  policies?: string[];
+  /** Cancellation token for aborting the check */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting check progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface PolicyViolation {
+  policy: string;
+  rule: PolicyRule;
+  severity: 'error' | 'warning';
+  /** Absolute path */
+  file: string;
+  /** 0-based */
+  line: number;
+  character: number;
+  message: string;
+  /** Qualified name of the symbol at fault, e.g. `Store.Get` */
+  symbol?: string;
+}
+
+export interface PolicyReport {
+  /** The workspace root checked; violations are reported relative to it */
+  root: string;
+  /** The commit compared with, resolved to its hash */
+  since?: string;
+  head?: string;
+  /** Names of the policies checked */
+  policies: string[];
+  filesChecked: number;
+  /** In path and line order */
+  violations: PolicyViolation[];
+  errors: number;
+  warnings: number;
+  /** No error-severity violations; CI fails the build otherwise */
+  passed: boolean;
+}
+
+/** What the check knows about a file */
+interface CheckedFile {
+  uri: string;
+  /** Workspace-relative, `/`-separated */
+  relative: string;
+  result: IndexedFileResult;
+  status: 'added' | 'modified' | 'unchanged';
+  ranges: ChangedLineRange[];
+}
+
+const YIELD_INTERVAL = 50;
+
+/** Code that needs no doc comments */
+const UNDOCUMENTED_TAGS: CodeTag[] = ['test', 'mock', 'generated', 'example'];
+
+/**
+ * Policy Check - enforces repository policies (see PolicyConfig) over the
+ * index, for pre-commit hooks and CI gates:
+ *
+ * - `exported-doc`: top-level exported declarations and exported methods
+ *   have a doc comment; test, mock, generated and example code is exempt
+ * - `forbidden-import`: no import matches an `imports` glob. Globs match
+ *   the import as written and the workspace folder it resolves to
+ *   (relative TS/JS imports, Go packages of the module, Python modules
+ *   with `/` for dots), so `pkg/legacy/**` catches
+ *   `example.com/acme/pkg/legacy`, `../legacy/db` and `pkg.legacy`
+ * - `max-metric`: no function or method has a metric (complexity, loc,
+ *   parameters or nesting, see features/codeMetrics.ts) above `max`
+ *
+ * With `since`, only new code counts, so a policy can be introduced
+ * without fixing the whole repository first: exported symbols and imports
+ * the base version of the file lacked (everything in added files), and
+ * functions overlapping the lines changed since the commit. Base versions
+ * are read with `git show` and indexed.
+ */
+export class PolicyCheck {
+  constructor(
+    private index: PolicySourceIndex,
+    private workspaceRoot: string,
+    private indexContent: PolicyContentIndexer,
+    private runGit?: GitRunner,
+    private readFile: (uri: string) => Promise<string> = uri => fs.promises.readFile(uri, 'utf-8')
+  ) {}
+
+  /**
+   * Throws on invalid or unknown policies, and with `since` on directories
+   * outside a repository and unknown commits.
+   */
+  async check(policies: PolicyConfig[], options: PolicyCheckOptions = {}): Promise<PolicyReport> {
+    const { cancellationToken, onProgress } = options;
+    for (const policy of policies) {
+      validatePolicy(policy);
+    }
+    const selected = options.policies && options.policies.length > 0 ? options.policies : undefined;
+    for (const name of selected ?? []) {
+      if (!policies.some(policy => policy.name === name)) {
+        throw new Error(`Unknown policy "${name}"`);
+      }
+    }
+    const active = selected ? policies.filter(policy => selected.includes(policy.name)) : policies;
+
+    const root = path.resolve(this.workspaceRoot);
+    let run = this.runGit;
+    if (!run && options.since !== undefined) {
+      const git = simpleGit(root);
+      run = args => git.raw(args);
+    }
+    const delta = options.since !== undefined ? await gitChangedLinesSince(root, options.since, run) : undefined;
+
+    const candidates: Array<Pick<CheckedFile, 'uri' | 'status' | 'ranges'>> = delta
+      ? delta.files.filter(file => file.status !== 'deleted').map(({ file, status, ranges }) => ({ uri: file, status, ranges }))
+      : (await this.index.getAllFiles()).sort().map(uri => ({ uri, status: 'unchanged' as const, ranges: [] }));
+
+    const violations: PolicyViolation[] = [];
+    let filesChecked = 0;
+    for (let i = 0; i < candidates.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, candidates.length, `Checking policies (${i}/${candidates.length})`);
+      }
+
+      const { uri, status, ranges } = candidates[i];
+      const relative = path.relative(root, uri);
+      if (relative.startsWith('..') || path.isAbsolute(relative)) {
+        continue;
+      }
+      const result = await this.index.getFileResult(uri);
+      if (!result) {
+        continue;
+      }
+      const file: CheckedFile = { uri, relative: relative.split(path.sep).join('/'), result, status, ranges };
+      const applicable = active.filter(policy => appliesTo(policy, file.relative));
+      if (applicable.length === 0) {
+        continue;
+      }
+      filesChecked++;
+
+      const needsBase = status === 'modified' && applicable.some(policy => policy.rule !== 'max-metric');
+      const base = needsBase ? await this.baseVersion(run!, delta!.since, file) : undefined;
+      let content: string | undefined;
+      const readContent = async () => (content ??= await this.readFile(uri).catch(() => ''));
+
+      for (const policy of applicable) {
+        switch (policy.rule) {
+          case 'exported-doc':
+            violations.push(...checkExportedDocs(policy, file, base));
+            break;
+          case 'forbidden-import':
+            violations.push(...checkImports(policy, file, base, await readContent()));
+            break;
+          case 'max-metric':
+            violations.push(...checkMetric(policy, file));
+            break;
+        }
+      }
+    }
+    onProgress?.(candidates.length, candidates.length, 'Done');
+
+    violations.sort((a, b) =>
+      (a.file < b.file ? -1 : a.file > b.file ? 1 : 0) || a.line - b.line || a.character - b.character || a.policy.localeCompare(b.policy)
+    );
+    const errors = violations.filter(violation => violation.severity === 'error').length;
+    return {
+      root,
+      ...(delta && { since: delta.since, head: delta.head }),
+      policies: active.map(policy => policy.name),
+      filesChecked,
+      violations,
+      errors,
+      warnings: violations.length - errors,
+      passed: errors === 0
+    };
+  }
+
+  /** The file at the base commit, indexed; undefined if it is unreadable there */
+  private async baseVersion(run: GitRunner, commit: string, file: CheckedFile): Promise<IndexedFileResult | undefined> {
+    try {
+      return await this.indexContent(file.uri, await run(['show', `${commit}:./${file.relative}`]));
+    } catch {
+      return undefined;
+    }
+  }
+}
+
+/**
+ * Throws `Invalid policy "name": ...` for policies the check cannot run.
+ */
+export function validatePolicy(policy: PolicyConfig): void {
+  const invalid = (reason: string) => new Error(`Invalid policy "${policy.name}": ${reason}`);
+  if (!POLICY_RULES.includes(policy.rule)) {
+    throw invalid(`unknown rule "${policy.rule}", expected one of ${POLICY_RULES.join(', ')}`);
+  }
+  if (policy.severity !== undefined && policy.severity !== 'error' && policy.severity !== 'warning') {
+    throw invalid(`severity must be error or warning, not "${policy.severity}"`);
+  }
+  if (policy.rule === 'forbidden-import' && !(Array.isArray(policy.imports) && policy.imports.length > 0)) {
+    throw invalid('forbidden-import needs "imports" globs');
+  }
+  if (policy.rule === 'max-metric') {
+    if (policy.metric !== undefined && !CODE_METRICS.includes(policy.metric)) {
+      throw invalid(`unknown metric "${policy.metric}", expected one of ${CODE_METRICS.join(', ')}`);
+    }
+    if (typeof policy.max !== 'number' || !(policy.max >= 0)) {
+      throw invalid('max-metric needs a "max" of 0 or more');
+    }
+  }
+}
+
+function checkExportedDocs(policy: PolicyConfig, file: CheckedFile, base: IndexedFileResult | undefined): PolicyViolation[] {
+  // Files indexed before code tags existed have none stored
+  if ((file.result.tags ?? classifyPath(file.uri)).some(tag => UNDOCUMENTED_TAGS.includes(tag))) {
+    return [];
+  }
+  const existing = new Set(base?.symbols.filter(symbol => symbol.isExported).map(symbolKey));
+  const isGo = file.uri.endsWith('.go');
+  const violations: PolicyViolation[] = [];
+  for (const symbol of file.result.symbols) {
+    if (symbol.isDefinition === false || !symbol.isExported || symbol.doc?.trim() ||
+        (symbol.containerName && symbol.kind !== 'method') || existing.has(symbolKey(symbol))) {
+      continue;
+    }
+    if (symbol.tags?.some(tag => UNDOCUMENTED_TAGS.includes(tag))) {
+      continue;
+    }
+    const name = qualifiedName(symbol);
+    const hint = isGo ? `; add a comment starting with "${symbol.name}"` : '';
+    violations.push(violation(policy, file, symbol.location.line, symbol.location.character,
+      `Exported ${symbol.kind} ${name} has no doc comment${hint}`, name));
+  }
+  return violations;
+}
+
+function checkImports(policy: PolicyConfig, file: CheckedFile, base: IndexedFileResult | undefined, content: string): PolicyViolation[] {
+  const existing = new Set(base?.imports.map(imp => imp.moduleSpecifier));
+  const reported = new Set<string>();
+  const violations: PolicyViolation[] = [];
+  for (const { moduleSpecifier } of file.result.imports) {
+    if (existing.has(moduleSpecifier) || reported.has(moduleSpecifier)) {
+      continue;
+    }
+    const names = importNames(moduleSpecifier, file);
+    const pattern = policy.imports!.find(glob => names.some(name => matchesPath(name, glob)));
+    if (!pattern) {
+      continue;
+    }
+    reported.add(moduleSpecifier);
+    const { line, character } = specifierPosition(content, moduleSpecifier);
+    violations.push(violation(policy, file, line, character, `Import of ${moduleSpecifier} is forbidden (matches ${pattern})`));
+  }
+  return violations;
+}
+
+function checkMetric(policy: PolicyConfig, file: CheckedFile): PolicyViolation[] {
+  const metric: CodeMetric = policy.metric ?? 'complexity';
+  const max = policy.max!;
+  const violations: PolicyViolation[] = [];
+  for (const symbol of file.result.symbols) {
+    if (!symbol.metrics || symbol.isDefinition === false) {
+      continue;
+    }
+    if (file.status === 'modified' && !file.ranges.some(range => overlaps(symbol, range))) {
+      continue;
+    }
+    const value = metric === 'parameters' ? symbol.parametersCount ?? 0 : symbol.metrics[metric];
+    if (value > max) {
+      const name = qualifiedName(symbol);
+      violations.push(violation(policy, file, symbol.location.line, symbol.location.character,
+        `${name} has ${metric} ${value}, above the maximum of ${max}`, name));
+    }
+  }
+  return violations;
+}
+
+function violation(
+  policy: PolicyConfig,
+  file: CheckedFile,
+  line: number,
+  character: number,
+  reason: string,
+  symbol?: string
+): PolicyViolation {
+  return {
+    policy: policy.name,
+    rule: policy.rule,
+    severity: policy.severity ?? 'error',
+    file: file.uri,
+    line,
+    character,
+    message: policy.message ? `${reason}: ${policy.message}` : reason,
+    ...(symbol && { symbol })
+  };
+}
+
+/**
+ * The names an import is matched by: as written, and the workspace
+ * folder or file it resolves to.
+ */
+function importNames(specifier: string, file: CheckedFile): string[] {
+  const names = [specifier];
+  const dir = path.posix.dirname(file.relative);
+  if (file.uri.endsWith('.go')) {
+    // The module path is the file's import path without its folder
+    const importPath = (file.result.symbols.find(s => s.metadata?.go)?.metadata?.go as GoSymbolMetadata | undefined)?.importPath;
+    const module = !importPath || dir === '.' ? importPath
+      : importPath.endsWith('/' + dir) ? importPath.slice(0, -dir.length - 1) : undefined;
+    if (module && specifier.startsWith(module + '/')) {
+      names.push(specifier.slice(module.length + 1));
+    }
+  } else if (specifier.startsWith('./') || specifier.startsWith('../')) {
+    names.push(path.posix.normalize(path.posix.join(dir, specifier)));
+  } else if (/\.pyi?$/.test(file.uri)) {
+    // `.models` is relative to the file's package, `..models` to its parent
+    const dots = /^\.*/.exec(specifier)![0].length;
+    const segments = dir === '.' ? [] : dir.split('/');
+    const base = dots === 0 ? [] : segments.slice(0, Math.max(0, segments.length - (dots - 1)));
+    names.push([...base, ...specifier.slice(dots).split('.').filter(Boolean)].join('/'));
+  }
+  return names;
+}
+
+/**
+ * Workspace-relative globs; a pattern without one (`pkg/legacy`) also
+ * matches everything under it, and `a/**` also matches `a`.
+ */
+function matchesPath(name: string, pattern: string): boolean {
+  const folder = pattern.replace(/\/(\*\*)?$/, '');
+  return minimatch(name, pattern, { dot: true }) || name === folder || (!/[*?[{]/.test(folder) && name.startsWith(folder + '/'));
+}
+
+function appliesTo(policy: PolicyConfig, relative: string): boolean {
+  const included = !policy.paths || policy.paths.length === 0 || policy.paths.some(pattern => matchesPath(relative, pattern));
+  return included && !(policy.exclude ?? []).some(pattern => matchesPath(relative, pattern));
+}
+
+/** Where the quoted specifier is first written in the file; 0:0 if nowhere */
+function specifierPosition(content: string, specifier: string): { line: number; character: number } {
+  let offset = -1;
+  for (const quote of ['"', '\'', '`']) {
+    const at = content.indexOf(quote + specifier + quote);
+    if (at >= 0 && (offset < 0 || at < offset)) {
+      offset = at;
+    }
+  }
+  if (offset < 0) {
+    // Python: `import pkg.legacy`, `from pkg.legacy import x`
+    const match = new RegExp(`^\\s*(?:from|import)\\s+${specifier.replace(/[.*+?^${}()|[\]\\]/g, '\\$&')}\\b`, 'm').exec(content);
+    offset = match ? match.index + match[0].length - specifier.length : -1;
+  }
+  if (offset < 0) {
+    return { line: 0, character: 0 };
+  }
+  const before = content.substring(0, offset);
+  const line = before.split('\n').length - 1;
+  return { line, character: offset - (before.lastIndexOf('\n') + 1) };
+}
+
+function symbolKey(symbol: IndexedSymbol): string {
+  return `${symbol.kind}\0${symbol.containerName ?? ''}\0${symbol.name}`;
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
+
+function overlaps(symbol: IndexedSymbol, range: ChangedLineRange): boolean {
+  return symbol.range.startLine <= range.endLine && symbol.range.endLine >= range.startLine;
+}
+
+/** How formatPolicyReport writes violations */
+export type PolicyReportFormat = 'text' | 'github';
+
+/**
+ * `text`: one line per violation, `file:line:col: severity: message
+ * [policy]` as compilers print them. `github`: GitHub Actions workflow
+ * commands, which annotate the lines of a pull request:
+ * `::error file=...,line=...,col=...,title=policy::message`. Either ends
+ * with a summary line.
+ */
+export function formatPolicyReport(report: PolicyReport, format: PolicyReportFormat = 'text'): string {
+  const relative = (file: string) => path.relative(report.root, file).split(path.sep).join('/');
+  const lines = report.violations.map(violation => {
+    if (format === 'github') {
+      const properties = [
+        `file=${escapeProperty(relative(violation.file))}`,
+        `line=${violation.line + 1}`,
+        `col=${violation.character + 1}`,
+        `title=${escapeProperty(violation.policy)}`
+      ];
+      return `::${violation.severity} ${properties.join(',')}::${escapeData(violation.message)}`;
+    }
+    const location = `${relative(violation.file)}:${violation.line + 1}:${violation.character + 1}`;
+    return `${location}: ${violation.severity}: ${violation.message} [${violation.policy}]`;
+  });
+  lines.push(summary(report));
+  return lines.join('\n') + '\n';
+}
+
+function summary(report: PolicyReport): string {
+  const scope = `${report.filesChecked} ${report.filesChecked === 1 ? 'file' : 'files'}` +
+    (report.since ? ` changed since ${report.since.slice(0, 8)}` : '') +
+    ` (${report.policies.length} ${report.policies.length === 1 ? 'policy' : 'policies'})`;
+  if (report.violations.length === 0) {
+    return `No policy violations in ${scope}`;
+  }
+  const count = (n: number, noun: string) => `${n} ${noun}${n === 1 ? '' : 's'}`;
+  return `${count(report.errors, 'error')}, ${count(report.warnings, 'warning')} in ${scope}`;
+}
+
+function escapeData(value: string): string {
+  return value.replace(/%/g, '%25').replace(/\r/g, '%0D').replace(/\n/g, '%0A');
+}
+
+function escapeProperty(value: string): string {
+  return escapeData(value).replace(/:/g, '%3A').replace(/,/g, '%2C');
+}
@@ -0,0 +1,118 @@
+/**
+ * Position Lookup Tests
+ *
+ * Resolves the symbol at file:line:character in Go-shaped index data:
+ * declarations, local variables, package-qualified calls, method calls
+ * and TS named imports.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { PositionLookup } from './positionLookup.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestReference, createTestSymbol } from '../test/mocks/MockIndex.js';
+import { IndexedReference, IndexedSymbol } from '../types.js';
+
+const USER = '/repo/pkg/user/user.go';
+const ADMIN = '/repo/pkg/admin/admin.go';
+const MAIN = '/repo/cmd/main.go';
+
+function symbol(uri: string, name: string, line: number, character: number, extra: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    id: `${uri}:${name}`,
+    name,
+    filePath: uri,
+    location: { uri, line, character },
+    range: { startLine: line, startCharacter: 0, endLine: line + 2, endCharacter: 1 },
+    ...extra
+  });
+}
+
+function reference(uri: string, name: string, line: number, character: number, extra: Partial<IndexedReference> = {}): IndexedReference {
+  return createTestReference({
+    symbolName: name,
+    location: { uri, line, character },
+    range: { startLine: line, startCharacter: character, endLine: line, endCharacter: character + name.length },
+    ...extra
+  });
+}
+
+describe('PositionLookup', () => {
+  let index: MockBackgroundIndex;
+  let lookup: PositionLookup;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    index.addFile(USER, [
+      symbol(USER, 'Person', 2, 5, { kind: 'struct' }),
+      symbol(USER, 'Greet', 6, 17, { kind: 'method', containerName: 'Person' }),
+      symbol(USER, 'New', 10, 5)
+    ]);
+    index.addFile(ADMIN, [
+      symbol(ADMIN, 'New', 2, 5),
+      symbol(ADMIN, 'Greet', 4, 5)
+    ]);
+    // p := user.New()
+    // p.Greet()
+    // fmt.Println(p)
+    index.addFile(MAIN, [symbol(MAIN, 'main', 4, 5)], [
+      reference(MAIN, 'p', 5, 1, { isLocal: true }),
+      reference(MAIN, 'user', 5, 6),
+      reference(MAIN, 'New', 5, 11, { isCall: true }),
+      reference(MAIN, 'p', 6, 1, { isLocal: true }),
+      reference(MAIN, 'Greet', 6, 3, { isCall: true }),
+      reference(MAIN, 'fmt', 7, 1),
+      reference(MAIN, 'Println', 7, 5, { isCall: true })
+    ], {
+      imports: [
+        { localName: 'fmt', moduleSpecifier: 'fmt', isNamespace: true },
+        { localName: 'user', moduleSpecifier: 'example.com/app/pkg/user', isNamespace: true }
+      ]
+    });
+    lookup = new PositionLookup(index.asBackgroundIndex());
+  });
+
+  it('should resolve a package-qualified call to that package', async () => {
+    const result = await lookup.lookup(MAIN, 5, 12);
+
+    expect(result).toMatchObject({ name: 'New', role: 'reference', range: { startLine: 5, startCharacter: 11, endCharacter: 14 } });
+    expect(result!.definitions.map(s => s.location.uri)).toEqual([USER]);
+  });
+
+  it('should resolve a method call to members', async () => {
+    const result = await lookup.lookup(MAIN, 6, 3);
+
+    expect(result!.definitions.map(s => s.id)).toEqual([`${USER}:Greet`]);
+  });
+
+  it('should return the declaration when the position is on its name', async () => {
+    const result = await lookup.lookup(USER, 6, 22);
+
+    expect(result).toMatchObject({ name: 'Greet', role: 'definition', range: { startLine: 6, startCharacter: 17, endCharacter: 22 } });
+    expect(result!.definitions.map(s => s.id)).toEqual([`${USER}:Greet`]);
+  });
+
+  it('should find no definition for locals and packages outside the index', async () => {
+    expect(await lookup.lookup(MAIN, 6, 1)).toMatchObject({ name: 'p', definitions: [] });
+    expect(await lookup.lookup(MAIN, 7, 6)).toMatchObject({ name: 'Println', definitions: [] });
+  });
+
+  it('should follow a renamed import to its module', async () => {
+    const app = '/repo/web/app.ts';
+    const person = '/repo/web/models/person.ts';
+    index.addFile(person, [symbol(person, 'Person', 0, 13, { kind: 'class' })]);
+    index.addFile('/repo/web/admin.ts', [symbol('/repo/web/admin.ts', 'Person', 0, 13, { kind: 'class' })]);
+    // import { Person as Admin } from './models/person.js'; new Admin()
+    index.addFile(app, [], [reference(app, 'Admin', 2, 4, { isCall: true })], {
+      imports: [{ localName: 'Admin', exportedName: 'Person', moduleSpecifier: './models/person.js' }]
+    });
+
+    const result = await lookup.lookup(app, 2, 6);
+
+    expect(result!.definitions.map(s => s.id)).toEqual([`${person}:Person`]);
+  });
+
+  it('should return null off any symbol and for unindexed files', async () => {
+    expect(await lookup.lookup(MAIN, 0, 0)).toBeNull();
+    expect(await lookup.lookup('/repo/missing.go', 0, 0)).toBeNull();
+  });
+});
@@ -0,0 +1,151 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { ImportInfo, IndexedFileResult, IndexedReference, IndexedSymbol, SymbolRange } from '../types.js';
+import { rankDefinitionCandidates } from '../utils/disambiguation.js';
+
+/** The part of an index the lookup reads: the background index or the library Indexer */
+export type PositionLookupIndex = Pick<BackgroundIndex, 'getFileResult' | 'findDefinitions'>;
+
+export interface PositionLookupResult {
+  /** Name of the symbol at the position */
+  name: string;
+  /** Whether the position is on the symbol's declaration or on a use of it */
+  role: 'definition' | 'reference';
+  /** Span of the name at the position */
+  range: SymbolRange;
+  /** Where the symbol is defined, best match first; empty when it is not indexed (stdlib, dependencies) */
+  definitions: IndexedSymbol[];
+}
+
+/** Kinds that only bind a name to a symbol declared elsewhere */
+const IMPORT_KINDS = new Set(['import', 'alias']);
+
+/**
+ * Position Lookup - the symbol defined or referenced at file:line:column,
+ * from the stored index alone, so editors get go-to-definition without
+ * a language server (gopls, tsserver) running.
+ *
+ * A use is matched to its definition by name, then narrowed with what the
+ * file recorded about it: locals stay in the file, `pkg.Name` goes to the
+ * imported package's directory, `value.Name` to members, and imported
+ * names to the module they come from. Remaining candidates are ranked by
+ * distance from the file (utils/disambiguation.ts).
+ */
+export class PositionLookup {
+  constructor(private index: PositionLookupIndex) {}
+
+  /**
+   * Lines and characters are 0-based. Returns null when the file is not
+   * indexed or nothing is declared or referenced at the position.
+   */
+  async lookup(uri: string, line: number, character: number): Promise<PositionLookupResult | null> {
+    const file = await this.index.getFileResult(uri);
+    if (!file) {
+      return null;
+    }
+
+    const reference = file.references.find(ref => contains(ref.range, line, character));
+    if (reference) {
+      return {
+        name: reference.symbolName,
+        role: 'reference',
+        range: reference.range,
+        definitions: await this.resolveReference(file, reference)
+      };
+    }
+
+    const symbol = file.symbols.find(sym => contains(nameRange(sym), line, character));
+    if (symbol) {
+      return { name: symbol.name, role: 'definition', range: nameRange(symbol), definitions: [symbol] };
+    }
+    return null;
+  }
+
+  /**
+   * Definitions a reference of file resolves to, best match first.
+   */
+  async resolveReference(file: IndexedFileResult, reference: IndexedReference): Promise<IndexedSymbol[]> {
+    const name = reference.symbolName;
+    if (reference.isLocal) {
+      return file.symbols.filter(sym => sym.name === name && !IMPORT_KINDS.has(sym.kind));
+    }
+
+    const qualifier = qualifierOf(file, reference);
+    const namespace = qualifier && file.imports.find(imp => imp.localName === qualifier.symbolName && imp.isNamespace);
+    if (namespace) {
+      // pkg.Name / ns.Name: only what that module declares at its top level
+      const candidates = await this.definitions(name);
+      return rankDefinitionCandidates(
+        candidates.filter(sym => !sym.containerName && isFromModule(sym, namespace, file.uri)),
+        file.uri
+      );
+    }
+
+    const imported = !qualifier && file.imports.find(imp => imp.localName === name && !imp.isNamespace);
+    if (imported) {
+      const candidates = await this.definitions(imported.exportedName ?? name);
+      const fromModule = candidates.filter(sym => isFromModule(sym, imported, file.uri));
+      return rankDefinitionCandidates(fromModule.length > 0 ? fromModule : candidates, file.uri);
+    }
+
+    const candidates = await this.definitions(name);
+    const preferred = candidates.filter(qualifier
+      // value.Name: a field or method; its receiver type is not recorded
+      ? sym => !!sym.containerName
+      : sym => !sym.containerName || sym.location.uri === file.uri);
+    return rankDefinitionCandidates(preferred.length > 0 ? preferred : candidates, file.uri);
+  }
+
+  private async definitions(name: string): Promise<IndexedSymbol[]> {
+    return (await this.index.findDefinitions(name))
+      .filter(sym => sym.isDefinition !== false && !IMPORT_KINDS.has(sym.kind));
+  }
+}
+
+/**
+ * The reference right before `.name` on the same line: `user` in
+ * `user.New()`, `p` in `p.Greet()`.
+ */
+function qualifierOf(file: IndexedFileResult, reference: IndexedReference): IndexedReference | undefined {
+  const { startLine, startCharacter } = reference.range;
+  return file.references.find(ref =>
+    ref.range.endLine === startLine && ref.range.endCharacter === startCharacter - 1
+  );
+}
+
+/**
+ * Whether a symbol is declared in the module an import names: the file a
+ * relative specifier resolves to, or the directory of a Go import path.
+ */
+function isFromModule(symbol: IndexedSymbol, imp: ImportInfo, fromUri: string): boolean {
+  const uri = symbol.location.uri;
+  const specifier = imp.moduleSpecifier;
+  if (specifier.startsWith('.')) {
+    const target = stripExtension(path.resolve(path.dirname(fromUri), specifier));
+    const file = stripExtension(uri);
+    return file === target || file === path.join(target, 'index');
+  }
+  const dir = path.dirname(uri).split(path.sep).join('/');
+  return dir.endsWith('/' + specifier) || path.posix.basename(dir) === path.posix.basename(specifier);
+}
+
+function stripExtension(file: string): string {
+  return file.replace(/\.[cm]?[jt]sx?$/, '');
+}
+
+/** The span of a symbol's name at its declaration */
+function nameRange(symbol: IndexedSymbol): SymbolRange {
+  const { line, character } = symbol.location;
+  return { startLine: line, startCharacter: character, endLine: line, endCharacter: character + symbol.name.length };
+}
+
+/** Whether the position is inside the range, its end included (a cursor right after a name is on it) */
+function contains(range: SymbolRange, line: number, character: number): boolean {
+  if (line < range.startLine || line > range.endLine) {
+    return false;
+  }
+  if (line === range.startLine && character < range.startCharacter) {
+    return false;
+  }
+  return line !== range.endLine || character <= range.endCharacter;
+}
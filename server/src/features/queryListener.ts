@@ -0,0 +1,305 @@
+import * as fs from 'fs';
+import * as http from 'http';
+import * as https from 'https';
+import * as tls from 'tls';
+import { AddressInfo, isIP, Socket } from 'net';
+import { ILogger } from '../utils/Logger.js';
+import type { QueryResponse } from './queryServer.js';
+import { bearerToken, QueryPrincipal, QueryServerAuth } from './queryServerAuth.js';
+
+/** PEM certificate chain and key; with `ca`, clients need a certificate it signed */
+export interface QueryServerTls {
+  cert: string | Buffer;
+  key: string | Buffer;
+  ca?: string | Buffer;
+}
+
+export interface QueryListenOptions {
+  /** Serve HTTPS; with `tls.ca`, mutual TLS */
+  tls?: QueryServerTls;
+  /** Tokens and certificate subjects let in; without it, anyone who can connect is */
+  auth?: QueryServerAuth;
+}
+
+/** What a listener serves: QueryServer for one index, QueryServerHost for many */
+export interface QueryHandler {
+  /** principal is set when the listener authenticates requests */
+  handle(method: string, rawUrl: string, body: string, principal?: QueryPrincipal): Promise<QueryResponse>;
+  /** Paths served without credentials, e.g. health checks and the web UI page */
+  isPublic?(pathname: string): boolean;
+}
+
+const TEXT_CONTENT_TYPE = 'text/plain; charset=utf-8';
+const MAX_BODY_BYTES = 16 * 1024 * 1024;
+
+/**
+ * Query Listener - the HTTP(S) side of the query servers: reads request
+ * bodies, checks credentials, and writes JSON, text and NDJSON streams
+ * with socket backpressure.
+ *
+ * With `auth`, every request except the handler's public paths needs a
+ * bearer token or client certificate subject an access rule lets in,
+ * else it gets a 401. With `tls.ca` and no `auth`, any client
+ * certificate the CA signed is let in; TLS rejects the others during the
+ * handshake. With neither, a port only answers requests for `localhost`
+ * or an IP address: a web page that rebinds its own host name to the
+ * loopback address gets a 403 instead of the index.
+ */
+export class QueryListener {
+  private server: http.Server | https.Server | null = null;
+  private secure = false;
+  /** Set while listening on a unix socket or named pipe */
+  private socket: string | null = null;
+
+  constructor(private handler: QueryHandler, private logger: ILogger, private label: string) {}
+
+  /**
+   * Start listening. Port 0 picks a free port; the bound address is returned.
+   */
+  async start(port: number, host: string = '127.0.0.1', options: QueryListenOptions = {}): Promise<AddressInfo> {
+    const server = await this.listen(options, (server, listening) => server.listen(port, host, listening));
+    const address = server.address() as AddressInfo;
+    this.logger.info(`[${this.label}] Listening on ${this.secure ? 'https' : 'http'}://${address.address}:${address.port}` +
+      `${options.auth ? ' (authenticated)' : ''}${options.tls?.ca ? ' (client certificates required)' : ''}`);
+    return address;
+  }
+
+  /**
+   * Start listening on a unix socket (a named pipe on Windows) instead of
+   * a port. Only the user running the server can connect: the socket is
+   * made 0600, and named pipes let only their creator (and
+   * administrators) write to them. The socket file must not exist.
+   */
+  async startOnSocket(socketPath: string, options: QueryListenOptions = {}): Promise<void> {
+    await this.listen(options, (server, listening) =>
+      server.listen({ path: socketPath, readableAll: false, writableAll: false }, listening));
+    if (process.platform !== 'win32') {
+      try {
+        await fs.promises.chmod(socketPath, 0o600);
+      } catch (error) {
+        await this.stop();
+        throw error;
+      }
+    }
+    this.socket = socketPath;
+    this.logger.info(`[${this.label}] Listening on ${socketPath}${options.auth ? ' (authenticated)' : ''}`);
+  }
+
+  private async listen(
+    options: QueryListenOptions,
+    bind: (server: http.Server | https.Server, listening: () => void) => void
+  ): Promise<http.Server | https.Server> {
+    if (this.server) {
+      await this.stop();
+    }
+
+    const listener: http.RequestListener = (req, res) => {
+      void this.respond(req, res, options);
+    };
+    const server = options.tls
+      ? https.createServer({
+        cert: options.tls.cert,
+        key: options.tls.key,
+        ca: options.tls.ca,
+        requestCert: options.tls.ca !== undefined,
+        rejectUnauthorized: options.tls.ca !== undefined
+      }, listener)
+      : http.createServer(listener);
+
+    await new Promise<void>((resolve, reject) => {
+      server.once('error', reject);
+      bind(server, () => {
+        server.off('error', reject);
+        resolve();
+      });
+    });
+
+    this.server = server;
+    this.secure = options.tls !== undefined;
+    return server;
+  }
+
+  async stop(): Promise<void> {
+    const server = this.server;
+    if (!server) {
+      return;
+    }
+    this.server = null;
+    this.socket = null;
+    await new Promise<void>(resolve => server.close(() => resolve()));
+    this.logger.info(`[${this.label}] Stopped`);
+  }
+
+  isRunning(): boolean {
+    return this.server !== null;
+  }
+
+  /** The bound port and address; null when stopped or on a socket */
+  address(): AddressInfo | null {
+    return this.server && this.socket === null ? this.server.address() as AddressInfo : null;
+  }
+
+  /** The unix socket or named pipe listened on, if any */
+  socketPath(): string | null {
+    return this.socket;
+  }
+
+  /** Whether the server speaks HTTPS */
+  isSecure(): boolean {
+    return this.server !== null && this.secure;
+  }
+
+  private async respond(req: http.IncomingMessage, res: http.ServerResponse, options: QueryListenOptions): Promise<void> {
+    const method = req.method || 'GET';
+    const rawUrl = req.url || '/';
+    try {
+      const body = await readBody(req);
+      let response: QueryResponse;
+      if (typeof body !== 'string') {
+        response = body;
+      } else {
+        const principal = authenticate(req.headers.authorization, req.socket, options);
+        const pathname = new URL(rawUrl, 'http://localhost').pathname;
+        if (principal === undefined && this.socket === null && !isLocalHost(req.headers.host)) {
+          this.logger.warn(`[${this.label}] Rejected ${method} ${pathname} for host ${req.headers.host}: not localhost`);
+          response = { status: 403, body: { error: 'Requests without credentials must be for localhost' } };
+        } else if (principal === null && !this.handler.isPublic?.(pathname)) {
+          this.logger.warn(`[${this.label}] Rejected ${method} ${pathname} from ${req.socket.remoteAddress}: no valid credentials`);
+          response = { status: 401, body: { error: 'Missing or invalid access token' }, headers: { 'WWW-Authenticate': 'Bearer' } };
+        } else {
+          response = await this.handler.handle(method, rawUrl, body, principal ?? undefined);
+        }
+      }
+      await this.write(res, response);
+    } catch (error) {
+      this.logger.error(`[${this.label}] Error handling ${method} ${rawUrl}: ${error}`);
+      if (res.headersSent) {
+        res.destroy();
+        return;
+      }
+      res.writeHead(500, { 'Content-Type': 'application/json; charset=utf-8' });
+      res.end(JSON.stringify({ error: error instanceof Error ? error.message : String(error) }));
+    }
+  }
+
+  private async write(res: http.ServerResponse, response: QueryResponse): Promise<void> {
+    if (response.stream) {
+      return this.writeStream(res, response);
+    }
+    if (response.text !== undefined) {
+      res.writeHead(response.status, { ...response.headers, 'Content-Type': response.contentType ?? TEXT_CONTENT_TYPE });
+      res.end(response.text);
+      return;
+    }
+    if (response.binary !== undefined) {
+      res.writeHead(response.status, { ...response.headers, 'Content-Type': response.contentType ?? 'application/octet-stream' });
+      res.end(response.binary);
+      return;
+    }
+    res.writeHead(response.status, { ...response.headers, 'Content-Type': 'application/json; charset=utf-8' });
+    res.end(JSON.stringify(response.body));
+  }
+
+  /**
+   * Write items as NDJSON (strings as they are), pausing while the socket
+   * buffer is full and stopping early if the client goes away. Trailers
+   * follow the last item.
+   */
+  private async writeStream(res: http.ServerResponse, response: QueryResponse): Promise<void> {
+    const trailers = Object.keys(response.trailers ?? {});
+    res.writeHead(200, {
+      ...response.headers,
+      'Content-Type': response.contentType ?? 'application/x-ndjson; charset=utf-8',
+      ...(trailers.length > 0 && { Trailer: trailers.join(', ') })
+    });
+    let closed = false;
+    res.once('close', () => {
+      closed = true;
+    });
+
+    try {
+      for await (const item of response.stream!) {
+        if (closed) {
+          return;
+        }
+        if (!res.write(typeof item === 'string' ? item : JSON.stringify(item) + '\n')) {
+          await new Promise<void>(resolve => {
+            res.once('drain', resolve);
+            res.once('close', resolve);
+          });
+        }
+      }
+    } catch (error) {
+      this.logger.error(`[${this.label}] Error while streaming: ${error}`);
+      res.write(JSON.stringify({ error: error instanceof Error ? error.message : String(error) }) + '\n');
+    }
+    if (trailers.length > 0) {
+      res.addTrailers(Object.fromEntries(Object.entries(response.trailers!)
+        .map(([name, value]) => [name, value()])
+        .filter((entry): entry is [string, string] => entry[1] !== undefined)));
+    }
+    res.end();
+  }
+}
+
+/**
+ * The principal of a request, from its Authorization header and the
+ * connection it came on; null when credentials are required and missing
+ * or wrong, undefined when the server asks for none. Shared with the
+ * gRPC listener (see features/grpcListener.ts).
+ */
+export function authenticate(
+  authorization: string | undefined,
+  socket: Socket,
+  options: QueryListenOptions
+): QueryPrincipal | null | undefined {
+  const subject = clientSubject(socket);
+  if (!options.auth) {
+    if (options.tls?.ca === undefined) {
+      return undefined;
+    }
+    return subject ? { name: subject, indexes: ['*'] } : null;
+  }
+  return options.auth.authenticate({ token: bearerToken(authorization), subject });
+}
+
+/** Common name of the client certificate, when TLS verified one */
+function clientSubject(connection: Socket): string | undefined {
+  const socket = connection as tls.TLSSocket;
+  if (!socket.authorized || typeof socket.getPeerCertificate !== 'function') {
+    return undefined;
+  }
+  const commonName = socket.getPeerCertificate().subject?.CN;
+  return Array.isArray(commonName) ? commonName[0] : commonName || undefined;
+}
+
+/**
+ * Whether a Host header names this machine without a DNS name: a page's
+ * rebound name could point anywhere, an IP address or localhost cannot.
+ * Requests without one (HTTP/1.0) do not come from browsers.
+ */
+function isLocalHost(host: string | undefined): boolean {
+  if (host === undefined) {
+    return true;
+  }
+  const name = host.startsWith('[') ? host.substring(1, host.indexOf(']')) : host.replace(/:\d*$/, '');
+  return name.toLowerCase() === 'localhost' || isIP(name) !== 0;
+}
+
+/** The body of a request, or the error reply when it is too large or broke off */
+function readBody(req: http.IncomingMessage): Promise<string | QueryResponse> {
+  return new Promise(resolve => {
+    const chunks: Buffer[] = [];
+    let size = 0;
+    req.on('data', (chunk: Buffer) => {
+      size += chunk.length;
+      if (size <= MAX_BODY_BYTES) {
+        chunks.push(chunk);
+      }
+    });
+    req.on('end', () => resolve(size > MAX_BODY_BYTES
+      ? { status: 413, body: { error: `Request body exceeds ${MAX_BODY_BYTES} bytes` } }
+      : Buffer.concat(chunks).toString('utf-8')));
+    req.on('error', error => resolve({ status: 400, body: { error: `Could not read the request body: ${error.message}` } }));
+  });
+}
@@ -0,0 +1,1067 @@
+/**
+ * QueryServer Tests
+ *
+ * Verifies REST routing, parameter validation and the HTTP layer.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as http from 'http';
+import { QueryServer } from './queryServer.js';
+import { QueryListener } from './queryListener.js';
+import { QueryServerAuth } from './queryServerAuth.js';
+import { StructuredQuery } from './structuredQuery.js';
+import { SearchScopes } from './searchScopes.js';
+import { CodeMetrics } from './codeMetrics.js';
+import { Ownership } from './ownership.js';
+import { PositionLookup } from './positionLookup.js';
+import { CommentMarkerIndex } from './commentMarkers.js';
+import { HttpRouteIndex } from './httpRoutes.js';
+import { SqlQueryIndex } from './sqlQueries.js';
+import { ConfigKeyIndex } from './configKeys.js';
+import { ConcurrencyIndex } from './concurrency.js';
+import { ErrorPathIndex } from './errorPaths.js';
+import { CoverageIndex } from './coverage.js';
+import { CallGraph } from './callGraph.js';
+import { DocsIndex } from './docsIndex.js';
+import { GoTestIndex } from './goTests.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { parseCodeOwners, resolveOwners } from '../utils/codeOwners.js';
+import { MockIndex, createTestSymbol, createTestReference } from '../test/mocks/MockIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { ProgressTracker } from '../utils/indexingProgress.js';
+import { IndexerMetrics } from '../profiler/indexerMetrics.js';
+import { SamplingProfiler } from '../profiler/sampling.js';
+import { rankSymbols } from '../utils/fuzzySearch.js';
+import { NullLogger } from '../utils/Logger.js';
+
+const uri = '/ws/src/user.ts';
+
+async function collect(stream: Iterable<unknown> | AsyncIterable<unknown> | undefined): Promise<any[]> {
+  const items: any[] = [];
+  for await (const item of stream!) {
+    items.push(item);
+  }
+  return items;
+}
+const source = `export class UserService {
+  load() {}
+}
+const service = new UserService();
+`;
+
+describe('QueryServer', () => {
+  let index: MockIndex;
+  let server: QueryServer;
+
+  beforeEach(() => {
+    index = new MockIndex();
+    index.addSymbol(createTestSymbol({
+      id: 'svc', name: 'UserService', kind: 'class', filePath: uri,
+      location: { uri, line: 0, character: 13 },
+      doc: 'Serves users, see {@link UserService#load}.',
+      range: { startLine: 0, startCharacter: 0, endLine: 2, endCharacter: 1 }
+    }));
+    index.addSymbol(createTestSymbol({
+      id: 'load', name: 'load', kind: 'method', containerName: 'UserService', filePath: uri,
+      location: { uri, line: 1, character: 2 },
+      range: { startLine: 1, startCharacter: 2, endLine: 1, endCharacter: 11 }
+    }));
+    index.addReference('UserService', createTestReference({
+      symbolName: 'UserService',
+      location: { uri, line: 3, character: 20 },
+      isCall: true
+    }));
+    server = new QueryServer(index, undefined, async path => {
+      if (path !== uri) {
+        throw new Error('ENOENT');
+      }
+      return source;
+    });
+  });
+
+  afterEach(async () => {
+    await server.stop();
+  });
+
+  it('should search symbols', async () => {
+    const response = await server.handle('GET', '/symbols?q=User&limit=5');
+    expect(response.status).toBe(200);
+    expect((response.body as any).symbols.map((s: any) => s.name)).toContain('UserService');
+  });
+
+  it('should return ranking scores from an index that ranks', async () => {
+    const ranking = Object.assign(Object.create(index) as MockIndex, {
+      searchSymbolsRanked: async (query: string, limit: number) => rankSymbols(await index.searchSymbols(query, limit), query, {
+        popularity: { referenceCounts: await index.getReferenceCounts(['UserService']) }
+      })
+    });
+    const ranked = new QueryServer(ranking);
+
+    const body = (await ranked.handle('GET', '/symbols?q=UserService')).body as any;
+    expect(body.symbols[0]).toMatchObject({ name: 'UserService', popularity: { references: 1, score: 3 } });
+    expect(body.symbols[0].score).toBeGreaterThan(100);
+    expect(((await server.handle('GET', '/symbols?q=UserService')).body as any).symbols[0].score).toBeUndefined();
+  });
+
+  it('should filter symbol search by first- or third-party scope', async () => {
+    const depUri = '/home/dev/go/pkg/mod/github.com/acme/users@v1.4.0/user.go';
+    index.addSymbol(createTestSymbol({
+      id: 'dep', name: 'UserStore', kind: 'struct', filePath: depUri,
+      location: { uri: depUri, line: 4, character: 5 },
+      metadata: { go: { module: 'github.com/acme/users', moduleVersion: 'v1.4.0', thirdParty: true } }
+    }));
+
+    const thirdParty = (await server.handle('GET', '/symbols?q=User&scope=third-party')).body as any;
+    expect(thirdParty.symbols.map((s: any) => [s.name, s.module, s.moduleVersion, s.thirdParty]))
+      .toEqual([['UserStore', 'github.com/acme/users', 'v1.4.0', true]]);
+
+    const firstParty = (await server.handle('GET', '/symbols?q=User&scope=first-party')).body as any;
+    expect(firstParty.symbols.map((s: any) => s.name)).not.toContain('UserStore');
+    expect(firstParty.symbols.map((s: any) => s.name)).toContain('UserService');
+
+    expect((await server.handle('GET', '/symbols?q=User&scope=vendor')).status).toBe(400);
+  });
+
+  it('should find definitions by name and by position', async () => {
+    const byName = await server.handle('GET', '/definition?name=UserService');
+    expect((byName.body as any).definitions).toHaveLength(1);
+    expect((byName.body as any).definitions[0].doc).toBe('Serves users, see {@link UserService#load}.');
+    expect((byName.body as any).definitions[0].documentation)
+      .toBe('Serves users, see [UserService#load](file:///ws/src/user.ts#L2).');
+
+    // line 3, character 24 is inside `UserService` in `new UserService()`
+    const byPosition = await server.handle('GET', `/definition?uri=${encodeURIComponent(uri)}&line=3&character=24`);
+    expect(byPosition.status).toBe(200);
+    expect((byPosition.body as any).name).toBe('UserService');
+    expect((byPosition.body as any).definitions[0].location).toEqual({ uri, line: 0, character: 13 });
+  });
+
+  it('should resolve positions in indexed files from the index', async () => {
+    const background = new MockBackgroundIndex();
+    const load = createTestSymbol({
+      id: 'load', name: 'load', kind: 'method', containerName: 'UserService', filePath: uri,
+      location: { uri, line: 1, character: 2 }
+    });
+    background.addFile(uri, [load], [createTestReference({
+      symbolName: 'load',
+      location: { uri, line: 4, character: 8 },
+      range: { startLine: 4, startCharacter: 8, endLine: 4, endCharacter: 12 },
+      isCall: true
+    })]);
+    const withLookup = new QueryServer(
+      index, undefined, async () => source, undefined, undefined, undefined, undefined, undefined, undefined,
+      new PositionLookup(background.asBackgroundIndex())
+    );
+
+    // `service.load()` at line 4 of the indexed version of the file
+    const response = (await withLookup.handle('GET', `/definition?uri=${encodeURIComponent(uri)}&line=4&character=10`)).body as any;
+    expect(response).toMatchObject({ name: 'load', role: 'reference', range: { startLine: 4, startCharacter: 8 } });
+    expect(response.definitions.map((d: any) => d.location)).toEqual([{ uri, line: 1, character: 2 }]);
+
+    // Words the index has nothing recorded at are looked up by name
+    const fallback = (await withLookup.handle('GET', `/definition?uri=${encodeURIComponent(uri)}&line=3&character=24`)).body as any;
+    expect(fallback.name).toBe('UserService');
+    expect(fallback.role).toBeUndefined();
+  });
+
+  it('should find definitions by canonical ID', async () => {
+    const otherUri = '/ws/src/admin.ts';
+    for (const [id, file, canonicalId] of [['a', uri, 'app/src/user:Store'], ['b', otherUri, 'app/src/admin:Store']]) {
+      index.addSymbol(createTestSymbol({
+        id, name: 'Store', kind: 'class', filePath: file, canonicalId,
+        location: { uri: file, line: 5, character: 6 }
+      }));
+    }
+
+    const response = await server.handle('GET', `/definition?id=${encodeURIComponent('app/src/admin:Store')}`);
+    expect((response.body as any).name).toBe('Store');
+    expect((response.body as any).definitions.map((d: any) => [d.canonicalId, d.location.uri]))
+      .toEqual([['app/src/admin:Store', otherUri]]);
+  });
+
+  it('should list references and file outlines', async () => {
+    const references = await server.handle('GET', '/references?name=UserService');
+    const [reference] = (references.body as any).references;
+    expect(reference.isCall).toBe(true);
+    expect(reference.location).toEqual({ uri, line: 3, character: 20 });
+
+    const outline = await server.handle('GET', `/outline?uri=${encodeURIComponent('file://' + uri)}`);
+    expect((outline.body as any).symbols.map((s: any) => s.name)).toEqual(['UserService', 'load']);
+
+    const tree = await server.handle('GET', `/outline?uri=${encodeURIComponent(uri)}&tree=true`);
+    const [service] = (tree.body as any).outline;
+    expect(service.name).toBe('UserService');
+    expect(service.children.map((s: any) => [s.name, s.kind])).toEqual([['load', 'method']]);
+    expect(service.children[0].selectionRange).toEqual({ start: { line: 1, character: 2 }, end: { line: 1, character: 6 } });
+  });
+
+  it('should check renames', async () => {
+    const response = await server.handle('GET', '/rename-check?name=UserService&newName=AccountService');
+    expect(response.status).toBe(200);
+    const impact = response.body as any;
+    expect(impact.locations.map((l: any) => [l.line, l.definition])).toEqual([[0, true], [3, false]]);
+    expect(impact.safe).toBe(true);
+
+    expect((await server.handle('GET', '/rename-check?name=UserService')).status).toBe(400);
+  });
+
+  it('should filter symbols, definitions and references by code tag', async () => {
+    const testUri = '/ws/src/user_test.go';
+    index.addSymbol(createTestSymbol({
+      id: 'fake', name: 'UserService', kind: 'struct', filePath: testUri, tags: ['test', 'mock'],
+      location: { uri: testUri, line: 2, character: 5 }
+    }));
+    index.addReference('UserService', createTestReference({
+      symbolName: 'UserService',
+      location: { uri: testUri, line: 8, character: 1 }
+    }));
+
+    const all = (await server.handle('GET', '/definition?name=UserService')).body as any;
+    expect(all.definitions.map((s: any) => s.tags)).toEqual([undefined, ['test', 'mock']]);
+
+    const noTests = (await server.handle('GET', '/symbols?q=UserService&exclude=tests,generated')).body as any;
+    expect(noTests.symbols.map((s: any) => s.location.uri)).toEqual([uri]);
+
+    const onlyMocks = (await server.handle('GET', '/definition?name=UserService&only=mock')).body as any;
+    expect(onlyMocks.definitions.map((s: any) => s.location.uri)).toEqual([testUri]);
+
+    // References are classified by their file
+    const refs = (await server.handle('GET', '/references?name=UserService&exclude=test')).body as any;
+    expect(refs.references.map((r: any) => r.location.uri)).toEqual([uri]);
+
+    const bad = await server.handle('GET', '/symbols?q=User&exclude=fixtures');
+    expect(bad.status).toBe(400);
+    expect((bad.body as any).error).toContain('Unknown tag "fixtures"');
+  });
+
+  it('should filter symbols by kind and return constant values', async () => {
+    index.addSymbol(createTestSymbol({
+      id: 'retries', name: 'MaxRetries', kind: 'constant', filePath: '/ws/src/retry.go', value: '5',
+      location: { uri: '/ws/src/retry.go', line: 2, character: 6 }
+    }));
+    index.addSymbol(createTestSymbol({
+      id: 'retry', name: 'retryWithBackoff', kind: 'function', filePath: '/ws/src/retry.go',
+      location: { uri: '/ws/src/retry.go', line: 4, character: 5 }
+    }));
+
+    const constants = (await server.handle('GET', '/symbols?q=retr&kind=const')).body as any;
+    expect(constants.symbols.map((s: any) => [s.name, s.value])).toEqual([['MaxRetries', '5']]);
+
+    const both = (await server.handle('GET', '/symbols?q=retr&kind=constant,func')).body as any;
+    expect(both.symbols.map((s: any) => s.name).sort()).toEqual(['MaxRetries', 'retryWithBackoff']);
+  });
+
+  it('should filter symbols by signature and type parameter constraint', async () => {
+    index.addSymbol(createTestSymbol({
+      id: 'find', name: 'findUser', kind: 'function', filePath: uri,
+      location: { uri, line: 5, character: 9 },
+      signature: '(id: string): User',
+      typeParameters: [{ name: 'K', constraint: 'object' }]
+    }));
+
+    const bySignature = (await server.handle('GET', `/symbols?q=User&signature=${encodeURIComponent('(string) => User')}`)).body as any;
+    expect(bySignature.symbols.map((s: any) => s.name)).toEqual(['findUser']);
+    expect(bySignature.symbols[0].signature).toBe('(id: string): User');
+    expect(bySignature.symbols[0].typeParameters).toEqual([{ name: 'K', constraint: 'object' }]);
+
+    const byConstraint = (await server.handle('GET', '/symbols?q=User&constraint=object')).body as any;
+    expect(byConstraint.symbols.map((s: any) => s.name)).toEqual(['findUser']);
+
+    // Without a full scan a name query is still required
+    expect((await server.handle('GET', '/symbols?generic=true')).status).toBe(400);
+    const bad = await server.handle('GET', '/symbols?q=User&signature=User');
+    expect(bad.status).toBe(400);
+    expect((bad.body as any).error).toContain('parameter list');
+  });
+
+  it('should run structured queries', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile(uri, await index.getFileSymbols(uri), []);
+    const withQueries = new QueryServer(index, undefined, undefined, undefined, new StructuredQuery(background.asBackgroundIndex()));
+
+    const methods = (await withQueries.handle('GET', `/query?q=${encodeURIComponent('kind:method container:UserService')}`)).body as any;
+    expect(methods.symbols.map((s: any) => s.name)).toEqual(['load']);
+    expect(methods.plan).toBe('files next to "UserService"');
+
+    const bad = await withQueries.handle('GET', '/query?q=colour:red');
+    expect(bad.status).toBe(400);
+    expect((bad.body as any).error).toContain('Unknown field "colour" (expected name, kind');
+    expect((await server.handle('GET', '/query?q=kind:method')).status).toBe(400);
+  });
+
+  it('should search and query in named scopes and run saved queries', async () => {
+    const apiUri = '/ws/services/users/api.go';
+    const generatedUri = '/ws/services/users/api.pb.go';
+    index.addSymbol(createTestSymbol({
+      id: 'api', name: 'UserAPI', kind: 'struct', filePath: apiUri, isExported: true,
+      location: { uri: apiUri, line: 2, character: 5 }
+    }));
+    index.addSymbol(createTestSymbol({
+      id: 'pb', name: 'UserRequest', kind: 'struct', filePath: generatedUri, isExported: true, tags: ['generated'],
+      location: { uri: generatedUri, line: 8, character: 5 }
+    }));
+    const background = new MockBackgroundIndex();
+    for (const file of [uri, apiUri, generatedUri]) {
+      background.addFile(file, await index.getFileSymbols(file), []);
+    }
+    const scoped = new QueryServer(index, undefined, undefined, undefined, new StructuredQuery(background.asBackgroundIndex()));
+    scoped.setScopes(new SearchScopes({
+      scopes: { backend: 'file:services/** -tag:generated', generated: 'tag:generated', broken: 'colour:red' },
+      queries: {
+        structs: { query: 'kind:struct', scope: 'backend', description: 'Backend structs' },
+        'all-structs': 'kind:struct'
+      }
+    }));
+
+    const names = (response: { body?: unknown }) => (response.body as any).symbols.map((s: any) => s.name).sort();
+    expect(names(await scoped.handle('GET', '/symbols?q=User&scope=backend'))).toEqual(['UserAPI']);
+    expect(names(await scoped.handle('GET', '/symbols?q=User&scope=first-party'))).toEqual(['UserAPI', 'UserRequest', 'UserService']);
+    expect(names(await scoped.handle('GET', `/query?q=${encodeURIComponent('exported:true')}&scope=backend`))).toEqual(['UserAPI']);
+    expect(names(await scoped.handle('GET', '/query?saved=structs'))).toEqual(['UserAPI']);
+    expect((await scoped.handle('GET', '/query?saved=structs')).body).toMatchObject({ query: 'kind:struct' });
+    expect(names(await scoped.handle('GET', '/query?saved=all-structs'))).toEqual(['UserAPI', 'UserRequest']);
+    // scope= replaces the saved query's scope
+    expect(names(await scoped.handle('GET', '/query?saved=structs&scope=generated'))).toEqual(['UserRequest']);
+
+    expect((await scoped.handle('GET', '/scopes')).body).toEqual({
+      scopes: [
+        { name: 'backend', filter: 'file:services/** -tag:generated' },
+        { name: 'broken', filter: 'colour:red' },
+        { name: 'generated', filter: 'tag:generated' }
+      ],
+      queries: [
+        { name: 'all-structs', query: 'kind:struct' },
+        { name: 'structs', query: 'kind:struct', scope: 'backend', description: 'Backend structs' }
+      ]
+    });
+
+    const unknown = await scoped.handle('GET', '/symbols?q=User&scope=frontend');
+    expect(unknown.status).toBe(400);
+    expect((unknown.body as any).error).toBe('Unknown scope "frontend" (defined: backend, broken, generated)');
+    expect((await scoped.handle('GET', '/query?q=kind:struct&scope=broken')).body).toEqual({
+      error: 'Scope "broken": Unknown field "colour" (expected name, kind, receiver, container, exported, file, lang, tag, ' +
+        'signature, constraint, generic, value, doc, owner, deprecated, coverage, refs, text, key, target, deps) at position 0'
+    });
+    expect((await scoped.handle('GET', '/query?saved=missing')).status).toBe(400);
+    // Named scopes filter through structured queries
+    expect((await server.handle('GET', '/symbols?q=User&scope=backend')).status).toBe(400);
+  });
+
+  it('should rank functions by metrics', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile(uri, [
+      createTestSymbol({ id: 'a', name: 'load', kind: 'method', filePath: uri, parametersCount: 1, metrics: { complexity: 2, loc: 4, nesting: 1 } }),
+      createTestSymbol({ id: 'b', name: 'save', kind: 'method', filePath: uri, metrics: { complexity: 9, loc: 30, nesting: 3 } })
+    ]);
+    const withMetrics = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, new CodeMetrics(background.asBackgroundIndex())
+    );
+
+    const ranked = (await withMetrics.handle('GET', '/function-metrics?sort=loc&minComplexity=2')).body as any;
+    expect(ranked.functions.map((f: any) => [f.name, f.metrics.loc, f.parameters])).toEqual([['save', 30, 0], ['load', 4, 1]]);
+    expect(ranked.matched).toBe(2);
+
+    expect((await withMetrics.handle('GET', '/function-metrics?minNesting=2')).body).toMatchObject({ matched: 1 });
+    expect((await withMetrics.handle('GET', '/function-metrics?sort=size')).status).toBe(400);
+    expect((await server.handle('GET', '/function-metrics')).status).toBe(400);
+  });
+
+  it('should report code owners', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile(uri, [
+      createTestSymbol({ id: 'svc', name: 'UserService', kind: 'class', filePath: uri, isExported: true })
+    ]);
+    const rules = parseCodeOwners('/src/ @org/identity');
+    const withOwners = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      new Ownership(background.asBackgroundIndex(), { ownersOf: filePath => resolveOwners(rules, filePath.replace(/^\/ws\//, '')) })
+    );
+
+    const summary = (await withOwners.handle('GET', '/owners')).body as any;
+    expect(summary.owners).toEqual([{ owner: '@org/identity', files: 1, symbols: 1, exported: 1 }]);
+    expect((await withOwners.handle('GET', `/owners?uri=${uri}`)).body).toEqual({ uri, owners: ['@org/identity'] });
+
+    const symbols = (await withOwners.handle('GET', '/symbols?q=UserService')).body as any;
+    expect(symbols.symbols[0].owners).toEqual(['@org/identity']);
+    expect(((await server.handle('GET', '/symbols?q=UserService')).body as any).symbols[0].owners).toBeUndefined();
+    expect((await server.handle('GET', '/owners')).status).toBe(400);
+  });
+
+  it('should list comment markers', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile(uri, []);
+    const markerIndex = new CommentMarkerIndex(background.asBackgroundIndex(), async () => '// TODO(alice): paginate\n// FIXME: leaks\n');
+    await markerIndex.build();
+    const withMarkers = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      query => markerIndex.query(query)
+    );
+
+    const todos = (await withMarkers.handle('GET', '/markers?tag=todo&path=/ws/src')).body as any;
+    expect(todos).toMatchObject({ total: 1, byTag: { TODO: 1 }, blamed: false });
+    expect(todos.markers[0]).toMatchObject({ tag: 'TODO', text: 'paginate', owner: 'alice', uri, line: 0 });
+    expect(((await withMarkers.handle('GET', '/markers?author=nobody')).body as any).total).toBe(0);
+
+    // Ages need blame, which this source does not have
+    expect((await withMarkers.handle('GET', '/markers?minAge=30')).status).toBe(400);
+    expect((await withMarkers.handle('GET', '/markers?sort=size')).status).toBe(400);
+    expect((await server.handle('GET', '/markers')).status).toBe(400);
+  });
+
+  it('should list HTTP routes', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/main.go', []);
+    const routeIndex = new HttpRouteIndex(background.asBackgroundIndex(), async () =>
+      'package main\n\nimport "net/http"\n\nfunc main() {\n\thttp.HandleFunc("GET /users", listUsers)\n\thttp.HandleFunc("POST /users", createUser)\n}\n'
+    );
+    await routeIndex.build();
+    const withRoutes = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      query => routeIndex.query(query)
+    );
+
+    const posts = (await withRoutes.handle('GET', '/routes?method=post&framework=net/http')).body as any;
+    expect(posts).toMatchObject({ total: 1, byMethod: { POST: 1 } });
+    expect(posts.routes[0]).toMatchObject({ method: 'POST', path: '/users', handler: 'createUser', uri: '/ws/main.go', line: 6 });
+    expect(((await withRoutes.handle('GET', '/routes?route=/admin')).body as any).total).toBe(0);
+
+    expect((await withRoutes.handle('GET', '/routes?framework=rails')).status).toBe(400);
+    expect((await server.handle('GET', '/routes')).status).toBe(400);
+  });
+
+  it('should list the functions running SQL on a table', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/store.go', []);
+    const sqlIndex = new SqlQueryIndex(background.asBackgroundIndex(), async () =>
+      'package store\n\nimport "database/sql"\n\nfunc f(db *sql.DB) {\n\tdb.Exec("UPDATE users SET name = $1", n)\n\tdb.Query("SELECT id FROM orders")\n}\n'
+    );
+    await sqlIndex.build();
+    const withSql = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, filter => Promise.resolve(sqlIndex.query(filter))
+    );
+
+    const writes = (await withSql.handle('GET', '/sql?table=users&access=write')).body as any;
+    expect(writes).toMatchObject({ total: 1, functions: [{ uri: '/ws/store.go', queries: 1, operations: ['update'] }] });
+    expect(writes.queries[0]).toMatchObject({ call: 'Exec', sql: 'UPDATE users SET name = $1', line: 5 });
+    expect(((await withSql.handle('GET', '/sql?operation=SELECT&column=id')).body as any).total).toBe(1);
+
+    expect((await withSql.handle('GET', '/sql?operation=upsert')).status).toBe(400);
+    expect((await withSql.handle('GET', '/sql?access=both')).status).toBe(400);
+    expect((await server.handle('GET', '/sql')).status).toBe(400);
+  });
+
+  it('should list configuration keys', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/main.go', []);
+    const keyIndex = new ConfigKeyIndex(background.asBackgroundIndex(), async () =>
+      'package main\n\nimport (\n\t"flag"\n\t"os"\n)\n\nvar addr = flag.String("addr", ":80", "listen address")\nvar home = os.Getenv("HOME")\n'
+    );
+    await keyIndex.build();
+    const withKeys = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, query => Promise.resolve(keyIndex.query(query))
+    );
+
+    const flags = (await withKeys.handle('GET', '/env?source=flag')).body as any;
+    expect(flags).toMatchObject({ total: 1, keys: [{ key: 'addr', types: ['string'], defaults: ['":80"'] }] });
+    expect(flags.keys[0].uses[0]).toMatchObject({ uri: '/ws/main.go', line: 7, description: 'listen address' });
+    const rendered = await withKeys.handle('GET', `/env?format=template&template=${encodeURIComponent('{{.source}} {{.key}}')}`);
+    expect(rendered.text).toBe('env HOME\nflag addr\n');
+
+    expect((await withKeys.handle('GET', '/env?source=yaml')).status).toBe(400);
+    expect((await server.handle('GET', '/env')).status).toBe(400);
+  });
+
+  it('should list goroutine launches and channel operations', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/worker/pool.go', []);
+    const concurrencyIndex = new ConcurrencyIndex(background.asBackgroundIndex(), async () =>
+      'package worker\n\nfunc Start(jobs chan int) {\n\tgo run(jobs)\n\tjobs <- 1\n}\n'
+    );
+    await concurrencyIndex.build();
+    const withConcurrency = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, query => Promise.resolve(concurrencyIndex.query(query))
+    );
+
+    const spawns = (await withConcurrency.handle('GET', '/concurrency?op=go&package=worker')).body as any;
+    expect(spawns).toMatchObject({ total: 1, byOp: { go: 1 } });
+    expect(spawns.events[0]).toMatchObject({ op: 'go', target: 'run', uri: '/ws/worker/pool.go', line: 3 });
+    expect(((await withConcurrency.handle('GET', '/concurrency?target=jobs')).body as any).byOp).toEqual({ send: 1 });
+    expect(((await withConcurrency.handle('GET', '/concurrency?package=cache')).body as any).total).toBe(0);
+
+    expect((await withConcurrency.handle('GET', '/concurrency?op=spawn')).status).toBe(400);
+    expect((await server.handle('GET', '/concurrency')).status).toBe(400);
+  });
+
+  it('should list the exported functions that can panic', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/config/config.go', [
+      createTestSymbol({
+        name: 'MustLoad', location: { uri: '/ws/config/config.go', line: 2, character: 5 },
+        range: { startLine: 2, startCharacter: 0, endLine: 4, endCharacter: 1 }, isExported: true
+      }),
+      createTestSymbol({
+        name: 'load', location: { uri: '/ws/config/config.go', line: 6, character: 5 },
+        range: { startLine: 6, startCharacter: 0, endLine: 9, endCharacter: 1 }, isExported: false
+      })
+    ]);
+    const errorPathIndex = new ErrorPathIndex(background.asBackgroundIndex(), async () =>
+      'package config\n\nfunc MustLoad() {\n\tpanic("no config")\n}\n\nfunc load() {\n\t_ = parse()\n\tpanic("bad")\n}\n'
+    );
+    await errorPathIndex.build();
+    const withErrors = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, query => Promise.resolve(errorPathIndex.query(query))
+    );
+
+    const panics = (await withErrors.handle('GET', '/errors?exported=true&canPanic=true')).body as any;
+    expect(panics).toMatchObject({ total: 1, byKind: { panic: 1 } });
+    expect(panics.functions.map((summary: any) => summary.name)).toEqual(['MustLoad']);
+    expect(((await withErrors.handle('GET', '/errors?kind=discard')).body as any).events[0]).toMatchObject({ expression: 'parse()', line: 7 });
+
+    expect((await withErrors.handle('GET', '/errors?kind=fatal')).status).toBe(400);
+    expect((await server.handle('GET', '/errors')).status).toBe(400);
+  });
+
+  it('should list untested functions with their reference counts', async () => {
+    const background = new MockBackgroundIndex();
+    background.addFile('/ws/store/store.go', [
+      createTestSymbol({
+        name: 'Get', kind: 'function', location: { uri: '/ws/store/store.go', line: 2, character: 5 },
+        range: { startLine: 2, startCharacter: 0, endLine: 4, endCharacter: 1 }, isExported: true
+      }),
+      createTestSymbol({
+        name: 'put', kind: 'function', location: { uri: '/ws/store/store.go', line: 6, character: 5 },
+        range: { startLine: 6, startCharacter: 0, endLine: 8, endCharacter: 1 }, isExported: false
+      })
+    ]);
+    const coverageIndex = new CoverageIndex(background.asBackgroundIndex(), {
+      readFile: async () => 'mode: set\nexample.com/app/store/store.go:3.12,5.2 2 0\nexample.com/app/store/store.go:7.12,9.2 1 1\n',
+      stat: async () => ({ mtimeMs: 1, size: 1 })
+    });
+    coverageIndex.setProfiles(['/ws/coverage.out']);
+    const withCoverage = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, query => coverageIndex.query(query)
+    );
+
+    const untested = (await withCoverage.handle('GET', '/coverage?maxCoverage=0&exported=true')).body as any;
+    expect(untested.functions.map((entry: any) => [entry.name, entry.percent, entry.statements])).toEqual([['Get', 0, 2]]);
+    expect(((await withCoverage.handle('GET', '/coverage?exported=false')).body as any).functions[0]).toMatchObject({ name: 'put', percent: 100 });
+
+    expect((await withCoverage.handle('GET', '/coverage?minRefs=-1')).status).toBe(400);
+    expect((await withCoverage.handle('GET', '/coverage?exported=yes')).status).toBe(400);
+    expect((await server.handle('GET', '/coverage')).status).toBe(400);
+  });
+
+  it('should list the Go tests exercising a symbol', async () => {
+    const background = new MockBackgroundIndex();
+    const goIndexer = new GoIndexer();
+    for (const [fileUri, content] of [
+      ['/ws/store/store.go', 'package store\n\nfunc Get(key string) string { return key }\n'],
+      ['/ws/store/store_test.go', 'package store\n\nimport "testing"\n\nfunc TestGet(t *testing.T) { Get("a") }\n\nfunc BenchmarkGet(b *testing.B) { Get("b") }\n']
+    ]) {
+      const result = goIndexer.indexFile(fileUri, content);
+      background.addFile(fileUri, result.symbols, result.references, { imports: result.imports });
+    }
+    const goTestIndex = new GoTestIndex(background.asBackgroundIndex());
+    const withTests = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, async query => {
+        await goTestIndex.build();
+        return goTestIndex.testsFor(query);
+      }
+    );
+
+    const report = (await withTests.handle('GET', '/tests-for?symbol=store.Get')).body as any;
+    expect(report.tests.map((test: any) => [test.name, test.kind])).toEqual([['TestGet', 'test'], ['BenchmarkGet', 'benchmark']]);
+    expect(((await withTests.handle('GET', '/tests-for?symbol=Get&kind=benchmark')).body as any).tests).toHaveLength(1);
+
+    expect((await withTests.handle('GET', '/tests-for')).status).toBe(400);
+    expect((await withTests.handle('GET', '/tests-for?symbol=Get&kind=unit')).status).toBe(400);
+    expect((await server.handle('GET', '/tests-for?symbol=Get')).status).toBe(400);
+  });
+
+  it('should list the Go tests affected by the changes since a commit', async () => {
+    const queries: any[] = [];
+    const withAffected = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, undefined, async query => {
+        queries.push(query);
+        return { since: 'abc', head: 'def', files: 1, changed: [], tests: [], packages: [], all: true, commands: ['go test ./...'] };
+      }
+    );
+
+    expect(((await withAffected.handle('GET', '/affected-tests?since=HEAD~1&kind=test')).body as any).commands).toEqual(['go test ./...']);
+    expect(queries).toEqual([{ since: 'HEAD~1', maxDepth: undefined, kinds: ['test'] }]);
+
+    expect((await withAffected.handle('GET', '/affected-tests')).status).toBe(400);
+    expect((await withAffected.handle('GET', '/affected-tests?since=HEAD&kind=unit')).status).toBe(400);
+    expect((await server.handle('GET', '/affected-tests?since=HEAD')).status).toBe(400);
+  });
+
+  it('should serve call graph slices by name and position', async () => {
+    const background = new MockBackgroundIndex();
+    const goIndexer = new GoIndexer();
+    const serviceGo = '/ws/service/service.go';
+    const result = goIndexer.indexFile(serviceGo, 'package service\n\nfunc Save() { validate() }\n\nfunc validate() { check() }\n\nfunc check() {}\n');
+    background.addFile(serviceGo, result.symbols, result.references);
+    const graph = new CallGraph(background.asBackgroundIndex());
+    await graph.build();
+    const withGraph = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, async query => graph.query(query)
+    );
+
+    const callees = (await withGraph.handle('GET', '/call-graph?name=Save&direction=callees&depth=1')).body as any;
+    expect(callees.nodes.map((node: any) => node.name).sort()).toEqual(['Save', 'validate']);
+    const around = (await withGraph.handle('GET', `/call-graph?uri=file://${serviceGo}&line=4&character=8`)).body as any;
+    expect(around.nodes.map((node: any) => node.name).sort()).toEqual(['Save', 'check', 'validate']);
+
+    expect((await withGraph.handle('GET', '/call-graph')).status).toBe(400);
+    expect((await withGraph.handle('GET', '/call-graph?name=Save&direction=up')).status).toBe(400);
+    expect((await server.handle('GET', '/call-graph?name=Save')).status).toBe(400);
+  });
+
+  it('should search the docs and the doc mentions of a symbol', async () => {
+    const goIndexer = new GoIndexer();
+    const docIndex = new MockIndex();
+    for (const symbol of goIndexer.indexFile('/ws/greet/greet.go', 'package greet\n\ntype Person struct{}\n\nfunc (p Person) Greet() {}\n').symbols) {
+      docIndex.addSymbol(symbol);
+    }
+    const files: Record<string, string> = { '/ws/greet/README.md': '# Greeting\n\nCall `Person.Greet`.\n\n```go\nPerson{}.Greet()\n```\n' };
+    const docs = new DocsIndex({
+      getAllFiles: async () => Object.keys(files),
+      getFileInfo: () => ({ hash: 'h' }),
+      findDefinitions: name => docIndex.findDefinitions(name)
+    }, async uri => files[uri]);
+    await docs.build();
+    const withDocs = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, {
+        search: async query => docs.search(query),
+        mentions: query => docs.mentions(query),
+        links: uri => docs.links(uri)
+      }
+    );
+
+    const found = (await withDocs.handle('GET', '/docs?q=call&language=go')).body as any;
+    expect(found.sections.map((s: any) => [s.heading, s.anchor, s.excerpt])).toEqual([['Greeting', 'greeting', 'Call `Person.Greet`.']]);
+    expect((await withDocs.handle('GET', '/docs/mentions?symbol=Person.Greet&format=template&template={{.uri}}:{{.line}}')).text)
+      .toBe('/ws/greet/README.md:2\n');
+    const links = (await withDocs.handle('GET', '/docs/links?uri=file:///ws/greet/README.md')).body as any;
+    expect(links.links.map((l: any) => [l.target, l.definition.location.line])).toEqual([['Person.Greet', 4]]);
+
+    expect((await withDocs.handle('GET', '/docs/mentions')).status).toBe(400);
+    expect((await server.handle('GET', '/docs?q=call')).status).toBe(400);
+  });
+
+  it('should serve the web UI', async () => {
+    for (const path of ['/', '/ui']) {
+      const response = await server.handle('GET', path);
+      expect(response).toMatchObject({ status: 200, contentType: 'text/html; charset=utf-8' });
+      expect(response.text).toContain('<title>Smart Indexer</title>');
+    }
+
+    const address = await server.start(0);
+    const response = await fetch(`http://127.0.0.1:${address.port}/`);
+    expect(response.headers.get('content-type')).toContain('text/html');
+    expect(await response.text()).toContain("api('/call-graph'");
+  });
+
+  it('should answer GraphQL queries over GET and POST', async () => {
+    const query = '{ definitions(name: "UserService") { qualifiedName members { name } references { isCall location { line } } } }';
+    const expected = {
+      data: {
+        definitions: [{ qualifiedName: 'UserService', members: [{ name: 'load' }], references: [{ isCall: true, location: { line: 3 } }] }]
+      }
+    };
+    expect(await server.handle('GET', `/graphql?query=${encodeURIComponent(query)}`)).toEqual({ status: 200, body: expected });
+    expect(await server.handle('POST', '/graphql', query)).toEqual({ status: 200, body: expected });
+
+    const address = await server.start(0);
+    const response = await fetch(`http://127.0.0.1:${address.port}/graphql`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query: 'query Find($q: String!) { symbols(query: $q, limit: 1) { name } }', variables: { q: 'load' } })
+    });
+    expect(await response.json()).toEqual({ data: { symbols: [{ name: 'load' }] } });
+
+    expect(await server.handle('GET', '/graphql?query={ nope }')).toMatchObject({
+      status: 400, body: { errors: [{ message: 'Cannot query field "nope" on type "Query"' }] }
+    });
+    expect((await server.handle('POST', '/graphql', '{"variables": {}}')).status).toBe(400);
+    expect((await server.handle('DELETE', '/graphql')).status).toBe(405);
+    expect((await server.handle('GET', '/graphql/schema')).text).toContain('type Symbol {');
+  });
+
+  it('should report indexing progress', async () => {
+    const tracker = new ProgressTracker();
+    tracker.fileScanned(2);
+    tracker.startIndexing(2, 300);
+    tracker.fileIndexed('/ws/a.ts', 100);
+    const withProgress = new QueryServer(index, undefined, undefined, undefined, undefined, () => tracker.snapshot());
+
+    const progress = (await withProgress.handle('GET', '/progress')).body as any;
+    expect(progress).toMatchObject({ phase: 'indexing', filesIndexed: 1, filesTotal: 2, bytesProcessed: 100, bytesTotal: 300 });
+
+    const events: any[] = [];
+    const response = await withProgress.handle('GET', '/stream/progress?interval=100');
+    for await (const event of response.stream!) {
+      events.push(event);
+      tracker.fileIndexed('/ws/b.ts', 200);
+      tracker.setPhase('idle');
+    }
+    expect(events.map(e => [e.phase, e.filesIndexed])).toEqual([['indexing', 1], ['idle', 2]]);
+    expect((await server.handle('GET', '/progress')).status).toBe(400);
+  });
+
+  it('should serve Prometheus metrics and time requests', async () => {
+    const metrics = new IndexerMetrics({
+      getStats: () => ({ files: 1, symbols: 2 }),
+      getParseErrorCount: () => 0,
+      getIndexingProgress: () => new ProgressTracker().snapshot()
+    });
+    const withMetrics = new QueryServer(index, undefined, undefined, undefined, undefined, undefined, metrics);
+    await withMetrics.handle('GET', '/symbols?q=User');
+    await withMetrics.handle('GET', '/nope');
+
+    const address = await withMetrics.start(0);
+    const response = await fetch(`http://127.0.0.1:${address.port}/metrics`);
+    expect(response.headers.get('content-type')).toContain('text/plain; version=0.0.4');
+    const text = await response.text();
+    expect(text).toContain('smart_indexer_index_symbols{index="background"} 2');
+    expect(text).toContain('smart_indexer_query_duration_seconds_count{api="http",endpoint="/symbols"} 1');
+    expect(text).not.toContain('endpoint="/nope"');
+    await withMetrics.stop();
+
+    expect((await server.handle('GET', '/metrics')).status).toBe(400);
+  });
+
+  it('should serve CPU and heap profiles at /debug/pprof', async () => {
+    expect(await server.handle('GET', '/debug/pprof/profile?seconds=0.05')).toEqual({ status: 400, body: { error: 'Profiling is not enabled' } });
+
+    server.setProfiler(new SamplingProfiler());
+    expect((await server.handle('GET', '/debug/pprof')).text).toContain('/debug/pprof/heap?seconds=10');
+    const address = await server.start(0);
+    const response = await fetch(`http://127.0.0.1:${address.port}/debug/pprof/profile?seconds=0.05`);
+    expect(response.headers.get('content-type')).toBe('application/octet-stream');
+    expect(response.headers.get('content-disposition')).toBe('attachment; filename="profile.pb.gz"');
+    const body = new Uint8Array(await response.arrayBuffer());
+    expect([body[0], body[1]]).toEqual([0x1f, 0x8b]);
+
+    const heap = await server.handle('GET', '/debug/pprof/heap?seconds=0.05&format=template&template={{.}}');
+    expect([heap.status, heap.binary && Buffer.from(heap.binary).subarray(0, 2).toString('hex')]).toEqual([200, '1f8b']);
+
+    const running = server.handle('GET', '/debug/pprof/profile?seconds=0.2');
+    expect((await server.handle('GET', '/debug/pprof/profile?seconds=0.05')).status).toBe(409);
+    expect((await running).status).toBe(200);
+
+    expect((await server.handle('GET', '/debug/pprof/profile?seconds=0')).status).toBe(400);
+    expect((await server.handle('GET', '/debug/pprof/heap?seconds=301')).status).toBe(400);
+    const batch = await server.handle('POST', '/batch', '["/debug/pprof/heap?seconds=1"]');
+    for await (const line of batch.stream!) {
+      expect(line).toMatchObject({ status: 400, body: { error: 'Streaming endpoints, profiles and /batch cannot be batched' } });
+    }
+  });
+
+  it('should reject bad requests', async () => {
+    expect((await server.handle('GET', '/symbols')).status).toBe(400);
+    expect((await server.handle('GET', '/definition?uri=/ws/missing.ts&line=0&character=0')).status).toBe(400);
+    expect((await server.handle('GET', '/definition?uri=/ws/src/user.ts&line=-1&character=0')).status).toBe(400);
+    expect((await server.handle('GET', '/nope')).status).toBe(404);
+    expect((await server.handle('POST', '/symbols?q=User')).status).toBe(405);
+  });
+
+  it('should serve JSON over HTTP', async () => {
+    const address = await server.start(0);
+    const response = await fetch(`http://127.0.0.1:${address.port}/definition?name=load`);
+
+    expect(response.status).toBe(200);
+    expect(response.headers.get('content-type')).toContain('application/json');
+    expect((await response.json() as any).definitions[0].containerName).toBe('UserService');
+  });
+
+  it('should only answer requests for localhost without credentials', async () => {
+    /** Status of a request naming host in its Host header, as a rebound page would */
+    const statusFor = (port: number, host: string, headers: http.OutgoingHttpHeaders = {}) => new Promise<number>((resolve, reject) => {
+      http.get({ host: '127.0.0.1', port, path: '/definition?name=load', headers: { host, ...headers } }, res => {
+        res.resume();
+        resolve(res.statusCode!);
+      }).on('error', reject);
+    });
+
+    const address = await server.start(0);
+    expect(await statusFor(address.port, `attacker.example:${address.port}`)).toBe(403);
+    expect(await statusFor(address.port, `localhost:${address.port}`)).toBe(200);
+    expect(await statusFor(address.port, `127.0.0.1:${address.port}`)).toBe(200);
+    expect(await statusFor(address.port, `[::1]:${address.port}`)).toBe(200);
+
+    // A token is what lets other host names in
+    await server.stop();
+    const authenticated = await server.start(0, '127.0.0.1', { auth: new QueryServerAuth([{ name: 'ci', token: 's3cret' }]) });
+    expect(await statusFor(authenticated.port, 'index.internal', { authorization: 'Bearer s3cret' })).toBe(200);
+    expect(await statusFor(authenticated.port, 'index.internal')).toBe(401);
+  });
+
+  it('should answer with a 500 when the handler throws', async () => {
+    const listener = new QueryListener({ handle: async () => { throw new Error('index closed'); } }, new NullLogger(), 'Test');
+    const address = await listener.start(0);
+    try {
+      const response = await fetch(`http://127.0.0.1:${address.port}/symbols?q=User`);
+      expect(response.status).toBe(500);
+      expect(await response.json()).toEqual({ error: 'index closed' });
+    } finally {
+      await listener.stop();
+    }
+  });
+
+  it('should stream references as NDJSON', async () => {
+    for (let i = 0; i < 3; i++) {
+      index.addReference('load', createTestReference({ symbolName: 'load', location: { uri, line: 10 + i, character: 4 } }));
+    }
+
+    const response = await server.handle('GET', '/stream/references?name=load');
+    expect(response.status).toBe(200);
+    expect((await collect(response.stream)).map(r => r.location.line)).toEqual([10, 11, 12]);
+
+    const address = await server.start(0);
+    const http = await fetch(`http://127.0.0.1:${address.port}/stream/references?name=load`);
+    expect(http.headers.get('content-type')).toContain('application/x-ndjson');
+    const lines = (await http.text()).trim().split('\n').map(line => JSON.parse(line));
+    expect(lines.map(r => r.name)).toEqual(['load', 'load', 'load']);
+  });
+
+  it('should stream references as the index reads them, with the next cursor as a trailer', async () => {
+    let read = 0;
+    const streaming = Object.assign(index, {
+      async *streamReferencesByName(name: string) {
+        for (let batch = 0; batch < 3; batch++) {
+          read++;
+          yield [0, 1].map(i => createTestReference({ symbolName: name, location: { uri, line: 10 * batch + i, character: 4 } }));
+        }
+      }
+    });
+    const streamingServer = new QueryServer(streaming);
+
+    const response = await streamingServer.handle('GET', '/stream/references?name=load&limit=3');
+    expect(read).toBe(0);
+    expect((await collect(response.stream)).map(r => r.location.line)).toEqual([0, 1, 10]);
+    expect(read).toBe(2);
+    const cursor = response.trailers!['X-Next-Cursor']();
+    const rest = await streamingServer.handle('GET', `/stream/references?name=load&limit=3&cursor=${cursor}`);
+    expect((await collect(rest.stream)).map(r => r.location.line)).toEqual([11, 20, 21]);
+    expect(rest.trailers!['X-Next-Cursor']()).toBeUndefined();
+
+    const address = await streamingServer.start(0);
+    const trailers = await new Promise<NodeJS.Dict<string>>((resolve, reject) => {
+      http.get(`http://127.0.0.1:${address.port}/stream/references?name=load&limit=3`, res => {
+        res.resume();
+        res.on('end', () => resolve(res.trailers));
+      }).on('error', reject);
+    });
+    expect(trailers['x-next-cursor']).toBe(cursor);
+    await streamingServer.stop();
+  });
+
+  it('should page references with offsets and cursors', async () => {
+    for (let i = 0; i < 5; i++) {
+      index.addReference('load', createTestReference({ symbolName: 'load', location: { uri, line: 10 + i, character: 4 } }));
+    }
+    const lines = (body: any) => body.references.map((r: any) => r.location.line);
+
+    expect(lines((await server.handle('GET', '/references?name=load')).body)).toEqual([10, 11, 12, 13, 14]);
+    const first = (await server.handle('GET', '/references?name=load&limit=2')).body as any;
+    expect([lines(first), first.offset]).toEqual([[10, 11], 0]);
+    expect(typeof first.nextCursor).toBe('string');
+    expect(lines((await server.handle('GET', '/references?name=load&limit=2&offset=3')).body)).toEqual([13, 14]);
+
+    // A reference added in front of the cursor neither repeats nor shifts the next page
+    (await index.findReferencesByName('load')).unshift(createTestReference({ symbolName: 'load', location: { uri, line: 5, character: 4 } }));
+    const second = (await server.handle('GET', `/references?name=load&limit=2&cursor=${first.nextCursor}`)).body as any;
+    expect([lines(second), second.offset]).toEqual([[12, 13], 3]);
+    const last = (await server.handle('GET', `/references?name=load&limit=2&cursor=${second.nextCursor}`)).body as any;
+    expect([lines(last), last.nextCursor]).toEqual([[14], undefined]);
+
+    const stream = await server.handle('GET', `/stream/references?name=load&limit=2&cursor=${first.nextCursor}`);
+    expect((await collect(stream.stream)).map(r => r.location.line)).toEqual([12, 13]);
+    expect(stream.trailers!['X-Next-Cursor']()).toBe(second.nextCursor);
+
+    const other = await server.handle('GET', `/references?name=UserService&cursor=${first.nextCursor}`);
+    expect([other.status, (other.body as any).error]).toEqual([400, 'Cursor belongs to a different query']);
+    expect((await server.handle('GET', '/references?name=load&cursor=bogus')).status).toBe(400);
+  });
+
+  it('should page symbol searches and structured queries', async () => {
+    for (let i = 0; i < 4; i++) {
+      index.addSymbol(createTestSymbol({ id: `user${i}`, name: `User${i}`, kind: 'function', filePath: uri, location: { uri, line: 5 + i, character: 0 } }));
+    }
+    const names = (body: any) => body.symbols.map((s: any) => s.name);
+    const all = names((await server.handle('GET', '/symbols?q=User')).body);
+    expect(all).toHaveLength(5);
+
+    const first = (await server.handle('GET', '/symbols?q=User&limit=3')).body as any;
+    const second = (await server.handle('GET', `/symbols?q=User&limit=3&cursor=${first.nextCursor}`)).body as any;
+    expect([...names(first), ...names(second)]).toEqual(all);
+    expect([second.offset, second.nextCursor]).toEqual([3, undefined]);
+    expect(names((await server.handle('GET', '/symbols?q=User&limit=0')).body)).toEqual([]);
+    expect((await server.handle('GET', `/symbols?q=User&kind=class&cursor=${first.nextCursor}`)).status).toBe(400);
+
+    const background = new MockBackgroundIndex();
+    background.addFile(uri, await index.getFileSymbols(uri), []);
+    const withQueries = new QueryServer(index, undefined, undefined, undefined, new StructuredQuery(background.asBackgroundIndex()));
+    const page = (await withQueries.handle('GET', '/query?q=kind:function&limit=3')).body as any;
+    expect([names(page), page.truncated]).toEqual([['User0', 'User1', 'User2'], true]);
+    const rest = (await withQueries.handle('GET', `/query?q=kind:function&limit=3&cursor=${page.nextCursor}`)).body as any;
+    expect([names(rest), rest.truncated, rest.offset]).toEqual([['User3'], false, 3]);
+
+    const text = await server.handle('GET', '/symbols?q=User&limit=3&format=template&template={{.name}}');
+    expect(text.headers).toEqual({ 'X-Next-Cursor': first.nextCursor });
+  });
+
+  it('should render results through an output template', async () => {
+    const template = encodeURIComponent('{{.name}}{{if .containerName}} in {{.containerName}}{{end}} line {{.location.line}}');
+    const response = await server.handle('GET', `/symbols?q=load&format=template&template=${template}`);
+    expect(response.status).toBe(200);
+    expect(response.text).toBe('load in UserService line 1\n');
+
+    const health = await server.handle('GET', `/health?format=template&template=${encodeURIComponent('{{.status | upper}}')}`);
+    expect(health.text).toBe('OK\n');
+
+    const stream = await server.handle('GET', `/stream/references?name=UserService&format=template&template=${encodeURIComponent('{{.location.line}}:{{.location.character}}')}`);
+    const lines: unknown[] = [];
+    for await (const line of stream.stream!) {
+      lines.push(line);
+    }
+    expect(lines).toEqual(['3:20\n']);
+
+    const address = await server.start(0);
+    const http = await fetch(`http://127.0.0.1:${address.port}/symbols?q=User&format=template&template=${encodeURIComponent('{{.kind}}')}`);
+    expect(http.headers.get('content-type')).toBe('text/plain; charset=utf-8');
+    expect(await http.text()).toBe('class\n');
+  });
+
+  it('should reject bad output templates', async () => {
+    const unclosed = await server.handle('GET', `/symbols?q=User&format=template&template=${encodeURIComponent('{{.name')}`);
+    expect(unclosed.status).toBe(400);
+    expect((unclosed.body as any).error).toContain('Unclosed action');
+    expect((await server.handle('GET', '/symbols?q=User&format=template')).status).toBe(400);
+    expect((await server.handle('GET', '/symbols?q=User&format=yaml')).status).toBe(400);
+    const runtime = await server.handle('GET', `/symbols?q=User&format=template&template=${encodeURIComponent('{{len .missing}}')}`);
+    expect(runtime.status).toBe(400);
+    // Errors stay JSON
+    expect((await server.handle('GET', `/symbols?format=template&template=x`)).body).toEqual({ error: 'Missing query parameter "q"' });
+  });
+
+  it('should answer a batch of queries in order, each with its own status', async () => {
+    const body = [
+      '/definition?name=load',
+      '"/symbols?q=User"',
+      JSON.stringify({ id: 'refs', endpoint: '/references', params: { name: 'UserService' } }),
+      '/symbols',
+      '/stream/symbols?q=User',
+      `/health?format=template&template=${encodeURIComponent('{{.status}}')}`,
+      '{"endpoint": "symbols"}'
+    ].join('\n');
+    const response = await server.handle('POST', '/batch', body);
+    expect(response.status).toBe(200);
+    const lines: any[] = [];
+    for await (const line of response.stream!) {
+      lines.push(line);
+    }
+
+    expect(lines.map(line => [line.index, line.status])).toEqual([[0, 200], [1, 200], [2, 200], [3, 400], [4, 400], [5, 200], [6, 400]]);
+    expect(lines[0].body.definitions[0].containerName).toBe('UserService');
+    expect(lines[1].body.symbols[0].name).toBe('UserService');
+    expect(lines[2]).toMatchObject({ id: 'refs', body: { references: [{ location: { line: 3 } }] } });
+    expect(lines[3].body.error).toBe('Missing query parameter "q"');
+    expect(lines[5].text).toBe('ok\n');
+
+    // A JSON array over HTTP
+    const address = await server.start(0);
+    const http = await fetch(`http://127.0.0.1:${address.port}/batch`, {
+      method: 'POST',
+      body: JSON.stringify(['/definition?name=load', { endpoint: '/outline', params: { uri } }])
+    });
+    expect(http.headers.get('content-type')).toContain('application/x-ndjson');
+    const results = (await http.text()).trim().split('\n').map(line => JSON.parse(line));
+    expect(results.map(r => r.status)).toEqual([200, 200]);
+    expect(results[1].body.symbols.map((s: any) => s.name)).toEqual(['UserService', 'load']);
+  });
+
+  it('should index unsaved buffers POSTed to /overlay', async () => {
+    const overlaid: string[] = [];
+    const withOverlays = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, {
+        overlay: async (file, content) => {
+          const draft = `/ws/${file}`;
+          overlaid.push(draft);
+          index.addSymbol(createTestSymbol({
+            name: content.trim(), kind: 'class', filePath: draft, location: { uri: draft, line: 0, character: 6 }
+          }));
+          return { uri: draft, shadowsIndexed: false };
+        },
+        clear: file => file === undefined ? overlaid.splice(0).length : 0
+      }
+    );
+
+    const response = await withOverlays.handle('POST', '/overlay?uri=src/draft.ts', 'Draft\n');
+    expect(response.status).toBe(200);
+    expect(response.body).toMatchObject({ uri: '/ws/src/draft.ts', shadowsIndexed: false, symbols: [{ name: 'Draft' }], outline: [{ name: 'Draft' }] });
+    expect((await withOverlays.handle('DELETE', '/overlay')).body).toEqual({ cleared: 1 });
+    expect((await withOverlays.handle('GET', '/overlay?uri=src/draft.ts')).status).toBe(405);
+    expect((await withOverlays.handle('POST', '/overlay', 'Draft')).status).toBe(400);
+    expect((await server.handle('POST', '/overlay?uri=src/draft.ts', 'Draft')).status).toBe(400);
+  });
+
+  it('should answer shell completions as plain text', async () => {
+    const queries: unknown[] = [];
+    const withCompletions = new QueryServer(
+      index, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined, undefined,
+      async query => {
+        queries.push(query);
+        return ['UserService', 'UserStore'];
+      }
+    );
+
+    const response = await withCompletions.handle('GET', '/complete?kind=symbol&prefix=User&describe=true&cwd=file:///ws/src');
+    expect([response.status, response.text]).toEqual([200, 'UserService\nUserStore\n']);
+    expect(queries).toEqual([{ kind: 'symbol', prefix: 'User', cwd: '/ws/src', limit: undefined, describe: true }]);
+    expect((await withCompletions.handle('GET', '/complete?kind=flag')).status).toBe(400);
+    expect((await server.handle('GET', '/complete?kind=file')).status).toBe(400);
+  });
+
+  it('should reject malformed batches', async () => {
+    expect((await server.handle('GET', '/batch')).status).toBe(405);
+    expect((await server.handle('POST', '/batch', '[1, 2')).status).toBe(400);
+    expect((await server.handle('POST', '/batch', 'symbols?q=User')).status).toBe(400);
+  });
+
+  it('should validate streaming requests before sending headers', async () => {
+    const response = await server.handle('GET', '/stream/symbols');
+    expect(response.status).toBe(400);
+    expect(response.stream).toBeUndefined();
+  });
+
+  it('should cache results until the index generation changes', async () => {
+    let generation = 1;
+    const versioned = Object.assign(Object.create(index) as MockIndex, { getGeneration: () => generation });
+    const cached = new QueryServer(versioned);
+    const names = async () => ((await cached.handle('GET', '/symbols?q=User&limit=5')).body as any).symbols.map((s: any) => s.name);
+
+    expect(await names()).toEqual(['UserService']);
+    index.addSymbol(createTestSymbol({ id: 'repo', name: 'UserRepo', filePath: uri, location: { uri, line: 5, character: 5 } }));
+    expect(await names()).toEqual(['UserService']);
+
+    generation++;
+    expect(await names()).toContain('UserRepo');
+
+    cached.setCacheSize(0);
+    index.addSymbol(createTestSymbol({ id: 'store', name: 'UserStore', filePath: uri, location: { uri, line: 6, character: 5 } }));
+    expect(await names()).toContain('UserStore');
+  });
+});
@@ -0,0 +1,1751 @@
+import * as fs from 'fs';
+import { AddressInfo } from 'net';
+import { fileURLToPath } from 'url';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { CodeTag, IndexedReference, IndexedSymbol } from '../types.js';
+import { ILogger, NullLogger } from '../utils/Logger.js';
+import { getWordAtPosition } from '../utils/textUtils.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { canonicalIdName } from '../indexer/canonicalIds.js';
+import { SymbolDocs } from './symbolDocs.js';
+import { matchesCriteria, SignatureSearch } from './signatureSearch.js';
+import { StructuredQuery } from './structuredQuery.js';
+import { buildOutline } from './outline.js';
+import { RenameImpactAnalyzer } from './renameImpact.js';
+import { CODE_METRICS, CodeMetric, CodeMetrics } from './codeMetrics.js';
+import { Ownership } from './ownership.js';
+import { SummaryLookup } from './symbolSummaries.js';
+import { ScopeError, SearchScopes } from './searchScopes.js';
+import { PositionLookup } from './positionLookup.js';
+import { CommentMarkerSource, MarkerQueryError } from './commentMarkers.js';
+import { HTTP_FRAMEWORKS, HttpFramework, HttpRouteSource, RouteQueryError } from './httpRoutes.js';
+import { SqlQuerySource } from './sqlQueries.js';
+import { CONFIG_SOURCES, ConfigKeySource, ConfigSource } from './configKeys.js';
+import { CONCURRENCY_OPS, ConcurrencyOp, ConcurrencySource } from './concurrency.js';
+import { ERROR_PATH_KINDS, ErrorPathKind, ErrorPathSource } from './errorPaths.js';
+import { CoverageSource } from './coverage.js';
+import { TestsForSource } from './goTests.js';
+import { AffectedTestsSource } from './affectedTests.js';
+import { CallGraphDirection, CallGraphSource } from './callGraph.js';
+import { DocsSource } from './docsIndex.js';
+import { COMPLETION_KINDS, CompletionKind, CompletionSource } from './completions.js';
+import { WEB_UI_CONTENT_TYPE, WEB_UI_HTML } from './webUi.js';
+import { IndexGraphQL } from './graphqlSchema.js';
+import { GraphQLRequest } from '../utils/graphql.js';
+import { QueryListener, QueryListenOptions } from './queryListener.js';
+import { GrpcListener } from './grpcListener.js';
+import { GO_TEST_KINDS, GoTestKind } from '../indexer/goIndexer.js';
+import { SQL_OPERATIONS, SqlOperation } from '../utils/sqlParser.js';
+import { QuerySyntaxError } from '../utils/queryLanguage.js';
+import { RankedSymbol } from '../utils/fuzzySearch.js';
+import { OutputTemplate, parseTemplate, TemplateError } from '../utils/outputTemplate.js';
+import { parseSignaturePattern, SignaturePattern } from '../utils/signatures.js';
+import { normalizeKind } from '../utils/symbolKind.js';
+import { IndexingProgress } from '../utils/indexingProgress.js';
+import { IndexerMetrics } from '../profiler/indexerMetrics.js';
+import { PROMETHEUS_CONTENT_TYPE } from '../profiler/metrics.js';
+import { Profile, ProfileKind, ProfilerBusyError, SamplingProfiler, toPprof } from '../profiler/sampling.js';
+import { classifyPath, CodeTagFilter, isTagFilterEmpty, matchesTagFilter, parseCodeTags } from '../utils/codeTags.js';
+import {
+  CursorError,
+  locationKey,
+  Page,
+  PageStream,
+  PageWindow,
+  pageWindow,
+  queryFingerprint,
+  takePage,
+  takePageStream
+} from '../utils/pagination.js';
+import { QueryCache } from '../utils/queryCache.js';
+
+export interface QueryResponse {
+  status: number;
+  body?: unknown;
+  /** Streaming endpoints: items are sent as NDJSON, one per line; strings as is */
+  stream?: Iterable<unknown> | AsyncIterable<unknown>;
+  /** Plain-text responses (/metrics, templates): sent as is */
+  text?: string;
+  /** Binary responses (/debug/pprof profiles): sent as is */
+  binary?: Uint8Array;
+  /** Content type of `text`, `binary` and `stream`; defaults to plain text, octet stream and NDJSON */
+  contentType?: string;
+  /** Extra response headers, e.g. `Location` for redirects */
+  headers?: Record<string, string>;
+  /**
+   * Streams: headers sent after the last item, read once the stream
+   * ended, such as the next cursor of a page; unset values are left out
+   */
+  trailers?: Record<string, () => string | undefined>;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type FileReader = (filePath: string) => Promise<string>;
+
+/** Current indexing progress; injectable for tests */
+export type ProgressSource = () => IndexingProgress;
+
+/** Unsaved editor buffers laid over the index (see POST /overlay) */
+export interface OverlaySource {
+  /** Index content in place of the file; the file it went to and whether that was indexed */
+  overlay(uri: string, content: string): Promise<{ uri: string; shadowsIndexed: boolean }>;
+  /** Drop the overlay of a file, or of every file; returns the number dropped */
+  clear(uri?: string): number;
+}
+
+const DEFAULT_SEARCH_LIMIT = 50;
+const MAX_SEARCH_LIMIT = 1000;
+const MAX_STREAM_LIMIT = 100000;
+/** Search results filtered per step of a stream */
+const FILTER_BATCH_SIZE = 500;
+const DEFAULT_PROGRESS_INTERVAL_MS = 1000;
+const MIN_PROGRESS_INTERVAL_MS = 100;
+const TEXT_CONTENT_TYPE = 'text/plain; charset=utf-8';
+const PPROF_CONTENT_TYPE = 'application/octet-stream';
+/** Seconds /debug/pprof/profile and /debug/pprof/heap sample for by default, as in Go */
+const DEFAULT_PROFILE_SECONDS: Record<ProfileKind, number> = { cpu: 30, heap: 10 };
+const MAX_PROFILE_SECONDS = 300;
+
+/** Where endpoints put their results; `format=template` renders each one */
+const RESULT_KEYS = ['symbols', 'tests', 'mentions', 'links', 'sections', 'definitions', 'references', 'functions', 'outline', 'owners', 'locations', 'markers', 'routes', 'queries', 'keys'];
+
+class BadRequest extends Error {}
+
+/** What /debug/pprof lists */
+const PPROF_INDEX = [
+  '/debug/pprof/profile?seconds=30  CPU profile of the server (gzipped pprof)',
+  '/debug/pprof/heap?seconds=10     sampled allocations still live at the end (gzipped pprof)',
+  ''
+].join('\n');
+
+/** The /debug/pprof endpoints and what they profile */
+const PPROF_ENDPOINTS: Record<string, ProfileKind> = { '/debug/pprof/profile': 'cpu', '/debug/pprof/heap': 'heap' };
+
+/** One query of a batch: a path like `/symbols?q=User`, or the endpoint and its parameters */
+type BatchQuery = string | { id?: unknown; endpoint?: unknown; params?: unknown };
+
+/** A search result; score and popularity come with indexes that rank (MergedIndex) */
+type SearchHit = Pick<RankedSymbol<IndexedSymbol>, 'symbol'> & Partial<Pick<RankedSymbol<IndexedSymbol>, 'score' | 'popularity'>>;
+
+/** A usage: a reference, or a symbol for indexes without reference lookup */
+type ReferenceHit = IndexedReference | IndexedSymbol;
+
+/** Parameters that pick a page rather than the results */
+const PAGE_PARAMS = new Set(['limit', 'offset', 'cursor', 'format', 'template']);
+
+/** Header (trailer, for streams) carrying the cursor of the next page */
+const NEXT_CURSOR_HEADER = 'X-Next-Cursor';
+
+/** Endpoints answered from the index alone, whose bodies can be cached per index generation */
+const CACHED_ENDPOINTS = new Set(['/symbols', '/definition', '/references', '/outline']);
+
+/**
+ * Query Server - read-only REST/JSON API over the index.
+ *
+ * Lets editors and tools query the index over HTTP instead of going
+ * through LSP, and people browse it at `/` (see features/webUi.ts). All
+ * endpoints are GET and return JSON:
+ *
+ *   /, /ui                                   web UI for browsing the index (HTML)
+ *   /health                                  server liveness
+ *   /symbols?q=&limit=&scope=&kind=          fuzzy symbol search (scope: first-party | third-party | a named scope)
+ *   /symbols?signature=&constraint=&generic= search by signature or type parameters (q optional)
+ *   /definition?name= | ?uri=&line=&character= | ?id=   (id: canonical ID)
+ *   /references?name= | ?uri=&line=&character=
+ *   /outline?uri=&tree=                      symbols of one file (tree=true: nested outline)
+ *   /query?q=&limit=&scope= | ?saved=        structured query, e.g. q=kind:func receiver:Person, or a saved one
+ *   /scopes                                  named scopes and saved queries of the config
+ *   /rename-check?name=&newName=             locations, collisions and blast radius of a rename
+ *   /function-metrics?sort=&limit=&minComplexity=&minLoc=&minParameters=&minNesting=
+ *                                            functions ranked by complexity / size metrics
+ *   /owners?owner= | ?uri=                   code per CODEOWNERS owner, or the owners of a file
+ *   /markers?tag=&path=&author=&minAge=&maxAge=&sort=&limit=
+ *                                            TODO / FIXME / ... comment markers with blame
+ *   /routes?method=&route=&framework=&path=&limit=
+ *                                            HTTP routes of Go services and their handlers
+ *   /sql?table=&column=&operation=&access=&path=&limit=
+ *                                            functions running SQL on a table or column
+ *   /env?source=&key=&path=&limit=           environment variables, viper keys and flags read
+ *   /concurrency?op=&package=&target=&path=&limit=
+ *                                            goroutine launches, channel operations and mutex calls
+ *   /errors?kind=&exported=&canPanic=&package=&path=&limit=
+ *                                            panics, recovers, propagated and dropped errors
+ *   /coverage?maxCoverage=&minCoverage=&minRefs=&exported=&path=&limit=
+ *                                            per-function test coverage and reference counts
+ *   /tests-for?symbol=&maxDepth=&kind=       Go tests exercising a symbol, with go test commands
+ *   /affected-tests?since=&maxDepth=&kind=   Go tests affected by the changes since a commit
+ *   /call-graph?name= | ?uri=&line=&character=  (&direction=&depth=)
+ *                                            callers and callees of a function, depth 2 by default
+ *   /docs?q=&language=&path=&limit=          sections of the Markdown and AsciiDoc docs
+ *   /docs/mentions?symbol=&path=&limit=      doc mentions of a symbol, e.g. `Person.Greet`
+ *   /docs/links?uri=                         the symbols a document mentions, resolved
+ *   /graphql?query=&variables=&operationName=, POST /graphql
+ *                                            GraphQL over the index (see below)
+ *   /graphql/schema                          the GraphQL schema (SDL)
+ *   /complete?kind=symbol|file&prefix=&cwd=&limit=&describe=
+ *                                            shell completions from the index, one per line
+ *   /progress                                indexing counts, rate and ETA
+ *   /metrics                                 Prometheus metrics (text format)
+ *   /debug/pprof/profile?seconds=,
+ *   /debug/pprof/heap?seconds=               CPU and heap profiles of the server (pprof, see below)
+ *   /stream/symbols, /stream/references,
+ *   /stream/query                            same queries, streamed as NDJSON
+ *   /stream/progress?interval=               a progress event every interval ms until idle
+ *   POST /batch                              many of the above in one request (see below)
+ *   POST /overlay?uri=, DELETE /overlay?uri= index an unsaved buffer over the file (see below)
+ *
+ * Every endpoint also takes `format=template&template=` to render each
+ * result through a Go text/template (see utils/outputTemplate.ts) and
+ * return plain text, one result per line, like `docker --format`:
+ * `/symbols?q=Greet&format=template&template={{.name}} {{.location.uri}}`.
+ * Results are the endpoint's list (`symbols`, `references`, ...) or the
+ * whole body; streams render each item. Template errors are 400s.
+ *
+ * Symbol, definition and reference queries accept `exclude=` and `only=`
+ * with comma-separated code tags (generated, test, mock, example), e.g.
+ * `/symbols?q=Greet&exclude=tests,generated`. References are filtered by
+ * the tags of the file they occur in.
+ *
+ * `signature=func(*Person)` matches parameter and result types (see
+ * utils/signatures.ts), `constraint=comparable` generic declarations with
+ * such a type parameter, `generic=true` any generic declaration. Without
+ * `q` these scan the whole background index.
+ *
+ * `kind=const,var` keeps symbols of these kinds (`const`, `var` and
+ * `func` stand for constant, variable and function). Constants carry
+ * their `value`, e.g. `/symbols?q=MaxRetries&kind=const` -> `"value": "5"`.
+ *
+ * Name searches are ranked by match quality and popularity (reference
+ * count, recency, exported; see utils/popularity.ts), and each symbol
+ * carries its `score` and `popularity` when the index provides them.
+ *
+ * /query takes the query language of utils/queryLanguage.ts and reports
+ * the `plan` it used (the definitions of a name, the files of a glob, or a
+ * full scan); syntax errors are 400s with the offending position.
+ *
+ * `scope=` on /symbols and /query also takes the scopes of the `search`
+ * config (see features/searchScopes.ts), query language filters results
+ * must match: `/symbols?q=Greet&scope=backend`. `/query?saved=untested-api`
+ * runs a saved query in its scope, or in `scope=` instead. Unknown scopes
+ * and saved queries are 400s; /scopes lists both.
+ *
+ * /symbols, /references and /query are paged (see utils/pagination.ts):
+ * `offset=` skips results, and `cursor=` continues after the previous
+ * page, whose body has the `offset` of its first result and a
+ * `nextCursor` unless it is the last one. The /stream/* variants take the
+ * same parameters and send the next cursor in an `X-Next-Cursor`
+ * trailer, as it is only known once the page has been sent.
+ * References are not paged unless asked: without `limit` they all come.
+ *
+ * With an index that reports its generation (see ISymbolIndex), bodies of
+ * /symbols, /definition, /references and /outline are cached until the
+ * generation changes (see utils/queryCache.ts), so editors repeating hot
+ * lookups are answered without touching the index. Server errors are
+ * not cached.
+ *
+ * /progress reports the running (or last) indexing run: files scanned,
+ * checked, parsed and indexed, bytes, files/s and `etaMs` (see
+ * utils/indexingProgress.ts). /stream/progress lets scripts follow a run
+ * and ends with the first idle event.
+ *
+ * /metrics serves index size, query latency (these endpoints and LSP
+ * requests), re-index durations, file watcher and parse error counters
+ * for Prometheus (see profiler/indexerMetrics.ts). Every request to a
+ * known endpoint is timed.
+ *
+ * With a profiler set (setProfiler()), /debug/pprof/profile samples the
+ * server's CPU for `seconds` (default 30) and /debug/pprof/heap its
+ * allocations (default 10), answering gzipped pprof as Go's net/http/pprof
+ * does (see profiler/sampling.ts): `go tool pprof
+ * http://localhost:7070/debug/pprof/profile?seconds=20` while a re-index
+ * or a query load runs. One profile of each kind is taken at a time;
+ * another request meanwhile is a 409. /debug/pprof lists the profiles.
+ *
+ * POST /batch runs queries one after another against the loaded index, so
+ * scripts making thousands of lookups pay for one request, not thousands.
+ * The body is a JSON array or NDJSON, one query per line; a query is a
+ * path (`"/definition?name=Load"`, quoted or not) or an object
+ * `{"id": 7, "endpoint": "/references", "params": {"name": "Load"}}`.
+ * The reply is NDJSON with one line per query, in order, as each one
+ * finishes: `{"index": 0, "id": 7, "status": 200, "body": {...}}` (`text`
+ * instead of `body` for plain-text results). A failing query gets its own
+ * status and error; streaming endpoints and profiles cannot be batched.
+ *
+ * `/outline?tree=true` returns the file as a tree (see features/outline.ts):
+ * members under their types, including Go methods declared outside them,
+ * with `range` and `selectionRange` for outline panes and breadcrumbs.
+ *
+ * Symbols carry the `owners` of their file per CODEOWNERS (see
+ * utils/codeOwners.ts); `/query?q=exported:true owner:@platform-team`
+ * lists an owner's API.
+ * With symbol summaries on, complex exported symbols also carry a
+ * one-line `summary` (see features/symbolSummaries.ts).
+ *
+ * `/definition?uri=&line=&character=` resolves the symbol recorded at the
+ * position in an indexed file (see features/positionLookup.ts): locals,
+ * `pkg.Name` and imported names go to the right declaration, and the
+ * response has its `role` (definition or reference) and name `range`.
+ * Other files fall back to a lookup of the word at the position.
+ *
+ * /markers lists comment markers (see features/commentMarkers.ts) with
+ * the author and age of their line from git blame: `tag=FIXME,HACK`,
+ * `path=` a file or folder, `author=` a marker owner or blame author,
+ * `minAge=`/`maxAge=` in days and `sort=age` (oldest first). The response
+ * counts markers per tag (`byTag`) for tech-debt dashboards.
+ *
+ * /routes lists the HTTP routes registered with net/http, chi, gin or echo
+ * (see features/httpRoutes.ts), each with the `definition` of its handler:
+ * `method=GET` (routes for any method included), `route=/api/v1` a path
+ * prefix, `framework=chi` and `path=` a file or folder.
+ *
+ * /sql lists the SQL statements Go code passes to database/sql, sqlx and
+ * pgx (see features/sqlQueries.ts) and the `functions` running them:
+ * `table=users` (`public.users` included), `column=email` or
+ * `column=users.email`, `operation=update`, `access=write` and `path=`.
+ * Templates render the functions.
+ *
+ * /env lists the configuration keys the Go code reads (see
+ * features/configKeys.ts), each with its `uses`, `types` and `defaults`:
+ * `source=env|viper|flag`, `key=` a substring and `path=`, e.g.
+ * `/env?source=env&format=template&template={{.key}} {{.defaults}}`.
+ *
+ * /concurrency lists where Go code starts goroutines, uses channels and
+ * locks mutexes (see features/concurrency.ts), with the `functions` they
+ * are in: `op=go|make|send|receive|close|lock|unlock`, `package=worker`
+ * (or a folder suffix, `internal/worker`), `target=jobs` and `path=`.
+ *
+ * /errors lists where Go code panics, recovers, passes errors on and
+ * drops them (see features/errorPaths.ts), with `functions` telling which
+ * can panic: `kind=panic|recover|propagate|discard`, `exported=true`,
+ * `canPanic=true`, `package=` and `path=`, e.g.
+ * `/errors?exported=true&canPanic=true` for the exported API that panics.
+ *
+ * /coverage lists Go functions with their statement coverage from the
+ * configured coverage profiles (see features/coverage.ts) and how often
+ * they are referenced, most referenced first: `maxCoverage=0&minRefs=11&exported=true`
+ * are the exported functions no test runs that more than ten places use.
+ * /query takes the same as `coverage:0 refs:>10`.
+ *
+ * /tests-for lists the Go test functions exercising a symbol directly or
+ * through up to `maxDepth` functions (see features/goTests.ts), with the
+ * `go test` commands running them per package: `symbol=user.Person`
+ * (also `Person.Greet`, `internal/user.Person`) and `kind=test,benchmark`.
+ * Templates render the tests, e.g. `/tests-for?symbol=Store.Get&format=template&template={{.name}}`.
+ *
+ * /affected-tests lists the Go tests the changes since a commit can break
+ * (see features/affectedTests.ts), for CI to run only those: `since=HEAD~1`
+ * or `since=origin/main`, with `maxDepth` (default: unlimited) and `kind`.
+ * `commands` holds the `go test` invocations to run (`jq -r '.commands[]'`);
+ * templates render the tests.
+ *
+ * /docs searches the sections of the Markdown and AsciiDoc files (see
+ * features/docsIndex.ts) for every word of `q`, headings first; with
+ * `language=go` only sections with a Go code block. /docs/mentions lists
+ * the inline code (`` `Person.Greet` ``) that resolves to a symbol, for
+ * "docs mentioning this"; /docs/links resolves the mentions of one file.
+ *
+ * /graphql answers GraphQL queries over the index (see
+ * features/graphqlSchema.ts), so a client gets nested data in one round
+ * trip: a symbol, its references, the functions enclosing them and the
+ * owners of those, e.g. `{ definitions(name: "Get") { references(calls:
+ * true) { enclosing { qualifiedName owners } } } }`. POST takes a JSON body
+ * (`query`, `variables`, `operationName`) or the bare query. Documents
+ * that do not parse or validate are 400s; resolver errors come back next
+ * to the data, as GraphQL clients expect.
+ *
+ * /complete answers shell completion scripts (see features/completions.ts)
+ * in plain text, one candidate per line: symbol names for `Per` or
+ * members for `Person.Gr`, and indexed paths one segment at a time,
+ * relative to `cwd`. With `describe=true` symbols carry their kind after
+ * a tab, as fish shows it.
+ *
+ * POST /overlay indexes the request body, an editor's unsaved buffer, in
+ * place of the file at `uri` until DELETE /overlay drops it (without
+ * `uri`: every overlay), so queries see the dirty file while saved
+ * indexes keep what is on disk. `uri` may be a path relative to the
+ * workspace that does not exist yet. The reply is the buffer's outline:
+ * `curl --data-binary @- 'localhost:7070/overlay?uri=pkg/user/person.go' < buffer`.
+ *
+ * Symbols carry their raw doc comment as `doc`; /definition also returns
+ * it rendered as Markdown (`documentation`), with links to the symbols it
+ * mentions.
+ *
+ * The streaming endpoints write one JSON object per line and respect
+ * socket backpressure, so huge result sets (every reference to a common
+ * function) never have to be serialized as one array. /stream/references
+ * sends references as the index reads them (see streamReferencesByName
+ * of ISymbolIndex); /stream/symbols filters and sends ranked results as
+ * they are written. Their messages match server/proto/smart_indexer.proto,
+ * which startGrpc serves over gRPC from the same endpoints.
+ *
+ * `uri` accepts a file path or a file:// URI. Lines and characters are 0-based;
+ * ranges also carry UTF-8 byte offsets (`startOffset`, `endOffset`) when indexed.
+ * The server binds to localhost by default. Before exposing it, start it
+ * with `auth` (bearer tokens or client certificates) and `tls`; the health
+ * check and the web UI page stay public (see features/queryListener.ts).
+ * QueryServerHost serves several indexes from one process.
+ */
+export class QueryServer {
+  private listener: QueryListener | null = null;
+  private grpcListener: GrpcListener | null = null;
+  private cache = new QueryCache();
+  private profiler: SamplingProfiler | undefined;
+  private summaries: SummaryLookup | undefined;
+  private scopes = new SearchScopes();
+
+  /** Symbol JSON with the owners of its file, when CODEOWNERS is known, and its summary */
+  private symbolJson = (symbol: IndexedSymbol) => {
+    const json = toSymbolJson(symbol);
+    const owners = this.ownership?.ownersOf(symbol.location.uri) ?? [];
+    const summary = this.summaries?.summaryOf(symbol.id);
+    return {
+      ...json,
+      ...(owners.length > 0 && { owners }),
+      ...(summary && { summary })
+    };
+  };
+
+  constructor(
+    private index: ISymbolIndex,
+    private logger: ILogger = new NullLogger(),
+    private readFile: FileReader = filePath => fs.promises.readFile(filePath, 'utf-8'),
+    private signatureSearch?: SignatureSearch,
+    private structuredQuery?: StructuredQuery,
+    private progressSource?: ProgressSource,
+    private metrics?: IndexerMetrics,
+    private codeMetrics?: CodeMetrics,
+    private ownership?: Ownership,
+    private positionLookup?: PositionLookup,
+    private commentMarkers?: CommentMarkerSource,
+    private httpRoutes?: HttpRouteSource,
+    private sqlQueries?: SqlQuerySource,
+    private configKeys?: ConfigKeySource,
+    private concurrency?: ConcurrencySource,
+    private errorPaths?: ErrorPathSource,
+    private coverage?: CoverageSource,
+    private testsFor?: TestsForSource,
+    private affectedTests?: AffectedTestsSource,
+    private callGraph?: CallGraphSource,
+    private docs?: DocsSource,
+    private overlays?: OverlaySource,
+    private completions?: CompletionSource
+  ) {}
+
+  /**
+   * Start listening. Port 0 picks a free port; the bound address is returned.
+   * With `options.tls` the server speaks HTTPS, with `options.auth` it
+   * asks for credentials (see features/queryListener.ts).
+   */
+  async start(port: number, host: string = '127.0.0.1', options: QueryListenOptions = {}): Promise<AddressInfo> {
+    if (!this.listener) {
+      this.listener = new QueryListener(this, this.logger, 'QueryServer');
+    }
+    return this.listener.start(port, host, options);
+  }
+
+  /**
+   * Start listening on a unix socket or named pipe, e.g. as a daemon
+   * (see features/daemon.ts).
+   */
+  async startOnSocket(socketPath: string, options: QueryListenOptions = {}): Promise<void> {
+    if (!this.listener) {
+      this.listener = new QueryListener(this, this.logger, 'QueryServer');
+    }
+    await this.listener.startOnSocket(socketPath, options);
+  }
+
+  /**
+   * Also serve the API of server/proto/smart_indexer.proto over gRPC on
+   * its own port, with the same options (see features/grpcListener.ts).
+   */
+  async startGrpc(port: number, host: string = '127.0.0.1', options: QueryListenOptions = {}): Promise<AddressInfo> {
+    if (!this.grpcListener) {
+      this.grpcListener = new GrpcListener(this, this.logger, 'QueryServer');
+    }
+    return this.grpcListener.start(port, host, options);
+  }
+
+  /** Stop listening, over HTTP and gRPC */
+  async stop(): Promise<void> {
+    await Promise.all([this.listener?.stop(), this.grpcListener?.stop()]);
+  }
+
+  /** Stop only the gRPC listener */
+  async stopGrpc(): Promise<void> {
+    await this.grpcListener?.stop();
+  }
+
+  isRunning(): boolean {
+    return this.listener?.isRunning() ?? false;
+  }
+
+  address(): AddressInfo | null {
+    return this.listener?.address() ?? null;
+  }
+
+  /** The bound gRPC port and address; null when not serving gRPC */
+  grpcAddress(): AddressInfo | null {
+    return this.grpcListener?.address() ?? null;
+  }
+
+  socketPath(): string | null {
+    return this.listener?.socketPath() ?? null;
+  }
+
+  /** Whether the server speaks HTTPS */
+  isSecure(): boolean {
+    return this.listener?.isSecure() ?? false;
+  }
+
+  /** The health check and the web UI page need no credentials; the UI asks for a token */
+  isPublic(pathname: string): boolean {
+    return pathname === '/health' || pathname === '/' || pathname === '/ui';
+  }
+
+  /**
+   * Route a request. Separate from the HTTP layer so it can be tested directly.
+   */
+  async handle(method: string, rawUrl: string, body: string = ''): Promise<QueryResponse> {
+    const url = new URL(rawUrl, 'http://localhost');
+    const endpoint = url.pathname.replace(/\/+$/, '') || '/';
+    if (endpoint === '/batch') {
+      return method === 'POST'
+        ? this.batch(body)
+        : { status: 405, body: { error: 'Use POST for /batch' } };
+    }
+    if (endpoint === '/graphql') {
+      return method === 'GET' || method === 'POST'
+        ? this.graphql(method, url.searchParams, body)
+        : { status: 405, body: { error: `Method ${method} not allowed` } };
+    }
+    if (endpoint === '/overlay') {
+      return method === 'POST' || method === 'DELETE'
+        ? this.overlay(method, url.searchParams, body)
+        : { status: 405, body: { error: 'Use POST or DELETE for /overlay' } };
+    }
+    if (method !== 'GET') {
+      return { status: 405, body: { error: `Method ${method} not allowed` } };
+    }
+
+    let template: OutputTemplate | undefined;
+    try {
+      template = parseOutputTemplate(url.searchParams);
+    } catch (error) {
+      return { status: 400, body: { error: error instanceof Error ? error.message : String(error) } };
+    }
+
+    const start = performance.now();
+    const response = await this.cachedRoute(endpoint, url.searchParams);
+    if (response.status !== 404) {
+      // Streams are timed until the first result is ready, not until sent
+      this.metrics?.observeQuery('http', endpoint, performance.now() - start);
+    }
+    return template ? renderResponse(response, template) : response;
+  }
+
+  /** Results kept in the cache (0: no caching) */
+  setCacheSize(entries: number): void {
+    this.cache.resize(entries);
+  }
+
+  /**
+   * Add the summaries of symbols to symbol JSON (see symbolSummaries.ts).
+   * Cached results are dropped, so call it again when summaries change.
+   */
+  setSummaries(summaries: SummaryLookup | undefined): void {
+    this.summaries = summaries;
+    this.cache.clear();
+  }
+
+  /**
+   * Named scopes and saved queries to serve (see searchScopes.ts). Cached
+   * results are dropped, so call it again when the config changes.
+   */
+  setScopes(scopes: SearchScopes): void {
+    this.scopes = scopes;
+    this.cache.clear();
+  }
+
+  /** Serve /debug/pprof profiles of this process (undefined: do not) */
+  setProfiler(profiler: SamplingProfiler | undefined): void {
+    this.profiler = profiler;
+  }
+
+  private async cachedRoute(endpoint: string, params: URLSearchParams): Promise<QueryResponse> {
+    const generation = this.index.getGeneration?.();
+    if (generation === undefined || !CACHED_ENDPOINTS.has(endpoint)) {
+      return this.route(endpoint, params);
+    }
+    // Templates render the cached body, so they share its entry
+    const key = endpoint + '?' + [...params]
+      .filter(([name]) => name !== 'format' && name !== 'template')
+      .sort(([a], [b]) => a < b ? -1 : a > b ? 1 : 0)
+      .map(([name, value]) => `${encodeURIComponent(name)}=${encodeURIComponent(value)}`)
+      .join('&');
+    return this.cache.get(key, generation, () => this.route(endpoint, params), {
+      onLookup: hit => this.metrics?.countQueryCacheLookup('http', hit),
+      keep: response => response.status < 500
+    });
+  }
+
+  private async route(endpoint: string, params: URLSearchParams): Promise<QueryResponse> {
+    try {
+      switch (endpoint) {
+        case '/':
+        case '/ui':
+          return { status: 200, text: WEB_UI_HTML, contentType: WEB_UI_CONTENT_TYPE };
+        case '/health':
+          return { status: 200, body: { status: 'ok' } };
+        case '/symbols':
+          return { status: 200, body: await this.searchSymbols(params) };
+        case '/definition':
+          return { status: 200, body: await this.findDefinitions(params) };
+        case '/references':
+          return { status: 200, body: await this.findReferences(params) };
+        case '/outline':
+          return { status: 200, body: await this.getOutline(params) };
+        case '/query':
+          return { status: 200, body: await this.runQuery(params) };
+        case '/scopes':
+          return { status: 200, body: { scopes: this.scopes.scopes(), queries: this.scopes.savedQueries() } };
+        case '/rename-check':
+          return { status: 200, body: await this.checkRename(params) };
+        case '/function-metrics':
+          return { status: 200, body: await this.getFunctionMetrics(params) };
+        case '/owners':
+          return { status: 200, body: await this.getOwners(params) };
+        case '/markers':
+          return { status: 200, body: await this.getMarkers(params) };
+        case '/routes':
+          return { status: 200, body: await this.getRoutes(params) };
+        case '/sql':
+          return { status: 200, body: await this.getSqlQueries(params) };
+        case '/env':
+          return { status: 200, body: await this.getConfigKeys(params) };
+        case '/concurrency':
+          return { status: 200, body: await this.getConcurrency(params) };
+        case '/errors':
+          return { status: 200, body: await this.getErrorPaths(params) };
+        case '/coverage':
+          return { status: 200, body: await this.getCoverage(params) };
+        case '/tests-for':
+          return { status: 200, body: await this.getTestsFor(params) };
+        case '/affected-tests':
+          return { status: 200, body: await this.getAffectedTests(params) };
+        case '/call-graph':
+          return { status: 200, body: await this.getCallGraph(params) };
+        case '/docs':
+          return { status: 200, body: await this.searchDocs(params) };
+        case '/docs/mentions':
+          return { status: 200, body: await this.getDocMentions(params) };
+        case '/docs/links':
+          return { status: 200, body: await this.requireDocs().links(requireUri(params)) };
+        case '/complete':
+          return { status: 200, text: (await this.complete(params)).map(line => line + '\n').join('') };
+        case '/graphql/schema':
+          return { status: 200, text: this.indexGraphQL().schema() };
+        case '/progress':
+          return { status: 200, body: this.requireProgressSource()() };
+        case '/metrics':
+          if (!this.metrics) {
+            throw new BadRequest('Metrics are not enabled');
+          }
+          return { status: 200, text: this.metrics.render(), contentType: PROMETHEUS_CONTENT_TYPE };
+        case '/debug/pprof':
+          if (!this.profiler) {
+            throw new BadRequest('Profiling is not enabled');
+          }
+          return { status: 200, text: PPROF_INDEX };
+        case '/debug/pprof/profile':
+        case '/debug/pprof/heap':
+          return await this.captureProfile(PPROF_ENDPOINTS[endpoint], params);
+        case '/stream/symbols':
+          return await this.streamSymbols(params);
+        case '/stream/references':
+          return await this.streamReferences(params);
+        case '/stream/query':
+          return await this.streamQuery(params);
+        case '/stream/progress':
+          return { status: 200, stream: this.streamProgress(params) };
+        default:
+          return { status: 404, body: { error: `Unknown endpoint: ${endpoint}` } };
+      }
+    } catch (error) {
+      if (error instanceof BadRequest || error instanceof ScopeError) {
+        return { status: 400, body: { error: error.message } };
+      }
+      this.logger.error(`[QueryServer] Error handling ${endpoint}: ${error}`);
+      return { status: 500, body: { error: error instanceof Error ? error.message : String(error) } };
+    }
+  }
+
+  /**
+   * GET takes `query`, `variables` (JSON) and `operationName` parameters;
+   * POST a JSON body with the same keys, or the query itself.
+   */
+  private async graphql(method: string, params: URLSearchParams, body: string): Promise<QueryResponse> {
+    let request: GraphQLRequest;
+    try {
+      request = method === 'POST' ? parseGraphQLBody(body) : {
+        query: params.get('query') ?? '',
+        variables: params.has('variables') ? JSON.parse(params.get('variables')!) : undefined,
+        operationName: params.get('operationName')
+      };
+    } catch (error) {
+      return { status: 400, body: { errors: [{ message: error instanceof Error ? error.message : String(error) }] } };
+    }
+    if (!request.query) {
+      return { status: 400, body: { errors: [{ message: 'Missing GraphQL "query"' }] } };
+    }
+
+    const start = performance.now();
+    try {
+      const result = await this.indexGraphQL().execute(request);
+      this.metrics?.observeQuery('http', '/graphql', performance.now() - start);
+      // Documents that do not parse or validate run nothing
+      return { status: result.data === undefined ? 400 : 200, body: result };
+    } catch (error) {
+      this.logger.error(`[QueryServer] Error handling /graphql: ${error}`);
+      return { status: 500, body: { errors: [{ message: error instanceof Error ? error.message : String(error) }] } };
+    }
+  }
+
+  /**
+   * POST lays the body over the file at `uri` and returns its outline;
+   * DELETE drops the overlay of `uri`, or all of them.
+   */
+  private async overlay(method: string, params: URLSearchParams, body: string): Promise<QueryResponse> {
+    const start = performance.now();
+    try {
+      if (!this.overlays) {
+        throw new BadRequest('Overlays are not supported by this server');
+      }
+      if (method === 'DELETE') {
+        const uri = params.get('uri');
+        return { status: 200, body: { cleared: this.overlays.clear(uri ? requireUri(params) : undefined) } };
+      }
+      const { uri, shadowsIndexed } = await this.overlays.overlay(requireUri(params), body);
+      const symbols = await this.index.getFileSymbols(uri);
+      this.metrics?.observeQuery('http', '/overlay', performance.now() - start);
+      return {
+        status: 200,
+        body: {
+          uri,
+          shadowsIndexed,
+          symbols: symbols.filter(s => s.isDefinition !== false).map(this.symbolJson),
+          outline: buildOutline(symbols, { groupByContainer: true })
+        }
+      };
+    } catch (error) {
+      if (error instanceof BadRequest) {
+        return { status: 400, body: { error: error.message } };
+      }
+      this.logger.error(`[QueryServer] Error handling /overlay: ${error}`);
+      return { status: 500, body: { error: error instanceof Error ? error.message : String(error) } };
+    }
+  }
+
+  private indexGraphQL(): IndexGraphQL {
+    return new IndexGraphQL(this.index, filePath => this.ownership?.ownersOf(filePath) ?? []);
+  }
+
+  private batch(body: string): QueryResponse {
+    let queries: BatchQuery[];
+    try {
+      queries = parseBatch(body);
+    } catch (error) {
+      return { status: 400, body: { error: error instanceof Error ? error.message : String(error) } };
+    }
+    this.logger.info(`[QueryServer] Batch of ${queries.length} queries`);
+    return { status: 200, stream: this.runBatch(queries) };
+  }
+
+  private async *runBatch(queries: BatchQuery[]): AsyncIterable<unknown> {
+    for (let index = 0; index < queries.length; index++) {
+      const query = queries[index];
+      const id = typeof query === 'object' && query.id !== undefined ? { id: query.id } : {};
+      let response: QueryResponse;
+      try {
+        const url = batchUrl(query);
+        const endpoint = new URL(url, 'http://localhost').pathname.replace(/\/+$/, '');
+        response = endpoint.startsWith('/stream/') || endpoint.startsWith('/debug/') || endpoint === '/batch'
+          ? { status: 400, body: { error: 'Streaming endpoints, profiles and /batch cannot be batched' } }
+          : await this.handle('GET', url);
+      } catch (error) {
+        response = { status: 400, body: { error: error instanceof Error ? error.message : String(error) } };
+      }
+      yield response.text !== undefined
+        ? { index, ...id, status: response.status, text: response.text }
+        : { index, ...id, status: response.status, body: response.body };
+    }
+  }
+
+  private async searchSymbols(params: URLSearchParams) {
+    const query = hasShapeCriteria(params) ? optionalQuery(params) : requireQuery(params);
+    const window = parsePageWindow('symbols', params, DEFAULT_SEARCH_LIMIT, MAX_SEARCH_LIMIT);
+    const page = takePage(await this.searchInScope(query, window.fetch, params), window, hit => hit.symbol.id);
+    return { query, ...pageInfo(page), symbols: page.items.map(this.hitJson) };
+  }
+
+  private async streamSymbols(params: URLSearchParams): Promise<QueryResponse> {
+    const query = hasShapeCriteria(params) ? optionalQuery(params) : requireQuery(params);
+    const window = parsePageWindow('symbols', params, MAX_STREAM_LIMIT, MAX_STREAM_LIMIT);
+    const batches = await this.hitsInScope(query, window.fetch, params);
+    return streamPage(takePageStream(batches, window, hit => hit.symbol.id), this.hitJson);
+  }
+
+  private hitJson = (hit: SearchHit) => ({
+    ...this.symbolJson(hit.symbol),
+    ...(hit.score !== undefined && { score: hit.score }),
+    ...(hit.popularity && { popularity: hit.popularity })
+  });
+
+  /**
+   * Search, keeping only first- or third-party symbols or those of a
+   * named scope if `scope` is given, symbols of the `kind`s given and
+   * symbols passing the tag filter. Filtered searches over-fetch so
+   * filtering does not starve the result. Name searches keep the ranking
+   * scores if the index has them.
+   */
+  private async searchInScope(
+    query: string,
+    limit: number,
+    params: URLSearchParams
+  ): Promise<SearchHit[]> {
+    const inScope: SearchHit[] = [];
+    for await (const batch of await this.hitsInScope(query, limit, params)) {
+      inScope.push(...batch);
+    }
+    return inScope;
+  }
+
+  /**
+   * searchInScope in batches. Bad parameters throw before anything is
+   * streamed, and the search itself runs then, as ranking needs every
+   * candidate; filtering (named scopes load reference counts and owners)
+   * runs batch by batch as they are consumed.
+   */
+  private async hitsInScope(
+    query: string,
+    limit: number,
+    params: URLSearchParams
+  ): Promise<AsyncIterable<SearchHit[]>> {
+    const scope = params.get('scope') || null;
+    const party = scope === 'first-party' || scope === 'third-party' ? scope : null;
+    const scopeFilter = scope && !party ? this.scopes.filter(scope) : undefined;
+    if (scopeFilter && !this.structuredQuery) {
+      throw new BadRequest('Named scopes need the background index');
+    }
+    const tagFilter = parseTagFilter(params);
+    const kinds = parseKinds(params);
+    const filtered = scope !== null || kinds !== undefined || !isTagFilterEmpty(tagFilter);
+    const fetchLimit = filtered ? Math.max(limit, MAX_SEARCH_LIMIT) : limit;
+
+    const hits: SearchHit[] = hasShapeCriteria(params)
+      ? (await this.searchByShape(query, fetchLimit, params)).map(symbol => ({ symbol }))
+      : this.index.searchSymbolsRanked
+        ? await this.index.searchSymbolsRanked(query, fetchLimit)
+        : (await this.index.searchSymbols(query, fetchLimit)).map(symbol => ({ symbol }));
+    const structuredQuery = this.structuredQuery;
+    return (async function* () {
+      let kept = 0;
+      for (let i = 0; i < hits.length && kept < limit; i += FILTER_BATCH_SIZE) {
+        const batch = hits.slice(i, i + FILTER_BATCH_SIZE);
+        const inFilter = scopeFilter === undefined
+          ? undefined
+          : new Set(await structuredQuery!.filter(batch.map(hit => hit.symbol), scopeFilter));
+        const inScope = batch.filter(({ symbol: s }) =>
+          (!party || isThirdParty(s) === (party === 'third-party')) &&
+          (!inFilter || inFilter.has(s)) &&
+          (!kinds || kinds.has(s.kind)) &&
+          matchesTagFilter(s.tags, tagFilter)
+        ).slice(0, limit - kept);
+        kept += inScope.length;
+        yield inScope;
+      }
+    })();
+  }
+
+  /**
+   * Symbols matching the signature/constraint/generic parameters, scanning
+   * the background index when available, otherwise filtering a name search.
+   */
+  private async searchByShape(query: string, limit: number, params: URLSearchParams): Promise<IndexedSymbol[]> {
+    const pattern = parseSignatureParam(params);
+    const criteria = {
+      constraint: params.get('constraint') || undefined,
+      generic: params.get('generic') === 'true' || params.get('generic') === '1'
+    };
+    if (!pattern && !criteria.constraint && !criteria.generic) {
+      throw new BadRequest('Parameter "signature" or "constraint" must not be empty, or use generic=true');
+    }
+    if (this.signatureSearch) {
+      const result = await this.signatureSearch.search({
+        ...criteria,
+        signature: params.get('signature') || undefined,
+        name: query || undefined,
+        limit
+      });
+      return result.symbols;
+    }
+    if (!query) {
+      throw new BadRequest('Missing query parameter "q" (signature search without a name needs the background index)');
+    }
+    const candidates = await this.index.searchSymbols(query, MAX_SEARCH_LIMIT);
+    return candidates.filter(s => s.isDefinition !== false && matchesCriteria(s, pattern, criteria)).slice(0, limit);
+  }
+
+  private async runQuery(params: URLSearchParams) {
+    const query = params.has('saved') ? this.scopes.savedQuery(params.get('saved')!).query : requireQuery(params);
+    const window = parsePageWindow('query', params, DEFAULT_SEARCH_LIMIT, MAX_SEARCH_LIMIT);
+    const { symbols, plan } = await this.executeQuery(params, window.fetch);
+    const page = takePage(symbols, window, symbol => symbol.id);
+    return { query, plan, truncated: page.nextCursor !== undefined, ...pageInfo(page), symbols: page.items.map(this.symbolJson) };
+  }
+
+  private async streamQuery(params: URLSearchParams): Promise<QueryResponse> {
+    const window = parsePageWindow('query', params, MAX_STREAM_LIMIT, MAX_STREAM_LIMIT);
+    const { symbols } = await this.executeQuery(params, window.fetch);
+    return streamPage(takePageStream([symbols], window, symbol => symbol.id), this.symbolJson);
+  }
+
+  /** The `q` query, or the `saved` one, in the named `scope` if given */
+  private async executeQuery(params: URLSearchParams, limit: number) {
+    if (!this.structuredQuery) {
+      throw new BadRequest('Structured queries need the background index');
+    }
+    const scope = params.get('scope') || undefined;
+    try {
+      const query = params.has('saved')
+        ? this.scopes.resolve(params.get('saved')!, scope)
+        : this.scopes.restrict(requireQuery(params), scope);
+      return await this.structuredQuery.run(query, { limit });
+    } catch (error) {
+      if (error instanceof QuerySyntaxError) {
+        throw new BadRequest(error.message);
+      }
+      throw error;
+    }
+  }
+
+  private requireProgressSource(): ProgressSource {
+    if (!this.progressSource) {
+      throw new BadRequest('Indexing progress needs the background index');
+    }
+    return this.progressSource;
+  }
+
+  private streamProgress(params: URLSearchParams): AsyncIterable<IndexingProgress> {
+    const source = this.requireProgressSource();
+    const interval = Math.max(parseInteger(params, 'interval') || DEFAULT_PROGRESS_INTERVAL_MS, MIN_PROGRESS_INTERVAL_MS);
+    return (async function* () {
+      for (;;) {
+        const progress = source();
+        yield progress;
+        if (progress.phase === 'idle') {
+          return;
+        }
+        await new Promise(resolve => setTimeout(resolve, interval));
+      }
+    })();
+  }
+
+  /**
+   * Profile the process for `seconds` and answer gzipped pprof; fractions
+   * are allowed (`seconds=0.5`), unlike Go.
+   */
+  private async captureProfile(kind: ProfileKind, params: URLSearchParams): Promise<QueryResponse> {
+    if (!this.profiler) {
+      throw new BadRequest('Profiling is not enabled');
+    }
+    const raw = params.get('seconds');
+    const seconds = raw === null ? DEFAULT_PROFILE_SECONDS[kind] : Number(raw);
+    if (!(seconds > 0 && seconds <= MAX_PROFILE_SECONDS)) {
+      throw new BadRequest(`Parameter "seconds" must be a number above 0 and at most ${MAX_PROFILE_SECONDS}`);
+    }
+    let profile: Profile;
+    try {
+      profile = await this.profiler.capture(kind, seconds * 1000);
+    } catch (error) {
+      if (error instanceof ProfilerBusyError) {
+        return { status: 409, body: { error: error.message } };
+      }
+      throw error;
+    }
+    this.logger.info(`[QueryServer] Served a ${seconds}s ${kind} profile`);
+    return {
+      status: 200,
+      binary: toPprof(profile),
+      contentType: PPROF_CONTENT_TYPE,
+      headers: { 'Content-Disposition': `attachment; filename="${kind === 'cpu' ? 'profile' : 'heap'}.pb.gz"` }
+    };
+  }
+
+  private async findDefinitions(params: URLSearchParams) {
+    const located = await this.lookupPosition(params);
+    if (located) {
+      const tagFilter = parseTagFilter(params);
+      const definitions = located.definitions.filter(s => matchesTagFilter(s.tags, tagFilter));
+      return {
+        name: located.name,
+        role: located.role,
+        range: located.range,
+        definitions: await this.renderDefinitions(definitions)
+      };
+    }
+
+    const canonicalId = params.get('id');
+    const { name, uri } = canonicalId ? { name: canonicalIdName(canonicalId), uri: undefined } : await this.resolveName(params);
+    const tagFilter = parseTagFilter(params);
+    let definitions = (await this.index.findDefinitions(name))
+      .filter(s => s.isDefinition !== false && matchesTagFilter(s.tags, tagFilter))
+      .filter(s => !canonicalId || s.canonicalId === canonicalId);
+
+    // Prefer definitions in the file the position came from
+    if (uri && definitions.length > 1) {
+      const local = definitions.filter(s => s.location.uri === uri);
+      if (local.length > 0) {
+        definitions = local;
+      }
+    }
+    return { name, definitions: await this.renderDefinitions(definitions) };
+  }
+
+  /**
+   * The symbol at uri/line/character as the index recorded it, when the
+   * file is indexed; null otherwise, and for name and id queries.
+   */
+  private async lookupPosition(params: URLSearchParams) {
+    const line = parseInteger(params, 'line');
+    const character = parseInteger(params, 'character');
+    if (!this.positionLookup || params.has('name') || params.has('id') || line === undefined || character === undefined) {
+      return null;
+    }
+    return this.positionLookup.lookup(requireUri(params), line, character);
+  }
+
+  private async renderDefinitions(definitions: IndexedSymbol[]) {
+    const docs = new SymbolDocs(this.index);
+    return Promise.all(definitions.map(async s => {
+      const documentation = await docs.render(s);
+      return { ...this.symbolJson(s), ...(documentation && { documentation }) };
+    }));
+  }
+
+  private async findReferences(params: URLSearchParams) {
+    const { name } = await this.resolveName(params);
+    const window = parsePageWindow('references', params, Infinity, Infinity);
+    const page = takePage(await this.loadReferences(name, parseTagFilter(params)), window, locationKey);
+    return { name, ...pageInfo(page), references: page.items.map(this.referenceJson) };
+  }
+
+  private async streamReferences(params: URLSearchParams): Promise<QueryResponse> {
+    const { name } = await this.resolveName(params);
+    const window = parsePageWindow('references', params, Infinity, Infinity);
+    const tagFilter = parseTagFilter(params);
+    const batches = this.index.streamReferencesByName
+      ? this.filterReferences(this.index.streamReferencesByName(name), tagFilter)
+      : [await this.loadReferences(name, tagFilter)];
+    return streamPage(takePageStream(batches, window, locationKey), this.referenceJson);
+  }
+
+  private async loadReferences(name: string, tagFilter: CodeTagFilter): Promise<ReferenceHit[]> {
+    if (this.index.findReferencesByName) {
+      const references = await this.index.findReferencesByName(name);
+      if (isTagFilterEmpty(tagFilter)) {
+        return references;
+      }
+      const fileTags = await this.loadFileTags(references.map(ref => ref.location.uri));
+      return references.filter(ref => matchesTagFilter(fileTags.get(ref.location.uri), tagFilter));
+    }
+    return (await this.index.findReferences(name)).filter(s => matchesTagFilter(s.tags, tagFilter));
+  }
+
+  /** Batches of references passing the tag filter, with file tags looked up once */
+  private async *filterReferences(
+    batches: AsyncIterable<IndexedReference[]>,
+    tagFilter: CodeTagFilter
+  ): AsyncIterable<IndexedReference[]> {
+    const fileTags = new Map<string, CodeTag[]>();
+    for await (const batch of batches) {
+      if (isTagFilterEmpty(tagFilter)) {
+        yield batch;
+        continue;
+      }
+      for (const [uri, tags] of await this.loadFileTags(batch.map(ref => ref.location.uri).filter(uri => !fileTags.has(uri)))) {
+        fileTags.set(uri, tags);
+      }
+      yield batch.filter(ref => matchesTagFilter(fileTags.get(ref.location.uri), tagFilter));
+    }
+  }
+
+  private referenceJson = (hit: ReferenceHit) => 'symbolName' in hit ? toReferenceJson(hit) : this.symbolJson(hit);
+
+  /**
+   * Tags of each distinct file, from the index when it tracks them,
+   * otherwise from the path alone.
+   */
+  private async loadFileTags(uris: string[]): Promise<Map<string, CodeTag[]>> {
+    const tags = new Map<string, CodeTag[]>();
+    for (const uri of uris) {
+      if (!tags.has(uri)) {
+        tags.set(uri, this.index.getFileTags ? await this.index.getFileTags(uri) : classifyPath(uri));
+      }
+    }
+    return tags;
+  }
+
+  private async checkRename(params: URLSearchParams) {
+    const name = params.get('name');
+    const newName = params.get('newName');
+    if (!name || !newName) {
+      throw new BadRequest('Missing query parameter "name" or "newName"');
+    }
+    const findReferencesByName = this.index.findReferencesByName?.bind(this.index);
+    if (!findReferencesByName) {
+      throw new BadRequest('The index has no reference lookup');
+    }
+    return new RenameImpactAnalyzer({
+      findDefinitions: definitionName => this.index.findDefinitions(definitionName),
+      findReferencesByName
+    }).check(name, newName);
+  }
+
+  private async getFunctionMetrics(params: URLSearchParams) {
+    if (!this.codeMetrics) {
+      throw new BadRequest('Function metrics need the background index');
+    }
+    const sort = params.get('sort') ?? 'complexity';
+    if (!(CODE_METRICS as readonly string[]).includes(sort)) {
+      throw new BadRequest(`Parameter "sort" must be one of ${CODE_METRICS.join(', ')}`);
+    }
+    const report = await this.codeMetrics.report({
+      sort: sort as CodeMetric,
+      limit: Math.min(parseInteger(params, 'limit') ?? DEFAULT_SEARCH_LIMIT, MAX_SEARCH_LIMIT),
+      min: {
+        complexity: parseInteger(params, 'minComplexity'),
+        loc: parseInteger(params, 'minLoc'),
+        parameters: parseInteger(params, 'minParameters'),
+        nesting: parseInteger(params, 'minNesting')
+      },
+      tags: parseTagFilter(params)
+    });
+    return {
+      sort,
+      functionsAnalyzed: report.functionsAnalyzed,
+      matched: report.matched,
+      truncated: report.truncated,
+      functions: report.functions.map(entry => ({ ...this.symbolJson(entry.symbol), parameters: entry.parameters }))
+    };
+  }
+
+  private async getOwners(params: URLSearchParams) {
+    if (!this.ownership) {
+      throw new BadRequest('Code owners need the background index');
+    }
+    if (params.has('uri')) {
+      const uri = requireUri(params);
+      return { uri, owners: this.ownership.ownersOf(uri) };
+    }
+    return this.ownership.summarize({
+      owner: params.get('owner') || undefined,
+      tags: parseTagFilter(params)
+    });
+  }
+
+  private async getMarkers(params: URLSearchParams) {
+    if (!this.commentMarkers) {
+      throw new BadRequest('Comment markers need the background index');
+    }
+    const sort = params.get('sort') ?? undefined;
+    if (sort !== undefined && sort !== 'path' && sort !== 'age') {
+      throw new BadRequest('Parameter "sort" must be path or age');
+    }
+    const tags = (params.get('tag') ?? '').split(',').map(tag => tag.trim()).filter(Boolean);
+    const scope = params.get('path');
+    try {
+      return await this.commentMarkers({
+        tags: tags.length > 0 ? tags : undefined,
+        path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+        author: params.get('author') || undefined,
+        minAgeDays: parseInteger(params, 'minAge'),
+        maxAgeDays: parseInteger(params, 'maxAge'),
+        sort,
+        limit: Math.min(parseInteger(params, 'limit') ?? DEFAULT_SEARCH_LIMIT, MAX_SEARCH_LIMIT)
+      });
+    } catch (error) {
+      if (error instanceof MarkerQueryError) {
+        throw new BadRequest(error.message);
+      }
+      throw error;
+    }
+  }
+
+  private async getRoutes(params: URLSearchParams) {
+    if (!this.httpRoutes) {
+      throw new BadRequest('HTTP routes need the background index');
+    }
+    const framework = params.get('framework') || undefined;
+    if (framework !== undefined && !HTTP_FRAMEWORKS.includes(framework as HttpFramework)) {
+      throw new BadRequest(`Parameter "framework" must be one of ${HTTP_FRAMEWORKS.join(', ')}`);
+    }
+    const scope = params.get('path');
+    try {
+      return await this.httpRoutes({
+        method: params.get('method') || undefined,
+        route: params.get('route') || undefined,
+        framework: framework as HttpFramework | undefined,
+        path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+        limit: parseInteger(params, 'limit')
+      });
+    } catch (error) {
+      if (error instanceof RouteQueryError) {
+        throw new BadRequest(error.message);
+      }
+      throw error;
+    }
+  }
+
+  private async getSqlQueries(params: URLSearchParams) {
+    if (!this.sqlQueries) {
+      throw new BadRequest('SQL queries need the background index');
+    }
+    const operation = params.get('operation')?.toLowerCase() || undefined;
+    if (operation !== undefined && !SQL_OPERATIONS.includes(operation as SqlOperation)) {
+      throw new BadRequest(`Parameter "operation" must be one of ${SQL_OPERATIONS.join(', ')}`);
+    }
+    const access = params.get('access') || undefined;
+    if (access !== undefined && access !== 'read' && access !== 'write') {
+      throw new BadRequest('Parameter "access" must be read or write');
+    }
+    const scope = params.get('path');
+    return this.sqlQueries({
+      table: params.get('table') || undefined,
+      column: params.get('column') || undefined,
+      operation: operation as SqlOperation | undefined,
+      access: access as 'read' | 'write' | undefined,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getConfigKeys(params: URLSearchParams) {
+    if (!this.configKeys) {
+      throw new BadRequest('Configuration keys need the background index');
+    }
+    const source = params.get('source') || undefined;
+    if (source !== undefined && !CONFIG_SOURCES.includes(source as ConfigSource)) {
+      throw new BadRequest(`Parameter "source" must be one of ${CONFIG_SOURCES.join(', ')}`);
+    }
+    const scope = params.get('path');
+    return this.configKeys({
+      source: source as ConfigSource | undefined,
+      key: params.get('key') || undefined,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getConcurrency(params: URLSearchParams) {
+    if (!this.concurrency) {
+      throw new BadRequest('Concurrency analysis needs the background index');
+    }
+    const op = params.get('op') || undefined;
+    if (op !== undefined && !CONCURRENCY_OPS.includes(op as ConcurrencyOp)) {
+      throw new BadRequest(`Parameter "op" must be one of ${CONCURRENCY_OPS.join(', ')}`);
+    }
+    const scope = params.get('path');
+    return this.concurrency({
+      op: op as ConcurrencyOp | undefined,
+      package: params.get('package') || undefined,
+      target: params.get('target') || undefined,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getErrorPaths(params: URLSearchParams) {
+    if (!this.errorPaths) {
+      throw new BadRequest('Error paths need the background index');
+    }
+    const kind = params.get('kind') || undefined;
+    if (kind !== undefined && !ERROR_PATH_KINDS.includes(kind as ErrorPathKind)) {
+      throw new BadRequest(`Parameter "kind" must be one of ${ERROR_PATH_KINDS.join(', ')}`);
+    }
+    const scope = params.get('path');
+    return this.errorPaths({
+      kind: kind as ErrorPathKind | undefined,
+      exported: params.get('exported') === 'true' || params.get('exported') === '1',
+      canPanic: params.get('canPanic') === 'true' || params.get('canPanic') === '1',
+      package: params.get('package') || undefined,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async complete(params: URLSearchParams): Promise<string[]> {
+    if (!this.completions) {
+      throw new BadRequest('Completions need the background index');
+    }
+    const kind = params.get('kind');
+    if (!kind || !COMPLETION_KINDS.includes(kind as CompletionKind)) {
+      throw new BadRequest(`Parameter "kind" must be one of ${COMPLETION_KINDS.join(', ')}`);
+    }
+    const cwd = params.get('cwd');
+    return this.completions({
+      kind: kind as CompletionKind,
+      prefix: params.get('prefix') ?? '',
+      cwd: cwd ? (cwd.startsWith('file://') ? fileURLToPath(cwd) : cwd) : undefined,
+      limit: parseInteger(params, 'limit'),
+      describe: params.get('describe') === 'true' || params.get('describe') === '1'
+    });
+  }
+
+  private async getCoverage(params: URLSearchParams) {
+    if (!this.coverage) {
+      throw new BadRequest('Coverage needs the background index');
+    }
+    const exported = params.get('exported');
+    if (exported !== null && !['true', 'false', '1', '0'].includes(exported)) {
+      throw new BadRequest('Parameter "exported" must be true or false');
+    }
+    const scope = params.get('path');
+    return this.coverage({
+      maxCoverage: parseInteger(params, 'maxCoverage'),
+      minCoverage: parseInteger(params, 'minCoverage'),
+      minReferences: parseInteger(params, 'minRefs'),
+      exported: exported === null ? undefined : exported === 'true' || exported === '1',
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getTestsFor(params: URLSearchParams) {
+    if (!this.testsFor) {
+      throw new BadRequest('Test linking needs the background index');
+    }
+    const symbol = params.get('symbol');
+    if (!symbol) {
+      throw new BadRequest('Missing query parameter "symbol"');
+    }
+    return this.testsFor({
+      symbol,
+      maxDepth: parseInteger(params, 'maxDepth'),
+      kinds: parseTestKinds(params)
+    });
+  }
+
+  private async getAffectedTests(params: URLSearchParams) {
+    if (!this.affectedTests) {
+      throw new BadRequest('Affected test selection needs the background index');
+    }
+    const since = params.get('since');
+    if (!since) {
+      throw new BadRequest('Missing query parameter "since"');
+    }
+    return this.affectedTests({
+      since,
+      maxDepth: parseInteger(params, 'maxDepth'),
+      kinds: parseTestKinds(params)
+    });
+  }
+
+  private requireDocs(): DocsSource {
+    if (!this.docs) {
+      throw new BadRequest('Doc search needs the background index');
+    }
+    return this.docs;
+  }
+
+  private async searchDocs(params: URLSearchParams) {
+    const docs = this.requireDocs();
+    const scope = params.get('path');
+    return docs.search({
+      text: params.get('q') || undefined,
+      language: params.get('language') || undefined,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getDocMentions(params: URLSearchParams) {
+    const docs = this.requireDocs();
+    const symbol = params.get('symbol');
+    if (!symbol) {
+      throw new BadRequest('Missing query parameter "symbol"');
+    }
+    const scope = params.get('path');
+    return docs.mentions({
+      symbol,
+      path: scope ? (scope.startsWith('file://') ? fileURLToPath(scope) : scope) : undefined,
+      limit: parseInteger(params, 'limit')
+    });
+  }
+
+  private async getCallGraph(params: URLSearchParams) {
+    if (!this.callGraph) {
+      throw new BadRequest('The call graph needs the background index');
+    }
+    const name = params.get('name');
+    const uri = params.get('uri');
+    if (!name && !uri) {
+      throw new BadRequest('Provide "name", or "uri" with "line" and "character"');
+    }
+    const direction = params.get('direction') ?? 'both';
+    if (direction !== 'callers' && direction !== 'callees' && direction !== 'both') {
+      throw new BadRequest('Parameter "direction" must be "callers", "callees" or "both"');
+    }
+    const position = uri ? { uri: requireUri(params), line: parseInteger(params, 'line') ?? 0, character: parseInteger(params, 'character') } : {};
+    return this.callGraph({
+      ...(name && { name }),
+      ...position,
+      direction: direction as CallGraphDirection,
+      depth: parseInteger(params, 'depth')
+    });
+  }
+
+  private async getOutline(params: URLSearchParams) {
+    const uri = requireUri(params);
+    if (params.get('tree') === 'true') {
+      return { uri, outline: buildOutline(await this.index.getFileSymbols(uri), { groupByContainer: true }) };
+    }
+    const symbols = (await this.index.getFileSymbols(uri))
+      .filter(s => s.isDefinition !== false)
+      .sort((a, b) => a.range.startLine - b.range.startLine || a.range.startCharacter - b.range.startCharacter);
+    return { uri, symbols: symbols.map(this.symbolJson) };
+  }
+
+  /**
+   * Symbol name from `name`, or the identifier at `uri`/`line`/`character`.
+   */
+  private async resolveName(params: URLSearchParams): Promise<{ name: string; uri?: string }> {
+    const name = params.get('name');
+    if (name) {
+      return { name };
+    }
+
+    const uri = requireUri(params);
+    const line = parseInteger(params, 'line');
+    const character = parseInteger(params, 'character');
+    if (line === undefined || character === undefined) {
+      throw new BadRequest('Provide "name", or "uri" with "line" and "character"');
+    }
+
+    let text: string;
+    try {
+      text = await this.readFile(uri);
+    } catch {
+      throw new BadRequest(`Cannot read file: ${uri}`);
+    }
+    const word = getWordAtPosition(text, offsetAt(text, line, character));
+    if (!word) {
+      throw new BadRequest(`No identifier at ${uri}:${line}:${character}`);
+    }
+    return { name: word, uri };
+  }
+}
+
+/**
+ * Queries of a batch body: a JSON array, or one query per line.
+ */
+function parseBatch(body: string): BatchQuery[] {
+  const text = body.trim();
+  if (text.startsWith('[')) {
+    const queries = JSON.parse(text) as unknown;
+    if (!Array.isArray(queries)) {
+      throw new Error('Batch must be a JSON array or one query per line');
+    }
+    return queries as BatchQuery[];
+  }
+  return text
+    .split('\n')
+    .map(line => line.trim())
+    .filter(line => line !== '')
+    .map((line, i) => {
+      if (line.startsWith('/')) {
+        return line;
+      }
+      try {
+        return JSON.parse(line) as BatchQuery;
+      } catch {
+        throw new Error(`Line ${i + 1} is neither a path nor JSON`);
+      }
+    });
+}
+
+/**
+ * A GraphQL POST body: `{"query": ..., "variables": ..., "operationName": ...}`
+ * as application/json, or the bare query as application/graphql.
+ */
+function parseGraphQLBody(body: string): GraphQLRequest {
+  let json: unknown;
+  try {
+    json = JSON.parse(body);
+  } catch {
+    return { query: body };
+  }
+  if (typeof json !== 'object' || json === null || typeof (json as GraphQLRequest).query !== 'string') {
+    throw new Error('Body must be a GraphQL query or a JSON object with a "query" string');
+  }
+  const { query, variables, operationName } = json as GraphQLRequest;
+  return { query, variables, operationName };
+}
+
+function batchUrl(query: BatchQuery): string {
+  if (typeof query === 'string') {
+    if (!query.startsWith('/')) {
+      throw new Error(`Query must start with "/": ${query}`);
+    }
+    return query;
+  }
+  if (query === null || typeof query !== 'object' || typeof query.endpoint !== 'string' || !query.endpoint.startsWith('/')) {
+    throw new Error('Query needs an "endpoint" starting with "/"');
+  }
+  const params = new URLSearchParams();
+  if (query.params !== undefined && (query.params === null || typeof query.params !== 'object' || Array.isArray(query.params))) {
+    throw new Error('Query "params" must be an object');
+  }
+  for (const [key, value] of Object.entries((query.params ?? {}) as Record<string, unknown>)) {
+    params.set(key, Array.isArray(value) ? value.join(',') : String(value));
+  }
+  const search = params.toString();
+  return search ? `${query.endpoint}?${search}` : query.endpoint;
+}
+
+function requireQuery(params: URLSearchParams): string {
+  const query = params.get('q') ?? params.get('query');
+  if (!query) {
+    throw new BadRequest('Missing query parameter "q"');
+  }
+  return query;
+}
+
+function optionalQuery(params: URLSearchParams): string {
+  return params.get('q') ?? params.get('query') ?? '';
+}
+
+function hasShapeCriteria(params: URLSearchParams): boolean {
+  return params.has('signature') || params.has('constraint') || params.has('generic');
+}
+
+function parseKinds(params: URLSearchParams): Set<string> | undefined {
+  const kinds = (params.get('kind') ?? '')
+    .split(',')
+    .filter(kind => kind.trim())
+    .map(normalizeKind);
+  return kinds.length > 0 ? new Set(kinds) : undefined;
+}
+
+function parseSignatureParam(params: URLSearchParams): SignaturePattern | undefined {
+  const signature = params.get('signature');
+  if (!signature) {
+    return undefined;
+  }
+  try {
+    return parseSignaturePattern(signature);
+  } catch (error) {
+    throw new BadRequest(error instanceof Error ? error.message : String(error));
+  }
+}
+
+async function* mapLazily<T>(items: AsyncIterable<T>, map: (item: T) => unknown): AsyncIterable<unknown> {
+  for await (const item of items) {
+    yield map(item);
+  }
+}
+
+/**
+ * The page that `limit`, `offset` and `cursor` ask for. Cursors are tied
+ * to the results (`symbols` for /symbols and /stream/symbols) and all
+ * other parameters, so they cannot continue a different query.
+ */
+function parsePageWindow(results: string, params: URLSearchParams, defaultLimit: number, maxLimit: number): PageWindow {
+  const query = [...params].filter(([key]) => !PAGE_PARAMS.has(key)).sort(([a], [b]) => a < b ? -1 : a > b ? 1 : 0);
+  try {
+    return pageWindow({
+      limit: parseInteger(params, 'limit'),
+      offset: parseInteger(params, 'offset'),
+      cursor: params.get('cursor') || undefined
+    }, defaultLimit, maxLimit, queryFingerprint(results, query));
+  } catch (error) {
+    if (error instanceof CursorError) {
+      throw new BadRequest(error.message);
+    }
+    throw error;
+  }
+}
+
+/** Where a page is among all results, for response bodies */
+function pageInfo<T>(page: Page<T>) {
+  return { offset: page.offset, ...(page.nextCursor && { nextCursor: page.nextCursor }) };
+}
+
+function streamPage<T>(page: PageStream<T>, map: (item: T) => unknown): QueryResponse {
+  return { status: 200, stream: mapLazily(page.items, map), trailers: { [NEXT_CURSOR_HEADER]: page.nextCursor } };
+}
+
+/** The `template` to render results with when `format=template` */
+function parseOutputTemplate(params: URLSearchParams): OutputTemplate | undefined {
+  const format = params.get('format');
+  if (format === null || format === 'json') {
+    return undefined;
+  }
+  if (format !== 'template') {
+    throw new BadRequest('Parameter "format" must be "json" or "template"');
+  }
+  const template = params.get('template');
+  if (!template) {
+    throw new BadRequest('Parameter "template" is required with format=template');
+  }
+  return parseTemplate(template);
+}
+
+/**
+ * A JSON response as template output, one rendered result per line.
+ * Errors, plain-text and binary responses are left alone.
+ */
+function renderResponse(response: QueryResponse, template: OutputTemplate): QueryResponse {
+  if (response.status !== 200 || response.text !== undefined || response.binary !== undefined) {
+    return response;
+  }
+  if (response.stream) {
+    return {
+      status: 200,
+      stream: renderEach(response.stream, template),
+      contentType: TEXT_CONTENT_TYPE,
+      headers: response.headers,
+      trailers: response.trailers
+    };
+  }
+  // Plain text has no room for the next page's cursor; the header carries it as for streams
+  const nextCursor = (response.body as { nextCursor?: unknown } | undefined)?.nextCursor;
+  try {
+    return {
+      status: 200,
+      text: resultsOf(response.body).map(item => template.render(item) + '\n').join(''),
+      ...(typeof nextCursor === 'string' && { headers: { [NEXT_CURSOR_HEADER]: nextCursor } })
+    };
+  } catch (error) {
+    if (error instanceof TemplateError) {
+      return { status: 400, body: { error: error.message } };
+    }
+    throw error;
+  }
+}
+
+async function* renderEach(items: Iterable<unknown> | AsyncIterable<unknown>, template: OutputTemplate): AsyncIterable<string> {
+  for await (const item of items) {
+    yield template.render(item) + '\n';
+  }
+}
+
+function resultsOf(body: unknown): unknown[] {
+  if (Array.isArray(body)) {
+    return body;
+  }
+  if (body !== null && typeof body === 'object') {
+    const record = body as Record<string, unknown>;
+    const key = RESULT_KEYS.find(candidate => Array.isArray(record[candidate]));
+    if (key) {
+      return record[key] as unknown[];
+    }
+  }
+  return [body];
+}
+
+/** `kind=test,benchmark`: Go test kinds */
+function parseTestKinds(params: URLSearchParams): GoTestKind[] | undefined {
+  const kinds = params.get('kind')?.split(',').map(kind => kind.trim()).filter(Boolean);
+  const unknown = kinds?.find(kind => !GO_TEST_KINDS.includes(kind as GoTestKind));
+  if (unknown !== undefined) {
+    throw new BadRequest(`Parameter "kind" must be one of ${GO_TEST_KINDS.join(', ')}`);
+  }
+  return kinds as GoTestKind[] | undefined;
+}
+
+function parseTagFilter(params: URLSearchParams): CodeTagFilter {
+  try {
+    return {
+      exclude: parseCodeTags(params.get('exclude') ?? ''),
+      only: parseCodeTags(params.get('only') ?? '')
+    };
+  } catch (error) {
+    throw new BadRequest(error instanceof Error ? error.message : String(error));
+  }
+}
+
+function requireUri(params: URLSearchParams): string {
+  const uri = params.get('uri');
+  if (!uri) {
+    throw new BadRequest('Missing query parameter "uri"');
+  }
+  return uri.startsWith('file://') ? fileURLToPath(uri) : uri;
+}
+
+function parseInteger(params: URLSearchParams, key: string): number | undefined {
+  const raw = params.get(key);
+  if (raw === null) {
+    return undefined;
+  }
+  const value = Number(raw);
+  if (!Number.isInteger(value) || value < 0) {
+    throw new BadRequest(`Parameter "${key}" must be a non-negative integer`);
+  }
+  return value;
+}
+
+function offsetAt(text: string, line: number, character: number): number {
+  let offset = 0;
+  for (let i = 0; i < line; i++) {
+    const next = text.indexOf('\n', offset);
+    if (next === -1) {
+      return text.length;
+    }
+    offset = next + 1;
+  }
+  return Math.min(offset + character, text.length);
+}
+
+/**
+ * Dependency code: Go module cache / vendor (per `metadata.go`) or node_modules.
+ */
+function isThirdParty(symbol: IndexedSymbol): boolean {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  return go?.thirdParty === true || /[\\/]node_modules[\\/]/.test(symbol.location.uri);
+}
+
+function toSymbolJson(symbol: IndexedSymbol) {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  return {
+    name: symbol.name,
+    kind: symbol.kind,
+    ...(symbol.canonicalId && { canonicalId: symbol.canonicalId }),
+    ...(symbol.containerName && { containerName: symbol.containerName }),
+    ...(symbol.fullContainerPath && { fullContainerPath: symbol.fullContainerPath }),
+    location: symbol.location,
+    range: symbol.range,
+    ...(go?.module && { module: go.module }),
+    ...(go?.moduleVersion && { moduleVersion: go.moduleVersion }),
+    ...(isThirdParty(symbol) && { thirdParty: true }),
+    ...(go?.buildConstraint && { buildConstraint: go.buildConstraint }),
+    ...(symbol.tags && symbol.tags.length > 0 && { tags: symbol.tags }),
+    ...(symbol.signature && { signature: symbol.signature }),
+    ...(symbol.typeParameters && symbol.typeParameters.length > 0 && { typeParameters: symbol.typeParameters }),
+    ...(symbol.value !== undefined && { value: symbol.value }),
+    ...(symbol.metrics && { metrics: symbol.metrics }),
+    ...(symbol.captures && symbol.captures.length > 0 && { captures: symbol.captures }),
+    ...(symbol.doc && { doc: symbol.doc }),
+    ...(symbol.deprecated !== undefined && { deprecated: true, deprecation: symbol.deprecated })
+  };
+}
+
+function toReferenceJson(reference: IndexedReference) {
+  return {
+    name: reference.symbolName,
+    ...(reference.containerName && { containerName: reference.containerName }),
+    ...(reference.isImport && { isImport: true }),
+    ...(reference.isCall && { isCall: true }),
+    location: reference.location,
+    range: reference.range
+  };
+}
@@ -0,0 +1,44 @@
+/**
+ * QueryServerAuth Tests
+ *
+ * Verifies access rules: tokens and their SHA-256 digests, client
+ * certificate subjects, index access and rule validation.
+ */
+
+import { describe, it, expect } from 'vitest';
+import * as crypto from 'crypto';
+import { AccessRuleError, bearerToken, canAccessIndex, QueryServerAuth } from './queryServerAuth.js';
+
+describe('QueryServerAuth', () => {
+  const auth = new QueryServerAuth([
+    { name: 'ci', token: 's3cret', indexes: ['payments'] },
+    { name: 'platform', tokenSha256: crypto.createHash('sha256').update('hashed').digest('hex') },
+    { subject: 'search-bot', indexes: ['payments', 'ledger'] }
+  ]);
+
+  it('should let in tokens, token digests and certificate subjects', () => {
+    expect(auth.authenticate({ token: 's3cret' })).toEqual({ name: 'ci', indexes: ['payments'] });
+    expect(auth.authenticate({ token: 'hashed' })).toEqual({ name: 'platform', indexes: ['*'] });
+    expect(auth.authenticate({ subject: 'search-bot' })).toEqual({ name: 'search-bot', indexes: ['payments', 'ledger'] });
+    expect(auth.authenticate({ token: 's3cre' })).toBeNull();
+    expect(auth.authenticate({ subject: 'ci' })).toBeNull();
+    expect(auth.authenticate({})).toBeNull();
+  });
+
+  it('should check index access', () => {
+    const ci = auth.authenticate({ token: 's3cret' })!;
+    expect(canAccessIndex(ci, 'payments')).toBe(true);
+    expect(canAccessIndex(ci, 'ledger')).toBe(false);
+    expect(canAccessIndex(auth.authenticate({ token: 'hashed' })!, 'ledger')).toBe(true);
+  });
+
+  it('should read bearer tokens and reject rules that match nothing', () => {
+    expect(bearerToken('Bearer abc.def')).toBe('abc.def');
+    expect(bearerToken('bearer  abc ')).toBe('abc');
+    expect(bearerToken('Basic abc')).toBeUndefined();
+    expect(bearerToken(undefined)).toBeUndefined();
+
+    expect(() => new QueryServerAuth([{ name: 'empty', indexes: ['*'] }])).toThrow(AccessRuleError);
+    expect(() => new QueryServerAuth([{ tokenSha256: 'abc' }])).toThrow('Access rule 1: "tokenSha256" must be 64 hex digits');
+  });
+});
@@ -0,0 +1,110 @@
+import * as crypto from 'crypto';
+
+/**
+ * Who may query the server: a bearer token or a TLS client certificate
+ * subject, and the indexes it opens.
+ */
+export interface QueryServerAccessRule {
+  /** Shown in logs, e.g. `ci` or `payments-team`; defaults to the subject */
+  name?: string;
+  /** Bearer token, as sent in `Authorization: Bearer <token>` */
+  token?: string;
+  /** SHA-256 of the token in hex, so config files need not hold the secret */
+  tokenSha256?: string;
+  /** Common name (CN) of a client certificate the server's CA signed */
+  subject?: string;
+  /** Names of the indexes it may query; `*` (the default) for all */
+  indexes?: string[];
+}
+
+/** An authenticated client */
+export interface QueryPrincipal {
+  name: string;
+  /** Index names it may query; `*` for all */
+  indexes: string[];
+}
+
+/** What a request presents: its bearer token and verified client certificate */
+export interface QueryCredentials {
+  token?: string;
+  subject?: string;
+}
+
+/** Thrown for rules that cannot match anything */
+export class AccessRuleError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = 'AccessRuleError';
+  }
+}
+
+interface CompiledRule {
+  principal: QueryPrincipal;
+  digest?: Buffer;
+  subject?: string;
+}
+
+/**
+ * Query Server Auth - token and client-certificate authentication with
+ * per-index access control, for servers reachable beyond localhost.
+ *
+ * A request is let in by the first rule whose token or certificate
+ * subject it presents. Tokens are compared as SHA-256 digests in constant
+ * time, so neither their length nor their prefix leaks through timing.
+ * Certificate subjects are only trusted when the TLS layer verified the
+ * certificate (see QueryListenOptions.tls).
+ */
+export class QueryServerAuth {
+  private rules: CompiledRule[];
+
+  constructor(rules: QueryServerAccessRule[]) {
+    this.rules = rules.map((rule, i) => compileRule(rule, i));
+  }
+
+  /** The principal of the first matching rule, or null */
+  authenticate(credentials: QueryCredentials): QueryPrincipal | null {
+    const digest = credentials.token ? sha256(credentials.token) : undefined;
+    for (const rule of this.rules) {
+      if (digest && rule.digest && crypto.timingSafeEqual(digest, rule.digest)) {
+        return rule.principal;
+      }
+      if (credentials.subject && rule.subject === credentials.subject) {
+        return rule.principal;
+      }
+    }
+    return null;
+  }
+}
+
+/** Whether principal may query the index named index */
+export function canAccessIndex(principal: QueryPrincipal, index: string): boolean {
+  return principal.indexes.includes('*') || principal.indexes.includes(index);
+}
+
+/** The token of an `Authorization: Bearer <token>` header */
+export function bearerToken(authorization: string | undefined): string | undefined {
+  const match = /^Bearer\s+(\S+)\s*$/i.exec(authorization ?? '');
+  return match ? match[1] : undefined;
+}
+
+function compileRule(rule: QueryServerAccessRule, index: number): CompiledRule {
+  if (rule.tokenSha256 !== undefined && !/^[0-9a-f]{64}$/i.test(rule.tokenSha256)) {
+    throw new AccessRuleError(`Access rule ${index + 1}: "tokenSha256" must be 64 hex digits`);
+  }
+  const digest = rule.token ? sha256(rule.token) : rule.tokenSha256 ? Buffer.from(rule.tokenSha256, 'hex') : undefined;
+  if (!digest && !rule.subject) {
+    throw new AccessRuleError(`Access rule ${index + 1} needs a "token", "tokenSha256" or "subject"`);
+  }
+  return {
+    principal: {
+      name: rule.name ?? rule.subject ?? `rule ${index + 1}`,
+      indexes: rule.indexes && rule.indexes.length > 0 ? rule.indexes : ['*']
+    },
+    digest,
+    subject: rule.subject
+  };
+}
+
+function sha256(text: string): Buffer {
+  return crypto.createHash('sha256').update(text).digest();
+}
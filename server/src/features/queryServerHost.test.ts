@@ -0,0 +1,133 @@
+/**
+ * QueryServerHost Tests
+ *
+ * Verifies serving several indexes from one listener: routing to each
+ * index, per-index access control, bearer tokens over HTTP and client
+ * certificates over mutual TLS (certificates made with openssl).
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as https from 'https';
+import * as os from 'os';
+import * as path from 'path';
+import { QueryServerHost } from './queryServerHost.js';
+import { QueryServer } from './queryServer.js';
+import { QueryServerAuth } from './queryServerAuth.js';
+import { MockIndex, createTestSymbol } from '../test/mocks/MockIndex.js';
+
+function serverFor(name: string): QueryServer {
+  const index = new MockIndex();
+  const uri = `/repos/${name}/main.go`;
+  index.addSymbol(createTestSymbol({
+    id: name, name: `${name}Service`, kind: 'struct', filePath: uri,
+    location: { uri, line: 2, character: 5 }
+  }));
+  return new QueryServer(index);
+}
+
+describe('QueryServerHost', () => {
+  let host: QueryServerHost;
+
+  beforeEach(() => {
+    host = new QueryServerHost();
+    host.add('payments', serverFor('payments'));
+    host.add('ledger', serverFor('ledger'));
+  });
+
+  afterEach(async () => {
+    await host.stop();
+  });
+
+  it('should route to the named index and list the indexes a client may query', async () => {
+    const symbols = await host.handle('GET', '/indexes/payments/symbols?q=Service');
+    expect((symbols.body as any).symbols.map((s: any) => s.name)).toEqual(['paymentsService']);
+    expect((await host.handle('GET', '/indexes/ledger/definition?name=ledgerService')).status).toBe(200);
+    expect((await host.handle('GET', '/indexes/ledger/ui')).contentType).toBe('text/html; charset=utf-8');
+
+    expect(await host.handle('GET', '/indexes')).toEqual({
+      status: 200,
+      body: { indexes: [{ name: 'ledger', url: '/indexes/ledger/' }, { name: 'payments', url: '/indexes/payments/' }] }
+    });
+    const ci = { name: 'ci', indexes: ['payments'] };
+    expect((await host.handle('GET', '/indexes', '', ci)).body).toEqual({ indexes: [{ name: 'payments', url: '/indexes/payments/' }] });
+    expect((await host.handle('GET', '/indexes/ledger/symbols?q=x', '', ci)).status).toBe(403);
+    expect((await host.handle('GET', '/indexes/billing/symbols?q=x')).status).toBe(404);
+    expect(await host.handle('GET', '/indexes/payments?x=1')).toMatchObject({ status: 308, headers: { Location: '/indexes/payments/?x=1' } });
+    expect((await host.handle('GET', '/symbols?q=x')).status).toBe(404);
+
+    expect(host.remove('ledger')).toBe(true);
+    expect(host.names()).toEqual(['payments']);
+    expect(() => host.add('../etc', serverFor('x'))).toThrow('Invalid index name');
+  });
+
+  it('should ask for a bearer token, except for the health check and web UI pages', async () => {
+    const auth = new QueryServerAuth([{ name: 'ci', token: 's3cret', indexes: ['payments'] }]);
+    const address = await host.start(0, '127.0.0.1', { auth });
+    const base = `http://127.0.0.1:${address.port}`;
+    const withToken = { headers: { Authorization: 'Bearer s3cret' } };
+
+    const anonymous = await fetch(`${base}/indexes/payments/symbols?q=Service`);
+    expect(anonymous.status).toBe(401);
+    expect(anonymous.headers.get('www-authenticate')).toBe('Bearer');
+    expect((await fetch(`${base}/indexes/payments/symbols?q=Service`, { headers: { Authorization: 'Bearer wrong' } })).status).toBe(401);
+
+    const allowed = await fetch(`${base}/indexes/payments/symbols?q=Service`, withToken);
+    expect(allowed.status).toBe(200);
+    expect(((await allowed.json()) as any).symbols[0].name).toBe('paymentsService');
+    expect((await fetch(`${base}/indexes/ledger/symbols?q=Service`, withToken)).status).toBe(403);
+
+    expect((await fetch(`${base}/health`)).status).toBe(200);
+    expect((await fetch(`${base}/indexes/payments/`)).headers.get('content-type')).toContain('text/html');
+  });
+
+  it('should let in client certificates over mutual TLS', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'query-tls-'));
+    try {
+      const pki = makeCertificates(dir);
+      const auth = new QueryServerAuth([{ subject: 'search-bot', indexes: ['ledger'] }]);
+      const address = await host.start(0, '127.0.0.1', { auth, tls: { cert: pki.serverCert, key: pki.serverKey, ca: pki.caCert } });
+
+      const get = (pathname: string, client?: { cert: string; key: string }) => new Promise<number>((resolve, reject) => {
+        https.get({ host: '127.0.0.1', port: address.port, path: pathname, ca: pki.caCert, ...client }, res => {
+          res.resume();
+          resolve(res.statusCode!);
+        }).on('error', reject);
+      });
+
+      expect(await get('/indexes/ledger/symbols?q=Service', pki.client)).toBe(200);
+      expect(await get('/indexes/payments/symbols?q=Service', pki.client)).toBe(403);
+      // Without a certificate the handshake fails
+      await expect(get('/health')).rejects.toThrow();
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+});
+
+/** A CA, a server certificate for 127.0.0.1 and a client certificate for `search-bot` */
+function makeCertificates(dir: string) {
+  const openssl = (args: string[]) => execFileSync('openssl', args, { cwd: dir, stdio: 'pipe' });
+  const key = (name: string) => openssl(['genpkey', '-algorithm', 'EC', '-pkeyopt', 'ec_paramgen_curve:P-256', '-out', `${name}.key`]);
+  const sign = (name: string, subject: string, extensions: string) => {
+    key(name);
+    fs.writeFileSync(path.join(dir, `${name}.ext`), extensions);
+    openssl(['req', '-new', '-key', `${name}.key`, '-subj', `/CN=${subject}`, '-out', `${name}.csr`]);
+    openssl(['x509', '-req', '-in', `${name}.csr`, '-CA', 'ca.crt', '-CAkey', 'ca.key', '-CAcreateserial',
+      '-days', '1', '-extfile', `${name}.ext`, '-out', `${name}.crt`]);
+  };
+
+  key('ca');
+  openssl(['req', '-x509', '-new', '-key', 'ca.key', '-subj', '/CN=test-ca', '-days', '1', '-out', 'ca.crt']);
+  sign('server', 'localhost', 'subjectAltName=IP:127.0.0.1\n');
+  sign('client', 'search-bot', 'extendedKeyUsage=clientAuth\n');
+
+  const read = (file: string) => fs.readFileSync(path.join(dir, file), 'utf-8');
+  return {
+    caCert: read('ca.crt'),
+    serverCert: read('server.crt'),
+    serverKey: read('server.key'),
+    client: { cert: read('client.crt'), key: read('client.key') }
+  };
+}
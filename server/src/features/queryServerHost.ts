@@ -0,0 +1,137 @@
+import { AddressInfo } from 'net';
+import { ILogger, NullLogger } from '../utils/Logger.js';
+import { QueryResponse, QueryServer } from './queryServer.js';
+import { QueryHandler, QueryListener, QueryListenOptions } from './queryListener.js';
+import { canAccessIndex, QueryPrincipal } from './queryServerAuth.js';
+
+/** Index names: URL path segments */
+const INDEX_NAME = /^[A-Za-z0-9][\w.-]*$/;
+
+/**
+ * Query Server Host - many named indexes behind one listener, so a
+ * central team can run one indexer service for many repositories:
+ *
+ *   /health                      server liveness
+ *   /indexes                     the indexes the client may query
+ *   /indexes/<name>/<endpoint>   any QueryServer endpoint of that index
+ *
+ * e.g. `/indexes/payments/symbols?q=Charge` or
+ * `POST /indexes/payments/graphql`. `/indexes/<name>/` serves the web UI
+ * of the index. With access rules (see features/queryServerAuth.ts), a
+ * client only sees and queries the indexes its rule names; others are
+ * 403s. Indexes can be added and removed while the host is running.
+ */
+export class QueryServerHost implements QueryHandler {
+  private servers = new Map<string, QueryServer>();
+  private listener: QueryListener;
+
+  constructor(private logger: ILogger = new NullLogger()) {
+    this.listener = new QueryListener(this, logger, 'QueryServerHost');
+  }
+
+  /** Serve an index under `/indexes/<name>/`, replacing one of that name */
+  add(name: string, server: QueryServer): void {
+    if (!INDEX_NAME.test(name)) {
+      throw new Error(`Invalid index name "${name}": use letters, digits, ".", "-" and "_"`);
+    }
+    this.servers.set(name, server);
+    this.logger.info(`[QueryServerHost] Serving index ${name}`);
+  }
+
+  remove(name: string): boolean {
+    return this.servers.delete(name);
+  }
+
+  names(): string[] {
+    return [...this.servers.keys()].sort();
+  }
+
+  /**
+   * Start listening. Port 0 picks a free port; the bound address is returned.
+   */
+  start(port: number, host: string = '127.0.0.1', options: QueryListenOptions = {}): Promise<AddressInfo> {
+    return this.listener.start(port, host, options);
+  }
+
+  stop(): Promise<void> {
+    return this.listener.stop();
+  }
+
+  isRunning(): boolean {
+    return this.listener.isRunning();
+  }
+
+  address(): AddressInfo | null {
+    return this.listener.address();
+  }
+
+  /** The health check, each index's web UI page and the redirects to it need no credentials */
+  isPublic(pathname: string): boolean {
+    if (pathname === '/health') {
+      return true;
+    }
+    const route = splitIndexPath(pathname);
+    if (!route) {
+      return false;
+    }
+    const server = this.servers.get(route.name);
+    return route.path === null || (server !== undefined && server.isPublic(route.path));
+  }
+
+  /**
+   * Route a request; principal is the authenticated client, if the
+   * listener asks for credentials. Separate from the HTTP layer so it can
+   * be tested directly.
+   */
+  async handle(method: string, rawUrl: string, body: string = '', principal?: QueryPrincipal): Promise<QueryResponse> {
+    const url = new URL(rawUrl, 'http://localhost');
+    const pathname = url.pathname.replace(/\/+$/, '') || '/';
+    if (pathname === '/health') {
+      return { status: 200, body: { status: 'ok', indexes: this.servers.size } };
+    }
+    if (pathname === '/indexes') {
+      if (method !== 'GET') {
+        return { status: 405, body: { error: `Method ${method} not allowed` } };
+      }
+      const names = this.names().filter(name => !principal || canAccessIndex(principal, name));
+      return { status: 200, body: { indexes: names.map(name => ({ name, url: `/indexes/${name}/` })) } };
+    }
+
+    const route = splitIndexPath(url.pathname);
+    if (!route) {
+      return { status: 404, body: { error: `Unknown endpoint: ${pathname}; indexes are served under /indexes/<name>/` } };
+    }
+    if (route.path === null) {
+      // The web UI calls the API relative to its page
+      const location = `/indexes/${encodeURIComponent(route.name)}/`;
+      return { status: 308, body: { location }, headers: { Location: location + url.search } };
+    }
+    const server = this.servers.get(route.name);
+    if (!server) {
+      return { status: 404, body: { error: `Unknown index: ${route.name}` } };
+    }
+    if (principal && !canAccessIndex(principal, route.name)) {
+      this.logger.warn(`[QueryServerHost] Denied ${principal.name} access to index ${route.name}`);
+      return { status: 403, body: { error: `No access to index ${route.name}` } };
+    }
+    return server.handle(method, route.path + url.search, body);
+  }
+}
+
+interface IndexRoute {
+  name: string;
+  /** The path within the index, `/` for `/indexes/<name>/`; null without the trailing slash */
+  path: string | null;
+}
+
+function splitIndexPath(pathname: string): IndexRoute | null {
+  const match = /^\/indexes\/([^/]+)(\/.*)?$/.exec(pathname);
+  if (!match) {
+    return null;
+  }
+  try {
+    return { name: decodeURIComponent(match[1]), path: match[2] ?? null };
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,128 @@
+/**
+ * Remote Index Tests
+ *
+ * Verifies push/pull round trips through a fake object store, checksum
+ * checks, and relocation of a pulled index to local roots and encrypted uploads.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { MANIFEST_SUFFIX, pullIndex, pushIndex, RemoteIndexSync } from './remoteIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { RemoteFetch } from '../utils/remoteStorage.js';
+import { EnvKeyProvider, IndexKeys, INDEX_KEY_ENV_VAR } from '../index/indexEncryption.js';
+import { CancellationTokenSource, CancellationError } from '../utils/asyncUtils.js';
+
+const REMOTE_URL = 'gs://ci-cache/main.idx';
+const ciRoot = path.join(path.sep, 'ci', 'build', 'service');
+const localRoot = path.join(path.sep, 'home', 'dev', 'service');
+
+function sha256(content: string): string {
+  return crypto.createHash('sha256').update(content).digest('hex');
+}
+
+function symbolId(uri: string, semanticPath: string): string {
+  return crypto.createHash('md5').update(uri).digest('hex').substring(0, 8) + ':' + semanticPath;
+}
+
+describe('RemoteIndex', () => {
+  let objects: Map<string, Uint8Array>;
+  let fetch: RemoteFetch;
+  let disk: Map<string, string>;
+
+  function addFile(index: MockBackgroundIndex, root: string, name: string, content: string): void {
+    const uri = path.join(root, name);
+    index.addFile(uri, [createTestSymbol({
+      id: symbolId(uri, name.replace(/\.ts$/, '')),
+      name: name.replace(/\.ts$/, ''),
+      filePath: uri,
+      location: { uri, line: 0, character: 0 }
+    })], [], { hash: sha256(content) });
+  }
+
+  beforeEach(() => {
+    objects = new Map();
+    fetch = async (url, init) => {
+      if (init.method === 'PUT') {
+        objects.set(url, init.body!);
+      }
+      const body = objects.get(url);
+      return {
+        ok: !!body,
+        status: body ? 200 : 404,
+        statusText: body ? 'OK' : 'Not Found',
+        arrayBuffer: async () => new Uint8Array(body ?? []).buffer
+      };
+    };
+    disk = new Map([
+      [path.join(localRoot, 'app.ts'), 'app();'],
+      [path.join(localRoot, 'util.ts'), 'export function util() {}']
+    ]);
+  });
+
+  it('should round-trip an index with a checksummed manifest', async () => {
+    const data = new TextEncoder().encode('index '.repeat(100));
+    const pushed = await pushIndex(REMOTE_URL, data, { files: 1, roots: [] }, { fetch, env: {} });
+
+    const stored = objects.get('https://storage.googleapis.com/ci-cache/main.idx')!;
+    expect(stored.length).toBe(pushed.compressedBytes);
+    expect(pushed.compressedBytes).toBeLessThan(data.length);
+    const manifest = JSON.parse(Buffer.from(objects.get(`https://storage.googleapis.com/ci-cache/main.idx${MANIFEST_SUFFIX}`)!).toString());
+    expect(manifest).toMatchObject({ format: 'smart-indexer-remote', compression: 'gzip', bytes: data.length, sha256: pushed.sha256 });
+
+    stored[stored.length - 1] ^= 0xff;
+    await expect(pullIndex(REMOTE_URL, { fetch, env: {} })).rejects.toThrow('Checksum mismatch');
+    await expect(pullIndex('gs://ci-cache/other.idx', { fetch, env: {} })).rejects.toThrow('No index at gs://ci-cache/other.idx');
+  });
+
+  it('should pull an index built elsewhere into the local roots', async () => {
+    const ci = new MockBackgroundIndex();
+    addFile(ci, ciRoot, 'app.ts', 'app();');
+    addFile(ci, ciRoot, 'util.ts', 'export function util() { return 1; }');
+    addFile(ci, ciRoot, 'gone.ts', 'gone();');
+    addFile(ci, path.join(path.sep, 'ci', 'other'), 'extra.ts', 'extra();');
+    await new RemoteIndexSync(ci.asBackgroundIndex()).push(REMOTE_URL, [{ path: ciRoot, name: 'service' }], { fetch, env: {} });
+
+    const local = new MockBackgroundIndex();
+    const sync = new RemoteIndexSync(local.asBackgroundIndex(), async uri => {
+      const content = disk.get(uri);
+      if (content === undefined) {
+        throw new Error(`ENOENT: ${uri}`);
+      }
+      return content;
+    });
+    const result = await sync.pull(REMOTE_URL, [{ path: localRoot, name: 'service' }], { fetch, env: {} });
+
+    expect(result).toMatchObject({ files: 4, imported: 1, changed: 1, missing: 1, outside: 1 });
+    const uri = path.join(localRoot, 'app.ts');
+    expect(await local.getAllFiles()).toEqual([uri]);
+    const [symbol] = (await local.getFileResult(uri))!.symbols;
+    expect(symbol.id).toBe(symbolId(uri, 'app'));
+    expect(symbol.location.uri).toBe(uri);
+  });
+
+  it('should encrypt the upload and decrypt it on pull', async () => {
+    const keys = new IndexKeys(new EnvKeyProvider(INDEX_KEY_ENV_VAR, { [INDEX_KEY_ENV_VAR]: 'cd'.repeat(32) }));
+    const ci = new MockBackgroundIndex();
+    addFile(ci, ciRoot, 'app.ts', 'app();');
+    await new RemoteIndexSync(ci.asBackgroundIndex()).push(REMOTE_URL, [{ path: ciRoot, name: 'service' }], { fetch, env: {}, keys });
+
+    const stored = Buffer.from(objects.get('https://storage.googleapis.com/ci-cache/main.idx')!);
+    expect(stored.subarray(0, 4).toString()).toBe('SIXE');
+    const manifest = JSON.parse(Buffer.from(objects.get(`https://storage.googleapis.com/ci-cache/main.idx${MANIFEST_SUFFIX}`)!).toString());
+    expect(manifest.encrypted).toBe(true);
+
+    const { saved } = await pullIndex(REMOTE_URL, { fetch, env: {}, keys });
+    expect(saved.files).toHaveLength(1);
+    await expect(pullIndex(REMOTE_URL, { fetch, env: {}, keys: new IndexKeys(undefined) })).rejects.toThrow('is encrypted');
+  });
+
+  it('should stop a cancelled transfer', async () => {
+    const source = new CancellationTokenSource();
+    source.cancel();
+    await expect(pullIndex(REMOTE_URL, { fetch, env: {}, cancellationToken: source.token })).rejects.toThrow(CancellationError);
+    expect(objects.size).toBe(0);
+  });
+});
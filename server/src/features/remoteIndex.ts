@@ -0,0 +1,321 @@
+import * as crypto from 'crypto';
+import * as fsPromises from 'fs/promises';
+import * as zlib from 'zlib';
+import { promisify } from 'util';
+import { encode } from '@msgpack/msgpack';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { fromCompactShard, isCompactShard, toCompactShard } from '../index/ShardPersistenceManager.js';
+import {
+  decodeSavedIndex,
+  DecodedSavedIndex,
+  mapSavedRoots,
+  relocateShard,
+  SAVED_INDEX_FORMAT,
+  SavedIndex,
+  savedRoots
+} from '../index/savedIndex.js';
+import { EnvKeyProvider, IndexKeys } from '../index/indexEncryption.js';
+import { SHARD_VERSION } from '../types.js';
+import { RemoteStorage, RemoteStorageError, RemoteStorageOptions } from '../utils/remoteStorage.js';
+import { WorkspaceRoot } from '../utils/workspaceRoots.js';
+import {
+  CancellationError,
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+const gzip = promisify(zlib.gzip);
+const gunzip = promisify(zlib.gunzip);
+
+/**
+ * Describes an uploaded index artifact; stored next to it as
+ * `<url>.manifest.json` and read first on pull.
+ */
+export interface RemoteIndexManifest {
+  format: typeof MANIFEST_FORMAT;
+  version: 1;
+  compression: 'gzip';
+  /** SHA-256 (hex) of the artifact as stored, compressed */
+  sha256: string;
+  compressedBytes: number;
+  /** The artifact is the compressed index, encrypted (see index/indexEncryption.ts) */
+  encrypted?: boolean;
+  /** SHA-256 of the saved index after decompression */
+  contentSha256: string;
+  bytes: number;
+  files: number;
+  roots: WorkspaceRoot[];
+  createdAt: number;
+}
+
+export interface RemoteIndexOptions extends Omit<RemoteStorageOptions, 'signal'> {
+  /** Cancellation token for aborting the transfer */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting transfer progress */
+  onProgress?: ProgressCallback;
+  /**
+   * Keys to encrypt the upload with and decrypt downloads with (default:
+   * no encryption; downloads are decrypted with SMART_INDEXER_INDEX_KEY)
+   */
+  keys?: IndexKeys;
+}
+
+export interface PushIndexResult {
+  url: string;
+  manifestUrl: string;
+  files: number;
+  bytes: number;
+  compressedBytes: number;
+  sha256: string;
+}
+
+export interface PulledIndex {
+  manifest: RemoteIndexManifest;
+  saved: DecodedSavedIndex;
+}
+
+export interface PullIndexResult {
+  url: string;
+  /** Files in the artifact */
+  files: number;
+  /** Files taken from the artifact */
+  imported: number;
+  /** Files whose local content differs from the indexed one, left to local indexing */
+  changed: number;
+  /** Files missing or unreadable locally */
+  missing: number;
+  /** Files under none of the local roots */
+  outside: number;
+  bytes: number;
+  compressedBytes: number;
+  createdAt: number;
+}
+
+/** The part of an index the sync reads and writes */
+export type RemoteIndexSourceIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileResult' | 'updateFile'>;
+
+export const MANIFEST_SUFFIX = '.manifest.json';
+
+const MANIFEST_FORMAT = 'smart-indexer-remote';
+const YIELD_INTERVAL = 50;
+
+/**
+ * Compress a saved index, upload it to url and its manifest next to it.
+ * The artifact goes first, so a manifest never names a missing artifact;
+ * a pull racing a push fails its checksum and can be retried.
+ */
+export async function pushIndex(
+  url: string,
+  data: Uint8Array,
+  info: { files: number; roots: WorkspaceRoot[] },
+  options: RemoteIndexOptions = {}
+): Promise<PushIndexResult> {
+  const { cancellationToken, onProgress, keys } = options;
+  const storage = new RemoteStorage({ ...options, signal: abortSignalFor(cancellationToken) });
+
+  onProgress?.(0, 2, 'Compressing index');
+  const gzipped = await gzip(data);
+  // Compressed first: encrypted data does not compress
+  const compressed = keys ? await keys.encrypt(gzipped) : gzipped;
+  const manifest: RemoteIndexManifest = {
+    format: MANIFEST_FORMAT,
+    version: 1,
+    compression: 'gzip',
+    sha256: sha256(compressed),
+    compressedBytes: compressed.length,
+    contentSha256: sha256(data),
+    bytes: data.length,
+    files: info.files,
+    roots: info.roots,
+    createdAt: Date.now()
+  };
+  if (keys?.encrypts) {
+    manifest.encrypted = true;
+  }
+
+  const manifestUrl = url + MANIFEST_SUFFIX;
+  await transfer(cancellationToken, async () => {
+    onProgress?.(1, 2, `Uploading ${compressed.length} bytes`);
+    await storage.put(url, compressed, 'application/gzip');
+    throwIfCancelled(cancellationToken);
+    await storage.put(manifestUrl, Buffer.from(JSON.stringify(manifest, null, 2)), 'application/json');
+  });
+  onProgress?.(2, 2, 'Index uploaded');
+
+  return { url, manifestUrl, files: info.files, bytes: data.length, compressedBytes: compressed.length, sha256: manifest.sha256 };
+}
+
+/**
+ * Download the index at url and check it against its manifest; throws on
+ * a missing index, a checksum mismatch or data that is not a saved index.
+ */
+export async function pullIndex(url: string, options: RemoteIndexOptions = {}): Promise<PulledIndex> {
+  const { cancellationToken, onProgress } = options;
+  const storage = new RemoteStorage({ ...options, signal: abortSignalFor(cancellationToken) });
+
+  onProgress?.(0, 2, 'Downloading manifest');
+  const manifestData = await transfer(cancellationToken, () => storage.get(url + MANIFEST_SUFFIX));
+  if (!manifestData) {
+    throw new RemoteStorageError(`No index at ${url} (${url + MANIFEST_SUFFIX} not found)`, 404);
+  }
+  const manifest = parseManifest(manifestData, url);
+
+  onProgress?.(1, 2, `Downloading ${manifest.compressedBytes} bytes`);
+  const compressed = await transfer(cancellationToken, () => storage.get(url));
+  if (!compressed) {
+    throw new RemoteStorageError(`No index at ${url}`, 404);
+  }
+  if (compressed.length !== manifest.compressedBytes || sha256(compressed) !== manifest.sha256) {
+    throw new RemoteStorageError(`Checksum mismatch for ${url}: the download is corrupt or was replaced while pulling`);
+  }
+  const keys = options.keys ?? new IndexKeys(undefined, [new EnvKeyProvider()]);
+  const data = await gunzip(await keys.decrypt(compressed, url));
+  if (sha256(data) !== manifest.contentSha256) {
+    throw new RemoteStorageError(`Checksum mismatch for ${url} after decompression`);
+  }
+  onProgress?.(2, 2, 'Index downloaded');
+
+  return { manifest, saved: decodeSavedIndex(data, url) };
+}
+
+/**
+ * Remote Index Sync - shares the background index through remote storage,
+ * so CI builds it once and developers pull it instead of indexing locally.
+ *
+ * A pulled index was built elsewhere: its roots are mapped to the local
+ * workspace roots (by name, then position) and each file is taken only if
+ * its content hash matches the local file. Changed files are left to the
+ * usual indexing, which then skips every file taken.
+ */
+export class RemoteIndexSync {
+  constructor(
+    private index: RemoteIndexSourceIndex,
+    private readFile: (uri: string) => Promise<string> = uri => fsPromises.readFile(uri, 'utf-8')
+  ) {}
+
+  async push(url: string, roots: WorkspaceRoot[], options: RemoteIndexOptions = {}): Promise<PushIndexResult> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles()).sort();
+    const now = Date.now();
+    const saved: SavedIndex = { format: SAVED_INDEX_FORMAT, shardVersion: SHARD_VERSION, files: [], roots };
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Encoding index (${i}/${uris.length})`);
+      }
+      const result = await this.index.getFileResult(uris[i]);
+      if (result) {
+        saved.files.push(toCompactShard({ ...result, lastIndexedAt: now }));
+      }
+    }
+
+    return pushIndex(url, encode(saved), { files: saved.files.length, roots }, { ...options, onProgress: undefined });
+  }
+
+  async pull(url: string, roots: WorkspaceRoot[], options: RemoteIndexOptions = {}): Promise<PullIndexResult> {
+    const { cancellationToken, onProgress } = options;
+    const { manifest, saved } = await pullIndex(url, { ...options, onProgress: undefined });
+    if (saved.shardVersion !== SHARD_VERSION) {
+      throw new Error(`Index ${url} has format version ${saved.shardVersion}, expected ${SHARD_VERSION}; push it again`);
+    }
+
+    const mappings = mapSavedRoots(savedRoots(saved), roots);
+    const result: PullIndexResult = {
+      url,
+      files: saved.files.length,
+      imported: 0,
+      changed: 0,
+      missing: 0,
+      outside: 0,
+      bytes: manifest.bytes,
+      compressedBytes: manifest.compressedBytes,
+      createdAt: manifest.createdAt
+    };
+
+    for (let i = 0; i < saved.files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, saved.files.length, `Importing index (${i}/${saved.files.length})`);
+      }
+      const entry = saved.files[i];
+      if (!isCompactShard(entry)) {
+        throw new Error(`Corrupt saved index: ${url}`);
+      }
+      const shard = relocateShard(entry, mappings);
+      if (!shard) {
+        result.outside++;
+        continue;
+      }
+
+      let content: string;
+      try {
+        content = await this.readFile(shard.u);
+      } catch {
+        result.missing++;
+        continue;
+      }
+      if (sha256(content) !== shard.h) {
+        result.changed++;
+        continue;
+      }
+      const { lastIndexedAt: _lastIndexedAt, mtime: _mtime, ...file } = fromCompactShard(shard);
+      await this.index.updateFile(file.uri, file);
+      result.imported++;
+    }
+    onProgress?.(saved.files.length, saved.files.length, `Imported ${result.imported} files`);
+    return result;
+  }
+}
+
+function parseManifest(data: Uint8Array, url: string): RemoteIndexManifest {
+  let manifest: Partial<RemoteIndexManifest> | null;
+  try {
+    manifest = JSON.parse(Buffer.from(data).toString('utf-8'));
+  } catch {
+    manifest = null;
+  }
+  if (
+    manifest?.format !== MANIFEST_FORMAT || manifest.compression !== 'gzip' ||
+    typeof manifest.sha256 !== 'string' || typeof manifest.contentSha256 !== 'string'
+  ) {
+    throw new RemoteStorageError(`Not an index manifest: ${url + MANIFEST_SUFFIX}`);
+  }
+  return manifest as RemoteIndexManifest;
+}
+
+/**
+ * Run a request, turning an abort by the token into CancellationError.
+ */
+async function transfer<T>(token: CancellationToken | undefined, run: () => Promise<T>): Promise<T> {
+  throwIfCancelled(token);
+  try {
+    return await run();
+  } catch (error) {
+    if (token?.isCancellationRequested) {
+      throw new CancellationError();
+    }
+    throw error;
+  }
+}
+
+function abortSignalFor(token?: CancellationToken): AbortSignal | undefined {
+  if (!token) {
+    return undefined;
+  }
+  const controller = new AbortController();
+  if (token.isCancellationRequested) {
+    controller.abort();
+  } else {
+    token.onCancellationRequested?.(() => controller.abort());
+  }
+  return controller.signal;
+}
+
+function sha256(data: Uint8Array | string): string {
+  return crypto.createHash('sha256').update(data).digest('hex');
+}
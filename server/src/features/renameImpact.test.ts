@@ -0,0 +1,173 @@
+/**
+ * RenameImpactAnalyzer Tests
+ *
+ * Verifies locations, collision detection and blast radius of renames.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { RenameImpactAnalyzer } from './renameImpact.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { PythonIndexer } from '../indexer/pythonIndexer.js';
+import { GoTemplateIndexer } from '../indexer/goTemplateIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const userGo = `package user
+
+type Person struct {
+	Name string
+	Age  int
+}
+
+func NewPerson(name string) *Person { return &Person{Name: name} }
+
+func (p *Person) Greet() string { return "hi " + p.Name }
+
+func (p *Person) greeting() string { return p.Greet() }
+
+func Lookup(id int) *Person { return nil }
+`;
+
+const storeGo = `package user
+
+func FindPerson(name string) *Person { return NewPerson(name) }
+`;
+
+const apiGo = `package api
+
+import "example.com/app/user"
+
+func Handle() string {
+	p := user.NewPerson("ann")
+	return p.Greet()
+}
+`;
+
+const helpersPy = `def load(path):
+    return open(path)
+
+
+def parse(text):
+    return text
+`;
+
+const mainPy = `from helpers import load
+
+
+def parse(data):
+    return data
+
+
+def main():
+    return load("config.json")
+`;
+
+describe('RenameImpactAnalyzer', () => {
+  let index: MockBackgroundIndex;
+  let analyzer: RenameImpactAnalyzer;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    const goIndexer = new GoIndexer();
+    for (const [uri, content] of [
+      ['/ws/user/user.go', userGo],
+      ['/ws/user/store.go', storeGo],
+      ['/ws/api/api.go', apiGo]
+    ]) {
+      const result = goIndexer.indexFile(uri, content);
+      index.addFile(uri, result.symbols, result.references);
+    }
+    const pythonIndexer = new PythonIndexer();
+    for (const [uri, content] of [
+      ['/ws/py/helpers.py', helpersPy],
+      ['/ws/py/main.py', mainPy]
+    ]) {
+      const result = pythonIndexer.parse(uri, content);
+      index.addFile(uri, result.symbols, result.references);
+    }
+    analyzer = new RenameImpactAnalyzer(index);
+  });
+
+  it('should list the definition and every reference across packages', async () => {
+    const impact = await analyzer.check('NewPerson', 'MakePerson');
+
+    expect(impact.locations.map(l => `${l.uri}:${l.line}:${l.definition}`)).toEqual([
+      '/ws/api/api.go:5:false',
+      '/ws/user/store.go:2:false',
+      '/ws/user/user.go:7:true'
+    ]);
+    expect(impact.collisions).toEqual([]);
+    expect(impact.problems).toEqual([]);
+    expect(impact.safe).toBe(true);
+    expect(impact.blastRadius).toEqual({
+      files: 3,
+      packages: [
+        { dir: '/ws/user', locations: 2 },
+        { dir: '/ws/api', locations: 1 }
+      ],
+      crossPackageReferences: 1,
+      risk: 'medium'
+    });
+  });
+
+  it('should detect a member collision on the same type', async () => {
+    const impact = await analyzer.check('Person.greeting', 'Greet');
+
+    expect(impact.collisions).toHaveLength(1);
+    expect(impact.collisions[0]).toMatchObject({
+      kind: 'member',
+      name: 'Greet',
+      containerName: 'Person',
+      uri: '/ws/user/user.go'
+    });
+    expect(impact.safe).toBe(false);
+    expect(impact.blastRadius.risk).toBe('high');
+  });
+
+  it('should detect package-level collisions in any file of a Go package', async () => {
+    const impact = await analyzer.check('Lookup', 'FindPerson');
+
+    expect(impact.collisions.map(c => [c.kind, c.uri])).toEqual([['scope', '/ws/user/store.go']]);
+    expect(impact.collisions[0].message).toBe('Package user already declares FindPerson');
+  });
+
+  it('should detect a shadowed import in a file referencing the old name', async () => {
+    const impact = await analyzer.check('load', 'parse');
+
+    const kinds = impact.collisions.map(c => `${c.kind}:${c.uri}`).sort();
+    expect(kinds).toEqual(['scope:/ws/py/helpers.py', 'shadow:/ws/py/main.py']);
+  });
+
+  it('should report unexporting a Go name used by other packages', async () => {
+    const impact = await analyzer.check('NewPerson', 'newPerson');
+
+    expect(impact.problems).toEqual([
+      'Renaming to newPerson unexports it; 1 reference(s) from other packages would break'
+    ]);
+    expect(impact.safe).toBe(false);
+
+    // Unexporting a name used only within its package is fine
+    const internal = await analyzer.check('Lookup', 'lookup');
+    expect(internal.problems).toEqual([]);
+    expect(internal.blastRadius.risk).toBe('low');
+  });
+
+  it('should report Go templates using a renamed field', async () => {
+    const uri = '/ws/web/templates/user.tmpl';
+    const result = new GoTemplateIndexer().parse(uri, '<h1>{{ .Name }}</h1>\n{{ .Greet }}\n');
+    index.addFile(uri, result.symbols, result.references);
+
+    const impact = await analyzer.check('Person.Name', 'FullName');
+    expect(impact.locations.filter(l => l.uri === uri).map(l => [l.line, l.character])).toEqual([[0, 8]]);
+    expect(impact.problems).toEqual(['Used by 1 Go template(s) (user.tmpl), which fail only when executed']);
+    expect(impact.safe).toBe(false);
+  });
+
+  it('should reject invalid names and unknown symbols', async () => {
+    expect((await analyzer.check('Greet', 'not-valid')).problems).toEqual(['"not-valid" is not a valid identifier']);
+    expect((await analyzer.check('Greet', 'Greet')).problems).toEqual(['The new name is the same as the old one']);
+
+    const missing = await analyzer.check('Missing', 'Found');
+    expect(missing.problems).toEqual(['No definition of Missing in the index']);
+    expect(missing.locations).toEqual([]);
+  });
+});
@@ -0,0 +1,299 @@
+/**
+ * Rename impact - what renaming a symbol would touch, before doing it.
+ *
+ * Lists every definition and reference the reference index knows for the
+ * old name, finds declarations the new name would collide with, and sizes
+ * the change by the packages (directories) it reaches. A precursor to
+ * automated refactoring: nothing is edited here.
+ */
+
+import * as path from 'path';
+import { IndexedReference, IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata, isGoExported } from '../indexer/goIndexer.js';
+import { isGoTemplateFile } from '../indexer/goTemplateIndexer.js';
+
+/** The part of an index the check reads: the merged index or the library Indexer */
+export interface RenameImpactIndex {
+  findDefinitions(name: string): Promise<IndexedSymbol[]>;
+  findReferencesByName(name: string): Promise<IndexedReference[]>;
+}
+
+export interface RenameLocation {
+  uri: string;
+  line: number;
+  character: number;
+  /** True for declarations, false for references */
+  definition: boolean;
+  containerName?: string;
+}
+
+export type RenameCollisionKind =
+  /** The container already has a member with the new name */
+  | 'member'
+  /** The package (Go) or module already declares the new name */
+  | 'scope'
+  /** A file referring to the old name declares the new name itself */
+  | 'shadow';
+
+export interface RenameCollision {
+  kind: RenameCollisionKind;
+  /** The existing declaration of the new name */
+  name: string;
+  symbolKind: string;
+  uri: string;
+  line: number;
+  character: number;
+  containerName?: string;
+  message: string;
+}
+
+export type RenameRisk = 'low' | 'medium' | 'high';
+
+export interface RenameBlastRadius {
+  files: number;
+  /** Directories with locations to change, most affected first */
+  packages: Array<{ dir: string; locations: number }>;
+  /** References outside the packages declaring the symbol */
+  crossPackageReferences: number;
+  risk: RenameRisk;
+}
+
+export interface RenameImpact {
+  oldName: string;
+  newName: string;
+  /** Definitions and references, by file and position */
+  locations: RenameLocation[];
+  collisions: RenameCollision[];
+  /** Reasons the rename is invalid or likely to break code */
+  problems: string[];
+  blastRadius: RenameBlastRadius;
+  /** No collisions and no problems */
+  safe: boolean;
+}
+
+const IDENTIFIER = /^[\p{L}_$][\p{L}\p{N}_$]*$/u;
+
+/** More packages than this make a rename high-risk */
+const HIGH_RISK_PACKAGES = 10;
+
+export class RenameImpactAnalyzer {
+  constructor(private index: RenameImpactIndex) {}
+
+  /**
+   * Check renaming oldName (`Name`, or `Container.Name` for one type's
+   * member) to newName.
+   */
+  async check(oldName: string, newName: string): Promise<RenameImpact> {
+    const { container, name } = splitQualifiedName(oldName);
+    const problems: string[] = [];
+
+    if (!IDENTIFIER.test(newName)) {
+      problems.push(`"${newName}" is not a valid identifier`);
+    } else if (newName === name) {
+      problems.push('The new name is the same as the old one');
+    }
+
+    const definitions = (await this.index.findDefinitions(name)).filter(symbol =>
+      isDeclaration(symbol) && (container === undefined || symbol.containerName === container)
+    );
+    if (definitions.length === 0) {
+      problems.push(`No definition of ${oldName} in the index`);
+    } else if (definitions.length > 1) {
+      // References carry a name, not the declaration they resolve to
+      problems.push(`${definitions.length} definitions named ${oldName}; references to all of them are listed`);
+    }
+    for (const definition of definitions) {
+      if (isThirdParty(definition)) {
+        problems.push(`${oldName} is declared in a dependency (${definition.location.uri})`);
+      }
+    }
+
+    // Locals with the same name are other variables
+    const references = (await this.index.findReferencesByName(name)).filter(reference => !reference.isLocal);
+    const locations = collectLocations(definitions, references);
+
+    const definitionDirs = new Set(definitions.map(symbol => path.dirname(symbol.location.uri)));
+    const crossPackage = references.filter(reference => !definitionDirs.has(path.dirname(reference.location.uri)));
+
+    // Go: the first letter decides visibility
+    const goDefinitions = definitions.filter(symbol => symbol.metadata?.go);
+    if (goDefinitions.length > 0 && IDENTIFIER.test(newName) && isGoExported(name) && !isGoExported(newName) &&
+        crossPackage.length > 0) {
+      problems.push(
+        `Renaming to ${newName} unexports it; ${crossPackage.length} reference(s) from other packages would break`
+      );
+    }
+
+    // Templates are checked when they run, not when the Go code builds
+    const templateFiles = new Set(goDefinitions.length > 0
+      ? references.filter(reference => isGoTemplateFile(reference.location.uri)).map(reference => reference.location.uri)
+      : []);
+    if (templateFiles.size > 0) {
+      const names = [...templateFiles].map(file => path.basename(file)).sort();
+      problems.push(`Used by ${templateFiles.size} Go template(s) (${names.join(', ')}), which fail only when executed`);
+    }
+
+    const collisions = IDENTIFIER.test(newName) && newName !== name
+      ? await this.findCollisions(definitions, references, newName)
+      : [];
+
+    return {
+      oldName,
+      newName,
+      locations,
+      collisions,
+      problems,
+      blastRadius: blastRadius(locations, crossPackage.length, collisions.length),
+      safe: collisions.length === 0 && problems.length === 0
+    };
+  }
+
+  private async findCollisions(
+    definitions: IndexedSymbol[],
+    references: IndexedReference[],
+    newName: string
+  ): Promise<RenameCollision[]> {
+    const existing = (await this.index.findDefinitions(newName)).filter(isDeclaration);
+    const collisions = new Map<string, RenameCollision>();
+    const add = (kind: RenameCollisionKind, symbol: IndexedSymbol, message: string) => {
+      const key = `${symbol.location.uri}:${symbol.location.line}:${symbol.location.character}`;
+      if (!collisions.has(key)) {
+        collisions.set(key, {
+          kind,
+          name: symbol.name,
+          symbolKind: symbol.kind,
+          uri: symbol.location.uri,
+          line: symbol.location.line,
+          character: symbol.location.character,
+          ...(symbol.containerName && { containerName: symbol.containerName }),
+          message
+        });
+      }
+    };
+
+    for (const definition of definitions) {
+      for (const symbol of existing) {
+        if (definition.containerName) {
+          if (symbol.containerName === definition.containerName && sameScope(symbol, definition)) {
+            add('member', symbol, `${definition.containerName} already has a member named ${newName}`);
+          }
+        } else if (!symbol.containerName && sameScope(symbol, definition)) {
+          add('scope', symbol, `${scopeLabel(definition)} already declares ${newName}`);
+        }
+      }
+    }
+
+    // An imported name clashes with a declaration of the new name in the
+    // importing module. Go references from other packages are qualified
+    // (pkg.Name), so only the package itself matters there.
+    if (definitions.some(definition => !definition.containerName && !isGoSymbol(definition))) {
+      const referencingFiles = new Set(references.map(reference => reference.location.uri));
+      for (const symbol of existing) {
+        if (!symbol.containerName && !isGoSymbol(symbol) && referencingFiles.has(symbol.location.uri)) {
+          add('shadow', symbol, `${path.basename(symbol.location.uri)} refers to the old name and already declares ${newName}`);
+        }
+      }
+    }
+
+    return [...collisions.values()];
+  }
+}
+
+function collectLocations(definitions: IndexedSymbol[], references: IndexedReference[]): RenameLocation[] {
+  const locations = new Map<string, RenameLocation>();
+  const add = (location: RenameLocation) => {
+    const key = `${location.uri}:${location.line}:${location.character}`;
+    if (!locations.has(key)) {
+      locations.set(key, location);
+    }
+  };
+
+  for (const symbol of definitions) {
+    add({
+      ...symbol.location,
+      definition: true,
+      ...(symbol.containerName && { containerName: symbol.containerName })
+    });
+  }
+  for (const reference of references) {
+    add({
+      ...reference.location,
+      definition: false,
+      ...(reference.containerName && { containerName: reference.containerName })
+    });
+  }
+
+  return [...locations.values()].sort((a, b) =>
+    a.uri.localeCompare(b.uri) || a.line - b.line || a.character - b.character
+  );
+}
+
+function blastRadius(locations: RenameLocation[], crossPackageReferences: number, collisions: number): RenameBlastRadius {
+  const byDir = new Map<string, number>();
+  for (const location of locations) {
+    const dir = path.dirname(location.uri);
+    byDir.set(dir, (byDir.get(dir) ?? 0) + 1);
+  }
+  const packages = [...byDir.entries()]
+    .map(([dir, count]) => ({ dir, locations: count }))
+    .sort((a, b) => b.locations - a.locations || a.dir.localeCompare(b.dir));
+
+  let risk: RenameRisk = 'low';
+  if (collisions > 0 || packages.length > HIGH_RISK_PACKAGES) {
+    risk = 'high';
+  } else if (packages.length > 1) {
+    risk = 'medium';
+  }
+
+  return {
+    files: new Set(locations.map(location => location.uri)).size,
+    packages,
+    crossPackageReferences,
+    risk
+  };
+}
+
+function splitQualifiedName(name: string): { container?: string; name: string } {
+  const dot = name.lastIndexOf('.');
+  if (dot <= 0 || dot === name.length - 1) {
+    return { name };
+  }
+  return { container: name.slice(0, dot).replace(/^\(\*?(.*)\)$/, '$1'), name: name.slice(dot + 1) };
+}
+
+function isDeclaration(symbol: IndexedSymbol): boolean {
+  return symbol.isDefinition !== false && symbol.kind !== 'text';
+}
+
+function isThirdParty(symbol: IndexedSymbol): boolean {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  return go?.thirdParty === true || /[\\/]node_modules[\\/]/.test(symbol.location.uri);
+}
+
+function isGoSymbol(symbol: IndexedSymbol): boolean {
+  return symbol.metadata?.go !== undefined || isGoUri(symbol.location.uri);
+}
+
+function isGoUri(uri: string): boolean {
+  const ext = path.extname(uri);
+  return ext === '.go' || ext === '.s';
+}
+
+/**
+ * Go names are package-scoped (a directory); elsewhere a module is a file.
+ */
+function scopeOf(uri: string, go: boolean): string {
+  return go ? path.dirname(uri) : uri;
+}
+
+function sameScope(a: IndexedSymbol, b: IndexedSymbol): boolean {
+  return scopeOf(a.location.uri, isGoSymbol(a)) === scopeOf(b.location.uri, isGoSymbol(b));
+}
+
+function scopeLabel(symbol: IndexedSymbol): string {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  if (isGoSymbol(symbol)) {
+    return `Package ${go?.package ?? path.basename(path.dirname(symbol.location.uri))}`;
+  }
+  return path.basename(symbol.location.uri);
+}
@@ -0,0 +1,146 @@
+/**
+ * SARIF Tests
+ *
+ * Verifies the SARIF log written for dead code, policy, deprecation and
+ * metric findings: paths relative to the source root, rule indexes,
+ * levels, regions and fingerprints that survive moved lines.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { deadCodeFindings, deprecationFindings, metricFindings, policyFindings, toSarif } from './sarif.js';
+import { Deprecations } from './deprecations.js';
+import { PolicyReport } from './policyCheck.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+
+const legacyGo = `package legacy
+
+// Deprecated: use store.Lookup.
+func Get(key string) string {
+	return ""
+}
+`;
+
+const billingGo = `package billing
+
+import "example.com/app/legacy"
+
+func Charge() {
+	legacy.Get("rate")
+	legacy.Get("currency")
+}
+`;
+
+function symbol(name: string, line: number, containerName?: string): IndexedSymbol {
+  return {
+    id: name,
+    name,
+    kind: containerName ? 'method' : 'function',
+    containerName,
+    filePath: '/ws/store/store.go',
+    location: { uri: '/ws/store/store.go', line, character: 5 },
+    range: { startLine: line, startCharacter: 0, endLine: line + 3, endCharacter: 1 }
+  };
+}
+
+describe('SARIF', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports });
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    addGoFile('/ws/legacy/legacy.go', legacyGo);
+    addGoFile('/ws/billing/billing.go', billingGo);
+  });
+
+  it('should write one run per analysis with relative paths and rule indexes', () => {
+    const log = toSarif([
+      deadCodeFindings({
+        symbols: [
+          { symbol: symbol('flush', 9), exported: false, reason: 'never-referenced', confidence: 'high' },
+          { symbol: symbol('Close', 20, 'Store'), exported: true, reason: 'only-dead-callers', confidence: 'low' }
+        ],
+        checkedSymbols: 12,
+        analyzedFiles: 3
+      })
+    ], '/ws', { toolVersion: '1.87.0' });
+
+    expect(log.version).toBe('2.1.0');
+    const [run] = log.runs;
+    expect(run.automationDetails.id).toBe('dead-code/');
+    expect(run.tool.driver).toMatchObject({ name: 'smart-indexer', version: '1.87.0' });
+    expect(run.originalUriBaseIds['%SRCROOT%'].uri).toMatch(/^file:\/\/.*\/ws\/$/);
+    expect(run.results.map(r => [r.ruleId, r.ruleIndex, r.level, r.message.text])).toEqual([
+      ['dead-code/never-referenced', 0, 'warning', 'function flush is never referenced (high confidence)'],
+      ['dead-code/only-dead-callers', 1, 'note', 'Exported method Store.Close is only referenced from unused code (low confidence)']
+    ]);
+    expect(run.results[0].locations[0]).toEqual({
+      physicalLocation: {
+        artifactLocation: { uri: 'store/store.go', uriBaseId: '%SRCROOT%' },
+        region: { startLine: 10, startColumn: 6 }
+      },
+      logicalLocations: [{ fullyQualifiedName: 'flush' }]
+    });
+  });
+
+  it('should keep fingerprints when lines move and count repeated findings', async () => {
+    const report = await new Deprecations(index.asBackgroundIndex(), { ownersOf: () => [] }).report();
+    const analysis = deprecationFindings(report);
+
+    const before = toSarif([analysis], '/ws').runs[0].results;
+    expect(before.map(r => [r.message.text, r.locations[0].physicalLocation.region.startLine])).toEqual([
+      ['Call of deprecated function Get: use store.Lookup.', 6],
+      ['Call of deprecated function Get: use store.Lookup.', 7]
+    ]);
+    expect(before[0].locations[0].logicalLocations).toEqual([{ fullyQualifiedName: 'Charge' }]);
+
+    const moved = { ...analysis, findings: analysis.findings.map(f => ({ ...f, line: f.line + 4 })) };
+    const after = toSarif([moved], '/ws').runs[0].results;
+    const fingerprints = (results: typeof before) => results.map(r => r.partialFingerprints['smartIndexer/v1']);
+    expect(fingerprints(after)).toEqual(fingerprints(before));
+    expect(new Set(fingerprints(before)).size).toBe(2);
+  });
+
+  it('should report policy severities and functions reaching metric thresholds', () => {
+    const policies: PolicyReport = {
+      root: '/ws',
+      policies: ['exported-docs', 'complexity'],
+      filesChecked: 1,
+      violations: [
+        { policy: 'exported-docs', rule: 'exported-doc', severity: 'error', file: '/ws/store/store.go', line: 9, character: 5,
+          message: 'Exported function Close has no doc comment', symbol: 'Close' },
+        { policy: 'complexity', rule: 'max-metric', severity: 'warning', file: '/other/gen.go', line: 0, character: 0,
+          message: 'route has complexity 31, above the maximum of 25' }
+      ],
+      errors: 1,
+      warnings: 1,
+      passed: false
+    };
+    const metrics = metricFindings({
+      functions: [
+        { symbol: symbol('route', 3), complexity: 31, loc: 80, parameters: 2, nesting: 3 },
+        { symbol: symbol('Get', 12, 'Store'), complexity: 4, loc: 120, parameters: 1, nesting: 1 }
+      ],
+      functionsAnalyzed: 2,
+      matched: 2,
+      filesScanned: 1,
+      truncated: false
+    }, { complexity: 25, loc: 100 });
+
+    const [policyRun, metricRun] = toSarif([policyFindings(policies), metrics], '/ws').runs;
+    expect(policyRun.tool.driver.rules.map(r => [r.id, r.defaultConfiguration.level])).toEqual([['exported-docs', 'error'], ['complexity', 'warning']]);
+    expect(policyRun.results[1].locations[0].physicalLocation.artifactLocation).toEqual({ uri: 'file:///other/gen.go' });
+    expect(metricRun.results.map(r => `${r.ruleId}: ${r.message.text}`)).toEqual([
+      'metrics/complexity: route has complexity 31 (threshold 25)',
+      'metrics/loc: Store.Get has loc 120 (threshold 100)'
+    ]);
+    expect(() => metricFindings({ functions: [], functionsAnalyzed: 0, matched: 0, filesScanned: 0, truncated: false }, { nesting: -1 }))
+      .toThrow('Invalid threshold for nesting: -1');
+  });
+});
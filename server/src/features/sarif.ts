@@ -0,0 +1,313 @@
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { pathToFileURL } from 'url';
+import { IndexedSymbol } from '../types.js';
+import { UnreferencedSymbolReport } from './deadCode.js';
+import { DeprecationReport } from './deprecations.js';
+import { CODE_METRICS, CodeMetric, CodeMetricsReport } from './codeMetrics.js';
+import { POLICY_RULES, PolicyReport, PolicyRule } from './policyCheck.js';
+
+export const SARIF_VERSION = '2.1.0';
+export const SARIF_SCHEMA = 'https://json.schemastore.org/sarif-2.1.0.json';
+
+/** Analyses that findings can be exported from */
+export const SARIF_ANALYSES = ['dead-code', 'policies', 'deprecations', 'metrics'] as const;
+export type SarifAnalysisName = typeof SARIF_ANALYSES[number];
+
+export type SarifLevel = 'error' | 'warning' | 'note';
+
+/** Functions the metrics analysis reports by default */
+export const DEFAULT_METRIC_THRESHOLDS: Partial<Record<CodeMetric, number>> = { complexity: 25 };
+
+export interface SarifRule {
+  /** E.g. `dead-code/never-referenced` */
+  id: string;
+  description: string;
+  /** Level of findings that give none */
+  level: SarifLevel;
+}
+
+export interface SarifFinding {
+  ruleId: string;
+  level?: SarifLevel;
+  message: string;
+  /** Absolute path */
+  file: string;
+  /** 0-based, like index locations */
+  line: number;
+  character: number;
+  endLine?: number;
+  endCharacter?: number;
+  /** Qualified name of the code it is in or about, e.g. `Store.Get` */
+  symbol?: string;
+  /** What identifies it across edits (default: the symbol, else the message) */
+  key?: string;
+}
+
+/** The findings of one analysis, written as one SARIF run */
+export interface SarifAnalysis {
+  /** Run category, so code scanning keeps each analysis's alerts apart */
+  category: SarifAnalysisName | string;
+  rules: SarifRule[];
+  findings: SarifFinding[];
+}
+
+export interface SarifOptions {
+  /** Tool name shown with alerts (default: `smart-indexer`) */
+  toolName?: string;
+  toolVersion?: string;
+}
+
+/** Static Analysis Results Interchange Format 2.1.0, as much of it as is written */
+export interface SarifLog {
+  $schema: string;
+  version: typeof SARIF_VERSION;
+  runs: SarifRun[];
+}
+
+export interface SarifRun {
+  tool: {
+    driver: {
+      name: string;
+      version?: string;
+      informationUri: string;
+      rules: Array<{
+        id: string;
+        shortDescription: { text: string };
+        defaultConfiguration: { level: SarifLevel };
+      }>;
+    };
+  };
+  automationDetails: { id: string };
+  originalUriBaseIds: Record<string, { uri: string }>;
+  results: SarifResult[];
+}
+
+export interface SarifResult {
+  ruleId: string;
+  ruleIndex: number;
+  level: SarifLevel;
+  message: { text: string };
+  locations: Array<{
+    physicalLocation: {
+      artifactLocation: { uri: string; uriBaseId?: string };
+      region: { startLine: number; startColumn: number; endLine?: number; endColumn?: number };
+    };
+    logicalLocations?: Array<{ fullyQualifiedName: string }>;
+  }>;
+  partialFingerprints: Record<string, string>;
+}
+
+const SOURCE_ROOT = '%SRCROOT%';
+const INFORMATION_URI = 'https://github.com/p-sternik/smart-indexer';
+const FINGERPRINT = 'smartIndexer/v1';
+
+const POLICY_RULE_DESCRIPTIONS: Record<PolicyRule, string> = {
+  'exported-doc': 'Exported symbols have a doc comment',
+  'forbidden-import': 'No imports of forbidden packages',
+  'max-metric': 'No functions above a metric maximum'
+};
+
+/**
+ * A SARIF log of analysis findings for GitHub code scanning and other
+ * tools that show them inline on pull requests, one run per analysis.
+ * Paths are relative to root (`%SRCROOT%`, the repository checkout);
+ * files outside it keep absolute `file:` URIs.
+ *
+ * Each result has a fingerprint of its rule, file and key (symbol or
+ * message) plus an occurrence count, so an alert stays the same alert
+ * when code above it moves.
+ */
+export function toSarif(analyses: SarifAnalysis[], root: string, options: SarifOptions = {}): SarifLog {
+  const base = path.resolve(root);
+  let baseUri = pathToFileURL(base).href;
+  if (!baseUri.endsWith('/')) {
+    baseUri += '/';
+  }
+  return {
+    $schema: SARIF_SCHEMA,
+    version: SARIF_VERSION,
+    runs: analyses.map(analysis => {
+      const ruleIndex = new Map(analysis.rules.map((rule, i) => [rule.id, i]));
+      const occurrences = new Map<string, number>();
+      return {
+        tool: {
+          driver: {
+            name: options.toolName ?? 'smart-indexer',
+            ...(options.toolVersion && { version: options.toolVersion }),
+            informationUri: INFORMATION_URI,
+            rules: analysis.rules.map(rule => ({
+              id: rule.id,
+              shortDescription: { text: rule.description },
+              defaultConfiguration: { level: rule.level }
+            }))
+          }
+        },
+        automationDetails: { id: `${analysis.category}/` },
+        originalUriBaseIds: { [SOURCE_ROOT]: { uri: baseUri } },
+        results: analysis.findings.map(finding => {
+          const index = ruleIndex.get(finding.ruleId);
+          if (index === undefined) {
+            throw new Error(`Finding of unknown rule "${finding.ruleId}" in ${analysis.category}`);
+          }
+          const relative = path.relative(base, finding.file);
+          const inside = !relative.startsWith('..') && !path.isAbsolute(relative);
+          const artifactLocation = inside
+            ? { uri: relative.split(path.sep).join('/'), uriBaseId: SOURCE_ROOT }
+            : { uri: pathToFileURL(finding.file).href };
+          const hash = crypto.createHash('sha256')
+            .update(`${finding.ruleId}\0${artifactLocation.uri}\0${finding.key ?? finding.symbol ?? finding.message}`)
+            .digest('hex')
+            .slice(0, 32);
+          const occurrence = occurrences.get(hash) ?? 0;
+          occurrences.set(hash, occurrence + 1);
+          return {
+            ruleId: finding.ruleId,
+            ruleIndex: index,
+            level: finding.level ?? analysis.rules[index].level,
+            message: { text: finding.message },
+            locations: [{
+              physicalLocation: {
+                artifactLocation,
+                region: {
+                  startLine: finding.line + 1,
+                  startColumn: finding.character + 1,
+                  ...(finding.endLine !== undefined && { endLine: finding.endLine + 1 }),
+                  ...(finding.endCharacter !== undefined && { endColumn: finding.endCharacter + 1 })
+                }
+              },
+              ...(finding.symbol && { logicalLocations: [{ fullyQualifiedName: finding.symbol }] })
+            }],
+            partialFingerprints: { [FINGERPRINT]: `${hash}:${occurrence + 1}` }
+          };
+        })
+      };
+    })
+  };
+}
+
+/**
+ * Unreferenced symbols (see features/deadCode.ts): warnings when the
+ * detector is confident, notes otherwise.
+ */
+export function deadCodeFindings(report: UnreferencedSymbolReport): SarifAnalysis {
+  return {
+    category: 'dead-code',
+    rules: [
+      { id: 'dead-code/never-referenced', description: 'Symbol is never referenced', level: 'warning' },
+      { id: 'dead-code/only-dead-callers', description: 'Symbol is only referenced from unused code', level: 'warning' }
+    ],
+    findings: report.symbols.map(({ symbol, reason, confidence, exported }) => ({
+      ruleId: `dead-code/${reason}`,
+      level: confidence === 'high' ? 'warning' : 'note',
+      message: `${exported ? 'Exported ' : ''}${symbol.kind} ${qualifiedName(symbol)} is ` +
+        `${reason === 'never-referenced' ? 'never referenced' : 'only referenced from unused code'} (${confidence} confidence)`,
+      file: symbol.location.uri,
+      line: symbol.location.line,
+      character: symbol.location.character,
+      symbol: qualifiedName(symbol)
+    }))
+  };
+}
+
+/**
+ * Policy violations (see features/policyCheck.ts), one rule per policy
+ * with a violation; warning policies stay warnings.
+ */
+export function policyFindings(report: PolicyReport): SarifAnalysis {
+  const rules = new Map<string, SarifRule>();
+  for (const violation of report.violations) {
+    if (!rules.has(violation.policy)) {
+      rules.set(violation.policy, {
+        id: violation.policy,
+        description: POLICY_RULES.includes(violation.rule) ? POLICY_RULE_DESCRIPTIONS[violation.rule] : violation.rule,
+        level: violation.severity
+      });
+    }
+  }
+  return {
+    category: 'policies',
+    rules: [...rules.values()],
+    findings: report.violations.map(violation => ({
+      ruleId: violation.policy,
+      level: violation.severity,
+      message: violation.message,
+      file: violation.file,
+      line: violation.line,
+      character: violation.character,
+      ...(violation.symbol && { symbol: violation.symbol })
+    }))
+  };
+}
+
+/**
+ * Uses of deprecated symbols (see features/deprecations.ts), one finding
+ * per call site with the deprecation notice.
+ */
+export function deprecationFindings(report: DeprecationReport): SarifAnalysis {
+  const findings: SarifFinding[] = [];
+  for (const { symbol, notice, callSites } of report.deprecated) {
+    for (const site of callSites) {
+      findings.push({
+        ruleId: 'deprecated-use',
+        message: `${site.isCall ? 'Call of' : 'Use of'} deprecated ${symbol.kind} ${qualifiedName(symbol)}${notice ? `: ${notice}` : ''}`,
+        file: site.uri,
+        line: site.range.startLine,
+        character: site.range.startCharacter,
+        endLine: site.range.endLine,
+        endCharacter: site.range.endCharacter,
+        ...(site.enclosing && { symbol: site.enclosing }),
+        key: `${site.enclosing ?? ''}\0${qualifiedName(symbol)}`
+      });
+    }
+  }
+  return {
+    category: 'deprecations',
+    rules: [{ id: 'deprecated-use', description: 'Code uses a deprecated symbol', level: 'warning' }],
+    findings
+  };
+}
+
+/**
+ * Functions reaching a metric threshold, e.g. `{ complexity: 25 }`, one
+ * finding per metric they reach. The report should hold every function:
+ * build it without minimums or a limit (see features/codeMetrics.ts).
+ */
+export function metricFindings(report: CodeMetricsReport, thresholds: Partial<Record<CodeMetric, number>>): SarifAnalysis {
+  const limits = CODE_METRICS
+    .filter(metric => thresholds[metric] !== undefined)
+    .map(metric => [metric, thresholds[metric]!] as const);
+  for (const [metric, threshold] of limits) {
+    if (!(threshold >= 0)) {
+      throw new Error(`Invalid threshold for ${metric}: ${threshold}`);
+    }
+  }
+  const findings: SarifFinding[] = [];
+  for (const entry of report.functions) {
+    for (const [metric, threshold] of limits) {
+      if (entry[metric] >= threshold) {
+        findings.push({
+          ruleId: `metrics/${metric}`,
+          message: `${qualifiedName(entry.symbol)} has ${metric} ${entry[metric]} (threshold ${threshold})`,
+          file: entry.symbol.location.uri,
+          line: entry.symbol.location.line,
+          character: entry.symbol.location.character,
+          symbol: qualifiedName(entry.symbol)
+        });
+      }
+    }
+  }
+  return {
+    category: 'metrics',
+    rules: limits.map(([metric, threshold]) => ({
+      id: `metrics/${metric}`,
+      description: `Function ${metric} reaches ${threshold}`,
+      level: 'warning'
+    })),
+    findings
+  };
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+}
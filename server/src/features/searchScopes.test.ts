@@ -0,0 +1,92 @@
+/**
+ * SearchScopes Tests
+ *
+ * Verifies how scopes restrict queries, how saved queries resolve, and
+ * the errors for unknown and invalid definitions.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ScopeError, SearchScopes } from './searchScopes.js';
+import { StructuredQuery } from './structuredQuery.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestReference, createTestSymbol } from '../test/mocks/MockIndex.js';
+import { QuerySyntaxError } from '../utils/queryLanguage.js';
+
+const scopes = new SearchScopes({
+  scopes: {
+    backend: 'file:services/** -tag:generated',
+    'public-api': 'file:pkg/api/** exported:true',
+    broken: 'kind:func (exported:true'
+  },
+  queries: {
+    'untested-api': { query: 'kind:func coverage:0', scope: 'public-api', description: 'Exported functions no test runs' },
+    handlers: 'signature:func(http.ResponseWriter,*http.Request)',
+    empty: { query: ' ' },
+    invalid: 'kind:func OR'
+  }
+});
+
+describe('SearchScopes', () => {
+  it('should restrict queries to a scope', () => {
+    expect(scopes.restrict('kind:func', 'backend')).toBe('(kind:func) (file:services/** -tag:generated)');
+    expect(scopes.restrict('Greet OR Hello', 'public-api')).toBe('(Greet OR Hello) (file:pkg/api/** exported:true)');
+    expect(scopes.restrict('kind:func')).toBe('kind:func');
+  });
+
+  it('should resolve saved queries in their scope or another', () => {
+    expect(scopes.resolve('untested-api')).toBe('(kind:func coverage:0) (file:pkg/api/** exported:true)');
+    expect(scopes.resolve('untested-api', 'backend')).toBe('(kind:func coverage:0) (file:services/** -tag:generated)');
+    expect(scopes.resolve('handlers')).toBe('signature:func(http.ResponseWriter,*http.Request)');
+    expect(scopes.savedQuery('untested-api')).toEqual({
+      name: 'untested-api',
+      query: 'kind:func coverage:0',
+      scope: 'public-api',
+      description: 'Exported functions no test runs'
+    });
+  });
+
+  it('should report unknown and invalid definitions', () => {
+    expect(() => scopes.filter('frontend')).toThrow(ScopeError);
+    expect(() => scopes.filter('frontend')).toThrow('Unknown scope "frontend" (defined: backend, broken, public-api)');
+    expect(() => new SearchScopes().filter('backend')).toThrow('Unknown scope "backend" (none defined)');
+    expect(() => scopes.filter('broken')).toThrow('Scope "broken": Unbalanced "(" at position 10');
+    expect(() => scopes.filter('constructor')).toThrow('Unknown scope "constructor"');
+    expect(() => scopes.resolve('missing')).toThrow('Unknown saved query "missing" (defined: empty, handlers, invalid, untested-api)');
+    expect(() => scopes.resolve('empty')).toThrow('Saved query "empty" has no query');
+    expect(() => scopes.resolve('invalid')).toThrow(ScopeError);
+
+    // Errors in the query itself keep their position in it
+    expect(() => scopes.restrict('kind:func OR', 'backend')).toThrow(QuerySyntaxError);
+    expect(() => scopes.restrict('kind:func OR', 'backend')).toThrow('at position 12');
+  });
+
+  it('should list definitions even when some are invalid', () => {
+    expect(scopes.scopes().map(scope => scope.name)).toEqual(['backend', 'broken', 'public-api']);
+    expect(scopes.savedQueries().map(query => [query.name, query.query])).toEqual([
+      ['empty', ' '],
+      ['handlers', 'signature:func(http.ResponseWriter,*http.Request)'],
+      ['invalid', 'kind:func OR'],
+      ['untested-api', 'kind:func coverage:0']
+    ]);
+  });
+});
+
+describe('StructuredQuery.filter', () => {
+  it('should keep the symbols matching a scope filter, in their order', async () => {
+    const background = new MockBackgroundIndex();
+    const used = createTestSymbol({ id: 'used', name: 'Used', filePath: '/ws/pkg/api/used.go', isExported: true });
+    const unused = createTestSymbol({ id: 'unused', name: 'Unused', filePath: '/ws/pkg/api/unused.go', isExported: true });
+    const local = createTestSymbol({ id: 'local', name: 'local', filePath: '/ws/pkg/api/local.go' });
+    const other = createTestSymbol({ id: 'other', name: 'Other', filePath: '/ws/cmd/other.go', isExported: true });
+    for (const symbol of [used, unused, local, other]) {
+      symbol.location = { uri: symbol.filePath, line: 0, character: 0 };
+      background.addFile(symbol.filePath, [symbol], []);
+    }
+    background.addFile('/ws/cmd/main.go', [], [createTestReference({ symbolName: 'Used' })]);
+    const query = new StructuredQuery(background.asBackgroundIndex());
+
+    const candidates = [other, unused, local, used];
+    expect((await query.filter(candidates, scopes.filter('public-api'))).map(s => s.name)).toEqual(['Unused', 'Used']);
+    expect((await query.filter(candidates, 'exported:true refs:>0')).map(s => s.name)).toEqual(['Used']);
+  });
+});
@@ -0,0 +1,139 @@
+import { SavedQueryConfig, SearchConfig } from '../config/configurationManager.js';
+import { parseQuery, QuerySyntaxError } from '../utils/queryLanguage.js';
+
+/**
+ * An unknown scope or saved query, or one whose definition is invalid.
+ */
+export class ScopeError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = 'ScopeError';
+  }
+}
+
+export interface ScopeOptions {
+  /** Name of a scope of the `search.scopes` config */
+  scope?: string;
+}
+
+export interface SearchScope {
+  name: string;
+  /** Query language filter of the scope */
+  filter: string;
+}
+
+export interface SavedQuery extends SavedQueryConfig {
+  name: string;
+}
+
+/** The scopes and saved queries of the search config */
+export type ScopesConfig = Pick<SearchConfig, 'scopes' | 'queries'>;
+
+/**
+ * Search Scopes - the named scopes and saved queries of the `search`
+ * config, from the config file or settings:
+ *
+ *   search:
+ *     scopes:
+ *       backend: "file:services/** -tag:generated"
+ *       public-api: "file:pkg/api/** exported:true"
+ *     queries:
+ *       untested-api: { query: "kind:func coverage:0", scope: public-api }
+ *       handlers: "signature:func(http.ResponseWriter,*http.Request)"
+ *
+ * A scope is a query language filter (see utils/queryLanguage.ts) that a
+ * search or structured query must also match, so it can use any field:
+ * paths, code tags, exported, owners, Bazel targets. A saved query is a
+ * structured query with an optional scope, run by name. Definitions are
+ * checked when used, so one bad entry does not hide the others.
+ */
+export class SearchScopes {
+  constructor(private config: ScopesConfig = { scopes: {}, queries: {} }) {}
+
+  /** Scopes by name, as defined */
+  scopes(): SearchScope[] {
+    return Object.keys(this.config.scopes).sort().map(name => ({ name, filter: this.config.scopes[name] }));
+  }
+
+  /** Saved queries by name, as defined */
+  savedQueries(): SavedQuery[] {
+    return Object.keys(this.config.queries).sort().map(name => {
+      const saved = this.config.queries[name];
+      return { name, ...(typeof saved === 'string' ? { query: saved } : saved) };
+    });
+  }
+
+  /**
+   * The filter of a scope. Throws ScopeError for unknown scopes and
+   * filters that do not parse.
+   */
+  filter(scope: string): string {
+    const filter = lookup(this.config.scopes, scope);
+    if (filter === undefined) {
+      throw new ScopeError(`Unknown scope "${scope}" (${describeNames(this.config.scopes)})`);
+    }
+    if (typeof filter !== 'string') {
+      throw new ScopeError(`Scope "${scope}" must be a query string`);
+    }
+    try {
+      parseQuery(filter);
+    } catch (error) {
+      if (error instanceof QuerySyntaxError) {
+        throw new ScopeError(`Scope "${scope}": ${error.message}`);
+      }
+      throw error;
+    }
+    return filter;
+  }
+
+  /**
+   * The query limited to a scope; without one, the query itself. Throws
+   * QuerySyntaxError when the query does not parse, with positions in it,
+   * and ScopeError for the scope.
+   */
+  restrict(query: string, scope?: string): string {
+    parseQuery(query);
+    return scope ? `(${query}) (${this.filter(scope)})` : query;
+  }
+
+  /**
+   * A saved query. Throws ScopeError for unknown queries and definitions
+   * without a query.
+   */
+  savedQuery(name: string): SavedQuery {
+    const saved = lookup(this.config.queries, name);
+    if (saved === undefined) {
+      throw new ScopeError(`Unknown saved query "${name}" (${describeNames(this.config.queries)})`);
+    }
+    const definition = typeof saved === 'string' ? { query: saved } : saved;
+    if (typeof definition?.query !== 'string' || !definition.query.trim()) {
+      throw new ScopeError(`Saved query "${name}" has no query`);
+    }
+    return { name, ...definition };
+  }
+
+  /**
+   * The text of a saved query in its scope, or in `scope` instead when
+   * given. Throws ScopeError, also for a query that does not parse.
+   */
+  resolve(name: string, scope?: string): string {
+    const saved = this.savedQuery(name);
+    try {
+      return this.restrict(saved.query, scope ?? saved.scope);
+    } catch (error) {
+      if (error instanceof QuerySyntaxError) {
+        throw new ScopeError(`Saved query "${name}": ${error.message}`);
+      }
+      throw error;
+    }
+  }
+}
+
+function lookup<T>(entries: Record<string, T>, name: string): T | undefined {
+  return Object.prototype.hasOwnProperty.call(entries, name) ? entries[name] : undefined;
+}
+
+function describeNames(entries: Record<string, unknown>): string {
+  const names = Object.keys(entries).sort();
+  return names.length > 0 ? `defined: ${names.join(', ')}` : 'none defined';
+}
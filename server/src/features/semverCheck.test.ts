@@ -0,0 +1,130 @@
+/**
+ * SemverCheck Tests
+ *
+ * Classifies the API changes between tagged commits of a throwaway git
+ * repository and checks them against the release version.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SemverCheck, parseSemver } from './semverCheck.js';
+import { IndexDiff } from './indexDiff.js';
+import { LanguageRouter } from '../indexer/languageRouter.js';
+import { SymbolIndexer } from '../indexer/symbolIndexer.js';
+
+const storeV1 = `package store
+
+type Key struct {
+	ID string
+}
+
+type Item struct {
+	Name string
+	tags []string
+}
+
+type Store interface {
+	Get(key Key) (*Item, error)
+}
+
+func Open(path string) (Store, error) { return nil, nil }
+
+func Close(s Store) error { return nil }
+`;
+
+// Renamed parameters, a new function and a field on a non-comparable struct
+const storeMinor = storeV1.replace('Open(path string)', 'Open(dir string)').replace('	tags []string', '	tags []string\n	Size int') +
+  '\nfunc OpenReadOnly(dir string) (Store, error) { return nil, nil }\n';
+
+// Key becomes non-comparable, Store gains a method, Close is gone
+const storeMajor = storeMinor
+  .replace('	ID string', '	ID    string\n	Parts []string')
+  .replace('	Get(key Key) (*Item, error)', '	Get(key Key) (*Item, error)\n	Put(key Key, item *Item) error')
+  .replace('func Close(s Store) error { return nil }\n', '');
+
+describe('SemverCheck', () => {
+  let root: string;
+
+  function git(args: string[]): string {
+    return execFileSync('git', args, {
+      cwd: root,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com',
+        GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com'
+      }
+    });
+  }
+
+  function release(content: string, tag: string): void {
+    fs.writeFileSync(path.join(root, 'store', 'store.go'), content);
+    git(['add', '.']);
+    git(['commit', '-q', '-m', tag]);
+    git(['tag', tag]);
+  }
+
+  function createCheck(): SemverCheck {
+    return new SemverCheck(new IndexDiff(new LanguageRouter(new SymbolIndexer()), root, undefined, async args => git(args)));
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'semver-check-'));
+    fs.mkdirSync(path.join(root, 'store'));
+    git(['init', '-q']);
+    release(storeV1, 'v1.2.0');
+    release(storeMinor, 'v1.3.0');
+    release(storeMajor, 'v1.3.1');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should classify additions as minor and ignore renamed parameters', async () => {
+    const report = await createCheck().check('v1.2.0..v1.3.0');
+
+    expect([report.level, report.allowed, report.compatible, report.suggestedVersion]).toEqual(['minor', 'minor', true, 'v1.3.0']);
+    expect(report.changes.map(c => `${c.level} ${c.symbol.qualifiedName} ${c.reason}`)).toEqual([
+      'minor Item.Size added',
+      'minor OpenReadOnly added'
+    ]);
+  });
+
+  it('should report unintended breaking changes', async () => {
+    const check = createCheck();
+    const report = await check.check('v1.3.0..v1.3.1');
+
+    expect([report.level, report.allowed, report.compatible, report.suggestedVersion]).toEqual(['major', 'patch', false, 'v2.0.0']);
+    expect(report.changes.filter(c => c.level === 'major').map(c => `${c.symbol.qualifiedName} ${c.reason}`)).toEqual([
+      'Close removed',
+      'Key.Parts makes Key non-comparable',
+      'Store.Put method added to interface'
+    ]);
+    expect(check.toMarkdown(report)).toContain('**Incompatible: unintended breaking changes.**');
+
+    // The same changes released as a new major version
+    expect((await check.check('v1.3.0..HEAD', { release: 'v2.0.0' })).compatible).toBe(true);
+  });
+
+  it('should treat v0 minor releases as allowing breaking changes', async () => {
+    git(['tag', 'v0.4.0', 'v1.3.0']);
+    const check = createCheck();
+
+    expect((await check.check('v0.4.0..HEAD', { release: 'v0.5.0' })).compatible).toBe(true);
+    expect((await check.check('v0.4.0..HEAD', { release: 'v0.4.1' })).compatible).toBe(false);
+    // Breaking changes without a release version are unintended
+    expect((await check.check('v1.3.0')).compatible).toBe(false);
+    await expect(check.check('v1.3.0..HEAD', { release: 'v1.2.9' })).rejects.toThrow('is not above v1.3.0');
+  });
+
+  it('should parse semantic versions', () => {
+    expect(parseSemver('v1.3.0-rc.1')).toEqual({ major: 1, minor: 3, patch: 0, prerelease: 'rc.1' });
+    expect(parseSemver('2.0.0+build.5')).toEqual({ major: 2, minor: 0, patch: 0 });
+    expect(parseSemver('main')).toBeUndefined();
+  });
+});
@@ -0,0 +1,289 @@
+import { ApiSnapshot, ApiSymbol, IndexDiff, IndexDiffOptions } from './indexDiff.js';
+import { signatureTypes } from './apiSurface.js';
+
+/** How much of a version an API change needs to bump */
+export type SemverLevel = 'patch' | 'minor' | 'major';
+
+export interface SemverVersion {
+  major: number;
+  minor: number;
+  patch: number;
+  /** `rc.1` of `v1.3.0-rc.1` */
+  prerelease?: string;
+}
+
+export interface SemverCheckOptions extends IndexDiffOptions {
+  /**
+   * Version the head is released as (`v1.3.0`). Defaults to the head
+   * revision if it is a version tag; without one, breaking changes are
+   * unintended.
+   */
+  release?: string;
+}
+
+export interface SemverChange {
+  level: SemverLevel;
+  /** E.g. `removed`, `signature changed`, `method added to interface` */
+  reason: string;
+  /** The symbol in the head revision, or the base revision for removals */
+  symbol: ApiSymbol;
+  /** The symbol in the base revision, for changed symbols */
+  before?: ApiSymbol;
+}
+
+export interface SemverReport {
+  base: { revision: string; commit: string; version?: string };
+  head: { revision: string; commit: string; version?: string };
+  /** Bump the changes need */
+  level: SemverLevel;
+  /** Bump from the base version to the release; `minor` without versions */
+  allowed: SemverLevel;
+  /** False if the changes need a bigger bump than the release makes */
+  compatible: boolean;
+  /** The base version bumped as the changes need, if the base is a version */
+  suggestedVersion?: string;
+  /** Changes, breaking ones first */
+  changes: SemverChange[];
+}
+
+const LEVEL_RANK: Record<SemverLevel, number> = { patch: 0, minor: 1, major: 2 };
+
+const SEMVER_PATTERN = /^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$/;
+
+/**
+ * Semver Check - classifies the API changes between two revisions (see
+ * features/indexDiff.ts) by the version bump they need, and checks them
+ * against the version being released.
+ *
+ * - major: a removed symbol, a changed signature or type, a method added
+ *   to an interface (its implementations no longer satisfy it), or a
+ *   field that makes a comparable struct non-comparable (`==` and map
+ *   keys stop compiling)
+ * - minor: added symbols, other added fields
+ * - patch: no exported API change
+ *
+ * Go signatures are compared without parameter and receiver names, so
+ * renaming a parameter needs no bump. As in Go's compatibility rules, v0
+ * versions promise no stability: a minor bump allows breaking changes and
+ * a patch bump allows additions.
+ */
+export class SemverCheck {
+  constructor(private indexDiff: IndexDiff) {}
+
+  /**
+   * Check a range such as `v1.2.0..HEAD` (head defaults to HEAD). Throws
+   * on unknown revisions and on a release version that is not above the
+   * base version.
+   */
+  async check(range: string, options: SemverCheckOptions = {}): Promise<SemverReport> {
+    const separator = range.indexOf('..');
+    const baseRevision = separator >= 0 ? range.substring(0, separator) : range;
+    const headRevision = separator >= 0 ? range.substring(separator + 2) || 'HEAD' : 'HEAD';
+    if (!baseRevision) {
+      throw new Error(`Invalid revision range: ${range}`);
+    }
+
+    const baseVersion = parseSemver(baseRevision);
+    const releaseName = options.release ?? (parseSemver(headRevision) ? headRevision : undefined);
+    const release = releaseName !== undefined ? parseSemver(releaseName) : undefined;
+    if (releaseName !== undefined && !release) {
+      throw new Error(`Invalid release version: ${releaseName}`);
+    }
+    if (release && baseVersion && compareSemver(release, baseVersion) <= 0) {
+      throw new Error(`Release version ${releaseName} is not above ${baseRevision}`);
+    }
+
+    const { result, base, head } = await this.indexDiff.compare(baseRevision, headRevision, options);
+    const changes = classifyChanges(result.added, result.removed, result.changed, base, head);
+    const level = changes.reduce<SemverLevel>((max, change) => LEVEL_RANK[change.level] > LEVEL_RANK[max] ? change.level : max, 'patch');
+    const allowed = release && baseVersion ? bumpLevel(baseVersion, release) : 'minor';
+
+    return {
+      base: { ...result.base, ...(baseVersion && { version: formatSemver(baseVersion) }) },
+      head: { ...result.head, ...(release && { version: formatSemver(release) }) },
+      level,
+      allowed,
+      compatible: LEVEL_RANK[level] <= LEVEL_RANK[allowed],
+      ...(baseVersion && { suggestedVersion: formatSemver(nextVersion(baseVersion, level)) }),
+      changes
+    };
+  }
+
+  /**
+   * Markdown report for pull request review and CI logs.
+   */
+  toMarkdown(report: SemverReport): string {
+    const ref = (side: SemverReport['base']) => `\`${side.revision}\` (${side.commit.slice(0, 8)})`;
+    const lines = [
+      `# Semantic version check: ${ref(report.base)} → ${ref(report.head)}`,
+      '',
+      `Changes need a **${report.level}** version bump` +
+        (report.suggestedVersion ? ` (${report.suggestedVersion})` : '') +
+        `; the release allows **${report.allowed}**.`,
+      '',
+      report.compatible ? 'Compatible.' : '**Incompatible: unintended breaking changes.**'
+    ];
+    for (const level of ['major', 'minor'] as const) {
+      const changes = report.changes.filter(change => change.level === level);
+      if (changes.length === 0) {
+        continue;
+      }
+      lines.push('', `## ${level === 'major' ? 'Major (breaking)' : 'Minor'}`, '');
+      for (const { reason, symbol, before } of changes) {
+        lines.push(`- \`${symbol.package}\` **${symbol.qualifiedName}** (${symbol.kind}): ${reason} — ${symbol.file}:${symbol.line + 1}`);
+        if (before) {
+          lines.push(`  - before: \`${before.signature}\``, `  - after: \`${symbol.signature}\``);
+        }
+      }
+    }
+    return lines.join('\n') + '\n';
+  }
+}
+
+export function parseSemver(version: string): SemverVersion | undefined {
+  const match = SEMVER_PATTERN.exec(version.trim());
+  if (!match) {
+    return undefined;
+  }
+  return {
+    major: Number(match[1]),
+    minor: Number(match[2]),
+    patch: Number(match[3]),
+    ...(match[4] && { prerelease: match[4] })
+  };
+}
+
+export function formatSemver(version: SemverVersion): string {
+  return `v${version.major}.${version.minor}.${version.patch}${version.prerelease ? `-${version.prerelease}` : ''}`;
+}
+
+function compareSemver(a: SemverVersion, b: SemverVersion): number {
+  return a.major - b.major || a.minor - b.minor || a.patch - b.patch ||
+    // A prerelease comes before its release
+    (a.prerelease === b.prerelease ? 0 : a.prerelease === undefined ? 1 : b.prerelease === undefined ? -1 : a.prerelease < b.prerelease ? -1 : 1);
+}
+
+/** The level of change the step from base to release allows */
+function bumpLevel(base: SemverVersion, release: SemverVersion): SemverLevel {
+  // Prereleases promise no compatibility with what follows
+  if (base.prerelease !== undefined) {
+    return 'major';
+  }
+  const level: SemverLevel = release.major > base.major ? 'major' : release.minor > base.minor ? 'minor' : 'patch';
+  if (base.major === 0 && release.major === 0) {
+    return level === 'minor' ? 'major' : 'minor';
+  }
+  return level;
+}
+
+function nextVersion(base: SemverVersion, level: SemverLevel): SemverVersion {
+  if (base.prerelease !== undefined) {
+    return { major: base.major, minor: base.minor, patch: base.patch };
+  }
+  const effective: SemverLevel = base.major === 0 ? (level === 'major' ? 'minor' : 'patch') : level;
+  switch (effective) {
+    case 'major':
+      return { major: base.major + 1, minor: 0, patch: 0 };
+    case 'minor':
+      return { major: base.major, minor: base.minor + 1, patch: 0 };
+    default:
+      return { major: base.major, minor: base.minor, patch: base.patch + 1 };
+  }
+}
+
+function classifyChanges(
+  added: ApiSymbol[],
+  removed: ApiSymbol[],
+  changed: Array<{ before: ApiSymbol; after: ApiSymbol }>,
+  base: ApiSnapshot,
+  head: ApiSnapshot
+): SemverChange[] {
+  const changes: SemverChange[] = [];
+  const baseTypes = new ApiTypes(base.symbols);
+  const headTypes = new ApiTypes(head.symbols);
+
+  for (const symbol of removed) {
+    changes.push({ level: 'major', reason: 'removed', symbol });
+  }
+  for (const { before, after } of changed) {
+    if (signatureKey(before) === signatureKey(after)) {
+      continue;
+    }
+    const reason = before.kind === 'field' || before.kind === 'variable' || before.kind === 'constant' ? 'type changed' : 'signature changed';
+    changes.push({ level: 'major', reason, symbol: after, before });
+  }
+  for (const symbol of added) {
+    const container = containerOf(symbol);
+    const existed = container !== undefined && baseTypes.kindOf(symbol.package, container) !== undefined;
+    if (existed && symbol.kind === 'method' && headTypes.kindOf(symbol.package, container) === 'interface') {
+      changes.push({ level: 'major', reason: 'method added to interface', symbol });
+    } else if (existed && symbol.kind === 'field' && isGoFile(symbol.file) &&
+        baseTypes.isComparable(symbol.package, container) && !headTypes.isComparable(symbol.package, container)) {
+      changes.push({ level: 'major', reason: `makes ${container} non-comparable`, symbol });
+    } else {
+      changes.push({ level: 'minor', reason: 'added', symbol });
+    }
+  }
+
+  const compare = (a: SemverChange, b: SemverChange) =>
+    LEVEL_RANK[b.level] - LEVEL_RANK[a.level] ||
+    a.symbol.package.localeCompare(b.symbol.package) ||
+    a.symbol.qualifiedName.localeCompare(b.symbol.qualifiedName);
+  return changes.sort(compare);
+}
+
+function containerOf(symbol: ApiSymbol): string | undefined {
+  const dot = symbol.qualifiedName.lastIndexOf('.');
+  return dot > 0 ? symbol.qualifiedName.substring(0, dot) : undefined;
+}
+
+function isGoFile(file: string): boolean {
+  return file.endsWith('.go');
+}
+
+/**
+ * What a signature promises: for Go functions and methods, the types of
+ * the receiver, parameters and results without their names.
+ */
+function signatureKey(symbol: ApiSymbol): string {
+  if (!isGoFile(symbol.file) || (symbol.kind !== 'function' && symbol.kind !== 'method')) {
+    return symbol.signature;
+  }
+  let rest = symbol.signature.replace(/^func\s*/, '');
+  let receiver = '';
+  if (rest.startsWith('(')) {
+    const close = rest.indexOf(')');
+    receiver = signatureTypes(`func${rest.substring(0, close + 1)}`);
+    rest = rest.substring(close + 1).trim();
+  }
+  const name = /^[A-Za-z_]\w*/.exec(rest)?.[0] ?? '';
+  rest = rest.substring(name.length);
+  let typeParameters = '';
+  if (rest.startsWith('[')) {
+    const close = rest.indexOf('](');
+    typeParameters = close >= 0 ? rest.substring(0, close + 1) : '';
+    rest = rest.substring(typeParameters.length);
+  }
+  return `${receiver} ${name}${typeParameters}${signatureTypes(`func${rest}`)}`;
+}
+
+/** Kinds and comparability of the top-level types of a snapshot */
+class ApiTypes {
+  private types = new Map<string, ApiSymbol>();
+
+  constructor(symbols: ApiSymbol[]) {
+    for (const symbol of symbols) {
+      if (!containerOf(symbol)) {
+        this.types.set(`${symbol.package}\0${symbol.qualifiedName}`, symbol);
+      }
+    }
+  }
+
+  kindOf(pkg: string, name: string): string | undefined {
+    return this.types.get(`${pkg}\0${name}`)?.kind;
+  }
+
+  isComparable(pkg: string, name: string): boolean {
+    return this.types.get(`${pkg}\0${name}`)?.comparable !== false;
+  }
+}
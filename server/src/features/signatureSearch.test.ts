@@ -0,0 +1,78 @@
+/**
+ * SignatureSearch Tests
+ *
+ * Verifies signature and generic-constraint search over indexed Go code.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { SignatureSearch } from './signatureSearch.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const peopleGo = `package people
+
+type Person struct {
+	Name string
+}
+
+func NewPerson(name string) *Person { return &Person{Name: name} }
+
+func Greet(p *Person) string { return "hi " + p.Name }
+
+func (p *Person) Rename(name string) { p.Name = name }
+
+func (p Person) String() string { return p.Name }
+`;
+
+const genericGo = `package generic
+
+func Keys[K comparable, V any](m map[K]V) []K { return nil }
+
+func Index[T comparable](xs []T, x T) int { return -1 }
+
+func Sum[T ~int | ~float64](xs []T) T { var s T; return s }
+`;
+
+describe('SignatureSearch', () => {
+  let index: MockBackgroundIndex;
+  let search: SignatureSearch;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    const goIndexer = new GoIndexer();
+    for (const [uri, content] of [['/ws/people/people.go', peopleGo], ['/ws/generic/generic.go', genericGo]]) {
+      const result = goIndexer.indexFile(uri, content);
+      index.addFile(uri, result.symbols, result.references);
+    }
+    search = new SignatureSearch(index.asBackgroundIndex());
+  });
+
+  it('should find functions and methods taking a type', async () => {
+    const { symbols } = await search.search({ signature: 'func(*Person)' });
+    expect(symbols.map(s => s.name)).toEqual(['Greet']);
+
+    const withRest = await search.search({ signature: 'func(*Person, ...)' });
+    expect(withRest.symbols.map(s => s.name)).toEqual(['Greet', 'Rename']);
+
+    const stringers = await search.search({ signature: 'func(Person) string' });
+    expect(stringers.symbols.map(s => s.name)).toEqual(['String']);
+
+    const constructors = await search.search({ signature: '(string) *Person', kinds: ['function'] });
+    expect(constructors.symbols.map(s => s.name)).toEqual(['NewPerson']);
+  });
+
+  it('should find generic declarations by constraint', async () => {
+    const comparable = await search.search({ constraint: 'comparable' });
+    expect(comparable.symbols.map(s => s.name)).toEqual(['Keys', 'Index']);
+
+    const generic = await search.search({ generic: true, name: 'su' });
+    expect(generic.symbols.map(s => s.name)).toEqual(['Sum']);
+
+    const limited = await search.search({ generic: true, limit: 1 });
+    expect(limited.symbols).toHaveLength(1);
+    expect(limited.truncated).toBe(true);
+
+    await expect(search.search({})).rejects.toThrow('is required');
+    await expect(search.search({ signature: 'Person' })).rejects.toThrow('parameter list');
+  });
+});
@@ -0,0 +1,114 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { hasConstraint, matchesSignature, parseSignaturePattern, SignaturePattern } from '../utils/signatures.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface SignatureSearchOptions {
+  /** Signature pattern, e.g. `func(*Person) string`, see utils/signatures.ts */
+  signature?: string;
+  /** Keep generic declarations with a type parameter constrained by this (`comparable`) */
+  constraint?: string;
+  /** Keep only generic declarations (with type parameters) */
+  generic?: boolean;
+  /** Case-insensitive substring of the symbol name */
+  name?: string;
+  /** Symbol kinds to keep (function, method, struct, ...) */
+  kinds?: string[];
+  /** Maximum number of matches (default: 100) */
+  limit?: number;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface SignatureSearchResult {
+  symbols: IndexedSymbol[];
+  /** Files scanned before the limit was reached */
+  filesScanned: number;
+  /** True if the limit was reached (there may be more matches) */
+  truncated: boolean;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 100;
+
+/**
+ * Signature Search - finds functions, methods and generic declarations by
+ * their shape rather than their name: `func(*Person)` finds everything
+ * taking a *Person (including methods on *Person, in method-expression
+ * form), `constraint: 'comparable'` finds generic code over comparable
+ * type parameters.
+ *
+ * Scans the background index file by file in path order; the criteria are
+ * combined with AND.
+ */
+export class SignatureSearch {
+  constructor(private backgroundIndex: BackgroundIndex) {}
+
+  /**
+   * Find definitions matching all given criteria. Throws on an invalid
+   * signature pattern or when no criterion is given.
+   */
+  async search(options: SignatureSearchOptions): Promise<SignatureSearchResult> {
+    const { cancellationToken, onProgress } = options;
+    const pattern = options.signature ? parseSignaturePattern(options.signature) : undefined;
+    if (!pattern && !options.constraint && !options.generic) {
+      throw new Error('A signature pattern, a constraint or generic is required');
+    }
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const name = options.name?.toLowerCase();
+    const kinds = options.kinds && options.kinds.length > 0 ? new Set(options.kinds) : undefined;
+
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const symbols: IndexedSymbol[] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Matching signatures (${i}/${files.length})`);
+      }
+
+      for (const symbol of await this.backgroundIndex.getFileSymbols(files[i])) {
+        if (symbol.isDefinition === false || (kinds && !kinds.has(symbol.kind)) ||
+            (name && !symbol.name.toLowerCase().includes(name))) {
+          continue;
+        }
+        if (matchesCriteria(symbol, pattern, options)) {
+          symbols.push(symbol);
+          if (symbols.length >= limit) {
+            return { symbols, filesScanned: i + 1, truncated: true };
+          }
+        }
+      }
+    }
+
+    return { symbols, filesScanned: files.length, truncated: false };
+  }
+}
+
+/**
+ * Does the symbol pass the signature, constraint and generic criteria?
+ */
+export function matchesCriteria(
+  symbol: IndexedSymbol,
+  pattern: SignaturePattern | undefined,
+  options: Pick<SignatureSearchOptions, 'constraint' | 'generic'>
+): boolean {
+  if (pattern && !matchesSignature(symbol, pattern)) {
+    return false;
+  }
+  if (options.constraint && !hasConstraint(symbol, options.constraint)) {
+    return false;
+  }
+  if (options.generic && !(symbol.typeParameters && symbol.typeParameters.length > 0)) {
+    return false;
+  }
+  return true;
+}
@@ -0,0 +1,147 @@
+/**
+ * SQL Query Tests
+ *
+ * Verifies statement extraction for database/sql, sqlx and pgx calls
+ * (literals, concatenations, constants, Sprintf formats) and the query
+ * index: enclosing functions, table, column and access filters, rescans.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { findSqlQueries, SqlQueryIndex } from './sqlQueries.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const STORE_GO = '/ws/users/store.go';
+const ORDERS_GO = '/ws/orders/orders.go';
+
+const storeGo = `package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const getUser = \`
+	SELECT id, email
+	FROM users
+	WHERE id = $1\`
+
+type Store struct{ db *sql.DB }
+
+func (s *Store) Get(ctx context.Context, id int) error {
+	return s.db.QueryRowContext(ctx, getUser, id).Scan()
+}
+
+func (s *Store) Rename(ctx context.Context, id int, name string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET name = $1 "+
+		"WHERE id = $2", name, id)
+	return err
+}
+
+func Count(db *sql.DB, table string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT count(*) FROM %s", table))
+	cache.Get("users")
+	return err
+}
+`;
+
+const ordersGo = `package orders
+
+import "github.com/jmoiron/sqlx"
+
+func Recent(db *sqlx.DB, out *[]Order) error {
+	return db.Select(out, "SELECT o.id, u.email FROM orders o JOIN users u ON u.id = o.user_id")
+}
+
+func Archive(db *sqlx.DB) {
+	db.MustExec("INSERT INTO archive (id) SELECT id FROM orders")
+}
+`;
+
+describe('findSqlQueries', () => {
+  it('should read literals, constants and concatenations passed to database/sql', () => {
+    const queries = findSqlQueries(STORE_GO, storeGo);
+
+    expect(queries.map(query => [query.call, query.operation, query.sql, query.dynamic])).toEqual([
+      ['QueryRowContext', 'select', 'SELECT id, email FROM users WHERE id = $1', undefined],
+      ['ExecContext', 'update', 'UPDATE users SET name = $1 WHERE id = $2', undefined],
+      ['Query', 'select', 'SELECT count(*) FROM %s', true]
+    ]);
+    expect(queries[0]).toMatchObject({
+      library: 'database/sql', line: 16, character: 13,
+      tables: [{ name: 'users', access: 'read' }],
+      columns: [{ name: 'id', table: 'users' }, { name: 'email', table: 'users' }]
+    });
+  });
+
+  it('should read sqlx and pgx calls and ignore files without a driver import', () => {
+    const recent = findSqlQueries(ORDERS_GO, ordersGo)[0];
+    expect(recent).toMatchObject({ call: 'Select', library: 'sqlx' });
+    expect(recent.tables).toEqual([{ name: 'orders', access: 'read' }, { name: 'users', access: 'read' }]);
+    expect(recent.columns).toContainEqual({ name: 'user_id', table: 'orders' });
+
+    const pgx = `package jobs
+
+import "github.com/jackc/pgx/v5/pgxpool"
+
+func Claim(ctx context.Context, pool *pgxpool.Pool, batch *pgx.Batch) {
+	pool.Exec(ctx, "DELETE FROM jobs WHERE id = $1", 1)
+	batch.Queue("UPDATE workers SET busy = true")
+}
+`;
+    expect(findSqlQueries('/ws/jobs.go', pgx).map(query => [query.library, query.operation, query.tables[0].name])).toEqual([
+      ['pgx', 'delete', 'jobs'],
+      ['pgx', 'update', 'workers']
+    ]);
+    expect(findSqlQueries('/ws/cache.go', 'package cache\n\nfunc f() { db.Query("SELECT 1 FROM t") }\n')).toEqual([]);
+  });
+});
+
+describe('SqlQueryIndex', () => {
+  let index: MockBackgroundIndex;
+  let queries: SqlQueryIndex;
+  let contents: Record<string, string>;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string, hash?: string): void {
+    contents[uri] = content;
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references, { imports: result.imports, ...(hash ? { hash } : {}) });
+  }
+
+  beforeEach(async () => {
+    index = new MockBackgroundIndex();
+    contents = {};
+    addGoFile(STORE_GO, storeGo);
+    addGoFile(ORDERS_GO, ordersGo);
+    addGoFile('/ws/users/store_test.go', 'package users\n\nimport "database/sql"\n\nfunc seed(db *sql.DB) { db.Exec("DELETE FROM users") }\n');
+    queries = new SqlQueryIndex(index.asBackgroundIndex(), async filePath => contents[filePath]);
+    await queries.build();
+  });
+
+  it('should list the functions touching a table', () => {
+    const report = queries.query({ table: 'USERS' });
+
+    expect(report).toMatchObject({ total: 3, truncated: false });
+    expect(report.functions.map(usage => [usage.name, usage.operations, usage.tables])).toEqual([
+      ['Recent', ['select'], ['orders', 'users']],
+      ['Store.Get', ['select'], ['users']],
+      ['Store.Rename', ['update'], ['users']]
+    ]);
+    expect(report.functions[1].location).toMatchObject({ uri: STORE_GO, line: 15 });
+  });
+
+  it('should filter by column, operation, access and path', () => {
+    expect(queries.query({ column: 'users.email' }).functions.map(usage => usage.name)).toEqual(['Recent', 'Store.Get']);
+    expect(queries.query({ table: 'users', access: 'write' }).functions.map(usage => usage.name)).toEqual(['Store.Rename']);
+    expect(queries.query({ operation: 'insert' }).queries.map(query => query.tables.map(table => table.access))).toEqual([['write', 'read']]);
+    expect(queries.query({ path: '/ws/users' }).total).toBe(3);
+    expect(queries.query({ limit: 1 }).truncated).toBe(true);
+  });
+
+  it('should rescan changed files only', async () => {
+    addGoFile(ORDERS_GO, ordersGo.replace('MustExec(', 'MustExec("DELETE FROM orders")\n\tdb.MustExec('), 'changed');
+    expect(await queries.build()).toMatchObject({ files: 2, queries: 6, updated: 1 });
+  });
+});
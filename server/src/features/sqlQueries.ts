@@ -0,0 +1,388 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { GoToken, GoTokenizer, unquoteGoString } from '../indexer/components/GoTokenizer.js';
+import { attachFunctions, splitArgs, stringConstants, TokenSpan } from '../indexer/components/GoTokenUtils.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { parseSql, SqlColumnRef, SqlOperation, SqlTableRef } from '../utils/sqlParser.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type SqlLibrary = 'database/sql' | 'sqlx' | 'pgx';
+
+export interface SqlQuery {
+  uri: string;
+  /** 0-based position of the call running the query (`QueryContext`, `Get`, ...) */
+  line: number;
+  character: number;
+  call: string;
+  library: SqlLibrary;
+  /** The statement, whitespace collapsed */
+  sql: string;
+  /** Built with fmt.Sprintf or concatenated with non-literals; only the literal parts were read */
+  dynamic?: boolean;
+  operation: SqlOperation;
+  tables: SqlTableRef[];
+  columns: SqlColumnRef[];
+  /** Function or method the call is in, e.g. `UserStore.Get` */
+  function?: string;
+  functionLocation?: SymbolLocation;
+}
+
+export interface SqlQueryIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface SqlQueryFilter {
+  /** Only queries on this table (case-insensitive; `users` also matches `public.users`) */
+  table?: string;
+  /** Only queries naming this column, `email` or `users.email` */
+  column?: string;
+  operation?: SqlOperation;
+  /** Only queries reading or writing the table (with `table`) or any table */
+  access?: SqlTableRef['access'];
+  /** Only queries in this file or under this folder */
+  path?: string;
+  /** Number of queries to return (default: 1000); functions are counted over all of them */
+  limit?: number;
+  /** Cancellation token for aborting the index update */
+  cancellationToken?: CancellationToken;
+}
+
+export interface SqlFunctionUsage {
+  /** Qualified function name; undefined for queries outside functions */
+  name?: string;
+  uri: string;
+  location?: SymbolLocation;
+  queries: number;
+  operations: SqlOperation[];
+  /** Tables its matching queries touch */
+  tables: string[];
+}
+
+export interface SqlQueryReport {
+  /** Matching queries, before the limit */
+  total: number;
+  /** In path and position order */
+  queries: SqlQuery[];
+  /** Functions running matching queries, most queries first */
+  functions: SqlFunctionUsage[];
+  truncated: boolean;
+}
+
+/** Queries matching a filter; how servers expose the index */
+export type SqlQuerySource = (filter: SqlQueryFilter) => Promise<SqlQueryReport>;
+
+/** The part of an index the query index reads: the background index or the library Indexer */
+export interface SqlQuerySourceIndex {
+  getAllFiles(): Promise<string[]>;
+  /** Files are rescanned when their content hash changes */
+  getFileInfo(uri: string): { hash: string } | undefined;
+  /** Declarations, for the function each query is in */
+  getFileSymbols(uri: string): Promise<IndexedSymbol[]>;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type SqlReader = (filePath: string) => Promise<string>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 1000;
+
+const IMPORT_PATHS: Array<[RegExp, SqlLibrary]> = [
+  [/^database\/sql$/, 'database/sql'],
+  [/^github\.com\/jmoiron\/sqlx$/, 'sqlx'],
+  [/^github\.com\/jackc\/pgx(?:\/v\d+)?(?:\/pgxpool)?$/, 'pgx']
+];
+
+/** database/sql methods; pgx has the same names, with a context first */
+const SQL_METHODS = new Set([
+  'Query', 'QueryContext', 'QueryRow', 'QueryRowContext', 'Exec', 'ExecContext', 'Prepare', 'PrepareContext'
+]);
+
+const SQLX_METHODS = new Set([
+  'Get', 'GetContext', 'Select', 'SelectContext', 'NamedExec', 'NamedExecContext', 'NamedQuery', 'NamedQueryContext',
+  'Queryx', 'QueryxContext', 'QueryRowx', 'QueryRowxContext', 'MustExec', 'MustExecContext', 'Preparex',
+  'PreparexContext', 'PrepareNamed', 'PrepareNamedContext'
+]);
+
+/** pgx.Batch */
+const PGX_METHODS = new Set(['Queue']);
+
+const FUNCTION_KINDS = new Set(['function', 'method']);
+
+/**
+ * SQL statements a Go file passes to database/sql, sqlx or pgx calls,
+ * read with utils/sqlParser.ts. The statement is the first argument that
+ * is a string literal, a concatenation of literals, a `fmt.Sprintf`
+ * format or a name the file assigns a literal to (`const getUser = "..."`).
+ */
+export function findSqlQueries(uri: string, content: string): SqlQuery[] {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens } = tokenizer.tokenize();
+  const libraries = importedLibraries(tokens);
+  if (libraries.length === 0) {
+    return [];
+  }
+  const constants = stringConstants(tokens, { keepPrefixes: true });
+  const queries: SqlQuery[] = [];
+
+  for (let i = 2; i < tokens.length; i++) {
+    const name = tokens[i].text;
+    if (tokens[i].type !== 'ident' || tokens[i - 1].text !== '.' || tokens[i + 1]?.text !== '(') {
+      continue;
+    }
+    const library = libraryOf(name, libraries);
+    if (!library) {
+      continue;
+    }
+
+    for (const arg of splitArgs(tokens, i + 1)) {
+      const text = stringValue(tokens, arg, constants);
+      const statement = text && parseSql(text.sql);
+      if (text && statement) {
+        const position = tokenizer.positionAt(tokens[i].offset);
+        queries.push({
+          uri,
+          line: position.line,
+          character: position.character,
+          call: name,
+          library,
+          sql: text.sql.replace(/\s+/g, ' ').trim(),
+          ...(text.dynamic ? { dynamic: true } : {}),
+          ...statement
+        });
+        break;
+      }
+    }
+  }
+  return queries;
+}
+
+/**
+ * SQL Query Index - the SQL statements Go code runs, with the tables and
+ * columns they touch, so "which functions touch the users table" is one
+ * query.
+ *
+ * Like the route index (features/httpRoutes.ts) it is built from the files
+ * of the background index, rescans only files whose hash changed and
+ * leaves test files out. Each statement is attributed to the function or
+ * method it is run from.
+ */
+export class SqlQueryIndex {
+  private files: Map<string, { hash: string; queries: SqlQuery[] }> = new Map();
+
+  constructor(
+    private index: SqlQuerySourceIndex,
+    private readFile: SqlReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the index in line with the source index.
+   */
+  async build(options: SqlQueryIndexOptions = {}): Promise<{ files: number; queries: number; updated: number }> {
+    const { cancellationToken, onProgress } = options;
+    const uris = (await this.index.getAllFiles())
+      .filter(uri => path.extname(uri) === '.go' && !uri.endsWith('_test.go'))
+      .sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Collecting SQL queries (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.index.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+      try {
+        const queries = findSqlQueries(uri, await this.readFile(uri));
+        if (queries.length > 0) {
+          attachFunctions(queries, await this.index.getFileSymbols(uri), FUNCTION_KINDS);
+        }
+        this.files.set(uri, { hash, queries });
+      } catch {
+        this.files.delete(uri);
+      }
+      updated++;
+    }
+
+    onProgress?.(uris.length, uris.length, 'SQL queries complete');
+    let queries = 0;
+    for (const file of this.files.values()) {
+      queries += file.queries.length;
+    }
+    return { files: this.files.size, queries, updated };
+  }
+
+  /**
+   * Queries matching the filter, and the functions that run them.
+   */
+  query(filter: SqlQueryFilter = {}): SqlQueryReport {
+    const scope = filter.path ? path.resolve(filter.path) : undefined;
+    const limit = filter.limit ?? DEFAULT_LIMIT;
+    const column = filter.column ? splitColumn(filter.column) : undefined;
+
+    const matches: SqlQuery[] = [];
+    for (const uri of [...this.files.keys()].sort()) {
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      matches.push(...this.files.get(uri)!.queries.filter(query =>
+        (!filter.operation || query.operation === filter.operation) &&
+        (!filter.table && !filter.access || query.tables.some(table =>
+          (!filter.table || sameTable(table.name, filter.table)) && (!filter.access || table.access === filter.access))) &&
+        (!column || query.columns.some(ref =>
+          ref.name.toLowerCase() === column.name && (!column.table || (ref.table !== undefined && sameTable(ref.table, column.table)))))
+      ));
+    }
+
+    const functions = new Map<string, SqlFunctionUsage>();
+    for (const query of matches) {
+      const key = `${query.uri}#${query.function ?? ''}`;
+      let usage = functions.get(key);
+      if (!usage) {
+        usage = {
+          ...(query.function ? { name: query.function, location: query.functionLocation } : {}),
+          uri: query.uri,
+          queries: 0,
+          operations: [],
+          tables: []
+        };
+        functions.set(key, usage);
+      }
+      usage.queries++;
+      if (!usage.operations.includes(query.operation)) {
+        usage.operations.push(query.operation);
+      }
+      for (const table of query.tables) {
+        if (!usage.tables.includes(table.name)) {
+          usage.tables.push(table.name);
+        }
+      }
+    }
+
+    return {
+      total: matches.length,
+      queries: matches.slice(0, limit),
+      functions: [...functions.values()].sort((a, b) => b.queries - a.queries),
+      truncated: matches.length > limit
+    };
+  }
+}
+
+function importedLibraries(tokens: GoToken[]): SqlLibrary[] {
+  const libraries: SqlLibrary[] = [];
+  for (let i = 0; i < tokens.length; i++) {
+    if (tokens[i].text !== 'import') {
+      continue;
+    }
+    const grouped = tokens[i + 1]?.text === '(';
+    for (let j = i + 1; j < tokens.length && tokens[j].text !== ')'; j++) {
+      if (tokens[j].type === 'string') {
+        const importPath = unquoteGoString(tokens[j].text);
+        const library = IMPORT_PATHS.find(([re]) => re.test(importPath))?.[1];
+        if (library && !libraries.includes(library)) {
+          libraries.push(library);
+        }
+        if (!grouped) {
+          break;
+        }
+      }
+    }
+  }
+  return libraries;
+}
+
+/**
+ * The library whose method this is, among the imported ones. pgx and
+ * database/sql share names; code importing both is taken as database/sql.
+ */
+function libraryOf(method: string, libraries: SqlLibrary[]): SqlLibrary | undefined {
+  if (SQLX_METHODS.has(method)) {
+    return libraries.includes('sqlx') ? 'sqlx' : undefined;
+  }
+  if (PGX_METHODS.has(method)) {
+    return libraries.includes('pgx') ? 'pgx' : undefined;
+  }
+  if (!SQL_METHODS.has(method)) {
+    return undefined;
+  }
+  if (libraries.includes('database/sql')) {
+    return 'database/sql';
+  }
+  return libraries.includes('pgx') ? 'pgx' : libraries.includes('sqlx') ? 'sqlx' : undefined;
+}
+
+/**
+ * The string an argument evaluates to, as far as literals tell.
+ */
+function stringValue(tokens: GoToken[], arg: TokenSpan, constants: Map<string, string>): { sql: string; dynamic: boolean } | undefined {
+  const { start, end } = arg;
+  if (end - start === 1 && tokens[start].type === 'ident') {
+    const value = constants.get(tokens[start].text);
+    return value !== undefined ? { sql: value, dynamic: false } : undefined;
+  }
+  // fmt.Sprintf("SELECT ... %s", table)
+  if (tokens[start + 2]?.text === 'Sprintf' && tokens[start + 3]?.text === '(' && tokens[start + 4]?.type === 'string') {
+    return { sql: unquoteGoString(tokens[start + 4].text), dynamic: true };
+  }
+  if (tokens[start].type !== 'string') {
+    return undefined;
+  }
+  // "SELECT ..." + "FROM ...", or "... FROM " + table + " WHERE ..."
+  const parts: string[] = [];
+  let dynamic = false;
+  for (let j = start; j < end; j++) {
+    const token = tokens[j];
+    if (token.type === 'string') {
+      parts.push(unquoteGoString(token.text));
+    } else if (token.text !== '+') {
+      dynamic = true;
+      if (tokens[j + 1]?.text !== '+') {
+        continue;
+      }
+      parts.push(' ');
+    }
+  }
+  return { sql: parts.join(''), dynamic };
+}
+
+/**
+ * `users` matches `users` and `public.users`; qualified names must match in full.
+ */
+function sameTable(name: string, wanted: string): boolean {
+  const a = name.toLowerCase();
+  const b = wanted.toLowerCase();
+  if (a === b) {
+    return true;
+  }
+  if (a.includes('.') === b.includes('.')) {
+    return false;
+  }
+  return a.slice(a.lastIndexOf('.') + 1) === b.slice(b.lastIndexOf('.') + 1);
+}
+
+function splitColumn(column: string): { name: string; table?: string } {
+  const dot = column.lastIndexOf('.');
+  return dot > 0
+    ? { name: column.slice(dot + 1).toLowerCase(), table: column.slice(0, dot) }
+    : { name: column.toLowerCase() };
+}
@@ -0,0 +1,229 @@
+/**
+ * StructuredQuery Tests
+ *
+ * Verifies query execution and index-backed candidate selection.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { StructuredQuery } from './structuredQuery.js';
+import { QuerySyntaxError } from '../utils/queryLanguage.js';
+import { CursorError } from '../utils/pagination.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { annotateBazelTargets } from '../indexer/bazelTargets.js';
+import { ConfigFileIndexer } from '../indexer/configFileIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { parseCodeOwners, resolveOwners } from '../utils/codeOwners.js';
+
+const userGo = `package user
+
+// Person is a registered user.
+type Person struct {
+	Name string
+}
+
+func NewPerson(name string) *Person { return &Person{Name: name} }
+
+// Rename changes the name. Deprecated: set Name.
+func (p *Person) Rename(name string) { p.Name = name }
+
+func (p Person) String() string { return p.Name }
+
+func (p Person) greeting() string { return "hi " + p.Name }
+`;
+
+const userTestGo = `package user
+
+func TestRename(t *testing.T) {}
+
+func (p Person) fixture() Person { return p }
+`;
+
+const orderGo = `package order
+
+const MaxItems = 10
+
+type Order struct{}
+
+func (o *Order) Rename(name string) {}
+
+func Keys[K comparable, V any](m map[K]V) []K { return nil }
+`;
+
+describe('StructuredQuery', () => {
+  let index: MockBackgroundIndex;
+  let query: StructuredQuery;
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    const goIndexer = new GoIndexer();
+    for (const [uri, content] of [
+      ['/ws/pkg/user/user.go', userGo],
+      ['/ws/pkg/user/user_test.go', userTestGo],
+      ['/ws/pkg/order/order.go', orderGo]
+    ]) {
+      const result = goIndexer.indexFile(uri, content);
+      index.addFile(uri, result.symbols, result.references);
+    }
+    query = new StructuredQuery(index.asBackgroundIndex());
+  });
+
+  it('should find methods by receiver, export status and file', async () => {
+    const result = await query.run('kind:method receiver:Person exported:true file:pkg/user/**');
+    expect(result.symbols.map(s => s.name)).toEqual(['Rename', 'String']);
+    expect(result.plan).toBe('files next to "Person" and files matching pkg/user/**');
+    expect(result.filesScanned).toBe(2);
+
+    const pointer = await query.run('receiver:*Person');
+    expect(pointer.symbols.map(s => s.name)).toEqual(['Rename']);
+
+    const unexported = await query.run('receiver:Person exported:false -file:*_test.go');
+    expect(unexported.symbols.map(s => s.name)).toEqual(['greeting']);
+  });
+
+  it('should look up exact names instead of scanning', async () => {
+    const result = await query.run('name:Rename -receiver:Order');
+    expect(result.symbols.map(s => s.containerName)).toEqual(['Person']);
+    expect(result.plan).toBe('definitions named "Rename"');
+    expect(result.filesScanned).toBe(0);
+
+    const either = await query.run('name:NewPerson OR name:MaxItems');
+    expect(either.symbols.map(s => s.name)).toEqual(['MaxItems', 'NewPerson']);
+  });
+
+  it('should scan all files for terms without an index', async () => {
+    const deprecated = await query.run('doc:deprecated');
+    expect(deprecated.symbols.map(s => s.name)).toEqual(['Rename']);
+    expect(deprecated.plan).toBe('full scan');
+    expect(deprecated.filesScanned).toBe(3);
+
+    const generic = await query.run('generic:true constraint:comparable');
+    expect(generic.symbols.map(s => s.name)).toEqual(['Keys']);
+
+    const shaped = await query.run('(signature:"func(Person) string" OR kind:const) lang:go');
+    expect(shaped.symbols.map(s => s.name)).toEqual(['MaxItems', 'String', 'greeting']);
+
+    const byName = await query.run('name:*Person OR ren kind:method');
+    expect(byName.symbols.map(s => s.name)).toEqual(['Rename', 'Person', 'NewPerson', 'Rename']);
+  });
+
+  it('should find deprecated symbols', async () => {
+    const uri = '/ws/pkg/legacy/legacy.go';
+    const result = new GoIndexer().indexFile(uri, 'package legacy\n\n// Deprecated: use user.NewPerson.\nfunc New() {}\n\nfunc Old() {}\n');
+    index.addFile(uri, result.symbols, result.references);
+
+    // A "Deprecated:" inside a sentence (Rename) does not count
+    expect((await query.run('deprecated:true')).symbols.map(s => [s.name, s.deprecated])).toEqual([['New', 'use user.NewPerson.']]);
+    expect((await query.run('deprecated:false file:pkg/legacy/**')).symbols.map(s => s.name)).toEqual(['Old']);
+  });
+
+  it('should stop at the limit and reject invalid values', async () => {
+    const limited = await query.run('lang:go', { limit: 2 });
+    expect(limited.symbols).toHaveLength(2);
+    expect(limited.truncated).toBe(true);
+
+    await expect(query.run('lang:cobol')).rejects.toThrow(QuerySyntaxError);
+    await expect(query.run('tag:legacy')).rejects.toThrow('Unknown tag "legacy"');
+    await expect(query.run('signature:Person')).rejects.toThrow('at position 0');
+  });
+
+  it('should filter by CODEOWNERS owner', async () => {
+    const rules = parseCodeOwners('/pkg/user/ @org/identity\n/pkg/user/*_test.go @org/qa @org/identity');
+    const owned = new StructuredQuery(index.asBackgroundIndex(), {
+      ownersOf: filePath => resolveOwners(rules, filePath.replace(/^\/ws\//, ''))
+    });
+
+    const api = await owned.run('exported:true owner:org/identity -file:*_test.go');
+    expect(api.symbols.map(s => s.name)).toEqual(['Person', 'Name', 'NewPerson', 'Rename', 'String']);
+    expect(api.plan).toBe('files owned by org/identity');
+    expect(api.filesScanned).toBe(2);
+
+    const qa = await owned.run('owner:@ORG/QA,@nobody kind:function');
+    expect(qa.symbols.map(s => s.name)).toEqual(['TestRename']);
+    expect((await owned.run('owner:@org/qa kind:test')).symbols.map(s => s.name)).toEqual(['TestRename']);
+
+    const unowned = await owned.run('owner:none kind:const');
+    expect(unowned.symbols.map(s => s.name)).toEqual(['MaxItems']);
+
+    // Without CODEOWNERS nothing has an owner
+    expect((await query.run('owner:org/identity')).symbols).toEqual([]);
+  });
+
+  it('should filter by coverage and reference counts', async () => {
+    const percents: Record<string, number> = { NewPerson: 0, String: 0, Keys: 60 };
+    let refreshed = 0;
+    const covered = new StructuredQuery(index.asBackgroundIndex(), undefined, {
+      refresh: async () => {
+        refreshed++;
+      },
+      coverageOf: symbol => symbol.name in percents
+        ? { statements: 5, covered: percents[symbol.name] / 20, percent: percents[symbol.name] }
+        : undefined
+    });
+
+    // Symbols without coverage data (Rename, greeting, types) never match
+    const untested = await covered.run('exported:true coverage:0');
+    expect(untested.symbols.map(s => s.name)).toEqual(['NewPerson', 'String']);
+    expect(refreshed).toBe(1);
+    expect((await covered.run('coverage:>50 OR coverage:<=0 kind:method')).symbols.map(s => s.name)).toEqual(['Keys', 'String']);
+    expect((await query.run('coverage:0')).symbols).toEqual([]);
+
+    // Person is used by its methods and NewPerson, Order by one method
+    expect((await query.run('kind:struct refs:>1')).symbols.map(s => s.name)).toEqual(['Person']);
+    expect((await query.run('name:NewPerson refs:0')).symbols.map(s => s.name)).toEqual(['NewPerson']);
+  });
+
+  it('should page matches with offsets and cursors', async () => {
+    const all = (await query.run('kind:method')).symbols.map(s => s.name);
+    expect(all.length).toBeGreaterThan(3);
+
+    const first = await query.page('kind:method', { limit: 2 });
+    expect([first.symbols.map(s => s.name), first.offset, first.truncated]).toEqual([all.slice(0, 2), 0, true]);
+    const second = await query.page('kind:method', { limit: 2, cursor: first.nextCursor });
+    expect([second.symbols.map(s => s.name), second.offset]).toEqual([all.slice(2, 4), 2]);
+    expect((await query.page('kind:method', { offset: 1, limit: 100 })).symbols.map(s => s.name)).toEqual(all.slice(1));
+    expect((await query.page('kind:method', { offset: all.length })).nextCursor).toBeUndefined();
+
+    await expect(query.page('kind:function', { cursor: first.nextCursor })).rejects.toThrow(CursorError);
+  });
+
+  it('should filter Go symbols by Bazel target and dependencies', async () => {
+    const goIndexer = new GoIndexer();
+    for (const [uri, content, label] of [
+      ['/ws/pkg/user/user.go', userGo, '//pkg/user:user'],
+      ['/ws/pkg/order/order.go', orderGo, '//pkg/order:order']
+    ]) {
+      const result = goIndexer.indexFile(uri, content);
+      annotateBazelTargets(result.symbols, { workspaceRoot: '/ws', pkg: '', labels: [label], generated: false });
+      index.addFile(uri, result.symbols, result.references);
+    }
+    const closures: Record<string, string[]> = {
+      '//pkg/order': ['//pkg/order:order', '//pkg/user:user'],
+      '//pkg/user': ['//pkg/user:user']
+    };
+    const scoped = new StructuredQuery(index.asBackgroundIndex(), undefined, undefined, {
+      dependencyClosure: label => new Set(closures[label] ?? [label])
+    });
+
+    expect((await scoped.run('target://pkg/order kind:method')).symbols.map(s => s.name)).toEqual(['Rename']);
+    expect((await scoped.run('target://pkg/... name:Rename')).symbols.map(s => s.location.uri)).toEqual([
+      '/ws/pkg/order/order.go', '/ws/pkg/user/user.go'
+    ]);
+    expect((await scoped.run('deps://pkg/order name:Rename')).symbols).toHaveLength(2);
+    expect((await scoped.run('deps://pkg/user name:Rename')).symbols.map(s => s.location.uri)).toEqual(['/ws/pkg/user/user.go']);
+    // user_test.go is not in a target
+    expect((await scoped.run('target://... name:fixture')).symbols).toEqual([]);
+  });
+
+  it('should find config keys by path', async () => {
+    const uri = '/ws/deploy/web.yaml';
+    const manifest = 'spec:\n  template:\n    spec:\n      containers:\n        - name: web\n          image: nginx\n';
+    index.addFile(uri, new ConfigFileIndexer('yaml').parse(uri, manifest).symbols, []);
+
+    const images = await query.run('key:containers[].image');
+    expect(images.symbols.map(s => [s.name, s.value])).toEqual([['image', 'nginx']]);
+    expect((await query.run('key:spec.*.spec lang:yaml')).symbols.map(s => s.containerName)).toEqual(['spec.template']);
+    expect((await query.run('key:spec.**.name')).symbols.map(s => s.name)).toEqual(['name']);
+    // Paths match at a key boundary only
+    expect((await query.run('key:mage')).symbols).toEqual([]);
+  });
+});
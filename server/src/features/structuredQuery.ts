@@ -0,0 +1,584 @@
+import * as path from 'path';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { ConfigFileKeyMetadata } from '../indexer/configFileIndexer.js';
+import { parseQuery, QueryNode, QuerySyntaxError, QueryTerm } from '../utils/queryLanguage.js';
+import { hasConstraint, matchesSignature, parseSignaturePattern } from '../utils/signatures.js';
+import { normalizeKind } from '../utils/symbolKind.js';
+import { parseCodeTags } from '../utils/codeTags.js';
+import { globToRegex } from '../utils/ignoreRules.js';
+import { PageOptions, pageWindow, queryFingerprint, takePage } from '../utils/pagination.js';
+import { extensionsOfLanguage, LANGUAGE_EXTENSIONS } from '../utils/languages.js';
+import { normalizeOwner, OwnerLookup } from '../utils/codeOwners.js';
+import { CoverageLookup } from './coverage.js';
+import { BazelTargetResolver, matchesTargetPattern } from '../indexer/bazelTargets.js';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface StructuredQueryOptions {
+  /** Maximum number of matches (default: 100) */
+  limit?: number;
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface StructuredQueryResult {
+  symbols: IndexedSymbol[];
+  /** How candidates were found, e.g. `definitions named "Greet"` or `full scan` */
+  plan: string;
+  /** Files whose symbols were read */
+  filesScanned: number;
+  /** True if the limit was reached (there may be more matches) */
+  truncated: boolean;
+}
+
+/** Options of one page of a query; `limit` is the page size */
+export type StructuredQueryPageOptions = StructuredQueryOptions & PageOptions;
+
+export interface StructuredQueryPage extends StructuredQueryResult {
+  /** Position of the first symbol among all matches */
+  offset: number;
+  /** Cursor of the next page; unset on the last page */
+  nextCursor?: string;
+}
+
+/**
+ * Where a query's matches can be: a list of symbols or a set of files.
+ * Anything outside cannot match, so only these need to be checked.
+ */
+type Candidates =
+  | { type: 'symbols'; plan: string; symbols: IndexedSymbol[] }
+  | { type: 'files'; plan: string; files: Set<string> };
+
+type SymbolPredicate = (symbol: IndexedSymbol) => boolean;
+
+type OwnersOf = (filePath: string) => string[];
+
+/** Targets a Bazel target depends on, with itself, in the workspace of a file */
+export type BazelDependencyLookup = Pick<BazelTargetResolver, 'dependencyClosure'>;
+
+/** What term predicates look up beyond the symbol itself */
+interface QueryContext {
+  ownersOf: OwnersOf;
+  coverageOf: CoverageLookup['coverageOf'];
+  /** References to a name, for symbols whose counts were loaded */
+  referencesOf: (name: string) => number;
+  dependenciesOf: BazelDependencyLookup['dependencyClosure'];
+}
+
+/** The part of an index queries read: the background index or the library Indexer */
+export type QueryableIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols' | 'findDefinitions' | 'getReferenceCounts'>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 100;
+
+/**
+ * Structured Query - runs query language expressions (see
+ * utils/queryLanguage.ts) against an index.
+ *
+ * Terms that pin down where matches can be drive the lookup instead of a
+ * full scan: `name:Greet` reads the definitions of Greet by name,
+ * `receiver:Person` and `container:Person` the files next to the
+ * definitions of Person, `file:` and `lang:` the matching paths of the file
+ * list, `owner:` the files CODEOWNERS assigns to the owner. Every other
+ * term filters those candidates. Queries without such a term (`-name:Foo`,
+ * `doc:deprecated`) scan all files in path order. Without a CODEOWNERS
+ * lookup nothing has an owner; without a coverage lookup (see
+ * features/coverage.ts) nothing has coverage. `refs:` counts references
+ * by name, loaded for the candidates of each file before they are matched.
+ * `target:` and `deps:` match the Bazel targets recorded on Go symbols
+ * (see indexer/bazelTargets.ts); `deps:` follows the BUILD files of the
+ * symbol's workspace.
+ */
+export class StructuredQuery {
+  private ownersOf: OwnersOf = filePath => this.codeOwners?.ownersOf(filePath) ?? [];
+
+  constructor(
+    private index: QueryableIndex,
+    private codeOwners?: OwnerLookup,
+    private coverage?: CoverageLookup,
+    private bazel: BazelDependencyLookup = new BazelTargetResolver()
+  ) {}
+
+  /**
+   * Definitions matching the query. Throws QuerySyntaxError on queries that
+   * do not parse or have invalid values.
+   */
+  async run(query: string | QueryNode, options: StructuredQueryOptions = {}): Promise<StructuredQueryResult> {
+    const { cancellationToken, onProgress } = options;
+    const node = typeof query === 'string' ? parseQuery(query) : query;
+    const { matches, loadReferenceCounts } = await this.compile(node);
+    const limit = options.limit ?? DEFAULT_LIMIT;
+    const countsReferences = usesField(node, 'refs');
+
+    let allFiles: Promise<string[]> | undefined;
+    const candidates = await this.plan(node, () => allFiles ??= this.index.getAllFiles());
+
+    if (candidates?.type === 'symbols') {
+      if (countsReferences) {
+        await loadReferenceCounts(candidates.symbols);
+      }
+      const symbols = candidates.symbols.filter(s => s.isDefinition !== false && matches(s)).sort(bySourceOrder);
+      return {
+        symbols: symbols.slice(0, limit),
+        plan: candidates.plan,
+        filesScanned: 0,
+        truncated: symbols.length > limit
+      };
+    }
+
+    const files = candidates ? [...candidates.files].sort() : (await (allFiles ??= this.index.getAllFiles())).sort();
+    const plan = candidates?.plan ?? 'full scan';
+    const symbols: IndexedSymbol[] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Running query (${i}/${files.length})`);
+      }
+
+      const fileSymbols = await this.index.getFileSymbols(files[i]);
+      if (countsReferences) {
+        await loadReferenceCounts(fileSymbols);
+      }
+      for (const symbol of fileSymbols) {
+        if (symbol.isDefinition !== false && matches(symbol)) {
+          symbols.push(symbol);
+          if (symbols.length >= limit) {
+            return { symbols, plan, filesScanned: i + 1, truncated: true };
+          }
+        }
+      }
+    }
+
+    return { symbols, plan, filesScanned: files.length, truncated: false };
+  }
+
+  /**
+   * One page of the matches, from `offset` or after the `cursor` of the
+   * previous page (see utils/pagination.ts). Matches come in the same
+   * order for every page, so paging is deterministic. Throws CursorError
+   * on a cursor of another query.
+   */
+  async page(query: string, options: StructuredQueryPageOptions = {}): Promise<StructuredQueryPage> {
+    const window = pageWindow(options, DEFAULT_LIMIT, Infinity, queryFingerprint('query', query));
+    const result = await this.run(query, { ...options, limit: window.fetch });
+    const page = takePage(result.symbols, window, symbol => symbol.id);
+    return {
+      ...result,
+      symbols: page.items,
+      truncated: page.nextCursor !== undefined,
+      offset: page.offset,
+      ...(page.nextCursor && { nextCursor: page.nextCursor })
+    };
+  }
+
+  /**
+   * The symbols matching a query, in their order, e.g. search results in
+   * a scope (see features/searchScopes.ts). Throws QuerySyntaxError like run().
+   */
+  async filter(symbols: IndexedSymbol[], query: string | QueryNode): Promise<IndexedSymbol[]> {
+    const node = typeof query === 'string' ? parseQuery(query) : query;
+    const { matches, loadReferenceCounts } = await this.compile(node);
+    if (usesField(node, 'refs')) {
+      await loadReferenceCounts(symbols);
+    }
+    return symbols.filter(matches);
+  }
+
+  /**
+   * The predicate of a query, and a loader for the reference counts its
+   * `refs:` terms compare, which must run before symbols are matched.
+   */
+  private async compile(node: QueryNode): Promise<{
+    matches: SymbolPredicate;
+    loadReferenceCounts: (symbols: IndexedSymbol[]) => Promise<void>;
+  }> {
+    const referenceCounts = new Map<string, number>();
+    const matches = compileQuery(node, {
+      ownersOf: this.ownersOf,
+      coverageOf: symbol => this.coverage?.coverageOf(symbol),
+      referencesOf: name => referenceCounts.get(name) ?? 0,
+      dependenciesOf: (label, filePath) => this.bazel.dependencyClosure(label, filePath)
+    });
+    const loadReferenceCounts = async (symbols: IndexedSymbol[]) => {
+      const names = symbols.filter(s => s.isDefinition !== false && !referenceCounts.has(s.name)).map(s => s.name);
+      if (names.length > 0) {
+        for (const [name, count] of await this.index.getReferenceCounts(names)) {
+          referenceCounts.set(name, count);
+        }
+      }
+    };
+    if (usesField(node, 'coverage')) {
+      await this.coverage?.refresh();
+    }
+    return { matches, loadReferenceCounts };
+  }
+
+  /**
+   * Candidates for a node, or undefined if it needs a full scan. AND takes
+   * the definitions of a name if it has one, otherwise the files all its
+   * file-driving terms allow; OR the union, if every branch has candidates.
+   */
+  private async plan(node: QueryNode, allFiles: () => Promise<string[]>): Promise<Candidates | undefined> {
+    switch (node.type) {
+      case 'term':
+        return this.planTerm(node, allFiles);
+      case 'not':
+        return undefined;
+      case 'and': {
+        const plans: Candidates[] = [];
+        for (const child of node.children) {
+          const candidates = await this.plan(child, allFiles);
+          if (candidates) {
+            plans.push(candidates);
+          }
+        }
+        const bySymbols = plans
+          .filter((c): c is Extract<Candidates, { type: 'symbols' }> => c.type === 'symbols')
+          .sort((a, b) => a.symbols.length - b.symbols.length);
+        if (bySymbols.length > 0) {
+          return bySymbols[0];
+        }
+        if (plans.length === 0) {
+          return undefined;
+        }
+        const byFiles = plans as Array<Extract<Candidates, { type: 'files' }>>;
+        const files = new Set([...byFiles[0].files].filter(file => byFiles.every(c => c.files.has(file))));
+        return { type: 'files', plan: byFiles.map(c => c.plan).join(' and '), files };
+      }
+      case 'or': {
+        const plans: Candidates[] = [];
+        for (const child of node.children) {
+          const candidates = await this.plan(child, allFiles);
+          if (!candidates) {
+            return undefined;
+          }
+          plans.push(candidates);
+        }
+        const plan = `(${plans.map(c => c.plan).join(' or ')})`;
+        if (plans.every(c => c.type === 'symbols')) {
+          const symbols = new Map<string, IndexedSymbol>();
+          for (const candidates of plans as Array<Extract<Candidates, { type: 'symbols' }>>) {
+            for (const symbol of candidates.symbols) {
+              symbols.set(symbol.id, symbol);
+            }
+          }
+          return { type: 'symbols', plan, symbols: [...symbols.values()] };
+        }
+        const files = new Set<string>();
+        for (const candidates of plans) {
+          for (const file of candidates.type === 'files' ? candidates.files : candidates.symbols.map(s => s.location.uri)) {
+            files.add(file);
+          }
+        }
+        return { type: 'files', plan, files };
+      }
+    }
+  }
+
+  private async planTerm(term: QueryTerm, allFiles: () => Promise<string[]>): Promise<Candidates | undefined> {
+    const value = term.values[0];
+    switch (term.field) {
+      case 'name':
+        if (isGlob(value)) {
+          return undefined;
+        }
+        return {
+          type: 'symbols',
+          plan: `definitions named "${value}"`,
+          symbols: await this.index.findDefinitions(value)
+        };
+      case 'receiver':
+      case 'container': {
+        // Methods and members are declared next to their type: in its file,
+        // or for Go in its package directory
+        const typeName = value.replace(/^\*/, '');
+        if (isGlob(typeName)) {
+          return undefined;
+        }
+        const definitions = await this.index.findDefinitions(typeName);
+        if (definitions.length === 0) {
+          return undefined;
+        }
+        const directories = new Set(definitions.map(s => path.dirname(s.location.uri)));
+        const files = (await allFiles()).filter(file => directories.has(path.dirname(file)));
+        return { type: 'files', plan: `files next to "${typeName}"`, files: new Set(files) };
+      }
+      case 'file': {
+        const regex = fileRegex(value);
+        const files = (await allFiles()).filter(file => regex.test(normalizePath(file)));
+        return { type: 'files', plan: `files matching ${value}`, files: new Set(files) };
+      }
+      case 'lang': {
+        const extensions = languageExtensions(term);
+        const files = (await allFiles()).filter(file => extensions.has(path.extname(file).toLowerCase()));
+        return { type: 'files', plan: `${term.values.join(',')} files`, files: new Set(files) };
+      }
+      case 'owner': {
+        const owned = ownerMatcher(term, this.ownersOf);
+        const files = (await allFiles()).filter(owned);
+        return { type: 'files', plan: `files owned by ${term.values.join(',')}`, files: new Set(files) };
+      }
+      default:
+        return undefined;
+    }
+  }
+}
+
+/**
+ * Compile a query into a symbol predicate. Throws QuerySyntaxError on
+ * invalid values (unknown tags or languages, bad signature patterns).
+ */
+function compileQuery(node: QueryNode, context: QueryContext): SymbolPredicate {
+  switch (node.type) {
+    case 'and': {
+      const children = node.children.map(child => compileQuery(child, context));
+      return symbol => children.every(matches => matches(symbol));
+    }
+    case 'or': {
+      const children = node.children.map(child => compileQuery(child, context));
+      return symbol => children.some(matches => matches(symbol));
+    }
+    case 'not': {
+      const child = compileQuery(node.child, context);
+      return symbol => !child(symbol);
+    }
+    case 'term':
+      return compileTerm(node, context);
+  }
+}
+
+function compileTerm(term: QueryTerm, context: QueryContext): SymbolPredicate {
+  const value = term.values[0];
+  switch (term.field) {
+    case 'name': {
+      const matches = valueMatcher(value);
+      return symbol => matches(symbol.name);
+    }
+    case 'text': {
+      const text = value.toLowerCase();
+      return symbol => symbol.name.toLowerCase().includes(text);
+    }
+    case 'kind': {
+      // Go tests stay functions and also match their test kind: kind:test, kind:benchmark
+      const kinds = new Set(term.values.map(normalizeKind));
+      return symbol => {
+        const testKind = (symbol.metadata?.go as GoSymbolMetadata | undefined)?.testKind;
+        return kinds.has(symbol.kind) || (testKind !== undefined && kinds.has(testKind));
+      };
+    }
+    case 'receiver': {
+      // Go receivers; methods of other languages by their class
+      const pointer = value.startsWith('*');
+      const matches = valueMatcher(value.replace(/^\*/, ''));
+      return symbol => {
+        const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+        const receiver = go?.receiverType ?? (symbol.kind === 'method' ? symbol.containerName : undefined);
+        return receiver !== undefined && matches(receiver) && (!pointer || go?.pointerReceiver === true);
+      };
+    }
+    case 'container': {
+      const matches = valueMatcher(value);
+      return symbol => symbol.containerName !== undefined && matches(symbol.containerName);
+    }
+    case 'exported': {
+      const exported = value === 'true';
+      return symbol => (symbol.isExported === true) === exported;
+    }
+    case 'deprecated': {
+      const deprecated = value === 'true';
+      return symbol => (symbol.deprecated !== undefined) === deprecated;
+    }
+    case 'file': {
+      const regex = fileRegex(value);
+      return symbol => regex.test(normalizePath(symbol.location.uri));
+    }
+    case 'lang': {
+      const extensions = languageExtensions(term);
+      return symbol => extensions.has(path.extname(symbol.location.uri).toLowerCase());
+    }
+    case 'tag': {
+      const tags = withTermError(term, () => parseCodeTags(term.values.join(',')));
+      return symbol => tags.some(tag => symbol.tags?.includes(tag));
+    }
+    case 'signature': {
+      const pattern = withTermError(term, () => parseSignaturePattern(value));
+      return symbol => matchesSignature(symbol, pattern);
+    }
+    case 'constraint':
+      return symbol => hasConstraint(symbol, value);
+    case 'generic': {
+      const generic = value === 'true';
+      return symbol => (symbol.typeParameters !== undefined && symbol.typeParameters.length > 0) === generic;
+    }
+    case 'value': {
+      const matches = valueMatcher(value);
+      return symbol => symbol.value !== undefined && matches(symbol.value);
+    }
+    case 'doc': {
+      const text = value.toLowerCase();
+      return symbol => symbol.doc !== undefined && symbol.doc.toLowerCase().includes(text);
+    }
+    case 'owner': {
+      const owned = ownerMatcher(term, context.ownersOf);
+      return symbol => owned(symbol.location.uri);
+    }
+    case 'coverage': {
+      // Symbols without coverage data never match, so coverage:0 means untested, not unknown
+      const compare = numberMatcher(value);
+      return symbol => {
+        const coverage = context.coverageOf(symbol);
+        return coverage !== undefined && compare(coverage.percent);
+      };
+    }
+    case 'refs': {
+      const compare = numberMatcher(value);
+      return symbol => compare(context.referencesOf(symbol.name));
+    }
+    case 'target': {
+      return symbol => bazelTargetsOf(symbol).some(label => term.values.some(pattern => matchesTargetPattern(label, pattern)));
+    }
+    case 'deps': {
+      return symbol => {
+        const labels = bazelTargetsOf(symbol);
+        if (labels.length === 0) {
+          return false;
+        }
+        const closure = context.dependenciesOf(value, symbol.location.uri);
+        return labels.some(label => closure.has(label));
+      };
+    }
+    case 'key': {
+      const regex = configPathRegex(value);
+      return symbol => {
+        const config = symbol.metadata?.config as ConfigFileKeyMetadata | undefined;
+        return config !== undefined && regex.test(config.path);
+      };
+    }
+  }
+}
+
+function bazelTargetsOf(symbol: IndexedSymbol): string[] {
+  return (symbol.metadata?.go as GoSymbolMetadata | undefined)?.bazelTargets ?? [];
+}
+
+/** `10`, `<10`, `<=10`, `>10` or `>=10` */
+function numberMatcher(value: string): (n: number) => boolean {
+  const [, operator, number] = /^([<>]=?)?(.+)$/.exec(value)!;
+  const target = Number(number);
+  switch (operator) {
+    case '<': return n => n < target;
+    case '<=': return n => n <= target;
+    case '>': return n => n > target;
+    case '>=': return n => n >= target;
+    default: return n => n === target;
+  }
+}
+
+function usesField(node: QueryNode, field: QueryTerm['field']): boolean {
+  switch (node.type) {
+    case 'and':
+    case 'or':
+      return node.children.some(child => usesField(child, field));
+    case 'not':
+      return usesField(node.child, field);
+    case 'term':
+      return node.field === field;
+  }
+}
+
+/**
+ * Files owned by any of the term's owners; `owner:none` matches files
+ * nobody owns. Owners compare case-insensitively, `@` optional.
+ */
+function ownerMatcher(term: QueryTerm, ownersOf: OwnersOf): (filePath: string) => boolean {
+  const wanted = new Set(term.values.map(normalizeOwner));
+  const unowned = term.values.some(value => value.toLowerCase() === 'none');
+  return filePath => {
+    const owners = ownersOf(filePath);
+    return owners.length === 0 ? unowned : owners.some(owner => wanted.has(normalizeOwner(owner)));
+  };
+}
+
+/** Exact match, or a glob match when the value has wildcards */
+function valueMatcher(value: string): (text: string) => boolean {
+  if (!isGlob(value)) {
+    return text => text === value;
+  }
+  const regex = new RegExp(`^${globToRegex(value)}$`);
+  return text => regex.test(text);
+}
+
+function isGlob(value: string): boolean {
+  return /[*?[]/.test(value);
+}
+
+/**
+ * File globs match the end of the path at a directory boundary, so
+ * `pkg/user/**` and `*_test.go` work without knowing the workspace root.
+ */
+function fileRegex(glob: string): RegExp {
+  const body = globToRegex(glob.replace(/^\.\//, '').replace(/^\//, ''));
+  return new RegExp(glob.startsWith('/') ? `^/${body}$` : `(^|/)${body}$`);
+}
+
+/**
+ * Config key paths match at the end on a key boundary, so
+ * `containers[].image` finds `spec.template.spec.containers[].image`.
+ * `*` matches one key, `**` any number of them.
+ */
+function configPathRegex(pattern: string): RegExp {
+  const source = pattern.split(/(\*\*|\*)/).map(part => {
+    if (part === '**') {
+      return '.*';
+    }
+    if (part === '*') {
+      return '[^.[\\]]*';
+    }
+    return part.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+  }).join('');
+  return new RegExp(`(?:^|\\.|(?=\\[))${source}$`);
+}
+
+function normalizePath(filePath: string): string {
+  return filePath.replace(/\\/g, '/');
+}
+
+function languageExtensions(term: QueryTerm): Set<string> {
+  const extensions = new Set<string>();
+  for (const raw of term.values) {
+    const known = extensionsOfLanguage(raw);
+    if (!known) {
+      throw new QuerySyntaxError(
+        `Unknown language "${raw}" (expected ${Object.keys(LANGUAGE_EXTENSIONS).join(', ')})`,
+        term.position
+      );
+    }
+    known.forEach(extension => extensions.add(extension));
+  }
+  return extensions;
+}
+
+function withTermError<T>(term: QueryTerm, parse: () => T): T {
+  try {
+    return parse();
+  } catch (error) {
+    throw new QuerySyntaxError(error instanceof Error ? error.message : String(error), term.position);
+  }
+}
+
+function bySourceOrder(a: IndexedSymbol, b: IndexedSymbol): number {
+  if (a.location.uri !== b.location.uri) {
+    return a.location.uri < b.location.uri ? -1 : 1;
+  }
+  return a.location.line - b.location.line;
+}
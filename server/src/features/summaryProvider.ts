@@ -0,0 +1,140 @@
+import { SummariesConfig } from '../config/configurationManager.js';
+import { FetchFn } from './embeddingProvider.js';
+import { JsonLinesProcess } from '../utils/jsonLinesProcess.js';
+import { ILogger } from '../utils/Logger.js';
+import { isSecureUrl } from '../utils/remoteStorage.js';
+
+/**
+ * Turns the source of a symbol into a one-line description of what it
+ * does, e.g. `Retries failed uploads with exponential backoff`.
+ */
+export interface SummaryProvider {
+  /** Model identifier; summaries of a different model are regenerated */
+  readonly model: string;
+  summarize(source: string): Promise<string>;
+  dispose?(): void;
+}
+
+interface OpenAIChatResponse {
+  choices?: Array<{ message?: { content?: string } }>;
+}
+
+/** Summaries longer than this are cut at a word */
+const MAX_SUMMARY_CHARS = 200;
+
+export const SUMMARY_PROMPT =
+  'Summarize what the following code does in one plain sentence of at most 20 words, for a developer new to the ' +
+  'codebase. Describe its purpose, not its syntax. Do not start with its name. Answer with the sentence only.';
+
+/**
+ * Any endpoint implementing the OpenAI `/v1/chat/completions` API
+ * (OpenAI, Azure OpenAI, Ollama, LM Studio, vLLM, ...). Source code and
+ * the API key go to it, so it must be HTTPS unless it is on this machine.
+ */
+export class OpenAISummaryProvider implements SummaryProvider {
+  constructor(
+    private readonly endpoint: string,
+    readonly model: string,
+    private readonly apiKey: string | undefined,
+    private readonly fetchFn: FetchFn = fetch as unknown as FetchFn
+  ) {
+    if (!isSecureUrl(endpoint)) {
+      throw new Error(`Summary endpoint ${endpoint} must use https (plain http only to localhost)`);
+    }
+  }
+
+  async summarize(source: string): Promise<string> {
+    const headers: Record<string, string> = { 'Content-Type': 'application/json' };
+    if (this.apiKey) {
+      headers.Authorization = `Bearer ${this.apiKey}`;
+    }
+
+    const response = await this.fetchFn(this.endpoint, {
+      method: 'POST',
+      headers,
+      body: JSON.stringify({
+        model: this.model,
+        messages: [
+          { role: 'system', content: SUMMARY_PROMPT },
+          { role: 'user', content: source }
+        ],
+        temperature: 0,
+        max_tokens: 64
+      })
+    });
+    if (!response.ok) {
+      const detail = (await response.text().catch(() => '')).slice(0, 500);
+      throw new Error(`Summary request failed: ${response.status} ${response.statusText}${detail ? ` - ${detail}` : ''}`);
+    }
+
+    const body = await response.json() as OpenAIChatResponse;
+    const content = body?.choices?.[0]?.message?.content;
+    if (typeof content !== 'string') {
+      throw new Error('Summary response has no message content');
+    }
+    return toOneLine(content);
+  }
+}
+
+/**
+ * A local model behind a JSON-lines process:
+ *
+ *   -> {"id":1,"method":"summarize","params":{"source":"...","prompt":"..."}}
+ *   <- {"id":1,"result":{"summary":"..."}}
+ */
+export class CommandSummaryProvider implements SummaryProvider {
+  private readonly process: JsonLinesProcess;
+
+  constructor(
+    readonly model: string,
+    command: string,
+    args: string[],
+    logger: ILogger,
+    cwd?: string
+  ) {
+    // Generating text on CPU takes a while
+    this.process = new JsonLinesProcess({ name: model, command, args, timeoutMs: 120000 }, 'Summaries', logger, cwd);
+  }
+
+  async summarize(source: string): Promise<string> {
+    const result = await this.process.request<{ summary?: string }>('summarize', { source, prompt: SUMMARY_PROMPT });
+    if (typeof result?.summary !== 'string') {
+      throw new Error(`Summary process '${this.model}' returned no summary`);
+    }
+    return toOneLine(result.summary);
+  }
+
+  dispose(): void {
+    this.process.dispose();
+  }
+}
+
+/**
+ * Create the provider described by the configuration.
+ */
+export function createSummaryProvider(config: SummariesConfig, logger: ILogger, cwd?: string): SummaryProvider {
+  if (config.provider === 'command') {
+    if (!config.command) {
+      throw new Error('smartIndexer.summaries.command is required for the command provider');
+    }
+    return new CommandSummaryProvider(config.model, config.command, config.args ?? [], logger, cwd);
+  }
+  return new OpenAISummaryProvider(config.endpoint, config.model, process.env[config.apiKeyEnv]);
+}
+
+/**
+ * The first line of a model's answer, without the quotes, Markdown and
+ * labels models like to add.
+ */
+export function toOneLine(text: string): string {
+  let line = text.trim().split('\n').find(candidate => candidate.trim() !== '')?.trim() ?? '';
+  line = line
+    .replace(/^(summary|description)\s*:\s*/i, '')
+    .replace(/^[*_`"'“]+|[*_`"'”]+$/g, '')
+    .trim();
+  if (line.length > MAX_SUMMARY_CHARS) {
+    const cut = line.slice(0, MAX_SUMMARY_CHARS);
+    line = `${cut.slice(0, Math.max(cut.lastIndexOf(' '), MAX_SUMMARY_CHARS / 2)).trimEnd()}…`;
+  }
+  return line;
+}
@@ -0,0 +1,82 @@
+/**
+ * SymbolDocs Tests
+ *
+ * Verifies doc link resolution against the index and rendered output.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { SymbolDocs } from './symbolDocs.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockIndex, createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const greetGo = `package greet
+
+// Person is someone to greet.
+type Person struct {
+	Name string
+}
+
+// Greet greets p, see [Person.Name], [Person] and [strings.Title].
+// Like [Unknown] it never fails.
+func Greet(p Person) string { return p.Name }
+`;
+
+const stringsGo = `package strings
+
+func Title(s string) string { return s }
+`;
+
+describe('SymbolDocs', () => {
+  let index: MockIndex;
+  let docs: SymbolDocs;
+
+  beforeEach(() => {
+    index = new MockIndex();
+    const indexer = new GoIndexer();
+    for (const [uri, source] of [['/ws/greet/greet.go', greetGo], ['/ws/strings/strings.go', stringsGo]]) {
+      for (const symbol of indexer.indexFile(uri, source).symbols) {
+        index.addSymbol(symbol);
+      }
+    }
+    docs = new SymbolDocs(index);
+  });
+
+  it('should render Go doc links to indexed definitions', async () => {
+    const [greet] = await index.findDefinitions('Greet');
+
+    expect(await docs.render(greet)).toBe(
+      'Greet greets p, see [Person.Name](file:///ws/greet/greet.go#L5), [Person](file:///ws/greet/greet.go#L4) ' +
+      'and [strings.Title](file:///ws/strings/strings.go#L3). Like \\[Unknown\\] it never fails.'
+    );
+    expect(await docs.render(createTestSymbol({ name: 'NoDoc' }))).toBeUndefined();
+  });
+
+  it('should prefer definitions near the documented symbol', async () => {
+    const far = createTestSymbol({
+      name: 'Config',
+      kind: 'interface',
+      filePath: '/ws/other/config.ts',
+      location: { uri: '/ws/other/config.ts', line: 0, character: 0 }
+    });
+    const near = createTestSymbol({
+      name: 'Config',
+      kind: 'interface',
+      filePath: '/ws/app/config.ts',
+      location: { uri: '/ws/app/config.ts', line: 2, character: 0 },
+      range: { startLine: 2, startCharacter: 0, endLine: 4, endCharacter: 1 }
+    });
+    index.addSymbol(far);
+    index.addSymbol(near);
+    const loader = createTestSymbol({
+      name: 'loadConfig',
+      filePath: '/ws/app/loader.ts',
+      location: { uri: '/ws/app/loader.ts', line: 0, character: 0 },
+      doc: 'Reads a {@link Config}.\n@param path - File to read'
+    });
+
+    expect(await docs.resolveLink('Config', loader)).toBe(near);
+    expect(await docs.render(loader)).toBe(
+      'Reads a [Config](file:///ws/app/config.ts#L3).\n\n*@param* `path` — File to read'
+    );
+  });
+});
@@ -0,0 +1,101 @@
+import * as path from 'path';
+import { pathToFileURL } from 'url';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import { docFormatFor, extractDocLinks, renderDocMarkdown } from '../utils/docComments.js';
+
+/**
+ * Symbol Docs - renders a symbol's doc comment as Markdown, with links to
+ * the symbols it mentions.
+ *
+ * Link targets ([Name], [Type.Method], [pkg.Name] in Go, `{@link X}` in
+ * JSDoc, Sphinx roles in docstrings) are looked up in the index by their
+ * last name part; a qualifier must match the container or the Go package.
+ * Among several matches the one in the same file wins, then the same
+ * directory, then a top-level declaration. Links point at the definition
+ * as `file:///path#L<line>`.
+ */
+export class SymbolDocs {
+  constructor(private index: Pick<ISymbolIndex, 'findDefinitions'>) {}
+
+  /**
+   * Markdown for the symbol's doc comment, or undefined when it has none.
+   */
+  async render(symbol: IndexedSymbol): Promise<string | undefined> {
+    if (!symbol.doc) {
+      return undefined;
+    }
+    const format = docFormatFor(symbol.filePath || symbol.location.uri);
+    const urls = new Map<string, string>();
+    for (const target of extractDocLinks(symbol.doc, format)) {
+      const definition = await this.resolveLink(target, symbol);
+      if (definition) {
+        urls.set(target, symbolUrl(definition));
+      }
+    }
+    return renderDocMarkdown(symbol.doc, format, target => urls.get(target));
+  }
+
+  /**
+   * Definition a doc link refers to, seen from the documented symbol.
+   */
+  resolveLink(target: string, from: IndexedSymbol): Promise<IndexedSymbol | undefined> {
+    return this.resolveLinkFrom(target, from.location.uri);
+  }
+
+  /**
+   * Definition a link refers to, seen from a file, e.g. a Markdown
+   * document mentioning `Person.Greet` (see features/docsIndex.ts).
+   */
+  async resolveLinkFrom(target: string, uri: string): Promise<IndexedSymbol | undefined> {
+    const candidates = await this.definitionsOf(target);
+    if (candidates.length === 0) {
+      return undefined;
+    }
+
+    const fromDir = path.dirname(uri);
+    const rank = (s: IndexedSymbol): number => {
+      if (s.location.uri === uri) {
+        return 0;
+      }
+      if (path.dirname(s.location.uri) === fromDir) {
+        return 1;
+      }
+      return s.containerName ? 3 : 2;
+    };
+    return candidates.reduce((best, s) => (rank(s) < rank(best) ? s : best));
+  }
+
+  /**
+   * Every definition a link could refer to: `Name`, `Type.Method`,
+   * `pkg.Name` or `pkg.Type.Method`.
+   */
+  async definitionsOf(target: string): Promise<IndexedSymbol[]> {
+    const parts = target.replace(/^\*/, '').split('.');
+    const name = parts.pop()!;
+    const qualifier = parts.join('.');
+    return (await this.index.findDefinitions(name))
+      .filter(s => s.isDefinition !== false && s.name === name && (!qualifier || matchesQualifier(s, qualifier)));
+  }
+}
+
+/**
+ * Does `Type`, `pkg` or `pkg.Type` qualify the symbol?
+ */
+function matchesQualifier(symbol: IndexedSymbol, qualifier: string): boolean {
+  if (symbol.containerName === qualifier || symbol.fullContainerPath === qualifier ||
+      symbol.fullContainerPath?.endsWith('.' + qualifier)) {
+    return true;
+  }
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  const [pkg, type] = qualifier.split('.');
+  if (go?.package !== pkg && !go?.importPath?.endsWith('/' + pkg)) {
+    return false;
+  }
+  return type === undefined ? !symbol.containerName : symbol.containerName === type;
+}
+
+function symbolUrl(symbol: IndexedSymbol): string {
+  return `${pathToFileURL(symbol.location.uri).href}#L${symbol.range.startLine + 1}`;
+}
@@ -0,0 +1,146 @@
+/**
+ * SymbolHistoryIndex Tests
+ *
+ * Blames a throwaway git repository with commits at fixed dates and checks
+ * ownership and recent-change queries.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SymbolHistoryIndex, GitRunner, parseLinePorcelain } from './symbolHistory.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const personV1 = `package people
+
+type Person struct {
+	Name string
+}
+
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+`;
+
+const personV2 = `package people
+
+type Person struct {
+	Name string
+}
+
+func NewPerson(name string) *Person {
+	p := &Person{Name: name}
+	return p
+}
+`;
+
+const NOW = Date.parse('2024-06-30T12:00:00Z');
+
+describe('SymbolHistoryIndex', () => {
+  let root: string;
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function git(args: string[], date?: string, author = 'Ada <ada@example.com>'): string {
+    const [name, email] = author.replace('>', '').split(' <');
+    return execFileSync('git', args, {
+      cwd: root,
+      encoding: 'utf-8',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: name,
+        GIT_AUTHOR_EMAIL: email,
+        GIT_COMMITTER_NAME: name,
+        GIT_COMMITTER_EMAIL: email,
+        ...(date && { GIT_AUTHOR_DATE: date, GIT_COMMITTER_DATE: date })
+      }
+    });
+  }
+
+  function commit(file: string, content: string, date: string, author?: string): void {
+    fs.writeFileSync(path.join(root, file), content);
+    git(['add', file]);
+    git(['commit', '-q', '-m', `update ${file}`], date, author);
+  }
+
+  function reindex(file: string): void {
+    const uri = path.join(root, file);
+    const result = goIndexer.indexFile(uri, fs.readFileSync(uri, 'utf-8'));
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  function createHistory(): SymbolHistoryIndex {
+    const runGit: GitRunner = async args => git(args);
+    return new SymbolHistoryIndex(index as unknown as BackgroundIndex, root, runGit, () => NOW);
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'symbol-history-'));
+    index = new MockBackgroundIndex();
+    git(['init', '-q']);
+    commit('person.go', personV1, '2024-01-10T09:00:00Z');
+    commit('person.go', personV2, '2024-06-20T09:00:00Z', 'Grace <grace@example.com>');
+    reindex('person.go');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should report last commit, author and age of a symbol', async () => {
+    const [owner] = await createHistory().findOwners('NewPerson');
+
+    expect(owner.symbol.name).toBe('NewPerson');
+    expect(owner.history).toMatchObject({
+      author: 'Grace',
+      authorEmail: 'grace@example.com',
+      date: '2024-06-20T09:00:00.000Z',
+      ageDays: 10,
+      summary: 'update person.go'
+    });
+    expect(owner.history.authors).toEqual([
+      { author: 'Ada', lines: 2 },
+      { author: 'Grace', lines: 2 }
+    ]);
+
+    const [person] = await createHistory().findOwners('people.Person');
+    expect(person.history).toMatchObject({ author: 'Ada', ageDays: 172 });
+  });
+
+  it('should list symbols changed within a period, including uncommitted edits', async () => {
+    const history = createHistory();
+    expect((await history.findChangedSince(30)).map(s => s.symbol.name)).toEqual(['NewPerson']);
+    expect((await history.findChangedSince(365)).map(s => s.symbol.name)).toEqual(['NewPerson', 'Person', 'Name']);
+
+    fs.appendFileSync(path.join(root, 'person.go'), '\nfunc Greet() {}\n');
+    reindex('person.go');
+    const [greet] = await history.findChangedSince(1, { kinds: ['function'] });
+    expect(greet.symbol.name).toBe('Greet');
+    expect(greet.history).toMatchObject({ uncommitted: true, author: 'Not Committed Yet', ageDays: 0 });
+  });
+
+  it('should parse line porcelain output', () => {
+    const output = [
+      'a'.repeat(40) + ' 1 1 1',
+      'author Ada',
+      'author-mail <ada@example.com>',
+      'author-time 1700000000',
+      'summary Initial commit',
+      'filename person.go',
+      '\tpackage people',
+      ''
+    ].join('\n');
+
+    expect(parseLinePorcelain(output)).toEqual([{
+      commit: 'a'.repeat(40),
+      author: 'Ada',
+      authorEmail: 'ada@example.com',
+      time: 1700000000,
+      summary: 'Initial commit'
+    }]);
+  });
+});
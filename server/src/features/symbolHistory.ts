@@ -0,0 +1,311 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { simpleGit } from 'simple-git';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/**
+ * Runs git with the given arguments in the workspace root and returns stdout.
+ */
+export type GitRunner = (args: string[]) => Promise<string>;
+
+/**
+ * Blame information of one source line.
+ */
+export interface BlameLine {
+  commit: string;
+  author: string;
+  authorEmail: string;
+  /** Author time, seconds since the epoch */
+  time: number;
+  summary: string;
+}
+
+export interface SymbolHistory {
+  /** Last commit touching the symbol's lines */
+  commit: string;
+  author: string;
+  authorEmail: string;
+  /** ISO 8601 author date of that commit */
+  date: string;
+  ageDays: number;
+  summary: string;
+  /** True when the symbol has uncommitted changes */
+  uncommitted?: boolean;
+  /** Authors by number of lines they last touched, most lines first */
+  authors: Array<{ author: string; lines: number }>;
+}
+
+export interface SymbolWithHistory {
+  symbol: IndexedSymbol;
+  history: SymbolHistory;
+}
+
+export interface ChangedSymbolsOptions {
+  /** Only these symbol kinds (default: all definitions) */
+  kinds?: string[];
+  /** Cancellation token for aborting the scan */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting scan progress */
+  onProgress?: ProgressCallback;
+}
+
+/** Commit git blame reports for lines with uncommitted changes */
+export const UNCOMMITTED = '0000000000000000000000000000000000000000';
+
+/** The part of an index history reads: the background index or the library Indexer */
+export type SymbolHistorySourceIndex = Pick<BackgroundIndex, 'findDefinitions' | 'getAllFiles' | 'getFileSymbols'>;
+
+const YIELD_INTERVAL = 50;
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+/**
+ * Parse `git blame --line-porcelain` output; entry i describes line i + 1.
+ */
+export function parseLinePorcelain(output: string): BlameLine[] {
+  const lines: BlameLine[] = [];
+  let current: Partial<BlameLine> = {};
+
+  for (const line of output.split('\n')) {
+    if (line.startsWith('\t')) {
+      lines.push({
+        commit: current.commit ?? UNCOMMITTED,
+        author: current.author ?? '',
+        authorEmail: current.authorEmail ?? '',
+        time: current.time ?? 0,
+        summary: current.summary ?? ''
+      });
+      current = {};
+      continue;
+    }
+
+    const space = line.indexOf(' ');
+    const key = space === -1 ? line : line.slice(0, space);
+    const value = space === -1 ? '' : line.slice(space + 1);
+    if (/^[0-9a-f]{40}$/.test(key)) {
+      current.commit = key;
+    } else if (key === 'author') {
+      current.author = value;
+    } else if (key === 'author-mail') {
+      current.authorEmail = value.replace(/^<|>$/g, '');
+    } else if (key === 'author-time') {
+      current.time = parseInt(value, 10);
+    } else if (key === 'summary') {
+      current.summary = value;
+    }
+  }
+  return lines;
+}
+
+/**
+ * Symbol History - last-modified commit, author and age of indexed symbols,
+ * from `git blame` of the lines each symbol spans.
+ *
+ * Blame runs once per file and is cached until the file or HEAD changes,
+ * so ownership questions ("who owns NewPerson?") and change queries
+ * ("symbols changed in the last 30 days") are answered from the index
+ * without blaming files by hand. Change queries only blame files that
+ * `git log` reports as touched in the period (plus uncommitted changes).
+ */
+export class SymbolHistoryIndex {
+  private blameCache: Map<string, { key: string; lines: BlameLine[] }> = new Map();
+  private runGit: GitRunner;
+
+  constructor(
+    private backgroundIndex: SymbolHistorySourceIndex,
+    private workspaceRoot: string,
+    runGit?: GitRunner,
+    private now: () => number = Date.now
+  ) {
+    const git = simpleGit(workspaceRoot);
+    this.runGit = runGit ?? (args => git.raw(args));
+  }
+
+  /**
+   * Definitions of `name` with their history. `Container.name` and
+   * `package.Name` narrow the match.
+   */
+  async findOwners(name: string): Promise<SymbolWithHistory[]> {
+    const dot = name.lastIndexOf('.');
+    const qualifier = dot === -1 ? undefined : name.slice(0, dot);
+    const shortName = dot === -1 ? name : name.slice(dot + 1);
+
+    const definitions = (await this.backgroundIndex.findDefinitions(shortName)).filter(symbol =>
+      !qualifier ||
+      symbol.containerName === qualifier ||
+      (symbol.metadata?.go as { package?: string } | undefined)?.package === qualifier
+    );
+
+    const head = await this.getHead();
+    const results: SymbolWithHistory[] = [];
+    for (const symbol of definitions) {
+      const history = await this.getSymbolHistory(symbol, head);
+      if (history) {
+        results.push({ symbol, history });
+      }
+    }
+    return results;
+  }
+
+  /**
+   * Definitions whose lines were last changed within the given number of
+   * days, most recent first.
+   */
+  async findChangedSince(days: number, options: ChangedSymbolsOptions = {}): Promise<SymbolWithHistory[]> {
+    const { cancellationToken, onProgress } = options;
+    const cutoff = this.now() - days * DAY_MS;
+    const head = await this.getHead();
+
+    const changedFiles = await this.getFilesChangedSince(new Date(cutoff));
+    const indexed = new Set(await this.backgroundIndex.getAllFiles());
+    const files = Array.from(changedFiles).filter(file => indexed.has(file)).sort();
+
+    const results: SymbolWithHistory[] = [];
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Blaming changed files (${i}/${files.length})`);
+      }
+
+      const blame = await this.getFileBlame(files[i], head);
+      if (!blame) {
+        continue;
+      }
+      for (const symbol of await this.backgroundIndex.getFileSymbols(files[i])) {
+        if (symbol.isDefinition === false || (options.kinds && !options.kinds.includes(symbol.kind))) {
+          continue;
+        }
+        const history = this.summarize(symbol, blame);
+        if (history && Date.parse(history.date) >= cutoff) {
+          results.push({ symbol, history });
+        }
+      }
+    }
+
+    onProgress?.(files.length, files.length, 'Done');
+    return results.sort((a, b) => Date.parse(b.history.date) - Date.parse(a.history.date));
+  }
+
+  async getSymbolHistory(symbol: IndexedSymbol, head?: string): Promise<SymbolHistory | undefined> {
+    const blame = await this.getFileBlame(symbol.filePath, head ?? await this.getHead());
+    return blame ? this.summarize(symbol, blame) : undefined;
+  }
+
+  /**
+   * Blame of a file, or undefined for files git does not track.
+   */
+  async getFileBlame(filePath: string, head: string): Promise<BlameLine[] | undefined> {
+    let key: string;
+    try {
+      const stats = await fsPromises.stat(filePath);
+      key = `${head}:${stats.mtimeMs}:${stats.size}`;
+    } catch {
+      return undefined;
+    }
+
+    const cached = this.blameCache.get(filePath);
+    if (cached && cached.key === key) {
+      return cached.lines;
+    }
+
+    try {
+      const relative = path.relative(this.workspaceRoot, filePath).split(path.sep).join('/');
+      const lines = parseLinePorcelain(await this.runGit(['blame', '--line-porcelain', '--', relative]));
+      this.blameCache.set(filePath, { key, lines });
+      return lines;
+    } catch {
+      // Untracked or outside the repository
+      return undefined;
+    }
+  }
+
+  /**
+   * Drop cached blame, e.g. after a branch switch.
+   */
+  clear(): void {
+    this.blameCache.clear();
+  }
+
+  private summarize(symbol: IndexedSymbol, blame: BlameLine[]): SymbolHistory | undefined {
+    const lines = blame.slice(symbol.range.startLine, symbol.range.endLine + 1);
+    if (lines.length === 0) {
+      return undefined;
+    }
+
+    // Uncommitted lines count as changed now
+    const nowSeconds = Math.floor(this.now() / 1000);
+    const timeOf = (line: BlameLine) => line.commit === UNCOMMITTED ? nowSeconds : line.time;
+    let latest = lines[0];
+    const authors = new Map<string, number>();
+    for (const line of lines) {
+      if (timeOf(line) > timeOf(latest)) {
+        latest = line;
+      }
+      if (line.commit !== UNCOMMITTED) {
+        authors.set(line.author, (authors.get(line.author) ?? 0) + 1);
+      }
+    }
+
+    const uncommitted = latest.commit === UNCOMMITTED;
+    const time = timeOf(latest);
+    return {
+      commit: uncommitted ? '' : latest.commit,
+      author: uncommitted ? 'Not Committed Yet' : latest.author,
+      authorEmail: uncommitted ? '' : latest.authorEmail,
+      date: new Date(time * 1000).toISOString(),
+      ageDays: Math.floor((this.now() - time * 1000) / DAY_MS),
+      summary: uncommitted ? '' : latest.summary,
+      ...(uncommitted && { uncommitted: true }),
+      authors: Array.from(authors, ([author, count]) => ({ author, lines: count }))
+        .sort((a, b) => b.lines - a.lines || a.author.localeCompare(b.author))
+    };
+  }
+
+  /**
+   * Current HEAD commit, '' outside a repository; the cache key for getFileBlame.
+   */
+  async getHead(): Promise<string> {
+    try {
+      return (await this.runGit(['rev-parse', 'HEAD'])).trim();
+    } catch {
+      return '';
+    }
+  }
+
+  /**
+   * Absolute paths of files committed to since `since`, plus files with
+   * uncommitted changes.
+   */
+  private async getFilesChangedSince(since: Date): Promise<Set<string>> {
+    const files = new Set<string>();
+    const collect = (output: string) => {
+      for (const line of output.split('\n')) {
+        if (line.trim()) {
+          files.add(path.join(this.workspaceRoot, line.trim()));
+        }
+      }
+    };
+
+    try {
+      collect(await this.runGit([
+        '-c', 'core.quotePath=false', 'log', '--relative', `--since=${since.toISOString()}`, '--name-only', '--format='
+      ]));
+    } catch {
+      // No commits yet
+    }
+    try {
+      collect(await this.runGit(['-c', 'core.quotePath=false', 'diff', '--relative', '--name-only', 'HEAD']));
+    } catch {
+      // No HEAD yet
+    }
+    return files;
+  }
+}
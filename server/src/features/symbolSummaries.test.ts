@@ -0,0 +1,208 @@
+/**
+ * SymbolSummaries Tests
+ *
+ * Uses a provider that echoes the declaration line, and checks which
+ * symbols are summarized, incremental updates, partial failures, the
+ * on-disk store, and the chat completions request of the openai provider.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SymbolSummaries } from './symbolSummaries.js';
+import { OpenAISummaryProvider, SummaryProvider, toOneLine } from './summaryProvider.js';
+import { FetchFn } from './embeddingProvider.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { attachFunctionMetrics } from '../utils/functionMetrics.js';
+
+const retryGo = `package upload
+
+// Retry uploads a file until it succeeds.
+func Retry(file string, attempts int) error {
+	for i := 0; i < attempts; i++ {
+		if err := send(file); err == nil {
+			return nil
+		} else if i == attempts-1 {
+			return err
+		}
+	}
+	return nil
+}
+
+func retryLocal(file string, attempts int) error {
+	for i := 0; i < attempts; i++ {
+		if err := send(file); err == nil {
+			return nil
+		} else if i == attempts-1 {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name returns the name.
+func Name() string {
+	return "upload"
+}
+`;
+
+const configGo = `package upload
+
+// Config configures uploads.
+type Config struct {
+	Endpoint string
+	Attempts int
+	Timeout  int
+	Region   string
+}
+`;
+
+/** Summarizes a source as its declaration line */
+class EchoProvider implements SummaryProvider {
+  sources: string[] = [];
+  failAfter = Infinity;
+
+  constructor(readonly model: string = 'echo') {}
+
+  async summarize(source: string): Promise<string> {
+    if (this.sources.length >= this.failAfter) {
+      throw new Error('rate limited');
+    }
+    this.sources.push(source);
+    const declaration = source.split('\n').find(line => /^(func|type) /.test(line)) ?? '';
+    return `Summary of ${declaration.replace(/ \{$/, '')}`;
+  }
+}
+
+describe('SymbolSummaries', () => {
+  let root: string;
+  let cacheDir: string;
+  let background: MockBackgroundIndex;
+  let provider: EchoProvider;
+  const goIndexer = new GoIndexer();
+
+  function writeFile(file: string, content: string): string {
+    const uri = path.join(root, file);
+    fs.writeFileSync(uri, content);
+    const result = goIndexer.indexFile(uri, content);
+    attachFunctionMetrics(content, result.symbols, uri);
+    background.addFile(uri, result.symbols, result.references, { hash: `${content.length}:${content}` });
+    return uri;
+  }
+
+  function createSummaries(): SymbolSummaries {
+    return new SymbolSummaries(background.asBackgroundIndex(), provider, root, cacheDir, { minComplexity: 4, minLines: 6, concurrency: 2 });
+  }
+
+  async function symbolId(name: string): Promise<string> {
+    for (const uri of background.getAllFileUris()) {
+      const symbol = (await background.getFileSymbols(uri)).find(s => s.name === name);
+      if (symbol) {
+        return symbol.id;
+      }
+    }
+    throw new Error(`No symbol ${name}`);
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'symbol-summaries-'));
+    cacheDir = path.join(root, '.smart-index');
+    background = new MockBackgroundIndex();
+    provider = new EchoProvider();
+    writeFile('retry.go', retryGo);
+    writeFile('config.go', configGo);
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should summarize complex and long exported symbols only', async () => {
+    const summaries = createSummaries();
+    const stats = await summaries.update();
+
+    expect(stats).toEqual({ files: 2, summaries: 2, summarized: 2 });
+    expect(summaries.summaryOf(await symbolId('Retry'))).toBe('Summary of func Retry(file string, attempts int) error');
+    expect(summaries.summaryOf(await symbolId('Config'))).toBe('Summary of type Config struct');
+    // Unexported, or simple
+    expect(summaries.summaryOf(await symbolId('retryLocal'))).toBeUndefined();
+    expect(summaries.summaryOf(await symbolId('Name'))).toBeUndefined();
+
+    const retrySource = provider.sources.find(source => source.startsWith('function Retry'))!;
+    expect(retrySource.split('\n').slice(0, 4)).toEqual([
+      'function Retry',
+      'file: retry.go',
+      '// Retry uploads a file until it succeeds.',
+      'func Retry(file string, attempts int) error {'
+    ]);
+
+    expect(summaries.list().map(s => [s.name, s.kind, s.line])).toEqual([['Config', 'struct', 3], ['Retry', 'function', 3]]);
+    expect(summaries.list({ scopePath: path.join(root, 'retry.go') }).map(s => s.name)).toEqual(['Retry']);
+  });
+
+  it('should only summarize symbols whose source changed', async () => {
+    await createSummaries().update();
+    provider.sources = [];
+
+    const summaries = createSummaries();
+    expect(await summaries.update()).toMatchObject({ summarized: 0 });
+    expect(provider.sources).toEqual([]);
+
+    writeFile('retry.go', retryGo.replace('until it succeeds', 'until it is accepted'));
+    writeFile('config.go', `\n${configGo}`);
+    expect(await summaries.update()).toMatchObject({ summaries: 2, summarized: 1 });
+    expect(provider.sources.map(source => source.split('\n')[0])).toEqual(['function Retry']);
+
+    background.removeFile(path.join(root, 'config.go'));
+    expect(await summaries.update()).toEqual({ files: 1, summaries: 1, summarized: 0 });
+  });
+
+  it('should keep the summaries of a failed update and regenerate them for another model', async () => {
+    provider.failAfter = 1;
+    await expect(createSummaries().update()).rejects.toThrow('rate limited');
+
+    provider.failAfter = Infinity;
+    provider.sources = [];
+    const summaries = createSummaries();
+    expect(await summaries.update()).toMatchObject({ summaries: 2, summarized: 1 });
+    expect(provider.sources).toHaveLength(1);
+
+    provider = new EchoProvider('echo-2');
+    expect(await createSummaries().update()).toMatchObject({ summarized: 2 });
+  });
+
+  it('should ask a chat completions endpoint for one line', async () => {
+    const requests: Array<{ url: string; headers: Record<string, string>; body: any }> = [];
+    const fetchFn: FetchFn = async (url, init) => {
+      requests.push({ url, headers: init.headers, body: JSON.parse(init.body) });
+      return {
+        ok: true,
+        status: 200,
+        statusText: 'OK',
+        json: async () => ({ choices: [{ message: { content: '"Retries failed uploads."\n\nIt loops.' } }] }),
+        text: async () => ''
+      };
+    };
+    const openai = new OpenAISummaryProvider('http://localhost:11434/v1/chat/completions', 'llama3', 'secret', fetchFn);
+
+    expect(await openai.summarize('func Retry() {}')).toBe('Retries failed uploads.');
+    expect(requests[0].headers.Authorization).toBe('Bearer secret');
+    expect(requests[0].body.model).toBe('llama3');
+    expect(requests[0].body.messages.map((m: { role: string }) => m.role)).toEqual(['system', 'user']);
+    expect(requests[0].body.messages[1].content).toBe('func Retry() {}');
+
+    expect(() => new OpenAISummaryProvider('http://llm.example.com/v1/chat/completions', 'llama3', 'secret', fetchFn)).toThrow(
+      'Summary endpoint http://llm.example.com/v1/chat/completions must use https (plain http only to localhost)'
+    );
+    expect(() => new OpenAISummaryProvider('https://llm.example.com/v1/chat/completions', 'llama3', 'secret', fetchFn)).not.toThrow();
+  });
+
+  it('should cut answers down to one line', () => {
+    expect(toOneLine('Summary: **Parses the config file.**')).toBe('Parses the config file.');
+    const long = toOneLine('word '.repeat(100));
+    expect(long.length).toBeLessThanOrEqual(201);
+    expect(long.endsWith('word…')).toBe(true);
+  });
+});
@@ -0,0 +1,383 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { SummaryProvider } from './summaryProvider.js';
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface SymbolSummariesOptions {
+  /** Functions and methods at least this complex are summarized (default 8) */
+  minComplexity?: number;
+  /** Functions, methods and types at least this long are summarized (default 40) */
+  minLines?: number;
+  /** Sources longer than this are truncated (default 4000) */
+  maxSourceChars?: number;
+  /** Summary requests in flight at once (default 4) */
+  concurrency?: number;
+}
+
+export interface SummaryUpdateOptions {
+  /** Cancellation token for aborting the update */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting update progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface SymbolSummary {
+  /** Symbol id the summary is of */
+  id: string;
+  uri: string;
+  name: string;
+  kind: string;
+  containerName?: string;
+  line: number;
+  summary: string;
+}
+
+export interface SymbolSummaryStats {
+  files: number;
+  summaries: number;
+  /** Symbols sent to the provider by the last update */
+  summarized: number;
+}
+
+export interface SummaryListOptions {
+  /** Only symbols under this folder */
+  scopePath?: string;
+  limit?: number;
+}
+
+/** The part of an index summaries are made from: the background index */
+export type SummarySourceIndex = Pick<BackgroundIndex, 'getAllFileUris' | 'getFileInfo' | 'getFileSymbols'>;
+
+/** Looks up the summary of a symbol, for hover and symbol output */
+export type SummaryLookup = Pick<SymbolSummaries, 'summaryOf'>;
+
+/** Reads a workspace file; injectable for tests */
+export type SummaryReader = (filePath: string) => Promise<string>;
+
+interface StoredEntry {
+  id: string;
+  name: string;
+  kind: string;
+  containerName?: string;
+  line: number;
+  /** Hash of the summarized source; identical sources share one summary */
+  hash: string;
+}
+
+interface StoredFile {
+  hash: string;
+  entries: StoredEntry[];
+}
+
+interface StoredSummaries {
+  version: number;
+  model: string;
+  files: Record<string, StoredFile>;
+  /** Summary per source hash */
+  summaries: Record<string, string>;
+}
+
+type SourceEntry = StoredEntry & { text: string };
+
+const STORE_VERSION = 1;
+const YIELD_INTERVAL = 50;
+const DEFAULT_MIN_COMPLEXITY = 8;
+const DEFAULT_MIN_LINES = 40;
+const DEFAULT_MAX_SOURCE_CHARS = 4000;
+const DEFAULT_CONCURRENCY = 4;
+
+/** Symbols a sentence helps with; fields, variables and imports explain themselves */
+const SUMMARY_KINDS = new Set(['function', 'method', 'class', 'interface', 'struct', 'type', 'enum']);
+
+/** Comment-ish lines directly above a declaration, plus decorators/attributes */
+const LEADING_LINE_RE = /^\s*(\/\/|\/\*|\*|#|@)/;
+
+/**
+ * Symbol Summaries - one-line natural-language descriptions of complex
+ * exported functions and types, written by an LLM, for hover and search
+ * output in codebases the reader does not know yet.
+ *
+ * Exported functions and methods reaching `minComplexity` (the cyclomatic
+ * complexity stored at indexing time, see utils/functionMetrics.ts) or
+ * `minLines`, and exported types reaching `minLines`, are summarized from
+ * their kind, qualified name, file, leading comment and source. Simple
+ * symbols are skipped: their name and doc comment say enough, and every
+ * summary is a paid request.
+ *
+ * Updates are incremental: a file is looked at again only when its hash in
+ * the background index changed, and a symbol is summarized again only when
+ * its source changed. Summaries live in `<cacheDir>/summaries/index.json`
+ * and are regenerated when the model changes. Between updates, a changed
+ * symbol keeps its previous summary.
+ */
+export class SymbolSummaries {
+  private files: Map<string, StoredFile> = new Map();
+  private summaries: Map<string, string> = new Map();
+  private loaded = false;
+  /** Entries per symbol id; rebuilt after updates */
+  private byId: Map<string, StoredEntry & { uri: string }> | null = null;
+
+  constructor(
+    private backgroundIndex: SummarySourceIndex,
+    private provider: SummaryProvider,
+    private workspaceRoot: string,
+    private cacheDir: string | undefined = undefined,
+    private options: SymbolSummariesOptions = {},
+    private readFile: SummaryReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Summarize the symbols of the background index that have no summary
+   * of their current source yet.
+   */
+  async update(options: SummaryUpdateOptions = {}): Promise<SymbolSummaryStats> {
+    const { cancellationToken, onProgress } = options;
+    this.load();
+
+    const uris = this.backgroundIndex.getAllFileUris().sort();
+    const current = new Set(uris);
+    let changed = [...this.files.keys()].some(uri => !current.has(uri));
+
+    // Collect changed files first; nothing is replaced until every summary is in
+    const collected: Map<string, { hash: string; entries: SourceEntry[] }> = new Map();
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Finding complex symbols (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.backgroundIndex.getFileInfo(uri)?.hash ?? '';
+      if (hash !== '' && this.files.get(uri)?.hash === hash) {
+        continue;
+      }
+      const symbols = (await this.backgroundIndex.getFileSymbols(uri)).filter(symbol => this.isSummarized(symbol));
+      let content = '';
+      if (symbols.length > 0) {
+        try {
+          content = await this.readFile(uri);
+        } catch {
+          // Deleted since indexing - dropped below
+        }
+      }
+      collected.set(uri, { hash, entries: content ? this.sourceEntries(uri, content, symbols) : [] });
+    }
+
+    const missing = new Map<string, string>();
+    for (const file of collected.values()) {
+      for (const entry of file.entries) {
+        if (!this.summaries.has(entry.hash)) {
+          missing.set(entry.hash, entry.text);
+        }
+      }
+    }
+
+    const pending = [...missing.entries()];
+    let next = 0;
+    let done = 0;
+    let stopped = false;
+    const worker = async () => {
+      try {
+        while (next < pending.length && !stopped) {
+          throwIfCancelled(cancellationToken);
+          const [hash, text] = pending[next++];
+          this.summaries.set(hash, await this.provider.summarize(text));
+          done++;
+          onProgress?.(done, pending.length, `Summarizing symbols (${done}/${pending.length})`);
+        }
+      } catch (error) {
+        stopped = true;
+        throw error;
+      }
+    };
+    try {
+      const workers = Math.min(Math.max(1, this.options.concurrency ?? DEFAULT_CONCURRENCY), pending.length);
+      await Promise.all(Array.from({ length: workers }, worker));
+    } catch (error) {
+      // Summaries are paid for: keep the ones that came in for the retry
+      if (done > 0) {
+        this.save();
+      }
+      throw error;
+    }
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.files.delete(uri);
+      }
+    }
+    for (const [uri, file] of collected) {
+      this.files.set(uri, { hash: file.hash, entries: file.entries.map(({ text: _text, ...entry }) => entry) });
+      changed = true;
+    }
+
+    if (changed || pending.length > 0) {
+      this.byId = null;
+      this.pruneSummaries();
+      this.save();
+    }
+
+    onProgress?.(uris.length, uris.length, 'Symbol summaries complete');
+    return { ...this.getStats(), summarized: pending.length };
+  }
+
+  /** The summary of a symbol, if it was summarized */
+  summaryOf(symbolId: string): string | undefined {
+    const entry = this.entries().get(symbolId);
+    const summary = entry && this.summaries.get(entry.hash);
+    return summary || undefined;
+  }
+
+  /**
+   * Summarized symbols in path and line order.
+   */
+  list(options: SummaryListOptions = {}): SymbolSummary[] {
+    const scope = options.scopePath ? path.resolve(options.scopePath) : undefined;
+    const results: SymbolSummary[] = [];
+    for (const [uri, file] of [...this.loadedFiles()].sort(([a], [b]) => a.localeCompare(b))) {
+      if (scope && uri !== scope && !uri.startsWith(scope + path.sep)) {
+        continue;
+      }
+      for (const { hash: _hash, ...entry } of file.entries) {
+        const summary = this.summaryOf(entry.id);
+        if (summary) {
+          results.push({ ...entry, uri, summary });
+        }
+      }
+    }
+    return options.limit !== undefined ? results.slice(0, options.limit) : results;
+  }
+
+  getStats(): Omit<SymbolSummaryStats, 'summarized'> {
+    let summaries = 0;
+    for (const [, file] of this.loadedFiles()) {
+      summaries += file.entries.filter(entry => this.summaries.get(entry.hash)).length;
+    }
+    return { files: this.files.size, summaries };
+  }
+
+  private isSummarized(symbol: IndexedSymbol): boolean {
+    if (!SUMMARY_KINDS.has(symbol.kind) || symbol.isDefinition === false || symbol.isExported !== true) {
+      return false;
+    }
+    const lines = symbol.range.endLine - symbol.range.startLine + 1;
+    return lines >= (this.options.minLines ?? DEFAULT_MIN_LINES) ||
+      (symbol.metrics?.complexity ?? 0) >= (this.options.minComplexity ?? DEFAULT_MIN_COMPLEXITY);
+  }
+
+  /**
+   * Text summarized for a symbol: a header naming it, the leading comment
+   * and the source of the declaration.
+   */
+  private sourceEntries(uri: string, content: string, symbols: IndexedSymbol[]): SourceEntry[] {
+    const lines = content.split('\n');
+    const relativePath = path.relative(this.workspaceRoot, uri).split(path.sep).join('/');
+    const maxChars = this.options.maxSourceChars ?? DEFAULT_MAX_SOURCE_CHARS;
+
+    return symbols.map(symbol => {
+      const { startLine, endLine } = symbol.range;
+      let first = startLine;
+      while (first > 0 && LEADING_LINE_RE.test(lines[first - 1])) {
+        first--;
+      }
+      const qualifiedName = symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+      const text = [
+        `${symbol.kind} ${qualifiedName}`,
+        `file: ${relativePath}`,
+        ...lines.slice(first, endLine + 1)
+      ].join('\n').slice(0, maxChars);
+
+      return {
+        id: symbol.id,
+        name: symbol.name,
+        kind: symbol.kind,
+        ...(symbol.containerName && { containerName: symbol.containerName }),
+        line: startLine,
+        hash: crypto.createHash('sha256').update(text).digest('hex').substring(0, 16),
+        text
+      };
+    });
+  }
+
+  private entries(): Map<string, StoredEntry & { uri: string }> {
+    if (!this.byId) {
+      this.byId = new Map();
+      for (const [uri, file] of this.loadedFiles()) {
+        for (const entry of file.entries) {
+          this.byId.set(entry.id, { ...entry, uri });
+        }
+      }
+    }
+    return this.byId;
+  }
+
+  private loadedFiles(): IterableIterator<[string, StoredFile]> {
+    this.load();
+    return this.files.entries();
+  }
+
+  private pruneSummaries(): void {
+    const used = new Set<string>();
+    for (const file of this.files.values()) {
+      for (const entry of file.entries) {
+        used.add(entry.hash);
+      }
+    }
+    for (const hash of [...this.summaries.keys()]) {
+      if (!used.has(hash)) {
+        this.summaries.delete(hash);
+      }
+    }
+  }
+
+  private storeDir(): string | undefined {
+    return this.cacheDir ? path.join(this.cacheDir, 'summaries') : undefined;
+  }
+
+  private load(): void {
+    if (this.loaded) {
+      return;
+    }
+    this.loaded = true;
+    const dir = this.storeDir();
+    if (!dir) {
+      return;
+    }
+
+    try {
+      const stored = JSON.parse(fs.readFileSync(path.join(dir, 'index.json'), 'utf-8')) as StoredSummaries;
+      if (stored.version !== STORE_VERSION || stored.model !== this.provider.model) {
+        return; // Different model: summarize again
+      }
+      this.files = new Map(Object.entries(stored.files));
+      this.summaries = new Map(Object.entries(stored.summaries));
+    } catch {
+      // Missing or corrupt store - rebuilt on update
+    }
+  }
+
+  private save(): void {
+    const dir = this.storeDir();
+    if (!dir) {
+      return;
+    }
+
+    const stored: StoredSummaries = {
+      version: STORE_VERSION,
+      model: this.provider.model,
+      files: Object.fromEntries(this.files),
+      summaries: Object.fromEntries(this.summaries)
+    };
+    fs.mkdirSync(dir, { recursive: true });
+    fs.writeFileSync(path.join(dir, 'index.json'), JSON.stringify(stored));
+  }
+}
@@ -0,0 +1,124 @@
+/**
+ * TagsExporter Tests
+ *
+ * Verifies ctags and etags output produced from the background index.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { TagsExporter } from './tagsExporter.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+
+const serviceTs = `export class UserService {
+  private load<T>(id: string,
+    force?: boolean) {}
+}
+export const url = 'a/b';
+`;
+
+const personGo = `package model
+
+type Person struct {
+	Name string // ĉu
+}
+
+func (p *Person) Greet(greeting string) string {
+	return greeting + " " + p.Name
+}
+`;
+
+describe('TagsExporter', () => {
+  let testDir: string;
+  let index: MockBackgroundIndex;
+  let serviceUri: string;
+  let personUri: string;
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-tags-'));
+    serviceUri = path.join(testDir, 'src', 'service.ts');
+    personUri = path.join(testDir, 'model', 'person.go');
+    fs.mkdirSync(path.dirname(serviceUri));
+    fs.mkdirSync(path.dirname(personUri));
+    fs.writeFileSync(serviceUri, serviceTs);
+    fs.writeFileSync(personUri, personGo);
+
+    index = new MockBackgroundIndex();
+    index.addFile(serviceUri, [
+      createTestSymbol({ id: 'svc', name: 'UserService', kind: 'class', filePath: serviceUri, location: { uri: serviceUri, line: 0, character: 13 } }),
+      createTestSymbol({
+        id: 'load', name: 'load', kind: 'method', containerName: 'UserService', containerKind: 'class',
+        visibility: 'private', filePath: serviceUri, location: { uri: serviceUri, line: 1, character: 10 }
+      }),
+      createTestSymbol({ id: 'url', name: 'url', kind: 'constant', filePath: serviceUri, location: { uri: serviceUri, line: 4, character: 13 } }),
+      createTestSymbol({ id: 'ref', name: 'UserService', kind: 'class', isDefinition: false, filePath: serviceUri, location: { uri: serviceUri, line: 9, character: 0 } })
+    ]);
+    index.addFile(personUri, [
+      createTestSymbol({ id: 'person', name: 'Person', kind: 'struct', filePath: personUri, location: { uri: personUri, line: 2, character: 5 } }),
+      createTestSymbol({
+        id: 'name', name: 'Name', kind: 'field', containerName: 'Person', containerKind: 'struct',
+        filePath: personUri, location: { uri: personUri, line: 3, character: 1 }
+      }),
+      createTestSymbol({
+        id: 'greet', name: 'Greet', kind: 'method', containerName: 'Person', containerKind: 'struct',
+        filePath: personUri, location: { uri: personUri, line: 6, character: 17 }
+      })
+    ]);
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should write a sorted extended-format ctags file', async () => {
+    const outputPath = path.join(testDir, 'tags');
+    const result = await new TagsExporter(index.asBackgroundIndex()).export(outputPath);
+
+    expect(result).toEqual({ outputPath, format: 'ctags', files: 2, tags: 6 });
+
+    const lines = fs.readFileSync(outputPath, 'utf-8').trimEnd().split('\n');
+    expect(lines[0]).toBe('!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;" to lines/');
+    expect(lines[1]).toBe('!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/');
+
+    const tags = lines.filter(line => !line.startsWith('!_'));
+    expect(tags.map(line => line.split('\t')[0])).toEqual(['Greet', 'Name', 'Person', 'UserService', 'load', 'url']);
+    expect(tags[0]).toBe(
+      'Greet\tmodel/person.go\t/^func (p *Person) Greet(greeting string) string {$/;"\tf\tline:7\tlanguage:Go\tstruct:Person\tsignature:(greeting string)'
+    );
+    expect(tags[1]).toBe('Name\tmodel/person.go\t/^\tName string \\/\\/ ĉu$/;"\tm\tline:4\tlanguage:Go\tstruct:Person');
+    expect(tags[4]).toBe(
+      'load\tsrc/service.ts\t/^  private load<T>(id: string,$/;"\tm\tline:2\tlanguage:TypeScript\tclass:UserService\taccess:private\tsignature:(id: string, force?: boolean)'
+    );
+    expect(tags[5]).toBe('url\tsrc/service.ts\t/^export const url = \'a\\/b\';$/;"\tC\tline:5\tlanguage:TypeScript');
+  });
+
+  it('should fall back to line numbers when a source file is unreadable', async () => {
+    const outputPath = path.join(testDir, 'tags');
+    const exporter = new TagsExporter(index.asBackgroundIndex(), async () => {
+      throw new Error('ENOENT');
+    });
+    await exporter.export(outputPath);
+
+    const person = fs.readFileSync(outputPath, 'utf-8').split('\n').find(line => line.startsWith('Person\t'));
+    expect(person).toBe('Person\tmodel/person.go\t3;"\ts\tline:3\tlanguage:Go');
+  });
+
+  it('should write etags sections with line numbers and byte offsets', async () => {
+    const outputPath = path.join(testDir, 'TAGS');
+    const result = await new TagsExporter(index.asBackgroundIndex()).export(outputPath, { format: 'etags' });
+
+    expect(result.tags).toBe(6);
+    const output = fs.readFileSync(outputPath, 'utf-8');
+    const sections = output.split('\x0c\n').slice(1);
+    expect(sections).toHaveLength(2);
+
+    const [header, ...body] = sections[0].split('\n');
+    expect(header).toBe(`model/person.go,${Buffer.byteLength(body.join('\n'), 'utf-8')}`);
+    expect(body[0]).toBe('type Person\x7fPerson\x013,15');
+    expect(body[2]).toBe(`func (p *Person) Greet\x7fGreet\x017,${Buffer.byteLength(personGo.split('\n').slice(0, 6).join('\n'), 'utf-8') + 1}`);
+
+    expect(sections[1].split('\n')[1]).toBe('export class UserService\x7fUserService\x011,0');
+  });
+});
@@ -0,0 +1,369 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol } from '../types.js';
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export type TagsFormat = 'ctags' | 'etags';
+
+export interface TagsExportOptions {
+  /** Output format (default: ctags) */
+  format?: TagsFormat;
+  /** Cancellation token for aborting the export */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting export progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface TagsExportResult {
+  outputPath: string;
+  format: TagsFormat;
+  files: number;
+  tags: number;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type TagsSourceReader = (filePath: string) => Promise<string>;
+
+interface TagEntry {
+  name: string;
+  /** Path relative to the tags file, with forward slashes */
+  file: string;
+  /** 1-based */
+  line: number;
+  /** Source line of the definition, or undefined if the file could not be read */
+  lineText?: string;
+  /** Byte offset of the line start (etags) */
+  byteOffset: number;
+  /** Character offset of the name in the line */
+  character: number;
+  kind: string;
+  language?: string;
+  scope?: { kind: string; name: string };
+  access?: string;
+  signature?: string;
+}
+
+const YIELD_INTERVAL = 50;
+
+/** Universal Ctags' default --pattern-length-limit; longer patterns drop the `$` anchor */
+const PATTERN_LENGTH_LIMIT = 96;
+
+/** Upper bound when looking for the closing paren of a multi-line parameter list */
+const SIGNATURE_SCAN_LIMIT = 2000;
+
+/**
+ * Kind letters, following Universal Ctags' TypeScript/JavaScript parsers.
+ */
+const KIND_LETTERS: Record<string, string> = {
+  class: 'c',
+  interface: 'i',
+  enum: 'g',
+  enumMember: 'e',
+  function: 'f',
+  method: 'm',
+  constructor: 'm',
+  property: 'p',
+  field: 'p',
+  variable: 'v',
+  constant: 'C',
+  type: 'a',
+  namespace: 'n',
+  module: 'n'
+};
+
+/**
+ * Kind letters of Universal Ctags' Go parser.
+ */
+const GO_KIND_LETTERS: Record<string, string> = {
+  package: 'p',
+  function: 'f',
+  method: 'f',
+  constant: 'c',
+  variable: 'v',
+  type: 't',
+  struct: 's',
+  interface: 'i',
+  field: 'm'
+};
+
+const LANGUAGES: Record<string, string> = {
+  '.ts': 'TypeScript',
+  '.tsx': 'TypeScript',
+  '.mts': 'TypeScript',
+  '.cts': 'TypeScript',
+  '.js': 'JavaScript',
+  '.jsx': 'JavaScript',
+  '.mjs': 'JavaScript',
+  '.cjs': 'JavaScript',
+  '.go': 'Go',
+  '.s': 'Asm',
+  '.proto': 'Protobuf'
+};
+
+const SCOPE_KINDS = new Set(['class', 'interface', 'struct', 'enum', 'namespace', 'type']);
+
+const CALLABLE_KINDS = new Set(['function', 'method', 'constructor']);
+
+/**
+ * Exports the background index as a tags file, so editors that read
+ * ctags (Vim, Neovim, Sublime) or etags (Emacs) can jump to definitions
+ * without any plugin.
+ *
+ * ctags output is the extended format (`!_TAG_FILE_FORMAT 2`), sorted by
+ * tag name so editors can binary search it. Each definition is addressed by
+ * a search pattern of its source line and carries the kind letter plus
+ * `line:`, `language:`, scope (`class:Foo`, `struct:Foo`), `access:` and
+ * `signature:` extension fields. etags output lists the tags of each file
+ * in line order with the byte offset of their line.
+ *
+ * Paths are written relative to the tags file, which is how editors
+ * resolve them.
+ */
+export class TagsExporter {
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private readFile: TagsSourceReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Write the tags file to outputPath.
+   */
+  async export(outputPath: string, options: TagsExportOptions = {}): Promise<TagsExportResult> {
+    const { format = 'ctags', cancellationToken, onProgress } = options;
+    const baseDir = path.dirname(outputPath);
+    const files = (await this.backgroundIndex.getAllFiles()).sort();
+    const entriesByFile: TagEntry[][] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting tags (${i}/${files.length})`);
+      }
+
+      const entries = await this.collectFile(files[i], baseDir);
+      if (entries.length > 0) {
+        entriesByFile.push(entries);
+      }
+    }
+
+    throwIfCancelled(cancellationToken);
+    const output = format === 'etags' ? formatEtags(entriesByFile) : formatCtags(entriesByFile.flat());
+
+    await fsPromises.mkdir(baseDir, { recursive: true });
+    await fsPromises.writeFile(outputPath, output, 'utf-8');
+
+    onProgress?.(files.length, files.length, 'Tags export complete');
+    return {
+      outputPath,
+      format,
+      files: entriesByFile.length,
+      tags: entriesByFile.reduce((sum, entries) => sum + entries.length, 0)
+    };
+  }
+
+  private async collectFile(uri: string, baseDir: string): Promise<TagEntry[]> {
+    const fileResult = await this.backgroundIndex.getFileResult(uri);
+    if (!fileResult) {
+      return [];
+    }
+    const definitions = fileResult.symbols
+      .filter(s => s.isDefinition !== false && s.kind !== 'text' && !/\s/.test(s.name))
+      .sort((a, b) => a.location.line - b.location.line || a.location.character - b.location.character);
+    if (definitions.length === 0) {
+      return [];
+    }
+
+    let content: string | undefined;
+    try {
+      content = await this.readFile(uri);
+    } catch {
+      // Fall back to line-number addresses
+    }
+    const lines = content?.split('\n').map(line => line.replace(/\r$/, ''));
+    const { lineStarts, lineByteStarts } = computeLineStarts(content ?? '');
+
+    const file = path.relative(baseDir, uri).split(path.sep).join('/');
+    const language = LANGUAGES[path.extname(uri).toLowerCase()];
+
+    return definitions.map(symbol => {
+      const line = symbol.location.line;
+      return {
+        name: symbol.name,
+        file,
+        line: line + 1,
+        lineText: lines?.[line],
+        byteOffset: lineByteStarts[line] ?? 0,
+        character: symbol.location.character,
+        kind: kindLetter(symbol, language),
+        language,
+        scope: symbol.containerName
+          ? { kind: scopeKind(symbol.containerKind), name: symbol.containerName }
+          : undefined,
+        access: symbol.visibility,
+        signature: CALLABLE_KINDS.has(symbol.kind) && content !== undefined && lineStarts[line] !== undefined
+          ? extractSignature(content!, lineStarts[line], symbol)
+          : undefined
+      };
+    });
+  }
+}
+
+function kindLetter(symbol: IndexedSymbol, language: string | undefined): string {
+  if (language === 'Go') {
+    const go = symbol.metadata?.go as { alias?: boolean; embedded?: boolean } | undefined;
+    if (symbol.kind === 'method' && symbol.containerKind === 'interface') {
+      return 'n';
+    }
+    if (go?.alias) {
+      return 'a';
+    }
+    if (symbol.kind === 'field' && go?.embedded) {
+      return 'M';
+    }
+    return GO_KIND_LETTERS[symbol.kind] ?? 'u';
+  }
+  return KIND_LETTERS[symbol.kind] ?? symbol.kind.charAt(0);
+}
+
+function scopeKind(containerKind: string | undefined): string {
+  if (containerKind === 'module') {
+    return 'namespace';
+  }
+  return containerKind && SCOPE_KINDS.has(containerKind) ? containerKind : 'class';
+}
+
+/**
+ * Parameter list following the symbol name, e.g. `(id: string, force?: boolean)`.
+ * Type parameters between the name and the list are skipped; whitespace
+ * inside multi-line lists is collapsed.
+ */
+function extractSignature(content: string, lineStart: number, symbol: IndexedSymbol): string | undefined {
+  let i = lineStart + symbol.location.character + symbol.name.length;
+  const limit = Math.min(content.length, i + SIGNATURE_SCAN_LIMIT);
+
+  // Skip type parameters: <T> in TS, [T any] in Go
+  const open = content[i];
+  if (open === '<' || open === '[') {
+    const close = open === '<' ? '>' : ']';
+    let depth = 0;
+    for (; i < limit; i++) {
+      if (content[i] === open) {
+        depth++;
+      } else if (content[i] === close && --depth === 0) {
+        i++;
+        break;
+      }
+    }
+  }
+  if (content[i] !== '(') {
+    return undefined;
+  }
+
+  let depth = 0;
+  for (let j = i; j < limit; j++) {
+    if (content[j] === '(') {
+      depth++;
+    } else if (content[j] === ')' && --depth === 0) {
+      return content.slice(i, j + 1).replace(/\s+/g, ' ').replace(/\( /g, '(').replace(/,? \)$/, ')');
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Character and UTF-8 byte offsets of each line start.
+ */
+function computeLineStarts(content: string): { lineStarts: number[]; lineByteStarts: number[] } {
+  const lineStarts: number[] = [];
+  const lineByteStarts: number[] = [];
+  let offset = 0;
+  let bytes = 0;
+  for (const line of content.split('\n')) {
+    lineStarts.push(offset);
+    lineByteStarts.push(bytes);
+    offset += line.length + 1;
+    bytes += Buffer.byteLength(line, 'utf-8') + 1;
+  }
+  return { lineStarts, lineByteStarts };
+}
+
+function formatCtags(entries: TagEntry[]): string {
+  // Byte order (not locale order) so editors can binary search the file
+  const sorted = entries.slice().sort((a, b) =>
+    compareStrings(a.name, b.name) || compareStrings(a.file, b.file) || a.line - b.line
+  );
+
+  const header = [
+    '!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;" to lines/',
+    '!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/',
+    '!_TAG_FILE_ENCODING\tutf-8\t//',
+    '!_TAG_PROGRAM_NAME\tsmart-indexer\t//',
+    '!_TAG_PROGRAM_URL\thttps://github.com/p-sternik/smart-indexer\t//'
+  ];
+
+  const lines = sorted.map(entry => {
+    const fields = [entry.kind, `line:${entry.line}`];
+    if (entry.language) {
+      fields.push(`language:${entry.language}`);
+    }
+    if (entry.scope) {
+      fields.push(`${entry.scope.kind}:${escapeField(entry.scope.name)}`);
+    }
+    if (entry.access) {
+      fields.push(`access:${entry.access}`);
+    }
+    if (entry.signature) {
+      fields.push(`signature:${escapeField(entry.signature)}`);
+    }
+    return `${entry.name}\t${entry.file}\t${searchPattern(entry)};"\t${fields.join('\t')}`;
+  });
+
+  return [...header, ...lines].join('\n') + '\n';
+}
+
+/**
+ * `/^line$/` with `\` and `/` escaped; line number if the source was unavailable.
+ */
+function searchPattern(entry: TagEntry): string {
+  if (entry.lineText === undefined) {
+    return String(entry.line);
+  }
+  const truncated = entry.lineText.length > PATTERN_LENGTH_LIMIT;
+  const text = truncated ? entry.lineText.slice(0, PATTERN_LENGTH_LIMIT) : entry.lineText;
+  return `/^${text.replace(/[\\/]/g, '\\$&')}${truncated ? '' : '$'}/`;
+}
+
+function escapeField(value: string): string {
+  return value.replace(/\\/g, '\\\\').replace(/\t/g, '\\t');
+}
+
+/**
+ * etags: per file, a form feed line, `file,size`, then one
+ * `text\x7fname\x01line,offset` line per tag where text is the source line
+ * up to the end of the name.
+ */
+function formatEtags(entriesByFile: TagEntry[][]): string {
+  let output = '';
+  for (const entries of entriesByFile) {
+    const body = entries.map(entry => {
+      const text = entry.lineText !== undefined
+        ? entry.lineText.slice(0, entry.character + entry.name.length)
+        : entry.name;
+      return `${text}\x7f${entry.name}\x01${entry.line},${entry.byteOffset}\n`;
+    }).join('');
+    output += `\x0c\n${entries[0].file},${Buffer.byteLength(body, 'utf-8')}\n${body}`;
+  }
+  return output;
+}
+
+function compareStrings(a: string, b: string): number {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
@@ -0,0 +1,99 @@
+/**
+ * TrigramIndex Tests
+ *
+ * Verifies that grep reads only the candidate files, reports positions,
+ * falls back to a scan for unindexable patterns and follows file changes.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { TrigramIndex } from './trigramIndex.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+
+const personGo = `package people
+
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+
+func NewAdminPerson() *Person { return NewPerson("admin") }
+`;
+
+const orderGo = `package orders
+
+func NewOrder() *Order {
+	return &Order{}
+}
+`;
+
+const readmeMd = `# People
+
+Call newperson to make one.
+`;
+
+describe('TrigramIndex', () => {
+  let background: MockBackgroundIndex;
+  let files: Map<string, string>;
+  let reads: string[];
+
+  async function build(): Promise<TrigramIndex> {
+    const index = new TrigramIndex(background.asBackgroundIndex(), async uri => {
+      reads.push(uri);
+      return files.get(uri)!;
+    });
+    await index.build();
+    reads = [];
+    return index;
+  }
+
+  beforeEach(() => {
+    background = new MockBackgroundIndex();
+    files = new Map([['/ws/people.go', personGo], ['/ws/orders.go', orderGo], ['/ws/README.md', readmeMd]]);
+    reads = [];
+    for (const uri of files.keys()) {
+      background.addFile(uri, []);
+    }
+  });
+
+  it('should read only the files that can match a regex', async () => {
+    const index = await build();
+    const result = await index.grep('New\\w+Person', { regex: true });
+
+    // README.md has the trigrams, lowercased, but not the match
+    expect(reads).toEqual(['/ws/README.md', '/ws/people.go']);
+    expect(result).toMatchObject({ candidates: 2, indexed: true, truncated: false });
+    expect(result.matches.map(m => [m.line, m.character, m.endCharacter])).toEqual([[6, 5, 19]]);
+    expect(result.matches[0].preview).toBe('func NewAdminPerson() *Person { return NewPerson("admin") }');
+  });
+
+  it('should search literal text and honour case and limit', async () => {
+    const index = await build();
+
+    expect((await index.grep('NewPerson(')).matches.map(m => m.line)).toEqual([2, 6]);
+    expect(index.candidates('NewPerson')).toEqual(['/ws/README.md', '/ws/people.go']);
+    expect((await index.grep('NewPerson')).matches.every(m => m.uri === '/ws/people.go')).toBe(true);
+    expect((await index.grep('NewPerson', { caseSensitive: false })).matches.map(m => m.uri)).toContain('/ws/README.md');
+    expect(await index.grep('Person', { limit: 2 })).toMatchObject({ truncated: true, matches: [{ line: 2 }, { line: 2 }] });
+  });
+
+  it('should scan every file when the pattern has no trigrams', async () => {
+    const index = await build();
+    const result = await index.grep('Ne\\w', { regex: true });
+
+    expect(result.indexed).toBe(false);
+    expect(reads.sort()).toEqual(['/ws/README.md', '/ws/orders.go', '/ws/people.go']);
+    expect(result.matches).toHaveLength(4);
+    await expect(index.grep('(', { regex: true })).rejects.toThrow(SyntaxError);
+  });
+
+  it('should follow changed and removed files', async () => {
+    const index = await build();
+
+    files.set('/ws/orders.go', 'package orders\n\nvar p = NewPerson("order")\n');
+    background.addFile('/ws/orders.go', [], [], { hash: 'changed' });
+    background.removeFile('/ws/README.md');
+    expect(await index.build()).toMatchObject({ files: 2, updated: 1 });
+
+    expect(index.candidates('NewPerson')).toEqual(['/ws/orders.go', '/ws/people.go']);
+    expect(index.candidates('NewOrder')).toEqual([]);
+  });
+});
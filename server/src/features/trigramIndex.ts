@@ -0,0 +1,369 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { TrigramQuery, lowerCodeUnit, regexToTrigramQuery } from '../utils/regexTrigrams.js';
+import * as fs from 'fs';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+export interface TrigramIndexOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface GrepOptions {
+  /** Treat the pattern as a regular expression (default: literal text) */
+  regex?: boolean;
+  /** Match case exactly (default: true) */
+  caseSensitive?: boolean;
+  /** Maximum number of matches (default: 500) */
+  limit?: number;
+  /** Cancellation token for aborting the scan of candidate files */
+  cancellationToken?: CancellationToken;
+}
+
+export interface GrepMatch {
+  uri: string;
+  line: number;
+  character: number;
+  endLine: number;
+  endCharacter: number;
+  /** Source line containing the start of the match, trimmed */
+  preview: string;
+}
+
+export interface GrepResult {
+  matches: GrepMatch[];
+  /** Files read to verify matches */
+  candidates: number;
+  /** False when the pattern had no trigrams to look up and every file was scanned */
+  indexed: boolean;
+  /** Stopped at the limit */
+  truncated: boolean;
+}
+
+export interface TrigramIndexStats {
+  files: number;
+  trigrams: number;
+  /** Files (re)read by the last build */
+  updated: number;
+}
+
+/** Reads a workspace file; injectable for tests */
+export type TrigramReader = (filePath: string) => Promise<string>;
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_LIMIT = 500;
+
+/**
+ * Trigram Index - posting lists of files per trigram, for regex search.
+ *
+ * Every three consecutive characters (UTF-16 code units, lowercased) of a
+ * file are a trigram; each trigram maps to the sorted ids of the files
+ * containing it. A pattern is turned into a boolean trigram query (see
+ * regexTrigrams.ts) - `New\w+Person` needs "new" and "person"'s trigrams -
+ * which is answered by intersecting and merging posting lists. Only the
+ * resulting candidates are read and matched with the actual RegExp, so
+ * the index decides what to read, never what matches.
+ *
+ * Patterns with no trigrams to require (`\w+`, `a.b`) fall back to
+ * scanning every file.
+ *
+ * Files are reread only when their hash in the background index changed
+ * since the last build. A changed file gets a new id, so posting lists
+ * stay sorted as they are appended to; ids of removed files are dropped
+ * from them once they make up half the ids.
+ */
+export class TrigramIndex {
+  private files: Map<string, { hash: string; id: number }> = new Map();
+  private uris: Array<string | undefined> = [];
+  private postings: Map<number, number[]> = new Map();
+
+  constructor(
+    private backgroundIndex: BackgroundIndex,
+    private readFile: TrigramReader = filePath => fs.promises.readFile(filePath, 'utf-8')
+  ) {}
+
+  /**
+   * Bring the index in line with the background index.
+   */
+  async build(options: TrigramIndexOptions = {}): Promise<TrigramIndexStats> {
+    const { cancellationToken, onProgress } = options;
+    const uris = this.backgroundIndex.getAllFileUris().sort();
+    const current = new Set(uris);
+    let updated = 0;
+
+    for (const uri of [...this.files.keys()]) {
+      if (!current.has(uri)) {
+        this.removeFile(uri);
+      }
+    }
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, uris.length, `Indexing trigrams (${i}/${uris.length})`);
+      }
+
+      const uri = uris[i];
+      const hash = this.backgroundIndex.getFileInfo(uri)?.hash ?? '';
+      const existing = this.files.get(uri);
+      if (existing && existing.hash === hash && hash !== '') {
+        continue;
+      }
+
+      try {
+        this.updateFile(uri, await this.readFile(uri), hash);
+      } catch {
+        this.removeFile(uri);
+      }
+      updated++;
+    }
+
+    if (this.uris.length > 1024 && this.files.size < this.uris.length / 2) {
+      this.compact();
+    }
+
+    onProgress?.(uris.length, uris.length, 'Trigram index complete');
+    return { files: this.files.size, trigrams: this.postings.size, updated };
+  }
+
+  /**
+   * (Re)index one file from its content.
+   */
+  updateFile(uri: string, content: string, hash: string = ''): void {
+    this.removeFile(uri);
+
+    const id = this.uris.length;
+    this.uris.push(uri);
+    this.files.set(uri, { hash, id });
+    for (const trigram of trigramsOf(content)) {
+      let posting = this.postings.get(trigram);
+      if (!posting) {
+        posting = [];
+        this.postings.set(trigram, posting);
+      }
+      posting.push(id);
+    }
+  }
+
+  removeFile(uri: string): void {
+    const entry = this.files.get(uri);
+    if (!entry) {
+      return;
+    }
+    this.uris[entry.id] = undefined;
+    this.files.delete(uri);
+  }
+
+  /**
+   * Files that may match the pattern; null when the pattern has no
+   * trigrams to look up and any file may match.
+   */
+  candidates(pattern: string, options: Pick<GrepOptions, 'regex'> = {}): string[] | null {
+    let query: TrigramQuery;
+    try {
+      query = regexToTrigramQuery(options.regex ? pattern : escapeRegex(pattern));
+    } catch {
+      return null;
+    }
+    const ids = this.evaluate(query);
+    if (!ids) {
+      return null;
+    }
+    return ids.map(id => this.uris[id]).filter((uri): uri is string => uri !== undefined).sort();
+  }
+
+  /**
+   * Find a pattern in the indexed files. Throws on invalid regular expressions.
+   */
+  async grep(pattern: string, options: GrepOptions = {}): Promise<GrepResult> {
+    const source = options.regex ? pattern : escapeRegex(pattern);
+    const regex = new RegExp(source, options.caseSensitive === false ? 'gim' : 'gm');
+    const limit = options.limit ?? DEFAULT_LIMIT;
+
+    const candidates = this.candidates(pattern, options);
+    const uris = candidates ?? this.backgroundIndex.getAllFileUris().sort();
+    const matches: GrepMatch[] = [];
+
+    for (let i = 0; i < uris.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(options.cancellationToken);
+        await yieldToEventLoop();
+      }
+      let content: string;
+      try {
+        content = await this.readFile(uris[i]);
+      } catch {
+        continue;
+      }
+      for (const match of matchesIn(uris[i], content, regex)) {
+        if (matches.length >= limit) {
+          return { matches, candidates: i + 1, indexed: candidates !== null, truncated: true };
+        }
+        matches.push(match);
+      }
+    }
+    return { matches, candidates: uris.length, indexed: candidates !== null, truncated: false };
+  }
+
+  getStats(): Omit<TrigramIndexStats, 'updated'> {
+    return { files: this.files.size, trigrams: this.postings.size };
+  }
+
+  private evaluate(query: TrigramQuery): number[] | null {
+    switch (query.op) {
+      case 'all':
+        return null;
+      case 'trigram':
+        return this.postings.get(trigramKey(query.trigram)) ?? [];
+      case 'and': {
+        const lists = query.parts.map(part => this.evaluate(part)).filter((ids): ids is number[] => ids !== null);
+        if (lists.length === 0) {
+          return null;
+        }
+        // Start from the rarest
+        lists.sort((a, b) => a.length - b.length);
+        return lists.slice(1).reduce(intersect, lists[0]);
+      }
+      case 'or': {
+        const lists: number[][] = [];
+        for (const part of query.parts) {
+          const ids = this.evaluate(part);
+          if (!ids) {
+            return null;
+          }
+          lists.push(ids);
+        }
+        return lists.reduce(merge, []);
+      }
+    }
+  }
+
+  /**
+   * Drop the ids of removed files: renumber live files in id order, which
+   * keeps every posting list sorted.
+   */
+  private compact(): void {
+    const renumbered = new Array<number>(this.uris.length).fill(-1);
+    const uris: string[] = [];
+    for (let id = 0; id < this.uris.length; id++) {
+      const uri = this.uris[id];
+      if (uri !== undefined) {
+        renumbered[id] = uris.length;
+        this.files.get(uri)!.id = uris.length;
+        uris.push(uri);
+      }
+    }
+    for (const [trigram, posting] of this.postings) {
+      const live = posting.map(id => renumbered[id]).filter(id => id !== -1);
+      if (live.length === 0) {
+        this.postings.delete(trigram);
+      } else {
+        this.postings.set(trigram, live);
+      }
+    }
+    this.uris = uris;
+  }
+}
+
+/**
+ * Distinct trigram keys of a text, lowercased as regexTrigrams.ts does.
+ */
+function trigramsOf(content: string): Set<number> {
+  const trigrams = new Set<number>();
+  let a = 0;
+  let b = 0;
+  for (let i = 0; i < content.length; i++) {
+    const c = lowerCodeUnit(content.charCodeAt(i));
+    if (i >= 2) {
+      trigrams.add((a * 65536 + b) * 65536 + c);
+    }
+    a = b;
+    b = c;
+  }
+  return trigrams;
+}
+
+function trigramKey(trigram: string): number {
+  return (trigram.charCodeAt(0) * 65536 + trigram.charCodeAt(1)) * 65536 + trigram.charCodeAt(2);
+}
+
+function intersect(a: number[], b: number[]): number[] {
+  const result: number[] = [];
+  for (let i = 0, j = 0; i < a.length && j < b.length;) {
+    if (a[i] === b[j]) {
+      result.push(a[i]);
+      i++;
+      j++;
+    } else if (a[i] < b[j]) {
+      i++;
+    } else {
+      j++;
+    }
+  }
+  return result;
+}
+
+function merge(a: number[], b: number[]): number[] {
+  const result: number[] = [];
+  let i = 0;
+  let j = 0;
+  while (i < a.length || j < b.length) {
+    if (j >= b.length || (i < a.length && a[i] < b[j])) {
+      result.push(a[i++]);
+    } else if (i >= a.length || b[j] < a[i]) {
+      result.push(b[j++]);
+    } else {
+      result.push(a[i]);
+      i++;
+      j++;
+    }
+  }
+  return result;
+}
+
+function escapeRegex(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+function* matchesIn(uri: string, content: string, regex: RegExp): Generator<GrepMatch> {
+  regex.lastIndex = 0;
+  let line = 0;
+  let lineStart = 0;
+  let scanned = 0;
+  for (let match = regex.exec(content); match; match = regex.exec(content)) {
+    if (match[0].length === 0) {
+      // Zero-width matches (`^`, `\b`) find nothing to show
+      regex.lastIndex++;
+      continue;
+    }
+    for (let i = content.indexOf('\n', scanned); i !== -1 && i < match.index; i = content.indexOf('\n', i + 1)) {
+      line++;
+      lineStart = i + 1;
+    }
+    scanned = match.index;
+
+    const end = match.index + match[0].length;
+    let endLine = line;
+    let endLineStart = lineStart;
+    for (let i = content.indexOf('\n', match.index); i !== -1 && i < end; i = content.indexOf('\n', i + 1)) {
+      endLine++;
+      endLineStart = i + 1;
+    }
+    const lineEnd = content.indexOf('\n', match.index);
+    yield {
+      uri,
+      line,
+      character: match.index - lineStart,
+      endLine,
+      endCharacter: end - endLineStart,
+      preview: content.slice(lineStart, lineEnd === -1 ? undefined : lineEnd).trim()
+    };
+  }
+}
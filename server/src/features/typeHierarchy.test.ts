@@ -0,0 +1,135 @@
+/**
+ * TypeHierarchy Tests
+ *
+ * Verifies embedding trees in both directions: Go struct and interface
+ * embedding across packages, types outside the index, cycles through
+ * pointers, depth limits and TS base classes.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { TypeHierarchy, TypeHierarchyNode } from './typeHierarchy.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const peopleGo = `package people
+
+type Person struct {
+	Name string
+}
+
+type Employee struct {
+	Person
+	Team string
+}
+
+type Namer interface {
+	Name() string
+}
+
+type Node struct {
+	*Tree
+}
+
+type Tree struct {
+	*Node
+}
+`;
+
+const adminGo = `package admin
+
+import (
+	"sync"
+
+	"example.com/app/people"
+)
+
+type AdminUser struct {
+	*people.Employee
+	sync.Mutex
+}
+
+type Guest struct {
+	people.Person
+}
+
+type NamedReader interface {
+	people.Namer
+	io.Reader
+}
+`;
+
+/** Name, embedding and flags of every node, children nested */
+function shape(node: TypeHierarchyNode): unknown {
+  const flags = [node.pointer && 'pointer', !node.location && 'external', node.cycle && 'cycle', node.truncated && 'truncated'];
+  return [node.qualifiedName, ...flags.filter(Boolean), ...node.children.map(shape)];
+}
+
+describe('TypeHierarchy', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  async function build(): Promise<TypeHierarchy> {
+    const hierarchy = new TypeHierarchy(index.asBackgroundIndex());
+    await hierarchy.build();
+    return hierarchy;
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+    addGoFile('/ws/people/people.go', peopleGo);
+    addGoFile('/ws/admin/admin.go', adminGo);
+  });
+
+  it('should list what a struct embeds, transitively', async () => {
+    const hierarchy = await build();
+
+    const [tree] = hierarchy.supertypes('AdminUser');
+    expect(shape(tree)).toEqual([
+      'admin.AdminUser',
+      ['people.Employee', 'pointer', ['people.Person']],
+      ['sync.Mutex', 'external']
+    ]);
+    expect(tree.children[0]).toMatchObject({ type: '*people.Employee', location: { uri: '/ws/people/people.go', line: 6 } });
+  });
+
+  it('should list what embeds a type, across packages', async () => {
+    const hierarchy = await build();
+
+    expect(hierarchy.subtypes('people.Person').map(shape)).toEqual([
+      ['people.Person', ['admin.Guest'], ['people.Employee', ['admin.AdminUser', 'pointer']]]
+    ]);
+    expect(hierarchy.subtypes('Namer').map(shape)).toEqual([['people.Namer', ['admin.NamedReader']]]);
+    expect(hierarchy.supertypes('NamedReader').map(shape)).toEqual([['admin.NamedReader', ['people.Namer'], ['Reader', 'external']]]);
+  });
+
+  it('should stop at cycles and at the depth limit', async () => {
+    const hierarchy = await build();
+
+    expect(hierarchy.supertypes('Node').map(shape)).toEqual([
+      ['people.Node', ['people.Tree', 'pointer', ['people.Node', 'pointer', 'cycle']]]
+    ]);
+    expect(hierarchy.supertypes('AdminUser', { maxDepth: 1 }).map(shape)).toEqual([
+      ['admin.AdminUser', ['people.Employee', 'pointer', 'truncated'], ['sync.Mutex', 'external']]
+    ]);
+    expect(hierarchy.supertypes('Missing')).toEqual([]);
+  });
+
+  it('should follow TS base classes', async () => {
+    const uri = '/ws/web/models.ts';
+    index.addFile(uri, [
+      createTestSymbol({ id: 'entity', name: 'Entity', kind: 'class', filePath: uri, location: { uri, line: 0, character: 13 } }),
+      createTestSymbol({ id: 'user', name: 'User', kind: 'class', extends: 'Entity', filePath: uri, location: { uri, line: 2, character: 13 } }),
+      createTestSymbol({ id: 'admin', name: 'Admin', kind: 'class', extends: 'User', filePath: uri, location: { uri, line: 4, character: 13 } })
+    ]);
+    const hierarchy = await build();
+
+    expect(hierarchy.supertypes('Admin').map(shape)).toEqual([['Admin', ['User', ['Entity']]]]);
+    expect(hierarchy.subtypes('Entity').map(shape)).toEqual([['Entity', ['User', ['Admin']]]]);
+  });
+});
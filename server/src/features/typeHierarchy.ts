@@ -0,0 +1,279 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import * as path from 'path';
+import {
+  CancellationToken,
+  ProgressCallback,
+  throwIfCancelled,
+  yieldToEventLoop
+} from '../utils/asyncUtils.js';
+
+/** The part of an index the hierarchy reads: the background index or the library Indexer */
+export type TypeHierarchyIndex = Pick<BackgroundIndex, 'getAllFiles' | 'getFileSymbols'>;
+
+/** supertypes: what a type embeds (or extends); subtypes: what embeds (or extends) it */
+export type TypeHierarchyDirection = 'supertypes' | 'subtypes';
+
+export interface TypeHierarchyBuildOptions {
+  /** Cancellation token for aborting the build */
+  cancellationToken?: CancellationToken;
+  /** Progress callback for reporting build progress */
+  onProgress?: ProgressCallback;
+}
+
+export interface TypeHierarchyQueryOptions {
+  /** File the query originates from; types in the same file/package are preferred */
+  uri?: string;
+  /** Levels below the root to expand (default: 16) */
+  maxDepth?: number;
+}
+
+export interface TypeHierarchyNode {
+  name: string;
+  qualifiedName: string;
+  kind: string;
+  /** Absent for types outside the index (`sync.Mutex`, `io.Reader` without the stdlib indexed) */
+  location?: SymbolLocation;
+  /** The embedding as written, e.g. `*Base` or `sync.Mutex`; absent on roots */
+  type?: string;
+  /** Embedded through a pointer (`*Base`) */
+  pointer?: boolean;
+  /** The type already appears above in this branch; not expanded again */
+  cycle?: boolean;
+  /** maxDepth was reached; the type has children that were not expanded */
+  truncated?: boolean;
+  children: TypeHierarchyNode[];
+}
+
+interface EmbedInfo {
+  name: string;
+  /** Type expression as written */
+  type: string;
+  qualifier?: string;
+  pointer: boolean;
+}
+
+interface TypeEntry {
+  key: string;
+  symbol: IndexedSymbol;
+  isGo: boolean;
+  embeds: EmbedInfo[];
+}
+
+/** An embedding, seen from either end */
+interface Edge {
+  entry?: TypeEntry;
+  embed: EmbedInfo;
+}
+
+const YIELD_INTERVAL = 50;
+const DEFAULT_MAX_DEPTH = 16;
+
+const GO_TYPE_KINDS = new Set(['struct', 'interface', 'type']);
+
+/**
+ * Type Hierarchy - which types a type embeds, and which embed it, as trees.
+ *
+ * Go has no inheritance; embedding plays its role. A struct's embedded
+ * fields (`Base`, `*Base`, `sync.Mutex`) and an interface's embedded
+ * interfaces are its supertypes, followed transitively. TS/JS classes
+ * contribute their `extends` base. Interface satisfaction, which needs no
+ * declaration, is answered by features/interfaceImplementations.ts.
+ *
+ * Embedded names resolve like promoted members do: a qualified name to the
+ * type of that package, an unqualified one to the same package first, then
+ * to a workspace-unique type. Types that do not resolve stay in the tree as
+ * leaves without a location.
+ */
+export class TypeHierarchy {
+  private types: Map<string, TypeEntry> = new Map();
+  private byName: Map<string, TypeEntry[]> = new Map();
+  private supertypeEdges: Map<string, Edge[]> = new Map();
+  private subtypeEdges: Map<string, Edge[]> = new Map();
+
+  constructor(private index: TypeHierarchyIndex) {}
+
+  /**
+   * Collect types and their embeddings from the index.
+   */
+  async build(options: TypeHierarchyBuildOptions = {}): Promise<{ types: number; embeddings: number }> {
+    const { cancellationToken, onProgress } = options;
+    this.types.clear();
+    this.byName.clear();
+    this.supertypeEdges.clear();
+    this.subtypeEdges.clear();
+
+    const files = (await this.index.getAllFiles()).sort();
+    const embeddedFields: IndexedSymbol[] = [];
+
+    for (let i = 0; i < files.length; i++) {
+      if (i % YIELD_INTERVAL === 0) {
+        throwIfCancelled(cancellationToken);
+        await yieldToEventLoop();
+        onProgress?.(i, files.length, `Collecting types (${i}/${files.length})`);
+      }
+
+      const uri = files[i];
+      const isGo = path.extname(uri) === '.go';
+      for (const symbol of await this.index.getFileSymbols(uri)) {
+        if (symbol.isDefinition === false) {
+          continue;
+        }
+        if (isGo ? GO_TYPE_KINDS.has(symbol.kind) : symbol.kind === 'class' || symbol.kind === 'interface') {
+          this.addType(symbol, isGo);
+        } else if (isGo && symbol.kind === 'field' && goMetadata(symbol).embedded) {
+          embeddedFields.push(symbol);
+        }
+      }
+    }
+
+    // Embedded fields name their struct; attach them once every type is collected
+    for (const field of embeddedFields) {
+      const owner = this.types.get(typeKey(field.location.uri, field.containerName || ''));
+      owner?.embeds.push(parseEmbed(field.name, goMetadata(field).type || field.name));
+    }
+
+    let embeddings = 0;
+    for (const entry of this.types.values()) {
+      const edges = entry.embeds.map(embed => ({ entry: this.resolveEmbed(embed, entry), embed }));
+      this.supertypeEdges.set(entry.key, edges);
+      for (const edge of edges) {
+        if (edge.entry) {
+          const list = this.subtypeEdges.get(edge.entry.key) || [];
+          list.push({ entry, embed: edge.embed });
+          this.subtypeEdges.set(edge.entry.key, list);
+        }
+      }
+      embeddings += edges.length;
+    }
+
+    onProgress?.(files.length, files.length, 'Type hierarchy complete');
+    return { types: this.types.size, embeddings };
+  }
+
+  /**
+   * What each type matching name (`Person` or `pkg.Person`) embeds or
+   * extends, transitively: one tree per type.
+   */
+  supertypes(name: string, options: TypeHierarchyQueryOptions = {}): TypeHierarchyNode[] {
+    return this.trees('supertypes', name, options);
+  }
+
+  /**
+   * What embeds or extends each type matching name, transitively.
+   */
+  subtypes(name: string, options: TypeHierarchyQueryOptions = {}): TypeHierarchyNode[] {
+    return this.trees('subtypes', name, options);
+  }
+
+  private trees(direction: TypeHierarchyDirection, name: string, options: TypeHierarchyQueryOptions = {}): TypeHierarchyNode[] {
+    const edges = direction === 'supertypes' ? this.supertypeEdges : this.subtypeEdges;
+    const maxDepth = options.maxDepth ?? DEFAULT_MAX_DEPTH;
+
+    const expand = (entry: TypeEntry, node: TypeHierarchyNode, depth: number, branch: Set<string>): TypeHierarchyNode => {
+      const children = edges.get(entry.key) || [];
+      if (children.length > 0 && depth >= maxDepth) {
+        node.truncated = true;
+        return node;
+      }
+      branch.add(entry.key);
+      for (const { entry: child, embed } of children) {
+        const link = { type: embed.type, ...(embed.pointer && { pointer: true }) };
+        if (!child) {
+          const qualifiedName = embed.qualifier ? `${embed.qualifier}.${embed.name}` : embed.name;
+          node.children.push({ name: embed.name, qualifiedName, kind: 'type', ...link, children: [] });
+        } else if (branch.has(child.key)) {
+          node.children.push({ ...toNode(child), ...link, cycle: true });
+        } else {
+          node.children.push(expand(child, { ...toNode(child), ...link }, depth + 1, branch));
+        }
+      }
+      branch.delete(entry.key);
+      return node;
+    };
+
+    return this.lookup(name, options.uri).map(entry => expand(entry, toNode(entry), 0, new Set()));
+  }
+
+  private resolveEmbed(embed: EmbedInfo, owner: TypeEntry): TypeEntry | undefined {
+    const candidates = (this.byName.get(embed.name) || []).filter(c => c.isGo === owner.isGo && c.key !== owner.key);
+    if (!owner.isGo) {
+      const sameFile = candidates.find(c => c.symbol.location.uri === owner.symbol.location.uri);
+      return sameFile ?? (candidates.length === 1 ? candidates[0] : undefined);
+    }
+    if (embed.qualifier) {
+      const qualified = candidates.filter(c => goMetadata(c.symbol).package === embed.qualifier);
+      return qualified.length === 1 ? qualified[0] : undefined;
+    }
+    const samePackage = candidates.find(c => packageKey(c.symbol.location.uri) === packageKey(owner.symbol.location.uri));
+    return samePackage ?? (candidates.length === 1 ? candidates[0] : undefined);
+  }
+
+  private lookup(name: string, fromUri?: string): TypeEntry[] {
+    const dot = name.lastIndexOf('.');
+    const qualifier = dot > 0 ? name.slice(0, dot) : undefined;
+    const simpleName = dot > 0 ? name.slice(dot + 1) : name;
+
+    let entries = this.byName.get(simpleName) || [];
+    if (qualifier) {
+      entries = entries.filter(e => goMetadata(e.symbol).package === qualifier || e.symbol.fullContainerPath === qualifier);
+    }
+    if (fromUri && entries.length > 1) {
+      const local = entries.filter(e => packageKey(e.symbol.location.uri) === packageKey(fromUri));
+      if (local.length > 0) {
+        return local;
+      }
+    }
+    return entries;
+  }
+
+  private addType(symbol: IndexedSymbol, isGo: boolean): void {
+    const entry: TypeEntry = { key: typeKey(symbol.location.uri, symbol.name), symbol, isGo, embeds: [] };
+    if (isGo && symbol.kind === 'interface') {
+      // Embedded interfaces carry no field symbol; names are recorded on the interface
+      for (const embed of goMetadata(symbol).embeds || []) {
+        entry.embeds.push({ name: embed, type: embed, pointer: false });
+      }
+    } else if (!isGo && symbol.extends) {
+      entry.embeds.push({ name: symbol.extends, type: symbol.extends, pointer: false });
+    }
+    this.types.set(entry.key, entry);
+    const list = this.byName.get(symbol.name) || [];
+    list.push(entry);
+    this.byName.set(symbol.name, list);
+  }
+}
+
+/**
+ * Go types are keyed by package directory, TS/JS types by file.
+ */
+function typeKey(uri: string, name: string): string {
+  return `${packageKey(uri)}#${name}`;
+}
+
+function packageKey(uri: string): string {
+  return path.extname(uri) === '.go' ? path.dirname(uri) : uri;
+}
+
+function parseEmbed(name: string, typeText: string): EmbedInfo {
+  const pointer = typeText.startsWith('*');
+  const bare = typeText.replace(/^\*/, '').replace(/\[.*$/, '');
+  const dot = bare.lastIndexOf('.');
+  return { name, type: typeText, pointer, ...(dot > 0 && { qualifier: bare.slice(0, dot) }) };
+}
+
+function goMetadata(symbol: IndexedSymbol): GoSymbolMetadata {
+  return (symbol.metadata?.go ?? {}) as GoSymbolMetadata;
+}
+
+function toNode(entry: TypeEntry): TypeHierarchyNode {
+  const symbol = entry.symbol;
+  return {
+    name: symbol.name,
+    qualifiedName: symbol.fullContainerPath ? `${symbol.fullContainerPath}.${symbol.name}` : symbol.name,
+    kind: symbol.kind,
+    location: symbol.location,
+    children: []
+  };
+}
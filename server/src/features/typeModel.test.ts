@@ -0,0 +1,115 @@
+/**
+ * TypeModel Tests
+ *
+ * Verifies member listing, receiver kinds and promotion through embedded types.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { TypeModel } from './typeModel.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { MockBackgroundIndex } from '../test/mocks/MockBackgroundIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const personGo = `package people
+
+type Named struct {
+	Name string
+}
+
+func (n Named) Display() string { return n.Name }
+
+type Audited struct {
+	CreatedBy string
+}
+
+func (a *Audited) Touch() {}
+
+type Person struct {
+	Named
+	*Audited
+	Age int \`json:"age"\`
+}
+
+func (p Person) Greet() string { return "hi" }
+`;
+
+const methodsGo = `package people
+
+func (p *Person) Birthday() { p.Age++ }
+`;
+
+describe('TypeModel', () => {
+  let index: MockBackgroundIndex;
+  const goIndexer = new GoIndexer();
+
+  function addGoFile(uri: string, content: string): void {
+    const result = goIndexer.indexFile(uri, content);
+    index.addFile(uri, result.symbols, result.references);
+  }
+
+  beforeEach(() => {
+    index = new MockBackgroundIndex();
+  });
+
+  it('should list Go fields, methods across files and embedded types', async () => {
+    addGoFile('/ws/people/person.go', personGo);
+    addGoFile('/ws/people/methods.go', methodsGo);
+
+    const [person] = await new TypeModel(index.asBackgroundIndex()).describe('Person');
+
+    expect(person.qualifiedName).toBe('people.Person');
+    expect(person.kind).toBe('struct');
+    expect(person.fields.map(f => [f.name, f.type])).toEqual([['Age', 'int']]);
+    expect(person.methods.map(m => [m.name, m.receiver]).sort()).toEqual([
+      ['Birthday', 'pointer'],
+      ['Greet', 'value']
+    ]);
+    expect(person.embedded.map(e => [e.name, e.pointer])).toEqual([['Named', false], ['Audited', true]]);
+    expect(person.embedded.every(e => e.resolved)).toBe(true);
+  });
+
+  it('should promote members of embedded types', async () => {
+    addGoFile('/ws/people/person.go', personGo);
+
+    const [person] = await new TypeModel(index.asBackgroundIndex()).describe('people.Person');
+    const promoted = Object.fromEntries(person.promoted.map(m => [m.name, m.promotedVia]));
+
+    expect(promoted).toEqual({
+      Name: ['Named'],
+      Display: ['Named'],
+      CreatedBy: ['Audited'],
+      Touch: ['Audited']
+    });
+    expect(person.promoted.find(m => m.name === 'Touch')?.receiver).toBe('pointer');
+  });
+
+  it('should not promote names that are ambiguous at the same depth', async () => {
+    addGoFile('/ws/shapes/shapes.go', `package shapes
+
+type A struct { ID int }
+type B struct { ID int }
+type C struct {
+	A
+	B
+}
+`);
+
+    const [c] = await new TypeModel(index.asBackgroundIndex()).describe('C');
+    expect(c.promoted.map(m => m.name)).not.toContain('ID');
+  });
+
+  it('should treat TS base classes as promoted members', async () => {
+    const uri = '/ws/src/models.ts';
+    index.addFile(uri, [
+      createTestSymbol({ id: 'base', name: 'Entity', kind: 'class', filePath: uri, location: { uri, line: 0, character: 13 } }),
+      createTestSymbol({ id: 'base-id', name: 'id', kind: 'property', containerName: 'Entity', fullContainerPath: 'Entity', filePath: uri, location: { uri, line: 1, character: 2 } }),
+      createTestSymbol({ id: 'user', name: 'User', kind: 'class', extends: 'Entity', filePath: uri, location: { uri, line: 4, character: 13 } }),
+      createTestSymbol({ id: 'user-save', name: 'save', kind: 'method', containerName: 'User', fullContainerPath: 'User', filePath: uri, location: { uri, line: 5, character: 2 } })
+    ]);
+
+    const [user] = await new TypeModel(index.asBackgroundIndex()).describe('User');
+    expect(user.methods.map(m => m.name)).toEqual(['save']);
+    expect(user.embedded.map(e => e.name)).toEqual(['Entity']);
+    expect(user.promoted.map(m => [m.name, m.promotedVia])).toEqual([['id', ['Entity']]]);
+  });
+});
@@ -0,0 +1,314 @@
+import { BackgroundIndex } from '../index/backgroundIndex.js';
+import { IndexedSymbol, SymbolLocation } from '../types.js';
+import { GoSymbolMetadata } from '../indexer/goIndexer.js';
+import * as path from 'path';
+
+export interface TypeMember {
+  name: string;
+  kind: string;
+  /** Declared type of fields/properties, when known */
+  type?: string;
+  /** Go methods: value (func (t T)) or pointer (func (t *T)) receiver */
+  receiver?: 'value' | 'pointer';
+  isStatic?: boolean;
+  location: SymbolLocation;
+  /** Embedding/inheritance chain a promoted member comes through (outermost first) */
+  promotedVia?: string[];
+}
+
+export interface EmbeddedType {
+  name: string;
+  /** Type expression as written (e.g. `*Base`, `io.Reader`) */
+  type: string;
+  pointer: boolean;
+  /** Location of the embedded type's declaration, if it is in the index */
+  resolved?: SymbolLocation;
+}
+
+export interface TypeDescription {
+  name: string;
+  qualifiedName: string;
+  kind: string;
+  location: SymbolLocation;
+  fields: TypeMember[];
+  methods: TypeMember[];
+  embedded: EmbeddedType[];
+  /** Members reachable through embedded types (Go) or base classes (TS) */
+  promoted: TypeMember[];
+}
+
+export interface DescribeTypeOptions {
+  /** File the query originates from; types in the same file/package are preferred */
+  uri?: string;
+  /** How deep to follow embedded types for promoted members */
+  maxDepth?: number;
+}
+
+const TYPE_KINDS = new Set(['struct', 'interface', 'type', 'class']);
+const FIELD_KINDS = new Set(['field', 'property']);
+const METHOD_KINDS = new Set(['method', 'constructor']);
+
+/**
+ * Own members of a type plus the types it embeds, before promotion.
+ */
+interface ResolvedType {
+  symbol: IndexedSymbol;
+  fields: TypeMember[];
+  methods: TypeMember[];
+  embedded: EmbeddedType[];
+  embeddedSymbols: IndexedSymbol[];
+}
+
+/**
+ * Type Model - structured view of a type's members.
+ *
+ * Groups the fields and methods of a type, wherever they are declared:
+ * Go methods live in any file of the package (the directory), TS/JS
+ * members live in the class body. Embedded Go types and TS base classes
+ * contribute promoted members, with Go's shadowing rule applied: a name
+ * declared at a shallower depth hides deeper ones, and a name found twice
+ * at the same depth is ambiguous and not promoted.
+ */
+export class TypeModel {
+  constructor(private backgroundIndex: BackgroundIndex) {}
+
+  /**
+   * Describe every type matching name (`Person` or `pkg.Person`).
+   */
+  async describe(name: string, options: DescribeTypeOptions = {}): Promise<TypeDescription[]> {
+    const types = await this.findTypes(name, options.uri);
+    const results: TypeDescription[] = [];
+
+    for (const symbol of types) {
+      const resolved = await this.resolve(symbol);
+      results.push({
+        name: symbol.name,
+        qualifiedName: qualifiedName(symbol),
+        kind: symbol.kind,
+        location: symbol.location,
+        fields: resolved.fields,
+        methods: resolved.methods,
+        embedded: resolved.embedded,
+        promoted: await this.collectPromoted(resolved, options.maxDepth ?? 8)
+      });
+    }
+
+    return results;
+  }
+
+  /**
+   * Find type declarations by (optionally package-qualified) name.
+   * Full symbols are reloaded from their files since SQL lookups may omit metadata.
+   */
+  private async findTypes(name: string, fromUri?: string): Promise<IndexedSymbol[]> {
+    const dot = name.lastIndexOf('.');
+    const qualifier = dot > 0 ? name.slice(0, dot) : undefined;
+    const simpleName = dot > 0 ? name.slice(dot + 1) : name;
+
+    const candidates = (await this.backgroundIndex.findDefinitions(simpleName))
+      .filter(s => TYPE_KINDS.has(s.kind));
+
+    const seen = new Set<string>();
+    const types: IndexedSymbol[] = [];
+    for (const candidate of candidates) {
+      if (seen.has(candidate.id)) {
+        continue;
+      }
+      seen.add(candidate.id);
+      const full = await this.loadFullSymbol(candidate);
+      if (qualifier && !matchesQualifier(full, qualifier)) {
+        continue;
+      }
+      types.push(full);
+    }
+
+    if (fromUri && types.length > 1) {
+      const local = types.filter(t => t.location.uri === fromUri || sameGoPackage(t.location.uri, fromUri));
+      if (local.length > 0) {
+        return local;
+      }
+    }
+    return types;
+  }
+
+  private async resolve(symbol: IndexedSymbol): Promise<ResolvedType> {
+    const members = await this.loadMembers(symbol);
+    const fields: TypeMember[] = [];
+    const methods: TypeMember[] = [];
+    const embedded: EmbeddedType[] = [];
+    const embeddedSymbols: IndexedSymbol[] = [];
+
+    for (const member of members) {
+      const go = goMetadata(member);
+      if (go.embedded) {
+        const typeText = go.type || member.name;
+        const target = await this.resolveEmbedded(member.name, typeText, symbol.location.uri);
+        embedded.push({
+          name: member.name,
+          type: typeText,
+          pointer: typeText.startsWith('*'),
+          ...(target && { resolved: target.location })
+        });
+        if (target) {
+          embeddedSymbols.push(target);
+        }
+      } else if (FIELD_KINDS.has(member.kind)) {
+        fields.push(toMember(member));
+      } else if (METHOD_KINDS.has(member.kind)) {
+        methods.push(toMember(member));
+      }
+    }
+
+    // Embedded interfaces are recorded on the interface itself (no field symbol)
+    const go = goMetadata(symbol);
+    if (symbol.kind === 'interface' && go.embeds) {
+      for (const embed of go.embeds) {
+        const target = await this.resolveEmbedded(embed, embed, symbol.location.uri);
+        embedded.push({ name: embed, type: embed, pointer: false, ...(target && { resolved: target.location }) });
+        if (target) {
+          embeddedSymbols.push(target);
+        }
+      }
+    }
+
+    // TS/JS: the base class plays the role of an embedded type
+    if (symbol.extends) {
+      const target = await this.resolveEmbedded(symbol.extends, symbol.extends, symbol.location.uri);
+      embedded.push({ name: symbol.extends, type: symbol.extends, pointer: false, ...(target && { resolved: target.location }) });
+      if (target) {
+        embeddedSymbols.push(target);
+      }
+    }
+
+    return { symbol, fields, methods, embedded, embeddedSymbols };
+  }
+
+  /**
+   * Breadth-first walk over embedded types applying Go's promotion rules.
+   */
+  private async collectPromoted(root: ResolvedType, maxDepth: number): Promise<TypeMember[]> {
+    const declared = new Set([...root.fields, ...root.methods, ...root.embedded].map(m => m.name));
+    const promoted: TypeMember[] = [];
+    const visited = new Set<string>([root.symbol.id]);
+
+    let level: Array<{ symbol: IndexedSymbol; via: string[] }> =
+      root.embeddedSymbols.map(s => ({ symbol: s, via: [s.name] }));
+
+    for (let depth = 1; depth <= maxDepth && level.length > 0; depth++) {
+      const atDepth = new Map<string, TypeMember[]>();
+      const next: Array<{ symbol: IndexedSymbol; via: string[] }> = [];
+
+      for (const { symbol, via } of level) {
+        if (visited.has(symbol.id)) {
+          continue;
+        }
+        visited.add(symbol.id);
+
+        const resolved = await this.resolve(symbol);
+        const members = [
+          ...resolved.fields,
+          ...resolved.methods,
+          ...resolved.embedded.map(e => ({ name: e.name, kind: 'field', type: e.type, location: e.resolved ?? symbol.location }))
+        ];
+        for (const member of members) {
+          const list = atDepth.get(member.name) || [];
+          list.push({ ...member, promotedVia: via });
+          atDepth.set(member.name, list);
+        }
+        for (const child of resolved.embeddedSymbols) {
+          next.push({ symbol: child, via: [...via, child.name] });
+        }
+      }
+
+      for (const [name, candidates] of atDepth) {
+        if (declared.has(name)) {
+          continue; // shadowed by a shallower declaration
+        }
+        declared.add(name);
+        if (candidates.length === 1) {
+          promoted.push(candidates[0]);
+        }
+        // Same name twice at one depth is ambiguous - Go does not promote it
+      }
+
+      level = next;
+    }
+
+    return promoted;
+  }
+
+  /**
+   * Fields and methods declared for a type. Go methods may live anywhere in
+   * the package directory, so all .go files next to the type are scanned.
+   */
+  private async loadMembers(type: IndexedSymbol): Promise<IndexedSymbol[]> {
+    const uri = type.location.uri;
+    const memberPath = qualifiedName(type);
+    const files = isGoFile(uri)
+      ? this.backgroundIndex.getAllFileUris().filter(f => sameGoPackage(f, uri))
+      : [uri];
+
+    const members: IndexedSymbol[] = [];
+    for (const file of files) {
+      for (const symbol of await this.backgroundIndex.getFileSymbols(file)) {
+        if (symbol.containerName === type.name && symbol.fullContainerPath === memberPath && symbol.id !== type.id) {
+          members.push(symbol);
+        }
+      }
+    }
+    return members;
+  }
+
+  /**
+   * Resolve an embedded type expression (`*Base`, `io.Reader`) to its declaration.
+   */
+  private async resolveEmbedded(name: string, typeText: string, fromUri: string): Promise<IndexedSymbol | undefined> {
+    const bare = typeText.replace(/^\*/, '').replace(/\[.*$/, '');
+    const dot = bare.lastIndexOf('.');
+    const qualified = dot > 0 ? bare : undefined;
+
+    const types = await this.findTypes(qualified ?? name, qualified ? undefined : fromUri);
+    return types.length === 1 ? types[0] : undefined;
+  }
+
+  private async loadFullSymbol(symbol: IndexedSymbol): Promise<IndexedSymbol> {
+    const fileSymbols = await this.backgroundIndex.getFileSymbols(symbol.location.uri);
+    return fileSymbols.find(s => s.id === symbol.id) ?? symbol;
+  }
+}
+
+function toMember(symbol: IndexedSymbol): TypeMember {
+  const go = goMetadata(symbol);
+  const member: TypeMember = { name: symbol.name, kind: symbol.kind, location: symbol.location };
+  if (go.type) {
+    member.type = go.type;
+  }
+  if (go.receiverType) {
+    member.receiver = go.pointerReceiver ? 'pointer' : 'value';
+  }
+  if (symbol.isStatic) {
+    member.isStatic = true;
+  }
+  return member;
+}
+
+function goMetadata(symbol: IndexedSymbol): GoSymbolMetadata {
+  return (symbol.metadata?.go ?? {}) as GoSymbolMetadata;
+}
+
+function qualifiedName(symbol: IndexedSymbol): string {
+  return symbol.fullContainerPath ? `${symbol.fullContainerPath}.${symbol.name}` : symbol.name;
+}
+
+function matchesQualifier(symbol: IndexedSymbol, qualifier: string): boolean {
+  const fullPath = symbol.fullContainerPath || '';
+  return goMetadata(symbol).package === qualifier || fullPath === qualifier || fullPath.endsWith(`.${qualifier}`);
+}
+
+function isGoFile(uri: string): boolean {
+  return path.extname(uri) === '.go';
+}
+
+function sameGoPackage(a: string, b: string): boolean {
+  return isGoFile(a) && isGoFile(b) && path.dirname(a) === path.dirname(b);
+}
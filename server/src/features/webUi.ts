@@ -0,0 +1,435 @@
+/**
+ * Web UI - a single page for browsing the index in a browser, served by
+ * the query server at `/` (and `/ui`).
+ *
+ * Embedded as a string so the bundled server ships it without extra
+ * files. The page only calls the public endpoints (/symbols, /outline,
+ * /references, /call-graph, /progress), so it sees what any other client
+ * of the query server sees. State lives in the URL hash, which makes a
+ * symbol view a link that can be shared. No external scripts or styles:
+ * it works offline and behind a proxy.
+ *
+ * API paths are relative to the page, so QueryServerHost can serve the
+ * page of each index under `/indexes/<name>/`. When the server asks for
+ * credentials, the page prompts for a token and keeps it for the tab.
+ *
+ * The inline script avoids template literals and backslashes, which would
+ * need escaping in the string below.
+ */
+
+export const WEB_UI_CONTENT_TYPE = 'text/html; charset=utf-8';
+
+export const WEB_UI_HTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Smart Indexer</title>
+<style>
+  :root { --fg: #1f2328; --muted: #656d76; --line: #d0d7de; --bg: #ffffff; --panel: #f6f8fa; --accent: #0969da; --callers: #8250df; --callees: #1a7f37; }
+  @media (prefers-color-scheme: dark) {
+    :root { --fg: #e6edf3; --muted: #8d96a0; --line: #30363d; --bg: #0d1117; --panel: #161b22; --accent: #4493f8; --callers: #ab7df8; --callees: #3fb950; }
+  }
+  * { box-sizing: border-box; }
+  body { margin: 0; font: 14px/1.45 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: var(--fg); background: var(--bg); display: grid; grid-template-columns: 360px 1fr; grid-template-rows: auto 1fr auto; height: 100vh; }
+  header { grid-column: 1 / 3; display: flex; gap: 12px; align-items: center; padding: 10px 16px; border-bottom: 1px solid var(--line); background: var(--panel); }
+  header h1 { font-size: 16px; margin: 0; white-space: nowrap; }
+  header input { flex: 1; font: inherit; padding: 6px 10px; border: 1px solid var(--line); border-radius: 6px; background: var(--bg); color: var(--fg); }
+  aside { overflow: auto; border-right: 1px solid var(--line); }
+  main { overflow: auto; padding: 16px 24px; }
+  footer { grid-column: 1 / 3; padding: 4px 16px; border-top: 1px solid var(--line); color: var(--muted); font-size: 12px; background: var(--panel); }
+  ul { list-style: none; margin: 0; padding: 0; }
+  .result { padding: 6px 16px; cursor: pointer; border-bottom: 1px solid var(--line); }
+  .result:hover, .result.selected { background: var(--panel); }
+  .kind { display: inline-block; min-width: 64px; color: var(--muted); font-size: 12px; }
+  .path { color: var(--muted); font-size: 12px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+  code, .mono { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; }
+  h2 { margin: 0 0 4px; font-size: 20px; }
+  pre.doc { white-space: pre-wrap; background: var(--panel); padding: 8px 12px; border-radius: 6px; }
+  .tabs { display: flex; gap: 4px; margin: 16px 0 8px; border-bottom: 1px solid var(--line); }
+  .tabs button { font: inherit; background: none; border: none; border-bottom: 2px solid transparent; padding: 6px 12px; cursor: pointer; color: var(--muted); }
+  .tabs button.active { color: var(--fg); border-bottom-color: var(--accent); }
+  a { color: var(--accent); text-decoration: none; cursor: pointer; }
+  a:hover { text-decoration: underline; }
+  .tree ul { padding-left: 18px; }
+  .tree li { padding: 1px 0; }
+  .group { margin: 12px 0 4px; font-weight: 600; }
+  .empty, .error { color: var(--muted); padding: 12px 16px; }
+  .error { color: #cf222e; }
+  svg { color: var(--muted); }
+  svg .node rect { fill: var(--panel); stroke: var(--line); rx: 4; }
+  svg .node.root rect { stroke: var(--accent); stroke-width: 2; }
+  svg .node text { fill: var(--fg); font: 12px ui-monospace, Menlo, Consolas, monospace; }
+  svg .node { cursor: pointer; }
+  svg .edge { fill: none; stroke: var(--muted); }
+  svg .edge.ambiguous { stroke-dasharray: 4 3; }
+  .legend { color: var(--muted); font-size: 12px; margin-bottom: 8px; }
+  .controls { display: flex; gap: 12px; align-items: center; margin-bottom: 8px; color: var(--muted); }
+  .controls select { font: inherit; }
+</style>
+</head>
+<body>
+<header>
+  <h1>Smart Indexer</h1>
+  <input id="search" type="search" placeholder="Search symbols (e.g. UserService, NewPerson, Store.Get)" autofocus>
+</header>
+<aside><ul id="results"></ul></aside>
+<main id="detail"><p class="empty">Search for a symbol to see its definition, the outline of its file, its references and its call graph.</p></main>
+<footer id="status">Connecting...</footer>
+<script>
+(function () {
+  'use strict';
+
+  var ROW = 34;
+  var COLUMN = 220;
+  var NODE_WIDTH = 180;
+  var svgNs = 'http://www.w3.org/2000/svg';
+  var state = { search: '', symbol: null, tab: 'outline', depth: 2, results: [] };
+
+  var TOKEN_KEY = 'smart-indexer-token';
+
+  function el(id) { return document.getElementById(id); }
+
+  function escapeHtml(text) {
+    return String(text).replace(/[&<>"']/g, function (c) {
+      return { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' }[c];
+    });
+  }
+
+  function api(endpoint, params) {
+    // Relative to the page, so an index served under /indexes/name/ queries itself
+    var url = new URL(endpoint.slice(1), window.location.href);
+    url.hash = '';
+    Object.keys(params || {}).forEach(function (key) {
+      if (params[key] !== undefined && params[key] !== null && params[key] !== '') {
+        url.searchParams.set(key, params[key]);
+      }
+    });
+    var token = sessionStorage.getItem(TOKEN_KEY);
+    return fetch(url, token ? { headers: { Authorization: 'Bearer ' + token } } : {}).then(function (response) {
+      if (response.status === 401) {
+        var entered = window.prompt(token ? 'The access token was not accepted. Access token:' : 'This server requires an access token:');
+        if (entered) {
+          sessionStorage.setItem(TOKEN_KEY, entered.trim());
+          return api(endpoint, params);
+        }
+      }
+      return response.json().then(function (body) {
+        if (!response.ok) {
+          throw new Error(body.error || response.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function where(location) {
+    return location.uri + ':' + (location.line + 1);
+  }
+
+  function qualified(symbol) {
+    return symbol.containerName ? symbol.containerName + '.' + symbol.name : symbol.name;
+  }
+
+  // -- URL hash state ------------------------------------------------------
+
+  function writeHash() {
+    var params = new URLSearchParams();
+    if (state.search) { params.set('q', state.search); }
+    if (state.symbol) {
+      params.set('name', state.symbol.name);
+      params.set('uri', state.symbol.location.uri);
+      params.set('line', state.symbol.location.line);
+      params.set('character', state.symbol.location.character);
+      params.set('tab', state.tab);
+    }
+    history.replaceState(null, '', '#' + params.toString());
+  }
+
+  function readHash() {
+    var params = new URLSearchParams(window.location.hash.slice(1));
+    state.search = params.get('q') || '';
+    state.tab = params.get('tab') || 'outline';
+    el('search').value = state.search;
+    if (state.search) { search(state.search); }
+    if (params.get('uri')) {
+      api('/outline', { uri: params.get('uri') }).then(function (body) {
+        var line = Number(params.get('line'));
+        var match = body.symbols.filter(function (s) { return s.name === params.get('name') && s.location.line === line; })[0];
+        if (match) { select(match); }
+      }).catch(showError);
+    }
+  }
+
+  // -- Search ----------------------------------------------------------------
+
+  var pending = 0;
+  function search(query) {
+    state.search = query;
+    var id = ++pending;
+    if (!query.trim()) {
+      el('results').innerHTML = '';
+      return;
+    }
+    api('/symbols', { q: query, limit: 100 }).then(function (body) {
+      if (id !== pending) { return; }
+      state.results = body.symbols;
+      renderResults();
+    }).catch(function (error) {
+      el('results').innerHTML = '<li class="error">' + escapeHtml(error.message) + '</li>';
+    });
+  }
+
+  function renderResults() {
+    var list = el('results');
+    if (state.results.length === 0) {
+      list.innerHTML = '<li class="empty">No symbols found.</li>';
+      return;
+    }
+    list.innerHTML = state.results.map(function (symbol, i) {
+      var selected = state.symbol && state.symbol.location.uri === symbol.location.uri && state.symbol.location.line === symbol.location.line;
+      return '<li class="result' + (selected ? ' selected' : '') + '" data-index="' + i + '">' +
+        '<span class="kind">' + escapeHtml(symbol.kind) + '</span> <span class="mono">' + escapeHtml(qualified(symbol)) + '</span>' +
+        '<div class="path">' + escapeHtml(where(symbol.location)) + '</div></li>';
+    }).join('');
+  }
+
+  el('results').addEventListener('click', function (event) {
+    var item = event.target.closest('.result');
+    if (item) { select(state.results[Number(item.dataset.index)]); }
+  });
+
+  var typing;
+  el('search').addEventListener('input', function (event) {
+    clearTimeout(typing);
+    typing = setTimeout(function () { search(event.target.value); writeHash(); }, 150);
+  });
+
+  // -- Symbol detail ---------------------------------------------------------
+
+  function select(symbol) {
+    state.symbol = symbol;
+    writeHash();
+    renderResults();
+    var detail = el('detail');
+    var parts = ['<div class="path">' + escapeHtml(symbol.kind) + ' &middot; ' + escapeHtml(where(symbol.location)) + '</div>',
+      '<h2 class="mono">' + escapeHtml(qualified(symbol)) + '</h2>'];
+    if (symbol.signature) { parts.push('<div><code>' + escapeHtml(symbol.signature) + '</code></div>'); }
+    if (symbol.owners) { parts.push('<div class="path">Owners: ' + escapeHtml(symbol.owners.join(', ')) + '</div>'); }
+    if (symbol.deprecated) { parts.push('<div class="error">Deprecated' + (symbol.deprecation ? ': ' + escapeHtml(symbol.deprecation) : '') + '</div>'); }
+    if (symbol.doc) { parts.push('<pre class="doc">' + escapeHtml(symbol.doc) + '</pre>'); }
+    parts.push('<div class="tabs">' + ['outline', 'references', 'calls'].map(function (tab) {
+      var label = { outline: 'File outline', references: 'References', calls: 'Call graph' }[tab];
+      return '<button data-tab="' + tab + '"' + (tab === state.tab ? ' class="active"' : '') + '>' + label + '</button>';
+    }).join('') + '</div><div id="tab"></div>');
+    detail.innerHTML = parts.join('');
+    detail.querySelectorAll('.tabs button').forEach(function (button) {
+      button.addEventListener('click', function () { showTab(button.dataset.tab); });
+    });
+    showTab(state.tab);
+  }
+
+  function showTab(tab) {
+    state.tab = tab;
+    writeHash();
+    el('detail').querySelectorAll('.tabs button').forEach(function (button) {
+      button.classList.toggle('active', button.dataset.tab === tab);
+    });
+    el('tab').innerHTML = '<p class="empty">Loading...</p>';
+    var load = { outline: showOutline, references: showReferences, calls: showCallGraph }[tab];
+    load(state.symbol).catch(showError);
+  }
+
+  function showError(error) {
+    var target = el('tab') || el('detail');
+    target.innerHTML = '<p class="error">' + escapeHtml(error.message || error) + '</p>';
+  }
+
+  /** A link opening the symbol defined at a location, resolved through the file outline */
+  function locationLink(label, location) {
+    return '<a class="mono" data-uri="' + escapeHtml(location.uri) + '" data-line="' + location.line + '">' + escapeHtml(label) + '</a>';
+  }
+
+  el('detail').addEventListener('click', function (event) {
+    var link = event.target.closest('a[data-uri]');
+    if (!link) { return; }
+    var line = Number(link.dataset.line);
+    api('/outline', { uri: link.dataset.uri }).then(function (body) {
+      var inside = body.symbols.filter(function (s) { return s.range.startLine <= line && s.range.endLine >= line; });
+      var exact = body.symbols.filter(function (s) { return s.location.line === line; })[0];
+      var innermost = inside.sort(function (a, b) { return (a.range.endLine - a.range.startLine) - (b.range.endLine - b.range.startLine); })[0];
+      if (exact || innermost) { select(exact || innermost); }
+    }).catch(showError);
+  });
+
+  function showOutline(symbol) {
+    return api('/outline', { uri: symbol.location.uri, tree: 'true' }).then(function (body) {
+      var render = function (nodes) {
+        return '<ul>' + nodes.map(function (node) {
+          var current = node.selectionRange.start.line === symbol.location.line && node.name === symbol.name;
+          var label = locationLink(node.name, { uri: symbol.location.uri, line: node.selectionRange.start.line });
+          return '<li><span class="kind">' + escapeHtml(node.kind) + '</span> ' + (current ? '<strong>' + label + '</strong>' : label) +
+            (node.detail ? ' <span class="path">' + escapeHtml(node.detail) + '</span>' : '') +
+            (node.children ? render(node.children) : '') + '</li>';
+        }).join('') + '</ul>';
+      };
+      el('tab').innerHTML = '<div class="path">' + escapeHtml(symbol.location.uri) + '</div>' +
+        (body.outline.length === 0 ? '<p class="empty">No declarations.</p>' : '<div class="tree">' + render(body.outline) + '</div>');
+    });
+  }
+
+  function showReferences(symbol) {
+    return api('/references', { name: symbol.name }).then(function (body) {
+      var byFile = {};
+      body.references.forEach(function (reference) {
+        (byFile[reference.location.uri] = byFile[reference.location.uri] || []).push(reference);
+      });
+      var files = Object.keys(byFile).sort();
+      if (files.length === 0) {
+        el('tab').innerHTML = '<p class="empty">No references to ' + escapeHtml(body.name) + '.</p>';
+        return;
+      }
+      el('tab').innerHTML = '<div class="path">' + body.references.length + ' references in ' + files.length + ' files</div>' +
+        files.map(function (uri) {
+          return '<div class="group">' + escapeHtml(uri) + '</div><ul>' + byFile[uri].map(function (reference) {
+            var kind = reference.isImport ? 'import' : reference.isCall ? 'call' : 'use';
+            return '<li><span class="kind">' + kind + '</span> ' +
+              locationLink('line ' + (reference.location.line + 1) + (reference.containerName ? ' in ' + reference.containerName : ''), reference.location) + '</li>';
+          }).join('') + '</ul>';
+        }).join('');
+    });
+  }
+
+  // -- Call graph ------------------------------------------------------------
+
+  function showCallGraph(symbol) {
+    var location = symbol.location;
+    return api('/call-graph', { uri: location.uri, line: location.line, character: location.character, depth: state.depth }).then(function (body) {
+      var tab = el('tab');
+      if (body.roots.length === 0) {
+        tab.innerHTML = '<p class="empty">' + escapeHtml(symbol.name) + ' is not a function or method.</p>';
+        return;
+      }
+      tab.innerHTML = '<div class="controls">Depth <select id="depth">' + [1, 2, 3, 4].map(function (depth) {
+        return '<option' + (depth === state.depth ? ' selected' : '') + '>' + depth + '</option>';
+      }).join('') + '</select><span class="legend"><span style="color: var(--callers)">callers</span> on the left, ' +
+        '<span style="color: var(--callees)">callees</span> on the right; dashed: ambiguous by name. ' +
+        body.nodes.length + ' functions, ' + body.edges.length + ' calls.</span></div>';
+      tab.querySelector('#depth').addEventListener('change', function (event) {
+        state.depth = Number(event.target.value);
+        showTab('calls');
+      });
+      tab.appendChild(drawGraph(body));
+    });
+  }
+
+  /**
+   * Lay the graph out in columns: the roots in the middle, callers one
+   * column to the left per call away, callees to the right.
+   */
+  function drawGraph(graph) {
+    var column = {};
+    graph.roots.forEach(function (id) { column[id] = 0; });
+    var spread = function (next, step) {
+      var frontier = graph.roots.slice();
+      while (frontier.length > 0) {
+        var following = [];
+        frontier.forEach(function (id) {
+          graph.edges.forEach(function (edge) {
+            var target = next(edge, id);
+            if (target && column[target] === undefined) {
+              column[target] = column[id] + step;
+              following.push(target);
+            }
+          });
+        });
+        frontier = following;
+      }
+    };
+    spread(function (edge, id) { return edge.caller === id ? edge.callee : null; }, 1);
+    spread(function (edge, id) { return edge.callee === id ? edge.caller : null; }, -1);
+
+    var columns = {};
+    graph.nodes.forEach(function (node) {
+      var c = column[node.id] === undefined ? 0 : column[node.id];
+      (columns[c] = columns[c] || []).push(node);
+    });
+    var keys = Object.keys(columns).map(Number).sort(function (a, b) { return a - b; });
+    var min = keys[0];
+    var tallest = Math.max.apply(null, keys.map(function (c) { return columns[c].length; }));
+    var position = {};
+    keys.forEach(function (c) {
+      var nodes = columns[c].sort(function (a, b) { return a.qualifiedName < b.qualifiedName ? -1 : 1; });
+      var offset = (tallest - nodes.length) * ROW / 2;
+      nodes.forEach(function (node, i) {
+        position[node.id] = { x: 10 + (c - min) * COLUMN, y: 10 + offset + i * ROW, node: node };
+      });
+    });
+
+    var svg = document.createElementNS(svgNs, 'svg');
+    svg.setAttribute('width', 20 + (keys.length - 1) * COLUMN + NODE_WIDTH);
+    svg.setAttribute('height', 20 + tallest * ROW);
+    svg.innerHTML = '<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto">' +
+      '<path d="M0,0 L10,5 L0,10 z" fill="currentColor"></path></marker></defs>';
+
+    graph.edges.forEach(function (edge) {
+      var from = position[edge.caller];
+      var to = position[edge.callee];
+      if (!from || !to) { return; }
+      var x1 = from.x + NODE_WIDTH, y1 = from.y + 12, x2 = to.x, y2 = to.y + 12;
+      if (to.x <= from.x) { x1 = from.x + NODE_WIDTH / 2; x2 = to.x + NODE_WIDTH / 2; y1 = from.y + 24; y2 = to.y; }
+      var path = document.createElementNS(svgNs, 'path');
+      var bend = Math.max(Math.abs(x2 - x1) / 2, 20);
+      path.setAttribute('d', 'M' + x1 + ',' + y1 + ' C' + (x1 + bend) + ',' + y1 + ' ' + (x2 - bend) + ',' + y2 + ' ' + x2 + ',' + y2);
+      path.setAttribute('class', 'edge' + (edge.ambiguous ? ' ambiguous' : ''));
+      path.setAttribute('marker-end', 'url(#arrow)');
+      var title = document.createElementNS(svgNs, 'title');
+      title.textContent = edge.callSites.length + ' call site' + (edge.callSites.length === 1 ? '' : 's');
+      path.appendChild(title);
+      svg.appendChild(path);
+    });
+
+    Object.keys(position).forEach(function (id) {
+      var p = position[id];
+      var c = column[id] || 0;
+      var group = document.createElementNS(svgNs, 'g');
+      group.setAttribute('class', 'node' + (c === 0 && graph.roots.indexOf(id) !== -1 ? ' root' : ''));
+      group.setAttribute('transform', 'translate(' + p.x + ',' + p.y + ')');
+      var name = p.node.qualifiedName;
+      var label = name.length > 24 ? '...' + name.slice(name.length - 23) : name;
+      group.innerHTML = '<rect width="' + NODE_WIDTH + '" height="24"' +
+        (c < 0 ? ' style="stroke: var(--callers)"' : c > 0 ? ' style="stroke: var(--callees)"' : '') + '></rect>' +
+        '<text x="8" y="16">' + escapeHtml(label) + '</text><title>' + escapeHtml(name + ' - ' + where(p.node.location)) + '</title>';
+      group.addEventListener('click', function () {
+        api('/outline', { uri: p.node.location.uri }).then(function (body) {
+          var match = body.symbols.filter(function (s) { return s.name === p.node.name && s.location.line === p.node.location.line; })[0];
+          if (match) { select(match); }
+        }).catch(showError);
+      });
+      svg.appendChild(group);
+    });
+    return svg;
+  }
+
+  // -- Status ----------------------------------------------------------------
+
+  function refreshStatus() {
+    api('/progress').then(function (progress) {
+      var indexing = progress.phase && progress.phase !== 'idle';
+      el('status').textContent = indexing
+        ? 'Indexing: ' + progress.filesIndexed + ' of ' + progress.filesTotal + ' files'
+        : 'Index ready';
+      setTimeout(refreshStatus, indexing ? 2000 : 30000);
+    }).catch(function () {
+      api('/health').then(function () { el('status').textContent = 'Connected'; })
+        .catch(function () { el('status').textContent = 'Query server unreachable'; });
+    });
+  }
+
+  readHash();
+  refreshStatus();
+})();
+</script>
+</body>
+</html>
+`;
@@ -0,0 +1,119 @@
+/**
+ * Git Delta Tests
+ *
+ * Reads the changes since a commit from a throwaway git repository:
+ * committed, uncommitted and untracked ones, scoped to a subdirectory.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { gitChangedLinesSince, gitChangesSince, GitRunner } from './gitDelta.js';
+
+describe('gitChangesSince', () => {
+  let root: string;
+
+  function git(args: string[], cwd: string = root): string {
+    return execFileSync('git', args, {
+      cwd,
+      encoding: 'utf-8',
+      stdio: 'pipe',
+      env: {
+        ...process.env,
+        GIT_AUTHOR_NAME: 'Ada', GIT_AUTHOR_EMAIL: 'ada@example.com',
+        GIT_COMMITTER_NAME: 'Ada', GIT_COMMITTER_EMAIL: 'ada@example.com'
+      }
+    });
+  }
+
+  function write(file: string, content: string): void {
+    fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+    fs.writeFileSync(path.join(root, file), content);
+  }
+
+  function runner(cwd: string): GitRunner {
+    return async args => git(args, cwd);
+  }
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'git-delta-'));
+    git(['init', '-q']);
+    write('svc/keep.go', 'package svc\n');
+    write('svc/edit.go', 'package svc\n');
+    write('svc/old name.go', 'package svc\n');
+    write('docs/readme.md', '# docs\n');
+    write('.gitignore', '*.log\n');
+    git(['add', '.']);
+    git(['commit', '-q', '-m', 'base']);
+    git(['tag', 'base']);
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should list committed, uncommitted and untracked changes', async () => {
+    write('svc/edit.go', 'package svc\n\nfunc Edit() {}\n');
+    git(['mv', 'svc/old name.go', 'svc/new name.go']);
+    git(['commit', '-q', '-am', 'rename']);
+    write('svc/keep.go', 'package svc\n\nfunc Keep() {}\n');
+    write('svc/draft.go', 'package svc\n');
+    write('svc/debug.log', 'ignored\n');
+
+    const delta = await gitChangesSince(root, 'base', runner(root));
+
+    expect(delta.since).toBe(git(['rev-parse', 'base']).trim());
+    expect(delta.head).toBe(git(['rev-parse', 'HEAD']).trim());
+    expect(delta.added).toEqual([path.join(root, 'svc/draft.go'), path.join(root, 'svc/new name.go')]);
+    expect(delta.modified).toEqual([path.join(root, 'svc/edit.go'), path.join(root, 'svc/keep.go')]);
+    expect(delta.deleted).toEqual([path.join(root, 'svc/old name.go')]);
+  });
+
+  it('should only list files under a subdirectory', async () => {
+    write('svc/edit.go', 'package svc\n\nfunc Edit() {}\n');
+    write('docs/readme.md', '# changed\n');
+    write('docs/new.md', '# new\n');
+
+    const svc = path.join(root, 'svc');
+    const delta = await gitChangesSince(svc, 'base', runner(svc));
+
+    expect(delta.modified).toEqual([path.join(svc, 'edit.go')]);
+    expect(delta.added).toEqual([]);
+  });
+
+  it('should reject unknown commits and directories outside a repository', async () => {
+    await expect(gitChangesSince(root, 'no-such-commit', runner(root))).rejects.toThrow('Unknown commit: no-such-commit');
+
+    const outside = fs.mkdtempSync(path.join(os.tmpdir(), 'git-delta-outside-'));
+    try {
+      await expect(gitChangesSince(outside, 'base', async () => { throw new Error('not a git repository'); }))
+        .rejects.toThrow('Not a git repository');
+    } finally {
+      fs.rmSync(outside, { recursive: true, force: true });
+    }
+  });
+
+  it('should list the changed lines of modified files', async () => {
+    write('svc/edit.go', 'package svc\n\nfunc A() {}\n\nfunc B() {\n\tprintln(1)\n\tprintln(2)\n}\n');
+    write('svc/old name.go', 'package svc\n\nvar X = 1\n');
+    git(['commit', '-q', '-am', 'functions']);
+    git(['tag', 'functions']);
+    write('svc/edit.go', 'package svc\n\nfunc A() { println(0) }\n\nfunc B() {\n\tprintln(1)\n}\n');
+    write('svc/old name.go', 'package svc\n\nvar X = 2\n');
+    write('svc/draft.go', 'package svc\n');
+
+    const delta = await gitChangedLinesSince(root, 'functions', runner(root));
+
+    expect(delta.files).toEqual([
+      { file: path.join(root, 'svc/draft.go'), status: 'added', ranges: [] },
+      {
+        file: path.join(root, 'svc/edit.go'),
+        status: 'modified',
+        ranges: [{ startLine: 2, endLine: 2 }, { startLine: 5, endLine: 6 }]
+      },
+      { file: path.join(root, 'svc/old name.go'), status: 'modified', ranges: [{ startLine: 2, endLine: 2 }] }
+    ]);
+  });
+});
@@ -0,0 +1,170 @@
+import { simpleGit } from 'simple-git';
+import * as path from 'path';
+
+/** Runs git in the directory being indexed; replaced in tests */
+export type GitRunner = (args: string[]) => Promise<string>;
+
+/**
+ * Files changed between a commit and the working tree, as absolute paths.
+ */
+export interface GitDelta {
+  /** The commit compared against, resolved to its hash */
+  since: string;
+  /** HEAD when the changes were read */
+  head: string;
+  /** New files, untracked ones included (unless git-ignored) */
+  added: string[];
+  modified: string[];
+  deleted: string[];
+}
+
+/**
+ * Changes to the files under dir since a commit: committed, staged and
+ * uncommitted ones alike, as `git diff <since>` plus untracked files.
+ * dir may be a subdirectory of the repository; only files under it are
+ * listed. Renames are a deletion and an addition. Throws on directories
+ * outside a repository and on unknown commits.
+ */
+export async function gitChangesSince(dir: string, since: string, runGit?: GitRunner): Promise<GitDelta> {
+  const root = path.resolve(dir);
+  let run = runGit;
+  if (!run) {
+    const git = simpleGit(root);
+    run = args => git.raw(args);
+  }
+
+  try {
+    await run(['rev-parse', '--is-inside-work-tree']);
+  } catch {
+    throw new Error(`Not a git repository: ${root}`);
+  }
+  const base = await resolveCommit(run, since);
+  const head = await resolveCommit(run, 'HEAD');
+
+  // -z: NUL-separated, unquoted paths, relative to dir (--relative)
+  const diff = splitNul(await run(['diff', '--name-status', '--no-renames', '--relative', '-z', base, '--']));
+  const delta: GitDelta = { since: base, head, added: [], modified: [], deleted: [] };
+  for (let i = 0; i + 1 < diff.length; i += 2) {
+    const file = path.join(root, diff[i + 1]);
+    switch (diff[i][0]) {
+      case 'A':
+        delta.added.push(file);
+        break;
+      case 'D':
+        delta.deleted.push(file);
+        break;
+      default:
+        // M, T (type change), U (unmerged)
+        delta.modified.push(file);
+    }
+  }
+
+  const listed = new Set(delta.added);
+  for (const relative of splitNul(await run(['ls-files', '-z', '--others', '--exclude-standard']))) {
+    const file = path.join(root, relative);
+    if (!listed.has(file)) {
+      delta.added.push(file);
+    }
+  }
+  delta.added.sort();
+  delta.modified.sort();
+  delta.deleted.sort();
+  return delta;
+}
+
+/** Lines of a file changed since a commit, zero-based and inclusive */
+export interface ChangedLineRange {
+  startLine: number;
+  endLine: number;
+}
+
+export interface GitFileLineChange {
+  /** Absolute path */
+  file: string;
+  status: 'added' | 'modified' | 'deleted';
+  /** Changed lines of the current file; empty for additions and deletions, which change it all */
+  ranges: ChangedLineRange[];
+}
+
+export interface GitLineDelta {
+  since: string;
+  head: string;
+  files: GitFileLineChange[];
+}
+
+/**
+ * Like gitChangesSince, with the lines each modified file changed, from
+ * `git diff --unified=0 <since>`. A hunk that only deletes lines marks
+ * the lines around the deletion, so removing a statement changes the
+ * function it was in.
+ */
+export async function gitChangedLinesSince(dir: string, since: string, runGit?: GitRunner): Promise<GitLineDelta> {
+  const root = path.resolve(dir);
+  let run = runGit;
+  if (!run) {
+    const git = simpleGit(root);
+    run = args => git.raw(args);
+  }
+
+  const delta = await gitChangesSince(root, since, run);
+  const patch = await run(['-c', 'core.quotePath=false', 'diff', '--unified=0', '--no-renames', '--no-color', '--no-ext-diff', '--relative', delta.since, '--']);
+  const ranges = parseUnifiedDiff(patch);
+  const files: GitFileLineChange[] = [
+    ...delta.added.map(file => ({ file, status: 'added' as const, ranges: [] })),
+    ...delta.modified.map(file => ({
+      file,
+      status: 'modified' as const,
+      ranges: ranges.get(path.relative(root, file).split(path.sep).join('/')) ?? []
+    })),
+    ...delta.deleted.map(file => ({ file, status: 'deleted' as const, ranges: [] }))
+  ];
+  return { since: delta.since, head: delta.head, files: files.sort((a, b) => (a.file < b.file ? -1 : a.file > b.file ? 1 : 0)) };
+}
+
+/**
+ * Changed line ranges of the current files per path, from a unified diff
+ * with `a/` and `b/` prefixes. Deleted files are left out.
+ */
+export function parseUnifiedDiff(patch: string): Map<string, ChangedLineRange[]> {
+  const files = new Map<string, ChangedLineRange[]>();
+  let current: ChangedLineRange[] | undefined;
+  for (const line of patch.split('\n')) {
+    if (line.startsWith('+++ ')) {
+      const target = line.slice(4).replace(/\t$/, '');
+      current = undefined;
+      if (target !== '/dev/null') {
+        const file = target.startsWith('b/') ? target.slice(2) : target;
+        current = files.get(file) ?? [];
+        files.set(file, current);
+      }
+      continue;
+    }
+    const hunk = /^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@/.exec(line);
+    if (hunk && current) {
+      const start = Number(hunk[1]);
+      const count = hunk[2] === undefined ? 1 : Number(hunk[2]);
+      // +N,0: lines deleted after line N (one-based)
+      current.push(count === 0
+        ? { startLine: Math.max(start - 1, 0), endLine: start }
+        : { startLine: start - 1, endLine: start + count - 2 });
+    }
+  }
+  return files;
+}
+
+async function resolveCommit(run: GitRunner, revision: string): Promise<string> {
+  let commit = '';
+  try {
+    commit = (await run(['rev-parse', '--verify', '--quiet', `${revision}^{commit}`])).trim();
+  } catch {
+    // reported below
+  }
+  if (!commit) {
+    throw new Error(`Unknown commit: ${revision}`);
+  }
+  return commit;
+}
+
+function splitNul(output: string): string[] {
+  return output.split('\0').filter(entry => entry.length > 0);
+}
@@ -21,9 +21,12 @@ import { URI } from 'vscode-uri';
 
 import { IHandler, ServerServices, ServerState } from './types.js';
 import { ImportResolver } from '../indexer/importResolver.js';
+import { collectGoDependencyFiles } from '../indexer/goModules.js';
+import { collectBazelGeneratedFiles } from '../indexer/bazelTargets.js';
 import { StaticIndex } from '../index/staticIndex.js';
 import { DeadCodeDetector } from '../features/deadCode.js';
 import { FileWatcher } from '../index/fileWatcher.js';
+import { formatProgress } from '../utils/indexingProgress.js';
 import * as path from 'path';
 import * as crypto from 'crypto';
 import * as fsPromises from 'fs/promises';
@@ -106,6 +109,7 @@ export class InitializationHandler implements IHandler {
           definitionProvider: true,
           referencesProvider: true,
           workspaceSymbolProvider: true,
+          documentSymbolProvider: true,
           hoverProvider: true,
           renameProvider: {
             prepareProvider: true
@@ -356,7 +360,7 @@ export class InitializationHandler implements IHandler {
         documents,
         backgroundIndex,
         configManager,
-        this.state.workspaceRoot,
+        [this.state.workspaceRoot],
         logger,
         600 // 600ms debounce delay
       );
@@ -442,7 +446,7 @@ export class InitializationHandler implements IHandler {
    * Perform full background indexing of the workspace.
    */
   private async performFullBackgroundIndexing(): Promise<void> {
-    const { connection, statsManager, logger } = this.services;
+    const { connection, configManager, statsManager, logger } = this.services;
     const { fileScanner } = this.services.infrastructure;
     
     if (!this.state.workspaceRoot) {
@@ -454,6 +458,19 @@ export class InitializationHandler implements IHandler {
       connection.console.info('[Server] Starting full workspace background indexing...');
       const allFiles = await fileScanner.scanWorkspace(this.state.workspaceRoot, true); // Skip folder hash optimization for full scan
       connection.console.info(`[Server] File scanner discovered ${allFiles.length} indexable files`);
+
+      if (configManager.getConfig().goIncludeDependencies) {
+        const dependencyFiles = await collectGoDependencyFiles(allFiles);
+        connection.console.info(`[Server] Including ${dependencyFiles.length} Go dependency files from the module cache`);
+        allFiles.push(...dependencyFiles);
+      }
+
+      const bazel = configManager.getBazelConfig();
+      if (bazel.enabled && bazel.generatedSources) {
+        const generatedFiles = await collectBazelGeneratedFiles(allFiles);
+        connection.console.info(`[Server] Including ${generatedFiles.length} generated Go files of Bazel targets`);
+        allFiles.push(...generatedFiles);
+      }
       
       if (allFiles.length === 0) {
         logger.warn('[Server] No files found to index. Check excludePatterns and file extensions.');
@@ -498,8 +515,8 @@ export class InitializationHandler implements IHandler {
 
       // Use BackgroundIndex's built-in worker pool
       await backgroundIndex.ensureUpToDate(files, computeHash, (current, total) => {
-        progress.report((current / total) * 100, `${current}/${total}`);
-      });
+        progress.report((current / total) * 100, formatProgress(backgroundIndex.getIndexingProgress()));
+      }, progress.token);
 
       progress.done();
 
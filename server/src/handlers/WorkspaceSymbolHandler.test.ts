@@ -129,4 +129,25 @@ describe('WorkspaceSymbolHandler', () => {
       // Note: Actual ranking happens in MergedIndex, not in the handler
     });
   });
+
+  describe('Scenario: Repeated queries', () => {
+    it('should answer from the cache until the index generation changes', async () => {
+      mockIndex.addSymbol(createTestSymbol({ name: 'AuthService', location: { uri: '/test/auth.ts', line: 1, character: 0 } }));
+      let generation = 1;
+      const mergedIndex = Object.assign(Object.create(mockIndex) as MockIndex, { getGeneration: () => generation });
+      const services = {
+        ...createMockServices(mergedIndex, new Map()),
+        configManager: { getSearchConfig: () => ({ excludeTags: [], cacheSize: 10 }) }
+      };
+      handler = new WorkspaceSymbolHandler(services as any, createMockState());
+      const search = async () => ((await (handler as any).handleWorkspaceSymbol({ query: 'Auth' })) as any[]).map(s => s.name);
+
+      expect(await search()).toEqual(['AuthService']);
+      mockIndex.addSymbol(createTestSymbol({ name: 'AuthToken', location: { uri: '/test/auth.ts', line: 2, character: 0 } }));
+      expect(await search()).toEqual(['AuthService']);
+
+      generation++;
+      expect(await search()).toContain('AuthToken');
+    });
+  });
 });
@@ -7,6 +7,8 @@
  * - Apply intelligent search mode selection based on query length
  * - Rank results by FTS relevance and context (open files, current file)
  * - Map storage results to LSP WorkspaceSymbol format
+ * - Hide code tagged with `smartIndexer.search.excludeTags` (generated, test, ...)
+ * - Answer repeated queries from a cache until the index changes
  * 
  * Search Strategy:
  * - Short queries (< 3 chars): Use 'prefix' mode to avoid noise
@@ -16,17 +18,27 @@
  * - FTS5 queries typically complete in < 10ms even on large codebases
  * - Results are limited to 200 to avoid overwhelming the UI
  * - No explicit debouncing needed (VS Code handles this)
+ * - Cached results (`smartIndexer.search.cacheSize`) are keyed by the query,
+ *   the tag filter and the ranking context, and dropped when the merged
+ *   index reports a new generation (see utils/queryCache.ts)
  */
 
 import {
   WorkspaceSymbol,
-  WorkspaceSymbolParams,
-  SymbolKind
+  WorkspaceSymbolParams
 } from 'vscode-languageserver/node';
 import { URI } from 'vscode-uri';
 
 import { IHandler, ServerServices, ServerState } from './types.js';
 import { RankingContext } from '../utils/fuzzySearch.js';
+import { toLspSymbolKind } from '../utils/symbolKind.js';
+import { CodeTag } from '../types.js';
+import { matchesTagFilter } from '../utils/codeTags.js';
+import { QueryCache } from '../utils/queryCache.js';
+
+const MAX_RESULTS = 200;
+/** Searches that drop tagged symbols over-fetch so filtering does not starve the result */
+const MAX_FILTERED_CANDIDATES = 1000;
 
 /**
  * Handler for workspace/symbol requests.
@@ -36,6 +48,7 @@ export class WorkspaceSymbolHandler implements IHandler {
   
   private services: ServerServices;
   private state: ServerState;
+  private cache = new QueryCache();
 
   constructor(services: ServerServices, state: ServerState) {
     this.services = services;
@@ -77,25 +90,19 @@ export class WorkspaceSymbolHandler implements IHandler {
         currentFileUri: this.state.currentActiveDocumentUri
       };
 
-      // Use fuzzy search with ranking (MergedIndex handles the coordination)
-      const { mergedIndex } = this.services;
-      const symbols = await mergedIndex.searchSymbols(query, 200, context);
-
-      // Map to LSP WorkspaceSymbol format
-      const results = symbols.map(sym => ({
-        name: sym.name,
-        kind: this.mapSymbolKind(sym.kind),
-        location: {
-          uri: URI.file(sym.location.uri).toString(),
-          range: {
-            start: { line: sym.location.line, character: sym.location.character },
-            end: { line: sym.location.line, character: sym.location.character + sym.name.length }
-          }
-        },
-        containerName: sym.containerName
-      }));
+      const { mergedIndex, configManager, metrics } = this.services;
+      const { excludeTags, cacheSize } = configManager.getSearchConfig();
+      this.cache.resize(cacheSize);
+      let cached = false;
+      const key = JSON.stringify([query, excludeTags, context.currentFileUri ?? null, [...openFiles].sort()]);
+      const results = await this.cache.get(key, mergedIndex.getGeneration(), () => this.search(query, context, excludeTags), {
+        onLookup: hit => {
+          cached = hit;
+          metrics?.countQueryCacheLookup('lsp', hit);
+        }
+      });
 
-      logger.info(`[WorkspaceSymbol] Result: query="${query}", ${results.length} symbols in ${Date.now() - start} ms`);
+      logger.info(`[WorkspaceSymbol] Result: query="${query}", ${results.length} symbols${cached ? ' (cached)' : ''} in ${Date.now() - start} ms`);
       return results;
     } catch (error) {
       logger.error(`[WorkspaceSymbol] Error: ${error}, ${Date.now() - start} ms`);
@@ -103,39 +110,30 @@ export class WorkspaceSymbolHandler implements IHandler {
     }
   }
 
-  /**
-   * Map internal symbol kind to LSP SymbolKind.
-   */
-  private mapSymbolKind(kind: string): SymbolKind {
-    switch (kind.toLowerCase()) {
-      case 'file': return SymbolKind.File;
-      case 'module': return SymbolKind.Module;
-      case 'namespace': return SymbolKind.Namespace;
-      case 'package': return SymbolKind.Package;
-      case 'class': return SymbolKind.Class;
-      case 'method': return SymbolKind.Method;
-      case 'property': return SymbolKind.Property;
-      case 'field': return SymbolKind.Field;
-      case 'constructor': return SymbolKind.Constructor;
-      case 'enum': return SymbolKind.Enum;
-      case 'interface': return SymbolKind.Interface;
-      case 'function': return SymbolKind.Function;
-      case 'variable': return SymbolKind.Variable;
-      case 'constant': return SymbolKind.Constant;
-      case 'string': return SymbolKind.String;
-      case 'number': return SymbolKind.Number;
-      case 'boolean': return SymbolKind.Boolean;
-      case 'array': return SymbolKind.Array;
-      case 'object': return SymbolKind.Object;
-      case 'key': return SymbolKind.Key;
-      case 'null': return SymbolKind.Null;
-      case 'enummember': return SymbolKind.EnumMember;
-      case 'struct': return SymbolKind.Struct;
-      case 'event': return SymbolKind.Event;
-      case 'operator': return SymbolKind.Operator;
-      case 'typeparameter': return SymbolKind.TypeParameter;
-      default: return SymbolKind.Variable;
-    }
+  private async search(query: string, context: RankingContext, excludeTags: CodeTag[]): Promise<WorkspaceSymbol[]> {
+    // Use fuzzy search with ranking (MergedIndex handles the coordination)
+    const candidates = await this.services.mergedIndex.searchSymbols(
+      query,
+      excludeTags.length > 0 ? MAX_FILTERED_CANDIDATES : MAX_RESULTS,
+      context
+    );
+    const symbols = candidates
+      .filter(sym => matchesTagFilter(sym.tags, { exclude: excludeTags }))
+      .slice(0, MAX_RESULTS);
+
+    // Map to LSP WorkspaceSymbol format
+    return symbols.map(sym => ({
+      name: sym.name,
+      kind: toLspSymbolKind(sym.kind),
+      location: {
+        uri: URI.file(sym.location.uri).toString(),
+        range: {
+          start: { line: sym.location.line, character: sym.location.character },
+          end: { line: sym.location.line, character: sym.location.character + sym.name.length }
+        }
+      },
+      containerName: sym.containerName
+    }));
   }
 }
 
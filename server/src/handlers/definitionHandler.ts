@@ -7,6 +7,8 @@
  * - Resolve imports and re-exports
  * - TypeScript-based disambiguation for multiple candidates
  * - Deduplicate results to prevent duplicate locations in the same file
+ * - Send generated protobuf Go code back to its .proto definitions
+ * - Resolve Go template fields and template names
  * 
  * This handler implements the core "Go to Definition" functionality.
  */
@@ -25,6 +27,9 @@ import { parseMemberAccess, resolvePropertyRecursively } from '../indexer/recurs
 import { disambiguateSymbols } from '../utils/disambiguation.js';
 import { getWordRangeAtPosition } from '../utils/textUtils.js';
 import { shouldEnableLooseMode } from '../utils/ngrxContextDetector.js';
+import { protoDefinitions } from '../indexer/protoIndexer.js';
+import { isConfigFileKey } from '../indexer/configFileIndexer.js';
+import { goTemplateDefinitionsAt, isGoTemplateFile } from '../indexer/goTemplateIndexer.js';
 
 /**
  * Handler for textDocument/definition requests.
@@ -109,6 +114,24 @@ export class DefinitionHandler implements IHandler {
 
       const text = document.getText();
       
+      // Go templates: fields resolve through the template's data type, not the TS/JS resolver
+      if (isGoTemplateFile(uri)) {
+        const templateDefinitions = await goTemplateDefinitionsAt(text, line, character, mergedIndex);
+        if (templateDefinitions) {
+          logger.info(`[Server] Go template definitions: ${templateDefinitions.length}`);
+          trace.addFilter('GoTemplate');
+          result = templateDefinitions.length === 0 ? null : templateDefinitions.map(symbol => ({
+            uri: URI.file(symbol.location.uri).toString(),
+            range: {
+              start: { line: symbol.range.startLine, character: symbol.range.startCharacter },
+              end: { line: symbol.range.endLine, character: symbol.range.endCharacter }
+            }
+          }));
+          this.cacheResult(cacheKey, result);
+          return result;
+        }
+      }
+
       // Update trace with resolved symbol name (will be updated later if found)
       let symbolName = '<unknown>';
 
@@ -319,7 +342,8 @@ export class DefinitionHandler implements IHandler {
 
         // Standard resolution: get all candidates by name
         trace.startDbQuery();
-        const candidates = await mergedIndex.findDefinitions(symbolAtCursor.name);
+        // Generated protobuf code stands in for its .proto declarations
+        const candidates = await protoDefinitions(await mergedIndex.findDefinitions(symbolAtCursor.name), mergedIndex);
         trace.endDbQuery(candidates.length);
         
         logger.info(`[Server] Found ${candidates.length} candidates by name`);
@@ -366,6 +390,7 @@ export class DefinitionHandler implements IHandler {
             logger.info(`[Server] Rule 2 (Code Superiority) - Removed ${beforeTextFilter - definitionCandidates.length} text/markdown symbols`);
           }
         }
+        definitionCandidates = this.preferCodeOverConfigKeys(definitionCandidates, uri);
         
         // RULE 3: Implementation over Abstraction
         // If we have both Class AND Interface with same name, keep ONLY the Class
@@ -631,6 +656,17 @@ export class DefinitionHandler implements IHandler {
     this.queryCache.set(key, result);
   }
 
+  /**
+   * YAML/JSON config keys named like a code definition give way to it,
+   * unless the request comes from a config file.
+   */
+  private preferCodeOverConfigKeys(symbols: IndexedSymbol[], uri: string): IndexedSymbol[] {
+    if (/\.(ya?ml|json)$/i.test(uri) || !symbols.some(s => s.isDefinition === true && !isConfigFileKey(s))) {
+      return symbols;
+    }
+    return symbols.filter(s => !isConfigFileKey(s));
+  }
+
   /**
    * Execute fallback search with strict filtering for instant jumps.
    * 
@@ -649,7 +685,7 @@ export class DefinitionHandler implements IHandler {
   ): Promise<Location[] | null> {
     const { mergedIndex, logger } = this.services;
     
-    let symbols = await mergedIndex.findDefinitions(word);
+    let symbols = await protoDefinitions(await mergedIndex.findDefinitions(word), mergedIndex);
     logger.info(`[Server] Fallback: Found ${symbols.length} candidates for "${word}"`);
     
     // STRICT FILTERING PIPELINE
@@ -680,6 +716,7 @@ export class DefinitionHandler implements IHandler {
         logger.info(`[Server] Fallback Rule 2 - Removed ${beforeText - symbols.length} text symbols`);
       }
     }
+    symbols = this.preferCodeOverConfigKeys(symbols, uri);
     
     // RULE 3: Implementation over Abstraction
     const symbolsByName = new Map<string, IndexedSymbol[]>();
@@ -0,0 +1,104 @@
+/**
+ * DocumentSymbolHandler Unit Tests
+ *
+ * Tests the textDocument/documentSymbol outline built from the index.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { DocumentSymbolHandler } from './documentSymbolHandler.js';
+import { MockIndex, createTestSymbol } from '../test/mocks/MockIndex.js';
+import { createMockServices, createMockState } from '../test/mocks/MockServices.js';
+import { SymbolKind } from 'vscode-languageserver/node';
+
+describe('DocumentSymbolHandler', () => {
+  const uri = '/test/src/user.service.ts';
+  let mockIndex: MockIndex;
+  let handler: DocumentSymbolHandler;
+
+  beforeEach(() => {
+    mockIndex = new MockIndex();
+    handler = new DocumentSymbolHandler(createMockServices(mockIndex) as any, createMockState());
+  });
+
+  it('should nest members under their class by range', async () => {
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'UserService', kind: 'class', filePath: uri,
+      location: { uri, line: 2, character: 13 },
+      range: { startLine: 2, startCharacter: 0, endLine: 10, endCharacter: 1 }
+    }));
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'load', kind: 'method', containerName: 'UserService', filePath: uri,
+      location: { uri, line: 4, character: 2 },
+      range: { startLine: 4, startCharacter: 2, endLine: 6, endCharacter: 3 }
+    }));
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'cache', kind: 'property', containerName: 'UserService', filePath: uri,
+      location: { uri, line: 3, character: 10 },
+      range: { startLine: 3, startCharacter: 2, endLine: 3, endCharacter: 30 }
+    }));
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'createService', kind: 'function', filePath: uri,
+      location: { uri, line: 12, character: 16 },
+      range: { startLine: 12, startCharacter: 0, endLine: 14, endCharacter: 1 }
+    }));
+
+    const outline = await handler.handleDocumentSymbol({ textDocument: { uri: `file://${uri}` } });
+
+    expect(outline.map(s => s.name)).toEqual(['UserService', 'createService']);
+    expect(outline[0].kind).toBe(SymbolKind.Class);
+    expect(outline[0].children!.map(s => s.name)).toEqual(['cache', 'load']);
+    expect(outline[0].children![1].selectionRange).toEqual({
+      start: { line: 4, character: 2 },
+      end: { line: 4, character: 6 }
+    });
+    expect(outline[1].children).toBeUndefined();
+  });
+
+  it('should keep out-of-line members at the top level with their container as detail', async () => {
+    const goUri = '/test/model/person.go';
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'Person', kind: 'struct', filePath: goUri,
+      location: { uri: goUri, line: 2, character: 5 },
+      range: { startLine: 2, startCharacter: 0, endLine: 4, endCharacter: 1 }
+    }));
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'Greet', kind: 'method', containerName: 'Person', filePath: goUri,
+      location: { uri: goUri, line: 6, character: 17 },
+      // Range recorded without the name - must be widened to contain selectionRange
+      range: { startLine: 6, startCharacter: 0, endLine: 6, endCharacter: 10 }
+    }));
+
+    const outline = await handler.handleDocumentSymbol({ textDocument: { uri: `file://${goUri}` } });
+
+    expect(outline.map(s => [s.name, s.detail])).toEqual([['Person', undefined], ['Greet', 'Person']]);
+    expect(outline[0].kind).toBe(SymbolKind.Struct);
+    expect(outline[1].range.end).toEqual({ line: 6, character: 22 });
+  });
+
+  it('should show constant values as detail', async () => {
+    const goUri = '/test/client/retry.go';
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'MaxRetries', kind: 'constant', filePath: goUri, value: '5',
+      location: { uri: goUri, line: 2, character: 6 },
+      range: { startLine: 2, startCharacter: 6, endLine: 2, endCharacter: 20 }
+    }));
+
+    const outline = await handler.handleDocumentSymbol({ textDocument: { uri: `file://${goUri}` } });
+
+    expect(outline.map(s => [s.name, s.detail])).toEqual([['MaxRetries', '= 5']]);
+    expect(outline[0].kind).toBe(SymbolKind.Constant);
+  });
+
+  it('should skip references and text matches', async () => {
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'UserService', kind: 'class', isDefinition: false, filePath: uri,
+      location: { uri, line: 20, character: 4 }
+    }));
+    mockIndex.addSymbol(createTestSymbol({
+      name: 'TODO', kind: 'text', filePath: uri,
+      location: { uri, line: 21, character: 4 }
+    }));
+
+    expect(await handler.handleDocumentSymbol({ textDocument: { uri: `file://${uri}` } })).toEqual([]);
+  });
+});
@@ -0,0 +1,84 @@
+/**
+ * DocumentSymbolHandler - Handles LSP textDocument/documentSymbol requests.
+ *
+ * Responsibilities:
+ * - Build the outline of a file from the index (see features/outline.ts)
+ * - Convert it to LSP document symbols
+ *
+ * Open files are served from the dynamic index, everything else from the
+ * persisted background index, so editors get an outline even for files
+ * the server has never seen opened.
+ */
+
+import {
+  DocumentSymbol,
+  DocumentSymbolParams
+} from 'vscode-languageserver/node';
+import { URI } from 'vscode-uri';
+
+import { IHandler, ServerServices, ServerState } from './types.js';
+import { buildOutline, OutlineNode } from '../features/outline.js';
+import { toLspSymbolKind } from '../utils/symbolKind.js';
+
+/**
+ * Handler for textDocument/documentSymbol requests.
+ */
+export class DocumentSymbolHandler implements IHandler {
+  readonly name = 'DocumentSymbolHandler';
+
+  private services: ServerServices;
+
+  constructor(services: ServerServices, _state: ServerState) {
+    this.services = services;
+  }
+
+  register(): void {
+    const { connection } = this.services;
+    connection.onDocumentSymbol(this.handleDocumentSymbol.bind(this));
+  }
+
+  /**
+   * Handle textDocument/documentSymbol request.
+   */
+  async handleDocumentSymbol(params: DocumentSymbolParams): Promise<DocumentSymbol[]> {
+    const start = Date.now();
+    const { mergedIndex, logger } = this.services;
+    const filePath = URI.parse(params.textDocument.uri).fsPath;
+
+    try {
+      const symbols = await mergedIndex.getFileSymbols(filePath);
+      const outline = toDocumentSymbols(buildOutline(symbols));
+      logger.info(`[DocumentSymbol] ${filePath}: ${symbols.length} symbols in ${Date.now() - start} ms`);
+      return outline;
+    } catch (error) {
+      logger.error(`[DocumentSymbol] Error: ${error}`);
+      return [];
+    }
+  }
+}
+
+/**
+ * The file outline as LSP document symbols. Members declared outside their
+ * type (Go methods) stay top-level with the container as detail, since the
+ * protocol requires children to lie within their parent's range.
+ */
+export function toDocumentSymbols(nodes: OutlineNode[]): DocumentSymbol[] {
+  return nodes.map(node => ({
+    name: node.name,
+    kind: toLspSymbolKind(node.kind),
+    range: node.range,
+    selectionRange: node.selectionRange,
+    ...(node.detail !== undefined && { detail: node.detail }),
+    ...(node.children && { children: toDocumentSymbols(node.children) })
+  }));
+}
+
+/**
+ * Factory function for creating DocumentSymbolHandler.
+ */
+export function createDocumentSymbolHandler(
+  services: ServerServices,
+  state: ServerState
+): DocumentSymbolHandler {
+  return new DocumentSymbolHandler(services, state);
+}
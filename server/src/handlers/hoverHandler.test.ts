@@ -317,6 +317,25 @@ const total = calculateSum(5, 10);
     });
   });
 
+  describe('Generated summaries', () => {
+    it('should show the summary of a symbol above its doc comment', () => {
+      const symbol = createTestSymbol({
+        name: 'retryUpload',
+        kind: 'function',
+        location: { uri: '/test/upload.ts', line: 4, character: 16 },
+        isDefinition: true
+      });
+      handler = new HoverHandler(createMockServices(mockIndex) as any, createMockState());
+
+      const value: string = (handler as any).buildHoverContent(symbol, 'retryUpload uploads a file.', 'Retries failed uploads with backoff');
+      expect(value).toContain('*Summary (generated):* Retries failed uploads with backoff');
+      expect(value.indexOf('Summary (generated)')).toBeLessThan(value.indexOf('retryUpload uploads a file.'));
+
+      const plain: string = (handler as any).buildHoverContent(symbol, 'retryUpload uploads a file.');
+      expect(plain).not.toContain('Summary');
+    });
+  });
+
   describe('Location breadcrumbs', () => {
     it('should show file path in hover content', async () => {
       // Arrange: Symbol with file location
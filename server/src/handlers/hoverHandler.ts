@@ -3,7 +3,9 @@
  * 
  * Responsibilities:
  * - Show symbol signature and type information
- * - Display JSDoc documentation if available
+ * - Show the generated one-line summary of complex symbols, when enabled
+ * - Display the doc comment (JSDoc, Go doc, docstring) as Markdown, with
+ *   links to the symbols it mentions
  * - Show metadata (Angular decorators, NgRx actions, etc.)
  * - Provide file location breadcrumbs
  * 
@@ -21,6 +23,7 @@ import { URI } from 'vscode-uri';
 import { IHandler, ServerServices, ServerState } from './types.js';
 import { IndexedSymbol } from '../types.js';
 import { findSymbolAtPosition } from '../indexer/symbolResolver.js';
+import { SymbolDocs } from '../features/symbolDocs.js';
 
 /**
  * Handler for textDocument/hover requests.
@@ -73,7 +76,9 @@ export class HoverHandler implements IHandler {
       const symbol = this.pickBestSymbol(definitions, uri, symbolAtCursor.kind);
       
       // Build hover content
-      const content = this.buildHoverContent(symbol);
+      const documentation = await new SymbolDocs(mergedIndex).render(symbol);
+      const summary = this.services.summaries?.summaryOf(symbol.id);
+      const content = this.buildHoverContent(symbol, documentation, summary);
       
       return {
         contents: {
@@ -113,7 +118,7 @@ export class HoverHandler implements IHandler {
   /**
    * Build Markdown hover content for a symbol.
    */
-  private buildHoverContent(symbol: IndexedSymbol): string {
+  private buildHoverContent(symbol: IndexedSymbol, documentation?: string, summary?: string): string {
     const lines: string[] = [];
 
     // Header: Symbol signature
@@ -122,6 +127,18 @@ export class HoverHandler implements IHandler {
     lines.push('```');
     lines.push(''); // Blank line
 
+    // Generated summary, marked as such
+    if (summary) {
+      lines.push(`*Summary (generated):* ${summary}`);
+      lines.push('');
+    }
+
+    // Doc comment
+    if (documentation) {
+      lines.push(documentation);
+      lines.push('');
+    }
+
     // Metadata section (Angular, NgRx, etc.)
     const metadataContent = this.buildMetadataSection(symbol);
     if (metadataContent) {
@@ -141,6 +158,8 @@ export class HoverHandler implements IHandler {
    *   (class) UserService
    *   (method) UserService.getData(): Observable<User[]>
    *   (property) UserComponent.title: string
+   *   (function) Map[T any, U any](s []T, f func(T) U) []U
+   *   (constant) MaxRetries = 5
    */
   private buildSignature(symbol: IndexedSymbol): string {
     const parts: string[] = [];
@@ -148,11 +167,13 @@ export class HoverHandler implements IHandler {
     // Kind prefix (e.g., "(class)", "(method)")
     parts.push(`(${symbol.kind})`);
 
-    // Full path (container + name)
-    if (symbol.containerName) {
-      parts.push(`${symbol.containerName}.${symbol.name}`);
-    } else {
-      parts.push(symbol.name);
+    // Full path (container + name), with type parameters and the indexed signature
+    const qualifiedName = symbol.containerName ? `${symbol.containerName}.${symbol.name}` : symbol.name;
+    parts.push(qualifiedName + this.buildTypeParameters(symbol) + (symbol.signature?.replace(/^func\s*/, '') ?? ''));
+
+    // Constant value
+    if (symbol.value !== undefined) {
+      parts.push(`= ${symbol.value}`);
     }
 
     // Static modifier
@@ -160,8 +181,8 @@ export class HoverHandler implements IHandler {
       parts.push('[static]');
     }
 
-    // Parameters for functions/methods
-    if ((symbol.kind === 'function' || symbol.kind === 'method') && symbol.parametersCount !== undefined) {
+    // Parameters for functions/methods without an indexed signature
+    if ((symbol.kind === 'function' || symbol.kind === 'method') && !symbol.signature && symbol.parametersCount !== undefined) {
       const params = '...'.repeat(Math.min(symbol.parametersCount, 1)); // Simplified
       parts.push(`(${params})`);
     }
@@ -169,6 +190,20 @@ export class HoverHandler implements IHandler {
     return parts.join(' ');
   }
 
+  /**
+   * Type parameter list in the declaring language's syntax: `[K comparable, V any]`, `<T extends Base>`.
+   */
+  private buildTypeParameters(symbol: IndexedSymbol): string {
+    if (!symbol.typeParameters || symbol.typeParameters.length === 0) {
+      return '';
+    }
+    const isGo = symbol.location.uri.endsWith('.go');
+    const list = symbol.typeParameters
+      .map(p => (p.constraint ? `${p.name}${isGo ? ' ' : ' extends '}${p.constraint}` : p.name))
+      .join(', ');
+    return isGo ? `[${list}]` : `<${list}>`;
+  }
+
   /**
    * Build metadata section (Angular decorators, NgRx info, etc.).
    */
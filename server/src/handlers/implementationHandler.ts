@@ -7,6 +7,9 @@ import { URI } from 'vscode-uri';
 import { IHandler, ServerServices, ServerState } from './types.js';
 import { IndexedSymbol } from '../types.js';
 import { getWordRangeAtPosition } from '../utils/textUtils.js';
+import { ImplementationIndex } from '../features/interfaceImplementations.js';
+import { assemblyCounterparts } from '../indexer/goAsmIndexer.js';
+import { generatedGoDefinitions } from '../indexer/protoIndexer.js';
 
 /**
  * Handler for textDocument/implementation requests.
@@ -48,6 +51,41 @@ export class ImplementationHandler implements IHandler {
       const word = text.substring(wordRange.start, wordRange.end);
       logger.info(`[ImplementationHandler] Finding implementations for: ${word}`);
 
+      // Go functions declared without a body are implemented in assembly, and vice versa
+      const filePath = URI.parse(params.textDocument.uri).fsPath;
+      if (filePath.endsWith('.go') || filePath.endsWith('.s')) {
+        const counterparts = assemblyCounterparts(await mergedIndex.findDefinitions(word), filePath);
+        if (counterparts.length > 0 || filePath.endsWith('.s')) {
+          logger.info(`[ImplementationHandler] Found ${counterparts.length} Go/assembly counterparts for ${word}`);
+          return counterparts.map(symbol => Location.create(
+            URI.file(symbol.location.uri).toString(),
+            {
+              start: { line: symbol.location.line, character: symbol.location.character },
+              end: { line: symbol.location.line, character: symbol.location.character + symbol.name.length }
+            }
+          ));
+        }
+      }
+
+      // A .proto declaration is implemented by the Go code generated for it
+      if (filePath.endsWith('.proto')) {
+        const declarations = (await mergedIndex.getFileSymbols(filePath)).filter(symbol => symbol.name === word);
+        const generated = (await Promise.all(declarations.map(symbol => generatedGoDefinitions(symbol, mergedIndex)))).flat();
+        logger.info(`[ImplementationHandler] Found ${generated.length} generated Go declarations for ${word}`);
+        return generated.map(symbol => Location.create(
+          URI.file(symbol.location.uri).toString(),
+          {
+            start: { line: symbol.location.line, character: symbol.location.character },
+            end: { line: symbol.location.line, character: symbol.location.character + symbol.name.length }
+          }
+        ));
+      }
+
+      // Go interfaces are satisfied implicitly - compare method sets instead of declarations
+      if (filePath.endsWith('.go')) {
+        return this.findGoImplementations(word, filePath);
+      }
+
       const implementations: Location[] = [];
       
       // Strategy:
@@ -99,7 +137,28 @@ export class ImplementationHandler implements IHandler {
     }
   }
 
-  private checkAndAddImplementation(
+  private async findGoImplementations(name: string, filePath: string): Promise<Location[]> {
+    const { backgroundIndex, logger } = this.services;
+    const index = new ImplementationIndex(backgroundIndex);
+    await index.build();
+
+    // On a concrete type, jump to the interfaces it satisfies instead
+    let matches = index.implementations(name, filePath);
+    if (matches.length === 0) {
+      matches = index.interfacesOf(name, filePath);
+    }
+
+    logger.info(`[ImplementationHandler] Found ${matches.length} Go method-set matches for ${name}`);
+    return matches.map(match => Location.create(
+      URI.file(match.location.uri).toString(),
+      {
+        start: { line: match.location.line, character: match.location.character },
+        end: { line: match.location.line, character: match.location.character + match.name.length }
+      }
+    ));
+  }
+
+    private checkAndAddImplementation(
     sym: IndexedSymbol, 
     targetName: string, 
     implementations: Location[], 
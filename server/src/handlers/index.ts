@@ -71,6 +71,11 @@ export {
   createImplementationHandler
 } from './implementationHandler.js';
 
+export {
+  DocumentSymbolHandler,
+  createDocumentSymbolHandler
+} from './documentSymbolHandler.js';
+
 // Future handlers (to be implemented):
 // export { DocumentHandler, createDocumentHandler } from './DocumentHandler.js';
 // export { CommandsHandler, createCommandsHandler } from './CommandsHandler.js';
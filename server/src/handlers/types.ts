@@ -17,12 +17,14 @@ import { LanguageRouter } from '../indexer/languageRouter.js';
 import { FileScanner } from '../indexer/fileScanner.js';
 import { GitWatcher } from '../git/gitWatcher.js';
 import { Profiler } from '../profiler/profiler.js';
+import { IndexerMetrics } from '../profiler/indexerMetrics.js';
 import { RequestTracer } from '../utils/RequestTracer.js';
 import { StatsManager } from '../index/statsManager.js';
 import { FolderHasher } from '../cache/folderHasher.js';
 import { DeadCodeDetector } from '../features/deadCode.js';
 import { FileWatcher } from '../index/fileWatcher.js';
 import { ILogger } from '../utils/Logger.js';
+import { SummaryLookup } from '../features/symbolSummaries.js';
 
 /**
  * Core services shared across all handlers.
@@ -85,6 +87,8 @@ export interface MonitoringServices {
   statsManager: StatsManager;
   /** Request tracer for forensic observability */
   requestTracer: RequestTracer;
+  /** Prometheus metrics, when the server exposes them */
+  metrics?: IndexerMetrics;
 }
 
 /**
@@ -100,6 +104,8 @@ export interface ServerServices extends CoreServices, IndexServices, ResolutionS
   infrastructure: IndexingInfrastructure;
   /** File watcher for live sync */
   fileWatcher: FileWatcher | null;
+  /** Generated summaries of complex symbols, when enabled */
+  summaries?: SummaryLookup;
 }
 
 /**
@@ -1,4 +1,5 @@
-import { IndexedSymbol, ReExportInfo, IndexedReference, ImportInfo } from '../types.js';
+import { IndexedSymbol, ReExportInfo, IndexedReference, ImportInfo, CodeTag } from '../types.js';
+import { RankedSymbol, RankingContext } from '../utils/fuzzySearch.js';
 
 /**
  * Core interface for symbol indices.
@@ -46,8 +47,36 @@ export interface ISymbolIndex {
    */
   findReferencesByName?(name: string): Promise<IndexedReference[]>;
 
+  /**
+   * findReferencesByName in batches, in the same order, read as they are
+   * consumed: queries can send the first references before the last are read.
+   */
+  streamReferencesByName?(name: string): AsyncIterable<IndexedReference[]>;
+
   /**
    * Get import info for a file.
    */
   getFileImports?(uri: string): Promise<ImportInfo[]>;
+
+  /**
+   * Get the classifications of a file (generated, test, mock, example).
+   */
+  getFileTags?(uri: string): Promise<CodeTag[]>;
+
+  /**
+   * Count the non-local references to each name; names without
+   * references may be left out.
+   */
+  getReferenceCounts?(names: string[]): Promise<Map<string, number>>;
+
+  /**
+   * A number that changes whenever query results may: caches of results
+   * hold for as long as it stays the same.
+   */
+  getGeneration?(): number;
+
+  /**
+   * Search like searchSymbols, returning the ranking scores too.
+   */
+  searchSymbolsRanked?(query: string, limit: number, context?: RankingContext): Promise<RankedSymbol<IndexedSymbol>[]>;
 }
@@ -4,7 +4,10 @@ import { sanitizeFilePath } from '../utils/stringUtils.js';
 import { PROGRESS_CONFIG, LOG_PREFIX } from '../constants.js';
 import { ILogger, NullLogger } from '../utils/Logger.js';
 import * as fsPromises from 'fs/promises';
-import pLimit from 'p-limit';
+import { runOrderedPipeline } from '../utils/orderedPipeline.js';
+import { CancellationToken, CancellationError, throwIfCancelled } from '../utils/asyncUtils.js';
+import { IndexingProgress, ProgressTracker } from '../utils/indexingProgress.js';
+import { IndexingKind, IndexThrottle } from './indexThrottle.js';
 
 /**
  * Progress callback for indexing operations.
@@ -14,6 +17,8 @@ export type ProgressCallback = (progress: {
   processed: number;
   total: number;
   currentFile?: string;
+  /** Counts, throughput and ETA of the current run */
+  details?: IndexingProgress;
 }) => void;
 
 /**
@@ -49,6 +54,12 @@ export class IndexScheduler {
   private isBulkMode: boolean = false;
   private logger: ILogger;
   private maxConcurrentJobs: number = 4;
+  // Progress of the current (or last) bulk run
+  private tracker = new ProgressTracker();
+  // Files whose parsing failed, since startup
+  private parseErrors = 0;
+  // Nice mode for indexing while the user works (see setThrottle)
+  private throttle: IndexThrottle | null = null;
   
   /**
    * Create an IndexScheduler.
@@ -59,14 +70,39 @@ export class IndexScheduler {
   constructor(workerPool: IWorkerPool, logger?: ILogger) {
     this.workerPool = workerPool;
     this.logger = logger || new NullLogger();
+    this.tracker.setPhase('idle');
   }
 
   /**
    * Set maximum concurrent indexing jobs.
-   * Controls memory pressure by limiting active promises.
+   * Resizes the worker pool and bounds the number of buffered results.
    */
   setMaxConcurrentJobs(max: number): void {
     this.maxConcurrentJobs = Math.max(1, Math.min(16, max));
+    this.workerPool.resize(this.maxConcurrentJobs);
+  }
+
+  /**
+   * Throttle the files of the kinds its mode covers: single files are
+   * 'watch', bulk runs take their kind from the caller.
+   */
+  setThrottle(throttle: IndexThrottle | null): void {
+    this.throttle = throttle;
+  }
+
+  /**
+   * Counts, throughput and ETA of the current bulk run, or the totals of
+   * the last one when idle.
+   */
+  getProgress(): IndexingProgress {
+    return this.tracker.snapshot();
+  }
+
+  /**
+   * Number of files that failed to parse since startup.
+   */
+  getParseErrorCount(): number {
+    return this.parseErrors;
   }
 
   /**
@@ -97,10 +133,13 @@ export class IndexScheduler {
     
     try {
       // Validate file exists
-      await fsPromises.access(sanitizedUri);
+      const { size } = await fsPromises.stat(sanitizedUri);
       
       // Index the file using worker pool
-      const result = await this.workerPool.runTask({ uri: sanitizedUri });
+      const result = await this.parse(sanitizedUri, 'watch', size).catch(error => {
+        this.parseErrors++;
+        throw error;
+      });
       
       // Handle the result
       if (result.isSkipped) {
@@ -145,24 +184,37 @@ export class IndexScheduler {
    * @param validator - Function to check if file needs indexing
    * @param handler - Result handler to process indexed data
    * @param onProgress - Optional progress callback
+   * @param cancellationToken - Optional token; throws CancellationError once
+   *   the files already being parsed have been written
+   * @param kind - 'watch' for updates of files changed while the user works,
+   *   which a throttle in watch mode holds back
    */
   async scheduleBulk(
     files: string[],
     validator: FileValidator,
     handler: IndexResultHandler,
-    onProgress?: (current: number, total: number) => void
+    onProgress?: (current: number, total: number) => void,
+    cancellationToken?: CancellationToken,
+    kind: IndexingKind = 'full'
   ): Promise<void> {
+    const tracker = new ProgressTracker();
+    tracker.fileScanned(files.length);
+    tracker.setPhase('checking');
+    this.tracker = tracker;
+
     // Filter files that need indexing
     const filesToIndex: string[] = [];
     let checked = 0;
     
     for (const uri of files) {
+      throwIfCancelled(cancellationToken);
       const needsIndexing = await validator(uri);
       if (needsIndexing) {
         filesToIndex.push(uri);
       }
       
       checked++;
+      tracker.fileChecked();
       if (onProgress) {
         onProgress(checked, files.length);
       }
@@ -181,7 +233,9 @@ export class IndexScheduler {
     try {
       await this.processQueue(filesToIndex, handler, onProgress ? 
         (current) => onProgress(checked - filesToIndex.length + current, files.length) :
-        undefined
+        undefined,
+        cancellationToken,
+        kind
       );
     } finally {
       // Always disable bulk mode
@@ -192,33 +246,36 @@ export class IndexScheduler {
   /**
    * Process the queue of files for indexing.
    * 
-   * This is the core execution loop that:
-   * - Pre-validates all files (filters non-existent files)
-   * - Submits tasks to the worker pool with bounded concurrency
-   * - Tracks progress and reports updates
-   * - Handles errors gracefully
-   * 
-   * Uses p-limit to prevent unbounded Promise.all() memory spikes.
+   * Pipeline: parse (worker threads, bounded concurrency) -> write (result
+   * handler, one file at a time in sorted path order). Output order is
+   * therefore deterministic regardless of which worker finishes first, and
+   * at most 2x maxConcurrentJobs parsed results are buffered in memory.
    * 
    * @param files - Array of file URIs to index
    * @param handler - Result handler to process indexed data
    * @param onProgress - Optional progress callback
+   * @param cancellationToken - Optional token; stops starting new files
+   * @param kind - What is indexed, for the throttle
    */
   private async processQueue(
     files: string[],
     handler: IndexResultHandler,
-    onProgress?: (current: number) => void
+    onProgress?: (current: number) => void,
+    cancellationToken?: CancellationToken,
+    kind: IndexingKind = 'full'
   ): Promise<void> {
     // Pre-validate: sanitize paths and filter non-existent files
     const validFiles: string[] = [];
     const skippedFiles: string[] = [];
+    const fileSizes = new Map<string, number>();
     
     for (const rawUri of files) {
       const uri = sanitizeFilePath(rawUri);
       
       try {
-        await fsPromises.access(uri);
+        const stat = await fsPromises.stat(uri);
         validFiles.push(uri);
+        fileSizes.set(uri, stat.size);
       } catch {
         skippedFiles.push(rawUri);
       }
@@ -240,10 +297,19 @@ export class IndexScheduler {
       }
     }
     
+    // Deterministic write order, independent of scan order and worker scheduling
+    validFiles.sort();
+    
     let processed = 0;
     const total = validFiles.length;
     const startTime = Date.now();
     let lastProgressTime = startTime;
+    const tracker = this.tracker;
+    let totalBytes = 0;
+    for (const size of fileSizes.values()) {
+      totalBytes += size;
+    }
+    tracker.startIndexing(total, totalBytes);
     
     // Emit initial busy state
     if (this.progressCallback) {
@@ -251,55 +317,74 @@ export class IndexScheduler {
         state: 'busy',
         processed: 0,
         total,
-        currentFile: validFiles[0]
+        currentFile: validFiles[0],
+        details: tracker.snapshot()
       });
     }
     
-    // Create semaphore to limit concurrent promises
-    const limit = pLimit(this.maxConcurrentJobs);
-    
-    // Process files with bounded concurrency
-    const indexFile = async (uri: string): Promise<void> => {
-      try {
-        const result = await this.workerPool.runTask({ uri });
-        
-        if (result.isSkipped) {
-          this.logger.warn(`${LOG_PREFIX.INDEX_SCHEDULER} Skipping file (${result.skipReason}): ${uri}`);
-        } else {
-          await handler(uri, result);
-        }
-      } catch (error) {
-        this.logger.error(`${LOG_PREFIX.INDEX_SCHEDULER} Error indexing file ${uri}: ${error}`);
-      } finally {
-        processed++;
-        if (onProgress) {
-          onProgress(processed);
-        }
-        
-        // Throttled progress reporting
-        const now = Date.now();
-        const shouldReport = 
-          this.progressCallback && 
-          (now - lastProgressTime >= PROGRESS_CONFIG.THROTTLE_INTERVAL_MS || 
-           processed % PROGRESS_CONFIG.BATCH_UPDATE_SIZE === 0 || 
-           processed === total);
-        
-        if (shouldReport) {
-          lastProgressTime = now;
-          this.progressCallback!({
-            state: 'busy',
-            processed,
-            total,
-            currentFile: uri
-          });
-        }
+    const reportProgress = (uri: string): void => {
+      processed++;
+      tracker.fileIndexed(uri, fileSizes.get(uri));
+      if (onProgress) {
+        onProgress(processed);
+      }
+      
+      // Throttled progress reporting
+      const now = Date.now();
+      const shouldReport = 
+        this.progressCallback && 
+        (now - lastProgressTime >= PROGRESS_CONFIG.THROTTLE_INTERVAL_MS || 
+         processed % PROGRESS_CONFIG.BATCH_UPDATE_SIZE === 0 || 
+         processed === total);
+      
+      if (shouldReport) {
+        lastProgressTime = now;
+        this.progressCallback!({
+          state: 'busy',
+          processed,
+          total,
+          currentFile: uri,
+          details: tracker.snapshot()
+        });
       }
     };
     
-    // Execute tasks with concurrency limit (prevents memory spike)
-    await Promise.allSettled(validFiles.map(uri => limit(() => indexFile(uri))));
+    await runOrderedPipeline(validFiles, {
+      concurrency: this.maxConcurrentJobs,
+      window: this.maxConcurrentJobs * 2,
+      produce: uri => this.parse(uri, kind, fileSizes.get(uri), cancellationToken).finally(() => tracker.fileParsed(uri)),
+      consume: async (result, uri) => {
+        try {
+          if (result.isSkipped) {
+            this.logger.warn(`${LOG_PREFIX.INDEX_SCHEDULER} Skipping file (${result.skipReason}): ${uri}`);
+          } else {
+            await handler(uri, result);
+          }
+        } catch (error) {
+          this.logger.error(`${LOG_PREFIX.INDEX_SCHEDULER} Error indexing file ${uri}: ${error}`);
+        } finally {
+          reportProgress(uri);
+        }
+      },
+      // Only parse failures get here - consume handles its own errors
+      onError: (error, uri) => {
+        if (error instanceof CancellationError) {
+          // Cancelled while the throttle held it back: not parsed at all
+          return;
+        }
+        this.parseErrors++;
+        this.logger.error(`${LOG_PREFIX.INDEX_SCHEDULER} Error indexing file ${uri}: ${error}`);
+        reportProgress(uri);
+      },
+      cancellationToken
+    });
     
     const duration = Date.now() - startTime;
+    if (cancellationToken?.isCancellationRequested) {
+      this.logger.warn(`${LOG_PREFIX.INDEX_SCHEDULER} Cancelled after ${processed}/${total} files in ${duration}ms`);
+      throw new CancellationError('Indexing cancelled');
+    }
+
     const filesPerSecond = (total / (duration / 1000)).toFixed(2);
     const stats = this.workerPool.getStats();
     
@@ -309,12 +394,23 @@ export class IndexScheduler {
     );
   }
 
+  /**
+   * Parse a file in the worker pool, under the throttle when one applies.
+   */
+  private parse(uri: string, kind: IndexingKind, bytes?: number, cancellationToken?: CancellationToken): Promise<IndexedFileResult> {
+    const task = () => this.workerPool.runTask({ uri });
+    return this.throttle ? this.throttle.run(kind, task, { bytes, cancellationToken }) : task();
+  }
+
   /**
    * Emit progress state to subscribers.
    */
   emitProgress(state: 'busy' | 'idle' | 'finalizing', processed: number, total: number): void {
+    if (state !== 'busy') {
+      this.tracker.setPhase(state);
+    }
     if (this.progressCallback) {
-      this.progressCallback({ state, processed, total });
+      this.progressCallback({ state, processed, total, details: this.tracker.snapshot() });
     }
   }
 
@@ -0,0 +1,145 @@
+/**
+ * IndexSnapshots Tests
+ *
+ * Runs writers against an in-memory stand-in for storage and checks that
+ * readers see the published generation until the last writer ends: file
+ * reads, summaries, name lookups, search and reference counts.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { FileSummary, IndexSnapshots } from './IndexSnapshots.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { FileIndexData } from '../storage/IIndexStorage.js';
+import { IndexedReference, IndexedSymbol } from '../types.js';
+
+const A = '/ws/a.go';
+const B = '/ws/b.go';
+const C = '/ws/c.go';
+
+function definition(uri: string, name: string): IndexedSymbol {
+  return createTestSymbol({ id: `${uri}#${name}`, name, filePath: uri, location: { uri, line: 0, character: 0 }, isDefinition: true });
+}
+
+function reference(uri: string, symbolName: string): IndexedReference {
+  return {
+    symbolName,
+    location: { uri, line: 1, character: 0 },
+    range: { startLine: 1, startCharacter: 0, endLine: 1, endCharacter: symbolName.length }
+  };
+}
+
+function shard(uri: string, symbols: IndexedSymbol[], references: IndexedReference[] = []): FileIndexData {
+  return { uri, hash: `${uri}:${symbols.map(s => s.name).join(',')}`, symbols, references, imports: [], lastIndexedAt: 0 };
+}
+
+describe('IndexSnapshots', () => {
+  let snapshots: IndexSnapshots;
+  let shards: Map<string, FileIndexData>;
+  let summaries: Map<string, FileSummary>;
+
+  /** A write as BackgroundIndex makes it: preserve, then store */
+  async function write(uri: string, next: FileIndexData | null): Promise<void> {
+    await snapshots.preserve(uri, async () => ({ shard: shards.get(uri) ?? null, summary: summaries.get(uri) }));
+    snapshots.recordWrite(uri, next?.references ?? []);
+    if (next) {
+      shards.set(uri, next);
+      summaries.set(uri, { hash: next.hash, lastIndexedAt: 1, symbolCount: next.symbols.length });
+    } else {
+      shards.delete(uri);
+      summaries.delete(uri);
+    }
+  }
+
+  const storedDefinitions = (name: string) =>
+    [...shards.values()].flatMap(s => s.symbols.filter(symbol => symbol.name === name));
+  const storedReferences = (name: string) =>
+    [...shards.values()].flatMap(s => s.references.filter(ref => ref.symbolName === name));
+  const definitionsOf = (name: string) =>
+    snapshots.definitions(storedDefinitions(name), name).map(symbol => symbol.location.uri).sort();
+  const read = (uri: string) => {
+    const published = snapshots.published(uri);
+    return published ? published.shard : shards.get(uri) ?? null;
+  };
+
+  beforeEach(() => {
+    snapshots = new IndexSnapshots();
+    shards = new Map([
+      [A, shard(A, [definition(A, 'Load')], [reference(A, 'Save')])],
+      [B, shard(B, [definition(B, 'Save')])]
+    ]);
+    summaries = new Map([...shards].map(([uri, s]) => [uri, { hash: s.hash, lastIndexedAt: 0, symbolCount: s.symbols.length }]));
+  });
+
+  it('should answer from the published generation until the run ends', async () => {
+    snapshots.beginWrite();
+    // Load moves from a.go to the new c.go; b.go goes away
+    await write(A, shard(A, [definition(A, 'Init')]));
+    await write(C, shard(C, [definition(C, 'Load')], [reference(C, 'Save')]));
+    await write(B, null);
+
+    expect(definitionsOf('Load')).toEqual([A]);
+    expect(definitionsOf('Save')).toEqual([B]);
+    expect(definitionsOf('Init')).toEqual([]);
+    expect(read(A)!.symbols.map(s => s.name)).toEqual(['Load']);
+    expect(read(C)).toBeNull();
+    expect([...snapshots.summaries(summaries)].map(([uri]) => uri).sort()).toEqual([A, B]);
+    expect(snapshots.summary(C, summaries.get(C))).toBeUndefined();
+    expect(snapshots.references(storedReferences('Save'), 'Save').map(ref => ref.location.uri)).toEqual([A]);
+    const streamed: string[] = [];
+    for await (const batch of snapshots.streamReferences([...shards.values()].map(s => s.references), 'Save')) {
+      streamed.push(...batch.map(ref => ref.location.uri));
+    }
+    expect(streamed).toEqual([A]);
+    expect(snapshots.getGeneration()).toBe(0);
+
+    expect(snapshots.endWrite()).toBe(1);
+    expect(definitionsOf('Load')).toEqual([C]);
+    expect(definitionsOf('Save')).toEqual([]);
+    expect([...snapshots.summaries(summaries)].map(([uri]) => uri).sort()).toEqual([A, C]);
+    expect(snapshots.getPendingFileCount()).toBe(0);
+  });
+
+  it('should publish concurrent writers together and keep the first preimage', async () => {
+    snapshots.beginWrite();
+    snapshots.beginWrite();
+    await write(A, shard(A, [definition(A, 'First')]));
+    await write(A, shard(A, [definition(A, 'Second')]));
+    expect(read(A)!.symbols[0].name).toBe('Load');
+
+    expect(snapshots.endWrite()).toBe(0);
+    expect(read(A)!.symbols[0].name).toBe('Load');
+    expect(snapshots.endWrite()).toBe(1);
+    expect(read(A)!.symbols[0].name).toBe('Second');
+
+    // Outside writers, writes are published right away
+    await write(B, shard(B, [definition(B, 'Store')]));
+    expect(snapshots.published(B)).toBeUndefined();
+    expect(snapshots.getGeneration()).toBe(1);
+  });
+
+  it('should publish direct writes only outside writers', async () => {
+    snapshots.publishDirectWrite();
+    expect(snapshots.getGeneration()).toBe(1);
+
+    // Inside writers the write waits for the last one to end
+    snapshots.beginWrite();
+    await write(C, shard(C, [definition(C, 'Cache')]));
+    snapshots.publishDirectWrite();
+    expect(snapshots.getGeneration()).toBe(1);
+    snapshots.endWrite();
+    expect(snapshots.getGeneration()).toBe(2);
+  });
+
+  it('should adjust search results and reference counts', async () => {
+    snapshots.beginWrite();
+    await write(A, shard(A, [definition(A, 'Loader')], [reference(A, 'Save'), reference(A, 'Save')]));
+
+    const stored = [...shards.values()].flatMap(s => s.symbols).filter(symbol => symbol.name.toLowerCase().includes('load'));
+    expect(snapshots.search(stored, 'load', 10).map(symbol => symbol.name)).toEqual(['Load']);
+    expect(snapshots.withId(storedDefinitions('Loader'), `${A}#Load`).map(symbol => symbol.name)).toEqual(['Load']);
+    expect(snapshots.referenceCounts(new Map([['Save', 2]]), ['Save'])).toEqual(new Map([['Save', 1]]));
+
+    snapshots.endWrite();
+    expect(snapshots.referenceCounts(new Map([['Save', 2]]), ['Save'])).toEqual(new Map([['Save', 2]]));
+  });
+});
@@ -0,0 +1,285 @@
+import { FileIndexData } from '../storage/IIndexStorage.js';
+import { IndexedReference, IndexedSymbol } from '../types.js';
+
+/**
+ * In-memory summary of an indexed file, as BackgroundIndex keeps it.
+ */
+export interface FileSummary {
+  hash: string;
+  lastIndexedAt: number;
+  symbolCount: number;
+  mtime?: number;
+}
+
+/**
+ * A file as the published generation has it; null/undefined when the
+ * file was not in it.
+ */
+export interface PublishedFile {
+  shard: FileIndexData | null;
+  summary?: FileSummary;
+}
+
+/**
+ * IndexSnapshots - copy-on-write generations of the background index.
+ *
+ * Writers go straight to storage, so a re-index run is persisted as it
+ * goes and a crash or cancellation loses nothing. Readers must not see
+ * that run half-done, though: while writers are active, the first write
+ * to a file saves the file as the published generation had it (its
+ * "preimage"), and reads of such files are answered from the preimage.
+ * Name lookups over storage drop the rows of written files and add the
+ * matches from their preimages.
+ *
+ * When the last writer ends, the preimages are dropped in one step and
+ * the generation number goes up: readers switch from the old generation
+ * to the new one between two reads, never in the middle of a run.
+ *
+ * Only written files are copied, so memory grows with the size of the
+ * run, not of the index.
+ */
+export class IndexSnapshots {
+  private generation = 0;
+  private writers = 0;
+  private preimages: Map<string, PublishedFile> = new Map();
+  /** Non-local reference counts per name of what writers stored, per file */
+  private written: Map<string, Map<string, number>> = new Map();
+  private publishing: Map<string, Promise<void>> = new Map();
+
+  /**
+   * The published generation; goes up each time a set of writes becomes visible.
+   */
+  getGeneration(): number {
+    return this.generation;
+  }
+
+  /**
+   * Whether writes are waiting to be published.
+   */
+  isWriting(): boolean {
+    return this.writers > 0;
+  }
+
+  /**
+   * Number of files readers see as they were before the pending writes.
+   */
+  getPendingFileCount(): number {
+    return this.preimages.size;
+  }
+
+  /**
+   * Start a writer. Writers running at the same time share a generation,
+   * published when the last one ends.
+   */
+  beginWrite(): void {
+    this.writers++;
+  }
+
+  /**
+   * End a writer; publishes the pending writes when it was the last one.
+   * Returns the generation readers now see.
+   */
+  endWrite(): number {
+    if (this.writers === 0) {
+      return this.generation;
+    }
+    this.writers--;
+    if (this.writers === 0 && (this.preimages.size > 0 || this.written.size > 0)) {
+      this.preimages = new Map();
+      this.written = new Map();
+      this.generation++;
+    }
+    return this.generation;
+  }
+
+  /**
+   * Note a change made outside writers, such as a file stored by a first
+   * indexing run or a cleared index. Readers see it right away, so it is
+   * a generation of its own.
+   */
+  publishDirectWrite(): void {
+    if (this.writers === 0) {
+      this.generation++;
+    }
+  }
+
+  /**
+   * Save a file as readers see it before a writer changes it. Only the
+   * first call per file and generation reads it; outside writers this
+   * does nothing, as writes are published right away.
+   */
+  async preserve(uri: string, read: () => Promise<PublishedFile>): Promise<void> {
+    if (this.writers === 0 || this.preimages.has(uri)) {
+      return;
+    }
+    // Concurrent writers of one file wait for the same read
+    let pending = this.publishing.get(uri);
+    if (!pending) {
+      pending = read().then(file => {
+        if (this.writers > 0 && !this.preimages.has(uri)) {
+          this.preimages.set(uri, { shard: file.shard, ...(file.summary ? { summary: { ...file.summary } } : {}) });
+        }
+      }).finally(() => this.publishing.delete(uri));
+      this.publishing.set(uri, pending);
+    }
+    await pending;
+  }
+
+  /**
+   * Record the references a writer stored for a preserved file, so
+   * reference counts can leave them out until they are published.
+   */
+  recordWrite(uri: string, references: IndexedReference[]): void {
+    if (this.writers === 0 || !this.preimages.has(uri)) {
+      return;
+    }
+    this.written.set(uri, countNonLocal(references));
+  }
+
+  /**
+   * The published state of a file with pending writes; undefined when
+   * storage has the published state.
+   */
+  published(uri: string): PublishedFile | undefined {
+    return this.preimages.get(uri);
+  }
+
+  /**
+   * Published summaries, given the live ones.
+   */
+  *summaries(live: Map<string, FileSummary>): IterableIterator<[string, FileSummary]> {
+    for (const [uri, summary] of live) {
+      if (!this.preimages.has(uri)) {
+        yield [uri, summary];
+      }
+    }
+    for (const [uri, file] of this.preimages) {
+      if (file.summary) {
+        yield [uri, file.summary];
+      }
+    }
+  }
+
+  /**
+   * Published summary of a file, given the live one.
+   */
+  summary(uri: string, live: FileSummary | undefined): FileSummary | undefined {
+    const file = this.preimages.get(uri);
+    return file ? file.summary : live;
+  }
+
+  /**
+   * Definitions from storage, as of the published generation.
+   */
+  definitions(stored: IndexedSymbol[], name: string): IndexedSymbol[] {
+    return this.merge(stored, shard => shard.symbols.filter(symbol => symbol.name === name && symbol.isDefinition));
+  }
+
+  /**
+   * Symbols found by ID in storage, as of the published generation.
+   */
+  withId(stored: IndexedSymbol[], id: string): IndexedSymbol[] {
+    return this.merge(stored, shard => shard.symbols.filter(symbol => symbol.id === id));
+  }
+
+  /**
+   * References from storage, as of the published generation.
+   */
+  references(stored: IndexedReference[], name: string): IndexedReference[] {
+    if (this.preimages.size === 0) {
+      return stored;
+    }
+    const result = stored.filter(ref => !this.preimages.has(ref.location.uri));
+    for (const file of this.preimages.values()) {
+      result.push(...(file.shard?.references ?? []).filter(ref => ref.symbolName === name));
+    }
+    return result;
+  }
+
+  /**
+   * references() over batches read from storage as they are consumed.
+   * Preimages are taken when the stream starts: a stream that outlives
+   * the generation it started in can see rows of the writes that follow.
+   */
+  async *streamReferences(
+    stored: AsyncIterable<IndexedReference[]> | Iterable<IndexedReference[]>,
+    name: string
+  ): AsyncIterable<IndexedReference[]> {
+    const preimages = new Map(this.preimages);
+    for await (const batch of stored) {
+      const refs = preimages.size === 0 ? batch : batch.filter(ref => !preimages.has(ref.location.uri));
+      if (refs.length > 0) {
+        yield refs;
+      }
+    }
+    for (const file of preimages.values()) {
+      const refs = (file.shard?.references ?? []).filter(ref => ref.symbolName === name);
+      if (refs.length > 0) {
+        yield refs;
+      }
+    }
+  }
+
+  /**
+   * Search results from storage, as of the published generation. Preimage
+   * symbols match by case-insensitive substring, as storage's ranking
+   * cannot be repeated here; they come after the stored results.
+   */
+  search(stored: IndexedSymbol[], query: string, limit: number): IndexedSymbol[] {
+    const text = query.toLowerCase();
+    return this.merge(stored, shard => shard.symbols.filter(symbol => symbol.name.toLowerCase().includes(text))).slice(0, limit);
+  }
+
+  /**
+   * Reference counts from storage, as of the published generation.
+   */
+  referenceCounts(stored: Map<string, number>, names: string[]): Map<string, number> {
+    if (this.preimages.size === 0) {
+      return stored;
+    }
+    const counts = new Map(stored);
+    const wanted = new Set(names);
+    const add = (perName: Map<string, number>, sign: number) => {
+      for (const [name, count] of perName) {
+        if (wanted.has(name)) {
+          counts.set(name, (counts.get(name) ?? 0) + sign * count);
+        }
+      }
+    };
+    for (const perName of this.written.values()) {
+      add(perName, -1);
+    }
+    for (const file of this.preimages.values()) {
+      add(countNonLocal(file.shard?.references ?? []), 1);
+    }
+    for (const [name, count] of counts) {
+      if (count <= 0) {
+        counts.delete(name);
+      }
+    }
+    return counts;
+  }
+
+  private merge(stored: IndexedSymbol[], matches: (shard: FileIndexData) => IndexedSymbol[]): IndexedSymbol[] {
+    if (this.preimages.size === 0) {
+      return stored;
+    }
+    const result = stored.filter(symbol => !this.preimages.has(symbol.location.uri));
+    for (const file of this.preimages.values()) {
+      if (file.shard) {
+        result.push(...matches(file.shard));
+      }
+    }
+    return result;
+  }
+}
+
+function countNonLocal(references: IndexedReference[]): Map<string, number> {
+  const counts = new Map<string, number>();
+  for (const ref of references) {
+    if (!ref.isLocal) {
+      counts.set(ref.symbolName, (counts.get(ref.symbolName) ?? 0) + 1);
+    }
+  }
+  return counts;
+}
@@ -8,6 +8,8 @@ import {
   PendingReference,
   ImportInfo,
   ReExportInfo,
+  CodeTag,
+  ParseDiagnostic,
   CompactShard,
   compactSymbol,
   compactReference,
@@ -17,6 +19,7 @@ import {
   hydratePendingRef,
   SHARD_VERSION
 } from '../types.js';
+import { writeFileAtomic } from '../utils/atomicWrite.js';
 
 /**
  * Represents a single shard (per-file index) in memory (hydrated format).
@@ -29,6 +32,8 @@ export interface FileShard {
   imports: ImportInfo[];
   reExports?: ReExportInfo[];
   pendingReferences?: PendingReference[];
+  tags?: CodeTag[];
+  diagnostics?: ParseDiagnostic[];
   lastIndexedAt: number;
   shardVersion?: number;
   mtime?: number;
@@ -185,7 +190,7 @@ interface PendingWrite {
  * - Using short field names
  * - Using numeric scope indices instead of repeated strings
  */
-function toCompactShard(shard: FileShard): CompactShard {
+export function toCompactShard(shard: FileShard): CompactShard {
   // Build scope table for reference deduplication
   const scopeTable = new Map<string, number>();
   
@@ -216,6 +221,12 @@ function toCompactShard(shard: FileShard): CompactShard {
   if (scopeArray.length > 0) {
     compact.sc = scopeArray;
   }
+  if (shard.tags && shard.tags.length > 0) {
+    compact.tg = shard.tags;
+  }
+  if (shard.diagnostics && shard.diagnostics.length > 0) {
+    compact.dg = shard.diagnostics;
+  }
   if (shard.mtime !== undefined) {
     compact.m = shard.mtime;
   }
@@ -227,7 +238,7 @@ function toCompactShard(shard: FileShard): CompactShard {
  * Type guard to validate decoded MessagePack data is a valid CompactShard.
  * Prevents unsafe casting of arbitrary data.
  */
-function isCompactShard(obj: unknown): obj is CompactShard {
+export function isCompactShard(obj: unknown): obj is CompactShard {
   if (typeof obj !== 'object' || obj === null) {
     return false;
   }
@@ -263,7 +274,7 @@ function isLegacyFileShard(obj: unknown): obj is FileShard {
 /**
  * Hydrate a compact shard from storage to full FileShard format.
  */
-function fromCompactShard(compact: CompactShard): FileShard {
+export function fromCompactShard(compact: CompactShard): FileShard {
   const uri = compact.u;
   const scopeTable = compact.sc || [];
   
@@ -275,6 +286,8 @@ function fromCompactShard(compact: CompactShard): FileShard {
     imports: compact.i,
     reExports: compact.re,
     pendingReferences: compact.pr?.map(pr => hydratePendingRef(pr, uri)),
+    tags: compact.tg,
+    diagnostics: compact.dg,
     lastIndexedAt: compact.t,
     shardVersion: compact.v,
     mtime: compact.m
@@ -512,7 +525,7 @@ export class ShardPersistenceManager implements IShardPersistence {
         await fsPromises.mkdir(shardDir, { recursive: true });
         const compact = toCompactShard(shard);
         const encoded = encode(compact);
-        await fsPromises.writeFile(binPath, encoded);
+        await writeFileAtomic(binPath, encoded);
         
         // Remove legacy JSON file
         await fsPromises.unlink(jsonPath);
@@ -576,7 +589,7 @@ export class ShardPersistenceManager implements IShardPersistence {
       // Convert to compact format before saving
       const compact = toCompactShard(shard);
       const encoded = encode(compact);
-      await fsPromises.writeFile(shardPath, encoded);
+      await writeFileAtomic(shardPath, encoded);
     } catch (error) {
       console.error(`[ShardPersistenceManager] Error saving shard for ${shard.uri}: ${error}`);
       throw error;
@@ -880,7 +893,7 @@ export class ShardPersistenceManager implements IShardPersistence {
       };
       
       const metadataPath = this.getMetadataPath();
-      await fsPromises.writeFile(metadataPath, JSON.stringify(summary), 'utf-8');
+      await writeFileAtomic(metadataPath, JSON.stringify(summary));
       this.metadataDirty = false;
       
       console.info(`[ShardPersistenceManager] Saved metadata summary: ${summary.entries.length} entries`);
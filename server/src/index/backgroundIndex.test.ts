@@ -0,0 +1,77 @@
+/**
+ * BackgroundIndex Tests
+ *
+ * Verifies when a file with cached metadata is re-indexed: an unchanged
+ * mtime skips it, and a changed mtime falls back to the content hash.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { BackgroundIndex } from './backgroundIndex.js';
+import { IIndexStorage } from '../storage/IIndexStorage.js';
+
+describe('BackgroundIndex', () => {
+  let dir: string;
+  let file: string;
+  let index: BackgroundIndex;
+  let updatedMetadata: any[];
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'background-index-'));
+    file = path.join(dir, 'user.go');
+    fs.writeFileSync(file, 'package user\n');
+    updatedMetadata = [];
+    const storage = { updateMetadata: async (metadata: unknown) => { updatedMetadata.push(metadata); } } as unknown as IIndexStorage;
+    index = new BackgroundIndex(undefined as any, storage, undefined as any, undefined as any);
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  function cache(metadata: { hash: string; mtime?: number }): void {
+    (index as any).fileMetadata.set(file, { symbolCount: 3, lastIndexedAt: 1, ...metadata });
+  }
+
+  function needsReindexing(computeHash?: (uri: string) => Promise<string>): Promise<boolean> {
+    return (index as any).needsReindexing(file, computeHash);
+  }
+
+  it('should skip files whose mtime is unchanged without hashing them', async () => {
+    cache({ hash: 'abc', mtime: fs.statSync(file).mtimeMs });
+    let hashed = 0;
+    expect(await needsReindexing(async () => { hashed++; return 'other'; })).toBe(false);
+    expect(hashed).toBe(0);
+  });
+
+  it('should skip files whose mtime changed but whose content hash is equal', async () => {
+    cache({ hash: 'abc', mtime: fs.statSync(file).mtimeMs - 1000 });
+    expect(await needsReindexing(async () => 'abc')).toBe(false);
+
+    // The new mtime is remembered, so the next check is stat-only
+    const mtime = fs.statSync(file).mtimeMs;
+    expect(updatedMetadata).toEqual([{ uri: file, hash: 'abc', mtime, symbolCount: 3, lastIndexedAt: 1 }]);
+    expect(await needsReindexing(async () => { throw new Error('hashed again'); })).toBe(false);
+    expect((index as any).unchangedByHashCount).toBe(1);
+  });
+
+  it('should re-index files whose mtime and content hash changed', async () => {
+    cache({ hash: 'abc', mtime: fs.statSync(file).mtimeMs - 1000 });
+    expect(await needsReindexing(async () => 'def')).toBe(true);
+    expect(updatedMetadata).toEqual([]);
+  });
+
+  it('should re-index without a hash to compare, and files never indexed or gone', async () => {
+    cache({ hash: 'abc', mtime: fs.statSync(file).mtimeMs - 1000 });
+    expect(await needsReindexing()).toBe(true);
+    expect(await needsReindexing(async () => { throw new Error('EACCES'); })).toBe(true);
+
+    (index as any).fileMetadata.clear();
+    expect(await needsReindexing(async () => 'abc')).toBe(true);
+    cache({ hash: 'abc' });
+    fs.rmSync(file);
+    expect(await needsReindexing(async () => 'abc')).toBe(true);
+  });
+});
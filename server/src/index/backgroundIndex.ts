@@ -1,5 +1,5 @@
 import { ISymbolIndex } from './ISymbolIndex.js';
-import { IndexedSymbol, IndexedFileResult, IndexedReference, ImportInfo, ReExportInfo, PendingReference, SHARD_VERSION } from '../types.js';
+import { IndexedSymbol, IndexedFileResult, IndexedReference, ImportInfo, ReExportInfo, PendingReference, CodeTag, SHARD_VERSION } from '../types.js';
 import { SymbolIndexer } from '../indexer/symbolIndexer.js';
 import { LanguageRouter } from '../indexer/languageRouter.js';
 import { sanitizeFilePath, toCamelCase, toPascalCase } from '../utils/stringUtils.js';
@@ -10,6 +10,10 @@ import { NgRxLinkResolver } from './resolvers/NgRxLinkResolver.js';
 import { IndexScheduler, ProgressCallback } from './IndexScheduler.js';
 import { STORAGE_CONFIG, INDEXING_STATE, LOG_PREFIX } from '../constants.js';
 import { ILogger, NullLogger } from '../utils/Logger.js';
+import { CancellationToken, CancellationError, throwIfCancelled } from '../utils/asyncUtils.js';
+import { IndexingProgress } from '../utils/indexingProgress.js';
+import { IndexSnapshots, PublishedFile } from './IndexSnapshots.js';
+import { IndexThrottle } from './indexThrottle.js';
 import * as fsPromises from 'fs/promises';
 import { performance } from 'perf_hooks';
 
@@ -34,6 +38,8 @@ export { ProgressCallback } from './IndexScheduler.js';
  * - Lazily loads shards from disk when needed
  * - Performs incremental updates (only re-indexes changed files)
  * - Supports parallel indexing with a worker pool
+ * - Answers queries from the last published generation while a run
+ *   writes the next one (see IndexSnapshots.ts)
  */
 export class BackgroundIndex implements ISymbolIndex {
   private configManager: ConfigurationManager | null = null;
@@ -48,6 +54,12 @@ export class BackgroundIndex implements ISymbolIndex {
   // Specialized resolver for NgRx action group references
   private ngrxResolver: NgRxLinkResolver;
 
+  // Files whose mtime changed but content hash did not (reset per ensureUpToDate run)
+  private unchangedByHashCount: number = 0;
+
+  // Published generation readers see while runs write the next one
+  private snapshots = new IndexSnapshots();
+
   /**
    * Create a BackgroundIndex with injected dependencies.
    * 
@@ -77,6 +89,20 @@ export class BackgroundIndex implements ISymbolIndex {
     this.scheduler.setProgressCallback(callback);
   }
 
+  /**
+   * Counts, throughput and ETA of the running index update (or the last one).
+   */
+  getIndexingProgress(): IndexingProgress {
+    return this.scheduler.getProgress();
+  }
+
+  /**
+   * Number of files that failed to parse since startup.
+   */
+  getParseErrorCount(): number {
+    return this.scheduler.getParseErrorCount();
+  }
+
   /**
    * Set the language router for multi-language indexing
    */
@@ -100,6 +126,14 @@ export class BackgroundIndex implements ISymbolIndex {
     this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Updated max concurrent jobs to ${max}`);
   }
 
+  /**
+   * Throttle indexing while the user works (see index/indexThrottle.ts):
+   * watched files and incremental updates, and full runs in mode always.
+   */
+  setThrottle(throttle: IndexThrottle | null): void {
+    this.scheduler.setThrottle(throttle);
+  }
+
   /**
    * Initialize the background index.
    * Validates shard version and clears incompatible cache.
@@ -113,12 +147,13 @@ export class BackgroundIndex implements ISymbolIndex {
     if (!isCompatible) {
       this.logger.warn(`${LOG_PREFIX.BACKGROUND_INDEX} Shard version mismatch detected. Current version: ${SHARD_VERSION}. Clearing incompatible cache...`);
       await this.clearAllShards();
-      console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Cache cleared. Full re-indexing will be triggered.`);
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Cache cleared. Full re-indexing will be triggered.`);
     }
     
-    console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Initialized (concurrency managed by IndexScheduler)`);
+    this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Initialized (concurrency managed by IndexScheduler)`);
 
     await this.loadShardMetadata();
+    this.snapshots.publishDirectWrite();
   }
 
   /**
@@ -170,8 +205,9 @@ export class BackgroundIndex implements ISymbolIndex {
       
       // Clear disk storage
       await this.storage.clear();
+      this.snapshots.publishDirectWrite();
       
-      console.info(`${LOG_PREFIX.BACKGROUND_INDEX} All shards cleared successfully`);
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} All shards cleared successfully`);
     } catch (error) {
       this.logger.error(`${LOG_PREFIX.BACKGROUND_INDEX} Error clearing shards: ${error}`);
       throw error;
@@ -232,7 +268,7 @@ export class BackgroundIndex implements ISymbolIndex {
     }
     
     const duration = Date.now() - startTime;
-    console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Loaded ${loadedShards} shards from metadata summary in ${duration}ms`);
+    this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Loaded ${loadedShards} shards from metadata summary in ${duration}ms`);
   }
 
   /**
@@ -294,7 +330,7 @@ export class BackgroundIndex implements ISymbolIndex {
     // Save metadata summary for fast startup next time
     await this.storage.saveMetadataSummary();
     
-    console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Loaded metadata from ${loadedShards} shards (scanned)`);
+    this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Loaded metadata from ${loadedShards} shards (scanned)`);
   }
 
   /**
@@ -328,6 +364,36 @@ export class BackgroundIndex implements ISymbolIndex {
     return shard;
   }
 
+  /**
+   * The shard of the published generation: the preimage of a file with
+   * pending writes, the stored shard otherwise.
+   */
+  private async readShard(uri: string): Promise<FileShard | null> {
+    const published = this.snapshots.published(uri);
+    return published ? published.shard : this.loadShard(uri);
+  }
+
+  /**
+   * Save a file's published state before a writer changes it.
+   * Pass locked when the caller holds the storage lock of the file.
+   */
+  private async preserve(uri: string, locked: boolean = false): Promise<void> {
+    await this.snapshots.preserve(uri, async (): Promise<PublishedFile> => {
+      const shard = this.shardCache.get(uri) ?? (locked ? await this.storage.getFileNoLock(uri) : await this.storage.getFile(uri));
+      const summary = this.fileMetadata.get(uri);
+      return { shard, ...(summary ? { summary } : {}) };
+    });
+  }
+
+  /**
+   * The published generation: it goes up each time a re-index run (or a
+   * single file update) becomes visible to queries. Writes of a first
+   * indexing run are visible as they are stored, so each is one.
+   */
+  getGeneration(): number {
+    return this.snapshots.getGeneration();
+  }
+
   /**
    * Load a shard with cache metrics tracking (for forensic tracing).
    * Returns shard along with timing and cache hit/miss info.
@@ -380,6 +446,19 @@ export class BackgroundIndex implements ISymbolIndex {
    * OPTIMIZED: Uses O(1) reverse indexes for cleanup instead of O(N) scans.
    */
   async updateFile(uri: string, result: IndexedFileResult): Promise<void> {
+    // Outside a run the update is a generation of its own; runs publish theirs when done
+    if (this.scheduler.isBulkIndexing()) {
+      return this.writeFile(uri, result);
+    }
+    this.snapshots.beginWrite();
+    try {
+      await this.writeFile(uri, result);
+    } finally {
+      this.snapshots.endWrite();
+    }
+  }
+
+  private async writeFile(uri: string, result: IndexedFileResult): Promise<void> {
     // Capture pending references before the lock to avoid race conditions
     // where file state changes between update and resolution
     const pendingRefs = result.pendingReferences && result.pendingReferences.length > 0
@@ -388,6 +467,9 @@ export class BackgroundIndex implements ISymbolIndex {
 
     // Wrap entire operation in lock to prevent race conditions
     await this.storage.withLock(uri, async () => {
+      await this.preserve(uri, true);
+      this.snapshots.recordWrite(uri, result.references || []);
+
       // CRITICAL: Invalidate cache INSIDE lock to prevent stale cache repopulation
       this.shardCache.delete(uri);
       
@@ -408,6 +490,8 @@ export class BackgroundIndex implements ISymbolIndex {
         imports: result.imports || [],
         reExports: result.reExports || [],
         pendingReferences: result.pendingReferences,
+        tags: result.tags,
+        diagnostics: result.diagnostics,
         lastIndexedAt: Date.now(),
         shardVersion: SHARD_VERSION,
         mtime
@@ -425,6 +509,7 @@ export class BackgroundIndex implements ISymbolIndex {
 
       // Save shard to disk - use NoLock variant since we already hold the lock
       await this.storage.storeFileNoLock(shard);
+      this.snapshots.publishDirectWrite();
       
       // Update metadata cache for fast startup
       await this.storage.updateMetadata({
@@ -484,13 +569,13 @@ export class BackgroundIndex implements ISymbolIndex {
           const camelMember = toCamelCase(pending.member);
           if (camelMember in events) {
             matchedMember = camelMember;
-            console.info(`[BackgroundIndex] NgRx camelCase fallback: ${pending.member} -> ${camelMember}`);
+            this.logger.debug(`[BackgroundIndex] NgRx camelCase fallback: ${pending.member} -> ${camelMember}`);
           } else {
             // Fallback 2: Try PascalCase version (e.g., 'load' -> 'Load')
             const pascalMember = toPascalCase(pending.member);
             if (pascalMember in events) {
               matchedMember = pascalMember;
-              console.info(`${LOG_PREFIX.BACKGROUND_INDEX} NgRx PascalCase fallback: ${pending.member} -> ${pascalMember}`);
+              this.logger.debug(`${LOG_PREFIX.BACKGROUND_INDEX} NgRx PascalCase fallback: ${pending.member} -> ${pascalMember}`);
             }
           }
         }
@@ -542,6 +627,7 @@ export class BackgroundIndex implements ISymbolIndex {
             
             // CRITICAL: Use storeFileNoLock to avoid nested lock acquisition
             await this.storage.storeFileNoLock(sourceShard);
+            this.snapshots.publishDirectWrite();
           }
         });
 
@@ -551,7 +637,7 @@ export class BackgroundIndex implements ISymbolIndex {
     }
 
     if (resolvedCount > 0) {
-      console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Resolved ${resolvedCount} NgRx cross-file references from ${sourceUri}`);
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Resolved ${resolvedCount} NgRx cross-file references from ${sourceUri}`);
     }
   }
 
@@ -563,17 +649,25 @@ export class BackgroundIndex implements ISymbolIndex {
    */
   async updateSingleFile(rawFilePath: string): Promise<void> {
     const filePath = sanitizeFilePath(rawFilePath);
-    
-    // Cleanup old entries first
-    const hadExistingEntry = this.fileMetadata.has(filePath);
-    if (hadExistingEntry) {
-      this.cleanupFileFromIndexes(filePath);
+
+    // Queries keep the old entry until the new one is written
+    this.snapshots.beginWrite();
+    try {
+      await this.preserve(filePath);
+
+      // Cleanup old entries first
+      const hadExistingEntry = this.fileMetadata.has(filePath);
+      if (hadExistingEntry) {
+        this.cleanupFileFromIndexes(filePath);
+      }
+
+      // Delegate to scheduler
+      await this.scheduler.scheduleSingle(filePath, async (uri, result) => {
+        await this.updateFile(uri, result);
+      });
+    } finally {
+      this.snapshots.endWrite();
     }
-    
-    // Delegate to scheduler
-    await this.scheduler.scheduleSingle(filePath, async (uri, result) => {
-      await this.updateFile(uri, result);
-    });
   }
 
   /**
@@ -593,14 +687,22 @@ export class BackgroundIndex implements ISymbolIndex {
    * OPTIMIZED: Uses O(1) reverse indexes instead of O(N) scans.
    */
   async removeFile(uri: string): Promise<void> {
-    // CRITICAL: Invalidate cache to prevent stale reads
-    this.shardCache.delete(uri);
-    this.fileMetadata.delete(uri);
-    
-    await this.deleteShard(uri);
-    
-    // Update metadata cache
-    await this.storage.removeMetadata(uri);
+    this.snapshots.beginWrite();
+    try {
+      await this.preserve(uri);
+      this.snapshots.recordWrite(uri, []);
+
+      // CRITICAL: Invalidate cache to prevent stale reads
+      this.shardCache.delete(uri);
+      this.fileMetadata.delete(uri);
+      
+      await this.deleteShard(uri);
+      
+      // Update metadata cache
+      await this.storage.removeMetadata(uri);
+    } finally {
+      this.snapshots.endWrite();
+    }
   }
 
   /**
@@ -612,34 +714,63 @@ export class BackgroundIndex implements ISymbolIndex {
   }
 
   /**
-   * Check if file needs reindexing based on mtime.
+   * Check if file needs reindexing based on mtime and content hash.
    * Returns true if file should be indexed (cache miss or stale).
    * 
+   * Two-stage check:
+   * 1. mtime matches the stored value -> unchanged (no I/O beyond stat)
+   * 2. mtime differs (e.g. git checkout, touch) -> hash the content and compare
+   *    with the stored hash; if equal, refresh the stored mtime and skip parsing
+   * 
    * ASYNC: Uses fsPromises.stat to avoid blocking the event loop.
    */
-  private async needsReindexing(uri: string): Promise<boolean> {
+  private async needsReindexing(
+    uri: string,
+    computeHash?: (uri: string) => Promise<string>
+  ): Promise<boolean> {
     const metadata = this.fileMetadata.get(uri);
     if (!metadata) {
       return true; // No cache entry
     }
 
-    // If no mtime stored, fall back to hash-based check
-    if (!metadata.mtime) {
+    let currentMtime: number;
+    try {
+      const stats = await fsPromises.stat(uri);
+      currentMtime = stats.mtimeMs;
+    } catch (error) {
+      // File might not exist anymore
+      return true;
+    }
+
+    // If mtime matches, file is unchanged
+    if (metadata.mtime && currentMtime === metadata.mtime) {
+      return false;
+    }
+
+    // mtime changed or was never stored - fall back to content hash comparison
+    if (!computeHash || !metadata.hash) {
       return true;
     }
 
     try {
-      const stats = await fsPromises.stat(uri);
-      const currentMtime = stats.mtimeMs;
-      
-      // If mtime matches, file is unchanged
-      if (currentMtime === metadata.mtime) {
-        return false;
+      const currentHash = await computeHash(uri);
+      if (currentHash !== metadata.hash) {
+        return true;
       }
-      
-      return true;
+
+      // Content is identical - remember the new mtime so the next check is stat-only
+      metadata.mtime = currentMtime;
+      await this.storage.updateMetadata({
+        uri,
+        hash: metadata.hash,
+        mtime: currentMtime,
+        symbolCount: metadata.symbolCount,
+        lastIndexedAt: metadata.lastIndexedAt
+      });
+      this.unchangedByHashCount++;
+      return false;
     } catch (error) {
-      // File might not exist anymore
+      // Unreadable file - let the worker report the skip reason
       return true;
     }
   }
@@ -647,15 +778,17 @@ export class BackgroundIndex implements ISymbolIndex {
   /**
    * Get file info for a URI.
    */
-  getFileInfo(uri: string): { uri: string; hash: string; lastIndexedAt: number } | undefined {
-    const metadata = this.fileMetadata.get(uri);
+  getFileInfo(uri: string): { uri: string; hash: string; lastIndexedAt: number; mtime?: number; symbolCount: number } | undefined {
+    const metadata = this.snapshots.summary(uri, this.fileMetadata.get(uri));
     if (!metadata) {
       return undefined;
     }
     return {
       uri,
       hash: metadata.hash,
-      lastIndexedAt: metadata.lastIndexedAt
+      lastIndexedAt: metadata.lastIndexedAt,
+      mtime: metadata.mtime,
+      symbolCount: metadata.symbolCount
     };
   }
 
@@ -663,28 +796,28 @@ export class BackgroundIndex implements ISymbolIndex {
    * Get all indexed file URIs.
    */
   getAllFileUris(): string[] {
-    return Array.from(this.fileMetadata.keys());
+    if (!this.snapshots.isWriting()) {
+      return Array.from(this.fileMetadata.keys());
+    }
+    return Array.from(this.snapshots.summaries(this.fileMetadata), ([uri]) => uri);
   }
 
   async findDefinitions(name: string): Promise<IndexedSymbol[]> {
-    return this.storage.findDefinitionsInSql(name);
+    return this.snapshots.definitions(await this.storage.findDefinitionsInSql(name), name);
   }
 
   async findDefinitionById(symbolId: string): Promise<IndexedSymbol | null> {
     // SQL symbols have IDs too, but they are stored in the symbols table
     // For now, we can use a simple SQL query if we wanted to be fully off-memory
     const results = await this.storage.searchSymbols(symbolId, 'exact'); // ID search would be better
-    if (results.length > 0) {
-      return results[0].symbol;
-    }
-    return null;
+    return this.snapshots.withId(results.map(r => r.symbol), symbolId)[0] ?? null;
   }
 
   async findReferences(name: string): Promise<IndexedSymbol[]> {
     // findReferences in BackgroundIndex currently returns the *definitions* of symbols 
     // that MATCH the name. This seems a bit confusing in the original code.
     // Let's stick to delegating to SQL for definitions.
-    return this.storage.findDefinitionsInSql(name);
+    return this.findDefinitions(name);
   }
 
   async findReferencesById(symbolId: string): Promise<IndexedSymbol[]> {
@@ -694,11 +827,19 @@ export class BackgroundIndex implements ISymbolIndex {
 
   async searchSymbols(query: string, limit: number): Promise<IndexedSymbol[]> {
     const results = await this.storage.searchSymbols(query, 'fuzzy', limit);
-    return results.map(r => r.symbol);
+    return this.snapshots.search(results.map(r => r.symbol), query, limit);
+  }
+
+  /**
+   * Count the non-local references to each name (popularity ranking).
+   */
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    const counts = await this.storage.countReferences([...new Set(names)]);
+    return this.snapshots.referenceCounts(new Map(counts.map(({ name, count }) => [name, count])), names);
   }
 
   async getFileSymbols(uri: string): Promise<IndexedSymbol[]> {
-    const shard = await this.loadShard(uri);
+    const shard = await this.readShard(uri);
     return shard ? shard.symbols : [];
   }
 
@@ -713,7 +854,7 @@ export class BackgroundIndex implements ISymbolIndex {
     name: string,
     options?: { excludeLocal?: boolean; scopeId?: string }
   ): Promise<IndexedReference[]> {
-    const sqlRefs = await this.storage.findReferencesInSql(name);
+    const sqlRefs = this.snapshots.references(await this.storage.findReferencesInSql(name), name);
     
     if (!options?.excludeLocal && !options?.scopeId) {
       return sqlRefs;
@@ -730,11 +871,23 @@ export class BackgroundIndex implements ISymbolIndex {
     });
   }
 
+  /**
+   * findReferencesByName in batches, in the same order, read from storage
+   * as they are consumed, so the first ones can be sent before the last
+   * are read.
+   */
+  async *streamReferencesByName(name: string): AsyncIterable<IndexedReference[]> {
+    const stored = this.storage.streamReferencesInSql
+      ? this.storage.streamReferencesInSql(name)
+      : [await this.storage.findReferencesInSql(name)];
+    yield* this.snapshots.streamReferences(stored, name);
+  }
+
   /**
    * Get import info for a file (for import resolution).
    */
   async getFileImports(uri: string): Promise<ImportInfo[]> {
-    const shard = await this.loadShard(uri);
+    const shard = await this.readShard(uri);
     return shard?.imports || [];
   }
 
@@ -742,20 +895,61 @@ export class BackgroundIndex implements ISymbolIndex {
    * Get re-export info for a file (for barrel file resolution).
    */
   async getFileReExports(uri: string): Promise<ReExportInfo[]> {
-    const shard = await this.loadShard(uri);
+    const shard = await this.readShard(uri);
     return shard?.reExports || [];
   }
 
+  /**
+   * Get the classifications of a file (generated, test, mock, example).
+   */
+  async getFileTags(uri: string): Promise<CodeTag[]> {
+    const shard = await this.readShard(uri);
+    return shard?.tags || [];
+  }
+
   /**
    * Ensure all files are up to date.
    * Delegates orchestration to IndexScheduler.
+   *
+   * On cancellation, files already being parsed are still written and the
+   * metadata summary is saved, so the persisted index matches the shards on
+   * disk; the remaining files are picked up by the next run. Finalization is
+   * skipped (it resolves across all files, so the next run catches up) and
+   * CancellationError is thrown.
    */
   async ensureUpToDate(
     allFiles: string[],
-    _computeHash: (uri: string) => Promise<string>,
-    onProgress?: (current: number, total: number) => void
+    computeHash: (uri: string) => Promise<string>,
+    onProgress?: (current: number, total: number) => void,
+    cancellationToken?: CancellationToken
+  ): Promise<void> {
+    await this.bringUpToDate(allFiles, computeHash, onProgress, cancellationToken, true);
+  }
+
+  /**
+   * Bring the given files up to date as ensureUpToDate does, but leave the
+   * other indexed files alone instead of dropping them as stale: for
+   * incremental updates, e.g. the files a git diff reports as changed.
+   */
+  async updateFiles(
+    files: string[],
+    computeHash: (uri: string) => Promise<string>,
+    onProgress?: (current: number, total: number) => void,
+    cancellationToken?: CancellationToken
+  ): Promise<void> {
+    await this.bringUpToDate(files, computeHash, onProgress, cancellationToken, false);
+  }
+
+  private async bringUpToDate(
+    allFiles: string[],
+    computeHash: (uri: string) => Promise<string>,
+    onProgress: ((current: number, total: number) => void) | undefined,
+    cancellationToken: CancellationToken | undefined,
+    removeStale: boolean
   ): Promise<void> {
+    throwIfCancelled(cancellationToken);
     let excluded = 0;
+    this.unchangedByHashCount = 0;
 
     // Apply exclusion filters
     const filteredFiles = allFiles.filter(uri => {
@@ -767,30 +961,86 @@ export class BackgroundIndex implements ISymbolIndex {
     });
 
     if (excluded > 0) {
-      console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Excluded ${excluded} files from indexing (build artifacts, node_modules, etc.)`);
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Excluded ${excluded} files from indexing (build artifacts, node_modules, etc.)`);
     }
 
+    // An index being built for the first time has no generation to keep
+    const isolated = this.fileMetadata.size > 0;
+    if (isolated) {
+      this.snapshots.beginWrite();
+    }
+    try {
+      await this.runUpdate(allFiles, filteredFiles, computeHash, onProgress, cancellationToken, removeStale);
+    } finally {
+      if (isolated) {
+        const previous = this.snapshots.getGeneration();
+        const changed = this.snapshots.getPendingFileCount();
+        const generation = this.snapshots.endWrite();
+        if (generation !== previous) {
+          this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Published index generation ${generation} (${changed} files changed)`);
+        }
+      }
+    }
+  }
+
+  /**
+   * The body of bringUpToDate: stale and excluded files, bulk indexing,
+   * finalization and the metadata summary.
+   */
+  private async runUpdate(
+    allFiles: string[],
+    filteredFiles: string[],
+    computeHash: (uri: string) => Promise<string>,
+    onProgress: ((current: number, total: number) => void) | undefined,
+    cancellationToken: CancellationToken | undefined,
+    removeStale: boolean
+  ): Promise<void> {
     // Remove stale shards (files that no longer exist)
-    const currentFileSet = new Set(allFiles);
-    const staleFiles = this.getAllFileUris().filter(uri => !currentFileSet.has(uri));
-    for (const uri of staleFiles) {
-      await this.removeFile(uri);
+    if (removeStale) {
+      const currentFileSet = new Set(allFiles);
+      const staleFiles = Array.from(this.fileMetadata.keys()).filter(uri => !currentFileSet.has(uri));
+      for (const uri of staleFiles) {
+        await this.removeFile(uri);
+      }
     }
 
     // Clean up previously indexed excluded files
     await this.purgeExcludedFiles();
 
     // Delegate to scheduler for bulk indexing
-    await this.scheduler.scheduleBulk(
-      filteredFiles,
-      (uri) => this.needsReindexing(uri),
-      (uri, result) => this.updateFile(uri, result),
-      onProgress
-    );
+    try {
+      await this.scheduler.scheduleBulk(
+        filteredFiles,
+        (uri) => this.needsReindexing(uri, computeHash),
+        (uri, result) => this.updateFile(uri, result),
+        onProgress,
+        cancellationToken,
+        // Incremental updates (files git reports changed) happen while the user works
+        removeStale ? 'full' : 'watch'
+      );
+    } catch (error) {
+      if (error instanceof CancellationError) {
+        this.scheduler.emitProgress(INDEXING_STATE.IDLE, 0, filteredFiles.length);
+        await this.storage.saveMetadataSummary();
+        this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Background indexing cancelled; index saved in a consistent state.`);
+      }
+      throw error;
+    }
+
+    if (this.unchangedByHashCount > 0) {
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Skipped ${this.unchangedByHashCount} files with new mtime but unchanged content hash`);
+    }
 
     // Finalization phase
     this.scheduler.emitProgress(INDEXING_STATE.FINALIZING, filteredFiles.length, filteredFiles.length);
-    console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Starting finalization phase...`);
+    this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Starting finalization phase...`);
+
+    // Finalization rewrites the references of files with pending NgRx links
+    if (this.snapshots.isWriting()) {
+      for (const uri of await this.storage.findFilesWithPendingRefs()) {
+        await this.preserve(uri);
+      }
+    }
 
     console.time('Finalize');
     await this.finalizeIndexing();
@@ -802,7 +1052,7 @@ export class BackgroundIndex implements ISymbolIndex {
 
     // Emit idle state
     this.scheduler.emitProgress(INDEXING_STATE.IDLE, filteredFiles.length, filteredFiles.length);
-    console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Background indexing completed successfully.`);
+    this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Background indexing completed successfully.`);
 
     // Save metadata and compact
     await this.storage.saveMetadataSummary();
@@ -827,7 +1077,7 @@ export class BackgroundIndex implements ISymbolIndex {
     }
 
     if (filesToPurge.length > 0) {
-      console.info(`${LOG_PREFIX.BACKGROUND_INDEX} Purging ${filesToPurge.length} excluded files from cache`);
+      this.logger.info(`${LOG_PREFIX.BACKGROUND_INDEX} Purging ${filesToPurge.length} excluded files from cache`);
       for (const uri of filesToPurge) {
         await this.removeFile(uri);
       }
@@ -839,13 +1089,13 @@ export class BackgroundIndex implements ISymbolIndex {
    * Delegates to NgRxLinkResolver for NgRx action group resolution.
    */
   async finalizeIndexing(): Promise<void> {
-    console.info(`${LOG_PREFIX.FINALIZE} Starting finalization phase...`);
+    this.logger.info(`${LOG_PREFIX.FINALIZE} Starting finalization phase...`);
     
     // Delegate NgRx resolution to specialized resolver
     // Now uses SQL discovery for high performance
     await this.ngrxResolver.resolveAll();
     
-    console.info(`${LOG_PREFIX.FINALIZE} Complete. ${this.ngrxResolver.getStats()}`);
+    this.logger.info(`${LOG_PREFIX.FINALIZE} Complete. ${this.ngrxResolver.getStats()}`);
   }
 
   /**
@@ -855,14 +1105,16 @@ export class BackgroundIndex implements ISymbolIndex {
     let totalSymbols = 0;
     
     // Sum symbol counts from all files
-    for (const metadata of this.fileMetadata.values()) {
+    let files = 0;
+    for (const [, metadata] of this.snapshots.summaries(this.fileMetadata)) {
       totalSymbols += metadata.symbolCount;
+      files++;
     }
 
     return {
-      files: this.fileMetadata.size,
+      files,
       symbols: totalSymbols,
-      shards: this.fileMetadata.size
+      shards: files
     };
   }
 
@@ -875,6 +1127,7 @@ export class BackgroundIndex implements ISymbolIndex {
 
       this.fileMetadata.clear();
       this.shardCache.clear(); // Clear LRU cache
+      this.snapshots.publishDirectWrite();
       
       // Compact maps to reclaim memory
       this.compact();
@@ -902,7 +1155,7 @@ export class BackgroundIndex implements ISymbolIndex {
     this.shardCache = new Map(this.shardCache);
 
     const duration = Date.now() - startTime;
-    console.info(
+    this.logger.info(
       `${LOG_PREFIX.BACKGROUND_INDEX} Compacted maps in ${duration}ms: ` +
       `files=${beforeSize}`
     );
@@ -926,7 +1179,7 @@ export class BackgroundIndex implements ISymbolIndex {
    * Get the complete file result (symbols, references, imports) for a URI.
    */
   async getFileResult(uri: string): Promise<IndexedFileResult | null> {
-    const shard = await this.loadShard(uri);
+    const shard = await this.readShard(uri);
     if (!shard) {
       return null;
     }
@@ -937,7 +1190,9 @@ export class BackgroundIndex implements ISymbolIndex {
       symbols: shard.symbols,
       references: shard.references,
       imports: shard.imports,
-      reExports: shard.reExports
+      reExports: shard.reExports,
+      tags: shard.tags,
+      diagnostics: shard.diagnostics
     };
   }
 }
@@ -0,0 +1,264 @@
+/**
+ * BinaryIndex Tests
+ *
+ * Round-trips symbols through the .sidx format and checks lookups, lazy
+ * section loading, in-place reads through the page cache, compressed
+ * blocks, byte-identical rewrites and StaticIndex integration.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { BinaryIndex, BinaryIndexWriteOptions, BinaryIndexWriter, isBinaryIndexFile, isCompressionSupported } from './binaryIndex.js';
+import { StaticIndex } from './staticIndex.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { IndexedSymbol } from '../types.js';
+import { ProtoReader, ProtoWriter } from '../utils/protoWire.js';
+
+const service = '/ws/src/user.service.ts';
+const model = '/ws/src/user.ts';
+
+function symbol(overrides: Partial<IndexedSymbol>): IndexedSymbol {
+  const uri = overrides.location?.uri ?? service;
+  return createTestSymbol({ filePath: uri, ...overrides, location: { uri, line: 0, character: 0, ...overrides.location } });
+}
+
+const serviceSymbols = [
+  symbol({
+    id: 'svc',
+    name: 'UserService',
+    kind: 'class',
+    location: { uri: service, line: 3, character: 13 },
+    range: { startLine: 3, startCharacter: 0, endLine: 20, endCharacter: 1 },
+    isExported: true,
+    implements: ['OnInit'],
+    extends: 'BaseService',
+    metadata: { angular: { decorator: 'Injectable' } }
+  }),
+  symbol({
+    id: 'svc.load',
+    canonicalId: 'app/src/user.service:UserService.load#6a0b8f3c',
+    name: 'load',
+    kind: 'method',
+    containerName: 'UserService',
+    containerKind: 'class',
+    fullContainerPath: 'UserService',
+    location: { uri: service, line: 5, character: 2 },
+    range: { startLine: 5, startCharacter: 2, endLine: 7, endCharacter: 3, startOffset: 0, endOffset: 140 },
+    visibility: 'private',
+    isStatic: true,
+    parametersCount: 0,
+    tags: ['generated', 'mock'],
+    doc: 'Loads users, see {@link UserService}.',
+    signature: '(key: K): User[K]',
+    typeParameters: [{ name: 'K', constraint: 'keyof User' }],
+    value: '50',
+    metrics: { complexity: 3, loc: 3, nesting: 0 },
+    deprecated: 'Use loadPage.'
+  })
+];
+
+const modelSymbols = [
+  symbol({ id: 'user', name: 'User', kind: 'interface', location: { uri: model, line: 0, character: 17 }, deprecated: '' }),
+  symbol({ id: 'user.ref', name: 'UserService', kind: 'class', location: { uri: model, line: 9, character: 4 }, isDefinition: false })
+];
+
+describe('BinaryIndex', () => {
+  let testDir: string;
+  let indexPath: string;
+  let index: BinaryIndex | undefined;
+
+  beforeEach(async () => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-sidx-'));
+    indexPath = path.join(testDir, 'index.sidx');
+    const writer = new BinaryIndexWriter();
+    writer.addFile(service, serviceSymbols);
+    writer.addFile(model, modelSymbols);
+    await writer.finish(indexPath);
+  });
+
+  /** Enough symbols for the sections to span many pages and compressed blocks */
+  async function writeLargeIndex(name: string, options: BinaryIndexWriteOptions = {}): Promise<string> {
+    const writer = new BinaryIndexWriter();
+    for (let file = 0; file < 40; file++) {
+      const uri = `/ws/pkg${file}/types.go`;
+      writer.addFile(uri, Array.from({ length: 50 }, (_, i) => symbol({
+        id: `${uri}#Type${i}`,
+        name: `Type${i}`,
+        kind: 'struct',
+        location: { uri, line: i, character: 5 },
+        doc: `Type${i} of package ${file}. `.repeat(4)
+      })));
+    }
+    const filePath = path.join(testDir, name);
+    await writer.finish(filePath, options);
+    return filePath;
+  }
+
+  afterEach(async () => {
+    await index?.close();
+    index = undefined;
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  it('should round-trip every symbol field', async () => {
+    index = await BinaryIndex.open(indexPath);
+    expect(index.symbolCount).toBe(4);
+    expect(index.fileCount).toBe(2);
+
+    expect(await index.getFileSymbols(service)).toEqual(serviceSymbols);
+    expect(await index.getFileSymbols(model)).toEqual(modelSymbols);
+    expect(await index.getFileSymbols('/ws/missing.ts')).toEqual([]);
+
+    const loaded = await BinaryIndex.open(indexPath, { mapped: false });
+    expect(await loaded.getFileSymbols(service)).toEqual(serviceSymbols);
+    await loaded.close();
+  });
+
+  it('should look up by name and id', async () => {
+    index = await BinaryIndex.open(indexPath);
+
+    expect((await index.findByName('UserService')).map(s => s.id)).toEqual(['svc', 'user.ref']);
+    expect((await index.findById('svc.load'))?.name).toBe('load');
+    expect(await index.findById('nope')).toBeNull();
+    expect((await index.findByNameMatching(name => name.startsWith('U'), 10)).map(s => s.id)).toEqual(['user', 'svc', 'user.ref']);
+    expect(await index.findByNameMatching(() => true, 1)).toHaveLength(1);
+  });
+
+  it('should read only the header when opened', async () => {
+    expect(await isBinaryIndexFile(indexPath)).toBe(true);
+
+    // Corrupt everything after the header: open still succeeds, queries fail
+    const bytes = fs.readFileSync(indexPath);
+    const dataStart = 8 + bytes.readUInt32LE(4);
+    bytes.fill(0xff, dataStart);
+    fs.writeFileSync(indexPath, bytes);
+
+    index = await BinaryIndex.open(indexPath);
+    expect(index.symbolCount).toBe(4);
+    await expect(index.findByName('User')).rejects.toThrow();
+
+    const jsonPath = path.join(testDir, 'index.json');
+    fs.writeFileSync(jsonPath, '{"symbols": []}');
+    expect(await isBinaryIndexFile(jsonPath)).toBe(false);
+    await expect(BinaryIndex.open(jsonPath)).rejects.toThrow('Not a binary index');
+  });
+
+  it('should answer the same in place and loaded, across many pages', async () => {
+    const largePath = await writeLargeIndex('large.sidx');
+    expect(fs.statSync(largePath).size).toBeGreaterThan(10 * 16 * 1024);
+
+    // A one-page cache makes every lookup go back to the file
+    index = await BinaryIndex.open(largePath, { pageCacheBytes: 1 });
+    const loaded = await BinaryIndex.open(largePath, { mapped: false });
+    try {
+      expect(index.mapped).toBe(true);
+      expect(loaded.mapped).toBe(false);
+
+      const type7 = await index.findByName('Type7');
+      expect(type7.map(s => s.location.uri)).toHaveLength(40);
+      expect(type7).toEqual(await loaded.findByName('Type7'));
+      expect(await index.findById('/ws/pkg31/types.go#Type49')).toEqual(await loaded.findById('/ws/pkg31/types.go#Type49'));
+      expect(await index.getFileSymbols('/ws/pkg12/types.go')).toEqual(await loaded.getFileSymbols('/ws/pkg12/types.go'));
+      expect(await index.getFileUris()).toEqual(await loaded.getFileUris());
+      expect(await index.findByNameMatching(name => name.endsWith('9'), 100)).toEqual(await loaded.findByNameMatching(name => name.endsWith('9'), 100));
+      expect(await index.findByName('Type50')).toEqual([]);
+    } finally {
+      await loaded.close();
+    }
+  });
+
+  it('should read compressed blocks on demand', async () => {
+    const plainPath = await writeLargeIndex('plain.sidx');
+    const deflatePath = await writeLargeIndex('deflate.sidx', { compression: 'deflate' });
+    expect(fs.statSync(deflatePath).size).toBeLessThan(fs.statSync(plainPath).size / 2);
+
+    const plain = await BinaryIndex.open(plainPath);
+    try {
+      for (const options of [{ pageCacheBytes: 1 }, { mapped: false }]) {
+        const compressed = await BinaryIndex.open(deflatePath, options);
+        try {
+          expect(compressed.compression).toBe('deflate');
+          expect(await compressed.findByName('Type3')).toEqual(await plain.findByName('Type3'));
+          expect(await compressed.getFileSymbols('/ws/pkg39/types.go')).toEqual(await plain.getFileSymbols('/ws/pkg39/types.go'));
+          expect(await compressed.getFileUris()).toHaveLength(40);
+        } finally {
+          await compressed.close();
+        }
+      }
+    } finally {
+      await plain.close();
+    }
+
+    // A damaged block fails the lookups that need it
+    const bytes = fs.readFileSync(deflatePath);
+    bytes.fill(0, 8 + bytes.readUInt32LE(4));
+    fs.writeFileSync(deflatePath, bytes);
+    index = await BinaryIndex.open(deflatePath);
+    await expect(index.findByName('Type3')).rejects.toThrow();
+
+    const hasZstd = typeof (zlib as unknown as Record<string, unknown>).zstdCompress === 'function';
+    expect(isCompressionSupported('zstd')).toBe(hasZstd);
+    if (hasZstd) {
+      const zstd = await BinaryIndex.open(await writeLargeIndex('zstd.sidx', { compression: 'zstd' }));
+      expect((await zstd.findById('/ws/pkg0/types.go#Type0'))?.name).toBe('Type0');
+      await zstd.close();
+    } else {
+      await expect(writeLargeIndex('zstd.sidx', { compression: 'zstd' })).rejects.toThrow('zstd compression needs a newer Node.js');
+    }
+  });
+
+  it('should write the same bytes for the same symbols and build time', async () => {
+    for (const compression of ['none', 'deflate'] as const) {
+      const first = await writeLargeIndex(`first-${compression}.sidx`, { compression, createdAt: 0 });
+      await new Promise(resolve => setTimeout(resolve, 5));
+      const second = await writeLargeIndex(`second-${compression}.sidx`, { compression, createdAt: 0 });
+      expect(fs.readFileSync(second).equals(fs.readFileSync(first))).toBe(true);
+    }
+    const stamped = await writeLargeIndex('stamped.sidx', { createdAt: 1_700_000_000_000 });
+    expect(fs.readFileSync(stamped).equals(fs.readFileSync(path.join(testDir, 'first-none.sidx')))).toBe(false);
+  });
+
+  it('should be loaded by StaticIndex from a file or alongside JSON snapshots', async () => {
+    const fromFile = new StaticIndex();
+    await fromFile.load(indexPath);
+    expect(fromFile.getStats()).toEqual({ files: 2, symbols: 4 });
+    expect((await fromFile.findDefinitionById('user'))?.name).toBe('User');
+    expect((await fromFile.searchSymbols('usrsvc', 10)).map(s => s.id)).toContain('svc');
+    await fromFile.dispose();
+
+    fs.writeFileSync(path.join(testDir, 'extra.json'), JSON.stringify({
+      symbols: [symbol({ id: 'json', name: 'UserService', kind: 'variable', location: { uri: '/ws/other.ts', line: 1, character: 0 } })]
+    }));
+    const fromDirectory = new StaticIndex();
+    await fromDirectory.load(testDir);
+    expect(fromDirectory.getStats()).toEqual({ files: 3, symbols: 5 });
+    expect((await fromDirectory.findDefinitions('UserService')).map(s => s.id)).toEqual(['json', 'svc', 'user.ref']);
+    await fromDirectory.dispose();
+  });
+});
+
+describe('protoWire', () => {
+  it('should encode varints, strings and packed fields in protobuf wire format', () => {
+    const bytes = new ProtoWriter().uint(1, 150).string(2, 'testing').packed(4, [3, 270, 86942]).uint(5, 0).finish();
+
+    // Examples from the protobuf encoding guide
+    expect(Buffer.from(bytes).toString('hex')).toBe('089601' + '120774657374696e67' + '2206038e029ea705');
+
+    const reader = new ProtoReader(bytes);
+    expect(reader.tag()).toEqual({ field: 1, wireType: 0 });
+    expect(reader.varint()).toBe(150);
+    expect(reader.tag()).toEqual({ field: 2, wireType: 2 });
+    expect(reader.string()).toBe('testing');
+    expect(reader.tag()).toEqual({ field: 4, wireType: 2 });
+    expect(reader.packed(2)).toEqual([3, 270, 86942]);
+    expect(reader.hasMore()).toBe(false);
+
+    const large = new ProtoWriter().uint(1, 2 ** 40 + 5).finish();
+    const largeReader = new ProtoReader(large);
+    largeReader.tag();
+    expect(largeReader.varint()).toBe(2 ** 40 + 5);
+  });
+});
@@ -0,0 +1,983 @@
+import { CodeTag, IndexedSymbol } from '../types.js';
+import { ProtoReader, ProtoWriter } from '../utils/protoWire.js';
+import { buildTimestamp } from '../utils/buildTimestamp.js';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { promisify } from 'util';
+
+/**
+ * Binary static index (.sidx) - see server/proto/smart_index_file.proto
+ * for the layout and message definitions.
+ */
+export const BINARY_INDEX_MAGIC = 'SIDX';
+export const BINARY_INDEX_VERSION = 1;
+/** Version of compressed files, so readers that predate compression refuse them */
+export const COMPRESSED_BINARY_INDEX_VERSION = 2;
+export const BINARY_INDEX_EXTENSION = '.sidx';
+
+export type BinaryIndexCompression = 'none' | 'deflate' | 'zstd';
+
+export const BINARY_INDEX_COMPRESSIONS: BinaryIndexCompression[] = ['none', 'deflate', 'zstd'];
+
+const PREAMBLE_SIZE = 8; // magic + u32 header length
+const PAGE_SIZE = 16 * 1024;
+const COMPRESSED_BLOCK_SIZE = 64 * 1024;
+const DEFAULT_PAGE_CACHE_BYTES = 8 * 1024 * 1024;
+
+type KeySectionName = 'names' | 'ids' | 'files';
+type SectionName =
+  | 'strings' | 'symbols' | 'symbol_offsets' | KeySectionName
+  | 'string_index' | 'symbol_index' | 'names_index' | 'ids_index' | 'files_index';
+
+/** Offset tables a file needs to be read in place */
+const MAPPED_SECTIONS: SectionName[] = ['string_index', 'symbol_index', 'names_index', 'ids_index', 'files_index'];
+
+interface SectionInfo {
+  offset: number;
+  length: number;
+}
+
+interface KeyIndex {
+  /** String table indexes, sorted by string */
+  keys: number[];
+  ordinals: number[][];
+}
+
+interface KeyEntry {
+  key: number;
+  ordinals: number[];
+}
+
+interface EncodedSection {
+  data: Uint8Array;
+  /** Byte offset of each entry in `data`, plus the end offset */
+  offsets: number[];
+}
+
+export interface BinaryIndexOptions {
+  /**
+   * Read the file in place through a bounded page cache instead of loading
+   * the string table and key indexes into memory. Default true; files
+   * written before the offset tables existed are always loaded.
+   */
+  mapped?: boolean;
+  /** Page cache budget of a mapped index, default 8 MB. */
+  pageCacheBytes?: number;
+}
+
+export interface BinaryIndexStats {
+  symbols: number;
+  files: number;
+  bytes: number;
+}
+
+export interface BinaryIndexWriteOptions {
+  /**
+   * Compress the sections in independent blocks, so readers decompress
+   * only the blocks a lookup touches. Default 'none'.
+   */
+  compression?: BinaryIndexCompression;
+  /** Build time in the header (default: now; see utils/buildTimestamp.ts) */
+  createdAt?: number;
+}
+
+interface Codec {
+  compress(data: Uint8Array): Promise<Buffer>;
+  decompress(data: Uint8Array): Promise<Buffer>;
+}
+
+type ZlibCallback = (data: Uint8Array, callback: (error: Error | null, result: Buffer) => void) => void;
+
+/**
+ * Compression codec of a .sidx file. zstd comes with Node.js 22.15 and
+ * later; undefined when the running Node.js lacks it.
+ */
+function codecOf(compression: Exclude<BinaryIndexCompression, 'none'>): Codec | undefined {
+  const functions = zlib as unknown as Record<string, ZlibCallback | undefined>;
+  const [compress, decompress] = compression === 'zstd'
+    ? [functions.zstdCompress, functions.zstdDecompress]
+    : [functions.deflateRaw, functions.inflateRaw];
+  if (!compress || !decompress) {
+    return undefined;
+  }
+  return { compress: promisify(compress), decompress: promisify(decompress) };
+}
+
+/**
+ * Can this Node.js write and read the compression?
+ */
+export function isCompressionSupported(compression: BinaryIndexCompression): boolean {
+  return compression === 'none' || codecOf(compression) !== undefined;
+}
+
+/**
+ * Does the file start with the .sidx magic?
+ */
+export async function isBinaryIndexFile(filePath: string): Promise<boolean> {
+  let handle: fsPromises.FileHandle | undefined;
+  try {
+    handle = await fsPromises.open(filePath, 'r');
+    const magic = Buffer.alloc(BINARY_INDEX_MAGIC.length);
+    const { bytesRead } = await handle.read(magic, 0, magic.length, 0);
+    return bytesRead === magic.length && magic.toString('latin1') === BINARY_INDEX_MAGIC;
+  } catch {
+    return false;
+  } finally {
+    await handle?.close();
+  }
+}
+
+/**
+ * Builds a .sidx file. Add symbols file by file, then `finish()`; symbols
+ * of one file get consecutive ordinals so reading a file's outline is a
+ * single read.
+ */
+export class BinaryIndexWriter {
+  private strings: Map<string, number> = new Map([['', 0]]);
+  private stringList: string[] = [''];
+  private records: Uint8Array[] = [];
+  private offsets: number[] = [0];
+  private names: Map<number, number[]> = new Map();
+  private ids: Map<number, number[]> = new Map();
+  private files: Map<number, number[]> = new Map();
+
+  addFile(uri: string, symbols: IndexedSymbol[]): void {
+    const uriIndex = this.intern(uri);
+    for (const symbol of symbols) {
+      const ordinal = this.records.length;
+      const record = this.encodeSymbol(symbol, uriIndex);
+      this.records.push(record);
+      this.offsets.push(this.offsets[this.offsets.length - 1] + record.length);
+
+      pushOrdinal(this.names, this.intern(symbol.name), ordinal);
+      pushOrdinal(this.ids, this.intern(symbol.id), ordinal);
+      pushOrdinal(this.files, uriIndex, ordinal);
+    }
+  }
+
+  /**
+   * Write the file and return its size.
+   */
+  async finish(outputPath: string, options: BinaryIndexWriteOptions = {}): Promise<BinaryIndexStats> {
+    const compression = options.compression ?? 'none';
+    const codec = compression === 'none' ? undefined : codecOf(compression);
+    if (compression !== 'none' && !codec) {
+      throw new Error(`${compression} compression needs a newer Node.js`);
+    }
+    const symbolsBytes = concat(this.records, this.offsets[this.offsets.length - 1]);
+    const strings = this.encodeStrings();
+    const names = this.encodeKeyIndex(this.names);
+    const ids = this.encodeKeyIndex(this.ids);
+    const files = this.encodeKeyIndex(this.files);
+    const sections: Array<[SectionName, Uint8Array]> = [
+      ['strings', strings.data],
+      ['symbol_offsets', new ProtoWriter(this.offsets.length * 3).packed(1, this.offsets).finish()],
+      ['names', names.data],
+      ['ids', ids.data],
+      ['files', files.data],
+      ['string_index', encodeFixed32(strings.offsets)],
+      ['symbol_index', encodeFixed32(this.offsets)],
+      ['names_index', encodeFixed32(names.offsets)],
+      ['ids_index', encodeFixed32(ids.offsets)],
+      ['files_index', encodeFixed32(files.offsets)],
+      ['symbols', symbolsBytes]
+    ];
+
+    const createdAt = options.createdAt ?? buildTimestamp();
+    let headerBytes: Uint8Array;
+    let data: Uint8Array[];
+    if (codec) {
+      // Offsets are into the uncompressed sections, which are cut into blocks
+      const blocks = await compressBlocks(sections.map(([, bytes]) => bytes), codec);
+      headerBytes = this.encodeHeader(sections, 0, createdAt, { compression, blocks });
+      data = blocks;
+    } else {
+      // Section offsets depend on the header size, which depends on the offsets (varints)
+      headerBytes = this.encodeHeader(sections, 0, createdAt);
+      for (let previous = -1; previous !== headerBytes.length;) {
+        previous = headerBytes.length;
+        headerBytes = this.encodeHeader(sections, PREAMBLE_SIZE + previous, createdAt);
+      }
+      data = sections.map(([, bytes]) => bytes);
+    }
+
+    const preamble = Buffer.alloc(PREAMBLE_SIZE);
+    preamble.write(BINARY_INDEX_MAGIC, 0, 'latin1');
+    preamble.writeUInt32LE(headerBytes.length, BINARY_INDEX_MAGIC.length);
+
+    await fsPromises.mkdir(path.dirname(outputPath), { recursive: true });
+    const handle = await fsPromises.open(outputPath, 'w');
+    let bytes = 0;
+    try {
+      for (const chunk of [preamble, headerBytes, ...data]) {
+        for (let written = 0; written < chunk.length;) {
+          const result = await handle.write(chunk, written, chunk.length - written, bytes + written);
+          written += result.bytesWritten;
+        }
+        bytes += chunk.length;
+      }
+    } finally {
+      await handle.close();
+    }
+
+    return { symbols: this.records.length, files: this.files.size, bytes };
+  }
+
+  private encodeHeader(
+    sections: Array<[SectionName, Uint8Array]>,
+    dataStart: number,
+    createdAt: number,
+    compressed?: { compression: BinaryIndexCompression; blocks: Uint8Array[] }
+  ): Uint8Array {
+    const header = new ProtoWriter()
+      .uint(1, compressed ? COMPRESSED_BINARY_INDEX_VERSION : BINARY_INDEX_VERSION)
+      .uint(2, this.records.length)
+      .uint(3, this.files.size);
+    let offset = dataStart;
+    for (const [name, data] of sections) {
+      header.message(4, new ProtoWriter().string(1, name).uint(2, offset).uint(3, data.length));
+      offset += data.length;
+    }
+    header
+      .string(5, 'smart-indexer')
+      .uint(6, createdAt);
+    if (compressed) {
+      let end = 0;
+      header
+        .string(7, compressed.compression)
+        .uint(8, COMPRESSED_BLOCK_SIZE)
+        .packed(9, compressed.blocks.map(block => (end += block.length)));
+    }
+    return header.finish().slice();
+  }
+
+  private encodeStrings(): EncodedSection {
+    const writer = new ProtoWriter(1024);
+    const offsets: number[] = [];
+    for (const value of this.stringList) {
+      offsets.push(writer.size);
+      // Repeated strings must keep their position, so "" is written too
+      writer.bytes(1, Buffer.from(value, 'utf-8'));
+    }
+    offsets.push(writer.size);
+    return { data: writer.finish(), offsets };
+  }
+
+  private encodeKeyIndex(index: Map<number, number[]>): EncodedSection {
+    const writer = new ProtoWriter(1024);
+    const offsets: number[] = [];
+    const keys = [...index.keys()].sort((a, b) => compareStrings(this.stringList[a], this.stringList[b]));
+    for (const key of keys) {
+      offsets.push(writer.size);
+      writer.message(1, new ProtoWriter().uint(1, key).packed(2, index.get(key)!));
+    }
+    offsets.push(writer.size);
+    return { data: writer.finish(), offsets };
+  }
+
+  private encodeSymbol(symbol: IndexedSymbol, uriIndex: number): Uint8Array {
+    const writer = new ProtoWriter(64)
+      .uint(1, this.intern(symbol.id))
+      .uint(2, this.intern(symbol.name))
+      .uint(3, this.intern(symbol.kind))
+      .uint(4, uriIndex)
+      .uint(5, symbol.location.line)
+      .uint(6, symbol.location.character)
+      .uint(7, symbol.range.startLine)
+      .uint(8, symbol.range.startCharacter)
+      .uint(9, symbol.range.endLine)
+      .uint(10, symbol.range.endCharacter)
+      .uint(11, this.intern(symbol.containerName))
+      .uint(12, this.intern(symbol.containerKind))
+      .uint(13, this.intern(symbol.fullContainerPath))
+      .bool(14, symbol.isDefinition === false)
+      .bool(15, symbol.isExported)
+      .bool(16, symbol.isStatic)
+      .uint(17, this.intern(symbol.visibility))
+      .uint(18, symbol.parametersCount !== undefined ? symbol.parametersCount + 1 : 0);
+    if (symbol.metadata && Object.keys(symbol.metadata).length > 0) {
+      writer.string(19, JSON.stringify(symbol.metadata));
+    }
+    writer
+      .packed(20, (symbol.implements ?? []).map(name => this.intern(name)))
+      .uint(21, this.intern(symbol.extends))
+      .packed(22, (symbol.tags ?? []).map(tag => this.intern(tag)));
+    if (symbol.doc) {
+      writer.string(23, symbol.doc);
+    }
+    if (symbol.signature) {
+      writer.string(24, symbol.signature);
+    }
+    if (symbol.typeParameters && symbol.typeParameters.length > 0) {
+      writer.string(25, JSON.stringify(symbol.typeParameters));
+    }
+    if (symbol.value) {
+      writer.string(26, symbol.value);
+    }
+    writer
+      .uint(27, symbol.range.startOffset !== undefined ? symbol.range.startOffset + 1 : 0)
+      .uint(28, symbol.range.endOffset !== undefined ? symbol.range.endOffset + 1 : 0)
+      .uint(29, this.intern(symbol.canonicalId));
+    if (symbol.metrics) {
+      writer
+        .uint(30, symbol.metrics.complexity)
+        .uint(31, symbol.metrics.loc)
+        .uint(32, symbol.metrics.nesting + 1);
+    }
+    if (symbol.deprecated !== undefined) {
+      writer.bool(33, true).string(34, symbol.deprecated);
+    }
+    writer.packed(35, (symbol.captures ?? []).map(name => this.intern(name)));
+    return writer.finish().slice();
+  }
+
+  private intern(value: string | undefined): number {
+    if (!value) {
+      return 0;
+    }
+    let index = this.strings.get(value);
+    if (index === undefined) {
+      index = this.stringList.length;
+      this.strings.set(value, index);
+      this.stringList.push(value);
+    }
+    return index;
+  }
+}
+
+/**
+ * Read-only view of a .sidx file.
+ *
+ * `open()` reads only the preamble and header. By default the file is then
+ * read in place: lookups binary search the fixed-width offset tables and
+ * decode just the strings and records they touch, through a bounded cache
+ * of file pages. Like a memory map, this leaves the bytes in the OS page
+ * cache, shared by every process reading the same file, and the heap cost
+ * does not grow with the index.
+ *
+ * Files without the offset tables (and `mapped: false`) read the string
+ * table and key indexes whole on first use, and symbol records one range
+ * at a time.
+ *
+ * Compressed files are read the same way, with their blocks as the cache
+ * pages: a lookup decompresses only the blocks it touches.
+ */
+export class BinaryIndex {
+  private cache: Map<string, Promise<unknown>> = new Map();
+
+  private constructor(
+    private handle: fsPromises.FileHandle,
+    readonly filePath: string,
+    readonly symbolCount: number,
+    readonly fileCount: number,
+    private sections: Map<string, SectionInfo>,
+    readonly mapped: boolean,
+    readonly compression: BinaryIndexCompression,
+    private pages?: PageCache
+  ) {}
+
+  static async open(filePath: string, options: BinaryIndexOptions = {}): Promise<BinaryIndex> {
+    const handle = await fsPromises.open(filePath, 'r');
+    try {
+      const preamble = Buffer.alloc(PREAMBLE_SIZE);
+      await handle.read(preamble, 0, PREAMBLE_SIZE, 0);
+      if (preamble.toString('latin1', 0, BINARY_INDEX_MAGIC.length) !== BINARY_INDEX_MAGIC) {
+        throw new Error(`Not a binary index: ${filePath}`);
+      }
+      const headerBytes = Buffer.alloc(preamble.readUInt32LE(BINARY_INDEX_MAGIC.length));
+      await handle.read(headerBytes, 0, headerBytes.length, PREAMBLE_SIZE);
+
+      let version = 0;
+      let symbolCount = 0;
+      let fileCount = 0;
+      let compression: BinaryIndexCompression = 'none';
+      let blockSize = 0;
+      const blockEnds: number[] = [];
+      const sections = new Map<string, SectionInfo>();
+      const reader = new ProtoReader(headerBytes);
+      while (reader.hasMore()) {
+        const { field, wireType } = reader.tag();
+        if (field === 1) {
+          version = reader.varint();
+        } else if (field === 2) {
+          symbolCount = reader.varint();
+        } else if (field === 3) {
+          fileCount = reader.varint();
+        } else if (field === 4) {
+          const section = reader.message();
+          let name = '';
+          const info: SectionInfo = { offset: 0, length: 0 };
+          while (section.hasMore()) {
+            const tag = section.tag();
+            if (tag.field === 1) {
+              name = section.string();
+            } else if (tag.field === 2) {
+              info.offset = section.varint();
+            } else if (tag.field === 3) {
+              info.length = section.varint();
+            } else {
+              section.skip(tag.wireType);
+            }
+          }
+          sections.set(name, info);
+        } else if (field === 7) {
+          compression = reader.string() as BinaryIndexCompression;
+        } else if (field === 8) {
+          blockSize = reader.varint();
+        } else if (field === 9) {
+          blockEnds.push(...reader.packed(wireType));
+        } else {
+          reader.skip(wireType);
+        }
+      }
+
+      const compressed = version === COMPRESSED_BINARY_INDEX_VERSION;
+      if (version !== BINARY_INDEX_VERSION && !compressed) {
+        throw new Error(`Unsupported binary index version ${version} in ${filePath}`);
+      }
+      const cacheBytes = options.pageCacheBytes ?? DEFAULT_PAGE_CACHE_BYTES;
+      const mapped = options.mapped !== false && MAPPED_SECTIONS.every(name => sections.has(name));
+
+      let pages: PageCache | undefined;
+      if (compressed) {
+        const codec = compression === 'none' || !BINARY_INDEX_COMPRESSIONS.includes(compression) ? undefined : codecOf(compression);
+        if (!codec || blockSize === 0) {
+          throw new Error(`Binary index ${filePath} uses ${compression} compression, which this Node.js cannot read`);
+        }
+        // Section offsets are into the uncompressed data that follows the header
+        const dataStart = PREAMBLE_SIZE + headerBytes.length;
+        const size = Math.max(0, ...[...sections.values()].map(section => section.offset + section.length));
+        pages = new PageCache(filePath, size, blockSize, Math.max(1, Math.floor(cacheBytes / blockSize)), async (index, length) => {
+          if (index >= blockEnds.length) {
+            throw new Error(`Binary index ${filePath} is truncated`);
+          }
+          const start = index === 0 ? 0 : blockEnds[index - 1];
+          const block = await codec.decompress(await readFully(handle, filePath, dataStart + start, blockEnds[index] - start));
+          if (block.length !== length) {
+            throw new Error(`Binary index ${filePath} has a corrupt block ${index}`);
+          }
+          return block;
+        });
+      } else if (mapped) {
+        const { size } = await handle.stat();
+        pages = new PageCache(filePath, size, PAGE_SIZE, Math.max(1, Math.floor(cacheBytes / PAGE_SIZE)), (index, length) =>
+          readFully(handle, filePath, index * PAGE_SIZE, length)
+        );
+      }
+      return new BinaryIndex(handle, filePath, symbolCount, fileCount, sections, mapped, compression, pages);
+    } catch (error) {
+      await handle.close();
+      throw error;
+    }
+  }
+
+  async findByName(name: string): Promise<IndexedSymbol[]> {
+    return this.readSymbols(await this.lookup('names', name));
+  }
+
+  async findById(id: string): Promise<IndexedSymbol | null> {
+    const [symbol] = await this.readSymbols(await this.lookup('ids', id));
+    return symbol ?? null;
+  }
+
+  async getFileSymbols(uri: string): Promise<IndexedSymbol[]> {
+    return this.readSymbols(await this.lookup('files', uri));
+  }
+
+  /**
+   * Every file with symbols in the index, sorted.
+   */
+  async getFileUris(): Promise<string[]> {
+    const uris: string[] = [];
+    const count = await this.keyCount('files');
+    for (let i = 0; i < count; i++) {
+      uris.push(await this.keyString(await this.keyAt('files', i)));
+    }
+    return uris;
+  }
+
+  /**
+   * Symbols whose name passes the filter, in name order, up to `limit`.
+   */
+  async findByNameMatching(matches: (name: string) => boolean, limit: number): Promise<IndexedSymbol[]> {
+    const ordinals: number[] = [];
+    const count = await this.keyCount('names');
+    for (let i = 0; i < count && ordinals.length < limit; i++) {
+      const entry = await this.keyAt('names', i);
+      if (matches(await this.keyString(entry))) {
+        ordinals.push(...entry.ordinals);
+      }
+    }
+    return this.readSymbols(ordinals.slice(0, limit));
+  }
+
+  async close(): Promise<void> {
+    this.cache.clear();
+    this.pages?.clear();
+    await this.handle.close();
+  }
+
+  private async lookup(section: KeySectionName, key: string): Promise<number[]> {
+    let low = 0;
+    let high = await this.keyCount(section) - 1;
+    while (low <= high) {
+      const mid = (low + high) >> 1;
+      const entry = await this.keyAt(section, mid);
+      const order = compareStrings(await this.keyString(entry), key);
+      if (order === 0) {
+        return entry.ordinals;
+      }
+      if (order < 0) {
+        low = mid + 1;
+      } else {
+        high = mid - 1;
+      }
+    }
+    return [];
+  }
+
+  private async keyCount(section: KeySectionName): Promise<number> {
+    if (this.mapped) {
+      return this.sections.get(`${section}_index`)!.length / 4 - 1;
+    }
+    return (await this.keyIndex(section)).keys.length;
+  }
+
+  private async keyAt(section: KeySectionName, i: number): Promise<KeyEntry> {
+    if (this.mapped) {
+      const [start, end] = await this.fixed32(`${section}_index`, i, 2);
+      const bytes = await this.read(this.sections.get(section)!.offset + start, end - start);
+      const reader = new ProtoReader(bytes);
+      reader.tag();
+      return decodeKeyEntry(reader.message());
+    }
+    const index = await this.keyIndex(section);
+    return { key: index.keys[i], ordinals: index.ordinals[i] };
+  }
+
+  private async keyString(entry: KeyEntry): Promise<string> {
+    if (this.mapped) {
+      return this.stringAt(entry.key);
+    }
+    return (await this.strings())[entry.key];
+  }
+
+  private async stringAt(index: number): Promise<string> {
+    const [start, end] = await this.fixed32('string_index', index, 2);
+    const reader = new ProtoReader(await this.read(this.sections.get('strings')!.offset + start, end - start));
+    reader.tag();
+    return reader.string();
+  }
+
+  /**
+   * Decode records, reading each run of consecutive ordinals with one read.
+   */
+  private async readSymbols(ordinals: number[]): Promise<IndexedSymbol[]> {
+    if (ordinals.length === 0) {
+      return [];
+    }
+    const symbols = this.sections.get('symbols')!;
+    const records: Array<[Buffer, number, number]> = [];
+
+    for (let i = 0; i < ordinals.length;) {
+      let j = i + 1;
+      while (j < ordinals.length && ordinals[j] === ordinals[j - 1] + 1) {
+        j++;
+      }
+      const offsets = await this.recordOffsets(ordinals[i], j - i);
+      const start = offsets[0];
+      const bytes = await this.read(symbols.offset + start, offsets[j - i] - start);
+      for (let k = 0; k < j - i; k++) {
+        records.push([bytes, offsets[k] - start, offsets[k + 1] - start]);
+      }
+      i = j;
+    }
+
+    const string = await this.stringsOf(records);
+    return records.map(([bytes, start, end]) => decodeSymbol(bytes, start, end, string));
+  }
+
+  /**
+   * Start offsets of `count` records from `first`, plus the end of the last.
+   */
+  private async recordOffsets(first: number, count: number): Promise<number[]> {
+    if (this.mapped) {
+      return this.fixed32('symbol_index', first, count + 1);
+    }
+    return (await this.offsets()).slice(first, first + count + 1);
+  }
+
+  /**
+   * String lookup for decoding records. A mapped index reads only the
+   * strings the records use, collected by a first decoding pass.
+   */
+  private async stringsOf(records: Array<[Buffer, number, number]>): Promise<(index: number) => string> {
+    if (!this.mapped) {
+      const strings = await this.strings();
+      return index => strings[index];
+    }
+    const used = new Set<number>();
+    for (const [bytes, start, end] of records) {
+      decodeSymbol(bytes, start, end, index => {
+        used.add(index);
+        return '';
+      });
+    }
+    const values = new Map<number, string>();
+    await Promise.all([...used].map(async index => values.set(index, await this.stringAt(index))));
+    return index => values.get(index) ?? '';
+  }
+
+  private async fixed32(section: SectionName, first: number, count: number): Promise<number[]> {
+    const bytes = await this.read(this.sections.get(section)!.offset + first * 4, count * 4);
+    const values: number[] = [];
+    for (let i = 0; i < count; i++) {
+      values.push(bytes.readUInt32LE(i * 4));
+    }
+    return values;
+  }
+
+  private strings(): Promise<string[]> {
+    return this.memo('strings', async () => {
+      const reader = new ProtoReader(await this.readSection('strings'));
+      const strings: string[] = [];
+      while (reader.hasMore()) {
+        const { field, wireType } = reader.tag();
+        if (field === 1) {
+          strings.push(reader.string());
+        } else {
+          reader.skip(wireType);
+        }
+      }
+      return strings;
+    });
+  }
+
+  private offsets(): Promise<number[]> {
+    return this.memo('symbol_offsets', async () => {
+      const reader = new ProtoReader(await this.readSection('symbol_offsets'));
+      const offsets: number[] = [];
+      while (reader.hasMore()) {
+        const { field, wireType } = reader.tag();
+        if (field === 1) {
+          offsets.push(...reader.packed(wireType));
+        } else {
+          reader.skip(wireType);
+        }
+      }
+      return offsets;
+    });
+  }
+
+  private keyIndex(section: KeySectionName): Promise<KeyIndex> {
+    return this.memo(section, async () => {
+      const reader = new ProtoReader(await this.readSection(section));
+      const index: KeyIndex = { keys: [], ordinals: [] };
+      while (reader.hasMore()) {
+        const { field, wireType } = reader.tag();
+        if (field !== 1) {
+          reader.skip(wireType);
+          continue;
+        }
+        const entry = decodeKeyEntry(reader.message());
+        index.keys.push(entry.key);
+        index.ordinals.push(entry.ordinals);
+      }
+      return index;
+    });
+  }
+
+  private memo<T>(key: string, load: () => Promise<T>): Promise<T> {
+    let cached = this.cache.get(key) as Promise<T> | undefined;
+    if (!cached) {
+      cached = load();
+      this.cache.set(key, cached);
+    }
+    return cached;
+  }
+
+  private readSection(name: SectionName): Promise<Buffer> {
+    const section = this.sections.get(name);
+    if (!section) {
+      throw new Error(`Binary index ${this.filePath} has no '${name}' section`);
+    }
+    return this.read(section.offset, section.length);
+  }
+
+  private read(position: number, length: number): Promise<Buffer> {
+    return this.pages ? this.pages.read(position, length) : readFully(this.handle, this.filePath, position, length);
+  }
+}
+
+/**
+ * Fixed-size pages of a file, least recently used dropped first. Stands in
+ * for a memory map, which Node cannot create without a native module: the
+ * bytes stay in the OS page cache and the heap holds at most `maxPages`.
+ * For compressed files, a page is a decompressed block.
+ */
+class PageCache {
+  private pages: Map<number, Promise<Buffer>> = new Map();
+
+  constructor(
+    private filePath: string,
+    private size: number,
+    private pageSize: number,
+    private maxPages: number,
+    private load: (index: number, length: number) => Promise<Buffer>
+  ) {}
+
+  async read(position: number, length: number): Promise<Buffer> {
+    if (position < 0 || length < 0 || position + length > this.size) {
+      throw new Error(`Binary index ${this.filePath} is truncated`);
+    }
+    if (length === 0) {
+      return Buffer.alloc(0);
+    }
+    const first = Math.floor(position / this.pageSize);
+    const last = Math.floor((position + length - 1) / this.pageSize);
+    if (first === last) {
+      const start = position - first * this.pageSize;
+      return (await this.page(first)).subarray(start, start + length);
+    }
+
+    const pages: Array<Promise<Buffer>> = [];
+    for (let index = first; index <= last; index++) {
+      pages.push(this.page(index));
+    }
+    const result = Buffer.alloc(length);
+    (await Promise.all(pages)).forEach((page, i) => {
+      const pageStart = (first + i) * this.pageSize;
+      const from = Math.max(position, pageStart);
+      const to = Math.min(position + length, pageStart + page.length);
+      page.copy(result, from - position, from - pageStart, to - pageStart);
+    });
+    return result;
+  }
+
+  clear(): void {
+    this.pages.clear();
+  }
+
+  private page(index: number): Promise<Buffer> {
+    const cached = this.pages.get(index);
+    if (cached) {
+      // Move to the most recently used end
+      this.pages.delete(index);
+      this.pages.set(index, cached);
+      return cached;
+    }
+    const page = this.load(index, Math.min(this.pageSize, this.size - index * this.pageSize));
+    page.catch(() => {
+      if (this.pages.get(index) === page) {
+        this.pages.delete(index);
+      }
+    });
+    this.pages.set(index, page);
+    while (this.pages.size > this.maxPages) {
+      this.pages.delete(this.pages.keys().next().value!);
+    }
+    return page;
+  }
+}
+
+async function readFully(handle: fsPromises.FileHandle, filePath: string, position: number, length: number): Promise<Buffer> {
+  const buffer = Buffer.alloc(length);
+  let done = 0;
+  while (done < length) {
+    const { bytesRead } = await handle.read(buffer, done, length - done, position + done);
+    if (bytesRead === 0) {
+      throw new Error(`Binary index ${filePath} is truncated`);
+    }
+    done += bytesRead;
+  }
+  return buffer;
+}
+
+function decodeKeyEntry(reader: ProtoReader): KeyEntry {
+  const entry: KeyEntry = { key: 0, ordinals: [] };
+  while (reader.hasMore()) {
+    const tag = reader.tag();
+    if (tag.field === 1) {
+      entry.key = reader.varint();
+    } else if (tag.field === 2) {
+      entry.ordinals.push(...reader.packed(tag.wireType));
+    } else {
+      reader.skip(tag.wireType);
+    }
+  }
+  return entry;
+}
+
+function decodeSymbol(bytes: Uint8Array, start: number, end: number, string: (index: number) => string): IndexedSymbol {
+  const reader = new ProtoReader(bytes, start, end);
+  const values: number[] = new Array(34).fill(0);
+  let metadata: string | undefined;
+  let implementsNames: number[] = [];
+  let tags: number[] = [];
+  let captures: number[] = [];
+  let doc: string | undefined;
+  let signature: string | undefined;
+  let typeParameters: string | undefined;
+  let value: string | undefined;
+  let deprecated: string | undefined;
+
+  while (reader.hasMore()) {
+    const { field, wireType } = reader.tag();
+    if (field === 19) {
+      metadata = reader.string();
+    } else if (field === 20) {
+      implementsNames = reader.packed(wireType);
+    } else if (field === 22) {
+      tags = reader.packed(wireType);
+    } else if (field === 23) {
+      doc = reader.string();
+    } else if (field === 24) {
+      signature = reader.string();
+    } else if (field === 25) {
+      typeParameters = reader.string();
+    } else if (field === 26) {
+      value = reader.string();
+    } else if (field === 34) {
+      deprecated = reader.string();
+    } else if (field === 35) {
+      captures = reader.packed(wireType);
+    } else if (field > 0 && field < values.length && wireType === 0) {
+      values[field] = reader.varint();
+    } else {
+      reader.skip(wireType);
+    }
+  }
+
+  const uri = string(values[4]);
+  const symbol: IndexedSymbol = {
+    id: string(values[1]),
+    name: string(values[2]),
+    kind: string(values[3]),
+    location: { uri, line: values[5], character: values[6] },
+    range: { startLine: values[7], startCharacter: values[8], endLine: values[9], endCharacter: values[10] },
+    filePath: uri,
+    isDefinition: values[14] === 0
+  };
+  if (values[11]) {
+    symbol.containerName = string(values[11]);
+  }
+  if (values[12]) {
+    symbol.containerKind = string(values[12]);
+  }
+  if (values[13]) {
+    symbol.fullContainerPath = string(values[13]);
+  }
+  if (values[29]) {
+    symbol.canonicalId = string(values[29]);
+  }
+  if (values[15]) {
+    symbol.isExported = true;
+  }
+  if (values[16]) {
+    symbol.isStatic = true;
+  }
+  if (values[17]) {
+    symbol.visibility = string(values[17]) as IndexedSymbol['visibility'];
+  }
+  if (values[18]) {
+    symbol.parametersCount = values[18] - 1;
+  }
+  if (values[30]) {
+    symbol.metrics = { complexity: values[30], loc: values[31], nesting: values[32] - 1 };
+  }
+  if (values[27] && values[28]) {
+    symbol.range.startOffset = values[27] - 1;
+    symbol.range.endOffset = values[28] - 1;
+  }
+  if (metadata) {
+    symbol.metadata = JSON.parse(metadata);
+  }
+  if (implementsNames.length > 0) {
+    symbol.implements = implementsNames.map(index => string(index));
+  }
+  if (values[21]) {
+    symbol.extends = string(values[21]);
+  }
+  if (tags.length > 0) {
+    symbol.tags = tags.map(index => string(index) as CodeTag);
+  }
+  if (captures.length > 0) {
+    symbol.captures = captures.map(index => string(index));
+  }
+  if (doc) {
+    symbol.doc = doc;
+  }
+  if (signature) {
+    symbol.signature = signature;
+  }
+  if (typeParameters) {
+    symbol.typeParameters = JSON.parse(typeParameters);
+  }
+  if (value) {
+    symbol.value = value;
+  }
+  if (values[33]) {
+    symbol.deprecated = deprecated ?? '';
+  }
+  return symbol;
+}
+
+function pushOrdinal(index: Map<number, number[]>, key: number, ordinal: number): void {
+  const ordinals = index.get(key);
+  if (ordinals) {
+    ordinals.push(ordinal);
+  } else {
+    index.set(key, [ordinal]);
+  }
+}
+
+function encodeFixed32(values: number[]): Uint8Array {
+  const buffer = Buffer.alloc(values.length * 4);
+  values.forEach((value, i) => buffer.writeUInt32LE(value, i * 4));
+  return buffer;
+}
+
+/**
+ * Cut the sections, back to back, into blocks of COMPRESSED_BLOCK_SIZE
+ * bytes (the last may be shorter) and compress each on its own.
+ */
+async function compressBlocks(chunks: Uint8Array[], codec: Codec): Promise<Uint8Array[]> {
+  const blocks: Uint8Array[] = [];
+  let pending: Uint8Array[] = [];
+  let pendingLength = 0;
+  const flush = async () => {
+    blocks.push(await codec.compress(concat(pending, pendingLength)));
+    pending = [];
+    pendingLength = 0;
+  };
+  for (const chunk of chunks) {
+    for (let offset = 0; offset < chunk.length;) {
+      const take = Math.min(COMPRESSED_BLOCK_SIZE - pendingLength, chunk.length - offset);
+      pending.push(chunk.subarray(offset, offset + take));
+      pendingLength += take;
+      offset += take;
+      if (pendingLength === COMPRESSED_BLOCK_SIZE) {
+        await flush();
+      }
+    }
+  }
+  if (pendingLength > 0) {
+    await flush();
+  }
+  return blocks;
+}
+
+function concat(chunks: Uint8Array[], length: number): Uint8Array {
+  const result = new Uint8Array(length);
+  let offset = 0;
+  for (const chunk of chunks) {
+    result.set(chunk, offset);
+    offset += chunk.length;
+  }
+  return result;
+}
+
+function compareStrings(a: string, b: string): number {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
@@ -0,0 +1,99 @@
+/**
+ * DynamicIndex Storage Tests
+ *
+ * Checks lookups over stored results with packed references, that
+ * replacing and removing files takes their names out of every index, and
+ * that equal tags and metadata are stored once.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { DynamicIndex } from './dynamicIndex.js';
+import { SymbolIndexer } from '../indexer/symbolIndexer.js';
+import { createTestReference, createTestSymbol } from '../test/mocks/MockIndex.js';
+import { IndexedFileResult, IndexedReference, IndexedSymbol } from '../types.js';
+
+const dial = '/ws/net/dial.go';
+const conn = '/ws/net/conn.go';
+
+function symbol(uri: string, name: string, overrides: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    id: `${uri}:${name}`,
+    name,
+    kind: 'function',
+    filePath: uri,
+    location: { uri, line: 0, character: 0 },
+    metadata: { go: { package: 'net', importPath: 'std/net' } },
+    tags: ['test'],
+    ...overrides
+  });
+}
+
+function reference(uri: string, symbolName: string, line: number, isLocal: boolean = false): IndexedReference {
+  return createTestReference({ symbolName, location: { uri, line, character: 4 }, isLocal });
+}
+
+function fileResult(uri: string, symbols: IndexedSymbol[], references: IndexedReference[]): IndexedFileResult {
+  return { uri, hash: `hash:${uri}`, symbols, references, imports: [] };
+}
+
+describe('DynamicIndex storage', () => {
+  let index: DynamicIndex;
+
+  beforeEach(() => {
+    index = new DynamicIndex(new SymbolIndexer());
+  });
+
+  it('should answer lookups from stored results', async () => {
+    const result = fileResult(dial, [symbol(dial, 'Dial')], [reference(dial, 'Conn', 3), reference(dial, 'Conn', 5, true)]);
+    index.setFileResult(dial, result);
+    index.setFileResult(conn, fileResult(conn, [symbol(conn, 'Conn', { kind: 'interface' })], [reference(conn, 'Dial', 9)]));
+
+    expect(index.getFileResult(dial)).toBe(result);
+    expect(index.getFileInfo(dial)?.hash).toBe(`hash:${dial}`);
+    expect(index.getFileInfo(dial)?.references).toEqual([]);
+    expect((await index.findReferencesByName('Conn')).map(ref => ref.location.line)).toEqual([3, 5]);
+    expect((await index.findReferencesByName('Conn'))[0]).toEqual(result.references[0]);
+    expect((await index.getReferenceCounts(['Conn', 'Dial', 'Addr'])).get('Conn')).toBe(1);
+    expect((await index.findDefinitions('Conn'))[0].kind).toBe('interface');
+    expect(await index.findDefinitionById(`${dial}:Dial`)).toBe(result.symbols[0]);
+    expect(index.getStats()).toEqual({ files: 2, symbols: 2 });
+  });
+
+  it('should drop the names of replaced and removed files', async () => {
+    index.setFileResult(dial, fileResult(dial, [symbol(dial, 'Dial'), symbol(dial, 'DialTimeout')], [reference(dial, 'Conn', 3)]));
+    index.setFileResult(dial, fileResult(dial, [symbol(dial, 'Dial')], [reference(dial, 'Addr', 4)]));
+
+    expect(await index.findDefinitions('DialTimeout')).toEqual([]);
+    expect(await index.findDefinitionById(`${dial}:DialTimeout`)).toBeNull();
+    expect(await index.findReferencesByName('Conn')).toEqual([]);
+    expect((await index.findReferencesByName('Addr')).length).toBe(1);
+
+    index.removeFile(dial);
+    expect(index.hasFile(dial)).toBe(false);
+    expect(await index.findDefinitions('Dial')).toEqual([]);
+    expect(await index.findReferencesByName('Addr')).toEqual([]);
+    expect(index.getFileResult(dial)).toBeUndefined();
+  });
+
+  it('should store equal tags and metadata once', async () => {
+    index.setFileResult(dial, fileResult(dial, [symbol(dial, 'Dial'), symbol(dial, 'Listen')], []));
+    index.setFileResult(conn, fileResult(conn, [symbol(conn, 'Conn')], []));
+
+    const [first, second] = await index.getFileSymbols(dial);
+    const [third] = await index.getFileSymbols(conn);
+    expect(second.metadata).toBe(first.metadata);
+    expect(third.metadata).toBe(first.metadata);
+    expect(third.tags).toBe(first.tags);
+    expect(first.metadata).toEqual({ go: { package: 'net', importPath: 'std/net' } });
+  });
+
+  it('should keep answering after many replacements sweep the string pool', async () => {
+    for (let i = 0; i < 1100; i++) {
+      index.setFileResult(dial, fileResult(dial, [symbol(dial, `Dial${i}`)], [reference(dial, `Conn${i}`, i)]));
+    }
+
+    expect((await index.findDefinitions('Dial1099')).length).toBe(1);
+    expect((await index.findReferencesByName('Conn1099'))[0].location.line).toBe(1099);
+    expect(await index.findReferencesByName('Conn1000')).toEqual([]);
+  });
+});
@@ -1,10 +1,29 @@
 import { ISymbolIndex } from './ISymbolIndex.js';
-import { IndexedSymbol, IndexedFileResult, IndexedReference, ImportInfo, ReExportInfo } from '../types.js';
+import { IndexedSymbol, IndexedFileResult, IndexedReference, ImportInfo, ReExportInfo, CodeTag } from '../types.js';
+import { tagFileResult } from '../utils/codeTags.js';
 import { SymbolIndexer } from '../indexer/symbolIndexer.js';
 import { LanguageRouter } from '../indexer/languageRouter.js';
 import { fuzzyScore } from '../utils/fuzzySearch.js';
+import { StringPool, flatten } from '../utils/stringPool.js';
+import { PackedReferences } from './packedReferences.js';
 import * as crypto from 'crypto';
 
+/**
+ * A file as the index holds it: the result with pooled strings and without
+ * references, which are packed. view is the last result handed out or
+ * stored, for as long as something else holds it.
+ */
+interface StoredFile {
+  result: IndexedFileResult;
+  references: PackedReferences;
+  view?: WeakRef<IndexedFileResult>;
+}
+
+const NO_REFERENCES: IndexedReference[] = [];
+
+/** Replaced files before the first sweep of pooled strings */
+const SWEEP_MIN_REPLACED = 1000;
+
 /**
  * DynamicIndex - In-memory index for currently open/edited files.
  * Inspired by clangd's dynamic index.
@@ -13,21 +32,30 @@ import * as crypto from 'crypto';
  * - Maintains symbols for all open files in memory
  * - Updates immediately on file changes
  * - Has priority over background index (open files are always fresh)
+ * - Interns strings and packs references (see setFileResult), so an index
+ *   of a whole large workspace stays a few hundred megabytes
  */
 export class DynamicIndex implements ISymbolIndex {
-  private fileSymbols: Map<string, IndexedFileResult> = new Map();
+  private files: Map<string, StoredFile> = new Map();
   private fileHashes: Map<string, string> = new Map(); // uri -> content hash for self-healing
+  // The names, IDs and referenced names of a file are read back from its
+  // StoredFile to take them out of these
   private symbolNameIndex: Map<string, Set<string>> = new Map(); // name -> Set of URIs (O(1) lookup)
-  private fileToSymbolNames: Map<string, Set<string>> = new Map(); // uri -> Set of symbol names (reverse index for O(1) cleanup)
   private symbolIdIndex: Map<string, IndexedSymbol> = new Map(); // symbolId -> IndexedSymbol (O(1) lookup by ID)
-  private fileToSymbolIds: Map<string, Set<string>> = new Map(); // uri -> Set of symbolIds (reverse index for O(1) cleanup)
   
   // Reference tracking for O(1) findReferencesByName (mirrors BackgroundIndex)
   private referenceMap: Map<string, Set<string>> = new Map(); // symbolName -> Set of URIs containing references
-  private fileToReferenceNames: Map<string, Set<string>> = new Map(); // uri -> Set of referenced symbol names (reverse index for O(1) cleanup)
   
+  // One copy of each name, kind, package and path held (see internStrings)
+  private strings = new StringPool();
+  private shared: Map<string, object> = new Map();
+  // Files replaced since the last sweep of strings
+  private replaced = 0;
+
   private symbolIndexer: SymbolIndexer;
   private languageRouter: LanguageRouter | null = null;
+  // Goes up with every changed file (see getGeneration)
+  private generation = 0;
 
   constructor(symbolIndexer: SymbolIndexer) {
     this.symbolIndexer = symbolIndexer;
@@ -45,81 +73,10 @@ export class DynamicIndex implements ISymbolIndex {
    */
   async updateFile(uri: string, content?: string): Promise<void> {
     try {
-      // O(1) CLEANUP: Remove old symbols using reverse index before adding new ones
-      const oldSymbolNames = this.fileToSymbolNames.get(uri);
-      if (oldSymbolNames) {
-        for (const name of oldSymbolNames) {
-          const uriSet = this.symbolNameIndex.get(name);
-          if (uriSet) {
-            uriSet.delete(uri);
-            if (uriSet.size === 0) {
-              this.symbolNameIndex.delete(name);
-            }
-          }
-        }
-      }
-      
-      // O(1) CLEANUP: Remove old symbol IDs using reverse index
-      const oldSymbolIds = this.fileToSymbolIds.get(uri);
-      if (oldSymbolIds) {
-        for (const id of oldSymbolIds) {
-          this.symbolIdIndex.delete(id);
-        }
-      }
-
-      // O(1) CLEANUP: Remove old references using reverse index
-      const oldReferenceNames = this.fileToReferenceNames.get(uri);
-      if (oldReferenceNames) {
-        for (const symbolName of oldReferenceNames) {
-          const uriSet = this.referenceMap.get(symbolName);
-          if (uriSet) {
-            uriSet.delete(uri);
-            if (uriSet.size === 0) {
-              this.referenceMap.delete(symbolName);
-            }
-          }
-        }
-      }
-
       // Use language router if available, otherwise fall back to symbol indexer
       const indexer = this.languageRouter || this.symbolIndexer;
-      const result = await indexer.indexFile(uri, content);
-      this.fileSymbols.set(uri, result);
-
-      // Update symbol name index, ID index, and reverse indexes
-      const newSymbolNames = new Set<string>();
-      const newSymbolIds = new Set<string>();
-      for (const symbol of result.symbols) {
-        // Update name index
-        let uriSet = this.symbolNameIndex.get(symbol.name);
-        if (!uriSet) {
-          uriSet = new Set();
-          this.symbolNameIndex.set(symbol.name, uriSet);
-        }
-        uriSet.add(uri);
-        newSymbolNames.add(symbol.name);
-        
-        // Update ID index for O(1) findDefinitionById
-        this.symbolIdIndex.set(symbol.id, symbol);
-        newSymbolIds.add(symbol.id);
-      }
-      this.fileToSymbolNames.set(uri, newSymbolNames);
-      this.fileToSymbolIds.set(uri, newSymbolIds);
-      
-      // Update reference map and reverse index for O(1) findReferencesByName
-      const newReferenceNames = new Set<string>();
-      if (result.references) {
-        for (const ref of result.references) {
-          let refUriSet = this.referenceMap.get(ref.symbolName);
-          if (!refUriSet) {
-            refUriSet = new Set();
-            this.referenceMap.set(ref.symbolName, refUriSet);
-          }
-          refUriSet.add(uri);
-          newReferenceNames.add(ref.symbolName);
-        }
-      }
-      this.fileToReferenceNames.set(uri, newReferenceNames);
+      const result = tagFileResult(await indexer.indexFile(uri, content), content);
+      this.setFileResult(uri, result);
       
       // Store content hash for self-healing validation
       if (content !== undefined) {
@@ -132,57 +89,161 @@ export class DynamicIndex implements ISymbolIndex {
   }
 
   /**
-   * Remove a file from the dynamic index (e.g., when closed).
+   * Replace the indexed result of a file without indexing it, e.g. with a
+   * result loaded from disk.
+   *
+   * The index keeps its own form of the result (see StoredFile): strings
+   * are interned in place, so the caller's symbols end up sharing them.
    */
-  removeFile(uri: string): void {
-    // O(1) CLEANUP: Remove symbols using reverse index
-    const oldSymbolNames = this.fileToSymbolNames.get(uri);
-    if (oldSymbolNames) {
-      for (const name of oldSymbolNames) {
-        const uriSet = this.symbolNameIndex.get(name);
-        if (uriSet) {
-          uriSet.delete(uri);
-          if (uriSet.size === 0) {
-            this.symbolNameIndex.delete(name);
-          }
-        }
+  setFileResult(uri: string, result: IndexedFileResult): void {
+    this.unlinkFile(uri);
+    uri = this.strings.intern(uri);
+
+    this.internStrings(result);
+    const references = new PackedReferences(uri, result.references ?? [], this.strings);
+    this.files.set(uri, { result: { ...result, references: NO_REFERENCES }, references, view: new WeakRef(result) });
+
+    // Update symbol name index and ID index
+    for (const symbol of result.symbols) {
+      // Update name index
+      let uriSet = this.symbolNameIndex.get(symbol.name);
+      if (!uriSet) {
+        uriSet = new Set();
+        this.symbolNameIndex.set(symbol.name, uriSet);
       }
-      this.fileToSymbolNames.delete(uri);
+      uriSet.add(uri);
+      
+      // Update ID index for O(1) findDefinitionById
+      this.symbolIdIndex.set(symbol.id, symbol);
     }
     
-    // O(1) CLEANUP: Remove symbol IDs using reverse index
-    const oldSymbolIds = this.fileToSymbolIds.get(uri);
-    if (oldSymbolIds) {
-      for (const id of oldSymbolIds) {
-        this.symbolIdIndex.delete(id);
+    // Update reference map for O(1) findReferencesByName
+    for (const symbolName of references.names()) {
+      let refUriSet = this.referenceMap.get(symbolName);
+      if (!refUriSet) {
+        refUriSet = new Set();
+        this.referenceMap.set(symbolName, refUriSet);
       }
-      this.fileToSymbolIds.delete(uri);
+      refUriSet.add(uri);
     }
+    this.generation++;
+    this.sweepIfStale();
+  }
 
-    // O(1) CLEANUP: Remove references using reverse index
-    const oldReferenceNames = this.fileToReferenceNames.get(uri);
-    if (oldReferenceNames) {
-      for (const symbolName of oldReferenceNames) {
-        const uriSet = this.referenceMap.get(symbolName);
-        if (uriSet) {
-          uriSet.delete(uri);
-          if (uriSet.size === 0) {
-            this.referenceMap.delete(symbolName);
-          }
+  /**
+   * Remove a file from the dynamic index (e.g., when closed).
+   */
+  removeFile(uri: string): void {
+    this.unlinkFile(uri);
+    this.files.delete(uri);
+    this.fileHashes.delete(uri);
+    this.generation++;
+    this.sweepIfStale();
+  }
+
+  /**
+   * Take the current result of a file out of the name, ID and reference
+   * indexes, before it is replaced or removed.
+   */
+  private unlinkFile(uri: string): void {
+    const old = this.files.get(uri);
+    if (!old) {
+      return;
+    }
+
+    for (const symbol of old.result.symbols) {
+      const uriSet = this.symbolNameIndex.get(symbol.name);
+      if (uriSet) {
+        uriSet.delete(uri);
+        if (uriSet.size === 0) {
+          this.symbolNameIndex.delete(symbol.name);
         }
       }
-      this.fileToReferenceNames.delete(uri);
+      this.symbolIdIndex.delete(symbol.id);
     }
 
-    this.fileSymbols.delete(uri);
-    this.fileHashes.delete(uri);
+    for (const symbolName of old.references.names()) {
+      const uriSet = this.referenceMap.get(symbolName);
+      if (uriSet) {
+        uriSet.delete(uri);
+        if (uriSet.size === 0) {
+          this.referenceMap.delete(symbolName);
+        }
+      }
+    }
+    this.replaced++;
+  }
+
+  /**
+   * Intern the strings of a result in place, and give symbols with equal
+   * tags or metadata (every symbol of a Go package has the same) one
+   * shared object. Strings each symbol has its own of (ids, doc comments)
+   * are not pooled, only copied out of the file text they may have been
+   * sliced from.
+   */
+  private internStrings(result: IndexedFileResult): void {
+    visitPooledStrings(result, value => this.strings.intern(value));
+    for (const symbol of result.symbols) {
+      symbol.id = flatten(symbol.id);
+      if (symbol.canonicalId !== undefined) { symbol.canonicalId = flatten(symbol.canonicalId); }
+      if (symbol.doc !== undefined) { symbol.doc = flatten(symbol.doc); }
+      if (symbol.tags) { symbol.tags = this.share(symbol.tags); }
+      if (symbol.metadata) { symbol.metadata = this.share(symbol.metadata); }
+    }
+    if (result.tags) { result.tags = this.share(result.tags); }
+  }
+
+  /**
+   * The stored object equal to value, or value which is stored from now
+   * on. Shared objects must not be changed; nothing changes the tags or
+   * metadata of an indexed symbol.
+   */
+  private share<T extends object>(value: T): T {
+    const key = JSON.stringify(value);
+    const shared = this.shared.get(key);
+    if (shared) {
+      return shared as T;
+    }
+    this.shared.set(key, value);
+    return value;
+  }
+
+  /**
+   * Drop pooled strings no file holds any more. Strings of replaced and
+   * removed results stay pooled until then; a sweep once there were as
+   * many replacements as there are files keeps the pool within about
+   * twice what live files use.
+   */
+  private sweepIfStale(): void {
+    if (this.replaced <= Math.max(SWEEP_MIN_REPLACED, this.files.size)) {
+      return;
+    }
+    this.strings.sweep(keep => {
+      for (const [uri, file] of this.files) {
+        keep(uri);
+        visitPooledStrings(file.result, value => (keep(value), value));
+        file.references.forEachString(keep);
+      }
+    });
+    // Files stored from now on share new objects; the old ones stay
+    // shared by the files that have them
+    this.shared.clear();
+    this.replaced = 0;
+  }
+
+  /**
+   * Goes up each time a file is indexed or removed, so every edit of an
+   * open document is a new generation.
+   */
+  getGeneration(): number {
+    return this.generation;
   }
 
   /**
    * Check if a file is in the dynamic index.
    */
   hasFile(uri: string): boolean {
-    return this.fileSymbols.has(uri);
+    return this.files.has(uri);
   }
 
   /**
@@ -210,85 +271,14 @@ export class DynamicIndex implements ISymbolIndex {
       // Hash mismatch or missing - index is stale, trigger immediate re-parsing
       console.info(`[DynamicIndex] Self-healing: Hash mismatch for ${filePath}, repairing index`);
 
-      // O(1) CLEANUP: Remove old symbols using reverse index before adding new ones
-      const oldSymbolNames = this.fileToSymbolNames.get(filePath);
-      if (oldSymbolNames) {
-        for (const name of oldSymbolNames) {
-          const uriSet = this.symbolNameIndex.get(name);
-          if (uriSet) {
-            uriSet.delete(filePath);
-            if (uriSet.size === 0) {
-              this.symbolNameIndex.delete(name);
-            }
-          }
-        }
-      }
-      
-      // O(1) CLEANUP: Remove old symbol IDs using reverse index
-      const oldSymbolIds = this.fileToSymbolIds.get(filePath);
-      if (oldSymbolIds) {
-        for (const id of oldSymbolIds) {
-          this.symbolIdIndex.delete(id);
-        }
-      }
-
-      // O(1) CLEANUP: Remove old references using reverse index
-      const oldReferenceNames = this.fileToReferenceNames.get(filePath);
-      if (oldReferenceNames) {
-        for (const symbolName of oldReferenceNames) {
-          const uriSet = this.referenceMap.get(symbolName);
-          if (uriSet) {
-            uriSet.delete(filePath);
-            if (uriSet.size === 0) {
-              this.referenceMap.delete(symbolName);
-            }
-          }
-        }
-      }
-      
       // Use the indexer directly for immediate, synchronous parsing (high priority)
       const indexer = this.languageRouter || this.symbolIndexer;
-      const result = await indexer.indexFile(filePath, content);
+      const result = tagFileResult(await indexer.indexFile(filePath, content), content);
       
       // Update the index with fresh symbols
-      this.fileSymbols.set(filePath, result);
+      this.setFileResult(filePath, result);
       this.fileHashes.set(filePath, currentHash);
 
-      // Update symbol name index, ID index, and reverse indexes
-      const newSymbolNames = new Set<string>();
-      const newSymbolIds = new Set<string>();
-      for (const symbol of result.symbols) {
-        // Update name index
-        let uriSet = this.symbolNameIndex.get(symbol.name);
-        if (!uriSet) {
-          uriSet = new Set();
-          this.symbolNameIndex.set(symbol.name, uriSet);
-        }
-        uriSet.add(filePath);
-        newSymbolNames.add(symbol.name);
-        
-        // Update ID index for O(1) findDefinitionById
-        this.symbolIdIndex.set(symbol.id, symbol);
-        newSymbolIds.add(symbol.id);
-      }
-      this.fileToSymbolNames.set(filePath, newSymbolNames);
-      this.fileToSymbolIds.set(filePath, newSymbolIds);
-
-      // Update reference map and reverse index for O(1) findReferencesByName
-      const newReferenceNames = new Set<string>();
-      if (result.references) {
-        for (const ref of result.references) {
-          let refUriSet = this.referenceMap.get(ref.symbolName);
-          if (!refUriSet) {
-            refUriSet = new Set();
-            this.referenceMap.set(ref.symbolName, refUriSet);
-          }
-          refUriSet.add(filePath);
-          newReferenceNames.add(ref.symbolName);
-        }
-      }
-      this.fileToReferenceNames.set(filePath, newReferenceNames);
-
       console.info(`[DynamicIndex] Self-healing complete: ${result.symbols.length} symbols indexed for ${filePath}`);
       return true;
     } catch (error) {
@@ -307,7 +297,7 @@ export class DynamicIndex implements ISymbolIndex {
 
     // Only scan files that contain the symbol
     for (const uri of uriSet) {
-      const fileResult = this.fileSymbols.get(uri);
+      const fileResult = this.files.get(uri)?.result;
       if (fileResult) {
         for (const symbol of fileResult.symbols) {
           if (symbol.name === name) {
@@ -344,7 +334,7 @@ export class DynamicIndex implements ISymbolIndex {
     const seen = new Set<string>();
     
     for (const uri of urisWithReferences) {
-      const fileResult = this.fileSymbols.get(uri);
+      const fileResult = this.files.get(uri)?.result;
       if (fileResult) {
         for (const symbol of fileResult.symbols) {
           // Return symbols that match the referenced name
@@ -392,7 +382,7 @@ export class DynamicIndex implements ISymbolIndex {
         break;
       }
       
-      const fileResult = this.fileSymbols.get(uri);
+      const fileResult = this.files.get(uri)?.result;
       if (fileResult) {
         for (const symbol of fileResult.symbols) {
           // Use fuzzy matching on actual symbol
@@ -415,7 +405,7 @@ export class DynamicIndex implements ISymbolIndex {
   }
 
   async getFileSymbols(uri: string): Promise<IndexedSymbol[]> {
-    const fileResult = this.fileSymbols.get(uri);
+    const fileResult = this.files.get(uri)?.result;
     return fileResult ? fileResult.symbols : [];
   }
 
@@ -435,24 +425,38 @@ export class DynamicIndex implements ISymbolIndex {
     
     // Only load and scan files that are known to have references to this symbol
     for (const uri of candidateUris) {
-      const fileResult = this.fileSymbols.get(uri);
-      if (fileResult && fileResult.references) {
-        for (const ref of fileResult.references) {
-          if (ref.symbolName === name) {
-            references.push(ref);
-          }
-        }
+      const file = this.files.get(uri);
+      if (file) {
+        references.push(...file.references.byName(name));
       }
     }
     
     return references;
   }
 
+  /**
+   * Count the non-local references to each name (popularity ranking).
+   */
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    const counts = new Map<string, number>();
+    for (const name of names) {
+      if (counts.has(name)) {
+        continue;
+      }
+      let count = 0;
+      for (const uri of this.referenceMap.get(name) ?? []) {
+        count += this.files.get(uri)?.references.count(name, true) ?? 0;
+      }
+      counts.set(name, count);
+    }
+    return counts;
+  }
+
   /**
    * Get import info for a file.
    */
   async getFileImports(uri: string): Promise<ImportInfo[]> {
-    const fileResult = this.fileSymbols.get(uri);
+    const fileResult = this.files.get(uri)?.result;
     return fileResult?.imports || [];
   }
 
@@ -460,15 +464,48 @@ export class DynamicIndex implements ISymbolIndex {
    * Get re-export info for a file (for barrel file resolution).
    */
   async getFileReExports(uri: string): Promise<ReExportInfo[]> {
-    const fileResult = this.fileSymbols.get(uri);
+    const fileResult = this.files.get(uri)?.result;
     return fileResult?.reExports || [];
   }
 
+  /**
+   * Get the classifications of a file (generated, test, mock, example).
+   */
+  async getFileTags(uri: string): Promise<CodeTag[]> {
+    const fileResult = this.files.get(uri)?.result;
+    return fileResult?.tags || [];
+  }
+
   /**
    * Get all URIs currently in the dynamic index.
    */
   getIndexedFiles(): string[] {
-    return Array.from(this.fileSymbols.keys());
+    return Array.from(this.files.keys());
+  }
+
+  /**
+   * Get the full indexed result of a file.
+   */
+  getFileResult(uri: string): IndexedFileResult | undefined {
+    const file = this.files.get(uri);
+    if (!file) {
+      return undefined;
+    }
+    let result = file.view?.deref();
+    if (!result) {
+      result = { ...file.result, references: file.references.toArray() };
+      file.view = new WeakRef(result);
+    }
+    return result;
+  }
+
+  /**
+   * The indexed result of a file without its references, which
+   * getFileResult() has to build from their packed form; for hashes,
+   * symbols and imports.
+   */
+  getFileInfo(uri: string): Omit<IndexedFileResult, 'references'> | undefined {
+    return this.files.get(uri)?.result;
   }
 
   /**
@@ -476,12 +513,90 @@ export class DynamicIndex implements ISymbolIndex {
    */
   getStats(): { files: number; symbols: number } {
     let totalSymbols = 0;
-    for (const [_, fileResult] of this.fileSymbols) {
-      totalSymbols += fileResult.symbols.length;
+    for (const file of this.files.values()) {
+      totalSymbols += file.result.symbols.length;
     }
     return {
-      files: this.fileSymbols.size,
+      files: this.files.size,
       symbols: totalSymbols
     };
   }
 }
+
+/**
+ * Pass each string a result shares with other files through visit() and
+ * store what it returns: names, kinds, containers, paths, import
+ * specifiers and the string values of plugin metadata.
+ */
+function visitPooledStrings(result: IndexedFileResult, visit: (value: string) => string): void {
+  const fields = (object: object, keys: readonly string[]) => {
+    const record = object as Record<string, unknown>;
+    for (const key of keys) {
+      const value = record[key];
+      if (typeof value === 'string') {
+        record[key] = visit(value);
+      }
+    }
+  };
+  const all = (values: string[] | undefined) => {
+    if (values) {
+      for (let i = 0; i < values.length; i++) {
+        values[i] = visit(values[i]);
+      }
+    }
+  };
+
+  for (const symbol of result.symbols) {
+    fields(symbol, SYMBOL_STRINGS);
+    fields(symbol.location, ['uri']);
+    all(symbol.implements);
+    all(symbol.captures);
+    for (const parameter of symbol.typeParameters ?? []) {
+      fields(parameter, ['name', 'constraint']);
+    }
+    if (symbol.metadata) {
+      visitRecord(symbol.metadata, visit, METADATA_DEPTH);
+    }
+  }
+  for (const info of result.imports ?? []) {
+    fields(info, ['localName', 'moduleSpecifier', 'exportedName']);
+  }
+  for (const info of result.reExports ?? []) {
+    fields(info, ['moduleSpecifier']);
+    all(info.exportedNames);
+  }
+  for (const pending of result.pendingReferences ?? []) {
+    fields(pending, ['container', 'member', 'containerName']);
+    fields(pending.location, ['uri']);
+  }
+}
+
+const SYMBOL_STRINGS = [
+  'name', 'kind', 'filePath', 'containerName', 'containerKind', 'fullContainerPath',
+  'extends', 'signature', 'deprecated', 'value'
+] as const;
+
+/** Plugin metadata is namespaced: metadata.go.importPath */
+const METADATA_DEPTH = 2;
+
+function visitRecord(record: Record<string, unknown>, visit: (value: string) => string, depth: number): void {
+  if (Object.isFrozen(record)) {
+    return;
+  }
+  for (const key of Object.keys(record)) {
+    const value = record[key];
+    if (typeof value === 'string') {
+      record[key] = visit(value);
+    } else if (Array.isArray(value)) {
+      if (!Object.isFrozen(value)) {
+        value.forEach((item, i) => {
+          if (typeof item === 'string') {
+            value[i] = visit(item);
+          }
+        });
+      }
+    } else if (depth > 1 && value !== null && typeof value === 'object') {
+      visitRecord(value as Record<string, unknown>, visit, depth - 1);
+    }
+  }
+}
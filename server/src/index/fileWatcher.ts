@@ -5,9 +5,30 @@ import { ConfigurationManager } from '../config/configurationManager.js';
 import { ILogger } from '../utils/Logger.js';
 import { URI } from 'vscode-uri';
 import * as chokidar from 'chokidar';
+import { IGNORE_FILE_NAMES } from '../utils/ignoreRules.js';
 import * as path from 'path';
 import type { DeadCodeHandler } from '../handlers/deadCodeHandler.js';
 
+/**
+ * Event emitted after the background index has been updated for a file.
+ */
+export interface IndexUpdateEvent {
+  uri: string;
+  change: 'updated' | 'removed';
+  trigger: string;
+  durationMs: number;
+}
+
+/**
+ * Listener for index updates. Receives one event per re-indexed or removed file.
+ */
+export type IndexUpdateListener = (event: IndexUpdateEvent) => void;
+
+/**
+ * LSP notification sent to the client after each index update.
+ */
+export const INDEX_UPDATED_NOTIFICATION = 'smart-indexer/indexUpdated';
+
 /**
  * FileWatcher with per-file debouncing for live index synchronization.
  * 
@@ -23,7 +44,7 @@ export class FileWatcher {
   private documents: TextDocuments<TextDocument>;
   private backgroundIndex: BackgroundIndex;
   private configManager: ConfigurationManager;
-  private workspaceRoot: string;
+  private workspaceRoots: string[];
   private logger: ILogger;
   private deadCodeHandler: DeadCodeHandler | null = null;
   
@@ -37,12 +58,15 @@ export class FileWatcher {
   // Track files currently being indexed to avoid duplicate jobs
   private indexingInProgress: Set<string> = new Set();
 
+  // Subscribers notified after each index update
+  private updateListeners: Set<IndexUpdateListener> = new Set();
+
   constructor(
     connection: Connection,
     documents: TextDocuments<TextDocument>,
     backgroundIndex: BackgroundIndex,
     configManager: ConfigurationManager,
-    workspaceRoot: string,
+    workspaceRoots: string[],
     logger: ILogger,
     debounceDelayMs: number = 600
   ) {
@@ -50,11 +74,29 @@ export class FileWatcher {
     this.documents = documents;
     this.backgroundIndex = backgroundIndex;
     this.configManager = configManager;
-    this.workspaceRoot = workspaceRoot;
+    this.workspaceRoots = [...workspaceRoots];
     this.logger = logger;
     this.debounceDelayMs = debounceDelayMs;
   }
 
+  /**
+   * Watch a folder added to the workspace.
+   */
+  addRoot(root: string): void {
+    if (!this.workspaceRoots.includes(root)) {
+      this.workspaceRoots.push(root);
+      this.fsWatcher?.add(root);
+    }
+  }
+
+  /**
+   * Stop watching a folder removed from the workspace.
+   */
+  removeRoot(root: string): void {
+    this.workspaceRoots = this.workspaceRoots.filter(r => r !== root);
+    this.fsWatcher?.unwatch(root);
+  }
+
   /**
    * Set the dead code handler (set after initialization).
    */
@@ -62,6 +104,41 @@ export class FileWatcher {
     this.deadCodeHandler = handler;
   }
 
+  /**
+   * Subscribe to index updates (re-indexed or removed files).
+   * The client is always notified via INDEX_UPDATED_NOTIFICATION as well.
+   * 
+   * @returns Disposable that removes the listener
+   */
+  onIndexUpdated(listener: IndexUpdateListener): { dispose(): void } {
+    this.updateListeners.add(listener);
+    return {
+      dispose: () => {
+        this.updateListeners.delete(listener);
+      }
+    };
+  }
+
+  /**
+   * Notify the client and all subscribers about an index update.
+   * Listener errors are logged and never interrupt indexing.
+   */
+  private emitIndexUpdate(event: IndexUpdateEvent): void {
+    try {
+      this.connection.sendNotification(INDEX_UPDATED_NOTIFICATION, event);
+    } catch (error) {
+      this.logger.error(`[FileWatcher] Error sending index update notification: ${error}`);
+    }
+
+    for (const listener of this.updateListeners) {
+      try {
+        listener(event);
+      } catch (error) {
+        this.logger.error(`[FileWatcher] Index update listener failed: ${error}`);
+      }
+    }
+  }
+
   /**
    * Initialize the file watcher and register all listeners.
    */
@@ -87,8 +164,8 @@ export class FileWatcher {
     try {
       const config = this.configManager.getConfig();
       
-      // Watch workspace for file changes
-      this.fsWatcher = chokidar.watch(this.workspaceRoot, {
+      // Watch every workspace folder for file changes
+      this.fsWatcher = chokidar.watch(this.workspaceRoots, {
         ignored: [
           '**/node_modules/**',
           '**/.git/**',
@@ -106,6 +183,17 @@ export class FileWatcher {
         }
       });
 
+      // Ignore file edits change what is excluded from now on, CODEOWNERS edits who owns what
+      this.fsWatcher.on('all', (_event: string, filePath: string) => {
+        if (IGNORE_FILE_NAMES.includes(path.basename(filePath))) {
+          this.connection.console.info(`[FileWatcher] Ignore file changed: ${filePath}`);
+          this.configManager.clearIgnoreCache();
+        } else if (path.basename(filePath) === 'CODEOWNERS') {
+          this.connection.console.info(`[FileWatcher] CODEOWNERS changed: ${filePath}`);
+          this.configManager.getCodeOwners()?.clear();
+        }
+      });
+
       // External file change
       this.fsWatcher.on('change', (filePath: string) => {
         const fullPath = path.resolve(filePath);
@@ -231,6 +319,8 @@ export class FileWatcher {
       this.connection.console.info(
         `[FileWatcher] Re-indexed ${path.basename(filePath)} in ${duration}ms (trigger: ${trigger})`
       );
+
+      this.emitIndexUpdate({ uri: filePath, change: 'updated', trigger, durationMs: duration });
     } finally {
       this.indexingInProgress.delete(filePath);
     }
@@ -248,11 +338,15 @@ export class FileWatcher {
       this.indexingInProgress.delete(filePath);
       
       // Purge from background index
+      const startTime = Date.now();
       await this.backgroundIndex.removeFile(filePath);
+      const duration = Date.now() - startTime;
       
       this.connection.console.info(
         `[FileWatcher] Removed deleted file from index: ${path.basename(filePath)}`
       );
+
+      this.emitIndexUpdate({ uri: filePath, change: 'removed', trigger: 'file-deleted', durationMs: duration });
     } catch (error) {
       this.logger.error(`[FileWatcher] Error handling file deletion ${filePath}: ${error}`);
     }
@@ -274,7 +368,7 @@ export class FileWatcher {
       '.py', '.java', '.go', '.rs', '.c', '.cpp', '.h', '.hpp'
     ];
     
-    if (!supportedExtensions.includes(ext)) {
+    if (!supportedExtensions.includes(ext) && !this.configManager.getExtractorExtensions().includes(ext)) {
       return false;
     }
     
@@ -323,6 +417,7 @@ export class FileWatcher {
     }
     
     this.indexingInProgress.clear();
+    this.updateListeners.clear();
     
     this.connection.console.info('[FileWatcher] File watcher disposed');
   }
@@ -0,0 +1,153 @@
+/**
+ * Index Encryption Tests
+ *
+ * Verifies encrypted round trips under env and command key providers,
+ * that the wrong key, a missing provider and changed data are reported,
+ * and that files that are not encrypted are read as they are only when
+ * not encrypting or allowed.
+ */
+
+import { describe, it, expect } from 'vitest';
+import * as crypto from 'crypto';
+import {
+  CommandKeyProvider,
+  EnvKeyProvider,
+  INDEX_KEY_ENV_VAR,
+  IndexKeys,
+  indexKeysFor,
+  isEncryptedIndex,
+  KeyCommandRunner
+} from './indexEncryption.js';
+import { DEFAULT_ENCRYPTION_CONFIG } from '../config/configurationManager.js';
+
+const KEY = crypto.randomBytes(32).toString('base64');
+const OTHER_KEY = crypto.randomBytes(32).toString('hex');
+
+function envKeys(key: string | undefined): IndexKeys {
+  const provider = new EnvKeyProvider(INDEX_KEY_ENV_VAR, { [INDEX_KEY_ENV_VAR]: key });
+  return new IndexKeys(provider);
+}
+
+async function* chunksOf(...parts: string[]): AsyncGenerator<Uint8Array> {
+  for (const part of parts) {
+    yield Buffer.from(part);
+  }
+}
+
+describe('IndexKeys', () => {
+  it('should round-trip data under an environment key', async () => {
+    const keys = envKeys(KEY);
+    const data = Buffer.from('saved index '.repeat(50));
+    const encrypted = await keys.encrypt(data);
+    expect(isEncryptedIndex(encrypted)).toBe(true);
+    expect(Buffer.from(encrypted).includes('saved index')).toBe(false);
+    expect(Buffer.from(await keys.decrypt(encrypted, 'index.bin')).equals(data)).toBe(true);
+
+    // Chunked output decrypts the same
+    const chunks: Uint8Array[] = [];
+    for await (const chunk of keys.encryptChunks(chunksOf('saved ', 'index'))) {
+      chunks.push(chunk);
+    }
+    expect(Buffer.from(await keys.decrypt(Buffer.concat(chunks), 'index.bin')).toString()).toBe('saved index');
+  });
+
+  it('should read files that are not encrypted as they are', async () => {
+    const data = Buffer.from([0x84, 0xa6, 0x66, 0x6f]);
+    expect(await new IndexKeys(undefined).decrypt(data, 'index.bin')).toBe(data);
+    expect(await new IndexKeys(undefined).encrypt(data)).toBe(data);
+    expect(isEncryptedIndex(Buffer.from('SIX'))).toBe(false);
+  });
+
+  it('should refuse files that are not encrypted while encrypting, unless allowed', async () => {
+    const data = Buffer.from([0x84, 0xa6, 0x66, 0x6f]);
+    await expect(envKeys(KEY).decrypt(data, 'index.bin')).rejects.toThrow(
+      'index.bin is not encrypted, but encryption is enabled; set encryption.allowPlaintext to read it'
+    );
+    const provider = new EnvKeyProvider(INDEX_KEY_ENV_VAR, { [INDEX_KEY_ENV_VAR]: KEY });
+    expect(await new IndexKeys(provider, [provider], true).decrypt(data, 'index.bin')).toBe(data);
+  });
+
+  it('should report the wrong key, a missing key and changed data', async () => {
+    const encrypted = Buffer.from(await envKeys(KEY).encrypt(Buffer.from('secret')));
+
+    await expect(envKeys(OTHER_KEY).decrypt(encrypted, 'index.bin')).rejects.toThrow(
+      /Could not decrypt index\.bin: Encrypted with key [0-9a-f]{16}, but SMART_INDEXER_INDEX_KEY holds key/
+    );
+    await expect(envKeys(undefined).decrypt(encrypted, 'index.bin')).rejects.toThrow('No index key');
+    await expect(envKeys('c2hvcnQ=').encrypt(Buffer.from('x'))).rejects.toThrow('must hold a 256-bit key');
+    await expect(new IndexKeys(undefined).decrypt(encrypted, 'index.bin')).rejects.toThrow(
+      "index.bin is encrypted (key provider 'env'); configure encryption to read it"
+    );
+
+    const tampered = Buffer.from(encrypted);
+    tampered[tampered.length - 20] ^= 0x01;
+    await expect(envKeys(KEY).decrypt(tampered, 'index.bin')).rejects.toThrow('it was modified or cut short');
+    await expect(envKeys(KEY).decrypt(encrypted.subarray(0, encrypted.length - 4), 'index.bin')).rejects.toThrow(
+      'it was modified or cut short'
+    );
+
+    // The header is authenticated too
+    const header = Buffer.from(encrypted);
+    const keyId = header.indexOf('"keyId":"') + 9;
+    header[keyId] = header[keyId] === 0x30 ? 0x31 : 0x30;
+    await expect(envKeys(KEY).decrypt(header, 'index.bin')).rejects.toThrow('Could not decrypt index.bin');
+  });
+
+  it('should wrap keys with a key command', async () => {
+    const masterKey = crypto.randomBytes(32);
+    const requests: Array<{ command: string; method: string }> = [];
+    // A KMS that "wraps" by XOR with its key
+    const run: KeyCommandRunner = async (command, _args, input) => {
+      const { method, params } = JSON.parse(input);
+      requests.push({ command, method });
+      const xor = (value: string) => Buffer.from(Buffer.from(value, 'base64').map((b, i) => b ^ masterKey[i])).toString('base64');
+      return method === 'wrapKey'
+        ? JSON.stringify({ result: { keyId: 'kms-key-1', wrappedKey: xor(params.key) } })
+        : JSON.stringify({ result: { key: xor(params.wrappedKey) } });
+    };
+    const keys = new IndexKeys(new CommandKeyProvider('./tools/kms.sh', ['--region', 'eu'], '/repo', run));
+
+    const encrypted = await keys.encrypt(Buffer.from('secret'));
+    expect(Buffer.from(await keys.decrypt(encrypted, 'index.bin')).toString()).toBe('secret');
+    expect(requests).toEqual([
+      { command: '/repo/tools/kms.sh', method: 'wrapKey' },
+      { command: '/repo/tools/kms.sh', method: 'unwrapKey' }
+    ]);
+
+    const refusing = new IndexKeys(new CommandKeyProvider('kms', [], undefined, async () =>
+      JSON.stringify({ error: { message: 'AccessDenied' } })
+    ));
+    await expect(refusing.decrypt(encrypted, 'index.bin')).rejects.toThrow("Key command 'kms': AccessDenied");
+    const failing = new IndexKeys(new CommandKeyProvider('kms', [], undefined, async () => {
+      throw new Error('exit code 1');
+    }));
+    await expect(failing.encrypt(Buffer.from('x'))).rejects.toThrow("Key command 'kms' failed: exit code 1");
+  });
+
+  it('should encrypt with the config only when enabled, and decrypt either way', async () => {
+    const env = { [INDEX_KEY_ENV_VAR]: KEY };
+    const enabled = indexKeysFor({ ...DEFAULT_ENCRYPTION_CONFIG, enabled: true }, { env });
+    const disabled = indexKeysFor(DEFAULT_ENCRYPTION_CONFIG, { env });
+    expect([enabled.encrypts, disabled.encrypts]).toEqual([true, false]);
+
+    const encrypted = await enabled.encrypt(Buffer.from('secret'));
+    expect(Buffer.from(await disabled.decrypt(encrypted, 'index.bin')).toString()).toBe('secret');
+    await expect(enabled.decrypt(Buffer.from('secret'), 'index.bin')).rejects.toThrow('is not encrypted');
+    const migrating = indexKeysFor({ ...DEFAULT_ENCRYPTION_CONFIG, enabled: true, allowPlaintext: true }, { env });
+    expect(Buffer.from(await migrating.decrypt(Buffer.from('secret'), 'index.bin')).toString()).toBe('secret');
+  });
+
+  it('should not run the key command when a provider replaces it', async () => {
+    const env = { [INDEX_KEY_ENV_VAR]: KEY };
+    const command = { ...DEFAULT_ENCRYPTION_CONFIG, provider: 'command' as const, command: '/nonexistent/kms' };
+    const envEncrypted = await envKeys(KEY).encrypt(Buffer.from('secret'));
+    const kms = { name: 'command', wrapKey: async () => { throw new Error('unused'); }, unwrapKey: async () => { throw new Error('unused'); } };
+    const keys = indexKeysFor(command, { provider: kms, env });
+    expect(Buffer.from(await keys.decrypt(envEncrypted, 'index.bin')).toString()).toBe('secret');
+    const commandEncrypted = await new IndexKeys(new CommandKeyProvider('kms', [], undefined, async () =>
+      JSON.stringify({ result: { keyId: 'k1', wrappedKey: 'AA==' } }))).encrypt(Buffer.from('secret'));
+    await expect(keys.decrypt(commandEncrypted, 'index.bin')).rejects.toThrow(
+      'Could not decrypt index.bin: unused'
+    );
+  });
+});
@@ -0,0 +1,338 @@
+import { execFile } from 'child_process';
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { EncryptionConfig } from '../config/configurationManager.js';
+
+/**
+ * Encryption of index files at rest: saved indexes (save(),
+ * indexDirToFile()) and indexes pushed to remote storage, which hold the
+ * names, signatures, doc comments and constant values of the code.
+ *
+ * Each file is encrypted with AES-256-GCM under a fresh data key. The data
+ * key is wrapped by a key provider and stored, wrapped, in the file's
+ * header:
+ * - `env`: wrapped with a 256-bit key from an environment variable
+ * - `command`: wrapped by a helper process, e.g. one calling a KMS
+ * - any IndexKeyProvider a library caller passes
+ *
+ *   SIXE | u32 LE header length | header (JSON) | ciphertext | GCM tag
+ *
+ * The header is authenticated with the data, so a file that was changed,
+ * cut short or has a swapped header does not decrypt.
+ */
+
+export const ENCRYPTED_INDEX_MAGIC = 'SIXE';
+export const INDEX_KEY_ENV_VAR = 'SMART_INDEXER_INDEX_KEY';
+
+const PREAMBLE_SIZE = 8; // magic + u32 header length
+const CIPHER = 'aes-256-gcm';
+const KEY_BYTES = 32;
+const IV_BYTES = 12;
+const TAG_BYTES = 16;
+const COMMAND_TIMEOUT_MS = 30000;
+
+export class IndexEncryptionError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = 'IndexEncryptionError';
+  }
+}
+
+export interface WrappedKey {
+  /** Which key of the provider wrapped it, e.g. a KMS key ARN */
+  keyId: string;
+  wrappedKey: Buffer;
+}
+
+/**
+ * Wraps and unwraps the data keys of encrypted index files.
+ */
+export interface IndexKeyProvider {
+  /** Recorded in each file; a file is unwrapped by the provider of that name */
+  readonly name: string;
+  wrapKey(key: Buffer): Promise<WrappedKey>;
+  unwrapKey(wrapped: WrappedKey): Promise<Buffer>;
+}
+
+interface EncryptedIndexHeader {
+  version: 1;
+  cipher: typeof CIPHER;
+  provider: string;
+  keyId: string;
+  /** Wrapped data key and IV, base64 */
+  key: string;
+  iv: string;
+}
+
+/**
+ * Wraps data keys with AES-256-GCM under a key read from an environment
+ * variable, base64 or hex (`openssl rand -base64 32`). Its key id is a
+ * fingerprint of the key, so a file encrypted under another key is
+ * reported as such instead of as corrupt.
+ */
+export class EnvKeyProvider implements IndexKeyProvider {
+  readonly name = 'env';
+
+  constructor(
+    private readonly envVar: string = INDEX_KEY_ENV_VAR,
+    private readonly env: Record<string, string | undefined> = process.env
+  ) {}
+
+  async wrapKey(key: Buffer): Promise<WrappedKey> {
+    const masterKey = this.masterKey();
+    const iv = crypto.randomBytes(IV_BYTES);
+    const cipher = crypto.createCipheriv(CIPHER, masterKey, iv);
+    const wrapped = Buffer.concat([iv, cipher.update(key), cipher.final(), cipher.getAuthTag()]);
+    return { keyId: fingerprint(masterKey), wrappedKey: wrapped };
+  }
+
+  async unwrapKey({ keyId, wrappedKey }: WrappedKey): Promise<Buffer> {
+    const masterKey = this.masterKey();
+    if (keyId !== fingerprint(masterKey)) {
+      throw new IndexEncryptionError(`Encrypted with key ${keyId}, but ${this.envVar} holds key ${fingerprint(masterKey)}`);
+    }
+    try {
+      const decipher = crypto.createDecipheriv(CIPHER, masterKey, wrappedKey.subarray(0, IV_BYTES));
+      decipher.setAuthTag(wrappedKey.subarray(wrappedKey.length - TAG_BYTES));
+      return Buffer.concat([decipher.update(wrappedKey.subarray(IV_BYTES, wrappedKey.length - TAG_BYTES)), decipher.final()]);
+    } catch {
+      throw new IndexEncryptionError('The wrapped data key is corrupt');
+    }
+  }
+
+  private masterKey(): Buffer {
+    const value = this.env[this.envVar]?.trim();
+    if (!value) {
+      throw new IndexEncryptionError(`No index key: set ${this.envVar} to a 256-bit key (openssl rand -base64 32)`);
+    }
+    const key = /^[0-9a-f]{64}$/i.test(value) ? Buffer.from(value, 'hex') : Buffer.from(value, 'base64');
+    if (key.length !== KEY_BYTES) {
+      throw new IndexEncryptionError(`${this.envVar} must hold a 256-bit key, base64 or hex (openssl rand -base64 32)`);
+    }
+    return key;
+  }
+}
+
+/** Runs `command args...` with a request on stdin; returns the result it writes */
+export type KeyCommandRunner = (command: string, args: string[], input: string) => Promise<string>;
+
+/**
+ * A key service behind a helper command, such as a script calling a KMS.
+ * It is run once per key, with one request on stdin, and answers on
+ * stdout in the format of the JSON-lines helpers:
+ *
+ *   -> {"method":"wrapKey","params":{"key":"<base64>"}}
+ *   <- {"result":{"keyId":"arn:aws:kms:...","wrappedKey":"<base64>"}}
+ *   -> {"method":"unwrapKey","params":{"keyId":"...","wrappedKey":"<base64>"}}
+ *   <- {"result":{"key":"<base64>"}}
+ *
+ * An `{"error":{"message":"..."}}` answer or a non-zero exit fails the
+ * read or write of the index.
+ */
+export class CommandKeyProvider implements IndexKeyProvider {
+  readonly name = 'command';
+
+  constructor(
+    private readonly command: string,
+    private readonly args: string[] = [],
+    private readonly cwd?: string,
+    private readonly run: KeyCommandRunner = runCommand
+  ) {}
+
+  async wrapKey(key: Buffer): Promise<WrappedKey> {
+    const result = await this.request<{ keyId?: unknown; wrappedKey?: unknown }>('wrapKey', { key: key.toString('base64') });
+    if (typeof result?.keyId !== 'string' || typeof result.wrappedKey !== 'string') {
+      throw new IndexEncryptionError(`Key command '${this.command}' returned no keyId and wrappedKey`);
+    }
+    return { keyId: result.keyId, wrappedKey: Buffer.from(result.wrappedKey, 'base64') };
+  }
+
+  async unwrapKey({ keyId, wrappedKey }: WrappedKey): Promise<Buffer> {
+    const result = await this.request<{ key?: unknown }>('unwrapKey', { keyId, wrappedKey: wrappedKey.toString('base64') });
+    const key = typeof result?.key === 'string' ? Buffer.from(result.key, 'base64') : undefined;
+    if (key?.length !== KEY_BYTES) {
+      throw new IndexEncryptionError(`Key command '${this.command}' returned no 256-bit key`);
+    }
+    return key;
+  }
+
+  private async request<T>(method: string, params: unknown): Promise<T | undefined> {
+    // ./tools/kms.sh style commands are relative to the workspace
+    const command = this.cwd && /^\.\.?[\\/]/.test(this.command) ? path.resolve(this.cwd, this.command) : this.command;
+    let output: string;
+    try {
+      output = await this.run(command, this.args, JSON.stringify({ method, params }) + '\n');
+    } catch (error) {
+      throw new IndexEncryptionError(`Key command '${this.command}' failed: ${error instanceof Error ? error.message : error}`);
+    }
+    let response: { result?: T; error?: { message?: string } };
+    try {
+      response = JSON.parse(output.trim().split('\n').pop() ?? '');
+    } catch {
+      throw new IndexEncryptionError(`Key command '${this.command}' did not answer with JSON`);
+    }
+    if (response?.error) {
+      throw new IndexEncryptionError(`Key command '${this.command}': ${response.error.message ?? 'failed'}`);
+    }
+    return response?.result;
+  }
+}
+
+/**
+ * The keys index files are written and read with. Files are encrypted
+ * when there is a provider to encrypt with; encrypted files are read with
+ * whichever of the providers wrote them. Files that are not encrypted are
+ * read as they are when not encrypting; when encrypting they are refused,
+ * so a swapped plaintext file is not trusted, unless allowPlaintext.
+ */
+export class IndexKeys {
+  constructor(
+    private readonly encryptWith: IndexKeyProvider | undefined,
+    private readonly providers: IndexKeyProvider[] = encryptWith ? [encryptWith] : [],
+    private readonly allowPlaintext: boolean = false
+  ) {}
+
+  get encrypts(): boolean {
+    return this.encryptWith !== undefined;
+  }
+
+  /** data encrypted, or as it is when not encrypting */
+  async encrypt(data: Uint8Array): Promise<Uint8Array> {
+    if (!this.encryptWith) {
+      return data;
+    }
+    const chunks: Uint8Array[] = [];
+    for await (const chunk of this.encryptChunks(oneChunk(data))) {
+      chunks.push(chunk);
+    }
+    return Buffer.concat(chunks);
+  }
+
+  /** Like encrypt(), for data produced in chunks */
+  async *encryptChunks(chunks: AsyncIterable<Uint8Array>): AsyncGenerator<Uint8Array> {
+    if (!this.encryptWith) {
+      yield* chunks;
+      return;
+    }
+    const key = crypto.randomBytes(KEY_BYTES);
+    const iv = crypto.randomBytes(IV_BYTES);
+    const { keyId, wrappedKey } = await this.encryptWith.wrapKey(key);
+    const preamble = encodePreamble({
+      version: 1,
+      cipher: CIPHER,
+      provider: this.encryptWith.name,
+      keyId,
+      key: wrappedKey.toString('base64'),
+      iv: iv.toString('base64')
+    });
+    const cipher = crypto.createCipheriv(CIPHER, key, iv);
+    cipher.setAAD(preamble);
+    yield preamble;
+    for await (const chunk of chunks) {
+      yield cipher.update(chunk);
+    }
+    yield Buffer.concat([cipher.final(), cipher.getAuthTag()]);
+  }
+
+  /**
+   * data decrypted, or as it is when it is not encrypted and allowed to
+   * be. source names the data in errors (a file path or URL).
+   */
+  async decrypt(data: Uint8Array, source: string): Promise<Uint8Array> {
+    if (!isEncryptedIndex(data)) {
+      if (this.encryptWith && !this.allowPlaintext) {
+        throw new IndexEncryptionError(
+          `${source} is not encrypted, but encryption is enabled; set encryption.allowPlaintext to read it`
+        );
+      }
+      return data;
+    }
+    const { header, dataStart } = decodePreamble(data, source);
+    const provider = this.providers.find(candidate => candidate.name === header.provider);
+    if (!provider) {
+      throw new IndexEncryptionError(`${source} is encrypted (key provider '${header.provider}'); configure encryption to read it`);
+    }
+    let key: Buffer;
+    try {
+      key = await provider.unwrapKey({ keyId: header.keyId, wrappedKey: Buffer.from(header.key, 'base64') });
+    } catch (error) {
+      throw new IndexEncryptionError(`Could not decrypt ${source}: ${error instanceof Error ? error.message : error}`);
+    }
+    try {
+      const decipher = crypto.createDecipheriv(CIPHER, key, Buffer.from(header.iv, 'base64'));
+      decipher.setAAD(data.subarray(0, dataStart));
+      decipher.setAuthTag(data.subarray(data.length - TAG_BYTES));
+      return Buffer.concat([decipher.update(data.subarray(dataStart, data.length - TAG_BYTES)), decipher.final()]);
+    } catch {
+      throw new IndexEncryptionError(`Could not decrypt ${source}: it was modified or cut short`);
+    }
+  }
+}
+
+/**
+ * The keys of an encryption config: its provider encrypts when enabled,
+ * and reads files either way. provider replaces the configured one (a
+ * library caller's KMS client); the key command is then never run, and
+ * files are read with provider or the key in `keyEnv`. The config must
+ * come from trusted settings, as its command is run.
+ */
+export function indexKeysFor(
+  config: EncryptionConfig,
+  options: { provider?: IndexKeyProvider; cwd?: string; env?: Record<string, string | undefined> } = {}
+): IndexKeys {
+  const envProvider = new EnvKeyProvider(config.keyEnv, options.env);
+  const provider = options.provider ??
+    (config.provider === 'command' && config.command ? new CommandKeyProvider(config.command, config.args ?? [], options.cwd) : envProvider);
+  return new IndexKeys(config.enabled ? provider : undefined, [provider, envProvider], config.allowPlaintext === true);
+}
+
+export function isEncryptedIndex(data: Uint8Array): boolean {
+  return data.length >= PREAMBLE_SIZE &&
+    Buffer.from(data.buffer, data.byteOffset, ENCRYPTED_INDEX_MAGIC.length).toString('latin1') === ENCRYPTED_INDEX_MAGIC;
+}
+
+function encodePreamble(header: EncryptedIndexHeader): Buffer {
+  const json = Buffer.from(JSON.stringify(header));
+  const preamble = Buffer.alloc(PREAMBLE_SIZE);
+  preamble.write(ENCRYPTED_INDEX_MAGIC, 0, 'latin1');
+  preamble.writeUInt32LE(json.length, ENCRYPTED_INDEX_MAGIC.length);
+  return Buffer.concat([preamble, json]);
+}
+
+function decodePreamble(data: Uint8Array, source: string): { header: EncryptedIndexHeader; dataStart: number } {
+  const bytes = Buffer.from(data.buffer, data.byteOffset, data.length);
+  const dataStart = PREAMBLE_SIZE + bytes.readUInt32LE(ENCRYPTED_INDEX_MAGIC.length);
+  let header: Partial<EncryptedIndexHeader> | undefined;
+  try {
+    header = dataStart + TAG_BYTES <= bytes.length ? JSON.parse(bytes.toString('utf-8', PREAMBLE_SIZE, dataStart)) : undefined;
+  } catch {
+    header = undefined;
+  }
+  if (header?.version !== 1 || header.cipher !== CIPHER || typeof header.provider !== 'string' ||
+      typeof header.keyId !== 'string' || typeof header.key !== 'string' || typeof header.iv !== 'string') {
+    throw new IndexEncryptionError(`Corrupt encrypted index: ${source}`);
+  }
+  return { header: header as EncryptedIndexHeader, dataStart };
+}
+
+function fingerprint(key: Buffer): string {
+  return crypto.createHash('sha256').update(key).digest('hex').slice(0, 16);
+}
+
+async function* oneChunk(data: Uint8Array): AsyncGenerator<Uint8Array> {
+  yield data;
+}
+
+function runCommand(command: string, args: string[], input: string): Promise<string> {
+  return new Promise((resolve, reject) => {
+    const child = execFile(command, args, { timeout: COMMAND_TIMEOUT_MS, windowsHide: true }, (error, stdout, stderr) => {
+      if (error) {
+        reject(new Error(stderr.trim() || error.message));
+      } else {
+        resolve(stdout);
+      }
+    });
+    child.stdin?.on('error', () => { /* reported by the exit */ });
+    child.stdin?.end(input);
+  });
+}
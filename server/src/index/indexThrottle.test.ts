@@ -0,0 +1,117 @@
+/**
+ * IndexThrottle Tests
+ *
+ * Verifies which indexing each mode throttles, the worker limit, the
+ * per-file delay and read budget, and the pause while on battery with
+ * its cancellation.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { IndexThrottle } from './indexThrottle.js';
+import { CancellationError, CancellationTokenSource } from '../utils/asyncUtils.js';
+
+const MEGABYTE = 1024 * 1024;
+
+function delay(ms: number): Promise<void> {
+  return new Promise(resolve => setTimeout(resolve, ms));
+}
+
+describe('IndexThrottle', () => {
+  it('should throttle watched files in watch mode and everything in always mode', () => {
+    const throttle = new IndexThrottle();
+    expect([throttle.appliesTo('watch'), throttle.appliesTo('full')]).toEqual([false, false]);
+    throttle.configure({ mode: 'watch' });
+    expect([throttle.appliesTo('watch'), throttle.appliesTo('full')]).toEqual([true, false]);
+    throttle.configure({ mode: 'always' });
+    expect([throttle.appliesTo('watch'), throttle.appliesTo('full')]).toEqual([true, true]);
+  });
+
+  it('should run at most maxWorkers files at a time, each after the file delay', async () => {
+    const throttle = new IndexThrottle({ mode: 'watch', maxWorkers: 2, fileDelayMs: 10, pauseOnBattery: false });
+    let running = 0;
+    let peak = 0;
+    const task = async (i: number) => {
+      running++;
+      peak = Math.max(peak, running);
+      await delay(5);
+      running--;
+      return i;
+    };
+
+    const started = Date.now();
+    const results = await Promise.all([0, 1, 2, 3, 4, 5].map(i => throttle.run('watch', () => task(i))));
+    expect(results).toEqual([0, 1, 2, 3, 4, 5]);
+    expect(peak).toBe(2);
+    // Three rounds of delay and work
+    expect(Date.now() - started).toBeGreaterThanOrEqual(40);
+
+    // Full indexing is not held in watch mode
+    running = peak = 0;
+    await Promise.all([0, 1, 2, 3, 4, 5].map(i => throttle.run('full', () => task(i))));
+    expect(peak).toBe(6);
+  });
+
+  it('should space reads to stay within maxMBPerSecond', async () => {
+    const throttle = new IndexThrottle({ mode: 'always', maxWorkers: 4, fileDelayMs: 0, maxMBPerSecond: 10, pauseOnBattery: false });
+    const started = Date.now();
+    const finished: number[] = [];
+    await Promise.all([0, 1, 2].map(() => throttle.run('full', async () => {
+      finished.push(Date.now() - started);
+    }, { bytes: MEGABYTE / 2 })));
+
+    // Half a megabyte at 10 MB/s is 50ms per file: the first reads at once
+    expect(finished[0]).toBeLessThan(40);
+    expect(finished[2]).toBeGreaterThanOrEqual(90);
+  });
+
+  it('should pause while on battery and resume once plugged in', async () => {
+    let onBattery = true;
+    const messages: string[] = [];
+    const logger = { info: (message: string) => messages.push(message) } as never;
+    const throttle = new IndexThrottle(
+      { mode: 'watch', fileDelayMs: 0, pauseOnBattery: true },
+      { onBattery: async () => onBattery, batteryPollMs: 10, logger }
+    );
+
+    let done = false;
+    const run = throttle.run('watch', async () => { done = true; });
+    await delay(30);
+    expect(done).toBe(false);
+    expect(throttle.isPaused()).toBe(true);
+
+    onBattery = false;
+    await run;
+    expect(done).toBe(true);
+    expect(throttle.isPaused()).toBe(false);
+    expect(messages.map(message => message.split(',')[0])).toEqual([
+      '[IndexThrottle] On battery', '[IndexThrottle] Plugged in'
+    ]);
+
+    // Not held once pausing is off
+    onBattery = true;
+    throttle.configure({ mode: 'watch', fileDelayMs: 0, pauseOnBattery: false });
+    await throttle.run('watch', async () => undefined);
+  });
+
+  it('should give up on files waiting when cancelled', async () => {
+    const throttle = new IndexThrottle(
+      { mode: 'watch', maxWorkers: 1, fileDelayMs: 0, pauseOnBattery: true },
+      { onBattery: async () => true, batteryPollMs: 10 }
+    );
+    const source = new CancellationTokenSource();
+    const ran: number[] = [];
+    const runs = [0, 1].map(i => throttle.run('watch', async () => { ran.push(i); }, { cancellationToken: source.token }));
+    await delay(20);
+    source.cancel();
+
+    for (const run of runs) {
+      await expect(run).rejects.toThrow(CancellationError);
+    }
+    expect(ran).toEqual([]);
+
+    // The slot was given back
+    throttle.configure({ mode: 'watch', maxWorkers: 1, fileDelayMs: 0, pauseOnBattery: false });
+    await throttle.run('watch', async () => { ran.push(2); });
+    expect(ran).toEqual([2]);
+  });
+});
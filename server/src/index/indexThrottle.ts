@@ -0,0 +1,173 @@
+import { DEFAULT_THROTTLE_CONFIG, ThrottleConfig } from '../config/configurationManager.js';
+import { CancellationToken, throwIfCancelled } from '../utils/asyncUtils.js';
+import { ILogger, NullLogger } from '../utils/Logger.js';
+import { isOnBattery } from '../utils/powerSource.js';
+
+/** Files changed while the user works (watchers, git updates), or a whole tree */
+export type IndexingKind = 'watch' | 'full';
+
+export interface IndexThrottleOptions {
+  /** Power source probe (default: utils/powerSource.ts) */
+  onBattery?: () => Promise<boolean | undefined>;
+  /** How long a power source reading holds, and how often a pause checks again */
+  batteryPollMs?: number;
+  logger?: ILogger;
+}
+
+export interface ThrottleRunOptions {
+  /** Bytes the task reads, counted against maxMBPerSecond */
+  bytes?: number;
+  cancellationToken?: CancellationToken;
+}
+
+const MEGABYTE = 1024 * 1024;
+const BATTERY_POLL_MS = 30_000;
+
+/**
+ * IndexThrottle - nice mode for indexing that runs while the user works.
+ *
+ * Re-indexing on every save and branch switch otherwise takes every core
+ * the worker pool has. Each file indexed under the throttle:
+ * - waits for one of maxWorkers slots, so the other cores stay free
+ * - waits while the machine is on battery, when pauseOnBattery is set
+ * - waits until maxMBPerSecond allows its bytes to be read
+ * - sleeps fileDelayMs, which leaves gaps for the editor and the disk
+ *
+ * Which indexing is throttled follows the mode; the rest runs as before.
+ */
+export class IndexThrottle {
+  private config: ThrottleConfig = DEFAULT_THROTTLE_CONFIG;
+  private onBattery: () => Promise<boolean | undefined>;
+  private batteryPollMs: number;
+  private logger: ILogger;
+  private active = 0;
+  private waiting: Array<() => void> = [];
+  // When the read budget allows the next file
+  private nextReadAt = 0;
+  private battery: { onBattery: Promise<boolean>; readAt: number } | undefined;
+  private paused = false;
+
+  constructor(config: Partial<ThrottleConfig> = {}, options: IndexThrottleOptions = {}) {
+    this.onBattery = options.onBattery ?? (() => isOnBattery());
+    this.batteryPollMs = options.batteryPollMs ?? BATTERY_POLL_MS;
+    this.logger = options.logger ?? new NullLogger();
+    this.configure(config);
+  }
+
+  /**
+   * Replace the settings; files already waiting are held by the new ones.
+   */
+  configure(config: Partial<ThrottleConfig>): void {
+    this.config = { ...DEFAULT_THROTTLE_CONFIG, ...config };
+    this.nextReadAt = 0;
+    this.battery = undefined;
+    this.wakeAll();
+  }
+
+  getConfig(): ThrottleConfig {
+    return { ...this.config };
+  }
+
+  appliesTo(kind: IndexingKind): boolean {
+    return this.config.mode === 'always' || (this.config.mode === 'watch' && kind === 'watch');
+  }
+
+  /** Whether throttled indexing is waiting for the machine to be plugged in */
+  isPaused(): boolean {
+    return this.paused;
+  }
+
+  /**
+   * Run the indexing of one file, under the throttle when it applies to
+   * kind. Throws CancellationError when the token is cancelled while the
+   * file waits.
+   */
+  async run<T>(kind: IndexingKind, task: () => Promise<T>, options: ThrottleRunOptions = {}): Promise<T> {
+    if (!this.appliesTo(kind)) {
+      return task();
+    }
+    const { bytes = 0, cancellationToken } = options;
+    await this.acquire(kind, cancellationToken);
+    try {
+      await this.waitForMains(kind, cancellationToken);
+      await this.waitForReadBudget(bytes, cancellationToken);
+      await sleep(this.config.fileDelayMs, cancellationToken);
+      return await task();
+    } finally {
+      this.release();
+    }
+  }
+
+  private async acquire(kind: IndexingKind, token: CancellationToken | undefined): Promise<void> {
+    while (this.appliesTo(kind) && this.active >= this.config.maxWorkers) {
+      await new Promise<void>(resolve => {
+        this.waiting.push(resolve);
+        token?.onCancellationRequested?.(resolve);
+      });
+      throwIfCancelled(token);
+    }
+    this.active++;
+  }
+
+  /** Waiters check again in order; cancelled ones have left */
+  private release(): void {
+    this.active--;
+    this.wakeAll();
+  }
+
+  private wakeAll(): void {
+    const waiting = this.waiting;
+    this.waiting = [];
+    waiting.forEach(resolve => resolve());
+  }
+
+  private async waitForMains(kind: IndexingKind, token: CancellationToken | undefined): Promise<void> {
+    while (this.appliesTo(kind) && this.config.pauseOnBattery && await this.readBattery()) {
+      if (!this.paused) {
+        this.paused = true;
+        this.logger.info('[IndexThrottle] On battery, pausing background indexing until the machine is plugged in');
+      }
+      await sleep(this.batteryPollMs, token);
+    }
+    if (this.paused) {
+      this.paused = false;
+      this.logger.info('[IndexThrottle] Plugged in, resuming background indexing');
+    }
+  }
+
+  /** One probe per poll interval, shared by the files waiting */
+  private readBattery(): Promise<boolean> {
+    const now = Date.now();
+    if (!this.battery || now - this.battery.readAt >= this.batteryPollMs) {
+      this.battery = { onBattery: this.onBattery().then(onBattery => onBattery === true, () => false), readAt: now };
+    }
+    return this.battery.onBattery;
+  }
+
+  private async waitForReadBudget(bytes: number, token: CancellationToken | undefined): Promise<void> {
+    const bytesPerMs = this.config.maxMBPerSecond * MEGABYTE / 1000;
+    if (bytesPerMs <= 0 || bytes <= 0) {
+      return;
+    }
+    const now = Date.now();
+    const start = Math.max(now, this.nextReadAt);
+    this.nextReadAt = start + bytes / bytesPerMs;
+    await sleep(start - now, token);
+  }
+}
+
+/** Resolves after ms, or throws CancellationError once the token is cancelled */
+async function sleep(ms: number, token: CancellationToken | undefined): Promise<void> {
+  throwIfCancelled(token);
+  if (ms <= 0) {
+    return;
+  }
+  await new Promise<void>(resolve => {
+    const timer = setTimeout(resolve, ms);
+    token?.onCancellationRequested?.(() => {
+      clearTimeout(timer);
+      resolve();
+    });
+  });
+  throwIfCancelled(token);
+}
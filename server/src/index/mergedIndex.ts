@@ -1,8 +1,8 @@
 import { ISymbolIndex } from './ISymbolIndex.js';
-import { IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo } from '../types.js';
+import { IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, CodeTag } from '../types.js';
 import { DynamicIndex } from './dynamicIndex.js';
 import { BackgroundIndex } from './backgroundIndex.js';
-import { rankSymbols, RankingContext } from '../utils/fuzzySearch.js';
+import { rankSymbols, RankedSymbol, RankingContext } from '../utils/fuzzySearch.js';
 
 /**
  * MergedIndex - Combines multiple indices with prioritization.
@@ -19,6 +19,7 @@ export class MergedIndex implements ISymbolIndex {
   private dynamicIndex: DynamicIndex;
   private backgroundIndex: BackgroundIndex;
   private staticIndex?: ISymbolIndex;
+  private staticGeneration = 0;
 
   constructor(dynamicIndex: DynamicIndex, backgroundIndex: BackgroundIndex, staticIndex?: ISymbolIndex) {
     this.dynamicIndex = dynamicIndex;
@@ -28,6 +29,16 @@ export class MergedIndex implements ISymbolIndex {
 
   setStaticIndex(staticIndex: ISymbolIndex | undefined): void {
     this.staticIndex = staticIndex;
+    this.staticGeneration++;
+  }
+
+  /**
+   * The sum of the generations of the indexes, which only go up: it
+   * changes whenever one of them does, or the static index is replaced.
+   */
+  getGeneration(): number {
+    return this.backgroundIndex.getGeneration() + this.dynamicIndex.getGeneration() +
+      this.staticGeneration + (this.staticIndex?.getGeneration?.() ?? 0);
   }
 
   async findDefinitions(name: string): Promise<IndexedSymbol[]> {
@@ -76,6 +87,15 @@ export class MergedIndex implements ISymbolIndex {
   }
 
   async searchSymbols(query: string, limit: number, context?: RankingContext): Promise<IndexedSymbol[]> {
+    return (await this.searchSymbolsRanked(query, limit, context)).map(r => r.symbol);
+  }
+
+  /**
+   * Search symbols ranked by match quality, the context and popularity:
+   * reference counts and file modification times from the background index
+   * (unless the context brings its own).
+   */
+  async searchSymbolsRanked(query: string, limit: number, context?: RankingContext): Promise<RankedSymbol<IndexedSymbol>[]> {
     // OPTIMIZATION: Use a search budget to avoid fetching 50k+ results when only 50 are needed.
     // Request limit * 2 from each provider, capped at 1000, to allow for deduplication and ranking.
     const searchBudget = Math.min(limit * 2, 1000);
@@ -92,12 +112,19 @@ export class MergedIndex implements ISymbolIndex {
     // Merge all results (deduplicate)
     const merged = this.mergeResults(dynamicResults, backgroundResults, staticResults);
 
+    const popularity = context?.popularity ?? {
+      referenceCounts: merged.length > 0
+        ? await this.backgroundIndex.getReferenceCounts(merged.map(s => s.name))
+        : new Map<string, number>(),
+      modifiedAt: (uri: string) => this.backgroundIndex.getFileInfo(uri)?.mtime
+    };
+
     // Apply fuzzy ranking with batching for large result sets
-    const ranked = await this.rankSymbolsWithBatching(merged, query, context, limit);
+    const ranked = await this.rankSymbolsWithBatching(merged, query, { ...context, popularity }, limit);
 
     // STRICT DEDUPLICATION: Use composite key (uri::line::character) to eliminate exact duplicates
     // This prevents the same symbol from appearing multiple times in search results
-    const deduped: Array<{ symbol: IndexedSymbol; score: number; matches: number[] }> = [];
+    const deduped: RankedSymbol<IndexedSymbol>[] = [];
     const seen = new Set<string>();
     
     for (const item of ranked) {
@@ -109,7 +136,15 @@ export class MergedIndex implements ISymbolIndex {
     }
 
     // Return top N results after strict deduplication
-    return deduped.slice(0, limit).map(r => r.symbol);
+    return deduped.slice(0, limit);
+  }
+
+  /**
+   * Reference counts from the background index, which covers open files
+   * as of their last save.
+   */
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    return this.backgroundIndex.getReferenceCounts(names);
   }
 
   /**
@@ -121,7 +156,7 @@ export class MergedIndex implements ISymbolIndex {
     query: string,
     context: RankingContext | undefined,
     _limit: number
-  ): Promise<Array<{ symbol: IndexedSymbol; score: number; matches: number[] }>> {
+  ): Promise<RankedSymbol<IndexedSymbol>[]> {
     const BATCH_SIZE = 1000;
     
     // For small result sets, use synchronous ranking
@@ -130,7 +165,7 @@ export class MergedIndex implements ISymbolIndex {
     }
 
     // For large result sets, process in batches
-    const allRanked: Array<{ symbol: IndexedSymbol; score: number; matches: number[] }> = [];
+    const allRanked: RankedSymbol<IndexedSymbol>[] = [];
     
     for (let i = 0; i < symbols.length; i += BATCH_SIZE) {
       const batch = symbols.slice(i, i + BATCH_SIZE);
@@ -199,6 +234,24 @@ export class MergedIndex implements ISymbolIndex {
     return merged;
   }
 
+  /**
+   * findReferencesByName in batches: the open files' references, then
+   * the background index's as it reads them.
+   */
+  async *streamReferencesByName(name: string): AsyncIterable<IndexedReference[]> {
+    const dynamicRefs = await this.dynamicIndex.findReferencesByName(name);
+    const seen = new Set(dynamicRefs.map(ref => this.makeReferenceKey(ref)));
+    if (dynamicRefs.length > 0) {
+      yield dynamicRefs;
+    }
+    for await (const batch of this.backgroundIndex.streamReferencesByName(name)) {
+      const refs = batch.filter(ref => !seen.has(this.makeReferenceKey(ref)));
+      if (refs.length > 0) {
+        yield refs;
+      }
+    }
+  }
+
   /**
    * Get import info for a file (for import resolution).
    */
@@ -227,18 +280,41 @@ export class MergedIndex implements ISymbolIndex {
     return this.backgroundIndex.getFileReExports(uri);
   }
 
+  /**
+   * Get the classifications of a file (generated, test, mock, example).
+   */
+  async getFileTags(uri: string): Promise<CodeTag[]> {
+    // Check dynamic index first (open files)
+    const dynamicTags = await this.dynamicIndex.getFileTags(uri);
+    if (dynamicTags.length > 0) {
+      return dynamicTags;
+    }
+
+    // Fall back to background index
+    return this.backgroundIndex.getFileTags(uri);
+  }
+
   /**
    * Merge and deduplicate symbol results from multiple indices.
    * Dynamic index results take priority over background, which takes priority over static.
+   *
+   * Symbols with a canonical ID are matched by it rather than by position,
+   * so a declaration that moved in an unsaved buffer is not listed twice.
+   * Static indexes are usually built on another machine, so their symbols
+   * are matched by canonical ID alone, whatever their path.
    */
   private mergeResults(dynamicResults: IndexedSymbol[], backgroundResults: IndexedSymbol[], staticResults: IndexedSymbol[] = []): IndexedSymbol[] {
     const results: IndexedSymbol[] = [...dynamicResults];
     const seen = new Set<string>();
+    const seenCanonical = new Set<string>();
 
     // Add dynamic results to seen set
     for (const symbol of dynamicResults) {
       const key = this.makeSymbolKey(symbol);
       seen.add(key);
+      if (symbol.canonicalId) {
+        seenCanonical.add(`${symbol.kind}:${symbol.canonicalId}`);
+      }
     }
 
     // Add background results that aren't already in dynamic index
@@ -247,12 +323,18 @@ export class MergedIndex implements ISymbolIndex {
       if (!seen.has(key)) {
         results.push(symbol);
         seen.add(key);
+        if (symbol.canonicalId) {
+          seenCanonical.add(`${symbol.kind}:${symbol.canonicalId}`);
+        }
       }
     }
 
     // Add static results that aren't already in dynamic or background index
     for (const symbol of staticResults) {
       const key = this.makeSymbolKey(symbol);
+      if (symbol.canonicalId && seenCanonical.has(`${symbol.kind}:${symbol.canonicalId}`)) {
+        continue;
+      }
       if (!seen.has(key)) {
         results.push(symbol);
         seen.add(key);
@@ -270,12 +352,11 @@ export class MergedIndex implements ISymbolIndex {
     const seen = new Set<string>();
 
     for (const ref of dynamicRefs) {
-      const key = `${ref.symbolName}:${ref.location.uri}:${ref.location.line}:${ref.location.character}`;
-      seen.add(key);
+      seen.add(this.makeReferenceKey(ref));
     }
 
     for (const ref of backgroundRefs) {
-      const key = `${ref.symbolName}:${ref.location.uri}:${ref.location.line}:${ref.location.character}`;
+      const key = this.makeReferenceKey(ref);
       if (!seen.has(key)) {
         results.push(ref);
         seen.add(key);
@@ -285,10 +366,20 @@ export class MergedIndex implements ISymbolIndex {
     return results;
   }
 
+  /**
+   * Create a unique key for a reference to detect duplicates.
+   */
+  private makeReferenceKey(ref: IndexedReference): string {
+    return `${ref.symbolName}:${ref.location.uri}:${ref.location.line}:${ref.location.character}`;
+  }
+
   /**
    * Create a unique key for a symbol to detect duplicates.
    */
   private makeSymbolKey(symbol: IndexedSymbol): string {
+    if (symbol.canonicalId) {
+      return `${symbol.location.uri}:${symbol.kind}:${symbol.canonicalId}`;
+    }
     return `${symbol.name}:${symbol.location.uri}:${symbol.location.line}:${symbol.location.character}`;
   }
 }
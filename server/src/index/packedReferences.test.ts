@@ -0,0 +1,115 @@
+/**
+ * PackedReferences Tests
+ *
+ * Round-trips references through both layouts and checks that lookups by
+ * name build only matching references, that optional fields come back as
+ * they went in and that references the format cannot hold are kept whole.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { PackedReferences } from './packedReferences.js';
+import { createTestReference } from '../test/mocks/MockIndex.js';
+import { IndexedReference } from '../types.js';
+import { StringPool } from '../utils/stringPool.js';
+
+const uri = '/ws/src/user.service.ts';
+
+/** A one-line reference at the start of its range, with offsets */
+function identifier(symbolName: string, line: number, character: number, overrides: Partial<IndexedReference> = {}): IndexedReference {
+  const offset = line * 100 + character;
+  return {
+    symbolName,
+    location: { uri, line, character },
+    range: {
+      startLine: line,
+      startCharacter: character,
+      endLine: line,
+      endCharacter: character + symbolName.length,
+      startOffset: offset,
+      endOffset: offset + symbolName.length
+    },
+    ...overrides
+  };
+}
+
+describe('PackedReferences', () => {
+  it('should give back identifier references as they were stored', () => {
+    const references = [
+      identifier('UserService', 3, 13, { containerName: 'AppModule', isImport: true }),
+      identifier('getUser', 8, 4, { scopeId: 'UserService::load', isCall: true }),
+      identifier('user', 9, 2, { scopeId: 'UserService::load', isLocal: true }),
+      identifier('getUser', 12, 10, { isLocal: false, isCall: false })
+    ];
+
+    const packed = new PackedReferences(uri, references, new StringPool());
+    expect(packed.length).toBe(4);
+    // Same fields in the same order, so results serialize unchanged
+    expect(JSON.stringify(packed.toArray())).toBe(JSON.stringify(references));
+    expect(packed.at(1)).toEqual(references[1]);
+  });
+
+  it('should keep the location and whole range of other references', () => {
+    const references = [
+      identifier('Config', 1, 0),
+      createTestReference({
+        symbolName: 'handler',
+        location: { uri, line: 20, character: 6 },
+        range: { startLine: 20, startCharacter: 2, endLine: 24, endCharacter: 3 }
+      })
+    ];
+
+    const packed = new PackedReferences(uri, references, new StringPool());
+    expect(JSON.stringify(packed.toArray())).toBe(JSON.stringify(references));
+  });
+
+  it('should keep references it cannot pack as they are', () => {
+    const references = [
+      identifier('User', 2, 9),
+      identifier('User', 4, 1, { location: { uri: '/ws/src/user.ts', line: 4, character: 1 } }),
+      { ...identifier('User', 6, 1), extra: { note: 'plugin field' } } as IndexedReference,
+      identifier('User', 7, 1, { range: { startLine: 7, startCharacter: 1.5, endLine: 7, endCharacter: 5 } })
+    ];
+
+    const packed = new PackedReferences(uri, references, new StringPool());
+    const unpacked = packed.toArray();
+    expect(unpacked).toEqual(references);
+    expect(unpacked[1]).toBe(references[1]);
+    expect(unpacked[2]).toBe(references[2]);
+    expect(unpacked[3]).toBe(references[3]);
+    expect(packed.byName('User').length).toBe(4);
+    expect(packed.count('User')).toBe(4);
+  });
+
+  it('should find, count and list referenced names', () => {
+    const references = [
+      identifier('load', 1, 0, { isLocal: false }),
+      identifier('save', 2, 0),
+      identifier('load', 3, 0, { isLocal: true }),
+      identifier('load', 4, 0)
+    ];
+
+    const packed = new PackedReferences(uri, references, new StringPool());
+    expect(packed.byName('load').map(ref => ref.location.line)).toEqual([1, 3, 4]);
+    expect(packed.byName('missing')).toEqual([]);
+    expect(packed.count('load')).toBe(3);
+    expect(packed.count('load', true)).toBe(2);
+    expect(packed.count('missing')).toBe(0);
+    expect([...packed.names()]).toEqual(['load', 'save']);
+  });
+
+  it('should hold pooled strings', () => {
+    const pool = new StringPool();
+    const first = new PackedReferences(uri, [identifier('UserService', 3, 13, { containerName: 'AppModule' })], pool);
+    const second = new PackedReferences('/ws/src/app.ts', [{
+      ...identifier('UserService', 5, 2, { containerName: 'AppModule' }),
+      location: { uri: '/ws/src/app.ts', line: 5, character: 2 }
+    }], pool);
+
+    expect(pool.size).toBe(2);
+    const seen: Array<string | undefined> = [];
+    first.forEachString(value => seen.push(value));
+    second.forEachString(value => seen.push(value));
+    expect(seen).toEqual(['UserService', 'AppModule', 'UserService', 'AppModule']);
+    expect(second.at(0).location.uri).toBe('/ws/src/app.ts');
+  });
+});
@@ -0,0 +1,264 @@
+import { IndexedReference } from '../types.js';
+import { StringPool } from '../utils/stringPool.js';
+
+/**
+ * Int32 slots per reference, in one of two layouts per file. Nearly every
+ * reference is one identifier: on one line, at the start of its range,
+ * with offsets. Files of only such references store the start and a
+ * length; others store the location and the whole range.
+ */
+const SHORT_STRIDE = 7;
+const FULL_STRIDE = 11;
+const START_LINE = 0;
+const START_CHARACTER = 1;
+const START_OFFSET = 2;
+const NAME = 3;
+const CONTAINER = 4;
+/** Scope string index above the flag bits */
+const SCOPE_FLAGS = 5;
+/** Short layout: end minus start, in characters and in offset */
+const LENGTH = 6;
+/** Full layout */
+const END_LINE = 6;
+const END_CHARACTER = 7;
+const END_OFFSET = 8;
+const LINE = 9;
+const CHARACTER = 10;
+
+/** Absent offsets and strings */
+const NONE = -1;
+
+/** Two bits per boolean: set, and its value */
+const IS_IMPORT = 0;
+const IS_LOCAL = 2;
+const IS_CALL = 4;
+/** Leaves 25 bits of scope index, more strings than any file has */
+const FLAG_BITS = 6;
+
+const REFERENCE_KEYS = new Set(['symbolName', 'location', 'range', 'containerName', 'isImport', 'scopeId', 'isLocal', 'isCall']);
+const LOCATION_KEYS = new Set(['uri', 'line', 'character']);
+const RANGE_KEYS = new Set(['startLine', 'startCharacter', 'endLine', 'endCharacter', 'startOffset', 'endOffset']);
+
+/**
+ * PackedReferences - the references of one file in a flat Int32Array.
+ *
+ * A reference object with its location and range takes about 250 bytes
+ * of heap; packed it takes 28, plus one pooled string per distinct name,
+ * container and scope of the file. References are the bulk of an index
+ * (ten or more per symbol), so this is most of what a large in-memory
+ * index weighs.
+ *
+ * References are built back into objects when read: all of them (at()
+ * and toArray()), or only those to a name (byName()). Members carry the
+ * file's uri. The odd reference that does not fit - another file's uri,
+ * fields the format does not know - is kept as the object it was.
+ */
+export class PackedReferences {
+  readonly length: number;
+  private stride: number;
+  private slots: Int32Array;
+  private strings: string[];
+  private unpacked: Map<number, IndexedReference> | undefined;
+
+  constructor(readonly uri: string, references: readonly IndexedReference[], pool: StringPool) {
+    this.length = references.length;
+    const packable = references.map(ref => isPackable(ref, uri));
+    this.stride = references.every((ref, i) => !packable[i] || isIdentifier(ref)) ? SHORT_STRIDE : FULL_STRIDE;
+    this.slots = new Int32Array(references.length * this.stride);
+    const indexes = new Map<string, number>();
+    this.strings = [];
+    const stringIndex = (value: string | undefined): number => {
+      if (value === undefined) {
+        return NONE;
+      }
+      let index = indexes.get(value);
+      if (index === undefined) {
+        index = this.strings.length;
+        indexes.set(value, index);
+        this.strings.push(pool.intern(value));
+      }
+      return index;
+    };
+
+    references.forEach((ref, i) => {
+      if (!packable[i]) {
+        (this.unpacked ??= new Map()).set(i, ref);
+        return;
+      }
+      const at = i * this.stride;
+      const { slots } = this;
+      const { range } = ref;
+      slots[at + START_LINE] = range.startLine;
+      slots[at + START_CHARACTER] = range.startCharacter;
+      slots[at + START_OFFSET] = range.startOffset ?? NONE;
+      slots[at + NAME] = stringIndex(ref.symbolName);
+      slots[at + CONTAINER] = stringIndex(ref.containerName);
+      slots[at + SCOPE_FLAGS] = (stringIndex(ref.scopeId) + 1) << FLAG_BITS |
+        packFlag(ref.isImport, IS_IMPORT) | packFlag(ref.isLocal, IS_LOCAL) | packFlag(ref.isCall, IS_CALL);
+      if (this.stride === SHORT_STRIDE) {
+        slots[at + LENGTH] = range.endCharacter - range.startCharacter;
+      } else {
+        slots[at + END_LINE] = range.endLine;
+        slots[at + END_CHARACTER] = range.endCharacter;
+        slots[at + END_OFFSET] = range.endOffset ?? NONE;
+        slots[at + LINE] = ref.location.line;
+        slots[at + CHARACTER] = ref.location.character;
+      }
+    });
+  }
+
+  at(i: number): IndexedReference {
+    const unpacked = this.unpacked?.get(i);
+    if (unpacked) {
+      return unpacked;
+    }
+    const { slots, strings } = this;
+    const at = i * this.stride;
+    const startLine = slots[at + START_LINE];
+    const startCharacter = slots[at + START_CHARACTER];
+    const short = this.stride === SHORT_STRIDE;
+    const ref: IndexedReference = {
+      symbolName: strings[slots[at + NAME]],
+      location: {
+        uri: this.uri,
+        line: short ? startLine : slots[at + LINE],
+        character: short ? startCharacter : slots[at + CHARACTER]
+      },
+      range: {
+        startLine,
+        startCharacter,
+        endLine: short ? startLine : slots[at + END_LINE],
+        endCharacter: short ? startCharacter + slots[at + LENGTH] : slots[at + END_CHARACTER]
+      }
+    };
+    const startOffset = slots[at + START_OFFSET];
+    const endOffset = short ? startOffset + slots[at + LENGTH] : slots[at + END_OFFSET];
+    if (startOffset !== NONE) { ref.range.startOffset = startOffset; }
+    if (endOffset !== NONE) { ref.range.endOffset = endOffset; }
+    if (slots[at + CONTAINER] !== NONE) { ref.containerName = strings[slots[at + CONTAINER]]; }
+    const flags = slots[at + SCOPE_FLAGS];
+    const isImport = unpackFlag(flags, IS_IMPORT);
+    if (isImport !== undefined) { ref.isImport = isImport; }
+    const scope = (flags >> FLAG_BITS) - 1;
+    if (scope !== NONE) { ref.scopeId = strings[scope]; }
+    const isLocal = unpackFlag(flags, IS_LOCAL);
+    if (isLocal !== undefined) { ref.isLocal = isLocal; }
+    const isCall = unpackFlag(flags, IS_CALL);
+    if (isCall !== undefined) { ref.isCall = isCall; }
+    return ref;
+  }
+
+  toArray(): IndexedReference[] {
+    const references = new Array<IndexedReference>(this.length);
+    for (let i = 0; i < this.length; i++) {
+      references[i] = this.at(i);
+    }
+    return references;
+  }
+
+  /**
+   * The references to name, in file order; builds no others.
+   */
+  byName(name: string): IndexedReference[] {
+    const references: IndexedReference[] = [];
+    const index = this.strings.indexOf(name);
+    for (let i = 0; i < this.length; i++) {
+      const unpacked = this.unpacked?.get(i);
+      if (unpacked ? unpacked.symbolName === name : index !== NONE && this.slots[i * this.stride + NAME] === index) {
+        references.push(this.at(i));
+      }
+    }
+    return references;
+  }
+
+  /**
+   * Number of references to name, without local ones when nonLocal is set.
+   */
+  count(name: string, nonLocal: boolean = false): number {
+    const index = this.strings.indexOf(name);
+    let count = 0;
+    for (let i = 0; i < this.length; i++) {
+      const unpacked = this.unpacked?.get(i);
+      const matches = unpacked
+        ? unpacked.symbolName === name && !(nonLocal && unpacked.isLocal)
+        : index !== NONE && this.slots[i * this.stride + NAME] === index &&
+          !(nonLocal && unpackFlag(this.slots[i * this.stride + SCOPE_FLAGS], IS_LOCAL));
+      if (matches) {
+        count++;
+      }
+    }
+    return count;
+  }
+
+  /** Distinct names referenced */
+  names(): Set<string> {
+    const names = new Set<string>();
+    for (let i = 0; i < this.length; i++) {
+      names.add(this.unpacked?.get(i)?.symbolName ?? this.strings[this.slots[i * this.stride + NAME]]);
+    }
+    return names;
+  }
+
+  /** Every string held, for StringPool.sweep() */
+  forEachString(visit: (value: string | undefined) => void): void {
+    this.strings.forEach(visit);
+    for (const ref of this.unpacked?.values() ?? []) {
+      visit(ref.symbolName);
+      visit(ref.containerName);
+      visit(ref.scopeId);
+    }
+  }
+}
+
+function isPackable(ref: IndexedReference, uri: string): boolean {
+  if (ref.location.uri !== uri || typeof ref.symbolName !== 'string' ||
+      !isOptional(ref.containerName, 'string') || !isOptional(ref.scopeId, 'string') ||
+      !isOptional(ref.isImport, 'boolean') || !isOptional(ref.isLocal, 'boolean') || !isOptional(ref.isCall, 'boolean')) {
+    return false;
+  }
+  for (const key in ref) {
+    if (!REFERENCE_KEYS.has(key)) {
+      return false;
+    }
+  }
+  for (const key in ref.location) {
+    if (!LOCATION_KEYS.has(key)) {
+      return false;
+    }
+  }
+  for (const key in ref.range) {
+    if (!RANGE_KEYS.has(key)) {
+      return false;
+    }
+  }
+  const { range } = ref;
+  return isSlot(ref.location.line) && isSlot(ref.location.character) &&
+    isSlot(range.startLine) && isSlot(range.startCharacter) && isSlot(range.endLine) && isSlot(range.endCharacter) &&
+    (range.startOffset === undefined || isSlot(range.startOffset)) && (range.endOffset === undefined || isSlot(range.endOffset));
+}
+
+/** Fits the short layout */
+function isIdentifier(ref: IndexedReference): boolean {
+  const { location, range } = ref;
+  return location.line === range.startLine && location.character === range.startCharacter &&
+    range.endLine === range.startLine && range.startOffset !== undefined && range.endOffset !== undefined &&
+    range.endOffset - range.startOffset === range.endCharacter - range.startCharacter;
+}
+
+function isOptional(value: unknown, type: 'string' | 'boolean'): boolean {
+  return value === undefined || typeof value === type;
+}
+
+/** Fits an Int32 slot and is not NONE */
+function isSlot(value: number): boolean {
+  return Number.isInteger(value) && value >= 0 && value <= 0x7fffffff;
+}
+
+function packFlag(value: boolean | undefined, shift: number): number {
+  return value === undefined ? 0 : (value ? 3 : 1) << shift;
+}
+
+function unpackFlag(flags: number, shift: number): boolean | undefined {
+  const bits = (flags >> shift) & 3;
+  return bits === 0 ? undefined : bits === 3;
+}
@@ -0,0 +1,113 @@
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { decode } from '@msgpack/msgpack';
+import { CompactShard } from '../types.js';
+import { findRoot, WorkspaceRoot } from '../utils/workspaceRoots.js';
+
+/**
+ * Saved index file: compact shards in MessagePack, as in the shard cache,
+ * written by the library's save() and indexDirToFile() and shared through
+ * remote storage (see features/remoteIndex.ts).
+ */
+export interface SavedIndex {
+  format: typeof SAVED_INDEX_FORMAT;
+  shardVersion: number;
+  files: CompactShard[];
+  /** Indexed roots; missing in files saved before multi-root support */
+  roots?: WorkspaceRoot[];
+}
+
+/** A saved index as decoded, with its entries not yet checked */
+export type DecodedSavedIndex = Omit<Partial<SavedIndex>, 'files'> & { files: unknown[] };
+
+export const SAVED_INDEX_FORMAT = 'smart-indexer';
+
+/** Where a saved root lives in this checkout */
+export interface RootMapping {
+  from: string;
+  to: string;
+}
+
+/**
+ * Decode a saved index; throws on data that is not one. source names the
+ * data in the error (a file path or URL).
+ */
+export function decodeSavedIndex(data: Uint8Array, source: string): DecodedSavedIndex {
+  let saved: Partial<SavedIndex> | null;
+  try {
+    saved = decode(data) as Partial<SavedIndex> | null;
+  } catch {
+    saved = null;
+  }
+  if (!saved || saved.format !== SAVED_INDEX_FORMAT || !Array.isArray(saved.files)) {
+    throw new Error(`Not a saved index: ${source}`);
+  }
+  return saved as DecodedSavedIndex;
+}
+
+/**
+ * The well-formed roots of a decoded saved index.
+ */
+export function savedRoots(saved: DecodedSavedIndex): WorkspaceRoot[] {
+  return (Array.isArray(saved.roots) ? saved.roots : []).filter(
+    (root): root is WorkspaceRoot => typeof root?.path === 'string' && typeof root.name === 'string'
+  );
+}
+
+/**
+ * Match the roots an index was saved with (on a CI machine, say) to local
+ * ones: by name first, then by position. Roots left unmatched are dropped.
+ */
+export function mapSavedRoots(saved: WorkspaceRoot[], local: WorkspaceRoot[]): RootMapping[] {
+  const unused = [...local];
+  const mappings: RootMapping[] = [];
+  const pending: number[] = [];
+  saved.forEach((root, i) => {
+    const byName = unused.findIndex(candidate => candidate.name === root.name);
+    if (byName >= 0) {
+      mappings.push({ from: root.path, to: unused[byName].path });
+      unused.splice(byName, 1);
+    } else {
+      pending.push(i);
+    }
+  });
+  for (const i of pending) {
+    const byPosition = unused.find(candidate => candidate.path === local[i]?.path);
+    if (byPosition) {
+      mappings.push({ from: saved[i].path, to: byPosition.path });
+      unused.splice(unused.indexOf(byPosition), 1);
+    }
+  }
+  return mappings;
+}
+
+/**
+ * Move a shard from the saved root containing it to its local root,
+ * rewriting the path-derived part of its symbol ids; null when it is under
+ * none of the mapped roots.
+ */
+export function relocateShard(shard: CompactShard, mappings: RootMapping[]): CompactShard | null {
+  const mapping = findRoot(mappings.map(m => ({ path: m.from, to: m.to })), shard.u);
+  if (!mapping) {
+    return null;
+  }
+  if (mapping.path === mapping.to) {
+    return shard;
+  }
+
+  const uri = path.join(mapping.to, path.relative(mapping.path, shard.u));
+  // Symbol ids start with a hash of the file path (indexer/symbolResolver.ts)
+  const oldPrefix = fileIdPrefix(shard.u);
+  const newPrefix = fileIdPrefix(uri);
+  return {
+    ...shard,
+    u: uri,
+    s: shard.s.map(symbol => symbol.id.startsWith(oldPrefix)
+      ? { ...symbol, id: newPrefix + symbol.id.slice(oldPrefix.length) }
+      : symbol)
+  };
+}
+
+function fileIdPrefix(uri: string): string {
+  return crypto.createHash('md5').update(uri).digest('hex').substring(0, 8) + ':';
+}
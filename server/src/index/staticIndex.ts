@@ -1,18 +1,24 @@
 import { ISymbolIndex } from './ISymbolIndex.js';
 import { IndexedSymbol } from '../types.js';
 import { fuzzyScore } from '../utils/fuzzySearch.js';
+import { BinaryIndex, BINARY_INDEX_EXTENSION, isBinaryIndexFile } from './binaryIndex.js';
 import * as fsPromises from 'fs/promises';
 import * as path from 'path';
 
 /**
- * Static index loaded from pre-generated JSON snapshots.
- * Provides read-only access to symbols.
+ * Static index loaded from pre-generated JSON snapshots or binary (.sidx)
+ * indexes. Provides read-only access to symbols.
+ *
+ * JSON snapshots are parsed into memory up front. Binary indexes are only
+ * opened (header read) and then read in place, so large pre-built indexes
+ * cost nothing at startup and processes sharing one share its page cache.
  */
 export class StaticIndex implements ISymbolIndex {
   private symbols: IndexedSymbol[] = [];
   private symbolNameIndex: Map<string, IndexedSymbol[]> = new Map();
   private symbolIdIndex: Map<string, IndexedSymbol> = new Map();
   private fileIndex: Map<string, IndexedSymbol[]> = new Map();
+  private binaryIndexes: BinaryIndex[] = [];
   private isLoaded: boolean = false;
 
   /**
@@ -41,6 +47,11 @@ export class StaticIndex implements ISymbolIndex {
   }
 
   private async loadFromFile(filePath: string): Promise<void> {
+    if (await isBinaryIndexFile(filePath)) {
+      this.binaryIndexes.push(await BinaryIndex.open(filePath));
+      return;
+    }
+
     const content = await fsPromises.readFile(filePath, 'utf-8');
     const data = JSON.parse(content);
 
@@ -80,6 +91,14 @@ export class StaticIndex implements ISymbolIndex {
     for (const symbols of results) {
       this.symbols.push(...symbols);
     }
+
+    for (const file of files.filter(file => file.endsWith(BINARY_INDEX_EXTENSION))) {
+      try {
+        this.binaryIndexes.push(await BinaryIndex.open(path.join(dirPath, file)));
+      } catch {
+        // Skip unreadable or incompatible binary indexes like malformed JSON files
+      }
+    }
   }
 
   private buildIndices(): void {
@@ -110,19 +129,29 @@ export class StaticIndex implements ISymbolIndex {
   }
 
   async findDefinitions(name: string): Promise<IndexedSymbol[]> {
-    return this.symbolNameIndex.get(name) || [];
+    return this.withBinary(this.symbolNameIndex.get(name) || [], index => index.findByName(name));
   }
 
   async findDefinitionById(symbolId: string): Promise<IndexedSymbol | null> {
-    return this.symbolIdIndex.get(symbolId) || null;
+    const symbol = this.symbolIdIndex.get(symbolId);
+    if (symbol) {
+      return symbol;
+    }
+    for (const index of this.binaryIndexes) {
+      const found = await index.findById(symbolId);
+      if (found) {
+        return found;
+      }
+    }
+    return null;
   }
 
   async findReferences(name: string): Promise<IndexedSymbol[]> {
-    return this.symbolNameIndex.get(name) || [];
+    return this.findDefinitions(name);
   }
 
   async findReferencesById(symbolId: string): Promise<IndexedSymbol[]> {
-    const def = this.symbolIdIndex.get(symbolId);
+    const def = await this.findDefinitionById(symbolId);
     return def ? [def] : [];
   }
 
@@ -146,20 +175,54 @@ export class StaticIndex implements ISymbolIndex {
       }
     }
 
+    for (const index of this.binaryIndexes) {
+      const matches = await index.findByNameMatching(name => fuzzyScore(name, query) !== null, limit - results.length);
+      for (const symbol of matches) {
+        const key = `${symbol.name}:${symbol.location.uri}:${symbol.location.line}:${symbol.location.character}`;
+        if (!seen.has(key)) {
+          results.push(symbol);
+          seen.add(key);
+          if (results.length >= limit) {
+            return results;
+          }
+        }
+      }
+    }
+
     return results;
   }
 
   async getFileSymbols(uri: string): Promise<IndexedSymbol[]> {
-    return this.fileIndex.get(uri) || [];
+    return this.withBinary(this.fileIndex.get(uri) || [], index => index.getFileSymbols(uri));
   }
 
   getStats(): { files: number; symbols: number } {
     return {
-      files: this.fileIndex.size,
-      symbols: this.symbols.length
+      files: this.fileIndex.size + this.binaryIndexes.reduce((sum, index) => sum + index.fileCount, 0),
+      symbols: this.symbols.length + this.binaryIndexes.reduce((sum, index) => sum + index.symbolCount, 0)
     };
   }
 
+  /**
+   * Close binary index files.
+   */
+  async dispose(): Promise<void> {
+    const indexes = this.binaryIndexes;
+    this.binaryIndexes = [];
+    await Promise.all(indexes.map(index => index.close()));
+  }
+
+  private async withBinary(
+    symbols: IndexedSymbol[],
+    query: (index: BinaryIndex) => Promise<IndexedSymbol[]>
+  ): Promise<IndexedSymbol[]> {
+    if (this.binaryIndexes.length === 0) {
+      return symbols;
+    }
+    const binary = await Promise.all(this.binaryIndexes.map(query));
+    return [...symbols, ...binary.flat()];
+  }
+
   isIndexLoaded(): boolean {
     return this.isLoaded;
   }
@@ -0,0 +1,186 @@
+/**
+ * Bazel Target Tests
+ *
+ * BUILD file parsing, labels and target patterns, mapping source and
+ * generated files to the Go targets that compile them, and collection of
+ * generated sources under bazel-bin.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  parseBuildFile,
+  normalizeLabel,
+  matchesTargetPattern,
+  BazelTargetResolver,
+  annotateBazelTargets,
+  isOutsideBazelTargets,
+  collectBazelGeneratedFiles
+} from './bazelTargets.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const apiBuild = `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+# gazelle:prefix github.com/acme/api
+go_library(
+    name = "api",
+    srcs = [
+        "server.go",
+        ":routes",
+    ] + select({
+        "@io_bazel_rules_go//go/platform:linux": ["server_linux.go"],
+        "//conditions:default": [],
+    }),
+    importpath = "github.com/acme/api",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//store",
+        "@com_github_google_uuid//:uuid",
+    ],
+)
+
+genrule(
+    name = "routes",
+    srcs = ["routes.yaml"],
+    outs = ["routes.go"],
+    cmd = "$(location //tools:gen) $< > $@",
+)
+
+go_test(
+    name = "api_test",
+    srcs = glob(["*_test.go"], exclude = ["legacy_test.go"]),
+    embed = [":api"],
+)
+`;
+
+const storeBuild = `go_library(
+    name = "store",
+    srcs = ["store.go"],
+    importpath = "github.com/acme/api/store",
+    deps = ["//internal/sql"],
+)
+`;
+
+function write(file: string, content: string): void {
+  fs.mkdirSync(path.dirname(file), { recursive: true });
+  fs.writeFileSync(file, content);
+}
+
+describe('parseBuildFile', () => {
+  it('should read rule kinds, names, srcs, deps and outs', () => {
+    const rules = parseBuildFile(apiBuild);
+
+    expect(rules.map(rule => `${rule.kind}:${rule.name}`)).toEqual(['go_library:api', 'genrule:routes', 'go_test:api_test']);
+    expect(rules[0]).toMatchObject({
+      srcs: ['server.go', ':routes', 'server_linux.go'],
+      deps: ['//store', '@com_github_google_uuid//:uuid'],
+      importpath: 'github.com/acme/api'
+    });
+    expect(rules[1].outs).toEqual(['routes.go']);
+    expect(rules[2]).toMatchObject({
+      srcGlobs: [{ include: ['*_test.go'], exclude: ['legacy_test.go'] }],
+      embed: [':api']
+    });
+  });
+});
+
+describe('labels', () => {
+  it('should normalize relative and shorthand labels', () => {
+    expect(normalizeLabel(':api', 'cmd/api')).toBe('//cmd/api:api');
+    expect(normalizeLabel('server', 'cmd/api')).toBe('//cmd/api:server');
+    expect(normalizeLabel('//store', 'cmd/api')).toBe('//store:store');
+    expect(normalizeLabel('@//store:store', '')).toBe('//store:store');
+    expect(normalizeLabel('@com_github_google_uuid//:uuid', 'cmd')).toBe('@com_github_google_uuid//:uuid');
+  });
+
+  it('should match labels against target patterns', () => {
+    expect(matchesTargetPattern('//cmd/api:api', '//cmd/api')).toBe(true);
+    expect(matchesTargetPattern('//cmd/api:api_test', '//cmd/api:all')).toBe(true);
+    expect(matchesTargetPattern('//cmd/api:api', '//cmd/...')).toBe(true);
+    expect(matchesTargetPattern('//cmdline:cmdline', '//cmd/...')).toBe(false);
+    expect(matchesTargetPattern('//store:store', '//...')).toBe(true);
+    expect(matchesTargetPattern('//store:store', '//cmd/api:api')).toBe(false);
+  });
+});
+
+describe('BazelTargetResolver', () => {
+  let root: string;
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'bazel-targets-'));
+    write(path.join(root, 'MODULE.bazel'), 'module(name = "acme")\n');
+    write(path.join(root, 'api', 'BUILD.bazel'), apiBuild);
+    write(path.join(root, 'api', 'server.go'), 'package api\n');
+    write(path.join(root, 'api', 'server_test.go'), 'package api\n');
+    write(path.join(root, 'api', 'legacy_test.go'), 'package api\n');
+    write(path.join(root, 'api', 'scratch.go'), 'package api\n');
+    write(path.join(root, 'store', 'BUILD'), storeBuild);
+    write(path.join(root, 'store', 'store.go'), 'package store\n');
+    write(path.join(root, 'internal', 'sql', 'BUILD.bazel'), 'go_library(name = "sql", srcs = ["sql.go"])\n');
+    write(path.join(root, 'docs', 'BUILD.bazel'), 'filegroup(name = "docs", srcs = glob(["*.md"]))\n');
+    write(path.join(root, 'docs', 'example.go'), 'package docs\n');
+    write(path.join(root, 'bazel-bin', 'api', 'routes.go'), 'package api\n');
+    write(path.join(root, 'bazel-bin', 'api', 'other.go'), 'package api\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should map source files to the Go targets that compile them', () => {
+    const resolver = new BazelTargetResolver();
+
+    expect(resolver.resolve(path.join(root, 'api', 'server.go'))).toEqual({
+      workspaceRoot: root, pkg: 'api', labels: ['//api:api', '//api:api_test'], generated: false
+    });
+    expect(resolver.resolve(path.join(root, 'api', 'server_test.go'))?.labels).toEqual(['//api:api_test']);
+    expect(resolver.resolve(path.join(root, 'store', 'store.go'))?.labels).toEqual(['//store:store']);
+  });
+
+  it('should report Go files no target lists, and ignore packages without Go rules', () => {
+    const resolver = new BazelTargetResolver();
+
+    expect(isOutsideBazelTargets(resolver.resolve(path.join(root, 'api', 'scratch.go')))).toBe(true);
+    expect(isOutsideBazelTargets(resolver.resolve(path.join(root, 'api', 'legacy_test.go')))).toBe(true);
+    expect(resolver.resolve(path.join(root, 'docs', 'example.go'))).toBeUndefined();
+  });
+
+  it('should map generated files to the targets using their rule', () => {
+    const resolver = new BazelTargetResolver();
+
+    expect(resolver.resolve(path.join(root, 'bazel-bin', 'api', 'routes.go'))).toEqual({
+      workspaceRoot: root, pkg: 'api', labels: ['//api:api', '//api:api_test'], generated: true
+    });
+    expect(resolver.resolve(path.join(root, 'bazel-bin', 'api', 'other.go'))?.labels).toEqual([]);
+  });
+
+  it('should follow deps and embed transitively', () => {
+    const resolver = new BazelTargetResolver();
+    const closure = resolver.dependencyClosure('//api:api_test', path.join(root, 'api', 'server.go'));
+
+    expect([...closure].sort()).toEqual([
+      '//api:api',
+      '//api:api_test',
+      '//internal/sql:sql',
+      '//store:store',
+      '@com_github_google_uuid//:uuid'
+    ]);
+  });
+
+  it('should record target labels on symbols', () => {
+    const resolver = new BazelTargetResolver();
+    const symbol = createTestSymbol({ name: 'Serve', metadata: { go: { package: 'api' } } });
+
+    annotateBazelTargets([symbol], resolver.resolve(path.join(root, 'api', 'server.go')));
+
+    expect(symbol.metadata!.go).toEqual({ package: 'api', bazelTargets: ['//api:api', '//api:api_test'] });
+  });
+
+  it('should collect generated sources of targets from bazel-bin', async () => {
+    const files = await collectBazelGeneratedFiles([path.join(root, 'api', 'server.go')]);
+
+    expect(files).toEqual([path.join(root, 'bazel-bin', 'api', 'routes.go')]);
+  });
+});
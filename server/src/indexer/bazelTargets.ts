@@ -0,0 +1,611 @@
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import { minimatch } from 'minimatch';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from './goIndexer.js';
+
+/**
+ * A rule of a BUILD file, with the attributes the indexer reads. Labels
+ * are as written (`:lib`, `//pkg/api`); see normalizeLabel.
+ */
+export interface BazelRule {
+  kind: string;
+  name: string;
+  /** File names and labels of srcs, from lists and select() branches */
+  srcs: string[];
+  /** glob() calls of srcs */
+  srcGlobs: Array<{ include: string[]; exclude: string[] }>;
+  deps: string[];
+  embed: string[];
+  /** Files the rule generates (genrule and friends) */
+  outs: string[];
+  importpath?: string;
+}
+
+/**
+ * Bazel targets of a file, from the BUILD file of its package.
+ */
+export interface BazelFileTargets {
+  /** Directory with the WORKSPACE or MODULE.bazel file */
+  workspaceRoot: string;
+  /** Package path relative to the workspace root (`` for the root package) */
+  pkg: string;
+  /** Labels of the Go targets that compile the file; empty if none does */
+  labels: string[];
+  /** True for files under bazel-bin or bazel-out */
+  generated: boolean;
+}
+
+const BUILD_FILES = ['BUILD.bazel', 'BUILD'];
+const WORKSPACE_FILES = ['MODULE.bazel', 'WORKSPACE.bazel', 'WORKSPACE', 'REPO.bazel'];
+/** Output trees of `bazel build`, symlinked into the workspace root */
+const OUTPUT_DIRS = new Set(['bazel-bin', 'bazel-out']);
+
+// ---------------------------------------------------------------------------
+// BUILD file parsing
+
+const CLOSING_BRACKETS = new Map([['(', ')'], ['[', ']'], ['{', '}']]);
+/** Tokens after an expression: separators and the ends of groups and dict keys */
+const EXPRESSION_ENDS = [',', ')', ']', '}', ':'];
+
+type Token = { type: 'string' | 'ident' | 'punct' | 'other'; text: string };
+
+type Value = string | Value[] | GlobValue | undefined;
+
+interface GlobValue {
+  glob: { include: string[]; exclude: string[] };
+}
+
+/**
+ * The rule calls of a BUILD file (Starlark). Only literal values are
+ * evaluated: strings, lists and their concatenation, glob() and the
+ * branches of select(); other expressions read as unknown.
+ */
+export function parseBuildFile(content: string): BazelRule[] {
+  const parser = new BuildFileParser(tokenize(content));
+  return parser.parseRules();
+}
+
+function tokenize(content: string): Token[] {
+  const tokens: Token[] = [];
+  const re = /#[^\n]*|[rRbB]?("""[\s\S]*?"""|'''[\s\S]*?'''|"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*')|([A-Za-z_][A-Za-z0-9_]*)|([()[\]{},=+:.])|(\s+)|(.)/g;
+  let match: RegExpExecArray | null;
+  while ((match = re.exec(content)) !== null) {
+    if (match[1] !== undefined) {
+      const quoted = match[1];
+      const quote = quoted.startsWith('"""') || quoted.startsWith("'''") ? 3 : 1;
+      tokens.push({ type: 'string', text: quoted.slice(quote, -quote).replace(/\\(.)/g, '$1') });
+    } else if (match[2] !== undefined) {
+      tokens.push({ type: 'ident', text: match[2] });
+    } else if (match[3] !== undefined) {
+      tokens.push({ type: 'punct', text: match[3] });
+    } else if (match[5] !== undefined) {
+      tokens.push({ type: 'other', text: match[5] });
+    }
+  }
+  return tokens;
+}
+
+class BuildFileParser {
+  private position = 0;
+
+  constructor(private tokens: Token[]) {}
+
+  parseRules(): BazelRule[] {
+    const rules: BazelRule[] = [];
+    while (this.position < this.tokens.length) {
+      const token = this.tokens[this.position];
+      if (token.type === 'ident' && this.isPunct(this.position + 1, '(')) {
+        this.position += 2;
+        const args = this.parseArguments();
+        const name = args.get('name');
+        if (typeof name === 'string' && token.text !== 'load' && token.text !== 'package') {
+          rules.push(toRule(token.text, name, args));
+        }
+      } else {
+        this.position++;
+      }
+    }
+    return rules;
+  }
+
+  /** Keyword arguments of a call, after its `(`, up to and past its `)` */
+  private parseArguments(): Map<string, Value> {
+    const args = new Map<string, Value>();
+    let index = 0;
+    while (this.position < this.tokens.length && !this.isPunct(this.position, ')')) {
+      if (this.tokens[this.position].type === 'ident' && this.isPunct(this.position + 1, '=')) {
+        const key = this.tokens[this.position].text;
+        this.position += 2;
+        args.set(key, this.parseExpression());
+      } else {
+        args.set(`#${index}`, this.parseExpression());
+      }
+      index++;
+      if (this.isPunct(this.position, ',')) {
+        this.position++;
+      } else if (!this.isPunct(this.position, ')')) {
+        this.skipOne();
+      }
+    }
+    this.position++;
+    return args;
+  }
+
+  private parseExpression(): Value {
+    let value = this.parsePrimary();
+    while (this.isPunct(this.position, '+')) {
+      this.position++;
+      const right = this.parsePrimary();
+      value = concat(value, right);
+    }
+    // Skip what is not evaluated, e.g. `if` expressions, `%` formatting
+    while (this.position < this.tokens.length && !EXPRESSION_ENDS.some(end => this.isPunct(this.position, end))) {
+      this.skipOne();
+      value = undefined;
+    }
+    return value;
+  }
+
+  private parsePrimary(): Value {
+    const token = this.tokens[this.position];
+    if (!token) {
+      return undefined;
+    }
+    if (token.type === 'string') {
+      this.position++;
+      // Adjacent strings concatenate
+      let text = token.text;
+      while (this.tokens[this.position]?.type === 'string') {
+        text += this.tokens[this.position++].text;
+      }
+      return text;
+    }
+    if (token.type === 'punct' && token.text === '[') {
+      this.position++;
+      const items: Value[] = [];
+      while (this.position < this.tokens.length && !this.isPunct(this.position, ']')) {
+        if (this.tokens[this.position].type === 'ident' && this.tokens[this.position].text === 'for') {
+          this.skipUntilClose(']');
+          return undefined;
+        }
+        items.push(this.parseExpression());
+        if (this.isPunct(this.position, ',')) {
+          this.position++;
+        } else if (!this.isPunct(this.position, ']')) {
+          this.skipOne();
+        }
+      }
+      this.position++;
+      return items;
+    }
+    if (token.type === 'ident' && this.isPunct(this.position + 1, '(')) {
+      this.position += 2;
+      if (token.text === 'select') {
+        return this.parseSelect();
+      }
+      const args = this.parseArguments();
+      if (token.text === 'glob') {
+        return { glob: { include: strings(args.get('#0') ?? args.get('include')), exclude: strings(args.get('exclude')) } };
+      }
+      return undefined;
+    }
+    this.skipOne();
+    return undefined;
+  }
+
+  /** select({condition: value, ...}) as the union of its values */
+  private parseSelect(): Value {
+    const branches: Value[] = [];
+    if (this.isPunct(this.position, '{')) {
+      this.position++;
+      while (this.position < this.tokens.length && !this.isPunct(this.position, '}')) {
+        this.parseExpression();
+        if (this.isPunct(this.position, ':')) {
+          this.position++;
+          branches.push(this.parseExpression());
+        }
+        if (this.isPunct(this.position, ',')) {
+          this.position++;
+        } else if (!this.isPunct(this.position, '}')) {
+          this.skipOne();
+        }
+      }
+      this.position++;
+    }
+    this.skipUntilClose(')');
+    return branches.flatMap(branch => Array.isArray(branch) ? branch : [branch]);
+  }
+
+  /** Skip a token, or a whole bracketed group */
+  private skipOne(): void {
+    const token = this.tokens[this.position++];
+    const close = token?.type === 'punct' ? CLOSING_BRACKETS.get(token.text) : undefined;
+    if (close) {
+      this.skipUntilClose(close);
+    }
+  }
+
+  /** Skip past the bracket that closes the group the parser is in */
+  private skipUntilClose(close: string): void {
+    while (this.position < this.tokens.length && !this.isPunct(this.position, close)) {
+      this.skipOne();
+    }
+    this.position++;
+  }
+
+  private isPunct(position: number, text: string): boolean {
+    const token = this.tokens[position];
+    return token !== undefined && token.type === 'punct' && token.text === text;
+  }
+}
+
+function concat(left: Value, right: Value): Value {
+  if (typeof left === 'string' && typeof right === 'string') {
+    return left + right;
+  }
+  const items = (value: Value) => Array.isArray(value) ? value : value === undefined ? [] : [value];
+  return [...items(left), ...items(right)];
+}
+
+function strings(value: Value): string[] {
+  if (typeof value === 'string') {
+    return [value];
+  }
+  return Array.isArray(value) ? value.flatMap(strings) : [];
+}
+
+function globs(value: Value): Array<{ include: string[]; exclude: string[] }> {
+  if (Array.isArray(value)) {
+    return value.flatMap(globs);
+  }
+  return value !== undefined && typeof value === 'object' ? [value.glob] : [];
+}
+
+function toRule(kind: string, name: string, args: Map<string, Value>): BazelRule {
+  const importpath = args.get('importpath');
+  return {
+    kind,
+    name,
+    srcs: strings(args.get('srcs')),
+    srcGlobs: globs(args.get('srcs')),
+    deps: strings(args.get('deps')),
+    embed: strings(args.get('embed')),
+    outs: [...strings(args.get('outs')), ...strings(args.get('out'))],
+    ...(typeof importpath === 'string' && { importpath })
+  };
+}
+
+// ---------------------------------------------------------------------------
+// Labels
+
+/**
+ * A label in its canonical form, `//pkg:name`, resolved against the
+ * package that mentions it: `:name` and `name` are in that package,
+ * `//pkg` is `//pkg:pkg`. Labels of other repositories (`@repo//...`)
+ * are kept as they are.
+ */
+export function normalizeLabel(label: string, pkg: string): string {
+  if (label.startsWith('@') && !label.startsWith('@//') && !label.startsWith('@@//')) {
+    return label;
+  }
+  const local = label.replace(/^@@?/, '');
+  if (!local.startsWith('//')) {
+    return `//${pkg}:${local.replace(/^:/, '')}`;
+  }
+  const colon = local.indexOf(':');
+  if (colon >= 0) {
+    return local;
+  }
+  return `${local}:${path.posix.basename(local.substring(2)) || local.substring(2)}`;
+}
+
+/**
+ * Whether a label matches a target pattern: a label, `//pkg:all` or
+ * `//pkg:*` for the targets of a package, `//pkg/...` for a package and
+ * the packages below it.
+ */
+export function matchesTargetPattern(label: string, pattern: string): boolean {
+  if (pattern.endsWith('/...') || pattern === '//...') {
+    const prefix = pattern === '//...' ? '//' : pattern.slice(0, -4);
+    const pkg = label.substring(0, label.indexOf(':'));
+    return prefix === '//' ? label.startsWith('//') : pkg === prefix || pkg.startsWith(prefix + '/');
+  }
+  const normalized = normalizeLabel(pattern, '');
+  const [pkg, name] = normalized.split(':');
+  if (name === 'all' || name === '*') {
+    return label.startsWith(pkg + ':');
+  }
+  return label === normalized;
+}
+
+// ---------------------------------------------------------------------------
+// Resolution
+
+/**
+ * Maps files to the Bazel targets that compile them, from the BUILD files
+ * Gazelle writes.
+ *
+ * A file belongs to the package of the nearest BUILD or BUILD.bazel file
+ * above it, up to the workspace root. Its targets are the Go rules
+ * (`go_library`, `go_test`, ...) listing it in srcs, directly or through
+ * glob(), and the rules embedding those. Generated files under bazel-bin
+ * or bazel-out belong to the package of their source directory: to the
+ * rule whose outs name them, or whose rules_go output directory
+ * (`<name>_/`) they are in, and to the Go rules listing that rule in srcs
+ * or embed.
+ *
+ * Lookups are synchronous (used from indexing workers) and cached per
+ * directory; BUILD files are re-read when their mtime changes.
+ */
+export class BazelTargetResolver {
+  private workspaceByDir = new Map<string, string | null>();
+  private buildFiles = new Map<string, { mtimeMs: number; rules: BazelRule[] }>();
+  private closures = new Map<string, Set<string>>();
+
+  resolve(filePath: string): BazelFileTargets | undefined {
+    const absolute = path.resolve(filePath);
+    const workspaceRoot = this.workspaceRootOf(path.dirname(absolute));
+    if (!workspaceRoot) {
+      return undefined;
+    }
+    const segments = path.relative(workspaceRoot, absolute).split(path.sep);
+    const generated = OUTPUT_DIRS.has(segments[0]);
+    // bazel-bin/<pkg>/..., bazel-out/<config>/bin/<pkg>/...
+    const sourceSegments = !generated ? segments : segments[0] === 'bazel-bin' ? segments.slice(1) : segments.slice(3);
+
+    for (let depth = sourceSegments.length - 1; depth >= 0; depth--) {
+      const pkg = sourceSegments.slice(0, depth).join('/');
+      const rules = this.packageRules(workspaceRoot, pkg);
+      if (!rules) {
+        continue;
+      }
+      if (!rules.some(isGoRule)) {
+        return undefined;
+      }
+      const relative = sourceSegments.slice(depth).join('/');
+      const direct = generated ? generatingRules(rules, relative) : rules.filter(rule => listsSource(rule, relative));
+      return { workspaceRoot, pkg, labels: goTargetsOf(rules, direct, pkg), generated };
+    }
+    return undefined;
+  }
+
+  /**
+   * Nearest directory at or above dir with a WORKSPACE or MODULE.bazel
+   * file, or null. Inside bazel-bin and bazel-out, the directory holding
+   * them.
+   */
+  workspaceRootOf(dir: string): string | null {
+    const visited: string[] = [];
+    let current = path.resolve(dir);
+    let found: string | null = null;
+
+    while (true) {
+      const cached = this.workspaceByDir.get(current);
+      if (cached !== undefined) {
+        found = cached;
+        break;
+      }
+      visited.push(current);
+      if (WORKSPACE_FILES.some(name => fs.existsSync(path.join(current, name)))) {
+        found = current;
+        break;
+      }
+      const parent = path.dirname(current);
+      if (parent === current) {
+        break;
+      }
+      current = parent;
+    }
+
+    for (const visitedDir of visited) {
+      this.workspaceByDir.set(visitedDir, found);
+    }
+    return found;
+  }
+
+  /** Rules of a package's BUILD file, or undefined if pkg is not a package */
+  packageRules(workspaceRoot: string, pkg: string): BazelRule[] | undefined {
+    for (const name of BUILD_FILES) {
+      const buildPath = path.join(workspaceRoot, ...pkg.split('/').filter(Boolean), name);
+      let mtimeMs: number;
+      try {
+        const stat = fs.statSync(buildPath);
+        if (!stat.isFile()) {
+          continue;
+        }
+        mtimeMs = stat.mtimeMs;
+      } catch {
+        continue;
+      }
+      const cached = this.buildFiles.get(buildPath);
+      if (cached && cached.mtimeMs === mtimeMs) {
+        return cached.rules;
+      }
+      let rules: BazelRule[];
+      try {
+        rules = parseBuildFile(fs.readFileSync(buildPath, 'utf-8'));
+      } catch {
+        rules = [];
+      }
+      this.buildFiles.set(buildPath, { mtimeMs, rules });
+      this.closures.clear();
+      return rules;
+    }
+    return undefined;
+  }
+
+  /**
+   * A target and what it depends on through deps and embed, transitively,
+   * within the workspace of filePath. Labels of other repositories are
+   * included but not followed.
+   */
+  dependencyClosure(label: string, filePath: string): Set<string> {
+    const workspaceRoot = this.workspaceRootOf(path.dirname(path.resolve(filePath)));
+    const start = normalizeLabel(label, '');
+    if (!workspaceRoot) {
+      return new Set([start]);
+    }
+    const key = `${workspaceRoot}\0${start}`;
+    const cached = this.closures.get(key);
+    if (cached) {
+      return cached;
+    }
+
+    const closure = new Set<string>([start]);
+    const pending = [start];
+    while (pending.length > 0) {
+      const current = pending.pop()!;
+      if (!current.startsWith('//')) {
+        continue;
+      }
+      const [pkg, name] = current.substring(2).split(':');
+      const rule = this.packageRules(workspaceRoot, pkg)?.find(r => r.name === name);
+      for (const dependency of [...(rule?.deps ?? []), ...(rule?.embed ?? [])]) {
+        const normalized = normalizeLabel(dependency, pkg);
+        if (!closure.has(normalized)) {
+          closure.add(normalized);
+          pending.push(normalized);
+        }
+      }
+    }
+    this.closures.set(key, closure);
+    return closure;
+  }
+}
+
+function isGoRule(rule: BazelRule): boolean {
+  return rule.kind.startsWith('go_');
+}
+
+function listsSource(rule: BazelRule, relative: string): boolean {
+  if (rule.srcs.some(src => !src.includes(':') && !src.startsWith('//') && src === relative)) {
+    return true;
+  }
+  return rule.srcGlobs.some(glob =>
+    glob.include.some(pattern => minimatch(relative, pattern)) &&
+    !glob.exclude.some(pattern => minimatch(relative, pattern))
+  );
+}
+
+/** Rules that generate a file at relative, a path below the package in the output tree */
+function generatingRules(rules: BazelRule[], relative: string): BazelRule[] {
+  const outputDir = relative.split('/')[0];
+  return rules.filter(rule => rule.outs.includes(relative) || (relative.includes('/') && outputDir === `${rule.name}_`));
+}
+
+/**
+ * Labels of the Go rules of a package that compile the files of the
+ * direct rules: those rules themselves if they are Go rules, and Go rules
+ * listing them in srcs or embed, transitively.
+ */
+function goTargetsOf(rules: BazelRule[], direct: BazelRule[], pkg: string): string[] {
+  const byLabel = new Map(rules.map(rule => [normalizeLabel(rule.name, pkg), rule]));
+  const reached = new Set(direct.map(rule => normalizeLabel(rule.name, pkg)));
+  const pending = [...reached];
+  while (pending.length > 0) {
+    const label = pending.pop()!;
+    for (const [candidate, rule] of byLabel) {
+      if (reached.has(candidate)) {
+        continue;
+      }
+      const includes = [...rule.embed, ...rule.srcs.filter(src => src.includes(':') || src.startsWith('//'))];
+      if (includes.some(included => normalizeLabel(included, pkg) === label)) {
+        reached.add(candidate);
+        pending.push(candidate);
+      }
+    }
+  }
+  return [...reached].filter(label => isGoRule(byLabel.get(label)!)).sort();
+}
+
+/**
+ * Record the Bazel targets of a Go file in `metadata.go.bazelTargets`
+ * of every symbol.
+ */
+export function annotateBazelTargets(symbols: IndexedSymbol[], targets: BazelFileTargets | undefined): void {
+  if (!targets || targets.labels.length === 0) {
+    return;
+  }
+  for (const symbol of symbols) {
+    const go: GoSymbolMetadata = {
+      ...(symbol.metadata?.go as GoSymbolMetadata | undefined),
+      bazelTargets: targets.labels
+    };
+    symbol.metadata = { ...symbol.metadata, go };
+  }
+}
+
+/**
+ * Whether indexing should skip a Go file because Bazel does not build
+ * it: it is in a package with Go rules, none of which lists it.
+ */
+export function isOutsideBazelTargets(targets: BazelFileTargets | undefined): boolean {
+  return targets !== undefined && targets.labels.length === 0;
+}
+
+/**
+ * Generated Go sources of the workspace packages of the given files that
+ * a Go target compiles, from the bazel-bin output tree of their
+ * workspace (run `bazel build` to produce them).
+ */
+export async function collectBazelGeneratedFiles(
+  workspaceFiles: string[],
+  resolver: BazelTargetResolver = new BazelTargetResolver()
+): Promise<string[]> {
+  const scanned = new Set(workspaceFiles.map(file => path.resolve(file)));
+  const packages = new Map<string, Set<string>>();
+  for (const dir of new Set(workspaceFiles.map(file => path.dirname(path.resolve(file))))) {
+    const workspaceRoot = resolver.workspaceRootOf(dir);
+    if (!workspaceRoot) {
+      continue;
+    }
+    const segments = path.relative(workspaceRoot, dir).split(path.sep).filter(Boolean);
+    if (OUTPUT_DIRS.has(segments[0])) {
+      continue;
+    }
+    for (let depth = segments.length; depth >= 0; depth--) {
+      const pkg = segments.slice(0, depth).join('/');
+      if (resolver.packageRules(workspaceRoot, pkg)) {
+        if (!packages.has(workspaceRoot)) {
+          packages.set(workspaceRoot, new Set());
+        }
+        packages.get(workspaceRoot)!.add(pkg);
+        break;
+      }
+    }
+  }
+
+  const files: string[] = [];
+  for (const [workspaceRoot, pkgs] of packages) {
+    for (const pkg of pkgs) {
+      const outputDir = path.join(workspaceRoot, 'bazel-bin', ...pkg.split('/').filter(Boolean));
+      const walk = async (dir: string, relative: string): Promise<void> => {
+        let entries: fs.Dirent[];
+        try {
+          entries = await fsPromises.readdir(dir, { withFileTypes: true });
+        } catch {
+          return;
+        }
+        for (const entry of entries) {
+          const fullPath = path.join(dir, entry.name);
+          const entryRelative = relative ? `${relative}/${entry.name}` : entry.name;
+          if (entry.isDirectory()) {
+            // Subpackages are walked as packages of their own; runfiles repeat sources
+            const subpackage = [pkg, entryRelative].filter(Boolean).join('/');
+            if (!entry.name.endsWith('.runfiles') && !resolver.packageRules(workspaceRoot, subpackage)) {
+              await walk(fullPath, entryRelative);
+            }
+          } else if (entry.isFile() && /\.(go|s)$/.test(entry.name)) {
+            if (!scanned.has(fullPath) && (resolver.resolve(fullPath)?.labels.length ?? 0) > 0) {
+              files.push(fullPath);
+            }
+          }
+        }
+      };
+      await walk(outputDir, '');
+    }
+  }
+  return files.sort();
+}
@@ -0,0 +1,102 @@
+/**
+ * Canonical ID Tests
+ *
+ * Package paths, qualified names and signature hashes of canonical symbol
+ * IDs, and their stability across checkouts and edits.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { PackagePathResolver, canonicalIdName } from './canonicalIds.js';
+import { processFileContent } from './worker.js';
+import { IndexedSymbol } from '../types.js';
+
+function write(file: string, content: string): void {
+  fs.mkdirSync(path.dirname(file), { recursive: true });
+  fs.writeFileSync(file, content);
+}
+
+describe('canonical IDs', () => {
+  let testDir: string;
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-canonical-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  const goSource = [
+    'package user',
+    '',
+    'type Person struct{ Name string }',
+    '',
+    'func (p *Person) Greet(greeting string) string { return greeting + p.Name }',
+    '',
+    'func New() *Person { return &Person{} }',
+    ''
+  ].join('\n');
+
+  async function canonicalIds(file: string, content: string): Promise<Array<string | undefined>> {
+    const result = await processFileContent(file, content);
+    return result.symbols.filter(s => s.isDefinition !== false).map((s: IndexedSymbol) => s.canonicalId);
+  }
+
+  it('should scope Go symbols by import path and qualify methods by receiver', async () => {
+    write(path.join(testDir, 'go.mod'), 'module github.com/acme/api\n');
+    const file = path.join(testDir, 'pkg', 'user', 'user.go');
+    write(file, goSource);
+
+    const ids = await canonicalIds(file, goSource);
+    expect(ids).toContain('github.com/acme/api/pkg/user:Person');
+    expect(ids).toContain('github.com/acme/api/pkg/user:Person.Name');
+    expect(ids.find(id => id?.includes('Greet'))).toMatch(/^github\.com\/acme\/api\/pkg\/user:\(\*Person\)\.Greet#[0-9a-f]{8}$/);
+    expect(canonicalIdName(ids.find(id => id?.includes('Greet'))!)).toBe('Greet');
+  });
+
+  it('should not depend on the checkout location or on positions', async () => {
+    const checkouts = ['one', 'two'].map(name => {
+      write(path.join(testDir, name, 'go.mod'), 'module github.com/acme/api\n');
+      const file = path.join(testDir, name, 'user.go');
+      write(file, name === 'one' ? goSource : `// Moved down\n\n${goSource}`);
+      return file;
+    });
+
+    const [first, second] = await Promise.all(checkouts.map(file => canonicalIds(file, fs.readFileSync(file, 'utf-8'))));
+    expect(second).toEqual(first);
+    const results = await Promise.all(checkouts.map(file => processFileContent(file, fs.readFileSync(file, 'utf-8'))));
+    expect(results[0].symbols[0].id).not.toBe(results[1].symbols[0].id);
+  });
+
+  it('should change with the signature of callables', async () => {
+    write(path.join(testDir, 'go.mod'), 'module example.com/m\n');
+    const file = path.join(testDir, 'user.go');
+    const before = await canonicalIds(file, goSource);
+    const after = await canonicalIds(file, goSource.replace('Greet(greeting string)', 'Greet(greeting string, loud bool)'));
+
+    expect(after.find(id => id?.includes('Greet'))).not.toBe(before.find(id => id?.includes('Greet')));
+    expect(after.filter(id => !id?.includes('Greet'))).toEqual(before.filter(id => !id?.includes('Greet')));
+  });
+
+  it('should scope other languages by project and module path', async () => {
+    write(path.join(testDir, 'svc', 'pyproject.toml'), '[project]\n');
+    const file = path.join(testDir, 'svc', 'app', 'models.py');
+    const content = 'class User:\n    def greet(self, greeting):\n        return greeting\n';
+    write(file, content);
+
+    const ids = await canonicalIds(file, content);
+    expect(ids).toContain('svc/app/models:User');
+    expect(ids.find(id => id?.includes('greet'))).toMatch(/^svc\/app\/models:User\.greet#[0-9a-f]{8}$/);
+  });
+
+  it('should name projects after package.json', () => {
+    write(path.join(testDir, 'packages', 'ui', 'package.json'), JSON.stringify({ name: '@acme/ui' }));
+    const resolver = new PackagePathResolver();
+
+    expect(resolver.modulePath(path.join(testDir, 'packages', 'ui', 'src', 'button.tsx'))).toBe('@acme/ui/src/button');
+    expect(resolver.directoryPath(path.join(testDir, 'packages', 'ui'))).toBe('@acme/ui');
+  });
+});
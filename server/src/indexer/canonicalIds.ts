@@ -0,0 +1,177 @@
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from './goIndexer.js';
+
+/**
+ * Files that mark the root of a project; the nearest one above a file
+ * decides its package path.
+ */
+const PROJECT_MARKERS = [
+  'package.json',
+  'go.mod',
+  'pyproject.toml',
+  'setup.py',
+  'setup.cfg',
+  'Cargo.toml',
+  'pom.xml',
+  'build.gradle',
+  '.git'
+];
+
+const CALLABLE_KINDS = new Set(['function', 'method', 'constructor']);
+
+/** Kinds that are occurrences of words, not declarations */
+const UNNAMED_KINDS = new Set(['text']);
+
+interface Project {
+  dir: string;
+  name: string;
+}
+
+/**
+ * Maps files to machine-independent package paths: the name of the nearest
+ * project (package.json `name`, else the project directory's name) plus
+ * the path within it, e.g. `@acme/ui/src/button` for
+ * `/home/me/acme/packages/ui/src/button.tsx`.
+ *
+ * Lookups are synchronous (used from indexing workers) and cached per
+ * directory.
+ */
+export class PackagePathResolver {
+  private projectByDir: Map<string, Project | null> = new Map();
+
+  /**
+   * Package path of a file: project name + file path without extension.
+   */
+  modulePath(filePath: string): string {
+    const absolute = path.resolve(filePath);
+    const withoutExtension = absolute.slice(0, absolute.length - path.extname(absolute).length);
+    return this.relativeToProject(withoutExtension, path.dirname(absolute));
+  }
+
+  /**
+   * Package path of a directory: project name + directory path.
+   */
+  directoryPath(dir: string): string {
+    const absolute = path.resolve(dir);
+    return this.relativeToProject(absolute, absolute);
+  }
+
+  private relativeToProject(target: string, searchFrom: string): string {
+    const project = this.findProject(searchFrom);
+    if (!project) {
+      return toPosix(target);
+    }
+    const relative = toPosix(path.relative(project.dir, target));
+    return relative ? `${project.name}/${relative}` : project.name;
+  }
+
+  private findProject(dir: string): Project | null {
+    const visited: string[] = [];
+    let current = dir;
+    let found: Project | null = null;
+
+    while (true) {
+      const cached = this.projectByDir.get(current);
+      if (cached !== undefined) {
+        found = cached;
+        break;
+      }
+      visited.push(current);
+      if (PROJECT_MARKERS.some(marker => fs.existsSync(path.join(current, marker)))) {
+        found = { dir: current, name: projectName(current) };
+        break;
+      }
+      const parent = path.dirname(current);
+      if (parent === current) {
+        break;
+      }
+      current = parent;
+    }
+
+    for (const visitedDir of visited) {
+      this.projectByDir.set(visitedDir, found);
+    }
+    return found;
+  }
+}
+
+/**
+ * Canonical ID of a declaration: `<package>:<qualified name>[#<signature hash>]`,
+ * e.g. `github.com/acme/api/user:(*Person).Greet#5f1c0a3e`.
+ *
+ * Unlike `id`, it depends neither on where the checkout lives nor on the
+ * position of the declaration, so it matches the same symbol across runs,
+ * machines, shards and revisions. Go symbols are scoped by import path (the
+ * package, so every file of a package shares one scope); other languages by
+ * the module path of their file. Callables carry a hash of their signature
+ * to tell overloads apart.
+ */
+export function canonicalSymbolId(symbol: IndexedSymbol, scope: string): string {
+  const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+  let qualifiedName: string;
+  if (go?.receiverType) {
+    qualifiedName = `${go.pointerReceiver ? `(*${go.receiverType})` : go.receiverType}.${symbol.name}`;
+  } else {
+    const container = go ? symbol.containerName : (symbol.fullContainerPath ?? symbol.containerName);
+    qualifiedName = container ? `${container}.${symbol.name}` : symbol.name;
+  }
+
+  const id = `${scope}:${qualifiedName}`;
+  if (!CALLABLE_KINDS.has(symbol.kind)) {
+    return id;
+  }
+  const signature = symbol.signature !== undefined
+    ? symbol.signature.replace(/\s+/g, ' ').trim()
+    : `(${symbol.parametersCount ?? 0})`;
+  return `${id}#${crypto.createHash('sha256').update(signature).digest('hex').substring(0, 8)}`;
+}
+
+/**
+ * Symbol name of a canonical ID: `Greet` for `.../user:(*Person).Greet#5f1c0a3e`.
+ */
+export function canonicalIdName(canonicalId: string): string {
+  const qualifiedName = canonicalId.slice(canonicalId.lastIndexOf(':') + 1).replace(/#[0-9a-f]*$/, '');
+  return qualifiedName.slice(qualifiedName.lastIndexOf('.') + 1);
+}
+
+/**
+ * Set `canonicalId` on the declarations of one file. Text occurrences and
+ * references are left without one.
+ */
+export function assignCanonicalIds(symbols: IndexedSymbol[], filePath: string, resolver: PackagePathResolver): void {
+  let moduleScope: string | undefined;
+  let packageScope: string | undefined;
+
+  for (const symbol of symbols) {
+    if (symbol.isDefinition === false || UNNAMED_KINDS.has(symbol.kind)) {
+      continue;
+    }
+    const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+    let scope: string;
+    if (go) {
+      scope = go.importPath ?? (packageScope ??= resolver.directoryPath(path.dirname(filePath)));
+    } else {
+      scope = moduleScope ??= resolver.modulePath(filePath);
+    }
+    symbol.canonicalId = canonicalSymbolId(symbol, scope);
+  }
+}
+
+function projectName(dir: string): string {
+  try {
+    const manifest = JSON.parse(fs.readFileSync(path.join(dir, 'package.json'), 'utf-8'));
+    if (typeof manifest.name === 'string' && manifest.name) {
+      return manifest.name;
+    }
+  } catch {
+    // No package.json (or not JSON): fall back to the directory name
+  }
+  return path.basename(dir);
+}
+
+function toPosix(filePath: string): string {
+  return filePath.split(path.sep).join('/');
+}
@@ -1,5 +1,7 @@
 import { parse, TSESTree } from '@typescript-eslint/typescript-estree';
 import * as crypto from 'crypto';
+import { ParseDiagnostic } from '../../types.js';
+import { parseWithRecovery } from './ParseRecovery.js';
 
 /**
  * AstParser - Wraps the TypeScript-ESTree parser with consistent options.
@@ -14,19 +16,35 @@ export class AstParser {
    */
   parse(content: string, uri: string): TSESTree.Program | null {
     try {
-      return parse(content, {
-        loc: true,
-        range: true,
-        comment: false,
-        tokens: false,
-        errorOnUnknownASTType: false,
-        jsx: uri.endsWith('x')
-      });
+      return this.parseStrict(content, uri);
     } catch (error) {
       return null;
     }
   }
 
+  /**
+   * Parse source code, skipping the top-level declarations that contain
+   * syntax errors (see parseWithRecovery).
+   *
+   * @returns The AST of the rest of the file (null if it could not be
+   * recovered) and the syntax errors found
+   */
+  parseTolerant(content: string, uri: string): { ast: TSESTree.Program | null; diagnostics: ParseDiagnostic[] } {
+    const { result, diagnostics } = parseWithRecovery(content, source => this.parseStrict(source, uri));
+    return { ast: result, diagnostics };
+  }
+
+  private parseStrict(content: string, uri: string): TSESTree.Program {
+    return parse(content, {
+      loc: true,
+      range: true,
+      comment: false,
+      tokens: false,
+      errorOnUnknownASTType: false,
+      jsx: uri.endsWith('x')
+    });
+  }
+
   /**
    * Compute a SHA-256 hash of the content for change detection.
    */
@@ -0,0 +1,67 @@
+/**
+ * Closure Extractor Tests
+ *
+ * Verifies names, containers and captures of TS closures, through the
+ * symbol indexer.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { SymbolIndexer } from '../symbolIndexer.js';
+import { IndexedSymbol } from '../../types.js';
+
+const serverTs = `import express from 'express';
+
+const app = express();
+
+app.get('/health', (req, res) => res.send('ok'));
+
+export class UserService {
+  private retries = 3;
+
+  onSaved = (user: User) => this.notify(user);
+
+  async save(users: User[], { dryRun }: SaveOptions) {
+    const limit = this.retries;
+    return Promise.all(users.map(async user => {
+      const attempt = function () {
+        return dryRun ? user : store(user, limit);
+      };
+      return attempt();
+    }));
+  }
+}
+
+export const handler = (event: Event) => {
+  emit(app, event);
+};
+`;
+
+describe('ClosureExtractor', () => {
+  async function closures(): Promise<IndexedSymbol[]> {
+    const result = await new SymbolIndexer().indexFile('/ws/src/server.ts', serverTs);
+    return result.symbols.filter(s => s.kind === 'closure');
+  }
+
+  it('should name closures after their declaration and share its container', async () => {
+    expect((await closures()).map(s => [s.containerName, s.name, s.location.line, s.location.character])).toEqual([
+      [undefined, 'server$1', 4, 19],
+      ['UserService', 'onSaved$1', 9, 12],
+      ['UserService', 'save$1', 13, 33],
+      ['UserService', 'save$1$1', 14, 22],
+      [undefined, 'handler$1', 22, 23]
+    ]);
+  });
+
+  it('should record the variables of enclosing functions closures use', async () => {
+    const [health, onSaved, map, attempt, handler] = await closures();
+
+    expect(attempt.captures).toEqual(['dryRun', 'user', 'limit']);
+    expect(map.captures).toEqual(['dryRun', 'limit']);
+    // Module-level names and parameters of the closure itself are not captures
+    expect(handler.captures).toBeUndefined();
+    expect(health.captures).toBeUndefined();
+    expect(onSaved.captures).toBeUndefined();
+    expect(map.parametersCount).toBe(1);
+    expect(map.metrics?.complexity).toBe(2);
+  });
+});
@@ -0,0 +1,338 @@
+import { AST_NODE_TYPES, TSESTree } from '@typescript-eslint/typescript-estree';
+import * as path from 'path';
+import { IndexedSymbol } from '../../types.js';
+import { createSymbolId } from '../symbolResolver.js';
+
+type FunctionNode = TSESTree.FunctionDeclaration | TSESTree.FunctionExpression | TSESTree.ArrowFunctionExpression;
+
+/**
+ * What closures are named after: a function, method, class field or
+ * module-level variable, or the closure they are written in.
+ */
+interface Owner {
+  name: string;
+  containerName?: string;
+  containerKind?: string;
+  fullContainerPath?: string;
+  /** Closures numbered so far */
+  count: number;
+}
+
+interface Scope {
+  names: Set<string>;
+  /** Captures of the closure this is the scope of; undefined for other functions and the module */
+  captures?: Set<string>;
+}
+
+/** Child keys holding types, which reference no variables */
+const TYPE_KEYS = new Set(['typeAnnotation', 'returnType', 'typeParameters', 'typeArguments', 'superTypeArguments', 'superTypeParameters']);
+
+const TYPE_DECLARATIONS = new Set<string>([
+  AST_NODE_TYPES.TSInterfaceDeclaration,
+  AST_NODE_TYPES.TSTypeAliasDeclaration,
+  AST_NODE_TYPES.TSDeclareFunction
+]);
+
+/**
+ * ClosureExtractor - anonymous functions and arrow functions of a TS/JS
+ * file as `closure` symbols.
+ *
+ * Closures are named after the declaration they are written in and
+ * numbered in source order (`save$1`, `save$2`, `save$1$1` for one inside
+ * the first), sharing its container; the declaration's symbol must be in
+ * `symbols`. Closures outside any declaration (`app.get('/', (req, res) =>
+ * ...)`) are named after the file: `server$1`. Method bodies and object
+ * literal methods are not closures.
+ *
+ * Captures are the variables a closure, or one nested in it, uses from
+ * the functions around it. Module-level names are not captures. Scopes are
+ * per function: block-scoped declarations count for the whole function.
+ */
+export function extractTsClosures(ast: TSESTree.Program, uri: string, symbols: IndexedSymbol[]): IndexedSymbol[] {
+  const declarations = new Map<string, IndexedSymbol>();
+  for (const symbol of symbols) {
+    if (symbol.isDefinition !== false) {
+      declarations.set(`${symbol.location.line}:${symbol.location.character}:${symbol.name}`, symbol);
+    }
+  }
+
+  const closures: IndexedSymbol[] = [];
+  const owners: Owner[] = [{ name: path.basename(uri, path.extname(uri)), count: 0 }];
+  const scopes: Scope[] = [{ names: declaredNames(ast) }];
+
+  /** Look the declaration's symbol up at its name or, for class fields, at the declaration */
+  const enterDeclaration = (name: TSESTree.Node, declaration?: TSESTree.Node): boolean => {
+    if (name.type !== AST_NODE_TYPES.Identifier) {
+      return false;
+    }
+    const symbol = declarations.get(`${name.loc.start.line - 1}:${name.loc.start.column}:${name.name}`) ??
+      (declaration && declarations.get(`${declaration.loc.start.line - 1}:${declaration.loc.start.column}:${name.name}`));
+    if (!symbol) {
+      return false;
+    }
+    owners.push({
+      name: symbol.name,
+      containerName: symbol.containerName,
+      containerKind: symbol.containerKind,
+      fullContainerPath: symbol.fullContainerPath,
+      count: 0
+    });
+    return true;
+  };
+
+  const reference = (name: string) => {
+    for (let i = scopes.length - 1; i > 0; i--) {
+      if (scopes[i].names.has(name)) {
+        for (let j = i + 1; j < scopes.length; j++) {
+          scopes[j].captures?.add(name);
+        }
+        return;
+      }
+    }
+  };
+
+  const visitFunction = (node: FunctionNode, parent: TSESTree.Node | null) => {
+    let closure: IndexedSymbol | undefined;
+    const scope: Scope = { names: declaredNames(node) };
+    if (node.type !== AST_NODE_TYPES.FunctionDeclaration && !isMethodBody(node, parent)) {
+      const owner = owners[owners.length - 1];
+      const name = `${owner.name}$${++owner.count}`;
+      const line = node.loc.start.line - 1;
+      const character = node.loc.start.column;
+      closure = {
+        id: createSymbolId(uri, name, owner.containerName, owner.fullContainerPath, 'closure', false, node.params.length, line, character),
+        name,
+        kind: 'closure',
+        location: { uri, line, character },
+        range: {
+          startLine: line,
+          startCharacter: character,
+          endLine: node.loc.end.line - 1,
+          endCharacter: node.loc.end.column
+        },
+        containerName: owner.containerName,
+        containerKind: owner.containerKind,
+        fullContainerPath: owner.fullContainerPath,
+        parametersCount: node.params.length,
+        filePath: uri,
+        isDefinition: true
+      };
+      closures.push(closure);
+      owners.push({ ...owner, name, count: 0 });
+      scope.captures = new Set();
+    }
+    const named = node.type === AST_NODE_TYPES.FunctionDeclaration && node.id !== null && enterDeclaration(node.id);
+
+    scopes.push(scope);
+    visitChildren(node);
+    scopes.pop();
+
+    if (closure || named) {
+      owners.pop();
+    }
+    if (closure && scope.captures!.size > 0) {
+      closure.captures = [...scope.captures!];
+    }
+  };
+
+  const visit = (node: TSESTree.Node, parent: TSESTree.Node | null): void => {
+    switch (node.type) {
+      case AST_NODE_TYPES.Identifier:
+        reference(node.name);
+        return;
+      case AST_NODE_TYPES.FunctionDeclaration:
+      case AST_NODE_TYPES.FunctionExpression:
+      case AST_NODE_TYPES.ArrowFunctionExpression:
+        visitFunction(node, parent);
+        return;
+      case AST_NODE_TYPES.MemberExpression:
+        visit(node.object, node);
+        if (node.computed) {
+          visit(node.property, node);
+        }
+        return;
+      case AST_NODE_TYPES.Property:
+        if (node.computed) {
+          visit(node.key, node);
+        }
+        visit(node.value, node);
+        return;
+      case AST_NODE_TYPES.MethodDefinition:
+      case AST_NODE_TYPES.PropertyDefinition: {
+        if (node.computed) {
+          visit(node.key, node);
+        }
+        const named = enterDeclaration(node.key, node);
+        for (const decorator of node.decorators ?? []) {
+          visit(decorator, node);
+        }
+        if (node.value) {
+          visit(node.value, node);
+        }
+        if (named) {
+          owners.pop();
+        }
+        return;
+      }
+      case AST_NODE_TYPES.VariableDeclarator: {
+        // Locals inside functions are not owners: closures take the function's name
+        const named = scopes.length === 1 && enterDeclaration(node.id);
+        visit(node.id, node);
+        if (node.init) {
+          visit(node.init, node);
+        }
+        if (named) {
+          owners.pop();
+        }
+        return;
+      }
+      case AST_NODE_TYPES.LabeledStatement:
+        visit(node.body, node);
+        return;
+      case AST_NODE_TYPES.BreakStatement:
+      case AST_NODE_TYPES.ContinueStatement:
+        return;
+    }
+    if (TYPE_DECLARATIONS.has(node.type)) {
+      return;
+    }
+    visitChildren(node);
+  };
+
+  const visitChildren = (node: TSESTree.Node) => {
+    for (const key in node) {
+      if (key === 'parent' || TYPE_KEYS.has(key)) {
+        continue;
+      }
+      const child = (node as any)[key];
+      if (Array.isArray(child)) {
+        for (const item of child) {
+          if (item && typeof item === 'object' && typeof item.type === 'string') {
+            visit(item, node);
+          }
+        }
+      } else if (child && typeof child === 'object' && typeof child.type === 'string') {
+        visit(child, node);
+      }
+    }
+  };
+
+  visitChildren(ast);
+  return closures;
+}
+
+/**
+ * The function of a method or accessor: `save() {}` in a class or object literal.
+ */
+function isMethodBody(node: FunctionNode, parent: TSESTree.Node | null): boolean {
+  if (!parent) {
+    return false;
+  }
+  if (parent.type === AST_NODE_TYPES.MethodDefinition || parent.type === AST_NODE_TYPES.TSAbstractMethodDefinition) {
+    return true;
+  }
+  return parent.type === AST_NODE_TYPES.Property && parent.value === node && (parent.method || parent.kind !== 'init');
+}
+
+/**
+ * Names declared in a function (parameters included) or in the module,
+ * without those declared in nested functions.
+ */
+function declaredNames(root: FunctionNode | TSESTree.Program): Set<string> {
+  const names = new Set<string>();
+  if (root.type !== AST_NODE_TYPES.Program) {
+    for (const param of root.params) {
+      patternNames(param, names);
+    }
+    if (root.type === AST_NODE_TYPES.FunctionExpression && root.id) {
+      names.add(root.id.name);
+    }
+  }
+
+  const visit = (node: TSESTree.Node) => {
+    switch (node.type) {
+      case AST_NODE_TYPES.VariableDeclarator:
+        patternNames(node.id, names);
+        if (node.init) {
+          visit(node.init);
+        }
+        return;
+      case AST_NODE_TYPES.FunctionDeclaration:
+      case AST_NODE_TYPES.ClassDeclaration:
+        if (node.id) {
+          names.add(node.id.name);
+        }
+        if (node.type === AST_NODE_TYPES.ClassDeclaration) {
+          visit(node.body);
+        }
+        return;
+      case AST_NODE_TYPES.FunctionExpression:
+      case AST_NODE_TYPES.ArrowFunctionExpression:
+        return;
+      case AST_NODE_TYPES.CatchClause:
+        if (node.param) {
+          patternNames(node.param, names);
+        }
+        visit(node.body);
+        return;
+      case AST_NODE_TYPES.ImportSpecifier:
+      case AST_NODE_TYPES.ImportDefaultSpecifier:
+      case AST_NODE_TYPES.ImportNamespaceSpecifier:
+        names.add(node.local.name);
+        return;
+    }
+    for (const key in node) {
+      if (key === 'parent' || TYPE_KEYS.has(key)) {
+        continue;
+      }
+      const child = (node as any)[key];
+      if (Array.isArray(child)) {
+        for (const item of child) {
+          if (item && typeof item === 'object' && typeof item.type === 'string') {
+            visit(item);
+          }
+        }
+      } else if (child && typeof child === 'object' && typeof child.type === 'string') {
+        visit(child);
+      }
+    }
+  };
+
+  const body = root.type === AST_NODE_TYPES.Program ? root : root.body;
+  if (body) {
+    visit(body);
+  }
+  return names;
+}
+
+/**
+ * Names bound by a parameter or declaration pattern: `{ a, b: [c] }`, `d = 1`, `...e`.
+ */
+function patternNames(pattern: TSESTree.Node, names: Set<string>): void {
+  switch (pattern.type) {
+    case AST_NODE_TYPES.Identifier:
+      names.add(pattern.name);
+      break;
+    case AST_NODE_TYPES.ObjectPattern:
+      for (const property of pattern.properties) {
+        patternNames(property.type === AST_NODE_TYPES.Property ? property.value : property, names);
+      }
+      break;
+    case AST_NODE_TYPES.ArrayPattern:
+      for (const element of pattern.elements) {
+        if (element) {
+          patternNames(element, names);
+        }
+      }
+      break;
+    case AST_NODE_TYPES.AssignmentPattern:
+      patternNames(pattern.left, names);
+      break;
+    case AST_NODE_TYPES.RestElement:
+      patternNames(pattern.argument, names);
+      break;
+    case AST_NODE_TYPES.TSParameterProperty:
+      patternNames(pattern.parameter, names);
+      break;
+  }
+}
@@ -0,0 +1,275 @@
+/**
+ * ContentScanner - Extracts comments and string literals from source files.
+ *
+ * Produces spans (with offsets and start positions) for doc comments,
+ * other comments and string literals, so their text can be indexed
+ * separately from code. TS/JS use a small hand-written scanner that only
+ * tracks what is needed to tell strings, comments, regex literals and
+ * template literals apart; Go reuses GoTokenizer.
+ */
+
+import * as path from 'path';
+import { GoTokenizer } from './GoTokenizer.js';
+
+export type ContentKind = 'doc' | 'comment' | 'string';
+
+export interface ContentSpan {
+  kind: ContentKind;
+  /** Raw source text, including delimiters */
+  text: string;
+  offset: number;
+  line: number;
+  character: number;
+}
+
+const JS_EXTENSIONS = new Set(['.ts', '.tsx', '.js', '.jsx', '.mts', '.cts', '.mjs', '.cjs']);
+
+/**
+ * Keywords after which `/` starts a regex literal rather than a division.
+ */
+const JS_REGEX_PRECEDING_KEYWORDS = new Set([
+  'return', 'typeof', 'instanceof', 'in', 'of', 'new', 'delete', 'void',
+  'throw', 'case', 'do', 'else', 'yield', 'await'
+]);
+
+const GO_DOC_TARGETS = new Set(['package', 'func', 'type', 'var', 'const']);
+
+const WORD_RE = /[\p{L}\p{N}_$]+/uy;
+
+export function isContentScannable(uri: string): boolean {
+  const ext = path.extname(uri).toLowerCase();
+  return ext === '.go' || JS_EXTENSIONS.has(ext);
+}
+
+/**
+ * Extract content spans from a file; unsupported languages yield none.
+ */
+export function scanContent(uri: string, content: string): ContentSpan[] {
+  const ext = path.extname(uri).toLowerCase();
+  if (ext === '.go') {
+    return scanGo(content);
+  }
+  if (JS_EXTENSIONS.has(ext)) {
+    return scanJs(content);
+  }
+  return [];
+}
+
+/**
+ * Go: a doc comment is a comment block ending on the line right before a
+ * top-level declaration keyword (the godoc convention).
+ */
+function scanGo(content: string): ContentSpan[] {
+  const tokenizer = new GoTokenizer(content);
+  const { tokens, comments } = tokenizer.tokenize();
+  const spans: ContentSpan[] = [];
+
+  const isDoc: boolean[] = new Array(comments.length).fill(false);
+  const positionAt = (offset: number) => tokenizer.positionAt(offset);
+  // First token after the current comment (comments are walked backwards)
+  let tokenIndex = tokens.length;
+  for (let i = comments.length - 1; i >= 0; i--) {
+    const comment = comments[i];
+    const next = comments[i + 1];
+    if (next && next.line === comment.endLine + 1 && isDoc[i + 1]) {
+      isDoc[i] = true;
+      continue;
+    }
+    while (tokenIndex > 0 && tokens[tokenIndex - 1].offset >= comment.end) {
+      tokenIndex--;
+    }
+    const following = tokens[tokenIndex];
+    isDoc[i] = following !== undefined &&
+      following.line === comment.endLine + 1 && GO_DOC_TARGETS.has(following.text);
+  }
+
+  comments.forEach((comment, i) => {
+    spans.push(makeSpan(positionAt, isDoc[i] ? 'doc' : 'comment', comment.text, comment.offset));
+  });
+  for (const token of tokens) {
+    if (token.type === 'string') {
+      spans.push(makeSpan(positionAt, 'string', token.text, token.offset));
+    }
+  }
+
+  return spans.sort((a, b) => a.offset - b.offset);
+}
+
+function makeSpan(
+  positionAt: (offset: number) => { line: number; character: number },
+  kind: ContentKind,
+  text: string,
+  offset: number
+): ContentSpan {
+  const { line, character } = positionAt(offset);
+  return { kind, text, offset, line, character };
+}
+
+/**
+ * TS/JS scanner. `regexAllowed` follows the usual heuristic: a `/` after an
+ * operand (identifier, literal, `)` or `]`) is division, otherwise a regex.
+ * Template literals are split into their text parts; `${...}` expressions
+ * are scanned as code, tracking brace depth to find where they end.
+ */
+function scanJs(content: string): ContentSpan[] {
+  const lineStarts = [0];
+  for (let i = 0; i < content.length; i++) {
+    if (content.charCodeAt(i) === 10) {
+      lineStarts.push(i + 1);
+    }
+  }
+  const toPosition = (offset: number) => positionAt(lineStarts, offset);
+
+  const spans: ContentSpan[] = [];
+  const length = content.length;
+  const templateDepths: number[] = [];
+  let regexAllowed = true;
+  let i = 0;
+
+  const push = (kind: ContentKind, start: number, end: number) => {
+    spans.push(makeSpan(toPosition, kind, content.slice(start, end), start));
+  };
+
+  // Scan a template text part starting after its opening delimiter.
+  // Returns the index after the part; pushes a `${` depth if one opened.
+  const scanTemplatePart = (start: number, delimiter: number): number => {
+    let j = start;
+    while (j < length) {
+      const ch = content[j];
+      if (ch === '\\') {
+        j += 2;
+      } else if (ch === '`') {
+        push('string', delimiter, j + 1);
+        return j + 1;
+      } else if (ch === '$' && content[j + 1] === '{') {
+        push('string', delimiter, j + 2);
+        templateDepths.push(0);
+        return j + 2;
+      } else {
+        j++;
+      }
+    }
+    push('string', delimiter, length);
+    return length;
+  };
+
+  while (i < length) {
+    const ch = content[i];
+
+    if (ch === ' ' || ch === '\t' || ch === '\n' || ch === '\r') {
+      i++;
+      continue;
+    }
+
+    if (ch === '/' && content[i + 1] === '/') {
+      let end = content.indexOf('\n', i);
+      if (end === -1) {
+        end = length;
+      }
+      push('comment', i, end);
+      i = end;
+      continue;
+    }
+
+    if (ch === '/' && content[i + 1] === '*') {
+      let end = content.indexOf('*/', i + 2);
+      end = end === -1 ? length : end + 2;
+      const isDoc = content[i + 2] === '*' && content[i + 3] !== '/';
+      push(isDoc ? 'doc' : 'comment', i, end);
+      i = end;
+      continue;
+    }
+
+    if (ch === '"' || ch === '\'') {
+      let j = i + 1;
+      while (j < length && content[j] !== ch && content[j] !== '\n') {
+        j += content[j] === '\\' ? 2 : 1;
+      }
+      const end = Math.min(j + 1, length);
+      push('string', i, end);
+      i = end;
+      regexAllowed = false;
+      continue;
+    }
+
+    if (ch === '`') {
+      i = scanTemplatePart(i + 1, i);
+      regexAllowed = content[i - 1] === '{';
+      continue;
+    }
+
+    if (ch === '{' && templateDepths.length > 0) {
+      templateDepths[templateDepths.length - 1]++;
+      i++;
+      regexAllowed = true;
+      continue;
+    }
+
+    if (ch === '}' && templateDepths.length > 0) {
+      const top = templateDepths.length - 1;
+      if (templateDepths[top] === 0) {
+        templateDepths.pop();
+        i = scanTemplatePart(i + 1, i);
+        regexAllowed = content[i - 1] === '{';
+      } else {
+        templateDepths[top]--;
+        i++;
+        regexAllowed = false;
+      }
+      continue;
+    }
+
+    if (ch === '/' && regexAllowed) {
+      let j = i + 1;
+      let inClass = false;
+      while (j < length && content[j] !== '\n') {
+        const c = content[j];
+        if (c === '\\') {
+          j += 2;
+          continue;
+        }
+        if (c === '[') {
+          inClass = true;
+        } else if (c === ']') {
+          inClass = false;
+        } else if (c === '/' && !inClass) {
+          break;
+        }
+        j++;
+      }
+      j++;
+      WORD_RE.lastIndex = j;
+      const flags = WORD_RE.exec(content);
+      i = flags ? j + flags[0].length : j;
+      regexAllowed = false;
+      continue;
+    }
+
+    WORD_RE.lastIndex = i;
+    const word = WORD_RE.exec(content);
+    if (word) {
+      i += word[0].length;
+      regexAllowed = JS_REGEX_PRECEDING_KEYWORDS.has(word[0]);
+      continue;
+    }
+
+    regexAllowed = ch !== ')' && ch !== ']';
+    i++;
+  }
+
+  return spans;
+}
+
+function positionAt(lineStarts: number[], offset: number): { line: number; character: number } {
+  let low = 0;
+  let high = lineStarts.length - 1;
+  while (low < high) {
+    const mid = (low + high + 1) >> 1;
+    if (lineStarts[mid] <= offset) {
+      low = mid;
+    } else {
+      high = mid - 1;
+    }
+  }
+  return { line: low, character: offset - lineStarts[low] };
+}
@@ -0,0 +1,79 @@
+/**
+ * GoTokenUtils Tests
+ *
+ * Verifies bracket matching, argument splitting and the selectors and
+ * assignment targets read around Go call sites.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { GoToken, GoTokenizer } from './GoTokenizer.js';
+import {
+  assignedName,
+  attachFunctions,
+  matchBrackets,
+  selectorEndingAt,
+  splitArgs,
+  stringConstants,
+  TokenSpan
+} from './GoTokenUtils.js';
+import { createTestSymbol } from '../../test/mocks/MockIndex.js';
+
+function tokenize(content: string): GoToken[] {
+  return new GoTokenizer(content).tokenize().tokens;
+}
+
+function texts(tokens: GoToken[], spans: TokenSpan[]): string[] {
+  return spans.map(({ start, end }) => tokens.slice(start, end).map(token => token.text).join(''));
+}
+
+describe('GoTokenUtils', () => {
+  it('should match brackets and leave unbalanced ones open', () => {
+    const tokens = tokenize('f(a, g[1], "(") {');
+    const closing = matchBrackets(tokens);
+    expect(tokens[closing[1]!].text).toBe(')');
+    expect(closing[1]).toBe(tokens.length - 2);
+    expect(closing[tokens.length - 1]).toBeUndefined();
+  });
+
+  it('should split arguments at top-level commas', () => {
+    const tokens = tokenize('r.Get("/users/{id}", h.Get(db, cfg), func(w, r) {})\nnext(x,)');
+    const open = tokens.findIndex(token => token.text === '(');
+    const closing = matchBrackets(tokens);
+    expect(texts(tokens, splitArgs(tokens, open, closing[open]))).toEqual(['"/users/{id}"', 'h.Get(db,cfg)', 'func(w,r){}']);
+    expect(texts(tokens, splitArgs(tokens, open))).toEqual(['"/users/{id}"', 'h.Get(db,cfg)', 'func(w,r){}']);
+
+    const next = tokens.findIndex(token => token.text === 'next') + 1;
+    expect(texts(tokens, splitArgs(tokens, next))).toEqual(['x']);
+    // Cut off at the end of the file
+    const cut = tokenize('db.Query("SELECT 1", id');
+    expect(texts(cut, splitArgs(cut, 3))).toEqual(['"SELECT 1"', 'id']);
+  });
+
+  it('should read selectors and the names calls are assigned to', () => {
+    const tokens = tokenize('s.api = r.Group("/api")\nv1 := s.api.Group("/v1")\nuse(r.Group("/x"))');
+    const groups = tokens.flatMap((token, i) => token.text === 'Group' ? [i] : []);
+
+    expect(groups.map(i => selectorEndingAt(tokens, i - 2)?.name)).toEqual(['r', 's.api', 'r']);
+    expect(groups.map(i => assignedName(tokens, selectorEndingAt(tokens, i - 2)!.start))).toEqual(['s.api', 'v1', undefined]);
+    expect(selectorEndingAt(tokens, tokens.findIndex(token => token.text === '='))).toBeNull();
+  });
+
+  it('should read string constants, with the prefixes of dynamic concatenations on request', () => {
+    const tokens = tokenize('const envPort = "PORT"\nq := "SELECT *" + " FROM users"\nbase := "SELECT * FROM " + table\nn := 1');
+    expect([...stringConstants(tokens)]).toEqual([['envPort', 'PORT'], ['q', 'SELECT * FROM users']]);
+    expect(stringConstants(tokens, { keepPrefixes: true }).get('base')).toBe('SELECT * FROM ');
+  });
+
+  it('should attach the innermost function of the kinds given', () => {
+    const range = (startLine: number, endLine: number) => ({ startLine, startCharacter: 0, endLine, endCharacter: 1 });
+    const method = createTestSymbol({ name: 'Run', kind: 'method', containerName: 'Server', range: range(1, 20) });
+    const closure = createTestSymbol({ name: 'func1', kind: 'closure', range: range(5, 8) });
+    const sites: Array<{ line: number; character: number; function?: string }> = [
+      { line: 6, character: 2 }, { line: 12, character: 2 }, { line: 30, character: 0 }
+    ];
+    attachFunctions(sites, [method, closure], new Set(['function', 'method']));
+    expect(sites.map(site => site.function)).toEqual(['Server.Run', 'Server.Run', undefined]);
+    attachFunctions(sites, [method, closure], new Set(['function', 'method', 'closure']));
+    expect(sites.map(site => site.function)).toEqual(['func1', 'Server.Run', undefined]);
+  });
+});
@@ -0,0 +1,162 @@
+import { IndexedSymbol, SymbolLocation } from '../../types.js';
+import { GoToken, unquoteGoString } from './GoTokenizer.js';
+
+/**
+ * Helpers over GoTokenizer tokens for the extractors that read Go call
+ * sites (HTTP routes, SQL queries, config keys, concurrency, error paths)
+ * without a full parse.
+ */
+
+/** Tokens `start` (inclusive) to `end` (exclusive), e.g. one call argument */
+export interface TokenSpan {
+  start: number;
+  end: number;
+}
+
+/**
+ * Index of the closing bracket for each opening one; unbalanced ones stay undefined.
+ */
+export function matchBrackets(tokens: GoToken[]): Array<number | undefined> {
+  const closing: Array<number | undefined> = new Array(tokens.length);
+  const stack: number[] = [];
+  for (let i = 0; i < tokens.length; i++) {
+    const text = tokens[i].text;
+    if (tokens[i].type !== 'punct') {
+      continue;
+    }
+    if (text === '(' || text === '[' || text === '{') {
+      stack.push(i);
+    } else if ((text === ')' || text === ']' || text === '}') && stack.length > 0) {
+      closing[stack.pop()!] = i;
+    }
+  }
+  return closing;
+}
+
+/**
+ * Arguments of the call whose `(` is at open, split at top-level commas.
+ * The call ends at close when known (see matchBrackets), else at the
+ * first unmatched closing bracket, else at the end of the file.
+ */
+export function splitArgs(tokens: GoToken[], open: number, close?: number): TokenSpan[] {
+  const end = close ?? tokens.length;
+  const args: TokenSpan[] = [];
+  let start = open + 1;
+  let depth = 0;
+  for (let j = open + 1; j < end; j++) {
+    const text = tokens[j].text;
+    if (text === '(' || text === '[' || text === '{') {
+      depth++;
+    } else if (text === ')' || text === ']' || text === '}') {
+      if (depth === 0) {
+        if (start < j) {
+          args.push({ start, end: j });
+        }
+        return args;
+      }
+      depth--;
+    } else if (text === ',' && depth === 0) {
+      args.push({ start, end: j });
+      start = j + 1;
+    }
+  }
+  if (start < end) {
+    args.push({ start, end });
+  }
+  return args;
+}
+
+/**
+ * The selector ending at token `end`: `r`, `s.router`, `a.b.c`, and the
+ * index it starts at. Null when `end` is not an identifier.
+ */
+export function selectorEndingAt(tokens: GoToken[], end: number): { start: number; name: string } | null {
+  const parts: string[] = [];
+  let j = end;
+  while (tokens[j]?.type === 'ident') {
+    parts.unshift(tokens[j].text);
+    if (tokens[j - 1]?.text !== '.') {
+      break;
+    }
+    j -= 2;
+  }
+  return parts.length > 0 ? { start: j, name: parts.join('.') } : null;
+}
+
+/**
+ * `v1` in `v1 := r.Group(...)` or `s.api = r.Group(...)`, where start is
+ * the index of the expression assigned (`r`).
+ */
+export function assignedName(tokens: GoToken[], start: number): string | undefined {
+  const operator = tokens[start - 1]?.text;
+  if (operator !== ':=' && operator !== '=') {
+    return undefined;
+  }
+  return selectorEndingAt(tokens, start - 2)?.name;
+}
+
+/**
+ * Names assigned string literals anywhere in the file: `const q = "..."`,
+ * `q := "..." + "..."`. Reassigned names keep their last literal. With
+ * keepPrefixes, a concatenation that goes on with other operands
+ * (`"... FROM " + table`) keeps its leading literals; otherwise it is
+ * not a constant.
+ */
+export function stringConstants(tokens: GoToken[], options: { keepPrefixes?: boolean } = {}): Map<string, string> {
+  const constants = new Map<string, string>();
+  for (let i = 1; i < tokens.length; i++) {
+    const operator = tokens[i].text;
+    if ((operator !== '=' && operator !== ':=') || tokens[i - 1].type !== 'ident' || tokens[i + 1]?.type !== 'string') {
+      continue;
+    }
+    const parts: string[] = [];
+    let literal = true;
+    let j = i + 1;
+    while (tokens[j]?.type === 'string') {
+      parts.push(unquoteGoString(tokens[j].text));
+      if (tokens[j + 1]?.text !== '+') {
+        break;
+      }
+      j += 2;
+      literal = tokens[j]?.type === 'string';
+    }
+    if (literal || options.keepPrefixes) {
+      constants.set(tokens[i - 1].text, parts.join(''));
+    }
+  }
+  return constants;
+}
+
+/**
+ * Set each site's function: the innermost symbol of one of kinds whose
+ * range contains its position. Func literals without a symbol of their
+ * own belong to the function they are in.
+ */
+export function attachFunctions(
+  sites: Array<{ line: number; character: number; function?: string; functionLocation?: SymbolLocation }>,
+  symbols: IndexedSymbol[],
+  kinds: ReadonlySet<string>
+): void {
+  const functions = symbols.filter(symbol => symbol.isDefinition !== false && kinds.has(symbol.kind));
+  const contains = (symbol: IndexedSymbol, line: number, character: number) => {
+    const { startLine, startCharacter, endLine, endCharacter } = symbol.range;
+    return (line > startLine || (line === startLine && character >= startCharacter)) &&
+      (line < endLine || (line === endLine && character < endCharacter));
+  };
+  // Ranges nest, so of those containing the site the innermost starts last
+  const startsAfter = (a: IndexedSymbol, b: IndexedSymbol) =>
+    a.range.startLine > b.range.startLine || (a.range.startLine === b.range.startLine && a.range.startCharacter > b.range.startCharacter);
+
+  for (const site of sites) {
+    let best: IndexedSymbol | undefined;
+    for (const symbol of functions) {
+      if (contains(symbol, site.line, site.character) && (!best || startsAfter(symbol, best))) {
+        best = symbol;
+      }
+    }
+    if (best) {
+      site.function = best.containerName ? `${best.containerName}.${best.name}` : best.name;
+      site.functionLocation = best.location;
+    }
+  }
+}
@@ -0,0 +1,235 @@
+/**
+ * GoTokenizer - Minimal lexer for Go source files.
+ *
+ * Produces identifier, literal and punctuation tokens with offsets,
+ * and collects comments separately. Offsets map to (line, character)
+ * through a precomputed line table, so callers never re-split content.
+ *
+ * This is intentionally not a full Go lexer: it only distinguishes what
+ * the structural indexer needs (identifiers, strings, brackets, operators).
+ */
+
+export type GoTokenType = 'ident' | 'string' | 'rune' | 'number' | 'punct';
+
+export interface GoToken {
+  type: GoTokenType;
+  text: string;
+  offset: number;
+  end: number;
+  line: number;
+}
+
+export interface GoComment {
+  text: string;
+  offset: number;
+  end: number;
+  line: number;
+  endLine: number;
+}
+
+/** A lexical error: an unterminated literal or comment */
+export interface GoLexError {
+  message: string;
+  offset: number;
+}
+
+export interface GoTokenizeResult {
+  tokens: GoToken[];
+  comments: GoComment[];
+  errors: GoLexError[];
+}
+
+/**
+ * Go keywords (never emitted as references).
+ */
+export const GO_KEYWORDS = new Set([
+  'break', 'case', 'chan', 'const', 'continue', 'default', 'defer', 'else',
+  'fallthrough', 'for', 'func', 'go', 'goto', 'if', 'import', 'interface',
+  'map', 'package', 'range', 'return', 'select', 'struct', 'switch', 'type', 'var'
+]);
+
+/**
+ * Predeclared identifiers from the universe block.
+ */
+export const GO_PREDECLARED = new Set([
+  'any', 'bool', 'byte', 'comparable', 'complex64', 'complex128', 'error',
+  'float32', 'float64', 'int', 'int8', 'int16', 'int32', 'int64', 'rune',
+  'string', 'uint', 'uint8', 'uint16', 'uint32', 'uint64', 'uintptr',
+  'true', 'false', 'iota', 'nil',
+  'append', 'cap', 'clear', 'close', 'complex', 'copy', 'delete', 'imag',
+  'len', 'make', 'max', 'min', 'new', 'panic', 'print', 'println', 'real', 'recover'
+]);
+
+const IDENT_RE = /[\p{L}_][\p{L}\p{N}_]*/uy;
+const NUMBER_RE = /\.?\d(?:[\w.]|[eEpP][+-])*/y;
+const MULTI_CHAR_PUNCT = [
+  '<<=', '>>=', '&^=', '...', ':=', '<-', '++', '--', '==', '!=', '<=', '>=',
+  '&&', '||', '<<', '>>', '&^', '+=', '-=', '*=', '/=', '%=', '&=', '|=', '^='
+];
+
+/**
+ * Tokenizer with a line table for offset -> position conversion.
+ */
+export class GoTokenizer {
+  private readonly lineStarts: number[];
+
+  constructor(private readonly content: string) {
+    this.lineStarts = [0];
+    for (let i = 0; i < content.length; i++) {
+      if (content.charCodeAt(i) === 10) {
+        this.lineStarts.push(i + 1);
+      }
+    }
+  }
+
+  /**
+   * Convert an offset to a zero-based line number.
+   */
+  lineAt(offset: number): number {
+    let low = 0;
+    let high = this.lineStarts.length - 1;
+    while (low < high) {
+      const mid = (low + high + 1) >> 1;
+      if (this.lineStarts[mid] <= offset) {
+        low = mid;
+      } else {
+        high = mid - 1;
+      }
+    }
+    return low;
+  }
+
+  /**
+   * Convert an offset to a zero-based (line, character) position.
+   */
+  positionAt(offset: number): { line: number; character: number } {
+    const line = this.lineAt(offset);
+    return { line, character: offset - this.lineStarts[line] };
+  }
+
+  /**
+   * Tokenize the whole file. Unterminated interpreted strings and runes end
+   * at the end of their line, raw strings and comments at EOF; each is
+   * reported in `errors`.
+   */
+  tokenize(): GoTokenizeResult {
+    const content = this.content;
+    const tokens: GoToken[] = [];
+    const comments: GoComment[] = [];
+    const errors: GoLexError[] = [];
+    const length = content.length;
+    let i = 0;
+
+    while (i < length) {
+      const ch = content[i];
+
+      // Whitespace
+      if (ch === ' ' || ch === '\t' || ch === '\n' || ch === '\r') {
+        i++;
+        continue;
+      }
+
+      // Comments
+      if (ch === '/' && content[i + 1] === '/') {
+        let end = content.indexOf('\n', i);
+        if (end === -1) {
+          end = length;
+        }
+        comments.push(this.makeComment(i, end));
+        i = end;
+        continue;
+      }
+      if (ch === '/' && content[i + 1] === '*') {
+        let end = content.indexOf('*/', i + 2);
+        if (end === -1) {
+          errors.push({ message: 'Unterminated comment', offset: i });
+        }
+        end = end === -1 ? length : end + 2;
+        comments.push(this.makeComment(i, end));
+        i = end;
+        continue;
+      }
+
+      // Interpreted string / rune literals
+      if (ch === '"' || ch === '\'') {
+        let j = i + 1;
+        while (j < length && content[j] !== ch && content[j] !== '\n') {
+          j += content[j] === '\\' ? 2 : 1;
+        }
+        if (j >= length || content[j] !== ch) {
+          errors.push({ message: ch === '"' ? 'Unterminated string literal' : 'Unterminated rune literal', offset: i });
+        }
+        const end = Math.min(j + 1, length);
+        tokens.push(this.makeToken(ch === '"' ? 'string' : 'rune', i, end));
+        i = end;
+        continue;
+      }
+
+      // Raw string literal
+      if (ch === '`') {
+        let end = content.indexOf('`', i + 1);
+        if (end === -1) {
+          errors.push({ message: 'Unterminated raw string literal', offset: i });
+        }
+        end = end === -1 ? length : end + 1;
+        tokens.push(this.makeToken('string', i, end));
+        i = end;
+        continue;
+      }
+
+      // Identifiers
+      IDENT_RE.lastIndex = i;
+      const ident = IDENT_RE.exec(content);
+      if (ident) {
+        const end = i + ident[0].length;
+        tokens.push(this.makeToken('ident', i, end));
+        i = end;
+        continue;
+      }
+
+      // Numbers
+      NUMBER_RE.lastIndex = i;
+      const number = NUMBER_RE.exec(content);
+      if (number) {
+        const end = i + number[0].length;
+        tokens.push(this.makeToken('number', i, end));
+        i = end;
+        continue;
+      }
+
+      // Punctuation (longest match first)
+      const multi = MULTI_CHAR_PUNCT.find(p => content.startsWith(p, i));
+      const end = i + (multi ? multi.length : 1);
+      tokens.push(this.makeToken('punct', i, end));
+      i = end;
+    }
+
+    return { tokens, comments, errors };
+  }
+
+  private makeToken(type: GoTokenType, offset: number, end: number): GoToken {
+    return { type, text: this.content.slice(offset, end), offset, end, line: this.lineAt(offset) };
+  }
+
+  private makeComment(offset: number, end: number): GoComment {
+    return {
+      text: this.content.slice(offset, end),
+      offset,
+      end,
+      line: this.lineAt(offset),
+      endLine: this.lineAt(Math.max(offset, end - 1))
+    };
+  }
+}
+
+/**
+ * Unquote a Go string literal token (interpreted or raw).
+ * Escape sequences other than \" and \\ are kept verbatim.
+ */
+export function unquoteGoString(literal: string): string {
+  if (literal.startsWith('`')) {
+    return literal.slice(1, literal.endsWith('`') && literal.length > 1 ? -1 : undefined);
+  }
+  const body = literal.slice(1, literal.endsWith('"') && literal.length > 1 ? -1 : undefined);
+  return body.replace(/\\(["\\])/g, '$1');
+}
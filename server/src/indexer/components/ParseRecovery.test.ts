@@ -0,0 +1,75 @@
+/**
+ * Parse Recovery Tests
+ *
+ * Verifies that declarations around a syntax error still parse, with a
+ * stand-in parser that only checks braces and decorators.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { parseWithRecovery } from './ParseRecovery.js';
+
+class FakeSyntaxError extends Error {
+  constructor(message: string, readonly index: number) {
+    super(message);
+  }
+}
+
+/** Returns the declared names; throws on unbalanced braces or a dangling decorator */
+function fakeParse(source: string): string[] {
+  const open: number[] = [];
+  for (let i = 0; i < source.length; i++) {
+    if (source[i] === '{') {
+      open.push(i);
+    } else if (source[i] === '}' && open.pop() === undefined) {
+      throw new FakeSyntaxError("'}' unexpected", i);
+    }
+  }
+  if (open.length > 0) {
+    throw new FakeSyntaxError("'}' expected", open[open.length - 1]);
+  }
+  const decorator = /@\w+\s*$/.exec(source);
+  if (decorator) {
+    throw new FakeSyntaxError('Declaration expected', decorator.index);
+  }
+  return [...source.matchAll(/^(?:export )?(?:function|class|const) (\w+)/gm)].map(m => m[1]);
+}
+
+describe('parseWithRecovery', () => {
+  it('should parse valid content once, without diagnostics', () => {
+    const { result, diagnostics } = parseWithRecovery('function a() {}\n', fakeParse);
+    expect(result).toEqual(['a']);
+    expect(diagnostics).toEqual([]);
+  });
+
+  it('should skip the broken declarations and keep the rest', () => {
+    const content = [
+      'function first() {',
+      '  return 1;',
+      '}',
+      'function broken() {',
+      '  if (x) {',
+      '  return 2;',
+      '}',
+      '@Component',
+      'export class Widget {',
+      '}',
+      'const last = { a: 1 };'
+    ].join('\n');
+    const { result, diagnostics } = parseWithRecovery(content, fakeParse);
+
+    expect(result).toEqual(['first', 'Widget', 'last']);
+    expect(diagnostics).toEqual([{ message: "'}' expected", line: 3, character: 18 }]);
+  });
+
+  it('should return null when the chunks only fail together', () => {
+    const parse = (source: string) => {
+      if (source.includes('a') && source.includes('b')) {
+        throw new Error('Duplicate export');
+      }
+      return source.length;
+    };
+    const { result, diagnostics } = parseWithRecovery('a\nb\n', parse);
+    expect(result).toBeNull();
+    expect(diagnostics).toEqual([{ message: 'Duplicate export', line: 0, character: 0 }]);
+  });
+});
@@ -0,0 +1,133 @@
+import { ParseDiagnostic } from '../../types.js';
+
+/**
+ * ParseRecovery - Error-tolerant parsing on top of a parser that throws.
+ *
+ * When a file fails to parse, it is split into top-level chunks (a chunk
+ * starts at every line beginning in column 0 with a name, `@` or `$`), and
+ * each chunk is parsed on its own. A chunk that fails alone is retried
+ * together with the next ones, so decorators and multi-line literals that
+ * cross a chunk boundary still parse. Chunks that fail either way are
+ * blanked out (newlines kept, so positions do not move) and the rest of the
+ * file is parsed once more; its declarations are indexed as usual.
+ */
+
+/** The parse result, or null when even the recovered source did not parse */
+export interface RecoveredParse<T> {
+  result: T | null;
+  diagnostics: ParseDiagnostic[];
+}
+
+/** Chunks tried together with one that fails to parse alone */
+const MAX_MERGED_CHUNKS = 3;
+
+const CHUNK_START_RE = /^[A-Za-z_$@]/gm;
+
+/**
+ * Parse content, recovering from syntax errors in top-level declarations.
+ * Errors carrying a character `index` (like TSError) are reported at that
+ * position, anything else at the start of the chunk that failed.
+ */
+export function parseWithRecovery<T>(content: string, parse: (source: string) => T): RecoveredParse<T> {
+  let firstError: unknown;
+  try {
+    return { result: parse(content), diagnostics: [] };
+  } catch (error) {
+    firstError = error;
+  }
+
+  const lines = lineStarts(content);
+  const chunks = chunkStarts(content);
+  const diagnostics: ParseDiagnostic[] = [];
+  let recovered = content;
+
+  for (let c = 0; c < chunks.length;) {
+    const start = chunks[c];
+    const error = tryParse(content.slice(start, chunkEnd(chunks, c + 1, content)), parse);
+    if (error === undefined) {
+      c++;
+      continue;
+    }
+
+    let merged = 0;
+    for (let n = 2; n <= MAX_MERGED_CHUNKS && c + n <= chunks.length; n++) {
+      if (tryParse(content.slice(start, chunkEnd(chunks, c + n, content)), parse) === undefined) {
+        merged = n;
+        break;
+      }
+    }
+    if (merged > 0) {
+      c += merged;
+      continue;
+    }
+
+    diagnostics.push(diagnosticAt(error, start, lines));
+    const end = chunkEnd(chunks, c + 1, content);
+    recovered = recovered.slice(0, start) + blank(content.slice(start, end)) + recovered.slice(end);
+    c++;
+  }
+
+  if (diagnostics.length === 0) {
+    // Every chunk parses alone; the error is in how they fit together
+    return { result: null, diagnostics: [diagnosticAt(firstError, 0, lines)] };
+  }
+  try {
+    return { result: parse(recovered), diagnostics };
+  } catch {
+    return { result: null, diagnostics };
+  }
+}
+
+function tryParse<T>(source: string, parse: (source: string) => T): unknown {
+  try {
+    parse(source);
+    return undefined;
+  } catch (error) {
+    return error ?? new Error('Parse error');
+  }
+}
+
+function chunkStarts(content: string): number[] {
+  const starts = [0];
+  for (const match of content.matchAll(CHUNK_START_RE)) {
+    if (match.index! > 0) {
+      starts.push(match.index!);
+    }
+  }
+  return starts;
+}
+
+function chunkEnd(chunks: number[], next: number, content: string): number {
+  return next < chunks.length ? chunks[next] : content.length;
+}
+
+function blank(text: string): string {
+  return text.replace(/[^\r\n]/g, ' ');
+}
+
+function lineStarts(content: string): number[] {
+  const starts = [0];
+  for (let i = 0; i < content.length; i++) {
+    if (content.charCodeAt(i) === 10) {
+      starts.push(i + 1);
+    }
+  }
+  return starts;
+}
+
+function diagnosticAt(error: unknown, chunkStart: number, lines: number[]): ParseDiagnostic {
+  const index = (error as { index?: unknown } | null)?.index;
+  const offset = chunkStart + (typeof index === 'number' && index >= 0 ? index : 0);
+  let low = 0;
+  let high = lines.length - 1;
+  while (low < high) {
+    const mid = (low + high + 1) >> 1;
+    if (lines[mid] <= offset) {
+      low = mid;
+    } else {
+      high = mid - 1;
+    }
+  }
+  const message = error instanceof Error ? error.message : String(error);
+  return { message, line: low, character: offset - lines[low] };
+}
@@ -0,0 +1,279 @@
+/**
+ * PythonTokenizer - Minimal lexer for Python source files.
+ *
+ * Produces name, literal and operator tokens with offsets, plus a
+ * `newline` token at the end of every logical line (newlines inside
+ * brackets and after a backslash continuation do not end a line).
+ * Comments are collected separately. Offsets map to (line, character)
+ * through a precomputed line table, like GoTokenizer.
+ *
+ * This is intentionally not a full Python lexer: f-string replacement
+ * fields are kept inside the string token, and INDENT/DEDENT are left to
+ * the caller, which compares the column of each logical line's first token.
+ *
+ * Errors are reported, not thrown: unterminated strings, unmatched closing
+ * brackets, and brackets left open. A `def` or `class` line cannot be
+ * inside brackets, so one closes any left open; the rest of the file is
+ * not swallowed into one logical line.
+ */
+
+export type PythonTokenType = 'name' | 'string' | 'number' | 'op' | 'newline';
+
+export interface PythonToken {
+  type: PythonTokenType;
+  text: string;
+  offset: number;
+  end: number;
+  line: number;
+}
+
+export interface PythonComment {
+  text: string;
+  offset: number;
+  end: number;
+  line: number;
+}
+
+/** A lexical error: an unterminated string or an unbalanced bracket */
+export interface PythonLexError {
+  message: string;
+  offset: number;
+}
+
+export interface PythonTokenizeResult {
+  tokens: PythonToken[];
+  comments: PythonComment[];
+  errors: PythonLexError[];
+}
+
+/**
+ * Python keywords (never emitted as references). Soft keywords
+ * (`match`, `case`, `type`, `_`) are valid names and are not listed.
+ */
+export const PYTHON_KEYWORDS = new Set([
+  'False', 'None', 'True', 'and', 'as', 'assert', 'async', 'await', 'break',
+  'class', 'continue', 'def', 'del', 'elif', 'else', 'except', 'finally', 'for',
+  'from', 'global', 'if', 'import', 'in', 'is', 'lambda', 'nonlocal', 'not',
+  'or', 'pass', 'raise', 'return', 'try', 'while', 'with', 'yield'
+]);
+
+/**
+ * Frequently used builtins, skipped as references to keep noise down.
+ */
+export const PYTHON_BUILTINS = new Set([
+  'self', 'cls', 'print', 'len', 'range', 'enumerate', 'zip', 'map', 'filter',
+  'sorted', 'reversed', 'min', 'max', 'sum', 'any', 'all', 'abs', 'round',
+  'isinstance', 'issubclass', 'hasattr', 'getattr', 'setattr', 'delattr',
+  'iter', 'next', 'open', 'repr', 'hash', 'id', 'super', 'object', 'type',
+  'str', 'int', 'float', 'bool', 'bytes', 'list', 'dict', 'set', 'frozenset', 'tuple',
+  'property', 'staticmethod', 'classmethod', 'Exception', 'ValueError',
+  'TypeError', 'KeyError', 'IndexError', 'RuntimeError', 'NotImplementedError'
+]);
+
+const NAME_RE = /[\p{L}_][\p{L}\p{N}_]*/uy;
+const NUMBER_RE = /\.?\d(?:[\w.]|[eE][+-])*/y;
+const STRING_PREFIX_RE = /(?:[rRbBuUfF]|[rR][bBfF]|[bBfF][rR])(?=['"])/y;
+const DECLARATION_LINE_RE = /[ \t]*(?:async[ \t]+)?(?:def|class)[ \t]/y;
+const OPENING_BRACKETS: Record<string, string> = { ')': '(', ']': '[', '}': '{' };
+const MULTI_CHAR_OPS = [
+  '**=', '//=', '>>=', '<<=', '...', '->', ':=', '**', '//', '==', '!=', '<=', '>=',
+  '<<', '>>', '+=', '-=', '*=', '/=', '%=', '&=', '|=', '^=', '@='
+];
+
+/**
+ * Tokenizer with a line table for offset -> position conversion.
+ */
+export class PythonTokenizer {
+  private readonly lineStarts: number[];
+
+  constructor(private readonly content: string) {
+    this.lineStarts = [0];
+    for (let i = 0; i < content.length; i++) {
+      if (content.charCodeAt(i) === 10) {
+        this.lineStarts.push(i + 1);
+      }
+    }
+  }
+
+  /**
+   * Convert an offset to a zero-based line number.
+   */
+  lineAt(offset: number): number {
+    let low = 0;
+    let high = this.lineStarts.length - 1;
+    while (low < high) {
+      const mid = (low + high + 1) >> 1;
+      if (this.lineStarts[mid] <= offset) {
+        low = mid;
+      } else {
+        high = mid - 1;
+      }
+    }
+    return low;
+  }
+
+  /**
+   * Convert an offset to a zero-based (line, character) position.
+   */
+  positionAt(offset: number): { line: number; character: number } {
+    const line = this.lineAt(offset);
+    return { line, character: offset - this.lineStarts[line] };
+  }
+
+  /**
+   * Tokenize the whole file. Unterminated literals end at EOF; the last
+   * logical line is always terminated by a `newline` token.
+   */
+  tokenize(): PythonTokenizeResult {
+    const content = this.content;
+    const tokens: PythonToken[] = [];
+    const comments: PythonComment[] = [];
+    const errors: PythonLexError[] = [];
+    const length = content.length;
+    // Offsets of the open brackets
+    const open: number[] = [];
+    let i = 0;
+
+    const endLogicalLine = (offset: number) => {
+      const last = tokens[tokens.length - 1];
+      if (last && last.type !== 'newline') {
+        tokens.push(this.makeToken('newline', offset, offset));
+      }
+    };
+
+    while (i < length) {
+      const ch = content[i];
+
+      if (ch === '\n') {
+        if (open.length > 0) {
+          DECLARATION_LINE_RE.lastIndex = i + 1;
+          if (DECLARATION_LINE_RE.test(content)) {
+            for (const offset of open) {
+              errors.push({ message: `Unclosed '${content[offset]}'`, offset });
+            }
+            open.length = 0;
+          }
+        }
+        if (open.length === 0) {
+          endLogicalLine(i);
+        }
+        i++;
+        continue;
+      }
+      if (ch === ' ' || ch === '\t' || ch === '\r' || ch === '\f') {
+        i++;
+        continue;
+      }
+
+      // Explicit line continuation
+      if (ch === '\\' && (content[i + 1] === '\n' || (content[i + 1] === '\r' && content[i + 2] === '\n'))) {
+        i += content[i + 1] === '\n' ? 2 : 3;
+        continue;
+      }
+
+      if (ch === '#') {
+        let end = content.indexOf('\n', i);
+        if (end === -1) {
+          end = length;
+        }
+        comments.push({ text: content.slice(i, end), offset: i, end, line: this.lineAt(i) });
+        i = end;
+        continue;
+      }
+
+      // Strings, with optional prefix (r, b, f, rb, ...)
+      STRING_PREFIX_RE.lastIndex = i;
+      const prefix = STRING_PREFIX_RE.exec(content);
+      const quoteStart = prefix ? i + prefix[0].length : i;
+      const quote = content[quoteStart];
+      if (quote === '"' || quote === '\'') {
+        const { end, terminated } = this.scanString(quoteStart, quote);
+        if (!terminated) {
+          errors.push({ message: 'Unterminated string literal', offset: i });
+        }
+        tokens.push(this.makeToken('string', i, end));
+        i = end;
+        continue;
+      }
+
+      NAME_RE.lastIndex = i;
+      const name = NAME_RE.exec(content);
+      if (name) {
+        const end = i + name[0].length;
+        tokens.push(this.makeToken('name', i, end));
+        i = end;
+        continue;
+      }
+
+      NUMBER_RE.lastIndex = i;
+      const number = NUMBER_RE.exec(content);
+      if (number) {
+        const end = i + number[0].length;
+        tokens.push(this.makeToken('number', i, end));
+        i = end;
+        continue;
+      }
+
+      if (ch === '(' || ch === '[' || ch === '{') {
+        open.push(i);
+      } else if (OPENING_BRACKETS[ch]) {
+        const match = open.map(offset => content[offset]).lastIndexOf(OPENING_BRACKETS[ch]);
+        if (match < 0) {
+          errors.push({ message: `Unexpected '${ch}'`, offset: i });
+        } else {
+          for (const offset of open.splice(match).slice(1)) {
+            errors.push({ message: `Unclosed '${content[offset]}'`, offset });
+          }
+        }
+      }
+
+      // Operators (longest match first)
+      const multi = MULTI_CHAR_OPS.find(op => content.startsWith(op, i));
+      const end = i + (multi ? multi.length : 1);
+      tokens.push(this.makeToken('op', i, end));
+      i = end;
+    }
+
+    for (const offset of open) {
+      errors.push({ message: `Unclosed '${content[offset]}'`, offset });
+    }
+    endLogicalLine(length);
+    return { tokens, comments, errors };
+  }
+
+  /**
+   * Scan a string literal starting at its opening quote; returns the end
+   * offset, and whether the closing quote was found.
+   */
+  private scanString(start: number, quote: string): { end: number; terminated: boolean } {
+    const content = this.content;
+    const length = content.length;
+    const triple = content.startsWith(quote.repeat(3), start);
+    let j = start + (triple ? 3 : 1);
+
+    while (j < length) {
+      const c = content[j];
+      if (c === '\\') {
+        // Also in raw strings: r"\"" does not end at the escaped quote
+        j += 2;
+        continue;
+      }
+      if (triple) {
+        if (content.startsWith(quote.repeat(3), j)) {
+          return { end: j + 3, terminated: true };
+        }
+      } else if (c === quote) {
+        return { end: j + 1, terminated: true };
+      } else if (c === '\n') {
+        // Unterminated single-quoted string
+        return { end: j, terminated: false };
+      }
+      j++;
+    }
+    return { end: length, terminated: false };
+  }
+
+  private makeToken(type: PythonTokenType, offset: number, end: number): PythonToken {
+    return { type, text: this.content.slice(offset, end), offset, end, line: this.lineAt(offset) };
+  }
+}
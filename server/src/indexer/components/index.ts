@@ -8,7 +8,11 @@
 export { StringInterner } from './StringInterner.js';
 export { ScopeTracker } from './ScopeTracker.js';
 export { AstParser, astParser } from './AstParser.js';
+export { parseWithRecovery, RecoveredParse } from './ParseRecovery.js';
 export { ImportExtractor } from './ImportExtractor.js';
+export { extractTsClosures } from './ClosureExtractor.js';
+export { GoTokenizer, GoToken, GoComment, GO_KEYWORDS, GO_PREDECLARED, unquoteGoString } from './GoTokenizer.js';
+export { PythonTokenizer, PythonToken, PythonComment, PYTHON_KEYWORDS, PYTHON_BUILTINS } from './PythonTokenizer.js';
 export {
   isNgRxCreateActionCall,
   isNgRxCreateActionGroupCall,
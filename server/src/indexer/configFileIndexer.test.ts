@@ -0,0 +1,156 @@
+/**
+ * ConfigFileIndexer Tests
+ *
+ * Verifies key paths of YAML and JSON config files, Kubernetes resources,
+ * Helm values and their template references.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ConfigFileIndexer, ConfigFileKeyMetadata, joinConfigPath } from './configFileIndexer.js';
+import { IndexedSymbol } from '../types.js';
+
+const manifest = `# Web frontend
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app.kubernetes.io/name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.25" # pinned
+          ports:
+            - containerPort: 80
+          args: [--port, "80",
+            --verbose]
+        - name: sidecar
+          image: envoy
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  nginx.conf: |
+    server {
+      listen: 80
+    }
+  mode: prod
+`;
+
+const values = `image:
+  repository: nginx
+  tag: "1.25"
+{{- if .Values.extra }}
+extra: true
+{{- end }}
+`;
+
+const template = `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Values.image.repository }}-svc
+spec:
+  type: {{ .Values.serviceType | default "ClusterIP" }}
+`;
+
+const tsconfig = `{
+  // Compiler settings
+  "compilerOptions": {
+    "target": "ES2022",
+    "strict": true,
+    "paths": { "@/*": ["src/*"] },
+  },
+  "include": ["src", { "glob": "**/*.ts" }]
+}
+`;
+
+function paths(symbols: IndexedSymbol[]): string[] {
+  return symbols.map(symbol => (symbol.metadata?.config as ConfigFileKeyMetadata).path);
+}
+
+function config(symbols: IndexedSymbol[], keyPath: string): ConfigFileKeyMetadata | undefined {
+  return symbols.map(symbol => symbol.metadata?.config as ConfigFileKeyMetadata).find(c => c.path === keyPath);
+}
+
+describe('ConfigFileIndexer', () => {
+  const yaml = new ConfigFileIndexer('yaml');
+  const json = new ConfigFileIndexer('json');
+
+  it('should index the key paths of a Kubernetes manifest', () => {
+    const { symbols, diagnostics } = yaml.parse('/ws/deploy/web.yaml', manifest);
+
+    expect(diagnostics).toBeUndefined();
+    expect(paths(symbols)).toEqual([
+      'apiVersion', 'kind', 'metadata', 'metadata.name', 'metadata.labels',
+      'metadata.labels["app.kubernetes.io/name"]', 'spec', 'spec.replicas', 'spec.template',
+      'spec.template.spec', 'spec.template.spec.containers', 'spec.template.spec.containers[].name',
+      'spec.template.spec.containers[].image', 'spec.template.spec.containers[].ports',
+      'spec.template.spec.containers[].ports[].containerPort', 'spec.template.spec.containers[].args',
+      'spec.template.spec.containers[].name', 'spec.template.spec.containers[].image',
+      'apiVersion', 'kind', 'metadata', 'metadata.name', 'data', 'data["nginx.conf"]', 'data.mode'
+    ]);
+
+    const image = symbols[12];
+    expect([image.name, image.kind, image.containerName, image.value]).toEqual(['image', 'key', 'spec.template.spec.containers[]', 'nginx:1.25']);
+    expect(image.location).toEqual({ uri: '/ws/deploy/web.yaml', line: 13, character: 10 });
+    expect(image.isExported).toBe(false);
+    expect(config(symbols, 'spec.replicas')).toEqual({ path: 'spec.replicas', document: 0, resource: 'Deployment/web' });
+    expect(config(symbols, 'data.mode')).toEqual({ path: 'data.mode', document: 1, resource: 'ConfigMap/web-config' });
+    // Nested keys span their children
+    expect(symbols[6].range).toMatchObject({ startLine: 7, endLine: 19 });
+  });
+
+  it('should skip block scalars, flow collections and Helm template lines', () => {
+    const { symbols } = yaml.parse('/ws/chart/values.yaml', values);
+    expect(paths(symbols)).toEqual(['image', 'image.repository', 'image.tag', 'extra']);
+    expect(symbols.map(s => s.value)).toEqual([undefined, 'nginx', '1.25', 'true']);
+    expect(config(symbols, 'image.tag')).toEqual({ path: 'image.tag', helmValues: true });
+
+    const conf = yaml.parse('/ws/deploy/web.yaml', manifest).symbols.find(s => s.name === 'nginx.conf')!;
+    expect(conf.range).toMatchObject({ startLine: 26, endLine: 29 });
+  });
+
+  it('should reference values from Helm templates', () => {
+    const { symbols, references } = yaml.parse('/ws/chart/templates/service.yaml', template);
+
+    expect(paths(symbols)).toContain('spec.type');
+    expect(references.map(r => [r.symbolName, r.containerName, r.location.line, r.location.character])).toEqual([
+      ['repository', 'image', 3, 25],
+      ['serviceType', undefined, 5, 19]
+    ]);
+    expect(yaml.parse('/ws/deploy/web.yaml', template).references).toEqual([]);
+  });
+
+  it('should index JSON with comments and trailing commas', () => {
+    const { symbols, diagnostics } = json.parse('/ws/tsconfig.json', tsconfig);
+
+    expect(diagnostics).toBeUndefined();
+    expect(paths(symbols)).toEqual([
+      'compilerOptions', 'compilerOptions.target', 'compilerOptions.strict', 'compilerOptions.paths',
+      'compilerOptions.paths["@/*"]', 'include', 'include[].glob'
+    ]);
+    expect(symbols.map(s => s.value)).toEqual([undefined, 'ES2022', 'true', undefined, undefined, undefined, '**/*.ts']);
+    expect(symbols[1].location).toEqual({ uri: '/ws/tsconfig.json', line: 3, character: 5 });
+    expect(symbols[0].range).toEqual({ startLine: 2, startCharacter: 3, endLine: 6, endCharacter: 3 });
+    expect(config(symbols, 'include')).toEqual({ path: 'include' });
+  });
+
+  it('should report malformed files and skip lock files', () => {
+    expect(json.parse('/ws/a.json', '{ "a": 1, b: 2 }').diagnostics).toEqual([{ message: 'Expected a string key', line: 0, character: 10 }]);
+    expect(json.parse('/ws/a.json', '{ "a": [1, 2 }').diagnostics?.[0].message).toBe("Expected ']'");
+    expect(json.parse('/ws/a.json', '{} {}').diagnostics?.[0].message).toBe('Unexpected content after the JSON value');
+    expect(yaml.parse('/ws/a.yaml', 'a:\n\tb: 1\n').diagnostics).toEqual([{ message: 'Tabs are not allowed for indentation', line: 1, character: 0 }]);
+    expect(json.parse('/ws/package-lock.json', '{ "name": "x" }').symbols).toEqual([]);
+  });
+
+  it('should quote keys that are not plain names', () => {
+    expect(joinConfigPath('', 'spec')).toBe('spec');
+    expect(joinConfigPath('spec', 'replicas')).toBe('spec.replicas');
+    expect(joinConfigPath('metadata.annotations', 'app.kubernetes.io/name')).toBe('metadata.annotations["app.kubernetes.io/name"]');
+  });
+});
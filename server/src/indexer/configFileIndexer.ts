@@ -0,0 +1,596 @@
+import * as path from 'path';
+import { IndexedSymbol, IndexedReference, ParseDiagnostic } from '../types.js';
+import { createSymbolId } from './symbolResolver.js';
+import { truncateValue } from '../utils/constantValues.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+
+/**
+ * Config file key metadata, stored under `symbol.metadata.config`.
+ */
+export interface ConfigFileKeyMetadata {
+  /** Key path from the document root, e.g. `spec.template.spec.containers[].image` */
+  path: string;
+  /** Multi-document YAML: the 0-based document the key is in */
+  document?: number;
+  /** Kubernetes manifests: the resource the key belongs to, e.g. `Deployment/web` */
+  resource?: string;
+  /** Keys of a Helm chart's values file, which templates read as `.Values.<path>` */
+  helmValues?: boolean;
+}
+
+export type ConfigFileFormat = 'yaml' | 'json';
+
+/** Larger files are data, not configuration */
+const MAX_CONFIG_FILE_LENGTH = 1024 * 1024;
+
+/** Keys indexed per file at most */
+const MAX_KEYS = 10000;
+
+/** Deeper JSON values are skipped */
+const MAX_JSON_DEPTH = 256;
+
+/** Lock files are generated and huge */
+const SKIPPED_FILES = new Set(['package-lock.json', 'npm-shrinkwrap.json', 'pnpm-lock.yaml', 'composer.lock']);
+
+/** `values.yaml`, `values-prod.yaml`, `values.staging.yml` */
+const HELM_VALUES_FILE = /^values([.-][\w.-]+)?\.ya?ml$/i;
+
+/** `.Values.image.tag` in a Helm template */
+const HELM_VALUES_REFERENCE = /\.Values((?:\.[A-Za-z_][\w-]*)+)/g;
+
+/**
+ * Keys of YAML and JSON config files: Kubernetes manifests, Helm values
+ * and charts, CI pipelines, tsconfig.json and the like. Every key is a
+ * `key` symbol named after itself, with its parent path as container and
+ * its full path in `metadata.config.path`, so config is searched next
+ * to the code that reads it. Arrays add `[]` to the path:
+ * `spec.template.spec.containers[].image`. Scalar values are the
+ * symbols' `value`.
+ *
+ * Parsing is tolerant rather than validating: Helm templates
+ * (`{{ ... }}` lines), block scalars, anchors and tags, JSON comments and
+ * trailing commas don't stop it. Lock files and files over 1 MB are
+ * skipped.
+ */
+export class ConfigFileIndexer implements LanguageParser {
+  readonly language: ConfigFileFormat;
+  readonly extensions: readonly string[];
+
+  constructor(format: ConfigFileFormat) {
+    this.language = format;
+    this.extensions = format === 'yaml' ? ['.yaml', '.yml'] : ['.json'];
+  }
+
+  parse(uri: string, content: string): ParseResult {
+    if (content.length > MAX_CONFIG_FILE_LENGTH || SKIPPED_FILES.has(path.basename(uri).toLowerCase())) {
+      return { symbols: [], references: [], imports: [] };
+    }
+    const keys = new KeyCollector(uri);
+    if (this.language === 'yaml') {
+      scanYaml(content, keys, HELM_VALUES_FILE.test(path.basename(uri)));
+    } else {
+      scanJson(content, keys);
+    }
+    const references = isHelmTemplate(uri, content) ? helmValuesReferences(uri, content) : [];
+    const { symbols, diagnostics } = keys;
+    return diagnostics.length > 0 ? { symbols, references, imports: [], diagnostics } : { symbols, references, imports: [] };
+  }
+}
+
+/**
+ * Append a key to a path: `spec.replicas`, `annotations["app.kubernetes.io/name"]`.
+ */
+export function joinConfigPath(parent: string, key: string): string {
+  if (!/^[\w$-]+$/.test(key)) {
+    return `${parent}[${JSON.stringify(key)}]`;
+  }
+  return parent ? `${parent}.${key}` : key;
+}
+
+class KeyCollector {
+  symbols: IndexedSymbol[] = [];
+  diagnostics: ParseDiagnostic[] = [];
+  /** Index of the first symbol of the current document, see endDocument */
+  private documentStart = 0;
+
+  constructor(private uri: string) {}
+
+  get full(): boolean {
+    return this.symbols.length >= MAX_KEYS;
+  }
+
+  add(key: string, parent: string, line: number, character: number, config: Omit<ConfigFileKeyMetadata, 'path'>): IndexedSymbol | undefined {
+    if (this.full) {
+      return undefined;
+    }
+    const containerName = parent || undefined;
+    const metadata: ConfigFileKeyMetadata = { path: joinConfigPath(parent, key), ...config };
+    const symbol: IndexedSymbol = {
+      id: createSymbolId(this.uri, key, containerName, containerName, 'key', false, undefined, line, character),
+      name: key,
+      kind: 'key',
+      location: { uri: this.uri, line, character },
+      range: { startLine: line, startCharacter: character, endLine: line, endCharacter: character + key.length },
+      containerName,
+      fullContainerPath: containerName,
+      filePath: this.uri,
+      metadata: { config: metadata },
+      isDefinition: true,
+      // Not part of any API: kept out of API diffs and completions
+      isExported: false
+    };
+    this.symbols.push(symbol);
+    return symbol;
+  }
+
+  /**
+   * Close a document: a Kubernetes object (`apiVersion` and `kind` at the
+   * top) tags its keys with `Kind/metadata.name`.
+   */
+  endDocument(): void {
+    const keys = this.symbols.slice(this.documentStart);
+    this.documentStart = this.symbols.length;
+    const top = (keyPath: string) => keys.find(symbol => configOf(symbol).path === keyPath);
+    const kind = top('kind')?.value;
+    if (!kind || !top('apiVersion')) {
+      return;
+    }
+    const name = top('metadata.name')?.value;
+    const resource = name ? `${kind}/${name}` : kind;
+    for (const symbol of keys) {
+      configOf(symbol).resource = resource;
+    }
+  }
+}
+
+function configOf(symbol: IndexedSymbol): ConfigFileKeyMetadata {
+  return symbol.metadata!.config as ConfigFileKeyMetadata;
+}
+
+// ============================================================================
+// YAML
+// ============================================================================
+
+interface YamlEntry {
+  indent: number;
+  /** A mapping key, or a sequence item (`- `) */
+  type: 'key' | 'item';
+  path: string;
+  symbol?: IndexedSymbol;
+}
+
+/**
+ * Find the keys of a YAML stream line by line, by indentation.
+ */
+function scanYaml(content: string, keys: KeyCollector, helmValues: boolean): void {
+  const lines = content.split('\n');
+  const stack: YamlEntry[] = [];
+  let document = 0;
+  let documentHasContent = false;
+  const multiDocument = /^---(\s|$)/m.test(content);
+  /** Indentation of the key owning a `|` or `>` block scalar being skipped */
+  let blockScalarIndent: number | undefined;
+  /** Open brackets of a flow collection continuing on the next lines */
+  let flowDepth = 0;
+  /** Last content line and its end, where open entries end */
+  let lastLine = 0;
+  let lastEnd = 0;
+
+  const pop = () => {
+    const entry = stack.pop()!;
+    if (entry.symbol && lastLine > entry.symbol.range.endLine) {
+      entry.symbol.range.endLine = lastLine;
+      entry.symbol.range.endCharacter = lastEnd;
+    }
+  };
+  const config = (): Omit<ConfigFileKeyMetadata, 'path'> => ({
+    ...(multiDocument && { document }),
+    ...(helmValues && { helmValues: true })
+  });
+
+  const scanNode = (text: string, column: number, lineNumber: number): void => {
+    if (text === '-' || text.startsWith('- ')) {
+      while (stack.length > 0) {
+        const top = stack[stack.length - 1];
+        if (top.indent > column || (top.indent === column && top.type === 'item')) {
+          pop();
+        } else {
+          break;
+        }
+      }
+      const parent = stack[stack.length - 1]?.path ?? '';
+      stack.push({ indent: column, type: 'item', path: `${parent}[]` });
+      const rest = text.slice(1);
+      const inner = rest.trimStart();
+      if (inner) {
+        scanNode(inner, column + 1 + rest.length - inner.length, lineNumber);
+      }
+      return;
+    }
+
+    const match = matchYamlKey(text);
+    if (!match) {
+      return;
+    }
+    while (stack.length > 0 && stack[stack.length - 1].indent >= column) {
+      pop();
+    }
+    if (match.key === '<<') {
+      return;
+    }
+    const parent = stack[stack.length - 1]?.path ?? '';
+    const symbol = keys.add(match.key, parent, lineNumber, column + match.keyOffset, config());
+    const value = match.value.replace(/^(?:[&!]\S*\s*)+/, '').trim();
+    const entry: YamlEntry = { indent: column, type: 'key', path: joinConfigPath(parent, match.key), symbol };
+
+    if (!value) {
+      stack.push(entry);
+    } else if (/^[|>][-+0-9]*$/.test(value)) {
+      blockScalarIndent = column;
+      stack.push(entry);
+    } else if (value.startsWith('{') || value.startsWith('[')) {
+      flowDepth = bracketDepth(value);
+      stack.push(entry);
+    } else if (symbol) {
+      symbol.value = truncateValue(unquoteYaml(value));
+      symbol.range.endCharacter = column + text.length;
+    }
+  };
+
+  for (let i = 0; i < lines.length; i++) {
+    const raw = lines[i].replace(/\r$/, '');
+    const indent = raw.length - raw.trimStart().length;
+
+    if (blockScalarIndent !== undefined) {
+      if (!raw.trim() || indent > blockScalarIndent) {
+        if (raw.trim()) {
+          lastLine = i;
+          lastEnd = raw.length;
+        }
+        continue;
+      }
+      blockScalarIndent = undefined;
+    }
+
+    const text = stripYamlComment(raw).trimEnd();
+    if (flowDepth > 0) {
+      flowDepth += bracketDepth(text);
+      if (text.trim()) {
+        lastLine = i;
+        lastEnd = text.length;
+      }
+      continue;
+    }
+    if (!text.trim()) {
+      continue;
+    }
+
+    if (/^(---|\.\.\.)(\s|$)/.test(text)) {
+      while (stack.length > 0) {
+        pop();
+      }
+      if (text.startsWith('---')) {
+        if (documentHasContent) {
+          keys.endDocument();
+          document++;
+        }
+        documentHasContent = false;
+      }
+      continue;
+    }
+    const trimmed = text.trimStart();
+    // Helm template actions and YAML directives
+    if (trimmed.startsWith('{{') || trimmed.startsWith('%')) {
+      continue;
+    }
+    if (/^\s*\t/.test(text)) {
+      keys.diagnostics.push({ message: 'Tabs are not allowed for indentation', line: i, character: 0 });
+      continue;
+    }
+
+    documentHasContent = true;
+    scanNode(trimmed, indent, i);
+    lastLine = i;
+    lastEnd = text.length;
+    if (keys.full) {
+      break;
+    }
+  }
+  while (stack.length > 0) {
+    pop();
+  }
+  keys.endDocument();
+}
+
+/**
+ * `key: value`, `"quoted key": value` or `'quoted': value`.
+ */
+function matchYamlKey(text: string): { key: string; keyOffset: number; value: string } | null {
+  const quoted = /^"((?:[^"\\]|\\.)*)"\s*:(?=\s|$)/.exec(text) ?? /^'((?:[^']|'')*)'\s*:(?=\s|$)/.exec(text);
+  if (quoted) {
+    const key = text[0] === '"' ? unquoteYaml(text.slice(0, quoted[1].length + 2)) : quoted[1].replace(/''/g, "'");
+    return { key, keyOffset: 1, value: text.slice(quoted[0].length) };
+  }
+  const plain = /^([^\s#'"{}[\],&*!|>%@`?-]|-(?=\S))[^:]*?\s*:(?=\s|$)/.exec(text);
+  if (!plain) {
+    return null;
+  }
+  const key = plain[0].slice(0, -1).trimEnd();
+  return { key, keyOffset: 0, value: text.slice(plain[0].length) };
+}
+
+/**
+ * Drop a `#` comment: one at the start of the line or after whitespace,
+ * outside quoted scalars.
+ */
+function stripYamlComment(line: string): string {
+  let quote: string | undefined;
+  for (let i = 0; i < line.length; i++) {
+    const c = line[i];
+    if (quote) {
+      if (c === '\\' && quote === '"') {
+        i++;
+      } else if (c === quote) {
+        quote = undefined;
+      }
+    } else if ((c === '"' || c === "'") && (i === 0 || /[\s[{,:]/.test(line[i - 1]))) {
+      quote = c;
+    } else if (c === '#' && (i === 0 || /\s/.test(line[i - 1]))) {
+      return line.slice(0, i);
+    }
+  }
+  return line;
+}
+
+function unquoteYaml(value: string): string {
+  if (value.length >= 2 && value.startsWith("'") && value.endsWith("'")) {
+    return value.slice(1, -1).replace(/''/g, "'");
+  }
+  if (value.length >= 2 && value.startsWith('"') && value.endsWith('"')) {
+    try {
+      return JSON.parse(value);
+    } catch {
+      return value.slice(1, -1);
+    }
+  }
+  return value;
+}
+
+/** Opened minus closed brackets, outside quotes */
+function bracketDepth(text: string): number {
+  let depth = 0;
+  let quote: string | undefined;
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i];
+    if (quote) {
+      if (c === '\\' && quote === '"') {
+        i++;
+      } else if (c === quote) {
+        quote = undefined;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === '{' || c === '[') {
+      depth++;
+    } else if (c === '}' || c === ']') {
+      depth--;
+    }
+  }
+  return depth;
+}
+
+// ============================================================================
+// JSON
+// ============================================================================
+
+/**
+ * Find the keys of a JSON (or JSONC) document.
+ */
+function scanJson(content: string, keys: KeyCollector): void {
+  const scanner = new JsonScanner(content, keys);
+  scanner.value('', undefined, 0);
+  if (scanner.peek() !== undefined && !keys.full) {
+    const { line, character } = scanner.position();
+    keys.diagnostics.push({ message: 'Unexpected content after the JSON value', line, character });
+  }
+  keys.endDocument();
+}
+
+class JsonScanner {
+  private i = 0;
+  private line = 0;
+  private lineStart = 0;
+
+  constructor(private content: string, private keys: KeyCollector) {}
+
+  position(): { line: number; character: number } {
+    return { line: this.line, character: this.i - this.lineStart };
+  }
+
+  /** Next significant character, after whitespace and comments */
+  peek(): string | undefined {
+    const content = this.content;
+    while (this.i < content.length) {
+      const c = content[this.i];
+      if (c === '\n') {
+        this.line++;
+        this.lineStart = this.i + 1;
+        this.i++;
+      } else if (c === ' ' || c === '\t' || c === '\r' || c === '﻿') {
+        this.i++;
+      } else if (content.startsWith('//', this.i)) {
+        const end = content.indexOf('\n', this.i);
+        this.i = end === -1 ? content.length : end;
+      } else if (content.startsWith('/*', this.i)) {
+        const end = content.indexOf('*/', this.i + 2);
+        this.advanceTo(end === -1 ? content.length : end + 2);
+      } else {
+        return c;
+      }
+    }
+    return undefined;
+  }
+
+  /**
+   * Scan a value at path; key is the symbol of the key it belongs to.
+   */
+  value(keyPath: string, key: IndexedSymbol | undefined, depth: number): void {
+    const c = this.peek();
+    if (c === '{' || c === '[') {
+      if (depth >= MAX_JSON_DEPTH || this.keys.full) {
+        this.skipCollection();
+      } else if (c === '{') {
+        this.object(keyPath, depth);
+      } else {
+        this.array(keyPath, depth);
+      }
+    } else if (c === '"') {
+      const text = this.string();
+      if (key) {
+        key.value = truncateValue(text);
+      }
+    } else if (c !== undefined && c !== ',' && c !== '}' && c !== ']') {
+      const start = this.i;
+      while (this.i < this.content.length && !/[\s,}\]/]/.test(this.content[this.i])) {
+        this.i++;
+      }
+      if (key) {
+        key.value = truncateValue(this.content.slice(start, this.i));
+      }
+    }
+    if (key) {
+      key.range.endLine = this.line;
+      key.range.endCharacter = this.i - this.lineStart;
+    }
+  }
+
+  private object(objectPath: string, depth: number): void {
+    this.i++;
+    for (let c = this.peek(); c !== undefined && c !== '}'; c = this.peek()) {
+      if (c === ',') {
+        this.i++;
+        continue;
+      }
+      if (c !== '"') {
+        const { line, character } = this.position();
+        this.keys.diagnostics.push({ message: 'Expected a string key', line, character });
+        this.skipCollection();
+        return;
+      }
+      const { line, character } = this.position();
+      const name = this.string();
+      const symbol = this.keys.add(name, objectPath, line, character + 1, {});
+      if (this.peek() === ':') {
+        this.i++;
+      }
+      this.value(joinConfigPath(objectPath, name), symbol, depth + 1);
+    }
+    this.expectClosing('}');
+  }
+
+  private array(arrayPath: string, depth: number): void {
+    this.i++;
+    for (let c = this.peek(); c !== undefined && c !== ']'; c = this.peek()) {
+      if (c === ',') {
+        this.i++;
+        continue;
+      }
+      const before = this.i;
+      this.value(`${arrayPath}[]`, undefined, depth + 1);
+      if (this.i === before) {
+        // `}` where a value belongs
+        this.i++;
+      }
+    }
+    this.expectClosing(']');
+  }
+
+  private expectClosing(bracket: string): void {
+    if (this.peek() === bracket) {
+      this.i++;
+    } else {
+      const { line, character } = this.position();
+      this.keys.diagnostics.push({ message: `Expected '${bracket}'`, line, character });
+    }
+  }
+
+  private string(): string {
+    const start = this.i;
+    this.i++;
+    while (this.i < this.content.length && this.content[this.i] !== '"' && this.content[this.i] !== '\n') {
+      this.i += this.content[this.i] === '\\' ? 2 : 1;
+    }
+    const text = this.content.slice(start, this.i + 1);
+    if (this.content[this.i] === '"') {
+      this.i++;
+    }
+    try {
+      return JSON.parse(text);
+    } catch {
+      return text.slice(1, text.endsWith('"') && text.length > 1 ? -1 : undefined);
+    }
+  }
+
+  /** Skip a balanced `{ ... }` or `[ ... ]` */
+  private skipCollection(): void {
+    let depth = 0;
+    for (let c = this.peek(); c !== undefined; c = this.peek()) {
+      if (c === '"') {
+        this.string();
+        continue;
+      }
+      this.i++;
+      if (c === '{' || c === '[') {
+        depth++;
+      } else if ((c === '}' || c === ']') && --depth <= 0) {
+        return;
+      }
+    }
+  }
+
+  private advanceTo(end: number): void {
+    for (; this.i < end; this.i++) {
+      if (this.content[this.i] === '\n') {
+        this.line++;
+        this.lineStart = this.i + 1;
+      }
+    }
+  }
+}
+
+// ============================================================================
+// Helm templates
+// ============================================================================
+
+function isHelmTemplate(uri: string, content: string): boolean {
+  return /[\\/]templates[\\/]/.test(uri) && content.includes('{{');
+}
+
+/**
+ * `.Values.image.tag` in a chart template refers to the key `tag` under
+ * `image` of the chart's values.
+ */
+function helmValuesReferences(uri: string, content: string): IndexedReference[] {
+  const references: IndexedReference[] = [];
+  content.split('\n').forEach((text, line) => {
+    for (const match of text.matchAll(HELM_VALUES_REFERENCE)) {
+      const parts = match[1].slice(1).split('.');
+      const symbolName = parts[parts.length - 1];
+      const character = match.index! + match[0].length - symbolName.length;
+      references.push({
+        symbolName,
+        location: { uri, line, character },
+        range: { startLine: line, startCharacter: character, endLine: line, endCharacter: character + symbolName.length },
+        containerName: parts.length > 1 ? parts.slice(0, -1).join('.') : undefined
+      });
+    }
+  });
+  return references;
+}
+
+/**
+ * Is the symbol a key of a YAML or JSON config file?
+ */
+export function isConfigFileKey(symbol: IndexedSymbol): boolean {
+  return symbol.kind === 'key' && symbol.metadata?.config !== undefined;
+}
@@ -0,0 +1,125 @@
+/**
+ * External Extractor Tests
+ *
+ * Runs a small Node.js extractor speaking the JSON-lines protocol and checks
+ * that its output is merged into worker results.
+ */
+
+import { describe, it, expect, afterEach } from 'vitest';
+import { ExternalExtractor, ExternalExtractorHost, toParseResult } from './externalExtractor.js';
+import { ConfigurationManager } from '../config/configurationManager.js';
+import { parseConfigFile } from '../config/configFile.js';
+import { NullLogger } from '../utils/Logger.js';
+import { IndexedFileResult } from '../types.js';
+
+// Reports every `service Name` as a class; fails on files containing "boom"
+const protoExtractor = `
+const rl = require('readline').createInterface({ input: process.stdin });
+rl.on('line', line => {
+  const { id, params } = JSON.parse(line);
+  if (params.content.includes('boom')) {
+    console.log(JSON.stringify({ id, error: { message: 'cannot parse' } }));
+    return;
+  }
+  const symbols = [];
+  params.content.split('\\n').forEach((text, lineNo) => {
+    const m = /service (\\w+)/.exec(text);
+    if (m) symbols.push({ name: m[1], kind: 'class', line: lineNo, character: m.index + 8 });
+  });
+  console.log(JSON.stringify({ id, result: { symbols, references: [{ name: 'GetUser', line: 0, character: 0 }] } }));
+});
+`;
+
+const config = {
+  name: 'proto',
+  command: process.execPath,
+  args: ['-e', protoExtractor],
+  extensions: ['.proto']
+};
+
+function skippedResult(uri: string): IndexedFileResult {
+  return {
+    uri, hash: 'h', symbols: [], references: [], imports: [],
+    isSkipped: true, skipReason: 'Unsupported file extension: .proto'
+  };
+}
+
+describe('ExternalExtractor', () => {
+  let extractor: ExternalExtractor | undefined;
+
+  afterEach(() => extractor?.dispose());
+
+  it('should exchange JSON lines with the extractor process', async () => {
+    extractor = new ExternalExtractor(config, new NullLogger());
+
+    const [first, second] = await Promise.all([
+      extractor.extract('/repo/api/user.proto', 'syntax = "proto3";\nservice UserService {}\n'),
+      extractor.extract('/repo/api/org.proto', 'service OrgService {}\n')
+    ]);
+
+    expect(first.symbols.map(s => [s.name, s.kind, s.location.line, s.location.character])).toEqual([
+      ['UserService', 'class', 1, 8]
+    ]);
+    expect(first.symbols[0].metadata).toEqual({ extractor: 'proto' });
+    expect(second.symbols[0].name).toBe('OrgService');
+    expect(second.references[0].symbolName).toBe('GetUser');
+
+    await expect(extractor.extract('/repo/api/bad.proto', 'boom')).rejects.toThrow('cannot parse');
+  });
+
+  it('should disable an extractor that cannot start', async () => {
+    extractor = new ExternalExtractor({ ...config, command: '/nonexistent/extractor' }, new NullLogger());
+
+    for (let attempt = 0; attempt < 3; attempt++) {
+      await expect(extractor.extract('/repo/a.proto', '')).rejects.toThrow();
+    }
+    expect(extractor.isDisabled()).toBe(true);
+  });
+});
+
+describe('ExternalExtractorHost', () => {
+  it('should turn skipped results into indexed ones and leave other files alone', async () => {
+    const configManager = new ConfigurationManager();
+    configManager.updateFromSettings({ extractors: [config] });
+    const host = new ExternalExtractorHost(configManager, new NullLogger(), async () => 'service Billing {}\n');
+
+    try {
+      const proto = await host.augment('/repo/api/billing.proto', skippedResult('/repo/api/billing.proto'));
+      expect(proto.isSkipped).toBeUndefined();
+      expect(proto.symbols.map(s => s.name)).toEqual(['Billing']);
+
+      const ts = skippedResult('/repo/src/app.ts');
+      expect(await host.augment('/repo/src/app.ts', ts)).toBe(ts);
+      expect(configManager.getExtractorExtensions()).toEqual(['.proto']);
+    } finally {
+      host.dispose();
+    }
+  });
+
+  it('should only run extractors of a config file the user trusts', async () => {
+    const file = parseConfigFile(`extractors: [${JSON.stringify(config)}]`, '/repo/smart-indexer.yaml');
+    const configManager = new ConfigurationManager();
+    configManager.setConfigFile(file);
+    const untrusted = new ExternalExtractorHost(configManager, new NullLogger(), async () => 'service Billing {}\n');
+    const skipped = skippedResult('/repo/api/billing.proto');
+    try {
+      expect(configManager.getExtractorExtensions()).toEqual([]);
+      expect(await untrusted.augment('/repo/api/billing.proto', skipped)).toBe(skipped);
+    } finally {
+      untrusted.dispose();
+    }
+
+    configManager.setConfigFile(file, undefined, true);
+    expect(configManager.getExtractorExtensions()).toEqual(['.proto']);
+  });
+
+  it('should drop malformed entries from extractor output', () => {
+    const result = toParseResult('sql', '/repo/db/001_init.sql', {
+      symbols: [{ name: 'users', kind: '', line: 2, character: 13 }, { name: 'broken' } as any]
+    });
+
+    expect(result.symbols).toHaveLength(1);
+    expect(result.symbols[0].kind).toBe('variable');
+    expect(result.symbols[0].range.endCharacter).toBe(18);
+  });
+});
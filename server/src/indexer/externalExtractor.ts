@@ -0,0 +1,265 @@
+import { spawn as nodeSpawn } from 'child_process';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import { IndexedFileResult, IndexedSymbol, IndexedReference } from '../types.js';
+import { ConfigurationManager, ExternalExtractorConfig } from '../config/configurationManager.js';
+import { IWorkerPool, WorkerTaskData, WorkerPoolStats } from '../utils/workerPool.js';
+import { ILogger } from '../utils/Logger.js';
+import { JsonLinesProcess, SpawnFn } from '../utils/jsonLinesProcess.js';
+import { createSymbolId } from './symbolResolver.js';
+import { ParseResult } from './languageParser.js';
+
+/**
+ * Symbol as reported by an extractor. Positions are zero-based.
+ */
+export interface ExtractedSymbol {
+  name: string;
+  kind: string;
+  line: number;
+  character: number;
+  endLine?: number;
+  endCharacter?: number;
+  containerName?: string;
+  isExported?: boolean;
+  metadata?: Record<string, unknown>;
+}
+
+export interface ExtractedReference {
+  name: string;
+  line: number;
+  character: number;
+  containerName?: string;
+}
+
+/**
+ * Protocol (see utils/jsonLinesProcess.ts): one JSON object per line.
+ *
+ *   -> {"id":1,"method":"extract","params":{"uri":"/repo/api/user.proto","content":"..."}}
+ *   <- {"id":1,"result":{"symbols":[...],"references":[...]}}
+ *   <- {"id":1,"error":{"message":"..."}}
+ */
+interface ExtractorResult {
+  symbols?: ExtractedSymbol[];
+  references?: ExtractedReference[];
+}
+
+/**
+ * A long-running extractor process. Started on first use and restarted
+ * if it exits, unless it keeps dying before answering anything.
+ */
+export class ExternalExtractor {
+  private process: JsonLinesProcess;
+
+  constructor(
+    readonly config: ExternalExtractorConfig,
+    logger: ILogger,
+    cwd: string | undefined = undefined,
+    spawn: SpawnFn = nodeSpawn
+  ) {
+    this.process = new JsonLinesProcess(config, 'Extractor', logger, cwd, spawn);
+  }
+
+  get name(): string {
+    return this.config.name;
+  }
+
+  handles(uri: string): boolean {
+    const ext = path.extname(uri).toLowerCase();
+    return this.config.extensions.some(e => e.toLowerCase() === ext);
+  }
+
+  isDisabled(): boolean {
+    return this.process.isDisabled();
+  }
+
+  /**
+   * Send one file to the extractor.
+   */
+  async extract(uri: string, content: string): Promise<ParseResult> {
+    const result = await this.process.request<ExtractorResult | undefined>('extract', { uri, content }, uri);
+    return toParseResult(this.name, uri, result ?? {});
+  }
+
+  dispose(): void {
+    this.process.dispose();
+  }
+}
+
+/**
+ * Convert an extractor response into index entries.
+ */
+export function toParseResult(
+  extractorName: string,
+  uri: string,
+  result: { symbols?: ExtractedSymbol[]; references?: ExtractedReference[] }
+): ParseResult {
+  const symbols: IndexedSymbol[] = [];
+  for (const s of result.symbols ?? []) {
+    if (typeof s?.name !== 'string' || typeof s.line !== 'number' || typeof s.character !== 'number') {
+      continue;
+    }
+    const kind = typeof s.kind === 'string' && s.kind ? s.kind : 'variable';
+    symbols.push({
+      id: createSymbolId(uri, s.name, s.containerName, s.containerName, kind, false, undefined, s.line, s.character),
+      name: s.name,
+      kind,
+      location: { uri, line: s.line, character: s.character },
+      range: {
+        startLine: s.line,
+        startCharacter: s.character,
+        endLine: s.endLine ?? s.line,
+        endCharacter: s.endCharacter ?? s.character + s.name.length
+      },
+      containerName: s.containerName,
+      fullContainerPath: s.containerName,
+      filePath: uri,
+      isDefinition: true,
+      isExported: s.isExported,
+      metadata: { ...(s.metadata ?? {}), extractor: extractorName }
+    });
+  }
+
+  const references: IndexedReference[] = [];
+  for (const r of result.references ?? []) {
+    if (typeof r?.name !== 'string' || typeof r.line !== 'number' || typeof r.character !== 'number') {
+      continue;
+    }
+    references.push({
+      symbolName: r.name,
+      location: { uri, line: r.line, character: r.character },
+      range: {
+        startLine: r.line,
+        startCharacter: r.character,
+        endLine: r.line,
+        endCharacter: r.character + r.name.length
+      },
+      containerName: r.containerName
+    });
+  }
+
+  return { symbols, references, imports: [] };
+}
+
+/**
+ * Owns the configured extractors and merges their output into indexing
+ * results. The extractor list is re-read from configuration on every
+ * call, so settings changes take effect without a restart.
+ */
+export class ExternalExtractorHost {
+  private extractors: ExternalExtractor[] = [];
+  private configKey = '[]';
+  private workspaceRoot: string | undefined;
+
+  constructor(
+    private readonly configManager: ConfigurationManager,
+    private readonly logger: ILogger,
+    private readonly readFile: (filePath: string, encoding: 'utf-8') => Promise<string> = fsPromises.readFile,
+    private readonly spawn: SpawnFn = nodeSpawn
+  ) {}
+
+  /**
+   * Extractors run with the workspace root as working directory, so
+   * commands like `./tools/extract-sql.js` resolve against the repository.
+   */
+  setWorkspaceRoot(workspaceRoot: string): void {
+    if (workspaceRoot !== this.workspaceRoot) {
+      this.dispose();
+      this.workspaceRoot = workspaceRoot;
+    }
+  }
+
+  /**
+   * Run every extractor that handles the file and merge what they return.
+   * Files no built-in indexer supports (skipped results) become indexed
+   * if an extractor produced something. Extractor failures are logged and
+   * leave the result as it was.
+   */
+  async augment(uri: string, result: IndexedFileResult): Promise<IndexedFileResult> {
+    const extractors = this.getExtractors().filter(e => e.handles(uri) && !e.isDisabled());
+    if (extractors.length === 0) {
+      return result;
+    }
+
+    let content: string;
+    try {
+      content = await this.readFile(uri, 'utf-8');
+    } catch {
+      return result;
+    }
+
+    let merged = result;
+    for (const extractor of extractors) {
+      try {
+        const extracted = await extractor.extract(uri, content);
+        merged = {
+          ...merged,
+          symbols: [...(merged.isSkipped ? [] : merged.symbols), ...extracted.symbols],
+          references: [...(merged.isSkipped ? [] : merged.references), ...extracted.references],
+          imports: merged.isSkipped ? [] : merged.imports,
+          isSkipped: undefined,
+          skipReason: undefined
+        };
+      } catch (error) {
+        this.logger.warn(`[Extractor] ${error instanceof Error ? error.message : error}`);
+      }
+    }
+    return merged;
+  }
+
+  dispose(): void {
+    for (const extractor of this.extractors) {
+      extractor.dispose();
+    }
+    this.extractors = [];
+    this.configKey = '[]';
+  }
+
+  private getExtractors(): ExternalExtractor[] {
+    const configs = this.configManager.getConfig().extractors ?? [];
+    const key = JSON.stringify(configs);
+    if (key !== this.configKey) {
+      this.dispose();
+      this.configKey = key;
+      this.extractors = configs.map(config => new ExternalExtractor(config, this.logger, this.workspaceRoot, this.spawn));
+    }
+    return this.extractors;
+  }
+}
+
+/**
+ * Worker pool decorator that passes every result through the extractors,
+ * so both bulk and incremental indexing pick up extractor symbols.
+ */
+export class ExtractingWorkerPool implements IWorkerPool {
+  constructor(private readonly inner: IWorkerPool, private readonly host: ExternalExtractorHost) {}
+
+  async runTask(taskData: WorkerTaskData): Promise<IndexedFileResult> {
+    const result = await this.inner.runTask(taskData);
+    return this.host.augment(taskData.uri, result);
+  }
+
+  async terminate(): Promise<void> {
+    this.host.dispose();
+    await this.inner.terminate();
+  }
+
+  getStats(): WorkerPoolStats {
+    return this.inner.getStats();
+  }
+
+  getActiveTasks(): number {
+    return this.inner.getActiveTasks();
+  }
+
+  validateCounters(): boolean {
+    return this.inner.validateCounters();
+  }
+
+  reset(): void {
+    this.inner.reset();
+  }
+
+  resize(size: number): void {
+    this.inner.resize(size);
+  }
+}
@@ -3,6 +3,9 @@ import * as path from 'path';
 import { minimatch } from 'minimatch';
 import { ConfigurationManager } from '../config/configurationManager.js';
 import { FolderHasher } from '../cache/folderHasher.js';
+import { CancellationToken, throwIfCancelled } from '../utils/asyncUtils.js';
+import { isWithinRoot } from '../utils/workspaceRoots.js';
+import { ILogger, NullLogger } from '../utils/Logger.js';
 
 export interface ScanOptions {
   excludePatterns: string[];
@@ -49,6 +52,8 @@ export class FileScanner {
   /** Concurrency limit for parallel stat operations */
   private static readonly STAT_CONCURRENCY = 50;
 
+  constructor(private logger: ILogger = new NullLogger()) {}
+
   configure(options: ScanOptions): void {
     this.excludePatterns = options.excludePatterns;
     this.maxFileSize = options.maxFileSize;
@@ -57,23 +62,77 @@ export class FileScanner {
     this.useFolderHashing = options.useFolderHashing !== undefined ? options.useFolderHashing : true;
   }
 
-  async scanWorkspace(workspaceRoot: string, skipFolderHashOptimization: boolean = false): Promise<string[]> {
+  /**
+   * Find indexable files under workspaceRoot. Throws CancellationError if
+   * the token is cancelled while scanning.
+   */
+  async scanWorkspace(
+    workspaceRoot: string,
+    skipFolderHashOptimization: boolean = false,
+    cancellationToken?: CancellationToken
+  ): Promise<string[]> {
     const files: string[] = [];
-    console.info(`[FileScanner] Starting workspace scan from: ${workspaceRoot}`);
-    console.info(`[FileScanner] Folder hashing: ${this.useFolderHashing ? 'enabled' : 'disabled'}, skip optimization: ${skipFolderHashOptimization}`);
+    this.logger.info(`[FileScanner] Starting workspace scan from: ${workspaceRoot}`);
+    this.logger.info(`[FileScanner] Folder hashing: ${this.useFolderHashing ? 'enabled' : 'disabled'}, skip optimization: ${skipFolderHashOptimization}`);
     const limiter = new ConcurrencyLimiter(FileScanner.STAT_CONCURRENCY);
-    await this.scanDirectory(workspaceRoot, files, limiter, skipFolderHashOptimization);
-    console.info(`[FileScanner] Workspace scan complete. Found ${files.length} indexable files`);
+    await this.scanDirectory(workspaceRoot, files, limiter, skipFolderHashOptimization, cancellationToken);
+    throwIfCancelled(cancellationToken);
+    this.logger.info(`[FileScanner] Workspace scan complete. Found ${files.length} indexable files`);
     return files;
   }
 
-  private async scanDirectory(dir: string, files: string[], limiter: ConcurrencyLimiter, skipFolderHashOptimization: boolean = false): Promise<void> {
+  /**
+   * Whether scanWorkspace(workspaceRoot) would find filePath, for checking
+   * single changed files without walking the tree.
+   */
+  async isIndexable(filePath: string, workspaceRoot: string): Promise<boolean> {
+    return this.isScannedPath(filePath, workspaceRoot) && this.isIndexableFileAsync(filePath);
+  }
+
+  /**
+   * Whether a scan would find a file of the given size at filePath, for
+   * files that are not on disk, such as archive entries.
+   */
+  isIndexableEntry(filePath: string, size: number, workspaceRoot: string): boolean {
+    if (!this.isScannedPath(filePath, workspaceRoot)) {
+      return false;
+    }
+    if (size > this.maxFileSize) {
+      this.logger.info(`[FileScanner] Skipping large file ${filePath} (${(size / (1024 * 1024)).toFixed(2)}MB)`);
+      return false;
+    }
+    return true;
+  }
+
+  private isScannedPath(filePath: string, workspaceRoot: string): boolean {
+    if (!isWithinRoot(workspaceRoot, filePath) || !this.hasIndexableExtension(filePath)) {
+      return false;
+    }
+    // A scan never enters excluded directories
+    for (let dir = path.dirname(filePath); dir !== workspaceRoot && isWithinRoot(workspaceRoot, dir); dir = path.dirname(dir)) {
+      if (this.shouldExclude(dir, true)) {
+        return false;
+      }
+    }
+    return !this.shouldExclude(filePath, false);
+  }
+
+  private async scanDirectory(
+    dir: string,
+    files: string[],
+    limiter: ConcurrencyLimiter,
+    skipFolderHashOptimization: boolean = false,
+    cancellationToken?: CancellationToken
+  ): Promise<void> {
+    if (cancellationToken?.isCancellationRequested) {
+      return;
+    }
     try {
       // Check folder hash early exit if enabled (but skip during full workspace scans)
       if (!skipFolderHashOptimization && this.useFolderHashing && this.folderHasher) {
         const changed = await this.folderHasher.hasFolderChanged(dir);
         if (changed === false) {
-          console.info(`[FileScanner] Skipping unchanged folder: ${dir} (hash unchanged)`);
+          this.logger.debug(`[FileScanner] Skipping unchanged folder: ${dir} (hash unchanged)`);
           return;
         }
       }
@@ -87,7 +146,7 @@ export class FileScanner {
       for (const entry of entries) {
         const fullPath = path.join(dir, entry.name);
 
-        if (this.shouldExclude(fullPath)) {
+        if (this.shouldExclude(fullPath, entry.isDirectory())) {
           continue;
         }
 
@@ -116,7 +175,7 @@ export class FileScanner {
 
       // Recurse into subdirectories (sequentially to avoid excessive parallelism)
       for (const subdir of subdirs) {
-        await this.scanDirectory(subdir, files, limiter, skipFolderHashOptimization);
+        await this.scanDirectory(subdir, files, limiter, skipFolderHashOptimization, cancellationToken);
       }
     } catch (error: any) {
       if (error.code !== 'ENOENT' && error.code !== 'EACCES') {
@@ -125,9 +184,9 @@ export class FileScanner {
     }
   }
 
-  private shouldExclude(filePath: string): boolean {
-    // Check hardcoded exclusions via config manager
-    if (this.configManager && this.configManager.shouldExcludePath(filePath)) {
+  private shouldExclude(filePath: string, isDirectory: boolean): boolean {
+    // Check hardcoded exclusions and ignore files via config manager
+    if (this.configManager && this.configManager.shouldExcludePath(filePath, isDirectory)) {
       return true;
     }
 
@@ -152,9 +211,14 @@ export class FileScanner {
       '.json', '.md', '.txt', '.yml', '.yaml',
       // Text indexable languages
       '.java', '.go', '.cs', '.py', '.rs',
-      '.cpp', '.cc', '.cxx', '.c', '.h', '.hpp'
+      '.cpp', '.cc', '.cxx', '.c', '.h', '.hpp',
+      // Go assembly, Protocol Buffers, Go templates
+      '.s', '.proto', '.tmpl', '.gotmpl', '.gohtml', '.tpl',
+      // Documentation, see features/docsIndex.ts
+      '.markdown', '.adoc', '.asciidoc'
     ];
-    return indexableExtensions.includes(ext);
+    return indexableExtensions.includes(ext) ||
+      (this.configManager?.getExtractorExtensions().includes(ext) ?? false);
   }
 
   /**
@@ -167,7 +231,7 @@ export class FileScanner {
       if (stats.size > this.maxFileSize) {
         const sizeInMB = stats.size / (1024 * 1024);
         const maxSizeMB = this.maxFileSize / (1024 * 1024);
-        console.info(
+        this.logger.info(
           `[FileScanner] Skipping large file ${filePath} (${sizeInMB.toFixed(2)}MB > ${maxSizeMB.toFixed(2)}MB)`
         );
         return false;
@@ -0,0 +1,81 @@
+/**
+ * Go Assembly Indexer Tests
+ *
+ * TEXT/GLOBL declarations, symbol operand references and linking assembly
+ * symbols to their Go declarations.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { GoAsmIndexer, assemblyCounterparts } from './goAsmIndexer.js';
+import { GoIndexer, GoSymbolMetadata } from './goIndexer.js';
+
+const asmSource = `// +build !noasm
+
+#include "textflag.h"
+
+// func Add(a, b int64) int64
+TEXT ·Add(SB), NOSPLIT, $0-24
+	MOVQ a+0(FP), AX
+	ADDQ b+8(FP), AX
+	CALL ·check(SB)
+	MOVQ AX, ret+16(FP)
+	RET
+
+/* memmove is provided by the runtime
+TEXT ·commented(SB), NOSPLIT, $0 */
+TEXT runtime∕internal∕sys·Prefetch<ABIInternal>(SB), NOSPLIT, $0-8
+	JMP runtime·memmove(SB)
+
+DATA ·table+0(SB)/8, $1
+GLOBL ·table(SB), RODATA, $8
+GLOBL mask<>(SB), RODATA, $16
+`;
+
+describe('GoAsmIndexer', () => {
+  const result = new GoAsmIndexer().parse('/ws/mathx/add_amd64.s', asmSource);
+
+  it('should index TEXT and GLOBL declarations', () => {
+    expect(result.symbols.map(s => `${s.kind}:${s.name}`)).toEqual([
+      'function:Add',
+      'function:Prefetch',
+      'variable:table'
+    ]);
+    const add = result.symbols[0];
+    expect(add.location).toEqual({ uri: '/ws/mathx/add_amd64.s', line: 5, character: 6 });
+    expect(add.isExported).toBe(true);
+    expect(add.metadata?.['go']).toEqual({ assembly: true, buildConstraint: 'amd64 && !noasm' });
+  });
+
+  it('should record symbol operands as references', () => {
+    expect(result.references.map(r => `${r.containerName}:${r.symbolName}`)).toEqual([
+      'Add:check',
+      'Prefetch:memmove',
+      'Prefetch:table'
+    ]);
+    expect(result.references[0].isCall).toBe(true);
+    expect(result.references[1].isCall).toBeUndefined();
+    expect(result.references[1].location.character).toBe(13);
+  });
+
+  it('should ignore other assembler dialects', () => {
+    const gnu = new GoAsmIndexer().parse('/ws/c/start.s', '.globl _start\n_start:\n\tcall main\n');
+    expect(gnu.symbols).toEqual([]);
+    expect(gnu.references).toEqual([]);
+  });
+});
+
+describe('assemblyCounterparts', () => {
+  const goSymbols = new GoIndexer().parse('/ws/mathx/add.go', 'package mathx\n\nfunc Add(a, b int64) int64\n').symbols;
+  const asmSymbols = new GoAsmIndexer().parse('/ws/mathx/add_amd64.s', asmSource).symbols;
+  const elsewhere = new GoAsmIndexer().parse('/ws/other/add_arm64.s', 'TEXT ·Add(SB), $0-24\n').symbols;
+  const definitions = [...goSymbols, ...asmSymbols, ...elsewhere].filter(s => s.name === 'Add');
+
+  it('should link Go declarations and assembly in the same package', () => {
+    const fromGo = assemblyCounterparts(definitions, '/ws/mathx/add.go');
+    expect(fromGo.map(s => s.location.uri)).toEqual(['/ws/mathx/add_amd64.s']);
+
+    const fromAsm = assemblyCounterparts(definitions, '/ws/mathx/add_amd64.s');
+    expect(fromAsm.map(s => s.location.uri)).toEqual(['/ws/mathx/add.go']);
+    expect((fromAsm[0].metadata?.['go'] as GoSymbolMetadata).external).toBe(true);
+  });
+});
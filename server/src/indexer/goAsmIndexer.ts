@@ -0,0 +1,150 @@
+import * as path from 'path';
+import { IndexedSymbol, IndexedReference } from '../types.js';
+import { createSymbolId } from './symbolResolver.js';
+import { fileBuildConstraint } from './goBuildConstraints.js';
+import { GoSymbolMetadata } from './goIndexer.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+
+/**
+ * Symbol operand of the Go assembler: `·Add(SB)`, `runtime·memmove(SB)`,
+ * `·table+8(SB)`, `·add<ABIInternal>(SB)`. The package before the middle
+ * dot is optional (the current package); `∕` stands for `/` in import paths.
+ */
+const SYMBOL_OPERAND = /([\p{L}\p{N}_.∕]*)·([\p{L}_][\p{L}\p{N}_]*)(?:<\w*>)?(?:[+-]\d+)?\(SB\)/gu;
+
+/** Directives that declare a symbol, and the kind they declare */
+const DECLARATIONS: Record<string, string> = {
+  TEXT: 'function',
+  GLOBL: 'variable'
+};
+
+/**
+ * Indexer for Go assembly (.s) files.
+ *
+ * Functions declared with TEXT and data declared with GLOBL become
+ * symbols named like their Go counterparts, so `func Add(a, b int) int`
+ * (declared without a body) and `TEXT ·Add(SB)` are found together.
+ * Symbol operands of other instructions (CALL, JMP, MOVQ $·x(SB), DATA)
+ * are references. File-local `name<>` symbols are not visible to Go and
+ * are left out. Files in other assembler dialects (GNU as) have no `·`
+ * operands and yield nothing.
+ */
+export class GoAsmIndexer implements LanguageParser {
+  readonly language = 'goasm';
+  readonly extensions = ['.s'];
+
+  parse(uri: string, content: string): ParseResult {
+    const symbols: IndexedSymbol[] = [];
+    const references: IndexedReference[] = [];
+    const buildConstraint = fileBuildConstraint(uri, content);
+    let currentFunction: string | undefined;
+    let inBlockComment = false;
+
+    const lines = content.split('\n');
+    for (let line = 0; line < lines.length; line++) {
+      const stripped = stripComments(lines[line], inBlockComment);
+      const text = stripped.text;
+      inBlockComment = stripped.inBlockComment;
+      if (text.trimStart().startsWith('#')) {
+        continue; // #include, #define
+      }
+
+      const directive = /^\s*(?:\w+:\s*)?([A-Z]+)\b/.exec(text)?.[1];
+      SYMBOL_OPERAND.lastIndex = 0;
+      let declared = false;
+      for (let match = SYMBOL_OPERAND.exec(text); match; match = SYMBOL_OPERAND.exec(text)) {
+        const name = match[2];
+        const character = match.index + match[1].length + 1;
+        const kind = directive ? DECLARATIONS[directive] : undefined;
+
+        if (kind && !declared) {
+          declared = true;
+          symbols.push(createAsmSymbol(uri, name, kind, line, character, text.trimEnd().length, {
+            assembly: true,
+            ...(buildConstraint && { buildConstraint })
+          }));
+          if (kind === 'function') {
+            currentFunction = name;
+          }
+          continue;
+        }
+
+        references.push({
+          symbolName: name,
+          location: { uri, line, character },
+          range: { startLine: line, startCharacter: character, endLine: line, endCharacter: character + name.length },
+          containerName: currentFunction,
+          isCall: directive === 'CALL' || undefined
+        });
+      }
+    }
+
+    return { symbols, references, imports: [] };
+  }
+}
+
+/**
+ * The other half of a declaration split between Go and assembly: for a Go
+ * function declared without a body, the TEXT symbols implementing it; for
+ * an assembly symbol, its Go declaration. Both halves are in the same
+ * directory, i.e. the same package.
+ */
+export function assemblyCounterparts(definitions: IndexedSymbol[], filePath: string): IndexedSymbol[] {
+  const fromAssembly = path.extname(filePath).toLowerCase() === '.s';
+  const dir = path.dirname(filePath);
+  return definitions.filter(symbol => {
+    const go = symbol.metadata?.go as GoSymbolMetadata | undefined;
+    return go !== undefined &&
+      !symbol.containerName &&
+      path.dirname(symbol.location.uri) === dir &&
+      (go.assembly === true) !== fromAssembly;
+  });
+}
+
+function createAsmSymbol(
+  uri: string,
+  name: string,
+  kind: string,
+  line: number,
+  character: number,
+  lineLength: number,
+  go: GoSymbolMetadata
+): IndexedSymbol {
+  return {
+    id: createSymbolId(uri, name, undefined, undefined, kind, false, undefined, line, character),
+    name,
+    kind,
+    location: { uri, line, character },
+    range: { startLine: line, startCharacter: 0, endLine: line, endCharacter: lineLength },
+    filePath: uri,
+    metadata: { go },
+    isDefinition: true,
+    isExported: /^\p{Lu}/u.test(name)
+  };
+}
+
+/**
+ * Blank out `//` and `/* *\/` comments, keeping columns.
+ */
+function stripComments(line: string, inBlockComment: boolean): { text: string; inBlockComment: boolean } {
+  let text = '';
+  let i = 0;
+  while (i < line.length) {
+    if (inBlockComment) {
+      const end = line.indexOf('*/', i);
+      const stop = end < 0 ? line.length : end + 2;
+      text += ' '.repeat(stop - i);
+      i = stop;
+      inBlockComment = end < 0;
+    } else if (line.startsWith('//', i)) {
+      break;
+    } else if (line.startsWith('/*', i)) {
+      inBlockComment = true;
+      text += '  ';
+      i += 2;
+    } else {
+      text += line[i++];
+    }
+  }
+  return { text, inBlockComment };
+}
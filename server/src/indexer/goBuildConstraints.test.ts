@@ -0,0 +1,94 @@
+/**
+ * Go Build Constraint Tests
+ *
+ * //go:build and // +build parsing, file name suffixes and evaluation
+ * against GOOS/GOARCH/tags.
+ */
+
+import { describe, it, expect } from 'vitest';
+import {
+  parseBuildExpression,
+  formatBuildExpression,
+  headerBuildConstraint,
+  fileNameBuildConstraint,
+  fileBuildConstraint,
+  matchesBuildContext,
+  resolveBuildContext,
+  hostBuildContext
+} from './goBuildConstraints.js';
+
+const linux = { goos: 'linux', goarch: 'amd64', tags: [] };
+const windows = { goos: 'windows', goarch: 'arm64', tags: [] };
+
+describe('parseBuildExpression', () => {
+  it('should respect precedence and normalize parentheses', () => {
+    expect(formatBuildExpression(parseBuildExpression('linux && (amd64 || arm64)'))).toBe('linux && (amd64 || arm64)');
+    expect(formatBuildExpression(parseBuildExpression('(linux && amd64) || darwin'))).toBe('linux && amd64 || darwin');
+    expect(formatBuildExpression(parseBuildExpression('!(js || wasip1)'))).toBe('!(js || wasip1)');
+  });
+
+  it('should reject malformed expressions', () => {
+    expect(() => parseBuildExpression('linux &&')).toThrow(/Invalid build constraint/);
+    expect(() => parseBuildExpression('(linux')).toThrow(/missing \)/);
+    expect(() => parseBuildExpression('linux darwin')).toThrow(/unexpected "darwin"/);
+  });
+});
+
+describe('headerBuildConstraint', () => {
+  it('should read //go:build before the package clause only', () => {
+    expect(headerBuildConstraint('// Copyright 2024\n\n//go:build linux || darwin\n\npackage x\n')).toBe('linux || darwin');
+    expect(headerBuildConstraint('package x\n\n//go:build linux\n')).toBeUndefined();
+    expect(headerBuildConstraint('/* license\n   text */\n//go:build ignore\npackage x\n')).toBe('ignore');
+  });
+
+  it('should convert legacy +build lines', () => {
+    const content = '// +build linux,amd64 darwin\n// +build !cgo\n\npackage x\n';
+    expect(headerBuildConstraint(content)).toBe('(linux && amd64 || darwin) && !cgo');
+  });
+
+  it('should prefer //go:build over +build lines', () => {
+    expect(headerBuildConstraint('//go:build windows\n// +build windows\n\npackage x\n')).toBe('windows');
+  });
+});
+
+describe('fileNameBuildConstraint', () => {
+  it('should follow go/build file name rules', () => {
+    expect(fileNameBuildConstraint('/ws/fs/open_windows.go')).toBe('windows');
+    expect(fileNameBuildConstraint('/ws/fs/open_linux_arm64.go')).toBe('linux && arm64');
+    expect(fileNameBuildConstraint('/ws/fs/open_amd64_test.go')).toBe('amd64');
+    expect(fileNameBuildConstraint('/ws/fs/linux.go')).toBeUndefined();
+    expect(fileNameBuildConstraint('/ws/fs/open_test.go')).toBeUndefined();
+    expect(fileNameBuildConstraint('/ws/fs/open_unix.go')).toBeUndefined();
+  });
+
+  it('should combine name and header constraints', () => {
+    expect(fileBuildConstraint('/ws/fs/open_linux.go', '//go:build cgo\npackage fs\n')).toBe('linux && cgo');
+    expect(fileBuildConstraint('/ws/fs/open.go', '//go:build &&\npackage fs\n')).toBeUndefined();
+  });
+});
+
+describe('matchesBuildContext', () => {
+  it('should evaluate tags against the target', () => {
+    expect(matchesBuildContext(undefined, linux)).toBe(true);
+    expect(matchesBuildContext('linux && amd64', linux)).toBe(true);
+    expect(matchesBuildContext('linux && amd64', windows)).toBe(false);
+    expect(matchesBuildContext('!windows', windows)).toBe(false);
+    expect(matchesBuildContext('integration', linux)).toBe(false);
+    expect(matchesBuildContext('integration', { ...linux, tags: ['integration'] })).toBe(true);
+  });
+
+  it('should know implied tags', () => {
+    expect(matchesBuildContext('unix', linux)).toBe(true);
+    expect(matchesBuildContext('unix', windows)).toBe(false);
+    expect(matchesBuildContext('linux', { ...linux, goos: 'android' })).toBe(true);
+    expect(matchesBuildContext('go1.21 && gc', linux)).toBe(true);
+  });
+
+  it('should enable cgo for native targets only', () => {
+    const host = hostBuildContext();
+    expect(resolveBuildContext({}).tags).toEqual(['cgo']);
+    expect(resolveBuildContext({}).goos).toBe(host.goos);
+    const cross = resolveBuildContext({ goos: host.goos === 'plan9' ? 'linux' : 'plan9', tags: ['netgo'] });
+    expect(cross.tags).toEqual(['netgo']);
+  });
+});
@@ -0,0 +1,311 @@
+/**
+ * Go build constraints - which files a given build would compile.
+ *
+ * A Go file is constrained by `//go:build` lines (or legacy `// +build`
+ * lines) in its header and by `_GOOS`, `_GOARCH` and `_GOOS_GOARCH` file
+ * name suffixes. These are evaluated against a target, as `go build` does
+ * with GOOS, GOARCH and -tags, so platform-specific files can be indexed for
+ * one platform or tagged with the constraint they belong to.
+ */
+
+import * as path from 'path';
+
+/** Build target: what `go build` would see */
+export interface GoBuildContext {
+  goos: string;
+  goarch: string;
+  /** Extra tags, as passed to `go build -tags` */
+  tags: string[];
+}
+
+export type GoBuildExpression =
+  | { op: 'tag'; tag: string }
+  | { op: 'not'; operand: GoBuildExpression }
+  | { op: 'and' | 'or'; left: GoBuildExpression; right: GoBuildExpression };
+
+/** GOOS values known to go/build (file name suffixes only count for these) */
+export const KNOWN_GOOS = new Set([
+  'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'js', 'linux',
+  'nacl', 'netbsd', 'openbsd', 'plan9', 'solaris', 'wasip1', 'windows', 'zos'
+]);
+
+/** GOARCH values known to go/build */
+export const KNOWN_GOARCH = new Set([
+  '386', 'amd64', 'amd64p32', 'arm', 'armbe', 'arm64', 'arm64be', 'loong64', 'mips', 'mipsle',
+  'mips64', 'mips64le', 'mips64p32', 'mips64p32le', 'ppc', 'ppc64', 'ppc64le', 'riscv', 'riscv64',
+  's390', 's390x', 'sparc', 'sparc64', 'wasm'
+]);
+
+/** GOOS values that satisfy the `unix` tag */
+const UNIX_GOOS = new Set([
+  'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'linux',
+  'netbsd', 'openbsd', 'solaris'
+]);
+
+/** GOOS values that also satisfy another GOOS tag */
+const IMPLIED_GOOS: Record<string, string> = {
+  android: 'linux',
+  illumos: 'solaris',
+  ios: 'darwin'
+};
+
+const NODE_PLATFORM_TO_GOOS: Record<string, string> = {
+  win32: 'windows',
+  sunos: 'solaris'
+};
+
+const NODE_ARCH_TO_GOARCH: Record<string, string> = {
+  x64: 'amd64',
+  ia32: '386',
+  mipsel: 'mipsle',
+  ppc64: 'ppc64le'
+};
+
+/**
+ * Build context of the machine the indexer runs on.
+ */
+export function hostBuildContext(): GoBuildContext {
+  return {
+    goos: NODE_PLATFORM_TO_GOOS[process.platform] ?? process.platform,
+    goarch: NODE_ARCH_TO_GOARCH[process.arch] ?? process.arch,
+    tags: []
+  };
+}
+
+/**
+ * Build context for a target, filling in the host's GOOS/GOARCH where not
+ * set. Native builds get the `cgo` tag, as with the go command's default
+ * CGO_ENABLED=1.
+ */
+export function resolveBuildContext(target: { goos?: string; goarch?: string; tags?: string[] }): GoBuildContext {
+  const host = hostBuildContext();
+  const goos = target.goos || host.goos;
+  const goarch = target.goarch || host.goarch;
+  const tags = (target.tags ?? []).map(tag => tag.trim()).filter(Boolean);
+  if (goos === host.goos && goarch === host.goarch && !tags.includes('cgo')) {
+    tags.push('cgo');
+  }
+  return { goos, goarch, tags };
+}
+
+/**
+ * Parse a `//go:build` expression (`linux && (amd64 || arm64)`).
+ * Throws on syntax errors.
+ */
+export function parseBuildExpression(text: string): GoBuildExpression {
+  const tokens = text.match(/&&|\|\||[!()]|[^\s!()&|]+|\S/g) ?? [];
+  let position = 0;
+
+  const fail = (message: string): never => {
+    throw new Error(`Invalid build constraint "${text}": ${message}`);
+  };
+
+  const parseOr = (): GoBuildExpression => {
+    let left = parseAnd();
+    while (tokens[position] === '||') {
+      position++;
+      left = { op: 'or', left, right: parseAnd() };
+    }
+    return left;
+  };
+
+  const parseAnd = (): GoBuildExpression => {
+    let left = parseNot();
+    while (tokens[position] === '&&') {
+      position++;
+      left = { op: 'and', left, right: parseNot() };
+    }
+    return left;
+  };
+
+  const parseNot = (): GoBuildExpression => {
+    const token = tokens[position++];
+    if (token === undefined) {
+      return fail('unexpected end of expression');
+    }
+    if (token === '!') {
+      return { op: 'not', operand: parseNot() };
+    }
+    if (token === '(') {
+      const inner = parseOr();
+      if (tokens[position++] !== ')') {
+        fail('missing )');
+      }
+      return inner;
+    }
+    if (!/^[\w.]+$/.test(token)) {
+      fail(`unexpected "${token}"`);
+    }
+    return { op: 'tag', tag: token };
+  };
+
+  const expression = parseOr();
+  if (position < tokens.length) {
+    fail(`unexpected "${tokens[position]}"`);
+  }
+  return expression;
+}
+
+/**
+ * Format an expression with minimal parentheses; the normalized form of a
+ * constraint.
+ */
+export function formatBuildExpression(expression: GoBuildExpression): string {
+  const format = (node: GoBuildExpression, parent: 'not' | 'and' | 'or' | undefined): string => {
+    switch (node.op) {
+      case 'tag':
+        return node.tag;
+      case 'not':
+        return `!${format(node.operand, 'not')}`;
+      default: {
+        const text = `${format(node.left, node.op)} ${node.op === 'and' ? '&&' : '||'} ${format(node.right, node.op)}`;
+        const needsParens = parent === 'not' || (parent === 'and' && node.op === 'or');
+        return needsParens ? `(${text})` : text;
+      }
+    }
+  };
+  return format(expression, undefined);
+}
+
+/**
+ * The constraint of a file's header, as a `//go:build` expression. A
+ * `//go:build` line wins over `// +build` lines, as in the go command; the
+ * lines only count before the package clause.
+ */
+export function headerBuildConstraint(content: string): string | undefined {
+  const plusBuild: string[] = [];
+  let inBlockComment = false;
+
+  for (const rawLine of content.split('\n')) {
+    const line = rawLine.trim();
+    if (inBlockComment) {
+      inBlockComment = !line.includes('*/');
+      continue;
+    }
+    if (line === '') {
+      continue;
+    }
+    if (line.startsWith('/*')) {
+      inBlockComment = !line.includes('*/', 2);
+      continue;
+    }
+    if (!line.startsWith('//')) {
+      break;
+    }
+
+    const goBuild = /^\/\/go:build\s+(.+)$/.exec(line);
+    if (goBuild) {
+      return formatBuildExpression(parseBuildExpression(goBuild[1]));
+    }
+    const legacy = /^\/\/\s*\+build\s+(.+)$/.exec(line);
+    if (legacy) {
+      plusBuild.push(plusBuildToExpression(legacy[1]));
+    }
+  }
+
+  if (plusBuild.length === 0) {
+    return undefined;
+  }
+  return formatBuildExpression(parseBuildExpression(plusBuild.map(part => `(${part})`).join(' && ')));
+}
+
+/**
+ * The constraint implied by a file name: `linux` for `file_linux.go`,
+ * `windows && amd64` for `file_windows_amd64.go`. Both `_test` files and
+ * names without an underscore prefix follow go/build's rules.
+ */
+export function fileNameBuildConstraint(filePath: string): string | undefined {
+  let name = path.basename(filePath);
+  const dot = name.indexOf('.');
+  if (dot >= 0) {
+    name = name.substring(0, dot);
+  }
+  const underscore = name.indexOf('_');
+  if (underscore < 0) {
+    return undefined;
+  }
+
+  // Everything before the first underscore is ignored, so `linux.go` is not constrained
+  const parts = name.substring(underscore).split('_');
+  if (parts[parts.length - 1] === 'test') {
+    parts.pop();
+  }
+  const last = parts[parts.length - 1];
+  const beforeLast = parts[parts.length - 2];
+  if (parts.length >= 3 && KNOWN_GOOS.has(beforeLast) && KNOWN_GOARCH.has(last)) {
+    return `${beforeLast} && ${last}`;
+  }
+  if (parts.length >= 2 && (KNOWN_GOOS.has(last) || KNOWN_GOARCH.has(last))) {
+    return last;
+  }
+  return undefined;
+}
+
+/**
+ * Full constraint of a file: its name suffix and its header combined, or
+ * undefined when every build includes it. A malformed header counts as no
+ * constraint rather than excluding the file.
+ */
+export function fileBuildConstraint(filePath: string, content: string): string | undefined {
+  let header: string | undefined;
+  try {
+    header = headerBuildConstraint(content);
+  } catch {
+    header = undefined;
+  }
+  const fileName = fileNameBuildConstraint(filePath);
+  if (header && fileName) {
+    return formatBuildExpression(parseBuildExpression(`(${fileName}) && (${header})`));
+  }
+  return header ?? fileName;
+}
+
+/**
+ * Whether a build for the context includes files with the constraint.
+ */
+export function matchesBuildContext(constraint: string | undefined, context: GoBuildContext): boolean {
+  if (!constraint) {
+    return true;
+  }
+  return evaluate(parseBuildExpression(constraint), context);
+}
+
+function evaluate(expression: GoBuildExpression, context: GoBuildContext): boolean {
+  switch (expression.op) {
+    case 'tag':
+      return hasTag(expression.tag, context);
+    case 'not':
+      return !evaluate(expression.operand, context);
+    case 'and':
+      return evaluate(expression.left, context) && evaluate(expression.right, context);
+    case 'or':
+      return evaluate(expression.left, context) || evaluate(expression.right, context);
+  }
+}
+
+function hasTag(tag: string, context: GoBuildContext): boolean {
+  if (tag === context.goos || tag === context.goarch || context.tags.includes(tag)) {
+    return true;
+  }
+  if (tag === 'unix') {
+    return UNIX_GOOS.has(context.goos);
+  }
+  if (IMPLIED_GOOS[context.goos] === tag) {
+    return true;
+  }
+  // Release tags: the indexer assumes a current toolchain, and the gc compiler
+  return /^go1\.\d+$/.test(tag) || tag === 'gc';
+}
+
+/**
+ * `// +build` syntax: space-separated options are ORed, comma-separated
+ * terms within an option are ANDed.
+ */
+function plusBuildToExpression(line: string): string {
+  return line
+    .trim()
+    .split(/\s+/)
+    .map(option => option.split(',').filter(Boolean).join(' && '))
+    .map(option => (option.includes('&&') ? `(${option})` : option))
+    .join(' || ');
+}
@@ -0,0 +1,60 @@
+/**
+ * Go Constant Evaluation Tests
+ *
+ * Verifies constant expression evaluation used for constant values.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { evaluateGoConstant, formatGoConstant, GoConstValue, parseGoNumber } from './goConstants.js';
+import { GoTokenizer } from './components/GoTokenizer.js';
+
+function evaluate(expression: string, constants: Record<string, GoConstValue> = {}, iota = 0): GoConstValue | undefined {
+  const { tokens } = new GoTokenizer(expression).tokenize();
+  return evaluateGoConstant(tokens, new Map(Object.entries(constants)), iota);
+}
+
+describe('evaluateGoConstant', () => {
+  it('should evaluate integer expressions with Go precedence', () => {
+    expect(evaluate('1 + 2*3')).toBe(7n);
+    expect(evaluate('(1 + 2) * 3')).toBe(9n);
+    expect(evaluate('1 << 3 | 1')).toBe(9n);
+    expect(evaluate('-7 / 2')).toBe(-3n);
+    expect(evaluate('^0')).toBe(-1n);
+    expect(evaluate('0b1010 &^ 0o2')).toBe(8n);
+    expect(evaluate('1 << (10 * (iota + 1))', {}, 2)).toBe(1n << 30n);
+    expect(evaluate('Size * 2', { Size: 4n })).toBe(8n);
+  });
+
+  it('should evaluate floats, strings, runes, booleans and conversions', () => {
+    expect(evaluate('1.5 * 2')).toBe(3);
+    expect(evaluate('uint8(255)')).toBe(255n);
+    expect(evaluate('Duration(3)')).toBe(3n);
+    expect(evaluate('"a" + `b`')).toBe('ab');
+    expect(evaluate('len("héllo")')).toBe(6n);
+    expect(evaluate("'a' + 1")).toBe(98n);
+    expect(evaluate('Debug && 1 < 2', { Debug: true })).toBe(true);
+  });
+
+  it('should give up on expressions it cannot evaluate', () => {
+    expect(evaluate('time.Second * 5')).toBeUndefined();
+    expect(evaluate('Unknown + 1')).toBeUndefined();
+    expect(evaluate('1 / 0')).toBeUndefined();
+    expect(evaluate('"a" + 1')).toBeUndefined();
+    expect(evaluate('max(1, 2)')).toBeUndefined();
+    expect(evaluate('(1 + 2')).toBeUndefined();
+    expect(evaluate('1 2')).toBeUndefined();
+  });
+
+  it('should parse number literals and format values', () => {
+    expect(parseGoNumber('1_000')).toBe(1000n);
+    expect(parseGoNumber('0x1F')).toBe(31n);
+    expect(parseGoNumber('017')).toBe(15n);
+    expect(parseGoNumber('1e3')).toBe(1000);
+    expect(parseGoNumber('2i')).toBeUndefined();
+
+    expect(formatGoConstant(5n)).toBe('5');
+    expect(formatGoConstant(0.25)).toBe('0.25');
+    expect(formatGoConstant('a"b')).toBe('"a\\"b"');
+    expect(formatGoConstant(false)).toBe('false');
+  });
+});
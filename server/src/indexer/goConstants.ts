@@ -0,0 +1,312 @@
+import { GoToken } from './components/GoTokenizer.js';
+
+/**
+ * Value of a Go constant expression: integers are exact (`bigint`), floats
+ * approximate (`number`).
+ */
+export type GoConstValue = bigint | number | string | boolean;
+
+/**
+ * Binary operator precedence, from the Go spec.
+ */
+const PRECEDENCE: Record<string, number> = {
+  '||': 1,
+  '&&': 2,
+  '==': 3, '!=': 3, '<': 3, '<=': 3, '>': 3, '>=': 3,
+  '+': 4, '-': 4, '|': 4, '^': 4,
+  '*': 5, '/': 5, '%': 5, '<<': 5, '>>': 5, '&': 5, '&^': 5
+};
+
+/** Conversions that keep the operand's value, besides named types */
+const CONVERSIONS = new Set([
+  'int', 'int8', 'int16', 'int32', 'int64', 'uint', 'uint8', 'uint16', 'uint32', 'uint64', 'uintptr',
+  'float32', 'float64', 'byte', 'rune', 'string'
+]);
+
+/** Builtins other than len, whose results are not the operand's value */
+const BUILTINS = new Set(['cap', 'complex', 'imag', 'max', 'min', 'real']);
+
+/**
+ * Evaluate a constant expression such as `1 << (10 * (iota + 1))`,
+ * `Weekday(3)` or `"v" + Major`.
+ *
+ * Identifiers resolve through `constants` (constants declared earlier in
+ * the file) and `iota`. Returns undefined for anything that is not a
+ * constant expression this evaluator understands: qualified names,
+ * function calls, overflowing shifts, division by zero, ...
+ */
+export function evaluateGoConstant(
+  tokens: GoToken[],
+  constants: ReadonlyMap<string, GoConstValue>,
+  iota: number
+): GoConstValue | undefined {
+  if (tokens.length === 0) {
+    return undefined;
+  }
+  const parser = new ConstParser(tokens, constants, iota);
+  try {
+    const value = parser.expression(0);
+    return parser.done() ? value : undefined;
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * Go syntax for a constant value: `5`, `1.5`, `"text"`, `true`.
+ */
+export function formatGoConstant(value: GoConstValue): string {
+  if (typeof value === 'string') {
+    return JSON.stringify(value);
+  }
+  return String(value);
+}
+
+/**
+ * Parse a Go integer or float literal (`0x1F`, `0o17`, `017`, `1_000`, `1e9`).
+ */
+export function parseGoNumber(text: string): bigint | number | undefined {
+  const literal = text.replace(/_/g, '');
+  if (/^0[xX][0-9a-fA-F]+$/.test(literal) || /^0[bB][01]+$/.test(literal) || /^0[oO][0-7]+$/.test(literal)) {
+    return BigInt(literal);
+  }
+  if (/^0[0-7]+$/.test(literal)) {
+    return BigInt('0o' + literal.slice(1));
+  }
+  if (/^\d+$/.test(literal)) {
+    return BigInt(literal);
+  }
+  if (/^(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$/.test(literal)) {
+    return Number(literal);
+  }
+  return undefined;
+}
+
+class NotConstant extends Error {}
+
+function fail(): never {
+  throw new NotConstant();
+}
+
+/**
+ * Precedence-climbing parser that evaluates while it parses.
+ */
+class ConstParser {
+  private pos = 0;
+
+  constructor(
+    private tokens: GoToken[],
+    private constants: ReadonlyMap<string, GoConstValue>,
+    private iota: number
+  ) {}
+
+  done(): boolean {
+    return this.pos === this.tokens.length;
+  }
+
+  expression(minPrecedence: number): GoConstValue {
+    let left = this.unary();
+    for (;;) {
+      const op = this.peek();
+      const precedence = op?.type === 'punct' ? PRECEDENCE[op.text] : undefined;
+      if (precedence === undefined || precedence <= minPrecedence) {
+        return left;
+      }
+      this.pos++;
+      left = binary(op!.text, left, this.expression(precedence));
+    }
+  }
+
+  private unary(): GoConstValue {
+    const token = this.next();
+    switch (token.text) {
+      case '+':
+        return numeric(this.unary());
+      case '-':
+        return -numeric(this.unary());
+      case '^': {
+        const value = this.unary();
+        return typeof value === 'bigint' ? ~value : fail();
+      }
+      case '!': {
+        const value = this.unary();
+        return typeof value === 'boolean' ? !value : fail();
+      }
+      case '(': {
+        const value = this.expression(0);
+        this.expect(')');
+        return value;
+      }
+    }
+    return this.primary(token);
+  }
+
+  private primary(token: GoToken): GoConstValue {
+    switch (token.type) {
+      case 'number':
+        return parseGoNumber(token.text) ?? fail();
+      case 'string':
+        return goStringValue(token.text);
+      case 'rune':
+        return BigInt(goStringValue(token.text).codePointAt(0) ?? fail());
+      case 'ident':
+        break;
+      default:
+        fail();
+    }
+
+    // Conversion: T(x), keeping the operand's value
+    if (this.peek()?.text === '(') {
+      if (token.text === 'len') {
+        this.pos++;
+        const operand = this.expression(0);
+        this.expect(')');
+        return typeof operand === 'string' ? BigInt(Buffer.byteLength(operand, 'utf8')) : fail();
+      }
+      if (CONVERSIONS.has(token.text) || (!BUILTINS.has(token.text) && !this.constants.has(token.text))) {
+        this.pos++;
+        const operand = this.expression(0);
+        this.expect(')');
+        return convert(token.text, operand);
+      }
+    }
+    if (token.text === 'iota') {
+      return BigInt(this.iota);
+    }
+    if (token.text === 'true' || token.text === 'false') {
+      return token.text === 'true';
+    }
+    return this.constants.get(token.text) ?? fail();
+  }
+
+  private peek(): GoToken | undefined {
+    return this.tokens[this.pos];
+  }
+
+  private next(): GoToken {
+    return this.tokens[this.pos++] ?? fail();
+  }
+
+  private expect(text: string): void {
+    if (this.next().text !== text) {
+      fail();
+    }
+  }
+}
+
+function numeric(value: GoConstValue): bigint | number {
+  return typeof value === 'bigint' || typeof value === 'number' ? value : fail();
+}
+
+function convert(type: string, value: GoConstValue): GoConstValue {
+  if (type.startsWith('float')) {
+    return Number(numeric(value));
+  }
+  if (CONVERSIONS.has(type) && type !== 'string') {
+    const number = numeric(value);
+    return typeof number === 'bigint' ? number : Number.isInteger(number) ? BigInt(number) : fail();
+  }
+  return value;
+}
+
+function binary(op: string, left: GoConstValue, right: GoConstValue): GoConstValue {
+  if (op === '&&' || op === '||') {
+    if (typeof left !== 'boolean' || typeof right !== 'boolean') {
+      fail();
+    }
+    return op === '&&' ? left && right : left || right;
+  }
+  if (op === '==' || op === '!=' || op === '<' || op === '<=' || op === '>' || op === '>=') {
+    return compare(op, left, right);
+  }
+  if (typeof left === 'string' || typeof right === 'string') {
+    return op === '+' && typeof left === 'string' && typeof right === 'string' ? left + right : fail();
+  }
+
+  const a = numeric(left);
+  const b = numeric(right);
+  if (typeof a === 'bigint' && typeof b === 'bigint') {
+    return integerOp(op, a, b);
+  }
+  const x = Number(a);
+  const y = Number(b);
+  switch (op) {
+    case '+': return x + y;
+    case '-': return x - y;
+    case '*': return x * y;
+    case '/': return y === 0 ? fail() : x / y;
+  }
+  // Shifts and bitwise operators need integers; an integral float operand is fine
+  if (Number.isInteger(x) && Number.isInteger(y)) {
+    return integerOp(op, BigInt(x), BigInt(y));
+  }
+  return fail();
+}
+
+function integerOp(op: string, a: bigint, b: bigint): bigint {
+  switch (op) {
+    case '+': return a + b;
+    case '-': return a - b;
+    case '*': return a * b;
+    case '/': return b === 0n ? fail() : a / b;
+    case '%': return b === 0n ? fail() : a % b;
+    case '|': return a | b;
+    case '^': return a ^ b;
+    case '&': return a & b;
+    case '&^': return a & ~b;
+    // Huge shifts are valid Go but not worth materializing
+    case '<<': return b < 0n || b > 1024n ? fail() : a << b;
+    case '>>': return b < 0n ? fail() : a >> b;
+  }
+  return fail();
+}
+
+function compare(op: string, left: GoConstValue, right: GoConstValue): boolean {
+  let order: number;
+  if (typeof left === 'boolean' || typeof right === 'boolean') {
+    if (typeof left !== typeof right || (op !== '==' && op !== '!=')) {
+      fail();
+    }
+    order = left === right ? 0 : 1;
+  } else if (typeof left === 'string' || typeof right === 'string') {
+    if (typeof left !== typeof right) {
+      fail();
+    }
+    order = left < right ? -1 : left > right ? 1 : 0;
+  } else {
+    order = left < right ? -1 : left > right ? 1 : 0;
+  }
+  switch (op) {
+    case '==': return order === 0;
+    case '!=': return order !== 0;
+    case '<': return order < 0;
+    case '<=': return order <= 0;
+    case '>': return order > 0;
+    default: return order >= 0;
+  }
+}
+
+/**
+ * Value of a string or rune literal, with the common escapes.
+ */
+function goStringValue(literal: string): string {
+  if (literal.startsWith('`')) {
+    return literal.slice(1, -1).replace(/\r/g, '');
+  }
+  const body = literal.slice(1, -1);
+  return body.replace(/\\(x[0-9a-fA-F]{2}|u[0-9a-fA-F]{4}|U[0-9a-fA-F]{8}|[0-7]{3}|.)/g, (_, escape: string) => {
+    switch (escape[0]) {
+      case 'n': return '\n';
+      case 't': return '\t';
+      case 'r': return '\r';
+      case 'a': return '\x07';
+      case 'b': return '\b';
+      case 'f': return '\f';
+      case 'v': return '\v';
+      case 'x': return String.fromCharCode(parseInt(escape.slice(1), 16));
+      case 'u':
+      case 'U': return String.fromCodePoint(parseInt(escape.slice(1), 16));
+    }
+    return /^[0-7]{3}$/.test(escape) ? String.fromCharCode(parseInt(escape, 8)) : escape;
+  });
+}
@@ -0,0 +1,454 @@
+/**
+ * Go Indexer Tests
+ *
+ * Validates structural extraction of Go declarations and identifier
+ * references used by go-to-definition and find-references.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { GoIndexer, GoSymbolMetadata, goPackageNameFromPath, goTestKind, isGoExported } from './goIndexer.js';
+import { IndexedSymbol } from '../types.js';
+
+const goSource = `package store
+
+import (
+	"context"
+	yaml "gopkg.in/yaml.v3"
+	"github.com/acme/api/v2"
+)
+
+const (
+	DefaultLimit = 10
+	maxRetries   int = 3
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Reader reads items.
+type Reader interface {
+	io.Closer
+	Get(ctx context.Context, id string) (*Item, error)
+}
+
+type Item struct {
+	Base
+	ID   string \`json:"id"\`
+	Tags []string
+}
+
+type Items []Item
+
+func (s *Store) Get(ctx context.Context, id string) (*Item, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	_ = yaml.Marshal
+	return item, nil
+}
+
+func NewStore() *Store {
+	return &Store{limit: DefaultLimit}
+}
+`;
+
+function find(symbols: IndexedSymbol[], name: string, containerName?: string): IndexedSymbol | undefined {
+  return symbols.find(s => s.name === name && (containerName === undefined || s.containerName === containerName));
+}
+
+function goMeta(symbol: IndexedSymbol | undefined): GoSymbolMetadata {
+  return (symbol?.metadata?.go ?? {}) as GoSymbolMetadata;
+}
+
+describe('GoIndexer', () => {
+  const result = new GoIndexer().indexFile('/ws/store/store.go', goSource);
+
+  it('should extract the package clause and imports', () => {
+    expect(result.packageName).toBe('store');
+    expect(result.imports.map(i => i.localName)).toEqual(['context', 'yaml', 'api']);
+    expect(result.imports[1].moduleSpecifier).toBe('gopkg.in/yaml.v3');
+    expect(result.imports.every(i => i.isNamespace)).toBe(true);
+  });
+
+  it('should extract structs with fields, tags and embedded types', () => {
+    const item = find(result.symbols, 'Item');
+    expect(item?.kind).toBe('struct');
+    expect(item?.isExported).toBe(true);
+    expect(goMeta(item).embeds).toEqual(['Base']);
+
+    const id = find(result.symbols, 'ID', 'Item');
+    expect(id?.kind).toBe('field');
+    expect(id?.fullContainerPath).toBe('store.Item');
+    expect(goMeta(id).type).toBe('string');
+    expect(goMeta(id).tag).toBe('json:"id"');
+
+    const base = find(result.symbols, 'Base', 'Item');
+    expect(goMeta(base).embedded).toBe(true);
+  });
+
+  it('should extract interfaces with methods and embedded interfaces', () => {
+    const reader = find(result.symbols, 'Reader');
+    expect(reader?.kind).toBe('interface');
+    expect(goMeta(reader).embeds).toEqual(['Closer']);
+
+    const get = find(result.symbols, 'Get', 'Reader');
+    expect(get?.kind).toBe('method');
+    expect(get?.containerKind).toBe('interface');
+    expect(get?.parametersCount).toBe(2);
+  });
+
+  it('should record receiver type and pointer-ness for methods', () => {
+    const get = find(result.symbols, 'Get', 'Store');
+    expect(get?.kind).toBe('method');
+    expect(goMeta(get).receiverType).toBe('Store');
+    expect(goMeta(get).pointerReceiver).toBe(true);
+
+    const ctor = find(result.symbols, 'NewStore');
+    expect(ctor?.kind).toBe('function');
+    expect(ctor?.fullContainerPath).toBe('store');
+  });
+
+  it('should extract grouped constants and package variables', () => {
+    expect(find(result.symbols, 'DefaultLimit')?.kind).toBe('constant');
+    const retries = find(result.symbols, 'maxRetries');
+    expect(retries?.isExported).toBe(false);
+    expect(goMeta(retries).type).toBe('int');
+    expect(find(result.symbols, 'ErrNotFound')?.kind).toBe('variable');
+    expect(goMeta(find(result.symbols, 'Items')).underlying).toBe('[]Item');
+  });
+
+  it('should capture constant values with iota expansion', () => {
+    expect(find(result.symbols, 'DefaultLimit')?.value).toBe('10');
+    expect(find(result.symbols, 'maxRetries')?.value).toBe('3');
+    expect(find(result.symbols, 'ErrNotFound')?.value).toBeUndefined();
+
+    const { symbols } = new GoIndexer().indexFile('/ws/units/units.go', [
+      'package units',
+      '',
+      'type Weekday int',
+      '',
+      'const (',
+      '\tSunday Weekday = iota',
+      '\tMonday',
+      '\t_',
+      '\tWednesday',
+      ')',
+      '',
+      'const (',
+      '\t_  = iota',
+      '\tKB = 1 << (10 * iota)',
+      '\tMB',
+      ')',
+      '',
+      'const (',
+      '\tMask      = 0xFF',
+      '\tLow, High = Mask & 0x0F, Mask &^ 0x0F',
+      '\tVersion   = "v" + "1.2"',
+      '\tTimeout   = 5 * time.Second',
+      '\tRatio     = float64(KB) / 4',
+      ')'
+    ].join('\n'));
+
+    expect(['Sunday', 'Monday', 'Wednesday'].map(name => find(symbols, name)?.value)).toEqual(['0', '1', '3']);
+    expect(goMeta(find(symbols, 'Wednesday')).type).toBe('Weekday');
+    expect(find(symbols, 'KB')?.value).toBe('1024');
+    expect(find(symbols, 'MB')?.value).toBe('1048576');
+    expect(find(symbols, 'Mask')?.value).toBe('0xFF');
+    expect(find(symbols, 'Low')?.value).toBe('15');
+    expect(find(symbols, 'High')?.value).toBe('240');
+    expect(find(symbols, 'Version')?.value).toBe('"v1.2"');
+    expect(find(symbols, 'Timeout')?.value).toBe('5 * time.Second');
+    expect(find(symbols, 'Ratio')?.value).toBe('256');
+  });
+
+  it('should record signatures and type parameters', () => {
+    const { symbols, references } = new GoIndexer().indexFile('/ws/slices/slices.go', [
+      'package slices',
+      '',
+      'type Set[K comparable, V any] map[K]V',
+      '',
+      'type Number interface { ~int | ~float64 }',
+      '',
+      'func Map[S ~[]E, E, R any](s S, f func(E) R) []R { return nil }',
+      '',
+      'func (s Set[K, V]) Get(key K) (v V, ok bool) { return }',
+      '',
+      'func Send(ch chan<- int, xs ...int) {}',
+      '',
+      'type Reader interface {',
+      '\tRead(p []byte) (n int, err error)',
+      '}'
+    ].join('\n'));
+
+    expect(find(symbols, 'Set')?.typeParameters).toEqual([
+      { name: 'K', constraint: 'comparable' },
+      { name: 'V', constraint: 'any' }
+    ]);
+    const map = find(symbols, 'Map');
+    expect(map?.signature).toBe('func(s S, f func(E) R) []R');
+    expect(map?.typeParameters).toEqual([
+      { name: 'S', constraint: '~[]E' },
+      { name: 'E', constraint: 'any' },
+      { name: 'R', constraint: 'any' }
+    ]);
+    expect(find(symbols, 'Get', 'Set')?.signature).toBe('func(key K) (v V, ok bool)');
+    expect(find(symbols, 'Send')?.signature).toBe('func(ch chan<- int, xs ...int)');
+    expect(find(symbols, 'Read', 'Reader')?.signature).toBe('func(p []byte) (n int, err error)');
+    expect(find(symbols, 'Number')?.typeParameters).toBeUndefined();
+
+    // Type parameter declarations are not references, their uses are
+    expect(references.filter(r => r.symbolName === 'E').map(r => r.location.line)).toEqual([6, 6]);
+  });
+
+  it('should attach doc comments to declarations', () => {
+    const { symbols } = new GoIndexer().indexFile('/ws/greet/greet.go', [
+      'package greet',
+      '',
+      '// Person is someone to greet.',
+      '//',
+      '// See [Greet].',
+      'type Person struct {',
+      '\t// Name is shown in greetings.',
+      '\tName string',
+      '\tAge  int // not a doc comment',
+      '}',
+      '',
+      '// Greet returns a greeting.',
+      '//go:noinline',
+      'func Greet(p Person) string { return p.Name }',
+      '',
+      '// Detached comment.',
+      '',
+      'func Other() {}',
+      '',
+      '/* Version is the',
+      '   release name. */',
+      'const Version = "1"'
+    ].join('\n'));
+
+    expect(find(symbols, 'Person')?.doc).toBe('Person is someone to greet.\n\nSee [Greet].');
+    expect(find(symbols, 'Name', 'Person')?.doc).toBe('Name is shown in greetings.');
+    expect(find(symbols, 'Age', 'Person')?.doc).toBeUndefined();
+    expect(find(symbols, 'Greet')?.doc).toBe('Greet returns a greeting.');
+    expect(find(symbols, 'Other')?.doc).toBeUndefined();
+    expect(find(symbols, 'Version')?.doc).toBe(' Version is the\n   release name.');
+  });
+
+  it('should collect references with local and import flags', () => {
+    const notFound = result.references.filter(r => r.symbolName === 'ErrNotFound');
+    expect(notFound).toHaveLength(1);
+    expect(notFound[0].containerName).toBe('Store.Get');
+
+    const itemRefs = result.references.filter(r => r.symbolName === 'item');
+    expect(itemRefs.length).toBeGreaterThan(0);
+    expect(itemRefs.every(r => r.isLocal)).toBe(true);
+
+    const yamlRef = result.references.find(r => r.symbolName === 'yaml');
+    expect(yamlRef?.isImport).toBe(true);
+
+    // Definition sites, keywords and predeclared identifiers are not references
+    expect(result.references.some(r => r.symbolName === 'NewStore')).toBe(false);
+    expect(result.references.some(r => r.symbolName === 'nil')).toBe(false);
+    expect(result.references.some(r => r.symbolName === 'return')).toBe(false);
+  });
+});
+
+describe('GoIndexer cgo and assembly declarations', () => {
+  const source = `package mathx
+
+import "C"
+
+// Add is implemented in add_amd64.s.
+func Add(a, b int64) int64
+
+//export GoCallback
+func GoCallback(value C.int) {
+}
+
+func helper() {}
+`;
+  const result = new GoIndexer().indexFile('/ws/mathx/mathx.go', source);
+
+  it('should mark functions declared without a body as external', () => {
+    expect(goMeta(find(result.symbols, 'Add')).external).toBe(true);
+    expect(goMeta(find(result.symbols, 'helper')).external).toBeUndefined();
+  });
+
+  it('should mark functions exported to C', () => {
+    const callback = find(result.symbols, 'GoCallback');
+    expect(goMeta(callback).cgoExport).toBe(true);
+    expect(goMeta(callback).external).toBeUndefined();
+    expect(callback?.doc).toBeUndefined();
+    expect(goMeta(find(result.symbols, 'Add')).cgoExport).toBeUndefined();
+  });
+});
+
+describe('GoIndexer test functions', () => {
+  const source = `package store_test
+
+import (
+	"testing"
+)
+
+func TestGet(t *testing.T) {}
+
+func BenchmarkGet(b *testing.B) {}
+
+func FuzzParse(f *testing.F) {}
+
+func ExampleStore_Get() {}
+
+func TestMain(m *testing.M) {}
+
+func Testify(t *testing.T) {}
+
+func TestHelper(t *testing.T, name string) {}
+`;
+
+  it('should classify tests, benchmarks, fuzz targets and examples in test files', () => {
+    const result = new GoIndexer().indexFile('/ws/store/store_test.go', source);
+    const kinds = result.symbols.filter(s => s.kind === 'function').map(s => [s.name, goMeta(s).testKind]);
+
+    expect(kinds).toEqual([
+      ['TestGet', 'test'],
+      ['BenchmarkGet', 'benchmark'],
+      ['FuzzParse', 'fuzz'],
+      ['ExampleStore_Get', 'example'],
+      ['TestMain', undefined],
+      ['Testify', undefined],
+      ['TestHelper', undefined]
+    ]);
+    expect(goMeta(find(new GoIndexer().indexFile('/ws/store/store.go', source).symbols, 'TestGet')).testKind).toBeUndefined();
+  });
+
+  it('should follow the testing package naming rules', () => {
+    expect(goTestKind('Test', 'func(t *testing.T)')).toBe('test');
+    expect(goTestKind('Test_parse', 'func(*testing.T)')).toBe('test');
+    expect(goTestKind('Example', 'func()')).toBe('example');
+    expect(goTestKind('Examples', 'func()')).toBeUndefined();
+    expect(goTestKind('ExampleGet', 'func() error')).toBeUndefined();
+    expect(goTestKind('BenchmarkGet', 'func(t *testing.T)')).toBeUndefined();
+  });
+});
+
+describe('GoIndexer closures', () => {
+  const source = `package main
+
+var handler = func(w http.ResponseWriter, r *http.Request) {
+	serve(w, r)
+}
+
+func (p *Person) Greet(greeting string) {
+	count := 0
+	var callbacks []func()
+	go func() {
+		count++
+		log(greeting, p.Name)
+	}()
+	defer func(prefix string) {
+		inner := func() { print(prefix, count) }
+		inner()
+	}(greeting)
+	_ = callbacks
+}
+`;
+  const result = new GoIndexer().indexFile('/ws/main.go', source);
+  const closures = result.symbols.filter(s => s.kind === 'closure');
+
+  it('should index function literals under synthesized names', () => {
+    expect(closures.map(s => [s.fullContainerPath, s.name, s.location.line, s.location.character])).toEqual([
+      ['main', 'handler$1', 2, 14],
+      ['main.Person', 'Greet$1', 9, 4],
+      ['main.Person', 'Greet$2', 13, 7],
+      ['main.Person', 'Greet$2$1', 14, 11]
+    ]);
+    const greet1 = closures[1];
+    expect(greet1).toMatchObject({ containerName: 'Person', containerKind: 'type', isExported: false, parametersCount: 0 });
+    expect(greet1.range).toMatchObject({ startLine: 9, endLine: 12 });
+    expect(closures[2].signature).toBe('func(prefix string)');
+  });
+
+  it('should record the variables closures capture', () => {
+    expect(closures.map(s => s.captures)).toEqual([
+      undefined,
+      ['count', 'greeting', 'p'],
+      ['count'],
+      ['prefix', 'count']
+    ]);
+  });
+
+  it('should attribute calls in package-level closures to them', () => {
+    const serve = result.references.find(r => r.symbolName === 'serve');
+    expect(serve).toMatchObject({ containerName: 'handler$1', isCall: true });
+    expect(result.references.some(r => r.symbolName === 'prefix' && r.location.line === 13)).toBe(false);
+  });
+});
+
+describe('GoIndexer error recovery', () => {
+  it('should report no diagnostics for valid files', () => {
+    expect(new GoIndexer().indexFile('/ws/store/store.go', goSource).diagnostics).toEqual([]);
+    expect(new GoIndexer().parse('/ws/store/store.go', goSource).diagnostics).toBeUndefined();
+  });
+
+  it('should index the declarations around an unclosed body', () => {
+    const source = [
+      'package broken',
+      '',
+      'func First(items []string) int {',
+      '\tfor _, item := range items {',
+      '\t\tif item == "" {',
+      '\t\t\treturn 0',
+      '\t}',
+      '\treturn len(items)',
+      '}',
+      '',
+      'type Config struct{ Name string }',
+      '',
+      'func (c *Config) Validate() error { return nil }',
+      ''
+    ].join('\n');
+    const result = new GoIndexer().indexFile('/ws/broken/broken.go', source);
+
+    expect(result.symbols.map(s => s.name)).toEqual(['First', 'Config', 'Name', 'Validate']);
+    expect(find(result.symbols, 'First')?.range.endLine).toBe(8);
+    expect(find(result.symbols, 'Validate')?.containerName).toBe('Config');
+    expect(result.diagnostics).toEqual([{ message: "Unclosed '{'", line: 2, character: 31 }]);
+    expect(result.references.some(r => r.symbolName === 'Config' && r.location.line === 12)).toBe(true);
+  });
+
+  it('should report unterminated literals, stray brackets and a missing package clause', () => {
+    const source = [
+      'import "fmt"',
+      '',
+      'func Hello() string {',
+      '\treturn "hello',
+      '}',
+      ')',
+      '',
+      'func Bye() {}',
+      ''
+    ].join('\n');
+    const result = new GoIndexer().indexFile('/ws/broken/hello.go', source);
+
+    expect(result.symbols.map(s => s.name)).toEqual(['Hello', 'Bye']);
+    expect(result.diagnostics).toEqual([
+      { message: "Expected 'package' clause", line: 0, character: 0 },
+      { message: 'Unterminated string literal', line: 3, character: 8 },
+      { message: "Unexpected ')'", line: 5, character: 0 }
+    ]);
+  });
+});
+
+describe('Go naming helpers', () => {
+  it('should derive package names from import paths', () => {
+    expect(goPackageNameFromPath('github.com/acme/api/v2')).toBe('api');
+    expect(goPackageNameFromPath('gopkg.in/yaml.v3')).toBe('yaml');
+    expect(goPackageNameFromPath('net/http')).toBe('http');
+  });
+
+  it('should detect exported identifiers', () => {
+    expect(isGoExported('Reader')).toBe(true);
+    expect(isGoExported('reader')).toBe(false);
+    expect(isGoExported('_x')).toBe(false);
+  });
+});
@@ -0,0 +1,1490 @@
+import { IndexedSymbol, IndexedReference, ImportInfo, ParseDiagnostic, TypeParameter } from '../types.js';
+import { createSymbolId } from './symbolResolver.js';
+import { cleanGoComment, setSymbolDoc } from '../utils/docComments.js';
+import { truncateValue } from '../utils/constantValues.js';
+import { evaluateGoConstant, formatGoConstant, GoConstValue } from './goConstants.js';
+import { fileBuildConstraint } from './goBuildConstraints.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+import {
+  GoTokenizer,
+  GoToken,
+  GoComment,
+  GO_KEYWORDS,
+  GO_PREDECLARED,
+  unquoteGoString
+} from './components/GoTokenizer.js';
+
+/**
+ * Go-specific symbol metadata, stored under `symbol.metadata.go`.
+ */
+export interface GoSymbolMetadata {
+  /** Package clause of the declaring file */
+  package?: string;
+  /** Receiver type name for methods (without pointer/type params) */
+  receiverType?: string;
+  /** True for pointer receivers: func (p *T) M() */
+  pointerReceiver?: boolean;
+  /** Declared type of fields, constants and variables */
+  type?: string;
+  /** True for embedded struct fields / embedded interfaces */
+  embedded?: boolean;
+  /** Embedded type names of a struct or interface */
+  embeds?: string[];
+  /** Struct field tag (unquoted) */
+  tag?: string;
+  /** Underlying type text for non-struct, non-interface type declarations */
+  underlying?: string;
+  /** True for alias declarations: type A = B */
+  alias?: boolean;
+  /** Owning module path (from go.mod or the module cache path) */
+  module?: string;
+  /** Owning module version, for dependencies */
+  moduleVersion?: string;
+  /** Package import path: module path + directory within the module */
+  importPath?: string;
+  /** True for symbols from the module cache or a vendor directory */
+  thirdParty?: boolean;
+  /** Build constraint of the declaring file (`linux && amd64`), if any */
+  buildConstraint?: string;
+  /** Functions declared without a body: implemented in assembly or linked in */
+  external?: boolean;
+  /** Functions exported to C with a cgo `//export` comment */
+  cgoExport?: boolean;
+  /** Symbols declared in a Go assembly (.s) file, see indexer/goAsmIndexer.ts */
+  assembly?: boolean;
+  /** Functions of _test.go files that `go test` runs, see goTestKind */
+  testKind?: GoTestKind;
+  /** Files generated by protoc-gen-go(-grpc): the `// source:` .proto file, see indexer/protoIndexer.ts */
+  protoSource?: string;
+  /** Labels of the Bazel targets that compile the file (`//pkg/api:api`), see indexer/bazelTargets.ts */
+  bazelTargets?: string[];
+}
+
+/** How `go test` runs a function: as a test, a benchmark, a fuzz target or an example */
+export type GoTestKind = 'test' | 'benchmark' | 'fuzz' | 'example';
+
+export const GO_TEST_KINDS: GoTestKind[] = ['test', 'benchmark', 'fuzz', 'example'];
+
+export interface GoIndexResult {
+  packageName?: string;
+  symbols: IndexedSymbol[];
+  references: IndexedReference[];
+  imports: ImportInfo[];
+  /** Syntax errors recovered from, in position order */
+  diagnostics: ParseDiagnostic[];
+}
+
+/**
+ * Context for emitting symbols of a single file.
+ */
+interface FileContext {
+  uri: string;
+  content: string;
+  tokenizer: GoTokenizer;
+  tokens: GoToken[];
+  comments: GoComment[];
+  /** Doc comment text by the (0-based) line its comment group ends on */
+  docComments: Map<number, string>;
+  packageName?: string;
+  /** Build constraint of the file (header and file name combined) */
+  buildConstraint?: string;
+  /** Function names listed in `//export` comments */
+  cgoExports: Set<string>;
+  /** The .proto file a protoc-generated file was generated from */
+  protoSource?: string;
+  symbols: IndexedSymbol[];
+  imports: ImportInfo[];
+  /** Offsets of identifier tokens that declare top-level symbols/members */
+  definitionOffsets: Set<number>;
+  /** Values of the constants evaluated so far, for later constant expressions */
+  constants: Map<string, GoConstValue>;
+  /** Function bodies (token index ranges) with their qualified names and local names */
+  bodies: Array<{ start: number; end: number; container: string; locals: Set<string> }>;
+}
+
+/**
+ * State of a const declaration: the spec's iota, and the last expression
+ * list and type, which specs without one repeat.
+ */
+interface ConstGroup {
+  iota: number;
+  expressions?: GoToken[][];
+  type?: string;
+}
+
+/**
+ * Token indices of a function literal: `func(...)` at the start, then
+ * the body from `{` up to bodyEnd (the index after `}`).
+ */
+interface FunctionLiteral {
+  paramsEnd: number;
+  body: number;
+  bodyEnd: number;
+}
+
+/**
+ * GoIndexer - Structural indexer for Go source files.
+ *
+ * Extracts without type-checking:
+ * - Types (struct, interface, defined and alias types) with fields,
+ *   interface methods and embedded types
+ * - Functions and methods (receiver type and pointer-ness) with their
+ *   signatures, and type parameters of generic functions and types
+ * - Function literals as closures, named after the enclosing declaration
+ *   (`Greet$1`) with the variables they capture from it
+ * - Package-level constants and variables, with constant values
+ *   (iota and constant expressions evaluated)
+ * - Import specs
+ * - Identifier references, with function parameters and short variable
+ *   declarations flagged as local, and call sites flagged as calls
+ * - Doc comments: the comment group on the lines right above a
+ *   declaration, spec or field
+ *
+ * Symbol names are package-qualified through fullContainerPath
+ * (`main.Person.Greet`), matching the TS indexer's container paths.
+ *
+ * Broken files are indexed as far as they can be read: unterminated
+ * literals, unbalanced brackets and a missing package clause are reported
+ * as diagnostics, and an unclosed body ends where the next function begins
+ * (see matchBrackets) instead of swallowing the rest of the file.
+ */
+export class GoIndexer implements LanguageParser {
+  readonly language = 'go';
+  readonly extensions = ['.go'];
+
+  /** Bracket ends of the file being indexed, see matchBrackets */
+  private brackets: { tokens: GoToken[]; ends: Int32Array } | undefined;
+
+  parse(uri: string, content: string): ParseResult {
+    const { symbols, references, imports, diagnostics } = this.indexFile(uri, content);
+    return diagnostics.length > 0 ? { symbols, references, imports, diagnostics } : { symbols, references, imports };
+  }
+
+  /**
+   * Index Go source content.
+   */
+  indexFile(uri: string, content: string): GoIndexResult {
+    const tokenizer = new GoTokenizer(content);
+    const { tokens, comments, errors } = tokenizer.tokenize();
+    const diagnostics: ParseDiagnostic[] = errors.map(error => ({ message: error.message, ...tokenizer.positionAt(error.offset) }));
+    if (tokens.length > 0 && tokens[0].text !== 'package') {
+      diagnostics.push({ message: "Expected 'package' clause", ...tokenizer.positionAt(tokens[0].offset) });
+    }
+    const ends = matchBrackets(tokens, tokenizer, diagnostics);
+
+    const ctx: FileContext = {
+      uri,
+      content,
+      tokenizer,
+      tokens,
+      comments,
+      docComments: collectDocComments(content, comments),
+      buildConstraint: fileBuildConstraint(uri, content),
+      cgoExports: collectCgoExports(comments),
+      protoSource: generatedProtoSource(comments, tokens[0]?.offset ?? content.length),
+      symbols: [],
+      imports: [],
+      definitionOffsets: new Set(),
+      constants: new Map(),
+      bodies: []
+    };
+
+    let references: IndexedReference[];
+    this.brackets = { tokens, ends };
+    try {
+      this.parseTopLevel(ctx);
+      this.resolveReceiverKinds(ctx);
+      references = this.collectReferences(ctx);
+    } finally {
+      this.brackets = undefined;
+    }
+
+    return {
+      packageName: ctx.packageName,
+      symbols: ctx.symbols,
+      references,
+      imports: ctx.imports,
+      diagnostics: diagnostics.sort((a, b) => a.line - b.line || a.character - b.character)
+    };
+  }
+
+  // ---------------------------------------------------------------------------
+  // Top-level declarations
+  // ---------------------------------------------------------------------------
+
+  private parseTopLevel(ctx: FileContext): void {
+    const tokens = ctx.tokens;
+    let i = 0;
+
+    while (i < tokens.length) {
+      const token = tokens[i];
+
+      if (token.type !== 'ident') {
+        i = token.text === '{' || token.text === '(' || token.text === '[' ? this.skipBalanced(tokens, i) : i + 1;
+        continue;
+      }
+
+      switch (token.text) {
+        case 'package':
+          if (tokens[i + 1]?.type === 'ident') {
+            ctx.packageName = tokens[i + 1].text;
+            i += 2;
+          } else {
+            i++;
+          }
+          break;
+        case 'import':
+          i = this.parseGroup(ctx, i + 1, (c, j) => this.parseImportSpec(c, j));
+          break;
+        case 'type':
+          i = this.parseGroup(ctx, i + 1, (c, j) => this.parseTypeSpec(c, j, token));
+          break;
+        case 'const': {
+          const group: ConstGroup = { iota: 0 };
+          i = this.parseGroup(ctx, i + 1, (c, j) => this.parseValueSpec(c, j, 'constant', token, group));
+          break;
+        }
+        case 'var':
+          i = this.parseGroup(ctx, i + 1, (c, j) => this.parseValueSpec(c, j, 'variable', token));
+          break;
+        case 'func':
+          i = this.parseFunc(ctx, i);
+          break;
+        default:
+          i++;
+      }
+    }
+  }
+
+  /**
+   * Parse either a single spec or a parenthesized group of specs.
+   * Returns the index after the declaration.
+   */
+  private parseGroup(
+    ctx: FileContext,
+    i: number,
+    parseSpec: (ctx: FileContext, i: number) => number
+  ): number {
+    const tokens = ctx.tokens;
+    if (tokens[i]?.text !== '(') {
+      return parseSpec(ctx, i);
+    }
+
+    const groupEnd = this.skipBalanced(tokens, i) - 1;
+    let j = i + 1;
+    while (j < groupEnd) {
+      if (tokens[j].text === ';') {
+        j++;
+        continue;
+      }
+      const next = parseSpec(ctx, j);
+      j = Math.min(Math.max(next, j + 1), groupEnd);
+    }
+    return groupEnd + 1;
+  }
+
+  private parseImportSpec(ctx: FileContext, i: number): number {
+    const tokens = ctx.tokens;
+    let alias: string | undefined;
+    let j = i;
+
+    if (tokens[j]?.type === 'ident' || tokens[j]?.text === '.') {
+      alias = tokens[j].text;
+      j++;
+    }
+
+    const literal = tokens[j];
+    if (!literal || literal.type !== 'string') {
+      return this.skipToSpecEnd(tokens, i);
+    }
+
+    const importPath = unquoteGoString(literal.text);
+    ctx.imports.push({
+      localName: alias ?? goPackageNameFromPath(importPath),
+      moduleSpecifier: importPath,
+      isNamespace: true
+    });
+
+    return j + 1;
+  }
+
+  private parseTypeSpec(ctx: FileContext, i: number, declToken: GoToken): number {
+    const tokens = ctx.tokens;
+    const nameToken = tokens[i];
+    if (!nameToken || nameToken.type !== 'ident') {
+      return this.skipToSpecEnd(tokens, i);
+    }
+
+    let j = i + 1;
+    // Type parameters: type List[T any] ... (but not array types: type A [4]int)
+    let typeParameters: TypeParameter[] | undefined;
+    if (tokens[j]?.text === '[' && this.isTypeParameterList(tokens, j)) {
+      typeParameters = this.parseTypeParameters(ctx, j);
+      j = this.skipBalanced(tokens, j);
+    }
+    const extra = typeParameters && { typeParameters };
+
+    let alias = false;
+    if (tokens[j]?.text === '=') {
+      alias = true;
+      j++;
+    }
+
+    const specStart = declToken.line === nameToken.line ? declToken : nameToken;
+    const typeToken = tokens[j];
+
+    if (typeToken?.text === 'struct' && tokens[j + 1]?.text === '{') {
+      const bodyEnd = this.skipBalanced(tokens, j + 1);
+      const symbol = this.pushSymbol(ctx, nameToken, 'struct', specStart, tokens[bodyEnd - 1], undefined, {
+        ...(alias && { alias })
+      }, extra);
+      const embeds = this.parseStructFields(ctx, j + 2, bodyEnd - 1, nameToken.text);
+      if (embeds.length > 0) {
+        this.goMetadata(symbol).embeds = embeds;
+      }
+      return bodyEnd;
+    }
+
+    if (typeToken?.text === 'interface' && tokens[j + 1]?.text === '{') {
+      const bodyEnd = this.skipBalanced(tokens, j + 1);
+      const symbol = this.pushSymbol(ctx, nameToken, 'interface', specStart, tokens[bodyEnd - 1], undefined, {
+        ...(alias && { alias })
+      }, extra);
+      const embeds = this.parseInterfaceMembers(ctx, j + 2, bodyEnd - 1, nameToken.text);
+      if (embeds.length > 0) {
+        this.goMetadata(symbol).embeds = embeds;
+      }
+      return bodyEnd;
+    }
+
+    const end = this.skipToSpecEnd(tokens, j);
+    const lastToken = tokens[Math.max(j, end - 1)] ?? nameToken;
+    this.pushSymbol(ctx, nameToken, 'type', specStart, lastToken, undefined, {
+      underlying: this.textBetween(ctx, tokens[j], lastToken),
+      ...(alias && { alias })
+    }, extra);
+    return end;
+  }
+
+  /**
+   * Parse struct fields between token indices [start, end).
+   * Returns the names of embedded types.
+   */
+  private parseStructFields(ctx: FileContext, start: number, end: number, structName: string): string[] {
+    const tokens = ctx.tokens;
+    const embeds: string[] = [];
+    let i = start;
+
+    while (i < end) {
+      if (tokens[i].text === ';') {
+        i++;
+        continue;
+      }
+
+      const lineEnd = Math.min(this.skipToSpecEnd(tokens, i), end);
+      const line = tokens.slice(i, lineEnd);
+      i = Math.max(lineEnd, i + 1);
+
+      const tagToken = line.length > 1 && line[line.length - 1].type === 'string' ? line.pop() : undefined;
+      const tag = tagToken ? unquoteGoString(tagToken.text) : undefined;
+
+      if (this.isEmbeddedField(line)) {
+        const typeName = embeddedTypeName(line);
+        if (!typeName) {
+          continue;
+        }
+        embeds.push(typeName.name);
+        this.pushSymbol(ctx, typeName.token, 'field', typeName.token, line[line.length - 1], structName, {
+          type: this.textBetween(ctx, line[0], line[line.length - 1]),
+          embedded: true,
+          ...(tag !== undefined && { tag })
+        });
+        continue;
+      }
+
+      // Named fields: A, B Type
+      const names: GoToken[] = [];
+      let k = 0;
+      while (k < line.length && line[k].type === 'ident') {
+        names.push(line[k]);
+        if (line[k + 1]?.text !== ',') {
+          k++;
+          break;
+        }
+        k += 2;
+      }
+      if (names.length === 0 || k >= line.length) {
+        continue;
+      }
+
+      const fieldType = this.textBetween(ctx, line[k], line[line.length - 1]);
+      for (const nameToken of names) {
+        this.pushSymbol(ctx, nameToken, 'field', nameToken, nameToken, structName, {
+          type: fieldType,
+          ...(tag !== undefined && { tag })
+        });
+      }
+    }
+
+    return embeds;
+  }
+
+  /**
+   * Parse interface methods and embedded interfaces between [start, end).
+   * Returns the names of embedded types (type-set terms included).
+   */
+  private parseInterfaceMembers(ctx: FileContext, start: number, end: number, interfaceName: string): string[] {
+    const tokens = ctx.tokens;
+    const embeds: string[] = [];
+    let i = start;
+
+    while (i < end) {
+      if (tokens[i].text === ';') {
+        i++;
+        continue;
+      }
+
+      const lineEnd = Math.min(this.skipToSpecEnd(tokens, i), end);
+      const first = tokens[i];
+
+      if (first.type === 'ident' && tokens[i + 1]?.text === '(' && i + 1 < lineEnd) {
+        const paramsEnd = this.skipBalanced(tokens, i + 1);
+        this.pushSymbol(ctx, first, 'method', first, tokens[lineEnd - 1], interfaceName, undefined, {
+          parametersCount: countParameters(tokens, i + 1, paramsEnd - 1),
+          containerKind: 'interface',
+          signature: 'func' + this.textBetween(ctx, tokens[i + 1], tokens[lineEnd - 1])
+        });
+        // Parameter and named result names are not references
+        const signatureNames = parameterNames(tokens, i + 1, paramsEnd - 1);
+        if (tokens[paramsEnd]?.text === '(') {
+          signatureNames.push(...parameterNames(tokens, paramsEnd, this.skipBalanced(tokens, paramsEnd) - 1));
+        }
+        for (const param of signatureNames) {
+          ctx.definitionOffsets.add(param.offset);
+        }
+      } else {
+        // Embedded interface or type-set term: io.Reader, ~int | ~string
+        for (let k = i; k < lineEnd; k++) {
+          const token = tokens[k];
+          if (token.type === 'ident' && tokens[k + 1]?.text !== '.' && !GO_PREDECLARED.has(token.text)) {
+            embeds.push(token.text);
+          }
+        }
+      }
+
+      i = Math.max(lineEnd, i + 1);
+    }
+
+    return embeds;
+  }
+
+  private parseValueSpec(
+    ctx: FileContext,
+    i: number,
+    kind: 'constant' | 'variable',
+    declToken: GoToken,
+    group?: ConstGroup
+  ): number {
+    const tokens = ctx.tokens;
+    const end = this.skipToSpecEnd(tokens, i);
+
+    const names: GoToken[] = [];
+    let j = i;
+    while (j < end && tokens[j].type === 'ident') {
+      names.push(tokens[j]);
+      if (tokens[j + 1]?.text !== ',') {
+        j++;
+        break;
+      }
+      j += 2;
+    }
+
+    let declaredType: string | undefined;
+    if (j < end && tokens[j].text !== '=') {
+      let typeEnd = j;
+      while (typeEnd < end && tokens[typeEnd].text !== '=') {
+        typeEnd = tokens[typeEnd].text === '(' || tokens[typeEnd].text === '[' || tokens[typeEnd].text === '{'
+          ? this.skipBalanced(tokens, typeEnd)
+          : typeEnd + 1;
+      }
+      declaredType = this.textBetween(ctx, tokens[j], tokens[Math.min(typeEnd, end) - 1]);
+      j = typeEnd;
+    }
+
+    let expressions = j < end && tokens[j].text === '=' ? this.splitExpressions(tokens, j + 1, end) : undefined;
+    if (group) {
+      // Implicit repetition: a const spec without values repeats the previous list and type
+      if (expressions) {
+        group.expressions = expressions;
+        group.type = declaredType;
+      } else {
+        expressions = group.expressions;
+        declaredType ??= group.type;
+      }
+    }
+
+    const lastToken = tokens[end - 1] ?? declToken;
+    const firstName = names.find(nameToken => nameToken.text !== '_');
+    names.forEach((nameToken, n) => {
+      if (nameToken.text === '_') {
+        return;
+      }
+      const value = group && expressions?.[n] ? this.constantValue(ctx, nameToken, expressions[n], group.iota) : undefined;
+      this.pushSymbol(ctx, nameToken, kind, nameToken, lastToken, undefined, {
+        ...(declaredType && { type: declaredType })
+      }, value !== undefined ? { value } : undefined);
+    });
+
+    if (group) {
+      group.iota++;
+    } else if (expressions && firstName) {
+      // var handler = func(w http.ResponseWriter, r *http.Request) {...}
+      this.parseClosures(ctx, j + 1, end, { name: firstName.text }, []);
+    }
+    return end;
+  }
+
+  /**
+   * Display value of a constant, recording the evaluated value for later
+   * specs: literals as written (`0x1F`, `"v1"`), other expressions
+   * evaluated (`1 << iota` -> `4`), or as written when they can't be
+   * evaluated (`5 * time.Second`).
+   */
+  private constantValue(ctx: FileContext, name: GoToken, expression: GoToken[], iota: number): string | undefined {
+    const evaluated = evaluateGoConstant(expression, ctx.constants, iota);
+    if (evaluated !== undefined) {
+      ctx.constants.set(name.text, evaluated);
+    }
+    if (expression.length === 1 && expression[0].type !== 'ident') {
+      return truncateValue(expression[0].text);
+    }
+    if (evaluated !== undefined) {
+      return truncateValue(formatGoConstant(evaluated));
+    }
+    // Repeated expressions mean something else for every iota
+    if (expression.some(token => token.text === 'iota')) {
+      return undefined;
+    }
+    return truncateValue(this.textBetween(ctx, expression[0], expression[expression.length - 1]));
+  }
+
+  /**
+   * Split an expression list (tokens[start..end)) at top-level commas.
+   */
+  private splitExpressions(tokens: GoToken[], start: number, end: number): GoToken[][] {
+    const expressions: GoToken[][] = [];
+    let current: GoToken[] = [];
+    let k = start;
+    while (k < end) {
+      const token = tokens[k];
+      if (token.text === ',') {
+        expressions.push(current);
+        current = [];
+        k++;
+      } else if (token.text === '(' || token.text === '[' || token.text === '{') {
+        const close = Math.min(this.skipBalanced(tokens, k), end);
+        current.push(...tokens.slice(k, close));
+        k = close;
+      } else {
+        current.push(token);
+        k++;
+      }
+    }
+    expressions.push(current);
+    return expressions.filter(expression => expression.length > 0);
+  }
+
+  private parseFunc(ctx: FileContext, i: number): number {
+    const tokens = ctx.tokens;
+    const funcToken = tokens[i];
+    let j = i + 1;
+
+    // Receiver
+    let receiverType: string | undefined;
+    let pointerReceiver = false;
+    const receiverLocals: string[] = [];
+    if (tokens[j]?.text === '(') {
+      const receiverEnd = this.skipBalanced(tokens, j);
+      const receiver = tokens.slice(j + 1, receiverEnd - 1);
+      pointerReceiver = receiver.some(t => t.text === '*');
+      const idents: string[] = [];
+      for (const t of receiver) {
+        if (t.text === '[') {
+          break;
+        }
+        if (t.type === 'ident') {
+          idents.push(t.text);
+        }
+      }
+      receiverType = idents[idents.length - 1];
+      const receiverName = receiver.find(t => t.type === 'ident');
+      if (idents.length > 1 && receiverName) {
+        receiverLocals.push(receiverName.text);
+        ctx.definitionOffsets.add(receiverName.offset);
+      }
+      j = receiverEnd;
+    }
+
+    const nameToken = tokens[j];
+    if (!nameToken || nameToken.type !== 'ident') {
+      // Function literal at top level (e.g. var x = func() {...} handled by caller) - skip
+      return j;
+    }
+    j++;
+
+    // Type parameters
+    let typeParameters: TypeParameter[] | undefined;
+    if (tokens[j]?.text === '[') {
+      typeParameters = this.parseTypeParameters(ctx, j);
+      j = this.skipBalanced(tokens, j);
+    }
+
+    if (tokens[j]?.text !== '(') {
+      return j;
+    }
+    const paramsStart = j;
+    const paramsEnd = this.skipBalanced(tokens, j);
+    const parametersCount = countParameters(tokens, paramsStart, paramsEnd - 1);
+    const locals = new Set<string>(receiverLocals);
+    this.declareParameters(ctx, parameterNames(tokens, paramsStart, paramsEnd - 1), locals);
+    j = paramsEnd;
+
+    // Results: parenthesized (may declare named results) or a single type
+    if (tokens[j]?.text === '(') {
+      const resultsEnd = this.skipBalanced(tokens, j);
+      this.declareParameters(ctx, parameterNames(tokens, j, resultsEnd - 1), locals);
+      j = resultsEnd;
+    }
+    while (j < tokens.length && tokens[j].text !== '{' && tokens[j].line === tokens[j - 1].line) {
+      j = tokens[j].text === '(' || tokens[j].text === '[' ? this.skipBalanced(tokens, j) : j + 1;
+    }
+
+    const signature = 'func' + this.textBetween(ctx, tokens[paramsStart], tokens[j - 1]);
+    let endToken = tokens[j - 1];
+    let next = j;
+    let scope: Set<string> | undefined;
+    if (tokens[j]?.text === '{') {
+      const bodyEnd = this.skipBalanced(tokens, j);
+      endToken = tokens[bodyEnd - 1];
+      next = bodyEnd;
+      const container = receiverType ? `${receiverType}.${nameToken.text}` : nameToken.text;
+      scope = new Set(locals);
+      this.collectBodyLocals(tokens, j + 1, bodyEnd - 1, scope, true);
+      this.collectBodyLocals(tokens, j + 1, bodyEnd - 1, locals);
+      ctx.bodies.push({ start: j + 1, end: bodyEnd - 1, container, locals });
+    }
+
+    if (receiverType) {
+      this.pushSymbol(ctx, nameToken, 'method', funcToken, endToken, receiverType, {
+        receiverType,
+        pointerReceiver
+      }, { parametersCount, signature });
+    } else {
+      const external = tokens[j]?.text !== '{';
+      const cgoExport = ctx.cgoExports.has(nameToken.text);
+      const testKind = ctx.uri.endsWith('_test.go') && !typeParameters ? goTestKind(nameToken.text, signature) : undefined;
+      const goMetadata: GoSymbolMetadata | undefined = external || cgoExport || testKind
+        ? { ...(external && { external: true }), ...(cgoExport && { cgoExport: true }), ...(testKind && { testKind }) }
+        : undefined;
+      this.pushSymbol(ctx, nameToken, 'function', funcToken, endToken, undefined, goMetadata, {
+        parametersCount,
+        signature,
+        ...(typeParameters && { typeParameters })
+      });
+    }
+
+    if (scope) {
+      this.parseClosures(ctx, j + 1, next - 1, { name: nameToken.text, containerName: receiverType }, [scope]);
+    }
+    return next;
+  }
+
+  /**
+   * Index the function literals in tokens [start, end) as closures, named
+   * after the enclosing declaration and numbered in source order:
+   * `Greet$1`, `Greet$2`, and `Greet$1$1` for one inside the first.
+   *
+   * `scopes` holds the names declared by the enclosing functions; those a
+   * literal uses, itself or through literals nested in it, are its
+   * captures. Without scopes (package-level variables) nothing is
+   * captured, and each literal gets a body of its own for references.
+   * Returns the names the literals use from `scopes`.
+   */
+  private parseClosures(
+    ctx: FileContext,
+    start: number,
+    end: number,
+    parent: { name: string; containerName?: string },
+    scopes: Array<Set<string>>
+  ): Set<string> {
+    const tokens = ctx.tokens;
+    const used = new Set<string>();
+    let count = 0;
+
+    for (let k = start; k < end; k++) {
+      const literal = this.functionLiteralAt(tokens, k);
+      if (!literal) {
+        continue;
+      }
+      const name = `${parent.name}$${++count}`;
+      const scope = new Set<string>();
+      this.declareParameters(ctx, parameterNames(tokens, k + 1, literal.paramsEnd - 1), scope);
+      if (tokens[literal.paramsEnd]?.text === '(') {
+        const resultsEnd = this.skipBalanced(tokens, literal.paramsEnd);
+        this.declareParameters(ctx, parameterNames(tokens, literal.paramsEnd, resultsEnd - 1), scope);
+      }
+      const bodyStart = literal.body + 1;
+      const bodyEnd = literal.bodyEnd - 1;
+      this.collectBodyLocals(tokens, bodyStart, bodyEnd, scope, true);
+      if (scopes.length === 0) {
+        const locals = new Set(scope);
+        this.collectBodyLocals(tokens, bodyStart, bodyEnd, locals);
+        ctx.bodies.push({ start: bodyStart, end: bodyEnd, container: name, locals });
+      }
+
+      const symbol = this.pushSymbol(ctx, tokens[k], 'closure', tokens[k], tokens[bodyEnd], parent.containerName, undefined, {
+        name,
+        parametersCount: countParameters(tokens, k + 1, literal.paramsEnd - 1),
+        signature: 'func' + this.textBetween(ctx, tokens[k + 1], tokens[literal.body - 1])
+      });
+      symbol.isExported = false;
+
+      const captures = this.capturedNames(tokens, bodyStart, bodyEnd, scope, scopes);
+      const nested = this.parseClosures(ctx, bodyStart, bodyEnd, { name, containerName: parent.containerName }, [...scopes, scope]);
+      for (const captured of nested) {
+        if (!scope.has(captured)) {
+          captures.add(captured);
+        }
+      }
+      if (captures.size > 0) {
+        symbol.captures = [...captures];
+        captures.forEach(captured => used.add(captured));
+      }
+      k = literal.bodyEnd - 1;
+    }
+
+    return used;
+  }
+
+  /**
+   * Names used in tokens [start, end), outside nested function literals,
+   * that are declared by an enclosing function rather than locally.
+   */
+  private capturedNames(
+    tokens: GoToken[],
+    start: number,
+    end: number,
+    locals: Set<string>,
+    scopes: Array<Set<string>>
+  ): Set<string> {
+    const captured = new Set<string>();
+    for (let k = start; k < end; k++) {
+      const literal = this.functionLiteralAt(tokens, k);
+      if (literal) {
+        k = literal.bodyEnd - 1;
+        continue;
+      }
+      const token = tokens[k];
+      if (token.type !== 'ident' || token.text === '_' || GO_KEYWORDS.has(token.text) ||
+          tokens[k - 1]?.text === '.' || locals.has(token.text)) {
+        continue;
+      }
+      // Keys of composite literals: Person{Name: name}
+      if (tokens[k + 1]?.text === ':' && (tokens[k - 1]?.text === '{' || tokens[k - 1]?.text === ',')) {
+        continue;
+      }
+      if (scopes.some(scope => scope.has(token.text))) {
+        captured.add(token.text);
+      }
+    }
+    return captured;
+  }
+
+  /**
+   * The function literal starting at tokens[k], if any: `func(params)
+   * results {`, with the body on the line of the signature. Function
+   * types (`var f func(int) error`, `[]func()`, `chan func()`) have no body.
+   */
+  private functionLiteralAt(tokens: GoToken[], k: number): FunctionLiteral | undefined {
+    const token = tokens[k];
+    if (token.text !== 'func' || token.type !== 'ident' || tokens[k + 1]?.text !== '(') {
+      return undefined;
+    }
+    const previous = tokens[k - 1]?.text;
+    if (previous === ']' || previous === 'chan' || previous === '*') {
+      return undefined;
+    }
+
+    const paramsEnd = this.skipBalanced(tokens, k + 1);
+    let j = paramsEnd;
+    while (j < tokens.length && tokens[j].text !== '{') {
+      const text = tokens[j].text;
+      if (tokens[j].line !== tokens[j - 1].line) {
+        return undefined;
+      }
+      if (text === '(' || text === '[') {
+        j = this.skipBalanced(tokens, j);
+      } else if ((text === 'interface' || text === 'struct') && tokens[j + 1]?.text === '{') {
+        j = this.skipBalanced(tokens, j + 1);
+      } else if (tokens[j].type === 'ident' || text === '.' || text === '*' || text === '<-') {
+        j++;
+      } else {
+        return undefined;
+      }
+    }
+    if (j >= tokens.length || tokens[j].line !== tokens[j - 1].line) {
+      return undefined;
+    }
+    return { paramsEnd, body: j, bodyEnd: this.skipBalanced(tokens, j) };
+  }
+
+  /**
+   * Register parameter names as locals; their declaration sites are not references.
+   */
+  private declareParameters(ctx: FileContext, params: GoToken[], locals: Set<string>): void {
+    for (const param of params) {
+      locals.add(param.text);
+      ctx.definitionOffsets.add(param.offset);
+    }
+  }
+
+  /**
+   * Collect names declared inside a function body (short variable
+   * declarations, var/const statements, closure parameters). With
+   * ownScope, function literals and the names declared in them are skipped.
+   */
+  private collectBodyLocals(tokens: GoToken[], start: number, end: number, locals: Set<string>, ownScope = false): void {
+    for (let k = start; k < end; k++) {
+      const token = tokens[k];
+      if (ownScope) {
+        const literal = this.functionLiteralAt(tokens, k);
+        if (literal) {
+          k = literal.bodyEnd - 1;
+          continue;
+        }
+      }
+
+      if (token.text === ':=') {
+        // a, b := ...  (walk back over "ident (, ident)*")
+        let b = k - 1;
+        while (b >= start && tokens[b].type === 'ident') {
+          locals.add(tokens[b].text);
+          if (tokens[b - 1]?.text !== ',') {
+            break;
+          }
+          b -= 2;
+        }
+      } else if ((token.text === 'var' || token.text === 'const') && tokens[k + 1]?.type === 'ident') {
+        let b = k + 1;
+        while (b < end && tokens[b].type === 'ident') {
+          locals.add(tokens[b].text);
+          if (tokens[b + 1]?.text !== ',') {
+            break;
+          }
+          b += 2;
+        }
+      } else if (token.text === 'func' && tokens[k + 1]?.text === '(') {
+        const paramsEnd = this.skipBalanced(tokens, k + 1);
+        for (const param of parameterNames(tokens, k + 1, paramsEnd - 1)) {
+          locals.add(param.text);
+        }
+      }
+    }
+  }
+
+  // ---------------------------------------------------------------------------
+  // References
+  // ---------------------------------------------------------------------------
+
+  private collectReferences(ctx: FileContext): IndexedReference[] {
+    const references: IndexedReference[] = [];
+    const tokens = ctx.tokens;
+    const importNames = new Set(ctx.imports.map(imp => imp.localName));
+    let bodyIndex = 0;
+    let skipUntil = -1;
+
+    for (let k = 0; k < tokens.length; k++) {
+      const token = tokens[k];
+
+      // Skip package/import clauses
+      if (token.text === 'package' || token.text === 'import') {
+        skipUntil = tokens[k + 1]?.text === '(' ? this.skipBalanced(tokens, k + 1) : k + 2;
+      }
+      if (k < skipUntil || token.type !== 'ident') {
+        continue;
+      }
+      if (GO_KEYWORDS.has(token.text) || GO_PREDECLARED.has(token.text) || token.text === '_') {
+        continue;
+      }
+      if (ctx.definitionOffsets.has(token.offset)) {
+        continue;
+      }
+
+      while (bodyIndex < ctx.bodies.length && ctx.bodies[bodyIndex].end <= k) {
+        bodyIndex++;
+      }
+      const body = ctx.bodies[bodyIndex];
+      const inBody = body !== undefined && k >= body.start && k < body.end;
+      const isSelector = tokens[k - 1]?.text === '.';
+      const isLocal = inBody && !isSelector && body.locals.has(token.text);
+
+      const position = ctx.tokenizer.positionAt(token.offset);
+      references.push({
+        symbolName: token.text,
+        location: { uri: ctx.uri, line: position.line, character: position.character },
+        range: {
+          startLine: position.line,
+          startCharacter: position.character,
+          endLine: position.line,
+          endCharacter: position.character + token.text.length
+        },
+        containerName: inBody ? body.container : undefined,
+        isImport: !isSelector && importNames.has(token.text) && tokens[k + 1]?.text === '.' ? true : undefined,
+        isLocal: isLocal || undefined,
+        // Conversions like T(x) look the same as calls without type information
+        isCall: inBody && tokens[k + 1]?.text === '(' ? true : undefined
+      });
+    }
+
+    return references;
+  }
+
+  // ---------------------------------------------------------------------------
+  // Helpers
+  // ---------------------------------------------------------------------------
+
+  /**
+   * Create and store a symbol. The name token is recorded as a definition site.
+   */
+  private pushSymbol(
+    ctx: FileContext,
+    nameToken: GoToken,
+    kind: string,
+    startToken: GoToken,
+    endToken: GoToken,
+    containerName: string | undefined,
+    goMetadata?: GoSymbolMetadata,
+    extra?: {
+      /** Name when the symbol has none in the source (closures) */
+      name?: string;
+      parametersCount?: number;
+      containerKind?: string;
+      signature?: string;
+      typeParameters?: TypeParameter[];
+      value?: string;
+    }
+  ): IndexedSymbol {
+    const name = extra?.name ?? nameToken.text;
+    const location = ctx.tokenizer.positionAt(nameToken.offset);
+    const start = ctx.tokenizer.positionAt(startToken.offset);
+    const end = ctx.tokenizer.positionAt(endToken.end);
+    const fullContainerPath = [ctx.packageName, containerName].filter(Boolean).join('.') || undefined;
+
+    const id = createSymbolId(
+      ctx.uri,
+      name,
+      containerName,
+      fullContainerPath,
+      kind,
+      false,
+      extra?.parametersCount,
+      location.line,
+      location.character
+    );
+
+    const metadata: GoSymbolMetadata = {
+      ...(ctx.packageName && { package: ctx.packageName }),
+      ...(ctx.buildConstraint && { buildConstraint: ctx.buildConstraint }),
+      ...(ctx.protoSource && { protoSource: ctx.protoSource }),
+      ...goMetadata
+    };
+
+    const symbol: IndexedSymbol = {
+      id,
+      name,
+      kind,
+      location: { uri: ctx.uri, line: location.line, character: location.character },
+      range: {
+        startLine: start.line,
+        startCharacter: start.character,
+        endLine: end.line,
+        endCharacter: end.character
+      },
+      containerName,
+      containerKind: containerName ? (extra?.containerKind ?? 'struct') : undefined,
+      fullContainerPath,
+      filePath: ctx.uri,
+      parametersCount: extra?.parametersCount,
+      metadata: { go: metadata },
+      isDefinition: true,
+      isExported: isGoExported(name)
+    };
+    if (extra?.signature) {
+      symbol.signature = extra.signature;
+    }
+    if (extra?.typeParameters && extra.typeParameters.length > 0) {
+      symbol.typeParameters = extra.typeParameters;
+    }
+    if (extra?.value !== undefined) {
+      symbol.value = extra.value;
+    }
+    const doc = ctx.docComments.get(startToken.line - 1);
+    if (doc) {
+      setSymbolDoc(symbol, doc, 'go');
+    }
+
+    ctx.symbols.push(symbol);
+    ctx.definitionOffsets.add(nameToken.offset);
+    return symbol;
+  }
+
+  private goMetadata(symbol: IndexedSymbol): GoSymbolMetadata {
+    return symbol.metadata!.go as GoSymbolMetadata;
+  }
+
+  /**
+   * Method receivers may be declared in another file of the package.
+   * When the receiver type is declared here, use its real kind as containerKind.
+   */
+  private resolveReceiverKinds(ctx: FileContext): void {
+    const typeKinds = new Map<string, string>();
+    for (const symbol of ctx.symbols) {
+      if (!symbol.containerName && (symbol.kind === 'struct' || symbol.kind === 'interface' || symbol.kind === 'type')) {
+        typeKinds.set(symbol.name, symbol.kind);
+      }
+    }
+
+    for (const symbol of ctx.symbols) {
+      if ((symbol.kind === 'method' || symbol.kind === 'closure') && symbol.containerKind !== 'interface' && symbol.containerName) {
+        symbol.containerKind = typeKinds.get(symbol.containerName) ?? 'type';
+      }
+    }
+  }
+
+  /**
+   * Parse a type parameter list starting at '[': `[K comparable, V any]`,
+   * `[S ~[]E, E any]`; `[A, B any]` gives both names the constraint.
+   */
+  private parseTypeParameters(ctx: FileContext, open: number): TypeParameter[] {
+    const tokens = ctx.tokens;
+    const close = this.skipBalanced(tokens, open) - 1;
+    const parameters: TypeParameter[] = [];
+    let pending: TypeParameter[] = [];
+    let k = open + 1;
+
+    while (k < close) {
+      let end = k;
+      while (end < close && tokens[end].text !== ',') {
+        end = tokens[end].text === '(' || tokens[end].text === '[' || tokens[end].text === '{'
+          ? this.skipBalanced(tokens, end)
+          : end + 1;
+      }
+      const name = tokens[k];
+      if (name.type === 'ident') {
+        const parameter: TypeParameter = { name: name.text };
+        parameters.push(parameter);
+        pending.push(parameter);
+        ctx.definitionOffsets.add(name.offset);
+        if (end > k + 1) {
+          const constraint = this.textBetween(ctx, tokens[k + 1], tokens[end - 1]);
+          for (const shared of pending) {
+            shared.constraint = constraint;
+          }
+          pending = [];
+        }
+      }
+      k = end + 1;
+    }
+    return parameters;
+  }
+
+  private isTypeParameterList(tokens: GoToken[], bracketIndex: number): boolean {
+    const first = tokens[bracketIndex + 1];
+    const second = tokens[bracketIndex + 2];
+    return first?.type === 'ident' && second !== undefined && second.text !== ']';
+  }
+
+  private isEmbeddedField(line: GoToken[]): boolean {
+    if (line.length === 0) {
+      return false;
+    }
+    if (line[0].text === '*') {
+      return true;
+    }
+    if (line[0].type !== 'ident') {
+      return false;
+    }
+    if (line.length === 1) {
+      return true;
+    }
+    // pkg.Type or Generic[T] (no space before '[')
+    return line[1].text === '.' || (line[1].text === '[' && line[1].offset === line[0].end);
+  }
+
+  private textBetween(ctx: FileContext, first: GoToken | undefined, last: GoToken | undefined): string {
+    if (!first || !last || last.end < first.offset) {
+      return '';
+    }
+    return ctx.content.slice(first.offset, last.end).replace(/\s+/g, ' ').trim();
+  }
+
+  /**
+   * Given an opening bracket at index i, return the index after its match.
+   */
+  private skipBalanced(tokens: GoToken[], i: number): number {
+    const end = this.brackets?.tokens === tokens ? this.brackets.ends[i] : 0;
+    if (end > 0) {
+      return end;
+    }
+    let depth = 0;
+    for (let k = i; k < tokens.length; k++) {
+      const text = tokens[k].text;
+      if (tokens[k].type !== 'punct') {
+        continue;
+      }
+      if (text === '(' || text === '[' || text === '{') {
+        depth++;
+      } else if (text === ')' || text === ']' || text === '}') {
+        depth--;
+        if (depth === 0) {
+          return k + 1;
+        }
+      }
+    }
+    return tokens.length;
+  }
+
+  /**
+   * Find the end of a spec/statement starting at i: a newline or ';' at
+   * bracket depth 0, or a closing bracket that belongs to the enclosing group.
+   */
+  private skipToSpecEnd(tokens: GoToken[], i: number): number {
+    let k = i;
+    while (k < tokens.length) {
+      const token = tokens[k];
+      if (token.type === 'punct') {
+        if (token.text === ';') {
+          return k;
+        }
+        if (token.text === ')' || token.text === ']' || token.text === '}') {
+          return k;
+        }
+        if (token.text === '(' || token.text === '[' || token.text === '{') {
+          k = this.skipBalanced(tokens, k);
+          if (k < tokens.length && tokens[k].line !== tokens[k - 1].line && !continuesLine(tokens[k - 1])) {
+            return k;
+          }
+          continue;
+        }
+      }
+
+      const next = tokens[k + 1];
+      if (!next || (next.line !== token.line && !continuesLine(token))) {
+        return k + 1;
+      }
+      k++;
+    }
+    return tokens.length;
+  }
+}
+
+/**
+ * Names exported to C: `//export Name` directly above `func Name`.
+ */
+function collectCgoExports(comments: GoComment[]): Set<string> {
+  const exports = new Set<string>();
+  for (const comment of comments) {
+    const match = /^\/\/export\s+([\p{L}_][\p{L}\p{N}_]*)\s*$/u.exec(comment.text);
+    if (match) {
+      exports.add(match[1]);
+    }
+  }
+  return exports;
+}
+
+/**
+ * The .proto file of a protoc-gen-go or protoc-gen-go-grpc output: the
+ * `// source: greet/v1/greet.proto` line of its header.
+ */
+function generatedProtoSource(comments: GoComment[], packageOffset: number): string | undefined {
+  const header = comments.filter(comment => comment.offset < packageOffset).map(comment => comment.text);
+  if (!header.some(text => /^\/\/ Code generated by protoc-gen-go(-grpc)?\b/.test(text))) {
+    return undefined;
+  }
+  for (const text of header) {
+    const match = /^\/\/ source: (\S+\.proto)$/.exec(text);
+    if (match) {
+      return match[1];
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Group comments that sit on their own lines: consecutive `//` lines form
+ * one group, a `/* *\/` block is a group of its own.
+ */
+function collectDocComments(content: string, comments: GoComment[]): Map<number, string> {
+  const docs = new Map<number, string>();
+  let group: GoComment[] = [];
+
+  const flush = () => {
+    if (group.length > 0) {
+      const text = cleanGoComment(group.map(comment => comment.text));
+      if (text) {
+        docs.set(group[group.length - 1].endLine, text);
+      }
+      group = [];
+    }
+  };
+
+  for (const comment of comments) {
+    const lineStart = content.lastIndexOf('\n', comment.offset - 1) + 1;
+    const lineEnd = content.indexOf('\n', comment.end);
+    const ownLine = !content.slice(lineStart, comment.offset).trim() &&
+      !content.slice(comment.end, lineEnd === -1 ? content.length : lineEnd).trim();
+    const isLineComment = comment.text.startsWith('//');
+    const previous = group[group.length - 1];
+    if (!ownLine || !isLineComment || !previous?.text.startsWith('//') || previous.endLine + 1 !== comment.line) {
+      flush();
+    }
+    if (ownLine) {
+      group.push(comment);
+      if (!isLineComment) {
+        flush();
+      }
+    }
+  }
+  flush();
+  return docs;
+}
+
+const OPENING_BRACKETS: Record<string, string> = { ')': '(', ']': '[', '}': '{' };
+
+/**
+ * Match brackets, recovering from unbalanced ones. Returns, per opening
+ * bracket token, the index after its range (0 for other tokens), and
+ * reports each bracket it had to recover from.
+ *
+ * A closing bracket closes the open brackets above its match; one without
+ * a match is ignored. A named function or method at the start of a line
+ * cannot be inside a body, and gofmt indents type declarations inside
+ * bodies, so either closes every open bracket: an unclosed body ends there
+ * instead of swallowing the rest of the file.
+ */
+function matchBrackets(tokens: GoToken[], tokenizer: GoTokenizer, diagnostics: ParseDiagnostic[]): Int32Array {
+  const ends = new Int32Array(tokens.length);
+  const open: number[] = [];
+  const closeUnclosed = (end: number) => {
+    const index = open.pop()!;
+    ends[index] = end;
+    diagnostics.push({ message: `Unclosed '${tokens[index].text}'`, ...tokenizer.positionAt(tokens[index].offset) });
+  };
+
+  for (let k = 0; k < tokens.length; k++) {
+    const token = tokens[k];
+    if (open.length > 0 && startsDeclaration(tokens, k, tokenizer)) {
+      while (open.length > 0) {
+        closeUnclosed(k);
+      }
+    }
+    if (token.type !== 'punct') {
+      continue;
+    }
+    if (token.text === '(' || token.text === '[' || token.text === '{') {
+      open.push(k);
+      continue;
+    }
+    const opening = OPENING_BRACKETS[token.text];
+    if (!opening) {
+      continue;
+    }
+    let match = open.length - 1;
+    while (match >= 0 && tokens[open[match]].text !== opening) {
+      match--;
+    }
+    if (match < 0) {
+      diagnostics.push({ message: `Unexpected '${token.text}'`, ...tokenizer.positionAt(token.offset) });
+      continue;
+    }
+    while (open.length - 1 > match) {
+      closeUnclosed(k);
+    }
+    ends[open.pop()!] = k + 1;
+  }
+  while (open.length > 0) {
+    closeUnclosed(tokens.length);
+  }
+  return ends;
+}
+
+/**
+ * `func Name`, `func (r T) Name` or `type Name` at the start of a line.
+ * Closures (`func(`, `func (x int) {`) do not count.
+ */
+function startsDeclaration(tokens: GoToken[], k: number, tokenizer: GoTokenizer): boolean {
+  const token = tokens[k];
+  if ((token.text !== 'func' && token.text !== 'type') || token.type !== 'ident' ||
+      tokenizer.positionAt(token.offset).character !== 0) {
+    return false;
+  }
+  const next = tokens[k + 1];
+  if (next?.type === 'ident') {
+    return next.line === token.line;
+  }
+  if (token.text === 'type' || next?.text !== '(') {
+    return false;
+  }
+  if (next?.text !== '(') {
+    return false;
+  }
+  // Receiver: a few tokens, then the method name right after ')'
+  for (let j = k + 2; j < Math.min(tokens.length, k + 10); j++) {
+    if (tokens[j].text === ')') {
+      return tokens[j + 1]?.type === 'ident' && tokens[j + 1].line === tokens[j].line;
+    }
+  }
+  return false;
+}
+
+/**
+ * True if a line ending with this token continues on the next line
+ * (Go inserts no semicolon after binary operators, commas, or opening brackets).
+ */
+function continuesLine(token: GoToken): boolean {
+  if (token.type !== 'punct') {
+    return false;
+  }
+  return !(token.text === ')' || token.text === ']' || token.text === '}' || token.text === '++' || token.text === '--');
+}
+
+/**
+ * Count parameters in a parameter list (open paren at start, close paren at end).
+ */
+function countParameters(tokens: GoToken[], start: number, end: number): number {
+  if (end <= start + 1) {
+    return 0;
+  }
+  let depth = 0;
+  let count = 1;
+  for (let k = start + 1; k < end; k++) {
+    const text = tokens[k].text;
+    if (text === '(' || text === '[' || text === '{') {
+      depth++;
+    } else if (text === ')' || text === ']' || text === '}') {
+      depth--;
+    } else if (text === ',' && depth === 0) {
+      count++;
+    }
+  }
+  // Trailing comma: f(a int,\n)
+  if (tokens[end - 1]?.text === ',') {
+    count--;
+  }
+  return count;
+}
+
+/**
+ * Extract declared parameter name tokens from a parameter list.
+ * Go lists are either all named or all unnamed, so a list is considered
+ * named if any comma-separated group starts with "ident <type>".
+ */
+function parameterNames(tokens: GoToken[], start: number, end: number): GoToken[] {
+  const groups: GoToken[][] = [];
+  let current: GoToken[] = [];
+  let depth = 0;
+
+  for (let k = start + 1; k < end; k++) {
+    const token = tokens[k];
+    if (token.text === '(' || token.text === '[' || token.text === '{') {
+      depth++;
+    } else if (token.text === ')' || token.text === ']' || token.text === '}') {
+      depth--;
+    }
+    if (token.text === ',' && depth === 0) {
+      groups.push(current);
+      current = [];
+      continue;
+    }
+    current.push(token);
+  }
+  if (current.length > 0) {
+    groups.push(current);
+  }
+
+  // "pkg.Type" and "Generic[T]" (no space before '[') are unnamed types, "xs []int" is named
+  const isNamed = groups.some(group =>
+    group.length >= 2 &&
+    group[0].type === 'ident' &&
+    group[1].text !== '.' &&
+    !(group[1].text === '[' && group[1].offset === group[0].end)
+  );
+  if (!isNamed) {
+    return [];
+  }
+
+  return groups
+    .map(group => group[0])
+    .filter(token => token?.type === 'ident' && token.text !== '_');
+}
+
+/**
+ * Resolve the type name of an embedded field: *pkg.Type[T] -> Type.
+ */
+function embeddedTypeName(line: GoToken[]): { name: string; token: GoToken } | null {
+  let last: GoToken | null = null;
+  for (const token of line) {
+    if (token.text === '[') {
+      break;
+    }
+    if (token.type === 'ident') {
+      last = token;
+    }
+  }
+  return last ? { name: last.text, token: last } : null;
+}
+
+/**
+ * What `go test` runs a top-level function of a _test.go file as, by the
+ * testing package's rules: `TestXxx(t *testing.T)`, `BenchmarkXxx(b
+ * *testing.B)`, `FuzzXxx(f *testing.F)` and `ExampleXxx()`, where Xxx
+ * does not start with a lower-case letter. TestMain and functions with
+ * other signatures are not run.
+ */
+export function goTestKind(name: string, signature: string | undefined): GoTestKind | undefined {
+  const compact = signature?.replace(/\s+/g, '') ?? '';
+  const prefix = /^(Test|Benchmark|Fuzz|Example)(?![a-z])/.exec(name)?.[1];
+  const parameter = /^func\((?:\w+)?\*\w+\.([TBF])\)$/.exec(compact)?.[1];
+  switch (prefix) {
+    case 'Test':
+      return parameter === 'T' && name !== 'TestMain' ? 'test' : undefined;
+    case 'Benchmark':
+      return parameter === 'B' ? 'benchmark' : undefined;
+    case 'Fuzz':
+      return parameter === 'F' ? 'fuzz' : undefined;
+    case 'Example':
+      return compact === 'func()' ? 'example' : undefined;
+    default:
+      return undefined;
+  }
+}
+
+/**
+ * Exported identifiers start with an upper-case letter.
+ */
+export function isGoExported(name: string): boolean {
+  return /^\p{Lu}/u.test(name);
+}
+
+/**
+ * Default package name for an import path: last segment, skipping
+ * major-version suffixes (example.com/mod/v2 -> mod) and gopkg.in suffixes.
+ */
+export function goPackageNameFromPath(importPath: string): string {
+  const segments = importPath.split('/').filter(Boolean);
+  let name = segments[segments.length - 1] || importPath;
+  if (/^v\d+$/.test(name) && segments.length > 1) {
+    name = segments[segments.length - 2];
+  }
+  return name.replace(/\.v\d+$/, '').replace(/^go-/, '').replace(/[^\p{L}\p{N}_]/gu, '_');
+}
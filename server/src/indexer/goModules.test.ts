@@ -0,0 +1,140 @@
+/**
+ * Go Module Tests
+ *
+ * go.mod parsing, module ownership of workspace/vendored/module-cache files,
+ * and dependency file collection.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  parseGoMod,
+  escapeModulePath,
+  unescapeModulePath,
+  GoModuleResolver,
+  annotateGoModule,
+  collectGoDependencyFiles
+} from './goModules.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+const goMod = `module github.com/acme/api // main module
+
+go 1.22
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	golang.org/x/sync v0.6.0 // indirect
+	github.com/acme/shared v0.1.0
+)
+
+require github.com/google/uuid v1.6.0
+
+replace github.com/acme/shared => ../shared
+`;
+
+function write(file: string, content: string): void {
+  fs.mkdirSync(path.dirname(file), { recursive: true });
+  fs.writeFileSync(file, content);
+}
+
+describe('parseGoMod', () => {
+  it('should read module, go version, requirements and replacements', () => {
+    const parsed = parseGoMod(goMod);
+
+    expect(parsed.module).toBe('github.com/acme/api');
+    expect(parsed.goVersion).toBe('1.22');
+    expect(parsed.require).toEqual([
+      { path: 'github.com/BurntSushi/toml', version: 'v1.3.2', indirect: false },
+      { path: 'golang.org/x/sync', version: 'v0.6.0', indirect: true },
+      { path: 'github.com/acme/shared', version: 'v0.1.0', indirect: false },
+      { path: 'github.com/google/uuid', version: 'v1.6.0', indirect: false }
+    ]);
+    expect(parsed.replace).toEqual([
+      { oldPath: 'github.com/acme/shared', oldVersion: undefined, newPath: '../shared', newVersion: undefined }
+    ]);
+  });
+
+  it('should round-trip module cache path escaping', () => {
+    expect(escapeModulePath('github.com/BurntSushi/toml')).toBe('github.com/!burnt!sushi/toml');
+    expect(unescapeModulePath('github.com/!burnt!sushi/toml')).toBe('github.com/BurntSushi/toml');
+  });
+});
+
+describe('GoModuleResolver', () => {
+  let root: string;
+  let workspace: string;
+  let modCache: string;
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'go-modules-'));
+    workspace = path.join(root, 'ws', 'api');
+    modCache = path.join(root, 'gopath', 'pkg', 'mod');
+
+    write(path.join(workspace, 'go.mod'), goMod);
+    write(path.join(workspace, 'internal', 'users', 'store.go'), 'package users\n');
+    write(path.join(workspace, 'vendor', 'github.com', 'google', 'uuid', 'uuid.go'), 'package uuid\n');
+    write(path.join(root, 'ws', 'shared', 'go.mod'), 'module github.com/acme/shared\n');
+    write(path.join(root, 'ws', 'shared', 'shared.go'), 'package shared\n');
+
+    const toml = path.join(modCache, 'github.com', '!burnt!sushi', 'toml@v1.3.2');
+    write(path.join(toml, 'go.mod'), 'module github.com/BurntSushi/toml\n');
+    write(path.join(toml, 'decode.go'), 'package toml\n');
+    write(path.join(toml, 'decode_test.go'), 'package toml\n');
+    write(path.join(toml, 'internal', 'tz.go'), 'package internal\n');
+    write(path.join(toml, 'testdata', 'x.go'), 'package x\n');
+    write(path.join(toml, 'cmd', 'tomlv', 'go.mod'), 'module github.com/BurntSushi/toml/cmd/tomlv\n');
+    write(path.join(toml, 'cmd', 'tomlv', 'main.go'), 'package main\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should resolve workspace, vendored and module cache files', () => {
+    const resolver = new GoModuleResolver(modCache);
+
+    expect(resolver.resolve(path.join(workspace, 'internal', 'users', 'store.go'))).toEqual({
+      module: 'github.com/acme/api', dir: workspace, thirdParty: false
+    });
+    expect(resolver.resolve(path.join(workspace, 'vendor', 'github.com', 'google', 'uuid', 'uuid.go'))).toMatchObject({
+      module: 'github.com/google/uuid', version: 'v1.6.0', thirdParty: true
+    });
+    expect(resolver.resolve(path.join(modCache, 'github.com', '!burnt!sushi', 'toml@v1.3.2', 'internal', 'tz.go'))).toEqual({
+      module: 'github.com/BurntSushi/toml',
+      version: 'v1.3.2',
+      dir: path.join(modCache, 'github.com', '!burnt!sushi', 'toml@v1.3.2'),
+      thirdParty: true
+    });
+  });
+
+  it('should record module, version and import path on symbols', () => {
+    const resolver = new GoModuleResolver(modCache);
+    const file = path.join(modCache, 'github.com', '!burnt!sushi', 'toml@v1.3.2', 'internal', 'tz.go');
+    const symbol = createTestSymbol({ name: 'Zone', metadata: { go: { package: 'internal' } } });
+
+    annotateGoModule([symbol], file, resolver.resolve(file));
+
+    expect(symbol.metadata!.go).toEqual({
+      package: 'internal',
+      module: 'github.com/BurntSushi/toml',
+      moduleVersion: 'v1.3.2',
+      importPath: 'github.com/BurntSushi/toml/internal',
+      thirdParty: true
+    });
+  });
+
+  it('should collect dependency sources, skipping tests, testdata and nested modules', async () => {
+    const resolver = new GoModuleResolver(modCache);
+    const files = await collectGoDependencyFiles([path.join(workspace, 'internal', 'users', 'store.go')], resolver);
+
+    const toml = path.join(modCache, 'github.com', '!burnt!sushi', 'toml@v1.3.2');
+    expect(files.sort()).toEqual([
+      path.join(toml, 'decode.go'),
+      path.join(toml, 'internal', 'tz.go'),
+      // Local replacement of github.com/acme/shared
+      path.join(root, 'ws', 'shared', 'shared.go')
+    ].sort());
+  });
+});
@@ -0,0 +1,390 @@
+import * as fs from 'fs';
+import * as fsPromises from 'fs/promises';
+import * as os from 'os';
+import * as path from 'path';
+import { IndexedSymbol } from '../types.js';
+import { GoSymbolMetadata } from './goIndexer.js';
+
+/**
+ * Parsed go.mod file (the directives the indexer needs).
+ */
+export interface GoModFile {
+  module: string;
+  goVersion?: string;
+  require: Array<{ path: string; version: string; indirect: boolean }>;
+  replace: Array<{ oldPath: string; oldVersion?: string; newPath: string; newVersion?: string }>;
+}
+
+/**
+ * The module a file belongs to.
+ */
+export interface GoModuleInfo {
+  /** Module path, e.g. `github.com/acme/api` */
+  module: string;
+  /** Version for dependencies (from the module cache path or go.mod require) */
+  version?: string;
+  /** Module root directory */
+  dir: string;
+  /** True for files in the module cache or a vendor directory */
+  thirdParty: boolean;
+}
+
+/**
+ * A dependency module to index, resolved to a directory on disk.
+ */
+export interface GoDependency {
+  module: string;
+  version?: string;
+  dir: string;
+}
+
+/**
+ * Parse a go.mod file. Unknown directives are ignored; block and
+ * single-line forms of `require`/`replace` are both accepted.
+ */
+export function parseGoMod(content: string): GoModFile {
+  const result: GoModFile = { module: '', require: [], replace: [] };
+  let block: string | null = null;
+
+  for (const rawLine of content.split('\n')) {
+    const indirect = /\/\/\s*indirect\b/.test(rawLine);
+    const line = rawLine.replace(/\/\/.*$/, '').trim();
+    if (!line) {
+      continue;
+    }
+
+    if (block) {
+      if (line === ')') {
+        block = null;
+      } else {
+        addDirective(result, block, tokenizeGoModLine(line), indirect);
+      }
+      continue;
+    }
+
+    const fields = tokenizeGoModLine(line);
+    const directive = fields.shift()!;
+    if (fields[0] === '(') {
+      block = directive;
+    } else {
+      addDirective(result, directive, fields, indirect);
+    }
+  }
+  return result;
+}
+
+function tokenizeGoModLine(line: string): string[] {
+  const fields: string[] = [];
+  const re = /"((?:[^"\\]|\\.)*)"|`([^`]*)`|(=>|\(|\))|([^\s()"`]+)/g;
+  let match: RegExpExecArray | null;
+  while ((match = re.exec(line)) !== null) {
+    fields.push(match[1] ?? match[2] ?? match[3] ?? match[4]);
+  }
+  return fields;
+}
+
+function addDirective(result: GoModFile, directive: string, fields: string[], indirect: boolean): void {
+  switch (directive) {
+    case 'module':
+      result.module = fields[0] ?? '';
+      break;
+    case 'go':
+      result.goVersion = fields[0];
+      break;
+    case 'require':
+      if (fields.length >= 2) {
+        result.require.push({ path: fields[0], version: fields[1], indirect });
+      }
+      break;
+    case 'replace': {
+      const arrow = fields.indexOf('=>');
+      if (arrow > 0 && fields.length > arrow + 1) {
+        result.replace.push({
+          oldPath: fields[0],
+          oldVersion: arrow > 1 ? fields[1] : undefined,
+          newPath: fields[arrow + 1],
+          newVersion: fields[arrow + 2]
+        });
+      }
+      break;
+    }
+  }
+}
+
+/**
+ * Module cache path encoding: upper-case letters become `!` + lower case.
+ */
+export function escapeModulePath(modulePath: string): string {
+  return modulePath.replace(/[A-Z]/g, c => '!' + c.toLowerCase());
+}
+
+export function unescapeModulePath(escaped: string): string {
+  return escaped.replace(/!([a-z])/g, (_m, c: string) => c.toUpperCase());
+}
+
+/**
+ * `$GOMODCACHE`, else `$GOPATH/pkg/mod` (first GOPATH entry), else `~/go/pkg/mod`.
+ */
+export function defaultGoModCache(env: NodeJS.ProcessEnv = process.env): string {
+  if (env.GOMODCACHE) {
+    return env.GOMODCACHE;
+  }
+  const gopath = env.GOPATH?.split(path.delimiter).find(Boolean);
+  return path.join(gopath || path.join(os.homedir(), 'go'), 'pkg', 'mod');
+}
+
+/**
+ * Maps files to the Go module that owns them.
+ *
+ * Workspace files belong to the nearest go.mod above them. Files in the
+ * module cache carry module and version in their path
+ * (`<cache>/github.com/!acme/lib@v1.2.0/...`); vendored files are matched
+ * against the requirements of the go.mod next to the vendor directory.
+ *
+ * Lookups are synchronous (used from indexing workers) and cached per
+ * directory; go.mod files are re-read when their mtime changes.
+ */
+export class GoModuleResolver {
+  private goModByDir: Map<string, string | null> = new Map();
+  private goModCache: Map<string, { mtimeMs: number; file: GoModFile }> = new Map();
+  private readonly modCacheDir: string;
+
+  constructor(modCacheDir: string = defaultGoModCache()) {
+    this.modCacheDir = path.resolve(modCacheDir);
+  }
+
+  resolve(filePath: string): GoModuleInfo | undefined {
+    const absolute = path.resolve(filePath);
+
+    const cached = this.resolveInModuleCache(absolute);
+    if (cached) {
+      return cached;
+    }
+
+    const goModPath = this.findGoMod(path.dirname(absolute));
+    if (!goModPath) {
+      return undefined;
+    }
+    const root = path.dirname(goModPath);
+    const goMod = this.readGoMod(goModPath);
+    if (!goMod?.module) {
+      return undefined;
+    }
+
+    // <root>/vendor/<module path>/...
+    const relative = path.relative(root, absolute).split(path.sep);
+    if (relative[0] === 'vendor' && relative.length > 2) {
+      const vendored = relative.slice(1, -1).join('/');
+      const requirement = goMod.require
+        .filter(r => vendored === r.path || vendored.startsWith(r.path + '/'))
+        .sort((a, b) => b.path.length - a.path.length)[0];
+      return {
+        module: requirement?.path ?? vendored,
+        version: requirement?.version,
+        dir: path.join(root, 'vendor', ...(requirement?.path ?? vendored).split('/')),
+        thirdParty: true
+      };
+    }
+
+    return { module: goMod.module, dir: root, thirdParty: false };
+  }
+
+  /**
+   * Nearest go.mod at or above dir, or null.
+   */
+  findGoMod(dir: string): string | null {
+    const visited: string[] = [];
+    let current = path.resolve(dir);
+    let found: string | null = null;
+
+    while (true) {
+      const cached = this.goModByDir.get(current);
+      if (cached !== undefined) {
+        found = cached;
+        break;
+      }
+      visited.push(current);
+      const candidate = path.join(current, 'go.mod');
+      if (fs.existsSync(candidate)) {
+        found = candidate;
+        break;
+      }
+      const parent = path.dirname(current);
+      if (parent === current) {
+        break;
+      }
+      current = parent;
+    }
+
+    for (const visitedDir of visited) {
+      this.goModByDir.set(visitedDir, found);
+    }
+    return found;
+  }
+
+  readGoMod(goModPath: string): GoModFile | undefined {
+    try {
+      const { mtimeMs } = fs.statSync(goModPath);
+      const cached = this.goModCache.get(goModPath);
+      if (cached && cached.mtimeMs === mtimeMs) {
+        return cached.file;
+      }
+      const file = parseGoMod(fs.readFileSync(goModPath, 'utf-8'));
+      this.goModCache.set(goModPath, { mtimeMs, file });
+      return file;
+    } catch {
+      return undefined;
+    }
+  }
+
+  /**
+   * Required modules of a go.mod, with `replace` directives applied and
+   * resolved to directories: module cache entries, or local paths for
+   * directory replacements. Modules missing from the cache are skipped
+   * (run `go mod download` to fetch them).
+   */
+  getDependencies(goModPath: string): GoDependency[] {
+    const goMod = this.readGoMod(goModPath);
+    if (!goMod) {
+      return [];
+    }
+
+    const dependencies: GoDependency[] = [];
+    for (const requirement of goMod.require) {
+      const replacement = goMod.replace.find(r =>
+        r.oldPath === requirement.path && (!r.oldVersion || r.oldVersion === requirement.version)
+      );
+
+      let dir: string;
+      let version: string | undefined = requirement.version;
+      if (replacement && (replacement.newPath.startsWith('./') || replacement.newPath.startsWith('../') || path.isAbsolute(replacement.newPath))) {
+        dir = path.resolve(path.dirname(goModPath), replacement.newPath);
+        version = undefined;
+      } else {
+        const modulePath = replacement?.newPath ?? requirement.path;
+        version = replacement?.newVersion ?? requirement.version;
+        dir = path.join(this.modCacheDir, ...escapeModulePath(modulePath).split('/')) + '@' + version;
+      }
+
+      if (fs.existsSync(dir)) {
+        dependencies.push({ module: requirement.path, version, dir });
+      }
+    }
+    return dependencies;
+  }
+
+  private resolveInModuleCache(absolute: string): GoModuleInfo | undefined {
+    const relative = path.relative(this.modCacheDir, absolute);
+    if (!relative || relative.startsWith('..') || path.isAbsolute(relative)) {
+      return undefined;
+    }
+
+    const segments = relative.split(path.sep);
+    const versioned = segments.findIndex(s => s.includes('@'));
+    if (versioned === -1 || segments[0] === 'cache') {
+      return undefined;
+    }
+    const [last, version] = segments[versioned].split('@');
+    const moduleSegments = [...segments.slice(0, versioned), last];
+    return {
+      module: unescapeModulePath(moduleSegments.join('/')),
+      version,
+      dir: path.join(this.modCacheDir, ...segments.slice(0, versioned + 1)),
+      thirdParty: true
+    };
+  }
+}
+
+/**
+ * Record the owning module in `metadata.go` of every symbol of a Go file:
+ * `module`, `moduleVersion`, `importPath` (module path + directory) and
+ * `thirdParty`.
+ */
+export function annotateGoModule(symbols: IndexedSymbol[], filePath: string, info: GoModuleInfo | undefined): void {
+  if (!info) {
+    return;
+  }
+  const relativeDir = path.relative(info.dir, path.dirname(path.resolve(filePath))).split(path.sep).join('/');
+  const importPath = relativeDir ? `${info.module}/${relativeDir}` : info.module;
+
+  for (const symbol of symbols) {
+    const go: GoSymbolMetadata = {
+      ...(symbol.metadata?.go as GoSymbolMetadata | undefined),
+      module: info.module,
+      ...(info.version && { moduleVersion: info.version }),
+      importPath,
+      ...(info.thirdParty && { thirdParty: true })
+    };
+    symbol.metadata = { ...symbol.metadata, go };
+  }
+}
+
+/**
+ * Non-test .go and assembly files of a module directory, skipping testdata, vendor,
+ * hidden/underscore directories and nested modules (as the go tool does).
+ */
+export async function listGoPackageFiles(moduleDir: string): Promise<string[]> {
+  const files: string[] = [];
+
+  const walk = async (dir: string): Promise<void> => {
+    let entries: fs.Dirent[];
+    try {
+      entries = await fsPromises.readdir(dir, { withFileTypes: true });
+    } catch {
+      return;
+    }
+    if (dir !== moduleDir && entries.some(e => e.isFile() && e.name === 'go.mod')) {
+      return;
+    }
+    for (const entry of entries) {
+      const fullPath = path.join(dir, entry.name);
+      if (entry.isDirectory()) {
+        if (entry.name === 'testdata' || entry.name === 'vendor' || entry.name.startsWith('.') || entry.name.startsWith('_')) {
+          continue;
+        }
+        await walk(fullPath);
+      } else if (entry.isFile() && /\.(go|s)$/.test(entry.name) && !entry.name.endsWith('_test.go')) {
+        files.push(fullPath);
+      }
+    }
+  };
+
+  await walk(moduleDir);
+  return files;
+}
+
+/**
+ * Source files of all dependencies required by the go.mod files that own
+ * the given workspace files.
+ */
+export async function collectGoDependencyFiles(
+  workspaceFiles: string[],
+  resolver: GoModuleResolver = new GoModuleResolver()
+): Promise<string[]> {
+  const goMods = new Set<string>();
+  for (const file of workspaceFiles) {
+    if (file.endsWith('.go')) {
+      const goMod = resolver.findGoMod(path.dirname(file));
+      if (goMod) {
+        goMods.add(goMod);
+      }
+    }
+  }
+
+  const workspaceModuleDirs = new Set(Array.from(goMods, goMod => path.dirname(goMod)));
+  const dependencyDirs = new Set<string>();
+  for (const goMod of goMods) {
+    for (const dependency of resolver.getDependencies(goMod)) {
+      // Local replacements pointing into the workspace are indexed already
+      if (!workspaceModuleDirs.has(dependency.dir)) {
+        dependencyDirs.add(dependency.dir);
+      }
+    }
+  }
+
+  const files: string[] = [];
+  for (const dir of dependencyDirs) {
+    files.push(...await listGoPackageFiles(dir));
+  }
+  return files;
+}
@@ -0,0 +1,175 @@
+/**
+ * GoTemplateIndexer Tests
+ *
+ * Verifies template definitions, calls and field paths of Go templates,
+ * and resolving fields to Go declarations through gotype comments.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { GoTemplateIndexer, goTemplateDefinitionsAt, parseGoTemplate } from './goTemplateIndexer.js';
+import { GoIndexer } from './goIndexer.js';
+import { MockIndex } from '../test/mocks/MockIndex.js';
+
+const page = `{{/* gotype: example.com/app/models.Page */}}
+{{define "page"}}
+<h1>{{ .Title | html }}</h1>
+{{template "nav" .User}}
+{{with .User}}<p>{{.Name}} ({{ $.Title }})</p>{{else}}{{.Title}}{{end}}
+{{range $i, $order := .User.Orders}}
+  {{- $order.Total }} {{ .ID }} {{ "}}" }}
+{{end}}
+{{ $user := .User }}{{ $user.Greet "hi" }} {{ (index .Users 0).Name }}
+{{end}}
+{{block "nav" .}}<a>{{ .Name }}</a>{{end}}
+`;
+
+const modelsGo = `package models
+
+type Base struct {
+	ID int
+}
+
+type Page struct {
+	Title string
+	User  *User
+	Users []User
+	Tags  map[string]Tag
+}
+
+type User struct {
+	Base
+	Name   string
+	Orders Orders
+}
+
+type Orders []Order
+
+type Order struct {
+	Base
+	Total float64
+}
+
+type Tag struct {
+	Label string
+}
+
+func (u *User) Greet(greeting string) string { return greeting + u.Name }
+`;
+
+const otherGo = `package other
+
+type Robot struct {
+	Name string
+	ID   int
+}
+`;
+
+describe('GoTemplateIndexer', () => {
+  const indexer = new GoTemplateIndexer();
+
+  it('should index templates, calls and fields', () => {
+    const { symbols, references, diagnostics } = indexer.parse('/ws/web/page.tmpl', page);
+
+    expect(diagnostics).toBeUndefined();
+    expect(symbols.map(s => [s.name, s.kind, s.location.line, s.location.character, s.range.endLine])).toEqual([
+      ['page', 'function', 1, 10, 9],
+      ['nav', 'function', 10, 9, 10]
+    ]);
+    expect(symbols[0].metadata?.goTemplate).toEqual({ dataType: 'example.com/app/models.Page' });
+    // The file's gotype comment covers templates without their own
+    expect(symbols[1].metadata?.goTemplate).toEqual({ block: true, dataType: 'example.com/app/models.Page' });
+    expect(references.map(r => [r.symbolName, r.location.line, r.location.character, r.containerName, r.isCall])).toEqual([
+      ['Title', 2, 8, 'page', undefined],
+      ['nav', 3, 12, 'page', true],
+      ['User', 3, 18, 'page', undefined],
+      ['User', 4, 8, 'page', undefined],
+      ['Name', 4, 20, 'page', undefined],
+      ['Title', 4, 33, 'page', undefined],
+      ['Title', 4, 57, 'page', undefined],
+      ['User', 5, 23, 'page', undefined],
+      ['Orders', 5, 28, 'page', undefined],
+      ['Total', 6, 13, 'page', undefined],
+      ['ID', 6, 26, 'page', undefined],
+      ['User', 8, 13, 'page', undefined],
+      ['Greet', 8, 29, 'page', undefined],
+      ['Users', 8, 54, 'page', undefined],
+      ['Name', 8, 63, 'page', undefined],
+      ['Name', 10, 24, 'nav', undefined]
+    ]);
+  });
+
+  it('should track the dot through with, range and variables', () => {
+    const { fields } = parseGoTemplate(page);
+    const paths = Object.fromEntries(fields.map(f => [`${f.name}@${f.line}:${f.character}`, f.path]));
+
+    expect(paths['Name@4:20']).toEqual(['User']);
+    expect(paths['Title@4:33']).toEqual([]);
+    // {{else}} of a with is back at the outer dot
+    expect(paths['Title@4:57']).toEqual([]);
+    expect(paths['Total@6:13']).toEqual(['User', 'Orders', '[]']);
+    expect(paths['ID@6:26']).toEqual(['User', 'Orders', '[]']);
+    expect(paths['Greet@8:29']).toEqual(['User']);
+    // Function results are unknown
+    expect(paths['Name@8:63']).toBeUndefined();
+    expect(fields.find(f => f.line === 10)).toMatchObject({ template: 'nav', path: [], dataType: 'example.com/app/models.Page' });
+  });
+
+  it('should read Helm includes and report unbalanced actions', () => {
+    const helpers = `{{- define "chart.labels" -}}
+app: {{ include "chart.name" . }}
+{{- end }}
+`;
+    expect(parseGoTemplate(helpers).calls).toEqual([{ name: 'chart.name', line: 1, character: 17, template: 'chart.labels' }]);
+
+    expect(parseGoTemplate('{{if .A}}x').diagnostics).toEqual([{ message: 'Missing {{end}} for {{if}}', line: 0, character: 10 }]);
+    expect(parseGoTemplate('x{{end}}').diagnostics).toEqual([{ message: 'Unexpected {{end}}', line: 0, character: 1 }]);
+    expect(parseGoTemplate('{{ .A ').diagnostics).toEqual([{ message: 'Unclosed action', line: 0, character: 0 }]);
+  });
+
+  describe('definitions', () => {
+    let index: MockIndex;
+
+    beforeEach(() => {
+      index = new MockIndex();
+      const goIndexer = new GoIndexer();
+      for (const [uri, source] of [['/ws/models/models.go', modelsGo], ['/ws/other/robot.go', otherGo]]) {
+        for (const symbol of goIndexer.indexFile(uri, source).symbols) {
+          index.addSymbol(symbol);
+        }
+      }
+      for (const symbol of indexer.parse('/ws/web/page.tmpl', page).symbols) {
+        index.addSymbol(symbol);
+      }
+    });
+
+    const definitionAt = async (line: number, character: number) =>
+      (await goTemplateDefinitionsAt(page, line, character, index))?.map(s => `${s.containerName}.${s.name}:${s.location.line}`);
+
+    it('should resolve fields through the gotype data type', async () => {
+      expect(await definitionAt(4, 21)).toEqual(['User.Name:15']);
+      expect(await definitionAt(2, 9)).toEqual(['Page.Title:7']);
+      // Named slice types, embedded structs and methods
+      expect(await definitionAt(6, 14)).toEqual(['Order.Total:23']);
+      expect(await definitionAt(6, 27)).toEqual(['Base.ID:3']);
+      expect(await definitionAt(8, 30)).toEqual(['User.Greet:30']);
+    });
+
+    it('should read map keys as values of the map', async () => {
+      const tags = '{{/* gotype: models.Page */}}{{ .Tags.home.Label }}';
+      const definitions = await goTemplateDefinitionsAt(tags, 0, 46, index);
+      expect(definitions?.map(s => `${s.containerName}.${s.name}`)).toEqual(['Tag.Label']);
+    });
+
+    it('should fall back to exported Go members of the name', async () => {
+      // The type of (index .Users 0) is not tracked
+      expect(await definitionAt(8, 64)).toEqual(['User.Name:15', 'Robot.Name:3']);
+      // Page has no Name field
+      expect(await definitionAt(10, 25)).toEqual(['User.Name:15', 'Robot.Name:3']);
+    });
+
+    it('should go to template definitions', async () => {
+      expect(await definitionAt(3, 13)).toEqual(['undefined.nav:10']);
+      expect(await definitionAt(0, 5)).toBeUndefined();
+    });
+  });
+});
@@ -0,0 +1,755 @@
+import * as path from 'path';
+import { IndexedSymbol, IndexedReference, ParseDiagnostic } from '../types.js';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { createSymbolId } from './symbolResolver.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+import { signatureTypes } from '../utils/signatures.js';
+import { GoSymbolMetadata, isGoExported } from './goIndexer.js';
+
+/**
+ * Go template symbol metadata, stored under `symbol.metadata.goTemplate`.
+ */
+export interface GoTemplateSymbolMetadata {
+  /** Declared with `{{block}}`, which also executes it in place */
+  block?: boolean;
+  /** Type of the template's data, from a `{{/* gotype: pkg.Type *\/}}` comment */
+  dataType?: string;
+}
+
+/** A `{{define}}` or `{{block}}` of a template file */
+export interface GoTemplateDefinition {
+  name: string;
+  block: boolean;
+  /** 0-based position of the name, inside the quotes */
+  line: number;
+  character: number;
+  /** Range from the opening action to the end of `{{end}}` */
+  startLine: number;
+  startCharacter: number;
+  endLine: number;
+  endCharacter: number;
+  dataType?: string;
+}
+
+/** A `{{template "name"}}` call, or Helm's `include "name"` */
+export interface GoTemplateCall {
+  name: string;
+  line: number;
+  character: number;
+  /** Template the call is made from; undefined at the top level of the file */
+  template?: string;
+}
+
+/** A field or method read by a template: each name of `.User.Name` */
+export interface GoTemplateField {
+  name: string;
+  line: number;
+  character: number;
+  /** Template the field is used in; undefined at the top level of the file */
+  template?: string;
+  /**
+   * Steps from the template's data to the value the field is read from,
+   * `[]` for the elements `range` iterates over: `.Name` inside
+   * `{{range .Users}}` has the path `['Users', '[]']`. Undefined when the
+   * value is not known, e.g. a function result.
+   */
+  path?: string[];
+  /** Type of the template's data when a gotype comment declares it */
+  dataType?: string;
+}
+
+export interface GoTemplateFile {
+  definitions: GoTemplateDefinition[];
+  calls: GoTemplateCall[];
+  fields: GoTemplateField[];
+  diagnostics: ParseDiagnostic[];
+}
+
+/** File extensions of Go templates; `.tpl` covers Helm's `_helpers.tpl` */
+export const GO_TEMPLATE_EXTENSIONS = ['.tmpl', '.gotmpl', '.gohtml', '.tpl'];
+
+/** `gotype: example.com/app/models.Person`, as GoLand writes it */
+const GOTYPE_COMMENT = /^\s*gotype:\s*(\S+)\s*$/;
+
+/** Structs followed through embedded fields for promoted members */
+const MAX_EMBEDDING_DEPTH = 4;
+
+const TYPE_KINDS = new Set(['struct', 'interface', 'type']);
+
+/**
+ * Go html/template and text/template files. `{{define}}` and `{{block}}`
+ * declare templates as `function` symbols; `{{template}}` calls and the
+ * fields and methods actions read (`.User.Name`, `$.Title`,
+ * `$item.Price`) are references, so Find References and rename impact on
+ * a Go struct field include the templates using it.
+ *
+ * The dot is tracked through `with`, `range` and variables, so each field
+ * knows its path from the template's data; with a gotype comment naming
+ * the data's type, goTemplateDefinitionsAt resolves the path to the exact
+ * Go declarations.
+ */
+export class GoTemplateIndexer implements LanguageParser {
+  readonly language = 'gotmpl';
+  readonly extensions = GO_TEMPLATE_EXTENSIONS;
+
+  parse(uri: string, content: string): ParseResult {
+    const file = parseGoTemplate(content);
+    const symbols = file.definitions.map(definition => templateSymbol(uri, definition));
+    const references: IndexedReference[] = [
+      ...file.calls.map(call => reference(uri, call.name, call.line, call.character, call.template, true)),
+      ...file.fields.map(field => reference(uri, field.name, field.line, field.character, field.template, false))
+    ].sort((a, b) => a.location.line - b.location.line || a.location.character - b.location.character);
+    const result: ParseResult = { symbols, references, imports: [] };
+    if (file.diagnostics.length > 0) {
+      result.diagnostics = file.diagnostics;
+    }
+    return result;
+  }
+}
+
+export function isGoTemplateFile(filePath: string): boolean {
+  return GO_TEMPLATE_EXTENSIONS.includes(path.extname(filePath).toLowerCase());
+}
+
+function templateSymbol(uri: string, definition: GoTemplateDefinition): IndexedSymbol {
+  const metadata: GoTemplateSymbolMetadata = {
+    ...(definition.block && { block: true }),
+    ...(definition.dataType && { dataType: definition.dataType })
+  };
+  return {
+    id: createSymbolId(uri, definition.name, undefined, undefined, 'function', false, undefined, definition.line, definition.character),
+    name: definition.name,
+    kind: 'function',
+    location: { uri, line: definition.line, character: definition.character },
+    range: {
+      startLine: definition.startLine,
+      startCharacter: definition.startCharacter,
+      endLine: definition.endLine,
+      endCharacter: definition.endCharacter
+    },
+    filePath: uri,
+    metadata: { goTemplate: metadata },
+    isDefinition: true
+  };
+}
+
+function reference(uri: string, name: string, line: number, character: number, template: string | undefined, isCall: boolean): IndexedReference {
+  return {
+    symbolName: name,
+    location: { uri, line, character },
+    range: { startLine: line, startCharacter: character, endLine: line, endCharacter: character + name.length },
+    containerName: template,
+    isCall: isCall || undefined
+  };
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+interface ActionToken {
+  type: 'string' | 'field' | 'variable' | 'dot' | 'ident' | 'punct' | 'other';
+  text: string;
+  offset: number;
+  /** Unquoted strings; the `$name` of variables */
+  value?: string;
+  /** Names after the dot or variable: `.User.Name`, `$x.Name` */
+  segments?: Array<{ name: string; offset: number }>;
+  /** A field chained to a parenthesized expression: `(index .Users 0).Name` */
+  chained?: boolean;
+}
+
+interface Frame {
+  kind: 'root' | 'define' | 'block' | 'if' | 'with' | 'range';
+  /** Template of define and block frames */
+  template?: string;
+  /** Path of the dot; undefined when unknown */
+  dot?: string[];
+  /** Dot outside the frame, restored by `{{else}}` */
+  outer?: string[];
+  vars: Map<string, string[] | undefined>;
+  definition?: GoTemplateDefinition;
+}
+
+/**
+ * Read the templates, template calls and field uses of a Go template file.
+ */
+export function parseGoTemplate(content: string): GoTemplateFile {
+  return new GoTemplateParser(content).parse();
+}
+
+class GoTemplateParser {
+  private definitions: GoTemplateDefinition[] = [];
+  private calls: GoTemplateCall[] = [];
+  private fields: GoTemplateField[] = [];
+  private diagnostics: ParseDiagnostic[] = [];
+  private frames: Frame[] = [{ kind: 'root', dot: [], vars: new Map() }];
+  /** Data types of templates by name, '' for the top level of the file */
+  private dataTypes = new Map<string, string>();
+  private lineStarts: number[] = [0];
+
+  constructor(private content: string) {
+    for (let i = 0; i < content.length; i++) {
+      if (content[i] === '\n') {
+        this.lineStarts.push(i + 1);
+      }
+    }
+  }
+
+  parse(): GoTemplateFile {
+    const content = this.content;
+    for (let open = content.indexOf('{{'); open !== -1; ) {
+      let start = open + 2;
+      if (content[start] === '-' && /\s/.test(content[start + 1] ?? '')) {
+        start++;
+      }
+      const comment = /^\s*\/\*/.exec(content.slice(start, start + 64));
+      if (comment) {
+        const commentStart = start + comment[0].length;
+        const commentEnd = content.indexOf('*/', commentStart);
+        if (commentEnd === -1) {
+          this.diagnose('Unclosed comment', open);
+          break;
+        }
+        this.comment(content.slice(commentStart, commentEnd));
+        const close = content.indexOf('}}', commentEnd + 2);
+        if (close === -1) {
+          break;
+        }
+        open = content.indexOf('{{', close + 2);
+        continue;
+      }
+
+      const close = this.findClose(start);
+      if (close === -1) {
+        this.diagnose('Unclosed action', open);
+        break;
+      }
+      let end = close;
+      if (content[end - 1] === '-' && /\s/.test(content[end - 2] ?? '')) {
+        end--;
+      }
+      this.action(this.tokenize(start, end), open, close + 2);
+      open = content.indexOf('{{', close + 2);
+    }
+
+    for (let i = this.frames.length - 1; i > 0; i--) {
+      const frame = this.frames[i];
+      this.diagnose(`Missing {{end}} for {{${frame.kind}}}`, frame.definition ? this.offsetOf(frame.definition) : content.length);
+    }
+    // Templates without a gotype comment of their own get the file's
+    for (const field of this.fields) {
+      const dataType = this.dataTypes.get(field.template ?? '') ?? this.dataTypes.get('');
+      if (dataType && field.path) {
+        field.dataType = dataType;
+      }
+    }
+    for (const definition of this.definitions) {
+      const dataType = this.dataTypes.get(definition.name) ?? this.dataTypes.get('');
+      if (dataType) {
+        definition.dataType = dataType;
+      }
+    }
+    return { definitions: this.definitions, calls: this.calls, fields: this.fields, diagnostics: this.diagnostics };
+  }
+
+  /** The `}}` ending an action, outside strings; -1 if there is none */
+  private findClose(from: number): number {
+    const content = this.content;
+    for (let i = from; i < content.length - 1; i++) {
+      const c = content[i];
+      if (c === '"' || c === '`' || c === "'") {
+        i = this.skipQuoted(i);
+      } else if (c === '}' && content[i + 1] === '}') {
+        return i;
+      }
+    }
+    return -1;
+  }
+
+  /** Index of the closing quote of the string starting at i */
+  private skipQuoted(i: number): number {
+    const content = this.content;
+    const quote = content[i];
+    for (let j = i + 1; j < content.length; j++) {
+      if (content[j] === '\\' && quote !== '`') {
+        j++;
+      } else if (content[j] === quote || (quote !== '`' && content[j] === '\n')) {
+        return j;
+      }
+    }
+    return content.length;
+  }
+
+  private comment(text: string): void {
+    const match = GOTYPE_COMMENT.exec(text);
+    if (match) {
+      this.dataTypes.set(this.template() ?? '', match[1]);
+    }
+  }
+
+  private tokenize(start: number, end: number): ActionToken[] {
+    const content = this.content;
+    const tokens: ActionToken[] = [];
+    for (let i = start; i < end; ) {
+      const c = content[i];
+      if (/\s/.test(c)) {
+        i++;
+      } else if (c === '"' || c === '`' || c === "'") {
+        const close = Math.min(this.skipQuoted(i), end - 1);
+        const text = content.slice(i, close + 1);
+        tokens.push({ type: 'string', text, offset: i, value: unquoteGoTemplateString(text) });
+        i = close + 1;
+      } else if (c === '.' || c === '$') {
+        const startOffset = i;
+        let value: string | undefined;
+        if (c === '$') {
+          const name = /^\$[\p{L}\p{N}_]*/u.exec(content.slice(i, end))![0];
+          value = name;
+          i += name.length;
+        }
+        const segments: Array<{ name: string; offset: number }> = [];
+        for (let match; (match = /^\.([\p{L}_][\p{L}\p{N}_]*)/u.exec(content.slice(i, end))); ) {
+          segments.push({ name: match[1], offset: i + 1 });
+          i += match[0].length;
+        }
+        if (c === '.' && segments.length === 0) {
+          if (/\d/.test(content[i + 1] ?? '')) {
+            i = this.skipWord(i, end);
+            tokens.push({ type: 'other', text: content.slice(startOffset, i), offset: startOffset });
+            continue;
+          }
+          i++;
+          tokens.push({ type: 'dot', text: '.', offset: startOffset });
+          continue;
+        }
+        tokens.push({
+          type: c === '$' ? 'variable' : 'field',
+          text: content.slice(startOffset, i),
+          offset: startOffset,
+          value,
+          segments,
+          chained: c === '.' && content[startOffset - 1] === ')'
+        });
+      } else if (/[\p{L}_]/u.test(c)) {
+        const name = /^[\p{L}\p{N}_]+/u.exec(content.slice(i, end))![0];
+        tokens.push({ type: 'ident', text: name, offset: i });
+        i += name.length;
+      } else if (c === ':' && content[i + 1] === '=') {
+        tokens.push({ type: 'punct', text: ':=', offset: i });
+        i += 2;
+      } else if ('=|(),'.includes(c)) {
+        tokens.push({ type: 'punct', text: c, offset: i });
+        i++;
+      } else {
+        const from = i;
+        i = this.skipWord(i, end);
+        tokens.push({ type: 'other', text: content.slice(from, i), offset: from });
+      }
+    }
+    return tokens;
+  }
+
+  /** Skip a number or other run of characters up to a delimiter */
+  private skipWord(i: number, end: number): number {
+    let j = i + 1;
+    while (j < end && !/[\s()|,]/.test(this.content[j])) {
+      j++;
+    }
+    return j;
+  }
+
+  private action(tokens: ActionToken[], start: number, end: number): void {
+    const keyword = tokens[0]?.type === 'ident' ? tokens[0].text : undefined;
+    const frame = this.frames[this.frames.length - 1];
+    switch (keyword) {
+      case 'define':
+      case 'block': {
+        const name = tokens[1]?.type === 'string' ? tokens[1] : undefined;
+        if (!name) {
+          this.diagnose(`Expected a template name after ${keyword}`, tokens[0].offset);
+          return;
+        }
+        if (keyword === 'block') {
+          this.pipeline(tokens.slice(2));
+        }
+        const position = this.positionAt(name.offset + 1);
+        const open = this.positionAt(start);
+        const definition: GoTemplateDefinition = {
+          name: name.value!,
+          block: keyword === 'block',
+          line: position.line,
+          character: position.character,
+          startLine: open.line,
+          startCharacter: open.character,
+          endLine: open.line,
+          endCharacter: open.character
+        };
+        this.definitions.push(definition);
+        this.frames.push({ kind: keyword, template: definition.name, dot: [], vars: new Map(), definition });
+        return;
+      }
+      case 'template': {
+        if (tokens[1]?.type === 'string') {
+          this.call(tokens[1]);
+        }
+        this.pipeline(tokens.slice(2));
+        return;
+      }
+      case 'if': {
+        const vars = new Map<string, string[] | undefined>();
+        this.pipeline(tokens.slice(1), vars);
+        this.frames.push({ kind: 'if', dot: frame.dot, outer: frame.dot, vars });
+        return;
+      }
+      case 'with': {
+        const vars = new Map<string, string[] | undefined>();
+        this.frames.push({ kind: 'with', dot: this.pipeline(tokens.slice(1), vars), outer: frame.dot, vars });
+        return;
+      }
+      case 'range': {
+        const vars = new Map<string, string[] | undefined>();
+        const value = this.pipeline(tokens.slice(1), vars);
+        const element = value && [...value, '[]'];
+        // {{range $v := .Items}} or {{range $i, $v := .Items}}: the last variable is the element
+        const names = [...vars.keys()];
+        if (names.length > 0) {
+          vars.set(names[names.length - 1], element);
+        }
+        this.frames.push({ kind: 'range', dot: element, outer: frame.dot, vars });
+        return;
+      }
+      case 'else': {
+        if (frame.kind === 'root' || frame.kind === 'define' || frame.kind === 'block') {
+          this.diagnose('Unexpected {{else}}', start);
+          return;
+        }
+        frame.dot = frame.outer;
+        frame.vars.clear();
+        if (tokens[1]?.type === 'ident' && tokens[1].text === 'with') {
+          frame.dot = this.pipeline(tokens.slice(2));
+        } else if (tokens[1]?.type === 'ident' && tokens[1].text === 'if') {
+          this.pipeline(tokens.slice(2));
+        }
+        return;
+      }
+      case 'end': {
+        if (frame.kind === 'root') {
+          this.diagnose('Unexpected {{end}}', start);
+          return;
+        }
+        this.frames.pop();
+        if (frame.definition) {
+          const close = this.positionAt(end);
+          frame.definition.endLine = close.line;
+          frame.definition.endCharacter = close.character;
+        }
+        return;
+      }
+      case 'break':
+      case 'continue':
+        return;
+      default:
+        this.pipeline(tokens, frame.vars);
+    }
+  }
+
+  /**
+   * Record the fields and template calls of a pipeline and return the
+   * path of its value: known when it is a single field, variable or dot.
+   * A leading `$x :=` declares variables in vars.
+   */
+  private pipeline(tokens: ActionToken[], vars?: Map<string, string[] | undefined>): string[] | undefined {
+    let declared: string[] = [];
+    const assign = tokens.findIndex(token => token.text === ':=' || token.text === '=');
+    if (assign > 0 && tokens.slice(0, assign).every(token => token.type === 'variable' || token.text === ',')) {
+      declared = tokens.slice(0, assign).map(token => token.value).filter((name): name is string => name !== undefined);
+      tokens = tokens.slice(assign + 1);
+    }
+
+    for (let i = 0; i < tokens.length; i++) {
+      const token = tokens[i];
+      if (token.type === 'field' || token.type === 'variable') {
+        this.chain(token);
+      } else if (token.type === 'ident' && token.text === 'include' && tokens[i + 1]?.type === 'string') {
+        // Helm: {{ include "chart.labels" . }}
+        this.call(tokens[i + 1]);
+      }
+    }
+    const value = tokens.length === 1 ? this.valuePath(tokens[0]) : undefined;
+    for (const name of declared) {
+      vars?.set(name, declared.length === 1 ? value : undefined);
+    }
+    return value;
+  }
+
+  /** Record each name of `.User.Name` or `$x.Name` as a field use */
+  private chain(token: ActionToken): void {
+    let base = this.basePath(token);
+    const template = this.template();
+    for (const segment of token.segments!) {
+      const position = this.positionAt(segment.offset);
+      this.fields.push({ name: segment.name, line: position.line, character: position.character, template, path: base });
+      base = base && [...base, segment.name];
+    }
+  }
+
+  private valuePath(token: ActionToken): string[] | undefined {
+    if (token.type === 'dot') {
+      return this.frames[this.frames.length - 1].dot;
+    }
+    if (token.type !== 'field' && token.type !== 'variable') {
+      return undefined;
+    }
+    const base = this.basePath(token);
+    return base && [...base, ...token.segments!.map(segment => segment.name)];
+  }
+
+  /** Path of the dot or variable a chain starts from */
+  private basePath(token: ActionToken): string[] | undefined {
+    if (token.type === 'field') {
+      return token.chained ? undefined : this.frames[this.frames.length - 1].dot;
+    }
+    if (token.value === '$') {
+      return [];
+    }
+    return this.lookupVar(token.value!) ?? undefined;
+  }
+
+  /** Path of a variable; null when it is not declared */
+  private lookupVar(name: string): string[] | undefined | null {
+    for (let i = this.frames.length - 1; i >= 0; i--) {
+      const vars = this.frames[i].vars;
+      if (vars.has(name)) {
+        return vars.get(name);
+      }
+      if (this.frames[i].template !== undefined) {
+        break;
+      }
+    }
+    return name === '$' ? [] : null;
+  }
+
+  private call(token: ActionToken): void {
+    const position = this.positionAt(token.offset + 1);
+    this.calls.push({ name: token.value!, line: position.line, character: position.character, template: this.template() });
+  }
+
+  /** Name of the template being parsed; undefined at the top level */
+  private template(): string | undefined {
+    for (let i = this.frames.length - 1; i >= 0; i--) {
+      if (this.frames[i].template !== undefined) {
+        return this.frames[i].template;
+      }
+    }
+    return undefined;
+  }
+
+  private diagnose(message: string, offset: number): void {
+    const { line, character } = this.positionAt(offset);
+    this.diagnostics.push({ message, line, character });
+  }
+
+  private offsetOf(definition: GoTemplateDefinition): number {
+    return this.lineStarts[definition.startLine] + definition.startCharacter;
+  }
+
+  private positionAt(offset: number): { line: number; character: number } {
+    let low = 0;
+    let high = this.lineStarts.length - 1;
+    while (low < high) {
+      const mid = (low + high + 1) >> 1;
+      if (this.lineStarts[mid] <= offset) {
+        low = mid;
+      } else {
+        high = mid - 1;
+      }
+    }
+    return { line: low, character: offset - this.lineStarts[low] };
+  }
+}
+
+function unquoteGoTemplateString(text: string): string {
+  if (text.startsWith('`')) {
+    return text.slice(1, text.endsWith('`') && text.length > 1 ? -1 : undefined);
+  }
+  try {
+    return JSON.parse(text.startsWith("'") ? `"${text.slice(1, -1)}"` : text);
+  } catch {
+    return text.slice(1, -1);
+  }
+}
+
+// ============================================================================
+// Resolution
+// ============================================================================
+
+/** The part of an index resolution reads */
+export type GoTemplateIndex = Pick<ISymbolIndex, 'findDefinitions'>;
+
+/**
+ * Definitions for the template field or template name at a position of a
+ * Go template, undefined when there is neither. A field whose path from a
+ * gotype-declared data type resolves goes to that exact declaration;
+ * others go to the exported Go fields and methods of that name, which
+ * templates can reach. Template names go to their `{{define}}`.
+ */
+export async function goTemplateDefinitionsAt(
+  content: string,
+  line: number,
+  character: number,
+  index: GoTemplateIndex
+): Promise<IndexedSymbol[] | undefined> {
+  const file = parseGoTemplate(content);
+  const covers = (item: { name: string; line: number; character: number }) =>
+    item.line === line && character >= item.character && character <= item.character + item.name.length;
+
+  const call = file.calls.find(covers);
+  if (call) {
+    return (await index.findDefinitions(call.name)).filter(symbol => symbol.metadata?.goTemplate !== undefined);
+  }
+  const field = file.fields.find(covers);
+  if (!field) {
+    return undefined;
+  }
+  const resolved = await resolveGoTemplateField(field, index);
+  if (resolved.length > 0) {
+    return resolved;
+  }
+  return (await index.findDefinitions(field.name)).filter(symbol =>
+    (symbol.kind === 'field' || symbol.kind === 'method') && symbol.metadata?.go !== undefined && isGoExported(symbol.name)
+  );
+}
+
+/** A Go type expression and the package its unqualified names belong to */
+interface GoTypeContext {
+  type: string;
+  package?: string;
+  dir?: string;
+}
+
+/**
+ * The Go field or method a template field reads, following its path
+ * from the template's data type; empty when the data type is not
+ * declared or a step does not resolve. Pointers are followed, `[]` steps
+ * take slice, array, map and channel elements, and a step into a map
+ * reads a key, so it takes the value type.
+ */
+export async function resolveGoTemplateField(field: GoTemplateField, index: GoTemplateIndex): Promise<IndexedSymbol[]> {
+  if (!field.dataType || !field.path) {
+    return [];
+  }
+  // example.com/app/models.Person -> models.Person
+  const dataType = field.dataType.replace(/^([*[\]]*)[^*[\]]*\//, '$1');
+  let current: GoTypeContext | undefined = { type: dataType };
+  for (const step of field.path) {
+    if (!current) {
+      return [];
+    }
+    const mapValue = mapValueType(current.type.replace(/^\*+/, ''));
+    if (step === '[]' || mapValue) {
+      current = mapValue ? { ...current, type: mapValue } : await elementType(current, index);
+      continue;
+    }
+    const member = await findMember(current, step, index);
+    current = member && memberType(member);
+  }
+  if (!current) {
+    return [];
+  }
+  const member = await findMember(current, field.name, index);
+  return member ? [member] : [];
+}
+
+async function elementType(context: GoTypeContext, index: GoTemplateIndex, depth = 0): Promise<GoTypeContext | undefined> {
+  const type = context.type.replace(/^\*+/, '');
+  const element = /^(?:\[\d*\]|chan\s+|<-chan\s+)(.+)$/.exec(type);
+  if (element) {
+    return { ...context, type: element[1] };
+  }
+  const mapValue = mapValueType(type);
+  if (mapValue) {
+    return { ...context, type: mapValue };
+  }
+  // type People []Person
+  const named = await findType(context, index);
+  const underlying = (named?.metadata?.go as GoSymbolMetadata | undefined)?.underlying;
+  if (named && underlying && depth < MAX_EMBEDDING_DEPTH) {
+    return elementType({ type: underlying, package: goPackage(named), dir: path.dirname(named.location.uri) }, index, depth + 1);
+  }
+  return undefined;
+}
+
+/** Value type of `map[K]V` */
+function mapValueType(type: string): string | undefined {
+  if (!type.startsWith('map[')) {
+    return undefined;
+  }
+  let depth = 0;
+  for (let i = 3; i < type.length; i++) {
+    if (type[i] === '[') {
+      depth++;
+    } else if (type[i] === ']' && --depth === 0) {
+      return type.slice(i + 1).trim();
+    }
+  }
+  return undefined;
+}
+
+/**
+ * A field or method of the type, own or promoted through embedded
+ * structs. A step into a map has no declaration: undefined.
+ */
+async function findMember(context: GoTypeContext, name: string, index: GoTemplateIndex, depth = 0): Promise<IndexedSymbol | undefined> {
+  const named = await findType(context, index);
+  if (!named) {
+    return undefined;
+  }
+  const dir = path.dirname(named.location.uri);
+  const members = (await index.findDefinitions(name)).filter(symbol =>
+    (symbol.kind === 'field' || symbol.kind === 'method') && symbol.containerName === named.name &&
+    path.dirname(symbol.location.uri) === dir && symbol.metadata?.go !== undefined
+  );
+  if (members.length > 0) {
+    return members[0];
+  }
+  if (depth >= MAX_EMBEDDING_DEPTH) {
+    return undefined;
+  }
+  for (const embedded of (named.metadata?.go as GoSymbolMetadata | undefined)?.embeds ?? []) {
+    const promoted = await findMember({ type: embedded, package: goPackage(named), dir }, name, index, depth + 1);
+    if (promoted) {
+      return promoted;
+    }
+  }
+  return undefined;
+}
+
+/** The Go type declaration a type expression names */
+async function findType(context: GoTypeContext, index: GoTemplateIndex): Promise<IndexedSymbol | undefined> {
+  const match = /^\*?(?:([\p{L}_][\p{L}\p{N}_]*)\.)?([\p{L}_][\p{L}\p{N}_]*)(?:\[.*\])?$/u.exec(context.type.trim());
+  if (!match) {
+    return undefined;
+  }
+  const [, qualifier, name] = match;
+  const types = (await index.findDefinitions(name)).filter(symbol =>
+    TYPE_KINDS.has(symbol.kind) && !symbol.containerName && symbol.metadata?.go !== undefined
+  );
+  if (qualifier) {
+    return types.find(symbol => goPackage(symbol) === qualifier);
+  }
+  return types.find(symbol => context.dir !== undefined && path.dirname(symbol.location.uri) === context.dir) ??
+    types.find(symbol => context.package === undefined || goPackage(symbol) === context.package);
+}
+
+/** Type of a field's value or a method's first result, in the member's package */
+function memberType(member: IndexedSymbol): GoTypeContext | undefined {
+  const type = member.kind === 'method'
+    ? signatureTypes(member)?.results?.[0]
+    : (member.metadata?.go as GoSymbolMetadata | undefined)?.type;
+  return type ? { type, package: goPackage(member), dir: path.dirname(member.location.uri) } : undefined;
+}
+
+function goPackage(symbol: IndexedSymbol): string | undefined {
+  return (symbol.metadata?.go as GoSymbolMetadata | undefined)?.package;
+}
@@ -0,0 +1,28 @@
+import { IndexedSymbol, IndexedReference, ImportInfo, ParseDiagnostic, ReExportInfo } from '../types.js';
+
+/**
+ * Symbols, references and imports extracted from one file.
+ */
+export interface ParseResult {
+  symbols: IndexedSymbol[];
+  references: IndexedReference[];
+  imports: ImportInfo[];
+  reExports?: ReExportInfo[];
+  /** Syntax errors the parser recovered from, in position order */
+  diagnostics?: ParseDiagnostic[];
+}
+
+/**
+ * A parser for one language.
+ *
+ * Parsers are synchronous and side-effect free: they get the file
+ * content and return what they found, so the same instance can run in
+ * the main thread (open documents) and in indexing workers.
+ */
+export interface LanguageParser {
+  /** Language id, e.g. `go`, `python` */
+  readonly language: string;
+  /** Lowercase file extensions including the dot */
+  readonly extensions: readonly string[];
+  parse(uri: string, content: string): ParseResult;
+}
@@ -1,6 +1,15 @@
 import { IndexedFileResult } from '../types.js';
 import { SymbolIndexer } from './symbolIndexer.js';
 import { TextIndexer } from './textIndexer.js';
+import { ParserRegistry, createDefaultParserRegistry } from './parserRegistry.js';
+import { GoModuleResolver, annotateGoModule } from './goModules.js';
+import { BazelTargetResolver, annotateBazelTargets, isOutsideBazelTargets } from './bazelTargets.js';
+import { PackagePathResolver, assignCanonicalIds } from './canonicalIds.js';
+import { GoBuildContext, fileBuildConstraint, matchesBuildContext } from './goBuildConstraints.js';
+import { attachByteOffsets } from '../utils/byteOffsets.js';
+import { attachFunctionMetrics } from '../utils/functionMetrics.js';
+import * as crypto from 'crypto';
+import * as fsPromises from 'fs/promises';
 import * as path from 'path';
 
 /**
@@ -9,11 +18,19 @@ import * as path from 'path';
 export class LanguageRouter {
   private symbolIndexer: SymbolIndexer;
   private textIndexer: TextIndexer;
+  private parserRegistry: ParserRegistry;
+  private goModules: GoModuleResolver;
+  private packagePaths: PackagePathResolver;
   private textIndexingEnabled: boolean;
+  private goBuildContext: GoBuildContext | undefined;
+  private bazelTargets: BazelTargetResolver | undefined;
 
   constructor(symbolIndexer: SymbolIndexer, textIndexingEnabled: boolean = false) {
     this.symbolIndexer = symbolIndexer;
     this.textIndexer = new TextIndexer();
+    this.parserRegistry = createDefaultParserRegistry();
+    this.goModules = new GoModuleResolver();
+    this.packagePaths = new PackagePathResolver();
     this.textIndexingEnabled = textIndexingEnabled;
   }
 
@@ -21,6 +38,22 @@ export class LanguageRouter {
     this.textIndexingEnabled = enabled;
   }
 
+  /**
+   * Skip Go files that a build for the context excludes (undefined: index
+   * every file).
+   */
+  setGoBuildContext(context: GoBuildContext | undefined): void {
+    this.goBuildContext = context;
+  }
+
+  /**
+   * Tag Go symbols with the Bazel targets that compile their file and
+   * skip Go files no target compiles (see indexer/bazelTargets.ts).
+   */
+  setBazelEnabled(enabled: boolean): void {
+    this.bazelTargets = enabled ? this.bazelTargets ?? new BazelTargetResolver() : undefined;
+  }
+
   /**
    * Index a file using the appropriate indexer
    */
@@ -29,7 +62,58 @@ export class LanguageRouter {
     
     // TypeScript/JavaScript files use AST-based indexer
     if (this.isTsJsFile(ext)) {
-      return this.symbolIndexer.indexFile(uri, content);
+      const result = await this.symbolIndexer.indexFile(uri, content);
+      assignCanonicalIds(result.symbols, uri, this.packagePaths);
+      return result;
+    }
+
+    // Go, Python, ... use their structural parsers (always enabled, like the background worker)
+    const parser = this.parserRegistry.getParser(uri);
+    if (parser) {
+      const source = content ?? await fsPromises.readFile(uri, 'utf-8').catch(() => '');
+      const hash = crypto.createHash('sha256').update(source).digest('hex');
+      const isGo = parser.language === 'go' || parser.language === 'goasm';
+      if (isGo && this.goBuildContext &&
+          !matchesBuildContext(fileBuildConstraint(uri, source), this.goBuildContext)) {
+        return {
+          uri,
+          hash,
+          symbols: [],
+          references: [],
+          imports: [],
+          isSkipped: true,
+          skipReason: 'Excluded by build constraints'
+        };
+      }
+      const bazelTargets = isGo ? this.bazelTargets?.resolve(uri) : undefined;
+      if (isOutsideBazelTargets(bazelTargets)) {
+        return {
+          uri,
+          hash,
+          symbols: [],
+          references: [],
+          imports: [],
+          isSkipped: true,
+          skipReason: 'Not in the srcs of a Bazel target'
+        };
+      }
+      const result = parser.parse(uri, source);
+      if (isGo) {
+        annotateGoModule(result.symbols, uri, this.goModules.resolve(uri));
+        annotateBazelTargets(result.symbols, bazelTargets);
+      }
+      assignCanonicalIds(result.symbols, uri, this.packagePaths);
+      attachFunctionMetrics(source, result.symbols, uri);
+      attachByteOffsets(source, result.symbols, result.references);
+      return {
+        uri,
+        hash,
+        symbols: result.symbols,
+        references: result.references,
+        imports: result.imports,
+        reExports: result.reExports,
+        diagnostics: result.diagnostics
+      };
     }
 
     // Other files use text-based indexer if enabled
@@ -38,7 +122,6 @@ export class LanguageRouter {
     }
 
     // Unknown or disabled - return empty result
-    const crypto = await import('crypto');
     const hash = crypto.createHash('sha256').update(content || '').digest('hex');
     return { uri, hash, symbols: [], references: [], imports: [] };
   }
@@ -0,0 +1,51 @@
+import { LanguageParser } from './languageParser.js';
+import { GoIndexer } from './goIndexer.js';
+import { GoAsmIndexer } from './goAsmIndexer.js';
+import { PythonIndexer } from './pythonIndexer.js';
+import { ProtoIndexer } from './protoIndexer.js';
+import { GoTemplateIndexer } from './goTemplateIndexer.js';
+import { ConfigFileIndexer } from './configFileIndexer.js';
+import * as path from 'path';
+
+/**
+ * Maps file extensions to parsers. The last parser registered for an
+ * extension wins, so defaults can be overridden.
+ */
+export class ParserRegistry {
+  private byExtension: Map<string, LanguageParser> = new Map();
+
+  register(parser: LanguageParser): void {
+    for (const ext of parser.extensions) {
+      this.byExtension.set(ext.toLowerCase(), parser);
+    }
+  }
+
+  getParser(uri: string): LanguageParser | undefined {
+    return this.byExtension.get(path.extname(uri).toLowerCase());
+  }
+
+  getExtensions(): string[] {
+    return Array.from(this.byExtension.keys());
+  }
+
+  getLanguages(): string[] {
+    return Array.from(new Set(Array.from(this.byExtension.values(), parser => parser.language)));
+  }
+}
+
+/**
+ * Registry with the built-in structural parsers (Go, Go assembly, Python,
+ * Protocol Buffers, Go templates) and the key indexers of YAML and JSON config files.
+ * TypeScript/JavaScript go through the AST indexer and its framework plugins.
+ */
+export function createDefaultParserRegistry(): ParserRegistry {
+  const registry = new ParserRegistry();
+  registry.register(new GoIndexer());
+  registry.register(new GoAsmIndexer());
+  registry.register(new PythonIndexer());
+  registry.register(new ProtoIndexer());
+  registry.register(new GoTemplateIndexer());
+  registry.register(new ConfigFileIndexer('yaml'));
+  registry.register(new ConfigFileIndexer('json'));
+  return registry;
+}
@@ -0,0 +1,282 @@
+/**
+ * Protocol Buffers Indexer Tests
+ *
+ * Messages, fields, enums, services and RPCs, their Go names, and linking
+ * protoc-gen-go(-grpc) output back to the .proto definitions.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ProtoIndexer, ProtoSymbolMetadata, goCamelCase, protoDefinitions, generatedGoDefinitions } from './protoIndexer.js';
+import { GoIndexer, GoSymbolMetadata } from './goIndexer.js';
+import { MockIndex } from '../test/mocks/MockIndex.js';
+import { IndexedSymbol } from '../types.js';
+
+const protoSource = `syntax = "proto3";
+
+package greet.v1;
+
+import "google/protobuf/timestamp.proto";
+import public "greet/v1/common.proto";
+
+option go_package = "example.com/gen/greet/v1;greetv1";
+
+// A person to greet.
+message Person {
+  string name = 1;
+  repeated Address addresses = 2 [deprecated = true];
+  map<string, Person> friends = 3;
+  google.protobuf.Timestamp born_at = 4;
+
+  message Address {
+    string city = 1;
+  }
+
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_ROBOT = -1;
+  }
+
+  oneof contact {
+    string email = 5;
+    string phone = 6;
+  }
+  reserved 7, 8;
+}
+
+/* Status of a greeting */
+enum Status {
+  option allow_alias = true;
+  STATUS_UNKNOWN = 0;
+  STATUS_OK = 0x1;
+}
+
+service Greeter {
+  // Greets one person.
+  rpc SayHello(Person) returns (HelloReply);
+  rpc Chat(stream Person) returns (stream HelloReply) {
+    option (google.api.http) = { post: "/v1/chat" body: "*" };
+  }
+}
+
+message HelloReply { string message = 1; }
+`;
+
+const pbGo = `// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.27.1
+// source: greet/v1/greet.proto
+
+package greetv1
+
+// A person to greet.
+type Person struct {
+	Name string \`protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"\`
+	// Types that are assignable to Contact:
+	Contact isPerson_Contact \`protobuf_oneof:"contact"\`
+}
+
+func (x *Person) GetName() string { return x.Name }
+
+func (x *Person) String() string { return "" }
+
+type Person_Email struct {
+	Email string \`protobuf:"bytes,5,opt,name=email,proto3,oneof"\`
+}
+
+type Person_Address struct {
+	City string
+}
+
+type Person_Kind int32
+
+const (
+	Person_KIND_UNSPECIFIED Person_Kind = 0
+	Person_KIND_ROBOT       Person_Kind = -1
+)
+
+type Status int32
+
+const (
+	Status_STATUS_UNKNOWN Status = 0
+)
+`;
+
+const grpcGo = `// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// source: greet/v1/greet.proto
+
+package greetv1
+
+type GreeterClient interface {
+	SayHello(ctx context.Context, in *Person) (*HelloReply, error)
+}
+
+type greeterClient struct{}
+
+func (c *greeterClient) SayHello(ctx context.Context, in *Person) (*HelloReply, error) { return nil, nil }
+
+func NewGreeterClient(cc grpc.ClientConnInterface) GreeterClient { return &greeterClient{} }
+
+type UnimplementedGreeterServer struct{}
+
+func (UnimplementedGreeterServer) SayHello(context.Context, *Person) (*HelloReply, error) { return nil, nil }
+`;
+
+function protoMeta(symbol: IndexedSymbol | undefined): ProtoSymbolMetadata {
+  return symbol?.metadata?.proto as ProtoSymbolMetadata;
+}
+
+describe('ProtoIndexer', () => {
+  const result = new ProtoIndexer().parse('/ws/greet/v1/greet.proto', protoSource);
+  const find = (container: string | undefined, name: string) =>
+    result.symbols.find(s => s.containerName === container && s.name === name);
+
+  it('should index messages, fields, enums, services and RPCs', () => {
+    expect(result.symbols.map(s => `${s.kind}:${s.containerName ? s.containerName + '.' : ''}${s.name}`)).toEqual([
+      'struct:Person',
+      'field:Person.name',
+      'field:Person.addresses',
+      'field:Person.friends',
+      'field:Person.born_at',
+      'struct:Person.Address',
+      'field:Address.city',
+      'enum:Person.Kind',
+      'enumMember:Kind.KIND_UNSPECIFIED',
+      'enumMember:Kind.KIND_ROBOT',
+      'field:Person.email',
+      'field:Person.phone',
+      'enum:Status',
+      'enumMember:Status.STATUS_UNKNOWN',
+      'enumMember:Status.STATUS_OK',
+      'interface:Greeter',
+      'method:Greeter.SayHello',
+      'method:Greeter.Chat',
+      'struct:HelloReply',
+      'field:HelloReply.message'
+    ]);
+    expect(result.diagnostics).toBeUndefined();
+
+    const person = find(undefined, 'Person')!;
+    expect(person.doc).toBe('A person to greet.');
+    expect(person.location).toEqual({ uri: '/ws/greet/v1/greet.proto', line: 10, character: 8 });
+    expect(person.range).toMatchObject({ startLine: 10, startCharacter: 0, endLine: 30, endCharacter: 1 });
+    expect(find(undefined, 'Status')!.doc).toBe('Status of a greeting');
+    expect(find('Greeter', 'SayHello')!.doc).toBe('Greets one person.');
+  });
+
+  it('should record field types, numbers and RPC signatures', () => {
+    expect(protoMeta(find('Person', 'addresses'))).toEqual({
+      package: 'greet.v1', fullName: 'greet.v1.Person.addresses', goName: 'Addresses', goContainer: 'Person',
+      type: 'repeated Address', number: 2
+    });
+    expect(protoMeta(find('Person', 'friends')).type).toBe('map<string, Person>');
+    expect(protoMeta(find('Person', 'email')).oneof).toBe('contact');
+    expect(find('Kind', 'KIND_ROBOT')!.value).toBe('-1');
+    expect(protoMeta(find('Status', 'STATUS_OK')).number).toBe(1);
+    expect(protoMeta(find('Address', 'city')).fullName).toBe('greet.v1.Person.Address.city');
+
+    const chat = find('Greeter', 'Chat')!;
+    expect(chat.signature).toBe('rpc Chat(stream Person) returns (stream HelloReply)');
+    expect(protoMeta(chat)).toMatchObject({ requestType: 'Person', responseType: 'HelloReply', clientStreaming: true, serverStreaming: true });
+    expect(chat.range).toMatchObject({ startLine: 42, endLine: 44 });
+    expect(find('Greeter', 'SayHello')!.signature).toBe('rpc SayHello(Person) returns (HelloReply)');
+  });
+
+  it('should name declarations as protoc-gen-go does', () => {
+    expect(protoMeta(find('Person', 'Address')).goName).toBe('Person_Address');
+    expect(protoMeta(find('Person', 'born_at')).goName).toBe('BornAt');
+    // Values of a nested enum are prefixed with the message, top-level ones with the enum
+    expect(protoMeta(find('Kind', 'KIND_ROBOT')).goName).toBe('Person_KIND_ROBOT');
+    expect(protoMeta(find('Status', 'STATUS_OK')).goName).toBe('Status_STATUS_OK');
+    expect(goCamelCase('user_id')).toBe('UserId');
+    expect(goCamelCase('_foo_bar2_x')).toBe('XFooBar2X');
+    expect(goCamelCase('oauth2_token')).toBe('Oauth2Token');
+  });
+
+  it('should collect type references and imports', () => {
+    expect(result.references.map(r => `${r.containerName}:${r.symbolName}@${r.location.line}:${r.location.character}`)).toEqual([
+      'Person:Address@12:11',
+      'Person:Person@13:14',
+      'Person:Timestamp@14:18',
+      'Greeter:Person@41:15',
+      'Greeter:HelloReply@41:32',
+      'Greeter:Person@42:18',
+      'Greeter:HelloReply@42:42'
+    ]);
+    expect(result.imports).toEqual([
+      { localName: 'timestamp', moduleSpecifier: 'google/protobuf/timestamp.proto' },
+      { localName: 'common', moduleSpecifier: 'greet/v1/common.proto' }
+    ]);
+  });
+
+  it('should recover from unterminated declarations', () => {
+    const broken = new ProtoIndexer().parse('/ws/broken.proto', 'message A {\n  string name = 1;\n');
+    expect(broken.symbols.map(s => s.name)).toEqual(['A', 'name']);
+    expect(broken.diagnostics).toEqual([{ message: "Expected '}'", line: 0, character: 10 }]);
+  });
+});
+
+describe('Generated protobuf Go code', () => {
+  const goIndexer = new GoIndexer();
+  const index = new MockIndex();
+  for (const symbol of [
+    ...new ProtoIndexer().parse('/ws/proto/greet/v1/greet.proto', protoSource).symbols,
+    ...goIndexer.indexFile('/ws/gen/greet/v1/greet.pb.go', pbGo).symbols,
+    ...goIndexer.indexFile('/ws/gen/greet/v1/greet_grpc.pb.go', grpcGo).symbols
+  ]) {
+    index.addSymbol(symbol);
+  }
+  const definitionsOf = async (name: string) => protoDefinitions(await index.findDefinitions(name), index);
+  const describeAll = (symbols: IndexedSymbol[]) =>
+    symbols.map(s => `${s.location.uri.split('/').pop()}:${s.containerName ? s.containerName + '.' : ''}${s.name}`);
+
+  it('should read the source .proto file from the generated header', () => {
+    const go = (uri: string) => index.getFileSymbols(uri).then(symbols => symbols[0].metadata?.go as GoSymbolMetadata);
+    return Promise.all([
+      go('/ws/gen/greet/v1/greet.pb.go').then(meta => expect(meta.protoSource).toBe('greet/v1/greet.proto')),
+      go('/ws/gen/greet/v1/greet_grpc.pb.go').then(meta => expect(meta.protoSource).toBe('greet/v1/greet.proto'))
+    ]);
+  });
+
+  it('should send generated declarations to their .proto definitions', async () => {
+    expect(describeAll(await definitionsOf('Person'))).toEqual(['greet.proto:Person']);
+    expect(describeAll(await definitionsOf('Name'))).toEqual(['greet.proto:Person.name']);
+    expect(describeAll(await definitionsOf('GetName'))).toEqual(['greet.proto:Person.name']);
+    expect(describeAll(await definitionsOf('Person_Address'))).toEqual(['greet.proto:Person.Address']);
+    expect(describeAll(await definitionsOf('Person_Email'))).toEqual(['greet.proto:Person.email']);
+    expect(describeAll(await definitionsOf('Person_KIND_ROBOT'))).toEqual(['greet.proto:Kind.KIND_ROBOT']);
+    expect(describeAll(await definitionsOf('Status_STATUS_UNKNOWN'))).toEqual(['greet.proto:Status.STATUS_UNKNOWN']);
+    expect(describeAll(await definitionsOf('GreeterClient'))).toEqual(['greet.proto:Greeter']);
+    expect(describeAll(await definitionsOf('NewGreeterClient'))).toEqual(['greet.proto:Greeter']);
+    expect(describeAll(await definitionsOf('SayHello'))).toEqual(['greet.proto:Greeter.SayHello']);
+    // Go-only methods stay in the generated code
+    expect(describeAll(await definitionsOf('String'))).toEqual(['greet.pb.go:Person.String']);
+  });
+
+  it('should find the .proto file next to the generated code', async () => {
+    const local = new MockIndex();
+    for (const symbol of [
+      ...new ProtoIndexer().parse('/ws/greet/v1/greet.proto', protoSource).symbols,
+      ...goIndexer.indexFile('/ws/greet/v1/greet.pb.go', pbGo).symbols
+    ]) {
+      local.addSymbol(symbol);
+    }
+    const definitions = await protoDefinitions(await local.findDefinitions('Person_Kind'), local);
+    expect(describeAll(definitions)).toEqual(['greet.proto:Person.Kind']);
+  });
+
+  it('should find the generated Go declarations of a .proto symbol', async () => {
+    const protoSymbols = await index.getFileSymbols('/ws/proto/greet/v1/greet.proto');
+    const generatedFor = async (container: string | undefined, name: string) => describeAll(await generatedGoDefinitions(
+      protoSymbols.find(s => s.containerName === container && s.name === name)!, index
+    )).sort();
+
+    expect(await generatedFor('Person', 'name')).toEqual(['greet.pb.go:Person.GetName', 'greet.pb.go:Person.Name']);
+    expect(await generatedFor('Greeter', 'SayHello')).toEqual([
+      'greet_grpc.pb.go:GreeterClient.SayHello',
+      'greet_grpc.pb.go:UnimplementedGreeterServer.SayHello',
+      'greet_grpc.pb.go:greeterClient.SayHello'
+    ]);
+    expect(await generatedFor(undefined, 'HelloReply')).toEqual([]);
+  });
+});
@@ -0,0 +1,816 @@
+import * as path from 'path';
+import { IndexedSymbol, IndexedReference, ImportInfo, ParseDiagnostic } from '../types.js';
+import { ISymbolIndex } from '../index/ISymbolIndex.js';
+import { createSymbolId } from './symbolResolver.js';
+import { cleanGoComment, cleanJsDocComment, setSymbolDoc } from '../utils/docComments.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+import type { GoSymbolMetadata } from './goIndexer.js';
+
+/**
+ * Protocol Buffers symbol metadata, stored under `symbol.metadata.proto`.
+ */
+export interface ProtoSymbolMetadata {
+  /** Package statement of the declaring file */
+  package?: string;
+  /** Fully qualified name, e.g. `greet.v1.Person.name` */
+  fullName: string;
+  /** Name protoc-gen-go gives the declaration, e.g. `Person_Address` for a nested message */
+  goName: string;
+  /** Fields: Go name of the message; RPCs: Go name of the service */
+  goContainer?: string;
+  /** Fields and enum values: the field or value number */
+  number?: number;
+  /** Fields: the type as written, with its label, e.g. `repeated Person` or `map<string, Person>` */
+  type?: string;
+  /** Fields of a `oneof`: its name */
+  oneof?: string;
+  /** RPCs: request and response message types */
+  requestType?: string;
+  responseType?: string;
+  clientStreaming?: boolean;
+  serverStreaming?: boolean;
+}
+
+const SCALAR_TYPES = new Set([
+  'double', 'float', 'int32', 'int64', 'uint32', 'uint64', 'sint32', 'sint64',
+  'fixed32', 'fixed64', 'sfixed32', 'sfixed64', 'bool', 'string', 'bytes'
+]);
+
+const FIELD_LABELS = new Set(['repeated', 'optional', 'required']);
+
+interface ProtoToken {
+  text: string;
+  type: 'ident' | 'number' | 'string' | 'punct';
+  offset: number;
+  line: number;
+  character: number;
+}
+
+interface ProtoComment {
+  text: string;
+  line: number;
+  endLine: number;
+  /** Nothing but whitespace before the comment on its line */
+  ownLine: boolean;
+}
+
+/** The message, enum or service a declaration sits in */
+interface Scope {
+  /** Proto names from the outermost message in */
+  names: string[];
+  goName?: string;
+  kind?: string;
+}
+
+/**
+ * Protocol Buffers parser: messages (nested ones too), their fields,
+ * enums and their values, services and their RPCs, for proto2, proto3
+ * and editions files.
+ *
+ * Each declaration records the Go name protoc-gen-go and
+ * protoc-gen-go-grpc generate for it, so generated .pb.go declarations
+ * link back to their .proto definitions (see protoDefinitions). Message
+ * and enum types named by fields and RPCs are references; `import`
+ * statements are imports.
+ */
+export class ProtoIndexer implements LanguageParser {
+  readonly language = 'proto';
+  readonly extensions = ['.proto'];
+
+  parse(uri: string, content: string): ParseResult {
+    const parser = new ProtoFileParser(uri, content);
+    parser.parseFile();
+    const { symbols, references, imports, diagnostics } = parser;
+    return diagnostics.length > 0 ? { symbols, references, imports, diagnostics } : { symbols, references, imports };
+  }
+}
+
+/**
+ * Go identifier for a proto name, as protoc-gen-go derives it: `user_id`
+ * becomes `UserId`, `_x` becomes `XX`.
+ */
+export function goCamelCase(name: string): string {
+  const isLower = (c: string | undefined) => c !== undefined && c >= 'a' && c <= 'z';
+  let result = '';
+  for (let i = 0; i < name.length; i++) {
+    const c = name[i];
+    if (c === '.' && isLower(name[i + 1])) {
+      continue;
+    } else if (c === '.') {
+      result += '_';
+    } else if (c === '_' && (i === 0 || name[i - 1] === '.')) {
+      result += 'X';
+    } else if (c === '_' && isLower(name[i + 1])) {
+      continue;
+    } else if (c >= '0' && c <= '9') {
+      result += c;
+    } else {
+      result += c.toUpperCase();
+      while (isLower(name[i + 1])) {
+        result += name[++i];
+      }
+    }
+  }
+  return result;
+}
+
+class ProtoFileParser {
+  symbols: IndexedSymbol[] = [];
+  references: IndexedReference[] = [];
+  imports: ImportInfo[] = [];
+  diagnostics: ParseDiagnostic[] = [];
+
+  private tokens: ProtoToken[] = [];
+  private docs = new Map<number, string>();
+  private packageName?: string;
+  private i = 0;
+
+  constructor(private uri: string, private content: string) {
+    const comments = this.tokenize();
+    this.docs = collectDocComments(comments);
+  }
+
+  parseFile(): void {
+    while (this.i < this.tokens.length) {
+      const token = this.tokens[this.i];
+      switch (token.text) {
+        case 'package':
+          this.packageName = this.tokens[this.i + 1]?.type === 'ident' ? this.tokens[this.i + 1].text : undefined;
+          this.skipStatement();
+          break;
+        case 'import':
+          this.parseImport();
+          break;
+        case 'message':
+          this.parseMessage({ names: [] });
+          break;
+        case 'enum':
+          this.parseEnum({ names: [] });
+          break;
+        case 'service':
+          this.parseService();
+          break;
+        case 'extend':
+          this.parseExtend({ names: [] });
+          break;
+        default:
+          this.skipStatement();
+      }
+    }
+  }
+
+  // ---------------------------------------------------------------------------
+  // Declarations
+  // ---------------------------------------------------------------------------
+
+  private parseImport(): void {
+    let j = this.i + 1;
+    if (this.tokens[j]?.text === 'public' || this.tokens[j]?.text === 'weak') {
+      j++;
+    }
+    const token = this.tokens[j];
+    if (token?.type === 'string') {
+      const specifier = unquote(token.text);
+      this.imports.push({ localName: path.posix.basename(specifier, '.proto'), moduleSpecifier: specifier });
+    }
+    this.skipStatement();
+  }
+
+  private parseMessage(scope: Scope): void {
+    const start = this.tokens[this.i];
+    const nameToken = this.tokens[this.i + 1];
+    if (nameToken?.type !== 'ident') {
+      this.skipStatement();
+      return;
+    }
+    const names = [...scope.names, nameToken.text];
+    const goName = names.map(goCamelCase).join('_');
+    const symbol = this.addSymbol(nameToken, 'struct', start, scope, { goName });
+    this.i += 2;
+    const inner: Scope = { names, goName, kind: 'struct' };
+    this.parseBody(symbol, () => this.parseMessageMember(inner));
+  }
+
+  private parseMessageMember(scope: Scope, oneof?: string): void {
+    const token = this.tokens[this.i];
+    switch (token.text) {
+      case 'message':
+        this.parseMessage(scope);
+        return;
+      case 'enum':
+        this.parseEnum(scope);
+        return;
+      case 'extend':
+        this.parseExtend(scope);
+        return;
+      case 'oneof': {
+        const nameToken = this.tokens[this.i + 1];
+        if (nameToken?.type === 'ident' && this.tokens[this.i + 2]?.text === '{') {
+          this.i += 2;
+          this.parseBody(undefined, () => this.parseMessageMember(scope, nameToken.text));
+          return;
+        }
+        break;
+      }
+      case 'option':
+      case 'reserved':
+      case 'extensions':
+        break;
+      default:
+        if (token.type === 'ident') {
+          this.parseField(scope, oneof);
+          return;
+        }
+    }
+    this.skipStatement();
+  }
+
+  /**
+   * `[label] Type name = N [options];` or `map<K, V> name = N;`
+   */
+  private parseField(scope: Scope, oneof?: string): void {
+    const start = this.tokens[this.i];
+    const end = this.statementEnd(this.i);
+    const tokens = this.tokens.slice(this.i, end);
+    const equals = tokens.findIndex(token => token.text === '=');
+    const nameToken = tokens[equals - 1];
+    if (equals < 2 || nameToken.type !== 'ident' || tokens[0].text === 'group' || tokens[1]?.text === 'group') {
+      this.skipStatement();
+      return;
+    }
+
+    const typeTokens = tokens.slice(0, equals - 1);
+    const type = typeTokens.map(token => token.text).join(' ').replace(/ ?([<>,]) ?/g, (_, c: string) => c === ',' ? ', ' : c);
+    for (const token of typeTokens) {
+      if (token.type === 'ident' && !FIELD_LABELS.has(token.text) && !SCALAR_TYPES.has(token.text) && token.text !== 'map') {
+        this.addReference(token, scope);
+      }
+    }
+    const numberToken = tokens[equals + 1];
+    const proto: Omit<ProtoSymbolMetadata, 'fullName'> = {
+      goName: goCamelCase(nameToken.text),
+      goContainer: scope.goName,
+      type,
+      ...(numberToken?.type === 'number' && { number: parseNumber(numberToken.text) }),
+      ...(oneof && { oneof })
+    };
+    this.i = end;
+    const endToken = this.tokens[this.i];
+    this.addSymbol(nameToken, 'field', start, scope, proto, endToken?.text === ';' ? endToken : tokens[tokens.length - 1]);
+    this.skipStatement();
+  }
+
+  private parseEnum(scope: Scope): void {
+    const start = this.tokens[this.i];
+    const nameToken = this.tokens[this.i + 1];
+    if (nameToken?.type !== 'ident') {
+      this.skipStatement();
+      return;
+    }
+    const names = [...scope.names, nameToken.text];
+    const goName = names.map(goCamelCase).join('_');
+    const symbol = this.addSymbol(nameToken, 'enum', start, scope, { goName });
+    this.i += 2;
+    // Values of a nested enum are prefixed with the message, not the enum
+    const valuePrefix = scope.goName ?? goName;
+    const inner: Scope = { names, goName, kind: 'enum' };
+    this.parseBody(symbol, () => {
+      const token = this.tokens[this.i];
+      if (token.type === 'ident' && token.text !== 'option' && token.text !== 'reserved' && this.tokens[this.i + 1]?.text === '=') {
+        const negative = this.tokens[this.i + 2]?.text === '-';
+        const numberToken = this.tokens[this.i + (negative ? 3 : 2)];
+        const number = numberToken?.type === 'number' ? parseNumber(numberToken.text) * (negative ? -1 : 1) : undefined;
+        const end = this.statementEnd(this.i);
+        const value = this.addSymbol(token, 'enumMember', token, inner, {
+          goName: `${valuePrefix}_${token.text}`,
+          ...(number !== undefined && { number })
+        }, this.tokens[end]?.text === ';' ? this.tokens[end] : this.tokens[end - 1]);
+        if (number !== undefined) {
+          value.value = String(number);
+        }
+      }
+      this.skipStatement();
+    });
+  }
+
+  private parseService(): void {
+    const start = this.tokens[this.i];
+    const nameToken = this.tokens[this.i + 1];
+    if (nameToken?.type !== 'ident') {
+      this.skipStatement();
+      return;
+    }
+    const goName = goCamelCase(nameToken.text);
+    const symbol = this.addSymbol(nameToken, 'interface', start, { names: [] }, { goName });
+    this.i += 2;
+    const inner: Scope = { names: [nameToken.text], goName, kind: 'interface' };
+    this.parseBody(symbol, () => {
+      if (this.tokens[this.i].text === 'rpc') {
+        this.parseRpc(inner);
+      } else {
+        this.skipStatement();
+      }
+    });
+  }
+
+  /**
+   * `rpc Name (stream Request) returns (stream Response);` or with an
+   * options block instead of the semicolon.
+   */
+  private parseRpc(scope: Scope): void {
+    const start = this.tokens[this.i];
+    const nameToken = this.tokens[this.i + 1];
+    if (nameToken?.type !== 'ident') {
+      this.skipStatement();
+      return;
+    }
+    let j = this.i + 2;
+    const messageType = (): { type?: string; stream: boolean } => {
+      if (this.tokens[j]?.text !== '(') {
+        return { stream: false };
+      }
+      j++;
+      const stream = this.tokens[j]?.text === 'stream' && this.tokens[j + 1]?.type === 'ident';
+      if (stream) {
+        j++;
+      }
+      const token = this.tokens[j];
+      if (token?.type !== 'ident') {
+        return { stream };
+      }
+      this.addReference(token, scope);
+      j++;
+      if (this.tokens[j]?.text === ')') {
+        j++;
+      }
+      return { type: token.text, stream };
+    };
+
+    const request = messageType();
+    const returns = this.tokens[j]?.text === 'returns';
+    if (returns) {
+      j++;
+    }
+    const response = returns ? messageType() : { stream: false };
+    const signature = `rpc ${nameToken.text}(${request.stream ? 'stream ' : ''}${request.type ?? ''})` +
+      ` returns (${response.stream ? 'stream ' : ''}${response.type ?? ''})`;
+
+    this.i = j;
+    const endToken = this.tokens[this.statementEnd(this.i)];
+    const hasOptions = endToken?.text === '{';
+    const symbol = this.addSymbol(nameToken, 'method', start, scope, {
+      goName: goCamelCase(nameToken.text),
+      goContainer: scope.goName,
+      ...(request.type && { requestType: request.type }),
+      ...(response.type && { responseType: response.type }),
+      ...(request.stream && { clientStreaming: true }),
+      ...(response.stream && { serverStreaming: true })
+    }, hasOptions ? undefined : endToken);
+    symbol.signature = signature;
+    if (hasOptions) {
+      this.i = this.statementEnd(this.i);
+      this.parseBody(symbol, () => this.skipStatement());
+    } else {
+      this.skipStatement();
+    }
+  }
+
+  /** `extend Type { ... }`: a reference to the extended message */
+  private parseExtend(scope: Scope): void {
+    const target = this.tokens[this.i + 1];
+    if (target?.type === 'ident') {
+      this.addReference(target, scope);
+    }
+    this.skipStatement();
+  }
+
+  // ---------------------------------------------------------------------------
+  // Symbols and references
+  // ---------------------------------------------------------------------------
+
+  private addSymbol(
+    nameToken: ProtoToken,
+    kind: string,
+    startToken: ProtoToken,
+    scope: Scope,
+    proto: Omit<ProtoSymbolMetadata, 'fullName'>,
+    endToken?: ProtoToken
+  ): IndexedSymbol {
+    const name = nameToken.text;
+    const qualified = [...(this.packageName ? [this.packageName] : []), ...scope.names];
+    const containerName = scope.names[scope.names.length - 1];
+    const fullContainerPath = containerName ? qualified.join('.') : undefined;
+    const end = endToken ?? nameToken;
+    const metadata: ProtoSymbolMetadata = {
+      ...(this.packageName && { package: this.packageName }),
+      fullName: [...qualified, name].join('.'),
+      ...proto
+    };
+
+    const symbol: IndexedSymbol = {
+      id: createSymbolId(this.uri, name, containerName, fullContainerPath, kind, false, undefined, nameToken.line, nameToken.character),
+      name,
+      kind,
+      location: { uri: this.uri, line: nameToken.line, character: nameToken.character },
+      range: {
+        startLine: startToken.line,
+        startCharacter: startToken.character,
+        endLine: end.line,
+        endCharacter: end.character + end.text.length
+      },
+      containerName,
+      containerKind: containerName ? scope.kind : undefined,
+      fullContainerPath,
+      filePath: this.uri,
+      metadata: { proto: metadata },
+      isDefinition: true,
+      isExported: true
+    };
+    const doc = this.docs.get(startToken.line - 1);
+    if (doc) {
+      setSymbolDoc(symbol, doc, 'plain');
+    }
+    this.symbols.push(symbol);
+    return symbol;
+  }
+
+  /** A type name, possibly qualified (`google.protobuf.Timestamp`): refers to its last part */
+  private addReference(token: ProtoToken, scope: Scope): void {
+    const dot = token.text.lastIndexOf('.');
+    const symbolName = token.text.slice(dot + 1);
+    const character = token.character + dot + 1;
+    this.references.push({
+      symbolName,
+      location: { uri: this.uri, line: token.line, character },
+      range: { startLine: token.line, startCharacter: character, endLine: token.line, endCharacter: character + symbolName.length },
+      containerName: scope.names[scope.names.length - 1]
+    });
+  }
+
+  // ---------------------------------------------------------------------------
+  // Token navigation
+  // ---------------------------------------------------------------------------
+
+  /**
+   * With the cursor after a declaration's name, parse its `{ ... }` body
+   * one member at a time and extend the symbol's range to the closing brace.
+   */
+  private parseBody(symbol: IndexedSymbol | undefined, member: () => void): void {
+    while (this.i < this.tokens.length && this.tokens[this.i].text !== '{' && this.tokens[this.i].text !== ';') {
+      this.i++;
+    }
+    if (this.tokens[this.i]?.text !== '{') {
+      this.i++;
+      return;
+    }
+    const open = this.tokens[this.i++];
+    while (this.i < this.tokens.length && this.tokens[this.i].text !== '}') {
+      const before = this.i;
+      member();
+      if (this.i === before) {
+        this.i++;
+      }
+    }
+    const close = this.tokens[this.i];
+    if (!close) {
+      this.diagnostics.push({ message: "Expected '}'", line: open.line, character: open.character });
+      return;
+    }
+    this.i++;
+    if (symbol) {
+      symbol.range.endLine = close.line;
+      symbol.range.endCharacter = close.character + 1;
+    }
+  }
+
+  /** Index of the `;` or `{` ending the statement at j, skipping bracketed parts */
+  private statementEnd(j: number): number {
+    let depth = 0;
+    for (; j < this.tokens.length; j++) {
+      const text = this.tokens[j].text;
+      if (text === '[' || text === '(' || text === '<') {
+        depth++;
+      } else if (text === ']' || text === ')' || text === '>') {
+        depth--;
+      } else if (depth <= 0 && (text === ';' || text === '{' || text === '}')) {
+        return j;
+      }
+    }
+    return j;
+  }
+
+  /** Move past the statement at the cursor: through its `;` or its `{ ... }` block */
+  private skipStatement(): void {
+    const end = this.statementEnd(this.i);
+    const token = this.tokens[end];
+    if (token?.text === '{') {
+      let depth = 0;
+      for (this.i = end; this.i < this.tokens.length; this.i++) {
+        const text = this.tokens[this.i].text;
+        if (text === '{') {
+          depth++;
+        } else if (text === '}' && --depth === 0) {
+          break;
+        }
+      }
+      this.i++;
+    } else {
+      // A `}` ends the enclosing body: leave it for the caller
+      this.i = token?.text === ';' ? end + 1 : Math.max(end, this.i + 1);
+    }
+  }
+
+  // ---------------------------------------------------------------------------
+  // Tokenizer
+  // ---------------------------------------------------------------------------
+
+  private tokenize(): ProtoComment[] {
+    const content = this.content;
+    const comments: ProtoComment[] = [];
+    let line = 0;
+    let lineStart = 0;
+    let lineHasToken = false;
+    let i = 0;
+
+    const newlines = (from: number, to: number) => {
+      for (let k = from; k < to; k++) {
+        if (content[k] === '\n') {
+          line++;
+          lineStart = k + 1;
+          lineHasToken = false;
+        }
+      }
+    };
+
+    while (i < content.length) {
+      const c = content[i];
+      if (c === '\n' || c === ' ' || c === '\t' || c === '\r' || c === '\f' || c === '\v') {
+        newlines(i, i + 1);
+        i++;
+        continue;
+      }
+
+      const startLine = line;
+      if (content.startsWith('//', i)) {
+        const end = content.indexOf('\n', i);
+        const stop = end === -1 ? content.length : end;
+        comments.push({ text: content.slice(i, stop).replace(/\r$/, ''), line, endLine: line, ownLine: !lineHasToken });
+        i = stop;
+        continue;
+      }
+      if (content.startsWith('/*', i)) {
+        const end = content.indexOf('*/', i + 2);
+        const stop = end === -1 ? content.length : end + 2;
+        if (end === -1) {
+          this.diagnostics.push({ message: 'Unterminated comment', line, character: i - lineStart });
+        }
+        const ownLine = !lineHasToken;
+        newlines(i, stop);
+        comments.push({ text: content.slice(i, stop), line: startLine, endLine: line, ownLine });
+        i = stop;
+        continue;
+      }
+
+      const character = i - lineStart;
+      let end: number;
+      let type: ProtoToken['type'];
+      if (c === '"' || c === "'") {
+        end = i + 1;
+        while (end < content.length && content[end] !== c && content[end] !== '\n') {
+          end += content[end] === '\\' ? 2 : 1;
+        }
+        if (content[end] !== c) {
+          this.diagnostics.push({ message: 'Unterminated string', line, character });
+        } else {
+          end++;
+        }
+        type = 'string';
+      } else if (/[A-Za-z_.]/.test(c) && /^\.?[A-Za-z_]/.test(content.slice(i, i + 2))) {
+        const match = /^\.?[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*/.exec(content.slice(i, i + 512))!;
+        end = i + match[0].length;
+        type = 'ident';
+      } else if (/[0-9]/.test(c)) {
+        const match = /^[0-9][\w.]*/.exec(content.slice(i, i + 64))!;
+        end = i + match[0].length;
+        type = 'number';
+      } else {
+        end = i + 1;
+        type = 'punct';
+      }
+      this.tokens.push({ text: content.slice(i, end), type, offset: i, line, character });
+      lineHasToken = true;
+      i = end;
+    }
+    return comments;
+  }
+}
+
+/**
+ * Doc comments by the line they end on: consecutive `//` lines on their
+ * own form one group, a `/* *\/` block is a group of its own.
+ */
+function collectDocComments(comments: ProtoComment[]): Map<number, string> {
+  const docs = new Map<number, string>();
+  let group: ProtoComment[] = [];
+
+  const flush = () => {
+    if (group.length > 0) {
+      const text = group[0].text.startsWith('//')
+        ? cleanGoComment(group.map(comment => comment.text))
+        : cleanJsDocComment(group[0].text.replace(/^\/\*+/, '/**')).trim();
+      if (text) {
+        docs.set(group[group.length - 1].endLine, text);
+      }
+      group = [];
+    }
+  };
+
+  for (const comment of comments) {
+    const isLineComment = comment.text.startsWith('//');
+    const previous = group[group.length - 1];
+    if (!comment.ownLine || !isLineComment || !previous?.text.startsWith('//') || previous.endLine + 1 !== comment.line) {
+      flush();
+    }
+    if (comment.ownLine) {
+      group.push(comment);
+      if (!isLineComment) {
+        flush();
+      }
+    }
+  }
+  flush();
+  return docs;
+}
+
+function unquote(text: string): string {
+  return text.slice(1, text.endsWith(text[0]) && text.length > 1 ? -1 : undefined).replace(/\\(.)/g, '$1');
+}
+
+function parseNumber(text: string): number {
+  return /^0[xX]/.test(text) ? parseInt(text, 16) : /^0[0-7]+$/.test(text) ? parseInt(text, 8) : Number(text);
+}
+
+// ---------------------------------------------------------------------------
+// Generated Go code
+// ---------------------------------------------------------------------------
+
+/**
+ * `container.name` keys of the Go declarations generated for a proto
+ * symbol (no container: `.name`): a message's struct, a field's struct
+ * field and getter, a service's client and server interfaces, their
+ * constructors and the unimplemented server, an RPC's methods on those.
+ */
+export function generatedGoKeys(symbol: IndexedSymbol): string[] {
+  const proto = symbol.metadata?.proto as ProtoSymbolMetadata | undefined;
+  if (!proto) {
+    return [];
+  }
+  const { goName, goContainer } = proto;
+  switch (symbol.kind) {
+    case 'field': {
+      const keys = [`${goContainer}.${goName}`, `${goContainer}.Get${goName}`];
+      if (proto.oneof) {
+        // The oneof wrapper type, e.g. Person_Email{Email: ...}
+        keys.push(`.${goContainer}_${goName}`, `${goContainer}_${goName}.${goName}`);
+      }
+      return keys;
+    }
+    case 'interface':
+      return [
+        `.${goName}Client`, `.${goName}Server`, `.New${goName}Client`, `.Register${goName}Server`,
+        `.Unimplemented${goName}Server`, `.Unsafe${goName}Server`
+      ];
+    case 'method': {
+      const service = goContainer ?? '';
+      const client = service.charAt(0).toLowerCase() + service.slice(1) + 'Client';
+      return [`${service}Client`, `${service}Server`, `Unimplemented${service}Server`, client].map(type => `${type}.${goName}`);
+    }
+    default:
+      return [`.${goName}`];
+  }
+}
+
+function goKey(symbol: IndexedSymbol): string {
+  return `${symbol.containerName ?? ''}.${symbol.name}`;
+}
+
+/** Does the .proto file at filePath match a `// source:` path (given relative to a protoc include directory)? */
+function isProtoSource(filePath: string, source: string): boolean {
+  const file = filePath.split(path.sep).join('/');
+  return file === source || file.endsWith('/' + source);
+}
+
+/**
+ * Replace the definitions generated by protoc-gen-go(-grpc) with the
+ * .proto declarations they come from, so go-to-definition on a generated
+ * type or its use lands on the .proto source. Other definitions, and
+ * generated ones without a proto counterpart (e.g. `String()`), are kept.
+ *
+ * The .proto file is looked for at its `// source:` path relative to each
+ * directory above the generated file, then by the names it declares.
+ */
+export async function protoDefinitions(
+  definitions: IndexedSymbol[],
+  index: Pick<ISymbolIndex, 'findDefinitions' | 'getFileSymbols'>
+): Promise<IndexedSymbol[]> {
+  if (!definitions.some(symbol => (symbol.metadata?.go as GoSymbolMetadata | undefined)?.protoSource)) {
+    return definitions;
+  }
+  const results: IndexedSymbol[] = [];
+  const seen = new Set<string>();
+  const add = (symbol: IndexedSymbol) => {
+    if (!seen.has(symbol.id)) {
+      seen.add(symbol.id);
+      results.push(symbol);
+    }
+  };
+
+  const keysByFile = new Map<string, Promise<Map<string, IndexedSymbol> | undefined>>();
+  for (const symbol of definitions) {
+    const source = (symbol.metadata?.go as GoSymbolMetadata | undefined)?.protoSource;
+    if (!source) {
+      add(symbol);
+      continue;
+    }
+    const cacheKey = `${path.dirname(symbol.location.uri)}\0${source}`;
+    let keys = keysByFile.get(cacheKey);
+    if (!keys) {
+      keys = protoKeysFor(symbol, source, index);
+      keysByFile.set(cacheKey, keys);
+    }
+    add((await keys)?.get(goKey(symbol)) ?? symbol);
+  }
+  return results;
+}
+
+async function protoKeysFor(
+  generated: IndexedSymbol,
+  source: string,
+  index: Pick<ISymbolIndex, 'findDefinitions' | 'getFileSymbols'>
+): Promise<Map<string, IndexedSymbol> | undefined> {
+  const protoSymbols = await findProtoFile(generated, source, index);
+  if (!protoSymbols) {
+    return undefined;
+  }
+  const keys = new Map<string, IndexedSymbol>();
+  for (const symbol of protoSymbols) {
+    for (const key of generatedGoKeys(symbol)) {
+      if (!keys.has(key)) {
+        keys.set(key, symbol);
+      }
+    }
+  }
+  return keys;
+}
+
+async function findProtoFile(
+  generated: IndexedSymbol,
+  source: string,
+  index: Pick<ISymbolIndex, 'findDefinitions' | 'getFileSymbols'>
+): Promise<IndexedSymbol[] | undefined> {
+  const isProto = (symbols: IndexedSymbol[]) => symbols.some(symbol => symbol.metadata?.proto);
+
+  for (let dir = path.dirname(generated.location.uri); ; dir = path.dirname(dir)) {
+    const symbols = await index.getFileSymbols(path.join(dir, source));
+    if (isProto(symbols)) {
+      return symbols;
+    }
+    if (path.dirname(dir) === dir) {
+      break;
+    }
+  }
+
+  // Generated elsewhere (`protoc -I proto --go_out=gen`): find the file by a name it declares
+  const outer = (generated.containerName ?? generated.name).replace(/^(New|Register|Unimplemented|Unsafe)/, '');
+  const candidates = new Set([outer.split('_')[0], outer.replace(/(Client|Server)$/, '')]);
+  for (const name of candidates) {
+    for (const symbol of await index.findDefinitions(name.charAt(0).toUpperCase() + name.slice(1))) {
+      if (symbol.metadata?.proto && isProtoSource(symbol.location.uri, source)) {
+        return index.getFileSymbols(symbol.location.uri);
+      }
+    }
+  }
+  return undefined;
+}
+
+/**
+ * The Go declarations protoc-gen-go(-grpc) generated for a proto symbol,
+ * for go-to-implementation from a .proto file.
+ */
+export async function generatedGoDefinitions(
+  symbol: IndexedSymbol,
+  index: Pick<ISymbolIndex, 'findDefinitions'>
+): Promise<IndexedSymbol[]> {
+  const keys = new Set(generatedGoKeys(symbol));
+  const names = new Set([...keys].map(key => key.slice(key.indexOf('.') + 1)));
+  const results: IndexedSymbol[] = [];
+  for (const name of names) {
+    for (const candidate of await index.findDefinitions(name)) {
+      const source = (candidate.metadata?.go as GoSymbolMetadata | undefined)?.protoSource;
+      if (source && isProtoSource(symbol.location.uri, source) && keys.has(goKey(candidate))) {
+        results.push(candidate);
+      }
+    }
+  }
+  return results;
+}
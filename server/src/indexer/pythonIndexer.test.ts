@@ -0,0 +1,237 @@
+/**
+ * Python Indexer Tests
+ *
+ * Validates indentation-based extraction of Python declarations, imports
+ * and identifier references, and routing through the parser registry.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { PythonIndexer, PythonSymbolMetadata } from './pythonIndexer.js';
+import { PythonTokenizer } from './components/PythonTokenizer.js';
+import { createDefaultParserRegistry } from './parserRegistry.js';
+import { IndexedSymbol } from '../types.js';
+
+const pySource = `import os.path as osp
+from .models import User, Group as G
+
+MAX_USERS = 100
+_cache = {}
+
+@dataclass
+class UserService(BaseService, metaclass=Meta):
+    """Loads users."""
+    default_limit: int = 10
+
+    def __init__(self, repo, *, limit=MAX_USERS):
+        self.repo = repo
+        self._limit = limit
+
+    @property
+    def limit(self):
+        return self._limit
+
+    @limit.setter
+    def limit(self, value):
+        self._limit = value
+
+    @staticmethod
+    def normalize(name: str) -> str:
+        return name.strip()
+
+    async def load(self, user_id):
+        result = self.repo.find(user_id)
+        for item in result:
+            process(item)
+        return User(result)
+
+def helper(x, y=(1,
+                 2)):
+    return x
+`;
+
+function find(symbols: IndexedSymbol[], name: string, kind?: string): IndexedSymbol {
+  const symbol = symbols.find(s => s.name === name && (!kind || s.kind === kind));
+  expect(symbol).toBeDefined();
+  return symbol!;
+}
+
+describe('PythonTokenizer', () => {
+  it('should end logical lines outside brackets only', () => {
+    const { tokens, comments } = new PythonTokenizer('x = f(1,\n  2)  # c\ny = """a\nb"""\n').tokenize();
+
+    expect(tokens.filter(t => t.type === 'newline')).toHaveLength(2);
+    expect(tokens.find(t => t.type === 'string')!.text).toBe('"""a\nb"""');
+    expect(comments.map(c => c.text)).toEqual(['# c']);
+  });
+});
+
+describe('PythonIndexer', () => {
+  const indexer = new PythonIndexer();
+  const uri = '/repo/app/services.py';
+
+  it('should extract classes, methods, attributes and module variables', () => {
+    const { symbols } = indexer.parse(uri, pySource);
+
+    const cls = find(symbols, 'UserService', 'class');
+    expect(cls.extends).toBe('BaseService');
+    expect((cls.metadata!.python as PythonSymbolMetadata).bases).toEqual(['BaseService']);
+    expect((cls.metadata!.python as PythonSymbolMetadata).decorators).toEqual(['dataclass']);
+    expect(cls.range.startLine).toBe(6);
+    expect(cls.range.endLine).toBe(31);
+
+    const init = find(symbols, '__init__', 'method');
+    expect(init.containerName).toBe('UserService');
+    expect(init.visibility).toBe('public');
+    expect(init.parametersCount).toBe(2);
+
+    // Property with setter: one symbol
+    expect(symbols.filter(s => s.name === 'limit')).toHaveLength(1);
+    expect(find(symbols, 'limit').kind).toBe('property');
+
+    const normalize = find(symbols, 'normalize', 'method');
+    expect(normalize.isStatic).toBe(true);
+    expect(normalize.parametersCount).toBe(1);
+    expect((normalize.metadata!.python as PythonSymbolMetadata).type).toBe('str');
+
+    expect((find(symbols, 'load', 'method').metadata!.python as PythonSymbolMetadata).async).toBe(true);
+
+    expect(find(symbols, 'default_limit', 'property').containerName).toBe('UserService');
+    expect(find(symbols, 'repo', 'property').containerName).toBe('UserService');
+    expect(find(symbols, '_limit', 'property').visibility).toBe('protected');
+
+    expect(find(symbols, 'MAX_USERS').kind).toBe('constant');
+    expect(find(symbols, '_cache').isExported).toBe(false);
+    expect(find(symbols, 'helper', 'function').parametersCount).toBe(2);
+    expect(find(symbols, 'helper').range.endLine).toBe(35);
+  });
+
+  it('should extract imports and mark local and call references', () => {
+    const { imports, references } = indexer.parse(uri, pySource);
+
+    expect(imports).toEqual([
+      { localName: 'osp', moduleSpecifier: 'os.path', isNamespace: true },
+      { localName: 'User', moduleSpecifier: '.models' },
+      { localName: 'G', moduleSpecifier: '.models', exportedName: 'Group' }
+    ]);
+
+    const userRefs = references.filter(r => r.symbolName === 'User');
+    expect(userRefs).toHaveLength(2);
+    expect(userRefs[0].isImport).toBe(true);
+    expect(userRefs[1].isCall).toBe(true);
+    expect(userRefs[1].containerName).toBe('UserService.load');
+
+    const find = references.find(r => r.symbolName === 'find')!;
+    expect(find.isCall).toBe(true);
+    expect(find.isLocal).toBeUndefined();
+
+    expect(references.filter(r => r.symbolName === 'item').every(r => r.isLocal)).toBe(true);
+    expect(references.find(r => r.symbolName === 'process')!.isLocal).toBeUndefined();
+    expect(references.find(r => r.symbolName === 'MAX_USERS')!.containerName).toBe('UserService.__init__');
+    // Declaration names are not references
+    expect(references.some(r => r.symbolName === 'helper' || r.symbolName === 'normalize')).toBe(false);
+  });
+
+  it('should attach class and function docstrings', () => {
+    const { symbols } = indexer.parse(uri, [
+      'class UserService:',
+      '    r"""Loads users.',
+      '',
+      '    Args:',
+      '        db: the database',
+      '    """',
+      '',
+      '    def load(self, user_id):',
+      "        'Load one user.'",
+      '        return user_id',
+      '',
+      'def helper():',
+      '    x = "not a docstring"',
+      '    return x',
+      ''
+    ].join('\n'));
+
+    expect(find(symbols, 'UserService').doc).toBe('Loads users.\n\nArgs:\n    db: the database');
+    expect(find(symbols, 'load').doc).toBe('Load one user.');
+    expect(find(symbols, 'helper').doc).toBeUndefined();
+  });
+
+  it('should record function and method signatures', () => {
+    const { symbols } = indexer.parse(uri, [
+      'class Repo:',
+      '    async def find(self, user_id: int, *, active: bool = True) -> "User | None":',
+      '        pass',
+      '',
+      'def merge(a, b: dict[str, int]):',
+      '    pass',
+      ''
+    ].join('\n'));
+
+    expect(find(symbols, 'find').signature).toBe('(self, user_id: int, *, active: bool = True) -> "User | None"');
+    expect(find(symbols, 'merge').signature).toBe('(a, b: dict[str, int])');
+    expect(find(symbols, 'Repo').signature).toBeUndefined();
+  });
+
+  it('should record constant initializers', () => {
+    const { symbols } = indexer.parse(uri, [
+      'MAX_RETRIES = 5',
+      'TIMEOUT: float = 60 * 1.5',
+      'HOSTS = (',
+      '    "a",',
+      '    "b",',
+      ')',
+      'A = B = 1',
+      'counter = 0',
+      ''
+    ].join('\n'));
+
+    expect(find(symbols, 'MAX_RETRIES').value).toBe('5');
+    expect(find(symbols, 'TIMEOUT').value).toBe('60 * 1.5');
+    expect(find(symbols, 'HOSTS').value).toBe('( "a", "b", )');
+    expect(find(symbols, 'A').value).toBeUndefined();
+    expect(find(symbols, 'counter').value).toBeUndefined();
+  });
+
+  it('should restrict exports to __all__ when present', () => {
+    const { symbols } = indexer.parse(uri, `__all__ = ["public_api"]\n\ndef public_api():\n    pass\n\ndef other():\n    pass\n`);
+
+    expect(find(symbols, 'public_api').isExported).toBe(true);
+    expect(find(symbols, 'other').isExported).toBe(false);
+  });
+
+  it('should keep indexing after unclosed brackets and strings', () => {
+    const broken = [
+      'def first(a, b:',
+      '    return a',
+      '',
+      'class Service:',
+      '    name = "unterminated',
+      '    def run(self):',
+      '        return [1, 2)',
+      '',
+      'def last():',
+      '    pass'
+    ].join('\n');
+    const result = indexer.parse(uri, broken);
+
+    expect(result.symbols.map(s => s.name)).toEqual(['first', 'Service', 'name', 'run', 'last']);
+    expect(find(result.symbols, 'run').containerName).toBe('Service');
+    expect(result.diagnostics).toEqual([
+      { message: "Unclosed '('", line: 0, character: 9 },
+      { message: 'Unterminated string literal', line: 4, character: 11 },
+      { message: "Unclosed '['", line: 6, character: 15 },
+      { message: "Unexpected ')'", line: 6, character: 20 }
+    ]);
+    expect(indexer.parse(uri, 'x = [1,\n     2]\n').diagnostics).toBeUndefined();
+  });
+});
+
+describe('ParserRegistry', () => {
+  it('should route files to parsers by extension', () => {
+    const registry = createDefaultParserRegistry();
+
+    expect(registry.getParser('/repo/main.go')!.language).toBe('go');
+    expect(registry.getParser('/repo/app/Stub.PYI')!.language).toBe('python');
+    expect(registry.getParser('/repo/src/index.ts')).toBeUndefined();
+    expect(registry.getLanguages()).toEqual(['go', 'goasm', 'python', 'proto', 'gotmpl', 'yaml', 'json']);
+  });
+});
@@ -0,0 +1,803 @@
+import { IndexedSymbol, IndexedReference, ImportInfo } from '../types.js';
+import { createSymbolId } from './symbolResolver.js';
+import { cleanDocstring, setSymbolDoc } from '../utils/docComments.js';
+import { truncateValue } from '../utils/constantValues.js';
+import { LanguageParser, ParseResult } from './languageParser.js';
+import {
+  PythonTokenizer,
+  PythonToken,
+  PYTHON_KEYWORDS,
+  PYTHON_BUILTINS
+} from './components/PythonTokenizer.js';
+
+/**
+ * Python-specific symbol metadata, stored under `symbol.metadata.python`.
+ */
+export interface PythonSymbolMetadata {
+  /** Decorator names without `@` and call arguments, e.g. `app.route` */
+  decorators?: string[];
+  /** Base class expressions of a class, in declaration order */
+  bases?: string[];
+  /** True for `async def` */
+  async?: boolean;
+  /** Return annotation of a function, or annotation of a variable/attribute */
+  type?: string;
+}
+
+/**
+ * A class or function body, as a token index range.
+ */
+interface Body {
+  kind: 'class' | 'def';
+  name: string;
+  /** Dotted path of the body, e.g. `UserService.load` */
+  path: string;
+  indent: number;
+  /** First token index inside the body (right after the name) */
+  start: number;
+  /** Token index after the body */
+  end: number;
+  symbol: IndexedSymbol;
+  /** Function bodies: parameters and assigned names */
+  locals: Set<string>;
+  /** Function bodies: names declared `global`/`nonlocal` */
+  nonLocals: Set<string>;
+  /** Class bodies: member names already emitted */
+  members: Set<string>;
+  /** Function bodies directly inside a class: name of the first parameter */
+  selfName?: string;
+  parent?: Body;
+}
+
+/**
+ * Context for indexing a single file.
+ */
+interface FileContext {
+  uri: string;
+  content: string;
+  tokenizer: PythonTokenizer;
+  tokens: PythonToken[];
+  symbols: IndexedSymbol[];
+  imports: ImportInfo[];
+  bodies: Body[];
+  /** Offsets of name tokens that declare symbols, parameters or import aliases */
+  definitionOffsets: Set<number>;
+  /** Offsets of imported names in `from x import name` */
+  importOffsets: Set<number>;
+  /** Names listed in a module-level `__all__`, if any */
+  exportList?: Set<string>;
+}
+
+const UPPER_CASE_RE = /^[A-Z][A-Z0-9_]*$/;
+
+const PROPERTY_DECORATORS = new Set(['property', 'cached_property', 'functools.cached_property']);
+const STATIC_DECORATORS = new Set(['staticmethod', 'classmethod']);
+
+const BLOCK_KEYWORDS = new Set(['if', 'elif', 'else', 'for', 'while', 'try', 'except', 'finally', 'with', 'match', 'case']);
+
+/**
+ * PythonIndexer - Structural indexer for Python source files.
+ *
+ * Block structure comes from indentation: each logical line's column is
+ * compared with the open class/def bodies. Extracts without type inference:
+ * - Classes (with bases), functions, methods and `@property` accessors,
+ *   at any nesting depth, with function and method signatures
+ * - Module-level variables and constants (UPPER_CASE, with their
+ *   initializer text), class attributes and `self.x = ...` attributes
+ *   assigned in methods
+ * - `import` / `from ... import` statements
+ * - Identifier references, with parameters and assigned names inside
+ *   functions flagged as local, and call sites flagged as calls
+ * - Class and function docstrings
+ *
+ * Module-level names are exported unless they start with `_`, or, when the
+ * module defines `__all__`, if they are listed in it.
+ *
+ * Lexical errors from the tokenizer are returned as diagnostics; the
+ * declarations around them are still indexed.
+ */
+export class PythonIndexer implements LanguageParser {
+  readonly language = 'python';
+  readonly extensions = ['.py', '.pyi'];
+
+  parse(uri: string, content: string): ParseResult {
+    const tokenizer = new PythonTokenizer(content);
+    const { tokens, errors } = tokenizer.tokenize();
+
+    const ctx: FileContext = {
+      uri,
+      content,
+      tokenizer,
+      tokens,
+      symbols: [],
+      imports: [],
+      bodies: [],
+      definitionOffsets: new Set(),
+      importOffsets: new Set()
+    };
+
+    this.parseStructure(ctx);
+    this.applyExportList(ctx);
+    const references = this.collectReferences(ctx);
+
+    const result: ParseResult = { symbols: ctx.symbols, references, imports: ctx.imports };
+    if (errors.length > 0) {
+      result.diagnostics = errors
+        .map(error => ({ message: error.message, ...tokenizer.positionAt(error.offset) }))
+        .sort((a, b) => a.line - b.line || a.character - b.character);
+    }
+    return result;
+  }
+
+  // ---------------------------------------------------------------------------
+  // Structure
+  // ---------------------------------------------------------------------------
+
+  private parseStructure(ctx: FileContext): void {
+    const tokens = ctx.tokens;
+    const open: Body[] = [];
+    let decorators: { names: string[]; start: PythonToken } | null = null;
+    let previousLineEnd: PythonToken | undefined;
+    /** Body opened by the previous line, whose first statement may be a docstring */
+    let docstringBody: Body | undefined;
+    let lineStart = 0;
+
+    while (lineStart < tokens.length) {
+      let lineEnd = lineStart;
+      while (lineEnd < tokens.length && tokens[lineEnd].type !== 'newline') {
+        lineEnd++;
+      }
+      const line = tokens.slice(lineStart, lineEnd);
+      const indent = ctx.tokenizer.positionAt(line[0].offset).character;
+
+      // Dedent closes bodies
+      while (open.length > 0 && indent <= open[open.length - 1].indent) {
+        this.closeBody(ctx, open.pop()!, lineStart, previousLineEnd);
+      }
+      const current = open[open.length - 1];
+
+      if (docstringBody && current === docstringBody && line.length === 1 && line[0].type === 'string') {
+        const doc = docstringText(line[0].text);
+        if (doc) {
+          setSymbolDoc(docstringBody.symbol, doc, 'plain');
+        }
+      }
+      docstringBody = undefined;
+
+      if (line[0].text === '@') {
+        const name = dottedName(line, 1);
+        decorators = { names: [...(decorators?.names ?? []), name.text], start: decorators?.start ?? line[0] };
+      } else {
+        const isAsync = line[0].text === 'async' && line[1]?.text === 'def';
+        const keyword = line[isAsync ? 1 : 0];
+        const nameToken = line[isAsync ? 2 : 1];
+
+        if ((keyword.text === 'class' || keyword.text === 'def') && nameToken?.type === 'name') {
+          const body = keyword.text === 'class'
+            ? this.parseClass(ctx, line, nameToken, decorators?.names ?? [], current)
+            : this.parseFunction(ctx, line, nameToken, decorators?.names ?? [], isAsync, current);
+          if (body) {
+            body.symbol.range.startLine = ctx.tokenizer.positionAt((decorators?.start ?? line[0]).offset).line;
+            body.symbol.range.startCharacter = ctx.tokenizer.positionAt((decorators?.start ?? line[0]).offset).character;
+            body.indent = indent;
+            body.start = lineStart + line.indexOf(nameToken) + 1;
+            ctx.bodies.push(body);
+            open.push(body);
+            docstringBody = body;
+          }
+        } else if (line[0].text === 'import' || line[0].text === 'from') {
+          this.parseImport(ctx, line, current);
+        } else if (current?.kind === 'def') {
+          this.collectLocals(ctx, line, current);
+        } else {
+          this.parseAssignment(ctx, line, current);
+        }
+        decorators = null;
+      }
+
+      previousLineEnd = line[line.length - 1];
+      lineStart = lineEnd + 1;
+    }
+
+    while (open.length > 0) {
+      this.closeBody(ctx, open.pop()!, tokens.length, previousLineEnd);
+    }
+    ctx.bodies.sort((a, b) => a.start - b.start);
+  }
+
+  private closeBody(ctx: FileContext, body: Body, end: number, lastToken: PythonToken | undefined): void {
+    body.end = end;
+    if (lastToken) {
+      const position = ctx.tokenizer.positionAt(lastToken.end);
+      body.symbol.range.endLine = position.line;
+      body.symbol.range.endCharacter = position.character;
+    }
+    for (const name of body.nonLocals) {
+      body.locals.delete(name);
+    }
+  }
+
+  private parseClass(
+    ctx: FileContext,
+    line: PythonToken[],
+    nameToken: PythonToken,
+    decorators: string[],
+    parent: Body | undefined
+  ): Body {
+    const nameIndex = line.indexOf(nameToken);
+    const bases: string[] = [];
+    if (line[nameIndex + 1]?.text === '(') {
+      for (const arg of splitArguments(line, nameIndex + 1)) {
+        // Skip keyword arguments (metaclass=..., total=False)
+        if (arg.length > 0 && !(arg[1]?.text === '=')) {
+          bases.push(tokensText(ctx, arg));
+        }
+      }
+    }
+
+    const symbol = this.pushSymbol(ctx, nameToken, 'class', line, parent, {
+      ...(decorators.length > 0 && { decorators }),
+      ...(bases.length > 0 && { bases })
+    });
+    if (bases.length > 0) {
+      symbol.extends = bases[0];
+    }
+    if (parent?.kind === 'class') {
+      parent.members.add(nameToken.text);
+    } else if (parent) {
+      parent.locals.add(nameToken.text);
+    }
+
+    return this.createBody(ctx, 'class', nameToken.text, symbol, parent);
+  }
+
+  private parseFunction(
+    ctx: FileContext,
+    line: PythonToken[],
+    nameToken: PythonToken,
+    decorators: string[],
+    isAsync: boolean,
+    parent: Body | undefined
+  ): Body | null {
+    const nameIndex = line.indexOf(nameToken);
+    const inClass = parent?.kind === 'class';
+    const isStatic = decorators.some(d => STATIC_DECORATORS.has(d));
+    const isAccessor = decorators.some(d => /\.(setter|deleter)$/.test(d));
+
+    // Parameters
+    const params: PythonToken[] = [];
+    let closeIndex = nameIndex + 1;
+    if (line[nameIndex + 1]?.text === '(') {
+      const args = splitArguments(line, nameIndex + 1);
+      for (const arg of args) {
+        const first = arg.findIndex(t => t.text !== '*' && t.text !== '**');
+        const param = arg[first];
+        if (param?.type === 'name') {
+          params.push(param);
+          ctx.definitionOffsets.add(param.offset);
+        }
+      }
+      closeIndex = matchingClose(line, nameIndex + 1);
+    }
+
+    let returnType: string | undefined;
+    if (line[closeIndex + 1]?.text === '->') {
+      const colon = findAtDepthZero(line, closeIndex + 2, ':');
+      returnType = tokensText(ctx, line.slice(closeIndex + 2, colon === -1 ? undefined : colon));
+    }
+
+    const hasSelf = inClass && decorators.every(d => d !== 'staticmethod') && params.length > 0;
+    const parametersCount = params.length - (hasSelf ? 1 : 0);
+    const metadata: PythonSymbolMetadata = {
+      ...(decorators.length > 0 && { decorators }),
+      ...(isAsync && { async: true }),
+      ...(returnType && { type: returnType })
+    };
+
+    let symbol: IndexedSymbol;
+    if (isAccessor && parent?.members.has(nameToken.text)) {
+      // @x.setter / @x.deleter: same property, no second symbol - but still a body for locals
+      ctx.definitionOffsets.add(nameToken.offset);
+      symbol = this.createDetachedSymbol(ctx, nameToken, 'property', line, parent);
+    } else {
+      const kind = inClass
+        ? (decorators.some(d => PROPERTY_DECORATORS.has(d)) ? 'property' : 'method')
+        : 'function';
+      symbol = this.pushSymbol(ctx, nameToken, kind, line, parent, metadata, {
+        parametersCount: kind === 'property' ? undefined : parametersCount,
+        isStatic
+      });
+      if (kind !== 'property' && line[nameIndex + 1]?.text === '(') {
+        symbol.signature = tokensText(ctx, line.slice(nameIndex + 1, closeIndex + 1)) + (returnType ? ` -> ${returnType}` : '');
+      }
+    }
+
+    if (inClass) {
+      parent!.members.add(nameToken.text);
+    } else if (parent) {
+      parent.locals.add(nameToken.text);
+    }
+
+    const body = this.createBody(ctx, 'def', nameToken.text, symbol, parent);
+    for (const param of params) {
+      body.locals.add(param.text);
+    }
+    if (hasSelf) {
+      body.selfName = params[0].text;
+    }
+    // One-line bodies: def f(x): return x
+    const colon = findAtDepthZero(line, closeIndex + 1, ':');
+    if (colon !== -1 && colon < line.length - 1) {
+      this.collectLocals(ctx, line.slice(colon + 1), body);
+    }
+    return body;
+  }
+
+  private parseImport(ctx: FileContext, line: PythonToken[], current: Body | undefined): void {
+    const declare = (token: PythonToken) => {
+      ctx.definitionOffsets.add(token.offset);
+      if (current?.kind === 'def') {
+        current.locals.add(token.text);
+      }
+    };
+
+    if (line[0].text === 'import') {
+      // import a.b, c as d
+      for (const part of splitAtDepthZero(line.slice(1), ',')) {
+        const module = dottedName(part, 0);
+        const asIndex = part.findIndex(t => t.text === 'as');
+        const alias = asIndex !== -1 ? part[asIndex + 1] : undefined;
+        if (!module.text) {
+          continue;
+        }
+        part.forEach(token => ctx.definitionOffsets.add(token.offset));
+        ctx.imports.push({
+          localName: alias?.text ?? module.text.split('.')[0],
+          moduleSpecifier: module.text,
+          isNamespace: true
+        });
+        if (alias) {
+          declare(alias);
+        } else if (part[0]) {
+          declare(part[0]);
+        }
+      }
+      return;
+    }
+
+    // from .module import name as alias, other
+    const importIndex = line.findIndex(t => t.text === 'import');
+    if (importIndex === -1) {
+      return;
+    }
+    const module = line.slice(1, importIndex).map(t => t.text).join('');
+    line.slice(0, importIndex + 1).forEach(token => ctx.definitionOffsets.add(token.offset));
+
+    const names = line.slice(importIndex + 1).filter(t => t.text !== '(' && t.text !== ')');
+    for (const part of splitAtDepthZero(names, ',')) {
+      const name = part[0];
+      if (!name || name.text === '*') {
+        continue;
+      }
+      const alias = part[1]?.text === 'as' ? part[2] : undefined;
+      ctx.importOffsets.add(name.offset);
+      ctx.imports.push({
+        localName: (alias ?? name).text,
+        moduleSpecifier: module,
+        ...(alias && { exportedName: name.text })
+      });
+      if (alias) {
+        declare(alias);
+      } else if (current?.kind === 'def') {
+        current.locals.add(name.text);
+      }
+    }
+  }
+
+  /**
+   * Module- and class-level assignments: `NAME = ...`, `a, b = ...`, `NAME: T = ...`.
+   */
+  private parseAssignment(ctx: FileContext, line: PythonToken[], current: Body | undefined): void {
+    if (BLOCK_KEYWORDS.has(line[0].text) && line[line.length - 1].text === ':') {
+      return;
+    }
+
+    let targets: PythonToken[] = [];
+    let type: string | undefined;
+    let equals: number;
+
+    if (line[0].type === 'name' && line[1]?.text === ':') {
+      // Annotated: NAME: T [= value]
+      equals = findAtDepthZero(line, 2, '=');
+      targets = [line[0]];
+      type = tokensText(ctx, line.slice(2, equals === -1 ? undefined : equals));
+    } else {
+      equals = findAtDepthZero(line, 0, '=');
+      if (equals <= 0) {
+        return;
+      }
+      const lhs = line.slice(0, equals).filter(t => t.text !== '(' && t.text !== ')' && t.text !== '[' && t.text !== ']');
+      const parts = splitAtDepthZero(lhs, ',');
+      if (parts.some(part => part.length !== 1 || part[0].type !== 'name')) {
+        return;
+      }
+      targets = parts.map(part => part[0]);
+
+      if (!current && targets.length === 1 && targets[0].text === '__all__') {
+        ctx.exportList = new Set(line.slice(equals + 1)
+          .filter(t => t.type === 'string')
+          .map(t => t.text.replace(/^[a-zA-Z]*['"]|['"]$/g, '')));
+        return;
+      }
+    }
+
+    for (const target of targets) {
+      if (PYTHON_KEYWORDS.has(target.text)) {
+        continue;
+      }
+      if (current?.kind === 'class') {
+        if (current.members.has(target.text)) {
+          ctx.definitionOffsets.add(target.offset);
+          continue;
+        }
+        current.members.add(target.text);
+        this.pushSymbol(ctx, target, 'property', line, current, type ? { type } : {});
+      } else if (!ctx.symbols.some(s => !s.containerName && s.name === target.text)) {
+        const kind = UPPER_CASE_RE.test(target.text) ? 'constant' : 'variable';
+        const symbol = this.pushSymbol(ctx, target, kind, line, current, type ? { type } : {});
+        const value = kind === 'constant' && targets.length === 1 && equals !== -1 ? line.slice(equals + 1) : [];
+        // Chained assignments (A = B = 1) have no single initializer text
+        if (value.length > 0 && findAtDepthZero(value, 0, '=') === -1) {
+          symbol.value = truncateValue(tokensText(ctx, value));
+        }
+      }
+    }
+  }
+
+  /**
+   * Names bound inside a function body. `self.x = ...` in a method also
+   * declares attribute `x` on the class.
+   */
+  private collectLocals(ctx: FileContext, line: PythonToken[], body: Body): void {
+    const first = line[0];
+    if (!first) {
+      return;
+    }
+
+    if (first.text === 'global' || first.text === 'nonlocal') {
+      line.slice(1).filter(t => t.type === 'name').forEach(t => body.nonLocals.add(t.text));
+      return;
+    }
+
+    // self.attr = value / self.attr: T = value
+    const owner = body.parent;
+    if (body.selfName && owner?.kind === 'class' && first.text === body.selfName &&
+        line[1]?.text === '.' && line[2]?.type === 'name' && (line[3]?.text === '=' || line[3]?.text === ':')) {
+      const attribute = line[2];
+      if (!owner.members.has(attribute.text)) {
+        owner.members.add(attribute.text);
+        let type: string | undefined;
+        if (line[3].text === ':') {
+          const equals = findAtDepthZero(line, 4, '=');
+          type = tokensText(ctx, line.slice(4, equals === -1 ? undefined : equals));
+        }
+        this.pushSymbol(ctx, attribute, 'property', line, owner, type ? { type } : {});
+      }
+      return;
+    }
+
+    for (let k = 0; k < line.length; k++) {
+      const token = line[k];
+      const next = line[k + 1];
+      if (token.type !== 'name' || line[k - 1]?.text === '.') {
+        continue;
+      }
+      if (next?.text === ':=' || ((next?.text === '=' || next?.text === ',') && this.isAssignmentTarget(line, k))) {
+        body.locals.add(token.text);
+      } else if (line[k - 1]?.text === 'as') {
+        body.locals.add(token.text);
+      } else if (line[k - 1]?.text === 'for' || (line[k - 1]?.text === ',' && this.inForTarget(line, k))) {
+        body.locals.add(token.text);
+      } else if (line[k - 1]?.text === 'lambda' || (line[k - 1]?.text === ',' && this.inLambdaParams(line, k))) {
+        body.locals.add(token.text);
+      }
+    }
+  }
+
+  /**
+   * True if the name at k is on the left of the line's first top-level `=`.
+   */
+  private isAssignmentTarget(line: PythonToken[], k: number): boolean {
+    const equals = findAtDepthZero(line, 0, '=');
+    if (equals === -1 || k >= equals) {
+      return false;
+    }
+    // Only names, commas and brackets before `=` (not `a[i] = ...` or `f(x=1)`)
+    return line.slice(0, equals).every(t => t.type === 'name' || t.text === ',' || t.text === '(' || t.text === ')' || t.text === '[' || t.text === ']') &&
+      line[k - 1]?.text !== '[';
+  }
+
+  private inForTarget(line: PythonToken[], k: number): boolean {
+    for (let j = k - 1; j >= 0; j--) {
+      if (line[j].text === 'for') {
+        return true;
+      }
+      if (line[j].type !== 'name' && line[j].text !== ',' && line[j].text !== '(') {
+        return false;
+      }
+    }
+    return false;
+  }
+
+  private inLambdaParams(line: PythonToken[], k: number): boolean {
+    for (let j = k - 1; j >= 0; j--) {
+      if (line[j].text === 'lambda') {
+        return true;
+      }
+      if (line[j].type !== 'name' && line[j].text !== ',' && line[j].text !== '*' && line[j].text !== '**') {
+        return false;
+      }
+    }
+    return false;
+  }
+
+  /**
+   * With `__all__`, only listed module-level names are exported.
+   */
+  private applyExportList(ctx: FileContext): void {
+    if (!ctx.exportList) {
+      return;
+    }
+    for (const symbol of ctx.symbols) {
+      if (!symbol.containerName) {
+        symbol.isExported = ctx.exportList.has(symbol.name);
+      }
+    }
+  }
+
+  // ---------------------------------------------------------------------------
+  // References
+  // ---------------------------------------------------------------------------
+
+  private collectReferences(ctx: FileContext): IndexedReference[] {
+    const references: IndexedReference[] = [];
+    const tokens = ctx.tokens;
+    const stack: Body[] = [];
+    let nextBody = 0;
+
+    for (let k = 0; k < tokens.length; k++) {
+      while (stack.length > 0 && stack[stack.length - 1].end <= k) {
+        stack.pop();
+      }
+      while (nextBody < ctx.bodies.length && ctx.bodies[nextBody].start <= k) {
+        const body = ctx.bodies[nextBody++];
+        if (body.end > k) {
+          stack.push(body);
+        }
+      }
+
+      const token = tokens[k];
+      if (token.type !== 'name' || PYTHON_KEYWORDS.has(token.text) || PYTHON_BUILTINS.has(token.text)) {
+        continue;
+      }
+      if (ctx.definitionOffsets.has(token.offset)) {
+        continue;
+      }
+
+      const isAttribute = tokens[k - 1]?.text === '.';
+      const isLocal = !isAttribute && stack.some(body => body.kind === 'def' && body.locals.has(token.text));
+      const container = stack[stack.length - 1];
+      const position = ctx.tokenizer.positionAt(token.offset);
+
+      references.push({
+        symbolName: token.text,
+        location: { uri: ctx.uri, line: position.line, character: position.character },
+        range: {
+          startLine: position.line,
+          startCharacter: position.character,
+          endLine: position.line,
+          endCharacter: position.character + token.text.length
+        },
+        containerName: container?.path,
+        isImport: ctx.importOffsets.has(token.offset) || undefined,
+        isLocal: isLocal || undefined,
+        isCall: tokens[k + 1]?.text === '(' && !ctx.importOffsets.has(token.offset) ? true : undefined
+      });
+    }
+
+    return references;
+  }
+
+  // ---------------------------------------------------------------------------
+  // Helpers
+  // ---------------------------------------------------------------------------
+
+  private createBody(ctx: FileContext, kind: Body['kind'], name: string, symbol: IndexedSymbol, parent: Body | undefined): Body {
+    return {
+      kind,
+      name,
+      path: parent ? `${parent.path}.${name}` : name,
+      indent: 0,
+      start: 0,
+      end: ctx.tokens.length,
+      symbol,
+      locals: new Set(),
+      nonLocals: new Set(),
+      members: new Set(),
+      parent
+    };
+  }
+
+  /**
+   * Create and store a symbol. The name token is recorded as a definition site.
+   */
+  private pushSymbol(
+    ctx: FileContext,
+    nameToken: PythonToken,
+    kind: string,
+    line: PythonToken[],
+    parent: Body | undefined,
+    pythonMetadata: PythonSymbolMetadata,
+    extra?: { parametersCount?: number; isStatic?: boolean }
+  ): IndexedSymbol {
+    const symbol = this.createDetachedSymbol(ctx, nameToken, kind, line, parent, pythonMetadata, extra);
+    ctx.symbols.push(symbol);
+    ctx.definitionOffsets.add(nameToken.offset);
+    return symbol;
+  }
+
+  private createDetachedSymbol(
+    ctx: FileContext,
+    nameToken: PythonToken,
+    kind: string,
+    line: PythonToken[],
+    parent: Body | undefined,
+    pythonMetadata: PythonSymbolMetadata = {},
+    extra?: { parametersCount?: number; isStatic?: boolean }
+  ): IndexedSymbol {
+    const name = nameToken.text;
+    const location = ctx.tokenizer.positionAt(nameToken.offset);
+    const start = ctx.tokenizer.positionAt(line[0].offset);
+    const end = ctx.tokenizer.positionAt(line[line.length - 1].end);
+    const containerName = parent?.name;
+    const fullContainerPath = parent?.path;
+
+    const id = createSymbolId(
+      ctx.uri,
+      name,
+      containerName,
+      fullContainerPath,
+      kind,
+      extra?.isStatic,
+      extra?.parametersCount,
+      location.line,
+      location.character
+    );
+
+    return {
+      id,
+      name,
+      kind,
+      location: { uri: ctx.uri, line: location.line, character: location.character },
+      range: {
+        startLine: start.line,
+        startCharacter: start.character,
+        endLine: end.line,
+        endCharacter: end.character
+      },
+      containerName,
+      containerKind: parent ? (parent.kind === 'class' ? 'class' : 'function') : undefined,
+      fullContainerPath,
+      filePath: ctx.uri,
+      isStatic: extra?.isStatic || undefined,
+      parametersCount: extra?.parametersCount,
+      metadata: { python: pythonMetadata },
+      isDefinition: true,
+      visibility: parent?.kind === 'class' ? pythonVisibility(name) : undefined,
+      isExported: parent?.kind === 'def' ? false : !name.startsWith('_')
+    };
+  }
+}
+
+function pythonVisibility(name: string): 'public' | 'protected' | 'private' {
+  if (name.startsWith('__') && !name.endsWith('__')) {
+    return 'private';
+  }
+  return name.startsWith('_') && !name.endsWith('__') ? 'protected' : 'public';
+}
+
+/**
+ * Dotted name starting at index (`a.b.c`), stopping at anything else.
+ */
+function dottedName(line: PythonToken[], index: number): { text: string; end: number } {
+  let text = '';
+  let k = index;
+  while (line[k]?.type === 'name') {
+    text += line[k].text;
+    if (line[k + 1]?.text !== '.' || line[k + 2]?.type !== 'name') {
+      return { text, end: k + 1 };
+    }
+    text += '.';
+    k += 2;
+  }
+  return { text, end: k };
+}
+
+function isOpen(text: string): boolean {
+  return text === '(' || text === '[' || text === '{';
+}
+
+function isClose(text: string): boolean {
+  return text === ')' || text === ']' || text === '}';
+}
+
+/**
+ * Index of the bracket closing the one at openIndex (or the last token).
+ */
+function matchingClose(line: PythonToken[], openIndex: number): number {
+  let depth = 0;
+  for (let k = openIndex; k < line.length; k++) {
+    if (isOpen(line[k].text)) {
+      depth++;
+    } else if (isClose(line[k].text) && --depth === 0) {
+      return k;
+    }
+  }
+  return line.length - 1;
+}
+
+/**
+ * Comma-separated arguments between the bracket at openIndex and its match.
+ */
+function splitArguments(line: PythonToken[], openIndex: number): PythonToken[][] {
+  return splitAtDepthZero(line.slice(openIndex + 1, matchingClose(line, openIndex)), ',')
+    .filter(arg => arg.length > 0);
+}
+
+function splitAtDepthZero(tokens: PythonToken[], separator: string): PythonToken[][] {
+  const parts: PythonToken[][] = [[]];
+  let depth = 0;
+  for (const token of tokens) {
+    if (isOpen(token.text)) {
+      depth++;
+    } else if (isClose(token.text)) {
+      depth--;
+    }
+    if (depth === 0 && token.text === separator) {
+      parts.push([]);
+    } else {
+      parts[parts.length - 1].push(token);
+    }
+  }
+  return parts;
+}
+
+function findAtDepthZero(line: PythonToken[], from: number, text: string): number {
+  let depth = 0;
+  for (let k = from; k < line.length; k++) {
+    if (isOpen(line[k].text)) {
+      depth++;
+    } else if (isClose(line[k].text)) {
+      depth--;
+    } else if (depth === 0 && line[k].text === text) {
+      return k;
+    }
+  }
+  return -1;
+}
+
+function tokensText(ctx: FileContext, tokens: PythonToken[]): string {
+  if (tokens.length === 0) {
+    return '';
+  }
+  return ctx.content.slice(tokens[0].offset, tokens[tokens.length - 1].end).replace(/\s+/g, ' ').trim();
+}
+
+/**
+ * Cleaned text of a docstring literal (prefix and quotes removed), or
+ * undefined for byte and f-strings, which are not docstrings.
+ */
+function docstringText(literal: string): string | undefined {
+  const match = literal.match(/^([rRuU]?)("""|'''|"|')([\s\S]*?)\2$/);
+  return match ? cleanDocstring(match[3]) || undefined : undefined;
+}
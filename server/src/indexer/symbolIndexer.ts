@@ -1,7 +1,14 @@
-import { parse, AST_NODE_TYPES, TSESTree } from '@typescript-eslint/typescript-estree';
-import { IndexedFileResult, IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, SHARD_VERSION } from '../types.js';
+import { AST_NODE_TYPES, TSESTree } from '@typescript-eslint/typescript-estree';
+import { IndexedFileResult, IndexedSymbol, IndexedReference, ImportInfo, ParseDiagnostic, ReExportInfo, SHARD_VERSION } from '../types.js';
 import { FastRegexParser } from './FastRegexParser.js';
+import { astParser } from './components/AstParser.js';
+import { extractTsClosures } from './components/ClosureExtractor.js';
 import { createSymbolId } from './symbolResolver.js';
+import { attachJsDocComments } from '../utils/docComments.js';
+import { attachTsSignatures } from '../utils/signatures.js';
+import { attachTsConstantValues } from '../utils/constantValues.js';
+import { attachByteOffsets } from '../utils/byteOffsets.js';
+import { attachFunctionMetrics } from '../utils/functionMetrics.js';
 import * as crypto from 'crypto';
 import * as fsPromises from 'fs/promises';
 import * as path from 'path';
@@ -39,6 +46,17 @@ class ScopeTracker {
   }
 }
 
+/**
+ * What a code file yields, before doc comments, signatures and metrics are attached.
+ */
+interface CodeExtraction {
+  symbols: IndexedSymbol[];
+  references: IndexedReference[];
+  imports: ImportInfo[];
+  reExports: ReExportInfo[];
+  diagnostics?: ParseDiagnostic[];
+}
+
 export class SymbolIndexer {
   async indexFile(uri: string, content?: string, options: { fast?: boolean, threshold?: number } = {}): Promise<IndexedFileResult> {
     try {
@@ -52,7 +70,7 @@ export class SymbolIndexer {
       const useFastParser = options.fast || fileContent.length > threshold;
 
       if (isCodeFile) {
-        let result;
+        let result: CodeExtraction;
         if (useFastParser) {
           result = {
             symbols: FastRegexParser.extractSymbols(uri, fileContent),
@@ -63,6 +81,11 @@ export class SymbolIndexer {
         } else {
           result = this.extractCodeSymbolsAndReferences(uri, fileContent);
         }
+        attachJsDocComments(result.symbols, fileContent);
+        attachTsSignatures(result.symbols, fileContent);
+        attachTsConstantValues(result.symbols, fileContent);
+        attachFunctionMetrics(fileContent, result.symbols, uri);
+        attachByteOffsets(fileContent, result.symbols, result.references);
 
         return {
           uri,
@@ -71,10 +94,12 @@ export class SymbolIndexer {
           references: result.references,
           imports: result.imports,
           reExports: result.reExports,
+          diagnostics: result.diagnostics,
           shardVersion: SHARD_VERSION
         };
       } else {
         const symbols = this.extractTextSymbols(uri, fileContent);
+        attachByteOffsets(fileContent, symbols);
         return {
           uri,
           hash,
@@ -111,26 +136,25 @@ export class SymbolIndexer {
     return crypto.createHash('sha256').update(content).digest('hex');
   }
 
-  private extractCodeSymbolsAndReferences(uri: string, content: string): {
-    symbols: IndexedSymbol[];
-    references: IndexedReference[];
-    imports: ImportInfo[];
-    reExports: ReExportInfo[];
-  } {
+  /**
+   * Declarations that fail to parse are skipped and reported as
+   * diagnostics; when nothing can be recovered, the regex parser extracts
+   * the declarations instead.
+   */
+  private extractCodeSymbolsAndReferences(uri: string, content: string): CodeExtraction {
     const symbols: IndexedSymbol[] = [];
     const references: IndexedReference[] = [];
     const imports: ImportInfo[] = [];
     const reExports: ReExportInfo[] = [];
+    let diagnostics: ParseDiagnostic[] | undefined;
 
     try {
-      const ast = parse(content, {
-        loc: true,
-        range: true,
-        comment: false,
-        tokens: false,
-        errorOnUnknownASTType: false,
-        jsx: uri.endsWith('x')
-      });
+      const parsed = astParser.parseTolerant(content, uri);
+      diagnostics = parsed.diagnostics.length > 0 ? parsed.diagnostics : undefined;
+      const ast = parsed.ast;
+      if (!ast) {
+        return { symbols: FastRegexParser.extractSymbols(uri, content), references, imports, reExports, diagnostics };
+      }
 
       // First pass: extract imports and re-exports
       this.extractImports(ast, imports);
@@ -139,10 +163,11 @@ export class SymbolIndexer {
       // Second pass: extract symbols and references with scope tracking
       const scopeTracker = new ScopeTracker();
       this.traverseAST(ast, symbols, references, uri, undefined, undefined, [], imports, scopeTracker);
+      symbols.push(...extractTsClosures(ast, uri, symbols));
     } catch (error) {
       }
 
-    return { symbols, references, imports, reExports };
+    return { symbols, references, imports, reExports, diagnostics };
   }
 
   private extractImports(ast: TSESTree.Program, imports: ImportInfo[]): void {
@@ -1,5 +1,6 @@
 import { IndexedSymbol, IndexedFileResult } from '../types.js';
 import { createSymbolId } from './symbolResolver.js';
+import { attachByteOffsets } from '../utils/byteOffsets.js';
 import * as crypto from 'crypto';
 import * as fsPromises from 'fs/promises';
 import * as path from 'path';
@@ -59,6 +60,7 @@ export class TextIndexer {
       const fileContent = content !== undefined ? content : await this.readFile(uri);
       const hash = this.computeHash(fileContent);
       const symbols = this.extractSymbols(uri, fileContent);
+      attachByteOffsets(fileContent, symbols);
 
       return { uri, hash, symbols, references: [], imports: [] };
     } catch (error) {
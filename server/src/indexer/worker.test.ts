@@ -6,7 +6,10 @@
  */
 
 import { describe, it, expect } from 'vitest';
-import { processFileContent } from './worker.js';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { processFileContent, defaultPlugins } from './worker.js';
 import { IndexedSymbol } from '../types.js';
 
 // Mock Angular component source code
@@ -145,3 +148,77 @@ describe('Worker with custom plugins', () => {
     expect(componentSymbol?.metadata?.['angular']).toBeUndefined();
   });
 });
+
+describe('Worker generated files', () => {
+  const generatedSource = `// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: user.proto
+
+package userpb
+
+type User struct {
+	Name string
+}
+`;
+
+  it('should skip files with a generated-code marker unless included', async () => {
+    const skipped = await processFileContent('/ws/userpb/user.pb.go', generatedSource, defaultPlugins, false);
+    expect(skipped.isSkipped).toBe(true);
+    expect(skipped.skipReason).toBe('Generated file');
+    expect(skipped.symbols).toEqual([]);
+
+    const included = await processFileContent('/ws/userpb/user.pb.go', generatedSource, defaultPlugins, true);
+    expect(included.isSkipped).toBeFalsy();
+    expect(findSymbol(included.symbols, 'User')).toBeDefined();
+  });
+});
+
+describe('Worker Go build constraints', () => {
+  const windowsSource = `//go:build windows
+
+package fsutil
+
+func Open(name string) error {
+	return nil
+}
+`;
+
+  it('should skip Go files excluded for the build target', async () => {
+    const linux = { goos: 'linux', goarch: 'amd64', tags: [] };
+    const skipped = await processFileContent('/ws/fsutil/open.go', windowsSource, defaultPlugins, true, linux);
+    expect(skipped.isSkipped).toBe(true);
+    expect(skipped.skipReason).toBe('Excluded by build constraints');
+
+    const windows = { goos: 'windows', goarch: 'amd64', tags: [] };
+    const included = await processFileContent('/ws/fsutil/open.go', windowsSource, defaultPlugins, true, windows);
+    expect(included.isSkipped).toBeFalsy();
+    expect(findSymbol(included.symbols, 'Open')).toBeDefined();
+  });
+
+  it('should tag symbols with the constraint of their file', async () => {
+    const result = await processFileContent('/ws/fsutil/open_amd64.go', windowsSource, defaultPlugins, true);
+    const open = findSymbol(result.symbols, 'Open');
+    expect((open?.metadata?.['go'] as any).buildConstraint).toBe('amd64 && windows');
+  });
+});
+
+describe('Worker Bazel targets', () => {
+  it('should tag Go symbols with their targets and skip files no target compiles', async () => {
+    const root = fs.mkdtempSync(path.join(os.tmpdir(), 'worker-bazel-'));
+    try {
+      fs.writeFileSync(path.join(root, 'MODULE.bazel'), '');
+      fs.mkdirSync(path.join(root, 'fsutil'));
+      fs.writeFileSync(path.join(root, 'fsutil', 'BUILD.bazel'), 'go_library(name = "fsutil", srcs = ["open.go"])\n');
+      const source = 'package fsutil\n\nfunc Open(name string) error {\n\treturn nil\n}\n';
+
+      const result = await processFileContent(path.join(root, 'fsutil', 'open.go'), source, defaultPlugins, true, undefined, true);
+      const open = findSymbol(result.symbols, 'Open');
+      expect((open?.metadata?.['go'] as any).bazelTargets).toEqual(['//fsutil:fsutil']);
+
+      const skipped = await processFileContent(path.join(root, 'fsutil', 'scratch.go'), source, defaultPlugins, true, undefined, true);
+      expect(skipped.isSkipped).toBe(true);
+      expect(skipped.skipReason).toBe('Not in the srcs of a Bazel target');
+    } finally {
+      fs.rmSync(root, { recursive: true, force: true });
+    }
+  });
+});
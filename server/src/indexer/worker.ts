@@ -1,6 +1,6 @@
 import { parentPort } from 'worker_threads';
 import { AST_NODE_TYPES, TSESTree } from '@typescript-eslint/typescript-estree';
-import { IndexedFileResult, IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, PendingReference, SHARD_VERSION, NgRxMetadata } from '../types.js';
+import { IndexedFileResult, IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, PendingReference, SHARD_VERSION, NgRxMetadata, ParseDiagnostic } from '../types.js';
 import { createSymbolId } from './symbolResolver.js';
 import { PluginRegistry, PluginVisitorContext, FrameworkPlugin } from '../plugins/FrameworkPlugin.js';
 import { AngularPlugin } from '../plugins/angular/AngularPlugin.js';
@@ -14,6 +14,7 @@ import {
   ScopeTracker,
   astParser,
   ImportExtractor,
+  extractTsClosures,
   isNgRxCreateActionCall,
   isNgRxCreateActionGroupCall,
   isNgRxCreateEffectCall,
@@ -24,6 +25,19 @@ import {
   hasEffectDecorator,
   processCreateActionGroup
 } from './components/index.js';
+import { createDefaultParserRegistry } from './parserRegistry.js';
+import { FastRegexParser } from './FastRegexParser.js';
+import { GoModuleResolver, annotateGoModule } from './goModules.js';
+import { BazelTargetResolver, annotateBazelTargets, isOutsideBazelTargets } from './bazelTargets.js';
+import { PackagePathResolver, assignCanonicalIds } from './canonicalIds.js';
+import { isGeneratedSource } from '../utils/ignoreRules.js';
+import { tagFileResult } from '../utils/codeTags.js';
+import { attachJsDocComments } from '../utils/docComments.js';
+import { attachTsSignatures } from '../utils/signatures.js';
+import { attachTsConstantValues } from '../utils/constantValues.js';
+import { attachByteOffsets } from '../utils/byteOffsets.js';
+import { attachFunctionMetrics } from '../utils/functionMetrics.js';
+import { GoBuildContext, fileBuildConstraint, matchesBuildContext } from './goBuildConstraints.js';
 
 // Default plugins for production use
 const defaultPlugins: FrameworkPlugin[] = [
@@ -40,10 +54,20 @@ for (const plugin of defaultPlugins) {
 // Global interner instance per worker - reused across all file processing
 const interner = new StringInterner();
 const importExtractor = new ImportExtractor(interner);
+const parserRegistry = createDefaultParserRegistry();
+const goModules = new GoModuleResolver();
+const bazelTargets = new BazelTargetResolver();
+const packagePaths = new PackagePathResolver();
+
+// Callee identifiers of call/new expressions, marked when the call is visited (before its children)
+const calleeNodes = new WeakSet<TSESTree.Node>();
 
 interface WorkerTaskData {
   uri: string;
   content?: string;
+  includeGenerated?: boolean;
+  goBuildContext?: GoBuildContext;
+  bazel?: boolean;
 }
 
 interface WorkerResult {
@@ -212,6 +236,16 @@ function traverseAST(
   if (!node || !node.loc) {return;}
 
   try {
+    // Mark callees so call sites can be flagged for the call graph
+    if (node.type === AST_NODE_TYPES.CallExpression || node.type === AST_NODE_TYPES.NewExpression) {
+      const callee = (node as TSESTree.CallExpression | TSESTree.NewExpression).callee;
+      if (callee.type === AST_NODE_TYPES.Identifier) {
+        calleeNodes.add(callee);
+      } else if (callee.type === AST_NODE_TYPES.MemberExpression) {
+        calleeNodes.add(callee.property);
+      }
+    }
+
     // Handle Identifiers - but only if they are NOT part of a declaration
     if (node.type === AST_NODE_TYPES.Identifier && node.loc) {
       // Skip if this identifier is the name being declared
@@ -239,7 +273,8 @@ function traverseAST(
           containerName,
           isImport: isImportRef,
           scopeId,
-          isLocal
+          isLocal,
+          isCall: calleeNodes.has(node)
         });
       }
     }
@@ -368,7 +403,8 @@ function traverseAST(
             },
             containerName,
             scopeId,
-            isLocal: false
+            isLocal: false,
+            isCall: calleeNodes.has(memberExpr.property)
           });
         }
         
@@ -990,12 +1026,18 @@ function extractTextSymbols(uri: string, content: string): IndexedSymbol[] {
  * @param uri - The file URI (used for symbol locations)
  * @param content - Optional file content. If not provided, reads from disk.
  * @param plugins - Optional plugins array. If not provided, uses default Angular/NgRx plugins.
- * @returns IndexedFileResult with symbols, references, imports, etc.
+ * @param includeGenerated - Index files with a generated-code marker instead of skipping them.
+ * @param goBuildContext - Skip Go files that a build for this target would exclude; when omitted, every file is indexed.
+ * @param bazel - Tag Go symbols with their Bazel targets and skip Go files no target compiles.
+ * @returns IndexedFileResult with symbols, references, imports, doc comments, signatures, constant values, byte offsets and code tags.
  */
 export async function processFileContent(
   uri: string,
   content?: string,
-  plugins: FrameworkPlugin[] = defaultPlugins
+  plugins: FrameworkPlugin[] = defaultPlugins,
+  includeGenerated: boolean = true,
+  goBuildContext?: GoBuildContext,
+  bazel: boolean = false
 ): Promise<IndexedFileResult> {
   // Create a local plugin registry if custom plugins are provided
   let pluginRegistry = workerPluginRegistry;
@@ -1027,12 +1069,78 @@ export async function processFileContent(
   }
   
   const hash = astParser.computeHash(fileContent);
-  
   const ext = path.extname(uri).toLowerCase();
+  const bazelFileTargets = bazel && (ext === '.go' || ext === '.s') ? bazelTargets.resolve(uri) : undefined;
+  
+  // Generated sources under bazel-bin are only indexed when asked for (see collectBazelGeneratedFiles)
+  if (!includeGenerated && isGeneratedSource(fileContent) && !bazelFileTargets?.generated) {
+    return {
+      uri,
+      hash,
+      symbols: [],
+      references: [],
+      imports: [],
+      reExports: [],
+      isSkipped: true,
+      skipReason: 'Generated file',
+      shardVersion: SHARD_VERSION
+    };
+  }
+  
   const isCodeFile = ['.ts', '.tsx', '.js', '.jsx', '.mts', '.cts', '.mjs', '.cjs'].includes(ext);
+
+  if (goBuildContext && (ext === '.go' || ext === '.s') && !matchesBuildContext(fileBuildConstraint(uri, fileContent), goBuildContext)) {
+    return {
+      uri,
+      hash,
+      symbols: [],
+      references: [],
+      imports: [],
+      reExports: [],
+      isSkipped: true,
+      skipReason: 'Excluded by build constraints',
+      shardVersion: SHARD_VERSION
+    };
+  }
+  if (isOutsideBazelTargets(bazelFileTargets)) {
+    return {
+      uri,
+      hash,
+      symbols: [],
+      references: [],
+      imports: [],
+      reExports: [],
+      isSkipped: true,
+      skipReason: 'Not in the srcs of a Bazel target',
+      shardVersion: SHARD_VERSION
+    };
+  }
+  
+  // Go, Python, ... use their structural parsers (no AST dependency)
+  const languageParser = parserRegistry.getParser(uri);
+  if (languageParser) {
+    const result = languageParser.parse(uri, fileContent);
+    if (languageParser.language === 'go' || languageParser.language === 'goasm') {
+      annotateGoModule(result.symbols, uri, goModules.resolve(uri));
+      annotateBazelTargets(result.symbols, bazelFileTargets);
+    }
+    assignCanonicalIds(result.symbols, uri, packagePaths);
+    attachFunctionMetrics(fileContent, result.symbols, uri);
+    attachByteOffsets(fileContent, result.symbols, result.references);
+    return tagFileResult({
+      uri,
+      hash,
+      symbols: result.symbols,
+      references: result.references,
+      imports: result.imports,
+      reExports: result.reExports ?? [],
+      diagnostics: result.diagnostics,
+      shardVersion: SHARD_VERSION
+    }, fileContent);
+  }
   
   // Early exit for unsupported file types to prevent RangeError
-  if (!isCodeFile && ext !== '.json' && ext !== '.md' && ext !== '.txt') {
+  if (!isCodeFile && !['.json', '.txt', '.md', '.markdown', '.adoc', '.asciidoc'].includes(ext)) {
     return {
       uri,
       hash,
@@ -1048,9 +1156,15 @@ export async function processFileContent(
   
   if (isCodeFile) {
     const result = extractCodeSymbolsAndReferencesWithPlugins(uri, fileContent, pluginRegistry);
+    attachJsDocComments(result.symbols, fileContent);
+    attachTsSignatures(result.symbols, fileContent);
+    attachTsConstantValues(result.symbols, fileContent);
+    attachFunctionMetrics(fileContent, result.symbols, uri);
+    assignCanonicalIds(result.symbols, uri, packagePaths);
+    attachByteOffsets(fileContent, result.symbols, result.references, result.pendingReferences);
     
     if (result.parseError) {
-      return {
+      return tagFileResult({
         uri,
         hash,
         symbols: result.symbols,
@@ -1058,13 +1172,14 @@ export async function processFileContent(
         imports: result.imports,
         reExports: result.reExports,
         pendingReferences: result.pendingReferences.length > 0 ? result.pendingReferences : undefined,
+        diagnostics: result.diagnostics,
         isSkipped: true,
         skipReason: `Parse error: ${result.parseError}`,
         shardVersion: SHARD_VERSION
-      };
+      }, fileContent);
     }
     
-    return {
+    return tagFileResult({
       uri,
       hash,
       symbols: result.symbols,
@@ -1072,11 +1187,13 @@ export async function processFileContent(
       imports: result.imports,
       reExports: result.reExports,
       pendingReferences: result.pendingReferences.length > 0 ? result.pendingReferences : undefined,
+      diagnostics: result.diagnostics,
       shardVersion: SHARD_VERSION
-    };
+    }, fileContent);
   } else {
     const symbols = extractTextSymbols(uri, fileContent);
-    return {
+    attachByteOffsets(fileContent, symbols);
+    return tagFileResult({
       uri,
       hash,
       symbols,
@@ -1084,13 +1201,17 @@ export async function processFileContent(
       imports: [],
       reExports: [],
       shardVersion: SHARD_VERSION
-    };
+    }, fileContent);
   }
 }
 
 /**
  * Internal helper that accepts a custom plugin registry.
  * Used by processFileContent when custom plugins are provided.
+ *
+ * Syntax errors do not drop the file: the declarations that parse are
+ * indexed and the errors returned as diagnostics. If nothing can be
+ * recovered, the regex parser still extracts the declarations.
  */
 function extractCodeSymbolsAndReferencesWithPlugins(
   uri: string,
@@ -1102,16 +1223,21 @@ function extractCodeSymbolsAndReferencesWithPlugins(
   imports: ImportInfo[];
   reExports: ReExportInfo[];
   pendingReferences: PendingReference[];
+  diagnostics?: ParseDiagnostic[];
   parseError?: string;
 } {
   const symbols: IndexedSymbol[] = [];
   const references: IndexedReference[] = [];
   const pendingReferences: PendingReference[] = [];
+  let diagnostics: ParseDiagnostic[] | undefined;
 
   try {
-    const ast = astParser.parse(content, uri);
+    const parsed = astParser.parseTolerant(content, uri);
+    const ast = parsed.ast;
+    diagnostics = parsed.diagnostics.length > 0 ? parsed.diagnostics : undefined;
     if (!ast) {
-      return { symbols, references, imports: [], reExports: [], pendingReferences, parseError: 'Failed to parse AST' };
+      const fallback = FastRegexParser.extractSymbols(uri, content);
+      return { symbols: fallback, references, imports: [], reExports: [], pendingReferences, diagnostics };
     }
 
     const imports = importExtractor.extractImports(ast);
@@ -1119,11 +1245,12 @@ function extractCodeSymbolsAndReferencesWithPlugins(
 
     const scopeTracker = new ScopeTracker();
     traverseASTWithPlugins(ast, symbols, references, uri, undefined, undefined, [], imports, scopeTracker, null, undefined, pendingReferences, pluginRegistry);
+    symbols.push(...extractTsClosures(ast, uri, symbols));
     
-    return { symbols, references, imports, reExports, pendingReferences };
+    return { symbols, references, imports, reExports, pendingReferences, diagnostics };
   } catch (error) {
     const errorMessage = error instanceof Error ? error.message : String(error);
-    return { symbols, references, imports: [], reExports: [], pendingReferences, parseError: errorMessage };
+    return { symbols, references, imports: [], reExports: [], pendingReferences, diagnostics, parseError: errorMessage };
   }
 }
 
@@ -1158,7 +1285,14 @@ export { defaultPlugins };
  * Delegates to processFileContent with default plugins.
  */
 async function processFile(taskData: WorkerTaskData): Promise<IndexedFileResult> {
-  return processFileContent(taskData.uri, taskData.content);
+  return processFileContent(
+    taskData.uri,
+    taskData.content,
+    defaultPlugins,
+    taskData.includeGenerated ?? true,
+    taskData.goBuildContext,
+    taskData.bazel ?? false
+  );
 }
 
 if (parentPort) {
@@ -0,0 +1,86 @@
+/**
+ * Indexing throughput over repeated runs, for comparing changes to the
+ * indexers and the walk (see Indexer.bench in api/indexer.ts).
+ */
+
+import { formatBytes } from '../utils/indexingProgress.js';
+
+/** One timed run */
+export interface BenchmarkRun {
+  durationMs: number;
+  files: number;
+  bytes: number;
+  symbols: number;
+}
+
+export interface BenchmarkResult {
+  /** Absolute path of the indexed directory */
+  root: string;
+  /** Timed runs, in order; warm-up runs are left out */
+  runs: BenchmarkRun[];
+  warmup: number;
+  /** Files and bytes of one run (the last) */
+  files: number;
+  bytes: number;
+  symbols: number;
+  medianMs: number;
+  bestMs: number;
+  meanMs: number;
+  /** Standard deviation of the run durations */
+  stddevMs: number;
+  /** Throughput of the median run */
+  filesPerSecond: number;
+  megabytesPerSecond: number;
+}
+
+const MEGABYTE = 1024 * 1024;
+
+/**
+ * Summary of the timed runs of a directory; throughput is that of the
+ * median run, which one slow run (a GC pause, a cold disk cache) does not
+ * move.
+ */
+export function summarizeBenchmark(root: string, runs: BenchmarkRun[], warmup: number): BenchmarkResult {
+  if (runs.length === 0) {
+    throw new Error('A benchmark needs at least one timed run');
+  }
+  const durations = runs.map(run => run.durationMs).sort((a, b) => a - b);
+  const middle = Math.floor(durations.length / 2);
+  const medianMs = durations.length % 2 === 1 ? durations[middle] : (durations[middle - 1] + durations[middle]) / 2;
+  const meanMs = durations.reduce((sum, ms) => sum + ms, 0) / durations.length;
+  const stddevMs = Math.sqrt(durations.reduce((sum, ms) => sum + (ms - meanMs) ** 2, 0) / durations.length);
+  const { files, bytes, symbols } = runs[runs.length - 1];
+  const seconds = Math.max(medianMs, 1e-3) / 1000;
+  return {
+    root,
+    runs,
+    warmup,
+    files,
+    bytes,
+    symbols,
+    medianMs,
+    bestMs: durations[0],
+    meanMs,
+    stddevMs,
+    filesPerSecond: files / seconds,
+    megabytesPerSecond: bytes / MEGABYTE / seconds
+  };
+}
+
+/**
+ * The result as a few lines for a terminal or an output channel:
+ *
+ *   /src/app: 1204 files, 8.3 MB, 15872 symbols
+ *   5 runs (1 warm-up): median 812.4ms, best 790.1ms, mean 818.0ms ± 21.3ms
+ *   1482.0 files/s, 10.22 MB/s
+ */
+export function formatBenchmark(result: BenchmarkResult): string {
+  const ms = (value: number) => `${value.toFixed(1)}ms`;
+  return [
+    `${result.root}: ${result.files} files, ${formatBytes(result.bytes)}, ${result.symbols} symbols`,
+    `${result.runs.length} run${result.runs.length === 1 ? '' : 's'}` +
+      `${result.warmup > 0 ? ` (${result.warmup} warm-up)` : ''}: ` +
+      `median ${ms(result.medianMs)}, best ${ms(result.bestMs)}, mean ${ms(result.meanMs)} ± ${ms(result.stddevMs)}`,
+    `${result.filesPerSecond.toFixed(1)} files/s, ${result.megabytesPerSecond.toFixed(2)} MB/s`
+  ].join('\n');
+}
@@ -0,0 +1,139 @@
+/**
+ * Indexer metrics - what the language server exposes on /metrics.
+ *
+ * Index size, query latency, re-index durations, file watcher events and
+ * parse errors, so the indexer can be monitored like any other service.
+ * Values kept by the indexes are read at scrape time; latencies arrive
+ * through the Profiler, the query server and the file watcher.
+ */
+
+import { Counter, Histogram, MetricsRegistry } from './metrics.js';
+import { Profiler } from './profiler.js';
+import { IndexingProgress } from '../utils/indexingProgress.js';
+import { IndexUpdateListener } from '../index/fileWatcher.js';
+
+/** Background index, as far as metrics are concerned */
+export interface MetricsSource {
+  getStats(): { files: number; symbols: number };
+  getParseErrorCount(): number;
+  getIndexingProgress(): IndexingProgress;
+  /** Index generation queries see (see index/IndexSnapshots.ts) */
+  getGeneration?(): number;
+}
+
+/** Index of open documents */
+export interface DynamicMetricsSource {
+  getStats(): { files: number; symbols: number };
+}
+
+export type QueryApi = 'http' | 'lsp';
+export type ReindexKind = 'full' | 'incremental' | 'file';
+
+const QUERY_BUCKETS_SECONDS = [0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5];
+const REINDEX_BUCKETS_SECONDS = [0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900];
+
+/** Profiler keys recorded as LSP query latency */
+const LSP_QUERY_KEYS = ['definition', 'references'];
+
+/** Profiler keys recorded as re-index durations */
+const REINDEX_KEYS: Record<string, ReindexKind> = {
+  fullIndex: 'full',
+  incrementalIndex: 'incremental'
+};
+
+export class IndexerMetrics {
+  private registry = new MetricsRegistry();
+  private queryDuration: Histogram;
+  private reindexDuration: Histogram;
+  private watchEvents: Counter;
+  private queryCacheLookups: Counter;
+
+  constructor(background: MetricsSource, dynamic?: DynamicMetricsSource) {
+    const sizes = (pick: (stats: { files: number; symbols: number }) => number) => () => [
+      { labels: { index: 'background' }, value: pick(background.getStats()) },
+      ...(dynamic ? [{ labels: { index: 'dynamic' }, value: pick(dynamic.getStats()) }] : [])
+    ];
+
+    this.registry.gauge('smart_indexer_index_files', 'Files in the index', ['index'], sizes(stats => stats.files));
+    this.registry.gauge('smart_indexer_index_symbols', 'Symbols in the index', ['index'], sizes(stats => stats.symbols));
+    this.registry.gauge('smart_indexer_indexing_active', 'Whether an indexing run is in progress', [],
+      () => background.getIndexingProgress().phase === 'idle' ? 0 : 1);
+    this.registry.gauge('smart_indexer_indexing_files_remaining', 'Files left in the current indexing run', [], () => {
+      const progress = background.getIndexingProgress();
+      return progress.phase === 'idle' ? 0 : Math.max(0, progress.filesTotal - progress.filesIndexed);
+    });
+    if (background.getGeneration) {
+      this.registry.gauge('smart_indexer_index_generation', 'Index generation queries see', [],
+        () => background.getGeneration!());
+    }
+    this.registry.counter('smart_indexer_parse_errors_total', 'Files that failed to parse', [],
+      () => background.getParseErrorCount());
+
+    this.queryDuration = this.registry.histogram(
+      'smart_indexer_query_duration_seconds', 'Query latency', QUERY_BUCKETS_SECONDS, ['api', 'endpoint']
+    );
+    this.reindexDuration = this.registry.histogram(
+      'smart_indexer_reindex_duration_seconds', 'Duration of index updates', REINDEX_BUCKETS_SECONDS, ['kind']
+    );
+    this.watchEvents = this.registry.counter(
+      'smart_indexer_watch_events_total', 'File watcher events that updated the index', ['trigger']
+    );
+    this.queryCacheLookups = this.registry.counter(
+      'smart_indexer_query_cache_lookups_total', 'Query result cache lookups (see utils/queryCache.ts)', ['api', 'result']
+    );
+
+    this.registry.gauge('process_resident_memory_bytes', 'Resident memory size in bytes', [], () => process.memoryUsage().rss);
+    this.registry.gauge('nodejs_heap_size_used_bytes', 'V8 heap in use in bytes', [], () => process.memoryUsage().heapUsed);
+    const startTime = Math.floor(Date.now() / 1000 - process.uptime());
+    this.registry.gauge('process_start_time_seconds', 'Start time of the process since the Unix epoch', [], () => startTime);
+  }
+
+  observeQuery(api: QueryApi, endpoint: string, durationMs: number): void {
+    this.queryDuration.observe(durationMs / 1000, { api, endpoint });
+  }
+
+  observeReindex(kind: ReindexKind, durationMs: number): void {
+    this.reindexDuration.observe(durationMs / 1000, { kind });
+  }
+
+  countWatchEvent(trigger: string): void {
+    this.watchEvents.inc({ trigger });
+  }
+
+  countQueryCacheLookup(api: QueryApi, hit: boolean): void {
+    this.queryCacheLookups.inc({ api, result: hit ? 'hit' : 'miss' });
+  }
+
+  /**
+   * Record LSP query latency and full/incremental indexing durations as
+   * the profiler sees them.
+   */
+  attachProfiler(profiler: Profiler): { dispose(): void } {
+    return profiler.onRecord((key, durationMs) => {
+      if (LSP_QUERY_KEYS.includes(key)) {
+        this.observeQuery('lsp', key, durationMs);
+      } else if (REINDEX_KEYS[key]) {
+        this.observeReindex(REINDEX_KEYS[key], durationMs);
+      }
+    });
+  }
+
+  /**
+   * Count file watcher updates and time single-file re-indexing.
+   */
+  attachFileWatcher(watcher: { onIndexUpdated(listener: IndexUpdateListener): { dispose(): void } }): { dispose(): void } {
+    return watcher.onIndexUpdated(event => {
+      this.countWatchEvent(event.trigger);
+      if (event.change === 'updated') {
+        this.observeReindex('file', event.durationMs);
+      }
+    });
+  }
+
+  /**
+   * All metrics in the Prometheus text format.
+   */
+  render(): string {
+    return this.registry.render();
+  }
+}
@@ -0,0 +1,127 @@
+/**
+ * Metrics Tests
+ *
+ * Verifies the Prometheus text format and the indexer's metrics.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { MetricsRegistry } from './metrics.js';
+import { IndexerMetrics } from './indexerMetrics.js';
+import { Profiler } from './profiler.js';
+import { IndexUpdateListener } from '../index/fileWatcher.js';
+import { ProgressTracker } from '../utils/indexingProgress.js';
+
+describe('MetricsRegistry', () => {
+  it('should render counters, gauges and histograms', () => {
+    const registry = new MetricsRegistry();
+    const requests = registry.counter('requests_total', 'Requests handled', ['path']);
+    registry.gauge('queue_size', 'Items\nqueued', [], () => 3);
+    const latency = registry.histogram('latency_seconds', 'Latency', [0.5, 0.1]);
+
+    requests.inc({ path: '/a' });
+    requests.inc({ path: '/a' }, 2);
+    requests.inc({ path: 'say "hi"' });
+    latency.observe(0.05);
+    latency.observe(0.3);
+    latency.observe(2);
+
+    expect(registry.render()).toBe([
+      '# HELP requests_total Requests handled',
+      '# TYPE requests_total counter',
+      'requests_total{path="/a"} 3',
+      'requests_total{path="say \\"hi\\""} 1',
+      '# HELP queue_size Items\\nqueued',
+      '# TYPE queue_size gauge',
+      'queue_size 3',
+      '# HELP latency_seconds Latency',
+      '# TYPE latency_seconds histogram',
+      'latency_seconds_bucket{le="0.1"} 1',
+      'latency_seconds_bucket{le="0.5"} 2',
+      'latency_seconds_bucket{le="+Inf"} 3',
+      'latency_seconds_sum 2.35',
+      'latency_seconds_count 3',
+      ''
+    ].join('\n'));
+  });
+
+  it('should reject invalid and duplicate names', () => {
+    const registry = new MetricsRegistry();
+    registry.counter('ok_total', 'ok');
+    expect(() => registry.counter('ok_total', 'again')).toThrow('already registered');
+    expect(() => registry.gauge('bad-name', 'bad')).toThrow('Invalid metric name');
+  });
+});
+
+describe('IndexerMetrics', () => {
+  let tracker: ProgressTracker;
+  let parseErrors: number;
+  let profiler: Profiler;
+  let metrics: IndexerMetrics;
+
+  beforeEach(() => {
+    tracker = new ProgressTracker();
+    tracker.setPhase('idle');
+    parseErrors = 0;
+    profiler = new Profiler();
+    metrics = new IndexerMetrics(
+      {
+        getStats: () => ({ files: 12, symbols: 340 }),
+        getParseErrorCount: () => parseErrors,
+        getIndexingProgress: () => tracker.snapshot(),
+        getGeneration: () => 3
+      },
+      { getStats: () => ({ files: 1, symbols: 9 }) }
+    );
+    metrics.attachProfiler(profiler);
+  });
+
+  const line = (name: string) => metrics.render().split('\n').find(l => l.startsWith(name));
+
+  it('should report index size, indexing state and parse errors', () => {
+    expect(line('smart_indexer_index_files{index="background"}')).toBe('smart_indexer_index_files{index="background"} 12');
+    expect(line('smart_indexer_index_symbols{index="dynamic"}')).toBe('smart_indexer_index_symbols{index="dynamic"} 9');
+    expect(line('smart_indexer_indexing_active')).toBe('smart_indexer_indexing_active 0');
+    expect(line('smart_indexer_index_generation')).toBe('smart_indexer_index_generation 3');
+
+    tracker.startIndexing(10);
+    tracker.fileIndexed('/ws/a.go');
+    parseErrors = 2;
+    expect(line('smart_indexer_indexing_active')).toBe('smart_indexer_indexing_active 1');
+    expect(line('smart_indexer_indexing_files_remaining')).toBe('smart_indexer_indexing_files_remaining 9');
+    expect(line('smart_indexer_parse_errors_total')).toBe('smart_indexer_parse_errors_total 2');
+    expect(line('process_resident_memory_bytes')).toMatch(/^process_resident_memory_bytes \d+$/);
+  });
+
+  it('should record query latency, re-index durations and watch events', () => {
+    metrics.observeQuery('http', '/symbols', 4);
+    profiler.record('definition', 30);
+    profiler.record('fullIndex', 2000);
+    profiler.record('somethingElse', 1);
+
+    let listener: IndexUpdateListener = () => {};
+    metrics.attachFileWatcher({
+      onIndexUpdated: l => {
+        listener = l;
+        return { dispose: () => {} };
+      }
+    });
+    listener({ uri: '/ws/a.go', change: 'updated', trigger: 'file-saved', durationMs: 20 });
+    listener({ uri: '/ws/b.go', change: 'removed', trigger: 'file-deleted', durationMs: 1 });
+
+    expect(line('smart_indexer_query_duration_seconds_bucket{api="http",endpoint="/symbols",le="0.005"}')).toMatch(/ 1$/);
+    expect(line('smart_indexer_query_duration_seconds_count{api="lsp",endpoint="definition"}')).toMatch(/ 1$/);
+    expect(line('smart_indexer_reindex_duration_seconds_sum{kind="full"}')).toBe('smart_indexer_reindex_duration_seconds_sum{kind="full"} 2');
+    expect(line('smart_indexer_reindex_duration_seconds_count{kind="file"}')).toMatch(/ 1$/);
+    expect(line('smart_indexer_watch_events_total{trigger="file-deleted"}')).toMatch(/ 1$/);
+    expect(metrics.render()).not.toContain('somethingElse');
+  });
+
+  it('should count query cache hits and misses', () => {
+    metrics.countQueryCacheLookup('lsp', false);
+    metrics.countQueryCacheLookup('lsp', true);
+    metrics.countQueryCacheLookup('lsp', true);
+
+    expect(line('smart_indexer_query_cache_lookups_total{api="lsp",result="hit"}')).toMatch(/ 2$/);
+    expect(line('smart_indexer_query_cache_lookups_total{api="lsp",result="miss"}')).toMatch(/ 1$/);
+  });
+});
@@ -0,0 +1,214 @@
+/**
+ * Minimal Prometheus metrics registry.
+ *
+ * Counters, gauges and histograms with labels, rendered in the Prometheus
+ * text exposition format (version 0.0.4) without a client library. Gauges
+ * and counters can also be read from a callback at scrape time, for values
+ * that are already tracked elsewhere (index size, worker error counts).
+ */
+
+export type Labels = Record<string, string>;
+
+/** Value read at scrape time: a single value or one per label set */
+export type Collector = () => number | Array<{ labels: Labels; value: number }>;
+
+export const PROMETHEUS_CONTENT_TYPE = 'text/plain; version=0.0.4; charset=utf-8';
+
+interface Metric {
+  render(): string[];
+}
+
+/**
+ * Base for metrics keyed by label values.
+ */
+abstract class LabelledMetric<T> implements Metric {
+  protected series = new Map<string, { labels: Labels; value: T }>();
+
+  constructor(
+    readonly name: string,
+    readonly help: string,
+    protected readonly type: 'counter' | 'gauge' | 'histogram',
+    protected readonly labelNames: readonly string[]
+  ) {}
+
+  protected get(labels: Labels, create: () => T): T {
+    const key = this.labelNames.map(name => labels[name] ?? '').join('\u0000');
+    let entry = this.series.get(key);
+    if (!entry) {
+      entry = { labels: pick(labels, this.labelNames), value: create() };
+      this.series.set(key, entry);
+    }
+    return entry.value;
+  }
+
+  protected header(): string[] {
+    return [`# HELP ${this.name} ${escapeHelp(this.help)}`, `# TYPE ${this.name} ${this.type}`];
+  }
+
+  abstract render(): string[];
+}
+
+/**
+ * Counter or gauge: one number per label set, or read from a collector.
+ */
+abstract class ValueMetric extends LabelledMetric<{ value: number }> {
+  constructor(
+    name: string,
+    help: string,
+    type: 'counter' | 'gauge',
+    labelNames: readonly string[],
+    private collector?: Collector
+  ) {
+    super(name, help, type, labelNames);
+  }
+
+  render(): string[] {
+    const lines = this.header();
+    const values = this.collector
+      ? collect(this.collector)
+      : [...this.series.values()].map(({ labels, value }) => ({ labels, value: value.value }));
+    for (const { labels, value } of values) {
+      lines.push(`${this.name}${formatLabels(labels)} ${formatValue(value)}`);
+    }
+    return lines;
+  }
+}
+
+export class Counter extends ValueMetric {
+  constructor(name: string, help: string, labelNames: readonly string[] = [], collector?: Collector) {
+    super(name, help, 'counter', labelNames, collector);
+  }
+
+  inc(labels: Labels = {}, amount: number = 1): void {
+    this.get(labels, () => ({ value: 0 })).value += amount;
+  }
+}
+
+export class Gauge extends ValueMetric {
+  constructor(name: string, help: string, labelNames: readonly string[] = [], collector?: Collector) {
+    super(name, help, 'gauge', labelNames, collector);
+  }
+
+  set(value: number, labels: Labels = {}): void {
+    this.get(labels, () => ({ value: 0 })).value = value;
+  }
+}
+
+interface HistogramSeries {
+  counts: number[];
+  sum: number;
+  count: number;
+}
+
+export class Histogram extends LabelledMetric<HistogramSeries> {
+  private readonly buckets: number[];
+
+  constructor(name: string, help: string, buckets: readonly number[], labelNames: readonly string[] = []) {
+    super(name, help, 'histogram', labelNames);
+    this.buckets = [...buckets].sort((a, b) => a - b);
+  }
+
+  observe(value: number, labels: Labels = {}): void {
+    const series = this.get(labels, () => ({ counts: this.buckets.map(() => 0), sum: 0, count: 0 }));
+    for (let i = 0; i < this.buckets.length; i++) {
+      if (value <= this.buckets[i]) {
+        series.counts[i]++;
+      }
+    }
+    series.sum += value;
+    series.count++;
+  }
+
+  render(): string[] {
+    const lines = this.header();
+    for (const { labels, value } of this.series.values()) {
+      this.buckets.forEach((bound, i) => {
+        lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: formatValue(bound) })} ${value.counts[i]}`);
+      });
+      lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: '+Inf' })} ${value.count}`);
+      lines.push(`${this.name}_sum${formatLabels(labels)} ${formatValue(value.sum)}`);
+      lines.push(`${this.name}_count${formatLabels(labels)} ${value.count}`);
+    }
+    return lines;
+  }
+}
+
+/**
+ * Registry of metrics, rendered together on scrape.
+ */
+export class MetricsRegistry {
+  private metrics = new Map<string, Metric>();
+
+  counter(name: string, help: string, labelNames: readonly string[] = [], collector?: Collector): Counter {
+    return this.register(name, new Counter(name, help, labelNames, collector));
+  }
+
+  gauge(name: string, help: string, labelNames: readonly string[] = [], collector?: Collector): Gauge {
+    return this.register(name, new Gauge(name, help, labelNames, collector));
+  }
+
+  histogram(name: string, help: string, buckets: readonly number[], labelNames: readonly string[] = []): Histogram {
+    return this.register(name, new Histogram(name, help, buckets, labelNames));
+  }
+
+  /**
+   * All metrics in the text exposition format.
+   */
+  render(): string {
+    const lines: string[] = [];
+    for (const metric of this.metrics.values()) {
+      lines.push(...metric.render());
+    }
+    return lines.join('\n') + '\n';
+  }
+
+  private register<T extends Metric>(name: string, metric: T): T {
+    if (!/^[a-zA-Z_:][a-zA-Z0-9_:]*$/.test(name)) {
+      throw new Error(`Invalid metric name: ${name}`);
+    }
+    if (this.metrics.has(name)) {
+      throw new Error(`Metric already registered: ${name}`);
+    }
+    this.metrics.set(name, metric);
+    return metric;
+  }
+}
+
+function collect(collector: Collector): Array<{ labels: Labels; value: number }> {
+  const value = collector();
+  return typeof value === 'number' ? [{ labels: {}, value }] : value;
+}
+
+function pick(labels: Labels, names: readonly string[]): Labels {
+  const picked: Labels = {};
+  for (const name of names) {
+    picked[name] = labels[name] ?? '';
+  }
+  return picked;
+}
+
+function formatLabels(labels: Labels): string {
+  const entries = Object.entries(labels);
+  if (entries.length === 0) {
+    return '';
+  }
+  return `{${entries.map(([name, value]) => `${name}="${escapeLabelValue(value)}"`).join(',')}}`;
+}
+
+function formatValue(value: number): string {
+  if (Number.isNaN(value)) {
+    return 'NaN';
+  }
+  if (!Number.isFinite(value)) {
+    return value > 0 ? '+Inf' : '-Inf';
+  }
+  return String(value);
+}
+
+function escapeLabelValue(value: string): string {
+  return value.replace(/\\/g, '\\\\').replace(/\n/g, '\\n').replace(/"/g, '\\"');
+}
+
+function escapeHelp(help: string): string {
+  return help.replace(/\\/g, '\\\\').replace(/\n/g, '\\n');
+}
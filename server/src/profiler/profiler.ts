@@ -10,8 +10,11 @@ export interface ProfileMetrics {
   samples: number[];
 }
 
+export type ProfileListener = (key: string, durationMs: number) => void;
+
 export class Profiler {
   private metrics = new Map<string, ProfileMetrics>();
+  private listeners = new Set<ProfileListener>();
 
   record(key: string, durationMs: number): void {
     let metric = this.metrics.get(key);
@@ -36,6 +39,24 @@ export class Profiler {
     if (metric.samples.length > 100) {
       metric.samples.shift();
     }
+
+    for (const listener of this.listeners) {
+      listener(key, durationMs);
+    }
+  }
+
+  /**
+   * Subscribe to every recorded duration (e.g. to export metrics).
+   *
+   * @returns Disposable that removes the listener
+   */
+  onRecord(listener: ProfileListener): { dispose(): void } {
+    this.listeners.add(listener);
+    return {
+      dispose: () => {
+        this.listeners.delete(listener);
+      }
+    };
   }
 
   getMetrics(key: string): ProfileMetrics | undefined {
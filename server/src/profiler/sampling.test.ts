@@ -0,0 +1,218 @@
+/**
+ * Sampling Profiler Tests
+ *
+ * Verifies the pprof encoding of CPU and heap profiles (decoded back from
+ * profile.proto), the files written per extension and that one profile
+ * of each kind runs at a time.
+ */
+
+import { describe, it, expect, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { CpuProfile, HeapProfile, ProfileCallFrame, ProfilerBusyError, SamplingProfiler, toPprof } from './sampling.js';
+import { ProtoReader } from '../utils/protoWire.js';
+
+interface DecodedProfile {
+  sampleTypes: string[];
+  /** Function names of each sample, leaf first, with its values */
+  samples: Array<{ stack: string[]; values: number[] }>;
+  files: string[];
+  periodType: string;
+  period: number;
+  durationNanos: number;
+}
+
+/** Reads back what toPprof writes, resolving ids and string indexes */
+function decode(data: Buffer): DecodedProfile {
+  const reader = new ProtoReader(zlib.gunzipSync(data));
+  const strings: string[] = [];
+  const valueTypes: Array<[number, number]> = [];
+  const samples: Array<{ locations: number[]; values: number[] }> = [];
+  const locations = new Map<number, number>();
+  const functions = new Map<number, { name: number; file: number }>();
+  let periodType: [number, number] = [0, 0];
+  let period = 0;
+  let durationNanos = 0;
+
+  const valueType = (message: ProtoReader): [number, number] => {
+    const result: [number, number] = [0, 0];
+    while (message.hasMore()) {
+      const { field, wireType } = message.tag();
+      if (field === 1 || field === 2) {
+        result[field - 1] = message.varint();
+      } else {
+        message.skip(wireType);
+      }
+    }
+    return result;
+  };
+
+  while (reader.hasMore()) {
+    const { field, wireType } = reader.tag();
+    if (field === 1) {
+      valueTypes.push(valueType(reader.message()));
+    } else if (field === 2) {
+      const message = reader.message();
+      const sample = { locations: [] as number[], values: [] as number[] };
+      while (message.hasMore()) {
+        const tag = message.tag();
+        if (tag.field === 1) {
+          sample.locations.push(...message.packed(tag.wireType));
+        } else if (tag.field === 2) {
+          sample.values.push(...message.packed(tag.wireType));
+        } else {
+          message.skip(tag.wireType);
+        }
+      }
+      samples.push(sample);
+    } else if (field === 4) {
+      const message = reader.message();
+      let id = 0;
+      let functionId = 0;
+      while (message.hasMore()) {
+        const tag = message.tag();
+        if (tag.field === 1) {
+          id = message.varint();
+        } else if (tag.field === 4) {
+          const line = message.message();
+          while (line.hasMore()) {
+            const lineTag = line.tag();
+            if (lineTag.field === 1) {
+              functionId = line.varint();
+            } else {
+              line.skip(lineTag.wireType);
+            }
+          }
+        } else {
+          message.skip(tag.wireType);
+        }
+      }
+      locations.set(id, functionId);
+    } else if (field === 5) {
+      const message = reader.message();
+      const fn = { id: 0, name: 0, file: 0 };
+      while (message.hasMore()) {
+        const tag = message.tag();
+        if (tag.field === 1) {
+          fn.id = message.varint();
+        } else if (tag.field === 2) {
+          fn.name = message.varint();
+        } else if (tag.field === 4) {
+          fn.file = message.varint();
+        } else {
+          message.skip(tag.wireType);
+        }
+      }
+      functions.set(fn.id, fn);
+    } else if (field === 6) {
+      strings.push(reader.string());
+    } else if (field === 10) {
+      durationNanos = reader.varint();
+    } else if (field === 11) {
+      periodType = valueType(reader.message());
+    } else if (field === 12) {
+      period = reader.varint();
+    } else {
+      reader.skip(wireType);
+    }
+  }
+
+  expect(strings[0]).toBe('');
+  const fn = (locationId: number) => functions.get(locations.get(locationId)!)!;
+  return {
+    sampleTypes: valueTypes.map(([type, unit]) => `${strings[type]}/${strings[unit]}`),
+    samples: samples.map(sample => ({ stack: sample.locations.map(id => strings[fn(id).name]), values: sample.values })),
+    files: [...new Set([...functions.values()].map(f => strings[f.file]))].sort(),
+    periodType: `${strings[periodType[0]]}/${strings[periodType[1]]}`,
+    period,
+    durationNanos
+  };
+}
+
+function frame(functionName: string, url: string = 'file:///app/dist/indexer.js', lineNumber: number = 0): ProfileCallFrame {
+  return { functionName, scriptId: '1', url, lineNumber, columnNumber: 0 };
+}
+
+describe('SamplingProfiler', () => {
+  let tempDir: string | undefined;
+
+  afterEach(() => {
+    if (tempDir) {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+      tempDir = undefined;
+    }
+  });
+
+  it('should encode CPU samples with their stacks, counts and time', () => {
+    const profile: CpuProfile = {
+      nodes: [
+        { id: 1, callFrame: frame('(root)', ''), children: [2] },
+        { id: 2, callFrame: frame('indexDir', 'file:///app/dist/indexer.js', 40), children: [3, 4] },
+        { id: 3, callFrame: frame('parse', 'file:///app/dist/parser.js', 12) },
+        { id: 4, callFrame: frame('', 'node:fs') }
+      ],
+      startTime: 1_000_000,
+      endTime: 1_250_000,
+      samples: [3, 3, 4, 2, 3],
+      timeDeltas: [1000, 1000, 2000, 1000, -5]
+    };
+
+    const decoded = decode(toPprof({ kind: 'cpu', profile, startedAt: Date.now(), durationMs: 250, intervalUs: 1000 }));
+    expect(decoded.sampleTypes).toEqual(['samples/count', 'cpu/nanoseconds']);
+    expect([decoded.periodType, decoded.period, decoded.durationNanos]).toEqual(['cpu/nanoseconds', 1_000_000, 250_000_000]);
+    expect(decoded.samples).toEqual([
+      { stack: ['indexDir'], values: [1, 1_000_000] },
+      { stack: ['parse', 'indexDir'], values: [3, 2_000_000] },
+      { stack: ['(anonymous)', 'indexDir'], values: [1, 2_000_000] }
+    ]);
+    expect(decoded.files).toEqual(['/app/dist/indexer.js', '/app/dist/parser.js', 'node:fs']);
+  });
+
+  it('should encode the live allocations of a heap profile', () => {
+    const profile: HeapProfile = {
+      head: {
+        id: 1, callFrame: frame('(root)', ''), selfSize: 0, children: [
+          { id: 2, callFrame: frame('storeFile'), selfSize: 4096, children: [
+            { id: 3, callFrame: frame('intern'), selfSize: 65536, children: [] }
+          ] },
+          { id: 4, callFrame: frame('scan'), selfSize: 0, children: [] }
+        ]
+      }
+    };
+
+    const decoded = decode(toPprof({ kind: 'heap', profile, startedAt: Date.now(), durationMs: 10, intervalBytes: 32768 }));
+    expect(decoded.sampleTypes).toEqual(['space/bytes']);
+    expect([decoded.periodType, decoded.period]).toEqual(['space/bytes', 32768]);
+    expect(decoded.samples).toEqual([
+      { stack: ['storeFile'], values: [4096] },
+      { stack: ['intern', 'storeFile'], values: [65536] }
+    ]);
+  });
+
+  it('should write profiles of work per extension and take one of each kind at a time', async () => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'sampling-'));
+    const profiler = new SamplingProfiler({ cpuIntervalUs: 100 });
+    const cpuProfile = path.join(tempDir, 'cpu.pb.gz');
+    const heapProfile = path.join(tempDir, 'run', 'heap.heapprofile');
+
+    const result = await profiler.profile({ cpuProfile, heapProfile }, async () => {
+      await expect(profiler.start('cpu')).rejects.toThrow(ProfilerBusyError);
+      let sum = 0;
+      for (let i = 0; i < 200000; i++) {
+        sum += Math.sqrt(i);
+      }
+      return sum;
+    });
+
+    expect(result).toBeGreaterThan(0);
+    expect(decode(fs.readFileSync(cpuProfile)).sampleTypes).toEqual(['samples/count', 'cpu/nanoseconds']);
+    expect(JSON.parse(fs.readFileSync(heapProfile, 'utf-8')).head.callFrame.functionName).toBe('(root)');
+
+    // Both profilers are free again, also after work that throws
+    await expect(profiler.profile({ cpuProfile }, async () => { throw new Error('failed'); })).rejects.toThrow('failed');
+    const stop = await profiler.start('cpu');
+    expect((await stop()).kind).toBe('cpu');
+  });
+});
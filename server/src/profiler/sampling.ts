@@ -0,0 +1,353 @@
+/**
+ * Sampling CPU and heap profiles of this process through the V8
+ * inspector, written as pprof (`go tool pprof`, speedscope) or as the
+ * JSON Chrome DevTools and VS Code open (.cpuprofile, .heapprofile).
+ */
+
+import * as fsPromises from 'fs/promises';
+import * as inspector from 'inspector';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { fileURLToPath } from 'url';
+import { ProtoWriter } from '../utils/protoWire.js';
+
+export type ProfileKind = 'cpu' | 'heap';
+
+export interface ProfileCallFrame {
+  functionName: string;
+  scriptId: string;
+  url: string;
+  /** 0-based; -1 when unknown */
+  lineNumber: number;
+  columnNumber: number;
+}
+
+/** V8 CPU profile (`Profiler.Profile`), as DevTools saves it */
+export interface CpuProfile {
+  nodes: Array<{ id: number; callFrame: ProfileCallFrame; hitCount?: number; children?: number[] }>;
+  /** Microseconds, monotonic */
+  startTime: number;
+  endTime: number;
+  /** Node id of each sample */
+  samples?: number[];
+  /** Microseconds since the previous sample */
+  timeDeltas?: number[];
+}
+
+export interface HeapProfileNode {
+  id: number;
+  callFrame: ProfileCallFrame;
+  /** Bytes allocated here and still live, scaled from the samples */
+  selfSize: number;
+  children: HeapProfileNode[];
+}
+
+/** V8 sampling heap profile (`HeapProfiler.SamplingHeapProfile`) */
+export interface HeapProfile {
+  head: HeapProfileNode;
+  samples?: Array<{ size: number; nodeId: number; ordinal: number }>;
+}
+
+/** A finished profile and when it was taken */
+export type Profile =
+  | { kind: 'cpu'; profile: CpuProfile; startedAt: number; durationMs: number; intervalUs: number }
+  | { kind: 'heap'; profile: HeapProfile; startedAt: number; durationMs: number; intervalBytes: number };
+
+export interface SamplingProfilerOptions {
+  /** Microseconds between CPU samples (default: 1000) */
+  cpuIntervalUs?: number;
+  /** Average bytes allocated between heap samples (default: 32768) */
+  heapIntervalBytes?: number;
+}
+
+/** Thrown when a profile of the same kind is already being taken */
+export class ProfilerBusyError extends Error {}
+
+const DEFAULT_CPU_INTERVAL_US = 1000;
+const DEFAULT_HEAP_INTERVAL_BYTES = 32768;
+
+/** Frames of the CPU profile tree that are not code */
+const ROOT_FRAME = '(root)';
+
+/** Extensions written as DevTools JSON; anything else is gzipped pprof */
+const JSON_EXTENSIONS = new Set(['.cpuprofile', '.heapprofile', '.json']);
+
+/** V8 has one CPU profiler and one sampling heap profiler per isolate */
+const active = new Set<ProfileKind>();
+
+/**
+ * Sampling Profiler - CPU and heap profiles of the running process, for
+ * finding where indexing spends its time and memory.
+ *
+ * start() begins a profile and returns the function that ends it, for
+ * profiling a run from start to finish; capture() takes one for a fixed
+ * time, as the query server's /debug/pprof endpoints do. Only one profile
+ * of each kind runs at a time; another start throws ProfilerBusyError.
+ *
+ * Heap profiles hold the sampled allocations made while profiling that
+ * are still live when it ends, not the whole heap.
+ */
+export class SamplingProfiler {
+  constructor(private options: SamplingProfilerOptions = {}) {}
+
+  async start(kind: ProfileKind): Promise<() => Promise<Profile>> {
+    if (active.has(kind)) {
+      throw new ProfilerBusyError(`A ${kind === 'cpu' ? 'CPU' : 'heap'} profile is already being taken`);
+    }
+    active.add(kind);
+
+    const session = new inspector.Session();
+    const post = <T>(method: string, params?: object): Promise<T> => new Promise((resolve, reject) => {
+      session.post(method, params ?? {}, (error, result) => error ? reject(error) : resolve(result as T));
+    });
+    const startedAt = Date.now();
+    const intervalUs = this.options.cpuIntervalUs ?? DEFAULT_CPU_INTERVAL_US;
+    const intervalBytes = this.options.heapIntervalBytes ?? DEFAULT_HEAP_INTERVAL_BYTES;
+
+    try {
+      session.connect();
+      if (kind === 'cpu') {
+        await post('Profiler.enable');
+        await post('Profiler.setSamplingInterval', { interval: intervalUs });
+        await post('Profiler.start');
+      } else {
+        await post('HeapProfiler.enable');
+        await post('HeapProfiler.startSampling', { samplingInterval: intervalBytes });
+      }
+    } catch (error) {
+      session.disconnect();
+      active.delete(kind);
+      throw error;
+    }
+
+    let stopped: Promise<Profile> | undefined;
+    return () => {
+      stopped ??= (async (): Promise<Profile> => {
+        try {
+          const durationMs = Date.now() - startedAt;
+          if (kind === 'cpu') {
+            const { profile } = await post<{ profile: CpuProfile }>('Profiler.stop');
+            return { kind, profile, startedAt, durationMs, intervalUs };
+          }
+          const { profile } = await post<{ profile: HeapProfile }>('HeapProfiler.stopSampling');
+          return { kind, profile, startedAt, durationMs, intervalBytes };
+        } finally {
+          session.disconnect();
+          active.delete(kind);
+        }
+      })();
+      return stopped;
+    };
+  }
+
+  /**
+   * Profile for durationMs, e.g. while the workload to look at runs.
+   */
+  async capture(kind: ProfileKind, durationMs: number): Promise<Profile> {
+    const stop = await this.start(kind);
+    await new Promise(resolve => setTimeout(resolve, durationMs));
+    return stop();
+  }
+
+  /**
+   * Run work with a CPU and/or heap profile written to the given paths
+   * when it ends, whether it succeeds or throws.
+   */
+  async profile<T>(paths: { cpuProfile?: string; heapProfile?: string }, work: () => Promise<T>): Promise<T> {
+    const stops: Array<[() => Promise<Profile>, string]> = [];
+    try {
+      if (paths.cpuProfile) {
+        stops.push([await this.start('cpu'), paths.cpuProfile]);
+      }
+      if (paths.heapProfile) {
+        stops.push([await this.start('heap'), paths.heapProfile]);
+      }
+      return await work();
+    } finally {
+      for (const [stop, filePath] of stops) {
+        await writeProfile(filePath, await stop());
+      }
+    }
+  }
+}
+
+/**
+ * Write a profile to filePath: DevTools JSON for `.cpuprofile`,
+ * `.heapprofile` and `.json`, gzipped pprof otherwise (`cpu.pb.gz`,
+ * `cpu.out`).
+ */
+export async function writeProfile(filePath: string, profile: Profile): Promise<void> {
+  const data = JSON_EXTENSIONS.has(path.extname(filePath).toLowerCase())
+    ? JSON.stringify(profile.profile)
+    : toPprof(profile);
+  await fsPromises.mkdir(path.dirname(path.resolve(filePath)), { recursive: true });
+  await fsPromises.writeFile(filePath, data);
+}
+
+/**
+ * A profile in pprof's profile.proto format, gzipped. CPU profiles have
+ * `samples/count` and `cpu/nanoseconds` values, heap profiles
+ * `space/bytes`; Go's tools read both.
+ */
+export function toPprof(profile: Profile): Buffer {
+  const builder = new PprofBuilder();
+
+  if (profile.kind === 'cpu') {
+    const { nodes, samples, timeDeltas } = profile.profile;
+    const intervalNs = profile.intervalUs * 1000;
+    const parents = new Map<number, number>();
+    for (const node of nodes) {
+      for (const child of node.children ?? []) {
+        parents.set(child, node.id);
+      }
+    }
+    const counts = new Map<number, number>();
+    const times = new Map<number, number>();
+    if (samples && timeDeltas && samples.length > 0) {
+      samples.forEach((id, i) => {
+        counts.set(id, (counts.get(id) ?? 0) + 1);
+        times.set(id, (times.get(id) ?? 0) + Math.max(0, timeDeltas[i] ?? 0) * 1000);
+      });
+    } else {
+      for (const node of nodes) {
+        counts.set(node.id, node.hitCount ?? 0);
+        times.set(node.id, (node.hitCount ?? 0) * intervalNs);
+      }
+    }
+
+    const byId = new Map(nodes.map(node => [node.id, node]));
+    for (const node of nodes) {
+      const count = counts.get(node.id) ?? 0;
+      if (count === 0 || node.callFrame.functionName === ROOT_FRAME) {
+        continue;
+      }
+      const stack: number[] = [];
+      for (let id: number | undefined = node.id; id !== undefined; id = parents.get(id)) {
+        const frame = byId.get(id)!;
+        if (frame.callFrame.functionName !== ROOT_FRAME) {
+          stack.push(builder.location(id, frame.callFrame));
+        }
+      }
+      builder.sample(stack, [count, Math.round(times.get(node.id) ?? 0)]);
+    }
+    return builder.finish({
+      sampleTypes: [['samples', 'count'], ['cpu', 'nanoseconds']],
+      periodType: ['cpu', 'nanoseconds'],
+      period: intervalNs,
+      startedAt: profile.startedAt,
+      durationNs: Math.max(0, profile.profile.endTime - profile.profile.startTime) * 1000
+    });
+  }
+
+  const visit = (node: HeapProfileNode, stack: HeapProfileNode[]): void => {
+    const frames = [node, ...stack];
+    if (node.selfSize > 0) {
+      builder.sample(frames.map(frame => builder.location(frame.id, frame.callFrame)), [node.selfSize]);
+    }
+    for (const child of node.children) {
+      visit(child, frames);
+    }
+  };
+  // The head is the synthetic root
+  for (const child of profile.profile.head.children) {
+    visit(child, []);
+  }
+  return builder.finish({
+    sampleTypes: [['space', 'bytes']],
+    periodType: ['space', 'bytes'],
+    period: profile.intervalBytes,
+    startedAt: profile.startedAt,
+    durationNs: profile.durationMs * 1e6
+  });
+}
+
+/**
+ * Builds a profile.proto message: one location per profile node, one
+ * function per distinct name, file and line, and the string table.
+ */
+class PprofBuilder {
+  private strings = new Map<string, number>([['', 0]]);
+  private functions = new Map<string, number>();
+  private locations = new Map<number, number>();
+  private message = new ProtoWriter(1024);
+  private tail = new ProtoWriter(1024);
+
+  location(nodeId: number, frame: ProfileCallFrame): number {
+    let id = this.locations.get(nodeId);
+    if (id === undefined) {
+      id = this.locations.size + 1;
+      this.locations.set(nodeId, id);
+      const line = Math.max(0, frame.lineNumber + 1);
+      // Location: id, line { function_id, line }
+      this.tail.message(4, new ProtoWriter(16)
+        .uint(1, id)
+        .message(4, new ProtoWriter(8).uint(1, this.function(frame, line)).uint(2, line)));
+    }
+    return id;
+  }
+
+  sample(locationIds: number[], values: number[]): void {
+    // Sample: location_id (leaf first), value
+    this.message.message(2, new ProtoWriter(32).packed(1, locationIds).packed(2, values));
+  }
+
+  finish(options: {
+    sampleTypes: Array<[string, string]>;
+    periodType: [string, string];
+    period: number;
+    startedAt: number;
+    durationNs: number;
+  }): Buffer {
+    const valueType = ([type, unit]: [string, string]) => new ProtoWriter(8).uint(1, this.string(type)).uint(2, this.string(unit));
+    const head = new ProtoWriter(32);
+    for (const sampleType of options.sampleTypes) {
+      head.message(1, valueType(sampleType));
+    }
+    // time_nanos, duration_nanos, period_type, period
+    const trailer = new ProtoWriter(32)
+      .uint(9, options.startedAt * 1e6)
+      .uint(10, Math.round(options.durationNs))
+      .message(11, valueType(options.periodType))
+      .uint(12, options.period);
+    return zlib.gzipSync(Buffer.concat([
+      head.finish(), this.message.finish(), this.tail.finish(), this.stringTable(), trailer.finish()
+    ]));
+  }
+
+  private function(frame: ProfileCallFrame, line: number): number {
+    const name = frame.functionName || '(anonymous)';
+    const file = frame.url.startsWith('file:') ? fileURLToPath(frame.url) : frame.url;
+    const key = `${name}\0${file}\0${line}`;
+    let id = this.functions.get(key);
+    if (id === undefined) {
+      id = this.functions.size + 1;
+      this.functions.set(key, id);
+      // Function: id, name, system_name, filename, start_line
+      this.tail.message(5, new ProtoWriter(16)
+        .uint(1, id)
+        .uint(2, this.string(name))
+        .uint(3, this.string(name))
+        .uint(4, this.string(file))
+        .uint(5, line));
+    }
+    return id;
+  }
+
+  private string(value: string): number {
+    let index = this.strings.get(value);
+    if (index === undefined) {
+      index = this.strings.size;
+      this.strings.set(value, index);
+    }
+    return index;
+  }
+
+  /** string_table; the empty string at index 0 is written too */
+  private stringTable(): Uint8Array {
+    const table = new ProtoWriter(this.strings.size * 16);
+    for (const value of this.strings.keys()) {
+      table.bytes(6, Buffer.from(value, 'utf-8'));
+    }
+    return table.finish();
+  }
+}
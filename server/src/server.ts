@@ -14,31 +14,114 @@ import {
   TextDocuments,
   ProposedFeatures,
   CancellationToken,
-  ResponseError
+  ResponseError,
+  ErrorCodes
 } from 'vscode-languageserver/node';
 
 import { TextDocument } from 'vscode-languageserver-textdocument';
+import { URI } from 'vscode-uri';
 import { GitWatcher } from './git/gitWatcher.js';
 import { FileScanner } from './indexer/fileScanner.js';
 import { SymbolIndexer } from './indexer/symbolIndexer.js';
 import { LanguageRouter } from './indexer/languageRouter.js';
-import { ConfigurationManager } from './config/configurationManager.js';
+import { ConfigurationManager, QueryServerTlsConfig } from './config/configurationManager.js';
 import { DynamicIndex } from './index/dynamicIndex.js';
 import { BackgroundIndex } from './index/backgroundIndex.js';
+import { IndexThrottle } from './index/indexThrottle.js';
 import { MergedIndex } from './index/mergedIndex.js';
 import { StatsManager } from './index/statsManager.js';
 import { NgRxLinkResolver } from './index/resolvers/NgRxLinkResolver.js';
 import { WorkerPool } from './utils/workerPool.js';
+import { ExternalExtractorHost, ExtractingWorkerPool } from './indexer/externalExtractor.js';
+import { resolveBuildContext } from './indexer/goBuildConstraints.js';
 import { Profiler } from './profiler/profiler.js';
 import { FolderHasher } from './cache/folderHasher.js';
-import { LoggerService, LogLevel } from './utils/Logger.js';
+import { LoggerService, LogLevel, parseLogLevels } from './utils/Logger.js';
 import { RequestTracer } from './utils/RequestTracer.js';
+import * as fs from 'fs';
 import * as path from 'path';
 
 // Core module imports
 import { ServerInitializer, DocumentEventHandler } from './core/index.js';
 import { FileSystemService } from './utils/FileSystemService.js';
-import { CancellationError } from './utils/asyncUtils.js';
+import { CancellationError, ProgressCallback } from './utils/asyncUtils.js';
+import { LsifExporter } from './features/lsifExporter.js';
+import { TagsExporter, TagsFormat } from './features/tagsExporter.js';
+import { JsonLinesExporter, JsonLinesRecordType, JSON_LINES_RECORD_TYPES } from './features/jsonLinesExporter.js';
+import { GraphExporter, GraphExportFormat, GraphRelationshipType, GRAPH_EXPORT_FORMATS, GRAPH_RELATIONSHIP_TYPES } from './features/graphExporter.js';
+import { HeatmapExporter, HeatmapFormat, HEATMAP_FORMATS } from './features/heatmapExporter.js';
+import { BinaryIndexExporter } from './features/binaryIndexExporter.js';
+import { BinaryIndexCompression, BINARY_INDEX_COMPRESSIONS, isCompressionSupported } from './index/binaryIndex.js';
+import { RemoteIndexSync } from './features/remoteIndex.js';
+import { indexKeysFor } from './index/indexEncryption.js';
+import { IndexShardBuilder, mergeShards, ShardStrategy, SHARD_STRATEGIES } from './features/indexShards.js';
+import { CallGraph, CallGraphDirection, CallGraphQuery, CallGraphReport } from './features/callGraph.js';
+import { DocMentionQuery, DocMentionReport, DocsIndex, DocSearchQuery, DocSearchReport } from './features/docsIndex.js';
+import { DependencyGraph } from './features/dependencyGraph.js';
+import { SymbolHistoryIndex, SymbolWithHistory } from './features/symbolHistory.js';
+import { IndexDiff } from './features/indexDiff.js';
+import { ApiSurface, formatApiSurface } from './features/apiSurface.js';
+import { SemverCheck } from './features/semverCheck.js';
+import { PolicyCheck, formatPolicyReport } from './features/policyCheck.js';
+import {
+  DEFAULT_METRIC_THRESHOLDS, SARIF_ANALYSES, SarifAnalysis, SarifAnalysisName,
+  deadCodeFindings, deprecationFindings, metricFindings, policyFindings, toSarif
+} from './features/sarif.js';
+import { EmbeddingIndex } from './features/embeddingIndex.js';
+import { EmbeddingProvider, createEmbeddingProvider } from './features/embeddingProvider.js';
+import {
+  SummaryListOptions,
+  SummaryLookup,
+  SummaryUpdateOptions,
+  SymbolSummaries,
+  SymbolSummaryStats
+} from './features/symbolSummaries.js';
+import { SummaryProvider, createSummaryProvider } from './features/summaryProvider.js';
+import { TypeModel } from './features/typeModel.js';
+import { ImplementationIndex } from './features/interfaceImplementations.js';
+import { TypeHierarchy, TypeHierarchyDirection } from './features/typeHierarchy.js';
+import { OverlaySource, QueryServer } from './features/queryServer.js';
+import { IndexCompletions } from './features/completions.js';
+import { QueryListenOptions } from './features/queryListener.js';
+import { QueryServerAccessRule, QueryServerAuth } from './features/queryServerAuth.js';
+import { IndexerMetrics } from './profiler/indexerMetrics.js';
+import { SamplingProfiler } from './profiler/sampling.js';
+import { formatBenchmark } from './profiler/benchmark.js';
+import { Indexer } from './api/indexer.js';
+import { ContentIndex } from './features/contentIndex.js';
+import { TrigramIndex } from './features/trigramIndex.js';
+import { FileSkipList } from './features/fileSkipList.js';
+import { Deprecations } from './features/deprecations.js';
+import {
+  CommentMarkerIndex,
+  CommentMarkerQuery,
+  CommentMarkerReport,
+  MarkerBlame,
+  MarkerQueryError
+} from './features/commentMarkers.js';
+import { HttpRouteIndex, HttpRouteQuery, HttpRouteReport, RouteQueryError } from './features/httpRoutes.js';
+import { SqlQueryFilter, SqlQueryIndex, SqlQueryReport } from './features/sqlQueries.js';
+import { ConfigKeyIndex, ConfigKeyQuery, ConfigKeyReport } from './features/configKeys.js';
+import { ConcurrencyIndex, ConcurrencyQuery, ConcurrencyReport } from './features/concurrency.js';
+import { ErrorPathIndex, ErrorPathQuery, ErrorPathReport } from './features/errorPaths.js';
+import { CoverageIndex, CoverageLookup, CoverageQuery, CoverageReport } from './features/coverage.js';
+import { GoTestIndex, TestsForQuery, TestsForReport } from './features/goTests.js';
+import { AffectedTests, AffectedTestsQuery, AffectedTestsReport } from './features/affectedTests.js';
+import { SignatureSearch } from './features/signatureSearch.js';
+import { StructuredQuery } from './features/structuredQuery.js';
+import { ScopeError, SearchScopes } from './features/searchScopes.js';
+import { RenameImpactAnalyzer } from './features/renameImpact.js';
+import { CloneDetector } from './features/cloneDetection.js';
+import { CodeMetrics, CodeMetric } from './features/codeMetrics.js';
+import { ParseErrors } from './features/parseErrors.js';
+import { Ownership } from './features/ownership.js';
+import { PositionLookup } from './features/positionLookup.js';
+import { OwnerLookup } from './utils/codeOwners.js';
+import { CodeTagFilter } from './utils/codeTags.js';
+import { QuerySyntaxError } from './utils/queryLanguage.js';
+import { CursorError } from './utils/pagination.js';
+import { parseSignaturePattern } from './utils/signatures.js';
+import { ContentKind } from './indexer/components/ContentScanner.js';
 
 // Plugin system initialization
 import { initializeDefaultPlugins } from './plugins/index.js';
@@ -59,6 +142,7 @@ import {
   createRenameHandler,
   createWorkspaceSymbolHandler,
   createImplementationHandler,
+  createDocumentSymbolHandler,
   DeadCodeHandler
 } from './handlers/index.js';
 
@@ -69,13 +153,15 @@ import {
 const connection = createConnection(ProposedFeatures.all);
 const documents: TextDocuments<TextDocument> = new TextDocuments(TextDocument);
 
-// Unified logger (replaces scattered console.log/connection.console.log calls)
+// Unified logger (replaces scattered console.log/connection.console.log calls);
+// subsystems log under their scope, whose level smartIndexer.logLevel can set
 const logger = new LoggerService(connection, LogLevel.INFO);
+const serverLogger = logger.scope('server');
 
 // Core services
 const configManager = new ConfigurationManager();
 const gitWatcher = new GitWatcher();
-const fileScanner = new FileScanner();
+const fileScanner = new FileScanner(logger.scope('walker'));
 const symbolIndexer = new SymbolIndexer();
 const languageRouter = new LanguageRouter(symbolIndexer, false);
 const profiler = new Profiler();
@@ -85,17 +171,116 @@ const fileSystem = new FileSystemService();
 // Infrastructure components (injected into BackgroundIndex)
 import { SqlWorkerProxy } from './storage/SqlWorkerProxy.js';
 
-const storage = new SqlWorkerProxy(2000); // Auto-save every 2 seconds (handled in worker)
+const storage = new SqlWorkerProxy(2000, logger.scope('store')); // Auto-save every 2 seconds (handled in worker)
 const workerScriptPath = path.join(__dirname, 'indexer', 'worker.js');
-const workerPool = new WorkerPool(workerScriptPath, 4, logger);
+// External extractors (smartIndexer.extractors) post-process every worker result
+const extractorHost = new ExternalExtractorHost(configManager, logger.scope('parser'));
+const baseWorkerPool = new WorkerPool(workerScriptPath, 4, logger.scope('parser'));
+const workerPool = new ExtractingWorkerPool(baseWorkerPool, extractorHost);
 const ngrxResolver = new NgRxLinkResolver(storage);
 
 // Index architecture (clangd-inspired 3-tier)
 const dynamicIndex = new DynamicIndex(symbolIndexer);
-const backgroundIndex = new BackgroundIndex(symbolIndexer, storage, workerPool, ngrxResolver, logger);
+const backgroundIndex = new BackgroundIndex(symbolIndexer, storage, workerPool, ngrxResolver, logger.scope('index'));
 const mergedIndex = new MergedIndex(dynamicIndex, backgroundIndex);
+// Nice mode for re-indexing while the user works (smartIndexer.throttle)
+const indexThrottle = new IndexThrottle({}, { logger: logger.scope('index') });
+backgroundIndex.setThrottle(indexThrottle);
 const statsManager = new StatsManager();
-const requestTracer = new RequestTracer(logger);
+const requestTracer = new RequestTracer(serverLogger);
+const metrics = new IndexerMetrics(backgroundIndex, dynamicIndex);
+metrics.attachProfiler(profiler);
+// CODEOWNERS of the workspace, read once the workspace root is known
+const codeOwners: OwnerLookup = { ownersOf: filePath => configManager.getCodeOwners()?.ownersOf(filePath) ?? [] };
+// Coverage profiles from the settings, resolved against the workspace root when read
+const coverageIndex = new CoverageIndex(backgroundIndex);
+const coverage: CoverageLookup = {
+  refresh: () => {
+    coverageIndex.setProfiles(coverageProfiles());
+    return coverageIndex.refresh();
+  },
+  coverageOf: symbol => coverageIndex.coverageOf(symbol)
+};
+const ownership = new Ownership(backgroundIndex, codeOwners);
+// Summaries of complex symbols for hover and symbol output (smartIndexer.summaries)
+const summaryLookup: SummaryLookup = {
+  summaryOf: id => {
+    try {
+      return getSymbolSummaries()?.summaryOf(id);
+    } catch {
+      return undefined; // Misconfigured provider; the summarize request reports it
+    }
+  }
+};
+/** Buffers POSTed to /overlay, indexed in the dynamic index like open documents */
+const httpOverlays = new Set<string>();
+const overlaySource: OverlaySource = {
+  overlay: async (uri, content) => {
+    const file = path.resolve(serverState.workspaceRoot || process.cwd(), uri);
+    const shadowsIndexed = dynamicIndex.hasFile(file) || (await backgroundIndex.getFileResult(file)) !== null;
+    await dynamicIndex.updateFile(file, content);
+    httpOverlays.add(file);
+    return { uri: file, shadowsIndexed };
+  },
+  clear: uri => {
+    const files = uri !== undefined
+      ? [path.resolve(serverState.workspaceRoot || process.cwd(), uri)].filter(file => httpOverlays.has(file))
+      : [...httpOverlays];
+    for (const file of files) {
+      httpOverlays.delete(file);
+      // Documents open in the editor go back to their editor text
+      const document = documents.get(URI.file(file).toString());
+      if (document) {
+        void dynamicIndex.updateFile(file, document.getText());
+      } else {
+        dynamicIndex.removeFile(file);
+      }
+    }
+    return files.length;
+  }
+};
+const shellCompletions = new IndexCompletions(
+  { searchSymbols: (query, limit) => mergedIndex.searchSymbols(query, limit), getAllFiles: () => backgroundIndex.getAllFiles() },
+  () => serverState.workspaceRoot ? [serverState.workspaceRoot] : []
+);
+const queryServer = new QueryServer(
+  mergedIndex, serverLogger, undefined, new SignatureSearch(backgroundIndex), new StructuredQuery(backgroundIndex, codeOwners, coverage),
+  () => backgroundIndex.getIndexingProgress(), metrics, new CodeMetrics(backgroundIndex), ownership,
+  // Open documents first: their shards in the background index may be stale
+  new PositionLookup({
+    getFileResult: async uri => dynamicIndex.getFileResult(uri) ?? backgroundIndex.getFileResult(uri),
+    findDefinitions: name => mergedIndex.findDefinitions(name)
+  }),
+  query => queryCommentMarkers(query),
+  query => queryHttpRoutes(query),
+  filter => querySql(filter),
+  query => queryConfigKeys(query),
+  query => queryConcurrency(query),
+  query => queryErrorPaths(query),
+  query => queryCoverage(query),
+  query => queryTestsFor(query),
+  query => queryAffectedTests(query),
+  query => queryCallGraph(query),
+  {
+    search: query => queryDocs(query),
+    mentions: query => queryDocMentions(query),
+    links: async uri => {
+      await docsIndex.build();
+      return docsIndex.links(uri);
+    }
+  },
+  overlaySource,
+  query => shellCompletions.complete(query)
+);
+queryServer.setSummaries(summaryLookup);
+const commentMarkerIndex = new CommentMarkerIndex(backgroundIndex);
+const httpRouteIndex = new HttpRouteIndex(backgroundIndex);
+const sqlQueryIndex = new SqlQueryIndex(backgroundIndex);
+const configKeyIndex = new ConfigKeyIndex(backgroundIndex);
+const concurrencyIndex = new ConcurrencyIndex(backgroundIndex);
+const errorPathIndex = new ErrorPathIndex(backgroundIndex);
+const goTestIndex = new GoTestIndex(backgroundIndex);
+const docsIndex = new DocsIndex(backgroundIndex);
 
 // ============================================================================
 // Server Initializer and Document Event Handler
@@ -160,8 +345,10 @@ const serverServices: ServerServices = {
   profiler,
   statsManager,
   requestTracer,
+  metrics,
   workspaceRoot: '',
   logger,
+  summaries: summaryLookup,
   infrastructure: {
     languageRouter,
     fileScanner,
@@ -181,6 +368,7 @@ handlerRegistry.register(createHoverHandler);
 handlerRegistry.register(createRenameHandler);
 handlerRegistry.register(createWorkspaceSymbolHandler);
 handlerRegistry.register(createImplementationHandler);
+handlerRegistry.register(createDocumentSymbolHandler);
 
 // ============================================================================
 // LSP Lifecycle Handlers
@@ -197,6 +385,11 @@ connection.onInitialize(async (params) => {
   serverState.importResolver = initResult.importResolver;
   serverServices.workspaceRoot = initResult.workspaceRoot;
   serverServices.importResolver = initResult.importResolver;
+  extractorHost.setWorkspaceRoot(initResult.workspaceRoot);
+  configManager.setWorkspaceRoots(initResult.workspaceRoots);
+  applyContentFilters();
+  applyLoggingConfig();
+  indexThrottle.configure(configManager.getThrottleConfig());
   
   return result;
 });
@@ -212,6 +405,9 @@ connection.onInitialized(async () => {
   serverServices.deadCodeDetector = initResult.deadCodeDetector;
   serverServices.fileWatcher = initResult.fileWatcher;
   serverServices.staticIndex = initResult.staticIndex;
+  if (initResult.fileWatcher) {
+    metrics.attachFileWatcher(initResult.fileWatcher);
+  }
   
   // Initialize document event handler
   documentEventHandler = new DocumentEventHandler(
@@ -235,16 +431,113 @@ connection.onInitialized(async () => {
       initResult.fileWatcher.setDeadCodeHandler(deadCodeHandler);
     }
     
-    connection.console.info('[Server] DeadCodeHandler registered');
+    serverLogger.info('[Server] DeadCodeHandler registered');
   }
   
   // Register document event handlers
   documentEventHandler.register();
+
+  await applyQueryServerConfig();
+});
+
+/**
+ * Start, stop or rebind the HTTP query server to match the current config.
+ */
+let queryServerBinding: string | null = null;
+
+async function applyQueryServerConfig(): Promise<void> {
+  const { enabled, port, host, grpcPort, accessRules, tls, pprof } = configManager.getQueryServerConfig();
+  queryServer.setCacheSize(configManager.getSearchConfig().cacheSize);
+  queryServer.setScopes(new SearchScopes(configManager.getSearchConfig()));
+  queryServer.setProfiler(pprof ? new SamplingProfiler() : undefined);
+  // Changed tokens or certificates restart the server too
+  const binding = JSON.stringify({ host, port, grpcPort, accessRules, tls });
+
+  try {
+    if (!enabled) {
+      queryServerBinding = null;
+      await queryServer.stop();
+      return;
+    }
+    if (queryServer.isRunning() && queryServerBinding === binding) {
+      return;
+    }
+    const options = await queryServerListenOptions(accessRules, tls);
+    const bound = await queryServer.start(port, host, options);
+    if (grpcPort) {
+      const grpc = await queryServer.startGrpc(grpcPort, host, options);
+      serverLogger.info(`[Server] Query server serving gRPC on ${grpc.address}:${grpc.port}`);
+    } else {
+      await queryServer.stopGrpc();
+    }
+    queryServerBinding = binding;
+    serverLogger.info(`[Server] Query server listening on ${queryServer.isSecure() ? 'https' : 'http'}://${bound.address}:${bound.port}`);
+    if (accessRules.length === 0 && host !== '127.0.0.1' && host !== 'localhost' && host !== '::1') {
+      serverLogger.warn(`[Server] Query server on ${host} has no access rules: anyone who can reach it can read the index`);
+    }
+  } catch (error) {
+    // Bad rules or certificates must not leave the old server running
+    queryServerBinding = null;
+    await queryServer.stop();
+    serverLogger.error(`[Server] Failed to start query server on ${host}:${port}${grpcPort ? ` (gRPC ${grpcPort})` : ''}: ${error}`);
+  }
+}
+
+/**
+ * Credentials and TLS of the query server; certificate files are read
+ * relative to the workspace root.
+ */
+async function queryServerListenOptions(accessRules: QueryServerAccessRule[], tls: QueryServerTlsConfig | undefined): Promise<QueryListenOptions> {
+  const readPem = (file: string) => fs.promises.readFile(path.resolve(serverState.workspaceRoot, file));
+  return {
+    auth: accessRules.length > 0 ? new QueryServerAuth(accessRules) : undefined,
+    tls: tls && {
+      cert: await readPem(tls.certFile),
+      key: await readPem(tls.keyFile),
+      ca: tls.caFile ? await readPem(tls.caFile) : undefined
+    }
+  };
+}
+
+connection.onRequest('smart-indexer/webUi', async () => {
+  // The UI is served by the query server; null while it is disabled
+  const address = queryServer.address();
+  if (!address) {
+    return { url: null };
+  }
+  const host = address.address === '0.0.0.0' || address.address === '::' ? '127.0.0.1' : address.address;
+  return { url: `${queryServer.isSecure() ? 'https' : 'http'}://${host.includes(':') ? `[${host}]` : host}:${address.port}/` };
 });
 
+/**
+ * Generated-file detection, Go build constraints and Bazel targets need the
+ * file content or BUILD files, so they are applied in the workers.
+ */
+function applyContentFilters(): void {
+  const goBuild = configManager.getGoBuildConfig();
+  baseWorkerPool.setTaskDefaults({
+    includeGenerated: !configManager.getIgnoreConfig().generated,
+    goBuildContext: goBuild.allConfigs ? undefined : resolveBuildContext(goBuild),
+    bazel: configManager.getBazelConfig().enabled
+  });
+}
+
+/**
+ * Levels and format of the log, from smartIndexer.logLevel and logFormat.
+ */
+function applyLoggingConfig(): void {
+  const { logLevel, logFormat } = configManager.getConfig();
+  try {
+    logger.setLevels(parseLogLevels(logLevel));
+  } catch (error) {
+    serverLogger.warn(`[Server] Ignoring smartIndexer.logLevel: ${error instanceof Error ? error.message : error}`);
+  }
+  logger.setFormat(logFormat);
+}
+
 connection.onDidChangeConfiguration(change => {
   try {
-    connection.console.info('[Server] Configuration changed');
+    serverLogger.info('[Server] Configuration changed');
     if (change.settings?.smartIndexer) {
       configManager.updateFromSettings(change.settings.smartIndexer);
       const config = configManager.getConfig();
@@ -255,13 +548,17 @@ connection.onDidChangeConfiguration(change => {
         configManager
       });
       
+      applyContentFilters();
+      applyLoggingConfig();
       backgroundIndex.setMaxConcurrentJobs(config.maxConcurrentIndexJobs);
+      indexThrottle.configure(configManager.getThrottleConfig());
       storage.setAutoSaveDelay(config.autoSaveDelay); // Update autoSaveDelay for SqlWorkerProxy
+      void applyQueryServerConfig();
       
-      connection.console.info('[Server] Configuration updated and applied');
+      serverLogger.info('[Server] Configuration updated and applied');
     }
   } catch (error) {
-    logger.error(`[Server] Error updating configuration: ${error}`);
+    serverLogger.error(`[Server] Error updating configuration: ${error}`);
   }
 });
 
@@ -271,41 +568,53 @@ connection.onDidChangeConfiguration(change => {
 
 connection.onRequest('smart-indexer/rebuildIndex', async () => {
   try {
-    connection.console.info('[Server] ========== REBUILD INDEX COMMAND ==========');
+    serverLogger.info('[Server] ========== REBUILD INDEX COMMAND ==========');
     await backgroundIndex.clear();
-    connection.console.info('[Server] Background index cleared');
+    serverLogger.info('[Server] Background index cleared');
     // Note: Full reindexing would need to be triggered separately
     const stats = statsManager.getStats();
-    connection.console.info(`[Server] ========== REBUILD COMPLETE ==========`);
+    serverLogger.info(`[Server] ========== REBUILD COMPLETE ==========`);
     return stats;
   } catch (error) {
-    logger.error(`[Server] Error rebuilding index: ${error}`);
+    serverLogger.error(`[Server] Error rebuilding index: ${error}`);
     throw error;
   }
 });
 
 connection.onRequest('smart-indexer/clearCache', async () => {
   try {
-    connection.console.info('[Server] Clear cache command received');
+    serverLogger.info('[Server] Clear cache command received');
     await backgroundIndex.clear();
     statsManager.reset();
-    connection.console.info('[Server] Cache cleared successfully');
+    serverLogger.info('[Server] Cache cleared successfully');
     return { success: true };
   } catch (error) {
-    logger.error(`[Server] Error clearing cache: ${error}`);
+    serverLogger.error(`[Server] Error clearing cache: ${error}`);
     throw error;
   }
 });
 
 connection.onRequest('smart-indexer/getStats', async () => {
   try {
-    connection.console.info('[Server] ========== GET STATS REQUEST ==========');
+    serverLogger.info('[Server] ========== GET STATS REQUEST ==========');
     updateStats();
     const stats = statsManager.getStats();
-    connection.console.info(`[Server] Returning stats to client: totalFiles=${stats.totalFiles}, totalSymbols=${stats.totalSymbols}`);
+    serverLogger.info(`[Server] Returning stats to client: totalFiles=${stats.totalFiles}, totalSymbols=${stats.totalSymbols}`);
     return stats;
   } catch (error) {
-    logger.error(`[Server] Error getting stats: ${error}`);
+    serverLogger.error(`[Server] Error getting stats: ${error}`);
+    throw error;
+  }
+});
+
+// Per-root file and symbol counts of a (multi-root) workspace
+connection.onRequest('smart-indexer/workspaceRoots', async () => {
+  try {
+    const roots = serverInitializer.getWorkspaceRoots();
+    serverLogger.info(`[Server] Returning ${roots.length} workspace roots to client`);
+    return roots;
+  } catch (error) {
+    serverLogger.error(`[Server] Error listing workspace roots: ${error}`);
     throw error;
   }
 });
@@ -313,23 +622,23 @@ connection.onRequest('smart-indexer/getStats', async () => {
 // Get forensic debug traces (flight recorder data)
 connection.onRequest('smart-indexer/getDebugTraces', async () => {
   try {
-    connection.console.info('[Server] ========== GET DEBUG TRACES REQUEST ==========');
+    serverLogger.info('[Server] ========== GET DEBUG TRACES REQUEST ==========');
     
     // Import RequestTracer to access static history
     const { RequestTracer } = await import('./utils/RequestTracer.js');
     const traces = RequestTracer.getHistory();
     
-    connection.console.info(`[Server] Returning ${traces.length} debug traces to client`);
+    serverLogger.info(`[Server] Returning ${traces.length} debug traces to client`);
     return traces;
   } catch (error) {
-    logger.error(`[Server] Error getting debug traces: ${error}`);
+    serverLogger.error(`[Server] Error getting debug traces: ${error}`);
     throw error;
   }
 });
 
 connection.onRequest('smart-indexer/inspectIndex', async () => {
   try {
-    connection.console.info('[Server] ========== INSPECT INDEX REQUEST ==========');
+    serverLogger.info('[Server] ========== INSPECT INDEX REQUEST ==========');
     
     const config = configManager.getConfig();
     const workspaceRoot = serverState.workspaceRoot;
@@ -385,7 +694,7 @@ connection.onRequest('smart-indexer/inspectIndex', async () => {
     
     const stats = statsManager.getStats();
     
-    connection.console.info(`[Server] Inspect index: ${folderBreakdown.length} folders analyzed`);
+    serverLogger.info(`[Server] Inspect index: ${folderBreakdown.length} folders analyzed`);
     
     return {
       totalFiles: stats.totalFiles,
@@ -400,7 +709,7 @@ connection.onRequest('smart-indexer/inspectIndex', async () => {
       folderBreakdown
     };
   } catch (error) {
-    logger.error(`[Server] Error inspecting index: ${error}`);
+    serverLogger.error(`[Server] Error inspecting index: ${error}`);
     throw error;
   }
 });
@@ -410,7 +719,7 @@ connection.onRequest('smart-indexer/findDeadCode', async (options: {
   includeTests?: boolean;
 } | undefined, token: CancellationToken) => {
   try {
-    connection.console.info('[Server] ========== FIND DEAD CODE REQUEST ==========');
+    serverLogger.info('[Server] ========== FIND DEAD CODE REQUEST ==========');
     
     const deadCodeDetector = serverState.deadCodeDetector;
     if (!deadCodeDetector) {
@@ -435,7 +744,7 @@ connection.onRequest('smart-indexer/findDeadCode', async (options: {
       
       const duration = Date.now() - start;
       
-      connection.console.info(
+      serverLogger.info(
         `[Server] Dead code analysis complete: ${result.candidates.length} candidates found ` +
         `(${result.analyzedFiles} files analyzed, ${result.totalExports} exports checked) in ${duration}ms`
       );
@@ -459,54 +768,2614 @@ connection.onRequest('smart-indexer/findDeadCode', async (options: {
   } catch (error) {
     // Handle cancellation specifically
     if (error instanceof CancellationError || token.isCancellationRequested) {
-      connection.console.info('[Server] Dead code analysis cancelled by user');
+      serverLogger.info('[Server] Dead code analysis cancelled by user');
       throw new ResponseError(-32800, 'Dead code analysis cancelled');
     }
     
-    logger.error(`[Server] Error finding dead code: ${error}`);
+    serverLogger.error(`[Server] Error finding dead code: ${error}`);
     throw error;
   }
 });
 
-// ============================================================================
-// Helper Functions
-// ============================================================================
+connection.onRequest('smart-indexer/deadCodeReport', async (options: {
+  format?: 'text' | 'json' | 'sarif';
+  includeTests?: boolean;
+  includeExported?: boolean;
+  scopePath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== DEAD CODE REPORT REQUEST ==========');
+    
+    const deadCodeDetector = serverState.deadCodeDetector;
+    if (!deadCodeDetector) {
+      throw new Error('Dead code detector not initialized');
+    }
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Dead Code Report', 0, 'Collecting references...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const deadCodeConfig = configManager.getDeadCodeConfig();
+      const report = await deadCodeDetector.findUnreferencedSymbols({
+        allowlist: deadCodeConfig.allowlist,
+        excludePatterns: deadCodeConfig.excludePatterns,
+        includeTests: options?.includeTests,
+        includeExported: options?.includeExported,
+        scopePath: options?.scopePath,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const format = options?.format ?? 'text';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Dead code report: ${report.symbols.length} unreferenced symbols ` +
+        `(${report.checkedSymbols} checked in ${report.analyzedFiles} files) in ${duration}ms`
+      );
+      
+      const symbols = report.symbols.map(entry => ({
+        name: entry.symbol.name,
+        kind: entry.symbol.kind,
+        containerName: entry.symbol.containerName,
+        filePath: entry.symbol.filePath,
+        location: entry.symbol.location,
+        exported: entry.exported,
+        reason: entry.reason,
+        confidence: entry.confidence
+      }));
+      
+      return {
+        format,
+        content: format === 'json'
+          ? JSON.stringify({ ...report, symbols }, null, 2)
+          : format === 'sarif'
+            ? JSON.stringify(toSarif([deadCodeFindings(report)], serverState.workspaceRoot), null, 2)
+            : deadCodeDetector.formatUnreferencedReport(report, serverState.workspaceRoot),
+        count: symbols.length,
+        checkedSymbols: report.checkedSymbols,
+        analyzedFiles: report.analyzedFiles,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Dead code report cancelled by user');
+      throw new ResponseError(-32800, 'Dead code report cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building dead code report: ${error}`);
+    throw error;
+  }
+});
 
-function updateStats(): void {
-  const dynamicStats = dynamicIndex.getStats();
-  const backgroundStats = backgroundIndex.getStats();
-  
-  statsManager.updateDynamicStats(dynamicStats.files, dynamicStats.symbols);
-  statsManager.updateBackgroundStats(backgroundStats.files, backgroundStats.symbols, backgroundStats.shards);
+connection.onRequest('smart-indexer/exportSarif', async (options: {
+  analyses?: SarifAnalysisName[];
+  /** Policies: only code new since this commit */
+  since?: string;
+  /** Metrics: thresholds functions are reported at (default: complexity 25) */
+  thresholds?: Partial<Record<CodeMetric, number>>;
+  includeTests?: boolean;
+  outputPath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    const analyses = options?.analyses?.length ? options.analyses : [...SARIF_ANALYSES];
+    serverLogger.info(`[Server] ========== EXPORT SARIF REQUEST: ${analyses.join(', ')} ==========`);
+    
+    const unknown = analyses.filter(name => !SARIF_ANALYSES.includes(name));
+    if (unknown.length > 0) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown analyses: ${unknown.join(', ')}`);
+    }
+    const workspaceRoot = serverState.workspaceRoot;
+    if (!workspaceRoot) {
+      throw new Error('No workspace root available');
+    }
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', 'findings.sarif');
+    const tags: CodeTagFilter = { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] };
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting SARIF', 0, 'Running analyses...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const runs: SarifAnalysis[] = [];
+      const skipped: string[] = [];
+      for (let i = 0; i < analyses.length; i++) {
+        progress.report(Math.round((i / analyses.length) * 100), `Running ${analyses[i]} (${i + 1}/${analyses.length})`);
+        switch (analyses[i]) {
+          case 'dead-code': {
+            const deadCodeDetector = serverState.deadCodeDetector;
+            if (!deadCodeDetector) {
+              throw new Error('Dead code detector not initialized');
+            }
+            const deadCodeConfig = configManager.getDeadCodeConfig();
+            runs.push(deadCodeFindings(await deadCodeDetector.findUnreferencedSymbols({
+              allowlist: deadCodeConfig.allowlist,
+              excludePatterns: deadCodeConfig.excludePatterns,
+              includeTests: options?.includeTests,
+              cancellationToken: token
+            })));
+            break;
+          }
+          case 'policies': {
+            const policies = configManager.getConfig().policies ?? [];
+            if (policies.length === 0) {
+              skipped.push('policies (none configured)');
+              break;
+            }
+            const policyCheck = new PolicyCheck(backgroundIndex, workspaceRoot, (uri, content) => languageRouter.indexFile(uri, content));
+            runs.push(policyFindings(await policyCheck.check(policies, { since: options?.since || undefined, cancellationToken: token })));
+            break;
+          }
+          case 'deprecations':
+            runs.push(deprecationFindings(await new Deprecations(backgroundIndex, codeOwners).report({ tags, cancellationToken: token })));
+            break;
+          case 'metrics':
+            runs.push(metricFindings(
+              await new CodeMetrics(backgroundIndex).report({ limit: Infinity, tags, cancellationToken: token }),
+              options?.thresholds ?? DEFAULT_METRIC_THRESHOLDS
+            ));
+            break;
+        }
+      }
+      
+      const log = toSarif(runs, workspaceRoot);
+      await fs.promises.mkdir(path.dirname(outputPath), { recursive: true });
+      await fs.promises.writeFile(outputPath, JSON.stringify(log, null, 2));
+      
+      const results = log.runs.reduce((sum, run) => sum + run.results.length, 0);
+      const duration = Date.now() - start;
+      serverLogger.info(
+        `[Server] SARIF export complete: ${results} results from ${runs.length} analyses` +
+        `${skipped.length > 0 ? `, skipped ${skipped.join(', ')}` : ''} in ${duration}ms -> ${outputPath}`
+      );
+      
+      return {
+        outputPath,
+        results,
+        runs: runs.map(run => ({ analysis: run.category, results: run.findings.length })),
+        skipped,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] SARIF export cancelled by user');
+      throw new ResponseError(-32800, 'SARIF export cancelled');
+    }
+    if (error instanceof Error && /^(Invalid policy|Invalid threshold|Not a git repository)/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error exporting SARIF: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/exportLsif', async (options: {
+  outputPath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT LSIF REQUEST ==========');
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', 'dump.lsif');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting LSIF', 0, 'Preparing export...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new LsifExporter(backgroundIndex, workspaceRoot);
+      const result = await exporter.export(outputPath, {
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] LSIF export complete: ${result.documents} documents, ${result.definitions} definitions, ` +
+        `${result.references} references (${result.unresolvedReferences} unresolved) in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] LSIF export cancelled by user');
+      throw new ResponseError(-32800, 'LSIF export cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error exporting LSIF: ${error}`);
+    throw error;
+  }
+});
+
+// Patch the index with the changes git reports since a commit, without a workspace scan
+connection.onRequest('smart-indexer/updateSince', async (options: { since?: string; root?: string } | undefined) => {
+  try {
+    if (!options?.since) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Missing commit to update since');
+    }
+    serverLogger.info(`[Server] ========== UPDATE SINCE ${options.since} REQUEST ==========`);
+    const result = await serverInitializer.updateSince(options.since, options.root ?? serverState.workspaceRoot);
+    serverLogger.info(
+      `[Server] Updated ${result.root} since ${result.since}: ${result.indexed} files indexed, ` +
+      `${result.removed} removed in ${result.duration}ms`
+    );
+    return result;
+  } catch (error) {
+    if (error instanceof CancellationError) {
+      throw new ResponseError(-32800, 'Update cancelled');
+    }
+    serverLogger.error(`[Server] Error updating index from git: ${error}`);
+    throw error;
+  }
+});
+
+// Upload the index to, or seed it from, remote storage (smartIndexer.remoteIndex.url)
+function remoteIndexUrl(options: { url?: string } | undefined): string {
+  const url = options?.url || configManager.getRemoteIndexConfig().url;
+  if (!url) {
+    throw new ResponseError(ErrorCodes.InvalidParams, 'No remote index URL: set smartIndexer.remoteIndex.url');
+  }
+  return url;
 }
 
-// ============================================================================
-// Server Lifecycle
-// ============================================================================
+connection.onRequest('smart-indexer/pushIndex', async (options: { url?: string } | undefined, token: CancellationToken) => {
+  try {
+    const url = remoteIndexUrl(options);
+    serverLogger.info(`[Server] ========== PUSH INDEX REQUEST: ${url} ==========`);
 
-documents.listen(connection);
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Pushing index', 0, 'Encoding index...', true);
+    const start = Date.now();
 
-connection.onShutdown(async () => {
+    try {
+      const result = await new RemoteIndexSync(backgroundIndex).push(url, serverInitializer.getWorkspaceRoots(), {
+        cancellationToken: token,
+        keys: indexKeysFor(configManager.getEncryptionConfig(), { cwd: serverState.workspaceRoot || undefined }),
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      const duration = Date.now() - start;
+      serverLogger.info(
+        `[Server] Pushed ${result.files} files (${result.compressedBytes} bytes compressed) to ${url} in ${duration}ms`
+      );
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Index push cancelled by user');
+      throw new ResponseError(-32800, 'Index push cancelled');
+    }
+
+    serverLogger.error(`[Server] Error pushing index: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/pullIndex', async (options: { url?: string } | undefined, token: CancellationToken) => {
   try {
-    connection.console.info('[Server] Shutting down, closing resources...');
-    
-    // Dispose document event handler
-    if (documentEventHandler) {
-      documentEventHandler.dispose();
+    const url = remoteIndexUrl(options);
+    serverLogger.info(`[Server] ========== PULL INDEX REQUEST: ${url} ==========`);
+
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Pulling index', 0, 'Downloading index...', true);
+    const start = Date.now();
+
+    try {
+      const result = await new RemoteIndexSync(backgroundIndex).pull(url, serverInitializer.getWorkspaceRoots(), {
+        cancellationToken: token,
+        keys: indexKeysFor(configManager.getEncryptionConfig(), { cwd: serverState.workspaceRoot || undefined }),
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      const duration = Date.now() - start;
+      serverLogger.info(
+        `[Server] Pulled ${result.imported} of ${result.files} files from ${url} in ${duration}ms ` +
+        `(${result.changed} changed locally, ${result.missing} missing, ${result.outside} outside the workspace)`
+      );
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Index pull cancelled by user');
+      throw new ResponseError(-32800, 'Index pull cancelled');
     }
+
+    serverLogger.error(`[Server] Error pulling index: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/exportTags', async (options: {
+  outputPath?: string;
+  format?: TagsFormat;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT TAGS REQUEST ==========');
     
-    // Dispose file watcher
-    const fileWatcher = serverState.fileWatcher;
-    if (fileWatcher) {
-      await fileWatcher.dispose();
+    const format = options?.format ?? 'ctags';
+    if (format !== 'ctags' && format !== 'etags') {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown tags format: ${format}`);
     }
     
+    // Editors look for tags/TAGS in the workspace root
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, format === 'etags' ? 'TAGS' : 'tags');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin(`Exporting ${format}`, 0, 'Preparing export...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new TagsExporter(backgroundIndex);
+      const result = await exporter.export(outputPath, {
+        format,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Tags export complete: ${result.tags} tags from ${result.files} files in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Tags export cancelled by user');
+      throw new ResponseError(-32800, 'Tags export cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error exporting tags: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/exportJsonLines', async (options: {
+  outputPath?: string;
+  records?: JsonLinesRecordType[];
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT JSON LINES REQUEST ==========');
+    
+    const unknown = (options?.records ?? []).filter(type => !JSON_LINES_RECORD_TYPES.includes(type));
+    if (unknown.length > 0) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown record types: ${unknown.join(', ')}`);
+    }
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', 'index.jsonl');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting JSON Lines', 0, 'Preparing export...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new JsonLinesExporter(backgroundIndex, workspaceRoot);
+      const result = await exporter.export(outputPath, {
+        records: options?.records,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      const lines = Object.values(result.counts).reduce((sum, count) => sum + count, 0);
+      
+      serverLogger.info(
+        `[Server] JSON Lines export complete: ${lines} records from ${result.files} files in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, records: lines, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] JSON Lines export cancelled by user');
+      throw new ResponseError(-32800, 'JSON Lines export cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error exporting JSON Lines: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/benchmark', async (options: {
+  iterations?: number;
+  warmup?: number;
+  cpuProfile?: boolean;
+  heapProfile?: boolean;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== BENCHMARK REQUEST ==========');
+    
+    const iterations = options?.iterations ?? 5;
+    const warmup = options?.warmup ?? 1;
+    if (!Number.isInteger(iterations) || iterations < 1 || !Number.isInteger(warmup) || warmup < 0) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Invalid benchmark: ${iterations} iterations after ${warmup} warm-up runs`);
+    }
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const config = configManager.getConfig();
+    const profileDir = path.join(workspaceRoot, config.cacheDirectory, 'profiles');
+    const cpuProfile = options?.cpuProfile ? path.join(profileDir, 'bench.cpuprofile') : undefined;
+    const heapProfile = options?.heapProfile ? path.join(profileDir, 'bench.heapprofile') : undefined;
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Benchmarking Indexing', 0, `Indexing the workspace ${warmup + iterations} times...`, true);
+    
+    try {
+      // A library indexer of its own, so the benchmark leaves the live index alone
+      const indexer = new Indexer({
+        excludePatterns: config.excludePatterns,
+        includePatterns: config.includePatterns,
+        languages: config.languages,
+        maxFileSizeMB: config.maxFileSizeMB,
+        textIndexing: config.textIndexingEnabled
+      });
+      let run = 0;
+      const result = await indexer.bench(workspaceRoot, {
+        iterations,
+        warmup,
+        cpuProfile,
+        heapProfile,
+        cancellationToken: token,
+        onIndexingProgress: snapshot => {
+          if (snapshot.phase === 'idle') {
+            run++;
+            progress.report(Math.round((run / (warmup + iterations)) * 100), `Run ${run}/${warmup + iterations}`);
+          }
+        }
+      });
+      
+      serverLogger.info(`[Server] Benchmark complete:\n${formatBenchmark(result)}`);
+      
+      return { ...result, summary: formatBenchmark(result), cpuProfile, heapProfile };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Benchmark cancelled by user');
+      throw new ResponseError(-32800, 'Benchmark cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error running benchmark: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/exportGraph', async (options: {
+  outputPath?: string;
+  format?: GraphExportFormat;
+  relationships?: GraphRelationshipType[];
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT GRAPH REQUEST ==========');
+    
+    const format = options?.format ?? 'cypher';
+    if (!GRAPH_EXPORT_FORMATS.includes(format)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unsupported graph format: ${format}`);
+    }
+    const unknown = (options?.relationships ?? []).filter(type => !GRAPH_RELATIONSHIP_TYPES.includes(type));
+    if (unknown.length > 0) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown relationship types: ${unknown.join(', ')}`);
+    }
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', format === 'csv' ? 'graph-csv' : 'graph.cypher');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting Symbol Graph', 0, 'Preparing export...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new GraphExporter(backgroundIndex, workspaceRoot);
+      const result = await exporter.export(outputPath, {
+        format,
+        relationships: options?.relationships,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      const nodes = Object.values(result.nodes).reduce((sum, count) => sum + count, 0);
+      const relationships = Object.values(result.relationships).reduce((sum, count) => sum + count, 0);
+      
+      serverLogger.info(
+        `[Server] Graph export complete: ${nodes} nodes, ${relationships} relationships ` +
+        `(${result.unresolvedReferences} unresolved references) in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, totalNodes: nodes, totalRelationships: relationships, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Graph export cancelled by user');
+      throw new ResponseError(-32800, 'Graph export cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error exporting graph: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/exportHeatmap', async (options: {
+  outputPath?: string;
+  format?: HeatmapFormat;
+  days?: number;
+  depth?: number;
+  includeTests?: boolean;
+  scopePath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT HEATMAP REQUEST ==========');
+    
+    const format = options?.format ?? 'csv';
+    if (!HEATMAP_FORMATS.includes(format)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown heatmap format: ${format}`);
+    }
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', `heatmap.${format}`);
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting Heatmap', 0, 'Collecting metrics...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new HeatmapExporter(backgroundIndex, workspaceRoot);
+      const result = await exporter.export(outputPath, format, {
+        days: options?.days,
+        depth: options?.depth,
+        scopePath: options?.scopePath,
+        tags: { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] },
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Heatmap export complete: ${result.entries} directories from ${result.filesScanned} files in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Heatmap export cancelled by user');
+      throw new ResponseError(-32800, 'Heatmap export cancelled');
+    }
+    if (error instanceof Error && /^Invalid (number of days|depth)/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error exporting heatmap: ${error}`);
+    throw error;
+  }
+});
+
+// Compression of written .sidx files: the request's, else smartIndexer.staticIndex.compression
+function binaryIndexCompression(options: { compression?: BinaryIndexCompression } | undefined): BinaryIndexCompression {
+  const compression = options?.compression ?? configManager.getConfig().staticIndexCompression;
+  if (!BINARY_INDEX_COMPRESSIONS.includes(compression)) {
+    throw new ResponseError(ErrorCodes.InvalidParams, `Unknown compression: ${compression}`);
+  }
+  if (!isCompressionSupported(compression)) {
+    throw new ResponseError(ErrorCodes.InvalidParams, `${compression} compression needs a newer Node.js`);
+  }
+  return compression;
+}
+
+connection.onRequest('smart-indexer/exportBinaryIndex', async (options: {
+  outputPath?: string;
+  compression?: BinaryIndexCompression;
+  reproducible?: boolean;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== EXPORT BINARY INDEX REQUEST ==========');
+    
+    const compression = binaryIndexCompression(options);
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', 'index.sidx');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Exporting Binary Index', 0, 'Preparing export...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const exporter = new BinaryIndexExporter(backgroundIndex);
+      const result = await exporter.export(outputPath, {
+        compression,
+        reproducible: options?.reproducible,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Binary index export complete: ${result.symbols} symbols from ${result.files} files (${result.bytes} bytes) in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Binary index export cancelled by user');
+      throw new ResponseError(-32800, 'Binary index export cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error exporting binary index: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/buildShards', async (options: {
+  outputDir?: string;
+  by?: ShardStrategy;
+  depth?: number;
+  include?: string[];
+  compression?: BinaryIndexCompression;
+  reproducible?: boolean;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== BUILD SHARDS REQUEST ==========');
+    
+    if (options?.by && !SHARD_STRATEGIES.includes(options.by)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, `Unknown shard strategy: ${options.by}`);
+    }
+    if (options?.depth !== undefined && (!Number.isInteger(options.depth) || options.depth < 1)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'depth must be a positive integer');
+    }
+    const compression = binaryIndexCompression(options);
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    const outputDir = options?.outputDir
+      ? path.resolve(workspaceRoot, options.outputDir)
+      : path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export', 'shards');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Building Index Shards', 0, 'Grouping files...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const builder = new IndexShardBuilder(backgroundIndex, workspaceRoot);
+      const result = await builder.build(outputDir, {
+        by: options?.by,
+        depth: options?.depth,
+        include: options?.include,
+        compression,
+        reproducible: options?.reproducible,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Shard build complete: ${result.shards.length} shards in ${duration}ms -> ${result.outputDir}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Shard build cancelled by user');
+      throw new ResponseError(-32800, 'Shard build cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building shards: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/mergeShards', async (options: {
+  inputs?: string[];
+  outputPath?: string;
+  compression?: BinaryIndexCompression;
+  reproducible?: boolean;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== MERGE SHARDS REQUEST ==========');
+    
+    const compression = binaryIndexCompression(options);
+    const workspaceRoot = serverState.workspaceRoot;
+    const cacheExportDir = path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'export');
+    const inputs = options?.inputs?.length
+      ? options.inputs.map(input => path.resolve(workspaceRoot, input))
+      : [path.join(cacheExportDir, 'shards')];
+    const outputPath = options?.outputPath
+      ? path.resolve(workspaceRoot, options.outputPath)
+      : path.join(cacheExportDir, 'index.sidx');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Merging Index Shards', 0, 'Reading shards...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const result = await mergeShards(inputs, outputPath, {
+        compression,
+        reproducible: options?.reproducible,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Shard merge complete: ${result.shards} shards, ${result.files} files, ${result.symbols} symbols in ${duration}ms -> ${result.outputPath}`
+      );
+      
+      return { ...result, duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Shard merge cancelled by user');
+      throw new ResponseError(-32800, 'Shard merge cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error merging shards: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * The call graph for the query server (and its web UI), rebuilt when a
+ * new index generation is published rather than on every request.
+ */
+let servedCallGraph: { generation: number; graph: CallGraph } | null = null;
+
+async function queryCallGraph(query: CallGraphQuery): Promise<CallGraphReport> {
+  const generation = backgroundIndex.getGeneration();
+  if (!servedCallGraph || servedCallGraph.generation !== generation) {
+    const graph = new CallGraph(backgroundIndex);
+    await graph.build({ cancellationToken: query.cancellationToken });
+    servedCallGraph = { generation, graph };
+  }
+  return servedCallGraph.graph.query(query);
+}
+
+connection.onRequest('smart-indexer/callGraph', async (options: {
+  uri?: string;
+  line?: number;
+  character?: number;
+  symbolName?: string;
+  direction?: CallGraphDirection;
+  depth?: number;
+  format?: 'dot' | 'json';
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== CALL GRAPH REQUEST ==========');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Building Call Graph', 0, 'Collecting functions...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const graph = new CallGraph(backgroundIndex);
+      const stats = await graph.build({
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      // Root the graph at the function under the cursor or the named symbol; otherwise export everything
+      let roots: string[] | undefined;
+      if (options?.uri && options.line !== undefined) {
+        const filePath = URI.parse(options.uri).fsPath;
+        const node = graph.findNodeAt(filePath, options.line, options.character ?? 0);
+        if (!node) {
+          throw new ResponseError(ErrorCodes.InvalidParams, 'No function or method at the given position');
+        }
+        roots = [node.id];
+      } else if (options?.symbolName) {
+        roots = graph.findNodesByName(options.symbolName).map(n => n.id);
+        if (roots.length === 0) {
+          throw new ResponseError(ErrorCodes.InvalidParams, `No function or method named '${options.symbolName}'`);
+        }
+      }
+      
+      const view = graph.view(roots, options?.direction ?? 'both', options?.depth ?? Infinity);
+      const format = options?.format ?? 'dot';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Call graph built: ${stats.functions} functions, ${stats.edges} edges ` +
+        `(${stats.unresolvedCalls} unresolved calls), view ${view.nodes.length} nodes in ${duration}ms`
+      );
+      
+      return {
+        format,
+        content: format === 'json' ? graph.toJson(view) : graph.toDot(view),
+        nodes: view.nodes.length,
+        edges: view.edges.length,
+        ...stats,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Call graph cancelled by user');
+      throw new ResponseError(-32800, 'Call graph cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building call graph: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/dependencyGraph', async (options: {
+  format?: 'dot' | 'json';
+  includeExternal?: boolean;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== DEPENDENCY GRAPH REQUEST ==========');
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    if (!workspaceRoot) {
+      throw new Error('No workspace root available');
+    }
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Building Dependency Graph', 0, 'Collecting packages...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const graph = new DependencyGraph(backgroundIndex, workspaceRoot);
+      const result = await graph.build({
+        includeExternal: options?.includeExternal,
+        rules: configManager.getConfig().dependencyRules,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      const format = options?.format ?? 'dot';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Dependency graph built: ${result.packages.length} packages, ${result.edges.length} edges, ` +
+        `${result.cycles.length} cycles, ${result.violations.length} rule violations in ${duration}ms`
+      );
+      
+      return {
+        format,
+        content: format === 'json' ? graph.toJson(result) : graph.toDot(result),
+        packages: result.packages.length,
+        edges: result.edges.length,
+        cycles: result.cycles,
+        violations: result.violations,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Dependency graph cancelled by user');
+      throw new ResponseError(-32800, 'Dependency graph cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building dependency graph: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Blame-backed symbol history, created on first use (keeps its blame cache
+ * across requests).
+ */
+let symbolHistory: SymbolHistoryIndex | null = null;
+
+function getSymbolHistory(): SymbolHistoryIndex {
+  if (!configManager.getConfig().enableGitIntegration) {
+    throw new ResponseError(ErrorCodes.InvalidRequest, 'Git integration is disabled (smartIndexer.enableGitIntegration)');
+  }
+  if (!serverState.workspaceRoot) {
+    throw new Error('No workspace root available');
+  }
+  if (!symbolHistory) {
+    symbolHistory = new SymbolHistoryIndex(backgroundIndex, serverState.workspaceRoot);
+  }
+  return symbolHistory;
+}
+
+function toHistoryJson({ symbol, history }: SymbolWithHistory) {
+  return {
+    name: symbol.name,
+    kind: symbol.kind,
+    containerName: symbol.containerName,
+    location: symbol.location,
+    ...history
+  };
+}
+
+connection.onRequest('smart-indexer/symbolHistory', async (options: {
+  name: string;
+}) => {
+  try {
+    serverLogger.info(`[Server] ========== SYMBOL HISTORY REQUEST: ${options?.name} ==========`);
+    
+    if (!options?.name) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Symbol name is required');
+    }
+    
+    const start = Date.now();
+    const owners = await getSymbolHistory().findOwners(options.name);
+    
+    serverLogger.info(`[Server] History of ${owners.length} definition(s) named ${options.name} in ${Date.now() - start}ms`);
+    
+    return { symbols: owners.map(toHistoryJson) };
+  } catch (error) {
+    serverLogger.error(`[Server] Error reading symbol history: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/changedSymbols', async (options: {
+  days?: number;
+  kinds?: string[];
+  limit?: number;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== CHANGED SYMBOLS REQUEST ==========');
+    
+    const days = options?.days ?? 30;
+    if (typeof days !== 'number' || !(days > 0)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'days must be a positive number');
+    }
+    
+    const history = getSymbolHistory();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding Changed Symbols', 0, 'Reading git log...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const changed = await history.findChangedSince(days, {
+        kinds: options?.kinds,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      serverLogger.info(`[Server] ${changed.length} symbols changed in the last ${days} days (${duration}ms)`);
+      
+      return {
+        symbols: changed.slice(0, options?.limit ?? changed.length).map(toHistoryJson),
+        total: changed.length,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Changed symbols search cancelled by user');
+      throw new ResponseError(-32800, 'Changed symbols search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding changed symbols: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Comment markers matching a query, with blame when git integration is on;
+ * serves both the LSP request and the query server's /markers.
+ */
+async function queryCommentMarkers(query: CommentMarkerQuery, onProgress?: ProgressCallback): Promise<CommentMarkerReport> {
+  commentMarkerIndex.setConfig(configManager.getCommentMarkersConfig());
+  await commentMarkerIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+
+  let blame: MarkerBlame | undefined;
+  if (configManager.getConfig().enableGitIntegration && serverState.workspaceRoot) {
+    const history = getSymbolHistory();
+    const head = await history.getHead();
+    blame = filePath => history.getFileBlame(filePath, head);
+  }
+  return commentMarkerIndex.query(query, blame);
+}
+
+connection.onRequest('smart-indexer/commentMarkers', async (options: CommentMarkerQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== COMMENT MARKERS REQUEST ==========');
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting comment markers', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await queryCommentMarkers({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.total} comment markers (${report.blamed ? 'blamed' : 'no blame'}) in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof MarkerQueryError) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Comment marker search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting comment markers: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * HTTP routes matching a query, when route extraction is turned on;
+ * serves both the LSP request and the query server's /routes.
+ */
+async function queryHttpRoutes(query: HttpRouteQuery, onProgress?: ProgressCallback): Promise<HttpRouteReport> {
+  const config = configManager.getHttpRoutesConfig();
+  if (!config.enabled) {
+    throw new RouteQueryError('HTTP route extraction is disabled (smartIndexer.httpRoutes.enabled)');
+  }
+  httpRouteIndex.setFrameworks(config.frameworks);
+  await httpRouteIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return httpRouteIndex.query(query);
+}
+
+connection.onRequest('smart-indexer/httpRoutes', async (options: HttpRouteQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== HTTP ROUTES REQUEST ==========');
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting HTTP routes', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await queryHttpRoutes({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(`[Server] ${report.total} HTTP routes in ${Date.now() - start}ms`);
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof RouteQueryError) {
+      throw new ResponseError(ErrorCodes.InvalidRequest, error.message);
+    }
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'HTTP route search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting HTTP routes: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * SQL statements matching a filter and the functions running them;
+ * serves both the LSP request and the query server's /sql.
+ */
+async function querySql(filter: SqlQueryFilter, onProgress?: ProgressCallback): Promise<SqlQueryReport> {
+  await sqlQueryIndex.build({ cancellationToken: filter.cancellationToken, onProgress });
+  return sqlQueryIndex.query(filter);
+}
+
+connection.onRequest('smart-indexer/sqlQueries', async (options: SqlQueryFilter | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== SQL QUERIES REQUEST: ${options?.table ?? options?.column ?? '*'} ==========`);
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting SQL queries', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await querySql({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.total} SQL queries in ${report.functions.length} functions in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'SQL query search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting SQL queries: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Environment variables, viper keys and flags matching a query;
+ * serves both the LSP request and the query server's /env.
+ */
+async function queryConfigKeys(query: ConfigKeyQuery, onProgress?: ProgressCallback): Promise<ConfigKeyReport> {
+  await configKeyIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return configKeyIndex.query(query);
+}
+
+connection.onRequest('smart-indexer/configKeys', async (options: ConfigKeyQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== CONFIG KEYS REQUEST ==========');
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting configuration keys', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await queryConfigKeys({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(`[Server] ${report.total} configuration keys in ${Date.now() - start}ms`);
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Configuration key search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting configuration keys: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Goroutine launches, channel operations and mutex calls matching a query;
+ * serves both the LSP request and the query server's /concurrency.
+ */
+async function queryConcurrency(query: ConcurrencyQuery, onProgress?: ProgressCallback): Promise<ConcurrencyReport> {
+  await concurrencyIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return concurrencyIndex.query(query);
+}
+
+connection.onRequest('smart-indexer/concurrency', async (options: ConcurrencyQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== CONCURRENCY REQUEST: ${options?.op ?? '*'} in ${options?.package ?? '*'} ==========`);
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting goroutines and channels', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await queryConcurrency({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.total} concurrency events in ${report.functions.length} functions in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Concurrency analysis cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting concurrency events: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Panics, recovers, propagated and dropped errors matching a query;
+ * serves both the LSP request and the query server's /errors.
+ */
+async function queryErrorPaths(query: ErrorPathQuery, onProgress?: ProgressCallback): Promise<ErrorPathReport> {
+  await errorPathIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return errorPathIndex.query(query);
+}
+
+connection.onRequest('smart-indexer/errorPaths', async (options: ErrorPathQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== ERROR PATHS REQUEST: ${options?.kind ?? '*'} in ${options?.package ?? '*'} ==========`);
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Collecting error paths', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await queryErrorPaths({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.total} error path events in ${report.functions.length} functions in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Error path search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error collecting error paths: ${error}`);
+    throw error;
+  }
+});
+
+function coverageProfiles(): string[] {
+  return configManager.getCoverageConfig().profiles.map(profile => path.resolve(serverState.workspaceRoot, profile));
+}
+
+async function queryCoverage(query: CoverageQuery): Promise<CoverageReport> {
+  coverageIndex.setProfiles(coverageProfiles());
+  return coverageIndex.query(query);
+}
+
+connection.onRequest('smart-indexer/coverage', async (options: CoverageQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(
+      `[Server] ========== COVERAGE REQUEST: at most ${options?.maxCoverage ?? 100}% covered, ` +
+      `${options?.minReferences ?? 0}+ references ==========`
+    );
+    
+    const start = Date.now();
+    const report = await queryCoverage({ ...options, cancellationToken: token });
+    
+    const missing = report.profiles.filter(profile => !profile.loaded);
+    for (const profile of missing) {
+      serverLogger.warn(`[Server] Coverage profile ${profile.path} not loaded: ${profile.error}`);
+    }
+    serverLogger.info(
+      `[Server] ${report.total} functions from ${report.profiles.length - missing.length} coverage profiles ` +
+      `(${report.files} files, ${report.unmatched.length} unmatched) in ${Date.now() - start}ms`
+    );
+    return { ...report, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Coverage lookup cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error reading coverage: ${error}`);
+    throw error;
+  }
+});
+
+async function queryTestsFor(query: TestsForQuery, onProgress?: ProgressCallback): Promise<TestsForReport> {
+  await goTestIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return goTestIndex.testsFor({ root: serverState.workspaceRoot || undefined, ...query });
+}
+
+connection.onRequest('smart-indexer/testsFor', async (options: TestsForQuery, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== TESTS FOR REQUEST: ${options?.symbol} ==========`);
+    
+    if (!options?.symbol?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'A symbol is required');
+    }
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding tests', 0, 'Linking tests...', true);
+    
+    try {
+      const report = await queryTestsFor({ ...options, symbol: options.symbol.trim(), cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.tests.length} tests in ${report.packages.length} packages exercise ` +
+        `${report.definitions.length} definitions of ${report.symbol} in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Test search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding tests: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Go tests affected by the changes since a commit; serves both the LSP
+ * request and the query server's /affected-tests.
+ */
+async function queryAffectedTests(query: AffectedTestsQuery, onProgress?: ProgressCallback): Promise<AffectedTestsReport> {
+  const workspaceRoot = serverState.workspaceRoot;
+  if (!workspaceRoot) {
+    throw new Error('No workspace root available');
+  }
+  await goTestIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return new AffectedTests(goTestIndex, backgroundIndex, workspaceRoot).find(query);
+}
+
+connection.onRequest('smart-indexer/affectedTests', async (options: AffectedTestsQuery, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== AFFECTED TESTS REQUEST: ${options?.since} ==========`);
+    
+    if (!options?.since?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'A base revision is required');
+    }
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding affected tests', 0, 'Linking tests...', true);
+    
+    try {
+      const report = await queryAffectedTests({ ...options, since: options.since.trim(), cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.changed.length} symbols changed in ${report.files} files since ${options.since}: ` +
+        `${report.all ? 'all packages' : `${report.tests.length} tests in ${report.packages.length} packages`} in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Affected test search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding affected tests: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Sections of the Markdown and AsciiDoc files matching a query; serves
+ * both the LSP request and the query server's /docs.
+ */
+async function queryDocs(query: DocSearchQuery, onProgress?: ProgressCallback): Promise<DocSearchReport> {
+  await docsIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return docsIndex.search(query);
+}
+
+/**
+ * Doc mentions of a symbol; serves both the LSP request and the query
+ * server's /docs/mentions.
+ */
+async function queryDocMentions(query: DocMentionQuery, onProgress?: ProgressCallback): Promise<DocMentionReport> {
+  await docsIndex.build({ cancellationToken: query.cancellationToken, onProgress });
+  return docsIndex.mentions(query);
+}
+
+connection.onRequest('smart-indexer/docs', async (options: DocSearchQuery | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== DOC SEARCH REQUEST: ${options?.text ?? ''} ==========`);
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Searching docs', 0, 'Reading docs...', true);
+    
+    try {
+      const report = await queryDocs({ ...options, cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(`[Server] ${report.total} doc sections in ${Date.now() - start}ms`);
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Doc search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error searching docs: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/docMentions', async (options: DocMentionQuery, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== DOC MENTIONS REQUEST: ${options?.symbol} ==========`);
+    
+    if (!options?.symbol?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'A symbol is required');
+    }
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding doc mentions', 0, 'Reading docs...', true);
+    
+    try {
+      const report = await queryDocMentions({ ...options, symbol: options.symbol.trim(), cancellationToken: token }, (current, total, message) => {
+        progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.total} doc mentions of ${report.definitions.length} definitions of ${report.symbol} in ${Date.now() - start}ms`
+      );
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Doc mention search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding doc mentions: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/indexDiff', async (options: {
+  base: string;
+  head?: string;
+  format?: 'markdown' | 'json';
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== INDEX DIFF REQUEST: ${options?.base}..${options?.head ?? 'HEAD'} ==========`);
+    
+    if (!options?.base) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Base revision is required');
+    }
+    const workspaceRoot = serverState.workspaceRoot;
+    if (!workspaceRoot) {
+      throw new Error('No workspace root available');
+    }
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Comparing Revisions', 0, 'Resolving revisions...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const cacheDir = path.join(workspaceRoot, configManager.getConfig().cacheDirectory);
+      const indexDiff = new IndexDiff(languageRouter, workspaceRoot, cacheDir);
+      const result = await indexDiff.diff(options.base, options.head ?? 'HEAD', {
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const format = options.format ?? 'markdown';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Index diff ${options.base}..${options.head ?? 'HEAD'}: ${result.added.length} added, ` +
+        `${result.removed.length} removed, ${result.changed.length} changed in ${duration}ms`
+      );
+      
+      return {
+        format,
+        content: format === 'json' ? JSON.stringify(result, null, 2) : indexDiff.toMarkdown(result),
+        added: result.added.length,
+        removed: result.removed.length,
+        changed: result.changed.length,
+        reviewers: result.reviewers,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Index diff cancelled by user');
+      throw new ResponseError(-32800, 'Index diff cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error diffing revisions: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/semverCheck', async (options: {
+  range: string;
+  release?: string;
+  format?: 'markdown' | 'json';
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== SEMVER CHECK REQUEST: ${options?.range} ==========`);
+    
+    if (!options?.range) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Revision range is required');
+    }
+    const workspaceRoot = serverState.workspaceRoot;
+    if (!workspaceRoot) {
+      throw new Error('No workspace root available');
+    }
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Checking Semantic Version', 0, 'Resolving revisions...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const cacheDir = path.join(workspaceRoot, configManager.getConfig().cacheDirectory);
+      const semverCheck = new SemverCheck(new IndexDiff(languageRouter, workspaceRoot, cacheDir));
+      const report = await semverCheck.check(options.range, {
+        release: options.release,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const format = options.format ?? 'markdown';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Semver check ${options.range}: ${report.level} changes, release allows ${report.allowed}, ` +
+        `${report.compatible ? 'compatible' : 'incompatible'} in ${duration}ms`
+      );
+      
+      return {
+        format,
+        content: format === 'json' ? JSON.stringify(report, null, 2) : semverCheck.toMarkdown(report),
+        level: report.level,
+        allowed: report.allowed,
+        compatible: report.compatible,
+        suggestedVersion: report.suggestedVersion,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Semver check cancelled by user');
+      throw new ResponseError(-32800, 'Semver check cancelled');
+    }
+    if (error instanceof Error && /^(Invalid (revision range|release version)|Release version )/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error checking semantic version: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/checkPolicies', async (options: {
+  since?: string;
+  policies?: string[];
+  format?: 'text' | 'github' | 'json' | 'sarif';
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== POLICY CHECK REQUEST${options?.since ? `: since ${options.since}` : ''} ==========`);
+    
+    const workspaceRoot = serverState.workspaceRoot;
+    if (!workspaceRoot) {
+      throw new Error('No workspace root available');
+    }
+    const policies = configManager.getConfig().policies ?? [];
+    if (policies.length === 0) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'No policies configured; add them to smartIndexer.policies or the config file');
+    }
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Checking Policies', 0, 'Reading files...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const policyCheck = new PolicyCheck(backgroundIndex, workspaceRoot, (uri, content) => languageRouter.indexFile(uri, content));
+      const report = await policyCheck.check(policies, {
+        since: options?.since || undefined,
+        policies: options?.policies,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const format = options?.format ?? 'text';
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Policy check: ${report.errors} errors, ${report.warnings} warnings in ${report.filesChecked} files in ${duration}ms`
+      );
+      
+      return {
+        format,
+        content: format === 'json' ? JSON.stringify(report, null, 2)
+          : format === 'sarif' ? JSON.stringify(toSarif([policyFindings(report)], workspaceRoot), null, 2)
+          : formatPolicyReport(report, format),
+        errors: report.errors,
+        warnings: report.warnings,
+        passed: report.passed,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Policy check cancelled by user');
+      throw new ResponseError(-32800, 'Policy check cancelled');
+    }
+    if (error instanceof Error && /^(Invalid policy|Unknown policy|Not a git repository)/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error checking policies: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/goModules', async () => {
+  return new ApiSurface(backgroundIndex).modules();
+});
+
+connection.onRequest('smart-indexer/apiSurface', async (options: {
+  module: string;
+  includeInternal?: boolean;
+  format?: 'text' | 'json';
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== API SURFACE REQUEST: ${options?.module} ==========`);
+    
+    if (!options?.module) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Module is required');
+    }
+    
+    const start = Date.now();
+    const report = await new ApiSurface(backgroundIndex).build(options.module, {
+      includeInternal: options.includeInternal,
+      cancellationToken: token
+    });
+    const format = options.format ?? 'text';
+    const duration = Date.now() - start;
+    
+    serverLogger.info(
+      `[Server] API surface of ${report.module}: ${report.features.length} features in ${report.packages.length} packages in ${duration}ms`
+    );
+    
+    return {
+      format,
+      content: format === 'json' ? JSON.stringify(report, null, 2) : formatApiSurface(report),
+      module: report.module,
+      version: report.version,
+      packages: report.packages.length,
+      features: report.features.length,
+      duration
+    };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] API surface report cancelled by user');
+      throw new ResponseError(-32800, 'API surface report cancelled');
+    }
+    if (error instanceof Error && /^(Unknown module|Module .* several versions)/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error building API surface: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Embedding index, created on first use and recreated when the embeddings
+ * settings change (a different model or provider invalidates the vectors).
+ */
+let embeddingIndex: { key: string; index: EmbeddingIndex; provider: EmbeddingProvider } | null = null;
+
+function getEmbeddingIndex(): EmbeddingIndex {
+  const config = configManager.getEmbeddingsConfig();
+  if (!config.enabled) {
+    throw new ResponseError(ErrorCodes.InvalidRequest, 'Embeddings are disabled (smartIndexer.embeddings.enabled)');
+  }
+  const workspaceRoot = serverState.workspaceRoot;
+  if (!workspaceRoot) {
+    throw new Error('No workspace root available');
+  }
+
+  const key = JSON.stringify(config);
+  if (embeddingIndex?.key !== key) {
+    embeddingIndex?.provider.dispose?.();
+    const provider = createEmbeddingProvider(config, logger, workspaceRoot);
+    const cacheDir = path.join(workspaceRoot, configManager.getConfig().cacheDirectory);
+    const { enabled: annEnabled, ...hnsw } = config.hnsw;
+    const index = new EmbeddingIndex(backgroundIndex, provider, workspaceRoot, cacheDir, {
+      batchSize: config.batchSize,
+      maxChunkChars: config.maxChunkChars,
+      hnsw: annEnabled ? hnsw : undefined
+    });
+    embeddingIndex = { key, index, provider };
+  }
+  return embeddingIndex.index;
+}
+
+connection.onRequest('smart-indexer/ask', async (options: {
+  query: string;
+  limit?: number;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== ASK REQUEST: ${options?.query} ==========`);
+    
+    if (!options?.query?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Query is required');
+    }
+    
+    const index = getEmbeddingIndex();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Natural-Language Search', 0, 'Updating embeddings...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const stats = await index.update({
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      
+      progress.report(100, 'Searching...');
+      const results = await index.search(options.query, options.limit ?? 20);
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Ask: ${results.length} results over ${stats.chunks} chunks ` +
+        `(${stats.embedded} newly embedded) in ${duration}ms`
+      );
+      
+      return {
+        results: results.map(({ chunk, score }) => ({
+          name: chunk.name,
+          kind: chunk.kind,
+          containerName: chunk.containerName,
+          location: { uri: chunk.uri, line: chunk.line, character: 0 },
+          endLine: chunk.endLine,
+          score,
+          summary: summaryLookup.summaryOf(chunk.id)
+        })),
+        chunks: stats.chunks,
+        embedded: stats.embedded,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Ask cancelled by user');
+      throw new ResponseError(-32800, 'Ask cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error answering natural-language query: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Symbol summaries, created on first use and recreated when the summaries
+ * settings change (summaries of another model are regenerated).
+ */
+let symbolSummaries: { key: string; summaries: SymbolSummaries; provider: SummaryProvider } | null = null;
+/** The running summary pass; passes do not overlap so no symbol is paid for twice */
+let summaryPass: Promise<unknown> = Promise.resolve();
+
+/** Undefined while summaries are disabled or no workspace is open */
+function getSymbolSummaries(): SymbolSummaries | undefined {
+  const config = configManager.getSummariesConfig();
+  const workspaceRoot = serverState.workspaceRoot;
+  if (!config.enabled || !workspaceRoot) {
+    return undefined;
+  }
+
+  const key = JSON.stringify(config);
+  if (symbolSummaries?.key !== key) {
+    symbolSummaries?.provider.dispose?.();
+    const provider = createSummaryProvider(config, logger, workspaceRoot);
+    const cacheDir = path.join(workspaceRoot, configManager.getConfig().cacheDirectory);
+    const summaries = new SymbolSummaries(backgroundIndex, provider, workspaceRoot, cacheDir, {
+      minComplexity: config.minComplexity,
+      minLines: config.minLines,
+      maxSourceChars: config.maxSourceChars,
+      concurrency: config.concurrency
+    });
+    symbolSummaries = { key, summaries, provider };
+  }
+  return symbolSummaries.summaries;
+}
+
+function runSummaryPass(summaries: SymbolSummaries, options: SummaryUpdateOptions = {}): Promise<SymbolSummaryStats> {
+  const pass = summaryPass.catch(() => undefined).then(() => summaries.update(options));
+  summaryPass = pass;
+  // Cached query results were rendered without the new summaries
+  return pass.finally(() => queryServer.setSummaries(summaryLookup));
+}
+
+connection.onRequest('smart-indexer/summarizeSymbols', async (options: SummaryListOptions | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== SUMMARIZE SYMBOLS REQUEST ==========');
+
+    const summaries = getSymbolSummaries();
+    if (!summaries) {
+      throw new ResponseError(ErrorCodes.InvalidRequest, 'Symbol summaries are disabled (smartIndexer.summaries.enabled)');
+    }
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Symbol Summaries', 0, 'Finding complex symbols...', true);
+    const start = Date.now();
+
+    try {
+      const stats = await runSummaryPass(summaries, {
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total}`);
+        }
+      });
+      const duration = Date.now() - start;
+      serverLogger.info(
+        `[Server] Summarized ${stats.summarized} symbols (${stats.summaries} in ${stats.files} files) in ${duration}ms`
+      );
+      return { ...stats, symbols: summaries.list({ scopePath: options?.scopePath, limit: options?.limit ?? 1000 }), duration };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Symbol summaries cancelled by user');
+      throw new ResponseError(-32800, 'Symbol summaries cancelled');
+    }
+
+    serverLogger.error(`[Server] Error summarizing symbols: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/describeType', async (options: {
+  name: string;
+  uri?: string;
+}) => {
+  try {
+    serverLogger.info(`[Server] ========== DESCRIBE TYPE REQUEST: ${options?.name} ==========`);
+    
+    if (!options?.name) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Type name is required');
+    }
+    
+    const start = Date.now();
+    const fromUri = options.uri ? URI.parse(options.uri).fsPath : undefined;
+    const types = await new TypeModel(backgroundIndex).describe(options.name, { uri: fromUri });
+    
+    serverLogger.info(`[Server] Described ${types.length} type(s) named ${options.name} in ${Date.now() - start}ms`);
+    
+    return { types };
+  } catch (error) {
+    serverLogger.error(`[Server] Error describing type: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/implementations', async (options: {
+  name: string;
+  uri?: string;
+  direction?: 'implementations' | 'interfaces';
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== IMPLEMENTATIONS REQUEST: ${options?.name} ==========`);
+    
+    if (!options?.name) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Type or interface name is required');
+    }
+    
+    const start = Date.now();
+    const index = new ImplementationIndex(backgroundIndex);
+    const stats = await index.build({ cancellationToken: token });
+    
+    const fromUri = options.uri ? URI.parse(options.uri).fsPath : undefined;
+    const matches = options.direction === 'interfaces'
+      ? index.interfacesOf(options.name, fromUri)
+      : index.implementations(options.name, fromUri);
+    
+    serverLogger.info(
+      `[Server] ${matches.length} matches for ${options.name} ` +
+      `(${stats.types} types, ${stats.interfaces} interfaces) in ${Date.now() - start}ms`
+    );
+    
+    return { matches };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Implementation search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding implementations: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/typeHierarchy', async (options: {
+  name: string;
+  uri?: string;
+  direction?: TypeHierarchyDirection;
+  maxDepth?: number;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== TYPE HIERARCHY REQUEST: ${options?.name} ==========`);
+    
+    if (!options?.name) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Type name is required');
+    }
+    
+    const start = Date.now();
+    const hierarchy = new TypeHierarchy(backgroundIndex);
+    const stats = await hierarchy.build({ cancellationToken: token });
+    
+    const direction = options.direction ?? 'supertypes';
+    const queryOptions = { uri: options.uri ? URI.parse(options.uri).fsPath : undefined, maxDepth: options.maxDepth };
+    const roots = direction === 'subtypes'
+      ? hierarchy.subtypes(options.name, queryOptions)
+      : hierarchy.supertypes(options.name, queryOptions);
+    
+    serverLogger.info(
+      `[Server] ${direction} of ${options.name}: ${roots.length} root(s) ` +
+      `(${stats.types} types, ${stats.embeddings} embeddings) in ${Date.now() - start}ms`
+    );
+    
+    return { direction, roots };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Type hierarchy cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building type hierarchy: ${error}`);
+    throw error;
+  }
+});
+
+/**
+ * Content index with its skip list under `<cacheDirectory>/skip-list.bin`,
+ * created on first search and recreated when the cache directory moves.
+ */
+let contentIndex: { skipListPath: string; index: ContentIndex } | null = null;
+
+function getContentIndex(): ContentIndex {
+  const workspaceRoot = serverState.workspaceRoot;
+  if (!workspaceRoot) {
+    throw new Error('No workspace root available');
+  }
+  const skipListPath = path.join(workspaceRoot, configManager.getConfig().cacheDirectory, 'skip-list.bin');
+  if (contentIndex?.skipListPath !== skipListPath) {
+    contentIndex = { skipListPath, index: new ContentIndex(backgroundIndex, undefined, new FileSkipList(skipListPath)) };
+  }
+  return contentIndex.index;
+}
+
+connection.onRequest('smart-indexer/searchText', async (options: {
+  query: string;
+  kinds?: ContentKind[];
+  caseSensitive?: boolean;
+  limit?: number;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== SEARCH TEXT REQUEST: ${options?.query} ==========`);
+    
+    if (!options?.query?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Search query is required');
+    }
+    
+    const index = getContentIndex();
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Indexing comments and strings', 0, 'Scanning files...', true);
+    
+    let stats;
+    try {
+      stats = await index.build({
+        query: options.query,
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+        }
+      });
+    } finally {
+      progress.done();
+    }
+    
+    const matches = index.search(options.query, {
+      kinds: options.kinds,
+      caseSensitive: options.caseSensitive,
+      limit: options.limit
+    });
+    
+    serverLogger.info(
+      `[Server] ${matches.length} text matches for "${options.query}" ` +
+      `(${stats.files} files, ${stats.updated} rescanned, ${stats.skipped} skipped, ${stats.terms} terms) in ${Date.now() - start}ms`
+    );
+    
+    return { matches, ...stats, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Text search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error searching text: ${error}`);
+    throw error;
+  }
+});
+
+const trigramIndex = new TrigramIndex(backgroundIndex);
+
+connection.onRequest('smart-indexer/grep', async (options: {
+  pattern: string;
+  regex?: boolean;
+  caseSensitive?: boolean;
+  limit?: number;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== GREP REQUEST: ${options?.pattern} ==========`);
+    
+    if (!options?.pattern) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'Search pattern is required');
+    }
+    if (options.regex) {
+      try {
+        new RegExp(options.pattern);
+      } catch (error) {
+        throw new ResponseError(ErrorCodes.InvalidParams, error instanceof Error ? error.message : String(error));
+      }
+    }
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Indexing trigrams', 0, 'Scanning files...', true);
+    
+    let stats;
+    try {
+      stats = await trigramIndex.build({
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+        }
+      });
+    } finally {
+      progress.done();
+    }
+    
+    const result = await trigramIndex.grep(options.pattern, {
+      regex: options.regex,
+      caseSensitive: options.caseSensitive,
+      limit: options.limit,
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${result.matches.length} grep matches for "${options.pattern}" in ${result.candidates} of ${stats.files} files ` +
+      `(${result.indexed ? 'trigram index' : 'full scan'}, ${stats.updated} reindexed) in ${Date.now() - start}ms`
+    );
+    
+    return { ...result, ...stats, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Grep cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error running grep: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/searchSignatures', async (options: {
+  signature?: string;
+  constraint?: string;
+  generic?: boolean;
+  name?: string;
+  kinds?: string[];
+  limit?: number;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(
+      `[Server] ========== SEARCH SIGNATURES REQUEST: ${options?.signature ?? ''} ${options?.constraint ?? ''} ==========`
+    );
+    
+    if (!options?.signature?.trim() && !options?.constraint?.trim() && !options?.generic) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'A signature pattern, a constraint or generic is required');
+    }
+    
+    const signature = options.signature?.trim() || undefined;
+    if (signature) {
+      try {
+        parseSignaturePattern(signature);
+      } catch (error) {
+        throw new ResponseError(ErrorCodes.InvalidParams, error instanceof Error ? error.message : String(error));
+      }
+    }
+    
+    const start = Date.now();
+    const result = await new SignatureSearch(backgroundIndex).search({
+      signature,
+      constraint: options.constraint?.trim() || undefined,
+      generic: options.generic,
+      name: options.name,
+      kinds: options.kinds,
+      limit: options.limit,
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${result.symbols.length} signature matches (${result.filesScanned} files) in ${Date.now() - start}ms`
+    );
+    
+    return { ...result, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Signature search cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error searching signatures: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/query', async (options: {
+  query?: string;
+  /** Name of a saved query of the config, run instead of `query` */
+  saved?: string;
+  /** Named scope of the config to run the query in */
+  scope?: string;
+  limit?: number;
+  offset?: number;
+  cursor?: string;
+}, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== STRUCTURED QUERY REQUEST: ${options?.saved ? `saved ${options.saved}` : options?.query ?? ''} ==========`);
+    
+    if (!options?.saved && !options?.query?.trim()) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'A query is required');
+    }
+    
+    const start = Date.now();
+    const scopes = new SearchScopes(configManager.getSearchConfig());
+    const query = options.saved ? scopes.resolve(options.saved, options.scope) : scopes.restrict(options.query!, options.scope);
+    const result = await new StructuredQuery(backgroundIndex, codeOwners, coverage).page(query, {
+      limit: options.limit,
+      offset: options.offset,
+      cursor: options.cursor,
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${result.symbols.length} query matches via ${result.plan} (${result.filesScanned} files) in ${Date.now() - start}ms`
+    );
+    
+    return { ...result, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof QuerySyntaxError || error instanceof ScopeError || error instanceof CursorError || error instanceof RangeError) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Structured query cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error running structured query: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/scopes', () => {
+  const scopes = new SearchScopes(configManager.getSearchConfig());
+  return { scopes: scopes.scopes(), queries: scopes.savedQueries() };
+});
+
+connection.onRequest('smart-indexer/renameCheck', async (options: {
+  oldName: string;
+  newName: string;
+}) => {
+  try {
+    serverLogger.info(`[Server] ========== RENAME CHECK REQUEST: ${options?.oldName} -> ${options?.newName} ==========`);
+    
+    if (!options?.oldName || !options?.newName) {
+      throw new ResponseError(ErrorCodes.InvalidParams, 'The old and new names are required');
+    }
+    
+    const start = Date.now();
+    const impact = await new RenameImpactAnalyzer(mergedIndex).check(options.oldName, options.newName);
+    
+    serverLogger.info(
+      `[Server] Rename ${options.oldName} -> ${options.newName}: ${impact.locations.length} locations, ` +
+      `${impact.collisions.length} collisions, risk ${impact.blastRadius.risk} in ${Date.now() - start}ms`
+    );
+    
+    return impact;
+  } catch (error) {
+    serverLogger.error(`[Server] Error checking rename: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/findClones', async (options: {
+  minSimilarity?: number;
+  minTokens?: number;
+  includeTests?: boolean;
+  scopePath?: string;
+  limit?: number;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== FIND CLONES REQUEST ==========');
+    
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding Duplicate Code', 0, 'Fingerprinting functions...', true);
+    
+    const start = Date.now();
+    
+    try {
+      const report = await new CloneDetector(backgroundIndex).findClones({
+        minSimilarity: options?.minSimilarity,
+        minTokens: options?.minTokens,
+        scopePath: options?.scopePath,
+        limit: options?.limit,
+        // Generated code is duplicated by design
+        tags: { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] },
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          const percentage = total > 0 ? Math.round((current / total) * 100) : 0;
+          progress.report(percentage, message || `${current}/${total} files`);
+        }
+      });
+      
+      const duration = Date.now() - start;
+      
+      serverLogger.info(
+        `[Server] Clone detection complete: ${report.clusters.length} clusters ` +
+        `(${report.functionsAnalyzed} functions in ${report.filesScanned} files) in ${duration}ms`
+      );
+      
+      return {
+        clusters: report.clusters.map(cluster => ({
+          similarity: cluster.similarity,
+          exact: cluster.exact,
+          members: cluster.members.map(member => ({
+            name: member.symbol.name,
+            kind: member.symbol.kind,
+            containerName: member.symbol.containerName,
+            location: member.symbol.location,
+            startLine: member.startLine,
+            endLine: member.endLine,
+            tokens: member.tokens
+          }))
+        })),
+        functionsAnalyzed: report.functionsAnalyzed,
+        filesScanned: report.filesScanned,
+        truncated: report.truncated,
+        duration
+      };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      serverLogger.info('[Server] Clone detection cancelled by user');
+      throw new ResponseError(-32800, 'Clone detection cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error finding clones: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/functionMetrics', async (options: {
+  sort?: CodeMetric;
+  limit?: number;
+  min?: Partial<Record<CodeMetric, number>>;
+  includeTests?: boolean;
+  scopePath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== FUNCTION METRICS REQUEST: sort by ${options?.sort ?? 'complexity'} ==========`);
+    
+    const start = Date.now();
+    const report = await new CodeMetrics(backgroundIndex).report({
+      sort: options?.sort,
+      limit: options?.limit,
+      min: options?.min,
+      scopePath: options?.scopePath,
+      tags: { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] },
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${report.matched} of ${report.functionsAnalyzed} functions match (${report.filesScanned} files) in ${Date.now() - start}ms`
+    );
+    
+    return {
+      functions: report.functions.map(entry => ({
+        name: entry.symbol.name,
+        kind: entry.symbol.kind,
+        containerName: entry.symbol.containerName,
+        location: entry.symbol.location,
+        complexity: entry.complexity,
+        loc: entry.loc,
+        parameters: entry.parameters,
+        nesting: entry.nesting
+      })),
+      functionsAnalyzed: report.functionsAnalyzed,
+      matched: report.matched,
+      truncated: report.truncated,
+      duration: Date.now() - start
+    };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Function metrics cancelled');
+    }
+    if (error instanceof Error && /^(Unknown metric|Invalid minimum)/.test(error.message)) {
+      throw new ResponseError(ErrorCodes.InvalidParams, error.message);
+    }
+    
+    serverLogger.error(`[Server] Error collecting function metrics: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/ownership', async (options: {
+  owner?: string;
+  includeTests?: boolean;
+  scopePath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== OWNERSHIP REQUEST${options?.owner ? `: ${options.owner}` : ''} ==========`);
+    
+    const start = Date.now();
+    const codeOwnersFile = configManager.getCodeOwners()?.getSourceFile();
+    const report = await ownership.summarize({
+      owner: options?.owner,
+      scopePath: options?.scopePath,
+      tags: { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] },
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${report.owners.length} owners from ${codeOwnersFile ?? 'no CODEOWNERS file'} ` +
+      `(${report.filesScanned} files) in ${Date.now() - start}ms`
+    );
+    
+    return { ...report, codeOwnersFile, duration: Date.now() - start };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Ownership summary cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error summarizing ownership: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/deprecations', async (options: {
+  name?: string;
+  owner?: string;
+  includeTests?: boolean;
+  scopePath?: string;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info(`[Server] ========== DEPRECATIONS REQUEST${options?.name ? `: ${options.name}` : ''} ==========`);
+    
+    const start = Date.now();
+    const progress = await connection.window.createWorkDoneProgress();
+    progress.begin('Finding uses of deprecated symbols', 0, 'Scanning files...', true);
+    
+    try {
+      const report = await new Deprecations(backgroundIndex, codeOwners).report({
+        name: options?.name,
+        owner: options?.owner,
+        scopePath: options?.scopePath,
+        tags: { exclude: options?.includeTests ? ['generated'] : ['test', 'generated'] },
+        cancellationToken: token,
+        onProgress: (current, total, message) => {
+          progress.report(total > 0 ? Math.round((current / total) * 100) : 0, message);
+        }
+      });
+      
+      serverLogger.info(
+        `[Server] ${report.callSites} uses of ${report.deprecated.length} deprecated symbols ` +
+        `by ${report.teams.length} owners (${report.filesScanned} files) in ${Date.now() - start}ms`
+      );
+      
+      return { ...report, duration: Date.now() - start };
+    } finally {
+      progress.done();
+    }
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Deprecation report cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error building deprecation report: ${error}`);
+    throw error;
+  }
+});
+
+connection.onRequest('smart-indexer/parseErrors', async (options: {
+  scopePath?: string;
+  limit?: number;
+} | undefined, token: CancellationToken) => {
+  try {
+    serverLogger.info('[Server] ========== PARSE ERRORS REQUEST ==========');
+    
+    const start = Date.now();
+    const report = await new ParseErrors(backgroundIndex).list({
+      scopePath: options?.scopePath,
+      limit: options?.limit,
+      cancellationToken: token
+    });
+    
+    serverLogger.info(
+      `[Server] ${report.errors} parse errors in ${report.files.length} of ${report.filesScanned} files in ${Date.now() - start}ms`
+    );
+    
+    return {
+      files: report.files,
+      errors: report.errors,
+      truncated: report.truncated,
+      duration: Date.now() - start
+    };
+  } catch (error) {
+    if (error instanceof CancellationError || token.isCancellationRequested) {
+      throw new ResponseError(-32800, 'Parse error listing cancelled');
+    }
+    
+    serverLogger.error(`[Server] Error listing parse errors: ${error}`);
+    throw error;
+  }
+});
+
+// ============================================================================
+// Helper Functions
+// ============================================================================
+
+function updateStats(): void {
+  const dynamicStats = dynamicIndex.getStats();
+  const backgroundStats = backgroundIndex.getStats();
+  
+  statsManager.updateDynamicStats(dynamicStats.files, dynamicStats.symbols);
+  statsManager.updateBackgroundStats(backgroundStats.files, backgroundStats.symbols, backgroundStats.shards);
+}
+
+// ============================================================================
+// Server Lifecycle
+// ============================================================================
+
+documents.listen(connection);
+
+connection.onShutdown(async () => {
+  try {
+    serverLogger.info('[Server] Shutting down, closing resources...');
+
+    // Stop background indexing first; files being written are finished
+    serverInitializer.cancelIndexing();
+    
+    // Dispose document event handler
+    if (documentEventHandler) {
+      documentEventHandler.dispose();
+    }
+    
+    // Dispose file watcher
+    const fileWatcher = serverState.fileWatcher;
+    if (fileWatcher) {
+      await fileWatcher.dispose();
+    }
+    
+    await queryServer.stop();
+    extractorHost.dispose();
+    embeddingIndex?.provider.dispose?.();
+    symbolSummaries?.provider.dispose?.();
+    await serverState.staticIndex?.dispose();
+    
     // Then dispose background index
     await backgroundIndex.dispose();
     
-    connection.console.info('[Server] Resources closed successfully');
+    serverLogger.info('[Server] Resources closed successfully');
   } catch (error) {
-    logger.error(`[Server] Error during shutdown: ${error}`);
+    serverLogger.error(`[Server] Error during shutdown: ${error}`);
   }
 });
 
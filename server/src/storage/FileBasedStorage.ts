@@ -47,6 +47,8 @@ export class FileBasedStorage implements IIndexStorage {
       imports: data.imports,
       reExports: data.reExports,
       pendingReferences: data.pendingReferences,
+      tags: data.tags,
+      diagnostics: data.diagnostics,
       lastIndexedAt: data.lastIndexedAt,
       shardVersion: data.shardVersion,
       mtime: data.mtime
@@ -73,6 +75,8 @@ export class FileBasedStorage implements IIndexStorage {
       imports: shard.imports,
       reExports: shard.reExports,
       pendingReferences: shard.pendingReferences,
+      tags: shard.tags,
+      diagnostics: shard.diagnostics,
       lastIndexedAt: shard.lastIndexedAt,
       shardVersion: shard.shardVersion,
       mtime: shard.mtime
@@ -118,6 +122,8 @@ export class FileBasedStorage implements IIndexStorage {
       imports: shard.imports,
       reExports: shard.reExports,
       pendingReferences: shard.pendingReferences,
+      tags: shard.tags,
+      diagnostics: shard.diagnostics,
       lastIndexedAt: shard.lastIndexedAt,
       shardVersion: shard.shardVersion,
       mtime: shard.mtime
@@ -137,6 +143,8 @@ export class FileBasedStorage implements IIndexStorage {
       imports: data.imports,
       reExports: data.reExports,
       pendingReferences: data.pendingReferences,
+      tags: data.tags,
+      diagnostics: data.diagnostics,
       lastIndexedAt: data.lastIndexedAt,
       shardVersion: data.shardVersion,
       mtime: data.mtime
@@ -383,19 +391,47 @@ export class FileBasedStorage implements IIndexStorage {
    */
   async findReferencesInSql(name: string): Promise<IndexedReference[]> {
     const refs: IndexedReference[] = [];
+    for await (const batch of this.streamReferencesInSql(name)) {
+      for (const ref of batch) {
+        refs.push(ref);
+      }
+    }
+    return refs;
+  }
+
+  /**
+   * References to a name one shard at a time, as the shards are read.
+   */
+  async *streamReferencesInSql(name: string): AsyncIterable<IndexedReference[]> {
     const allFiles = await this.getAllMetadata();
-    
+    for (const meta of allFiles) {
+      const shard = await this.getFile(meta.uri);
+      const refs = shard?.references.filter(ref => ref.symbolName === name) ?? [];
+      if (refs.length > 0) {
+        yield refs;
+      }
+    }
+  }
+
+  /**
+   * Count non-local references per name by scanning all shards.
+   */
+  async countReferences(names: string[]): Promise<Array<{ name: string; count: number }>> {
+    const counts = new Map<string, number>(names.map(name => [name, 0]));
+    const allFiles = await this.getAllMetadata();
+
     for (const meta of allFiles) {
       const shard = await this.getFile(meta.uri);
       if (shard) {
         for (const ref of shard.references) {
-          if (ref.symbolName === name) {
-            refs.push(ref);
+          const count = counts.get(ref.symbolName);
+          if (count !== undefined && !ref.isLocal) {
+            counts.set(ref.symbolName, count + 1);
           }
         }
       }
     }
-    return refs;
+    return [...counts].filter(([, count]) => count > 0).map(([name, count]) => ({ name, count }));
   }
 
   /**
@@ -1,4 +1,4 @@
-import { IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, PendingReference } from '../types.js';
+import { IndexedSymbol, IndexedReference, ImportInfo, ReExportInfo, PendingReference, CodeTag, ParseDiagnostic } from '../types.js';
 
 /**
  * Represents indexed data for a single file.
@@ -11,6 +11,8 @@ export interface FileIndexData {
   imports: ImportInfo[];
   reExports?: ReExportInfo[];
   pendingReferences?: PendingReference[];
+  tags?: CodeTag[];
+  diagnostics?: ParseDiagnostic[];
   lastIndexedAt: number;
   shardVersion?: number;
   mtime?: number;
@@ -222,6 +224,18 @@ export interface IIndexStorage {
    */
   findReferencesInSql(name: string): Promise<IndexedReference[]>;
 
+  /**
+   * findReferencesInSql in batches, in the same order, read from storage
+   * as they are consumed. Storages without it are read in one go.
+   */
+  streamReferencesInSql?(name: string): AsyncIterable<IndexedReference[]>;
+
+  /**
+   * Count the non-local references to each of the given names.
+   * Names without references are left out.
+   */
+  countReferences(names: string[]): Promise<Array<{ name: string; count: number }>>;
+
   /**
    * Find all NgRx action groups in the workspace.
    */
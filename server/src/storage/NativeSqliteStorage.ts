@@ -7,13 +7,20 @@ import {
   FileMetadata, 
   StorageStats
 } from './IIndexStorage';
-import { IndexedSymbol, IndexedReference } from '../types';
+import { IndexedSymbol, IndexedReference, SymbolRange } from '../types';
+import { toSubsequenceLikePattern } from '../utils/fuzzySearch';
+
+/** Names per countReferences query */
+const COUNT_BATCH_SIZE = 500;
+
+/** Rows per batch of streamReferencesInSql */
+const REFERENCE_BATCH_SIZE = 1000;
 
 export class NativeSqliteStorage implements IIndexStorage {
   private db: Database.Database | null = null;
   private dbPath: string = '';
   private isInitialized = false;
-  private static readonly SCHEMA_VERSION = 9;
+  private static readonly SCHEMA_VERSION = 16;
   
   // Statement Cache
   private statements: Map<string, Database.Statement> = new Map();
@@ -91,8 +98,8 @@ export class NativeSqliteStorage implements IIndexStorage {
     // Symbols
     this.statements.set('deleteSymbolsByUri', this.db.prepare('DELETE FROM symbols WHERE uri = ?'));
     this.statements.set('insertSymbol', this.db.prepare(`
-      INSERT INTO symbols (id, uri, name, kind, container_name, range_start_line, range_start_character, range_end_line, range_end_character, is_definition, is_exported, full_container_path, ngrx_metadata, extends_name, implements_names)
-      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      INSERT INTO symbols (id, uri, name, kind, container_name, range_start_line, range_start_character, range_end_line, range_end_character, is_definition, is_exported, full_container_path, ngrx_metadata, extends_name, implements_names, metadata, tags, doc, signature, type_parameters, value, range_start_offset, range_end_offset)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `));
     this.statements.set('getSymbolsByUri', this.db.prepare('SELECT * FROM symbols WHERE uri = ?'));
     this.statements.set('findDefinitions', this.db.prepare('SELECT * FROM symbols WHERE name = ? AND is_definition = 1'));
@@ -100,11 +107,14 @@ export class NativeSqliteStorage implements IIndexStorage {
     // References
     this.statements.set('deleteRefsByUri', this.db.prepare('DELETE FROM references WHERE uri = ?'));
     this.statements.set('insertRef', this.db.prepare(`
-      INSERT INTO references (uri, symbol_name, line, character, range_start_line, range_start_character, range_end_line, range_end_character, container_name, is_local)
-      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      INSERT INTO references (uri, symbol_name, line, character, range_start_line, range_start_character, range_end_line, range_end_character, container_name, is_local, is_call, range_start_offset, range_end_offset)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `));
     this.statements.set('getRefsByUri', this.db.prepare('SELECT * FROM references WHERE uri = ?'));
     this.statements.set('findRefsByName', this.db.prepare('SELECT * FROM references WHERE symbol_name = ?'));
+    this.statements.set('findRefsByNameAfter', this.db.prepare(
+      'SELECT rowid AS row_id, * FROM references WHERE symbol_name = ? AND rowid > ? ORDER BY rowid LIMIT ?'
+    ));
 
     // FTS
     this.statements.set('deleteFtsByUri', this.db.prepare('DELETE FROM symbols_fts WHERE uri = ?'));
@@ -150,12 +160,121 @@ export class NativeSqliteStorage implements IIndexStorage {
           if (currentVersion < 9) {
             this.migrateToV9();
           }
+          if (currentVersion < 10) {
+            this.migrateToV10();
+          }
+          if (currentVersion < 11) {
+            this.migrateToV11();
+          }
+          if (currentVersion < 12) {
+            this.migrateToV12();
+          }
+          if (currentVersion < 13) {
+            this.migrateToV13();
+          }
+          if (currentVersion < 14) {
+            this.migrateToV14();
+          }
+          if (currentVersion < 15) {
+            this.migrateToV15();
+          }
+          if (currentVersion < 16) {
+            this.migrateToV16();
+          }
         }
         this.setSchemaVersion(NativeSqliteStorage.SCHEMA_VERSION);
       })();
     }
   }
 
+  private migrateToV16() {
+    try {
+      // Byte offsets of symbol and reference ranges
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN range_start_offset INTEGER');
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN range_end_offset INTEGER');
+      this.db!.exec('ALTER TABLE references ADD COLUMN range_start_offset INTEGER');
+      this.db!.exec('ALTER TABLE references ADD COLUMN range_end_offset INTEGER');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL, refs_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV15() {
+    try {
+      // Constant values shown in search results and hover
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN value TEXT');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV14() {
+    try {
+      // Function signatures and generic type parameters for signature search
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN signature TEXT');
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN type_parameters TEXT');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV13() {
+    try {
+      // Doc comments shown in hover and query output
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN doc TEXT');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV12() {
+    try {
+      // Code tags (generated, test, mock, example) for query filtering
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN tags TEXT');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV11() {
+    try {
+      // Plugin/language metadata (e.g. Go receivers and embedded types) used to be dropped on load
+      this.db!.exec('ALTER TABLE symbols ADD COLUMN metadata TEXT');
+      this.db!.exec('UPDATE files SET symbol_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
+  private migrateToV10() {
+    try {
+      this.db!.exec('ALTER TABLE references ADD COLUMN is_call INTEGER DEFAULT 0');
+      // Existing rows predate call tracking - force references to be rewritten
+      this.db!.exec('UPDATE files SET refs_hash = NULL');
+    } catch (error: any) {
+      if (!error.message.includes('duplicate column name')) {
+        throw error;
+      }
+    }
+  }
+
   private migrateToV9() {
     try {
       this.db!.exec(`
@@ -246,6 +365,14 @@ export class NativeSqliteStorage implements IIndexStorage {
         is_exported INTEGER,
         full_container_path TEXT,
         ngrx_metadata TEXT,
+        metadata TEXT,
+        tags TEXT,
+        doc TEXT,
+        signature TEXT,
+        type_parameters TEXT,
+        value TEXT,
+        range_start_offset INTEGER,
+        range_end_offset INTEGER,
         FOREIGN KEY(uri) REFERENCES files(uri) ON DELETE CASCADE
       )
     `);
@@ -265,6 +392,9 @@ export class NativeSqliteStorage implements IIndexStorage {
         range_end_character INTEGER,
         container_name TEXT,
         is_local INTEGER,
+        is_call INTEGER DEFAULT 0,
+        range_start_offset INTEGER,
+        range_end_offset INTEGER,
         FOREIGN KEY(uri) REFERENCES files(uri) ON DELETE CASCADE
       )
     `);
@@ -363,7 +493,15 @@ export class NativeSqliteStorage implements IIndexStorage {
             s.isDefinition ? 1 : 0, s.isExported ? 1 : 0, s.fullContainerPath || '',
             s.ngrxMetadata ? JSON.stringify(s.ngrxMetadata) : null,
             (s as any).extends || null,
-            (s as any).implements ? (s as any).implements.join(',') : null
+            (s as any).implements ? (s as any).implements.join(',') : null,
+            s.metadata ? JSON.stringify(s.metadata) : null,
+            s.tags && s.tags.length > 0 ? s.tags.join(',') : null,
+            s.doc || null,
+            s.signature || null,
+            s.typeParameters && s.typeParameters.length > 0 ? JSON.stringify(s.typeParameters) : null,
+            s.value ?? null,
+            s.range.startOffset ?? null,
+            s.range.endOffset ?? null
           );
           insertFts.run(s.id, fileData.uri, s.name, s.containerName || '', s.kind, s.filePath || '');
         }
@@ -375,7 +513,8 @@ export class NativeSqliteStorage implements IIndexStorage {
           insertRef.run(
             fileData.uri, r.symbolName, r.location.line, r.location.character,
             r.range.startLine, r.range.startCharacter, r.range.endLine, r.range.endCharacter,
-            r.containerName || '', r.isLocal ? 1 : 0
+            r.containerName || '', r.isLocal ? 1 : 0, r.isCall ? 1 : 0,
+            r.range.startOffset ?? null, r.range.endOffset ?? null
           );
         }
       }
@@ -421,18 +560,7 @@ export class NativeSqliteStorage implements IIndexStorage {
 
   private async getFileReferences(uri: string): Promise<IndexedReference[]> {
     const rows = this.statements.get('getRefsByUri')!.all(uri) as any[];
-    return rows.map(r => ({
-      symbolName: r.symbol_name,
-      location: { uri: r.uri, line: r.line, character: r.character },
-      range: {
-        startLine: r.range_start_line,
-        startCharacter: r.range_start_character,
-        endLine: r.range_end_line,
-        endCharacter: r.range_end_character
-      },
-      containerName: r.container_name,
-      isLocal: !!r.is_local
-    }));
+    return rows.map(mapReference);
   }
 
   async deleteFile(uri: string): Promise<void> {
@@ -522,8 +650,9 @@ export class NativeSqliteStorage implements IIndexStorage {
     // No-op
   }
 
-  async searchSymbols(query: string, _mode: 'exact' | 'fuzzy' | 'fulltext' = 'exact', limit: number = 100): Promise<any[]> {
+  async searchSymbols(query: string, mode: 'exact' | 'fuzzy' | 'fulltext' = 'exact', limit: number = 100): Promise<any[]> {
     this.ensureInitialized();
+    let results: Array<{ uri: string; symbol: IndexedSymbol; rank?: number }>;
     try {
       // Use trigram matching for partial matches and ranking
       const sql = `
@@ -542,7 +671,7 @@ export class NativeSqliteStorage implements IIndexStorage {
       // For trigram, we use '"query"' for better accuracy or just query for contains
       const ftsQuery = `"${query.replace(/"/g, '""')}"`;
       const rows = this.db!.prepare(sql).all(query, `${query}%`, ftsQuery, limit) as any[];
-      return rows.map(r => ({
+      results = rows.map(r => ({
         uri: r.uri,
         symbol: this.mapSymbol(r),
         rank: r.relevance
@@ -550,11 +679,31 @@ export class NativeSqliteStorage implements IIndexStorage {
     } catch (error: any) {
       console.warn(`[NativeSqliteStorage] Search failed: ${error.message}. Falling back to LIKE.`);
       const rows = this.db!.prepare('SELECT * FROM symbols WHERE name LIKE ? LIMIT ?').all(`%${query}%`, limit) as any[];
-      return rows.map(r => ({
+      results = rows.map(r => ({
         uri: r.uri,
         symbol: this.mapSymbol(r)
       }));
     }
+
+    // Trigrams only find substrings - fuzzy queries ("NewPrsn") also need
+    // names that contain the characters in order. Ranking happens upstream.
+    if (mode === 'fuzzy' && results.length < limit) {
+      const seen = new Set(results.map(r => r.symbol.id));
+      const rows = this.db!.prepare(
+        "SELECT * FROM symbols WHERE is_definition = 1 AND name LIKE ? ESCAPE '\\' LIMIT ?"
+      ).all(toSubsequenceLikePattern(query), limit) as any[];
+      for (const r of rows) {
+        if (results.length >= limit) {
+          break;
+        }
+        if (!seen.has(r.id)) {
+          seen.add(r.id);
+          results.push({ uri: r.uri, symbol: this.mapSymbol(r) });
+        }
+      }
+    }
+
+    return results;
   }
 
   private mapSymbol(r: any): IndexedSymbol {
@@ -564,19 +713,20 @@ export class NativeSqliteStorage implements IIndexStorage {
       kind: r.kind,
       containerName: r.container_name,
       location: { uri: r.uri, line: r.range_start_line, character: r.range_start_character },
-      range: {
-        startLine: r.range_start_line,
-        startCharacter: r.range_start_character,
-        endLine: r.range_end_line,
-        endCharacter: r.range_end_character
-      },
+      range: mapRange(r),
       isDefinition: !!r.is_definition,
       isExported: !!r.is_exported,
       fullContainerPath: r.full_container_path,
       filePath: r.uri,
       extends: r.extends_name,
       implements: r.implements_names ? r.implements_names.split(',') : undefined,
-      ngrxMetadata: r.ngrx_metadata ? JSON.parse(r.ngrx_metadata) : undefined
+      ngrxMetadata: r.ngrx_metadata ? JSON.parse(r.ngrx_metadata) : undefined,
+      metadata: r.metadata ? JSON.parse(r.metadata) : undefined,
+      tags: r.tags ? r.tags.split(',') : undefined,
+      doc: r.doc || undefined,
+      signature: r.signature || undefined,
+      typeParameters: r.type_parameters ? JSON.parse(r.type_parameters) : undefined,
+      value: r.value ?? undefined
     };
   }
 
@@ -589,18 +739,44 @@ export class NativeSqliteStorage implements IIndexStorage {
   async findReferencesInSql(name: string): Promise<IndexedReference[]> {
     this.ensureInitialized();
     const rows = this.statements.get('findRefsByName')!.all(name) as any[];
-    return rows.map(r => ({
-      symbolName: r.symbol_name,
-      location: { uri: r.uri, line: r.line, character: r.character },
-      range: {
-        startLine: r.range_start_line,
-        startCharacter: r.range_start_character,
-        endLine: r.range_end_line,
-        endCharacter: r.range_end_character
-      },
-      containerName: r.container_name,
-      isLocal: !!r.is_local
-    }));
+    return rows.map(mapReference);
+  }
+
+  /**
+   * References to a name, a batch of rows per query. Each query starts
+   * after the last row of the one before, so the connection is free for
+   * other statements while a batch is being consumed.
+   */
+  async *streamReferencesInSql(name: string): AsyncIterable<IndexedReference[]> {
+    this.ensureInitialized();
+    let after = 0;
+    for (;;) {
+      const rows = this.statements.get('findRefsByNameAfter')!.all(name, after, REFERENCE_BATCH_SIZE) as any[];
+      if (rows.length === 0) {
+        return;
+      }
+      yield rows.map(mapReference);
+      if (rows.length < REFERENCE_BATCH_SIZE) {
+        return;
+      }
+      after = rows[rows.length - 1].row_id;
+    }
+  }
+
+  async countReferences(names: string[]): Promise<Array<{ name: string; count: number }>> {
+    this.ensureInitialized();
+    const counts: Array<{ name: string; count: number }> = [];
+    // Chunked to stay under SQLite's bound parameter limit
+    for (let i = 0; i < names.length; i += COUNT_BATCH_SIZE) {
+      const batch = names.slice(i, i + COUNT_BATCH_SIZE);
+      const rows = this.db!.prepare(
+        `SELECT symbol_name, COUNT(*) AS count FROM references
+         WHERE symbol_name IN (${batch.map(() => '?').join(',')}) AND is_local = 0
+         GROUP BY symbol_name`
+      ).all(...batch) as any[];
+      counts.push(...rows.map(r => ({ name: r.symbol_name, count: r.count })));
+    }
+    return counts;
   }
 
   async findFilesWithPendingRefs(): Promise<string[]> {
@@ -707,3 +883,31 @@ export class NativeSqliteStorage implements IIndexStorage {
     await this.clear();
   }
 }
+
+function mapReference(r: any): IndexedReference {
+  return {
+    symbolName: r.symbol_name,
+    location: { uri: r.uri, line: r.line, character: r.character },
+    range: mapRange(r),
+    containerName: r.container_name,
+    isLocal: !!r.is_local,
+    isCall: !!r.is_call
+  };
+}
+
+/**
+ * Range of a symbols/references row, with byte offsets when stored.
+ */
+function mapRange(r: any): SymbolRange {
+  const range: SymbolRange = {
+    startLine: r.range_start_line,
+    startCharacter: r.range_start_character,
+    endLine: r.range_end_line,
+    endCharacter: r.range_end_character
+  };
+  if (r.range_start_offset !== null && r.range_start_offset !== undefined) {
+    range.startOffset = r.range_start_offset;
+    range.endOffset = r.range_end_offset;
+  }
+  return range;
+}
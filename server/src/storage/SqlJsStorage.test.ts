@@ -185,6 +185,12 @@ describe('SqlJsStorage - Migrations and FTS', () => {
       expect(names).toContain('getData');
     });
 
+    it('should find names containing the query as a subsequence in fuzzy mode', async () => {
+      const results = await storage.searchSymbols('DtSrv', 'fuzzy');
+      
+      expect(results.map(r => r.symbol.name)).toContain('DataService');
+    });
+
     it('should search using FTS5 (prefix matching)', async () => {
       const results = await storage.searchSymbols('load', 'fulltext');
       
@@ -263,6 +269,48 @@ describe('SqlJsStorage - Migrations and FTS', () => {
     });
   });
 
+  describe('Metadata Updates', () => {
+    it('should persist mtime changes without touching stored symbols', async () => {
+      const uri = path.join(testDir, 'meta.ts');
+      const testData: FileIndexData = {
+        uri,
+        hash: 'hash1',
+        symbols: [
+          {
+            id: 'sym1',
+            name: 'MetaClass',
+            kind: 'class',
+            location: { uri, line: 1, character: 6 },
+            range: { startLine: 1, startCharacter: 6, endLine: 3, endCharacter: 1 },
+            filePath: uri,
+            isDefinition: true
+          } as IndexedSymbol
+        ],
+        references: [],
+        imports: [],
+        lastIndexedAt: 1000,
+        mtime: 1000
+      };
+
+      await storage.storeFile(testData);
+      await storage.updateMetadata({ uri, hash: 'hash1', mtime: 2000, symbolCount: 1, lastIndexedAt: 1000 });
+
+      const metadata = await storage.getMetadata(uri);
+      expect(metadata?.mtime).toBe(2000);
+      expect(metadata?.hash).toBe('hash1');
+
+      const results = await storage.searchSymbols('MetaClass', 'exact');
+      expect(results.length).toBe(1);
+    });
+
+    it('should ignore metadata updates for unknown files', async () => {
+      const uri = path.join(testDir, 'missing.ts');
+      await storage.updateMetadata({ uri, hash: 'h', mtime: 1, symbolCount: 0, lastIndexedAt: 0 });
+
+      expect(await storage.getMetadata(uri)).toBeNull();
+    });
+  });
+
   describe('Atomic Updates and Duplicate Prevention', () => {
     it('should prevent duplicate symbols on repeated saves', async () => {
       const testData: FileIndexData = {
@@ -518,6 +566,23 @@ describe('SqlJsStorage - Migrations and FTS', () => {
       expect(results[0].location.line).toBe(5);
     });
 
+    it('should count non-local references per name', async () => {
+      await storage.storeFile({
+        uri: path.join(testDir, 'count_test.ts'),
+        hash: 'c123',
+        symbols: [],
+        references: [
+          { symbolName: 'Logger', location: { uri: path.join(testDir, 'count_test.ts'), line: 1, character: 0 }, range: { startLine: 1, startCharacter: 0, endLine: 1, endCharacter: 6 }, isLocal: false },
+          { symbolName: 'Service', location: { uri: path.join(testDir, 'count_test.ts'), line: 2, character: 0 }, range: { startLine: 2, startCharacter: 0, endLine: 2, endCharacter: 7 }, isLocal: true }
+        ] as any,
+        imports: [],
+        lastIndexedAt: Date.now()
+      });
+
+      const counts = await storage.countReferences(['Logger', 'Service', 'NonExistent']);
+      expect(counts).toEqual([{ name: 'Logger', count: 2 }]);
+    });
+
     it('should return empty array for non-existent symbols', async () => {
       const defs = await storage.findDefinitionsInSql('NonExistent');
       expect(defs).toEqual([]);
@@ -1,9 +1,13 @@
 import { IIndexStorage, FileIndexData, FileMetadata, StorageStats } from './IIndexStorage.js';
 import { IndexedSymbol, IndexedReference } from '../types.js';
+import { toSubsequenceLikePattern } from '../utils/fuzzySearch.js';
 import initSqlJs, { Database } from 'sql.js';
 import * as fs from 'fs';
 import * as path from 'path';
 
+/** Names per countReferences query */
+const COUNT_BATCH_SIZE = 500;
+
 /**
  * SQLite-based storage implementation using sql.js (WASM).
  * 
@@ -1008,11 +1012,31 @@ private async migrateToV6(): Promise<void> {
 
   /**
    * Update metadata for a file (optimization for avoiding full file loads).
-   * Note: In SQLite implementation, this is a no-op as metadata is derived from file data.
+   * Only touches the metadata columns - stored symbols and references are left as-is.
+   * No-op if the file has not been stored yet.
    */
-  async updateMetadata(_metadata: FileMetadata): Promise<void> {
-    // No-op for SQLite - metadata is derived from full file data
-    // This method exists for interface compatibility with sharded storage
+  async updateMetadata(metadata: FileMetadata): Promise<void> {
+    await this.withLock(metadata.uri, async () => {
+      this.ensureInitialized();
+
+      const normalizedUri = this.normalizeUri(metadata.uri);
+
+      this.db!.run(
+        `UPDATE files SET hash = ?, mtime = ?, symbol_count = ?, last_indexed_at = ? WHERE uri = ?`,
+        [
+          metadata.hash,
+          metadata.mtime || 0,
+          metadata.symbolCount,
+          metadata.lastIndexedAt,
+          normalizedUri
+        ]
+      );
+
+      if (this.db!.getRowsModified() > 0) {
+        this.isDirty = true;
+        this.scheduleAutoSave();
+      }
+    });
   }
 
   /**
@@ -1130,6 +1154,36 @@ private async migrateToV6(): Promise<void> {
       } catch (fallbackError) { /* ignore */ }
     }
 
+    // Prefix tokens miss names that skip characters ("NewPrsn" -> NewPerson);
+    // fetch subsequence matches too and let the caller rank them
+    if (mode === 'fuzzy' && results.length < limit) {
+      try {
+        const seen = new Set(results.map(r => r.symbol.id));
+        const result = this.db!.exec(`
+          SELECT 
+            uri, id, name, kind, container_name, range_start_line, range_start_character,
+            range_end_line, range_end_character, is_definition, is_exported, full_container_path, ngrx_metadata
+          FROM symbols
+          WHERE is_definition = 1 AND name LIKE ? ESCAPE '\\'
+          LIMIT ?
+        `, [toSubsequenceLikePattern(query), limit]);
+
+        if (result.length > 0) {
+          for (const row of result[0].values) {
+            if (results.length >= limit) {
+              break;
+            }
+            if (!seen.has(row[1] as string)) {
+              seen.add(row[1] as string);
+              results.push({ uri: row[0] as string, symbol: this.mapSqlSymbol(row) });
+            }
+          }
+        }
+      } catch (error: any) {
+        console.warn(`[SqlJsStorage] Fuzzy subsequence search failed: ${error.message}`);
+      }
+    }
+
     return results;
   }
 
@@ -1492,6 +1546,28 @@ private async migrateToV6(): Promise<void> {
     return result[0].values.map(row => this.mapSqlReference(row));
   }
 
+  /**
+   * Count non-local references per name using relational index.
+   */
+  async countReferences(names: string[]): Promise<Array<{ name: string; count: number }>> {
+    this.ensureInitialized();
+    const counts: Array<{ name: string; count: number }> = [];
+    // Chunked to stay under SQLite's bound parameter limit
+    for (let i = 0; i < names.length; i += COUNT_BATCH_SIZE) {
+      const batch = names.slice(i, i + COUNT_BATCH_SIZE);
+      const result = this.db!.exec(
+        `SELECT symbol_name, COUNT(*) FROM refs
+         WHERE symbol_name IN (${batch.map(() => '?').join(',')}) AND is_local = 0
+         GROUP BY symbol_name`,
+        batch
+      );
+      if (result.length > 0) {
+        counts.push(...result[0].values.map(row => ({ name: row[0] as string, count: row[1] as number })));
+      }
+    }
+    return counts;
+  }
+
   /**
    * Helper to map a SQL row to an IndexedSymbol.
    */
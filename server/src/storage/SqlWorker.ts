@@ -91,6 +91,9 @@ parentPort.on('message', async (message) => {
         result = await storage.findReferencesInSql(payload);
         useTransfer = true;
         break;
+      case 'countReferences':
+        result = await storage.countReferences(payload);
+        break;
       case 'findNgRxActionGroups':
         result = await storage.findNgRxActionGroups();
         break;
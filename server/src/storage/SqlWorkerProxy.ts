@@ -8,6 +8,7 @@ import {
 } from './IIndexStorage.js';
 import { IndexedSymbol, IndexedReference } from '../types.js';
 import { decode } from '@msgpack/msgpack';
+import { ILogger, NullLogger } from '../utils/Logger.js';
 
 // Note: __dirname is available in CommonJS context.
 // In the bundled output, esbuild handles this correctly.
@@ -26,7 +27,7 @@ export class SqlWorkerProxy implements IIndexStorage {
   private cacheDirectory: string = '';
   private autoSaveDelay: number;
 
-  constructor(autoSaveDelay: number = 2000) {
+  constructor(autoSaveDelay: number = 2000, private logger: ILogger = new NullLogger()) {
     this.autoSaveDelay = autoSaveDelay;
   }
 
@@ -57,7 +58,7 @@ export class SqlWorkerProxy implements IIndexStorage {
     });
 
     this.worker.on('error', (err) => {
-      console.error('[SqlWorkerProxy] Worker error:', err);
+      this.logger.error('[SqlWorkerProxy] Worker error', err);
       // Fail all pending requests
       for (const [id, request] of this.pendingRequests) {
         request.reject(err);
@@ -190,6 +191,10 @@ export class SqlWorkerProxy implements IIndexStorage {
     return this.sendRequest('findReferencesInSql', name);
   }
 
+  async countReferences(names: string[]): Promise<Array<{ name: string; count: number }>> {
+    return this.sendRequest('countReferences', names);
+  }
+
   async findNgRxActionGroups(): Promise<Array<{ uri: string; symbol: IndexedSymbol }>> {
     return this.sendRequest('findNgRxActionGroups');
   }
@@ -0,0 +1,113 @@
+/**
+ * MockBackgroundIndex - Test double for the file-level BackgroundIndex API.
+ * 
+ * Covers the subset used by workspace analyses (getAllFiles, getFileInfo,
+ * getFileResult, updateFile, findDefinitions, findReferencesByName,
+ * getReferenceCounts).
+ * Cast to BackgroundIndex when injecting.
+ */
+
+import { BackgroundIndex } from '../../index/backgroundIndex.js';
+import { IndexedFileResult, IndexedReference, IndexedSymbol } from '../../types.js';
+
+export class MockBackgroundIndex {
+  private files: Map<string, IndexedFileResult> = new Map();
+
+  /**
+   * Add (or replace) a file in the mock index.
+   */
+  addFile(
+    uri: string,
+    symbols: IndexedSymbol[],
+    references: IndexedReference[] = [],
+    extra: Partial<IndexedFileResult> = {}
+  ): void {
+    this.files.set(uri, {
+      uri,
+      hash: `hash-${uri}`,
+      symbols,
+      references,
+      imports: [],
+      ...extra
+    });
+  }
+
+  removeFile(uri: string): void {
+    this.files.delete(uri);
+  }
+
+  async updateFile(uri: string, result: IndexedFileResult): Promise<void> {
+    this.files.set(uri, result);
+  }
+
+  async getAllFiles(): Promise<string[]> {
+    return Array.from(this.files.keys());
+  }
+
+  getAllFileUris(): string[] {
+    return Array.from(this.files.keys());
+  }
+
+  getFileInfo(uri: string): { uri: string; hash: string; lastIndexedAt: number } | undefined {
+    const file = this.files.get(uri);
+    return file ? { uri, hash: file.hash, lastIndexedAt: 0 } : undefined;
+  }
+
+  async getFileResult(uri: string): Promise<IndexedFileResult | null> {
+    return this.files.get(uri) || null;
+  }
+
+  async getFileSymbols(uri: string): Promise<IndexedSymbol[]> {
+    return this.files.get(uri)?.symbols || [];
+  }
+
+  async findDefinitions(name: string): Promise<IndexedSymbol[]> {
+    const defs: IndexedSymbol[] = [];
+    for (const file of this.files.values()) {
+      defs.push(...file.symbols.filter(s => s.name === name && s.isDefinition !== false));
+    }
+    return defs;
+  }
+
+  async findReferencesByName(
+    name: string,
+    options?: { excludeLocal?: boolean; scopeId?: string }
+  ): Promise<IndexedReference[]> {
+    const refs: IndexedReference[] = [];
+    for (const file of this.files.values()) {
+      for (const ref of file.references) {
+        if (ref.symbolName !== name) {
+          continue;
+        }
+        if (options?.excludeLocal && ref.isLocal) {
+          continue;
+        }
+        if (options?.scopeId && ref.scopeId !== options.scopeId) {
+          continue;
+        }
+        refs.push(ref);
+      }
+    }
+    return refs;
+  }
+
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    const counts = new Map(names.map(name => [name, 0]));
+    for (const file of this.files.values()) {
+      for (const ref of file.references) {
+        const count = counts.get(ref.symbolName);
+        if (count !== undefined && !ref.isLocal) {
+          counts.set(ref.symbolName, count + 1);
+        }
+      }
+    }
+    return counts;
+  }
+
+  /**
+   * View this mock as a BackgroundIndex for constructor injection.
+   */
+  asBackgroundIndex(): BackgroundIndex {
+    return this as unknown as BackgroundIndex;
+  }
+}
@@ -123,6 +123,10 @@ export class MockIndex implements ISymbolIndex {
     });
   }
 
+  async getReferenceCounts(names: string[]): Promise<Map<string, number>> {
+    return new Map(names.map(name => [name, (this.references.get(name) || []).filter(ref => !ref.isLocal).length]));
+  }
+
   async getFileImports(uri: string): Promise<ImportInfo[]> {
     return this.fileImports.get(uri) || [];
   }
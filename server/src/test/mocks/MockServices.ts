@@ -10,6 +10,7 @@ import { ServerServices, ServerState } from '../../handlers/types.js';
 import { MockIndex } from './MockIndex.js';
 import { Profiler } from '../../profiler/profiler.js';
 import { StatsManager } from '../../index/statsManager.js';
+import { NullLogger } from '../../utils/Logger.js';
 
 /**
  * Create a minimal mock connection for testing.
@@ -82,6 +83,7 @@ export function createMockServices(
     mergedIndex: mergedIndex as any,
     profiler: new Profiler(),
     statsManager: new StatsManager(),
+    logger: new NullLogger(),
 
     workspaceRoot: '/test/workspace'
   };
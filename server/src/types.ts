@@ -9,6 +9,10 @@ export interface SymbolRange {
   startCharacter: number;
   endLine: number;
   endCharacter: number;
+  /** UTF-8 byte offset of the start in the file, see utils/byteOffsets.ts */
+  startOffset?: number;
+  /** UTF-8 byte offset of the end (exclusive) */
+  endOffset?: number;
 }
 
 /**
@@ -27,6 +31,8 @@ export interface CompactRange {
   sc: number;  // startCharacter
   el: number;  // endLine
   ec: number;  // endCharacter
+  so?: number; // startOffset
+  eo?: number; // endOffset
 }
 
 export interface NgRxMetadata {
@@ -36,8 +42,43 @@ export interface NgRxMetadata {
   events?: Record<string, string>; // For action groups: camelCase method -> 'Event String'
 }
 
+/**
+ * Classification of indexed code, see utils/codeTags.ts.
+ */
+export type CodeTag = 'generated' | 'test' | 'mock' | 'example';
+
+export const CODE_TAGS: CodeTag[] = ['generated', 'test', 'mock', 'example'];
+
+/**
+ * Type parameter of a generic declaration: `T comparable`, `K extends string`.
+ */
+export interface TypeParameter {
+  name: string;
+  /** Constraint as written (`comparable`, `~int | ~string`), when declared */
+  constraint?: string;
+}
+
+/**
+ * Size and complexity of a function or method body, see
+ * utils/functionMetrics.ts.
+ */
+export interface FunctionMetrics {
+  /** Cyclomatic complexity: 1 + branches, loops, cases, catches and && / || */
+  complexity: number;
+  /** Lines with code, without blank and comment-only lines */
+  loc: number;
+  /** Deepest nesting of control-flow blocks (0 for straight-line code) */
+  nesting: number;
+}
+
 export interface IndexedSymbol {
   id: string; // stable symbol identifier
+  /**
+   * Declarations: package path + receiver/container + name (+ signature
+   * hash for callables), independent of checkout location and position.
+   * See indexer/canonicalIds.ts.
+   */
+  canonicalId?: string;
   name: string;
   kind: string;
   location: SymbolLocation;
@@ -62,6 +103,22 @@ export interface IndexedSymbol {
   implements?: string[];
   /** Name of the base class this class extends */
   extends?: string;
+  /** Classifications of the symbol (its file's tags plus symbol-level ones like Go examples) */
+  tags?: CodeTag[];
+  /** Doc comment text without comment markers, see utils/docComments.ts */
+  doc?: string;
+  /** Deprecation notice from the doc comment (`Deprecated:`, `@deprecated`); '' when it gives no reason */
+  deprecated?: string;
+  /** Functions and methods: parameters and results as written, e.g. `func(p *Person) string` */
+  signature?: string;
+  /** Generic declarations: type parameters with their constraints */
+  typeParameters?: TypeParameter[];
+  /** Constants: the value (evaluated for Go, e.g. iota) or its literal, e.g. `5` */
+  value?: string;
+  /** Functions and methods: complexity and size */
+  metrics?: FunctionMetrics;
+  /** Closures: variables of the enclosing functions used in the body */
+  captures?: string[];
 }
 
 /**
@@ -86,6 +143,15 @@ export interface CompactSymbol {
   e?: boolean; // isExported
   im?: string[]; // implements
   ex?: string;   // extends
+  tg?: CodeTag[]; // tags
+  dc?: string;    // doc
+  dp?: string;    // deprecated
+  sg?: string;    // signature
+  tp?: TypeParameter[]; // typeParameters
+  vl?: string;    // value
+  ci?: string;    // canonicalId
+  mt?: FunctionMetrics; // metrics
+  cv?: string[];  // captures
 }
 
 export interface IndexedReference {
@@ -96,6 +162,7 @@ export interface IndexedReference {
   isImport?: boolean; // true if part of import declaration
   scopeId?: string; // lexical scope identifier for local variable filtering
   isLocal?: boolean; // true if reference is to a local variable/parameter
+  isCall?: boolean; // true if reference is the callee of a call/new expression
 }
 
 /**
@@ -110,6 +177,7 @@ export interface CompactReference {
   im?: boolean; // isImport
   si?: number;  // scopeIndex (into shard's scope table)
   lo?: boolean; // isLocal
+  ca?: boolean; // isCall
 }
 
 /**
@@ -159,11 +227,23 @@ export interface IndexedFileResult {
   imports: ImportInfo[];
   reExports?: ReExportInfo[];
   pendingReferences?: PendingReference[]; // Cross-file references to resolve post-indexing
+  tags?: CodeTag[]; // file classifications (generated, test, mock, example)
+  diagnostics?: ParseDiagnostic[]; // syntax errors the indexer recovered from; results may be partial
   shardVersion?: number; // version of the shard format
   isSkipped?: boolean; // true if file was skipped due to read error or malformed path
   skipReason?: string; // reason why file was skipped
 }
 
+/**
+ * A syntax error found while indexing a file. Indexers recover from it and
+ * keep the declarations they could still read.
+ */
+export interface ParseDiagnostic {
+  message: string;
+  line: number; // 0-based
+  character: number; // 0-based
+}
+
 export interface FileInfo {
   uri: string;
   hash: string;
@@ -178,7 +258,7 @@ export interface Metadata {
 }
 
 // Bump when storage format changes - forces re-indexing
-export const SHARD_VERSION = 4;
+export const SHARD_VERSION = 19;
 
 /**
  * Compact shard format for storage - significantly smaller than full format
@@ -193,12 +273,44 @@ export interface CompactShard {
   i: ImportInfo[];         // imports (already compact)
   re?: ReExportInfo[];     // reExports
   pr?: CompactPendingReference[]; // pendingReferences
+  tg?: CodeTag[];          // tags
+  dg?: ParseDiagnostic[];  // diagnostics
   sc?: string[];           // scope table (for reference scopeIndex)
   t: number;   // lastIndexedAt
   v: number;   // shardVersion
   m?: number;  // mtime
 }
 
+/**
+ * Convert a range to compact storage format
+ */
+export function compactRange(range: SymbolRange): CompactRange {
+  const compact: CompactRange = {
+    sl: range.startLine,
+    sc: range.startCharacter,
+    el: range.endLine,
+    ec: range.endCharacter
+  };
+  if (range.startOffset !== undefined) { compact.so = range.startOffset; }
+  if (range.endOffset !== undefined) { compact.eo = range.endOffset; }
+  return compact;
+}
+
+/**
+ * Hydrate a compact range
+ */
+export function hydrateRange(compact: CompactRange): SymbolRange {
+  const range: SymbolRange = {
+    startLine: compact.sl,
+    startCharacter: compact.sc,
+    endLine: compact.el,
+    endCharacter: compact.ec
+  };
+  if (compact.so !== undefined) { range.startOffset = compact.so; }
+  if (compact.eo !== undefined) { range.endOffset = compact.eo; }
+  return range;
+}
+
 /**
  * Convert full IndexedSymbol to compact storage format
  */
@@ -208,12 +320,7 @@ export function compactSymbol(sym: IndexedSymbol): CompactSymbol {
     n: sym.name,
     k: sym.kind,
     p: { l: sym.location.line, c: sym.location.character },
-    r: {
-      sl: sym.range.startLine,
-      sc: sym.range.startCharacter,
-      el: sym.range.endLine,
-      ec: sym.range.endCharacter
-    }
+    r: compactRange(sym.range)
   };
   if (sym.containerName) { compact.cn = sym.containerName; }
   if (sym.containerKind) { compact.ck = sym.containerKind; }
@@ -229,6 +336,15 @@ export function compactSymbol(sym: IndexedSymbol): CompactSymbol {
   if (sym.isExported) { compact.e = sym.isExported; }
   if (sym.implements && sym.implements.length > 0) { compact.im = sym.implements; }
   if (sym.extends) { compact.ex = sym.extends; }
+  if (sym.tags && sym.tags.length > 0) { compact.tg = sym.tags; }
+  if (sym.doc) { compact.dc = sym.doc; }
+  if (sym.deprecated !== undefined) { compact.dp = sym.deprecated; }
+  if (sym.signature) { compact.sg = sym.signature; }
+  if (sym.typeParameters && sym.typeParameters.length > 0) { compact.tp = sym.typeParameters; }
+  if (sym.value !== undefined) { compact.vl = sym.value; }
+  if (sym.canonicalId) { compact.ci = sym.canonicalId; }
+  if (sym.metrics) { compact.mt = sym.metrics; }
+  if (sym.captures && sym.captures.length > 0) { compact.cv = sym.captures; }
   return compact;
 }
 
@@ -241,12 +357,7 @@ export function hydrateSymbol(compact: CompactSymbol, uri: string): IndexedSymbo
     name: compact.n,
     kind: compact.k,
     location: { uri, line: compact.p.l, character: compact.p.c },
-    range: {
-      startLine: compact.r.sl,
-      startCharacter: compact.r.sc,
-      endLine: compact.r.el,
-      endCharacter: compact.r.ec
-    },
+    range: hydrateRange(compact.r),
     containerName: compact.cn,
     containerKind: compact.ck,
     fullContainerPath: compact.cp,
@@ -259,7 +370,16 @@ export function hydrateSymbol(compact: CompactSymbol, uri: string): IndexedSymbo
     visibility: compact.v === undefined ? undefined : (compact.v === 0 ? 'public' : (compact.v === 1 ? 'protected' : 'private')),
     isExported: compact.e,
     implements: compact.im,
-    extends: compact.ex
+    extends: compact.ex,
+    tags: compact.tg,
+    doc: compact.dc,
+    deprecated: compact.dp,
+    signature: compact.sg,
+    typeParameters: compact.tp,
+    value: compact.vl,
+    canonicalId: compact.ci,
+    metrics: compact.mt,
+    captures: compact.cv
   };
 }
 
@@ -270,12 +390,7 @@ export function compactReference(ref: IndexedReference, scopeTable: Map<string,
   const compact: CompactReference = {
     sn: ref.symbolName,
     p: { l: ref.location.line, c: ref.location.character },
-    r: {
-      sl: ref.range.startLine,
-      sc: ref.range.startCharacter,
-      el: ref.range.endLine,
-      ec: ref.range.endCharacter
-    }
+    r: compactRange(ref.range)
   };
   if (ref.containerName) { compact.cn = ref.containerName; }
   if (ref.isImport) { compact.im = ref.isImport; }
@@ -289,6 +404,7 @@ export function compactReference(ref: IndexedReference, scopeTable: Map<string,
     compact.si = idx;
   }
   if (ref.isLocal) { compact.lo = ref.isLocal; }
+  if (ref.isCall) { compact.ca = ref.isCall; }
   return compact;
 }
 
@@ -299,16 +415,12 @@ export function hydrateReference(compact: CompactReference, uri: string, scopeTa
   return {
     symbolName: compact.sn,
     location: { uri, line: compact.p.l, character: compact.p.c },
-    range: {
-      startLine: compact.r.sl,
-      startCharacter: compact.r.sc,
-      endLine: compact.r.el,
-      endCharacter: compact.r.ec
-    },
+    range: hydrateRange(compact.r),
     containerName: compact.cn,
     isImport: compact.im,
     scopeId: compact.si !== undefined ? scopeTable[compact.si] : undefined,
-    isLocal: compact.lo
+    isLocal: compact.lo,
+    isCall: compact.ca
   };
 }
 
@@ -320,12 +432,7 @@ export function compactPendingRef(ref: PendingReference): CompactPendingReferenc
     ct: ref.container,
     mb: ref.member,
     p: { l: ref.location.line, c: ref.location.character },
-    r: {
-      sl: ref.range.startLine,
-      sc: ref.range.startCharacter,
-      el: ref.range.endLine,
-      ec: ref.range.endCharacter
-    }
+    r: compactRange(ref.range)
   };
   if (ref.containerName) { compact.cn = ref.containerName; }
   return compact;
@@ -339,12 +446,7 @@ export function hydratePendingRef(compact: CompactPendingReference, uri: string)
     container: compact.ct,
     member: compact.mb,
     location: { uri, line: compact.p.l, character: compact.p.c },
-    range: {
-      startLine: compact.r.sl,
-      startCharacter: compact.r.sc,
-      endLine: compact.r.el,
-      endCharacter: compact.r.ec
-    },
+    range: hydrateRange(compact.r),
     containerName: compact.cn
   };
 }
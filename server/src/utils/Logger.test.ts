@@ -0,0 +1,69 @@
+/**
+ * Logger Tests
+ *
+ * Verifies level specs, per-scope levels and the text and JSON console
+ * formats.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { LoggerService, LogLevel, parseLogLevels } from './Logger.js';
+
+describe('parseLogLevels', () => {
+  it('should read an overall level and per-scope levels', () => {
+    expect(parseLogLevels('warn')).toEqual({ level: LogLevel.WARN, scopes: {} });
+    expect(parseLogLevels(' parser = DEBUG, info ,store=error')).toEqual({
+      level: LogLevel.INFO,
+      scopes: { parser: LogLevel.DEBUG, store: LogLevel.ERROR }
+    });
+    expect(parseLogLevels('')).toEqual({ level: LogLevel.INFO, scopes: {} });
+  });
+
+  it('should reject unknown levels and missing scopes', () => {
+    expect(() => parseLogLevels('verbose')).toThrow('Unknown log level "verbose"');
+    expect(() => parseLogLevels('info,=debug')).toThrow('Missing scope');
+  });
+});
+
+describe('LoggerService', () => {
+  let lines: string[];
+  let logger: LoggerService;
+
+  beforeEach(() => {
+    lines = [];
+    logger = new LoggerService(line => lines.push(line));
+  });
+
+  it('should filter each scope by its own level', () => {
+    logger.setLevels(parseLogLevels('warn,parser=debug'));
+    const parser = logger.scope('parser');
+    const store = logger.scope('store');
+
+    parser.debug('parsing a.go');
+    store.info('saved');
+    store.warn('slow write');
+    logger.info('root info');
+
+    expect(lines.map(line => line.replace(/^\[[\d:]+\] /, ''))).toEqual([
+      '[DEBUG] parsing a.go',
+      '[WARN ] slow write'
+    ]);
+
+    store.setLevel(LogLevel.INFO);
+    store.info('saved again');
+    expect(lines[2]).toContain('saved again');
+  });
+
+  it('should write JSON entries with their scope', () => {
+    logger.setFormat('json');
+    logger.scope('walker').info('scan complete', 3);
+    logger.scope('server').error('request failed', new Error('boom'));
+    logger.info('unscoped');
+
+    const [scan, failure, unscoped] = lines.map(line => JSON.parse(line));
+    expect(scan).toMatchObject({ level: 'INFO', scope: 'walker', message: 'scan complete', metadata: { args: ['3'] } });
+    expect(failure).toMatchObject({ level: 'ERROR', scope: 'server', message: 'request failed: boom' });
+    expect(failure.stack).toContain('Error: boom');
+    expect(unscoped.scope).toBeUndefined();
+    expect(typeof scan.timestamp).toBe('string');
+  });
+});
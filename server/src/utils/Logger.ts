@@ -13,13 +13,39 @@ export enum LogLevel {
   PERF = 4  // Performance measurements
 }
 
+/**
+ * Console output: `[HH:MM:SS] [LEVEL] message` lines, or one JSON entry per line.
+ */
+export type LogFormat = 'text' | 'json';
+
+export const LOG_FORMATS: LogFormat[] = ['text', 'json'];
+
+/**
+ * Subsystems logging under their own scope: file walking and watching,
+ * parsing in workers and extractors, storage, the background index, and
+ * request handling (LSP and the query server).
+ */
+export const LOG_SCOPES = ['walker', 'parser', 'store', 'index', 'server'];
+
+/**
+ * Minimum level overall and per scope, as parsed from `info,parser=debug`.
+ */
+export interface LogLevels {
+  level: LogLevel;
+  scopes: Record<string, LogLevel>;
+}
+
+/** Receives each formatted console line; lets embedders log without an LSP connection */
+export type LogWriter = (line: string) => void;
+
 /**
  * Structured log entry for JSONL format
  */
 interface LogEntry {
   timestamp: string;
   level: string;
-  component?: string;
+  /** Subsystem that logged it, for scoped loggers */
+  scope?: string;
   message: string;
   metadata?: Record<string, any>;
   stack?: string;
@@ -37,14 +63,61 @@ export interface ILogger {
   perf(component: string, operation: string, durationMs: number, metadata?: Record<string, any>): void;
   measure<T>(component: string, operation: string, fn: () => Promise<T>, metadata?: Record<string, any>): Promise<T>;
   setLevel(level: LogLevel): void;
+  /**
+   * A logger for one subsystem (see LOG_SCOPES): its entries carry the
+   * scope, and its level can be set apart from the rest.
+   */
+  scope(name: string): ILogger;
+}
+
+const LEVEL_NAMES: Record<string, LogLevel> = {
+  debug: LogLevel.DEBUG,
+  info: LogLevel.INFO,
+  warn: LogLevel.WARN,
+  warning: LogLevel.WARN,
+  error: LogLevel.ERROR
+};
+
+/**
+ * A level by name: debug, info, warn or error.
+ */
+export function parseLogLevel(name: string): LogLevel {
+  const level = LEVEL_NAMES[name.trim().toLowerCase()];
+  if (level === undefined) {
+    throw new Error(`Unknown log level "${name}" (expected debug, info, warn or error)`);
+  }
+  return level;
+}
+
+/**
+ * Levels from a spec like `info,parser=debug,store=warn`: an overall
+ * level and per-scope ones, in any order. Throws on unknown level names.
+ */
+export function parseLogLevels(spec: string): LogLevels {
+  const levels: LogLevels = { level: LogLevel.INFO, scopes: {} };
+  for (const part of spec.split(',').map(p => p.trim()).filter(p => p !== '')) {
+    const eq = part.indexOf('=');
+    if (eq === -1) {
+      levels.level = parseLogLevel(part);
+    } else {
+      const scope = part.slice(0, eq).trim();
+      if (!scope) {
+        throw new Error(`Missing scope before "=" in "${part}"`);
+      }
+      levels.scopes[scope] = parseLogLevel(part.slice(eq + 1));
+    }
+  }
+  return levels;
 }
 
 /**
  * Unified logging service for the Smart Indexer server.
  * 
  * Features:
- * - Dual transport: VS Code output channel + rolling log files
+ * - Dual transport: VS Code output channel (or any LogWriter) + rolling log files
  * - JSONL structured logs for post-mortem analysis
+ * - Per-subsystem scopes with their own levels (`scope('parser')`)
+ * - Text or JSON console output
  * - Performance measurement helpers
  * - Automatic log rotation (keeps last 7 days)
  * - Buffered file writes for performance
@@ -54,11 +127,14 @@ export interface ILogger {
  * this.logger.info('Indexing started');
  * this.logger.error('Failed to parse file', parseError);
  * await this.logger.measure('SqlJsStorage', 'FTS5 Query', async () => db.exec(...));
+ * const parserLogger = this.logger.scope('parser');
  * ```
  */
 export class LoggerService implements ILogger {
-  private connection: Connection;
+  private write: LogWriter;
   private currentLevel: LogLevel = LogLevel.INFO;
+  private scopeLevels: Map<string, LogLevel> = new Map();
+  private format: LogFormat = 'text';
   private logFilePath: string = '';
   private logBuffer: LogEntry[] = [];
   private flushTimer: NodeJS.Timeout | null = null;
@@ -66,8 +142,11 @@ export class LoggerService implements ILogger {
   private readonly flushIntervalMs = 5000;
   private workspaceRoot: string = '';
 
-  constructor(connection: Connection, level: LogLevel = LogLevel.INFO) {
-    this.connection = connection;
+  /**
+   * @param output - LSP connection whose console receives the log, or a writer of lines
+   */
+  constructor(output: Connection | LogWriter, level: LogLevel = LogLevel.INFO) {
+    this.write = typeof output === 'function' ? output : line => output.console.log(line);
     this.currentLevel = level;
   }
 
@@ -127,38 +206,83 @@ export class LoggerService implements ILogger {
     this.currentLevel = level;
   }
 
+  /**
+   * Set the level of one scope; undefined makes it follow the overall level.
+   */
+  setScopeLevel(scope: string, level: LogLevel | undefined): void {
+    if (level === undefined) {
+      this.scopeLevels.delete(scope);
+    } else {
+      this.scopeLevels.set(scope, level);
+    }
+  }
+
+  /**
+   * Apply parsed levels: the overall level, and exactly these scope levels.
+   */
+  setLevels(levels: LogLevels): void {
+    this.currentLevel = levels.level;
+    this.scopeLevels = new Map(Object.entries(levels.scopes));
+  }
+
+  setFormat(format: LogFormat): void {
+    this.format = format;
+  }
+
+  scope(name: string): ILogger {
+    return new ScopedLogger(this, name);
+  }
+
+  /**
+   * Whether entries of this level (and scope) are logged.
+   */
+  isEnabled(level: LogLevel, scope?: string): boolean {
+    const threshold = scope !== undefined ? this.scopeLevels.get(scope) ?? this.currentLevel : this.currentLevel;
+    return threshold <= level;
+  }
+
   /**
    * Log a debug message (verbose, typically disabled in production).
    */
   debug(message: string, ...args: any[]): void {
-    if (this.currentLevel <= LogLevel.DEBUG) {
-      this.log('DEBUG', message, args);
-    }
+    this.logAt(LogLevel.DEBUG, message, args);
   }
 
   /**
    * Log an informational message.
    */
   info(message: string, ...args: any[]): void {
-    if (this.currentLevel <= LogLevel.INFO) {
-      this.log('INFO', message, args);
-    }
+    this.logAt(LogLevel.INFO, message, args);
   }
 
   /**
    * Log a warning message.
    */
   warn(message: string, ...args: any[]): void {
-    if (this.currentLevel <= LogLevel.WARN) {
-      this.log('WARN', message, args);
-    }
+    this.logAt(LogLevel.WARN, message, args);
   }
 
   /**
    * Log an error message with optional error object.
    */
   error(message: string, error?: any): void {
-    if (this.currentLevel <= LogLevel.ERROR) {
+    this.errorIn(undefined, message, error);
+  }
+
+  /**
+   * Log a debug, info or warn message for a scope; used by scoped loggers.
+   */
+  logAt(level: LogLevel, message: string, args: any[], scope?: string): void {
+    if (this.isEnabled(level, scope)) {
+      this.log(LogLevel[level], message, args, scope);
+    }
+  }
+
+  /**
+   * Log an error for a scope; used by scoped loggers.
+   */
+  errorIn(scope: string | undefined, message: string, error?: any): void {
+    if (this.isEnabled(LogLevel.ERROR, scope)) {
       let fullMessage = message;
       let stack: string | undefined;
       
@@ -173,16 +297,16 @@ export class LoggerService implements ILogger {
         }
       }
       
-      this.logStructured('ERROR', fullMessage, undefined, { stack });
+      this.logStructured('ERROR', fullMessage, undefined, { stack, scope });
     }
   }
 
   /**
    * Log performance measurement
    */
-  perf(component: string, operation: string, durationMs: number, metadata?: Record<string, any>): void {
+  perf(component: string, operation: string, durationMs: number, metadata?: Record<string, any>, scope?: string): void {
     const message = `[${component}] ${operation}`;
-    this.logStructured('PERF', message, metadata, { duration: durationMs });
+    this.logStructured('PERF', message, metadata, { duration: durationMs, scope });
   }
 
   /**
@@ -194,17 +318,7 @@ export class LoggerService implements ILogger {
     fn: () => Promise<T>,
     metadata?: Record<string, any>
   ): Promise<T> {
-    const start = performance.now();
-    try {
-      const result = await fn();
-      const duration = performance.now() - start;
-      this.perf(component, operation, duration, metadata);
-      return result;
-    } catch (error: any) {
-      const duration = performance.now() - start;
-      this.error(`[${component}] ${operation} failed after ${duration.toFixed(2)}ms`, error);
-      throw error;
-    }
+    return measureWith(this, component, operation, fn, metadata);
   }
 
   /**
@@ -281,21 +395,24 @@ export class LoggerService implements ILogger {
   /**
    * Internal structured logging method
    */
-  private logStructured(level: string, message: string, metadata?: Record<string, any>, extra?: { stack?: string; duration?: number }): void {
+  private logStructured(
+    level: string,
+    message: string,
+    metadata?: Record<string, any>,
+    extra?: { stack?: string; duration?: number; scope?: string }
+  ): void {
     const entry: LogEntry = {
       timestamp: new Date().toISOString(),
       level,
+      ...(extra?.scope && { scope: extra.scope }),
       message,
       metadata,
       ...(extra?.stack && { stack: extra.stack }),
       ...(extra?.duration !== undefined && { duration: extra.duration })
     };
 
-    // Transport 1: VS Code Output Channel (human-readable, INFO+)
-    if (level !== 'DEBUG') {
-      const consoleMsg = this.formatForConsole(entry);
-      this.connection.console.log(consoleMsg);
-    }
+    // Transport 1: VS Code Output Channel (human-readable or JSON)
+    this.write(this.format === 'json' ? JSON.stringify(entry) : this.formatForConsole(entry));
 
     // Transport 2: File (JSONL, all levels)
     if (this.logFilePath) {
@@ -334,9 +451,9 @@ export class LoggerService implements ILogger {
   /**
    * Internal logging method that formats and sends messages to the connection.
    */
-  private log(level: string, message: string, args: any[]): void {
+  private log(level: string, message: string, args: any[], scope?: string): void {
     const metadata = args.length > 0 ? { args: args.map(a => this.stringify(a)) } : undefined;
-    this.logStructured(level, message, metadata);
+    this.logStructured(level, message, metadata, { scope });
   }
 
   /**
@@ -364,6 +481,64 @@ export class LoggerService implements ILogger {
   }
 }
 
+/**
+ * A LoggerService view for one scope; level and format are the service's.
+ */
+class ScopedLogger implements ILogger {
+  constructor(private root: LoggerService, private name: string) {}
+
+  debug(message: string, ...args: any[]): void {
+    this.root.logAt(LogLevel.DEBUG, message, args, this.name);
+  }
+
+  info(message: string, ...args: any[]): void {
+    this.root.logAt(LogLevel.INFO, message, args, this.name);
+  }
+
+  warn(message: string, ...args: any[]): void {
+    this.root.logAt(LogLevel.WARN, message, args, this.name);
+  }
+
+  error(message: string, error?: any): void {
+    this.root.errorIn(this.name, message, error);
+  }
+
+  perf(component: string, operation: string, durationMs: number, metadata?: Record<string, any>): void {
+    this.root.perf(component, operation, durationMs, metadata, this.name);
+  }
+
+  async measure<T>(component: string, operation: string, fn: () => Promise<T>, metadata?: Record<string, any>): Promise<T> {
+    return measureWith(this, component, operation, fn, metadata);
+  }
+
+  setLevel(level: LogLevel): void {
+    this.root.setScopeLevel(this.name, level);
+  }
+
+  scope(name: string): ILogger {
+    return this.root.scope(name);
+  }
+}
+
+async function measureWith<T>(
+  logger: ILogger,
+  component: string,
+  operation: string,
+  fn: () => Promise<T>,
+  metadata?: Record<string, any>
+): Promise<T> {
+  const start = performance.now();
+  try {
+    const result = await fn();
+    logger.perf(component, operation, performance.now() - start, metadata);
+    return result;
+  } catch (error: any) {
+    const duration = performance.now() - start;
+    logger.error(`[${component}] ${operation} failed after ${duration.toFixed(2)}ms`, error);
+    throw error;
+  }
+}
+
 /**
  * Null logger for testing or disabled logging scenarios.
  */
@@ -377,4 +552,7 @@ export class NullLogger implements ILogger {
     return fn();
   }
   setLevel(): void {}
+  scope(): ILogger {
+    return this;
+  }
 }
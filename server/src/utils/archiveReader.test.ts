@@ -0,0 +1,121 @@
+/**
+ * Archive Reader Tests
+ *
+ * Reads zip, tar and tar.gz archives made by the system tools, from files
+ * and from streams, and checks long names, filtering and unsafe paths.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { execFileSync } from 'child_process';
+import { Readable } from 'stream';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { archiveFormatOf, readArchive, stripArchiveExtension } from './archiveReader.js';
+
+describe('readArchive', () => {
+  let testDir: string;
+  const longName = `pkg/${'nested-directory/'.repeat(8)}handler.go`;
+
+  beforeEach(() => {
+    testDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-archive-'));
+    const files: Record<string, string> = {
+      'app/main.go': 'package main\n\nfunc main() {}\n',
+      'app/README.md': '# App\n',
+      [`app/${longName}`]: 'package pkg\n\nfunc Handle() {}\n'
+    };
+    for (const [name, content] of Object.entries(files)) {
+      fs.mkdirSync(path.join(testDir, 'src', path.dirname(name)), { recursive: true });
+      fs.writeFileSync(path.join(testDir, 'src', name), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(testDir, { recursive: true, force: true });
+  });
+
+  async function read(source: string | Readable, options: Parameters<typeof readArchive>[1] = {}): Promise<Map<string, string>> {
+    const entries = new Map<string, string>();
+    for await (const entry of readArchive(source, options)) {
+      entries.set(entry.path, entry.content.toString('utf-8'));
+    }
+    return entries;
+  }
+
+  function archive(name: string, ...command: string[]): string {
+    execFileSync(command[0], command.slice(1), { cwd: path.join(testDir, 'src'), stdio: 'ignore' });
+    return path.join(testDir, 'src', name);
+  }
+
+  it('should read tar and tar.gz archives, with long names', async () => {
+    const tar = archive('app.tar', 'tar', '-cf', 'app.tar', 'app');
+    const tgz = archive('app.tar.gz', 'tar', '-czf', 'app.tar.gz', 'app');
+
+    const entries = await read(tar);
+    expect([...entries.keys()].sort()).toEqual(['app/README.md', 'app/main.go', `app/${longName}`]);
+    expect(entries.get('app/main.go')).toBe('package main\n\nfunc main() {}\n');
+    expect(await read(tgz)).toEqual(entries);
+  });
+
+  it('should read zip archives from files and streams', async () => {
+    const zip = archive('app.zip', 'zip', '-qr', 'app.zip', 'app');
+
+    const entries = await read(zip, { stripComponents: 1 });
+    expect(entries.get('main.go')).toBe('package main\n\nfunc main() {}\n');
+    expect(entries.get(longName)).toBe('package pkg\n\nfunc Handle() {}\n');
+    expect(await read(fs.createReadStream(zip), { stripComponents: 1 })).toEqual(entries);
+  });
+
+  it('should reject zip entries larger than their declared size', async () => {
+    fs.writeFileSync(path.join(testDir, 'src', 'app', 'bomb.go'), 'a'.repeat(1 << 20));
+    const zip = archive('bomb.zip', 'zip', '-q', 'bomb.zip', 'app/bomb.go');
+
+    // Declare 1 KB in the central directory, where accept() gets the size from
+    const bytes = fs.readFileSync(zip);
+    const directory = bytes.indexOf(Buffer.from([0x50, 0x4b, 0x01, 0x02]));
+    bytes.writeUInt32LE(1024, directory + 24);
+    fs.writeFileSync(zip, bytes);
+
+    const sizes: number[] = [];
+    await expect(read(zip, { accept: (_entry, size) => sizes.push(size) > 0 })).rejects.toThrow('Invalid zip entry: app/bomb.go');
+    expect(sizes).toEqual([1024]);
+    await expect(read(fs.createReadStream(zip))).rejects.toThrow('Invalid zip entry');
+
+    // A compressed size past the end of the archive is not allocated
+    bytes.writeUInt32LE(0xfffffff0, directory + 20);
+    fs.writeFileSync(zip, bytes);
+    await expect(read(zip)).rejects.toThrow('Truncated zip entry: app/bomb.go');
+  });
+
+  it('should detect the format of a stream and skip entries not accepted', async () => {
+    const tgz = archive('app.tgz', 'tar', '-czf', 'app.tgz', 'app');
+    const sizes: number[] = [];
+
+    const entries = await read(fs.createReadStream(tgz), {
+      accept: (entry, size) => {
+        sizes.push(size);
+        return entry.endsWith('.go');
+      }
+    });
+    expect([...entries.keys()].sort()).toEqual(['app/main.go', `app/${longName}`]);
+    expect(sizes).toContain('# App\n'.length);
+  });
+
+  it('should skip paths that leave the archive', async () => {
+    fs.writeFileSync(path.join(testDir, 'outside.go'), 'package outside\n');
+    const tar = archive('unsafe.tar', 'tar', '-cPf', 'unsafe.tar', '../outside.go', 'app/main.go');
+
+    expect([...(await read(tar)).keys()]).toEqual(['app/main.go']);
+  });
+
+  it('should reject input that is not an archive', async () => {
+    await expect(read(Readable.from([Buffer.from('not an archive')]))).rejects.toThrow('Unknown archive format');
+  });
+
+  it('should name formats and roots by extension', () => {
+    expect(archiveFormatOf('/ci/app.TGZ')).toBe('tar.gz');
+    expect(archiveFormatOf('app.zip')).toBe('zip');
+    expect(archiveFormatOf('app.go')).toBeUndefined();
+    expect(stripArchiveExtension('/ci/app-1.2.tar.gz')).toBe('/ci/app-1.2');
+  });
+});
@@ -0,0 +1,514 @@
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { Readable, pipeline } from 'stream';
+import { promisify } from 'util';
+
+/*
+ * Archive Reader - reads the files of a .zip, .tar or .tar.gz archive
+ * without extracting it, so CI jobs and indexing workers can index the
+ * artifacts they download. Entries come one at a time and only the
+ * accepted ones are read into memory, one file at a time.
+ *
+ * Tar archives (plain or gzipped, as `git archive` writes them) are read
+ * as a stream from start to end. Zip archives keep their directory at the
+ * end: a zip file on disk is read entry by entry through its central
+ * directory, while a zip stream is buffered in memory first. ZIP64 and the
+ * pax and GNU long names of tar are supported; links, devices and
+ * encrypted entries are skipped, as are paths that leave the archive.
+ */
+
+export type ArchiveFormat = 'zip' | 'tar' | 'tar.gz';
+
+export type ArchiveSource = string | Readable | AsyncIterable<Uint8Array>;
+
+export interface ArchiveEntry {
+  /** Path inside the archive, with forward slashes and no leading `./` */
+  path: string;
+  content: Buffer;
+}
+
+export interface ArchiveReadOptions {
+  /** Default: from the file extension, or sniffed from the first bytes */
+  format?: ArchiveFormat;
+  /** Leading path components to drop, like `tar --strip-components` */
+  stripComponents?: number;
+  /** Whether to read an entry; rejected entries are skipped unread */
+  accept?: (entryPath: string, size: number) => boolean;
+}
+
+const inflateRaw = promisify(zlib.inflateRaw);
+
+const TAR_BLOCK = 512;
+const ZIP_LOCAL_HEADER = 0x04034b50;
+const ZIP_CENTRAL_HEADER = 0x02014b50;
+const ZIP_END = 0x06054b50;
+const ZIP64_END = 0x06064b50;
+const ZIP64_LOCATOR = 0x07064b50;
+/** End of central directory record plus the longest comment */
+const ZIP_END_SEARCH = 22 + 0xffff;
+
+/** The format of a file name, or undefined if it is not an archive */
+export function archiveFormatOf(fileName: string): ArchiveFormat | undefined {
+  const name = fileName.toLowerCase();
+  if (name.endsWith('.zip')) {
+    return 'zip';
+  }
+  if (name.endsWith('.tar.gz') || name.endsWith('.tgz')) {
+    return 'tar.gz';
+  }
+  return name.endsWith('.tar') ? 'tar' : undefined;
+}
+
+/** A file name without its archive extension, e.g. `app-1.2` for `app-1.2.tar.gz` */
+export function stripArchiveExtension(fileName: string): string {
+  return fileName.replace(/\.(zip|tar\.gz|tgz|tar)$/i, '');
+}
+
+/** Path of an archive entry when the archive stands in for root */
+export function archiveEntryFile(root: string, entry: string): string {
+  return path.join(root, ...entry.split('/'));
+}
+
+/**
+ * The accepted file entries of an archive, in archive order. Throws on
+ * input that is not an archive of the format, or a truncated one.
+ */
+export async function* readArchive(source: ArchiveSource, options: ArchiveReadOptions = {}): AsyncGenerator<ArchiveEntry> {
+  const accept = options.accept ?? (() => true);
+  const strip = options.stripComponents ?? 0;
+  const format = options.format ?? (typeof source === 'string' ? archiveFormatOf(source) : undefined);
+  const toEntryPath = (name: string) => entryPath(name, strip);
+
+  if (typeof source === 'string' && (format === 'zip' || format === undefined)) {
+    const handle = await fsPromises.open(source, 'r');
+    try {
+      const { size } = await handle.stat();
+      const file: RandomAccess = {
+        size,
+        // Offsets and lengths come from the archive: never read past its end
+        read: async (offset, length) => {
+          length = Math.max(0, Math.min(length, size - offset));
+          const buffer = Buffer.alloc(length);
+          const { bytesRead } = await handle.read(buffer, 0, length, offset);
+          return buffer.subarray(0, bytesRead);
+        }
+      };
+      if (format === 'zip' || await isZip(file)) {
+        yield* readZip(file, toEntryPath, accept);
+        return;
+      }
+    } finally {
+      await handle.close();
+    }
+  }
+
+  const reader = new StreamReader(typeof source === 'string' ? (await fsPromises.open(source, 'r')).createReadStream() : source);
+  try {
+    const head = await reader.peek(TAR_BLOCK);
+    const detected = format ?? sniffFormat(head);
+    if (detected === 'zip') {
+      const buffer = await reader.readAll();
+      yield* readZip({ size: buffer.length, read: async (offset, length) => buffer.subarray(offset, offset + length) }, toEntryPath, accept);
+    } else if (detected === 'tar.gz') {
+      const gunzipped = new StreamReader(pipeline(reader.rest(), zlib.createGunzip(), () => {}));
+      try {
+        yield* readTar(gunzipped, toEntryPath, accept);
+      } finally {
+        gunzipped.close();
+      }
+    } else {
+      yield* readTar(reader, toEntryPath, accept);
+    }
+  } finally {
+    reader.close();
+  }
+}
+
+function sniffFormat(head: Buffer): ArchiveFormat {
+  if (head.length >= 2 && head[0] === 0x1f && head[1] === 0x8b) {
+    return 'tar.gz';
+  }
+  if (head.length >= 4 && head.readUInt32LE(0) === ZIP_LOCAL_HEADER) {
+    return 'zip';
+  }
+  if (head.length >= 262 && head.toString('latin1', 257, 262) === 'ustar') {
+    return 'tar';
+  }
+  throw new Error('Unknown archive format: expected zip, tar or tar.gz');
+}
+
+/**
+ * The entry path to report, or undefined for entries that are stripped
+ * away or lead out of the archive.
+ */
+function entryPath(name: string, stripComponents: number): string | undefined {
+  const parts = name.replace(/\\/g, '/').split('/').filter(part => part !== '' && part !== '.');
+  if (parts.includes('..') || /^[a-zA-Z]:$/.test(parts[0] ?? '')) {
+    return undefined;
+  }
+  const kept = parts.slice(stripComponents);
+  return kept.length > 0 ? kept.join('/') : undefined;
+}
+
+// ---------------------------------------------------------------------------
+// tar
+
+async function* readTar(
+  reader: StreamReader,
+  toEntryPath: (name: string) => string | undefined,
+  accept: (entryPath: string, size: number) => boolean
+): AsyncGenerator<ArchiveEntry> {
+  let longName: string | undefined;
+  let paxPath: string | undefined;
+  for (;;) {
+    const header = await reader.read(TAR_BLOCK);
+    if (header.length === 0 || isZeroBlock(header)) {
+      return;
+    }
+    if (header.length < TAR_BLOCK) {
+      throw new Error('Truncated tar archive');
+    }
+    if (!hasValidChecksum(header)) {
+      throw new Error('Invalid tar header checksum');
+    }
+    const size = tarSize(header);
+    const padded = Math.ceil(size / TAR_BLOCK) * TAR_BLOCK;
+    const type = String.fromCharCode(header[156] || 0x30);
+
+    // Long names apply to the entry that follows them
+    if (type === 'L' || type === 'x') {
+      const data = (await reader.readExactly(padded)).subarray(0, size);
+      if (type === 'L') {
+        longName = data.toString('utf-8').replace(/\0+$/, '');
+      } else {
+        paxPath = paxRecords(data).get('path') ?? paxPath;
+      }
+      continue;
+    }
+
+    const name = paxPath ?? longName ?? tarName(header);
+    longName = undefined;
+    paxPath = undefined;
+    const file = type === '0' || type === '7';
+    const target = file ? toEntryPath(name) : undefined;
+    if (target === undefined || !accept(target, size)) {
+      await reader.skip(padded);
+      continue;
+    }
+    const content = (await reader.readExactly(padded)).subarray(0, size);
+    yield { path: target, content };
+  }
+}
+
+function tarName(header: Buffer): string {
+  const name = cString(header, 0, 100);
+  const prefix = header.toString('latin1', 257, 262) === 'ustar' ? cString(header, 345, 155) : '';
+  return prefix ? `${prefix}/${name}` : name;
+}
+
+function tarSize(header: Buffer): number {
+  // Base-256 for sizes past 8 GB (GNU)
+  if (header[124] & 0x80) {
+    let size = header[124] & 0x7f;
+    for (let i = 125; i < 136; i++) {
+      size = size * 256 + header[i];
+    }
+    return size;
+  }
+  return parseInt(cString(header, 124, 12).trim() || '0', 8);
+}
+
+function hasValidChecksum(header: Buffer): boolean {
+  const expected = parseInt(cString(header, 148, 8).trim(), 8);
+  let sum = 0;
+  for (let i = 0; i < TAR_BLOCK; i++) {
+    sum += i >= 148 && i < 156 ? 0x20 : header[i];
+  }
+  return sum === expected;
+}
+
+function isZeroBlock(block: Buffer): boolean {
+  return block.every(byte => byte === 0);
+}
+
+function cString(buffer: Buffer, offset: number, length: number): string {
+  const end = buffer.indexOf(0, offset);
+  return buffer.toString('utf-8', offset, end >= 0 && end < offset + length ? end : offset + length);
+}
+
+/** Pax extended header records: "<length> <key>=<value>\n" */
+function paxRecords(data: Buffer): Map<string, string> {
+  const records = new Map<string, string>();
+  let offset = 0;
+  while (offset < data.length) {
+    const space = data.indexOf(0x20, offset);
+    const length = parseInt(data.toString('latin1', offset, space), 10);
+    if (space < 0 || !(length > 0)) {
+      break;
+    }
+    const record = data.toString('utf-8', space + 1, offset + length - 1);
+    const equals = record.indexOf('=');
+    if (equals > 0) {
+      records.set(record.substring(0, equals), record.substring(equals + 1));
+    }
+    offset += length;
+  }
+  return records;
+}
+
+// ---------------------------------------------------------------------------
+// zip
+
+interface RandomAccess {
+  size: number;
+  read(offset: number, length: number): Promise<Buffer>;
+}
+
+interface ZipEntry {
+  name: string;
+  flags: number;
+  method: number;
+  compressedSize: number;
+  size: number;
+  localHeaderOffset: number;
+}
+
+async function isZip(file: RandomAccess): Promise<boolean> {
+  const head = await file.read(0, 4);
+  return head.length === 4 && head.readUInt32LE(0) === ZIP_LOCAL_HEADER;
+}
+
+async function* readZip(
+  file: RandomAccess,
+  toEntryPath: (name: string) => string | undefined,
+  accept: (entryPath: string, size: number) => boolean
+): AsyncGenerator<ArchiveEntry> {
+  for (const entry of await zipDirectory(file)) {
+    const target = entry.name.endsWith('/') ? undefined : toEntryPath(entry.name);
+    // Bit 0: encrypted
+    if (target === undefined || (entry.flags & 1) !== 0 || !accept(target, entry.size)) {
+      continue;
+    }
+    const local = await file.read(entry.localHeaderOffset, 30);
+    if (local.length < 30 || local.readUInt32LE(0) !== ZIP_LOCAL_HEADER) {
+      throw new Error(`Invalid zip entry: ${entry.name}`);
+    }
+    const dataOffset = entry.localHeaderOffset + 30 + local.readUInt16LE(26) + local.readUInt16LE(28);
+    const data = await file.read(dataOffset, entry.compressedSize);
+    if (data.length < entry.compressedSize) {
+      throw new Error(`Truncated zip entry: ${entry.name}`);
+    }
+    // accept() saw the declared size: content must not be larger, so
+    // inflating stops there instead of running out of memory on a zip bomb
+    if (entry.method === 0) {
+      if (data.length !== entry.size) {
+        throw new Error(`Invalid zip entry: ${entry.name} does not have its declared size`);
+      }
+      yield { path: target, content: data };
+    } else if (entry.method === 8) {
+      let content: Buffer;
+      try {
+        content = await inflateRaw(data, { maxOutputLength: Math.max(entry.size, 1) });
+      } catch (error) {
+        throw new Error(`Invalid zip entry: ${entry.name}: ${error instanceof Error ? error.message : String(error)}`);
+      }
+      if (content.length !== entry.size) {
+        throw new Error(`Invalid zip entry: ${entry.name} does not have its declared size`);
+      }
+      yield { path: target, content };
+    }
+    // Other compression methods (bzip2, LZMA, ...) are skipped
+  }
+}
+
+async function zipDirectory(file: RandomAccess): Promise<ZipEntry[]> {
+  const tailStart = Math.max(0, file.size - ZIP_END_SEARCH);
+  const tail = await file.read(tailStart, file.size - tailStart);
+  let end = -1;
+  for (let i = tail.length - 22; i >= 0; i--) {
+    if (tail.readUInt32LE(i) === ZIP_END) {
+      end = i;
+      break;
+    }
+  }
+  if (end < 0) {
+    throw new Error('Invalid zip archive: no central directory');
+  }
+
+  let count = tail.readUInt16LE(end + 10);
+  let directorySize = tail.readUInt32LE(end + 12);
+  let directoryOffset = tail.readUInt32LE(end + 16);
+  const locator = end - 20;
+  if (locator >= 0 && tail.readUInt32LE(locator) === ZIP64_LOCATOR) {
+    const record = await file.read(Number(tail.readBigUInt64LE(locator + 8)), 56);
+    if (record.length === 56 && record.readUInt32LE(0) === ZIP64_END) {
+      count = Number(record.readBigUInt64LE(32));
+      directorySize = Number(record.readBigUInt64LE(40));
+      directoryOffset = Number(record.readBigUInt64LE(48));
+    }
+  }
+
+  const directory = await file.read(directoryOffset, directorySize);
+  const entries: ZipEntry[] = [];
+  let offset = 0;
+  for (let i = 0; i < count; i++) {
+    if (offset + 46 > directory.length || directory.readUInt32LE(offset) !== ZIP_CENTRAL_HEADER) {
+      throw new Error('Invalid zip archive: corrupt central directory');
+    }
+    const nameLength = directory.readUInt16LE(offset + 28);
+    const extraLength = directory.readUInt16LE(offset + 30);
+    const commentLength = directory.readUInt16LE(offset + 32);
+    const entry: ZipEntry = {
+      name: directory.toString('utf-8', offset + 46, offset + 46 + nameLength),
+      flags: directory.readUInt16LE(offset + 8),
+      method: directory.readUInt16LE(offset + 10),
+      compressedSize: directory.readUInt32LE(offset + 20),
+      size: directory.readUInt32LE(offset + 24),
+      localHeaderOffset: directory.readUInt32LE(offset + 42)
+    };
+    applyZip64Extra(entry, directory.subarray(offset + 46 + nameLength, offset + 46 + nameLength + extraLength));
+    entries.push(entry);
+    offset += 46 + nameLength + extraLength + commentLength;
+  }
+  return entries;
+}
+
+/** Sizes and offsets too large for 32 bits are in the ZIP64 extra field, in this order */
+function applyZip64Extra(entry: ZipEntry, extra: Buffer): void {
+  for (let offset = 0; offset + 4 <= extra.length;) {
+    const id = extra.readUInt16LE(offset);
+    const length = extra.readUInt16LE(offset + 2);
+    if (id === 0x0001) {
+      let field = offset + 4;
+      const next = (): number | undefined => {
+        if (field + 8 > offset + 4 + length) {
+          return undefined;
+        }
+        const value = Number(extra.readBigUInt64LE(field));
+        field += 8;
+        return value;
+      };
+      if (entry.size === 0xffffffff) {
+        entry.size = next() ?? entry.size;
+      }
+      if (entry.compressedSize === 0xffffffff) {
+        entry.compressedSize = next() ?? entry.compressedSize;
+      }
+      if (entry.localHeaderOffset === 0xffffffff) {
+        entry.localHeaderOffset = next() ?? entry.localHeaderOffset;
+      }
+      return;
+    }
+    offset += 4 + length;
+  }
+}
+
+// ---------------------------------------------------------------------------
+// streams
+
+/**
+ * Reads a byte stream in pieces of a given length, buffering only what a
+ * piece needs beyond the chunk at hand.
+ */
+class StreamReader {
+  private iterator: AsyncIterator<Uint8Array>;
+  private chunks: Buffer[] = [];
+  private buffered = 0;
+  private done = false;
+
+  constructor(private source: Readable | AsyncIterable<Uint8Array>) {
+    this.iterator = source[Symbol.asyncIterator]();
+  }
+
+  /** The next bytes, up to length, left to be read again */
+  async peek(length: number): Promise<Buffer> {
+    await this.fill(length);
+    return Buffer.concat(this.chunks).subarray(0, length);
+  }
+
+  /** Up to length bytes; fewer only at the end of the stream */
+  async read(length: number): Promise<Buffer> {
+    await this.fill(length);
+    return this.take(Math.min(length, this.buffered));
+  }
+
+  async readExactly(length: number): Promise<Buffer> {
+    const data = await this.read(length);
+    if (data.length < length) {
+      throw new Error('Unexpected end of archive');
+    }
+    return data;
+  }
+
+  async skip(length: number): Promise<void> {
+    let left = length;
+    while (left > 0) {
+      if (this.buffered === 0 && !await this.pull()) {
+        throw new Error('Unexpected end of archive');
+      }
+      left -= this.take(Math.min(left, this.buffered)).length;
+    }
+  }
+
+  async readAll(): Promise<Buffer> {
+    while (await this.pull()) {
+      // Keep reading
+    }
+    return this.take(this.buffered);
+  }
+
+  /** What is left of the stream, starting with the buffered bytes */
+  rest(): Readable {
+    return Readable.from(this.remaining());
+  }
+
+  close(): void {
+    if (!this.done) {
+      this.done = true;
+      void this.iterator.return?.();
+    }
+    if (this.source instanceof Readable) {
+      this.source.destroy();
+    }
+  }
+
+  private async *remaining(): AsyncGenerator<Buffer> {
+    if (this.buffered > 0) {
+      yield this.take(this.buffered);
+    }
+    while (await this.pull()) {
+      yield this.take(this.buffered);
+    }
+  }
+
+  private async fill(length: number): Promise<void> {
+    while (this.buffered < length && await this.pull()) {
+      // Keep reading
+    }
+  }
+
+  private async pull(): Promise<boolean> {
+    if (this.done) {
+      return false;
+    }
+    const next = await this.iterator.next();
+    if (next.done) {
+      this.done = true;
+      return false;
+    }
+    const chunk = Buffer.from(next.value);
+    this.chunks.push(chunk);
+    this.buffered += chunk.length;
+    return true;
+  }
+
+  private take(length: number): Buffer {
+    const data = this.chunks.length === 1 ? this.chunks[0] : Buffer.concat(this.chunks);
+    const taken = data.subarray(0, length);
+    const rest = data.subarray(length);
+    this.chunks = rest.length > 0 ? [rest] : [];
+    this.buffered = rest.length;
+    return taken;
+  }
+}
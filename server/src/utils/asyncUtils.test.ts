@@ -0,0 +1,53 @@
+/**
+ * Async Utilities Tests
+ *
+ * Verifies cancellation token sources, parent tokens and deadlines.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { CancellationError, CancellationTokenSource, throwIfCancelled } from './asyncUtils.js';
+
+describe('CancellationTokenSource', () => {
+  it('should notify listeners once when cancelled', () => {
+    const source = new CancellationTokenSource();
+    let calls = 0;
+    source.token.onCancellationRequested?.(() => calls++);
+    expect(source.token.isCancellationRequested).toBe(false);
+
+    source.cancel();
+    source.cancel();
+    expect(source.token.isCancellationRequested).toBe(true);
+    expect(calls).toBe(1);
+    expect(() => throwIfCancelled(source.token)).toThrow(CancellationError);
+
+    // Late listeners run right away
+    source.token.onCancellationRequested?.(() => calls++);
+    expect(calls).toBe(2);
+  });
+
+  it('should follow a parent token', () => {
+    const parent = new CancellationTokenSource();
+    const child = new CancellationTokenSource(parent.token);
+    const plain = { isCancellationRequested: false };
+    const childOfPlain = new CancellationTokenSource(plain);
+
+    parent.cancel();
+    expect(child.token.isCancellationRequested).toBe(true);
+
+    expect(childOfPlain.token.isCancellationRequested).toBe(false);
+    plain.isCancellationRequested = true;
+    expect(childOfPlain.token.isCancellationRequested).toBe(true);
+  });
+
+  it('should cancel at the deadline unless disposed', async () => {
+    const expiring = new CancellationTokenSource();
+    const disposed = new CancellationTokenSource();
+    expiring.cancelAfter(5);
+    disposed.cancelAfter(5);
+    disposed.dispose();
+
+    await new Promise(resolve => setTimeout(resolve, 20));
+    expect(expiring.token.isCancellationRequested).toBe(true);
+    expect(disposed.token.isCancellationRequested).toBe(false);
+  });
+});
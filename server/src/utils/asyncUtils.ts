@@ -56,6 +56,86 @@ export function throwIfCancelled(token?: CancellationToken): void {
   }
 }
 
+/**
+ * Source of a cancellation token that is cancelled explicitly, when a
+ * parent token is cancelled, or when a deadline passes.
+ *
+ * @example
+ * const source = new CancellationTokenSource(lspToken);
+ * source.cancelAfter(10 * 60 * 1000);
+ * try {
+ *   await index.ensureUpToDate(files, computeHash, undefined, source.token);
+ * } finally {
+ *   source.dispose();
+ * }
+ */
+export class CancellationTokenSource {
+  private cancelled = false;
+  private listeners: Array<() => void> = [];
+  private timer: NodeJS.Timeout | null = null;
+
+  readonly token: CancellationToken;
+
+  constructor(parent?: CancellationToken) {
+    const isCancelled = () => this.cancelled || (parent?.isCancellationRequested ?? false);
+    this.token = {
+      get isCancellationRequested() {
+        return isCancelled();
+      },
+      onCancellationRequested: callback => {
+        if (isCancelled()) {
+          callback();
+        } else {
+          this.listeners.push(callback);
+        }
+      }
+    };
+    parent?.onCancellationRequested?.(() => this.cancel());
+  }
+
+  cancel(): void {
+    if (this.cancelled) {
+      return;
+    }
+    this.cancelled = true;
+    this.clearTimer();
+    const listeners = this.listeners;
+    this.listeners = [];
+    for (const listener of listeners) {
+      listener();
+    }
+  }
+
+  /**
+   * Cancel once ms milliseconds have passed (replaces an earlier deadline).
+   * A deadline of 0 or less cancels right away.
+   */
+  cancelAfter(ms: number): void {
+    this.clearTimer();
+    if (ms <= 0) {
+      this.cancel();
+      return;
+    }
+    this.timer = setTimeout(() => this.cancel(), ms);
+    this.timer.unref?.();
+  }
+
+  /**
+   * Stop the deadline timer and drop listeners. Does not cancel.
+   */
+  dispose(): void {
+    this.clearTimer();
+    this.listeners = [];
+  }
+
+  private clearTimer(): void {
+    if (this.timer) {
+      clearTimeout(this.timer);
+      this.timer = null;
+    }
+  }
+}
+
 /**
  * Progress callback type for long-running operations.
  */
@@ -0,0 +1,53 @@
+/**
+ * Atomic file writes.
+ *
+ * Data is written to a temporary file next to the destination and renamed
+ * into place, so an interrupted write (crash, kill, cancelled indexing run)
+ * leaves either the old file or the new one, never a truncated mix.
+ */
+
+import * as fsPromises from 'fs/promises';
+
+let tempCounter = 0;
+
+/**
+ * Write data to filePath atomically. The parent directory must exist.
+ */
+export async function writeFileAtomic(filePath: string, data: string | Uint8Array): Promise<void> {
+  const tempPath = `${filePath}.${process.pid}.${tempCounter++}.tmp`;
+  try {
+    await fsPromises.writeFile(tempPath, data);
+    await fsPromises.rename(tempPath, filePath);
+  } catch (error) {
+    await fsPromises.rm(tempPath, { force: true });
+    throw error;
+  }
+}
+
+/**
+ * Like writeFileAtomic, for data produced in chunks (too large to hold in
+ * memory at once). Returns the number of bytes written.
+ */
+export async function writeChunksAtomic(filePath: string, chunks: AsyncIterable<Uint8Array>): Promise<number> {
+  const tempPath = `${filePath}.${process.pid}.${tempCounter++}.tmp`;
+  let bytes = 0;
+  try {
+    const handle = await fsPromises.open(tempPath, 'w');
+    try {
+      for await (const chunk of chunks) {
+        for (let written = 0; written < chunk.length;) {
+          const result = await handle.write(chunk, written, chunk.length - written, bytes + written);
+          written += result.bytesWritten;
+        }
+        bytes += chunk.length;
+      }
+    } finally {
+      await handle.close();
+    }
+    await fsPromises.rename(tempPath, filePath);
+    return bytes;
+  } catch (error) {
+    await fsPromises.rm(tempPath, { force: true });
+    throw error;
+  }
+}
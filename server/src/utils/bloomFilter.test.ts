@@ -0,0 +1,39 @@
+/**
+ * Bloom Filter Tests
+ *
+ * Verifies that added strings are always found and that the false
+ * positive rate stays near the one the filter was sized for.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { BloomFilter } from './bloomFilter.js';
+
+describe('BloomFilter', () => {
+  it('should find every added string and few others', () => {
+    const filter = BloomFilter.create(1000, 0.01);
+    for (let i = 0; i < 1000; i++) {
+      filter.add(`identifier${i}`);
+    }
+
+    for (let i = 0; i < 1000; i++) {
+      expect(filter.has(`identifier${i}`)).toBe(true);
+    }
+    let falsePositives = 0;
+    for (let i = 0; i < 10000; i++) {
+      if (filter.has(`other${i}`)) {
+        falsePositives++;
+      }
+    }
+    expect(falsePositives / 10000).toBeLessThan(0.02);
+    expect(filter.hashes).toBe(7);
+    expect(filter.bits.length).toBe(1199);
+  });
+
+  it('should keep small filters usable', () => {
+    const empty = BloomFilter.create(0);
+    expect(empty.has('anything')).toBe(false);
+    empty.add('');
+    expect(empty.has('')).toBe(true);
+    expect(new BloomFilter(empty.bits, empty.hashes).has('')).toBe(true);
+  });
+});
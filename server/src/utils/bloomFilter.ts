@@ -0,0 +1,64 @@
+/**
+ * Bloom filter over strings.
+ *
+ * `has()` never misses a string that was added; it answers true for a
+ * string that was not added with roughly the false positive rate the
+ * filter was sized for. Bit positions come from two 32-bit hashes
+ * combined (Kirsch-Mitzenmacher), so a lookup reads the string once
+ * whatever the number of hash functions.
+ */
+export class BloomFilter {
+  constructor(
+    /** m bits, m a multiple of 8 */
+    readonly bits: Uint8Array,
+    /** k, the number of bit positions per string */
+    readonly hashes: number
+  ) {}
+
+  /**
+   * An empty filter sized for `expectedItems` strings.
+   */
+  static create(expectedItems: number, falsePositiveRate: number = 0.01): BloomFilter {
+    const items = Math.max(1, expectedItems);
+    const bits = Math.max(64, Math.ceil(-items * Math.log(falsePositiveRate) / (Math.LN2 * Math.LN2)));
+    const bytes = Math.ceil(bits / 8);
+    const hashes = Math.max(1, Math.min(16, Math.round((bytes * 8 / items) * Math.LN2)));
+    return new BloomFilter(new Uint8Array(bytes), hashes);
+  }
+
+  add(value: string): void {
+    const size = this.bits.length * 8;
+    const [h1, h2] = hashPair(value);
+    for (let i = 0; i < this.hashes; i++) {
+      const bit = (h1 + i * h2) % size;
+      this.bits[bit >>> 3] |= 1 << (bit & 7);
+    }
+  }
+
+  has(value: string): boolean {
+    const size = this.bits.length * 8;
+    const [h1, h2] = hashPair(value);
+    for (let i = 0; i < this.hashes; i++) {
+      const bit = (h1 + i * h2) % size;
+      if ((this.bits[bit >>> 3] & (1 << (bit & 7))) === 0) {
+        return false;
+      }
+    }
+    return true;
+  }
+}
+
+/**
+ * Two 32-bit FNV-1a style hashes with different offset bases and primes;
+ * the second is forced odd so the probe sequence does not collapse.
+ */
+function hashPair(value: string): [number, number] {
+  let h1 = 0x811c9dc5;
+  let h2 = 0x5bd1e995;
+  for (let i = 0; i < value.length; i++) {
+    const code = value.charCodeAt(i);
+    h1 = Math.imul(h1 ^ code, 0x01000193);
+    h2 = Math.imul(h2 ^ code, 0x2127599b);
+  }
+  return [h1 >>> 0, (h2 | 1) >>> 0];
+}
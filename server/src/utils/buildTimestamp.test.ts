@@ -0,0 +1,24 @@
+/**
+ * Build Timestamp Tests
+ *
+ * Verifies the build time of index files: now, none when reproducible,
+ * and SOURCE_DATE_EPOCH when set.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { buildTimestamp, SOURCE_DATE_EPOCH_ENV_VAR } from './buildTimestamp.js';
+
+describe('buildTimestamp', () => {
+  it('should record now, or nothing when reproducible', () => {
+    const before = Date.now();
+    expect(buildTimestamp(false, {})).toBeGreaterThanOrEqual(before);
+    expect(buildTimestamp(true, {})).toBe(0);
+  });
+
+  it('should record SOURCE_DATE_EPOCH when it is a number of seconds', () => {
+    expect(buildTimestamp(true, { [SOURCE_DATE_EPOCH_ENV_VAR]: '1700000000' })).toBe(1_700_000_000_000);
+    expect(buildTimestamp(false, { [SOURCE_DATE_EPOCH_ENV_VAR]: ' 1700000000\n' })).toBe(1_700_000_000_000);
+    expect(buildTimestamp(true, { [SOURCE_DATE_EPOCH_ENV_VAR]: '2023-11-14' })).toBe(0);
+    expect(buildTimestamp(true, { [SOURCE_DATE_EPOCH_ENV_VAR]: '' })).toBe(0);
+  });
+});
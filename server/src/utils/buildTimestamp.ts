@@ -0,0 +1,20 @@
+/**
+ * The time index files record as when they were built: saved indexes
+ * (the `lastIndexedAt` of each file and the `indexedAt` of each root)
+ * and the header of .sidx files.
+ *
+ * Builds record the current time by default. Reproducible builds record 0
+ * instead, so two builds of the same tree are byte-identical and CI can
+ * cache and compare them by hash. SOURCE_DATE_EPOCH (seconds, the
+ * reproducible-builds.org convention) records that time in either case.
+ */
+
+export const SOURCE_DATE_EPOCH_ENV_VAR = 'SOURCE_DATE_EPOCH';
+
+export function buildTimestamp(reproducible: boolean = false, env: NodeJS.ProcessEnv = process.env): number {
+  const epoch = env[SOURCE_DATE_EPOCH_ENV_VAR]?.trim();
+  if (epoch && /^\d+$/.test(epoch)) {
+    return Number(epoch) * 1000;
+  }
+  return reproducible ? 0 : Date.now();
+}
@@ -0,0 +1,57 @@
+/**
+ * Byte Offsets Tests
+ *
+ * Verifies UTF-8 byte offsets computed for indexed ranges.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { attachByteOffsets, ByteOffsetMap } from './byteOffsets.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+
+describe('ByteOffsetMap', () => {
+  it('should count UTF-8 bytes on non-ASCII lines', () => {
+    const offsets = new ByteOffsetMap('ab\n// héllo 😀\nx := 1\n');
+
+    expect(offsets.byteOffset(0, 1)).toBe(1);
+    expect(offsets.byteOffset(1, 4)).toBe(7);
+    expect(offsets.byteOffset(1, 5)).toBe(9);
+    // The emoji is 2 UTF-16 units and 4 bytes
+    expect(offsets.byteOffset(1, 11)).toBe(17);
+    expect(offsets.byteOffset(2, 0)).toBe(18);
+    // Past the end of the line clamps to the newline
+    expect(offsets.byteOffset(0, 99)).toBe(2);
+    expect(offsets.byteOffset(9, 0)).toBeUndefined();
+  });
+
+  it('should use string indexes for ASCII content', () => {
+    const offsets = new ByteOffsetMap('a\r\nbc\n');
+
+    expect(offsets.byteOffset(1, 1)).toBe(4);
+    expect(offsets.byteOffset(2, 0)).toBe(6);
+  });
+});
+
+describe('attachByteOffsets', () => {
+  it('should let symbols and references slice the file bytes', () => {
+    const source = [
+      'package greet',
+      '',
+      '// Grüße says hello.',
+      'func Grüße(name string) string { return "¡Hola, " + name }',
+      '',
+      'var greeting = Grüße("Zoë")',
+      ''
+    ].join('\n');
+    const bytes = Buffer.from(source, 'utf8');
+    const { symbols, references } = new GoIndexer().indexFile('/ws/greet/greet.go', source);
+
+    attachByteOffsets(source, symbols, references);
+
+    const slice = (range: { startOffset?: number; endOffset?: number }) =>
+      bytes.subarray(range.startOffset!, range.endOffset!).toString('utf8');
+    const func = symbols.find(s => s.name === 'Grüße')!;
+    expect(slice(func.range)).toBe('func Grüße(name string) string { return "¡Hola, " + name }');
+    const call = references.find(r => r.symbolName === 'Grüße' && r.location.line === 5)!;
+    expect(slice(call.range)).toBe('Grüße');
+  });
+});
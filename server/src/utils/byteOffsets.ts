@@ -0,0 +1,73 @@
+import { SymbolRange } from '../types.js';
+
+/**
+ * Converts (line, character) positions of one file to UTF-8 byte offsets.
+ *
+ * Characters are UTF-16 code units, as in LSP and the index; byte offsets
+ * count the file's bytes, so tools outside the JS world can slice the
+ * source without re-parsing it. ASCII-only files take a fast path where
+ * both are the same.
+ */
+export class ByteOffsetMap {
+  /** String index of each line start */
+  private lineStarts: number[] = [0];
+  /** Byte offset of each line start, only for non-ASCII content */
+  private lineByteStarts?: number[];
+
+  constructor(private content: string) {
+    for (let i = content.indexOf('\n'); i !== -1; i = content.indexOf('\n', i + 1)) {
+      this.lineStarts.push(i + 1);
+    }
+    if (/[^\x00-\x7F]/.test(content)) {
+      this.lineByteStarts = [0];
+      for (let line = 1; line < this.lineStarts.length; line++) {
+        const previous = this.lineStarts[line - 1];
+        this.lineByteStarts.push(
+          this.lineByteStarts[line - 1] + Buffer.byteLength(content.slice(previous, this.lineStarts[line]), 'utf8')
+        );
+      }
+    }
+  }
+
+  /**
+   * Byte offset of a position, or undefined if the line is out of range.
+   * Characters past the end of the line clamp to its end.
+   */
+  byteOffset(line: number, character: number): number | undefined {
+    const lineStart = this.lineStarts[line];
+    if (lineStart === undefined || line < 0) {
+      return undefined;
+    }
+    const lineEnd = line + 1 < this.lineStarts.length ? this.lineStarts[line + 1] - 1 : this.content.length;
+    const index = Math.min(lineStart + Math.max(character, 0), lineEnd);
+    if (!this.lineByteStarts) {
+      return index;
+    }
+    return this.lineByteStarts[line] + Buffer.byteLength(this.content.slice(lineStart, index), 'utf8');
+  }
+
+  /**
+   * Attach startOffset/endOffset to a range.
+   */
+  attach(range: SymbolRange): void {
+    const start = this.byteOffset(range.startLine, range.startCharacter);
+    const end = this.byteOffset(range.endLine, range.endCharacter);
+    if (start !== undefined && end !== undefined) {
+      range.startOffset = start;
+      range.endOffset = end;
+    }
+  }
+}
+
+/**
+ * Attach byte offsets to the ranges of everything indexed from `content`:
+ * symbols, references and pending references.
+ */
+export function attachByteOffsets(content: string, ...groups: Array<Array<{ range: SymbolRange }> | undefined>): void {
+  const offsets = new ByteOffsetMap(content);
+  for (const items of groups) {
+    for (const item of items ?? []) {
+      offsets.attach(item.range);
+    }
+  }
+}
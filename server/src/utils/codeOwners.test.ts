@@ -0,0 +1,121 @@
+/**
+ * CodeOwners Tests
+ *
+ * Verifies GitHub and GitLab CODEOWNERS syntax, owner resolution and the
+ * lookup of the CODEOWNERS file in a workspace.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import * as path from 'path';
+import { CodeOwners, normalizeOwner, parseCodeOwners, resolveOwners } from './codeOwners.js';
+
+const root = path.join(path.sep, 'ws');
+
+function p(relative: string): string {
+  return path.join(root, ...relative.split('/'));
+}
+
+describe('resolveOwners', () => {
+  it('should follow GitHub syntax, the last matching line winning', () => {
+    const rules = parseCodeOwners([
+      '# Default owners',
+      '*       @org/everyone',
+      '*.go    @org/backend   # Go code',
+      '/docs/  @org/docs docs@example.com',
+      'docs/*  @org/writers',
+      'apps/   @org/apps',
+      '/build/logs/',
+      'my\\ file.txt @org/spaces',
+      '\\#notes.md @org/notes'
+    ].join('\n'));
+
+    expect(resolveOwners(rules, 'README.md')).toEqual(['@org/everyone']);
+    expect(resolveOwners(rules, 'pkg/server/main.go')).toEqual(['@org/backend']);
+    expect(resolveOwners(rules, 'docs/intro.md')).toEqual(['@org/writers']);
+    expect(resolveOwners(rules, 'docs/guides/setup.md')).toEqual(['@org/docs', 'docs@example.com']);
+    expect(resolveOwners(rules, 'src/apps/web/index.ts')).toEqual(['@org/apps']);
+    expect(resolveOwners(rules, 'build/logs/today.log')).toEqual([]);
+    expect(resolveOwners(rules, 'my file.txt')).toEqual(['@org/spaces']);
+    expect(resolveOwners(rules, '#notes.md')).toEqual(['@org/notes']);
+  });
+
+  it('should combine GitLab sections with default owners and exclusions', () => {
+    const rules = parseCodeOwners([
+      '*.go @backend',
+      '',
+      '[Platform][2] @platform-team',
+      '/pkg/api/',
+      '/pkg/api/v1/ @api-owners',
+      '!/pkg/api/generated/',
+      '',
+      '^[Security]',
+      '**/auth/** @security',
+      '',
+      '[platform]',
+      '/pkg/store/'
+    ].join('\n'));
+
+    expect(resolveOwners(rules, 'pkg/api/users.go')).toEqual(['@backend', '@platform-team']);
+    expect(resolveOwners(rules, 'pkg/api/v1/users.go')).toEqual(['@backend', '@api-owners']);
+    expect(resolveOwners(rules, 'pkg/api/auth/token.go')).toEqual(['@backend', '@platform-team', '@security']);
+    expect(resolveOwners(rules, 'pkg/api/generated/api.pb.go')).toEqual(['@backend']);
+    // A repeated section keeps its name but has no default owners
+    expect(resolveOwners(rules, 'pkg/store/store.ts')).toEqual([]);
+    expect(rules.find(rule => rule.pattern === '/pkg/store/')!.section).toBe('platform');
+  });
+
+  it('should normalize owners for comparison', () => {
+    expect(normalizeOwner('Platform-Team')).toBe('@platform-team');
+    expect(normalizeOwner('@Org/API')).toBe('@org/api');
+    expect(normalizeOwner('Dev@Example.com')).toBe('dev@example.com');
+  });
+});
+
+describe('CodeOwners', () => {
+  let files: Map<string, string>;
+  let reads: number;
+
+  beforeEach(() => {
+    files = new Map();
+    reads = 0;
+  });
+
+  function codeOwners(): CodeOwners {
+    return new CodeOwners(root, filePath => {
+      reads++;
+      return files.get(filePath) ?? null;
+    });
+  }
+
+  it('should use the first CODEOWNERS location that exists', () => {
+    files.set(p('docs/CODEOWNERS'), '* @docs');
+    files.set(p('.github/CODEOWNERS'), '*.go @backend');
+    const owners = codeOwners();
+
+    expect(owners.ownersOf(p('cmd/main.go'))).toEqual(['@backend']);
+    expect(owners.ownersOf(p('README.md'))).toEqual([]);
+    expect(owners.ownersOf(path.join(path.sep, 'elsewhere', 'main.go'))).toEqual([]);
+    expect(owners.getSourceFile()).toBe('.github/CODEOWNERS');
+  });
+
+  it('should read the file once until cleared', () => {
+    files.set(p('CODEOWNERS'), '* @old');
+    const owners = codeOwners();
+    expect(owners.ownersOf(p('a.go'))).toEqual(['@old']);
+    expect(owners.ownersOf(p('b.go'))).toEqual(['@old']);
+    const readsBefore = reads;
+
+    files.set(p('CODEOWNERS'), '* @new');
+    expect(owners.ownersOf(p('a.go'))).toEqual(['@old']);
+    expect(reads).toBe(readsBefore);
+
+    owners.clear();
+    expect(owners.ownersOf(p('a.go'))).toEqual(['@new']);
+  });
+
+  it('should own nothing without a CODEOWNERS file', () => {
+    const owners = codeOwners();
+    expect(owners.ownersOf(p('a.go'))).toEqual([]);
+    expect(owners.getSourceFile()).toBeUndefined();
+  });
+});
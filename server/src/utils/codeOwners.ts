@@ -0,0 +1,222 @@
+import * as path from 'path';
+import { globToRegex, readFileIfExists, ReadFileSyncFn } from './ignoreRules.js';
+
+/*
+ * Code owners from a CODEOWNERS file, in GitHub or GitLab syntax:
+ *
+ *   # GitHub: the last matching line wins
+ *   *.go            @backend
+ *   /docs/          @docs-team docs@example.com
+ *
+ *   # GitLab: sections, each contributing its last matching line
+ *   [Platform][2] @platform-team
+ *   /pkg/api/
+ *   /pkg/api/v1/    @api-owners
+ *   ^[Optional]
+ *   !/pkg/api/generated/
+ *
+ * Owners are resolved when queried, not stored in the index, so editing
+ * CODEOWNERS takes effect without re-indexing.
+ */
+
+/** Where GitHub and GitLab look for the file; the first one found is used */
+export const CODEOWNERS_LOCATIONS = ['.github/CODEOWNERS', 'CODEOWNERS', 'docs/CODEOWNERS', '.gitlab/CODEOWNERS'];
+
+export interface CodeOwnersRule {
+  pattern: string;
+  /** Empty for a line that leaves matching paths unowned */
+  owners: string[];
+  /** Lowercased GitLab section name, '' before the first section */
+  section: string;
+  /** GitLab `!pattern`: matching paths are not owned by this section */
+  excluded: boolean;
+  /** One-based line in the CODEOWNERS file */
+  line: number;
+  regex: RegExp;
+}
+
+/** The part of CodeOwners queries need; injectable for tests */
+export type OwnerLookup = Pick<CodeOwners, 'ownersOf'>;
+
+/** `[Section name]`, `^[Optional section]`, `[Section][2]`, then default owners */
+const SECTION_HEADER = /^\^?\[([^\]]+)\](?:\[\d+\])?(?:\s+(.*))?$/;
+
+/**
+ * Parse CODEOWNERS content. Lines without owners inherit their section's
+ * default owners (GitLab), else leave matching paths unowned (GitHub).
+ */
+export function parseCodeOwners(content: string): CodeOwnersRule[] {
+  const rules: CodeOwnersRule[] = [];
+  let section = '';
+  let defaultOwners: string[] = [];
+
+  const lines = content.split(/\r?\n/);
+  for (let i = 0; i < lines.length; i++) {
+    const words = splitWords(lines[i]);
+    if (words.length === 0) {
+      continue;
+    }
+    const header = SECTION_HEADER.exec(words.join(' '));
+    if (header) {
+      section = header[1].trim().toLowerCase();
+      defaultOwners = header[2] ? splitWords(header[2]) : [];
+      continue;
+    }
+
+    let pattern = words[0];
+    const excluded = pattern.startsWith('!');
+    if (excluded) {
+      pattern = pattern.slice(1);
+    }
+    if (!pattern) {
+      continue;
+    }
+    const owners = words.length > 1 ? words.slice(1) : defaultOwners;
+    rules.push({ pattern, owners, section, excluded, line: i + 1, regex: patternRegex(pattern) });
+  }
+  return rules;
+}
+
+/**
+ * Owners of a path relative to the repository root (`/` separated): per
+ * section, the last matching rule; sections combined in file order.
+ */
+export function resolveOwners(rules: CodeOwnersRule[], relativePath: string): string[] {
+  const bySection = new Map<string, CodeOwnersRule | null>();
+  for (const rule of rules) {
+    if (bySection.get(rule.section) === null || !rule.regex.test(relativePath)) {
+      continue;
+    }
+    // An exclusion wins over every other line of its section
+    bySection.set(rule.section, rule.excluded ? null : rule);
+  }
+
+  const owners = new Set<string>();
+  for (const rule of bySection.values()) {
+    rule?.owners.forEach(owner => owners.add(owner));
+  }
+  return [...owners];
+}
+
+/**
+ * Canonical form of an owner for comparisons: lowercased, with `@` added
+ * to bare user or team names (`platform-team` -> `@platform-team`).
+ */
+export function normalizeOwner(owner: string): string {
+  const trimmed = owner.trim().toLowerCase();
+  return trimmed.includes('@') ? trimmed : `@${trimmed}`;
+}
+
+/**
+ * Code Owners - owners of workspace files per the repository's CODEOWNERS.
+ *
+ * The file is read on first use from the first of CODEOWNERS_LOCATIONS
+ * that exists; call `clear()` when it changes. Owners are cached per path.
+ */
+export class CodeOwners {
+  private rules: CodeOwnersRule[] | null = null;
+  private source: string | undefined;
+  private cache: Map<string, string[]> = new Map();
+
+  constructor(
+    private workspaceRoot: string,
+    private readFile: ReadFileSyncFn = readFileIfExists
+  ) {}
+
+  /**
+   * Owners of a file, in CODEOWNERS order; empty when unowned, outside the
+   * workspace or without a CODEOWNERS file.
+   */
+  ownersOf(filePath: string): string[] {
+    const relative = path.relative(this.workspaceRoot, filePath);
+    if (!relative || relative.startsWith('..') || path.isAbsolute(relative)) {
+      return [];
+    }
+    let owners = this.cache.get(relative);
+    if (!owners) {
+      owners = resolveOwners(this.getRules(), relative.split(path.sep).join('/'));
+      this.cache.set(relative, owners);
+    }
+    return owners;
+  }
+
+  /** Workspace-relative path of the CODEOWNERS file in use, if any */
+  getSourceFile(): string | undefined {
+    this.getRules();
+    return this.source;
+  }
+
+  /** Re-read CODEOWNERS on next use */
+  clear(): void {
+    this.rules = null;
+    this.source = undefined;
+    this.cache.clear();
+  }
+
+  private getRules(): CodeOwnersRule[] {
+    if (!this.rules) {
+      this.rules = [];
+      for (const location of CODEOWNERS_LOCATIONS) {
+        const content = this.readFile(path.join(this.workspaceRoot, ...location.split('/')));
+        if (content !== null) {
+          this.rules = parseCodeOwners(content);
+          this.source = location;
+          break;
+        }
+      }
+    }
+    return this.rules;
+  }
+}
+
+/**
+ * Words of a line up to a comment: `#` starts one at the beginning of a
+ * word, `\#` and `\ ` are literal.
+ */
+function splitWords(line: string): string[] {
+  const words: string[] = [];
+  let word = '';
+  for (let i = 0; i < line.length; i++) {
+    const char = line[i];
+    if (char === '\\' && i + 1 < line.length) {
+      word += line.slice(i, i + 2);
+      i++;
+    } else if (/\s/.test(char)) {
+      if (word) {
+        words.push(word);
+      }
+      word = '';
+    } else if (char === '#' && !word) {
+      break;
+    } else {
+      word += char;
+    }
+  }
+  if (word) {
+    words.push(word);
+  }
+  return words;
+}
+
+/**
+ * CODEOWNERS patterns follow gitignore: a slash other than a trailing one
+ * anchors the pattern to the root, a trailing slash matches directories
+ * only. A matching directory owns everything below it, except for
+ * `dir/*`, which owns only the files directly inside.
+ */
+function patternRegex(pattern: string): RegExp {
+  let glob = pattern;
+  const directoryOnly = glob.endsWith('/');
+  if (directoryOnly) {
+    glob = glob.slice(0, -1);
+  }
+  const anchored = glob.includes('/');
+  glob = glob.replace(/^\//, '');
+  if (!glob) {
+    return /^/; // `/`: the whole repository
+  }
+  const body = globToRegex(glob);
+  const ownsDescendants = !glob.endsWith('/*') || glob.endsWith('/**');
+  const suffix = directoryOnly ? '/.+' : ownsDescendants ? '(?:/.+)?' : '';
+  return new RegExp(`${anchored ? '^' : '(?:^|/)'}${body}${suffix}$`);
+}
@@ -0,0 +1,95 @@
+/**
+ * Code Tags Tests
+ *
+ * Verifies path and content heuristics, symbol-level tags and tag filters.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { classifyFile, classifyPath, matchesTagFilter, parseCodeTags, tagFileResult } from './codeTags.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { IndexedFileResult } from '../types.js';
+
+describe('classifyPath', () => {
+  it('should tag test, mock, example and generated files by naming convention', () => {
+    expect(classifyPath('/ws/pkg/greet/greet_test.go')).toEqual(['test']);
+    expect(classifyPath('/ws/src/user.service.spec.ts')).toEqual(['test']);
+    expect(classifyPath('/ws/app/test_models.py')).toEqual(['test']);
+    expect(classifyPath('/ws/src/test/java/com/acme/UserServiceTest.java')).toEqual(['test']);
+    expect(classifyPath('/ws/web/__tests__/App.tsx')).toEqual(['test']);
+
+    expect(classifyPath('/ws/pkg/store/mock_store.go')).toEqual(['mock']);
+    expect(classifyPath('/ws/src/__mocks__/api.ts')).toEqual(['mock']);
+    expect(classifyPath('/ws/pkg/mocks/store.go')).toEqual(['mock']);
+
+    expect(classifyPath('/ws/pkg/greet/example_test.go')).toEqual(['test', 'example']);
+    expect(classifyPath('/ws/examples/basic/main.go')).toEqual(['example']);
+
+    expect(classifyPath('/ws/api/user.pb.go')).toEqual(['generated']);
+    expect(classifyPath('/ws/api/zz_generated.deepcopy.go')).toEqual(['generated']);
+    expect(classifyPath('/ws/proto/user_pb2.py')).toEqual(['generated']);
+    expect(classifyPath('/ws/src/schema.generated.ts')).toEqual(['generated']);
+
+    expect(classifyPath('/ws/pkg/greet/greet.go')).toEqual([]);
+    expect(classifyPath('/ws/src/contest.ts')).toEqual([]);
+    expect(classifyPath('/ws/src/testing/helpers.ts')).toEqual([]);
+  });
+
+  it('should add content markers when the content is known', () => {
+    expect(classifyFile('/ws/db/query.go', '// Code generated by sqlc. DO NOT EDIT.\npackage db\n')).toEqual(['generated']);
+    expect(classifyFile('/ws/store/store_mock.go', '// Code generated by MockGen. DO NOT EDIT.\npackage store\n'))
+      .toEqual(['generated', 'mock']);
+    expect(classifyFile('/ws/db/query.go', 'package db\n')).toEqual([]);
+  });
+});
+
+describe('tagFileResult', () => {
+  it('should tag the file and its symbols, adding Go examples and mock types', () => {
+    const uri = '/ws/greet/example_greet_test.go';
+    const result: IndexedFileResult = {
+      uri,
+      hash: 'h',
+      symbols: [
+        createTestSymbol({ name: 'ExampleGreet', kind: 'function', filePath: uri }),
+        createTestSymbol({ name: 'TestGreet', kind: 'function', filePath: uri }),
+        createTestSymbol({ name: 'Examples', kind: 'function', filePath: uri }),
+        createTestSymbol({ name: 'FakeClock', kind: 'struct', filePath: uri })
+      ],
+      references: [],
+      imports: []
+    };
+
+    tagFileResult(result, 'package greet_test\n');
+    expect(result.tags).toEqual(['test', 'example']);
+    expect(result.symbols.map(s => s.tags)).toEqual([
+      ['test', 'example'],
+      ['test', 'example'],
+      ['test', 'example'],
+      ['test', 'mock', 'example']
+    ]);
+
+    const plain: IndexedFileResult = {
+      uri: '/ws/greet/greet.go',
+      hash: 'h',
+      symbols: [createTestSymbol({ name: 'ExampleGreet', kind: 'function', filePath: '/ws/greet/greet.go' })],
+      references: [],
+      imports: []
+    };
+    tagFileResult(plain, 'package greet\n');
+    expect(plain.tags).toBeUndefined();
+    expect(plain.symbols[0].tags).toBeUndefined();
+  });
+});
+
+describe('tag filters', () => {
+  it('should parse tag lists and apply exclude and only', () => {
+    expect(parseCodeTags('tests, Generated,,mock')).toEqual(['generated', 'test', 'mock']);
+    expect(parseCodeTags('')).toEqual([]);
+    expect(() => parseCodeTags('test,fixtures')).toThrow('Unknown tag "fixtures"');
+
+    expect(matchesTagFilter(undefined, { exclude: ['test'] })).toBe(true);
+    expect(matchesTagFilter(['test', 'mock'], { exclude: ['mock'] })).toBe(false);
+    expect(matchesTagFilter(['test'], { only: ['test', 'example'] })).toBe(true);
+    expect(matchesTagFilter([], { only: ['test'] })).toBe(false);
+    expect(matchesTagFilter(['example'], { only: [], exclude: [] })).toBe(true);
+  });
+});
@@ -0,0 +1,180 @@
+import { CODE_TAGS, CodeTag, IndexedFileResult, IndexedSymbol } from '../types.js';
+import { isGeneratedSource } from './ignoreRules.js';
+
+/*
+ * Code tags classify indexed files and symbols as generated, test, mock or
+ * example code so queries can leave them out (or ask only for them) instead
+ * of the files being excluded from the index altogether. Classification is
+ * heuristic: file names and directories per language convention, plus the
+ * generated-code and mockgen headers when the content is at hand.
+ */
+
+/**
+ * Which tagged code a query keeps. A result passes when it has none of the
+ * `exclude` tags and, if `only` is non-empty, at least one of the `only` tags.
+ */
+export interface CodeTagFilter {
+  exclude?: CodeTag[];
+  only?: CodeTag[];
+}
+
+const TEST_FILE_PATTERNS = [
+  /_test\.go$/,
+  /\.(test|spec)\.[cm]?[jt]sx?$/,
+  /^test_.*\.py$/,
+  /_test\.py$/,
+  /^conftest\.py$/,
+  /[a-z0-9]Tests?\.(java|kt|cs)$/,
+  /_spec\.rb$/
+];
+
+const TEST_DIRECTORIES = new Set(['test', 'tests', '__tests__', 'spec']);
+
+const MOCK_FILE_PATTERNS = [
+  /^mocks?_/,
+  /_mocks?\.go$/,
+  /\.mocks?\.[cm]?[jt]sx?$/
+];
+
+const MOCK_DIRECTORIES = new Set(['mock', 'mocks', '__mocks__', 'fakes']);
+
+const EXAMPLE_FILE_PATTERNS = [
+  /^example(_.*)?_test\.go$/
+];
+
+const EXAMPLE_DIRECTORIES = new Set(['example', 'examples', '_examples']);
+
+const GENERATED_FILE_PATTERNS = [
+  /\.pb(\.gw)?\.go$/,
+  /_pb2(_grpc)?\.py$/,
+  /^zz_generated/,
+  /[._]generated\.\w+$/,
+  /_gen\.go$/,
+  /\.designer\.cs$/
+];
+
+/** mockgen's header, e.g. `// Code generated by MockGen. DO NOT EDIT.` */
+const MOCKGEN_MARKER = /^\/\/ Code generated by MockGen\b/m;
+
+/** Symbols that are mocks even outside mock files */
+const MOCK_SYMBOL_NAME = /^(Mock|Fake)[A-Z]/;
+
+/** Go example functions: `Example`, `ExampleFoo`, `ExampleFoo_Bar` */
+const GO_EXAMPLE_FUNCTION = /^Example($|[A-Z_])/;
+
+/**
+ * Tags of a file implied by its path alone.
+ */
+export function classifyPath(filePath: string): CodeTag[] {
+  const segments = filePath.split(/[\\/]/).filter(Boolean);
+  const base = segments.pop() ?? '';
+  const tags = new Set<CodeTag>();
+
+  if (GENERATED_FILE_PATTERNS.some(pattern => pattern.test(base))) {
+    tags.add('generated');
+  }
+  if (TEST_FILE_PATTERNS.some(pattern => pattern.test(base)) || segments.some(dir => TEST_DIRECTORIES.has(dir))) {
+    tags.add('test');
+  }
+  if (MOCK_FILE_PATTERNS.some(pattern => pattern.test(base)) || segments.some(dir => MOCK_DIRECTORIES.has(dir))) {
+    tags.add('mock');
+  }
+  if (EXAMPLE_FILE_PATTERNS.some(pattern => pattern.test(base)) || segments.some(dir => EXAMPLE_DIRECTORIES.has(dir))) {
+    tags.add('example');
+  }
+  return sortTags(tags);
+}
+
+/**
+ * Tags of a file from its path and, when available, its content
+ * (generated-code and mockgen headers).
+ */
+export function classifyFile(filePath: string, content?: string): CodeTag[] {
+  const tags = new Set(classifyPath(filePath));
+  if (content !== undefined) {
+    if (isGeneratedSource(content)) {
+      tags.add('generated');
+    }
+    if (MOCKGEN_MARKER.test(content)) {
+      tags.add('mock');
+    }
+  }
+  return sortTags(tags);
+}
+
+/**
+ * Tags of a symbol: its file's tags plus symbol-level ones
+ * (Go `Example*` functions in test files, `Mock*`/`Fake*` types).
+ */
+export function classifySymbol(symbol: IndexedSymbol, fileTags: CodeTag[]): CodeTag[] {
+  const tags = new Set(fileTags);
+  if (fileTags.includes('test') && symbol.filePath.endsWith('_test.go') &&
+      symbol.kind === 'function' && GO_EXAMPLE_FUNCTION.test(symbol.name)) {
+    tags.add('example');
+  }
+  if (symbol.isDefinition !== false && symbol.kind !== 'function' && symbol.kind !== 'method' &&
+      MOCK_SYMBOL_NAME.test(symbol.name)) {
+    tags.add('mock');
+  }
+  return sortTags(tags);
+}
+
+/**
+ * Set `tags` on an indexing result and its symbols (left unset when empty).
+ */
+export function tagFileResult(result: IndexedFileResult, content?: string): IndexedFileResult {
+  const fileTags = classifyFile(result.uri, content);
+  if (fileTags.length > 0) {
+    result.tags = fileTags;
+  }
+  for (const symbol of result.symbols) {
+    const tags = classifySymbol(symbol, fileTags);
+    if (tags.length > 0) {
+      symbol.tags = tags;
+    }
+  }
+  return result;
+}
+
+/**
+ * Does a result with these tags pass the filter?
+ */
+export function matchesTagFilter(tags: readonly CodeTag[] | undefined, filter: CodeTagFilter): boolean {
+  const present = tags ?? [];
+  if (filter.exclude?.some(tag => present.includes(tag))) {
+    return false;
+  }
+  if (filter.only && filter.only.length > 0 && !filter.only.some(tag => present.includes(tag))) {
+    return false;
+  }
+  return true;
+}
+
+/** True when the filter keeps everything */
+export function isTagFilterEmpty(filter: CodeTagFilter): boolean {
+  return !filter.exclude?.length && !filter.only?.length;
+}
+
+/**
+ * Parse a comma-separated tag list such as `tests,generated`. Plurals are
+ * accepted; unknown tags throw.
+ */
+export function parseCodeTags(value: string): CodeTag[] {
+  const tags = new Set<CodeTag>();
+  for (const raw of value.split(',')) {
+    const name = raw.trim().toLowerCase();
+    if (!name) {
+      continue;
+    }
+    const tag = CODE_TAGS.find(candidate => candidate === name || candidate + 's' === name);
+    if (!tag) {
+      throw new Error(`Unknown tag "${raw.trim()}" (expected ${CODE_TAGS.join(', ')})`);
+    }
+    tags.add(tag);
+  }
+  return sortTags(tags);
+}
+
+function sortTags(tags: Set<CodeTag>): CodeTag[] {
+  return CODE_TAGS.filter(tag => tags.has(tag));
+}
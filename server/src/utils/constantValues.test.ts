@@ -0,0 +1,45 @@
+/**
+ * Constant Values Tests
+ *
+ * Verifies literal value extraction for TS/JS constants.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { attachTsConstantValues, MAX_VALUE_LENGTH, truncateValue } from './constantValues.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+describe('attachTsConstantValues', () => {
+  it('should read literal initializers after the name', () => {
+    const content = [
+      'export const MAX_RETRIES = 5;',
+      "const API_URL: string = 'https://api.example.com'",
+      'const ROLES = { admin: 1 };',
+      'const MODE = `strict` as const; // default',
+      'const BIG = -0x1F_FFn, DEBUG = false;',
+      'let counter = 0;'
+    ].join('\n');
+    const constant = (name: string, line: number, character: number, kind = 'constant') =>
+      createTestSymbol({ name, kind, location: { uri: '/ws/config.ts', line, character } });
+    const symbols = [
+      constant('MAX_RETRIES', 0, 13),
+      constant('API_URL', 1, 6),
+      constant('ROLES', 2, 6),
+      constant('MODE', 3, 6),
+      constant('BIG', 4, 6),
+      constant('DEBUG', 4, 23),
+      constant('counter', 5, 4, 'variable')
+    ];
+
+    attachTsConstantValues(symbols, content);
+    expect(symbols.map(s => s.value)).toEqual([
+      '5', "'https://api.example.com'", undefined, '`strict`', '-0x1F_FFn', 'false', undefined
+    ]);
+  });
+
+  it('should truncate long values', () => {
+    expect(truncateValue('short')).toBe('short');
+    const long = truncateValue('x'.repeat(500));
+    expect(long).toHaveLength(MAX_VALUE_LENGTH);
+    expect(long.endsWith('…')).toBe(true);
+  });
+});
@@ -0,0 +1,46 @@
+import { IndexedSymbol } from '../types.js';
+
+/** Longest value text stored on a symbol; longer initializers are cut */
+export const MAX_VALUE_LENGTH = 120;
+
+/**
+ * A literal initializer after the constant's name: optional type
+ * annotation, `=`, then a number, string, template without
+ * substitutions, boolean or null, optionally `as const`.
+ */
+const TS_LITERAL_RE =
+  /\s*(?::[^=;\n]*)?=\s*(-?(?:0[xXbBoO][\da-fA-F_]+|\d[\d_]*(?:\.\d*)?(?:[eE][+-]?\d+)?|\.\d+(?:[eE][+-]?\d+)?)n?|'(?:[^'\\\n]|\\.)*'|"(?:[^"\\\n]|\\.)*"|`[^`$\\]*`|true|false|null)\s*(?:as\s+const\s*)?(?=[;,)\r\n]|\/[/*]|$)/y;
+
+/**
+ * Cut a value's source text to MAX_VALUE_LENGTH, marking the cut.
+ */
+export function truncateValue(text: string): string {
+  return text.length > MAX_VALUE_LENGTH ? text.slice(0, MAX_VALUE_LENGTH - 1) + '…' : text;
+}
+
+/**
+ * Attach literal values to TS/JS `const` declarations extracted from
+ * `content`, e.g. `5` for `export const MAX_RETRIES = 5;`. Computed
+ * initializers are left without a value.
+ */
+export function attachTsConstantValues(symbols: IndexedSymbol[], content: string): void {
+  const lineOffsets = [0];
+  for (let i = content.indexOf('\n'); i !== -1; i = content.indexOf('\n', i + 1)) {
+    lineOffsets.push(i + 1);
+  }
+
+  for (const symbol of symbols) {
+    if (symbol.kind !== 'constant' || symbol.isDefinition === false || symbol.value !== undefined) {
+      continue;
+    }
+    const nameStart = (lineOffsets[symbol.location.line] ?? -1) + symbol.location.character;
+    if (content.slice(nameStart, nameStart + symbol.name.length) !== symbol.name) {
+      continue;
+    }
+    TS_LITERAL_RE.lastIndex = nameStart + symbol.name.length;
+    const match = TS_LITERAL_RE.exec(content);
+    if (match) {
+      symbol.value = truncateValue(match[1]);
+    }
+  }
+}
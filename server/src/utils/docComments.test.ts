@@ -0,0 +1,121 @@
+/**
+ * Doc Comments Tests
+ *
+ * Verifies comment cleaning per language, JSDoc lookup, link extraction
+ * and Markdown rendering.
+ */
+
+import { describe, it, expect } from 'vitest';
+import {
+  attachJsDocComments,
+  cleanDocstring,
+  cleanGoComment,
+  deprecationNotice,
+  docFormatFor,
+  extractDocLinks,
+  renderDocMarkdown
+} from './docComments.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+
+describe('cleaning doc comments', () => {
+  it('should strip comment markers, directives and blank edges', () => {
+    expect(cleanGoComment(['// Greet says hello.', '//', '//   indented', '//go:noinline', '//'])).toBe('Greet says hello.\n\n  indented');
+    expect(cleanGoComment(['/*\n Package greet greets.\n*/'])).toBe(' Package greet greets.');
+    expect(cleanDocstring('Load a user.\n\n    Args:\n        id: the user id\n    ')).toBe('Load a user.\n\nArgs:\n    id: the user id');
+    expect(docFormatFor('/ws/a.go')).toBe('go');
+    expect(docFormatFor('/ws/a.py')).toBe('plain');
+    expect(docFormatFor('/ws/a.ts')).toBe('jsdoc');
+  });
+
+  it('should attach the JSDoc comment above a definition, skipping decorators', () => {
+    const content = [
+      '/**',
+      ' * Loads users.',
+      ' * @param id - The user id',
+      ' */',
+      '@Injectable()',
+      'export class UserService {}',
+      '/* not a doc comment */',
+      'function helper() {}'
+    ].join('\n');
+    const service = createTestSymbol({ name: 'UserService', range: { startLine: 5, startCharacter: 0, endLine: 5, endCharacter: 26 } });
+    const helper = createTestSymbol({ name: 'helper', range: { startLine: 7, startCharacter: 0, endLine: 7, endCharacter: 20 } });
+
+    attachJsDocComments([service, helper], content);
+    expect(service.doc).toBe('Loads users.\n@param id - The user id');
+    expect(helper.doc).toBeUndefined();
+  });
+
+  it('should read deprecation notices', () => {
+    expect(deprecationNotice('Get reads a key.\n\nDeprecated: use Lookup,\nwhich caches.\n\nMore text.', 'go')).toBe('use Lookup, which caches.');
+    expect(deprecationNotice('Deprecated:', 'go')).toBe('');
+    expect(deprecationNotice('Get reads a key. Deprecated: no', 'go')).toBeUndefined();
+    expect(deprecationNotice('Loads users.\n@deprecated Use\n  loadPage.\n@param id - The user id', 'jsdoc')).toBe('Use loadPage.');
+    expect(deprecationNotice('Loads users.\n@deprecated', 'jsdoc')).toBe('');
+    expect(deprecationNotice('Load a user.\n\n.. deprecated:: 2.0\n   Use load_page.\n', 'plain')).toBe('2.0 Use load_page.');
+    expect(deprecationNotice('Loads users.', 'jsdoc')).toBeUndefined();
+  });
+});
+
+describe('rendering doc comments', () => {
+  const urls: Record<string, string> = {
+    'Person': 'file:///ws/person.go#L3',
+    'Person.Greet': 'file:///ws/person.go#L9',
+    'UserService.load': 'file:///ws/user.ts#L4'
+  };
+  const resolve = (target: string) => urls[target];
+
+  it('should render go/doc syntax with resolved links', () => {
+    const doc = [
+      'Greet returns a greeting for [Person] using [Person.Greet] and [fmt.Sprintf].',
+      '',
+      '# Options',
+      '',
+      '  - short: only the name',
+      '  - long: see [RFC 7231]',
+      '',
+      'Example:',
+      '',
+      '\tfmt.Println(Greet(p))',
+      '',
+      '[RFC 7231]: https://www.rfc-editor.org/rfc/rfc7231'
+    ].join('\n');
+
+    expect(extractDocLinks(doc, 'go')).toEqual(['Person', 'Person.Greet', 'fmt.Sprintf']);
+    expect(renderDocMarkdown(doc, 'go', resolve)).toBe([
+      'Greet returns a greeting for [Person](file:///ws/person.go#L3) using ' +
+        '[Person.Greet](file:///ws/person.go#L9) and \\[fmt.Sprintf\\].',
+      '',
+      '#### Options',
+      '',
+      '- short: only the name\n- long: see [RFC 7231](https://www.rfc-editor.org/rfc/rfc7231)',
+      '',
+      'Example:',
+      '',
+      '```go\nfmt.Println(Greet(p))\n```'
+    ].join('\n'));
+  });
+
+  it('should render JSDoc links and block tags', () => {
+    const doc = 'Caches {@link UserService#load} results, see {@link https://example.com|docs}.\n' +
+      '@param {string} key - Cache key\n@returns The cached value';
+
+    expect(extractDocLinks(doc, 'jsdoc')).toEqual(['UserService.load']);
+    expect(renderDocMarkdown(doc, 'jsdoc', resolve)).toBe([
+      'Caches [UserService#load](file:///ws/user.ts#L4) results, see [docs](https://example.com).',
+      '',
+      '*@param* `key` — Cache key',
+      '',
+      '*@returns* — The cached value'
+    ].join('\n'));
+  });
+
+  it('should keep docstring layout and link Sphinx roles', () => {
+    const doc = 'Wraps :class:`Person` and :func:`missing`.\n\nArgs:\n    name: the *name*';
+
+    expect(extractDocLinks(doc, 'plain')).toEqual(['Person', 'missing']);
+    expect(renderDocMarkdown(doc, 'plain', resolve)).toBe(
+      'Wraps [Person](file:///ws/person.go#L3) and `missing`.\n\nArgs:  \n&nbsp;&nbsp;&nbsp;&nbsp;name: the \\*name\\*'
+    );
+  });
+});
@@ -0,0 +1,432 @@
+import { IndexedSymbol } from '../types.js';
+
+/**
+ * How a doc comment is written, which decides how it is rendered:
+ * - `go`: go/doc comment syntax (https://go.dev/doc/comment)
+ * - `jsdoc`: Markdown with `{@link}` and block tags
+ * - `plain`: free text (Python docstrings), Sphinx roles as links
+ */
+export type DocFormat = 'go' | 'jsdoc' | 'plain';
+
+/** Maps a link target (e.g. `Person.Greet`) to a URL, or undefined when unknown */
+export type DocLinkResolver = (target: string) => string | undefined;
+
+/** Go doc links: [Name], [Name.Method], [pkg.Name], [*Name] */
+const GO_DOC_LINK = /^\*?[A-Za-z_]\w*(\.[A-Za-z_]\w*){0,2}$/;
+
+/** go/doc link definitions: `[Text]: URL` */
+const GO_LINK_DEFINITION = /^\[([^\]\n]+)\]:\s*(\S+)\s*$/;
+
+/** Lines go/ast drops from doc comments: //go:generate, //nolint, //line, ... */
+const GO_DIRECTIVE = /^\/\/(go:|line |export |extern |nolint)/;
+
+const LIST_MARKER = /^([-*+•]|\d+[.)])\s+/;
+
+const JSDOC_LINK = /\{@link(?:code|plain)?\s+([^\s|}]+)(?:\s*\|\s*|\s+)?([^}]*)\}/g;
+
+const SPHINX_ROLE = /:(?:py:)?(?:class|func|meth|attr|mod|obj|data|exc):`~?([\w.]+)`/g;
+
+const URL_PATTERN = /https?:\/\/[^\s)>\]]+/g;
+
+/** Sphinx and numpydoc deprecations: `.. deprecated:: 2.0` */
+const SPHINX_DEPRECATED = /^\s*\.\.\s+deprecated::\s*(.*)$/;
+
+/**
+ * Doc format of a file, by extension.
+ */
+export function docFormatFor(filePath: string): DocFormat {
+  if (filePath.endsWith('.go')) {
+    return 'go';
+  }
+  if (/\.pyi?$/.test(filePath)) {
+    return 'plain';
+  }
+  return 'jsdoc';
+}
+
+/**
+ * Text of a Go comment group (`//` lines or one `/* *\/` block): comment
+ * markers, the first space of each line comment, directives and leading or
+ * trailing blank lines removed, like go/ast's CommentGroup.Text.
+ */
+export function cleanGoComment(comments: string[]): string {
+  const lines: string[] = [];
+  for (const comment of comments) {
+    if (comment.startsWith('//')) {
+      if (!GO_DIRECTIVE.test(comment)) {
+        lines.push(comment.slice(2).replace(/^ /, ''));
+      }
+    } else {
+      lines.push(...comment.slice(2, comment.endsWith('*/') ? -2 : undefined).split('\n'));
+    }
+  }
+  return trimBlankLines(lines.map(line => line.replace(/\s+$/, '')));
+}
+
+/**
+ * Text of a `/** ... *\/` comment without the delimiters and leading `*`s.
+ */
+export function cleanJsDocComment(comment: string): string {
+  const body = comment.replace(/^\/\*\*/, '').replace(/\*\/$/, '');
+  return trimBlankLines(body.split('\n').map(line => line.replace(/^\s*\* ?/, '').replace(/\s+$/, '')));
+}
+
+/**
+ * Docstring text with the indentation of its continuation lines removed (PEP 257).
+ */
+export function cleanDocstring(text: string): string {
+  const lines = text.replace(/\t/g, '    ').split('\n');
+  let indent = Infinity;
+  for (const line of lines.slice(1)) {
+    if (line.trim()) {
+      indent = Math.min(indent, line.length - line.trimStart().length);
+    }
+  }
+  const cleaned = [lines[0].trim(), ...lines.slice(1).map(line => (indent === Infinity ? line : line.slice(indent)).replace(/\s+$/, ''))];
+  return trimBlankLines(cleaned);
+}
+
+/**
+ * The JSDoc comment ending right above line `declarationLine` (0-based),
+ * skipping decorator lines in between.
+ */
+export function findJsDocComment(lines: string[], declarationLine: number): string | undefined {
+  let end = declarationLine - 1;
+  while (end >= 0 && lines[end].trim().startsWith('@') && !lines[end].includes('*/')) {
+    end--;
+  }
+  if (end < 0 || !lines[end].trimEnd().endsWith('*/')) {
+    return undefined;
+  }
+  for (let start = end; start >= 0; start--) {
+    const open = lines[start].indexOf('/*');
+    if (open !== -1) {
+      if (lines[start].slice(open, open + 3) !== '/**' || lines[start].slice(0, open).trim()) {
+        return undefined;
+      }
+      const text = lines.slice(start, end + 1).join('\n').trim();
+      return cleanJsDocComment(text) || undefined;
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Attach JSDoc comments to the definitions among symbols (`symbol.doc`).
+ */
+export function attachJsDocComments(symbols: IndexedSymbol[], content: string): void {
+  if (!content.includes('/**')) {
+    return;
+  }
+  const lines = content.split('\n');
+  for (const symbol of symbols) {
+    if (symbol.isDefinition === false || symbol.doc) {
+      continue;
+    }
+    const doc = findJsDocComment(lines, symbol.range.startLine);
+    if (doc) {
+      setSymbolDoc(symbol, doc, 'jsdoc');
+    }
+  }
+}
+
+/**
+ * The deprecation notice of a doc comment, '' when it gives no reason, or
+ * undefined when the symbol is not deprecated: a Go paragraph starting
+ * with `Deprecated:`, a JSDoc `@deprecated` tag or a Sphinx
+ * `.. deprecated::` directive, up to the end of its paragraph or tag.
+ */
+export function deprecationNotice(doc: string, format: DocFormat): string | undefined {
+  const lines = doc.split('\n');
+  for (let i = 0; i < lines.length; i++) {
+    let first: string | undefined;
+    let continues: (line: string) => boolean;
+    if (format === 'go') {
+      // go/doc: only a paragraph that starts with "Deprecated: " counts
+      if ((i === 0 || !lines[i - 1].trim()) && lines[i].startsWith('Deprecated:')) {
+        first = lines[i].slice('Deprecated:'.length);
+      }
+      continues = line => line.trim() !== '';
+    } else if (format === 'jsdoc') {
+      const match = /^@deprecated\b(.*)$/.exec(lines[i].trim());
+      first = match?.[1];
+      continues = line => line.trim() !== '' && !line.trim().startsWith('@');
+    } else {
+      first = SPHINX_DEPRECATED.exec(lines[i])?.[1];
+      continues = line => /^\s+\S/.test(line);
+    }
+    if (first === undefined) {
+      continue;
+    }
+    const text = [first];
+    while (i + 1 < lines.length && continues(lines[i + 1])) {
+      text.push(lines[++i]);
+    }
+    return text.map(line => line.trim()).filter(Boolean).join(' ');
+  }
+  return undefined;
+}
+
+/**
+ * Set `doc` on a definition, and `deprecated` when the doc says so.
+ */
+export function setSymbolDoc(symbol: IndexedSymbol, doc: string, format: DocFormat): void {
+  symbol.doc = doc;
+  const notice = deprecationNotice(doc, format);
+  if (notice !== undefined) {
+    symbol.deprecated = notice;
+  }
+}
+
+/**
+ * Link targets a doc comment refers to, for resolving before rendering.
+ */
+export function extractDocLinks(doc: string, format: DocFormat): string[] {
+  const targets = new Set<string>();
+  if (format === 'go') {
+    const definitions = goLinkDefinitions(doc.split('\n'));
+    for (const match of doc.matchAll(/\[([^\]\n]+)\]/g)) {
+      if (!definitions.has(match[1]) && GO_DOC_LINK.test(match[1])) {
+        targets.add(match[1]);
+      }
+    }
+  } else if (format === 'jsdoc') {
+    for (const match of doc.matchAll(JSDOC_LINK)) {
+      if (!/^https?:/.test(match[1])) {
+        targets.add(normalizeJsDocTarget(match[1]));
+      }
+    }
+  } else {
+    for (const match of doc.matchAll(SPHINX_ROLE)) {
+      targets.add(match[1]);
+    }
+  }
+  return [...targets];
+}
+
+/**
+ * Render a doc comment as Markdown. Links the resolver knows become
+ * Markdown links; unknown ones stay as text.
+ */
+export function renderDocMarkdown(doc: string, format: DocFormat, resolve: DocLinkResolver = () => undefined): string {
+  if (format === 'go') {
+    return renderGoDoc(doc, resolve);
+  }
+  if (format === 'jsdoc') {
+    return renderJsDoc(doc, resolve);
+  }
+  return renderPlainDoc(doc, resolve);
+}
+
+// ---------------------------------------------------------------------------
+// go/doc
+// ---------------------------------------------------------------------------
+
+function renderGoDoc(doc: string, resolve: DocLinkResolver): string {
+  const lines = doc.split('\n');
+  const definitions = goLinkDefinitions(lines);
+  const blocks: string[] = [];
+  const inline = (text: string) => renderGoInline(text, definitions, resolve);
+  let i = 0;
+
+  while (i < lines.length) {
+    const line = lines[i];
+    if (!line.trim() || GO_LINK_DEFINITION.test(line)) {
+      i++;
+      continue;
+    }
+
+    const indented = /^\s/.test(line);
+    const atParagraphStart = i === 0 || !lines[i - 1].trim();
+
+    if (!indented && atParagraphStart && /^# \S/.test(line) && (i + 1 === lines.length || !lines[i + 1].trim())) {
+      blocks.push(`#### ${inline(line.slice(2))}`);
+      i++;
+    } else if (LIST_MARKER.test(line.trim()) && (indented || atParagraphStart)) {
+      const items: string[] = [];
+      while (i < lines.length && lines[i].trim() && (LIST_MARKER.test(lines[i].trim()) || /^\s/.test(lines[i]))) {
+        const trimmed = lines[i].trim();
+        const marker = trimmed.match(LIST_MARKER);
+        if (marker) {
+          const bullet = /\d/.test(marker[1]) ? `${marker[1].replace(')', '.')} ` : '- ';
+          items.push(bullet + trimmed.slice(marker[0].length));
+        } else if (items.length > 0) {
+          items[items.length - 1] += ' ' + trimmed;
+        }
+        i++;
+      }
+      blocks.push(items.map(item => {
+        const bullet = item.match(/^(\d+\.|-) /)![0];
+        return bullet + inline(item.slice(bullet.length));
+      }).join('\n'));
+    } else if (indented) {
+      let end = i;
+      while (end < lines.length && (/^\s/.test(lines[end]) || (!lines[end].trim() && /^\s/.test(lines[end + 1] ?? '')))) {
+        end++;
+      }
+      blocks.push(fence(dedent(lines.slice(i, end)), 'go'));
+      i = end;
+    } else {
+      const paragraph: string[] = [];
+      while (i < lines.length && lines[i].trim() && !/^\s/.test(lines[i]) && !GO_LINK_DEFINITION.test(lines[i])) {
+        paragraph.push(lines[i]);
+        i++;
+      }
+      blocks.push(inline(paragraph.join(' ')));
+    }
+  }
+  return blocks.join('\n\n');
+}
+
+function renderGoInline(text: string, definitions: Map<string, string>, resolve: DocLinkResolver): string {
+  return replaceOutsideUrls(text, /\[([^\]\n]+)\]/g, (match, label: string) => {
+    const url = definitions.get(label) ?? (GO_DOC_LINK.test(label) ? resolve(label) : undefined);
+    return url ? `[${escapeMarkdown(label)}](${url})` : escapeMarkdown(match);
+  });
+}
+
+function goLinkDefinitions(lines: string[]): Map<string, string> {
+  const definitions = new Map<string, string>();
+  for (const line of lines) {
+    const match = line.match(GO_LINK_DEFINITION);
+    if (match) {
+      definitions.set(match[1], match[2]);
+    }
+  }
+  return definitions;
+}
+
+// ---------------------------------------------------------------------------
+// JSDoc
+// ---------------------------------------------------------------------------
+
+function renderJsDoc(doc: string, resolve: DocLinkResolver): string {
+  const lines = doc.split('\n');
+  const tagStart = lines.findIndex(line => /^@\w+/.test(line.trim()));
+  const description = (tagStart === -1 ? lines : lines.slice(0, tagStart)).join('\n').trim();
+  const blocks: string[] = [];
+  if (description) {
+    blocks.push(renderJsDocLinks(description, resolve));
+  }
+
+  if (tagStart !== -1) {
+    const tags: string[][] = [];
+    for (const line of lines.slice(tagStart)) {
+      if (/^@\w+/.test(line.trim())) {
+        tags.push([line.trim()]);
+      } else {
+        tags[tags.length - 1].push(line);
+      }
+    }
+    for (const [first, ...rest] of tags) {
+      const [, tag, remainder] = first.match(/^@(\w+)\s*(.*)$/)!;
+      if (tag === 'example') {
+        const code = [remainder, ...rest].join('\n').trim();
+        blocks.push(`*@example*\n${code.startsWith('```') ? code : fence(code.split('\n'), 'typescript')}`);
+        continue;
+      }
+      const text = renderJsDocLinks([remainder, ...rest.map(line => line.trim())].join(' ').trim(), resolve);
+      const param = (tag === 'param' || tag === 'property' || tag === 'template')
+        ? text.match(/^(\{[^}]*\}\s*)?(\[[^\]]+\]|[\w$.]+)\s*(?:-\s*)?(.*)$/)
+        : null;
+      blocks.push(param
+        ? `*@${tag}* \`${param[2]}\`${param[3] ? ` — ${param[3]}` : ''}`
+        : `*@${tag}*${text ? ` — ${text}` : ''}`);
+    }
+  }
+  return blocks.join('\n\n');
+}
+
+function renderJsDocLinks(text: string, resolve: DocLinkResolver): string {
+  return text.replace(JSDOC_LINK, (_match, target: string, label: string) => {
+    const shown = label.trim() || target;
+    if (/^https?:/.test(target)) {
+      return `[${shown}](${target})`;
+    }
+    const url = resolve(normalizeJsDocTarget(target));
+    return url ? `[${shown}](${url})` : `\`${shown}\``;
+  });
+}
+
+/** `Foo#bar` and `Foo~bar` (JSDoc namepaths) -> `Foo.bar` */
+function normalizeJsDocTarget(target: string): string {
+  return target.replace(/[#~]/g, '.').replace(/\(\)$/, '');
+}
+
+// ---------------------------------------------------------------------------
+// Plain text
+// ---------------------------------------------------------------------------
+
+function renderPlainDoc(doc: string, resolve: DocLinkResolver): string {
+  const paragraphs = doc.split(/\n\s*\n/);
+  return paragraphs.map(paragraph => paragraph.split('\n').map(line => {
+    const indent = line.length - line.trimStart().length;
+    let last = 0;
+    let rendered = '';
+    for (const match of line.matchAll(SPHINX_ROLE)) {
+      const url = resolve(match[1]);
+      rendered += escapeMarkdown(line.slice(last, match.index)) + (url ? `[${match[1]}](${url})` : `\`${match[1]}\``);
+      last = match.index! + match[0].length;
+    }
+    rendered += escapeMarkdown(line.slice(last));
+    return '&nbsp;'.repeat(indent) + rendered.trimStart();
+  }).join('  \n')).join('\n\n');
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+function trimBlankLines(lines: string[]): string {
+  let start = 0;
+  let end = lines.length;
+  while (start < end && !lines[start].trim()) {
+    start++;
+  }
+  while (end > start && !lines[end - 1].trim()) {
+    end--;
+  }
+  return lines.slice(start, end).join('\n');
+}
+
+function dedent(lines: string[]): string[] {
+  let indent = Infinity;
+  for (const line of lines) {
+    if (line.trim()) {
+      indent = Math.min(indent, line.length - line.trimStart().length);
+    }
+  }
+  return lines.map(line => line.slice(Math.min(indent, line.length)));
+}
+
+function fence(lines: string[], language: string): string {
+  return ['```' + language, ...lines, '```'].join('\n');
+}
+
+/**
+ * Apply replace to text outside URLs and escape the rest for Markdown,
+ * leaving URLs intact so they stay auto-linked.
+ */
+function replaceOutsideUrls(text: string, pattern: RegExp, replace: (match: string, ...groups: any[]) => string): string {
+  let result = '';
+  let last = 0;
+  for (const url of text.matchAll(URL_PATTERN)) {
+    result += replaceAndEscape(text.slice(last, url.index), pattern, replace) + url[0];
+    last = url.index! + url[0].length;
+  }
+  return result + replaceAndEscape(text.slice(last), pattern, replace);
+}
+
+function replaceAndEscape(text: string, pattern: RegExp, replace: (match: string, ...groups: any[]) => string): string {
+  let result = '';
+  let last = 0;
+  for (const match of text.matchAll(pattern)) {
+    result += escapeMarkdown(text.slice(last, match.index)) + replace(match[0], ...match.slice(1));
+    last = match.index! + match[0].length;
+  }
+  return result + escapeMarkdown(text.slice(last));
+}
+
+function escapeMarkdown(text: string): string {
+  return text.replace(/[\\`*_<>[\]]/g, '\\$&').replace(/^#/, '\\#');
+}
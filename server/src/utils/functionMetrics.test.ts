@@ -0,0 +1,113 @@
+/**
+ * Function Metrics Tests
+ *
+ * Verifies complexity, size and nesting for Go, TypeScript and Python.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { attachFunctionMetrics, computeFunctionMetrics } from './functionMetrics.js';
+import { GoIndexer } from '../indexer/goIndexer.js';
+import { PythonIndexer } from '../indexer/pythonIndexer.js';
+
+describe('computeFunctionMetrics', () => {
+  it('should measure Go functions', () => {
+    const source = `func Classify(items []Item) (int, error) {
+	count := 0
+	// Skip the header row
+	for i, item := range items {
+		if i == 0 || item.Skip {
+			continue
+		}
+		switch item.Kind {
+		case "a", "b":
+			if err := item.Check(); err != nil && !item.Lenient {
+				return 0, err
+			}
+		case "c":
+			count++
+		default:
+		}
+	}
+
+	return count, nil
+}`;
+    // for, if, ||, case, if, &&, case
+    expect(computeFunctionMetrics(source, '/ws/items.go')).toEqual({ complexity: 8, loc: 18, nesting: 3 });
+  });
+
+  it('should measure TypeScript functions', () => {
+    const source = `function pick(users: User[], limit?: number): User[] {
+  const result = users.filter(u => { return u.active; });
+  for (let i = 0; i < result.length; i++) {
+    if (result[i].admin) return result;
+  }
+  const options = { strict: true };
+  try {
+    return result.slice(0, limit ?? 10);
+  } catch (e) {
+    return options.strict ? [] : result;
+  }
+}`;
+    // for, if, catch, ternary - not the optional parameter or ??
+    expect(computeFunctionMetrics(source, '/ws/pick.ts')).toEqual({ complexity: 5, loc: 12, nesting: 1 });
+  });
+
+  it('should measure Python functions by indentation', () => {
+    const source = `def load(paths, strict=False):
+    """Load every path.
+
+    # not a comment
+    """
+    loaded = []
+    for path in paths:
+        try:
+            with open(path) as f:
+                if f.readable() and not strict:
+                    loaded.append(f.read())
+        except OSError:
+            pass
+    return loaded if loaded else None`;
+    // for, if, and, except, conditional expression
+    expect(computeFunctionMetrics(source, '/ws/load.py')).toEqual({ complexity: 6, loc: 11, nesting: 4 });
+  });
+
+  it('should give straight-line code a complexity of 1', () => {
+    expect(computeFunctionMetrics('func Name() string {\n\treturn "x"\n}', '/ws/a.go'))
+      .toEqual({ complexity: 1, loc: 3, nesting: 0 });
+  });
+});
+
+describe('attachFunctionMetrics', () => {
+  it('should set metrics on functions and methods only', () => {
+    const content = `package user
+
+type Person struct {
+	Name string
+}
+
+func (p *Person) Greet(formal bool) string {
+	if formal {
+		return "Good day " + p.Name
+	}
+	return "hi " + p.Name
+}
+`;
+    const { symbols } = new GoIndexer().indexFile('/ws/user.go', content);
+    attachFunctionMetrics(content, symbols, '/ws/user.go');
+
+    const greet = symbols.find(s => s.name === 'Greet')!;
+    expect(greet.metrics).toEqual({ complexity: 2, loc: 6, nesting: 1 });
+    expect(symbols.find(s => s.name === 'Person')!.metrics).toBeUndefined();
+    expect(symbols.find(s => s.name === 'Name')!.metrics).toBeUndefined();
+  });
+
+  it('should measure Python methods', () => {
+    const content = `class Cart:
+    def total(self, items):
+        return sum(i.price for i in items if i.price)
+`;
+    const { symbols } = new PythonIndexer().parse('/ws/cart.py', content);
+    attachFunctionMetrics(content, symbols, '/ws/cart.py');
+    expect(symbols.find(s => s.name === 'total')!.metrics).toEqual({ complexity: 3, loc: 2, nesting: 0 });
+  });
+});
@@ -0,0 +1,149 @@
+import * as path from 'path';
+import { FunctionMetrics, IndexedSymbol } from '../types.js';
+import { rangeSource, SourceToken, tokenizeSource, usesHashComments } from './sourceTokens.js';
+
+/*
+ * Per-function metrics computed at indexing time from the token stream of
+ * the declaration's range (see sourceTokens.ts), the same way for every
+ * language:
+ * - complexity: McCabe's cyclomatic complexity, 1 plus one per `if`/`elif`,
+ *   loop, `case`, `catch`/`except`, ternary `?` and `&&`/`||`/`and`/`or`.
+ *   Closures count towards the function they are written in, as in gocyclo,
+ *   and also get metrics of their own.
+ * - loc: lines holding at least one token.
+ * - nesting: how many control-flow blocks deep the deepest statement is;
+ *   braces for C-like languages, indentation for Python.
+ */
+
+const METRIC_KINDS = new Set(['function', 'method', 'constructor', 'closure']);
+
+const DECISION_KEYWORDS = new Set(['if', 'elif', 'for', 'while', 'case', 'catch', 'except', 'and', 'or']);
+
+const DECISION_OPERATORS = new Set(['&&', '||']);
+
+/** Keywords whose block counts as a level of nesting */
+const BLOCK_KEYWORDS = new Set(['if', 'else', 'for', 'while', 'do', 'switch', 'select', 'try', 'catch', 'finally']);
+
+/** Tokens after `?` that make it an optional marker (`x?: T`, `f?()`) rather than a ternary */
+const OPTIONAL_MARKER_FOLLOWERS = new Set([':', ')', ',', '=', ';', '(']);
+
+/** Python statements that open a block */
+const PYTHON_BLOCK = /^(?:if|elif|else|for|while|with|try|except|finally|match|case|async\s+(?:for|with))\b.*:$/;
+
+/**
+ * Set `metrics` on the functions and methods of one file.
+ */
+export function attachFunctionMetrics(content: string, symbols: IndexedSymbol[], filePath: string): void {
+  if (path.extname(filePath).toLowerCase() === '.s') {
+    return; // Assembly: no control flow the lexer understands
+  }
+  let lines: string[] | undefined;
+  for (const symbol of symbols) {
+    if (symbol.isDefinition === false || !METRIC_KINDS.has(symbol.kind)) {
+      continue;
+    }
+    lines ??= content.split('\n');
+    const metrics = computeFunctionMetrics(rangeSource(lines, symbol.range), filePath);
+    if (metrics.loc > 0) {
+      symbol.metrics = metrics;
+    }
+  }
+}
+
+/**
+ * Metrics of one function's source text.
+ */
+export function computeFunctionMetrics(source: string, filePath: string): FunctionMetrics {
+  const python = usesHashComments(filePath);
+  const tokens = tokenizeSource(source, python);
+  return {
+    complexity: 1 + countDecisions(tokens),
+    loc: new Set(tokens.map(token => token.line)).size,
+    nesting: python ? indentationNesting(source) : braceNesting(tokens)
+  };
+}
+
+function countDecisions(tokens: SourceToken[]): number {
+  let decisions = 0;
+  for (let i = 0; i < tokens.length; i++) {
+    const { type, text } = tokens[i];
+    if (type === 'keyword' && DECISION_KEYWORDS.has(text)) {
+      decisions++;
+    } else if (type === 'operator') {
+      if (DECISION_OPERATORS.has(text)) {
+        decisions++;
+      } else if (text === '?' && i + 1 < tokens.length && !OPTIONAL_MARKER_FOLLOWERS.has(tokens[i + 1].text)) {
+        decisions++;
+      }
+    }
+  }
+  return decisions;
+}
+
+/**
+ * Nesting of brace blocks opened after a control keyword. Other braces
+ * (literals, closures) are tracked so they close correctly but add no
+ * level. A statement ending on a later line (`if (x) return;`) means the
+ * keyword had no block; Go's `if v := f(); v {` keeps it on one line.
+ */
+function braceNesting(tokens: SourceToken[]): number {
+  const blocks: boolean[] = [];
+  let depth = 0;
+  let deepest = 0;
+  let parens = 0;
+  let pendingControl = false;
+  let controlLine = 0;
+
+  for (const { type, text, line } of tokens) {
+    if (type === 'keyword' && BLOCK_KEYWORDS.has(text)) {
+      pendingControl = true;
+      controlLine = line;
+    } else if (text === '(' || text === '[') {
+      parens++;
+    } else if (text === ')' || text === ']') {
+      parens = Math.max(0, parens - 1);
+    } else if (text === ';' && parens === 0 && line !== controlLine) {
+      pendingControl = false;
+    } else if (text === '{') {
+      blocks.push(pendingControl);
+      if (pendingControl) {
+        depth++;
+        deepest = Math.max(deepest, depth);
+      }
+      pendingControl = false;
+    } else if (text === '}') {
+      if (blocks.pop()) {
+        depth--;
+      }
+    }
+  }
+
+  return deepest;
+}
+
+/**
+ * Nesting of Python block statements, by indentation. The first line is
+ * the `def` itself.
+ */
+function indentationNesting(source: string): number {
+  const open: number[] = [];
+  let deepest = 0;
+
+  for (const rawLine of source.split('\n').slice(1)) {
+    const line = rawLine.replace(/#.*$/, '').trimEnd();
+    const statement = line.trimStart();
+    if (statement === '') {
+      continue;
+    }
+    const indent = line.length - statement.length;
+    while (open.length > 0 && indent <= open[open.length - 1]) {
+      open.pop();
+    }
+    if (PYTHON_BLOCK.test(statement)) {
+      open.push(indent);
+      deepest = Math.max(deepest, open.length);
+    }
+  }
+
+  return deepest;
+}
@@ -0,0 +1,87 @@
+/**
+ * Fuzzy Search Tests
+ *
+ * Verifies match tiers, subsequence ranking, the ranking boosts and
+ * popularity.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { fuzzyScore, rankSymbols, toSubsequenceLikePattern } from './fuzzySearch.js';
+
+describe('fuzzyScore', () => {
+  it('should order match tiers by exactness', () => {
+    const exact = fuzzyScore('UserService', 'UserService')!.score;
+    const acronym = fuzzyScore('UserService', 'US')!.score;
+    const prefix = fuzzyScore('UserService', 'User')!.score;
+    const contains = fuzzyScore('UserService', 'Service')!.score;
+    const fuzzy = fuzzyScore('UserService', 'UsrSvc')!.score;
+
+    expect(exact).toBeGreaterThan(acronym);
+    expect(acronym).toBeGreaterThan(prefix);
+    expect(prefix).toBeGreaterThan(contains);
+    expect(contains).toBeGreaterThan(fuzzy);
+    expect(fuzzyScore('UserService', 'Xyz')).toBeNull();
+  });
+
+  it('should prefer the shorter name for the same subsequence', () => {
+    const person = fuzzyScore('NewPerson', 'NewPrsn')!.score;
+    const repository = fuzzyScore('NewPersonRepository', 'NewPrsn')!.score;
+    expect(person).toBeGreaterThan(repository);
+  });
+});
+
+describe('rankSymbols', () => {
+  it('should put NewPerson first for NewPrsn', () => {
+    const ranked = rankSymbols([
+      { name: 'newPrinterSession', kind: 'variable' },
+      { name: 'NewPersonRepository', kind: 'function', isExported: true },
+      { name: 'NewPerson', kind: 'function', isExported: true },
+      { name: 'renewPersonalInfo', kind: 'function' }
+    ], 'NewPrsn');
+
+    expect(ranked[0].symbol.name).toBe('NewPerson');
+  });
+
+  it('should boost exported symbols', () => {
+    const ranked = rankSymbols([
+      { name: 'parseConfig', kind: 'function' },
+      { name: 'parseConfig', kind: 'function', isExported: true, location: { uri: '/ws/b.ts' } }
+    ], 'parseConfig');
+
+    expect(ranked[0].symbol.isExported).toBe(true);
+  });
+
+  it('should rank symbols of similar match by popularity', () => {
+    const day = 24 * 60 * 60 * 1000;
+    const now = 100 * day;
+    const modified = new Map([['/ws/old.go', 0], ['/ws/fresh.go', now - day]]);
+    const popularity = {
+      referenceCounts: new Map([['NewServer', 120], ['NewStore', 9], ['NewSession', 9]]),
+      modifiedAt: (uri: string) => modified.get(uri),
+      now
+    };
+    const ranked = rankSymbols([
+      { name: 'NewSession', kind: 'function', isExported: true, location: { uri: '/ws/old.go' } },
+      { name: 'NewStore', kind: 'function', isExported: true, location: { uri: '/ws/fresh.go' } },
+      { name: 'NewServer', kind: 'function', isExported: true, location: { uri: '/ws/old.go' } },
+      { name: 'NewSpan', kind: 'function', isExported: true, location: { uri: '/ws/old.go' } }
+    ], 'New', { popularity });
+
+    expect(ranked.map(r => r.symbol.name)).toEqual(['NewServer', 'NewStore', 'NewSession', 'NewSpan']);
+    expect(ranked[1].popularity).toEqual({ score: 24.8, references: 9, recency: 9.8, exported: 5 });
+    expect(ranked[3].popularity).toEqual({ score: 6, references: 0, recency: 1, exported: 5 });
+    // Popularity breaks ties between equal matches but never beats an exact one
+    const exact = rankSymbols([
+      { name: 'NewServer', kind: 'function' },
+      { name: 'New', kind: 'function' }
+    ], 'New', { popularity: { referenceCounts: new Map([['NewServer', 100000]]) } });
+    expect(exact[0].symbol.name).toBe('New');
+  });
+});
+
+describe('toSubsequenceLikePattern', () => {
+  it('should interleave wildcards and escape LIKE metacharacters', () => {
+    expect(toSubsequenceLikePattern('NwP')).toBe('%N%w%P%');
+    expect(toSubsequenceLikePattern('a_b')).toBe('%a%\\_%b%');
+  });
+});
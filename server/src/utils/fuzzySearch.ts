@@ -6,6 +6,8 @@
  * - Character position (earlier matches score higher)
  */
 
+import { popularityScore, PopularityScore, PopularitySources } from './popularity.js';
+
 export interface FuzzyMatch {
   score: number;
   matches: number[]; // indices of matched characters
@@ -135,12 +137,24 @@ export function fuzzyScore(symbolName: string, query: string): FuzzyMatch | null
     }
   }
 
-  // Cap fuzzy score at 39 to ensure it's always lower than TIER 5
-  score = Math.min(score, 39);
+  // Cap fuzzy score at 39 to ensure it's always lower than TIER 5. The last
+  // 5 points reward covering more of the name, applied after the cap so that
+  // long queries still separate "NewPrsn" -> NewPerson from NewPersonRepository.
+  score = Math.min(score, 34) + 5 * (query.length / symbolName.length);
 
   return { score, matches };
 }
 
+/**
+ * SQL LIKE pattern matching names that contain the query's characters in
+ * order ("NewPrsn" -> "%N%e%w%P%r%s%n%"), for use with ESCAPE '\\'.
+ * Full-text/trigram indexes only find substrings, so fuzzy candidates that
+ * skip characters have to be fetched this way before fuzzyScore ranks them.
+ */
+export function toSubsequenceLikePattern(query: string): string {
+  return '%' + Array.from(query, char => (char === '%' || char === '_' || char === '\\' ? `\\${char}` : char)).join('%') + '%';
+}
+
 /**
  * Check if a query matches the CamelCase acronym of a symbol.
  * Example: "US" matches "UserService" (U + S at CamelCase boundaries)
@@ -222,22 +236,28 @@ function isWordBoundary(str: string, index: number): boolean {
  * Priority factors:
  * 1. Fuzzy match score
  * 2. Open files (dynamic index)
- * 3. Source code over node_modules/dist
+ * 3. Source code over node_modules/dist and Go dependencies
  * 4. Same directory as current file
  * 5. Definition priority (classes/interfaces over variables)
+ * 6. Exported symbols over module-private ones
+ * 7. Popularity: reference count and recency (with `context.popularity`,
+ *    which also covers 6; see popularity.ts)
  */
 export interface RankedSymbol<T> {
   symbol: T;
   score: number;
   matches: number[];
+  /** Set when ranked with `context.popularity`; included in score */
+  popularity?: PopularityScore;
 }
 
 export interface RankingContext {
   currentFileUri?: string; // Current file for proximity ranking
   openFiles?: Set<string>; // URIs of open files
+  popularity?: PopularitySources; // Reference counts and file times
 }
 
-export function rankSymbols<T extends { name: string; location?: { uri: string } | string; kind?: string }>(
+export function rankSymbols<T extends { name: string; location?: { uri: string } | string; kind?: string; isExported?: boolean }>(
   symbols: T[],
   query: string,
   context?: RankingContext
@@ -264,6 +284,12 @@ export function rankSymbols<T extends { name: string; location?: { uri: string }
         score -= 50;
       }
 
+      // Same penalty for Go dependencies (module cache, vendor)
+      if (uri.includes('/pkg/mod/') || uri.includes('\\pkg\\mod\\') ||
+          uri.includes('/vendor/') || uri.includes('\\vendor\\')) {
+        score -= 50;
+      }
+
       // Penalty for dist/out/build folders
       if (uri.includes('/dist/') || uri.includes('\\dist\\') ||
           uri.includes('/out/') || uri.includes('\\out\\') ||
@@ -297,10 +323,19 @@ export function rankSymbols<T extends { name: string; location?: { uri: string }
       }
     }
 
+    // Exported symbols are what callers usually look for
+    const popularity = context?.popularity ? popularityScore(symbol, context.popularity) : undefined;
+    if (popularity) {
+      score += popularity.score;
+    } else if (symbol.isExported) {
+      score += 5;
+    }
+
     ranked.push({
       symbol,
       score,
-      matches: match.matches
+      matches: match.matches,
+      ...(popularity && { popularity })
     });
   }
 
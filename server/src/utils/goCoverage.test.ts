@@ -0,0 +1,41 @@
+/**
+ * Go Coverage Profile Tests
+ *
+ * Verifies block parsing, merging of concatenated profiles and errors on
+ * files that are not coverage profiles.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { parseCoverProfile } from './goCoverage.js';
+
+describe('parseCoverProfile', () => {
+  it('should parse blocks per file', () => {
+    const profile = parseCoverProfile([
+      'mode: set',
+      'example.com/app/user/user.go:12.34,14.2 2 1',
+      'example.com/app/user/user.go:16.40,18.2 1 0',
+      'example.com/app/main.go:5.13,7.2 1 1',
+      ''
+    ].join('\n'));
+
+    expect(profile.mode).toBe('set');
+    expect([...profile.files.keys()]).toEqual(['example.com/app/user/user.go', 'example.com/app/main.go']);
+    expect(profile.files.get('example.com/app/user/user.go')).toEqual([
+      { startLine: 12, startColumn: 34, endLine: 14, endColumn: 2, statements: 2, count: 1 },
+      { startLine: 16, startColumn: 40, endLine: 18, endColumn: 2, statements: 1, count: 0 }
+    ]);
+  });
+
+  it('should merge blocks listed again by concatenated runs', () => {
+    const counted = parseCoverProfile('mode: count\r\na.go:1.10,3.2 1 2\r\nmode: count\r\na.go:1.10,3.2 1 3\r\n');
+    expect(counted.files.get('a.go')).toMatchObject([{ count: 5 }]);
+
+    const set = parseCoverProfile('mode: set\na.go:1.10,3.2 1 1\na.go:1.10,3.2 1 0\n');
+    expect(set.files.get('a.go')).toMatchObject([{ count: 1 }]);
+  });
+
+  it('should reject files that are not coverage profiles', () => {
+    expect(() => parseCoverProfile('PASS\nok  example.com/app 0.01s\n')).toThrow('Not a Go coverage profile');
+    expect(() => parseCoverProfile('mode: atomic\na.go:1.10,3.2 1 1\na.go:4 1 1\n')).toThrow('Malformed coverage block on line 3');
+  });
+});
@@ -0,0 +1,83 @@
+/*
+ * Go coverage profiles, as written by `go test -coverprofile`:
+ *
+ *   mode: set
+ *   example.com/app/internal/user/user.go:12.34,14.2 2 1
+ *   example.com/app/internal/user/user.go:16.40,18.2 1 0
+ *
+ * Each line after the mode is a block: file, start line.column, end
+ * line.column (one-based, columns in bytes), statements in the block and
+ * how often it ran. Files are named by import path, not by location, so
+ * they are matched to workspace files by path suffix.
+ */
+
+export type CoverageMode = 'set' | 'count' | 'atomic';
+
+export interface CoverageBlock {
+  /** One-based, as in the profile */
+  startLine: number;
+  startColumn: number;
+  endLine: number;
+  endColumn: number;
+  statements: number;
+  count: number;
+}
+
+export interface CoverageProfile {
+  mode: CoverageMode;
+  /** Blocks per profile file name, in profile order */
+  files: Map<string, CoverageBlock[]>;
+}
+
+const BLOCK_RE = /^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$/;
+
+/**
+ * Parse a coverage profile. Profiles concatenated from several runs (each
+ * with its own mode line) are merged: a block listed again adds its count,
+ * or in set mode is covered if any run covered it. Throws on a missing
+ * mode line or a malformed block line, with its one-based line number.
+ */
+export function parseCoverProfile(text: string): CoverageProfile {
+  const lines = text.split(/\r?\n/);
+  const first = lines.findIndex(line => line.trim() !== '');
+  const mode = /^mode: (set|count|atomic)$/.exec(lines[first]?.trim() ?? '')?.[1] as CoverageMode | undefined;
+  if (!mode) {
+    throw new Error('Not a Go coverage profile: expected "mode: set|count|atomic" on the first line');
+  }
+
+  const files = new Map<string, CoverageBlock[]>();
+  const seen = new Map<string, CoverageBlock>();
+  for (let i = first + 1; i < lines.length; i++) {
+    const line = lines[i].trim();
+    if (line === '' || line.startsWith('mode: ')) {
+      continue;
+    }
+    const match = BLOCK_RE.exec(line);
+    if (!match) {
+      throw new Error(`Malformed coverage block on line ${i + 1}: ${line}`);
+    }
+    const [, file, startLine, startColumn, endLine, endColumn, statements, count] = match;
+    const key = `${file}:${startLine}.${startColumn},${endLine}.${endColumn}`;
+    const existing = seen.get(key);
+    if (existing) {
+      existing.count = mode === 'set' ? Math.max(existing.count, Number(count)) : existing.count + Number(count);
+      continue;
+    }
+    const block: CoverageBlock = {
+      startLine: Number(startLine),
+      startColumn: Number(startColumn),
+      endLine: Number(endLine),
+      endColumn: Number(endColumn),
+      statements: Number(statements),
+      count: Number(count)
+    };
+    seen.set(key, block);
+    let blocks = files.get(file);
+    if (!blocks) {
+      blocks = [];
+      files.set(file, blocks);
+    }
+    blocks.push(block);
+  }
+  return { mode, files };
+}
@@ -0,0 +1,132 @@
+/**
+ * GraphQL Tests
+ *
+ * Verifies the executor: selections, arguments, variables, aliases,
+ * fragments and directives, validation errors with their location and
+ * null propagation of resolver errors.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { executeGraphQL, GraphQLSchema, printSchema } from './graphql.js';
+
+interface Person {
+  name: string;
+  age?: number;
+  friends: string[];
+}
+
+const people: Record<string, Person> = {
+  ada: { name: 'Ada', age: 36, friends: ['bob'] },
+  bob: { name: 'Bob', friends: ['ada', 'eve'] }
+};
+
+const schema: GraphQLSchema<{ calls: string[] }> = {
+  query: 'Query',
+  types: {
+    Query: {
+      fields: {
+        person: {
+          type: 'Person',
+          description: 'A person by id',
+          args: { id: { type: 'ID!' } },
+          resolve: (_parent, args, context) => {
+            context.calls.push(args.id);
+            return people[args.id] ?? null;
+          }
+        },
+        people: {
+          type: '[Person!]!',
+          args: { limit: { type: 'Int', defaultValue: 10 } },
+          resolve: (_parent, args) => Object.values(people).slice(0, args.limit)
+        },
+        broken: { type: 'String!', resolve: () => { throw new Error('boom'); } }
+      }
+    },
+    Person: {
+      fields: {
+        name: { type: 'String!' },
+        age: { type: 'Int' },
+        friends: {
+          type: '[Person!]!',
+          resolve: (person: Person) => person.friends.map(id => {
+            if (!people[id]) {
+              throw new Error(`No person ${id}`);
+            }
+            return people[id];
+          })
+        }
+      }
+    }
+  }
+};
+
+const run = (query: string, variables?: Record<string, unknown>, operationName?: string) =>
+  executeGraphQL(schema, { query, variables, operationName }, { calls: [] });
+
+describe('graphql', () => {
+  it('should resolve nested selections with arguments, aliases and defaults', async () => {
+    expect(await run('{ person(id: "ada") { name age friends { name } } all: people(limit: 1) { name } }')).toEqual({
+      data: {
+        person: { name: 'Ada', age: 36, friends: [{ name: 'Bob' }] },
+        all: [{ name: 'Ada' }]
+      }
+    });
+    expect(await run('{ people { __typename name } }')).toEqual({
+      data: { people: [{ __typename: 'Person', name: 'Ada' }, { __typename: 'Person', name: 'Bob' }] }
+    });
+  });
+
+  it('should take variables, fragments and directives', async () => {
+    const query = `
+      query Who($id: ID!, $withAge: Boolean = false) {
+        person(id: $id) { ...Names age @include(if: $withAge) }
+      }
+      query Everyone { people { name } }
+      fragment Names on Person { name ... on Person { friends { name } } }
+    `;
+    expect(await run(query, { id: 'ada', withAge: true }, 'Who')).toEqual({
+      data: { person: { name: 'Ada', friends: [{ name: 'Bob' }], age: 36 } }
+    });
+    expect(await run(query, { id: 'ada' }, 'Who')).toEqual({
+      data: { person: { name: 'Ada', friends: [{ name: 'Bob' }] } }
+    });
+    expect((await run(query, {}, 'Everyone')).data).toEqual({ people: [{ name: 'Ada' }, { name: 'Bob' }] });
+  });
+
+  it('should reject invalid documents with their location', async () => {
+    expect(await run('{\n  person(id: "ada") { nme }\n}')).toEqual({
+      errors: [{ message: 'Cannot query field "nme" on type "Person"', locations: [{ line: 2, column: 23 }] }]
+    });
+    expect((await run('{ person { name } }')).errors![0].message).toBe('Field "Query.person" needs the argument "id"');
+    expect((await run('{ person(id: "ada") }')).errors![0].message).toBe('Field "person" of type "Person" needs a selection of subfields');
+    expect((await run('{ people(limit: "2") { name } }')).data).toBeNull();
+    expect((await run('{ people { name }')).errors![0].message).toBe('Expected a name, found end of document');
+    expect((await run('mutation { people { name } }')).errors![0].message).toBe('Only queries are supported, not mutations');
+    expect((await run('query Q($id: ID!) { person(id: $id) { name } }')).errors![0].message)
+      .toBe('Variable "$id" of type "ID!" is required');
+    expect((await run('{ person(id: "ada") { ...F } } fragment F on Person { ...F }')).errors![0].message)
+      .toBe('Fragment "F" spreads itself');
+
+    const deep = '{ person(id: "ada") { friends { friends { friends { name } } } } }';
+    expect((await executeGraphQL(schema, { query: deep }, { calls: [] }, { maxDepth: 3 })).errors![0].message)
+      .toBe('Query is nested deeper than 3 levels');
+  });
+
+  it('should null failed fields and propagate through non-null ones', async () => {
+    const result = await run('{ person(id: "bob") { name friends { name } } }');
+    expect(result.data).toEqual({ person: null });
+    expect(result.errors).toEqual([{ message: 'No person eve', locations: [{ line: 1, column: 28 }], path: ['person', 'friends'] }]);
+
+    expect(await run('{ broken }')).toEqual({
+      data: null,
+      errors: [{ message: 'boom', locations: [{ line: 1, column: 3 }], path: ['broken'] }]
+    });
+    expect(await run('{ person(id: "nobody") { name } }')).toEqual({ data: { person: null } });
+  });
+
+  it('should print the schema as SDL', () => {
+    const sdl = printSchema(schema);
+    expect(sdl).toContain('type Query {\n  """A person by id"""\n  person(id: ID!): Person\n  people(limit: Int = 10): [Person!]!');
+    expect(sdl).toContain('type Person {\n  name: String!\n  age: Int\n  friends: [Person!]!\n}');
+  });
+});
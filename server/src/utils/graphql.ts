@@ -0,0 +1,1000 @@
+/*
+ * A small GraphQL executor for read-only schemas, enough for clients to
+ * fetch nested data in one request:
+ *
+ *   query Callers($name: String!) {
+ *     definitions(name: $name) {
+ *       name
+ *       refs: references(limit: 20) { location { uri line } enclosing { name owners } }
+ *     }
+ *   }
+ *
+ * Supported: queries (named or shorthand, `operationName` to pick one),
+ * arguments and defaults, variables, aliases, fragments and inline
+ * fragments, `@include`/`@skip`, `__typename`, the scalars String, Int,
+ * Float, Boolean and ID, lists and non-null types. Not supported:
+ * mutations, subscriptions, interfaces, unions, custom input types and
+ * introspection beyond `__typename` (printSchema renders the SDL instead).
+ *
+ * Documents are validated before anything runs: unknown fields, arguments
+ * and fragments, missing required arguments and selections, and queries
+ * nested deeper than `maxDepth` fail with their line and column. Resolver
+ * errors are reported per field with their `path`; the field becomes
+ * null, or its parent when it is non-null, as the spec says.
+ */
+
+/** A document that does not parse or validate; line and column are 1-based */
+export class GraphQLSyntaxError extends Error {
+  constructor(readonly reason: string, readonly line: number, readonly column: number) {
+    super(`${reason} at ${line}:${column}`);
+    this.name = 'GraphQLSyntaxError';
+  }
+}
+
+export interface GraphQLArgument {
+  /** SDL type, e.g. `String!` or `[String!]` */
+  type: string;
+  defaultValue?: unknown;
+  description?: string;
+}
+
+export interface GraphQLField<TContext> {
+  /** SDL type, e.g. `[Symbol!]!` */
+  type: string;
+  description?: string;
+  args?: Record<string, GraphQLArgument>;
+  /** Defaults to the parent's property of the field's name */
+  resolve?: (parent: any, args: any, context: TContext) => unknown;
+}
+
+export interface GraphQLObjectType<TContext> {
+  description?: string;
+  fields: Record<string, GraphQLField<TContext>>;
+}
+
+export interface GraphQLSchema<TContext> {
+  /** Name of the root query type */
+  query: string;
+  types: Record<string, GraphQLObjectType<TContext>>;
+}
+
+export interface GraphQLRequest {
+  query: string;
+  variables?: Record<string, unknown> | null;
+  operationName?: string | null;
+}
+
+export interface GraphQLErrorJson {
+  message: string;
+  locations?: Array<{ line: number; column: number }>;
+  path?: Array<string | number>;
+}
+
+export interface GraphQLResult {
+  /** Missing when the document did not parse or validate */
+  data?: Record<string, unknown> | null;
+  errors?: GraphQLErrorJson[];
+}
+
+export interface GraphQLOptions {
+  /** Deepest field nesting a query may select (default: 12) */
+  maxDepth?: number;
+}
+
+type TypeRef =
+  | { kind: 'named'; name: string }
+  | { kind: 'list'; of: TypeRef }
+  | { kind: 'nonNull'; of: TypeRef };
+
+type Value =
+  | { kind: 'variable'; name: string; position: number }
+  | { kind: 'literal'; value: unknown; position: number }
+  | { kind: 'list'; values: Value[]; position: number }
+  | { kind: 'object'; fields: Record<string, Value>; position: number };
+
+interface Directive {
+  name: string;
+  args: Record<string, Value>;
+  position: number;
+}
+
+type Selection =
+  | { kind: 'field'; alias?: string; name: string; args: Record<string, Value>; directives: Directive[]; selections?: Selection[]; position: number }
+  | { kind: 'spread'; name: string; directives: Directive[]; position: number }
+  | { kind: 'inline'; typeCondition?: string; directives: Directive[]; selections: Selection[]; position: number };
+
+type FieldNode = Extract<Selection, { kind: 'field' }>;
+
+interface VariableDefinition {
+  name: string;
+  type: TypeRef;
+  defaultValue?: Value;
+  position: number;
+}
+
+interface Operation {
+  name?: string;
+  variables: VariableDefinition[];
+  directives: Directive[];
+  selections: Selection[];
+  position: number;
+}
+
+interface Fragment {
+  name: string;
+  typeCondition: string;
+  selections: Selection[];
+  position: number;
+}
+
+interface Document {
+  operations: Operation[];
+  fragments: Map<string, Fragment>;
+  source: string;
+}
+
+type Token =
+  | { type: 'punctuator'; value: string; position: number }
+  | { type: 'name'; value: string; position: number }
+  | { type: 'int' | 'float'; value: number; position: number }
+  | { type: 'string'; value: string; position: number }
+  | { type: 'end'; position: number };
+
+const SCALARS = new Set(['String', 'Int', 'Float', 'Boolean', 'ID']);
+const DEFAULT_MAX_DEPTH = 12;
+const PUNCTUATORS = '!$():=@[]{|}';
+const ESCAPES: Record<string, string> = { '"': '"', '/': '/', b: '\b', f: '\f', n: '\n', r: '\r', t: '\t', '\\': '\\' };
+
+/**
+ * Parse, validate and run a query against a schema. Parse and validation
+ * errors come back as `errors` without `data`; resolver errors next to
+ * the data that could be resolved.
+ */
+export async function executeGraphQL<TContext>(
+  schema: GraphQLSchema<TContext>,
+  request: GraphQLRequest,
+  context: TContext,
+  options: GraphQLOptions = {}
+): Promise<GraphQLResult> {
+  let document: Document;
+  let operation: Operation;
+  let variables: Record<string, unknown>;
+  try {
+    document = parseDocument(request.query);
+    operation = selectOperation(document, request.operationName ?? undefined);
+    validate(schema, document, operation, options.maxDepth ?? DEFAULT_MAX_DEPTH);
+    variables = coerceVariables(document.source, operation, request.variables ?? {});
+  } catch (error) {
+    if (error instanceof GraphQLSyntaxError) {
+      return { errors: [{ message: error.reason, locations: [{ line: error.line, column: error.column }] }] };
+    }
+    throw error;
+  }
+
+  const execution = new Execution(schema, document, variables, context);
+  let data: Record<string, unknown> | null;
+  try {
+    data = await execution.selectionSet(schema.query, operation.selections, undefined, []);
+  } catch (error) {
+    if (error !== PROPAGATED) {
+      throw error;
+    }
+    data = null;
+  }
+  return execution.errors.length > 0 ? { data, errors: execution.errors } : { data };
+}
+
+/** The schema in GraphQL SDL, descriptions included */
+export function printSchema<TContext>(schema: GraphQLSchema<TContext>): string {
+  const types = Object.entries(schema.types);
+  const blocks = [`schema {\n  query: ${schema.query}\n}`];
+  for (const [name, type] of types) {
+    const lines: string[] = [];
+    if (type.description) {
+      lines.push(`"""${type.description}"""`);
+    }
+    lines.push(`type ${name} {`);
+    for (const [fieldName, field] of Object.entries(type.fields)) {
+      if (field.description) {
+        lines.push(`  """${field.description}"""`);
+      }
+      const args = Object.entries(field.args ?? {}).map(([argName, arg]) =>
+        `${argName}: ${arg.type}${arg.defaultValue !== undefined ? ` = ${JSON.stringify(arg.defaultValue)}` : ''}`
+      );
+      lines.push(`  ${fieldName}${args.length > 0 ? `(${args.join(', ')})` : ''}: ${field.type}`);
+    }
+    lines.push('}');
+    blocks.push(lines.join('\n'));
+  }
+  return blocks.join('\n\n') + '\n';
+}
+
+/** Thrown up to the nearest nullable field once a non-null one failed */
+const PROPAGATED = Symbol('propagated');
+
+class Execution<TContext> {
+  readonly errors: GraphQLErrorJson[] = [];
+
+  constructor(
+    private schema: GraphQLSchema<TContext>,
+    private document: Document,
+    private variables: Record<string, unknown>,
+    private context: TContext
+  ) {}
+
+  async selectionSet(
+    typeName: string,
+    selections: Selection[],
+    parent: unknown,
+    path: Array<string | number>
+  ): Promise<Record<string, unknown>> {
+    const fields = new Map<string, FieldNode[]>();
+    this.collectFields(typeName, selections, fields, new Set());
+    const keys = [...fields.keys()];
+    const values = await Promise.all(keys.map(key => this.field(typeName, fields.get(key)!, parent, [...path, key])));
+    const result: Record<string, unknown> = {};
+    keys.forEach((key, i) => {
+      result[key] = values[i];
+    });
+    return result;
+  }
+
+  private collectFields(typeName: string, selections: Selection[], fields: Map<string, FieldNode[]>, visited: Set<string>): void {
+    for (const selection of selections) {
+      if (!this.included(selection.directives)) {
+        continue;
+      }
+      if (selection.kind === 'field') {
+        const key = selection.alias ?? selection.name;
+        fields.set(key, [...(fields.get(key) ?? []), selection]);
+      } else if (selection.kind === 'inline') {
+        if (!selection.typeCondition || selection.typeCondition === typeName) {
+          this.collectFields(typeName, selection.selections, fields, visited);
+        }
+      } else if (!visited.has(selection.name)) {
+        visited.add(selection.name);
+        const fragment = this.document.fragments.get(selection.name)!;
+        if (fragment.typeCondition === typeName) {
+          this.collectFields(typeName, fragment.selections, fields, visited);
+        }
+      }
+    }
+  }
+
+  private included(directives: Directive[]): boolean {
+    for (const directive of directives) {
+      const condition = valueOf(directive.args.if, this.variables);
+      if ((directive.name === 'skip' && condition === true) || (directive.name === 'include' && condition === false)) {
+        return false;
+      }
+    }
+    return true;
+  }
+
+  private async field(typeName: string, nodes: FieldNode[], parent: unknown, path: Array<string | number>): Promise<unknown> {
+    const node = nodes[0];
+    if (node.name === '__typename') {
+      return typeName;
+    }
+    const field = this.schema.types[typeName].fields[node.name];
+    const type = parseTypeRef(field.type);
+    try {
+      const args = coerceArguments(field, node.args, this.variables);
+      const value = field.resolve
+        ? await field.resolve(parent, args, this.context)
+        : (parent as Record<string, unknown> | undefined)?.[node.name];
+      return await this.complete(type, nodes, value, path);
+    } catch (error) {
+      if (error !== PROPAGATED) {
+        const message = error instanceof Error ? error.message : String(error);
+        this.errors.push({ message, locations: [location(this.document.source, node.position)], path });
+      }
+      if (type.kind === 'nonNull') {
+        throw PROPAGATED;
+      }
+      return null;
+    }
+  }
+
+  private async complete(type: TypeRef, nodes: FieldNode[], value: unknown, path: Array<string | number>): Promise<unknown> {
+    if (type.kind === 'nonNull') {
+      const completed = await this.complete(type.of, nodes, value, path);
+      if (completed === null) {
+        throw new Error(`Cannot return null for non-nullable field ${path.filter(key => typeof key === 'string').join('.')}`);
+      }
+      return completed;
+    }
+    if (value === null || value === undefined) {
+      return null;
+    }
+    if (type.kind === 'list') {
+      if (!Array.isArray(value)) {
+        throw new Error(`Expected a list for field ${String(path[path.length - 1])}`);
+      }
+      const itemType = type.of;
+      return Promise.all(value.map(async (item, i) => {
+        try {
+          return await this.complete(itemType, nodes, item, [...path, i]);
+        } catch (error) {
+          if (error !== PROPAGATED) {
+            const message = error instanceof Error ? error.message : String(error);
+            this.errors.push({ message, locations: [location(this.document.source, nodes[0].position)], path: [...path, i] });
+          }
+          if (itemType.kind === 'nonNull') {
+            throw PROPAGATED;
+          }
+          return null;
+        }
+      }));
+    }
+    if (SCALARS.has(type.name)) {
+      return serializeScalar(type.name, value);
+    }
+    const selections = nodes.flatMap(node => node.selections ?? []);
+    return this.selectionSet(type.name, selections, value, path);
+  }
+}
+
+function serializeScalar(name: string, value: unknown): unknown {
+  switch (name) {
+    case 'Int':
+    case 'Float': {
+      const number = typeof value === 'number' ? value : Number(value);
+      if (!Number.isFinite(number)) {
+        throw new Error(`${name} cannot represent ${JSON.stringify(value)}`);
+      }
+      return name === 'Int' ? Math.trunc(number) : number;
+    }
+    case 'Boolean':
+      return Boolean(value);
+    default:
+      return typeof value === 'object' ? JSON.stringify(value) : String(value);
+  }
+}
+
+// ---------------------------------------------------------------------------
+// Validation
+// ---------------------------------------------------------------------------
+
+function validate<TContext>(schema: GraphQLSchema<TContext>, document: Document, operation: Operation, maxDepth: number): void {
+  const fail = (message: string, position: number): never => {
+    const { line, column } = location(document.source, position);
+    throw new GraphQLSyntaxError(message, line, column);
+  };
+
+  for (const fragment of document.fragments.values()) {
+    if (!schema.types[fragment.typeCondition]) {
+      fail(`Unknown type "${fragment.typeCondition}"`, fragment.position);
+    }
+  }
+  const declared = new Set(operation.variables.map(variable => variable.name));
+  for (const variable of operation.variables) {
+    const named = namedType(variable.type);
+    if (!SCALARS.has(named)) {
+      fail(`Variable "$${variable.name}" must have a scalar type, not "${named}"`, variable.position);
+    }
+  }
+
+  const checkValue = (value: Value | undefined): void => {
+    if (!value) {
+      return;
+    }
+    if (value.kind === 'variable' && !declared.has(value.name)) {
+      fail(`Variable "$${value.name}" is not defined`, value.position);
+    } else if (value.kind === 'list') {
+      value.values.forEach(checkValue);
+    } else if (value.kind === 'object') {
+      Object.values(value.fields).forEach(checkValue);
+    }
+  };
+  const checkDirectives = (directives: Directive[]): void => {
+    for (const directive of directives) {
+      if (directive.name !== 'include' && directive.name !== 'skip') {
+        fail(`Unknown directive "@${directive.name}"`, directive.position);
+      }
+      if (!directive.args.if) {
+        fail(`Directive "@${directive.name}" needs the argument "if"`, directive.position);
+      }
+      checkValue(directive.args.if);
+    }
+  };
+
+  const visit = (typeName: string, selections: Selection[], depth: number, fragments: string[]): void => {
+    const type = schema.types[typeName];
+    for (const selection of selections) {
+      checkDirectives(selection.directives);
+      if (selection.kind === 'spread') {
+        const fragment = document.fragments.get(selection.name);
+        if (!fragment) {
+          fail(`Unknown fragment "${selection.name}"`, selection.position);
+          return;
+        }
+        if (fragments.includes(selection.name)) {
+          fail(`Fragment "${selection.name}" spreads itself`, selection.position);
+        }
+        if (fragment.typeCondition !== typeName) {
+          fail(`Fragment "${selection.name}" on "${fragment.typeCondition}" cannot be spread on type "${typeName}"`, selection.position);
+        }
+        visit(typeName, fragment.selections, depth, [...fragments, selection.name]);
+        continue;
+      }
+      if (selection.kind === 'inline') {
+        if (selection.typeCondition && selection.typeCondition !== typeName) {
+          fail(`Fragment on "${selection.typeCondition}" cannot be spread on type "${typeName}"`, selection.position);
+        }
+        visit(typeName, selection.selections, depth, fragments);
+        continue;
+      }
+
+      if (selection.name === '__typename') {
+        if (selection.selections) {
+          fail('Field "__typename" must not have a selection', selection.position);
+        }
+        continue;
+      }
+      const field = type.fields[selection.name];
+      if (!field) {
+        fail(`Cannot query field "${selection.name}" on type "${typeName}"`, selection.position);
+        return;
+      }
+      for (const [name, value] of Object.entries(selection.args)) {
+        if (!field.args?.[name]) {
+          fail(`Unknown argument "${name}" on field "${typeName}.${selection.name}"`, value.position);
+        }
+        checkValue(value);
+      }
+      for (const [name, arg] of Object.entries(field.args ?? {})) {
+        if (parseTypeRef(arg.type).kind === 'nonNull' && arg.defaultValue === undefined && !selection.args[name]) {
+          fail(`Field "${typeName}.${selection.name}" needs the argument "${name}"`, selection.position);
+        }
+      }
+      const fieldType = namedType(parseTypeRef(field.type));
+      if (SCALARS.has(fieldType)) {
+        if (selection.selections) {
+          fail(`Field "${selection.name}" of type "${field.type}" must not have a selection`, selection.position);
+        }
+        continue;
+      }
+      if (!selection.selections) {
+        fail(`Field "${selection.name}" of type "${field.type}" needs a selection of subfields`, selection.position);
+        return;
+      }
+      if (depth + 1 > maxDepth) {
+        fail(`Query is nested deeper than ${maxDepth} levels`, selection.position);
+      }
+      visit(fieldType, selection.selections, depth + 1, fragments);
+    }
+  };
+
+  checkDirectives(operation.directives);
+  visit(schema.query, operation.selections, 1, []);
+}
+
+function selectOperation(document: Document, operationName: string | undefined): Operation {
+  const fail = (message: string, position: number): never => {
+    const { line, column } = location(document.source, position);
+    throw new GraphQLSyntaxError(message, line, column);
+  };
+  if (document.operations.length === 0) {
+    fail('The document has no query', 0);
+  }
+  if (operationName) {
+    const operation = document.operations.find(op => op.name === operationName);
+    return operation ?? fail(`Unknown operation "${operationName}"`, 0);
+  }
+  if (document.operations.length > 1) {
+    fail('The document has several operations; pick one with "operationName"', document.operations[1].position);
+  }
+  return document.operations[0];
+}
+
+// ---------------------------------------------------------------------------
+// Values
+// ---------------------------------------------------------------------------
+
+function coerceVariables(source: string, operation: Operation, provided: Record<string, unknown>): Record<string, unknown> {
+  const variables: Record<string, unknown> = {};
+  for (const variable of operation.variables) {
+    const fail = (message: string): never => {
+      const { line, column } = location(source, variable.position);
+      throw new GraphQLSyntaxError(message, line, column);
+    };
+    let value = Object.prototype.hasOwnProperty.call(provided, variable.name)
+      ? provided[variable.name]
+      : variable.defaultValue ? valueOf(variable.defaultValue, {}) : undefined;
+    if (value === undefined || value === null) {
+      if (variable.type.kind === 'nonNull') {
+        fail(`Variable "$${variable.name}" of type "${printTypeRef(variable.type)}" is required`);
+      }
+      value = value === null ? null : undefined;
+    } else {
+      try {
+        value = coerceInput(value, variable.type);
+      } catch (error) {
+        fail(`Variable "$${variable.name}": ${error instanceof Error ? error.message : String(error)}`);
+      }
+    }
+    if (value !== undefined) {
+      variables[variable.name] = value;
+    }
+  }
+  return variables;
+}
+
+function coerceArguments<TContext>(
+  field: GraphQLField<TContext>,
+  nodes: Record<string, Value>,
+  variables: Record<string, unknown>
+): Record<string, unknown> {
+  const args: Record<string, unknown> = {};
+  for (const [name, arg] of Object.entries(field.args ?? {})) {
+    const type = parseTypeRef(arg.type);
+    const node = nodes[name];
+    let value = node ? valueOf(node, variables) : undefined;
+    if (value === undefined) {
+      value = arg.defaultValue;
+    }
+    if (value === undefined || value === null) {
+      if (type.kind === 'nonNull') {
+        throw new Error(`Argument "${name}" of type "${arg.type}" is required`);
+      }
+      continue;
+    }
+    try {
+      args[name] = coerceInput(value, type);
+    } catch (error) {
+      throw new Error(`Argument "${name}": ${error instanceof Error ? error.message : String(error)}`);
+    }
+  }
+  return args;
+}
+
+/** Input coercion: scalars checked, single values accepted for lists */
+function coerceInput(value: unknown, type: TypeRef): unknown {
+  if (type.kind === 'nonNull') {
+    if (value === null || value === undefined) {
+      throw new Error(`expected ${printTypeRef(type)}, got null`);
+    }
+    return coerceInput(value, type.of);
+  }
+  if (value === null || value === undefined) {
+    return null;
+  }
+  if (type.kind === 'list') {
+    return (Array.isArray(value) ? value : [value]).map(item => coerceInput(item, type.of));
+  }
+  switch (type.name) {
+    case 'Int':
+      if (typeof value !== 'number' || !Number.isInteger(value)) {
+        throw new Error(`Int cannot represent ${JSON.stringify(value)}`);
+      }
+      return value;
+    case 'Float':
+      if (typeof value !== 'number') {
+        throw new Error(`Float cannot represent ${JSON.stringify(value)}`);
+      }
+      return value;
+    case 'Boolean':
+      if (typeof value !== 'boolean') {
+        throw new Error(`Boolean cannot represent ${JSON.stringify(value)}`);
+      }
+      return value;
+    case 'ID':
+      if (typeof value !== 'string' && !(typeof value === 'number' && Number.isInteger(value))) {
+        throw new Error(`ID cannot represent ${JSON.stringify(value)}`);
+      }
+      return String(value);
+    default:
+      if (typeof value !== 'string') {
+        throw new Error(`String cannot represent ${JSON.stringify(value)}`);
+      }
+      return value;
+  }
+}
+
+function valueOf(value: Value | undefined, variables: Record<string, unknown>): unknown {
+  if (!value) {
+    return undefined;
+  }
+  switch (value.kind) {
+    case 'variable':
+      return variables[value.name];
+    case 'literal':
+      return value.value;
+    case 'list':
+      return value.values.map(item => valueOf(item, variables));
+    case 'object': {
+      const result: Record<string, unknown> = {};
+      for (const [key, field] of Object.entries(value.fields)) {
+        result[key] = valueOf(field, variables);
+      }
+      return result;
+    }
+  }
+}
+
+// ---------------------------------------------------------------------------
+// Types
+// ---------------------------------------------------------------------------
+
+const typeRefs = new Map<string, TypeRef>();
+
+function parseTypeRef(text: string): TypeRef {
+  let type = typeRefs.get(text);
+  if (!type) {
+    const parser = new Parser(text);
+    type = parser.typeRef();
+    parser.expectEnd();
+    typeRefs.set(text, type);
+  }
+  return type;
+}
+
+function namedType(type: TypeRef): string {
+  return type.kind === 'named' ? type.name : namedType(type.of);
+}
+
+function printTypeRef(type: TypeRef): string {
+  switch (type.kind) {
+    case 'named':
+      return type.name;
+    case 'list':
+      return `[${printTypeRef(type.of)}]`;
+    case 'nonNull':
+      return `${printTypeRef(type.of)}!`;
+  }
+}
+
+function location(source: string, position: number): { line: number; column: number } {
+  let line = 1;
+  let lineStart = 0;
+  for (let i = 0; i < position && i < source.length; i++) {
+    if (source[i] === '\n') {
+      line++;
+      lineStart = i + 1;
+    }
+  }
+  return { line, column: position - lineStart + 1 };
+}
+
+// ---------------------------------------------------------------------------
+// Parsing
+// ---------------------------------------------------------------------------
+
+function parseDocument(source: string): Document {
+  const parser = new Parser(source);
+  const document: Document = { operations: [], fragments: new Map(), source };
+  while (!parser.atEnd()) {
+    if (parser.peekName('fragment')) {
+      const fragment = parser.fragment();
+      if (document.fragments.has(fragment.name)) {
+        parser.fail(`Fragment "${fragment.name}" is defined twice`, fragment.position);
+      }
+      document.fragments.set(fragment.name, fragment);
+    } else {
+      document.operations.push(parser.operation());
+    }
+  }
+  return document;
+}
+
+class Parser {
+  private tokens: Token[];
+  private index = 0;
+
+  constructor(private source: string) {
+    this.tokens = tokenize(source);
+  }
+
+  fail(message: string, position: number): never {
+    const { line, column } = location(this.source, position);
+    throw new GraphQLSyntaxError(message, line, column);
+  }
+
+  atEnd(): boolean {
+    return this.peek().type === 'end';
+  }
+
+  expectEnd(): void {
+    if (!this.atEnd()) {
+      this.unexpected();
+    }
+  }
+
+  peekName(name?: string): boolean {
+    const token = this.peek();
+    return token.type === 'name' && (name === undefined || token.value === name);
+  }
+
+  operation(): Operation {
+    const position = this.peek().position;
+    if (this.peekPunctuator('{')) {
+      return { variables: [], directives: [], selections: this.selectionSet(), position };
+    }
+    const keyword = this.name();
+    if (keyword === 'mutation' || keyword === 'subscription') {
+      this.fail(`Only queries are supported, not ${keyword}s`, position);
+    }
+    if (keyword !== 'query') {
+      this.fail(`Unexpected "${keyword}"`, position);
+    }
+    const name = this.peekName() ? this.name() : undefined;
+    const variables: VariableDefinition[] = [];
+    if (this.skipPunctuator('(')) {
+      while (!this.skipPunctuator(')')) {
+        const variablePosition = this.expectPunctuator('$').position;
+        const variableName = this.name();
+        this.expectPunctuator(':');
+        const type = this.typeRef();
+        const defaultValue = this.skipPunctuator('=') ? this.value(true) : undefined;
+        variables.push({ name: variableName, type, defaultValue, position: variablePosition });
+      }
+    }
+    return { name, variables, directives: this.directives(), selections: this.selectionSet(), position };
+  }
+
+  fragment(): Fragment {
+    const position = this.peek().position;
+    this.name();
+    const name = this.name();
+    if (name === 'on') {
+      this.fail('A fragment cannot be named "on"', position);
+    }
+    this.expectName('on');
+    const typeCondition = this.name();
+    this.directives();
+    return { name, typeCondition, selections: this.selectionSet(), position };
+  }
+
+  typeRef(): TypeRef {
+    let type: TypeRef;
+    if (this.skipPunctuator('[')) {
+      type = { kind: 'list', of: this.typeRef() };
+      this.expectPunctuator(']');
+    } else {
+      type = { kind: 'named', name: this.name() };
+    }
+    return this.skipPunctuator('!') ? { kind: 'nonNull', of: type } : type;
+  }
+
+  private selectionSet(): Selection[] {
+    this.expectPunctuator('{');
+    const selections: Selection[] = [];
+    while (!this.skipPunctuator('}')) {
+      selections.push(this.selection());
+    }
+    if (selections.length === 0) {
+      this.fail('Empty selection', this.tokens[this.index - 1].position);
+    }
+    return selections;
+  }
+
+  private selection(): Selection {
+    const position = this.peek().position;
+    if (this.skipPunctuator('...')) {
+      if (this.peekName() && !this.peekName('on')) {
+        return { kind: 'spread', name: this.name(), directives: this.directives(), position };
+      }
+      const typeCondition = this.skipName('on') ? this.name() : undefined;
+      return { kind: 'inline', typeCondition, directives: this.directives(), selections: this.selectionSet(), position };
+    }
+    let name = this.name();
+    let alias: string | undefined;
+    if (this.skipPunctuator(':')) {
+      alias = name;
+      name = this.name();
+    }
+    const args = this.arguments(false);
+    const directives = this.directives();
+    const selections = this.peekPunctuator('{') ? this.selectionSet() : undefined;
+    return { kind: 'field', alias, name, args, directives, selections, position };
+  }
+
+  private arguments(constant: boolean): Record<string, Value> {
+    const args: Record<string, Value> = {};
+    if (this.skipPunctuator('(')) {
+      while (!this.skipPunctuator(')')) {
+        const position = this.peek().position;
+        const name = this.name();
+        if (args[name]) {
+          this.fail(`Argument "${name}" is given twice`, position);
+        }
+        this.expectPunctuator(':');
+        args[name] = this.value(constant);
+      }
+    }
+    return args;
+  }
+
+  private directives(): Directive[] {
+    const directives: Directive[] = [];
+    while (this.peekPunctuator('@')) {
+      const position = this.next().position;
+      directives.push({ name: this.name(), args: this.arguments(false), position });
+    }
+    return directives;
+  }
+
+  private value(constant: boolean): Value {
+    const token = this.next();
+    const position = token.position;
+    switch (token.type) {
+      case 'int':
+      case 'float':
+      case 'string':
+        return { kind: 'literal', value: token.value, position };
+      case 'name':
+        // Enum values are passed on as their names
+        return { kind: 'literal', value: token.value === 'true' ? true : token.value === 'false' ? false : token.value === 'null' ? null : token.value, position };
+      case 'punctuator':
+        if (token.value === '$' && !constant) {
+          return { kind: 'variable', name: this.name(), position };
+        }
+        if (token.value === '[') {
+          const values: Value[] = [];
+          while (!this.skipPunctuator(']')) {
+            values.push(this.value(constant));
+          }
+          return { kind: 'list', values, position };
+        }
+        if (token.value === '{') {
+          const fields: Record<string, Value> = {};
+          while (!this.skipPunctuator('}')) {
+            const name = this.name();
+            this.expectPunctuator(':');
+            fields[name] = this.value(constant);
+          }
+          return { kind: 'object', fields, position };
+        }
+    }
+    this.index--;
+    return this.unexpected();
+  }
+
+  private name(): string {
+    const token = this.peek();
+    if (token.type !== 'name') {
+      this.unexpected('a name');
+    }
+    this.index++;
+    return (token as { value: string }).value;
+  }
+
+  private expectName(name: string): void {
+    if (!this.skipName(name)) {
+      this.unexpected(`"${name}"`);
+    }
+  }
+
+  private skipName(name: string): boolean {
+    if (this.peekName(name)) {
+      this.index++;
+      return true;
+    }
+    return false;
+  }
+
+  private peekPunctuator(value: string): boolean {
+    const token = this.peek();
+    return token.type === 'punctuator' && token.value === value;
+  }
+
+  private skipPunctuator(value: string): boolean {
+    if (this.peekPunctuator(value)) {
+      this.index++;
+      return true;
+    }
+    return false;
+  }
+
+  private expectPunctuator(value: string): Token {
+    if (!this.peekPunctuator(value)) {
+      this.unexpected(`"${value}"`);
+    }
+    return this.next();
+  }
+
+  private peek(): Token {
+    return this.tokens[this.index];
+  }
+
+  private next(): Token {
+    const token = this.tokens[this.index];
+    if (token.type !== 'end') {
+      this.index++;
+    }
+    return token;
+  }
+
+  private unexpected(expected?: string): never {
+    const token = this.peek();
+    const found = token.type === 'end' ? 'end of document' : `"${this.source.slice(token.position, this.tokenEnd(token))}"`;
+    return this.fail(expected ? `Expected ${expected}, found ${found}` : `Unexpected ${found}`, token.position);
+  }
+
+  private tokenEnd(token: Token): number {
+    const next = this.tokens[this.tokens.indexOf(token) + 1];
+    return next ? Math.min(next.position, token.position + 40) : token.position + 1;
+  }
+}
+
+function tokenize(source: string): Token[] {
+  const tokens: Token[] = [];
+  const fail = (message: string, position: number): never => {
+    const { line, column } = location(source, position);
+    throw new GraphQLSyntaxError(message, line, column);
+  };
+  let i = 0;
+  while (i < source.length) {
+    const char = source[i];
+    if (char === ' ' || char === '\t' || char === '\n' || char === '\r' || char === ',' || char === '\uFEFF') {
+      i++;
+    } else if (char === '#') {
+      while (i < source.length && source[i] !== '\n') {
+        i++;
+      }
+    } else if (source.startsWith('...', i)) {
+      tokens.push({ type: 'punctuator', value: '...', position: i });
+      i += 3;
+    } else if (PUNCTUATORS.includes(char)) {
+      tokens.push({ type: 'punctuator', value: char, position: i });
+      i++;
+    } else if (/[A-Za-z_]/.test(char)) {
+      const start = i;
+      while (i < source.length && /\w/.test(source[i])) {
+        i++;
+      }
+      tokens.push({ type: 'name', value: source.slice(start, i), position: start });
+    } else if (/[-\d]/.test(char)) {
+      const match = /^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?/.exec(source.slice(i));
+      if (!match) {
+        fail('Invalid number', i);
+      }
+      const text = match![0];
+      tokens.push({ type: match![2] || match![3] ? 'float' : 'int', value: Number(text), position: i });
+      i += text.length;
+    } else if (char === '"') {
+      if (source.startsWith('"""', i)) {
+        const end = source.indexOf('"""', i + 3);
+        if (end < 0) {
+          fail('Unterminated string', i);
+        }
+        tokens.push({ type: 'string', value: source.slice(i + 3, end).trim(), position: i });
+        i = end + 3;
+        continue;
+      }
+      const start = i++;
+      let value = '';
+      while (source[i] !== '"') {
+        if (i >= source.length || source[i] === '\n') {
+          fail('Unterminated string', start);
+        }
+        if (source[i] === '\\') {
+          const escape = source[i + 1];
+          if (escape === 'u') {
+            const hex = source.slice(i + 2, i + 6);
+            if (!/^[0-9a-fA-F]{4}$/.test(hex)) {
+              fail('Invalid unicode escape', i);
+            }
+            value += String.fromCharCode(parseInt(hex, 16));
+            i += 6;
+            continue;
+          }
+          if (!(escape in ESCAPES)) {
+            fail(`Invalid escape "${escape}"`, i);
+          }
+          value += ESCAPES[escape];
+          i += 2;
+          continue;
+        }
+        value += source[i++];
+      }
+      i++;
+      tokens.push({ type: 'string', value, position: start });
+    } else {
+      fail(`Unexpected character "${char}"`, i);
+    }
+  }
+  tokens.push({ type: 'end', position: source.length });
+  return tokens;
+}
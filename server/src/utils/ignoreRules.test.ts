@@ -0,0 +1,125 @@
+/**
+ * IgnoreRules Tests
+ *
+ * Verifies gitignore syntax, precedence between ignore sources, the
+ * built-in vendor/testdata rules and generated-code detection.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import * as path from 'path';
+import { IgnoreRules, IgnoreRulesOptions, isGeneratedSource } from './ignoreRules.js';
+
+const root = path.join(path.sep, 'ws');
+
+function p(relative: string): string {
+  return path.join(root, ...relative.split('/'));
+}
+
+describe('IgnoreRules', () => {
+  let files: Map<string, string>;
+  let options: IgnoreRulesOptions;
+
+  beforeEach(() => {
+    files = new Map();
+    options = { gitignore: true, vendor: true, testdata: true, patterns: [] };
+  });
+
+  function rules(): IgnoreRules {
+    return new IgnoreRules(root, options, filePath => files.get(filePath) ?? null);
+  }
+
+  it('should follow gitignore pattern syntax', () => {
+    files.set(p('.gitignore'), [
+      '# build output',
+      '*.log',
+      '/generated',
+      'tmp/',
+      'docs/**/*.draft.md',
+      '**/fixtures/big',
+      'file[0-9].ts',
+      '\\#hash.ts',
+      'keep.log.bak   ',
+      '*.snap',
+      '!important.snap'
+    ].join('\n'));
+    const ignore = rules();
+
+    expect(ignore.isIgnored(p('debug.log'))).toBe(true);
+    expect(ignore.isIgnored(p('src/deep/trace.log'))).toBe(true);
+    expect(ignore.isIgnored(p('generated/api.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('src/generated/api.ts'))).toBe(false); // anchored
+    expect(ignore.isIgnored(p('src/tmp/cache.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('src/tmp'))).toBe(false); // a file named tmp
+    expect(ignore.isIgnored(p('src/tmp'), true)).toBe(true);
+    expect(ignore.isIgnored(p('docs/a/b/intro.draft.md'))).toBe(true);
+    expect(ignore.isIgnored(p('docs/intro.md'))).toBe(false);
+    expect(ignore.isIgnored(p('pkg/fixtures/big/data.json'))).toBe(true);
+    expect(ignore.isIgnored(p('file7.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('fileA.ts'))).toBe(false);
+    expect(ignore.isIgnored(p('#hash.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('keep.log.bak'))).toBe(true);
+    expect(ignore.isIgnored(p('ui/button.snap'))).toBe(true);
+    expect(ignore.isIgnored(p('ui/important.snap'))).toBe(false);
+  });
+
+  it('should let deeper ignore files and .indexerignore override shallower rules', () => {
+    files.set(p('.gitignore'), '*.gen.ts\nbuild/');
+    files.set(p('.indexerignore'), 'legacy/\n!vendor/');
+    files.set(p('pkg/.gitignore'), '!keep.gen.ts\n/local.ts');
+    files.set(p('build/.gitignore'), '!*');
+    const ignore = rules();
+
+    expect(ignore.isIgnored(p('src/a.gen.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('pkg/keep.gen.ts'))).toBe(false);
+    expect(ignore.isIgnored(p('pkg/local.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('local.ts'))).toBe(false);
+    expect(ignore.isIgnored(p('legacy/old.ts'))).toBe(true);
+    // Nothing inside an ignored directory can be re-included
+    expect(ignore.isIgnored(p('build/out.ts'))).toBe(true);
+    // .indexerignore re-includes the built-in vendor rule
+    expect(ignore.isIgnored(p('vendor/github.com/acme/lib/lib.go'))).toBe(false);
+    expect(ignore.isIgnored(p('pkg/testdata/input.go'))).toBe(true);
+    // Outside the workspace nothing is ignored
+    expect(ignore.isIgnored(path.join(path.sep, 'gomodcache', 'vendor', 'x.go'))).toBe(false);
+  });
+
+  it('should apply .git/info/exclude, configured patterns and option switches', () => {
+    files.set(p('.gitignore'), 'secret.ts');
+    files.set(p('.git/info/exclude'), 'scratch/');
+    options.patterns = ['**/*.stories.ts'];
+    let ignore = rules();
+
+    expect(ignore.isIgnored(p('scratch/try.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('ui/button.stories.ts'))).toBe(true);
+    expect(ignore.isIgnored(p('vendor/lib.go'))).toBe(true);
+
+    options = { gitignore: false, vendor: false, testdata: false, patterns: [] };
+    ignore = rules();
+    expect(ignore.isIgnored(p('secret.ts'))).toBe(false);
+    expect(ignore.isIgnored(p('scratch/try.ts'))).toBe(false);
+    expect(ignore.isIgnored(p('vendor/lib.go'))).toBe(false);
+    expect(ignore.isIgnored(p('pkg/testdata/input.go'))).toBe(false);
+  });
+
+  it('should re-read ignore files after clear()', () => {
+    const ignore = rules();
+    expect(ignore.isIgnored(p('src/a.ts'))).toBe(false);
+
+    files.set(p('src/.indexerignore'), 'a.ts');
+    expect(ignore.isIgnored(p('src/a.ts'))).toBe(false); // cached
+    ignore.clear();
+    expect(ignore.isIgnored(p('src/a.ts'))).toBe(true);
+  });
+});
+
+describe('isGeneratedSource', () => {
+  it('should detect generated-code markers in the file header', () => {
+    expect(isGeneratedSource('// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n')).toBe(true);
+    expect(isGeneratedSource('// Copyright 2024\n\n// Code generated by sqlc. DO NOT EDIT\npackage db\n')).toBe(true);
+    expect(isGeneratedSource('/**\n * @generated\n */\nexport const x = 1;\n')).toBe(true);
+    expect(isGeneratedSource('// <auto-generated>\n// </auto-generated>\nclass A {}\n')).toBe(true);
+
+    expect(isGeneratedSource('package main\n\n// Code generated code is not this\nfunc main() {}\n')).toBe(false);
+    expect(isGeneratedSource('x\n'.repeat(40) + '// Code generated by foo. DO NOT EDIT.\n')).toBe(false);
+  });
+});
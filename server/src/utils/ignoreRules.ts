@@ -0,0 +1,254 @@
+import * as fs from 'fs';
+import * as path from 'path';
+
+/**
+ * Which ignore sources apply, see config/configurationManager.ts.
+ */
+export interface IgnoreRulesOptions {
+  /** Honor .gitignore files and .git/info/exclude */
+  gitignore: boolean;
+  /** Skip vendor/ directories */
+  vendor: boolean;
+  /** Skip testdata/ directories */
+  testdata: boolean;
+  /** Extra gitignore-syntax patterns, relative to the workspace root */
+  patterns: string[];
+}
+
+/** Reads a file, or returns null when it does not exist; injectable for tests */
+export type ReadFileSyncFn = (filePath: string) => string | null;
+
+export interface IgnoreRule {
+  regex: RegExp;
+  negated: boolean;
+  directoryOnly: boolean;
+}
+
+interface RuleSet {
+  /** Workspace-relative directory the patterns are relative to ('' for the root) */
+  base: string;
+  rules: IgnoreRule[];
+}
+
+export const INDEXER_IGNORE_FILE = '.indexerignore';
+
+/** Per-directory ignore files, lowest precedence first */
+export const IGNORE_FILE_NAMES = ['.gitignore', INDEXER_IGNORE_FILE];
+
+/** Lines searched for a generated-code marker */
+const GENERATED_HEADER_LINES = 30;
+
+const GENERATED_MARKERS = [
+  // Go convention (https://go.dev/s/generatedcode), also used by protoc, sqlc, ...
+  /^\/\/ Code generated .* DO NOT EDIT\.?$/m,
+  /@generated\b/,
+  /<auto-generated[\s>/]/
+];
+
+/**
+ * Does the source start with a generated-code marker
+ * (`// Code generated ... DO NOT EDIT`, `@generated`, `<auto-generated>`)?
+ */
+export function isGeneratedSource(content: string): boolean {
+  let end = 0;
+  for (let line = 0; line < GENERATED_HEADER_LINES && end !== -1; line++) {
+    end = content.indexOf('\n', end + 1);
+  }
+  const header = end === -1 ? content : content.slice(0, end);
+  return GENERATED_MARKERS.some(marker => marker.test(header));
+}
+
+/**
+ * Ignore Rules - gitignore-style file filtering for the indexer.
+ *
+ * A path is checked against, lowest precedence first:
+ * - built-in `vendor/` and `testdata/` rules
+ * - `.git/info/exclude` and the configured patterns (workspace root)
+ * - `.gitignore` then `.indexerignore` of each directory from the root
+ *   down to the file's parent, deeper files overriding shallower ones
+ *
+ * The last matching rule wins, so `!vendor/` in a `.indexerignore`
+ * re-includes vendored code. Like git, nothing inside an ignored directory
+ * can be re-included. Paths outside the workspace are never ignored.
+ *
+ * Ignore files are read synchronously once per directory; call `clear()`
+ * when one changes.
+ */
+export class IgnoreRules {
+  private ruleSets: Map<string, RuleSet[]> = new Map();
+  private rootRules: RuleSet[];
+
+  constructor(
+    private workspaceRoot: string,
+    private options: IgnoreRulesOptions,
+    private readFile: ReadFileSyncFn = readFileIfExists
+  ) {
+    this.rootRules = this.loadRootRules();
+  }
+
+  /**
+   * Is the path (file, or directory when isDirectory) ignored?
+   */
+  isIgnored(filePath: string, isDirectory: boolean = false): boolean {
+    const relative = path.relative(this.workspaceRoot, filePath);
+    if (!relative || relative.startsWith('..') || path.isAbsolute(relative)) {
+      return false;
+    }
+    const segments = relative.split(path.sep).join('/').split('/');
+    const ruleSets = [...this.rootRules];
+    for (let i = 0; i < segments.length; i++) {
+      ruleSets.push(...this.directoryRules(segments.slice(0, i).join('/')));
+      const isLast = i === segments.length - 1;
+      if (matches(ruleSets, segments, i + 1, isLast ? isDirectory : true)) {
+        return true;
+      }
+    }
+    return false;
+  }
+
+  /** Drop cached ignore files (after a .gitignore or .indexerignore change) */
+  clear(): void {
+    this.ruleSets.clear();
+    this.rootRules = this.loadRootRules();
+  }
+
+  private directoryRules(dir: string): RuleSet[] {
+    let cached = this.ruleSets.get(dir);
+    if (!cached) {
+      cached = [];
+      for (const name of IGNORE_FILE_NAMES) {
+        if (name === '.gitignore' && !this.options.gitignore) {
+          continue;
+        }
+        const content = this.readFile(path.join(this.workspaceRoot, dir, name));
+        if (content) {
+          cached.push({ base: dir, rules: parseIgnoreFile(content) });
+        }
+      }
+      this.ruleSets.set(dir, cached);
+    }
+    return cached;
+  }
+
+  private loadRootRules(): RuleSet[] {
+    const builtIn: string[] = [];
+    if (this.options.vendor) {
+      builtIn.push('vendor/');
+    }
+    if (this.options.testdata) {
+      builtIn.push('testdata/');
+    }
+    const ruleSets: RuleSet[] = [{ base: '', rules: parseIgnoreFile(builtIn.join('\n')) }];
+    if (this.options.gitignore) {
+      const exclude = this.readFile(path.join(this.workspaceRoot, '.git', 'info', 'exclude'));
+      if (exclude) {
+        ruleSets.push({ base: '', rules: parseIgnoreFile(exclude) });
+      }
+    }
+    ruleSets.push({ base: '', rules: parseIgnoreFile(this.options.patterns.join('\n')) });
+    return ruleSets;
+  }
+}
+
+/**
+ * Result of the last matching rule for segments[0..length).
+ */
+function matches(ruleSets: RuleSet[], segments: string[], length: number, isDirectory: boolean): boolean {
+  let ignored = false;
+  for (const { base, rules } of ruleSets) {
+    const target = segments.slice(base ? base.split('/').length : 0, length).join('/');
+    for (const rule of rules) {
+      if (rule.negated === ignored && (!rule.directoryOnly || isDirectory) && rule.regex.test(target)) {
+        ignored = !rule.negated;
+      }
+    }
+  }
+  return ignored;
+}
+
+/**
+ * Parse gitignore syntax (https://git-scm.com/docs/gitignore).
+ */
+export function parseIgnoreFile(content: string): IgnoreRule[] {
+  const rules: IgnoreRule[] = [];
+  for (const rawLine of content.split(/\r?\n/)) {
+    // Trailing spaces are ignored unless escaped
+    let line = rawLine.replace(/(?<!\\)\s+$/, '');
+    if (!line || line.startsWith('#')) {
+      continue;
+    }
+    const negated = line.startsWith('!');
+    if (negated) {
+      line = line.slice(1);
+    } else if (line.startsWith('\\#') || line.startsWith('\\!')) {
+      line = line.slice(1);
+    }
+    const directoryOnly = line.endsWith('/');
+    if (directoryOnly) {
+      line = line.slice(0, -1);
+    }
+    if (!line) {
+      continue;
+    }
+    // A slash anywhere but the end anchors the pattern to the ignore file's directory
+    const anchored = line.includes('/');
+    if (line.startsWith('/')) {
+      line = line.slice(1);
+    }
+    const body = globToRegex(line);
+    rules.push({ regex: new RegExp(anchored ? `^${body}$` : `(^|/)${body}$`), negated, directoryOnly });
+  }
+  return rules;
+}
+
+/**
+ * Regex source for a gitignore-style glob: `*` and `?` stay within a path
+ * segment, `**` spans directories.
+ */
+export function globToRegex(glob: string): string {
+  let regex = '';
+  for (let i = 0; i < glob.length; i++) {
+    const char = glob[i];
+    if (char === '*' && glob[i + 1] === '*' && (i === 0 || glob[i - 1] === '/') && (i + 2 === glob.length || glob[i + 2] === '/')) {
+      // `**/` matches any number of directories, a trailing `/**` everything inside
+      if (i + 2 === glob.length) {
+        regex += '.*';
+      } else {
+        regex += '(?:.*/)?';
+        i++;
+      }
+      i++;
+    } else if (char === '*') {
+      regex += '[^/]*';
+    } else if (char === '?') {
+      regex += '[^/]';
+    } else if (char === '[') {
+      const close = glob.indexOf(']', i + 2);
+      if (close === -1) {
+        regex += '\\[';
+      } else {
+        const body = glob.slice(i + 1, close).replace(/^!/, '^').replace(/\\/g, '\\\\');
+        regex += `[${body}]`;
+        i = close;
+      }
+    } else if (char === '\\' && i + 1 < glob.length) {
+      regex += escapeRegex(glob[++i]);
+    } else {
+      regex += escapeRegex(char);
+    }
+  }
+  return regex;
+}
+
+function escapeRegex(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\/]/g, '\\$&');
+}
+
+/** ReadFileSyncFn on the real file system */
+export function readFileIfExists(filePath: string): string | null {
+  try {
+    return fs.readFileSync(filePath, 'utf-8');
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,73 @@
+/**
+ * Indexing Progress Tests
+ *
+ * Verifies counters, windowed rates, ETA and formatting.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import { formatBytes, formatDuration, formatProgress, ProgressTracker } from './indexingProgress.js';
+
+describe('ProgressTracker', () => {
+  let time: number;
+  let tracker: ProgressTracker;
+
+  beforeEach(() => {
+    time = 1000;
+    tracker = new ProgressTracker(() => time);
+  });
+
+  it('should count files through the phases', () => {
+    tracker.fileScanned(4);
+    tracker.setPhase('checking');
+    tracker.fileChecked(4);
+    expect(formatProgress(tracker.snapshot())).toBe('Checking 4/4 files');
+
+    tracker.startIndexing(2, 3 * 1024 * 1024);
+    tracker.fileParsed('/ws/a.go');
+    expect(tracker.snapshot()).toMatchObject({
+      phase: 'indexing', filesScanned: 4, filesChecked: 4, filesTotal: 2, filesParsed: 1, filesIndexed: 0, currentFile: '/ws/a.go'
+    });
+    expect(tracker.snapshot().etaMs).toBeUndefined();
+
+    time += 500;
+    tracker.fileIndexed('/ws/a.go', 1024 * 1024);
+    const progress = tracker.snapshot();
+    expect(progress).toMatchObject({ filesIndexed: 1, bytesProcessed: 1024 * 1024, filesPerSecond: 2, etaMs: 500, elapsedMs: 500 });
+    expect(formatProgress(progress)).toBe('1/2 files, 1.0/3.0 MB, 2 files/s, ETA 500ms');
+
+    tracker.setPhase('idle');
+    expect(tracker.snapshot().etaMs).toBeUndefined();
+    expect(formatProgress(tracker.snapshot())).toBe('Indexed 1 files (1.0 MB) in 500ms');
+  });
+
+  it('should measure the rate over recent files only', () => {
+    tracker.startIndexing(100);
+    // 10 slow files, then 10 fast ones
+    for (let i = 0; i < 10; i++) {
+      time += 2000;
+      tracker.fileIndexed(`/ws/slow${i}.go`, 100);
+    }
+    for (let i = 0; i < 10; i++) {
+      time += 100;
+      tracker.fileIndexed(`/ws/fast${i}.go`, 100);
+    }
+
+    const progress = tracker.snapshot();
+    // Measured from the last file before the 10s window: 15 files in 11s
+    expect(progress.filesPerSecond).toBe(15 / 11);
+    expect(progress.bytesPerSecond).toBe(1500 / 11);
+    expect(progress.etaMs).toBe(Math.round((80 / (15 / 11)) * 1000));
+  });
+});
+
+describe('progress formatting', () => {
+  it('should format sizes and durations', () => {
+    expect(formatBytes(512)).toBe('512 B');
+    expect(formatBytes(1536)).toBe('1.5 KB');
+    expect(formatBytes(5 * 1024 * 1024 * 1024)).toBe('5.0 GB');
+    expect(formatDuration(850)).toBe('850ms');
+    expect(formatDuration(42000)).toBe('42s');
+    expect(formatDuration(200000)).toBe('3m 20s');
+    expect(formatDuration(3900000)).toBe('1h 5m');
+  });
+});
@@ -0,0 +1,218 @@
+/**
+ * Indexing progress - counts, throughput and ETA of an indexing run.
+ *
+ * A run goes through phases: scanning (walking directories), checking
+ * (comparing files against the index), indexing (parsing and writing) and
+ * finalizing. Files are counted as they are found, parsed and written;
+ * rates are measured over the last RATE_WINDOW_MS so the ETA follows the
+ * current speed instead of the average since the start.
+ */
+
+export type IndexingPhase = 'scanning' | 'checking' | 'indexing' | 'finalizing' | 'idle';
+
+export interface IndexingProgress {
+  phase: IndexingPhase;
+  /** Files found by the directory walk */
+  filesScanned: number;
+  /** Files compared against the index */
+  filesChecked: number;
+  /** Files that need indexing in this run */
+  filesTotal: number;
+  /** Files parsed (including files that failed to parse) */
+  filesParsed: number;
+  /** Files written to the index */
+  filesIndexed: number;
+  /** Size of the files indexed so far */
+  bytesProcessed: number;
+  /** Size of all files that need indexing, when known */
+  bytesTotal?: number;
+  /** Files indexed per second, over the last few seconds */
+  filesPerSecond: number;
+  /** Bytes indexed per second, over the last few seconds */
+  bytesPerSecond: number;
+  /** Estimated time until indexing is done; absent until there is a rate */
+  etaMs?: number;
+  elapsedMs: number;
+  currentFile?: string;
+}
+
+/** Listener for progress snapshots */
+export type IndexingProgressListener = (progress: IndexingProgress) => void;
+
+const RATE_WINDOW_MS = 10000;
+
+interface RateSample {
+  time: number;
+  files: number;
+  bytes: number;
+}
+
+/**
+ * Tracks one indexing run. The clock is injectable for tests.
+ */
+export class ProgressTracker {
+  private phase: IndexingPhase = 'scanning';
+  private readonly startTime: number;
+  private filesScanned = 0;
+  private filesChecked = 0;
+  private filesTotal = 0;
+  private filesParsed = 0;
+  private filesIndexed = 0;
+  private bytesProcessed = 0;
+  private bytesTotal: number | undefined;
+  private currentFile: string | undefined;
+  private samples: RateSample[] = [];
+
+  constructor(private readonly now: () => number = Date.now) {
+    this.startTime = now();
+  }
+
+  setPhase(phase: IndexingPhase): void {
+    this.phase = phase;
+  }
+
+  fileScanned(count: number = 1): void {
+    this.filesScanned += count;
+  }
+
+  fileChecked(count: number = 1): void {
+    this.filesChecked += count;
+  }
+
+  /**
+   * Start the indexing phase for files totalling bytesTotal (if known).
+   */
+  startIndexing(filesTotal: number, bytesTotal?: number): void {
+    this.phase = 'indexing';
+    this.filesTotal = filesTotal;
+    this.bytesTotal = bytesTotal;
+    this.samples = [{ time: this.now(), files: this.filesIndexed, bytes: this.bytesProcessed }];
+  }
+
+  /** Count files found while indexing, for inputs listed as they are read */
+  addToTotal(count: number = 1): void {
+    this.filesTotal += count;
+  }
+
+  fileParsed(uri?: string): void {
+    this.filesParsed++;
+    if (uri) {
+      this.currentFile = uri;
+    }
+  }
+
+  fileIndexed(uri: string, bytes: number = 0): void {
+    this.filesIndexed++;
+    this.bytesProcessed += bytes;
+    this.currentFile = uri;
+
+    const time = this.now();
+    this.samples.push({ time, files: this.filesIndexed, bytes: this.bytesProcessed });
+    // Keep one sample older than the window as the rate's starting point
+    while (this.samples.length > 2 && this.samples[1].time <= time - RATE_WINDOW_MS) {
+      this.samples.shift();
+    }
+  }
+
+  snapshot(): IndexingProgress {
+    const time = this.now();
+    const first = this.samples[0];
+    const last = this.samples[this.samples.length - 1];
+    const seconds = first && last ? (last.time - first.time) / 1000 : 0;
+    const filesPerSecond = seconds > 0 ? (last.files - first.files) / seconds : 0;
+    const bytesPerSecond = seconds > 0 ? (last.bytes - first.bytes) / seconds : 0;
+    const remaining = Math.max(0, this.filesTotal - this.filesIndexed);
+
+    return {
+      phase: this.phase,
+      filesScanned: this.filesScanned,
+      filesChecked: this.filesChecked,
+      filesTotal: this.filesTotal,
+      filesParsed: this.filesParsed,
+      filesIndexed: this.filesIndexed,
+      bytesProcessed: this.bytesProcessed,
+      ...(this.bytesTotal !== undefined && { bytesTotal: this.bytesTotal }),
+      filesPerSecond,
+      bytesPerSecond,
+      ...(this.phase === 'indexing' && filesPerSecond > 0 && { etaMs: Math.round((remaining / filesPerSecond) * 1000) }),
+      elapsedMs: time - this.startTime,
+      ...(this.currentFile !== undefined && { currentFile: this.currentFile })
+    };
+  }
+}
+
+/**
+ * One-line summary, e.g. "120/400 files, 1.2/3.9 MB, 85 files/s, ETA 3s".
+ */
+export function formatProgress(progress: IndexingProgress): string {
+  switch (progress.phase) {
+    case 'scanning':
+      return `Scanning: ${progress.filesScanned} files found`;
+    case 'checking':
+      return `Checking ${progress.filesChecked}/${progress.filesScanned} files`;
+    case 'finalizing':
+      return `Finalizing ${progress.filesIndexed} files`;
+    case 'idle':
+      return `Indexed ${progress.filesIndexed} files (${formatBytes(progress.bytesProcessed)}) in ${formatDuration(progress.elapsedMs)}`;
+  }
+
+  const parts = [`${progress.filesIndexed}/${progress.filesTotal} files`];
+  parts.push(progress.bytesTotal !== undefined
+    ? formatByteRatio(progress.bytesProcessed, progress.bytesTotal)
+    : formatBytes(progress.bytesProcessed));
+  if (progress.filesPerSecond > 0) {
+    parts.push(`${Math.round(progress.filesPerSecond)} files/s`);
+  }
+  if (progress.etaMs !== undefined) {
+    parts.push(`ETA ${formatDuration(progress.etaMs)}`);
+  }
+  return parts.join(', ');
+}
+
+const BYTE_UNITS = ['B', 'KB', 'MB', 'GB'];
+
+/**
+ * Human-readable size: "512 B", "1.2 MB".
+ */
+export function formatBytes(bytes: number): string {
+  const unit = byteUnit(bytes);
+  return `${scaleBytes(bytes, unit)} ${BYTE_UNITS[unit]}`;
+}
+
+/**
+ * "1.2/3.9 MB" - both sizes in the unit of the total.
+ */
+function formatByteRatio(done: number, total: number): string {
+  const unit = byteUnit(total);
+  return `${scaleBytes(done, unit)}/${scaleBytes(total, unit)} ${BYTE_UNITS[unit]}`;
+}
+
+function byteUnit(bytes: number): number {
+  let unit = 0;
+  while (unit < BYTE_UNITS.length - 1 && bytes >= 1024 ** (unit + 1)) {
+    unit++;
+  }
+  return unit;
+}
+
+function scaleBytes(bytes: number, unit: number): string {
+  return unit === 0 ? String(bytes) : (bytes / 1024 ** unit).toFixed(1);
+}
+
+/**
+ * Human-readable duration: "850ms", "42s", "3m 20s", "1h 5m".
+ */
+export function formatDuration(ms: number): string {
+  if (ms < 1000) {
+    return `${Math.round(ms)}ms`;
+  }
+  const seconds = Math.round(ms / 1000);
+  if (seconds < 60) {
+    return `${seconds}s`;
+  }
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) {
+    return `${minutes}m ${seconds % 60}s`;
+  }
+  return `${Math.floor(minutes / 60)}h ${minutes % 60}m`;
+}
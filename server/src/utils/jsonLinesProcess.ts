@@ -0,0 +1,202 @@
+import { spawn as nodeSpawn, ChildProcess, SpawnOptions } from 'child_process';
+import * as path from 'path';
+import { ILogger } from './Logger.js';
+
+export type SpawnFn = (command: string, args: string[], options: SpawnOptions) => ChildProcess;
+
+export interface JsonLinesProcessConfig {
+  /** Name used in logs and error messages */
+  name: string;
+  command: string;
+  args?: string[];
+  /** Per-request timeout in milliseconds (default 10000) */
+  timeoutMs?: number;
+}
+
+/**
+ * Protocol: one JSON object per line on stdin/stdout.
+ *
+ *   -> {"id":1,"method":"extract","params":{...}}
+ *   <- {"id":1,"result":{...}}
+ *   <- {"id":1,"error":{"message":"..."}}
+ *
+ * Requests may be pipelined; responses are matched by id. Anything the
+ * process writes to stderr is logged.
+ */
+interface JsonLinesResponse {
+  id: number;
+  result?: unknown;
+  error?: { message: string };
+}
+
+interface PendingRequest {
+  resolve: (result: unknown) => void;
+  reject: (error: Error) => void;
+  timer: NodeJS.Timeout;
+}
+
+const DEFAULT_TIMEOUT_MS = 10000;
+/** Give up on a process after this many exits in a row without a single response */
+const MAX_CONSECUTIVE_FAILURES = 3;
+
+/**
+ * A long-running helper process speaking JSON lines (external extractors,
+ * local embedding models). Started on first use and restarted if it exits,
+ * unless it keeps dying before answering anything.
+ */
+export class JsonLinesProcess {
+  private child: ChildProcess | null = null;
+  private buffer = '';
+  private nextId = 1;
+  private pending: Map<number, PendingRequest> = new Map();
+  private consecutiveFailures = 0;
+  private answeredSinceStart = false;
+  private disabled = false;
+
+  /**
+   * @param label Kind of process for logs and errors, e.g. `Extractor`
+   */
+  constructor(
+    private readonly config: JsonLinesProcessConfig,
+    private readonly label: string,
+    private readonly logger: ILogger,
+    private readonly cwd: string | undefined = undefined,
+    private readonly spawn: SpawnFn = nodeSpawn
+  ) {}
+
+  get name(): string {
+    return this.config.name;
+  }
+
+  isDisabled(): boolean {
+    return this.disabled;
+  }
+
+  /**
+   * Send one request and wait for its result.
+   *
+   * @param context What the request is about (e.g. a file), for timeout errors
+   */
+  request<T>(method: string, params: unknown, context?: string): Promise<T> {
+    if (this.disabled) {
+      return Promise.reject(new Error(`${this.label} '${this.name}' is disabled after repeated failures`));
+    }
+    const child = this.ensureStarted();
+    const id = this.nextId++;
+
+    return new Promise<T>((resolve, reject) => {
+      const timer = setTimeout(() => {
+        this.pending.delete(id);
+        reject(new Error(`${this.label} '${this.name}' timed out${context ? ` on ${context}` : ''}`));
+      }, this.config.timeoutMs ?? DEFAULT_TIMEOUT_MS);
+
+      this.pending.set(id, { resolve: resolve as (result: unknown) => void, reject, timer });
+      child.stdin!.write(JSON.stringify({ id, method, params }) + '\n');
+    });
+  }
+
+  dispose(): void {
+    this.rejectAll(new Error(`${this.label} '${this.name}' stopped`));
+    if (this.child) {
+      const child = this.child;
+      this.child = null;
+      child.stdin?.end();
+      child.kill();
+    }
+  }
+
+  private ensureStarted(): ChildProcess {
+    if (this.child) {
+      return this.child;
+    }
+
+    // ./tools/extract.js style commands are relative to the workspace, not the server
+    const command = this.cwd && /^\.\.?[\\/]/.test(this.config.command)
+      ? path.resolve(this.cwd, this.config.command)
+      : this.config.command;
+    const child = this.spawn(command, this.config.args ?? [], {
+      cwd: this.cwd,
+      stdio: ['pipe', 'pipe', 'pipe']
+    });
+    this.child = child;
+    this.buffer = '';
+    this.answeredSinceStart = false;
+    this.logger.info(`[${this.label}] Started '${this.name}': ${command} ${(this.config.args ?? []).join(' ')}`);
+
+    child.stdout!.setEncoding('utf-8');
+    child.stdout!.on('data', (chunk: string) => this.onData(chunk));
+    child.stderr!.setEncoding('utf-8');
+    child.stderr!.on('data', (chunk: string) => {
+      for (const line of chunk.split('\n').filter(l => l.trim())) {
+        this.logger.warn(`[${this.label}] ${this.name}: ${line}`);
+      }
+    });
+    // Writes after the process died surface as 'error' on stdin
+    child.stdin!.on('error', () => { /* handled by 'exit' */ });
+    child.on('error', error => this.onExit(child, `failed: ${error.message}`));
+    child.on('exit', (code, signal) => this.onExit(child, `exited (${signal ?? code})`));
+
+    return child;
+  }
+
+  private onData(chunk: string): void {
+    this.buffer += chunk;
+    let newline = this.buffer.indexOf('\n');
+    while (newline !== -1) {
+      const line = this.buffer.slice(0, newline).trim();
+      this.buffer = this.buffer.slice(newline + 1);
+      if (line) {
+        this.onLine(line);
+      }
+      newline = this.buffer.indexOf('\n');
+    }
+  }
+
+  private onLine(line: string): void {
+    let response: JsonLinesResponse;
+    try {
+      response = JSON.parse(line);
+    } catch {
+      this.logger.warn(`[${this.label}] ${this.name}: ignoring non-JSON output: ${line.slice(0, 200)}`);
+      return;
+    }
+
+    const request = this.pending.get(response.id);
+    if (!request) {
+      return;
+    }
+    this.pending.delete(response.id);
+    clearTimeout(request.timer);
+    this.answeredSinceStart = true;
+    this.consecutiveFailures = 0;
+
+    if (response.error) {
+      request.reject(new Error(`${this.label} '${this.name}': ${response.error.message}`));
+    } else {
+      request.resolve(response.result);
+    }
+  }
+
+  private onExit(child: ChildProcess, reason: string): void {
+    if (this.child !== child) {
+      return;
+    }
+    this.child = null;
+    this.rejectAll(new Error(`${this.label} '${this.name}' ${reason}`));
+
+    if (!this.answeredSinceStart && ++this.consecutiveFailures >= MAX_CONSECUTIVE_FAILURES) {
+      this.disabled = true;
+      this.logger.error(`[${this.label}] '${this.name}' ${reason} ${this.consecutiveFailures} times without responding - disabled`);
+    } else {
+      this.logger.warn(`[${this.label}] '${this.name}' ${reason}, restarting on next request`);
+    }
+  }
+
+  private rejectAll(error: Error): void {
+    for (const request of this.pending.values()) {
+      clearTimeout(request.timer);
+      request.reject(error);
+    }
+    this.pending.clear();
+  }
+}
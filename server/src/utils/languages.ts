@@ -0,0 +1,35 @@
+/**
+ * Languages by name, for `lang:` queries and the `languages` setting:
+ * canonical names with their file extensions, and the aliases accepted
+ * for them (`golang`, `typescript`, ...).
+ */
+export const LANGUAGE_EXTENSIONS: Record<string, string[]> = {
+  go: ['.go'],
+  asm: ['.s'],
+  ts: ['.ts', '.tsx', '.mts', '.cts'],
+  js: ['.js', '.jsx', '.mjs', '.cjs'],
+  py: ['.py', '.pyi'],
+  proto: ['.proto'],
+  gotmpl: ['.tmpl', '.gotmpl', '.gohtml', '.tpl'],
+  yaml: ['.yaml', '.yml'],
+  json: ['.json']
+};
+
+const LANGUAGE_ALIASES: Record<string, string> = {
+  golang: 'go',
+  typescript: 'ts',
+  javascript: 'js',
+  python: 'py',
+  protobuf: 'proto',
+  gotemplate: 'gotmpl',
+  yml: 'yaml'
+};
+
+/**
+ * Extensions of a language given by name or alias, case-insensitively;
+ * undefined for an unknown language.
+ */
+export function extensionsOfLanguage(name: string): string[] | undefined {
+  const language = name.toLowerCase();
+  return LANGUAGE_EXTENSIONS[LANGUAGE_ALIASES[language] ?? language];
+}
@@ -0,0 +1,119 @@
+/**
+ * Ordered Pipeline Tests
+ *
+ * Verifies in-order consumption, bounded buffering, error continuation
+ * and cancellation.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { runOrderedPipeline } from './orderedPipeline.js';
+import { CancellationTokenSource } from './asyncUtils.js';
+
+const delay = (ms: number) => new Promise<void>(resolve => setTimeout(resolve, ms));
+
+describe('runOrderedPipeline', () => {
+  it('should consume in input order regardless of completion order', async () => {
+    const items = [30, 5, 20, 1, 10, 0];
+    const consumed: number[] = [];
+
+    await runOrderedPipeline(items, {
+      concurrency: 3,
+      produce: async ms => {
+        await delay(ms);
+        return ms;
+      },
+      consume: async value => {
+        consumed.push(value);
+      }
+    });
+
+    expect(consumed).toEqual(items);
+  });
+
+  it('should bound started-but-unconsumed items by the window', async () => {
+    const items = Array.from({ length: 20 }, (_, i) => i);
+    let started = 0;
+    let consumedCount = 0;
+    let maxPending = 0;
+
+    await runOrderedPipeline(items, {
+      concurrency: 4,
+      window: 4,
+      produce: async i => {
+        started++;
+        maxPending = Math.max(maxPending, started - consumedCount);
+        // The first item is slow, everything else finishes immediately
+        await delay(i === 0 ? 20 : 0);
+        return i;
+      },
+      consume: async () => {
+        consumedCount++;
+      }
+    });
+
+    expect(consumedCount).toBe(items.length);
+    expect(maxPending).toBeLessThanOrEqual(4);
+  });
+
+  it('should report failures and continue with the next item', async () => {
+    const consumed: string[] = [];
+    const failed: string[] = [];
+
+    await runOrderedPipeline(['a', 'bad-produce', 'c', 'bad-consume', 'e'], {
+      concurrency: 2,
+      produce: async item => {
+        if (item === 'bad-produce') {
+          throw new Error('parse failed');
+        }
+        return item;
+      },
+      consume: async item => {
+        if (item === 'bad-consume') {
+          throw new Error('write failed');
+        }
+        consumed.push(item);
+      },
+      onError: (_error, item) => failed.push(item)
+    });
+
+    expect(consumed).toEqual(['a', 'c', 'e']);
+    expect(failed).toEqual(['bad-produce', 'bad-consume']);
+  });
+
+  it('should finish started items and start no more once cancelled', async () => {
+    const items = Array.from({ length: 20 }, (_, i) => i);
+    const source = new CancellationTokenSource();
+    const started: number[] = [];
+    const consumed: number[] = [];
+
+    await runOrderedPipeline(items, {
+      concurrency: 3,
+      cancellationToken: source.token,
+      produce: async i => {
+        started.push(i);
+        await delay(i === 4 ? 20 : 1);
+        return i;
+      },
+      consume: async i => {
+        consumed.push(i);
+        if (i === 2) {
+          source.cancel();
+        }
+      }
+    });
+
+    // Everything started before the cancel is still consumed, in order
+    expect(consumed).toEqual(started);
+    expect(consumed).toEqual(Array.from({ length: consumed.length }, (_, i) => i));
+    expect(consumed.length).toBeLessThan(items.length);
+    expect(consumed).toContain(4);
+  });
+
+  it('should resolve immediately for no items', async () => {
+    await expect(runOrderedPipeline([], {
+      concurrency: 2,
+      produce: async () => 1,
+      consume: async () => {}
+    })).resolves.toBeUndefined();
+  });
+});
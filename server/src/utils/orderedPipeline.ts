@@ -0,0 +1,107 @@
+/**
+ * Ordered pipeline - concurrent produce, sequential in-order consume.
+ *
+ * Items are produced (e.g. parsed on worker threads) with bounded
+ * concurrency but consumed (e.g. written to storage) strictly in input
+ * order, one at a time. The reorder buffer is bounded by `window`: item i
+ * is not started until item i - window has been consumed, so a slow early
+ * item cannot make finished results pile up in memory.
+ *
+ * On cancellation no new items are started; items already started are
+ * still consumed, so a consumer that writes to storage never stops halfway
+ * through the ordered prefix it was given.
+ */
+
+import { CancellationToken } from './asyncUtils.js';
+
+export interface OrderedPipelineOptions<T, R> {
+  /** Maximum number of produce calls in flight */
+  concurrency: number;
+  /** Maximum number of started-but-unconsumed items (>= concurrency) */
+  window?: number;
+  /** Produce stage - runs concurrently */
+  produce: (item: T, index: number) => Promise<R>;
+  /** Consume stage - runs sequentially in input order */
+  consume: (result: R, item: T, index: number) => Promise<void>;
+  /** Called when produce or consume throws; the pipeline continues with the next item */
+  onError?: (error: unknown, item: T, index: number) => void;
+  /** Stops starting new items; the pipeline resolves once started items are consumed */
+  cancellationToken?: CancellationToken;
+}
+
+type Slot<R> = { ok: true; value: R } | { ok: false; error: unknown };
+
+/**
+ * Run items through the pipeline. Resolves once every item was consumed,
+ * or, after cancellation, once every started item was. Check the token
+ * afterwards to tell the two apart.
+ */
+export async function runOrderedPipeline<T, R>(
+  items: readonly T[],
+  options: OrderedPipelineOptions<T, R>
+): Promise<void> {
+  const concurrency = Math.max(1, options.concurrency);
+  const window = Math.max(concurrency, options.window ?? concurrency * 2);
+  const slots = new Map<number, Slot<R>>();
+  const isCancelled = () => options.cancellationToken?.isCancellationRequested ?? false;
+
+  let nextToStart = 0;
+  let nextToConsume = 0;
+  let inFlight = 0;
+  let consuming = false;
+
+  return new Promise<void>((resolve) => {
+    const fail = (error: unknown, index: number) => {
+      options.onError?.(error, items[index], index);
+    };
+
+    const drain = async () => {
+      if (consuming) {
+        return;
+      }
+      consuming = true;
+      while (slots.has(nextToConsume)) {
+        const index = nextToConsume;
+        const slot = slots.get(index)!;
+        slots.delete(index);
+        if (slot.ok) {
+          try {
+            await options.consume(slot.value, items[index], index);
+          } catch (error) {
+            fail(error, index);
+          }
+        } else {
+          fail(slot.error, index);
+        }
+        nextToConsume++;
+      }
+      consuming = false;
+
+      if (nextToConsume === items.length || (isCancelled() && nextToConsume === nextToStart)) {
+        resolve();
+      } else {
+        fill();
+      }
+    };
+
+    const fill = () => {
+      while (!isCancelled() && inFlight < concurrency && nextToStart < items.length && nextToStart - nextToConsume < window) {
+        const index = nextToStart++;
+        inFlight++;
+        options.produce(items[index], index).then(
+          value => slots.set(index, { ok: true, value }),
+          error => slots.set(index, { ok: false, error })
+        ).finally(() => {
+          inFlight--;
+          void drain();
+        });
+      }
+    };
+
+    if (items.length === 0 || isCancelled()) {
+      resolve();
+      return;
+    }
+    fill();
+  });
+}
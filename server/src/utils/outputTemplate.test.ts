@@ -0,0 +1,87 @@
+/**
+ * OutputTemplate Tests
+ *
+ * Verifies the Go text/template subset: fields, pipelines, control
+ * structures, functions, trim markers and error positions.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { parseTemplate, renderTemplate, TemplateError } from './outputTemplate.js';
+
+const symbol = {
+  name: 'Greet',
+  kind: 'function',
+  containerName: '',
+  location: { uri: '/ws/hello.go', line: 4, character: 5 },
+  tags: ['test', 'generated'],
+  owners: [],
+  metrics: { complexity: 7, loc: 12 }
+};
+
+describe('outputTemplate', () => {
+  it('should render fields, case-insensitively and missing as empty', () => {
+    expect(renderTemplate('{{.name}}\t{{.location.uri}}:{{.location.line}}', symbol)).toBe('Greet\t/ws/hello.go:4');
+    expect(renderTemplate('{{.Name}} {{.Location.Line}}', symbol)).toBe('Greet 4');
+    expect(renderTemplate('[{{.nope.deeper}}]', symbol)).toBe('[]');
+    expect(renderTemplate('{{.tags}} {{$.metrics}}', symbol)).toBe('["test","generated"] {"complexity":7,"loc":12}');
+    expect(renderTemplate('{{.}}', 'plain')).toBe('plain');
+  });
+
+  it('should evaluate if, range and with', () => {
+    expect(renderTemplate('{{if .containerName}}{{.containerName}}.{{else if .owners}}owned {{else}}-{{end}}{{.name}}', symbol))
+      .toBe('-Greet');
+    expect(renderTemplate('{{range .tags}}<{{.}}>{{end}}', symbol)).toBe('<test><generated>');
+    expect(renderTemplate('{{range .owners}}{{.}}{{else}}unowned{{end}}', symbol)).toBe('unowned');
+    expect(renderTemplate('{{with .metrics}}{{.complexity}}/{{$.name}}{{end}}', symbol)).toBe('7/Greet');
+    expect(renderTemplate('{{range .}}{{.}},{{end}}', { b: 2, a: 1 })).toBe('1,2,');
+  });
+
+  it('should call functions with arguments and pipes', () => {
+    expect(renderTemplate('{{.name | upper}} {{lower "ABC"}} {{len .tags}} {{index .tags 1}}', symbol))
+      .toBe('GREET abc 2 generated');
+    expect(renderTemplate('{{join ", " .tags}} {{.tags | join "+"}}', symbol)).toBe('test, generated test+generated');
+    expect(renderTemplate('{{printf "%-8s|%3d|%05.1f|%q|%x|%%" .name .metrics.complexity 2.34 .kind 255}}', symbol))
+      .toBe('Greet   |  7|002.3|"function"|ff|%');
+    expect(renderTemplate('{{if and (gt .metrics.complexity 5) (eq .kind "method" "function")}}complex{{end}}', symbol))
+      .toBe('complex');
+    expect(renderTemplate('{{if or (lt .metrics.loc 10) (not .tags)}}small{{else}}big{{end}}', symbol)).toBe('big');
+    expect(renderTemplate('{{truncate 6 "interface"}} {{print 1 2 "x" 3}} {{json .location}}', symbol))
+      .toBe('int... 1 2x3 {"uri":"/ws/hello.go","line":4,"character":5}');
+    expect(renderTemplate('{{(index .tags 0) | upper}} {{(.location).line}}', symbol)).toBe('TEST 4');
+  });
+
+  it('should apply trim markers and drop comments', () => {
+    const template = parseTemplate(`
+      {{- range .tags -}}
+        {{/* one tag per line */}}
+        {{- . }}
+      {{ end -}}
+    `);
+    expect(template.render(symbol)).toBe('test\n      generated\n      ');
+    expect(renderTemplate('{{-3}}', {})).toBe('-3');
+    expect(renderTemplate('{{"}}"}} {{`raw {{x}}`}}', {})).toBe('}} raw {{x}}');
+  });
+
+  it('should report errors with their position', () => {
+    const error = (source: string, data: unknown = symbol) => {
+      try {
+        renderTemplate(source, data);
+      } catch (e) {
+        expect(e instanceof TemplateError).toBe(true);
+        return e as TemplateError;
+      }
+      throw new Error(`no error for ${source}`);
+    };
+
+    expect(error('ab {{.name').position).toBe(3);
+    expect(error('{{if .name}}x').message).toBe('Missing end for if at position 2');
+    expect(error('{{end}}').message).toContain('Unexpected end');
+    expect(error('{{frobnicate .name}}').message).toBe('Unknown function "frobnicate" at position 2');
+    expect(error('{{.name .kind}}').message).toContain('non-function');
+    expect(error('{{.name | .kind}}').message).toContain('Cannot pipe into a non-function');
+    expect(error('{{$x := .name}}').message).toContain('Variables are not supported');
+    expect(error('{{len .nope}}').message).toBe('len: len of nil at position 2');
+    expect(error('{{lt .name 3}}').message).toContain('incompatible types');
+    expect(error('{{upper}}').message).toContain('Wrong number of arguments');
+  });
+});
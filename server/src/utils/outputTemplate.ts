@@ -0,0 +1,640 @@
+/*
+ * Output templates in Go text/template syntax, the way `gh --template` and
+ * `docker --format` render results for scripts:
+ *
+ *   {{.name}}\t{{.location.uri}}:{{.location.line}}
+ *   {{range .tags}}{{.}} {{end}}
+ *   {{if .containerName}}{{.containerName}}.{{end}}{{.name | upper}}
+ *   {{printf "%-30s %3d" .name .metrics.complexity}}
+ *
+ * Supported: fields (`.a.b`, `$` for the whole result), string, number and
+ * boolean literals, pipelines, parenthesized sub-pipelines, `if`/`else
+ * if`/`else`, `range` (with `else`), `with`, `{{/* ... *\/}}` comments and the
+ * `{{-`/`-}}` trim markers. Functions: Go's and, or, not, len, index,
+ * print, printf, println, eq, ne, lt, le, gt, ge, plus json, join, upper,
+ * lower and truncate (as in gh). Variables are not supported.
+ *
+ * Fields are looked up by exact name, then case-insensitively, so `.Name`
+ * works on JSON's `name`. Missing fields print as nothing; objects and
+ * lists print as JSON.
+ */
+
+/**
+ * A template that does not parse or fails while rendering; `position` is
+ * the offset in the template text.
+ */
+export class TemplateError extends Error {
+  constructor(message: string, readonly position: number) {
+    super(`${message} at position ${position}`);
+    this.name = 'TemplateError';
+  }
+}
+
+export interface OutputTemplate {
+  /** Render one result, which is `.` (and `$`) in the template */
+  render(data: unknown): string;
+}
+
+type Operand =
+  | { type: 'field'; root: boolean; path: string[] }
+  | { type: 'literal'; value: unknown }
+  | { type: 'function'; name: string }
+  | { type: 'sub'; pipeline: Pipeline; path: string[] };
+
+interface Command {
+  operands: Operand[];
+  position: number;
+}
+
+type Pipeline = Command[];
+
+type Node =
+  | { type: 'text'; text: string }
+  | { type: 'output'; pipeline: Pipeline }
+  | { type: 'if' | 'with' | 'range'; pipeline: Pipeline; body: Node[]; otherwise: Node[] };
+
+type BlockNode = Extract<Node, { body: Node[] }>;
+
+type Token =
+  | { type: 'field'; root: boolean; path: string[]; position: number; spaced: boolean }
+  | { type: 'identifier'; name: string; position: number; spaced: boolean }
+  | { type: 'literal'; value: unknown; position: number; spaced: boolean }
+  | { type: 'pipe' | 'open' | 'close'; position: number; spaced: boolean };
+
+type Piece = { type: 'text'; text: string } | { type: 'action'; content: string; position: number };
+
+interface TemplateFunction {
+  /** Minimum and maximum argument counts, the piped value included */
+  arity: [number, number];
+  call: (...args: unknown[]) => unknown;
+}
+
+const MAX_ARGS = Number.MAX_SAFE_INTEGER;
+
+const FUNCTIONS: Record<string, TemplateFunction> = {
+  and: { arity: [1, MAX_ARGS], call: (...args) => args.find(arg => !isTruthy(arg)) ?? args[args.length - 1] },
+  or: { arity: [1, MAX_ARGS], call: (...args) => args.find(isTruthy) ?? args[args.length - 1] },
+  not: { arity: [1, 1], call: value => !isTruthy(value) },
+  len: { arity: [1, 1], call: lengthOf },
+  index: { arity: [1, MAX_ARGS], call: (value, ...keys) => keys.reduce((item, key) => lookupKey(item, key), value) },
+  print: { arity: [0, MAX_ARGS], call: (...args) => sprint(args) },
+  println: { arity: [0, MAX_ARGS], call: (...args) => args.map(formatValue).join(' ') + '\n' },
+  printf: { arity: [1, MAX_ARGS], call: (format, ...args) => sprintf(formatValue(format), args) },
+  eq: { arity: [2, MAX_ARGS], call: (value, ...others) => others.some(other => other === value) },
+  ne: { arity: [2, 2], call: (a, b) => a !== b },
+  lt: { arity: [2, 2], call: (a, b) => compare(a, b) < 0 },
+  le: { arity: [2, 2], call: (a, b) => compare(a, b) <= 0 },
+  gt: { arity: [2, 2], call: (a, b) => compare(a, b) > 0 },
+  ge: { arity: [2, 2], call: (a, b) => compare(a, b) >= 0 },
+  json: { arity: [1, 1], call: value => JSON.stringify(value ?? null) },
+  join: {
+    arity: [2, 2],
+    call: (separator, list) => Array.isArray(list) ? list.map(formatValue).join(formatValue(separator)) : formatValue(list)
+  },
+  upper: { arity: [1, 1], call: value => formatValue(value).toUpperCase() },
+  lower: { arity: [1, 1], call: value => formatValue(value).toLowerCase() },
+  truncate: {
+    arity: [2, 2],
+    call: (length, value) => {
+      const text = formatValue(value);
+      const max = Number(length);
+      return text.length > max ? text.slice(0, Math.max(0, max - 3)) + '...' : text;
+    }
+  }
+};
+
+const KEYWORDS = new Set(['if', 'else', 'end', 'range', 'with']);
+
+/**
+ * Parse a template. Throws TemplateError on syntax errors, unknown
+ * functions and unbalanced `if`/`range`/`with`/`end`.
+ */
+export function parseTemplate(source: string): OutputTemplate {
+  const nodes = buildTree(splitSource(source));
+  return {
+    render: data => {
+      const out: string[] = [];
+      renderNodes(nodes, data, data, out);
+      return out.join('');
+    }
+  };
+}
+
+/**
+ * Parse and render in one go.
+ */
+export function renderTemplate(source: string, data: unknown): string {
+  return parseTemplate(source).render(data);
+}
+
+/**
+ * Text between actions and the content of each action, trim markers
+ * applied and comments dropped.
+ */
+function splitSource(source: string): Piece[] {
+  const pieces: Piece[] = [];
+  let i = 0;
+
+  for (;;) {
+    const open = source.indexOf('{{', i);
+    if (open === -1) {
+      pieces.push({ type: 'text', text: source.slice(i) });
+      return pieces;
+    }
+    let text = source.slice(i, open);
+    let start = open + 2;
+    // `{{-` needs whitespace after it, so `{{-3}}` stays a number
+    if (source[start] === '-' && /\s/.test(source[start + 1] ?? '')) {
+      text = text.trimEnd();
+      start += 2;
+    }
+    pieces.push({ type: 'text', text });
+
+    const close = findActionEnd(source, start, open);
+    let end = close;
+    const trimAfter = source[end - 1] === '-' && end - 2 >= start && /\s/.test(source[end - 2]);
+    if (trimAfter) {
+      end--;
+    }
+    const content = source.slice(start, end);
+    if (!content.trim().startsWith('/*')) {
+      pieces.push({ type: 'action', content, position: start });
+    }
+    i = close + 2;
+    if (trimAfter) {
+      while (i < source.length && /\s/.test(source[i])) {
+        i++;
+      }
+    }
+  }
+}
+
+/** Offset of the `}}` closing the action at `start`, skipping strings and comments */
+function findActionEnd(source: string, start: number, open: number): number {
+  let i = start;
+  while (i < source.length && /\s/.test(source[i])) {
+    i++;
+  }
+  if (source.startsWith('/*', i)) {
+    const commentEnd = source.indexOf('*/', i + 2);
+    const close = commentEnd === -1 ? -1 : source.indexOf('}}', commentEnd + 2);
+    if (close === -1 || source.slice(commentEnd + 2, close).replace(/[\s-]/g, '') !== '') {
+      throw new TemplateError('Unclosed comment', open);
+    }
+    return close;
+  }
+
+  let quote: string | null = null;
+  for (; i < source.length; i++) {
+    const char = source[i];
+    if (quote) {
+      if (char === '\\' && quote === '"') {
+        i++;
+      } else if (char === quote) {
+        quote = null;
+      }
+    } else if (char === '"' || char === '`') {
+      quote = char;
+    } else if (source.startsWith('}}', i)) {
+      return i;
+    }
+  }
+  throw new TemplateError('Unclosed action', open);
+}
+
+function buildTree(pieces: Piece[]): Node[] {
+  const root: Node[] = [];
+  const frames: Array<{ node: BlockNode; inElse: boolean; chained: boolean; position: number }> = [];
+  const target = () => {
+    const frame = frames[frames.length - 1];
+    return frame ? (frame.inElse ? frame.node.otherwise : frame.node.body) : root;
+  };
+
+  for (const piece of pieces) {
+    if (piece.type === 'text') {
+      if (piece.text) {
+        target().push({ type: 'text', text: piece.text });
+      }
+      continue;
+    }
+
+    const tokens = tokenize(piece.content, piece.position);
+    const first = tokens[0];
+    if (!first) {
+      throw new TemplateError('Empty action', piece.position);
+    }
+    const keyword = first.type === 'identifier' && KEYWORDS.has(first.name) ? first.name : undefined;
+
+    if (keyword === 'if' || keyword === 'with' || keyword === 'range') {
+      const node: BlockNode = { type: keyword, pipeline: parsePipeline(tokens.slice(1), first.position), body: [], otherwise: [] };
+      target().push(node);
+      frames.push({ node, inElse: false, chained: false, position: first.position });
+    } else if (keyword === 'else') {
+      const frame = frames[frames.length - 1];
+      if (!frame || frame.inElse) {
+        throw new TemplateError('Unexpected else', first.position);
+      }
+      frame.inElse = true;
+      const next = tokens[1];
+      if (next?.type === 'identifier' && (next.name === 'if' || next.name === 'with')) {
+        // `else if`: an if inside the else branch, closed by the same end
+        const node: BlockNode = { type: next.name, pipeline: parsePipeline(tokens.slice(2), next.position), body: [], otherwise: [] };
+        frame.node.otherwise.push(node);
+        frames.push({ node, inElse: false, chained: true, position: next.position });
+      } else if (next) {
+        throw new TemplateError('Unexpected token after else', next.position);
+      }
+    } else if (keyword === 'end') {
+      if (tokens.length > 1) {
+        throw new TemplateError('Unexpected token after end', tokens[1].position);
+      }
+      let frame = frames.pop();
+      if (!frame) {
+        throw new TemplateError('Unexpected end', first.position);
+      }
+      while (frame.chained) {
+        frame = frames.pop()!;
+      }
+    } else {
+      target().push({ type: 'output', pipeline: parsePipeline(tokens, first.position) });
+    }
+  }
+
+  const open = frames.find(frame => !frame.chained);
+  if (open) {
+    throw new TemplateError(`Missing end for ${open.node.type}`, open.position);
+  }
+  return root;
+}
+
+function tokenize(content: string, offset: number): Token[] {
+  const tokens: Token[] = [];
+  let i = 0;
+  let spaced = true;
+
+  while (i < content.length) {
+    const char = content[i];
+    const position = offset + i;
+    if (/\s/.test(char)) {
+      spaced = true;
+      i++;
+      continue;
+    }
+
+    if (char === '|' || char === '(' || char === ')') {
+      tokens.push({ type: char === '|' ? 'pipe' : char === '(' ? 'open' : 'close', position, spaced });
+      i++;
+    } else if (char === '"') {
+      let end = i + 1;
+      while (end < content.length && content[end] !== '"') {
+        end += content[end] === '\\' ? 2 : 1;
+      }
+      if (end >= content.length) {
+        throw new TemplateError('Unterminated string', position);
+      }
+      let value: string;
+      try {
+        value = JSON.parse(content.slice(i, end + 1));
+      } catch {
+        throw new TemplateError('Invalid string escape', position);
+      }
+      tokens.push({ type: 'literal', value, position, spaced });
+      i = end + 1;
+    } else if (char === '`') {
+      const end = content.indexOf('`', i + 1);
+      if (end === -1) {
+        throw new TemplateError('Unterminated raw string', position);
+      }
+      tokens.push({ type: 'literal', value: content.slice(i + 1, end), position, spaced });
+      i = end + 1;
+    } else if (char === '.' || char === '$') {
+      const match = /^(\$?)((?:\.[A-Za-z_][A-Za-z0-9_]*)*)/.exec(content.slice(i))!;
+      const text = char === '.' && match[0] === '' ? '.' : match[0];
+      if (char === '$' && /^[A-Za-z_]/.test(content[i + 1] ?? '')) {
+        throw new TemplateError('Variables are not supported', position);
+      }
+      tokens.push({ type: 'field', root: char === '$', path: match[2].split('.').filter(Boolean), position, spaced });
+      i += text.length;
+    } else if (/[0-9]/.test(char) || (char === '-' && /[0-9]/.test(content[i + 1] ?? ''))) {
+      const text = /^-?[0-9]+(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?/.exec(content.slice(i))![0];
+      tokens.push({ type: 'literal', value: Number(text), position, spaced });
+      i += text.length;
+    } else if (/[A-Za-z_]/.test(char)) {
+      const name = /^[A-Za-z_][A-Za-z0-9_]*/.exec(content.slice(i))![0];
+      if (name === 'true' || name === 'false' || name === 'nil') {
+        tokens.push({ type: 'literal', value: name === 'nil' ? undefined : name === 'true', position, spaced });
+      } else {
+        tokens.push({ type: 'identifier', name, position, spaced });
+      }
+      i += name.length;
+    } else {
+      throw new TemplateError(`Unexpected "${char}"`, position);
+    }
+    spaced = false;
+  }
+
+  return tokens;
+}
+
+function parsePipeline(tokens: Token[], position: number): Pipeline {
+  let index = 0;
+
+  const parseCommands = (start: number): Pipeline => {
+    const pipeline: Pipeline = [];
+    for (;;) {
+      const command = parseCommand(tokens[index]?.position ?? start);
+      if (pipeline.length > 0 && command.operands[0].type !== 'function') {
+        throw new TemplateError('Cannot pipe into a non-function', command.position);
+      }
+      pipeline.push(command);
+      if (tokens[index]?.type !== 'pipe') {
+        return pipeline;
+      }
+      index++;
+    }
+  };
+
+  const parseCommand = (start: number): Command => {
+    const operands: Operand[] = [];
+    for (let token = tokens[index]; token && token.type !== 'pipe' && token.type !== 'close'; token = tokens[index]) {
+      const operand = parseOperand();
+      if (operands.length > 0 && operand.type === 'function' && FUNCTIONS[operand.name].arity[0] > 0) {
+        throw new TemplateError(`Function "${operand.name}" needs parentheses to take arguments here`, token.position);
+      }
+      operands.push(operand);
+    }
+    if (operands.length === 0) {
+      throw new TemplateError('Missing value', tokens[index]?.position ?? start);
+    }
+    if (operands.length > 1 && operands[0].type !== 'function') {
+      throw new TemplateError('Cannot give arguments to a non-function', start);
+    }
+    return { operands, position: start };
+  };
+
+  const parseOperand = (): Operand => {
+    const token = tokens[index++];
+    switch (token.type) {
+      case 'field':
+        return { type: 'field', root: token.root, path: token.path };
+      case 'literal':
+        return { type: 'literal', value: token.value };
+      case 'identifier':
+        if (KEYWORDS.has(token.name)) {
+          throw new TemplateError(`Unexpected ${token.name}`, token.position);
+        }
+        if (!FUNCTIONS[token.name]) {
+          throw new TemplateError(`Unknown function "${token.name}"`, token.position);
+        }
+        return { type: 'function', name: token.name };
+      case 'open': {
+        const pipeline = parseCommands(token.position);
+        if (tokens[index]?.type !== 'close') {
+          throw new TemplateError('Unclosed "("', token.position);
+        }
+        index++;
+        // `(index .items 0).name`
+        const next = tokens[index];
+        if (next?.type === 'field' && !next.spaced && !next.root) {
+          index++;
+          return { type: 'sub', pipeline, path: next.path };
+        }
+        return { type: 'sub', pipeline, path: [] };
+      }
+      default:
+        throw new TemplateError(`Unexpected "${token.type === 'pipe' ? '|' : ')'}"`, token.position);
+    }
+  };
+
+  const pipeline = parseCommands(position);
+  if (index < tokens.length) {
+    throw new TemplateError('Unexpected ")"', tokens[index].position);
+  }
+  return pipeline;
+}
+
+function renderNodes(nodes: Node[], dot: unknown, root: unknown, out: string[]): void {
+  for (const node of nodes) {
+    switch (node.type) {
+      case 'text':
+        out.push(node.text);
+        break;
+      case 'output':
+        out.push(formatValue(evaluate(node.pipeline, dot, root)));
+        break;
+      case 'if': {
+        const value = evaluate(node.pipeline, dot, root);
+        renderNodes(isTruthy(value) ? node.body : node.otherwise, dot, root, out);
+        break;
+      }
+      case 'with': {
+        const value = evaluate(node.pipeline, dot, root);
+        if (isTruthy(value)) {
+          renderNodes(node.body, value, root, out);
+        } else {
+          renderNodes(node.otherwise, dot, root, out);
+        }
+        break;
+      }
+      case 'range': {
+        const items = rangeItems(evaluate(node.pipeline, dot, root), node.pipeline[0].position);
+        if (items.length === 0) {
+          renderNodes(node.otherwise, dot, root, out);
+        }
+        for (const item of items) {
+          renderNodes(node.body, item, root, out);
+        }
+        break;
+      }
+    }
+  }
+}
+
+function evaluate(pipeline: Pipeline, dot: unknown, root: unknown): unknown {
+  let value: unknown;
+  pipeline.forEach((command, i) => {
+    const [first, ...rest] = command.operands;
+    if (first.type !== 'function') {
+      value = evaluateOperand(first, dot, root);
+      return;
+    }
+    const fn = FUNCTIONS[first.name];
+    const args = rest.map(operand => evaluateOperand(operand, dot, root));
+    if (i > 0) {
+      args.push(value);
+    }
+    const [min, max] = fn.arity;
+    if (args.length < min || args.length > max) {
+      throw new TemplateError(`Wrong number of arguments for ${first.name}: got ${args.length}`, command.position);
+    }
+    try {
+      value = fn.call(...args);
+    } catch (error) {
+      throw new TemplateError(`${first.name}: ${error instanceof Error ? error.message : String(error)}`, command.position);
+    }
+  });
+  return value;
+}
+
+function evaluateOperand(operand: Operand, dot: unknown, root: unknown): unknown {
+  switch (operand.type) {
+    case 'field':
+      return operand.path.reduce(lookupField, operand.root ? root : dot);
+    case 'literal':
+      return operand.value;
+    case 'function':
+      return FUNCTIONS[operand.name].call();
+    case 'sub':
+      return operand.path.reduce(lookupField, evaluate(operand.pipeline, dot, root));
+  }
+}
+
+/** Field by exact name, else case-insensitively (`.Name` on `name`) */
+function lookupField(value: unknown, key: string): unknown {
+  if (value === null || typeof value !== 'object') {
+    return undefined;
+  }
+  const record = value as Record<string, unknown>;
+  if (Object.prototype.hasOwnProperty.call(record, key)) {
+    return record[key];
+  }
+  const lower = key.toLowerCase();
+  const match = Object.keys(record).find(candidate => candidate.toLowerCase() === lower);
+  return match === undefined ? undefined : record[match];
+}
+
+/** `index`: list elements by position, object entries by exact key */
+function lookupKey(value: unknown, key: unknown): unknown {
+  if (value === null || typeof value !== 'object') {
+    return undefined;
+  }
+  return (value as Record<string, unknown>)[String(key)];
+}
+
+function rangeItems(value: unknown, position: number): unknown[] {
+  if (value === undefined || value === null) {
+    return [];
+  }
+  if (Array.isArray(value)) {
+    return value;
+  }
+  if (typeof value === 'number' && Number.isInteger(value)) {
+    return Array.from({ length: Math.max(0, value) }, (_, i) => i);
+  }
+  if (typeof value === 'object') {
+    // Like Go maps: values in key order
+    const record = value as Record<string, unknown>;
+    return Object.keys(record).sort().map(key => record[key]);
+  }
+  throw new TemplateError(`Cannot range over ${typeof value}`, position);
+}
+
+/** Go truthiness: false, 0, nil, and empty strings, lists and objects are false */
+function isTruthy(value: unknown): boolean {
+  if (Array.isArray(value)) {
+    return value.length > 0;
+  }
+  if (value !== null && typeof value === 'object') {
+    return Object.keys(value).length > 0;
+  }
+  return Boolean(value);
+}
+
+function lengthOf(value: unknown): number {
+  if (typeof value === 'string' || Array.isArray(value)) {
+    return value.length;
+  }
+  if (value !== null && typeof value === 'object') {
+    return Object.keys(value).length;
+  }
+  throw new Error(`len of ${value === undefined ? 'nil' : typeof value}`);
+}
+
+function compare(a: unknown, b: unknown): number {
+  if (typeof a !== typeof b || (typeof a !== 'number' && typeof a !== 'string')) {
+    throw new Error('incompatible types for comparison');
+  }
+  return a === b ? 0 : (a as number | string) < (b as number | string) ? -1 : 1;
+}
+
+function formatValue(value: unknown): string {
+  if (value === undefined || value === null) {
+    return '';
+  }
+  if (typeof value === 'string') {
+    return value;
+  }
+  if (typeof value === 'object') {
+    return JSON.stringify(value);
+  }
+  return String(value);
+}
+
+/** Go's Sprint: operands joined, with spaces between two non-strings */
+function sprint(args: unknown[]): string {
+  let text = '';
+  args.forEach((arg, i) => {
+    if (i > 0 && typeof arg !== 'string' && typeof args[i - 1] !== 'string') {
+      text += ' ';
+    }
+    text += formatValue(arg);
+  });
+  return text;
+}
+
+/**
+ * Go's Sprintf for the common verbs: %s %v %q %d %f %x %t %%, with `-`,
+ * `+` and `0` flags, width and precision.
+ */
+function sprintf(format: string, args: unknown[]): string {
+  let next = 0;
+  return format.replace(/%([-+0]*)(\d+)?(?:\.(\d+))?([svqdfxt%])/g, (spec, flags: string, width?: string, precision?: string, verb?: string) => {
+    if (verb === '%') {
+      return '%';
+    }
+    if (next >= args.length) {
+      return `%!${verb}(MISSING)`;
+    }
+    const arg = args[next++];
+    let text: string;
+    switch (verb) {
+      case 'd':
+        text = typeof arg === 'number' ? Math.trunc(arg).toString() : `%!d(${formatValue(arg)})`;
+        break;
+      case 'f':
+        text = typeof arg === 'number' ? arg.toFixed(precision !== undefined ? Number(precision) : 6) : `%!f(${formatValue(arg)})`;
+        break;
+      case 'x':
+        text = typeof arg === 'number'
+          ? Math.trunc(arg).toString(16)
+          : Buffer.from(formatValue(arg), 'utf-8').toString('hex');
+        break;
+      case 'q':
+        text = JSON.stringify(formatValue(arg));
+        break;
+      case 't':
+        text = String(Boolean(arg));
+        break;
+      default:
+        text = formatValue(arg);
+        if (precision !== undefined) {
+          text = text.slice(0, Number(precision));
+        }
+    }
+    if (flags.includes('+') && (verb === 'd' || verb === 'f') && typeof arg === 'number' && arg >= 0) {
+      text = '+' + text;
+    }
+    const pad = width !== undefined ? Number(width) - text.length : 0;
+    if (pad <= 0) {
+      return text;
+    }
+    if (flags.includes('-')) {
+      return text + ' '.repeat(pad);
+    }
+    if (flags.includes('0') && (verb === 'd' || verb === 'f') && typeof arg === 'number') {
+      const sign = /^[+-]/.test(text) ? text[0] : '';
+      return sign + '0'.repeat(pad) + text.slice(sign.length);
+    }
+    return ' '.repeat(pad) + text;
+  });
+}
@@ -0,0 +1,88 @@
+/**
+ * Pagination Tests
+ *
+ * Verifies page windows, cursor round trips and resuming after changes.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { CursorError, pageWindow, PageWindow, queryFingerprint, takePage, takePageStream } from './pagination.js';
+
+const key = (item: string) => item;
+
+describe('pagination', () => {
+  const fingerprint = queryFingerprint('symbols', 'User');
+
+  it('should page by offset with the page size capped', () => {
+    const results = ['a', 'b', 'c', 'd', 'e'];
+    const window = pageWindow({ limit: 2, offset: 1 }, 10, 100, fingerprint);
+    expect(window).toMatchObject({ limit: 2, offset: 1, fetch: 4 });
+    expect(takePage(results, window, key)).toMatchObject({ items: ['b', 'c'], offset: 1 });
+
+    expect(pageWindow({ limit: 500 }, 10, 100, fingerprint).limit).toBe(100);
+    expect(pageWindow({}, 10, 100, fingerprint).limit).toBe(10);
+    expect(pageWindow({ limit: 0 }, 10, 100, fingerprint).limit).toBe(0);
+    expect(takePage(results, pageWindow({ offset: 9 }, 10, 100, fingerprint), key)).toEqual({ items: [], offset: 9 });
+    expect(() => pageWindow({ offset: -1 }, 10, 100, fingerprint)).toThrow('Invalid offset: -1');
+  });
+
+  it('should continue after the cursor until the last page', () => {
+    const results = ['a', 'b', 'c', 'd', 'e'];
+    const pages: string[][] = [];
+    let cursor: string | undefined;
+    do {
+      const page = takePage(results, pageWindow({ limit: 2, cursor }, 10, 100, fingerprint), key);
+      pages.push(page.items);
+      cursor = page.nextCursor;
+    } while (cursor);
+    expect(pages).toEqual([['a', 'b'], ['c', 'd'], ['e']]);
+  });
+
+  it('should resume after the cursor result when results change in front of it', () => {
+    const first = takePage(['a', 'b', 'c', 'd'], pageWindow({ limit: 2 }, 10, 100, fingerprint), key);
+    const resume = (results: string[]) => takePage(results, pageWindow({ limit: 2, cursor: first.nextCursor }, 10, 100, fingerprint), key);
+
+    expect(resume(['x', 'a', 'b', 'c', 'd'])).toMatchObject({ items: ['c', 'd'], offset: 3 });
+    expect(resume(['b', 'c', 'd'])).toMatchObject({ items: ['c', 'd'], offset: 1 });
+    // Without the cursor result, the page starts at the recorded offset
+    expect(resume(['a', 'c', 'd', 'e'])).toMatchObject({ items: ['d', 'e'], offset: 2 });
+  });
+
+  it('should refuse cursors of other queries and malformed cursors', () => {
+    const { nextCursor } = takePage(['a', 'b'], pageWindow({ limit: 1 }, 10, 100, fingerprint), key);
+    expect(() => pageWindow({ cursor: nextCursor }, 10, 100, queryFingerprint('symbols', 'Order'))).toThrow(CursorError);
+    expect(() => pageWindow({ cursor: 'not-a-cursor' }, 10, 100, fingerprint)).toThrow('Invalid cursor');
+    expect(() => pageWindow({ cursor: Buffer.from('{"q":1}').toString('base64url') }, 10, 100, fingerprint)).toThrow('Invalid cursor');
+  });
+
+  it('should stream the same pages from batches, reading no further than needed', async () => {
+    const batches = [['a', 'b'], ['c'], ['d', 'e']];
+    let read = 0;
+    async function* source() {
+      for (const batch of batches) {
+        read++;
+        yield batch;
+      }
+    }
+    const stream = async (window: PageWindow, results: AsyncIterable<string[]> | Iterable<string[]> = source()) => {
+      const page = takePageStream(results, window, key);
+      const items: string[] = [];
+      for await (const item of page.items) {
+        items.push(item);
+      }
+      return { items, nextCursor: page.nextCursor() };
+    };
+
+    const first = await stream(pageWindow({ limit: 2 }, 10, 100, fingerprint));
+    const { items, nextCursor } = takePage(batches.flat(), pageWindow({ limit: 2 }, 10, 100, fingerprint), key);
+    expect(first).toEqual({ items, nextCursor });
+    expect(read).toBe(2);
+
+    const next = pageWindow({ limit: 2, cursor: first.nextCursor }, 10, 100, fingerprint);
+    const second = await stream(next);
+    expect([second.items, typeof second.nextCursor]).toEqual([['c', 'd'], 'string']);
+    expect(await stream(next, [['x', 'a', 'b'], ['c', 'd']])).toEqual({ items: ['c', 'd'], nextCursor: undefined });
+    // Without the cursor result, the page starts at the recorded offset
+    expect(await stream(next, [['a', 'c'], ['d', 'e']])).toEqual({ items: ['d', 'e'], nextCursor: undefined });
+    expect((await stream(pageWindow({ limit: 0 }, 10, 100, fingerprint))).items).toEqual([]);
+  });
+});
@@ -0,0 +1,212 @@
+import * as crypto from 'crypto';
+
+/*
+ * Pagination for queries whose results do not fit one response: search,
+ * references and structured queries on large repositories. A page is
+ * asked for by `offset`, or by the `nextCursor` of the previous page.
+ *
+ * Cursors are opaque strings holding where the next page starts and the
+ * key of the last result handed out (a symbol id, a reference location).
+ * Resuming looks that result up again, so results added or removed in
+ * front of it while paging neither repeat nor get skipped. Only if the
+ * result itself is gone does the page start at the recorded offset. A
+ * cursor also holds a fingerprint of its query and is refused by others.
+ */
+
+/** Which page of results to return */
+export interface PageOptions {
+  /** Results per page */
+  limit?: number;
+  /** Results to skip (default: 0); ignored with a cursor */
+  offset?: number;
+  /** `nextCursor` of the previous page */
+  cursor?: string;
+}
+
+export interface Page<T> {
+  items: T[];
+  /** Position of the first item among all results */
+  offset: number;
+  /** Cursor of the next page; unset on the last page */
+  nextCursor?: string;
+}
+
+/**
+ * Where a page starts and how many results make it. `fetch` results are
+ * enough to fill the page and see whether another follows, unless results
+ * were added in front of the cursor since.
+ */
+export interface PageWindow {
+  limit: number;
+  /** First result, or where the cursor expects it */
+  offset: number;
+  /** Key of the result the page starts after */
+  after?: string;
+  fingerprint: string;
+  fetch: number;
+}
+
+/** A malformed cursor, or one that belongs to a different query */
+export class CursorError extends Error {}
+
+interface CursorState {
+  /** Query fingerprint */
+  q: string;
+  /** Offset of the next page */
+  o: number;
+  /** Key of the last result of the previous page */
+  k: string;
+}
+
+/**
+ * Fingerprint of what a query asks for, such as the endpoint and its
+ * parameters. Paging parameters must not be part of it.
+ */
+export function queryFingerprint(...parts: unknown[]): string {
+  return crypto.createHash('sha256').update(JSON.stringify(parts)).digest('base64url').substring(0, 12);
+}
+
+/**
+ * The page to return for the options: up to `limit` results (capped at
+ * `maxLimit`), from the offset or after the cursor. Throws CursorError on
+ * a cursor of another query and RangeError on a negative offset.
+ */
+export function pageWindow(options: PageOptions, defaultLimit: number, maxLimit: number, fingerprint: string): PageWindow {
+  const limit = Math.min(options.limit ?? defaultLimit, maxLimit);
+  if (options.cursor) {
+    const state = decodeCursor(options.cursor);
+    if (state.q !== fingerprint) {
+      throw new CursorError('Cursor belongs to a different query');
+    }
+    return { limit, offset: state.o, after: state.k, fingerprint, fetch: state.o + limit + 1 };
+  }
+  const offset = options.offset ?? 0;
+  if (!Number.isInteger(offset) || offset < 0) {
+    throw new RangeError(`Invalid offset: ${offset}`);
+  }
+  return { limit, offset, fingerprint, fetch: offset + limit + 1 };
+}
+
+/**
+ * The window's page of results, which are in the order all pages share.
+ * `keyOf` identifies a result across queries.
+ */
+export function takePage<T>(results: T[], window: PageWindow, keyOf: (result: T) => string): Page<T> {
+  const start = pageStart(results, window, keyOf);
+  const items = results.slice(start, start + window.limit);
+  const more = results.length > start + items.length && items.length > 0;
+  return {
+    items,
+    offset: start,
+    ...(more && {
+      nextCursor: encodeCursor({ q: window.fingerprint, o: start + items.length, k: keyOf(items[items.length - 1]) })
+    })
+  };
+}
+
+/** A page whose items arrive as the results are read */
+export interface PageStream<T> {
+  items: AsyncIterable<T>;
+  /** Cursor of the next page, once items ran out; unset on the last page */
+  nextCursor(): string | undefined;
+}
+
+/**
+ * takePage over results read in batches: items are handed out as their
+ * batch arrives, and reading stops after the one that shows another page
+ * follows. Only when the cursor's result is gone are the results from
+ * the recorded offset held until the end shows it missing.
+ */
+export function takePageStream<T>(
+  batches: AsyncIterable<T[]> | Iterable<T[]>,
+  window: PageWindow,
+  keyOf: (result: T) => string
+): PageStream<T> {
+  let nextCursor: string | undefined;
+  const cursorAfter = (count: number, last: T) => {
+    nextCursor = encodeCursor({ q: window.fingerprint, o: count, k: keyOf(last) });
+  };
+
+  async function* items(): AsyncIterable<T> {
+    let index = 0;
+    // Where the page starts, once known
+    let start = window.after === undefined ? window.offset : -1;
+    let taken = 0;
+    let last: T | undefined;
+    const fromOffset: T[] = [];
+
+    for await (const batch of batches) {
+      for (const result of batch) {
+        const at = index++;
+        if (start < 0) {
+          if (keyOf(result) === window.after) {
+            start = at + 1;
+            fromOffset.length = 0;
+          } else if (at >= window.offset && fromOffset.length <= window.limit) {
+            fromOffset.push(result);
+          }
+          continue;
+        }
+        if (at < start) {
+          continue;
+        }
+        if (taken === window.limit) {
+          if (taken > 0) {
+            cursorAfter(start + taken, last!);
+          }
+          return;
+        }
+        taken++;
+        last = result;
+        yield result;
+      }
+    }
+
+    // The cursor's result is gone: the page starts at the recorded offset
+    if (start < 0) {
+      const page = fromOffset.slice(0, window.limit);
+      yield* page;
+      if (fromOffset.length > page.length && page.length > 0) {
+        cursorAfter(window.offset + page.length, page[page.length - 1]);
+      }
+    }
+  }
+
+  return { items: items(), nextCursor: () => nextCursor };
+}
+
+function pageStart<T>(results: T[], window: PageWindow, keyOf: (result: T) => string): number {
+  if (window.after === undefined) {
+    return window.offset;
+  }
+  // Usually nothing changed and the cursor's result is right in front of the offset
+  const expected = window.offset - 1;
+  if (expected >= 0 && expected < results.length && keyOf(results[expected]) === window.after) {
+    return window.offset;
+  }
+  const index = results.findIndex(result => keyOf(result) === window.after);
+  return index >= 0 ? index + 1 : Math.min(window.offset, results.length);
+}
+
+function encodeCursor(state: CursorState): string {
+  return Buffer.from(JSON.stringify(state)).toString('base64url');
+}
+
+function decodeCursor(cursor: string): CursorState {
+  let state: Partial<CursorState> | null;
+  try {
+    state = JSON.parse(Buffer.from(cursor, 'base64url').toString('utf-8'));
+  } catch {
+    throw new CursorError('Invalid cursor');
+  }
+  if (!state || typeof state.q !== 'string' || typeof state.k !== 'string' ||
+      !Number.isInteger(state.o) || (state.o as number) < 0) {
+    throw new CursorError('Invalid cursor');
+  }
+  return state as CursorState;
+}
+
+/** Key of a reference or symbol by where it is */
+export function locationKey(result: { location: { uri: string; line: number; character: number } }): string {
+  return `${result.location.uri}:${result.location.line}:${result.location.character}`;
+}
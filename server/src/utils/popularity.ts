@@ -0,0 +1,68 @@
+/*
+ * Symbol popularity, a search ranking signal: how often a name is
+ * referenced across the codebase, how recently its file changed, and
+ * whether it is exported. Counts are per name, not per declaration, so
+ * same-named symbols in different packages share theirs; in practice the
+ * names a fuzzy query matches (`New` -> `NewServer`, `NewStore`, ...)
+ * differ, and the ones callers use float to the top.
+ *
+ * Every part has a cap so popularity reorders results of similar match
+ * quality without letting a popular loose match beat an exact one.
+ */
+
+/** Points for references: 10 per factor of ten, up to 30 (1000 references) */
+const REFERENCE_WEIGHT = 10;
+const MAX_REFERENCE_POINTS = 30;
+
+/** Points for a file changed just now, halving every RECENCY_HALF_LIFE_MS */
+const MAX_RECENCY_POINTS = 10;
+const RECENCY_HALF_LIFE_MS = 30 * 24 * 60 * 60 * 1000;
+
+const EXPORTED_POINTS = 5;
+
+export interface PopularityScore {
+  /** Sum of the parts below */
+  score: number;
+  /** Non-local references to the symbol's name */
+  references: number;
+  /** Points for the recency of the symbol's file */
+  recency: number;
+  /** Points for being exported */
+  exported: number;
+}
+
+/** Where the signals come from */
+export interface PopularitySources {
+  /** Reference count per name; names missing have none */
+  referenceCounts: Map<string, number>;
+  /** Last modification time of a file (ms since epoch), if known */
+  modifiedAt?: (uri: string) => number | undefined;
+  /** Current time for the recency decay (default: Date.now()) */
+  now?: number;
+}
+
+/**
+ * Popularity of one symbol, rounded to one decimal.
+ */
+export function popularityScore(
+  symbol: { name: string; location?: { uri: string } | string; isExported?: boolean },
+  sources: PopularitySources
+): PopularityScore {
+  const references = sources.referenceCounts.get(symbol.name) ?? 0;
+  const referencePoints = Math.min(MAX_REFERENCE_POINTS, REFERENCE_WEIGHT * Math.log10(1 + references));
+
+  const uri = typeof symbol.location === 'string' ? symbol.location : symbol.location?.uri;
+  const modifiedAt = uri ? sources.modifiedAt?.(uri) : undefined;
+  let recency = 0;
+  if (modifiedAt !== undefined) {
+    const age = Math.max(0, (sources.now ?? Date.now()) - modifiedAt);
+    recency = round(MAX_RECENCY_POINTS * Math.pow(0.5, age / RECENCY_HALF_LIFE_MS));
+  }
+
+  const exported = symbol.isExported ? EXPORTED_POINTS : 0;
+  return { score: round(referencePoints + recency + exported), references, recency, exported };
+}
+
+function round(value: number): number {
+  return Math.round(value * 10) / 10;
+}
@@ -0,0 +1,65 @@
+/**
+ * Power Source Tests
+ *
+ * Verifies reading the power source from Linux sysfs, `pmset -g batt`
+ * and Win32_Battery, and that what cannot be read is unknown.
+ */
+
+import { describe, it, expect, afterEach } from 'vitest';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { isOnBattery, linuxOnBattery, parsePmset, parseWindowsBatteryStatus } from './powerSource.js';
+
+describe('powerSource', () => {
+  let tempDir: string | undefined;
+
+  afterEach(() => {
+    if (tempDir) {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+      tempDir = undefined;
+    }
+  });
+
+  function supplies(entries: Record<string, Record<string, string>>): string {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'power-'));
+    for (const [name, files] of Object.entries(entries)) {
+      fs.mkdirSync(path.join(tempDir, name));
+      for (const [file, content] of Object.entries(files)) {
+        fs.writeFileSync(path.join(tempDir, name, file), `${content}\n`);
+      }
+    }
+    return tempDir;
+  }
+
+  it('should read Linux supplies, preferring the mains adapter', async () => {
+    expect(await linuxOnBattery(supplies({
+      AC: { type: 'Mains', online: '0' },
+      BAT0: { type: 'Battery', status: 'Discharging' }
+    }))).toBe(true);
+    expect(await linuxOnBattery(supplies({
+      ADP1: { type: 'Mains', online: '1' },
+      BAT0: { type: 'Battery', status: 'Discharging' }
+    }))).toBe(false);
+    // No adapter listed
+    expect(await linuxOnBattery(supplies({ BAT1: { type: 'Battery', status: 'Charging' } }))).toBe(false);
+    // Desktop: nothing but a USB peripheral
+    expect(await linuxOnBattery(supplies({ 'hidpp_battery_0': { type: 'USB' } }))).toBeUndefined();
+  });
+
+  it('should parse pmset and Win32_Battery output', () => {
+    expect(parsePmset("Now drawing from 'Battery Power'\n -InternalBattery-0 (id=1)\t81%; discharging;")).toBe(true);
+    expect(parsePmset("Now drawing from 'AC Power'\n -InternalBattery-0 (id=1)\t100%; charged;")).toBe(false);
+    expect(parsePmset('')).toBeUndefined();
+
+    expect(parseWindowsBatteryStatus('1\r\n')).toBe(true);
+    expect(parseWindowsBatteryStatus('2\r\n')).toBe(false);
+    // One battery charging
+    expect(parseWindowsBatteryStatus('1\r\n6\r\n')).toBe(false);
+    expect(parseWindowsBatteryStatus('\r\n')).toBeUndefined();
+  });
+
+  it('should not know on other platforms', async () => {
+    expect(await isOnBattery('aix')).toBeUndefined();
+  });
+});
@@ -0,0 +1,93 @@
+/**
+ * Whether the machine runs on battery, so background work can wait for
+ * the charger (see index/indexThrottle.ts).
+ *
+ * - Linux: /sys/class/power_supply, on battery when no mains supply is
+ *   online (or, without one listed, when a battery is discharging)
+ * - macOS: `pmset -g batt`
+ * - Windows: BatteryStatus of Win32_Battery
+ *
+ * Undefined when it cannot tell: no battery, another platform, a probe
+ * that fails. Callers treat that as on mains.
+ */
+
+import { execFile } from 'child_process';
+import * as fsPromises from 'fs/promises';
+import * as path from 'path';
+
+const PROBE_TIMEOUT_MS = 5000;
+const LINUX_POWER_SUPPLY_DIR = '/sys/class/power_supply';
+
+/** Win32_Battery BatteryStatus values of a discharging battery: other, low, critical */
+const WINDOWS_DISCHARGING = new Set([1, 4, 5]);
+
+export async function isOnBattery(platform: NodeJS.Platform = process.platform): Promise<boolean | undefined> {
+  try {
+    switch (platform) {
+      case 'linux':
+        return await linuxOnBattery(LINUX_POWER_SUPPLY_DIR);
+      case 'darwin':
+        return parsePmset(await run('pmset', ['-g', 'batt']));
+      case 'win32':
+        return parseWindowsBatteryStatus(await run('powershell.exe', [
+          '-NoProfile', '-NonInteractive', '-Command', '(Get-CimInstance -ClassName Win32_Battery).BatteryStatus'
+        ]));
+      default:
+        return undefined;
+    }
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * Reads the `type`, `online` and `status` files of each supply under dir.
+ */
+export async function linuxOnBattery(dir: string): Promise<boolean | undefined> {
+  let mains: boolean | undefined;
+  let discharging: boolean | undefined;
+  for (const name of await fsPromises.readdir(dir)) {
+    const read = (file: string) => fsPromises.readFile(path.join(dir, name, file), 'utf-8').then(text => text.trim(), () => '');
+    const type = await read('type');
+    if (type === 'Mains') {
+      mains = mains === true || await read('online') === '1';
+    } else if (type === 'Battery') {
+      discharging = discharging === true || await read('status') === 'Discharging';
+    }
+  }
+  return mains !== undefined ? !mains : discharging;
+}
+
+/**
+ * `Now drawing from 'Battery Power'` or `'AC Power'` on the first line.
+ */
+export function parsePmset(output: string): boolean | undefined {
+  const source = /drawing from '([^']+)'/.exec(output)?.[1];
+  if (source === undefined) {
+    return undefined;
+  }
+  return source === 'Battery Power';
+}
+
+/**
+ * One status per battery; nothing when there is none.
+ */
+export function parseWindowsBatteryStatus(output: string): boolean | undefined {
+  const statuses = output.split(/\s+/).filter(Boolean).map(Number).filter(Number.isInteger);
+  if (statuses.length === 0) {
+    return undefined;
+  }
+  return statuses.every(status => WINDOWS_DISCHARGING.has(status));
+}
+
+function run(command: string, args: string[]): Promise<string> {
+  return new Promise((resolve, reject) => {
+    execFile(command, args, { timeout: PROBE_TIMEOUT_MS, windowsHide: true }, (error, stdout) => {
+      if (error) {
+        reject(error);
+      } else {
+        resolve(stdout);
+      }
+    });
+  });
+}
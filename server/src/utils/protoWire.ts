@@ -0,0 +1,243 @@
+/**
+ * Minimal Protocol Buffers wire-format encoding (proto3), enough for the
+ * messages in server/proto without a protobuf runtime dependency.
+ *
+ * Varint (0), length-delimited (2) and, for doubles, fixed64 (1) fields
+ * are written; readers skip fields they do not know so newer writers can
+ * add them.
+ */
+
+export const WIRE_VARINT = 0;
+export const WIRE_FIXED64 = 1;
+export const WIRE_LENGTH_DELIMITED = 2;
+export const WIRE_FIXED32 = 5;
+
+export class ProtoWriter {
+  private buffer: Uint8Array;
+  private length = 0;
+
+  constructor(initialCapacity: number = 256) {
+    this.buffer = new Uint8Array(initialCapacity);
+  }
+
+  /** Bytes written so far */
+  get size(): number {
+    return this.length;
+  }
+
+  /** Non-negative integer up to 2^53; omitted when 0 (proto3 default) */
+  uint(field: number, value: number): this {
+    if (value) {
+      this.tag(field, WIRE_VARINT);
+      this.varint(value);
+    }
+    return this;
+  }
+
+  /** proto3 `optional` integer: written whenever set, even when 0 */
+  optional(field: number, value: number | undefined): this {
+    if (value !== undefined) {
+      this.tag(field, WIRE_VARINT);
+      this.varint(value);
+    }
+    return this;
+  }
+
+  /** Omitted when 0 */
+  double(field: number, value: number): this {
+    if (value) {
+      this.tag(field, WIRE_FIXED64);
+      this.ensure(8);
+      new DataView(this.buffer.buffer, this.buffer.byteOffset).setFloat64(this.length, value, true);
+      this.length += 8;
+    }
+    return this;
+  }
+
+  bool(field: number, value: boolean | undefined): this {
+    return this.uint(field, value ? 1 : 0);
+  }
+
+  /** Omitted when empty or undefined */
+  string(field: number, value: string | undefined): this {
+    if (value) {
+      this.bytes(field, Buffer.from(value, 'utf-8'));
+    }
+    return this;
+  }
+
+  bytes(field: number, value: Uint8Array): this {
+    this.tag(field, WIRE_LENGTH_DELIMITED);
+    this.varint(value.length);
+    this.raw(value);
+    return this;
+  }
+
+  /** Packed repeated varints; omitted when empty */
+  packed(field: number, values: ArrayLike<number>): this {
+    if (values.length === 0) {
+      return this;
+    }
+    const inner = new ProtoWriter(values.length * 2);
+    for (let i = 0; i < values.length; i++) {
+      inner.varint(values[i]);
+    }
+    return this.bytes(field, inner.finish());
+  }
+
+  /** Embedded message; written even when empty so repeated entries keep their position */
+  message(field: number, message: ProtoWriter): this {
+    return this.bytes(field, message.finish());
+  }
+
+  /** Length-prefixed message without a tag, for streams of records */
+  delimited(message: ProtoWriter): this {
+    const bytes = message.finish();
+    this.varint(bytes.length);
+    this.raw(bytes);
+    return this;
+  }
+
+  finish(): Uint8Array {
+    return this.buffer.subarray(0, this.length);
+  }
+
+  varint(value: number): void {
+    this.ensure(10);
+    while (value >= 0x80) {
+      this.buffer[this.length++] = (value % 0x80) | 0x80;
+      value = Math.floor(value / 0x80);
+    }
+    this.buffer[this.length++] = value;
+  }
+
+  private tag(field: number, wireType: number): void {
+    this.varint(field * 8 + wireType);
+  }
+
+  private raw(bytes: Uint8Array): void {
+    this.ensure(bytes.length);
+    this.buffer.set(bytes, this.length);
+    this.length += bytes.length;
+  }
+
+  private ensure(extra: number): void {
+    if (this.length + extra <= this.buffer.length) {
+      return;
+    }
+    let capacity = this.buffer.length * 2;
+    while (capacity < this.length + extra) {
+      capacity *= 2;
+    }
+    const grown = new Uint8Array(capacity);
+    grown.set(this.buffer.subarray(0, this.length));
+    this.buffer = grown;
+  }
+}
+
+export class ProtoReader {
+  private position: number;
+
+  constructor(
+    private readonly buffer: Uint8Array,
+    start: number = 0,
+    private readonly end: number = buffer.length
+  ) {
+    this.position = start;
+  }
+
+  hasMore(): boolean {
+    return this.position < this.end;
+  }
+
+  /**
+   * Next field tag. Call the matching reader (or `skip`) before the next call.
+   */
+  tag(): { field: number; wireType: number } {
+    const tag = this.varint();
+    return { field: Math.floor(tag / 8), wireType: tag % 8 };
+  }
+
+  varint(): number {
+    let result = 0;
+    let multiplier = 1;
+    for (;;) {
+      if (this.position >= this.end) {
+        throw new Error('Truncated varint');
+      }
+      const byte = this.buffer[this.position++];
+      result += (byte & 0x7f) * multiplier;
+      if (byte < 0x80) {
+        return result;
+      }
+      multiplier *= 0x80;
+    }
+  }
+
+  bool(): boolean {
+    return this.varint() !== 0;
+  }
+
+  double(): number {
+    if (this.position + 8 > this.end) {
+      throw new Error('Truncated fixed64 field');
+    }
+    const value = new DataView(this.buffer.buffer, this.buffer.byteOffset).getFloat64(this.position, true);
+    this.position += 8;
+    return value;
+  }
+
+  bytes(): Uint8Array {
+    const length = this.varint();
+    const start = this.position;
+    this.position += length;
+    if (this.position > this.end) {
+      throw new Error('Truncated length-delimited field');
+    }
+    return this.buffer.subarray(start, this.position);
+  }
+
+  string(): string {
+    const bytes = this.bytes();
+    return Buffer.from(bytes.buffer, bytes.byteOffset, bytes.byteLength).toString('utf-8');
+  }
+
+  /** Packed repeated varints (also accepts a single unpacked value) */
+  packed(wireType: number): number[] {
+    if (wireType === WIRE_VARINT) {
+      return [this.varint()];
+    }
+    const bytes = this.bytes();
+    const inner = new ProtoReader(bytes);
+    const values: number[] = [];
+    while (inner.hasMore()) {
+      values.push(inner.varint());
+    }
+    return values;
+  }
+
+  /** Reader over an embedded message */
+  message(): ProtoReader {
+    const bytes = this.bytes();
+    return new ProtoReader(bytes);
+  }
+
+  skip(wireType: number): void {
+    switch (wireType) {
+      case WIRE_VARINT:
+        this.varint();
+        break;
+      case WIRE_FIXED64:
+        this.position += 8;
+        break;
+      case WIRE_LENGTH_DELIMITED:
+        this.bytes();
+        break;
+      case WIRE_FIXED32:
+        this.position += 4;
+        break;
+      default:
+        throw new Error(`Unsupported wire type ${wireType}`);
+    }
+  }
+}
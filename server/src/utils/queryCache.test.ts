@@ -0,0 +1,82 @@
+/**
+ * QueryCache Tests
+ *
+ * Verifies hits and misses, LRU eviction, invalidation by generation and
+ * sharing of lookups in flight.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { QueryCache } from './queryCache.js';
+
+describe('QueryCache', () => {
+  it('should compute on a miss and answer hits from the cache', async () => {
+    const cache = new QueryCache();
+    let computed = 0;
+    const compute = async () => ++computed;
+    const lookups: boolean[] = [];
+
+    expect(await cache.get('a', 1, compute, { onLookup: hit => lookups.push(hit) })).toBe(1);
+    expect(await cache.get('a', 1, compute, { onLookup: hit => lookups.push(hit) })).toBe(1);
+    expect(lookups).toEqual([false, true]);
+    expect(cache.stats()).toEqual({ entries: 1, hits: 1, misses: 1 });
+  });
+
+  it('should drop every entry when the generation changes', async () => {
+    const cache = new QueryCache();
+    await cache.get('a', 1, async () => 'old');
+    await cache.get('b', 1, async () => 'old');
+
+    expect(await cache.get('a', 2, async () => 'new')).toBe('new');
+    expect(cache.stats().entries).toBe(1);
+  });
+
+  it('should evict the least recently used entry', async () => {
+    const cache = new QueryCache(2);
+    await cache.get('a', 1, async () => 'a');
+    await cache.get('b', 1, async () => 'b');
+    await cache.get('a', 1, async () => 'a2');
+    await cache.get('c', 1, async () => 'c');
+
+    expect(await cache.get('a', 1, async () => 'a3')).toBe('a');
+    expect(await cache.get('b', 1, async () => 'b2')).toBe('b2');
+
+    cache.resize(1);
+    expect(cache.stats().entries).toBe(1);
+  });
+
+  it('should share a lookup in flight between identical queries', async () => {
+    const cache = new QueryCache();
+    let computed = 0;
+    const compute = async () => {
+      computed++;
+      await new Promise(resolve => setTimeout(resolve, 5));
+      return 'result';
+    };
+
+    const results = await Promise.all([cache.get('a', 1, compute), cache.get('a', 1, compute)]);
+    expect(results).toEqual(['result', 'result']);
+    expect(computed).toBe(1);
+  });
+
+  it('should not keep results refused by keep or from an old generation', async () => {
+    const cache = new QueryCache();
+    await cache.get('error', 1, async () => 500, { keep: status => status < 500 });
+    expect(cache.stats().entries).toBe(0);
+
+    let release!: () => void;
+    const slow = cache.get('a', 1, () => new Promise<string>(resolve => { release = () => resolve('stale'); }));
+    await cache.get('b', 2, async () => 'b');
+    release();
+    expect(await slow).toBe('stale');
+    expect(await cache.get('a', 2, async () => 'fresh')).toBe('fresh');
+  });
+
+  it('should compute every lookup when disabled', async () => {
+    const cache = new QueryCache(0);
+    let computed = 0;
+    await cache.get('a', 1, async () => ++computed);
+    await cache.get('a', 1, async () => ++computed);
+    expect(computed).toBe(2);
+    expect(cache.stats().entries).toBe(0);
+  });
+});
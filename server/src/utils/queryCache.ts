@@ -0,0 +1,109 @@
+/*
+ * Query result cache for serving hot queries, such as workspace/symbol
+ * while someone types in the editor's symbol picker. Entries belong to
+ * the index generation they were computed from: when the index reports a
+ * new generation, every entry is dropped at once, so no result outlives
+ * the index snapshot it came from. Within a generation the least recently
+ * used entries make room for new ones.
+ */
+
+export interface QueryCacheLookupOptions<T> {
+  /** Learns whether the result came from the cache */
+  onLookup?: (hit: boolean) => void;
+  /** Whether to keep a computed result, e.g. not server errors (default: all) */
+  keep?: (value: T) => boolean;
+}
+
+export interface QueryCacheStats {
+  entries: number;
+  hits: number;
+  misses: number;
+}
+
+export class QueryCache {
+  private entries = new Map<string, unknown>();
+  /** Lookups running for the current generation, shared by identical queries */
+  private pending = new Map<string, Promise<unknown>>();
+  private generation: number | undefined;
+  private hits = 0;
+  private misses = 0;
+
+  /** maxEntries 0 disables the cache: every lookup computes its result */
+  constructor(private maxEntries: number = 500) {}
+
+  /**
+   * The result of the query `key` at `generation`, computed on a miss.
+   * Results are shared between callers and must not be modified.
+   */
+  async get<T>(key: string, generation: number, compute: () => Promise<T>, options: QueryCacheLookupOptions<T> = {}): Promise<T> {
+    const { onLookup, keep } = options;
+    if (this.maxEntries <= 0) {
+      return compute();
+    }
+    if (generation !== this.generation) {
+      this.clear();
+      this.generation = generation;
+    }
+
+    if (this.entries.has(key)) {
+      const value = this.entries.get(key) as T;
+      // Move to end for LRU (delete + re-add makes it most recently used)
+      this.entries.delete(key);
+      this.entries.set(key, value);
+      this.hits++;
+      onLookup?.(true);
+      return value;
+    }
+    this.misses++;
+    onLookup?.(false);
+
+    const running = this.pending.get(key);
+    if (running) {
+      return running as Promise<T>;
+    }
+    const promise = compute();
+    this.pending.set(key, promise);
+    try {
+      const value = await promise;
+      // A result computed while a new generation came out is not kept
+      if (this.generation === generation && (!keep || keep(value))) {
+        this.store(key, value);
+      }
+      return value;
+    } finally {
+      if (this.pending.get(key) === promise) {
+        this.pending.delete(key);
+      }
+    }
+  }
+
+  clear(): void {
+    this.entries.clear();
+    this.pending.clear();
+  }
+
+  /** Change the capacity, evicting the oldest entries if it shrank */
+  resize(maxEntries: number): void {
+    this.maxEntries = maxEntries;
+    if (maxEntries <= 0) {
+      this.clear();
+    }
+    while (this.entries.size > Math.max(maxEntries, 0)) {
+      this.entries.delete(this.entries.keys().next().value as string);
+    }
+  }
+
+  stats(): QueryCacheStats {
+    return { entries: this.entries.size, hits: this.hits, misses: this.misses };
+  }
+
+  private store(key: string, value: unknown): void {
+    if (this.entries.size >= this.maxEntries) {
+      const oldestKey = this.entries.keys().next().value;
+      if (oldestKey !== undefined) {
+        this.entries.delete(oldestKey);
+      }
+    }
+    this.entries.set(key, value);
+  }
+}
@@ -0,0 +1,65 @@
+/**
+ * Query Language Tests
+ *
+ * Verifies parsing of structured index queries.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { formatQuery, parseQuery, QuerySyntaxError } from './queryLanguage.js';
+
+describe('parseQuery', () => {
+  it('should parse field terms joined by AND', () => {
+    expect(parseQuery('kind:func receiver:Person exported:true file:pkg/user/**')).toEqual({
+      type: 'and',
+      children: [
+        { type: 'term', field: 'kind', values: ['func'], position: 0 },
+        { type: 'term', field: 'receiver', values: ['Person'], position: 10 },
+        { type: 'term', field: 'exported', values: ['true'], position: 26 },
+        { type: 'term', field: 'file', values: ['pkg/user/**'], position: 40 }
+      ]
+    });
+  });
+
+  it('should parse OR, negation, grouping, lists and quoted values', () => {
+    const node = parseQuery('(name:Greet OR Hello) -tag:test NOT doc:"do not use" kind:func,method');
+    expect(formatQuery(node)).toBe('(name:Greet OR Hello) -tag:test -doc:"do not use" kind:func,method');
+
+    // OR binds looser than AND
+    expect(formatQuery(parseQuery('a b OR c'))).toBe('a b OR c');
+    expect(parseQuery('a b OR c')).toMatchObject({ type: 'or', children: [{ type: 'and' }, { type: 'term' }] });
+  });
+
+  it('should keep parentheses and spaces inside field values', () => {
+    expect(parseQuery('(signature:func(*Person, ...) string)')).toEqual({
+      type: 'and',
+      children: [
+        { type: 'term', field: 'signature', values: ['func(*Person, ...)'], position: 1 },
+        { type: 'term', field: 'text', values: ['string'], position: 30 }
+      ]
+    });
+    expect(parseQuery('signature:"func(*Person) string"')).toMatchObject({ values: ['func(*Person) string'] });
+  });
+
+  it('should report syntax errors with their position', () => {
+    const errorOf = (query: string) => {
+      try {
+        parseQuery(query);
+      } catch (error) {
+        return error as QuerySyntaxError;
+      }
+      throw new Error(`Expected "${query}" to fail`);
+    };
+
+    expect(errorOf('kind:func colour:red')).toMatchObject({ position: 10 });
+    expect(errorOf('kind:func colour:red').message).toContain('Unknown field "colour"');
+    expect(errorOf('(kind:func').message).toBe('Unbalanced "(" at position 0');
+    expect(errorOf('kind:func)').message).toBe('Unbalanced ")" at position 9');
+    expect(errorOf('exported:yes').message).toContain('must be true or false');
+    expect(errorOf('refs:many').message).toContain('"refs" must be a number');
+    expect(errorOf('coverage:=>5')).toMatchObject({ position: 0 });
+    expect(errorOf('kind: func').message).toContain('Missing value for "kind"');
+    expect(errorOf('doc:"open').message).toContain('Unterminated quote');
+    expect(errorOf('a OR').message).toContain('Expected a term');
+    expect(errorOf('  ').message).toBe('Empty query at position 0');
+  });
+});
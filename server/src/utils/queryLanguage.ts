@@ -0,0 +1,259 @@
+/*
+ * Query language for structured index queries:
+ *
+ *   kind:func receiver:Person exported:true file:pkg/user/**
+ *
+ * Terms separated by whitespace must all match; `OR` (upper case) joins
+ * alternatives, `-term` or `NOT term` negates, parentheses group. A term is
+ * `field:value` or a bare word, which matches a name substring. Values can
+ * be quoted (`signature:"func(*Person) string"`); list fields (kind, lang,
+ * tag, owner, target) take comma-separated alternatives: `kind:func,method`.
+ * Numeric fields (coverage, refs) take a number with an optional
+ * comparison: `coverage:0 refs:>10`, `coverage:<50`. `key` matches the
+ * path of a YAML or JSON config key: `key:containers[].image`. `target`
+ * takes Bazel target patterns (`target://pkg/api:api,//cmd/...`); `deps`
+ * a target whose dependencies, with itself, match: `deps://cmd/server`.
+ */
+
+/** Fields a term can filter on; `text` is the field of bare words */
+export const QUERY_FIELDS = [
+  'name', 'kind', 'receiver', 'container', 'exported', 'file', 'lang', 'tag',
+  'signature', 'constraint', 'generic', 'value', 'doc', 'owner', 'deprecated', 'coverage', 'refs', 'text',
+  'key', 'target', 'deps'
+] as const;
+
+export type QueryField = typeof QUERY_FIELDS[number];
+
+export type QueryNode =
+  | { type: 'and'; children: QueryNode[] }
+  | { type: 'or'; children: QueryNode[] }
+  | { type: 'not'; child: QueryNode }
+  | QueryTerm;
+
+export interface QueryTerm {
+  type: 'term';
+  field: QueryField;
+  /** One value, or the alternatives of a list field */
+  values: string[];
+  /** Offset of the term in the query text */
+  position: number;
+}
+
+/** Fields whose value is a comma-separated list of alternatives */
+const LIST_FIELDS = new Set<QueryField>(['kind', 'lang', 'tag', 'owner', 'target']);
+
+/** Fields whose value must be true or false */
+const BOOLEAN_FIELDS = new Set<QueryField>(['exported', 'generic', 'deprecated']);
+
+/** Fields whose value is a number, optionally after <, <=, > or >= */
+const NUMERIC_FIELDS = new Set<QueryField>(['coverage', 'refs']);
+
+/**
+ * A query that does not parse; `position` is the offset of the problem.
+ */
+export class QuerySyntaxError extends Error {
+  constructor(message: string, readonly position: number) {
+    super(`${message} at position ${position}`);
+    this.name = 'QuerySyntaxError';
+  }
+}
+
+type Token =
+  | { type: 'open' | 'close' | 'or' | 'not'; position: number }
+  | { type: 'word'; text: string; field?: string; position: number };
+
+/**
+ * Parse a query into an AST. Throws QuerySyntaxError on unknown fields,
+ * unbalanced parentheses, missing values and empty queries.
+ */
+export function parseQuery(query: string): QueryNode {
+  const tokens = tokenize(query);
+  if (tokens.length === 0) {
+    throw new QuerySyntaxError('Empty query', 0);
+  }
+  const parser = new Parser(tokens, query.length);
+  const node = parser.parseOr();
+  parser.expectEnd();
+  return node;
+}
+
+/**
+ * Render an AST back as query text, with explicit parentheses.
+ */
+export function formatQuery(node: QueryNode): string {
+  switch (node.type) {
+    case 'and':
+      return node.children.map(child => child.type === 'or' ? `(${formatQuery(child)})` : formatQuery(child)).join(' ');
+    case 'or':
+      return node.children.map(formatQuery).join(' OR ');
+    case 'not':
+      return node.child.type === 'term' ? `-${formatQuery(node.child)}` : `-(${formatQuery(node.child)})`;
+    case 'term': {
+      const value = node.values.map(quoteValue).join(',');
+      return node.field === 'text' ? value : `${node.field}:${value}`;
+    }
+  }
+}
+
+function quoteValue(value: string): string {
+  return /^[^\s"(),]+$/.test(value) ? value : `"${value.replace(/["\\]/g, '\\$&')}"`;
+}
+
+class Parser {
+  private index = 0;
+
+  constructor(private tokens: Token[], private end: number) {}
+
+  parseOr(): QueryNode {
+    const children = [this.parseAnd()];
+    while (this.peek()?.type === 'or') {
+      this.index++;
+      children.push(this.parseAnd());
+    }
+    return children.length === 1 ? children[0] : { type: 'or', children };
+  }
+
+  expectEnd(): void {
+    const token = this.peek();
+    if (token) {
+      throw new QuerySyntaxError(token.type === 'close' ? 'Unbalanced ")"' : 'Unexpected token', token.position);
+    }
+  }
+
+  private parseAnd(): QueryNode {
+    const children: QueryNode[] = [];
+    for (let token = this.peek(); token && token.type !== 'or' && token.type !== 'close'; token = this.peek()) {
+      children.push(this.parseUnary());
+    }
+    if (children.length === 0) {
+      throw new QuerySyntaxError('Expected a term', this.peek()?.position ?? this.end);
+    }
+    return children.length === 1 ? children[0] : { type: 'and', children };
+  }
+
+  private parseUnary(): QueryNode {
+    const token = this.tokens[this.index++];
+    switch (token.type) {
+      case 'not':
+        if (!this.peek() || this.peek()!.type === 'or' || this.peek()!.type === 'close') {
+          throw new QuerySyntaxError('Expected a term after NOT', this.peek()?.position ?? this.end);
+        }
+        return { type: 'not', child: this.parseUnary() };
+      case 'open': {
+        const node = this.parseOr();
+        if (this.peek()?.type !== 'close') {
+          throw new QuerySyntaxError('Unbalanced "("', token.position);
+        }
+        this.index++;
+        return node;
+      }
+      case 'word':
+        return toTerm(token);
+      default:
+        throw new QuerySyntaxError('Unexpected token', token.position);
+    }
+  }
+
+  private peek(): Token | undefined {
+    return this.tokens[this.index];
+  }
+}
+
+function toTerm(token: Extract<Token, { type: 'word' }>): QueryTerm {
+  if (token.field === undefined) {
+    return { type: 'term', field: 'text', values: [token.text], position: token.position };
+  }
+  const field = token.field.toLowerCase() as QueryField;
+  if (!QUERY_FIELDS.includes(field)) {
+    throw new QuerySyntaxError(`Unknown field "${token.field}" (expected ${QUERY_FIELDS.join(', ')})`, token.position);
+  }
+  const values = LIST_FIELDS.has(field)
+    ? token.text.split(',').map(value => value.trim()).filter(Boolean)
+    : [token.text];
+  if (values.length === 0 || values[0] === '') {
+    throw new QuerySyntaxError(`Missing value for "${field}"`, token.position);
+  }
+  if (BOOLEAN_FIELDS.has(field) && token.text !== 'true' && token.text !== 'false') {
+    throw new QuerySyntaxError(`"${field}" must be true or false`, token.position);
+  }
+  if (NUMERIC_FIELDS.has(field) && !/^(?:[<>]=?)?\d+(?:\.\d+)?$/.test(token.text)) {
+    throw new QuerySyntaxError(`"${field}" must be a number, e.g. 10 or >=10`, token.position);
+  }
+  return { type: 'term', field, values, position: token.position };
+}
+
+/**
+ * Split a query into parentheses, operators and words. A field value may
+ * contain balanced parentheses, spaces included (`signature:func(a, b)`),
+ * and quoted parts.
+ */
+function tokenize(query: string): Token[] {
+  const tokens: Token[] = [];
+  let i = 0;
+  while (i < query.length) {
+    const char = query[i];
+    if (/\s/.test(char)) {
+      i++;
+    } else if (char === '(' || char === ')') {
+      tokens.push({ type: char === '(' ? 'open' : 'close', position: i++ });
+    } else if (char === '-' && i + 1 < query.length && !/[\s)]/.test(query[i + 1])) {
+      tokens.push({ type: 'not', position: i++ });
+    } else {
+      const start = i;
+      const field = /^([A-Za-z]+):/.exec(query.slice(i))?.[1];
+      if (field !== undefined) {
+        i += field.length + 1;
+      }
+      const { text, end } = readValue(query, i, field !== undefined);
+      i = end;
+      if (field === undefined && text === 'OR') {
+        tokens.push({ type: 'or', position: start });
+      } else if (field === undefined && text === 'NOT') {
+        tokens.push({ type: 'not', position: start });
+      } else {
+        tokens.push({ type: 'word', text, field, position: start });
+      }
+    }
+  }
+  return tokens;
+}
+
+function readValue(query: string, start: number, allowParens: boolean): { text: string; end: number } {
+  let text = '';
+  let depth = 0;
+  let i = start;
+  while (i < query.length) {
+    const char = query[i];
+    if (char === '"') {
+      const close = findClosingQuote(query, i + 1);
+      if (close === -1) {
+        throw new QuerySyntaxError('Unterminated quote', i);
+      }
+      text += query.slice(i + 1, close).replace(/\\(["\\])/g, '$1');
+      i = close + 1;
+      continue;
+    }
+    if (depth === 0 && (/\s/.test(char) || char === ')' || (char === '(' && !allowParens))) {
+      break;
+    }
+    if (char === '(') {
+      depth++;
+    } else if (char === ')') {
+      depth--;
+    }
+    text += char;
+    i++;
+  }
+  return { text, end: i };
+}
+
+function findClosingQuote(query: string, from: number): number {
+  for (let i = from; i < query.length; i++) {
+    if (query[i] === '\\') {
+      i++;
+    } else if (query[i] === '"') {
+      return i;
+    }
+  }
+  return -1;
+}
@@ -0,0 +1,66 @@
+/**
+ * regexTrigrams Tests
+ *
+ * Checks the trigram queries derived from literals, classes, alternation
+ * and repeats, and that unanalysable patterns ask for nothing.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { TrigramQuery, queryTrigrams, regexToTrigramQuery } from './regexTrigrams.js';
+
+/** Compact form: trigrams joined by & and |, parenthesised per level */
+function show(query: TrigramQuery): string {
+  if (query.op === 'all') {
+    return '*';
+  }
+  if (query.op === 'trigram') {
+    return query.trigram;
+  }
+  return `(${query.parts.map(show).join(query.op === 'and' ? ' & ' : ' | ')})`;
+}
+
+/** Whether a file with this (lowercased) text passes the query */
+function admits(query: TrigramQuery, text: string): boolean {
+  if (query.op === 'all') {
+    return true;
+  }
+  if (query.op === 'trigram') {
+    return text.includes(query.trigram);
+  }
+  return query.op === 'and' ? query.parts.every(part => admits(part, text)) : query.parts.some(part => admits(part, text));
+}
+
+describe('regexToTrigramQuery', () => {
+  it('should require the trigrams of literals on both sides of a wildcard', () => {
+    expect(show(regexToTrigramQuery('New\\w+Person'))).toBe('(new & per & ers & rso & son)');
+    expect(show(regexToTrigramQuery('New.*Person'))).toBe('(new & per & ers & rso & son)');
+  });
+
+  it('should join alternatives, small classes and optional parts', () => {
+    expect(show(regexToTrigramQuery('(get|set)Name'))).toBe('((get & etn & tna & nam & ame) | (set & etn & tna & nam & ame))');
+    expect(queryTrigrams(regexToTrigramQuery('[Cc]olou?r'))).toEqual(['col', 'olo', 'lou', 'our', 'lor']);
+    expect(show(regexToTrigramQuery('colou?r'))).toBe('((col & olo & lou & our) | (col & olo & lor))');
+  });
+
+  it('should lowercase trigrams and keep zero-width assertions out of the text', () => {
+    expect(show(regexToTrigramQuery('^func\\s+Handle'))).toBe('(fun & unc & han & and & ndl & dle)');
+    expect(show(regexToTrigramQuery('\\bERR_(?=x)CODE\\b'))).toBe('(err & rr_ & r_c & _co & cod & ode)');
+  });
+
+  it('should ask for nothing when the pattern has no three known characters in a row', () => {
+    for (const pattern of ['\\w+', 'a.b', 'ab|cde', '(abc)*', '\\1', '[^abc]+x']) {
+      expect(regexToTrigramQuery(pattern)).toEqual({ op: 'all' });
+    }
+    expect(show(regexToTrigramQuery('x{3}'))).toBe('xxx');
+    expect(show(regexToTrigramQuery('(foo)+bar'))).toBe('(foo & bar)');
+  });
+
+  it('should not rule out matches of counted repeats longer than three copies', () => {
+    for (const [pattern, text] of [['z[ab]{5}c', 'x := zaabbbc'], ['a{5}b', 'aaaaab'], ['(ab){4}', 'abababab']]) {
+      expect(new RegExp(pattern).test(text)).toBe(true);
+      expect(admits(regexToTrigramQuery(pattern), text)).toBe(true);
+    }
+    // The first copies and the literal before them are still required
+    expect(queryTrigrams(regexToTrigramQuery('Load[ab]{5}c'))).toContain('loa');
+  });
+});
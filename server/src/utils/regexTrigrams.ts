@@ -0,0 +1,508 @@
+/**
+ * Regex to trigram query, after Russ Cox's "Regular Expression Matching
+ * with a Trigram Index" (Google Code Search).
+ *
+ * The pattern (JavaScript syntax) is parsed and, bottom up, each part is
+ * summarised by the strings it can match exactly (while there are few),
+ * or else by prefixes and suffixes every match starts and ends with, plus
+ * a boolean query of trigrams every match contains. Concatenation joins
+ * the suffixes of the left part with the prefixes of the right part into
+ * more trigrams, so `New\w+Person` needs "new" and "per", "ers", "rso",
+ * "son" while `(get|set)Name` needs either "get" or "set" and "nam", "ame".
+ *
+ * Anything the analysis does not follow (classes, backreferences, \p{...},
+ * lookarounds, unbounded repeats) is treated as "any text", which only
+ * weakens the query: a file ruled out by the query cannot match. Trigrams
+ * are lowercased, matching an index built over lowercased text.
+ */
+
+export type TrigramQuery =
+  | { op: 'all' }
+  | { op: 'trigram'; trigram: string }
+  | { op: 'and' | 'or'; parts: TrigramQuery[] };
+
+export const ALL: TrigramQuery = { op: 'all' };
+
+/** Exact sets and prefix/suffix sets are cut back beyond this many strings */
+const MAX_SET = 16;
+/** Small classes like [abc] are kept as exact alternatives */
+const MAX_CLASS = 8;
+
+interface Info {
+  /** Empty string may match */
+  emptyable: boolean;
+  /** Every string the part matches, when few; null when unknown */
+  exact: Set<string> | null;
+  /** What every match starts with (unused while exact is known) */
+  prefix: Set<string>;
+  /** What every match ends with (unused while exact is known) */
+  suffix: Set<string>;
+  /** Trigrams every match contains */
+  match: TrigramQuery;
+}
+
+type Node =
+  | { type: 'empty' }
+  | { type: 'any' }
+  | { type: 'chars'; chars: string[] }
+  | { type: 'concat'; parts: Node[] }
+  | { type: 'alt'; parts: Node[] }
+  | { type: 'repeat'; node: Node; min: number; max: number };
+
+/**
+ * Lowercase one UTF-16 code unit; characters whose lowercase form is not a
+ * single code unit are kept, so offsets in lowercased text stay aligned.
+ */
+export function lowerCodeUnit(code: number): number {
+  if (code >= 65 && code <= 90) {
+    return code + 32;
+  }
+  if (code < 128) {
+    return code;
+  }
+  const lower = String.fromCharCode(code).toLowerCase();
+  return lower.length === 1 ? lower.charCodeAt(0) : code;
+}
+
+/**
+ * The trigram query every match of the pattern satisfies. Throws on
+ * patterns the parser cannot read; callers validate with `new RegExp`
+ * first.
+ */
+export function regexToTrigramQuery(pattern: string): TrigramQuery {
+  const info = analyze(new RegexParser(pattern).parse());
+  return simplify(inexact(info).match);
+}
+
+/**
+ * Trigrams of a query, for diagnostics.
+ */
+export function queryTrigrams(query: TrigramQuery): string[] {
+  if (query.op === 'all') {
+    return [];
+  }
+  if (query.op === 'trigram') {
+    return [query.trigram];
+  }
+  return [...new Set(query.parts.flatMap(queryTrigrams))];
+}
+
+function analyze(node: Node): Info {
+  switch (node.type) {
+    case 'empty':
+      return exactInfo(new Set(['']));
+    case 'any':
+      return anyInfo(false);
+    case 'chars':
+      return exactInfo(new Set(node.chars));
+    case 'concat':
+      return node.parts.map(analyze).reduce(concat, exactInfo(new Set([''])));
+    case 'alt':
+      return node.parts.map(analyze).reduce(alternate);
+    case 'repeat': {
+      const inner = analyze(node.node);
+      if (node.min === 0) {
+        return node.max === 1 ? alternate(inner, exactInfo(new Set(['']))) : anyInfo(true);
+      }
+      // x{2,} is x x x*: the required copies, then anything. Copies past
+      // the third are "any text" too, so x{5} is not exactly x x x
+      let info = inner;
+      for (let i = 1; i < Math.min(node.min, 3); i++) {
+        info = concat(info, inner);
+      }
+      if (node.min > 3) {
+        info = concat(info, anyInfo(inner.emptyable));
+      }
+      return node.max > node.min ? concat(info, anyInfo(true)) : info;
+    }
+  }
+}
+
+function exactInfo(exact: Set<string>): Info {
+  return { emptyable: exact.has(''), exact, prefix: new Set(['']), suffix: new Set(['']), match: ALL };
+}
+
+function anyInfo(emptyable: boolean): Info {
+  return { emptyable, exact: null, prefix: new Set(['']), suffix: new Set(['']), match: ALL };
+}
+
+/**
+ * Turn known exact strings into prefixes, suffixes and their trigrams.
+ */
+function inexact(info: Info): Info {
+  if (!info.exact) {
+    return info;
+  }
+  return trimmed({
+    emptyable: info.emptyable,
+    exact: null,
+    prefix: info.exact,
+    suffix: info.exact,
+    match: and(info.match, anyOfStrings(info.exact))
+  });
+}
+
+/**
+ * Move the trigrams of prefixes and suffixes into the query and keep only
+ * the two characters at their outer end, which is all a concatenation can
+ * still join onto.
+ */
+function trimmed(info: Info): Info {
+  return {
+    ...info,
+    prefix: cutPrefixes(new Set([...info.prefix].map(value => value.slice(0, 2)))),
+    suffix: cutSuffixes(new Set([...info.suffix].map(value => value.slice(-2)))),
+    match: and(info.match, and(anyOfStrings(info.prefix), anyOfStrings(info.suffix)))
+  };
+}
+
+function concat(x: Info, y: Info): Info {
+  if (x.exact && y.exact) {
+    const exact = cross(x.exact, y.exact);
+    if (exact.size <= MAX_SET) {
+      return { ...exactInfo(exact), match: and(x.match, y.match) };
+    }
+  }
+  const a = inexact(x);
+  const b = inexact(y);
+  const prefix = x.exact
+    ? cross(x.exact, b.prefix)
+    : x.emptyable ? union(a.prefix, b.prefix) : a.prefix;
+  const suffix = y.exact
+    ? cross(a.suffix, y.exact)
+    : y.emptyable ? union(b.suffix, a.suffix) : b.suffix;
+  return trimmed({
+    emptyable: x.emptyable && y.emptyable,
+    exact: null,
+    prefix,
+    suffix,
+    // Text spanning the boundary: a suffix of x followed by a prefix of y
+    match: and(and(a.match, b.match), anyOfStrings(cross(a.suffix, b.prefix)))
+  });
+}
+
+function alternate(x: Info, y: Info): Info {
+  if (x.exact && y.exact) {
+    const exact = union(x.exact, y.exact);
+    if (exact.size <= MAX_SET) {
+      return { ...exactInfo(exact), match: or(x.match, y.match) };
+    }
+  }
+  const a = inexact(x);
+  const b = inexact(y);
+  return trimmed({
+    emptyable: a.emptyable || b.emptyable,
+    exact: null,
+    prefix: union(a.prefix, b.prefix),
+    suffix: union(a.suffix, b.suffix),
+    match: or(a.match, b.match)
+  });
+}
+
+/**
+ * Any of the strings: per string, all of its trigrams. A string shorter
+ * than a trigram says nothing, and then neither does the whole set.
+ */
+function anyOfStrings(strings: Set<string>): TrigramQuery {
+  const alternatives: TrigramQuery[] = [];
+  for (const value of strings) {
+    if (value.length < 3) {
+      return ALL;
+    }
+    const trigrams: TrigramQuery[] = [];
+    for (let i = 0; i + 3 <= value.length; i++) {
+      trigrams.push({ op: 'trigram', trigram: value.slice(i, i + 3) });
+    }
+    alternatives.push(trigrams.reduce(and));
+  }
+  return alternatives.length === 0 ? ALL : alternatives.reduce(or);
+}
+
+function and(x: TrigramQuery, y: TrigramQuery): TrigramQuery {
+  if (x.op === 'all') {
+    return y;
+  }
+  if (y.op === 'all') {
+    return x;
+  }
+  return { op: 'and', parts: [...(x.op === 'and' ? x.parts : [x]), ...(y.op === 'and' ? y.parts : [y])] };
+}
+
+function or(x: TrigramQuery, y: TrigramQuery): TrigramQuery {
+  if (x.op === 'all' || y.op === 'all') {
+    return ALL;
+  }
+  return { op: 'or', parts: [...(x.op === 'or' ? x.parts : [x]), ...(y.op === 'or' ? y.parts : [y])] };
+}
+
+/**
+ * Drop repeated parts, so `aaaa` asks for "aaa" once.
+ */
+function simplify(query: TrigramQuery): TrigramQuery {
+  if (query.op === 'all' || query.op === 'trigram') {
+    return query;
+  }
+  const seen = new Set<string>();
+  const parts: TrigramQuery[] = [];
+  for (const part of query.parts.map(simplify)) {
+    const key = JSON.stringify(part);
+    if (!seen.has(key)) {
+      seen.add(key);
+      parts.push(part);
+    }
+  }
+  return parts.length === 1 ? parts[0] : { op: query.op, parts };
+}
+
+function cross(left: Set<string>, right: Set<string>): Set<string> {
+  const result = new Set<string>();
+  for (const a of left) {
+    for (const b of right) {
+      result.add(a + b);
+    }
+  }
+  return result;
+}
+
+function union(left: Set<string>, right: Set<string>): Set<string> {
+  return new Set([...left, ...right]);
+}
+
+/**
+ * Shorten prefixes until few are left; a prefix of a prefix is still one.
+ */
+function cutPrefixes(strings: Set<string>): Set<string> {
+  let result = strings;
+  for (let length = longest(strings) - 1; result.size > MAX_SET && length >= 0; length--) {
+    result = new Set([...strings].map(value => value.slice(0, length)));
+  }
+  return result;
+}
+
+function cutSuffixes(strings: Set<string>): Set<string> {
+  let result = strings;
+  for (let length = longest(strings) - 1; result.size > MAX_SET && length >= 0; length--) {
+    result = new Set([...strings].map(value => value.slice(Math.max(0, value.length - length))));
+  }
+  return result;
+}
+
+function longest(strings: Set<string>): number {
+  return Math.max(0, ...[...strings].map(value => value.length));
+}
+
+/**
+ * Parser for JavaScript regex syntax, down to what the analysis needs.
+ */
+class RegexParser {
+  private position = 0;
+
+  constructor(private readonly pattern: string) {}
+
+  parse(): Node {
+    const node = this.alternation();
+    if (this.position < this.pattern.length) {
+      throw new Error(`Unexpected '${this.pattern[this.position]}' at ${this.position}`);
+    }
+    return node;
+  }
+
+  private alternation(): Node {
+    const parts = [this.sequence()];
+    while (this.peek() === '|') {
+      this.position++;
+      parts.push(this.sequence());
+    }
+    return parts.length === 1 ? parts[0] : { type: 'alt', parts };
+  }
+
+  private sequence(): Node {
+    const parts: Node[] = [];
+    while (this.position < this.pattern.length && this.peek() !== '|' && this.peek() !== ')') {
+      parts.push(this.quantified(this.atom()));
+    }
+    return parts.length === 1 ? parts[0] : { type: 'concat', parts };
+  }
+
+  private quantified(node: Node): Node {
+    for (;;) {
+      const ch = this.peek();
+      let min: number;
+      let max: number;
+      if (ch === '*' || ch === '+' || ch === '?') {
+        this.position++;
+        [min, max] = ch === '*' ? [0, Infinity] : ch === '+' ? [1, Infinity] : [0, 1];
+      } else if (ch === '{') {
+        const bounds = /^\{(\d+)(,(\d*))?\}/.exec(this.pattern.slice(this.position));
+        if (!bounds) {
+          return node;
+        }
+        this.position += bounds[0].length;
+        min = Number(bounds[1]);
+        max = bounds[2] === undefined ? min : bounds[3] === '' ? Infinity : Number(bounds[3]);
+      } else {
+        return node;
+      }
+      if (this.peek() === '?') {
+        this.position++; // Lazy: same strings
+      }
+      node = { type: 'repeat', node, min, max };
+    }
+  }
+
+  private atom(): Node {
+    const ch = this.pattern[this.position++];
+    switch (ch) {
+      case '(':
+        return this.group();
+      case '[':
+        return this.charClass();
+      case '.':
+        return { type: 'any' };
+      case '^':
+      case '$':
+        return { type: 'empty' };
+      case '\\':
+        return this.escape();
+      default:
+        return literal(ch);
+    }
+  }
+
+  private group(): Node {
+    let lookaround = false;
+    if (this.pattern.startsWith('?:', this.position)) {
+      this.position += 2;
+    } else if (/^\?<?[=!]/.test(this.pattern.slice(this.position, this.position + 3))) {
+      this.position += this.pattern[this.position + 1] === '<' ? 3 : 2;
+      lookaround = true;
+    } else if (this.pattern.startsWith('?<', this.position)) {
+      const end = this.pattern.indexOf('>', this.position);
+      if (end === -1) {
+        throw new Error('Unterminated group name');
+      }
+      this.position = end + 1;
+    }
+    const inner = this.alternation();
+    if (this.pattern[this.position++] !== ')') {
+      throw new Error('Missing )');
+    }
+    // Lookarounds match no text of their own
+    return lookaround ? { type: 'empty' } : inner;
+  }
+
+  private charClass(): Node {
+    const negated = this.peek() === '^';
+    if (negated) {
+      this.position++;
+    }
+    const chars = new Set<string>();
+    let any = negated;
+    let first = true;
+    while (this.position < this.pattern.length && (this.peek() !== ']' || first)) {
+      first = false;
+      const start = this.classChar();
+      if (start === null) {
+        any = true;
+        continue;
+      }
+      if (this.peek() === '-' && this.pattern[this.position + 1] !== ']' && this.position + 1 < this.pattern.length) {
+        this.position++;
+        const end = this.classChar();
+        if (end === null || end.charCodeAt(0) - start.charCodeAt(0) > MAX_CLASS) {
+          any = true;
+          continue;
+        }
+        for (let code = start.charCodeAt(0); code <= end.charCodeAt(0); code++) {
+          chars.add(String.fromCharCode(lowerCodeUnit(code)));
+        }
+      } else {
+        chars.add(start);
+      }
+    }
+    if (this.pattern[this.position++] !== ']') {
+      throw new Error('Missing ]');
+    }
+    return any || chars.size === 0 || chars.size > MAX_CLASS ? { type: 'any' } : { type: 'chars', chars: [...chars] };
+  }
+
+  /** One class member, lowercased; null for escapes like \w that stand for many */
+  private classChar(): string | null {
+    const ch = this.pattern[this.position++];
+    if (ch !== '\\') {
+      return String.fromCharCode(lowerCodeUnit(ch.charCodeAt(0)));
+    }
+    const node = this.escape();
+    return node.type === 'chars' && node.chars.length === 1 ? node.chars[0] : null;
+  }
+
+  private escape(): Node {
+    const ch = this.pattern[this.position++];
+    if (ch === undefined) {
+      throw new Error('Trailing \\');
+    }
+    const rest = this.pattern.slice(this.position);
+    switch (ch) {
+      case 'b':
+      case 'B':
+        return { type: 'empty' };
+      case 'd': case 'D': case 'w': case 'W': case 's': case 'S':
+        return { type: 'any' };
+      case 'n':
+        return literal('\n');
+      case 't':
+        return literal('\t');
+      case 'r':
+        return literal('\r');
+      case 'f':
+        return literal('\f');
+      case 'v':
+        return literal('\v');
+      case 'x': {
+        const hex = /^[0-9a-fA-F]{2}/.exec(rest);
+        if (!hex) {
+          return literal('x');
+        }
+        this.position += 2;
+        return literal(String.fromCharCode(parseInt(hex[0], 16)));
+      }
+      case 'u': {
+        const hex = /^[0-9a-fA-F]{4}/.exec(rest);
+        if (!hex) {
+          return { type: 'any' };
+        }
+        this.position += 4;
+        return literal(String.fromCharCode(parseInt(hex[0], 16)));
+      }
+      case 'c':
+      case 'p':
+      case 'P':
+      case 'k':
+        // Control characters, Unicode properties, named backreferences
+        if (rest[0] === '{' || rest[0] === '<') {
+          const end = this.pattern.indexOf(rest[0] === '{' ? '}' : '>', this.position);
+          this.position = end === -1 ? this.position : end + 1;
+        } else if (ch === 'c') {
+          this.position++;
+        }
+        return { type: 'any' };
+      default:
+        if (ch >= '0' && ch <= '9') {
+          // Backreferences and octal escapes
+          while (/[0-9]/.test(this.peek() ?? '')) {
+            this.position++;
+          }
+          return { type: 'any' };
+        }
+        return literal(ch);
+    }
+  }
+
+  private peek(): string | undefined {
+    return this.pattern[this.position];
+  }
+}
+
+function literal(ch: string): Node {
+  return { type: 'chars', chars: [String.fromCharCode(lowerCodeUnit(ch.charCodeAt(0)))] };
+}
@@ -0,0 +1,274 @@
+import * as crypto from 'crypto';
+
+/**
+ * Remote Storage - minimal object storage client for index artifacts.
+ *
+ * Objects are addressed by URL:
+ * - `s3://bucket/key`: Amazon S3 (or an S3-compatible store through
+ *   AWS_ENDPOINT_URL_S3 / AWS_ENDPOINT_URL, addressed path-style), with
+ *   requests signed (Signature Version 4) from AWS_ACCESS_KEY_ID,
+ *   AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION
+ *   (default: us-east-1). Without credentials, requests are anonymous.
+ * - `gs://bucket/key`: Google Cloud Storage, authorized with the OAuth
+ *   token in GOOGLE_OAUTH_ACCESS_TOKEN (e.g. from
+ *   `gcloud auth print-access-token`); anonymous without one.
+ * - `http://` / `https://`: GET and PUT on the URL itself, with
+ *   `Authorization: Bearer $SMART_INDEXER_REMOTE_TOKEN` when set. The
+ *   token is only sent over HTTPS or to a loopback address; other plain
+ *   http URLs are refused while it is set.
+ *
+ * No SDK is needed: requests go through fetch, which tests replace.
+ */
+
+/** The part of fetch used, so tests can pass a fake */
+export type RemoteFetch = (url: string, init: {
+  method: 'GET' | 'PUT';
+  headers: Record<string, string>;
+  body?: Uint8Array;
+  signal?: AbortSignal;
+}) => Promise<{ ok: boolean; status: number; statusText: string; arrayBuffer(): Promise<ArrayBuffer> }>;
+
+export interface RemoteStorageOptions {
+  /** Default: the global fetch */
+  fetch?: RemoteFetch;
+  /** Credentials and endpoints are read from here (default: process.env) */
+  env?: Record<string, string | undefined>;
+  /** Extra request headers, e.g. for an HTTP server behind custom auth */
+  headers?: Record<string, string>;
+  /** Aborts requests that have not completed in time */
+  signal?: AbortSignal;
+  /** Clock for request signing (default: now) */
+  now?: () => Date;
+}
+
+export type RemoteScheme = 's3' | 'gs' | 'http';
+
+export interface RemoteLocation {
+  scheme: RemoteScheme;
+  /** Bucket, for s3 and gs */
+  bucket?: string;
+  /** Object key, for s3 and gs */
+  key?: string;
+  /** The URL as given */
+  url: string;
+}
+
+export class RemoteStorageError extends Error {
+  constructor(message: string, readonly status?: number) {
+    super(message);
+    this.name = 'RemoteStorageError';
+  }
+}
+
+export const REMOTE_TOKEN_ENV_VAR = 'SMART_INDEXER_REMOTE_TOKEN';
+
+const EMPTY_SHA256 = crypto.createHash('sha256').digest('hex');
+const DEFAULT_S3_REGION = 'us-east-1';
+const GCS_ENDPOINT = 'https://storage.googleapis.com';
+
+/**
+ * Parse an `s3://`, `gs://` or `http(s)://` URL; throws RemoteStorageError
+ * on anything else.
+ */
+export function parseRemoteUrl(url: string): RemoteLocation {
+  const match = /^(s3|gs):\/\/([^/]+)\/(.+)$/.exec(url);
+  if (match) {
+    return { scheme: match[1] as RemoteScheme, bucket: match[2], key: match[3], url };
+  }
+  if (/^https?:\/\/[^/]+/.test(url)) {
+    return { scheme: 'http', url };
+  }
+  throw new RemoteStorageError(`Unsupported remote URL: ${url} (expected s3://bucket/key, gs://bucket/key or http(s)://...)`);
+}
+
+/**
+ * Whether a URL may carry credentials: HTTPS, or plain http to a loopback
+ * address (e.g. a local cache or model server).
+ */
+export function isSecureUrl(url: string): boolean {
+  let parsed: URL;
+  try {
+    parsed = new URL(url);
+  } catch {
+    return false;
+  }
+  if (parsed.protocol === 'https:') {
+    return true;
+  }
+  const host = parsed.hostname.replace(/^\[|\]$/g, '');
+  return parsed.protocol === 'http:' && (host === 'localhost' || host === '::1' || /^127(\.\d{1,3}){3}$/.test(host));
+}
+
+export class RemoteStorage {
+  private env: Record<string, string | undefined>;
+
+  constructor(private options: RemoteStorageOptions = {}) {
+    this.env = options.env ?? process.env;
+  }
+
+  /**
+   * Object content, or null when it does not exist.
+   */
+  async get(url: string): Promise<Uint8Array | null> {
+    const response = await this.send('GET', parseRemoteUrl(url));
+    if (response.status === 404) {
+      return null;
+    }
+    if (!response.ok) {
+      throw new RemoteStorageError(`GET ${url} failed: ${response.status} ${response.statusText}`, response.status);
+    }
+    return new Uint8Array(await response.arrayBuffer());
+  }
+
+  async put(url: string, body: Uint8Array, contentType: string): Promise<void> {
+    const response = await this.send('PUT', parseRemoteUrl(url), body, contentType);
+    if (!response.ok) {
+      throw new RemoteStorageError(`PUT ${url} failed: ${response.status} ${response.statusText}`, response.status);
+    }
+  }
+
+  private async send(method: 'GET' | 'PUT', location: RemoteLocation, body?: Uint8Array, contentType?: string) {
+    const headers: Record<string, string> = { ...this.options.headers };
+    if (contentType) {
+      headers['content-type'] = contentType;
+    }
+
+    let url: string;
+    if (location.scheme === 's3') {
+      url = this.s3Url(location);
+      Object.assign(headers, this.signS3(method, url, headers, body));
+    } else if (location.scheme === 'gs') {
+      url = `${GCS_ENDPOINT}/${location.bucket}/${encodeKey(location.key!)}`;
+      const token = this.env.GOOGLE_OAUTH_ACCESS_TOKEN;
+      if (token) {
+        headers.authorization = `Bearer ${token}`;
+      }
+    } else {
+      url = location.url;
+      const token = this.env[REMOTE_TOKEN_ENV_VAR];
+      if (token && !headers.authorization) {
+        if (!isSecureUrl(url)) {
+          throw new RemoteStorageError(`Refusing to send ${REMOTE_TOKEN_ENV_VAR} over plain http to ${location.url}; use https`);
+        }
+        headers.authorization = `Bearer ${token}`;
+      }
+    }
+
+    const fetchFn = this.options.fetch ?? (globalThis.fetch as unknown as RemoteFetch);
+    try {
+      return await fetchFn(url, { method, headers, body, signal: this.options.signal });
+    } catch (error) {
+      if (this.options.signal?.aborted) {
+        throw error;
+      }
+      throw new RemoteStorageError(`${method} ${location.url} failed: ${error instanceof Error ? error.message : error}`);
+    }
+  }
+
+  private s3Url(location: RemoteLocation): string {
+    const endpoint = this.env.AWS_ENDPOINT_URL_S3 || this.env.AWS_ENDPOINT_URL;
+    if (endpoint) {
+      return `${endpoint.replace(/\/+$/, '')}/${location.bucket}/${encodeKey(location.key!)}`;
+    }
+    const region = this.env.AWS_REGION || this.env.AWS_DEFAULT_REGION || DEFAULT_S3_REGION;
+    return `https://${location.bucket}.s3.${region}.amazonaws.com/${encodeKey(location.key!)}`;
+  }
+
+  private signS3(method: string, url: string, headers: Record<string, string>, body?: Uint8Array): Record<string, string> {
+    const accessKeyId = this.env.AWS_ACCESS_KEY_ID;
+    const secretAccessKey = this.env.AWS_SECRET_ACCESS_KEY;
+    if (!accessKeyId || !secretAccessKey) {
+      return {};
+    }
+    return signAwsRequest({
+      method,
+      url,
+      headers,
+      payloadHash: body ? crypto.createHash('sha256').update(body).digest('hex') : EMPTY_SHA256,
+      region: this.env.AWS_REGION || this.env.AWS_DEFAULT_REGION || DEFAULT_S3_REGION,
+      service: 's3',
+      accessKeyId,
+      secretAccessKey,
+      sessionToken: this.env.AWS_SESSION_TOKEN,
+      date: (this.options.now ?? (() => new Date()))()
+    });
+  }
+}
+
+export interface AwsSigningRequest {
+  method: string;
+  url: string;
+  /** Headers to sign besides host and the x-amz-* ones added here */
+  headers: Record<string, string>;
+  /** Hex SHA-256 of the body */
+  payloadHash: string;
+  region: string;
+  service: string;
+  accessKeyId: string;
+  secretAccessKey: string;
+  sessionToken?: string;
+  date: Date;
+}
+
+/**
+ * Signature Version 4 headers (authorization, x-amz-date,
+ * x-amz-content-sha256 and x-amz-security-token) for a request without a
+ * query string.
+ */
+export function signAwsRequest(request: AwsSigningRequest): Record<string, string> {
+  const { host, pathname } = new URL(request.url);
+  const amzDate = request.date.toISOString().replace(/[-:]/g, '').replace(/\.\d{3}/, '');
+  const day = amzDate.slice(0, 8);
+
+  const added: Record<string, string> = {
+    'x-amz-content-sha256': request.payloadHash,
+    'x-amz-date': amzDate
+  };
+  if (request.sessionToken) {
+    added['x-amz-security-token'] = request.sessionToken;
+  }
+
+  const signed = new Map<string, string>([['host', host]]);
+  for (const [name, value] of Object.entries({ ...request.headers, ...added })) {
+    signed.set(name.toLowerCase(), value.trim().replace(/\s+/g, ' '));
+  }
+  const names = [...signed.keys()].sort();
+  const signedHeaders = names.join(';');
+  const canonicalRequest = [
+    request.method,
+    pathname,
+    '',
+    ...names.map(name => `${name}:${signed.get(name)}`),
+    '',
+    signedHeaders,
+    request.payloadHash
+  ].join('\n');
+
+  const scope = `${day}/${request.region}/${request.service}/aws4_request`;
+  const stringToSign = ['AWS4-HMAC-SHA256', amzDate, scope, sha256Hex(canonicalRequest)].join('\n');
+  let key: crypto.BinaryLike = `AWS4${request.secretAccessKey}`;
+  for (const part of [day, request.region, request.service, 'aws4_request']) {
+    key = hmac(key, part);
+  }
+  const signature = crypto.createHmac('sha256', key).update(stringToSign).digest('hex');
+
+  return {
+    ...added,
+    authorization: `AWS4-HMAC-SHA256 Credential=${request.accessKeyId}/${scope},SignedHeaders=${signedHeaders},Signature=${signature}`
+  };
+}
+
+/** URI-encode each segment of an object key (RFC 3986), keeping the slashes */
+function encodeKey(key: string): string {
+  return key.split('/').map(segment => encodeURIComponent(segment).replace(/[!'()*]/g, c =>
+    `%${c.charCodeAt(0).toString(16).toUpperCase()}`
+  )).join('/');
+}
+
+function hmac(key: crypto.BinaryLike, data: string): Buffer {
+  return crypto.createHmac('sha256', key).update(data).digest();
+}
+
+function sha256Hex(data: string): string {
+  return crypto.createHash('sha256').update(data).digest('hex');
+}
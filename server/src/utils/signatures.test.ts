@@ -0,0 +1,115 @@
+/**
+ * Signatures Tests
+ *
+ * Verifies TS signature extraction, signature patterns across languages
+ * and type parameter constraints.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { attachTsSignatures, hasConstraint, matchesSignature, parseSignaturePattern, signatureTypes } from './signatures.js';
+import { createTestSymbol } from '../test/mocks/MockIndex.js';
+import { IndexedSymbol } from '../types.js';
+
+function goSymbol(name: string, signature: string, overrides: Partial<IndexedSymbol> = {}): IndexedSymbol {
+  return createTestSymbol({
+    name,
+    signature,
+    filePath: '/ws/person.go',
+    location: { uri: '/ws/person.go', line: 0, character: 0 },
+    ...overrides
+  });
+}
+
+describe('attachTsSignatures', () => {
+  it('should read type parameters, parameters and return types after the name', () => {
+    const content = [
+      'export function pick<T extends object, K extends keyof T = keyof T>(obj: T, ...keys: K[]): Pick<T, K> {',
+      '  return obj;',
+      '}',
+      'class Cache<in out V> {',
+      '  get(key: string, load?: () => Promise<V>): { value: V; hit: boolean } {',
+      '    return null!;',
+      '  }',
+      '  clear() {}',
+      '}',
+      'interface Repo<T = unknown> {}'
+    ].join('\n');
+    const pick = createTestSymbol({ name: 'pick', kind: 'function', location: { uri: '/ws/a.ts', line: 0, character: 16 } });
+    const cache = createTestSymbol({ name: 'Cache', kind: 'class', location: { uri: '/ws/a.ts', line: 3, character: 6 } });
+    const get = createTestSymbol({ name: 'get', kind: 'method', location: { uri: '/ws/a.ts', line: 4, character: 2 } });
+    const clear = createTestSymbol({ name: 'clear', kind: 'method', location: { uri: '/ws/a.ts', line: 7, character: 2 } });
+    const repo = createTestSymbol({ name: 'Repo', kind: 'interface', location: { uri: '/ws/a.ts', line: 9, character: 10 } });
+
+    attachTsSignatures([pick, cache, get, clear, repo], content);
+    expect(pick.signature).toBe('(obj: T, ...keys: K[]): Pick<T, K>');
+    expect(pick.typeParameters).toEqual([
+      { name: 'T', constraint: 'object' },
+      { name: 'K', constraint: 'keyof T' }
+    ]);
+    expect(cache.typeParameters).toEqual([{ name: 'V' }]);
+    expect(cache.signature).toBeUndefined();
+    expect(get.signature).toBe('(key: string, load?: () => Promise<V>): { value: V; hit: boolean }');
+    expect(clear.signature).toBe('()');
+    expect(repo.typeParameters).toEqual([{ name: 'T' }]);
+  });
+});
+
+describe('signature patterns', () => {
+  it('should compare Go parameter and result types without names', () => {
+    const greet = goSymbol('Greet', 'func(p *Person, loud bool) string');
+    const save = goSymbol('Save', 'func(ctx context.Context, a, b *Person) error');
+
+    expect(signatureTypes(save)).toEqual({ params: ['context.Context', '*Person', '*Person'], results: ['error'] });
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person, bool)'))).toBe(true);
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person, bool) string'))).toBe(true);
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person, bool) error'))).toBe(false);
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person)'))).toBe(false);
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person, ...)'))).toBe(true);
+    expect(matchesSignature(save, parseSignaturePattern('func(Context, _, *Person) error'))).toBe(true);
+    expect(matchesSignature(goSymbol('Any', 'func(v interface{})'), parseSignaturePattern('func(any)'))).toBe(true);
+    expect(matchesSignature(goSymbol('Both', 'func(int, string) (int, error)'), parseSignaturePattern('func(int, string) (int, error)')))
+      .toBe(true);
+  });
+
+  it('should match Go methods in method-expression form', () => {
+    const greet = goSymbol('Greet', 'func() string', {
+      kind: 'method',
+      metadata: { go: { receiverType: 'Person', pointerReceiver: true } }
+    });
+
+    expect(matchesSignature(greet, parseSignaturePattern('func(*Person)'))).toBe(true);
+    expect(matchesSignature(greet, parseSignaturePattern('func() string'))).toBe(true);
+    expect(matchesSignature(greet, parseSignaturePattern('func(Person)'))).toBe(false);
+  });
+
+  it('should match TS and Python signatures', () => {
+    const ts = createTestSymbol({ name: 'load', kind: 'method', signature: '(id: string, options?: LoadOptions): Promise<User>' });
+    const py = createTestSymbol({
+      name: 'load',
+      kind: 'method',
+      filePath: '/ws/repo.py',
+      signature: '(self, user_id: int, cache: bool = True) -> User'
+    });
+
+    expect(matchesSignature(ts, parseSignaturePattern('(string, LoadOptions) => Promise<User>'))).toBe(true);
+    expect(matchesSignature(ts, parseSignaturePattern('(string, ...)'))).toBe(true);
+    expect(matchesSignature(py, parseSignaturePattern('(int, bool) -> User'))).toBe(true);
+    expect(matchesSignature(py, parseSignaturePattern('(int)'))).toBe(false);
+
+    expect(() => parseSignaturePattern('Person')).toThrow('must start with a parameter list');
+    expect(() => parseSignaturePattern('func(*Person')).toThrow('Unbalanced');
+  });
+
+  it('should find type parameters by constraint', () => {
+    const keys = goSymbol('Keys', 'func(m map[K]V) []K', {
+      typeParameters: [{ name: 'K', constraint: 'comparable' }, { name: 'V', constraint: 'any' }]
+    });
+    const sum = goSymbol('Sum', 'func(xs []T) T', { typeParameters: [{ name: 'T', constraint: '~int | constraints.Float' }] });
+
+    expect(hasConstraint(keys, 'comparable')).toBe(true);
+    expect(hasConstraint(sum, 'comparable')).toBe(false);
+    expect(hasConstraint(sum, 'int')).toBe(true);
+    expect(hasConstraint(sum, 'Float')).toBe(true);
+    expect(hasConstraint(sum, 'constraints.Float')).toBe(true);
+  });
+});
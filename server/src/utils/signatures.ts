@@ -0,0 +1,436 @@
+import { IndexedSymbol, TypeParameter } from '../types.js';
+
+/*
+ * Signatures are stored as written, per language:
+ *   Go:     func(p *Person, n int) (string, error)
+ *   TS/JS:  (p: Person, n?: number): string
+ *   Python: (self, p: Person, n: int = 1) -> str
+ *
+ * Signature patterns compare parameter and result types with names
+ * dropped. A pattern is a parenthesized list of types with optional results;
+ * `func` and any of `:`, `=>` or `->` before the results may be included:
+ *   func(*Person)           one *Person parameter, any results
+ *   func(string, ...) error a string first, anything after, returns error
+ *   (_, number) => void     any first parameter, a number, returns void
+ * Types compare with whitespace removed; a type written without a package
+ * or namespace qualifier also matches qualified ones (`Context` matches
+ * `context.Context`), and `any` equals `interface{}`.
+ */
+
+/** Parameter and result types of a signature; results undefined when not declared */
+export interface SignatureTypes {
+  params: string[];
+  results?: string[];
+}
+
+/** Parsed signature pattern, see parseSignaturePattern */
+export interface SignaturePattern extends SignatureTypes {
+  /** Trailing `...`: any further parameters */
+  rest: boolean;
+}
+
+const TS_SIGNATURE_KINDS = new Set(['function', 'method']);
+const TS_GENERIC_KINDS = new Set(['function', 'method', 'class', 'interface', 'type']);
+
+/** Go types that start with a keyword followed by a space: `chan int`, `func() error` */
+const GO_TYPE_KEYWORDS = new Set(['chan', 'func', 'interface', 'struct', 'map']);
+
+/**
+ * Parse a signature pattern such as `func(*Person) string`.
+ * Throws on patterns that are not a parenthesized parameter list.
+ */
+export function parseSignaturePattern(pattern: string): SignaturePattern {
+  const text = pattern.trim().replace(/^func\s*/, '');
+  if (!text.startsWith('(')) {
+    throw new Error(`Signature pattern must start with a parameter list, e.g. func(*Person): "${pattern}"`);
+  }
+  const close = matchingClose(text, 0);
+  if (close === -1) {
+    throw new Error(`Unbalanced parentheses in signature pattern "${pattern}"`);
+  }
+  const params = splitTopLevel(text.slice(1, close));
+  const rest = params[params.length - 1] === '...';
+  if (rest) {
+    params.pop();
+  }
+  const resultText = text.slice(close + 1).trim().replace(/^(:|=>|->)\s*/, '');
+  return { params, rest, results: resultText ? parseResults(resultText) : undefined };
+}
+
+/**
+ * Does the symbol's signature match the pattern? Go methods also match in
+ * method-expression form, with the receiver as the first parameter
+ * (`func(*Person) string` matches `func (p *Person) Greet() string`);
+ * Python `self`/`cls` parameters are not compared.
+ */
+export function matchesSignature(symbol: IndexedSymbol, pattern: SignaturePattern): boolean {
+  const types = signatureTypes(symbol);
+  if (!types) {
+    return false;
+  }
+  if (matchesTypes(types, pattern)) {
+    return true;
+  }
+  const receiver = goReceiver(symbol);
+  return receiver !== undefined && matchesTypes({ ...types, params: [receiver, ...types.params] }, pattern);
+}
+
+/**
+ * Does any type parameter have a constraint mentioning the term
+ * (`comparable`, `constraints.Ordered`, `~int`)?
+ */
+export function hasConstraint(symbol: IndexedSymbol, term: string): boolean {
+  const wanted = term.replace(/\s+/g, '');
+  return (symbol.typeParameters ?? []).some(parameter => {
+    const constraint = parameter.constraint?.replace(/\s+/g, '');
+    if (!constraint) {
+      return false;
+    }
+    return constraint === wanted || constraint.split(/[|&,{}()[\];]/).some(part =>
+      part === wanted || part.replace(/^~/, '') === wanted || stripQualifiers(part) === wanted
+    );
+  });
+}
+
+/**
+ * Parameter and result types of a symbol's signature, by language.
+ */
+export function signatureTypes(symbol: IndexedSymbol): SignatureTypes | undefined {
+  const signature = symbol.signature;
+  if (!signature) {
+    return undefined;
+  }
+  const filePath = symbol.filePath || symbol.location.uri;
+  if (filePath.endsWith('.go')) {
+    return goSignatureTypes(signature.replace(/^func\s*/, ''));
+  }
+  if (/\.pyi?$/.test(filePath)) {
+    return pythonSignatureTypes(signature, symbol.kind === 'method' && !symbol.isStatic);
+  }
+  return tsSignatureTypes(signature);
+}
+
+/**
+ * Attach TS/JS signatures and type parameters to functions, methods,
+ * classes, interfaces and type aliases, read from the source after each
+ * symbol's name.
+ */
+export function attachTsSignatures(symbols: IndexedSymbol[], content: string): void {
+  const lineOffsets = [0];
+  for (let i = content.indexOf('\n'); i !== -1; i = content.indexOf('\n', i + 1)) {
+    lineOffsets.push(i + 1);
+  }
+
+  for (const symbol of symbols) {
+    if (symbol.isDefinition === false || !TS_GENERIC_KINDS.has(symbol.kind)) {
+      continue;
+    }
+    const nameStart = (lineOffsets[symbol.location.line] ?? -1) + symbol.location.character;
+    if (content.slice(nameStart, nameStart + symbol.name.length) !== symbol.name) {
+      continue;
+    }
+    let i = skipSpaces(content, nameStart + symbol.name.length);
+
+    if (content[i] === '<') {
+      const close = matchingClose(content, i);
+      if (close === -1) {
+        continue;
+      }
+      const typeParameters = parseTsTypeParameters(content.slice(i + 1, close));
+      if (typeParameters.length > 0) {
+        symbol.typeParameters = typeParameters;
+      }
+      i = skipSpaces(content, close + 1);
+    }
+
+    if (TS_SIGNATURE_KINDS.has(symbol.kind) && content[i] === '(') {
+      const close = matchingClose(content, i);
+      if (close === -1) {
+        continue;
+      }
+      const params = content.slice(i, close + 1);
+      const returnType = readTsReturnType(content, close + 1);
+      symbol.signature = collapse(params) + (returnType ? `: ${returnType}` : '');
+    }
+  }
+}
+
+/**
+ * TS type parameters: `const T extends Base = Default, in out U`.
+ */
+export function parseTsTypeParameters(text: string): TypeParameter[] {
+  const parameters: TypeParameter[] = [];
+  for (const entry of splitTopLevel(text)) {
+    const match = entry.match(/^(?:(?:const|in|out)\s+)*([A-Za-z_$][\w$]*)(?:\s+extends\s+([\s\S]+?))?(?:\s*=\s*[\s\S]+)?$/);
+    if (match) {
+      parameters.push(match[2] ? { name: match[1], constraint: collapse(match[2]) } : { name: match[1] });
+    }
+  }
+  return parameters;
+}
+
+// ---------------------------------------------------------------------------
+// Per-language parameter lists
+// ---------------------------------------------------------------------------
+
+function goSignatureTypes(signature: string): SignatureTypes | undefined {
+  if (!signature.startsWith('(')) {
+    return undefined;
+  }
+  const close = matchingClose(signature, 0);
+  if (close === -1) {
+    return undefined;
+  }
+  const resultText = signature.slice(close + 1).trim();
+  return {
+    params: goParameterTypes(signature.slice(1, close)),
+    results: resultText.startsWith('(')
+      ? goParameterTypes(resultText.slice(1, matchingClose(resultText, 0)))
+      : resultText ? [resultText] : []
+  };
+}
+
+/**
+ * Types of a Go parameter list. Lists are all named or all unnamed; in a
+ * named list `a, b int` gives both names the type.
+ */
+function goParameterTypes(list: string): string[] {
+  const entries = splitTopLevel(list);
+  const named = entries.some(entry => {
+    const match = entry.match(/^([A-Za-z_]\w*)\s+\S/);
+    return match !== null && !GO_TYPE_KEYWORDS.has(match[1]);
+  });
+  if (!named) {
+    return entries;
+  }
+  const types: string[] = [];
+  let pending = 0;
+  for (const entry of entries) {
+    const space = entry.search(/\s/);
+    if (space === -1) {
+      pending++;
+      continue;
+    }
+    const type = entry.slice(space).trim();
+    for (let k = 0; k <= pending; k++) {
+      types.push(type);
+    }
+    pending = 0;
+  }
+  return types;
+}
+
+function tsSignatureTypes(signature: string): SignatureTypes | undefined {
+  const close = matchingClose(signature, 0);
+  if (close === -1) {
+    return undefined;
+  }
+  const params = splitTopLevel(signature.slice(1, close))
+    .filter(entry => !/^this\s*:/.test(entry))
+    .map(entry => {
+      const colon = topLevelIndex(entry, ':');
+      const type = colon === -1 ? '' : stripDefault(entry.slice(colon + 1));
+      return entry.startsWith('...') && type ? '...' + type : type;
+    });
+  const resultText = signature.slice(close + 1).trim().replace(/^:\s*/, '');
+  return { params, results: resultText ? [resultText] : undefined };
+}
+
+function pythonSignatureTypes(signature: string, isMethod: boolean): SignatureTypes | undefined {
+  const close = matchingClose(signature, 0);
+  if (close === -1) {
+    return undefined;
+  }
+  const entries = splitTopLevel(signature.slice(1, close)).filter(entry => entry !== '/' && entry !== '*');
+  if (isMethod && /^(self|cls)$/.test(entries[0] ?? '')) {
+    entries.shift();
+  }
+  const params = entries.map(entry => {
+    const colon = topLevelIndex(entry, ':');
+    return colon === -1 ? '' : stripDefault(entry.slice(colon + 1));
+  });
+  const resultText = signature.slice(close + 1).trim().replace(/^->\s*/, '');
+  return { params, results: resultText ? [resultText] : undefined };
+}
+
+function goReceiver(symbol: IndexedSymbol): string | undefined {
+  const go = symbol.metadata?.go as { receiverType?: string; pointerReceiver?: boolean } | undefined;
+  if (symbol.kind !== 'method' || !go?.receiverType) {
+    return undefined;
+  }
+  return (go.pointerReceiver ? '*' : '') + go.receiverType;
+}
+
+// ---------------------------------------------------------------------------
+// Matching
+// ---------------------------------------------------------------------------
+
+function matchesTypes(types: SignatureTypes, pattern: SignaturePattern): boolean {
+  if (pattern.rest ? types.params.length < pattern.params.length : types.params.length !== pattern.params.length) {
+    return false;
+  }
+  if (!pattern.params.every((wanted, k) => typeMatches(types.params[k], wanted))) {
+    return false;
+  }
+  if (pattern.results === undefined) {
+    return true;
+  }
+  const results = types.results ?? [];
+  return results.length === pattern.results.length &&
+    pattern.results.every((wanted, k) => typeMatches(results[k], wanted));
+}
+
+function typeMatches(actual: string, wanted: string): boolean {
+  if (wanted === '_') {
+    return true;
+  }
+  const a = normalizeType(actual);
+  const w = normalizeType(wanted);
+  if (!a) {
+    return false;
+  }
+  return a === w || (!w.includes('.') && stripQualifiers(a) === w);
+}
+
+function normalizeType(type: string): string {
+  return type.replace(/\s+/g, '').replace(/interface\{\}/g, 'any');
+}
+
+/** `*context.Context` -> `*Context`, `[]pkg.T` -> `[]T` */
+function stripQualifiers(type: string): string {
+  return type.replace(/\b[A-Za-z_]\w*\./g, '');
+}
+
+function parseResults(text: string): string[] {
+  if (text.startsWith('(')) {
+    const close = matchingClose(text, 0);
+    if (close === text.length - 1) {
+      return splitTopLevel(text.slice(1, close));
+    }
+  }
+  return [text];
+}
+
+// ---------------------------------------------------------------------------
+// Text helpers
+// ---------------------------------------------------------------------------
+
+/** Opening bracket; `<-` (Go channel direction) is not one */
+function isOpen(text: string, k: number): boolean {
+  return '([{'.includes(text[k]) || (text[k] === '<' && text[k + 1] !== '-');
+}
+
+/** Closing bracket; `=>` and `->` arrows are not */
+function isClose(text: string, k: number): boolean {
+  return ')]}'.includes(text[k]) || (text[k] === '>' && text[k - 1] !== '=' && text[k - 1] !== '-');
+}
+
+/**
+ * Index of the bracket closing the one at `open`, or -1.
+ */
+function matchingClose(text: string, open: number): number {
+  let depth = 0;
+  for (let k = open; k < text.length; k++) {
+    const char = text[k];
+    if (char === '"' || char === "'" || char === '`') {
+      const end = text.indexOf(char, k + 1);
+      k = end === -1 ? text.length : end;
+    } else if (isOpen(text, k)) {
+      depth++;
+    } else if (isClose(text, k)) {
+      depth--;
+      if (depth === 0) {
+        return k;
+      }
+    }
+  }
+  return -1;
+}
+
+/** Split at commas outside brackets, trimming entries and dropping empty ones */
+function splitTopLevel(text: string): string[] {
+  const entries: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let k = 0; k <= text.length; k++) {
+    const char = text[k];
+    if (k === text.length || (char === ',' && depth === 0)) {
+      const entry = collapse(text.slice(start, k));
+      if (entry) {
+        entries.push(entry);
+      }
+      start = k + 1;
+    } else if (isOpen(text, k)) {
+      depth++;
+    } else if (isClose(text, k)) {
+      depth--;
+    }
+  }
+  return entries;
+}
+
+function topLevelIndex(text: string, wanted: string): number {
+  let depth = 0;
+  for (let k = 0; k < text.length; k++) {
+    const char = text[k];
+    if (char === wanted && depth === 0) {
+      return k;
+    }
+    if (isOpen(text, k)) {
+      depth++;
+    } else if (isClose(text, k)) {
+      depth--;
+    }
+  }
+  return -1;
+}
+
+/** `int = 1` -> `int` */
+function stripDefault(text: string): string {
+  const equals = topLevelIndex(text, '=');
+  return collapse(equals === -1 ? text : text.slice(0, equals));
+}
+
+/**
+ * A TS return type annotation starting at `start` (after the parameter
+ * list), up to the body, `;` or the end of the line.
+ */
+function readTsReturnType(content: string, start: number): string | undefined {
+  let i = skipSpaces(content, start);
+  if (content[i] !== ':') {
+    return undefined;
+  }
+  i++;
+  const typeStart = i;
+  let depth = 0;
+  for (; i < content.length; i++) {
+    const char = content[i];
+    const text = content.slice(typeStart, i).trim();
+    if (depth === 0) {
+      if (char === ';' || (char === '{' && text && !/[|&,:<]$|=>$/.test(text))) {
+        break;
+      }
+      if (char === '\n' && text && !/[|&,<(]$|=>$/.test(text)) {
+        break;
+      }
+    }
+    if (isOpen(content, i)) {
+      depth++;
+    } else if (isClose(content, i)) {
+      depth--;
+    }
+  }
+  return collapse(content.slice(typeStart, i)) || undefined;
+}
+
+function skipSpaces(text: string, start: number): number {
+  let i = start;
+  while (i < text.length && /\s/.test(text[i])) {
+    i++;
+  }
+  return i;
+}
+
+function collapse(text: string): string {
+  return text.replace(/\s+/g, ' ').trim();
+}
@@ -0,0 +1,134 @@
+import * as path from 'path';
+import { SymbolRange } from '../types.js';
+
+/*
+ * A lexer that is good enough for every indexed language at once: it knows
+ * comments, string and number literals, identifiers, keywords and
+ * operators, but no grammar. Analyses that look at the shape of code
+ * (clone fingerprints, complexity metrics) use it instead of a parser per
+ * language.
+ */
+
+export type SourceTokenType = 'keyword' | 'identifier' | 'string' | 'number' | 'operator';
+
+export interface SourceToken {
+  type: SourceTokenType;
+  text: string;
+  /** Zero-based line of the token's first character, relative to the source */
+  line: number;
+}
+
+/**
+ * Keywords of the indexed languages (Go, TypeScript/JavaScript, Python).
+ */
+export const SOURCE_KEYWORDS = new Set([
+  // Go
+  'break', 'case', 'chan', 'const', 'continue', 'default', 'defer', 'else', 'fallthrough', 'for', 'func',
+  'go', 'goto', 'if', 'import', 'interface', 'map', 'package', 'range', 'return', 'select', 'struct',
+  'switch', 'type', 'var', 'nil',
+  // TypeScript / JavaScript
+  'async', 'await', 'catch', 'class', 'delete', 'do', 'extends', 'finally', 'function', 'in', 'instanceof',
+  'let', 'new', 'of', 'super', 'this', 'throw', 'try', 'typeof', 'void', 'while', 'yield', 'null',
+  'undefined', 'true', 'false',
+  // Python
+  'and', 'as', 'assert', 'def', 'del', 'elif', 'except', 'from', 'global', 'is', 'lambda', 'nonlocal',
+  'not', 'or', 'pass', 'raise', 'with', 'None', 'True', 'False', 'self'
+]);
+
+/** Languages where `#` starts a comment */
+const HASH_COMMENT_EXTENSIONS = new Set(['.py', '.pyi', '.rb', '.sh']);
+
+/** Multi-character operators, longest first */
+const OPERATOR = /^(?:===|!==|\*\*=|<<=|>>=|\.\.\.|=>|:=|<-|==|!=|<=|>=|&&|\|\||\+\+|--|\+=|-=|\*=|\/=|<<|>>|->|::|\?\?|\?\.)/;
+
+/**
+ * Does `#` start a comment in this file's language?
+ */
+export function usesHashComments(filePath: string): boolean {
+  return HASH_COMMENT_EXTENSIONS.has(path.extname(filePath).toLowerCase());
+}
+
+/**
+ * Tokens of source code, comments dropped. Unterminated strings and
+ * comments end at the end of the line or source instead of failing.
+ */
+export function tokenizeSource(source: string, hashComments: boolean = false): SourceToken[] {
+  const tokens: SourceToken[] = [];
+  let line = 0;
+  let i = 0;
+
+  // Move to `end`, counting the lines skipped on the way
+  const advance = (end: number) => {
+    for (let next = source.indexOf('\n', i); next !== -1 && next < end; next = source.indexOf('\n', next + 1)) {
+      line++;
+    }
+    i = end;
+  };
+
+  while (i < source.length) {
+    const ch = source[i];
+    const start = line;
+
+    if (ch === '\n') {
+      line++;
+      i++;
+    } else if (/\s/.test(ch)) {
+      i++;
+    } else if (source.startsWith('//', i) || (hashComments && ch === '#')) {
+      const end = source.indexOf('\n', i);
+      i = end < 0 ? source.length : end;
+    } else if (source.startsWith('/*', i)) {
+      const end = source.indexOf('*/', i + 2);
+      advance(end < 0 ? source.length : end + 2);
+    } else if (ch === '"' || ch === "'" || ch === '`') {
+      const quote = source.startsWith(ch.repeat(3), i) ? ch.repeat(3) : ch;
+      const end = skipString(source, i + quote.length, quote);
+      tokens.push({ type: 'string', text: source.slice(i, end), line: start });
+      advance(end);
+    } else if (/[0-9]/.test(ch) || (ch === '.' && /[0-9]/.test(source[i + 1] ?? ''))) {
+      const match = /^(?:0[xX][0-9a-fA-F_]+|[0-9_]*\.?[0-9_]+(?:[eE][+-]?[0-9]+)?)[a-zA-Z]*/.exec(source.slice(i, i + 64));
+      const text = match ? match[0] : ch;
+      tokens.push({ type: 'number', text, line });
+      i += text.length;
+    } else if (/[\p{L}_$]/u.test(ch)) {
+      const text = /^[\p{L}\p{N}_$]+/u.exec(source.slice(i, i + 256))![0];
+      tokens.push({ type: SOURCE_KEYWORDS.has(text) ? 'keyword' : 'identifier', text, line });
+      i += text.length;
+    } else {
+      const text = OPERATOR.exec(source.slice(i, i + 3))?.[0] ?? ch;
+      tokens.push({ type: 'operator', text, line });
+      i += text.length;
+    }
+  }
+
+  return tokens;
+}
+
+/**
+ * Source text of a range, given the file's lines.
+ */
+export function rangeSource(lines: string[], range: SymbolRange): string {
+  const text = lines.slice(range.startLine, range.endLine + 1);
+  if (text.length === 0) {
+    return '';
+  }
+  text[text.length - 1] = text[text.length - 1].substring(0, range.endCharacter);
+  text[0] = text[0].substring(range.startCharacter);
+  return text.join('\n');
+}
+
+function skipString(source: string, start: number, quote: string): number {
+  let i = start;
+  while (i < source.length) {
+    if (source[i] === '\\' && quote !== '`') {
+      i += 2;
+    } else if (source.startsWith(quote, i)) {
+      return i + quote.length;
+    } else if (source[i] === '\n' && quote.length === 1 && quote !== '`') {
+      return i; // Unterminated
+    } else {
+      i++;
+    }
+  }
+  return i;
+}
@@ -0,0 +1,79 @@
+/**
+ * Spill Buffer Tests
+ *
+ * Verifies spilling to segments and the merge back into key order.
+ */
+
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { SpillBuffer, SpillRecord } from './spillBuffer.js';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+
+describe('SpillBuffer', () => {
+  let tempDir: string;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'smart-indexer-spill-test-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  async function drain(buffer: SpillBuffer): Promise<SpillRecord[]> {
+    const records: SpillRecord[] = [];
+    for await (const record of buffer.drain()) {
+      records.push(record);
+    }
+    return records;
+  }
+
+  const bytes = (text: string) => new TextEncoder().encode(text);
+  const text = (records: SpillRecord[]) => records.map(r => `${r.key}=${new TextDecoder().decode(r.data)}`);
+
+  it('should sort in memory while under budget', async () => {
+    const buffer = new SpillBuffer({ budgetBytes: 1024, tempDir });
+    await buffer.add('b', bytes('2'));
+    await buffer.add('a', bytes('1'));
+
+    expect(text(await drain(buffer))).toEqual(['a=1', 'b=2']);
+    expect(buffer.segmentCount).toBe(0);
+    expect(fs.readdirSync(tempDir)).toEqual([]);
+  });
+
+  it('should merge spilled segments in key order', async () => {
+    const buffer = new SpillBuffer({ budgetBytes: 10, tempDir });
+    const keys = ['m', 'c', 'x', 'a', 'q', 'c', 'z', 'b', 'k'];
+    for (let i = 0; i < keys.length; i++) {
+      await buffer.add(keys[i], bytes(`${i}`.repeat(4)));
+    }
+    expect(buffer.size).toBe(keys.length);
+    expect(buffer.segmentCount).toBe(4);
+    expect(fs.readdirSync(tempDir)).toHaveLength(1);
+
+    const merged = await drain(buffer);
+    expect(merged.map(r => r.key)).toEqual(['a', 'b', 'c', 'c', 'k', 'm', 'q', 'x', 'z']);
+    // Equal keys keep insertion order
+    expect(text(merged.filter(r => r.key === 'c'))).toEqual(['c=1111', 'c=5555']);
+    expect(fs.readdirSync(tempDir)).toEqual([]);
+  });
+
+  it('should read records larger than the read chunk', async () => {
+    const buffer = new SpillBuffer({ budgetBytes: 1, tempDir });
+    const large = new Uint8Array(200 * 1024).fill(7);
+    await buffer.add('large', large);
+    await buffer.add('small', bytes('s'));
+
+    const [first, second] = await drain(buffer);
+    expect(first.data).toEqual(large);
+    expect(text([second])).toEqual(['small=s']);
+  });
+
+  it('should delete segments on dispose', async () => {
+    const buffer = new SpillBuffer({ budgetBytes: 1, tempDir });
+    await buffer.add('a', bytes('1'));
+    await buffer.dispose();
+    expect(fs.readdirSync(tempDir)).toEqual([]);
+  });
+});
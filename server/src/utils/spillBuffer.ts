@@ -0,0 +1,207 @@
+/**
+ * Spill buffer - bounded-memory sort of keyed records.
+ *
+ * Records are buffered in memory until their size reaches the budget, then
+ * sorted by key and written to a temporary segment file. drain() merges the
+ * segments (and whatever is still buffered) back in key order, reading each
+ * segment in small chunks, so memory stays around the budget no matter how
+ * many records pass through.
+ *
+ * Segment layout, per record: u32 key length, key (UTF-8), u32 data
+ * length, data. Lengths are little-endian.
+ */
+
+import * as fsPromises from 'fs/promises';
+import * as os from 'os';
+import * as path from 'path';
+
+export interface SpillRecord {
+  key: string;
+  data: Uint8Array;
+}
+
+export interface SpillBufferOptions {
+  /** Buffered bytes (keys and data) before a segment is written */
+  budgetBytes: number;
+  /** Parent directory for segment files (default: the OS temp directory) */
+  tempDir?: string;
+}
+
+const READ_CHUNK_SIZE = 64 * 1024;
+
+export class SpillBuffer {
+  private records: SpillRecord[] = [];
+  private bufferedBytes = 0;
+  private segments: string[] = [];
+  private segmentDir: string | undefined;
+  private count = 0;
+
+  constructor(private readonly options: SpillBufferOptions) {}
+
+  /** Records added so far */
+  get size(): number {
+    return this.count;
+  }
+
+  /** Segments written to disk so far */
+  get segmentCount(): number {
+    return this.segments.length;
+  }
+
+  async add(key: string, data: Uint8Array): Promise<void> {
+    this.records.push({ key, data });
+    this.bufferedBytes += Buffer.byteLength(key) + data.length;
+    this.count++;
+    if (this.bufferedBytes >= this.options.budgetBytes) {
+      await this.spill();
+    }
+  }
+
+  /**
+   * All records in key order; records with equal keys come out in the order
+   * they were added. The buffer is empty afterwards.
+   */
+  async *drain(): AsyncGenerator<SpillRecord> {
+    if (this.segments.length === 0) {
+      const records = this.takeSorted();
+      yield* records;
+      return;
+    }
+
+    await this.spill();
+    const readers: SegmentReader[] = [];
+    try {
+      for (const segment of this.segments) {
+        readers.push(await SegmentReader.open(segment));
+      }
+      const heads = await Promise.all(readers.map(reader => reader.next()));
+
+      // Segments are few (data size / budget), so a linear scan for the
+      // smallest head is cheaper than maintaining a heap
+      for (;;) {
+        let min = -1;
+        for (let i = 0; i < heads.length; i++) {
+          const head = heads[i];
+          if (head && (min < 0 || head.key < heads[min]!.key)) {
+            min = i;
+          }
+        }
+        if (min < 0) {
+          break;
+        }
+        yield heads[min]!;
+        heads[min] = await readers[min].next();
+      }
+    } finally {
+      await Promise.all(readers.map(reader => reader.close()));
+      await this.removeSegments();
+    }
+  }
+
+  /**
+   * Delete segment files; call when giving up before drain() finishes.
+   */
+  async dispose(): Promise<void> {
+    this.records = [];
+    this.bufferedBytes = 0;
+    await this.removeSegments();
+  }
+
+  private async spill(): Promise<void> {
+    if (this.records.length === 0) {
+      return;
+    }
+    if (!this.segmentDir) {
+      this.segmentDir = await fsPromises.mkdtemp(path.join(this.options.tempDir ?? os.tmpdir(), 'smart-indexer-spill-'));
+    }
+
+    const chunks: Uint8Array[] = [];
+    for (const { key, data } of this.takeSorted()) {
+      const keyBytes = Buffer.from(key, 'utf-8');
+      const header = Buffer.alloc(4);
+      header.writeUInt32LE(keyBytes.length);
+      const length = Buffer.alloc(4);
+      length.writeUInt32LE(data.length);
+      chunks.push(header, keyBytes, length, data);
+    }
+
+    const segment = path.join(this.segmentDir, `segment-${this.segments.length}.bin`);
+    await fsPromises.writeFile(segment, Buffer.concat(chunks));
+    this.segments.push(segment);
+  }
+
+  private takeSorted(): SpillRecord[] {
+    // Array.prototype.sort is stable, which keeps equal keys in insertion order
+    const records = this.records.sort((a, b) => (a.key < b.key ? -1 : a.key > b.key ? 1 : 0));
+    this.records = [];
+    this.bufferedBytes = 0;
+    return records;
+  }
+
+  private async removeSegments(): Promise<void> {
+    if (this.segmentDir) {
+      await fsPromises.rm(this.segmentDir, { recursive: true, force: true });
+    }
+    this.segmentDir = undefined;
+    this.segments = [];
+  }
+}
+
+/**
+ * Sequential reader over one segment file.
+ */
+class SegmentReader {
+  private buffer = Buffer.alloc(0);
+  private position = 0;
+  private eof = false;
+
+  private constructor(private handle: fsPromises.FileHandle) {}
+
+  static async open(filePath: string): Promise<SegmentReader> {
+    return new SegmentReader(await fsPromises.open(filePath, 'r'));
+  }
+
+  async next(): Promise<SpillRecord | undefined> {
+    if (!(await this.fill(4))) {
+      if (this.buffer.length > 0) {
+        throw new Error('Truncated spill segment');
+      }
+      return undefined;
+    }
+    const keyLength = this.buffer.readUInt32LE(0);
+    await this.require(4 + keyLength + 4);
+    const key = this.buffer.toString('utf-8', 4, 4 + keyLength);
+    const dataLength = this.buffer.readUInt32LE(4 + keyLength);
+    const recordLength = 4 + keyLength + 4 + dataLength;
+    await this.require(recordLength);
+
+    // Copy, so the record does not keep the whole read chunk alive
+    const data = new Uint8Array(this.buffer.subarray(4 + keyLength + 4, recordLength));
+    this.buffer = this.buffer.subarray(recordLength);
+    return { key, data };
+  }
+
+  async close(): Promise<void> {
+    await this.handle.close();
+  }
+
+  private async require(length: number): Promise<void> {
+    if (!(await this.fill(length))) {
+      throw new Error('Truncated spill segment');
+    }
+  }
+
+  /**
+   * Read until at least length bytes are buffered; false at end of file.
+   */
+  private async fill(length: number): Promise<boolean> {
+    while (this.buffer.length < length && !this.eof) {
+      const chunk = Buffer.alloc(Math.max(READ_CHUNK_SIZE, length - this.buffer.length));
+      const { bytesRead } = await this.handle.read(chunk, 0, chunk.length, this.position);
+      this.position += bytesRead;
+      this.eof = bytesRead === 0;
+      this.buffer = Buffer.concat([this.buffer, chunk.subarray(0, bytesRead)]);
+    }
+    return this.buffer.length >= length;
+  }
+}
@@ -0,0 +1,73 @@
+/**
+ * SQL Parser Tests
+ *
+ * Verifies operations, read and written tables (aliases, joins, CTEs,
+ * DDL) and column attribution of the lightweight SQL reader.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { parseSql } from './sqlParser.js';
+
+describe('parseSql', () => {
+  it('should attribute columns through aliases and joins', () => {
+    expect(parseSql(`
+      SELECT u.id, u.email, o.total, count(*) AS orders
+      FROM public.users u
+      LEFT JOIN orders AS o ON o.user_id = u.id
+      WHERE u.deleted_at IS NULL AND o.created_at > $1 -- recent only
+      GROUP BY u.id ORDER BY orders DESC`)).toEqual({
+      operation: 'select',
+      tables: [{ name: 'public.users', access: 'read' }, { name: 'orders', access: 'read' }],
+      columns: [
+        { name: 'id', table: 'public.users' },
+        { name: 'email', table: 'public.users' },
+        { name: 'total', table: 'orders' },
+        { name: 'user_id', table: 'orders' },
+        { name: 'deleted_at', table: 'public.users' },
+        { name: 'created_at', table: 'orders' }
+      ]
+    });
+  });
+
+  it('should tell written tables from read ones', () => {
+    expect(parseSql('INSERT INTO audit_log (actor, action) SELECT name, ? FROM users WHERE id = ?')).toMatchObject({
+      operation: 'insert',
+      tables: [{ name: 'audit_log', access: 'write' }, { name: 'users', access: 'read' }]
+    });
+    expect(parseSql(`INSERT INTO users (email, name) VALUES ($1, $2)
+      ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name`)).toEqual({
+      operation: 'insert',
+      tables: [{ name: 'users', access: 'write' }],
+      columns: [{ name: 'email', table: 'users' }, { name: 'name', table: 'users' }]
+    });
+    expect(parseSql('UPDATE "Accounts" SET balance = balance - :amount WHERE id = :id')).toEqual({
+      operation: 'update',
+      tables: [{ name: 'Accounts', access: 'write' }],
+      columns: [{ name: 'balance', table: 'Accounts' }, { name: 'id', table: 'Accounts' }]
+    });
+    expect(parseSql('DELETE FROM sessions s USING users u WHERE s.user_id = u.id')!.tables).toEqual([
+      { name: 'sessions', access: 'write' },
+      { name: 'users', access: 'read' }
+    ]);
+  });
+
+  it('should read CTEs, DDL and table functions', () => {
+    const cte = parseSql(`WITH recent AS (SELECT user_id FROM orders WHERE created_at > now() - interval '7 days')
+      SELECT * FROM users JOIN recent ON recent.user_id = users.id`)!;
+    expect(cte.operation).toBe('select');
+    expect(cte.tables).toEqual([{ name: 'orders', access: 'read' }, { name: 'users', access: 'read' }]);
+
+    expect(parseSql('CREATE TABLE IF NOT EXISTS invoices (id bigserial PRIMARY KEY, user_id int REFERENCES users(id))')).toMatchObject({
+      operation: 'create',
+      tables: [{ name: 'invoices', access: 'write' }, { name: 'users', access: 'read' }]
+    });
+    expect(parseSql('TRUNCATE events')!.tables).toEqual([{ name: 'events', access: 'write' }]);
+    expect(parseSql('SELECT n FROM generate_series(1, %d) AS n')!.tables).toEqual([]);
+  });
+
+  it('should only read text that starts like a statement', () => {
+    expect(parseSql('select a file to upload')).toMatchObject({ operation: 'select', tables: [] });
+    expect(parseSql('users')).toBeNull();
+    expect(parseSql('')).toBeNull();
+  });
+});
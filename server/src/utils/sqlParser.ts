@@ -0,0 +1,326 @@
+/*
+ * A lightweight SQL reader: enough of the grammar shared by PostgreSQL,
+ * MySQL and SQLite to tell which tables a statement reads or writes and
+ * which columns it names. It does not validate anything; statements it
+ * cannot follow just report fewer tables and columns.
+ *
+ *   SELECT u.id, o.total FROM users u JOIN orders o ON o.user_id = u.id
+ *     -> select; tables users, orders (read); columns users.id,
+ *        orders.total, orders.user_id
+ *   INSERT INTO audit_log (actor, action) VALUES ($1, $2)
+ *     -> insert; table audit_log (write); columns audit_log.actor, audit_log.action
+ *
+ * Placeholders ($1, ?, :name, @name) and fmt verbs (%s) are parameters,
+ * never columns. CTE names are not tables; their bodies are read like the
+ * rest of the statement.
+ */
+
+export type SqlOperation = 'select' | 'insert' | 'update' | 'delete' | 'merge' | 'create' | 'alter' | 'drop' | 'truncate';
+
+export const SQL_OPERATIONS: SqlOperation[] = ['select', 'insert', 'update', 'delete', 'merge', 'create', 'alter', 'drop', 'truncate'];
+
+export interface SqlTableRef {
+  /** As written, schema included: `users`, `public.users` */
+  name: string;
+  /** Written by INSERT, UPDATE, DELETE, MERGE and DDL; read otherwise */
+  access: 'read' | 'write';
+}
+
+export interface SqlColumnRef {
+  name: string;
+  /** Table the column belongs to, when the qualifier or a single table tells */
+  table?: string;
+}
+
+export interface SqlStatement {
+  operation: SqlOperation;
+  tables: SqlTableRef[];
+  columns: SqlColumnRef[];
+}
+
+interface SqlToken {
+  type: 'word' | 'quoted' | 'string' | 'number' | 'param' | 'punct';
+  text: string;
+  /** Upper-case text of words, for keyword checks */
+  upper: string;
+}
+
+const OPERATIONS = new Set(SQL_OPERATIONS.map(operation => operation.toUpperCase()));
+
+const KEYWORDS = new Set([
+  'SELECT', 'FROM', 'WHERE', 'AND', 'OR', 'NOT', 'IN', 'IS', 'NULL', 'LIKE', 'ILIKE', 'SIMILAR', 'BETWEEN', 'EXISTS',
+  'AS', 'ON', 'JOIN', 'INNER', 'LEFT', 'RIGHT', 'FULL', 'OUTER', 'CROSS', 'NATURAL', 'LATERAL', 'GROUP', 'BY',
+  'ORDER', 'HAVING', 'LIMIT', 'OFFSET', 'FETCH', 'FIRST', 'NEXT', 'ROWS', 'ROW', 'ONLY', 'UNION', 'ALL',
+  'INTERSECT', 'EXCEPT', 'DISTINCT', 'INSERT', 'INTO', 'VALUES', 'VALUE', 'UPDATE', 'SET', 'DELETE', 'MERGE',
+  'USING', 'WHEN', 'MATCHED', 'THEN', 'ELSE', 'END', 'CASE', 'RETURNING', 'WITH', 'RECURSIVE', 'CREATE',
+  'ALTER', 'DROP', 'TRUNCATE', 'TABLE', 'INDEX', 'UNIQUE', 'PRIMARY', 'KEY', 'FOREIGN', 'REFERENCES',
+  'CONSTRAINT', 'DEFAULT', 'CHECK', 'IF', 'CASCADE', 'RESTRICT', 'ASC', 'DESC', 'NULLS', 'TRUE', 'FALSE',
+  'CONFLICT', 'DO', 'NOTHING', 'EXCLUDED', 'ADD', 'COLUMN', 'RENAME', 'TO', 'TEMP', 'TEMPORARY', 'UNLOGGED',
+  'VIEW', 'MATERIALIZED', 'ANY', 'SOME', 'ESCAPE', 'COLLATE', 'FOR', 'SHARE', 'NOWAIT', 'SKIP', 'LOCKED',
+  'OVER', 'PARTITION', 'WINDOW', 'FILTER', 'WITHIN', 'INTERVAL', 'CURRENT_DATE', 'CURRENT_TIME',
+  'CURRENT_TIMESTAMP', 'LOCALTIMESTAMP', 'CAST', 'ARRAY', 'TOP', 'OF', 'IGNORE', 'REPLACE', 'DUPLICATE',
+  // Column types, so CREATE TABLE definitions and casts name no columns
+  'INT', 'INTEGER', 'BIGINT', 'SMALLINT', 'SERIAL', 'BIGSERIAL', 'TEXT', 'VARCHAR', 'CHAR', 'CHARACTER',
+  'VARYING', 'BOOLEAN', 'BOOL', 'TIMESTAMP', 'TIMESTAMPTZ', 'DATE', 'TIME', 'ZONE', 'UUID', 'JSON', 'JSONB',
+  'NUMERIC', 'DECIMAL', 'REAL', 'DOUBLE', 'PRECISION', 'FLOAT', 'BYTEA', 'BLOB'
+]);
+
+/** Tables the DDL statement after it names */
+const DDL_OPERATIONS = new Set(['create', 'alter', 'drop', 'truncate']);
+
+/** Pseudo-tables of upserts and triggers; their columns belong to the written table */
+const ROW_QUALIFIERS = new Set(['EXCLUDED', 'NEW', 'OLD']);
+
+/**
+ * The operation, tables and columns of a statement, or null when the text
+ * does not start like SQL.
+ */
+export function parseSql(sql: string): SqlStatement | null {
+  const tokens = tokenizeSql(sql);
+  const ctes = new Set<string>();
+  let start = 0;
+  while (tokens[start]?.text === '(') {
+    start++;
+  }
+  if (tokens[start]?.upper === 'WITH') {
+    start = skipCtes(tokens, start + 1, ctes);
+  }
+  const first = tokens[start]?.upper;
+  if (!first || !OPERATIONS.has(first)) {
+    return null;
+  }
+  const operation = first.toLowerCase() as SqlOperation;
+
+  const tables: SqlTableRef[] = [];
+  const aliases = new Map<string, string>();
+  /** Tokens naming tables or aliases; never columns */
+  const consumed = new Set<number>();
+  /** In FROM and JOIN, a name followed by `(` is a table function: `generate_series(1, 10)` */
+  const addTable = (index: number, access: SqlTableRef['access'], source: boolean = false): number => {
+    const name = readName(tokens, index);
+    if (!name || (source && tokens[name.end]?.text === '(') || ctes.has(name.text.toLowerCase())) {
+      return index;
+    }
+    for (let j = index; j < name.end; j++) {
+      consumed.add(j);
+    }
+    const existing = tables.find(table => table.name.toLowerCase() === name.text.toLowerCase());
+    if (existing) {
+      existing.access = existing.access === 'write' ? 'write' : access;
+    } else {
+      tables.push({ name: name.text, access });
+    }
+    // Alias: `users u`, `users AS u`
+    let next = name.end;
+    if (tokens[next]?.upper === 'AS') {
+      next++;
+    }
+    const alias = tokens[next];
+    if (alias && (alias.type === 'quoted' || (alias.type === 'word' && !KEYWORDS.has(alias.upper)))) {
+      aliases.set(alias.text.toLowerCase(), name.text);
+      consumed.add(next);
+      return next + 1;
+    }
+    return name.end;
+  };
+
+  const writes = operation !== 'select';
+  for (let i = 0; i < tokens.length; i++) {
+    const word = tokens[i].upper;
+    const previous = tokens[i - 1]?.upper;
+    if (word === 'FROM') {
+      const access = writes && operation === 'delete' && previous === 'DELETE' ? 'write' : 'read';
+      // FROM a, b
+      let j = addTable(i + 1, access, true);
+      while (tokens[j]?.text === ',' && j > i + 1) {
+        const next = addTable(j + 1, 'read', true);
+        if (next === j + 1) {
+          break;
+        }
+        j = next;
+      }
+    } else if (word === 'JOIN' || (word === 'USING' && tokens[i + 1]?.text !== '(')) {
+      addTable(i + 1, 'read', true);
+    } else if (word === 'REFERENCES') {
+      addTable(i + 1, 'read');
+    } else if (word === 'INTO' || (word === 'UPDATE' && previous !== 'DO' && previous !== 'FOR' && previous !== 'ON')) {
+      addTable(tokens[i + 1]?.upper === 'ONLY' ? i + 2 : i + 1, writes ? 'write' : 'read');
+    } else if ((word === 'TABLE' || (word === 'TRUNCATE' && tokens[i + 1]?.upper !== 'TABLE')) && DDL_OPERATIONS.has(operation)) {
+      let j = i + 1;
+      while (['IF', 'NOT', 'EXISTS', 'ONLY'].includes(tokens[j]?.upper)) {
+        j++;
+      }
+      addTable(j, 'write');
+    } else if (word === 'ON' && operation === 'create' && tokens.slice(start, i).some(token => token.upper === 'INDEX')) {
+      addTable(i + 1, 'write');
+    }
+  }
+
+  const single = tables.length === 1 ? tables[0].name : undefined;
+  const written = tables.find(table => table.access === 'write')?.name;
+  const selectAliases = new Set<string>();
+  const columns: SqlColumnRef[] = [];
+  const addColumn = (name: string, table: string | undefined) => {
+    if (!columns.some(column => column.name.toLowerCase() === name.toLowerCase() && column.table === table)) {
+      columns.push(table ? { name, table } : { name });
+    }
+  };
+
+  for (let i = 0; i < tokens.length; i++) {
+    const token = tokens[i];
+    if (consumed.has(i) || tokens[i - 1]?.text === '.' || tokens[i - 1]?.text === '::') {
+      continue;
+    }
+    if (token.text === '*' && ['SELECT', 'DISTINCT', ','].includes(tokens[i - 1]?.upper) && !inSelectArgs(tokens, i)) {
+      addColumn('*', single);
+      continue;
+    }
+    const isName = token.type === 'quoted' || (token.type === 'word' && !KEYWORDS.has(token.upper));
+    if (!isName && !(token.type === 'word' && ROW_QUALIFIERS.has(token.upper) && tokens[i + 1]?.text === '.')) {
+      continue;
+    }
+    if (tokens[i + 1]?.text === '(' || ctes.has(token.text.toLowerCase())) {
+      continue;
+    }
+    if (tokens[i - 1]?.upper === 'AS') {
+      selectAliases.add(token.text.toLowerCase());
+      continue;
+    }
+
+    // qualifier.column
+    const member = tokens[i + 2];
+    if (tokens[i + 1]?.text === '.' && member && (member.type === 'word' || member.type === 'quoted' || member.text === '*')) {
+      const qualifier = token.text.toLowerCase();
+      const table = ROW_QUALIFIERS.has(token.upper) ? written
+        : aliases.get(qualifier) ?? tables.find(t => t.name.toLowerCase() === qualifier)?.name ?? token.text;
+      if (tokens[i + 3]?.text !== '(') {
+        addColumn(member.text, table);
+      }
+      i += 2;
+      continue;
+    }
+    if (aliases.has(token.text.toLowerCase()) || selectAliases.has(token.text.toLowerCase())) {
+      continue;
+    }
+    addColumn(token.text, single);
+  }
+
+  return { operation, tables, columns };
+}
+
+/**
+ * Skip `name [(columns)] AS [NOT] [MATERIALIZED] (...)` definitions,
+ * recording their names; returns the index of the main statement.
+ */
+function skipCtes(tokens: SqlToken[], start: number, ctes: Set<string>): number {
+  let i = tokens[start]?.upper === 'RECURSIVE' ? start + 1 : start;
+  while (i < tokens.length) {
+    const name = tokens[i];
+    if (!name || (name.type !== 'word' && name.type !== 'quoted')) {
+      return i;
+    }
+    ctes.add(name.text.toLowerCase());
+    i++;
+    if (tokens[i]?.text === '(') {
+      i = closingParen(tokens, i) + 1;
+    }
+    while (tokens[i] && tokens[i].text !== '(') {
+      i++;
+    }
+    i = closingParen(tokens, i) + 1;
+    if (tokens[i]?.text !== ',') {
+      return i;
+    }
+    i++;
+  }
+  return i;
+}
+
+function closingParen(tokens: SqlToken[], open: number): number {
+  let depth = 0;
+  for (let i = open; i < tokens.length; i++) {
+    if (tokens[i].text === '(') {
+      depth++;
+    } else if (tokens[i].text === ')' && --depth === 0) {
+      return i;
+    }
+  }
+  return tokens.length;
+}
+
+/**
+ * `count(*)`: a star inside a call, not a select-all.
+ */
+function inSelectArgs(tokens: SqlToken[], index: number): boolean {
+  return tokens[index - 1]?.text === '(' || tokens[index + 1]?.text === ')';
+}
+
+/**
+ * A possibly qualified name (`schema.table`, `"Order Items"`) at index.
+ */
+function readName(tokens: SqlToken[], index: number): { text: string; end: number } | null {
+  const parts: string[] = [];
+  let i = index;
+  while (true) {
+    const token = tokens[i];
+    if (!token || !(token.type === 'quoted' || (token.type === 'word' && !KEYWORDS.has(token.upper)))) {
+      break;
+    }
+    parts.push(token.text);
+    i++;
+    if (tokens[i]?.text !== '.') {
+      break;
+    }
+    i++;
+  }
+  return parts.length > 0 ? { text: parts.join('.'), end: i } : null;
+}
+
+function tokenizeSql(sql: string): SqlToken[] {
+  const tokens: SqlToken[] = [];
+  const push = (type: SqlToken['type'], text: string) =>
+    tokens.push({ type, text, upper: type === 'word' ? text.toUpperCase() : text });
+  let i = 0;
+  while (i < sql.length) {
+    const c = sql[i];
+    const rest = sql.slice(i);
+    let match: RegExpExecArray | null;
+    if (/\s/.test(c)) {
+      i++;
+    } else if (rest.startsWith('--')) {
+      const end = sql.indexOf('\n', i);
+      i = end === -1 ? sql.length : end;
+    } else if (rest.startsWith('/*')) {
+      const end = sql.indexOf('*/', i + 2);
+      i = end === -1 ? sql.length : end + 2;
+    } else if (c === "'") {
+      let j = i + 1;
+      while (j < sql.length && !(sql[j] === "'" && sql[j + 1] !== "'")) {
+        j += sql[j] === "'" ? 2 : 1;
+      }
+      push('string', sql.slice(i, j + 1));
+      i = j + 1;
+    } else if (c === '"' || c === '`') {
+      const end = sql.indexOf(c, i + 1);
+      const close = end === -1 ? sql.length : end;
+      push('quoted', sql.slice(i + 1, close));
+      i = close + 1;
+    } else if ((match = /^(?:\$\d+|\?|[:@][A-Za-z_]\w*|%[-+ #0-9.]*[a-zA-Z])/.exec(rest))) {
+      push('param', match[0]);
+      i += match[0].length;
+    } else if ((match = /^[A-Za-z_][\w$]*/.exec(rest))) {
+      push('word', match[0]);
+      i += match[0].length;
+    } else if ((match = /^\d+(?:\.\d+)?/.exec(rest))) {
+      push('number', match[0]);
+      i += match[0].length;
+    } else if (rest.startsWith('::')) {
+      push('punct', '::');
+      i += 2;
+    } else {
+      push('punct', c);
+      i++;
+    }
+  }
+  return tokens;
+}
@@ -0,0 +1,44 @@
+/**
+ * String Pool Tests
+ *
+ * Verifies that equal strings intern to one stored copy and that a sweep
+ * keeps exactly the strings still held.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { StringPool, flatten } from './stringPool.js';
+
+describe('StringPool', () => {
+  it('should return one copy of equal strings', () => {
+    const pool = new StringPool();
+    const text = 'package net\nfunc Dial() {}';
+    const first = pool.intern(text.slice(17, 21));
+
+    expect(first).toBe('Dial');
+    expect(pool.intern(['Di', 'al'].join(''))).toBe(first);
+    expect(pool.intern(undefined)).toBeUndefined();
+    expect(pool.internAll(['Dial', 'net'])).toEqual(['Dial', 'net']);
+    expect(pool.size).toBe(2);
+  });
+
+  it('should keep only the strings a sweep visits', () => {
+    const pool = new StringPool();
+    const held = ['net', 'Conn', 'Dial'].map(value => pool.intern(value));
+    pool.intern('Listener');
+
+    pool.sweep(keep => {
+      held.slice(1).forEach(keep);
+      keep(undefined);
+    });
+    expect(pool.size).toBe(2);
+    // Kept strings are still the pooled copies
+    expect(pool.intern('Conn')).toBe(held[1]);
+    pool.clear();
+    expect(pool.size).toBe(0);
+  });
+
+  it('should copy strings without changing them', () => {
+    expect(flatten('')).toBe('');
+    expect(flatten('std/net')).toBe('std/net');
+  });
+});
@@ -0,0 +1,75 @@
+/**
+ * StringPool - one copy of each distinct string an index holds.
+ *
+ * Indexed files repeat the same few strings thousands of times: symbol
+ * kinds, container and package names, Go import paths, the names every
+ * reference points to. Parsers and workers hand out fresh copies of them
+ * for every file, and V8 keeps names sliced from a file's text as views
+ * that hold the whole text alive. intern() returns the pooled copy, made
+ * on first sight as a flat string of its own, so an index keeps one small
+ * string per distinct value and no file contents.
+ *
+ * Unlike indexer/components/StringInterner, nothing is evicted while in
+ * use: the owner calls sweep() with the strings it still holds, e.g. after
+ * many files were replaced, to drop the rest.
+ */
+export class StringPool {
+  private pool = new Map<string, string>();
+
+  intern(value: string): string;
+  intern(value: string | undefined): string | undefined;
+  intern(value: string | undefined): string | undefined {
+    if (value === undefined) {
+      return undefined;
+    }
+    let pooled = this.pool.get(value);
+    if (pooled === undefined) {
+      pooled = flatten(value);
+      this.pool.set(pooled, pooled);
+    }
+    return pooled;
+  }
+
+  /**
+   * Intern each string of an array, reusing the array.
+   */
+  internAll(values: string[] | undefined): string[] | undefined {
+    if (values) {
+      for (let i = 0; i < values.length; i++) {
+        values[i] = this.intern(values[i]);
+      }
+    }
+    return values;
+  }
+
+  /**
+   * Keep only the strings visit() passes to keep. They must be pooled
+   * copies, so what holds them stays shared.
+   */
+  sweep(visit: (keep: (value: string | undefined) => void) => void): void {
+    const kept = new Map<string, string>();
+    visit(value => {
+      if (value !== undefined) {
+        kept.set(value, value);
+      }
+    });
+    this.pool = kept;
+  }
+
+  clear(): void {
+    this.pool.clear();
+  }
+
+  get size(): number {
+    return this.pool.size;
+  }
+}
+
+/**
+ * A copy of value that shares no memory with the string it was sliced
+ * from. Slicing a concatenation flattens it into a new string first, so
+ * the result holds at most its own characters and one more.
+ */
+export function flatten(value: string): string {
+  return (' ' + value).slice(1);
+}
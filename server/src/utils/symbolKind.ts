@@ -0,0 +1,60 @@
+/**
+ * Symbol kind utilities for LSP handlers.
+ *
+ * Maps the indexer's kind strings to LSP SymbolKind values, shared by
+ * workspace/symbol and textDocument/documentSymbol.
+ */
+
+import { SymbolKind } from 'vscode-languageserver/node';
+
+/** Short kind names accepted by kind filters, as in Go declarations */
+const KIND_ALIASES: Record<string, string> = {
+  const: 'constant',
+  var: 'variable',
+  func: 'function'
+};
+
+/**
+ * Normalize a kind given in a query: lower case, aliases expanded.
+ */
+export function normalizeKind(kind: string): string {
+  const lower = kind.trim().toLowerCase();
+  return KIND_ALIASES[lower] ?? lower;
+}
+
+/**
+ * Map internal symbol kind to LSP SymbolKind.
+ */
+export function toLspSymbolKind(kind: string): SymbolKind {
+  switch (kind.toLowerCase()) {
+    case 'file': return SymbolKind.File;
+    case 'module': return SymbolKind.Module;
+    case 'namespace': return SymbolKind.Namespace;
+    case 'package': return SymbolKind.Package;
+    case 'class': return SymbolKind.Class;
+    case 'type': return SymbolKind.Class;
+    case 'method': return SymbolKind.Method;
+    case 'property': return SymbolKind.Property;
+    case 'field': return SymbolKind.Field;
+    case 'constructor': return SymbolKind.Constructor;
+    case 'enum': return SymbolKind.Enum;
+    case 'interface': return SymbolKind.Interface;
+    case 'function': return SymbolKind.Function;
+    case 'closure': return SymbolKind.Function;
+    case 'variable': return SymbolKind.Variable;
+    case 'constant': return SymbolKind.Constant;
+    case 'string': return SymbolKind.String;
+    case 'number': return SymbolKind.Number;
+    case 'boolean': return SymbolKind.Boolean;
+    case 'array': return SymbolKind.Array;
+    case 'object': return SymbolKind.Object;
+    case 'key': return SymbolKind.Key;
+    case 'null': return SymbolKind.Null;
+    case 'enummember': return SymbolKind.EnumMember;
+    case 'struct': return SymbolKind.Struct;
+    case 'event': return SymbolKind.Event;
+    case 'operator': return SymbolKind.Operator;
+    case 'typeparameter': return SymbolKind.TypeParameter;
+    default: return SymbolKind.Variable;
+  }
+}
@@ -2,6 +2,7 @@ import { Worker } from 'worker_threads';
 import * as os from 'os';
 import { IndexedFileResult } from '../types.js';
 import { ILogger, NullLogger } from './Logger.js';
+import { GoBuildContext } from '../indexer/goBuildConstraints.js';
 
 /**
  * Data structure for worker task input.
@@ -10,6 +11,9 @@ export interface WorkerTaskData {
   uri: string;
   content?: string;
   priority?: 'high' | 'normal'; // High priority for self-healing repairs
+  includeGenerated?: boolean; // Index files with a generated-code marker
+  goBuildContext?: GoBuildContext; // Skip Go files excluded for this build target
+  bazel?: boolean; // Tag Go symbols with their Bazel targets, skip Go files no target compiles
 }
 
 /**
@@ -40,6 +44,7 @@ interface WorkerState {
   worker: Worker;
   idle: boolean;
   currentTask?: CurrentTask<IndexedFileResult>;
+  retiring?: boolean; // Terminate after the current task (pool shrink)
 }
 
 /**
@@ -89,6 +94,11 @@ export interface IWorkerPool {
    * Force reset the active tasks counter.
    */
   reset(): void;
+
+  /**
+   * Grow or shrink the pool. Busy workers finish their current task first.
+   */
+  resize(size: number): void;
 }
 
 export class WorkerPool implements IWorkerPool {
@@ -102,6 +112,7 @@ export class WorkerPool implements IWorkerPool {
   private taskTimeoutMs: number = 60000; // 60 second timeout per task
   private activeTasks: number = 0; // Track in-flight tasks for counter validation
   private logger: ILogger;
+  private taskDefaults: Partial<WorkerTaskData> = {};
 
   constructor(workerScriptPath: string, poolSize?: number, logger?: ILogger) {
     this.workerScriptPath = workerScriptPath;
@@ -160,7 +171,9 @@ export class WorkerPool implements IWorkerPool {
       }
       
       this.workers.splice(index, 1);
-      this.createWorker();
+      if (!workerState.retiring) {
+        this.createWorker();
+      }
       
       // Immediately process next queued task with the new worker
       this.processNextTask();
@@ -168,10 +181,70 @@ export class WorkerPool implements IWorkerPool {
   }
 
   private getIdleWorker(): WorkerState | null {
-    return this.workers.find(w => w.idle) || null;
+    return this.workers.find(w => w.idle && !w.retiring) || null;
+  }
+
+  /**
+   * Remove a worker from the pool for good. It is dropped from the list
+   * before terminating so the exit handler does not restart it.
+   */
+  private retireWorker(workerState: WorkerState): void {
+    const index = this.workers.indexOf(workerState);
+    if (index === -1) {
+      return;
+    }
+    this.workers.splice(index, 1);
+    workerState.worker.terminate().catch(error => {
+      this.logger.error(`[WorkerPool] Error terminating worker:`, error);
+    });
   }
 
-  async runTask(taskData: WorkerTaskData): Promise<IndexedFileResult> {
+  resize(size: number): void {
+    const target = Math.max(1, Math.floor(size));
+    if (target === this.poolSize) {
+      return;
+    }
+    this.logger.info(`[WorkerPool] Resizing pool from ${this.poolSize} to ${target} workers`);
+    this.poolSize = target;
+
+    // Un-retire busy workers first, then spawn new ones
+    for (const workerState of this.workers) {
+      if (workerState.retiring && this.workers.filter(w => !w.retiring).length < target) {
+        workerState.retiring = false;
+      }
+    }
+    while (this.workers.length < target) {
+      this.createWorker();
+      this.processNextTask();
+    }
+
+    // Shrink: idle workers go now, busy ones after their current task
+    let excess = this.workers.filter(w => !w.retiring).length - target;
+    for (const workerState of [...this.workers].sort((a, b) => Number(b.idle) - Number(a.idle))) {
+      if (excess <= 0) {
+        break;
+      }
+      if (workerState.retiring) {
+        continue;
+      }
+      excess--;
+      if (workerState.idle) {
+        this.retireWorker(workerState);
+      } else {
+        workerState.retiring = true;
+      }
+    }
+  }
+
+  /**
+   * Options applied to every task that does not set them itself.
+   */
+  setTaskDefaults(defaults: Partial<WorkerTaskData>): void {
+    this.taskDefaults = { ...defaults };
+  }
+
+  async runTask(task: WorkerTaskData): Promise<IndexedFileResult> {
+    const taskData: WorkerTaskData = { ...this.taskDefaults, ...task };
     // Increment active tasks counter IMMEDIATELY when task is submitted
     this.activeTasks++;
     
@@ -235,6 +308,9 @@ export class WorkerPool implements IWorkerPool {
         reject(new Error(result.error || 'Worker task failed'));
       }
 
+      if (workerState.retiring) {
+        this.retireWorker(workerState);
+      }
       this.processNextTask();
     };
 
@@ -0,0 +1,53 @@
+/**
+ * WorkspaceRoots Tests
+ *
+ * Verifies root naming, the deepest-root lookup and the per-root summary.
+ */
+
+import { describe, it, expect, beforeEach } from 'vitest';
+import * as path from 'path';
+import { WorkspaceRoots } from './workspaceRoots.js';
+
+const repos = path.join(path.sep, 'repos');
+
+describe('WorkspaceRoots', () => {
+  let roots: WorkspaceRoots;
+
+  beforeEach(() => {
+    roots = new WorkspaceRoots();
+  });
+
+  it('should name roots after their directory, uniquely', () => {
+    roots.add(path.join(repos, 'svc-a', 'api'));
+    roots.add(path.join(repos, 'svc-b', 'api'));
+    roots.add(path.join(repos, 'web'), 'frontend');
+    roots.add(path.join(repos, 'svc-a', 'api'), 'ignored');
+
+    expect(roots.list().map(r => r.name)).toEqual(['api', 'api-2', 'frontend']);
+    expect(roots.remove(path.join(repos, 'web'))).toBe(true);
+    expect(roots.remove(path.join(repos, 'web'))).toBe(false);
+    expect(roots.size).toBe(2);
+  });
+
+  it('should assign files to the deepest root and summarize them', () => {
+    const svc = path.join(repos, 'svc');
+    const vendored = path.join(svc, 'third_party', 'lib');
+    roots.add(svc);
+    roots.add(vendored);
+    roots.markIndexed(svc, 1000);
+
+    expect(roots.rootOf(path.join(svc, 'main.go'))?.name).toBe('svc');
+    expect(roots.rootOf(path.join(vendored, 'lib.go'))?.name).toBe('lib');
+    expect(roots.rootOf(path.join(repos, 'svc-old', 'main.go'))).toBeUndefined();
+
+    expect(roots.summarize([
+      { uri: path.join(svc, 'main.go'), symbols: 3 },
+      { uri: path.join(svc, 'server.go'), symbols: 5 },
+      { uri: path.join(vendored, 'lib.go'), symbols: 2 },
+      { uri: path.join(repos, 'elsewhere.go'), symbols: 9 }
+    ])).toEqual([
+      { path: svc, name: 'svc', indexedAt: 1000, files: 2, symbols: 8 },
+      { path: vendored, name: 'lib', files: 1, symbols: 2 }
+    ]);
+  });
+});
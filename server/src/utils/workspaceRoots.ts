@@ -0,0 +1,132 @@
+import * as path from 'path';
+
+/**
+ * Workspace Roots - the directories (repositories, services) indexed into
+ * one logical workspace.
+ *
+ * A file belongs to the deepest root containing it, so a repository
+ * checked out inside another keeps its own files. Roots get a display
+ * name, the directory name by default, made unique with a `-2`, `-3`, ...
+ * suffix when two repositories share one (`svc-a/api`, `svc-b/api`).
+ */
+
+export interface WorkspaceRoot {
+  /** Absolute path */
+  path: string;
+  /** Display name, unique within the workspace */
+  name: string;
+  /** When the root was last indexed (ms since epoch) */
+  indexedAt?: number;
+}
+
+export interface WorkspaceRootSummary extends WorkspaceRoot {
+  files: number;
+  symbols: number;
+}
+
+export class WorkspaceRoots {
+  private roots: WorkspaceRoot[] = [];
+
+  /**
+   * Add a root; returns the existing one if the path is already a root.
+   */
+  add(rootPath: string, name?: string): WorkspaceRoot {
+    const resolved = path.resolve(rootPath);
+    const existing = this.get(resolved);
+    if (existing) {
+      return existing;
+    }
+    const root: WorkspaceRoot = { path: resolved, name: this.uniqueName(name || path.basename(resolved) || resolved) };
+    this.roots.push(root);
+    return root;
+  }
+
+  /**
+   * Remove a root; false if it was not one.
+   */
+  remove(rootPath: string): boolean {
+    const resolved = path.resolve(rootPath);
+    const before = this.roots.length;
+    this.roots = this.roots.filter(root => root.path !== resolved);
+    return this.roots.length < before;
+  }
+
+  get(rootPath: string): WorkspaceRoot | undefined {
+    const resolved = path.resolve(rootPath);
+    return this.roots.find(root => root.path === resolved);
+  }
+
+  markIndexed(rootPath: string, indexedAt: number = Date.now()): void {
+    const root = this.get(rootPath);
+    if (root) {
+      root.indexedAt = indexedAt;
+    }
+  }
+
+  /** Roots in the order they were added */
+  list(): WorkspaceRoot[] {
+    return this.roots.map(root => ({ ...root }));
+  }
+
+  paths(): string[] {
+    return this.roots.map(root => root.path);
+  }
+
+  get size(): number {
+    return this.roots.length;
+  }
+
+  clear(): void {
+    this.roots = [];
+  }
+
+  /**
+   * The deepest root containing filePath, if any.
+   */
+  rootOf(filePath: string): WorkspaceRoot | undefined {
+    return findRoot(this.roots, filePath);
+  }
+
+  /**
+   * Files and symbols per root, in root order; files outside every root
+   * are left out.
+   */
+  summarize(files: Iterable<{ uri: string; symbols: number }>): WorkspaceRootSummary[] {
+    const summaries = new Map(this.roots.map(root => [root.path, { ...root, files: 0, symbols: 0 }]));
+    for (const file of files) {
+      const root = this.rootOf(file.uri);
+      const summary = root && summaries.get(root.path);
+      if (summary) {
+        summary.files++;
+        summary.symbols += file.symbols;
+      }
+    }
+    return [...summaries.values()];
+  }
+
+  private uniqueName(base: string): string {
+    let name = base;
+    for (let n = 2; this.roots.some(root => root.name === name); n++) {
+      name = `${base}-${n}`;
+    }
+    return name;
+  }
+}
+
+/**
+ * The deepest of roots containing filePath, if any.
+ */
+export function findRoot<T extends { path: string }>(roots: Iterable<T>, filePath: string): T | undefined {
+  let found: T | undefined;
+  for (const root of roots) {
+    if (isWithinRoot(root.path, filePath) && (!found || root.path.length > found.path.length)) {
+      found = root;
+    }
+  }
+  return found;
+}
+
+export function isWithinRoot(root: string, filePath: string): boolean {
+  const relative = path.relative(root, filePath);
+  return !relative.startsWith('..') && !path.isAbsolute(relative);
+}
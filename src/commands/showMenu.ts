@@ -23,6 +23,11 @@ export async function showQuickMenu(
       description: 'View forensic traces of recent searches',
       action: 'debug'
     },
+    {
+      label: '$(dashboard) Benchmark Indexing',
+      description: 'Index the workspace repeatedly and report files/s and MB/s',
+      action: 'benchmark'
+    },
     {
       label: '$(refresh) Rebuild Index',
       description: 'Clear cache and re-index entire workspace',
@@ -38,10 +43,130 @@ export async function showQuickMenu(
       description: 'Scan for unused exports (Beta)',
       action: 'deadCode'
     },
+    {
+      label: '$(output) Dead Code Report',
+      description: 'Unreferenced functions, types and variables',
+      action: 'deadCodeReport'
+    },
     {
       label: '$(list-tree) Inspect Index',
       description: 'Browse indexed folders and symbols',
       action: 'inspect'
+    },
+    {
+      label: '$(export) Export LSIF Dump',
+      description: 'Write definitions, references and hovers as LSIF',
+      action: 'exportLsif'
+    },
+    {
+      label: '$(shield) Export Findings as SARIF',
+      description: 'Dead code, policies, deprecations and complex functions for code scanning',
+      action: 'exportSarif'
+    },
+    {
+      label: '$(tag) Export Tags File',
+      description: 'Write a ctags or etags file for other editors',
+      action: 'exportTags'
+    },
+    {
+      label: '$(json) Export JSON Lines',
+      description: 'Stream symbols, references and imports, one record per line',
+      action: 'exportJsonLines'
+    },
+    {
+      label: '$(circuit-board) Export Symbol Graph',
+      description: 'Symbols, calls, references and imports for Neo4j (Cypher or CSV)',
+      action: 'exportGraph'
+    },
+    {
+      label: '$(graph) Export Usage Heatmap',
+      description: 'References, git churn and complexity per directory as CSV or JSON',
+      action: 'exportHeatmap'
+    },
+    {
+      label: '$(file-binary) Export Binary Index',
+      description: 'Compact .sidx static index that loads lazily',
+      action: 'exportBinaryIndex'
+    },
+    {
+      label: '$(split-horizontal) Build Index Shards',
+      description: 'One binary index per module or directory',
+      action: 'buildShards'
+    },
+    {
+      label: '$(merge) Merge Index Shards',
+      description: 'Combine shards into one binary index',
+      action: 'mergeShards'
+    },
+    {
+      label: '$(type-hierarchy) Show Call Graph',
+      description: 'Callers and callees of the function under the cursor',
+      action: 'callGraph'
+    },
+    {
+      label: '$(references) Show Package Dependency Graph',
+      description: 'Package imports, import cycles and layering violations',
+      action: 'dependencyGraph'
+    },
+    {
+      label: '$(git-commit) Show Symbol History',
+      description: 'Last commit, author and age of a symbol',
+      action: 'symbolHistory'
+    },
+    {
+      label: '$(history) Show Recently Changed Symbols',
+      description: 'Symbols changed in the last N days',
+      action: 'changedSymbols'
+    },
+    {
+      label: '$(git-compare) Compare API Between Revisions',
+      description: 'Added, removed and changed exported symbols',
+      action: 'compareRevisions'
+    },
+    {
+      label: '$(symbol-interface) Show Module API Surface',
+      description: 'Exported API of a Go module, one line per feature',
+      action: 'showApiSurface'
+    },
+    {
+      label: '$(versions) Check Semantic Version Compatibility',
+      description: 'Major, minor or patch: breaking API changes since a release',
+      action: 'checkSemver'
+    },
+    {
+      label: '$(checklist) Check Policies',
+      description: 'Doc comment, import and complexity rules, for CI gates',
+      action: 'checkPolicies'
+    },
+    {
+      label: '$(sparkle) Ask (Natural-Language Search)',
+      description: 'Find functions and types by describing them',
+      action: 'ask'
+    },
+    {
+      label: '$(symbol-structure) Describe Type',
+      description: 'Fields, methods, embedded and promoted members',
+      action: 'describeType'
+    },
+    {
+      label: '$(search) Search Comments and Strings',
+      description: 'Find a phrase in comments, doc comments or string literals',
+      action: 'searchText'
+    },
+    {
+      label: '$(regex) Grep Workspace',
+      description: 'Regular expression search, narrowed by a trigram index',
+      action: 'grep'
+    },
+    {
+      label: '$(symbol-method) Search by Signature',
+      description: 'Find functions by parameter and result types or generic constraints',
+      action: 'searchSignatures'
+    },
+    {
+      label: '$(filter) Structured Query',
+      description: 'Query definitions by kind, receiver, file, export status and more',
+      action: 'structuredQuery'
     }
   ];
 
@@ -61,6 +186,9 @@ export async function showQuickMenu(
     case 'debug':
       await vscode.commands.executeCommand('smart-indexer.showDebugInfo');
       break;
+    case 'benchmark':
+      await vscode.commands.executeCommand('smart-indexer.benchmark');
+      break;
     case 'rebuild':
       await vscode.commands.executeCommand('smart-indexer.rebuildIndex');
       break;
@@ -70,9 +198,81 @@ export async function showQuickMenu(
     case 'deadCode':
       await vscode.commands.executeCommand('smart-indexer.findDeadCode');
       break;
+    case 'deadCodeReport':
+      await vscode.commands.executeCommand('smart-indexer.deadCodeReport');
+      break;
     case 'inspect':
       await vscode.commands.executeCommand('smart-indexer.inspectIndex');
       break;
+    case 'exportLsif':
+      await vscode.commands.executeCommand('smart-indexer.exportLsif');
+      break;
+    case 'exportSarif':
+      await vscode.commands.executeCommand('smart-indexer.exportSarif');
+      break;
+    case 'exportTags':
+      await vscode.commands.executeCommand('smart-indexer.exportTags');
+      break;
+    case 'exportJsonLines':
+      await vscode.commands.executeCommand('smart-indexer.exportJsonLines');
+      break;
+    case 'exportGraph':
+      await vscode.commands.executeCommand('smart-indexer.exportGraph');
+      break;
+    case 'exportHeatmap':
+      await vscode.commands.executeCommand('smart-indexer.exportHeatmap');
+      break;
+    case 'exportBinaryIndex':
+      await vscode.commands.executeCommand('smart-indexer.exportBinaryIndex');
+      break;
+    case 'buildShards':
+      await vscode.commands.executeCommand('smart-indexer.buildShards');
+      break;
+    case 'mergeShards':
+      await vscode.commands.executeCommand('smart-indexer.mergeShards');
+      break;
+    case 'callGraph':
+      await vscode.commands.executeCommand('smart-indexer.showCallGraph');
+      break;
+    case 'dependencyGraph':
+      await vscode.commands.executeCommand('smart-indexer.showDependencyGraph');
+      break;
+    case 'symbolHistory':
+      await vscode.commands.executeCommand('smart-indexer.showSymbolHistory');
+      break;
+    case 'changedSymbols':
+      await vscode.commands.executeCommand('smart-indexer.showChangedSymbols');
+      break;
+    case 'compareRevisions':
+      await vscode.commands.executeCommand('smart-indexer.compareRevisions');
+      break;
+    case 'showApiSurface':
+      await vscode.commands.executeCommand('smart-indexer.showApiSurface');
+      break;
+    case 'checkSemver':
+      await vscode.commands.executeCommand('smart-indexer.checkSemver');
+      break;
+    case 'checkPolicies':
+      await vscode.commands.executeCommand('smart-indexer.checkPolicies');
+      break;
+    case 'ask':
+      await vscode.commands.executeCommand('smart-indexer.ask');
+      break;
+    case 'describeType':
+      await vscode.commands.executeCommand('smart-indexer.describeType');
+      break;
+    case 'searchText':
+      await vscode.commands.executeCommand('smart-indexer.searchText');
+      break;
+    case 'grep':
+      await vscode.commands.executeCommand('smart-indexer.grep');
+      break;
+    case 'searchSignatures':
+      await vscode.commands.executeCommand('smart-indexer.searchSignatures');
+      break;
+    case 'structuredQuery':
+      await vscode.commands.executeCommand('smart-indexer.structuredQuery');
+      break;
   }
 }
 
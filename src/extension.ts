@@ -56,6 +56,43 @@ async function ensureGitIgnoreEntry(workspaceRoot: string, cacheDir: string): Pr
   }
 }
 
+/**
+ * A setting's value if the user set it (globally, for the workspace or the
+ * folder), else undefined so the workspace config file and the server's
+ * defaults apply.
+ */
+function explicitSetting<T>(config: vscode.WorkspaceConfiguration, key: string): T | undefined {
+  const inspected = config.inspect<T>(key);
+  return inspected?.workspaceFolderValue ?? inspected?.workspaceValue ?? inspected?.globalValue;
+}
+
+/**
+ * An explicit setting that runs programs or opens the network. In an
+ * untrusted workspace only the user's own value counts, not one the
+ * repository brings in .vscode/settings.json.
+ */
+function trustedSetting<T>(config: vscode.WorkspaceConfiguration, key: string): T | undefined {
+  return vscode.workspace.isTrusted ? explicitSetting<T>(config, key) : config.inspect<T>(key)?.globalValue;
+}
+
+/**
+ * Open a file at a zero-based server location and center it.
+ */
+async function revealLocation(location: { uri: string; line: number; character: number }): Promise<void> {
+  const document = await vscode.workspace.openTextDocument(vscode.Uri.file(location.uri));
+  const editor = await vscode.window.showTextDocument(document);
+  const position = new vscode.Position(location.line, location.character);
+  editor.selection = new vscode.Selection(position, position);
+  editor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+}
+
+function formatAge(days: number): string {
+  if (days === 0) {
+    return 'today';
+  }
+  return days === 1 ? '1 day ago' : `${days} days ago`;
+}
+
 export async function activate(context: vscode.ExtensionContext) {
   logChannel = vscode.window.createOutputChannel('Smart Indexer', { log: true });
   context.subscriptions.push(logChannel);
@@ -95,21 +132,132 @@ export async function activate(context: vscode.ExtensionContext) {
     await ensureGitIgnoreEntry(workspaceFolders[0].uri.fsPath, cacheDirectory);
   }
   
+  // Only settings set explicitly, so the workspace config file
+  // (smart-indexer.yaml) supplies the rest
   const initializationOptions = {
-    cacheDirectory,
-    enableGitIntegration: config.get('enableGitIntegration', true),
-    excludePatterns: config.get('excludePatterns', []),
-    maxIndexedFileSize: config.get('maxIndexedFileSize', 1048576),
-    maxFileSizeMB: config.get('maxFileSizeMB', 50),
-    maxCacheSizeMB: config.get('maxCacheSizeMB', 500),
-    maxConcurrentIndexJobs: config.get('maxConcurrentIndexJobs', 4),
-    enableBackgroundIndex: config.get('enableBackgroundIndex', true),
-    textIndexingEnabled: config.get('textIndexing.enabled', false),
-    staticIndexEnabled: config.get('staticIndex.enabled', false),
-    staticIndexPath: config.get('staticIndex.path', ''),
-    maxConcurrentWorkers: config.get('indexing.maxConcurrentWorkers', 4),
-    batchSize: config.get('indexing.batchSize', 50),
-    useFolderHashing: config.get('indexing.useFolderHashing', true)
+    cacheDirectory: explicitSetting(config, 'cacheDirectory'),
+    enableGitIntegration: explicitSetting(config, 'enableGitIntegration'),
+    excludePatterns: explicitSetting(config, 'excludePatterns'),
+    includePatterns: explicitSetting(config, 'includePatterns'),
+    languages: explicitSetting(config, 'languages'),
+    profile: explicitSetting(config, 'profile') || undefined,
+    // User settings only: a repository must not trust its own config file
+    trustConfigFile: vscode.workspace.isTrusted && config.inspect<boolean>('trustConfigFile')?.globalValue === true,
+    maxIndexedFileSize: explicitSetting(config, 'maxIndexedFileSize'),
+    maxFileSizeMB: explicitSetting(config, 'maxFileSizeMB'),
+    maxCacheSizeMB: explicitSetting(config, 'maxCacheSizeMB'),
+    maxConcurrentIndexJobs: explicitSetting(config, 'maxConcurrentIndexJobs'),
+    enableBackgroundIndex: explicitSetting(config, 'enableBackgroundIndex'),
+    textIndexingEnabled: explicitSetting(config, 'textIndexing.enabled'),
+    staticIndexEnabled: explicitSetting(config, 'staticIndex.enabled'),
+    staticIndexPath: explicitSetting(config, 'staticIndex.path'),
+    staticIndexCompression: explicitSetting(config, 'staticIndex.compression'),
+    logLevel: explicitSetting(config, 'logLevel'),
+    logFormat: explicitSetting(config, 'logFormat'),
+    maxConcurrentWorkers: explicitSetting(config, 'indexing.maxConcurrentWorkers'),
+    batchSize: explicitSetting(config, 'indexing.batchSize'),
+    useFolderHashing: explicitSetting(config, 'indexing.useFolderHashing'),
+    indexingTimeoutMinutes: explicitSetting(config, 'indexing.timeoutMinutes'),
+    queryServer: {
+      enabled: explicitSetting(config, 'queryServer.enabled'),
+      port: explicitSetting(config, 'queryServer.port'),
+      host: explicitSetting(config, 'queryServer.host'),
+      grpcPort: explicitSetting(config, 'queryServer.grpcPort'),
+      accessRules: explicitSetting(config, 'queryServer.accessRules'),
+      tls: explicitSetting(config, 'queryServer.tls'),
+      pprof: explicitSetting(config, 'queryServer.pprof')
+    },
+    extractors: trustedSetting(config, 'extractors'),
+    deadCode: {
+      allowlist: explicitSetting(config, 'deadCode.allowlist')
+    },
+    dependencyRules: explicitSetting(config, 'dependencyRules'),
+    policies: explicitSetting(config, 'policies'),
+    embeddings: {
+      enabled: explicitSetting(config, 'embeddings.enabled'),
+      provider: explicitSetting(config, 'embeddings.provider'),
+      endpoint: explicitSetting(config, 'embeddings.endpoint'),
+      model: explicitSetting(config, 'embeddings.model'),
+      apiKeyEnv: explicitSetting(config, 'embeddings.apiKeyEnv'),
+      command: explicitSetting(config, 'embeddings.command'),
+      args: explicitSetting(config, 'embeddings.args'),
+      batchSize: explicitSetting(config, 'embeddings.batchSize'),
+      maxChunkChars: explicitSetting(config, 'embeddings.maxChunkChars'),
+      hnsw: {
+        enabled: explicitSetting(config, 'embeddings.hnsw.enabled'),
+        m: explicitSetting(config, 'embeddings.hnsw.m'),
+        efConstruction: explicitSetting(config, 'embeddings.hnsw.efConstruction'),
+        efSearch: explicitSetting(config, 'embeddings.hnsw.efSearch')
+      }
+    },
+    summaries: {
+      enabled: explicitSetting(config, 'summaries.enabled'),
+      provider: explicitSetting(config, 'summaries.provider'),
+      endpoint: trustedSetting(config, 'summaries.endpoint'),
+      model: explicitSetting(config, 'summaries.model'),
+      // User settings only: which secret is sent is not up to the repository
+      apiKeyEnv: config.inspect<string>('summaries.apiKeyEnv')?.globalValue,
+      command: trustedSetting(config, 'summaries.command'),
+      args: trustedSetting(config, 'summaries.args'),
+      minComplexity: explicitSetting(config, 'summaries.minComplexity'),
+      minLines: explicitSetting(config, 'summaries.minLines'),
+      maxSourceChars: explicitSetting(config, 'summaries.maxSourceChars'),
+      concurrency: explicitSetting(config, 'summaries.concurrency')
+    },
+    goIncludeDependencies: explicitSetting(config, 'go.includeDependencies'),
+    goBuild: {
+      tags: explicitSetting(config, 'go.buildTags'),
+      goos: explicitSetting(config, 'go.goos'),
+      goarch: explicitSetting(config, 'go.goarch'),
+      allConfigs: explicitSetting(config, 'go.allConfigs')
+    },
+    bazel: {
+      enabled: explicitSetting(config, 'bazel.enabled'),
+      generatedSources: explicitSetting(config, 'bazel.generatedSources')
+    },
+    ignore: {
+      gitignore: explicitSetting(config, 'ignore.gitignore'),
+      vendor: explicitSetting(config, 'ignore.vendor'),
+      testdata: explicitSetting(config, 'ignore.testdata'),
+      generated: explicitSetting(config, 'ignore.generated'),
+      patterns: explicitSetting(config, 'ignore.patterns')
+    },
+    search: {
+      excludeTags: explicitSetting(config, 'search.excludeTags'),
+      cacheSize: explicitSetting(config, 'search.cacheSize'),
+      scopes: explicitSetting(config, 'search.scopes'),
+      queries: explicitSetting(config, 'search.queries')
+    },
+    remoteIndex: {
+      url: trustedSetting(config, 'remoteIndex.url'),
+      pullOnStartup: explicitSetting(config, 'remoteIndex.pullOnStartup')
+    },
+    encryption: {
+      enabled: explicitSetting(config, 'encryption.enabled'),
+      provider: explicitSetting(config, 'encryption.provider'),
+      keyEnv: explicitSetting(config, 'encryption.keyEnv'),
+      command: trustedSetting(config, 'encryption.command'),
+      args: trustedSetting(config, 'encryption.args'),
+      allowPlaintext: explicitSetting(config, 'encryption.allowPlaintext')
+    },
+    commentMarkers: {
+      tags: explicitSetting(config, 'commentMarkers.tags'),
+      patterns: explicitSetting(config, 'commentMarkers.patterns')
+    },
+    httpRoutes: {
+      enabled: explicitSetting(config, 'httpRoutes.enabled'),
+      frameworks: explicitSetting(config, 'httpRoutes.frameworks')
+    },
+    coverage: {
+      profiles: explicitSetting(config, 'coverage.profiles')
+    },
+    throttle: {
+      mode: explicitSetting(config, 'throttle.mode'),
+      maxWorkers: explicitSetting(config, 'throttle.maxWorkers'),
+      fileDelayMs: explicitSetting(config, 'throttle.fileDelayMs'),
+      maxMBPerSecond: explicitSetting(config, 'throttle.maxMBPerSecond'),
+      pauseOnBattery: explicitSetting(config, 'throttle.pauseOnBattery')
+    }
   };
 
   logChannel.info('[Client] Initialization options:', initializationOptions);
@@ -120,10 +268,11 @@ export async function activate(context: vscode.ExtensionContext) {
       { scheme: 'file', language: 'typescript' },
       { scheme: 'file', language: 'javascript' },
       { scheme: 'file', language: 'typescriptreact' },
-      { scheme: 'file', language: 'javascriptreact' }
+      { scheme: 'file', language: 'javascriptreact' },
+      { scheme: 'file', language: 'go' }
     ],
     synchronize: {
-      fileEvents: vscode.workspace.createFileSystemWatcher('**/*.{ts,tsx,js,jsx,mts,cts,mjs,cjs}')
+      fileEvents: vscode.workspace.createFileSystemWatcher('**/*.{ts,tsx,js,jsx,mts,cts,mjs,cjs,go}')
     },
     initializationOptions,
     outputChannel: logChannel,
@@ -277,7 +426,12 @@ export async function activate(context: vscode.ExtensionContext) {
       smartStatusBar.updateProgress(progress);
     });
     logChannel.info('[Client] Registered progress notification listener');
-    
+
+    // Listen for live index updates (file watcher re-index/removal)
+    client.onNotification('smart-indexer/indexUpdated', (event: { uri: string; change: string; trigger: string; durationMs: number }) => {
+      logChannel.debug(`[Client] Index ${event.change}: ${event.uri} (${event.trigger}, ${event.durationMs}ms)`);
+    });
+
     if (mode === 'hybrid') {
       logChannel.info('[Client] Hybrid mode active - using middleware fallback strategy (Native TS → Smart Indexer)');
     } else {
@@ -599,6 +753,2465 @@ ${profilingInfo}
     })
   );
 
+  // Command: Report unreferenced functions, types and variables
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.deadCodeReport', async () => {
+      logChannel.info('[Client] ========== DEAD CODE REPORT COMMAND ==========');
+
+      const scope = await vscode.window.showQuickPick(
+        [
+          { label: 'All symbols', value: true },
+          { label: 'Unexported symbols only', description: 'skip exported APIs that other modules may use', value: false }
+        ],
+        { title: 'Dead Code Report', placeHolder: 'Symbols to report' }
+      );
+      if (!scope) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/deadCodeReport', {
+          format: 'text',
+          includeExported: scope.value
+        }) as any;
+        logChannel.info(
+          `[Client] Dead code report: ${result.count} unreferenced symbols, ` +
+          `${result.checkedSymbols} checked in ${result.analyzedFiles} files (${result.duration}ms)`
+        );
+
+        if (result.count === 0) {
+          vscode.window.showInformationMessage('No unreferenced symbols found.');
+          return;
+        }
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: 'plaintext'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+      } catch (error) {
+        logChannel.error('[Client] Failed to build dead code report:', error);
+        vscode.window.showErrorMessage(`Failed to build dead code report: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export LSIF dump
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportLsif', async () => {
+      logChannel.info('[Client] ========== EXPORT LSIF COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/exportLsif', {}) as any;
+        logChannel.info(
+          `[Client] LSIF export complete: ${result.documents} documents, ${result.definitions} definitions, ` +
+          `${result.references} references in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `LSIF dump written: ${result.documents} documents, ${result.references} references`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export LSIF:', error);
+        vscode.window.showErrorMessage(`Failed to export LSIF: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export analysis findings as SARIF for code scanning
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportSarif', async () => {
+      logChannel.info('[Client] ========== EXPORT SARIF COMMAND ==========');
+      const picked = await vscode.window.showQuickPick(
+        [
+          { label: 'Dead code', description: 'unreferenced symbols', value: 'dead-code', picked: true },
+          { label: 'Policies', description: 'violations of smartIndexer.policies', value: 'policies', picked: true },
+          { label: 'Deprecations', description: 'uses of deprecated symbols', value: 'deprecations', picked: true },
+          { label: 'Complex functions', description: 'complexity of 25 or more', value: 'metrics', picked: true }
+        ],
+        { title: 'Export SARIF', placeHolder: 'Analyses to export', canPickMany: true }
+      );
+      if (!picked || picked.length === 0) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/exportSarif', {
+          analyses: picked.map(item => item.value)
+        }) as any;
+        logChannel.info(
+          `[Client] SARIF export complete: ${result.results} results in ${result.duration}ms` +
+          `${result.skipped.length > 0 ? `, skipped ${result.skipped.join(', ')}` : ''}`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `SARIF log written: ${result.results} results from ${result.runs.length} analyses`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export SARIF:', error);
+        vscode.window.showErrorMessage(`Failed to export SARIF: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export ctags/etags file
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportTags', async () => {
+      logChannel.info('[Client] ========== EXPORT TAGS COMMAND ==========');
+      const format = await vscode.window.showQuickPick(
+        [
+          { label: 'ctags', description: 'tags file for Vim, Neovim, Sublime Text', value: 'ctags' },
+          { label: 'etags', description: 'TAGS file for Emacs', value: 'etags' }
+        ],
+        { title: 'Export Tags', placeHolder: 'Tags file format' }
+      );
+      if (!format) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/exportTags', { format: format.value }) as any;
+        logChannel.info(
+          `[Client] Tags export complete: ${result.tags} tags from ${result.files} files in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `${format.label} file written: ${result.tags} tags from ${result.files} files`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export tags:', error);
+        vscode.window.showErrorMessage(`Failed to export tags: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export the index as JSON Lines
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportJsonLines', async () => {
+      logChannel.info('[Client] ========== EXPORT JSON LINES COMMAND ==========');
+      const selected = await vscode.window.showQuickPick(
+        [
+          { label: 'file', description: 'One record per indexed file', value: 'file', picked: true },
+          { label: 'symbol', description: 'Definitions', value: 'symbol', picked: true },
+          { label: 'reference', description: 'Usages', value: 'reference' },
+          { label: 'import', description: 'Import statements', value: 'import' }
+        ],
+        { title: 'Export JSON Lines', placeHolder: 'Record types to export', canPickMany: true }
+      );
+      if (!selected || selected.length === 0) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/exportJsonLines', {
+          records: selected.map(item => item.value)
+        }) as any;
+        logChannel.info(
+          `[Client] JSON Lines export complete: ${result.records} records from ${result.files} files in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `JSON Lines written: ${result.records} records from ${result.files} files`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export JSON Lines:', error);
+        vscode.window.showErrorMessage(`Failed to export JSON Lines: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export the symbol graph for Neo4j
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportGraph', async () => {
+      logChannel.info('[Client] ========== EXPORT GRAPH COMMAND ==========');
+      const format = await vscode.window.showQuickPick(
+        [
+          { label: 'Cypher', description: 'MERGE statements for cypher-shell -f', value: 'cypher' },
+          { label: 'CSV', description: 'Bulk-import files for neo4j-admin database import', value: 'csv' }
+        ],
+        { title: 'Export Symbol Graph', placeHolder: 'Graph format' }
+      );
+      if (!format) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/exportGraph', { format: format.value }) as any;
+        logChannel.info(
+          `[Client] Graph export complete: ${result.totalNodes} nodes, ${result.totalRelationships} relationships in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `Symbol graph written: ${result.totalNodes} nodes, ${result.totalRelationships} relationships`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.importScript ?? result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export graph:', error);
+        vscode.window.showErrorMessage(`Failed to export graph: ${error}`);
+      }
+    })
+  );
+
+  // Command: Index the workspace repeatedly and report throughput
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.benchmark', async () => {
+      logChannel.info('[Client] ========== BENCHMARK COMMAND ==========');
+      const mode = await vscode.window.showQuickPick(
+        [
+          { label: 'Benchmark', description: 'Five timed runs after one warm-up', value: {} },
+          { label: 'Benchmark with CPU Profile', description: 'Also profile the timed runs (.cpuprofile)', value: { cpuProfile: true } },
+          { label: 'Benchmark with Heap Profile', description: 'Also sample allocations of the timed runs (.heapprofile)', value: { heapProfile: true } }
+        ],
+        { title: 'Benchmark Indexing', placeHolder: 'Indexes the workspace into a separate index; the live one is left alone' }
+      );
+      if (!mode) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/benchmark', mode.value) as any;
+        logChannel.info(`[Client] Benchmark complete:\n${result.summary}`);
+
+        const profile = result.cpuProfile ?? result.heapProfile;
+        const action = await vscode.window.showInformationMessage(
+          `Indexing: ${result.filesPerSecond.toFixed(0)} files/s, ${result.megabytesPerSecond.toFixed(2)} MB/s ` +
+          `(median of ${result.runs.length} runs, ${result.files} files)`,
+          ...(profile ? ['Open Profile'] : [])
+        );
+        if (action === 'Open Profile') {
+          await vscode.commands.executeCommand('vscode.open', vscode.Uri.file(profile));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to run benchmark:', error);
+        vscode.window.showErrorMessage(`Failed to run benchmark: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export references, churn and complexity per directory
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportHeatmap', async () => {
+      logChannel.info('[Client] ========== EXPORT HEATMAP COMMAND ==========');
+      const format = await vscode.window.showQuickPick(
+        [
+          { label: 'CSV', description: 'One row per directory with a parent column', value: 'csv' },
+          { label: 'JSON', description: 'Nested directories for treemaps (d3.hierarchy)', value: 'json' }
+        ],
+        { title: 'Export Usage Heatmap', placeHolder: 'Heatmap format' }
+      );
+      if (!format) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/exportHeatmap', { format: format.value }) as any;
+        logChannel.info(
+          `[Client] Heatmap export complete: ${result.entries} directories from ${result.filesScanned} files in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `Heatmap written: ${result.entries} directories from ${result.filesScanned} files`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export heatmap:', error);
+        vscode.window.showErrorMessage(`Failed to export heatmap: ${error}`);
+      }
+    })
+  );
+
+  // Command: Export the index in the binary static index format
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.exportBinaryIndex', async () => {
+      logChannel.info('[Client] ========== EXPORT BINARY INDEX COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/exportBinaryIndex', {}) as any;
+        logChannel.info(
+          `[Client] Binary index export complete: ${result.symbols} symbols from ${result.files} files in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `Binary index written: ${result.symbols} symbols from ${result.files} files (${Math.round(result.bytes / 1024)} KB)`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to export binary index:', error);
+        vscode.window.showErrorMessage(`Failed to export binary index: ${error}`);
+      }
+    })
+  );
+
+  // Command: Build per-directory or per-module index shards
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.buildShards', async () => {
+      logChannel.info('[Client] ========== BUILD SHARDS COMMAND ==========');
+      const strategy = await vscode.window.showQuickPick(
+        [
+          { label: 'By module', description: 'Nearest go.mod, package.json, Cargo.toml, ...', value: 'module' },
+          { label: 'By top-level directory', value: 'directory' }
+        ],
+        { title: 'Build Index Shards', placeHolder: 'How to split the index' }
+      );
+      if (!strategy) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/buildShards', { by: strategy.value }) as any;
+        logChannel.info(`[Client] Shard build complete: ${result.shards.length} shards in ${result.duration}ms`);
+
+        const action = await vscode.window.showInformationMessage(
+          `Built ${result.shards.length} index shards`,
+          'Reveal Folder'
+        );
+        if (action === 'Reveal Folder') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputDir));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to build shards:', error);
+        vscode.window.showErrorMessage(`Failed to build shards: ${error}`);
+      }
+    })
+  );
+
+  // Command: Merge index shards into one binary index
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.mergeShards', async () => {
+      logChannel.info('[Client] ========== MERGE SHARDS COMMAND ==========');
+      const selected = await vscode.window.showOpenDialog({
+        title: 'Shards to merge (later files win)',
+        canSelectMany: true,
+        filters: { 'Binary Index': ['sidx'] }
+      });
+      if (!selected || selected.length === 0) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/mergeShards', {
+          inputs: selected.map(uri => uri.fsPath)
+        }) as any;
+        logChannel.info(
+          `[Client] Shard merge complete: ${result.files} files, ${result.symbols} symbols in ${result.duration}ms`
+        );
+
+        const action = await vscode.window.showInformationMessage(
+          `Merged ${result.shards} shards: ${result.files} files, ${result.symbols} symbols`,
+          'Reveal File'
+        );
+        if (action === 'Reveal File') {
+          await vscode.commands.executeCommand('revealFileInOS', vscode.Uri.file(result.outputPath));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to merge shards:', error);
+        vscode.window.showErrorMessage(`Failed to merge shards: ${error}`);
+      }
+    })
+  );
+
+  // Command: Show call graph for the function under the cursor
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showCallGraph', async () => {
+      logChannel.info('[Client] ========== SHOW CALL GRAPH COMMAND ==========');
+      const editor = vscode.window.activeTextEditor;
+
+      const direction = await vscode.window.showQuickPick(
+        [
+          { label: 'Callers', value: 'callers' },
+          { label: 'Callees', value: 'callees' },
+          { label: 'Callers and Callees', value: 'both' }
+        ],
+        { title: 'Call Graph', placeHolder: editor ? 'Direction from the function under the cursor' : 'Direction' }
+      );
+      if (!direction) {
+        return;
+      }
+
+      const format = await vscode.window.showQuickPick(
+        [
+          { label: 'DOT', description: 'Graphviz', value: 'dot' },
+          { label: 'JSON', value: 'json' }
+        ],
+        { title: 'Call Graph', placeHolder: 'Output format' }
+      );
+      if (!format) {
+        return;
+      }
+
+      try {
+        const position = editor?.selection.active;
+        const result = await client.sendRequest('smart-indexer/callGraph', {
+          uri: editor?.document.uri.toString(),
+          line: position?.line,
+          character: position?.character,
+          direction: direction.value,
+          format: format.value
+        }) as any;
+        logChannel.info(
+          `[Client] Call graph: ${result.nodes} nodes, ${result.edges} edges in ${result.duration}ms`
+        );
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: result.format === 'json' ? 'json' : 'dot'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+      } catch (error) {
+        logChannel.error('[Client] Failed to build call graph:', error);
+        vscode.window.showErrorMessage(`Failed to build call graph: ${error}`);
+      }
+    })
+  );
+
+  // Command: Show the package dependency graph with cycles and layering violations
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showDependencyGraph', async () => {
+      logChannel.info('[Client] ========== SHOW DEPENDENCY GRAPH COMMAND ==========');
+
+      const format = await vscode.window.showQuickPick(
+        [
+          { label: 'DOT', description: 'Graphviz', value: 'dot' },
+          { label: 'JSON', value: 'json' }
+        ],
+        { title: 'Package Dependency Graph', placeHolder: 'Output format' }
+      );
+      if (!format) {
+        return;
+      }
+
+      const scope = await vscode.window.showQuickPick(
+        [
+          { label: 'Workspace packages', value: false },
+          { label: 'Include external packages', description: 'stdlib, modules, npm packages', value: true }
+        ],
+        { title: 'Package Dependency Graph', placeHolder: 'Packages to include' }
+      );
+      if (!scope) {
+        return;
+      }
+
+      try {
+        const result = await client.sendRequest('smart-indexer/dependencyGraph', {
+          format: format.value,
+          includeExternal: scope.value
+        }) as any;
+        logChannel.info(
+          `[Client] Dependency graph: ${result.packages} packages, ${result.edges} edges, ` +
+          `${result.cycles.length} cycles, ${result.violations.length} violations in ${result.duration}ms`
+        );
+        for (const cycle of result.cycles) {
+          logChannel.warn(`[Client] Import cycle: ${cycle.path.join(' -> ')}`);
+        }
+        for (const violation of result.violations) {
+          logChannel.warn(
+            `[Client] Layering violation: ${violation.from} -> ${violation.to}` +
+            `${violation.message ? ` (${violation.message})` : ''} in ${violation.files.join(', ')}`
+          );
+        }
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: result.format === 'json' ? 'json' : 'dot'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+
+        if (result.cycles.length > 0 || result.violations.length > 0) {
+          const action = await vscode.window.showWarningMessage(
+            `${result.cycles.length} import cycle(s), ${result.violations.length} layering violation(s)`,
+            'Show Details'
+          );
+          if (action === 'Show Details') {
+            logChannel.show();
+          }
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to build dependency graph:', error);
+        vscode.window.showErrorMessage(`Failed to build dependency graph: ${error}`);
+      }
+    })
+  );
+
+  // Command: Show who last changed a symbol and who wrote most of it
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showSymbolHistory', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const name = await vscode.window.showInputBox({
+        title: 'Symbol History',
+        prompt: 'Symbol name (e.g. NewPerson or people.NewPerson)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!name) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== SYMBOL HISTORY COMMAND: ${name} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/symbolHistory', { name }) as any;
+        if (!result.symbols || result.symbols.length === 0) {
+          vscode.window.showInformationMessage(`No committed definition named '${name}' found in the index.`);
+          return;
+        }
+
+        const items = result.symbols.map((symbol: any) => ({
+          label: `$(git-commit) ${symbol.containerName ? `${symbol.containerName}.` : ''}${symbol.name}`,
+          description: `${symbol.author}, ${formatAge(symbol.ageDays)}${symbol.commit ? ` · ${symbol.commit.slice(0, 8)}` : ''}`,
+          detail: [
+            symbol.summary,
+            symbol.authors.length > 0
+              ? `Authors: ${symbol.authors.map((a: any) => `${a.author} (${a.lines} lines)`).join(', ')}`
+              : undefined,
+            `${symbol.location.uri}:${symbol.location.line + 1}`
+          ].filter(Boolean).join(' — '),
+          location: symbol.location
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `History of ${name}`,
+          placeHolder: 'Select a definition to navigate to it...'
+        }) as any;
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to read symbol history:', error);
+        vscode.window.showErrorMessage(`Failed to read symbol history: ${error}`);
+      }
+    })
+  );
+
+  // Command: List symbols changed in the last N days
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showChangedSymbols', async () => {
+      const input = await vscode.window.showInputBox({
+        title: 'Recently Changed Symbols',
+        prompt: 'Show symbols changed in the last N days',
+        value: '30',
+        validateInput: value => Number(value) > 0 ? undefined : 'Enter a positive number of days'
+      });
+      if (!input) {
+        return;
+      }
+      const days = Number(input);
+
+      logChannel.info(`[Client] ========== CHANGED SYMBOLS COMMAND: ${days} days ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/changedSymbols', { days, limit: 500 }) as any;
+        logChannel.info(`[Client] ${result.total} symbols changed in the last ${days} days (${result.duration}ms)`);
+        if (result.total === 0) {
+          vscode.window.showInformationMessage(`No symbols changed in the last ${days} days.`);
+          return;
+        }
+
+        const items = result.symbols.map((symbol: any) => ({
+          label: `$(symbol-${symbol.kind === 'function' ? 'function' : symbol.kind === 'method' ? 'method' : 'class'}) ` +
+            `${symbol.containerName ? `${symbol.containerName}.` : ''}${symbol.name}`,
+          description: `${symbol.author}, ${formatAge(symbol.ageDays)}`,
+          detail: `${symbol.summary ? `${symbol.summary} — ` : ''}${symbol.location.uri}:${symbol.location.line + 1}`,
+          location: symbol.location
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.total} symbols changed in the last ${days} days`,
+          placeHolder: 'Select a symbol to navigate to it...',
+          matchOnDescription: true
+        }) as any;
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find changed symbols:', error);
+        vscode.window.showErrorMessage(`Failed to find changed symbols: ${error}`);
+      }
+    })
+  );
+
+  // Command: Report exported API changes between two git revisions
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.compareRevisions', async () => {
+      const base = await vscode.window.showInputBox({
+        title: 'Compare API Between Revisions',
+        prompt: 'Base revision (branch, tag or commit)',
+        value: 'main'
+      });
+      if (!base) {
+        return;
+      }
+      const head = await vscode.window.showInputBox({
+        title: 'Compare API Between Revisions',
+        prompt: 'Head revision',
+        value: 'HEAD'
+      });
+      if (!head) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== COMPARE REVISIONS COMMAND: ${base}..${head} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/indexDiff', { base, head }) as any;
+        logChannel.info(
+          `[Client] API diff ${base}..${head}: ${result.added} added, ${result.removed} removed, ` +
+          `${result.changed} changed in ${result.duration}ms`
+        );
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: 'markdown'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+      } catch (error) {
+        logChannel.error('[Client] Failed to compare revisions:', error);
+        vscode.window.showErrorMessage(`Failed to compare revisions: ${error}`);
+      }
+    })
+  );
+
+  // Command: Classify API changes since a release as major, minor or patch
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.checkSemver', async () => {
+      const range = await vscode.window.showInputBox({
+        title: 'Check Semantic Version Compatibility',
+        prompt: 'Revision range from the last release, e.g. v1.2.0..HEAD',
+        value: 'v1.0.0..HEAD'
+      });
+      if (!range) {
+        return;
+      }
+      const release = await vscode.window.showInputBox({
+        title: 'Check Semantic Version Compatibility',
+        prompt: 'Version to release (empty: breaking changes are unintended)',
+        placeHolder: 'e.g. v1.3.0'
+      });
+      if (release === undefined) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== SEMVER CHECK COMMAND: ${range} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/semverCheck', { range, release: release || undefined }) as any;
+        logChannel.info(
+          `[Client] Semver check ${range}: ${result.level} changes, release allows ${result.allowed} in ${result.duration}ms`
+        );
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: 'markdown'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+        if (!result.compatible) {
+          vscode.window.showWarningMessage(
+            `Breaking API changes: they need a ${result.level} release${result.suggestedVersion ? ` (${result.suggestedVersion})` : ''}`
+          );
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to check semantic version:', error);
+        vscode.window.showErrorMessage(`Failed to check semantic version: ${error}`);
+      }
+    })
+  );
+
+  // Command: Check the configured policies, on all code or code new since a commit
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.checkPolicies', async () => {
+      const since = await vscode.window.showInputBox({
+        title: 'Check Policies',
+        prompt: 'Check only code new since this commit (empty: the whole workspace)',
+        placeHolder: 'e.g. origin/main'
+      });
+      if (since === undefined) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== POLICY CHECK COMMAND${since ? `: since ${since}` : ''} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/checkPolicies', { since: since || undefined }) as any;
+        logChannel.info(
+          `[Client] Policy check: ${result.errors} errors, ${result.warnings} warnings in ${result.duration}ms`
+        );
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: 'plaintext'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+        if (!result.passed) {
+          vscode.window.showWarningMessage(`Policy check failed: ${result.errors} ${result.errors === 1 ? 'error' : 'errors'}`);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to check policies:', error);
+        vscode.window.showErrorMessage(`Failed to check policies: ${error}`);
+      }
+    })
+  );
+
+  // Command: Exported API of a Go module, in a diffable text format
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showApiSurface', async () => {
+      try {
+        const modules = await client.sendRequest('smart-indexer/goModules') as Array<{ module: string; version?: string; thirdParty: boolean }>;
+        if (modules.length === 0) {
+          vscode.window.showInformationMessage('No Go modules in the index');
+          return;
+        }
+        const picked = await vscode.window.showQuickPick(
+          modules.map(m => ({
+            label: m.module,
+            description: m.version ?? (m.thirdParty ? 'dependency' : 'workspace'),
+            value: m.version ? `${m.module}@${m.version}` : m.module
+          })),
+          { title: 'Show Module API Surface', placeHolder: 'Go module' }
+        );
+        if (!picked) {
+          return;
+        }
+
+        logChannel.info(`[Client] ========== API SURFACE COMMAND: ${picked.value} ==========`);
+        const result = await client.sendRequest('smart-indexer/apiSurface', { module: picked.value }) as any;
+        logChannel.info(
+          `[Client] API surface of ${result.module}: ${result.features} features in ${result.packages} packages in ${result.duration}ms`
+        );
+
+        const doc = await vscode.workspace.openTextDocument({
+          content: result.content,
+          language: 'plaintext'
+        });
+        await vscode.window.showTextDocument(doc, { preview: false });
+      } catch (error) {
+        logChannel.error('[Client] Failed to show API surface:', error);
+        vscode.window.showErrorMessage(`Failed to show API surface: ${error}`);
+      }
+    })
+  );
+
+  // Command: Natural-language search over functions and types
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.ask', async () => {
+      const query = await vscode.window.showInputBox({
+        title: 'Ask',
+        prompt: 'Describe the code you are looking for',
+        placeHolder: 'e.g. function that constructs a person'
+      });
+      if (!query) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== ASK COMMAND: ${query} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/ask', { query }) as any;
+        logChannel.info(
+          `[Client] Ask: ${result.results.length} results over ${result.chunks} chunks ` +
+          `(${result.embedded} newly embedded) in ${result.duration}ms`
+        );
+        if (result.results.length === 0) {
+          vscode.window.showInformationMessage('No embedded functions or types yet.');
+          return;
+        }
+
+        const items = result.results.map((r: any) => ({
+          label: `$(symbol-${r.kind === 'function' || r.kind === 'method' ? 'method' : 'class'}) ${r.containerName ? `${r.containerName}.` : ''}${r.name}`,
+          description: `${r.kind} · ${r.score.toFixed(3)}`,
+          detail: `${vscode.workspace.asRelativePath(r.location.uri)}:${r.location.line + 1}${r.summary ? ` · ${r.summary}` : ''}`,
+          location: r.location
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: query,
+          placeHolder: 'Select a result to navigate to it...'
+        }) as any;
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Natural-language search failed:', error);
+        vscode.window.showErrorMessage(`Natural-language search failed: ${error}`);
+      }
+    })
+  );
+
+  // Command: Summarize complex exported functions and types
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.summarizeSymbols', async () => {
+      logChannel.info('[Client] ========== SUMMARIZE SYMBOLS COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/summarizeSymbols', {}) as any;
+        logChannel.info(
+          `[Client] Summaries: ${result.summaries} in ${result.files} files ` +
+          `(${result.summarized} newly summarized) in ${result.duration}ms`
+        );
+        if (result.symbols.length === 0) {
+          vscode.window.showInformationMessage('No symbols are complex enough to summarize.');
+          return;
+        }
+
+        const items = result.symbols.map((s: any) => ({
+          label: `$(symbol-${s.kind === 'function' || s.kind === 'method' ? 'method' : 'class'}) ${s.containerName ? `${s.containerName}.` : ''}${s.name}`,
+          description: `${s.kind} · ${vscode.workspace.asRelativePath(s.uri)}:${s.line + 1}`,
+          detail: s.summary,
+          location: { uri: s.uri, line: s.line, character: 0 }
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Symbol Summaries (${result.summaries})`,
+          placeHolder: 'Filter by name or summary...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        }) as any;
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Symbol summaries failed:', error);
+        vscode.window.showErrorMessage(`Symbol summaries failed: ${error}`);
+      }
+    })
+  );
+
+  // Command: Describe the members of a type
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.describeType', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const name = await vscode.window.showInputBox({
+        title: 'Describe Type',
+        prompt: 'Type name (e.g. Person or pkg.Person)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!name) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== DESCRIBE TYPE COMMAND: ${name} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/describeType', {
+          name,
+          uri: editor?.document.uri.toString()
+        }) as any;
+
+        if (!result.types || result.types.length === 0) {
+          vscode.window.showInformationMessage(`No type named '${name}' found in the index.`);
+          return;
+        }
+
+        interface MemberItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        const items: MemberItem[] = [];
+        const section = (label: string, members: any[]) => {
+          if (members.length === 0) {
+            return;
+          }
+          items.push({ label: `${label} (${members.length})`, kind: vscode.QuickPickItemKind.Separator });
+          for (const member of members) {
+            const receiver = member.receiver === 'pointer' ? 'pointer receiver' : member.receiver === 'value' ? 'value receiver' : undefined;
+            const via = member.promotedVia ? `via ${member.promotedVia.join('.')}` : undefined;
+            items.push({
+              label: `$(symbol-${member.kind === 'method' ? 'method' : 'field'}) ${member.name}`,
+              description: [member.type, receiver, via].filter(Boolean).join(' · '),
+              location: member.location
+            });
+          }
+        };
+
+        for (const type of result.types) {
+          items.push({
+            label: `$(symbol-${type.kind === 'interface' ? 'interface' : 'class'}) ${type.qualifiedName}`,
+            description: type.kind,
+            detail: `${type.location.uri}:${type.location.line + 1}`,
+            location: type.location
+          });
+          section('Fields', type.fields);
+          section('Methods', type.methods);
+          section('Embedded', type.embedded.map((e: any) => ({
+            name: e.name,
+            kind: 'field',
+            type: e.type,
+            location: e.resolved
+          })));
+          section('Promoted', type.promoted);
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Members of ${name}`,
+          placeHolder: 'Select a member to navigate to its declaration...'
+        });
+
+        if (selected?.location) {
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(selected.location.uri));
+          const memberEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(selected.location.line, selected.location.character);
+          memberEditor.selection = new vscode.Selection(position, position);
+          memberEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to describe type:', error);
+        vscode.window.showErrorMessage(`Failed to describe type: ${error}`);
+      }
+    })
+  );
+
+  // Command: Show what a type embeds, or what embeds it, as a tree
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.typeHierarchy', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const name = await vscode.window.showInputBox({
+        title: 'Type Hierarchy',
+        prompt: 'Type name (e.g. AdminUser or pkg.AdminUser)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!name) {
+        return;
+      }
+
+      const direction = await vscode.window.showQuickPick(
+        [
+          { label: 'Supertypes', description: `what ${name} embeds or extends`, value: 'supertypes' },
+          { label: 'Subtypes', description: `what embeds or extends ${name}`, value: 'subtypes' }
+        ],
+        { title: 'Type Hierarchy', placeHolder: 'Direction' }
+      );
+      if (!direction) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== TYPE HIERARCHY COMMAND: ${direction.value} of ${name} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/typeHierarchy', {
+          name,
+          uri: editor?.document.uri.toString(),
+          direction: direction.value
+        }) as any;
+
+        if (!result.roots || result.roots.length === 0) {
+          vscode.window.showInformationMessage(`No type named '${name}' found in the index.`);
+          return;
+        }
+
+        interface NodeItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        // Quick picks trim leading spaces; indent with non-breaking ones
+        const items: NodeItem[] = [];
+        const add = (node: any, depth: number) => {
+          const notes = [
+            node.type && node.type !== node.name ? node.type : undefined,
+            node.location ? undefined : 'not indexed',
+            node.cycle ? 'cycle' : undefined,
+            node.truncated ? 'more below' : undefined
+          ];
+          items.push({
+            label: `${'\u00a0'.repeat(depth * 4)}$(symbol-${node.kind === 'interface' ? 'interface' : 'class'}) ${depth === 0 ? node.qualifiedName : node.name}`,
+            description: notes.filter(Boolean).join(' · '),
+            detail: depth === 0 && node.location ? `${node.location.uri}:${node.location.line + 1}` : undefined,
+            location: node.location
+          });
+          for (const child of node.children) {
+            add(child, depth + 1);
+          }
+        };
+        for (const root of result.roots) {
+          add(root, 0);
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${direction.label} of ${name}`,
+          placeHolder: 'Select a type to navigate to its declaration...'
+        });
+
+        if (selected?.location) {
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(selected.location.uri));
+          const typeEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(selected.location.line, selected.location.character);
+          typeEditor.selection = new vscode.Selection(position, position);
+          typeEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to build type hierarchy:', error);
+        vscode.window.showErrorMessage(`Failed to build type hierarchy: ${error}`);
+      }
+    })
+  );
+
+  // Command: List TODO / FIXME / ... comment markers with their authors and ages
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.commentMarkers', async () => {
+      const tag = await vscode.window.showQuickPick(
+        [
+          { label: 'All markers', value: undefined },
+          ...['TODO', 'FIXME', 'HACK', 'XXX', 'BUG', 'DEPRECATED'].map(value => ({ label: value, value }))
+        ],
+        { title: 'Comment Markers', placeHolder: 'Tag' }
+      );
+      if (!tag) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== COMMENT MARKERS COMMAND: ${tag.value ?? 'all'} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/commentMarkers', {
+          tags: tag.value ? [tag.value] : undefined,
+          sort: vscode.workspace.getConfiguration('smartIndexer').get<boolean>('enableGitIntegration', true) ? 'age' : 'path'
+        }) as any;
+
+        if (!result.markers || result.markers.length === 0) {
+          vscode.window.showInformationMessage(`No ${tag.value ?? 'comment'} markers found in the index.`);
+          return;
+        }
+
+        interface MarkerItem extends vscode.QuickPickItem {
+          location: { uri: string; line: number; character: number };
+        }
+
+        const items: MarkerItem[] = result.markers.map((marker: any) => ({
+          label: `${marker.tag}: ${marker.text || '(no text)'}`,
+          description: [
+            marker.owner,
+            marker.author && marker.author !== marker.owner ? marker.author : undefined,
+            marker.ageDays !== undefined ? formatAge(marker.ageDays) : undefined
+          ].filter(Boolean).join(' · '),
+          detail: `${vscode.workspace.asRelativePath(marker.uri)}:${marker.line + 1}`,
+          location: marker
+        }));
+
+        const counts = Object.entries(result.byTag as Record<string, number>).map(([name, count]) => `${name} ${count}`).join(', ');
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.total} comment markers (${counts})${result.truncated ? `, first ${items.length} shown` : ''}`,
+          placeHolder: 'Select a marker to navigate to it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to list comment markers:', error);
+        vscode.window.showErrorMessage(`Failed to list comment markers: ${error}`);
+      }
+    })
+  );
+
+  // Command: Uses of deprecated symbols by owning team
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.deprecations', async () => {
+      logChannel.info('[Client] ========== DEPRECATIONS COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/deprecations', {}) as any;
+
+        if (!result.deprecated || result.deprecated.length === 0) {
+          vscode.window.showInformationMessage('No deprecated symbols found in the index.');
+          return;
+        }
+        if (result.callSites === 0) {
+          vscode.window.showInformationMessage(`${result.deprecated.length} deprecated symbols, none of them used outside deprecated code.`);
+          return;
+        }
+
+        interface CallSiteItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        const items: CallSiteItem[] = [];
+        for (const team of [...result.teams, result.unowned]) {
+          if (team.callSites === 0) {
+            continue;
+          }
+          items.push({
+            label: `${team.owner ?? 'Unowned'} - ${team.callSites} uses`,
+            kind: vscode.QuickPickItemKind.Separator
+          });
+          for (const usage of team.symbols) {
+            const name = usage.symbol.containerName ? `${usage.symbol.containerName}.${usage.symbol.name}` : usage.symbol.name;
+            for (const site of usage.callSites) {
+              items.push({
+                label: `$(warning) ${name}`,
+                description: usage.notice,
+                detail: `${vscode.workspace.asRelativePath(site.uri)}:${site.line + 1}${site.enclosing ? ` in ${site.enclosing}` : ''}`,
+                location: site
+              });
+            }
+          }
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.callSites} uses of ${result.deprecated.length} deprecated symbols`,
+          placeHolder: 'Select a use to navigate to it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to build the deprecation report:', error);
+        vscode.window.showErrorMessage(`Failed to build the deprecation report: ${error}`);
+      }
+    })
+  );
+
+  // Command: HTTP routes of Go services
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.httpRoutes', async () => {
+      if (!vscode.workspace.getConfiguration('smartIndexer').get<boolean>('httpRoutes.enabled', false)) {
+        const choice = await vscode.window.showInformationMessage(
+          'HTTP route extraction is disabled. Enable smartIndexer.httpRoutes.enabled?', 'Enable'
+        );
+        if (choice !== 'Enable') {
+          return;
+        }
+        await vscode.workspace.getConfiguration('smartIndexer').update('httpRoutes.enabled', true, vscode.ConfigurationTarget.Workspace);
+      }
+
+      logChannel.info('[Client] ========== HTTP ROUTES COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/httpRoutes', {}) as any;
+
+        if (!result.routes || result.routes.length === 0) {
+          vscode.window.showInformationMessage('No HTTP routes found in the index.');
+          return;
+        }
+
+        interface RouteItem extends vscode.QuickPickItem {
+          location: { uri: string; line: number; character: number };
+        }
+
+        const items: RouteItem[] = result.routes.map((route: any) => ({
+          label: `${route.method} ${route.path}`,
+          description: route.definition
+            ? [route.definition.containerName, route.definition.name].filter(Boolean).join('.')
+            : route.handler,
+          detail: `${vscode.workspace.asRelativePath(route.uri)}:${route.line + 1} · ${route.framework}`,
+          // Open the handler; inline and unindexed handlers open the registration
+          location: route.definition?.location ?? route
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.total} HTTP routes${result.truncated ? `, first ${items.length} shown` : ''}`,
+          placeHolder: 'Select a route to open its handler...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to list HTTP routes:', error);
+        vscode.window.showErrorMessage(`Failed to list HTTP routes: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find functions running SQL on a table
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.sqlQueries', async () => {
+      const table = await vscode.window.showInputBox({
+        title: 'Find Functions Using a Table',
+        prompt: 'Table name (e.g. users or public.users)'
+      });
+      if (!table) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== SQL QUERIES COMMAND: ${table} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/sqlQueries', { table }) as any;
+
+        if (!result.functions || result.functions.length === 0) {
+          vscode.window.showInformationMessage(`No SQL queries on '${table}' found in the index.`);
+          return;
+        }
+
+        interface FunctionItem extends vscode.QuickPickItem {
+          location: { uri: string; line: number; character: number };
+        }
+
+        const items: FunctionItem[] = result.functions.map((usage: any) => {
+          const query = result.queries.find((q: any) => q.uri === usage.uri && q.function === usage.name);
+          return {
+            label: usage.name ?? '(package level)',
+            description: `${usage.operations.join(', ')} · ${usage.queries} ${usage.queries === 1 ? 'query' : 'queries'}`,
+            detail: query ? query.sql : vscode.workspace.asRelativePath(usage.uri),
+            // Open the statement; the function when it was cut by the limit
+            location: query ?? usage.location ?? { uri: usage.uri, line: 0, character: 0 }
+          };
+        });
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.functions.length} functions run ${result.total} queries on '${table}'`,
+          placeHolder: 'Select a function to open its query...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find SQL queries:', error);
+        vscode.window.showErrorMessage(`Failed to find SQL queries: ${error}`);
+      }
+    })
+  );
+
+  // Command: List environment variables, viper keys and flags
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.configKeys', async () => {
+      logChannel.info('[Client] ========== CONFIG KEYS COMMAND ==========');
+      try {
+        const result = await client.sendRequest('smart-indexer/configKeys', {}) as any;
+
+        if (!result.keys || result.keys.length === 0) {
+          vscode.window.showInformationMessage('No configuration keys found in the index.');
+          return;
+        }
+
+        interface KeyItem extends vscode.QuickPickItem {
+          uses?: any[];
+        }
+
+        const sections: Record<string, string> = { env: 'Environment variables', viper: 'Viper keys', flag: 'Flags' };
+        const items: KeyItem[] = [];
+        let section: string | undefined;
+        for (const key of result.keys) {
+          if (key.source !== section) {
+            section = key.source;
+            items.push({ label: sections[key.source] ?? key.source, kind: vscode.QuickPickItemKind.Separator });
+          }
+          const first = key.uses[0];
+          items.push({
+            label: key.source === 'flag' ? `-${key.key}` : key.key,
+            description: [
+              key.types.join(' | '),
+              key.defaults.length > 0 ? `default ${key.defaults.join(', ')}` : '',
+              key.env.length > 0 ? `env ${key.env.join(', ')}` : ''
+            ].filter(Boolean).join(' · '),
+            detail: `${vscode.workspace.asRelativePath(first.uri)}:${first.line + 1}` +
+              (key.uses.length > 1 ? ` and ${key.uses.length - 1} more` : '') +
+              (key.description ? ` · ${key.description}` : ''),
+            uses: key.uses
+          });
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.total} configuration keys${result.truncated ? `, first ${result.keys.length} shown` : ''}`,
+          placeHolder: 'Select a key to open where it is read...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+        if (!selected?.uses) {
+          return;
+        }
+
+        let use = selected.uses[0];
+        if (selected.uses.length > 1) {
+          const picked = await vscode.window.showQuickPick(selected.uses.map((u: any) => ({
+            label: `${vscode.workspace.asRelativePath(u.uri)}:${u.line + 1}`,
+            description: u.call + (u.default !== undefined ? ` · default ${u.default}` : ''),
+            use: u
+          })), { title: selected.label, placeHolder: 'Select a use to open it...' }) as any;
+          if (!picked) {
+            return;
+          }
+          use = picked.use;
+        }
+        await revealLocation(use);
+      } catch (error) {
+        logChannel.error('[Client] Failed to list configuration keys:', error);
+        vscode.window.showErrorMessage(`Failed to list configuration keys: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find goroutine launches, channel operations and mutex calls in a package
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.concurrency', async () => {
+      const pkg = await vscode.window.showInputBox({
+        title: 'Find Goroutines, Channels and Locks',
+        prompt: 'Go package name or folder (e.g. worker or internal/worker); empty for the whole workspace'
+      });
+      if (pkg === undefined) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== CONCURRENCY COMMAND: ${pkg || '*'} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/concurrency', pkg ? { package: pkg } : {}) as any;
+        const where = pkg ? ` in '${pkg}'` : '';
+
+        if (!result.events || result.events.length === 0) {
+          vscode.window.showInformationMessage(`No goroutines, channels or locks found${where}.`);
+          return;
+        }
+
+        interface EventItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        const sections: Record<string, string> = {
+          go: 'Goroutines', make: 'Channels made', send: 'Sends', receive: 'Receives',
+          close: 'Closes', lock: 'Locks', unlock: 'Unlocks'
+        };
+        const items: EventItem[] = [];
+        for (const op of Object.keys(sections)) {
+          const events = result.events.filter((event: any) => event.op === op);
+          if (events.length === 0) {
+            continue;
+          }
+          items.push({ label: `${sections[op]} (${result.byOp[op]})`, kind: vscode.QuickPickItemKind.Separator });
+          for (const event of events) {
+            items.push({
+              label: event.target ?? event.type ?? op,
+              description: [
+                event.function ?? '(package level)',
+                event.type,
+                event.buffered ? 'buffered' : '',
+                event.range ? 'range' : '',
+                event.select ? 'select' : '',
+                event.read ? 'read' : '',
+                event.deferred ? 'deferred' : ''
+              ].filter(Boolean).join(' · '),
+              detail: `${vscode.workspace.asRelativePath(event.uri)}:${event.line + 1}`,
+              location: event
+            });
+          }
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.byOp.go ?? 0} goroutine launches and ${result.total} events in ${result.functions.length} functions${where}` +
+            (result.truncated ? `, first ${result.events.length} shown` : ''),
+          placeHolder: 'Select an event to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find goroutines and channels:', error);
+        vscode.window.showErrorMessage(`Failed to find goroutines and channels: ${error}`);
+      }
+    })
+  );
+
+  // Command: Audit which functions can panic and which drop errors
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.errorPaths', async () => {
+      const audits = [
+        { label: 'Exported functions that can panic', query: { exported: true, canPanic: true }, functions: true },
+        { label: 'Functions that can panic', query: { canPanic: true }, functions: true },
+        { label: 'Errors assigned to _', query: { kind: 'discard' }, functions: false },
+        { label: 'Panics', query: { kind: 'panic' }, functions: false }
+      ];
+      const audit = await vscode.window.showQuickPick(audits, { title: 'Audit Panics and Dropped Errors' });
+      if (!audit) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== ERROR PATHS COMMAND: ${audit.label} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/errorPaths', audit.query) as any;
+
+        interface PathItem extends vscode.QuickPickItem {
+          location: { uri: string; line: number; character: number };
+        }
+
+        const items: PathItem[] = audit.functions
+          ? result.functions.map((summary: any) => ({
+            label: summary.name,
+            description: summary.panics > 0
+              ? `${summary.panics} ${summary.panics === 1 ? 'panic' : 'panics'}`
+              : `through ${summary.panicsVia}`,
+            detail: `${vscode.workspace.asRelativePath(summary.uri)}:${summary.location.line + 1}`,
+            location: summary.location
+          }))
+          : result.events.map((event: any) => ({
+            label: event.expression,
+            description: event.function ?? '(package level)',
+            detail: `${vscode.workspace.asRelativePath(event.uri)}:${event.line + 1}`,
+            location: event
+          }));
+
+        if (items.length === 0) {
+          vscode.window.showInformationMessage(`${audit.label}: none found in the index.`);
+          return;
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${audit.label}: ${items.length}${result.truncated && !audit.functions ? ` of ${result.total}` : ''}`,
+          placeHolder: 'Select one to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to collect error paths:', error);
+        vscode.window.showErrorMessage(`Failed to collect error paths: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find untested functions from Go coverage profiles
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.coverage', async () => {
+      const views = [
+        { label: 'Untested exported functions used more than 10 times', query: { exported: true, maxCoverage: 0, minReferences: 11 } },
+        { label: 'Untested exported functions', query: { exported: true, maxCoverage: 0 } },
+        { label: 'Functions under 50% coverage', query: { maxCoverage: 49 } },
+        { label: 'All covered functions', query: {} }
+      ];
+      const view = await vscode.window.showQuickPick(views, { title: 'Find Untested Functions' });
+      if (!view) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== COVERAGE COMMAND: ${view.label} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/coverage', view.query) as any;
+
+        if (!result.profiles.some((profile: any) => profile.loaded)) {
+          const choice = await vscode.window.showWarningMessage(
+            'No coverage profile found. Run `go test -coverprofile=coverage.out ./...` or set smartIndexer.coverage.profiles.',
+            'Open Settings'
+          );
+          if (choice === 'Open Settings') {
+            await vscode.commands.executeCommand('workbench.action.openSettings', 'smartIndexer.coverage.profiles');
+          }
+          return;
+        }
+
+        if (result.functions.length === 0) {
+          vscode.window.showInformationMessage(`${view.label}: none found.`);
+          return;
+        }
+
+        const items = result.functions.map((entry: any) => ({
+          label: entry.name,
+          description: `${entry.percent}% of ${entry.statements} statements, ${entry.references} ${entry.references === 1 ? 'reference' : 'references'}`,
+          detail: `${vscode.workspace.asRelativePath(entry.location.uri)}:${entry.location.line + 1}`,
+          location: entry.location
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${view.label}: ${result.truncated ? `${items.length} of ${result.total}` : items.length}`,
+          placeHolder: 'Select a function to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        }) as any;
+
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to read coverage:', error);
+        vscode.window.showErrorMessage(`Failed to read coverage: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find the Go tests exercising a symbol and run them
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.testsFor', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const symbol = await vscode.window.showInputBox({
+        title: 'Find Tests for Symbol',
+        prompt: 'Symbol name (e.g. Person, user.Person or Person.Greet)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!symbol) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== TESTS FOR COMMAND: ${symbol} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/testsFor', { symbol }) as any;
+
+        if (result.definitions.length === 0) {
+          vscode.window.showInformationMessage(`No Go symbol named '${symbol}' found in the index.`);
+          return;
+        }
+        if (result.tests.length === 0) {
+          vscode.window.showInformationMessage(`No tests exercise ${symbol}.`);
+          return;
+        }
+
+        interface TestItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+          run?: boolean;
+        }
+
+        const items: TestItem[] = [
+          {
+            label: `$(play) Run ${result.tests.length} ${result.tests.length === 1 ? 'test' : 'tests'}`,
+            description: result.packages.map((pkg: any) => pkg.dir).join(', '),
+            run: true
+          },
+          ...result.tests.map((test: any) => ({
+            label: `$(beaker) ${test.name}`,
+            description: test.via.length > 0 ? `${test.kind}, via ${test.via.join(' → ')}` : test.kind,
+            detail: `${vscode.workspace.asRelativePath(test.uri)}:${test.location.line + 1}`,
+            location: test.location
+          }))
+        ];
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Tests for ${result.symbol}: ${result.tests.length}`,
+          placeHolder: 'Run them, or select a test to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected?.run) {
+          const terminal = vscode.window.createTerminal({
+            name: `Tests for ${result.symbol}`,
+            cwd: vscode.workspace.workspaceFolders?.[0]?.uri.fsPath
+          });
+          terminal.show();
+          for (const pkg of result.packages) {
+            for (const command of pkg.commands) {
+              terminal.sendText(command);
+            }
+          }
+        } else if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find tests:', error);
+        vscode.window.showErrorMessage(`Failed to find tests: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find the Go tests affected by the changes since a commit and run them
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.affectedTests', async () => {
+      const since = await vscode.window.showInputBox({
+        title: 'Find Affected Tests',
+        prompt: 'Compare with (e.g. HEAD for uncommitted changes, HEAD~1 or origin/main)',
+        value: 'HEAD'
+      });
+      if (!since) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== AFFECTED TESTS COMMAND: ${since} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/affectedTests', { since }) as any;
+
+        if (result.commands.length === 0) {
+          vscode.window.showInformationMessage(
+            `No tests affected by ${result.changed.length} changed symbols in ${result.files} files since ${since}.`
+          );
+          return;
+        }
+
+        interface TestItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+          run?: boolean;
+        }
+
+        const items: TestItem[] = [
+          {
+            label: result.all ? '$(play) Run all tests' : `$(play) Run ${result.tests.length} ${result.tests.length === 1 ? 'test' : 'tests'}`,
+            description: result.all ? 'go.mod changed' : result.packages.map((pkg: any) => pkg.all ? `${pkg.dir} (all)` : pkg.dir).join(', '),
+            run: true
+          },
+          ...result.tests.map((test: any) => ({
+            label: `$(beaker) ${test.name}`,
+            description: test.via.length > 0 ? `${test.kind}, via ${test.via.join(' → ')}` : `${test.kind}, changed`,
+            detail: `${vscode.workspace.asRelativePath(test.uri)}:${test.location.line + 1}`,
+            location: test.location
+          }))
+        ];
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Tests affected by ${result.changed.length} changed symbols since ${since}`,
+          placeHolder: 'Run them, or select a test to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected?.run) {
+          const terminal = vscode.window.createTerminal({
+            name: `Tests affected since ${since}`,
+            cwd: vscode.workspace.workspaceFolders?.[0]?.uri.fsPath
+          });
+          terminal.show();
+          for (const command of result.commands) {
+            terminal.sendText(command);
+          }
+        } else if (selected?.location) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find affected tests:', error);
+        vscode.window.showErrorMessage(`Failed to find affected tests: ${error}`);
+      }
+    })
+  );
+
+  // Command: Search the Markdown and AsciiDoc docs
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.searchDocs', async () => {
+      const text = await vscode.window.showInputBox({
+        title: 'Search Docs',
+        prompt: 'Words in a section of the Markdown or AsciiDoc docs (e.g. retry backoff)'
+      });
+      if (!text) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== SEARCH DOCS COMMAND: ${text} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/docs', { text }) as any;
+
+        if (result.sections.length === 0) {
+          vscode.window.showInformationMessage(`No doc sections contain '${text}'.`);
+          return;
+        }
+
+        const selected = await vscode.window.showQuickPick(result.sections.map((section: any) => ({
+          label: `$(book) ${section.heading || vscode.workspace.asRelativePath(section.uri)}`,
+          description: [...section.parents, section.heading].filter(Boolean).join(' › '),
+          detail: `${vscode.workspace.asRelativePath(section.uri)}:${section.line + 1}` +
+            (section.excerpt ? ` · ${section.excerpt}` : ''),
+          location: { uri: section.uri, line: section.line, character: 0 }
+        })), {
+          title: `${result.total} doc sections${result.truncated ? `, first ${result.sections.length} shown` : ''}`,
+          placeHolder: 'Select a section to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        }) as any;
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to search docs:', error);
+        vscode.window.showErrorMessage(`Failed to search docs: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find the docs mentioning a symbol
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.docMentions', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const symbol = await vscode.window.showInputBox({
+        title: 'Find Docs Mentioning Symbol',
+        prompt: 'Symbol name (e.g. Person, user.Person or Person.Greet)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!symbol) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== DOC MENTIONS COMMAND: ${symbol} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/docMentions', { symbol }) as any;
+
+        if (result.definitions.length === 0) {
+          vscode.window.showInformationMessage(`No symbol named '${symbol}' found in the index.`);
+          return;
+        }
+        if (result.mentions.length === 0) {
+          vscode.window.showInformationMessage(`No docs mention ${symbol}.`);
+          return;
+        }
+
+        const selected = await vscode.window.showQuickPick(result.mentions.map((mention: any) => ({
+          label: `$(book) ${mention.heading || vscode.workspace.asRelativePath(mention.uri)}`,
+          description: mention.text,
+          detail: `${vscode.workspace.asRelativePath(mention.uri)}:${mention.line + 1}`,
+          location: mention
+        })), {
+          title: `Docs mentioning ${result.symbol}: ${result.total}`,
+          placeHolder: 'Select a mention to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        }) as any;
+        if (selected) {
+          await revealLocation(selected.location);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find doc mentions:', error);
+        vscode.window.showErrorMessage(`Failed to find doc mentions: ${error}`);
+      }
+    })
+  );
+
+  // Command: Open the web UI the query server serves
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.openWebUi', async () => {
+      try {
+        let result = await client.sendRequest('smart-indexer/webUi') as { url: string | null };
+        if (!result.url) {
+          const choice = await vscode.window.showInformationMessage(
+            'The web UI is served by the query server, which is disabled. Enable smartIndexer.queryServer.enabled?', 'Enable'
+          );
+          if (choice !== 'Enable') {
+            return;
+          }
+          await vscode.workspace.getConfiguration('smartIndexer').update('queryServer.enabled', true, vscode.ConfigurationTarget.Workspace);
+          // The server starts listening once it has seen the setting change
+          for (let attempt = 0; attempt < 20 && !result.url; attempt++) {
+            await new Promise(resolve => setTimeout(resolve, 250));
+            result = await client.sendRequest('smart-indexer/webUi') as { url: string | null };
+          }
+          if (!result.url) {
+            vscode.window.showErrorMessage('The query server did not start; see the Smart Indexer output for details.');
+            return;
+          }
+        }
+        logChannel.info(`[Client] Opening web UI at ${result.url}`);
+        await vscode.env.openExternal(vscode.Uri.parse(result.url));
+      } catch (error) {
+        logChannel.error('[Client] Failed to open the web UI:', error);
+        vscode.window.showErrorMessage(`Failed to open the web UI: ${error}`);
+      }
+    })
+  );
+
+  // Command: Search comments and string literals
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.searchText', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const selection = editor && !editor.selection.isEmpty ? editor.document.getText(editor.selection) : '';
+      const query = await vscode.window.showInputBox({
+        title: 'Search Comments and Strings',
+        prompt: 'Word or phrase (e.g. connection refused)',
+        value: selection.split('\n')[0]
+      });
+      if (!query) {
+        return;
+      }
+
+      const scope = await vscode.window.showQuickPick([
+        { label: 'Everywhere', description: 'Comments, doc comments and strings', kinds: undefined },
+        { label: 'Comments', description: 'Doc comments and other comments', kinds: ['doc', 'comment'] },
+        { label: 'Doc comments', description: 'JSDoc and Go doc comments', kinds: ['doc'] },
+        { label: 'Strings', description: 'String and template literals', kinds: ['string'] }
+      ], { title: 'Search in', placeHolder: 'Where to search...' });
+      if (!scope) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== SEARCH TEXT COMMAND: ${query} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/searchText', {
+          query,
+          kinds: scope.kinds
+        }) as any;
+
+        if (!result.matches || result.matches.length === 0) {
+          vscode.window.showInformationMessage(`No matches for '${query}' in ${scope.label.toLowerCase()}.`);
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const icons: Record<string, string> = { doc: '$(book)', comment: '$(comment)', string: '$(quote)' };
+        const items = result.matches.map((match: any) => ({
+          label: `${icons[match.kind] || ''} ${match.preview}`,
+          description: `${workspaceRoot ? path.relative(workspaceRoot, match.uri) : match.uri}:${match.line + 1}`,
+          match
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.matches.length} matches for '${query}'`,
+          placeHolder: 'Select a match to open it...',
+          matchOnDescription: true
+        }) as any;
+
+        if (selected) {
+          const { match } = selected;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(match.uri));
+          const matchEditor = await vscode.window.showTextDocument(document);
+          const range = new vscode.Range(match.line, match.character, match.endLine, match.endCharacter);
+          matchEditor.selection = new vscode.Selection(range.start, range.end);
+          matchEditor.revealRange(range, vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to search text:', error);
+        vscode.window.showErrorMessage(`Failed to search comments and strings: ${error}`);
+      }
+    })
+  );
+
+  // Command: Grep the workspace through the trigram index
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.grep', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const selection = editor && !editor.selection.isEmpty ? editor.document.getText(editor.selection) : '';
+      const pattern = await vscode.window.showInputBox({
+        title: 'Grep Workspace',
+        prompt: 'Regular expression (e.g. New\\w+Person)',
+        value: selection.split('\n')[0],
+        validateInput: value => {
+          try {
+            new RegExp(value);
+            return undefined;
+          } catch (error) {
+            return error instanceof Error ? error.message : String(error);
+          }
+        }
+      });
+      if (!pattern) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== GREP COMMAND: ${pattern} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/grep', { pattern, regex: true }) as any;
+
+        if (!result.matches || result.matches.length === 0) {
+          vscode.window.showInformationMessage(`No matches for /${pattern}/ in ${result.candidates} candidate files.`);
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items = result.matches.map((match: any) => ({
+          label: match.preview,
+          description: `${workspaceRoot ? path.relative(workspaceRoot, match.uri) : match.uri}:${match.line + 1}`,
+          match
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.matches.length}${result.truncated ? '+' : ''} matches for /${pattern}/ ` +
+            `(${result.indexed ? `${result.candidates} of ${result.files} files read` : 'full scan'})`,
+          placeHolder: 'Select a match to open it...',
+          matchOnDescription: true
+        }) as any;
+
+        if (selected) {
+          const { match } = selected;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(match.uri));
+          const matchEditor = await vscode.window.showTextDocument(document);
+          const range = new vscode.Range(match.line, match.character, match.endLine, match.endCharacter);
+          matchEditor.selection = new vscode.Selection(range.start, range.end);
+          matchEditor.revealRange(range, vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to grep:', error);
+        vscode.window.showErrorMessage(`Failed to grep workspace: ${error}`);
+      }
+    })
+  );
+
+  // Command: Search functions by signature or generic constraint
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.searchSignatures', async () => {
+      const query = await vscode.window.showInputBox({
+        title: 'Search by Signature',
+        prompt: 'Signature pattern (e.g. func(*Person) string, (string, ...) => void) or constraint:comparable',
+        placeHolder: 'func(context.Context, ...) error'
+      });
+      if (!query?.trim()) {
+        return;
+      }
+
+      const constraint = query.trim().match(/^constraint:\s*(.+)$/)?.[1];
+      const options = constraint ? { constraint } : { signature: query.trim() };
+
+      logChannel.info(`[Client] ========== SEARCH SIGNATURES COMMAND: ${query} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/searchSignatures', options) as any;
+
+        if (!result.symbols || result.symbols.length === 0) {
+          vscode.window.showInformationMessage(`No declarations match '${query}'.`);
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items = result.symbols.map((symbol: any) => {
+          const typeParameters = (symbol.typeParameters || [])
+            .map((p: any) => (p.constraint ? `${p.name} ${p.constraint}` : p.name))
+            .join(', ');
+          return {
+            label: `${symbol.containerName ? symbol.containerName + '.' : ''}${symbol.name}`,
+            description: `${typeParameters ? `[${typeParameters}] ` : ''}${symbol.signature || ''}`,
+            detail: `${workspaceRoot ? path.relative(workspaceRoot, symbol.location.uri) : symbol.location.uri}:${symbol.location.line + 1}`,
+            symbol
+          };
+        });
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.symbols.length}${result.truncated ? '+' : ''} matches for '${query}'`,
+          placeHolder: 'Select a declaration to open it...',
+          matchOnDescription: true
+        }) as any;
+
+        if (selected) {
+          const { location } = selected.symbol;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(location.uri));
+          const matchEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(location.line, location.character);
+          matchEditor.selection = new vscode.Selection(position, position);
+          matchEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to search signatures:', error);
+        vscode.window.showErrorMessage(`Failed to search by signature: ${error}`);
+      }
+    })
+  );
+
+  // Command: Structured query over the index
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.structuredQuery', async () => {
+      // Saved queries and scopes of the config (smartIndexer.search or the config file)
+      const defined = await client.sendRequest('smart-indexer/scopes') as any;
+      let saved: string | undefined;
+      if (defined.queries.length > 0) {
+        const picked = await vscode.window.showQuickPick([
+          { label: '$(edit) New query...' },
+          { label: 'Saved queries', kind: vscode.QuickPickItemKind.Separator },
+          ...defined.queries.map((q: any) => ({
+            label: `$(bookmark) ${q.name}`,
+            description: q.scope ? `${q.query} · ${q.scope}` : q.query,
+            detail: q.description,
+            name: q.name
+          }))
+        ], { title: 'Structured Query', placeHolder: 'Run a saved query or write a new one...' }) as any;
+        if (!picked) {
+          return;
+        }
+        saved = picked.name;
+      }
+
+      let query = saved;
+      let scope: string | undefined;
+      if (!saved) {
+        query = (await vscode.window.showInputBox({
+          title: 'Structured Query',
+          prompt: 'Fields: name, kind, receiver, container, exported, file, lang, tag, signature, constraint, generic, value, doc; OR, -term, (...)',
+          placeHolder: 'kind:func receiver:Person exported:true file:pkg/user/**'
+        }))?.trim();
+        if (!query) {
+          return;
+        }
+        if (defined.scopes.length > 0) {
+          const picked = await vscode.window.showQuickPick([
+            { label: '$(globe) Everything' },
+            ...defined.scopes.map((s: any) => ({ label: `$(filter) ${s.name}`, description: s.filter, name: s.name }))
+          ], { title: query, placeHolder: 'Run the query in a scope...' }) as any;
+          if (!picked) {
+            return;
+          }
+          scope = picked.name;
+        }
+      }
+
+      logChannel.info(`[Client] ========== STRUCTURED QUERY COMMAND: ${query}${scope ? ` (scope ${scope})` : ''} ==========`);
+      try {
+        const result = await client.sendRequest('smart-indexer/query', saved ? { saved } : { query, scope }) as any;
+        logChannel.info(`[Client] Query plan: ${result.plan}, ${result.filesScanned} files scanned`);
+
+        if (!result.symbols || result.symbols.length === 0) {
+          vscode.window.showInformationMessage(`No definitions match '${query}'.`);
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items = result.symbols.map((symbol: any) => ({
+          label: `${symbol.containerName ? symbol.containerName + '.' : ''}${symbol.name}`,
+          description: `${symbol.kind}${symbol.signature ? ' ' + symbol.signature : ''}`,
+          detail: `${workspaceRoot ? path.relative(workspaceRoot, symbol.location.uri) : symbol.location.uri}:${symbol.location.line + 1}`,
+          symbol
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${result.symbols.length}${result.truncated ? '+' : ''} matches for '${query}'`,
+          placeHolder: 'Select a definition to open it...',
+          matchOnDescription: true
+        }) as any;
+
+        if (selected) {
+          const { location } = selected.symbol;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(location.uri));
+          const matchEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(location.line, location.character);
+          matchEditor.selection = new vscode.Selection(position, position);
+          matchEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to run structured query:', error);
+        vscode.window.showErrorMessage(`Failed to run query: ${error}`);
+      }
+    })
+  );
+
+  // Command: Check what a rename would touch
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.renameCheck', async () => {
+      const editor = vscode.window.activeTextEditor;
+      const wordRange = editor?.document.getWordRangeAtPosition(editor.selection.active);
+      const oldName = await vscode.window.showInputBox({
+        title: 'Check Rename Impact',
+        prompt: 'Symbol to rename (e.g. Greet or Person.Greet)',
+        value: wordRange ? editor!.document.getText(wordRange) : ''
+      });
+      if (!oldName) {
+        return;
+      }
+      const newName = await vscode.window.showInputBox({
+        title: 'Check Rename Impact',
+        prompt: `New name for ${oldName}`
+      });
+      if (!newName) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== RENAME CHECK COMMAND: ${oldName} -> ${newName} ==========`);
+      try {
+        const impact = await client.sendRequest('smart-indexer/renameCheck', { oldName, newName }) as any;
+        const radius = impact.blastRadius;
+        logChannel.info(
+          `[Client] Rename impact: ${impact.locations.length} locations in ${radius.files} files, ` +
+          `${radius.packages.length} packages, risk ${radius.risk}`
+        );
+
+        interface ImpactItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const relative = (uri: string) => (workspaceRoot ? path.relative(workspaceRoot, uri) : uri);
+        const items: ImpactItem[] = [];
+
+        if (impact.problems.length > 0) {
+          items.push({ label: `Problems (${impact.problems.length})`, kind: vscode.QuickPickItemKind.Separator });
+          for (const problem of impact.problems) {
+            items.push({ label: `$(error) ${problem}` });
+          }
+        }
+        if (impact.collisions.length > 0) {
+          items.push({ label: `Collisions (${impact.collisions.length})`, kind: vscode.QuickPickItemKind.Separator });
+          for (const collision of impact.collisions) {
+            items.push({
+              label: `$(warning) ${collision.message}`,
+              description: `${collision.symbolKind} ${collision.name}`,
+              detail: `${relative(collision.uri)}:${collision.line + 1}`,
+              location: collision
+            });
+          }
+        }
+        if (impact.locations.length > 0) {
+          items.push({ label: `Locations (${impact.locations.length})`, kind: vscode.QuickPickItemKind.Separator });
+          for (const location of impact.locations) {
+            items.push({
+              label: `$(${location.definition ? 'symbol-class' : 'references'}) ${relative(location.uri)}:${location.line + 1}`,
+              description: [location.definition ? 'definition' : undefined, location.containerName].filter(Boolean).join(' · '),
+              location
+            });
+          }
+        }
+
+        const verdict = impact.safe ? 'safe' : `${impact.problems.length + impact.collisions.length} issues`;
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Rename ${oldName} -> ${newName}: ${verdict}, risk ${radius.risk}, ${radius.packages.length} packages`,
+          placeHolder: 'Select a location to open it...'
+        });
+
+        if (selected?.location) {
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(selected.location.uri));
+          const locationEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(selected.location.line, selected.location.character);
+          locationEditor.selection = new vscode.Selection(position, position);
+          locationEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to check rename:', error);
+        vscode.window.showErrorMessage(`Failed to check rename: ${error}`);
+      }
+    })
+  );
+
+  // Command: Find near-duplicate functions
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.findClones', async () => {
+      const threshold = await vscode.window.showInputBox({
+        title: 'Find Duplicate Code',
+        prompt: 'Minimum similarity between 0 and 1 (1 = identical after renaming)',
+        value: '0.8',
+        validateInput: value => {
+          const number = Number(value);
+          return number > 0 && number <= 1 ? undefined : 'Enter a number greater than 0 and at most 1';
+        }
+      });
+      if (!threshold) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== FIND CLONES COMMAND (similarity ${threshold}) ==========`);
+      try {
+        const report = await client.sendRequest('smart-indexer/findClones', {
+          minSimilarity: Number(threshold),
+          includeTests: false
+        }) as any;
+        logChannel.info(
+          `[Client] Clone detection complete: ${report.clusters.length} clusters, ` +
+          `${report.functionsAnalyzed} functions in ${report.filesScanned} files in ${report.duration}ms`
+        );
+
+        if (report.clusters.length === 0) {
+          vscode.window.showInformationMessage('No duplicate functions found.');
+          return;
+        }
+
+        interface CloneItem extends vscode.QuickPickItem {
+          location?: { uri: string; line: number; character: number };
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items: CloneItem[] = [];
+        report.clusters.forEach((cluster: any, i: number) => {
+          const similarity = cluster.exact ? 'identical' : `${Math.round(cluster.similarity * 100)}% similar`;
+          items.push({
+            label: `Cluster ${i + 1}: ${cluster.members.length} functions, ${similarity}`,
+            kind: vscode.QuickPickItemKind.Separator
+          });
+          for (const member of cluster.members) {
+            const file = workspaceRoot ? path.relative(workspaceRoot, member.location.uri) : member.location.uri;
+            items.push({
+              label: `$(symbol-${member.kind === 'method' ? 'method' : 'function'}) ${member.containerName ? member.containerName + '.' : ''}${member.name}`,
+              description: `${member.endLine - member.startLine + 1} lines, ${member.tokens} tokens`,
+              detail: `${file}:${member.startLine + 1}`,
+              location: member.location
+            });
+          }
+        });
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${report.clusters.length}${report.truncated ? '+' : ''} clusters of duplicated functions`,
+          placeHolder: 'Select a function to open it...',
+          matchOnDescription: true,
+          matchOnDetail: true
+        });
+
+        if (selected?.location) {
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(selected.location.uri));
+          const cloneEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(selected.location.line, selected.location.character);
+          cloneEditor.selection = new vscode.Selection(position, position);
+          cloneEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to find duplicate code:', error);
+        vscode.window.showErrorMessage(`Failed to find duplicate code: ${error}`);
+      }
+    })
+  );
+
+  // Command: Rank functions by complexity and size
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.functionMetrics', async () => {
+      const sort = await vscode.window.showQuickPick([
+        { label: 'complexity', description: 'Cyclomatic complexity' },
+        { label: 'loc', description: 'Lines of code' },
+        { label: 'nesting', description: 'Deepest control-flow nesting' },
+        { label: 'parameters', description: 'Parameter count' }
+      ], {
+        title: 'Show Function Metrics',
+        placeHolder: 'Rank functions by...'
+      });
+      if (!sort) {
+        return;
+      }
+
+      logChannel.info(`[Client] ========== FUNCTION METRICS COMMAND: sort by ${sort.label} ==========`);
+      try {
+        const report = await client.sendRequest('smart-indexer/functionMetrics', {
+          sort: sort.label,
+          limit: 50,
+          includeTests: false
+        }) as any;
+        logChannel.info(`[Client] ${report.matched} of ${report.functionsAnalyzed} functions ranked in ${report.duration}ms`);
+
+        if (report.functions.length === 0) {
+          vscode.window.showInformationMessage('No function metrics in the index yet. Rebuild the index to compute them.');
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items = report.functions.map((entry: any) => ({
+          label: `$(symbol-${entry.kind === 'method' ? 'method' : 'function'}) ${entry.containerName ? entry.containerName + '.' : ''}${entry.name}`,
+          description: `complexity ${entry.complexity} · ${entry.loc} lines · ${entry.parameters} params · depth ${entry.nesting}`,
+          detail: `${workspaceRoot ? path.relative(workspaceRoot, entry.location.uri) : entry.location.uri}:${entry.location.line + 1}`,
+          entry
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Top ${report.functions.length} of ${report.functionsAnalyzed} functions by ${sort.label}`,
+          placeHolder: 'Select a function to open it...',
+          matchOnDetail: true
+        }) as any;
+
+        if (selected) {
+          const { location } = selected.entry;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(location.uri));
+          const metricsEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(location.line, location.character);
+          metricsEditor.selection = new vscode.Selection(position, position);
+          metricsEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to get function metrics:', error);
+        vscode.window.showErrorMessage(`Failed to get function metrics: ${error}`);
+      }
+    })
+  );
+
+  // Command: Code and API per CODEOWNERS owner
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.codeOwners', async () => {
+      logChannel.info('[Client] ========== CODE OWNERS COMMAND ==========');
+      try {
+        const report = await client.sendRequest('smart-indexer/ownership', { includeTests: false }) as any;
+        logChannel.info(`[Client] ${report.owners.length} owners from ${report.codeOwnersFile ?? 'no CODEOWNERS file'} in ${report.duration}ms`);
+
+        if (!report.codeOwnersFile) {
+          vscode.window.showInformationMessage('No CODEOWNERS file found (.github/, root, docs/ or .gitlab/).');
+          return;
+        }
+
+        const describe = (summary: any) =>
+          `${summary.files} files · ${summary.symbols} definitions · ${summary.exported} exported`;
+        const items: any[] = report.owners.map((summary: any) => ({
+          label: `$(organization) ${summary.owner}`,
+          description: describe(summary),
+          owner: summary.owner
+        }));
+        if (report.unowned.files > 0) {
+          items.push(
+            { label: '', kind: vscode.QuickPickItemKind.Separator },
+            { label: '$(question) No owner', description: describe(report.unowned), owner: 'none' }
+          );
+        }
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `Code owners (${report.codeOwnersFile})`,
+          placeHolder: 'Select an owner to list their exported API...'
+        });
+        if (!selected) {
+          return;
+        }
+
+        const result = await client.sendRequest('smart-indexer/query', {
+          query: `exported:true owner:${selected.owner} -tag:test,generated`,
+          limit: 500
+        }) as any;
+        if (result.symbols.length === 0) {
+          vscode.window.showInformationMessage(`No exported definitions owned by ${selected.owner}.`);
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const symbolItems = result.symbols.map((symbol: any) => ({
+          label: `${symbol.containerName ? symbol.containerName + '.' : ''}${symbol.name}`,
+          description: `${symbol.kind}${symbol.signature ? ' ' + symbol.signature : ''}`,
+          detail: `${workspaceRoot ? path.relative(workspaceRoot, symbol.location.uri) : symbol.location.uri}:${symbol.location.line + 1}`,
+          symbol
+        }));
+        const symbolSelected = await vscode.window.showQuickPick(symbolItems, {
+          title: `${result.symbols.length}${result.truncated ? '+' : ''} exported definitions owned by ${selected.owner}`,
+          placeHolder: 'Select a definition to open it...',
+          matchOnDescription: true
+        }) as any;
+
+        if (symbolSelected) {
+          const { location } = symbolSelected.symbol;
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(location.uri));
+          const ownerEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(location.line, location.character);
+          ownerEditor.selection = new vscode.Selection(position, position);
+          ownerEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to show code owners:', error);
+        vscode.window.showErrorMessage(`Failed to show code owners: ${error}`);
+      }
+    })
+  );
+
+  // Command: Syntax errors the indexer recovered from
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showParseErrors', async () => {
+      logChannel.info('[Client] ========== PARSE ERRORS COMMAND ==========');
+      try {
+        const report = await client.sendRequest('smart-indexer/parseErrors', { limit: 200 }) as any;
+        logChannel.info(`[Client] ${report.errors} parse errors in ${report.files.length} files in ${report.duration}ms`);
+
+        if (report.files.length === 0) {
+          vscode.window.showInformationMessage('No parse errors in the index.');
+          return;
+        }
+
+        const workspaceRoot = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+        const items = report.files.flatMap((file: any) => file.diagnostics.map((diagnostic: any) => ({
+          label: `$(error) ${diagnostic.message}`,
+          detail: `${workspaceRoot ? path.relative(workspaceRoot, file.uri) : file.uri}:${diagnostic.line + 1}:${diagnostic.character + 1}`,
+          uri: file.uri,
+          diagnostic
+        })));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${report.errors} parse errors in ${report.files.length}${report.truncated ? '+' : ''} files (the rest of each file is indexed)`,
+          placeHolder: 'Select an error to open it...',
+          matchOnDetail: true
+        }) as any;
+
+        if (selected) {
+          const document = await vscode.workspace.openTextDocument(vscode.Uri.file(selected.uri));
+          const errorEditor = await vscode.window.showTextDocument(document);
+          const position = new vscode.Position(selected.diagnostic.line, selected.diagnostic.character);
+          errorEditor.selection = new vscode.Selection(position, position);
+          errorEditor.revealRange(new vscode.Range(position, position), vscode.TextEditorRevealType.InCenter);
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to list parse errors:', error);
+        vscode.window.showErrorMessage(`Failed to list parse errors: ${error}`);
+      }
+    })
+  );
+
+  // Command: Show Workspace Roots
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.showWorkspaceRoots', async () => {
+      try {
+        const roots = await client.sendRequest('smart-indexer/workspaceRoots') as any[];
+        logChannel.info(`[Client] ${roots.length} workspace roots`);
+
+        if (roots.length === 0) {
+          vscode.window.showInformationMessage('No workspace folders are indexed.');
+          return;
+        }
+
+        const items = roots.map(root => ({
+          label: `$(root-folder) ${root.name}`,
+          description: `${root.files} files, ${root.symbols} symbols`,
+          detail: `${root.path}${root.indexedAt ? ` - indexed ${new Date(root.indexedAt).toLocaleString()}` : ''}`,
+          root
+        }));
+
+        const selected = await vscode.window.showQuickPick(items, {
+          title: `${roots.length} workspace roots in one index`,
+          placeHolder: 'Select a root to reveal it in the explorer...',
+          matchOnDetail: true
+        }) as any;
+
+        if (selected) {
+          await vscode.commands.executeCommand('revealInExplorer', vscode.Uri.file(selected.root.path));
+        }
+      } catch (error) {
+        logChannel.error('[Client] Failed to list workspace roots:', error);
+        vscode.window.showErrorMessage(`Failed to list workspace roots: ${error}`);
+      }
+    })
+  );
+
+  // Command: Update the index from the git changes since a commit
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.updateSinceCommit', async () => {
+      const since = await vscode.window.showInputBox({
+        title: 'Update index since commit',
+        prompt: 'Commit, branch or tag the index was up to date at; files changed since (committed or not) are indexed again',
+        value: 'HEAD',
+        ignoreFocusOut: true
+      });
+      if (!since) {
+        return;
+      }
+      try {
+        const result = await client.sendRequest('smart-indexer/updateSince', { since }) as any;
+        logChannel.info(`[Client] Updated index since ${result.since}: ${result.indexed} indexed, ${result.removed} removed`);
+        vscode.window.showInformationMessage(
+          `Index updated since ${result.since.slice(0, 12)}: ${result.indexed} files indexed, ${result.removed} removed (${result.duration}ms).`
+        );
+      } catch (error) {
+        logChannel.error('[Client] Failed to update index from git:', error);
+        vscode.window.showErrorMessage(`Failed to update index: ${error}`);
+      }
+    })
+  );
+
+  // Commands: Pull / Push the prebuilt index (smartIndexer.remoteIndex.url)
+  async function askRemoteIndexUrl(action: string): Promise<string | undefined> {
+    return vscode.window.showInputBox({
+      title: `${action} index`,
+      prompt: 's3://bucket/key, gs://bucket/key or http(s):// URL of the index',
+      value: vscode.workspace.getConfiguration('smartIndexer').get<string>('remoteIndex.url', ''),
+      ignoreFocusOut: true
+    });
+  }
+
+  context.subscriptions.push(
+    vscode.commands.registerCommand('smart-indexer.pullIndex', async () => {
+      const url = await askRemoteIndexUrl('Pull');
+      if (!url) {
+        return;
+      }
+      try {
+        const result = await client.sendRequest('smart-indexer/pullIndex', { url }) as any;
+        logChannel.info(`[Client] Pulled ${result.imported} of ${result.files} files from ${url}`);
+        vscode.window.showInformationMessage(
+          `Pulled ${result.imported} of ${result.files} files from the index built ${new Date(result.createdAt).toLocaleString()}` +
+          (result.changed > 0 ? `; ${result.changed} files changed locally are indexed here.` : '.')
+        );
+      } catch (error) {
+        logChannel.error('[Client] Failed to pull index:', error);
+        vscode.window.showErrorMessage(`Failed to pull index: ${error}`);
+      }
+    }),
+    vscode.commands.registerCommand('smart-indexer.pushIndex', async () => {
+      const url = await askRemoteIndexUrl('Push');
+      if (!url) {
+        return;
+      }
+      try {
+        const result = await client.sendRequest('smart-indexer/pushIndex', { url }) as any;
+        logChannel.info(`[Client] Pushed ${result.files} files to ${url}`);
+        vscode.window.showInformationMessage(
+          `Pushed ${result.files} files (${(result.compressedBytes / 1024 / 1024).toFixed(1)} MB) to ${url}.`
+        );
+      } catch (error) {
+        logChannel.error('[Client] Failed to push index:', error);
+        vscode.window.showErrorMessage(`Failed to push index: ${error}`);
+      }
+    })
+  );
+
   // Command: Find Dead Code in Folder (context menu)
   context.subscriptions.push(
     vscode.commands.registerCommand('smart-indexer.findDeadCodeInFolder', async (folderUri: vscode.Uri) => {
@@ -8,6 +8,17 @@ export interface IndexProgress {
   processed: number;
   total: number;
   currentFile?: string;
+  /** Throughput and ETA of the current run (server/src/utils/indexingProgress.ts) */
+  details?: IndexingDetails;
+}
+
+/**
+ * The part of the server's IndexingProgress the status bar shows.
+ */
+export interface IndexingDetails {
+  filesPerSecond: number;
+  bytesProcessed: number;
+  etaMs?: number;
 }
 
 /**
@@ -39,7 +50,7 @@ export class SmartIndexerStatusBar {
   updateProgress(progress: IndexProgress): void {
     switch (progress.state) {
       case 'busy':
-        this.setBusy(progress.total - progress.processed, progress.currentFile);
+        this.setBusy(progress.total - progress.processed, progress.currentFile, progress.details);
         break;
       case 'finalizing':
         this.setFinalizing();
@@ -66,9 +77,10 @@ export class SmartIndexerStatusBar {
   /**
    * Set status bar to busy (indexing) state.
    */
-  setBusy(remaining: number, currentFile?: string): void {
+  setBusy(remaining: number, currentFile?: string, details?: IndexingDetails): void {
     this.state = 'busy';
-    this.statusBarItem.text = `$(sync~spin) Indexing... (${remaining} remaining)`;
+    const eta = details?.etaMs !== undefined ? `, ~${formatEta(details.etaMs)}` : '';
+    this.statusBarItem.text = `$(sync~spin) Indexing... (${remaining} remaining${eta})`;
     
     let tooltip = 'Smart Indexer: Processing background queue';
     if (currentFile) {
@@ -76,6 +88,10 @@ export class SmartIndexerStatusBar {
       const fileName = currentFile.split(/[\\/]/).pop() || currentFile;
       tooltip += `\nCurrently: ${fileName}`;
     }
+    if (details && details.filesPerSecond > 0) {
+      const megabytes = (details.bytesProcessed / (1024 * 1024)).toFixed(1);
+      tooltip += `\n${Math.round(details.filesPerSecond)} files/s, ${megabytes} MB indexed`;
+    }
     tooltip += '\nClick for options';
     
     this.statusBarItem.tooltip = tooltip;
@@ -118,3 +134,15 @@ export class SmartIndexerStatusBar {
     this.statusBarItem.dispose();
   }
 }
+
+/**
+ * Coarse remaining time for the status bar: "45s", "3m", "1h 5m".
+ */
+function formatEta(ms: number): string {
+  const seconds = Math.max(1, Math.round(ms / 1000));
+  if (seconds < 60) {
+    return `${seconds}s`;
+  }
+  const minutes = Math.round(seconds / 60);
+  return minutes < 60 ? `${minutes}m` : `${Math.floor(minutes / 60)}h ${minutes % 60}m`;
+}